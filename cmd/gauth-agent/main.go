@@ -0,0 +1,150 @@
+// Package main implements gauth-agent, a sidecar that obtains tokens for a
+// co-located workload and keeps them fresh.
+//
+// The workload reads its token either from a file or over a Unix socket:
+//
+//	curl --unix-socket /run/gauth/agent.sock http://agent/token
+//
+// and reports rejected tokens with POST /invalidate to force a refresh, at
+// most once per -invalidate-interval.
+//
+// With -servers the token and introspection requests are spread over several
+// authorization servers, e.g. one per region, and fail over between them:
+//
+//	gauth-agent -token-url https://auth/token -servers https://eu.auth=3,https://us.auth=1
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/agent"
+)
+
+func main() {
+	var (
+		tokenURL         = flag.String("token-url", "", "OAuth 2.0 token endpoint")
+		introspectionURL = flag.String("introspection-url", "", "RFC 7662 introspection endpoint used to detect revocation (optional)")
+		clientID         = flag.String("client-id", os.Getenv("GAUTH_CLIENT_ID"), "client ID (or GAUTH_CLIENT_ID)")
+		secretFile       = flag.String("client-secret-file", "", "file containing the client secret (or GAUTH_CLIENT_SECRET)")
+		scopes           = flag.String("scopes", "", "space-separated scopes to request")
+		socketPath       = flag.String("socket", "/run/gauth/agent.sock", "Unix socket to serve tokens on (empty to disable)")
+		filePath         = flag.String("file", "", "file to write the access token to (optional)")
+		refreshFraction  = flag.Float64("refresh-fraction", 0.8, "fraction of token lifetime after which to refresh")
+		revocationPoll   = flag.Duration("revocation-interval", 30*time.Second, "how often to check for revocation")
+		invalidateLimit  = flag.Duration("invalidate-interval", 5*time.Second, "minimum time between refreshes forced through POST /invalidate")
+		servers          = flag.String("servers", "", "comma-separated authorization server base URLs with optional =weight; the token and introspection paths are sent to them (optional)")
+		strategy         = flag.String("strategy", string(agent.WeightedRoundRobin), "how to pick among -servers: weighted_round_robin or least_connections")
+		healthPath       = flag.String("health-path", "", "path probed on each of -servers to re-admit ejected ones (optional)")
+	)
+	flag.Parse()
+
+	if *tokenURL == "" || *clientID == "" {
+		log.Fatal("gauth-agent: -token-url and -client-id are required")
+	}
+	if *socketPath == "" && *filePath == "" {
+		log.Fatal("gauth-agent: at least one of -socket or -file is required")
+	}
+
+	secret := os.Getenv("GAUTH_CLIENT_SECRET")
+	if *secretFile != "" {
+		data, err := os.ReadFile(*secretFile)
+		if err != nil {
+			log.Fatalf("gauth-agent: failed to read client secret: %v", err)
+		}
+		secret = strings.TrimSpace(string(data))
+	}
+
+	var balancer *agent.Balancer
+	if *servers != "" {
+		endpoints, err := parseEndpoints(*servers)
+		if err != nil {
+			log.Fatalf("gauth-agent: %v", err)
+		}
+		balancer, err = agent.NewBalancer(agent.BalancerConfig{
+			Endpoints:  endpoints,
+			Strategy:   agent.Strategy(*strategy),
+			HealthPath: *healthPath,
+			Logf:       log.Printf,
+		})
+		if err != nil {
+			log.Fatalf("gauth-agent: %v", err)
+		}
+	}
+
+	source := &agent.ClientCredentialsSource{
+		TokenURL:     *tokenURL,
+		ClientID:     *clientID,
+		ClientSecret: secret,
+		Scopes:       strings.Fields(*scopes),
+	}
+	if balancer != nil {
+		source.Client = balancer.Client(10 * time.Second)
+	}
+	config := agent.Config{
+		Source:                source,
+		RefreshFraction:       *refreshFraction,
+		SocketPath:            *socketPath,
+		MinInvalidateInterval: *invalidateLimit,
+		FilePath:              *filePath,
+		RevocationInterval:    *revocationPoll,
+		Logf:                  log.Printf,
+	}
+	if *introspectionURL != "" {
+		checker := &agent.IntrospectionChecker{
+			IntrospectionURL: *introspectionURL,
+			ClientID:         *clientID,
+			ClientSecret:     secret,
+		}
+		if balancer != nil {
+			checker.Client = balancer.Client(10 * time.Second)
+		}
+		config.Revocation = checker
+	}
+
+	a, err := agent.New(config)
+	if err != nil {
+		log.Fatalf("gauth-agent: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if balancer != nil {
+		go balancer.Run(ctx)
+	}
+
+	log.Printf("gauth-agent: started (socket=%q file=%q)", *socketPath, *filePath)
+	if err := a.Run(ctx); err != nil {
+		log.Fatalf("gauth-agent: %v", err)
+	}
+	log.Print("gauth-agent: stopped")
+}
+
+// parseEndpoints parses "url[=weight],..."
+func parseEndpoints(spec string) ([]agent.Endpoint, error) {
+	var endpoints []agent.Endpoint
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		endpoint := agent.Endpoint{URL: item}
+		if i := strings.LastIndex(item, "="); i > 0 {
+			weight, err := strconv.Atoi(item[i+1:])
+			if err != nil || weight <= 0 {
+				return nil, fmt.Errorf("invalid weight in -servers entry %q", item)
+			}
+			endpoint = agent.Endpoint{URL: item[:i], Weight: weight}
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, nil
+}
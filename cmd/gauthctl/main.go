@@ -0,0 +1,444 @@
+// Package main implements gauthctl, an operator tool for GAuth deployments.
+//
+// The doctor command runs the startup self-test against the given
+// configuration and exits non-zero if any check fails:
+//
+//	gauthctl doctor -redis localhost:6379 -key signing.pem -config gauth.json
+//
+// The report includes the fingerprint of the -config document, to compare
+// with the config_fingerprint a running server reports.
+//
+// With -deprecations it also lists the deprecated APIs and configuration
+// options a running server still uses, read from the report it serves with
+// deprecation.Registry.Handler:
+//
+//	gauthctl doctor -deprecations http://localhost:8080/debug/deprecations
+//
+// The migrate-tokens command rewrites stored tokens in the current schema.
+// Run it with -dry-run first to see what would change:
+//
+//	gauthctl migrate-tokens -redis localhost:6379 -prefix gauth: -dry-run
+//	gauthctl migrate-tokens -postgres "postgres://gauth@localhost/gauth" -dry-run
+//
+// The conformance command runs the OAuth2/OIDC and RFC111/RFC115
+// conformance cases against a deployment and prints the result matrix:
+//
+//	gauthctl conformance -base https://auth.example.com -client-id ci -json
+//
+// The vectors command writes the extended token test vectors, or checks a
+// vector file against this implementation:
+//
+//	gauthctl vectors -out test/vectors/extended_tokens.json
+//	gauthctl vectors -verify test/vectors/extended_tokens.json
+//
+// The monitoring command generates a Grafana dashboard and Prometheus
+// alerting rules from the metrics GAuth exports, or lists those metrics:
+//
+//	gauthctl monitoring -dashboard gauth-dashboard.json -alerts gauth-rules.yml -selector 'job="gauth"'
+//	gauthctl monitoring -list
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	_ "github.com/lib/pq"
+
+	"github.com/Gimel-Foundation/gauth/pkg/conformance"
+	"github.com/Gimel-Foundation/gauth/pkg/deprecation"
+	"github.com/Gimel-Foundation/gauth/pkg/doctor"
+	"github.com/Gimel-Foundation/gauth/pkg/metrics"
+	"github.com/Gimel-Foundation/gauth/pkg/monitoring"
+	"github.com/Gimel-Foundation/gauth/pkg/provision"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "doctor":
+		os.Exit(runDoctor(os.Args[2:]))
+	case "migrate-tokens":
+		os.Exit(runMigrateTokens(os.Args[2:]))
+	case "conformance":
+		os.Exit(runConformance(os.Args[2:]))
+	case "vectors":
+		os.Exit(runVectors(os.Args[2:]))
+	case "monitoring":
+		os.Exit(runMonitoring(os.Args[2:]))
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: gauthctl doctor|migrate-tokens|conformance|vectors|monitoring [flags]")
+	os.Exit(2)
+}
+
+func runDoctor(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	var (
+		redisAddr  = fs.String("redis", "", "Redis address to check (optional)")
+		postgres   = fs.String("postgres", "", "PostgreSQL DSN to check (optional)")
+		keyFile    = fs.String("key", "", "PEM signing key to check (optional)")
+		certFile   = fs.String("cert", "", "PEM certificate to check for expiry (optional)")
+		certWarn   = fs.Duration("cert-warn", 30*24*time.Hour, "warn when the certificate expires within this period")
+		configFile = fs.String("config", "", "JSON provisioning document to check (optional)")
+		ntpServer  = fs.String("ntp", "pool.ntp.org:123", "NTP server for the clock check (empty to skip)")
+		maxSkew    = fs.Duration("max-skew", 2*time.Second, "maximum tolerated clock offset")
+		timeout    = fs.Duration("timeout", 10*time.Second, "timeout per check")
+		deprecated = fs.String("deprecations", "", "URL of a server's deprecation report to list deprecated features in use (optional)")
+	)
+	_ = fs.Parse(args)
+
+	d := doctor.New()
+	d.Timeout = *timeout
+
+	if *redisAddr != "" {
+		client := redis.NewClient(&redis.Options{Addr: *redisAddr})
+		defer client.Close()
+		d.Register(doctor.RedisCheck(client))
+	}
+	if *postgres != "" {
+		db, err := sql.Open("postgres", *postgres)
+		if err != nil {
+			log.Fatalf("gauthctl: %v", err)
+		}
+		defer db.Close()
+		d.Register(doctor.SQLCheck(db))
+	}
+	if *keyFile != "" {
+		key, err := loadKey(*keyFile)
+		if err != nil {
+			log.Fatalf("gauthctl: %v", err)
+		}
+		d.Register(doctor.KeyCheck("signing key", key))
+	}
+	if *certFile != "" {
+		cert, err := loadCertificate(*certFile)
+		if err != nil {
+			log.Fatalf("gauthctl: %v", err)
+		}
+		d.Register(doctor.CertificateCheck("certificate", cert, *certWarn))
+	}
+	if *configFile != "" {
+		data, err := os.ReadFile(*configFile)
+		if err != nil {
+			log.Fatalf("gauthctl: failed to read config: %v", err)
+		}
+		var doc provision.Document
+		if err := json.Unmarshal(data, &doc); err != nil {
+			log.Fatalf("gauthctl: failed to parse config: %v", err)
+		}
+		d.Register(doctor.PolicyCheck(&doc))
+		d.Register(doctor.ExclusionCheck(&doc))
+		fingerprint, err := doctor.Fingerprint(&doc)
+		if err != nil {
+			log.Fatalf("gauthctl: %v", err)
+		}
+		d.Register(doctor.ConfigCheck(fingerprint))
+	}
+	if *deprecated != "" {
+		d.Register(doctor.DeprecationCheck(func(ctx context.Context) ([]deprecation.Usage, error) {
+			return deprecation.FetchUsages(ctx, nil, *deprecated)
+		}))
+	}
+	if *ntpServer != "" {
+		d.Register(doctor.ClockCheck(&token.SNTPReference{Server: *ntpServer}, *maxSkew))
+	}
+
+	report := d.Run(context.Background())
+	if err := report.Write(os.Stdout); err != nil {
+		log.Fatalf("gauthctl: %v", err)
+	}
+	if !report.Healthy() {
+		return 1
+	}
+	return 0
+}
+
+func runMigrateTokens(args []string) int {
+	fs := flag.NewFlagSet("migrate-tokens", flag.ExitOnError)
+	var (
+		redisAddr = fs.String("redis", "", "Redis address of the token store")
+		postgres  = fs.String("postgres", "", "PostgreSQL DSN of the token store")
+		password  = fs.String("password", os.Getenv("GAUTH_REDIS_PASSWORD"), "Redis password (or GAUTH_REDIS_PASSWORD)")
+		prefix    = fs.String("prefix", "", "token key prefix")
+		dryRun    = fs.Bool("dry-run", false, "report what would be migrated without writing")
+	)
+	_ = fs.Parse(args)
+
+	var (
+		store interface {
+			token.RawTokenStore
+			Close() error
+		}
+		err error
+	)
+	switch {
+	case *redisAddr != "" && *postgres != "":
+		log.Fatal("gauthctl: use only one of -redis and -postgres")
+	case *redisAddr != "":
+		store, err = token.NewRedisStore(token.RedisConfig{
+			Addresses: []string{*redisAddr},
+			Password:  *password,
+			KeyPrefix: *prefix,
+		})
+	case *postgres != "":
+		store, err = token.NewSQLStore(token.SQLStoreConfig{DSN: *postgres})
+	default:
+		log.Fatal("gauthctl: -redis or -postgres is required")
+	}
+	if err != nil {
+		log.Fatalf("gauthctl: %v", err)
+	}
+	defer store.Close()
+
+	report, err := token.MigrateStore(context.Background(), store, *dryRun)
+	if err != nil {
+		log.Printf("gauthctl: migration stopped: %v", err)
+	}
+	fmt.Printf("scanned %d, migrated %d, already current %d, failed %d (dry run: %t)\n",
+		report.Scanned, report.Migrated, report.Current, len(report.Failed), report.DryRun)
+	for _, key := range report.FailedKeys() {
+		fmt.Printf("  %s: %s\n", key, report.Failed[key])
+	}
+	if err != nil || len(report.Failed) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func runConformance(args []string) int {
+	fs := flag.NewFlagSet("conformance", flag.ExitOnError)
+	var (
+		base         = fs.String("base", "", "base URL; fills in endpoints not given explicitly")
+		tokenURL     = fs.String("token-url", "", "token endpoint (default: <base>/token)")
+		introspect   = fs.String("introspection-url", "", "introspection endpoint (default: <base>/introspect)")
+		revoke       = fs.String("revocation-url", "", "revocation endpoint (default: <base>/revoke)")
+		discovery    = fs.String("discovery-url", "", "discovery document (default: <base>/.well-known/openid-configuration)")
+		clientID     = fs.String("client-id", "", "client ID")
+		clientSecret = fs.String("client-secret", os.Getenv("GAUTH_CLIENT_SECRET"), "client secret (or GAUTH_CLIENT_SECRET)")
+		scope        = fs.String("scope", "", "a scope the client is granted")
+		excessScope  = fs.String("excess-scope", "", "a scope the client is not granted")
+		only         = fs.String("only", "", "comma-separated case ID or spec substrings to run")
+		asJSON       = fs.Bool("json", false, "print the matrix as JSON")
+	)
+	_ = fs.Parse(args)
+
+	orDefault := func(v, path string) string {
+		if v != "" || *base == "" {
+			return v
+		}
+		return strings.TrimSuffix(*base, "/") + path
+	}
+	target := &conformance.Target{
+		TokenURL:         orDefault(*tokenURL, "/token"),
+		IntrospectionURL: orDefault(*introspect, "/introspect"),
+		RevocationURL:    orDefault(*revoke, "/revoke"),
+		DiscoveryURL:     orDefault(*discovery, "/.well-known/openid-configuration"),
+		ClientID:         *clientID,
+		ClientSecret:     *clientSecret,
+		Scope:            *scope,
+		ExcessScope:      *excessScope,
+	}
+
+	suite := conformance.DefaultSuite()
+	if *only != "" {
+		suite = suite.Filter(strings.Split(*only, ",")...)
+	}
+	matrix := suite.Run(context.Background(), target)
+
+	var err error
+	if *asJSON {
+		err = matrix.WriteJSON(os.Stdout)
+	} else {
+		err = matrix.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Printf("gauthctl: %v", err)
+		return 1
+	}
+	if !matrix.Conformant() {
+		return 1
+	}
+	return 0
+}
+
+func runVectors(args []string) int {
+	fs := flag.NewFlagSet("vectors", flag.ExitOnError)
+	var (
+		out    = fs.String("out", "", "file to write generated vectors to (default: stdout)")
+		verify = fs.String("verify", "", "vector file to check instead of generating")
+	)
+	_ = fs.Parse(args)
+
+	if *verify != "" {
+		data, err := os.ReadFile(*verify)
+		if err != nil {
+			log.Printf("gauthctl: %v", err)
+			return 1
+		}
+		var set token.VectorSet
+		if err := json.Unmarshal(data, &set); err != nil {
+			log.Printf("gauthctl: failed to parse %s: %v", *verify, err)
+			return 1
+		}
+		results, err := set.Verify()
+		if err != nil {
+			log.Printf("gauthctl: %v", err)
+			return 1
+		}
+		failed := 0
+		for _, r := range results {
+			status := "PASS"
+			if !r.Passed {
+				status = "FAIL"
+				failed++
+			}
+			fmt.Printf("%-4s %-24s expected=%q got=%q %s\n", status, r.Name, r.Expected, r.Got, r.Detail)
+		}
+		fmt.Printf("%d vectors, %d failed\n", len(results), failed)
+		if failed > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	set, err := token.GenerateVectors()
+	if err != nil {
+		log.Printf("gauthctl: %v", err)
+		return 1
+	}
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Printf("gauthctl: %v", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := set.WriteJSON(w); err != nil {
+		log.Printf("gauthctl: %v", err)
+		return 1
+	}
+	return 0
+}
+
+func runMonitoring(args []string) int {
+	fs := flag.NewFlagSet("monitoring", flag.ExitOnError)
+	var (
+		dashboard = fs.String("dashboard", "", "file to write the Grafana dashboard to (- for stdout)")
+		alerts    = fs.String("alerts", "", "file to write the Prometheus alerting rules to (- for stdout)")
+		selector  = fs.String("selector", "", `label matcher added to every query, e.g. job="gauth"`)
+		list      = fs.Bool("list", false, "list the exported metrics as JSON")
+	)
+	_ = fs.Parse(args)
+
+	defs := metrics.Definitions()
+	if *list {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(defs); err != nil {
+			log.Printf("gauthctl: %v", err)
+			return 1
+		}
+		return 0
+	}
+	if *dashboard == "" && *alerts == "" {
+		fmt.Fprintln(os.Stderr, "gauthctl monitoring: -dashboard, -alerts or -list is required")
+		fs.Usage()
+		return 2
+	}
+
+	if *dashboard != "" {
+		data, err := monitoring.GrafanaDashboard(defs, monitoring.DashboardConfig{Selector: *selector})
+		if err == nil {
+			err = writeOutput(*dashboard, data)
+		}
+		if err != nil {
+			log.Printf("gauthctl: %v", err)
+			return 1
+		}
+	}
+	if *alerts != "" {
+		data, err := monitoring.AlertRules(defs, monitoring.AlertRulesConfig{Selector: *selector})
+		if err == nil {
+			err = writeOutput(*alerts, data)
+		}
+		if err != nil {
+			log.Printf("gauthctl: %v", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// writeOutput writes generated data to a file, or to stdout for "-"
+func writeOutput(path string, data []byte) error {
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func loadKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported private key in %s", path)
+}
+
+func loadCertificate(path string) (*x509.Certificate, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return cert, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found in " + path)
+	}
+	return block, nil
+}
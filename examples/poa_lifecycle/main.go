@@ -0,0 +1,235 @@
+// Example: end-to-end power-of-attorney lifecycle.
+//
+// A principal (the CFO of Acme) grants a procurement agent the power to buy
+// from approved vendors up to a per-transaction limit. The example walks the
+// whole lifecycle against real package components:
+//
+//  1. grant creation       authz policy with a transaction limit
+//  2. attestation          principal signs the grant; issuance verifies it
+//  3. token issuance       token.Service with an issuance pipeline
+//  4. transaction checks   token validation plus authz decisions
+//  5. revocation           token revoked, further use rejected
+//  6. audit query          audit.FileStorage searched by correlation ID
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/audit"
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+const (
+	principal = "acme-cfo"
+	agent     = "procurement-agent"
+	grantID   = "poa-2024-001"
+	limit     = 10000
+)
+
+// principalKey stands in for the principal's attestation key
+var principalKey = []byte("acme-cfo-attestation-key")
+
+// amountLimit is an authz condition capping the transaction amount
+type amountLimit struct {
+	max float64
+}
+
+func (c amountLimit) Evaluate(_ context.Context, request *authz.AccessRequest) (bool, error) {
+	amount, err := strconv.ParseFloat(request.Context["amount"], 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid amount: %w", err)
+	}
+	return amount <= c.max, nil
+}
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatalf("poa_lifecycle: %v", err)
+	}
+}
+
+func run() error {
+	ctx := events.ContextWithCorrelationID(context.Background(), events.NewCorrelationID())
+
+	auditDir, err := os.MkdirTemp("", "poa-audit-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(auditDir)
+	trail, err := audit.NewFileStorage(audit.FileConfig{Directory: auditDir})
+	if err != nil {
+		return err
+	}
+	defer trail.Close()
+
+	// 1. Grant creation: the principal's power of attorney becomes a policy
+	fmt.Println("=== 1. Grant ===")
+	pdp := authz.NewMemoryAuthorizer()
+	if err := pdp.AddPolicy(ctx, &authz.Policy{
+		ID:          grantID,
+		Name:        "Procurement power of attorney",
+		Description: fmt.Sprintf("%s may purchase from approved vendors up to %d", agent, limit),
+		Effect:      authz.Allow,
+		Subjects:    []authz.Subject{{ID: agent}},
+		Resources:   []authz.Resource{{ID: "/vendors/*"}},
+		Actions:     []authz.Action{{Name: "purchase"}},
+		Conditions:  map[string]authz.Condition{"amount_limit": amountLimit{max: limit}},
+	}); err != nil {
+		return fmt.Errorf("failed to create grant: %w", err)
+	}
+	record(ctx, trail, principal, "grant_created", grantID, audit.ResultSuccess, "limit", strconv.Itoa(limit))
+	fmt.Printf("%s granted %s purchase power up to %d (grant %s)\n", principal, agent, limit, grantID)
+
+	// 2. Attestation: the principal signs the grant
+	fmt.Println("\n=== 2. Attestation ===")
+	attestation := attest(grantID, agent)
+	fmt.Printf("principal attestation: %s...\n", attestation[:16])
+
+	// 3. Token issuance, guarded by scope policy and attestation checks
+	fmt.Println("\n=== 3. Issuance ===")
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	checks := token.NewIssuancePipeline(
+		&token.ScopePolicyCheck{Allowed: map[token.Type][]string{token.Access: {"purchase"}}},
+		&token.AttestationCheck{Verify: func(_ context.Context, t *token.Token, evidence string) error {
+			if !hmac.Equal([]byte(evidence), []byte(attest(t.Metadata.AppData["grant_id"], t.Subject))) {
+				return errors.New("attestation does not match grant")
+			}
+			return nil
+		}},
+	)
+	svc := token.NewService(token.Config{
+		SigningKey:     key,
+		ValidityPeriod: time.Hour,
+		IssuanceChecks: checks,
+	}, token.NewMemoryStore(time.Hour))
+
+	if _, err := svc.Issue(ctx, poaToken(grantID, "forged")); err != nil {
+		fmt.Printf("forged attestation rejected: %v\n", err)
+		record(ctx, trail, agent, "token_issue", grantID, "denied", "reason", err.Error())
+	}
+	tok, err := svc.Issue(ctx, poaToken(grantID, attestation))
+	if err != nil {
+		return fmt.Errorf("failed to issue token: %w", err)
+	}
+	record(ctx, trail, agent, audit.ActionTokenGenerate, tok.ID, audit.ResultSuccess, "grant_id", grantID)
+	fmt.Printf("issued token %s to %s, expires %s\n", tok.ID, tok.Subject, tok.ExpiresAt.Format(time.RFC3339))
+
+	// 4. Transactions: validate the token, then ask the PDP
+	fmt.Println("\n=== 4. Transactions ===")
+	for _, tx := range []struct {
+		resource string
+		amount   int
+	}{{"/vendors/office-supplies", 2500}, {"/vendors/office-supplies", 25000}, {"/payroll/march", 100}} {
+		allowed, reason, err := transact(ctx, svc, pdp, tok, tx.resource, tx.amount)
+		if err != nil {
+			return err
+		}
+		result := "denied"
+		if allowed {
+			result = audit.ResultSuccess
+		}
+		record(ctx, trail, agent, "purchase", tx.resource, result, "amount", strconv.Itoa(tx.amount))
+		fmt.Printf("purchase %6d from %-25s allowed=%-5t (%s)\n", tx.amount, tx.resource, allowed, reason)
+	}
+
+	// 5. Revocation
+	fmt.Println("\n=== 5. Revocation ===")
+	if err := svc.Revoke(ctx, tok, token.ReasonMandateExpired); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	record(ctx, trail, principal, audit.ActionTokenRevoke, tok.ID, audit.ResultSuccess, "reason", string(token.ReasonMandateExpired))
+	if _, _, err := transact(ctx, svc, pdp, tok, "/vendors/office-supplies", 10); err != nil {
+		fmt.Printf("purchase after revocation rejected: %v\n", err)
+	}
+
+	// 6. Audit query: everything that happened in this flow
+	fmt.Println("\n=== 6. Audit trail ===")
+	entries, err := trail.Search(ctx, &audit.Filter{CorrelationID: events.CorrelationIDFromContext(ctx)})
+	if err != nil {
+		return fmt.Errorf("failed to query audit trail: %w", err)
+	}
+	for _, e := range entries {
+		fmt.Printf("%-18s %-14s %-24s %s\n", e.ActorID, e.Action, e.TargetID, e.Result)
+	}
+	fmt.Printf("%d audit entries for correlation %s\n", len(entries), events.CorrelationIDFromContext(ctx))
+	return nil
+}
+
+// attest is the principal's signature over a grant and its delegate
+func attest(grant, delegate string) string {
+	mac := hmac.New(sha256.New, principalKey)
+	mac.Write([]byte(grant + "|" + delegate))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func poaToken(grant, attestation string) *token.Token {
+	return &token.Token{
+		ID:      token.NewID(),
+		Type:    token.Access,
+		Subject: agent,
+		Scopes:  []string{"purchase"},
+		Metadata: &token.Metadata{AppData: map[string]string{
+			"grant_id":    grant,
+			"principal":   principal,
+			"attestation": attestation,
+		}},
+	}
+}
+
+// transact validates the token and authorizes one purchase. A token that
+// fails validation is returned as an error.
+func transact(ctx context.Context, svc token.ServiceAPI, pdp authz.Authorizer, tok *token.Token, resource string, amount int) (bool, string, error) {
+	if err := svc.Validate(ctx, tok); err != nil {
+		return false, "", fmt.Errorf("token rejected: %w", err)
+	}
+	req := authz.NewAccessRequest(authz.Subject{ID: tok.Subject}, authz.Resource{ID: resource}, authz.Action{Name: "purchase"})
+	req.Context = map[string]string{"amount": strconv.Itoa(amount)}
+	resp, err := authzRequest(ctx, pdp, req)
+	if err != nil {
+		return false, "", err
+	}
+	return resp.Allowed, resp.Reason, nil
+}
+
+// authzRequest evaluates a request with context attributes, which the
+// Authorizer interface does not carry
+func authzRequest(ctx context.Context, pdp authz.Authorizer, req *authz.AccessRequest) (*authz.AccessResponse, error) {
+	evaluator, ok := pdp.(interface {
+		IsAllowed(ctx context.Context, request *authz.AccessRequest) (*authz.AccessResponse, error)
+	})
+	if !ok {
+		return nil, errors.New("authorizer cannot evaluate request context")
+	}
+	return evaluator.IsAllowed(ctx, req)
+}
+
+func record(ctx context.Context, trail audit.Storage, actor, action, target, result string, kv ...string) {
+	e := audit.NewEntry(audit.TypeToken).
+		WithActor(actor, audit.ActorUser).
+		WithAction(action).
+		WithTarget(target, "poa").
+		WithResult(result).
+		WithContext(ctx)
+	for i := 0; i+1 < len(kv); i += 2 {
+		e.WithMetadata(kv[i], kv[i+1])
+	}
+	if err := trail.Store(ctx, e); err != nil {
+		log.Printf("poa_lifecycle: failed to record audit entry: %v", err)
+	}
+}
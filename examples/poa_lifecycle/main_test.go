@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestMainExample runs the lifecycle and checks each stage produced its outcome
+func TestMainExample(t *testing.T) {
+	origStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	err := run()
+
+	w.Close()
+	os.Stdout = origStdout
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	output := buf.String()
+
+	if err != nil {
+		t.Fatalf("run() error: %v\n%s", err, output)
+	}
+	for _, want := range []string{
+		"forged attestation rejected",
+		"purchase   2500 from /vendors/office-supplies  allowed=true",
+		"purchase  25000 from /vendors/office-supplies  allowed=false",
+		"purchase after revocation rejected",
+		"7 audit entries",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+}
@@ -94,7 +94,7 @@ func main() {
 	fmt.Printf("Found %d matching tokens\n", len(tokens))
 
 	// Revoke token
-	if err := tokenService.Revoke(ctx, issuedToken); err != nil {
+	if err := tokenService.Revoke(ctx, issuedToken, token.ReasonPrincipalRequest); err != nil {
 		log.Fatalf("Failed to revoke token: %v", err)
 	}
 
@@ -19,6 +19,7 @@ import (
 	"github.com/Gimel-Foundation/gauth/gauth-demo-app/web/backend/handlers"
 	"github.com/Gimel-Foundation/gauth/gauth-demo-app/web/backend/middleware"
 	"github.com/Gimel-Foundation/gauth/gauth-demo-app/web/backend/services"
+	"github.com/Gimel-Foundation/gauth/pkg/monitoring"
 )
 
 // @title GAuth Demo API
@@ -158,7 +159,14 @@ func setupRouter(svc *services.GAuthService, logger *logrus.Logger, config *vipe
 	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
 	router.Use(cors.New(corsConfig))
 
-	// Health check
+	// Health checks; the demo runs without Redis, so the cache only degrades
+	health := monitoring.NewHealthRegistry(monitoring.HealthConfig{})
+	_ = health.Register(monitoring.Probe{Name: "redis", Optional: true, Check: svc.Ping})
+	router.GET("/healthz", gin.WrapH(health.LivenessHandler()))
+	router.GET("/readyz", gin.WrapH(health.ReadinessHandler()))
+
+	// Kept in its original shape for existing clients; use /readyz for
+	// probe details
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"status":    "ok",
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -45,6 +46,14 @@ func NewGAuthService(config *viper.Viper, logger *logrus.Logger) (*GAuthService,
 	}, nil
 }
 
+// Ping checks the Redis cache, for health probes
+func (s *GAuthService) Ping(ctx context.Context) error {
+	if s.redis == nil {
+		return errors.New("redis unavailable, running without cache")
+	}
+	return s.redis.Ping(ctx).Err()
+}
+
 // Client represents a client application
 type Client struct {
 	ID           string   `json:"id"`
@@ -0,0 +1,405 @@
+// Package natsconn is a minimal NATS client speaking the core text protocol.
+// It is shared by the audit NATS publisher and the JetStream event
+// transport, so the module does not depend on the NATS client library.
+package natsconn
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrNoResponders reports a 503 status: nothing listens on the subject
+var ErrNoResponders = errors.New("no responders")
+
+// Config configures a connection
+type Config struct {
+	// Address is the host:port of the NATS server
+	Address string
+
+	// User and Password, or Token, authenticate the connection (optional)
+	User     string
+	Password string
+	Token    string
+
+	// TLS is used when the server requires TLS (optional)
+	TLS *tls.Config
+
+	// Timeout bounds dialing, the handshake and each write (default: 5s)
+	Timeout time.Duration
+
+	// Name identifies the client to the server
+	Name string
+
+	// Headers requires a server with header support and enables
+	// no-responders statuses
+	Headers bool
+}
+
+// Msg is a message delivered to the connection's inbox
+type Msg struct {
+	Subject string
+	Reply   string
+	Status  string
+	Data    []byte
+}
+
+// Conn is a NATS connection whose replies all arrive on one wildcard inbox
+// subscription and are routed to waiting requests by subject. A background
+// loop answers server PINGs; once the connection fails it stays closed and
+// the caller dials a new one.
+type Conn struct {
+	conn    net.Conn
+	timeout time.Duration
+	inbox   string
+	wmu     sync.Mutex
+
+	mu      sync.Mutex
+	waiting map[string]chan Msg
+	pongs   []chan struct{}
+	err     error
+	done    chan struct{}
+}
+
+// info holds the INFO fields the client needs
+type info struct {
+	TLSRequired bool `json:"tls_required"`
+	Headers     bool `json:"headers"`
+}
+
+// Dial connects, upgrades to TLS if required, authenticates, subscribes to
+// the inbox and starts the read loop
+func Dial(ctx context.Context, config Config) (*Conn, error) {
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	dialer := net.Dialer{Timeout: config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("nats connect: %w", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(config.Timeout))
+
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return nil, fmt.Errorf("nats connect: no INFO from server: %v", err)
+	}
+	var srv info
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(line), "INFO ")), &srv); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("nats connect: invalid INFO: %w", err)
+	}
+	if config.Headers && !srv.Headers {
+		conn.Close()
+		return nil, errors.New("nats connect: server does not support headers")
+	}
+	if srv.TLSRequired || config.TLS != nil {
+		tlsConfig := config.TLS
+		if tlsConfig == nil {
+			host, _, _ := net.SplitHostPort(config.Address)
+			tlsConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("nats connect: %w", err)
+		}
+		conn = tlsConn
+		reader = bufio.NewReader(conn)
+	}
+
+	c := &Conn{
+		conn:    conn,
+		timeout: config.Timeout,
+		inbox:   "_INBOX." + strings.ReplaceAll(uuid.New().String(), "-", ""),
+		waiting: make(map[string]chan Msg),
+		done:    make(chan struct{}),
+	}
+	options := map[string]interface{}{
+		"verbose":      false,
+		"pedantic":     false,
+		"tls_required": srv.TLSRequired || config.TLS != nil,
+		"name":         config.Name,
+		"lang":         "go",
+		"version":      "1.0.0",
+		"protocol":     1,
+		"user":         config.User,
+		"pass":         config.Password,
+		"auth_token":   config.Token,
+	}
+	if config.Headers {
+		options["headers"] = true
+		options["no_responders"] = true
+	}
+	connect, _ := json.Marshal(options)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nSUB %s.* 1\r\nPING\r\n", connect, c.inbox); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("nats connect: %w", err)
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("nats connect: %w", err)
+		}
+		if line = strings.TrimRight(line, "\r\n"); line == "PONG" {
+			break
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			conn.Close()
+			return nil, fmt.Errorf("nats connect: %w", serverError(line))
+		}
+	}
+	_ = conn.SetDeadline(time.Time{})
+	go c.readLoop(reader)
+	return c, nil
+}
+
+// readLoop answers PINGs, completes flushes and routes inbox messages until
+// the connection fails
+func (c *Conn) readLoop(reader *bufio.Reader) {
+	err := func() error {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return err
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch {
+			case line == "PING":
+				if err := c.write([]byte("PONG\r\n")); err != nil {
+					return err
+				}
+			case line == "PONG":
+				c.pong()
+			case strings.HasPrefix(line, "-ERR"):
+				return serverError(line)
+			case strings.HasPrefix(line, "MSG "), strings.HasPrefix(line, "HMSG "):
+				m, err := readMsg(reader, line)
+				if err != nil {
+					return err
+				}
+				c.route(m)
+			}
+		}
+	}()
+	c.mu.Lock()
+	c.err = err
+	c.mu.Unlock()
+	close(c.done)
+	c.conn.Close()
+}
+
+func serverError(line string) error {
+	return fmt.Errorf("server error %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+}
+
+// readMsg reads the payload of a MSG or HMSG and splits off the headers
+func readMsg(reader *bufio.Reader, line string) (Msg, error) {
+	fields := strings.Fields(line)
+	headers := fields[0] == "HMSG"
+	// MSG subject sid [reply] size; HMSG subject sid [reply] hsize size
+	n := 4
+	if headers {
+		n = 5
+	}
+	if len(fields) != n && len(fields) != n+1 {
+		return Msg{}, fmt.Errorf("malformed %s", fields[0])
+	}
+	m := Msg{Subject: fields[1]}
+	if len(fields) == n+1 {
+		m.Reply = fields[3]
+	}
+	size, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		return Msg{}, fmt.Errorf("malformed %s", fields[0])
+	}
+	hsize := 0
+	if headers {
+		if hsize, err = strconv.Atoi(fields[len(fields)-2]); err != nil || hsize > size {
+			return Msg{}, fmt.Errorf("malformed %s", fields[0])
+		}
+	}
+	buf := make([]byte, size+2)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return Msg{}, err
+	}
+	if hsize > 0 {
+		// NATS/1.0 [status [description]]
+		status, _, _ := strings.Cut(string(buf[:hsize]), "\r\n")
+		if f := strings.Fields(status); len(f) > 1 {
+			m.Status = f[1]
+		}
+	}
+	m.Data = buf[hsize:size]
+	return m, nil
+}
+
+// route hands a message to the request waiting on its subject; messages
+// nobody waits for any more are dropped
+func (c *Conn) route(m Msg) {
+	c.mu.Lock()
+	ch := c.waiting[m.Subject]
+	c.mu.Unlock()
+	if ch != nil {
+		select {
+		case ch <- m:
+		default:
+		}
+	}
+}
+
+// pong completes the oldest outstanding Flush; the server answers PINGs in
+// order
+func (c *Conn) pong() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pongs) > 0 {
+		close(c.pongs[0])
+		c.pongs = c.pongs[1:]
+	}
+}
+
+// Flush returns once the server has processed everything written before it
+func (c *Conn) Flush(ctx context.Context) error {
+	ch := make(chan struct{})
+	c.mu.Lock()
+	c.pongs = append(c.pongs, ch)
+	c.mu.Unlock()
+	if err := c.write([]byte("PING\r\n")); err != nil {
+		return err
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		return c.lost()
+	}
+}
+
+// Fetch publishes a request with a fresh reply subject and passes replies
+// to fn until max arrived, a 404, 408 or 409 status ends the batch, or wait
+// elapses
+func (c *Conn) Fetch(ctx context.Context, subject, header string, data []byte, max int, wait time.Duration, fn func(Msg)) error {
+	reply := c.inbox + "." + strings.ReplaceAll(uuid.New().String(), "-", "")
+	ch := make(chan Msg, max+1)
+	c.mu.Lock()
+	c.waiting[reply] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.waiting, reply)
+		c.mu.Unlock()
+	}()
+
+	if err := c.PublishRequest(subject, reply, header, data); err != nil {
+		return err
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for n := 0; n < max; {
+		select {
+		case m := <-ch:
+			switch m.Status {
+			case "":
+				fn(m)
+				n++
+			case "100":
+				// heartbeat
+			case "404", "408", "409":
+				return nil
+			case "503":
+				return ErrNoResponders
+			default:
+				return fmt.Errorf("status %s", m.Status)
+			}
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.done:
+			return c.lost()
+		}
+	}
+	return nil
+}
+
+// Publish sends a message without a reply subject
+func (c *Conn) Publish(subject, header string, data []byte) error {
+	return c.PublishRequest(subject, "", header, data)
+}
+
+// PublishRequest sends a message; header is a single "Name: value" line or
+// empty
+func (c *Conn) PublishRequest(subject, reply, header string, data []byte) error {
+	if reply != "" {
+		subject += " " + reply
+	}
+	var b strings.Builder
+	if header == "" {
+		fmt.Fprintf(&b, "PUB %s %d\r\n", subject, len(data))
+	} else {
+		h := "NATS/1.0\r\n" + header + "\r\n\r\n"
+		fmt.Fprintf(&b, "HPUB %s %d %d\r\n%s", subject, len(h), len(h)+len(data), h)
+	}
+	b.Write(data)
+	b.WriteString("\r\n")
+	return c.write([]byte(b.String()))
+}
+
+func (c *Conn) write(p []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err := c.conn.Write(p)
+	return err
+}
+
+func (c *Conn) lost() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return fmt.Errorf("connection lost: %w", c.err)
+}
+
+// Alive reports whether the read loop is still running
+func (c *Conn) Alive() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Close closes the connection
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// SubjectToken turns s into a single subject token
+func SubjectToken(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '.' || r == '*' || r == '>' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,184 @@
+package natsconn
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer speaks enough of the NATS protocol to exercise Conn: it echoes
+// requests on "echo", answers "none" with a 503 status and fails on "bad"
+type fakeServer struct {
+	ln        net.Listener
+	mu        sync.Mutex
+	connect   string
+	published []string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error: %v", err)
+	}
+	s := &fakeServer{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	fmt.Fprint(conn, "INFO {\"headers\":true}\r\n")
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "CONNECT":
+			s.mu.Lock()
+			s.connect = strings.TrimSpace(strings.TrimPrefix(line, "CONNECT"))
+			s.mu.Unlock()
+		case "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+		case "PUB", "HPUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			buf := make([]byte, size+2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			data := buf[:size]
+			if fields[0] == "HPUB" {
+				hsize, _ := strconv.Atoi(fields[len(fields)-2])
+				data = data[hsize:]
+			}
+			subject := fields[1]
+			s.mu.Lock()
+			s.published = append(s.published, subject+" "+string(data))
+			s.mu.Unlock()
+			reply := ""
+			if (fields[0] == "PUB" && len(fields) == 4) || (fields[0] == "HPUB" && len(fields) == 5) {
+				reply = fields[2]
+			}
+			switch subject {
+			case "echo":
+				fmt.Fprintf(conn, "MSG %s 1 %d\r\n%s\r\n", reply, len(data), data)
+			case "none":
+				h := "NATS/1.0 503\r\n\r\n"
+				fmt.Fprintf(conn, "HMSG %s 1 %d %d\r\n%s\r\n", reply, len(h), len(h), h)
+			case "bad":
+				fmt.Fprint(conn, "-ERR 'Permissions Violation'\r\n")
+			}
+		}
+	}
+}
+
+func (s *fakeServer) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.published...)
+}
+
+func TestConn(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeServer(t)
+
+	c, err := Dial(ctx, Config{Address: srv.ln.Addr().String(), Name: "test", Token: "secret", Headers: true})
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+	defer c.Close()
+
+	t.Run("Connect Options", func(t *testing.T) {
+		srv.mu.Lock()
+		connect := srv.connect
+		srv.mu.Unlock()
+		for _, want := range []string{`"name":"test"`, `"auth_token":"secret"`, `"headers":true`} {
+			if !strings.Contains(connect, want) {
+				t.Errorf("Expected CONNECT to contain %s, got %s", want, connect)
+			}
+		}
+	})
+
+	t.Run("Publish And Flush", func(t *testing.T) {
+		if err := c.Publish("audit.token", "", []byte("one")); err != nil {
+			t.Fatalf("Publish() error: %v", err)
+		}
+		if err := c.Flush(ctx); err != nil {
+			t.Fatalf("Flush() error: %v", err)
+		}
+		if got := srv.messages(); len(got) != 1 || got[0] != "audit.token one" {
+			t.Errorf("Expected the message to be processed before Flush returned, got %v", got)
+		}
+	})
+
+	t.Run("Request", func(t *testing.T) {
+		var replies []string
+		err := c.Fetch(ctx, "echo", "Nats-Msg-Id: 1", []byte("hello"), 1, time.Second, func(m Msg) {
+			replies = append(replies, string(m.Data))
+		})
+		if err != nil {
+			t.Fatalf("Fetch() error: %v", err)
+		}
+		if len(replies) != 1 || replies[0] != "hello" {
+			t.Errorf("Expected the echoed reply, got %v", replies)
+		}
+	})
+
+	t.Run("No Responders", func(t *testing.T) {
+		err := c.Fetch(ctx, "none", "", nil, 1, time.Second, func(Msg) {})
+		if !errors.Is(err, ErrNoResponders) {
+			t.Errorf("Expected ErrNoResponders, got %v", err)
+		}
+	})
+
+	t.Run("Server Error Closes", func(t *testing.T) {
+		if err := c.Publish("bad", "", []byte("x")); err != nil {
+			t.Fatalf("Publish() error: %v", err)
+		}
+		if err := c.Flush(ctx); err == nil || !strings.Contains(err.Error(), "Permissions Violation") {
+			t.Errorf("Expected the server error from Flush, got %v", err)
+		}
+		if c.Alive() {
+			t.Error("Expected the connection to be dead after -ERR")
+		}
+	})
+}
+
+func TestSubjectToken(t *testing.T) {
+	tests := map[string]string{
+		"":            "unknown",
+		"token":       "token",
+		"auth.login":  "auth_login",
+		"a*b>c d":     "a_b_c_d",
+		"résumé":      "r_sum_",
+		"tab\there":   "tab_here",
+		"plain_token": "plain_token",
+	}
+	for in, want := range tests {
+		if got := SubjectToken(in); got != want {
+			t.Errorf("SubjectToken(%q): expected %q, got %q", in, want, got)
+		}
+	}
+}
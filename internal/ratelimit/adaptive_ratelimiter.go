@@ -24,6 +24,10 @@ type AdaptiveConfig struct {
 
 	// ScaleDownFactor determines how quickly to decrease limits when capacity limited
 	ScaleDownFactor float64
+
+	// OnAdjust is called with the old and new limit and the average usage
+	// whenever the limit changes
+	OnAdjust func(oldLimit, newLimit int, avgUsage float64)
 }
 
 // AdaptiveRateLimiter implements a rate limiter that can adapt to system load
@@ -95,6 +99,7 @@ func (a *AdaptiveRateLimiter) adjustLimits() {
 		sum += usage
 	}
 	avgUsage := sum / float64(len(a.usageHistory))
+	oldLimit := a.currentLimit
 
 	// Adjust limits based on usage
 	if avgUsage > 0.8 {
@@ -114,6 +119,10 @@ func (a *AdaptiveRateLimiter) adjustLimits() {
 			a.currentLimit = a.config.MaxLimit
 		}
 	}
+
+	if a.currentLimit != oldLimit && a.config.OnAdjust != nil {
+		a.config.OnAdjust(oldLimit, a.currentLimit, avgUsage)
+	}
 }
 
 // GetCurrentLimit returns the current request limit
@@ -134,3 +143,25 @@ func (a *AdaptiveRateLimiter) GetUsage() float64 {
 
 	return float64(a.requestCount) / float64(a.currentLimit)
 }
+
+// Remaining returns the requests left in the current window and when the
+// window resets
+func (a *AdaptiveRateLimiter) Remaining() (int, time.Time) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	remaining := a.currentLimit - a.requestCount
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, a.lastReset.Add(a.config.Window)
+}
+
+// Reset clears the current window's count without changing the limit
+func (a *AdaptiveRateLimiter) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.requestCount = 0
+	a.lastReset = time.Now()
+}
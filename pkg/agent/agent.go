@@ -0,0 +1,410 @@
+// Package agent implements a token agent that fetches and refreshes tokens on
+// behalf of co-located workloads. The agent exposes the current token over a
+// local Unix socket and/or a file with owner-only permissions, so application
+// code never has to handle credentials or refresh logic itself.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	autherrors "github.com/Gimel-Foundation/gauth/pkg/errors"
+)
+
+// Agent errors
+var (
+	// ErrNoToken indicates the agent has not obtained a token yet
+	ErrNoToken = errors.New("no token available")
+
+	// ErrTokenRevoked indicates the current token was revoked upstream
+	ErrTokenRevoked = errors.New("token revoked")
+)
+
+// Token is the credential handed to workloads
+type Token struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	Scope       string    `json:"scope,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	IssuedAt    time.Time `json:"issued_at"`
+}
+
+// Source obtains new tokens from the authorization server
+type Source interface {
+	Fetch(ctx context.Context) (*Token, error)
+}
+
+// RevocationChecker reports whether a token has been revoked upstream
+type RevocationChecker interface {
+	Revoked(ctx context.Context, tok *Token) (bool, error)
+}
+
+// Config configures the agent
+type Config struct {
+	// Source fetches tokens
+	Source Source
+
+	// RefreshFraction is the fraction of a token's lifetime after which it is
+	// refreshed (default: 0.8)
+	RefreshFraction float64
+
+	// MinRefreshInterval bounds how often the agent refreshes (default: 5s)
+	MinRefreshInterval time.Duration
+
+	// RetryInterval is the wait after a failed fetch that carries no retry
+	// hint of its own (default: 5s)
+	RetryInterval time.Duration
+
+	// MaxRetryInterval caps the backoff between failed fetches; errors the
+	// server marks as not retryable wait this long (default: 5m)
+	MaxRetryInterval time.Duration
+
+	// SocketPath, if set, serves the token over HTTP on a Unix socket
+	// (mode 0600)
+	SocketPath string
+
+	// MinInvalidateInterval bounds how often workloads may force a refresh
+	// through POST /invalidate; earlier requests are answered 429
+	// (default: MinRefreshInterval)
+	MinInvalidateInterval time.Duration
+
+	// FilePath, if set, writes the access token to this file (mode 0600)
+	FilePath string
+
+	// Revocation optionally checks whether the cached token was revoked
+	Revocation RevocationChecker
+
+	// RevocationInterval is how often Revocation is polled (default: 30s)
+	RevocationInterval time.Duration
+
+	// Logf receives diagnostic messages (optional)
+	Logf func(format string, args ...interface{})
+}
+
+// Agent caches a token and keeps it fresh
+type Agent struct {
+	config      Config
+	mu          sync.RWMutex
+	current     *Token
+	invalidated time.Time
+	wake        chan struct{}
+}
+
+// New creates an agent
+func New(config Config) (*Agent, error) {
+	if config.Source == nil {
+		return nil, errors.New("token source is required")
+	}
+	if config.RefreshFraction <= 0 || config.RefreshFraction >= 1 {
+		config.RefreshFraction = 0.8
+	}
+	if config.MinRefreshInterval <= 0 {
+		config.MinRefreshInterval = 5 * time.Second
+	}
+	if config.MinInvalidateInterval <= 0 {
+		config.MinInvalidateInterval = config.MinRefreshInterval
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = 5 * time.Second
+	}
+	if config.MaxRetryInterval < config.RetryInterval {
+		config.MaxRetryInterval = 5 * time.Minute
+	}
+	if config.RevocationInterval <= 0 {
+		config.RevocationInterval = 30 * time.Second
+	}
+	if config.Logf == nil {
+		config.Logf = func(string, ...interface{}) {}
+	}
+	return &Agent{config: config, wake: make(chan struct{}, 1)}, nil
+}
+
+// Token returns the cached token if it is still valid
+func (a *Agent) Token() (*Token, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.current == nil || !time.Now().Before(a.current.ExpiresAt) {
+		return nil, ErrNoToken
+	}
+	tok := *a.current
+	return &tok, nil
+}
+
+// Invalidate drops the cached token and triggers an immediate refresh. It is
+// called when a workload reports a rejected token or a revocation is detected.
+func (a *Agent) Invalidate() {
+	a.mu.Lock()
+	a.current = nil
+	a.mu.Unlock()
+	if a.config.FilePath != "" {
+		_ = os.Remove(a.config.FilePath)
+	}
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// requestInvalidate invalidates on behalf of a workload unless one did so
+// within MinInvalidateInterval, returning the time left otherwise
+func (a *Agent) requestInvalidate() time.Duration {
+	a.mu.Lock()
+	if wait := time.Until(a.invalidated.Add(a.config.MinInvalidateInterval)); wait > 0 {
+		a.mu.Unlock()
+		return wait
+	}
+	a.invalidated = time.Now()
+	a.mu.Unlock()
+	a.Invalidate()
+	return 0
+}
+
+// Refresh fetches a new token and publishes it
+func (a *Agent) Refresh(ctx context.Context) error {
+	tok, err := a.config.Source.Fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch token: %w", err)
+	}
+	if tok.IssuedAt.IsZero() {
+		tok.IssuedAt = time.Now()
+	}
+
+	a.mu.Lock()
+	a.current = tok
+	a.mu.Unlock()
+
+	if a.config.FilePath != "" {
+		if err := writeTokenFile(a.config.FilePath, tok.AccessToken); err != nil {
+			return err
+		}
+	}
+	a.config.Logf("token refreshed, expires at %s", tok.ExpiresAt.Format(time.RFC3339))
+	return nil
+}
+
+// Run refreshes the token until ctx is cancelled, serving it on the
+// configured socket and file
+func (a *Agent) Run(ctx context.Context) error {
+	if a.config.SocketPath != "" {
+		srv, err := a.serveSocket()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			srv.Close()
+			_ = os.Remove(a.config.SocketPath)
+		}()
+	}
+	if a.config.Revocation != nil {
+		go a.watchRevocation(ctx)
+	}
+
+	failures := 0
+	for {
+		var wait time.Duration
+		if err := a.Refresh(ctx); err != nil {
+			wait = a.retryWait(err, failures)
+			failures++
+			a.config.Logf("%v (retrying in %s)", err, wait)
+		} else {
+			failures = 0
+			wait = a.nextRefresh()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if a.config.FilePath != "" {
+				_ = os.Remove(a.config.FilePath)
+			}
+			return nil
+		case <-a.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryWait honors the retry hints of structured errors: the server's
+// Retry-After, the code's backoff, or MaxRetryInterval for errors that will
+// not heal by themselves. Other errors back off from RetryInterval.
+func (a *Agent) retryWait(err error, attempt int) time.Duration {
+	var authErr *autherrors.Error
+	if errors.As(err, &authErr) {
+		// The server's own Retry-After is never shortened
+		if authErr.Retryable && authErr.RetryAfter > 0 {
+			return authErr.RetryAfter
+		}
+		if delay, ok := autherrors.RetryDelay(err, attempt); ok {
+			return min(delay, a.config.MaxRetryInterval)
+		}
+		return a.config.MaxRetryInterval
+	}
+	wait := a.config.RetryInterval
+	for i := 0; i < attempt && wait < a.config.MaxRetryInterval; i++ {
+		wait *= 2
+	}
+	return min(wait, a.config.MaxRetryInterval)
+}
+
+func (a *Agent) nextRefresh() time.Duration {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.current == nil {
+		return a.config.RetryInterval
+	}
+	lifetime := a.current.ExpiresAt.Sub(a.current.IssuedAt)
+	refreshAt := a.current.IssuedAt.Add(time.Duration(float64(lifetime) * a.config.RefreshFraction))
+	wait := time.Until(refreshAt)
+	if wait < a.config.MinRefreshInterval {
+		wait = a.config.MinRefreshInterval
+	}
+	return wait
+}
+
+func (a *Agent) watchRevocation(ctx context.Context) {
+	ticker := time.NewTicker(a.config.RevocationInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			tok, err := a.Token()
+			if err != nil {
+				continue
+			}
+			revoked, err := a.config.Revocation.Revoked(ctx, tok)
+			if err != nil {
+				a.config.Logf("revocation check failed: %v", err)
+				continue
+			}
+			if revoked {
+				a.config.Logf("%v, fetching a new one", ErrTokenRevoked)
+				a.Invalidate()
+			}
+		}
+	}
+}
+
+// Handler returns the HTTP API served on the Unix socket:
+//
+//	GET  /token       current token as JSON (503 if none)
+//	POST /invalidate  drop the cached token and refresh now (429 if another
+//	                  request did within MinInvalidateInterval)
+//	GET  /healthz     200 when a valid token is cached
+func (a *Agent) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		tok, err := a.Token()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(tok)
+	})
+	mux.HandleFunc("/invalidate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if wait := a.requestInvalidate(); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "invalidated too recently", http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		if _, err := a.Token(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux
+}
+
+// serveSocket binds the socket inside a fresh owner-only directory and
+// renames it into place once its mode is 0600, so it is never reachable
+// with looser permissions
+func (a *Agent) serveSocket() (*http.Server, error) {
+	path := a.config.SocketPath
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	private, err := os.MkdirTemp(dir, ".agent-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	defer os.RemoveAll(private)
+
+	tmp := filepath.Join(private, "agent.sock")
+	ln, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if ul, ok := ln.(*net.UnixListener); ok {
+		ul.SetUnlinkOnClose(false)
+	}
+	if err := os.Chmod(tmp, 0o600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to move socket to %s: %w", path, err)
+	}
+
+	srv := &http.Server{Handler: a.Handler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.config.Logf("socket server stopped: %v", err)
+		}
+	}()
+	return srv, nil
+}
+
+// writeTokenFile atomically replaces path with an owner-only file
+func writeTokenFile(path, value string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create token directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".token-*")
+	if err != nil {
+		return fmt.Errorf("failed to create token file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.WriteString(value); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,216 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAgent(t *testing.T) {
+	var issued int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "workload" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		n := atomic.AddInt32(&issued, 1)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token-%d", n),
+			"token_type":   "Bearer",
+			"expires_in":   300,
+		})
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	a, err := New(Config{
+		Source: &ClientCredentialsSource{
+			TokenURL:     server.URL,
+			ClientID:     "workload",
+			ClientSecret: "s3cret",
+		},
+		FilePath: tokenFile,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	t.Run("Refresh Writes File", func(t *testing.T) {
+		if err := a.Refresh(context.Background()); err != nil {
+			t.Fatalf("Refresh() error: %v", err)
+		}
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			t.Fatalf("ReadFile() error: %v", err)
+		}
+		if string(data) != "token-1" {
+			t.Errorf("Expected token-1, got %q", data)
+		}
+		info, _ := os.Stat(tokenFile)
+		if info.Mode().Perm() != 0o600 {
+			t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+		}
+		if wait := a.nextRefresh(); wait < 3*time.Minute || wait > 4*time.Minute {
+			t.Errorf("Expected refresh in about 4m, got %s", wait)
+		}
+	})
+
+	t.Run("Handler", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		a.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/token", nil))
+		var tok Token
+		if err := json.NewDecoder(rec.Body).Decode(&tok); err != nil {
+			t.Fatalf("Decode() error: %v", err)
+		}
+		if tok.AccessToken != "token-1" {
+			t.Errorf("Expected token-1, got %q", tok.AccessToken)
+		}
+
+		rec = httptest.NewRecorder()
+		a.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/invalidate", nil))
+		if rec.Code != http.StatusAccepted {
+			t.Errorf("Expected 202, got %d", rec.Code)
+		}
+		if _, err := a.Token(); err != ErrNoToken {
+			t.Errorf("Expected ErrNoToken after invalidation, got %v", err)
+		}
+
+		rec = httptest.NewRecorder()
+		a.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/invalidate", nil))
+		if rec.Code != http.StatusTooManyRequests {
+			t.Errorf("Expected repeated invalidation to be throttled, got %d", rec.Code)
+		}
+		if rec.Header().Get("Retry-After") == "" {
+			t.Error("Expected Retry-After on throttled invalidation")
+		}
+	})
+}
+
+func TestAgentSocket(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "token-1",
+			"token_type":   "Bearer",
+			"expires_in":   300,
+		})
+	}))
+	defer server.Close()
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	a, err := New(Config{
+		Source:     &ClientCredentialsSource{TokenURL: server.URL, ClientID: "workload", ClientSecret: "s3cret"},
+		SocketPath: socket,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- a.Run(ctx) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := client.Get("http://agent/healthz")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Agent did not serve on socket: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("Stat() error: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected socket mode 0600, got %v", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(filepath.Dir(socket))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the socket in its directory, got %d entries", len(entries))
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("Expected socket to be removed on shutdown, got %v", err)
+	}
+}
+
+func TestRetryHints(t *testing.T) {
+	status := int32(http.StatusTooManyRequests)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch atomic.LoadInt32(&status) {
+		case http.StatusTooManyRequests:
+			w.Header().Set("Retry-After", "42")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":"rate_limited","retryable":true}`))
+		case http.StatusBadGateway:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client","retryable":false}`))
+		}
+	}))
+	defer server.Close()
+
+	a, err := New(Config{
+		Source:        &ClientCredentialsSource{TokenURL: server.URL, ClientID: "workload", ClientSecret: "s3cret"},
+		RetryInterval: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	fetch := func() error {
+		err := a.Refresh(context.Background())
+		if err == nil {
+			t.Fatal("Expected refresh to fail")
+		}
+		return err
+	}
+
+	t.Run("Server Retry After", func(t *testing.T) {
+		if wait := a.retryWait(fetch(), 3); wait != 42*time.Second {
+			t.Errorf("Expected Retry-After of 42s, got %s", wait)
+		}
+	})
+
+	t.Run("Backoff For Server Errors", func(t *testing.T) {
+		atomic.StoreInt32(&status, http.StatusBadGateway)
+		err := fetch()
+		if a.retryWait(err, 0) != time.Second || a.retryWait(err, 2) != 4*time.Second {
+			t.Errorf("Expected doubling backoff, got %s and %s", a.retryWait(err, 0), a.retryWait(err, 2))
+		}
+	})
+
+	t.Run("Not Retryable", func(t *testing.T) {
+		atomic.StoreInt32(&status, http.StatusUnauthorized)
+		if wait := a.retryWait(fetch(), 0); wait != 5*time.Minute {
+			t.Errorf("Expected MaxRetryInterval for invalid_client, got %s", wait)
+		}
+	})
+}
@@ -0,0 +1,418 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoEndpoint indicates every endpoint failed or none is configured
+var ErrNoEndpoint = errors.New("no authorization server endpoint available")
+
+// Strategy chooses among the healthy endpoints of a Balancer
+type Strategy string
+
+const (
+	// WeightedRoundRobin spreads requests in proportion to endpoint weights
+	WeightedRoundRobin Strategy = "weighted_round_robin"
+
+	// LeastConnections sends each request to the endpoint with the fewest
+	// requests in flight relative to its weight
+	LeastConnections Strategy = "least_connections"
+)
+
+// Endpoint is one authorization server deployment
+type Endpoint struct {
+	// URL is the server's base URL. Its scheme and host replace those of
+	// each request and its path is prefixed to the request path.
+	URL string
+
+	// Weight is the endpoint's share of traffic (default: 1)
+	Weight int
+}
+
+// EndpointStats describes an endpoint's state
+type EndpointStats struct {
+	URL          string    `json:"url"`
+	Weight       int       `json:"weight"`
+	InFlight     int       `json:"in_flight"`
+	Requests     int64     `json:"requests"`
+	Failures     int64     `json:"failures"`
+	Ejected      bool      `json:"ejected"`
+	EjectedUntil time.Time `json:"ejected_until,omitempty"`
+}
+
+// BalancerConfig configures a Balancer
+type BalancerConfig struct {
+	// Endpoints are the authorization servers, e.g. one per region
+	Endpoints []Endpoint
+
+	// Strategy picks an endpoint per request (default: WeightedRoundRobin)
+	Strategy Strategy
+
+	// Transport sends the requests (default: http.DefaultTransport)
+	Transport http.RoundTripper
+
+	// MaxAttempts is how many endpoints a request may try before it fails
+	// (default: 3, at most the number of endpoints)
+	MaxAttempts int
+
+	// EjectAfter is the number of consecutive failures that ejects an
+	// endpoint (default: 5)
+	EjectAfter int
+
+	// EjectionTime is how long a first ejection lasts; it doubles with each
+	// ejection in a row (default: 30s)
+	EjectionTime time.Duration
+
+	// MaxEjectionTime caps the ejection time (default: 5m)
+	MaxEjectionTime time.Duration
+
+	// MaxEjectedPercent bounds the share of endpoints ejected at once
+	// (default: 50)
+	MaxEjectedPercent int
+
+	// HealthPath, if set, is probed on every endpoint by Run; a 2xx answer
+	// re-admits an ejected endpoint
+	HealthPath string
+
+	// HealthInterval is how often Run probes (default: 10s)
+	HealthInterval time.Duration
+
+	// Logf receives ejection and recovery messages (optional)
+	Logf func(format string, args ...interface{})
+}
+
+// Balancer is an http.RoundTripper that spreads requests over several
+// authorization servers and fails over between them. A request that cannot
+// connect or gets 502, 503 or 504 is retried on another endpoint; endpoints
+// failing repeatedly are ejected for a while (outlier ejection). Use it as
+// the transport of the Client of ClientCredentialsSource or
+// IntrospectionChecker:
+//
+//	lb, _ := agent.NewBalancer(agent.BalancerConfig{Endpoints: endpoints})
+//	source := &agent.ClientCredentialsSource{TokenURL: "https://auth/token", Client: lb.Client(10 * time.Second)}
+type Balancer struct {
+	config    BalancerConfig
+	endpoints []*endpointState
+
+	mu   sync.Mutex
+	next int
+}
+
+type endpointState struct {
+	base   *url.URL
+	weight int
+
+	// guarded by Balancer.mu
+	current      int
+	inFlight     int
+	requests     int64
+	failures     int64
+	consecutive  int
+	ejections    int
+	ejectedUntil time.Time
+}
+
+// NewBalancer creates a balancer
+func NewBalancer(config BalancerConfig) (*Balancer, error) {
+	if len(config.Endpoints) == 0 {
+		return nil, ErrNoEndpoint
+	}
+	switch config.Strategy {
+	case "":
+		config.Strategy = WeightedRoundRobin
+	case WeightedRoundRobin, LeastConnections:
+	default:
+		return nil, fmt.Errorf("unknown strategy %q", config.Strategy)
+	}
+	if config.Transport == nil {
+		config.Transport = http.DefaultTransport
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+	config.MaxAttempts = min(config.MaxAttempts, len(config.Endpoints))
+	if config.EjectAfter <= 0 {
+		config.EjectAfter = 5
+	}
+	if config.EjectionTime <= 0 {
+		config.EjectionTime = 30 * time.Second
+	}
+	if config.MaxEjectionTime < config.EjectionTime {
+		config.MaxEjectionTime = max(5*time.Minute, config.EjectionTime)
+	}
+	if config.MaxEjectedPercent <= 0 || config.MaxEjectedPercent > 100 {
+		config.MaxEjectedPercent = 50
+	}
+	if config.HealthInterval <= 0 {
+		config.HealthInterval = 10 * time.Second
+	}
+	if config.Logf == nil {
+		config.Logf = func(string, ...interface{}) {}
+	}
+
+	b := &Balancer{config: config}
+	for _, e := range config.Endpoints {
+		base, err := url.Parse(e.URL)
+		if err != nil || base.Scheme == "" || base.Host == "" {
+			return nil, fmt.Errorf("invalid endpoint URL %q", e.URL)
+		}
+		base.Path = strings.TrimSuffix(base.Path, "/")
+		weight := e.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		b.endpoints = append(b.endpoints, &endpointState{base: base, weight: weight})
+	}
+	return b, nil
+}
+
+// Client returns an HTTP client sending through the balancer
+func (b *Balancer) Client(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: b, Timeout: timeout}
+}
+
+// RoundTrip sends the request to a healthy endpoint, failing over to others
+func (b *Balancer) RoundTrip(req *http.Request) (*http.Response, error) {
+	tried := make(map[*endpointState]bool, b.config.MaxAttempts)
+	var lastErr error
+	for attempt := 0; attempt < b.config.MaxAttempts; attempt++ {
+		ep := b.pick(tried)
+		if ep == nil {
+			break
+		}
+		tried[ep] = true
+		out, err := b.rewrite(req, ep, attempt)
+		if err != nil {
+			b.release(ep)
+			return nil, err
+		}
+
+		resp, err := b.config.Transport.RoundTrip(out)
+		failed := err != nil || retryableStatus(resp.StatusCode)
+		if req.Context().Err() != nil {
+			// Cancelled by the caller, not the endpoint's fault
+			b.release(ep)
+			return resp, err
+		}
+		b.report(ep, failed)
+
+		last := attempt == b.config.MaxAttempts-1 || (req.Body != nil && req.GetBody == nil)
+		if !failed || last {
+			return resp, err
+		}
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", ep.base.Host, err)
+		} else {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("%s: HTTP %d", ep.base.Host, resp.StatusCode)
+		}
+	}
+	if lastErr == nil {
+		return nil, ErrNoEndpoint
+	}
+	return nil, fmt.Errorf("%w: %v", ErrNoEndpoint, lastErr)
+}
+
+// Stats returns the state of every endpoint
+func (b *Balancer) Stats() []EndpointStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	stats := make([]EndpointStats, len(b.endpoints))
+	for i, ep := range b.endpoints {
+		stats[i] = EndpointStats{
+			URL:      ep.base.String(),
+			Weight:   ep.weight,
+			InFlight: ep.inFlight,
+			Requests: ep.requests,
+			Failures: ep.failures,
+			Ejected:  now.Before(ep.ejectedUntil),
+		}
+		if stats[i].Ejected {
+			stats[i].EjectedUntil = ep.ejectedUntil
+		}
+	}
+	return stats
+}
+
+// Run probes HealthPath on every endpoint until ctx is cancelled. It
+// returns immediately when no health path is configured.
+func (b *Balancer) Run(ctx context.Context) {
+	if b.config.HealthPath == "" {
+		return
+	}
+	ticker := time.NewTicker(b.config.HealthInterval)
+	defer ticker.Stop()
+	for {
+		b.probe(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *Balancer) probe(ctx context.Context) {
+	for _, ep := range b.endpoints {
+		target := *ep.base
+		target.Path += "/" + strings.TrimPrefix(b.config.HealthPath, "/")
+		probeCtx, cancel := context.WithTimeout(ctx, b.config.HealthInterval)
+		req, err := http.NewRequestWithContext(probeCtx, http.MethodGet, target.String(), nil)
+		if err != nil {
+			cancel()
+			continue
+		}
+		resp, err := b.config.Transport.RoundTrip(req)
+		healthy := err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300
+		if err == nil {
+			resp.Body.Close()
+		}
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		b.mu.Lock()
+		if healthy {
+			if !ep.ejectedUntil.IsZero() {
+				b.config.Logf("endpoint %s passed its health check, re-admitted", ep.base.Host)
+			}
+			ep.consecutive, ep.ejections, ep.ejectedUntil = 0, 0, time.Time{}
+		} else {
+			b.fail(ep, time.Now())
+		}
+		b.mu.Unlock()
+	}
+}
+
+// pick chooses an endpoint not yet tried and counts the request in flight.
+// When every untried endpoint is ejected, ejected ones are used rather than
+// failing outright.
+func (b *Balancer) pick(tried map[*endpointState]bool) *endpointState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	var candidates []*endpointState
+	for _, ep := range b.endpoints {
+		if !tried[ep] && !now.Before(ep.ejectedUntil) {
+			candidates = append(candidates, ep)
+		}
+	}
+	if len(candidates) == 0 {
+		for _, ep := range b.endpoints {
+			if !tried[ep] {
+				candidates = append(candidates, ep)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	var chosen *endpointState
+	switch b.config.Strategy {
+	case LeastConnections:
+		// Rotate the starting point so ties are spread
+		b.next++
+		for i := range candidates {
+			ep := candidates[(b.next+i)%len(candidates)]
+			if chosen == nil || ep.inFlight*chosen.weight < chosen.inFlight*ep.weight {
+				chosen = ep
+			}
+		}
+	default:
+		// Smooth weighted round robin
+		total := 0
+		for _, ep := range candidates {
+			ep.current += ep.weight
+			total += ep.weight
+			if chosen == nil || ep.current > chosen.current {
+				chosen = ep
+			}
+		}
+		chosen.current -= total
+	}
+	chosen.inFlight++
+	chosen.requests++
+	return chosen
+}
+
+// report records the outcome of a request and ejects failing endpoints
+func (b *Balancer) report(ep *endpointState, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ep.inFlight--
+	if !failed {
+		ep.consecutive, ep.ejections = 0, 0
+		return
+	}
+	ep.failures++
+	b.fail(ep, time.Now())
+}
+
+func (b *Balancer) release(ep *endpointState) {
+	b.mu.Lock()
+	ep.inFlight--
+	ep.requests--
+	b.mu.Unlock()
+}
+
+// fail counts a failure and ejects the endpoint once it fails EjectAfter
+// times in a row, unless too many are ejected already; b.mu must be held
+func (b *Balancer) fail(ep *endpointState, now time.Time) {
+	ep.consecutive++
+	if ep.consecutive < b.config.EjectAfter || now.Before(ep.ejectedUntil) || len(b.endpoints) < 2 {
+		return
+	}
+	ejected := 0
+	for _, other := range b.endpoints {
+		if now.Before(other.ejectedUntil) {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > b.config.MaxEjectedPercent*len(b.endpoints) && ejected > 0 {
+		return
+	}
+	duration := b.config.EjectionTime << min(ep.ejections, 16)
+	if duration <= 0 || duration > b.config.MaxEjectionTime {
+		duration = b.config.MaxEjectionTime
+	}
+	ep.ejections++
+	ep.consecutive = 0
+	ep.ejectedUntil = now.Add(duration)
+	b.config.Logf("endpoint %s ejected for %s after repeated failures", ep.base.Host, duration)
+}
+
+// rewrite points a copy of req at the endpoint
+func (b *Balancer) rewrite(req *http.Request, ep *endpointState, attempt int) (*http.Request, error) {
+	out := req.Clone(req.Context())
+	out.URL.Scheme = ep.base.Scheme
+	out.URL.Host = ep.base.Host
+	out.URL.Path = ep.base.Path + req.URL.Path
+	if req.URL.RawPath != "" {
+		out.URL.RawPath = ep.base.EscapedPath() + req.URL.RawPath
+	}
+	out.Host = ""
+	if attempt > 0 && req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewind request body: %w", err)
+		}
+		out.Body = body
+	}
+	return out, nil
+}
+
+// retryableStatus reports whether a response means the endpoint, not the
+// request, is at fault
+func retryableStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
@@ -0,0 +1,161 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBalancer(t *testing.T) {
+	newServer := func(hits *int32, status *int32) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(hits, 1)
+			if code := atomic.LoadInt32(status); code != http.StatusOK {
+				w.WriteHeader(int(code))
+				return
+			}
+			if r.URL.Path == "/healthz" {
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			if r.URL.Path != "/oauth2/token" || string(body) != "grant_type=client_credentials" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "tok", "expires_in": 300})
+		}))
+	}
+	var hitsA, hitsB, statusA, statusB int32 = 0, 0, http.StatusOK, http.StatusOK
+	serverA, serverB := newServer(&hitsA, &statusA), newServer(&hitsB, &statusB)
+	defer serverA.Close()
+	defer serverB.Close()
+
+	reset := func() {
+		atomic.StoreInt32(&hitsA, 0)
+		atomic.StoreInt32(&hitsB, 0)
+	}
+	post := func(t *testing.T, client *http.Client) int {
+		t.Helper()
+		resp, err := client.Post("http://auth.invalid/oauth2/token", "application/x-www-form-urlencoded", strings.NewReader("grant_type=client_credentials"))
+		if err != nil {
+			t.Fatalf("Post() error: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	t.Run("Weighted Round Robin", func(t *testing.T) {
+		reset()
+		lb, err := NewBalancer(BalancerConfig{Endpoints: []Endpoint{{URL: serverA.URL, Weight: 3}, {URL: serverB.URL}}})
+		if err != nil {
+			t.Fatalf("NewBalancer() error: %v", err)
+		}
+		client := lb.Client(time.Second)
+		for i := 0; i < 8; i++ {
+			if code := post(t, client); code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d", code)
+			}
+		}
+		if hitsA != 6 || hitsB != 2 {
+			t.Errorf("Expected a 6:2 split, got %d:%d", hitsA, hitsB)
+		}
+	})
+
+	t.Run("Failover And Ejection", func(t *testing.T) {
+		reset()
+		atomic.StoreInt32(&statusA, http.StatusServiceUnavailable)
+		defer atomic.StoreInt32(&statusA, http.StatusOK)
+		lb, err := NewBalancer(BalancerConfig{
+			Endpoints:  []Endpoint{{URL: serverA.URL}, {URL: serverB.URL}},
+			EjectAfter: 2,
+		})
+		if err != nil {
+			t.Fatalf("NewBalancer() error: %v", err)
+		}
+		client := lb.Client(time.Second)
+		for i := 0; i < 6; i++ {
+			if code := post(t, client); code != http.StatusOK {
+				t.Fatalf("Expected failover to succeed, got %d", code)
+			}
+		}
+		if hitsA != 2 || hitsB != 6 {
+			t.Errorf("Expected the failing endpoint ejected after 2 failures, got %d:%d", hitsA, hitsB)
+		}
+		stats := lb.Stats()
+		if !stats[0].Ejected || stats[0].Failures != 2 || stats[1].Ejected {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("All Endpoints Failing", func(t *testing.T) {
+		atomic.StoreInt32(&statusA, http.StatusBadGateway)
+		atomic.StoreInt32(&statusB, http.StatusBadGateway)
+		defer atomic.StoreInt32(&statusA, http.StatusOK)
+		defer atomic.StoreInt32(&statusB, http.StatusOK)
+		lb, err := NewBalancer(BalancerConfig{Endpoints: []Endpoint{{URL: serverA.URL}, {URL: serverB.URL}}, EjectAfter: 1})
+		if err != nil {
+			t.Fatalf("NewBalancer() error: %v", err)
+		}
+		client := lb.Client(time.Second)
+		for i := 0; i < 3; i++ {
+			if code := post(t, client); code != http.StatusBadGateway {
+				t.Errorf("Expected the last response passed through, got %d", code)
+			}
+		}
+		if stats := lb.Stats(); stats[0].Ejected && stats[1].Ejected {
+			t.Errorf("Expected at most half the endpoints ejected, got %+v", stats)
+		}
+	})
+
+	t.Run("Health Check Readmits", func(t *testing.T) {
+		lb, err := NewBalancer(BalancerConfig{
+			Endpoints:  []Endpoint{{URL: serverA.URL}, {URL: "http://127.0.0.1:1"}},
+			EjectAfter: 1,
+			HealthPath: "/healthz",
+		})
+		if err != nil {
+			t.Fatalf("NewBalancer() error: %v", err)
+		}
+		lb.probe(context.Background())
+		if stats := lb.Stats(); stats[0].Ejected || !stats[1].Ejected {
+			t.Fatalf("Expected the unreachable endpoint ejected, got %+v", stats)
+		}
+		lb.endpoints[1].base = lb.endpoints[0].base
+		lb.probe(context.Background())
+		if stats := lb.Stats(); stats[1].Ejected {
+			t.Errorf("Expected the endpoint re-admitted, got %+v", stats)
+		}
+	})
+
+	t.Run("Least Connections", func(t *testing.T) {
+		lb, err := NewBalancer(BalancerConfig{
+			Endpoints: []Endpoint{{URL: serverA.URL}, {URL: serverB.URL, Weight: 2}},
+			Strategy:  LeastConnections,
+		})
+		if err != nil {
+			t.Fatalf("NewBalancer() error: %v", err)
+		}
+		picked := map[*endpointState]int{}
+		for i := 0; i < 6; i++ {
+			picked[lb.pick(nil)]++
+		}
+		if picked[lb.endpoints[0]] != 2 || picked[lb.endpoints[1]] != 4 {
+			t.Errorf("Expected in-flight requests split 2:4, got %d:%d", picked[lb.endpoints[0]], picked[lb.endpoints[1]])
+		}
+	})
+
+	t.Run("Invalid Config", func(t *testing.T) {
+		if _, err := NewBalancer(BalancerConfig{}); err == nil {
+			t.Error("Expected an error without endpoints")
+		}
+		if _, err := NewBalancer(BalancerConfig{Endpoints: []Endpoint{{URL: "auth.example.com"}}}); err == nil {
+			t.Error("Expected an error for a URL without scheme")
+		}
+	})
+}
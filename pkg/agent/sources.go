@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	autherrors "github.com/Gimel-Foundation/gauth/pkg/errors"
+)
+
+// ClientCredentialsSource fetches tokens with the OAuth 2.0 client
+// credentials grant (RFC 6749 section 4.4)
+type ClientCredentialsSource struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	// Client is the HTTP client to use (default: client with 10s timeout)
+	Client *http.Client
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// Fetch implements Source
+func (s *ClientCredentialsSource) Fetch(ctx context.Context) (*Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(s.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(s.ClientID), url.QueryEscape(s.ClientSecret))
+
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned HTTP %d: %w", resp.StatusCode, autherrors.NewHTTPError(resp, body))
+	}
+
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if tr.AccessToken == "" {
+		return nil, fmt.Errorf("token response has no access_token")
+	}
+	if tr.TokenType == "" {
+		tr.TokenType = "Bearer"
+	}
+
+	now := time.Now()
+	return &Token{
+		AccessToken: tr.AccessToken,
+		TokenType:   tr.TokenType,
+		Scope:       tr.Scope,
+		IssuedAt:    now,
+		ExpiresAt:   now.Add(time.Duration(tr.ExpiresIn) * time.Second),
+	}, nil
+}
+
+func (s *ClientCredentialsSource) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return &http.Client{Timeout: 10 * time.Second}
+}
+
+// IntrospectionChecker detects revocation with RFC 7662 token introspection
+type IntrospectionChecker struct {
+	IntrospectionURL string
+	ClientID         string
+	ClientSecret     string
+
+	// Client is the HTTP client to use (default: client with 10s timeout)
+	Client *http.Client
+}
+
+// Revoked implements RevocationChecker
+func (c *IntrospectionChecker) Revoked(ctx context.Context, tok *Token) (bool, error) {
+	form := url.Values{}
+	form.Set("token", tok.AccessToken)
+	form.Set("token_type_hint", "access_token")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.IntrospectionURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+
+	client := c.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("introspection request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("introspection endpoint returned HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Active bool `json:"active"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return false, fmt.Errorf("failed to decode introspection response: %w", err)
+	}
+	return !result.Active, nil
+}
@@ -0,0 +1,209 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrAuditAccessDenied indicates the caller may not read the requested audit data
+var ErrAuditAccessDenied = errors.New("audit access denied")
+
+// Audit access constants
+const (
+	// TypeAuditAccess is the entry type recording reads of the audit trail
+	TypeAuditAccess = "audit_access"
+
+	// ActionAuditQuery records an audit search
+	ActionAuditQuery = "audit_query"
+
+	// ActionAuditRead records a single-entry audit read
+	ActionAuditRead = "audit_read"
+
+	// MetadataTenant is the entry metadata key holding the owning tenant
+	MetadataTenant = "tenant"
+)
+
+// Principal is the caller reading the audit trail
+type Principal struct {
+	ID     string
+	Tenant string
+	Roles  []string
+}
+
+// AccessRule grants a role read access to a slice of the audit trail
+type AccessRule struct {
+	// Role the rule applies to
+	Role string
+
+	// Types limits access to these entry types (empty = all types)
+	Types []string
+
+	// OwnOnly limits access to entries where the principal is the actor or target
+	OwnOnly bool
+
+	// AllTenants allows access across tenants; otherwise only entries whose
+	// tenant metadata matches the principal's tenant are visible
+	AllTenants bool
+}
+
+// AccessPolicy decides which audit entries a principal may read
+type AccessPolicy struct {
+	Rules []AccessRule
+}
+
+// CanRead reports whether the principal may read the entry
+func (p *AccessPolicy) CanRead(principal Principal, entry *Entry) bool {
+	for _, rule := range p.Rules {
+		if !hasRole(principal, rule.Role) {
+			continue
+		}
+		if len(rule.Types) > 0 && !containsStr(rule.Types, entry.Type) {
+			continue
+		}
+		if rule.OwnOnly && entry.ActorID != principal.ID && entry.TargetID != principal.ID {
+			continue
+		}
+		if !rule.AllTenants && entry.Metadata[MetadataTenant] != principal.Tenant {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// GuardedReader enforces an AccessPolicy on reads from audit storage and
+// records every read of the audit trail as an audit entry of its own
+type GuardedReader struct {
+	storage Storage
+	policy  *AccessPolicy
+	sink    Storage
+}
+
+// NewGuardedReader creates a reader over storage. Access records are written
+// to sink, or to storage itself when sink is nil.
+func NewGuardedReader(storage Storage, policy *AccessPolicy, sink Storage) *GuardedReader {
+	if sink == nil {
+		sink = storage
+	}
+	return &GuardedReader{storage: storage, policy: policy, sink: sink}
+}
+
+// Search returns the entries matching filter that the principal may read.
+// Entries the principal may not see are silently omitted.
+func (r *GuardedReader) Search(ctx context.Context, principal Principal, filter *Filter) ([]*Entry, error) {
+	entries, err := r.storage.Search(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make([]*Entry, 0, len(entries))
+	for _, e := range entries {
+		if r.policy.CanRead(principal, e) {
+			visible = append(visible, e)
+		}
+	}
+
+	result := ResultSuccess
+	if len(visible) < len(entries) {
+		result = "partial"
+	}
+	access := r.accessEntry(ctx, principal, ActionAuditQuery, result).
+		WithMetadata("returned", strconv.Itoa(len(visible))).
+		WithMetadata("withheld", strconv.Itoa(len(entries)-len(visible))).
+		WithMetadata("filter", describeFilter(filter))
+	if err := r.sink.Store(ctx, access); err != nil {
+		return nil, fmt.Errorf("failed to record audit access: %w", err)
+	}
+
+	return visible, nil
+}
+
+// GetByID returns an entry if the principal may read it
+func (r *GuardedReader) GetByID(ctx context.Context, principal Principal, id string) (*Entry, error) {
+	entry, err := r.storage.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := r.policy.CanRead(principal, entry)
+	result := ResultSuccess
+	if !allowed {
+		result = "denied"
+	}
+	access := r.accessEntry(ctx, principal, ActionAuditRead, result).WithTarget(id, "audit_entry")
+	if err := r.sink.Store(ctx, access); err != nil {
+		return nil, fmt.Errorf("failed to record audit access: %w", err)
+	}
+
+	if !allowed {
+		return nil, ErrAuditAccessDenied
+	}
+	return entry, nil
+}
+
+func (r *GuardedReader) accessEntry(ctx context.Context, principal Principal, action, result string) *Entry {
+	e := NewEntry(TypeAuditAccess).
+		WithActor(principal.ID, ActorUser).
+		WithAction(action).
+		WithResult(result).
+		WithContext(ctx)
+	if principal.Tenant != "" {
+		e.WithMetadata(MetadataTenant, principal.Tenant)
+	}
+	return e
+}
+
+func describeFilter(f *Filter) string {
+	if f == nil {
+		return ""
+	}
+	var parts []string
+	if len(f.Types) > 0 {
+		parts = append(parts, "types="+strings.Join(f.Types, ","))
+	}
+	if len(f.ActorIDs) > 0 {
+		parts = append(parts, "actors="+strings.Join(f.ActorIDs, ","))
+	}
+	if len(f.Actions) > 0 {
+		parts = append(parts, "actions="+strings.Join(f.Actions, ","))
+	}
+	if len(f.Results) > 0 {
+		parts = append(parts, "results="+strings.Join(f.Results, ","))
+	}
+	if len(f.TargetIDs) > 0 {
+		parts = append(parts, "targets="+strings.Join(f.TargetIDs, ","))
+	}
+	if len(f.TargetTypes) > 0 {
+		parts = append(parts, "target_types="+strings.Join(f.TargetTypes, ","))
+	}
+	if f.Text != "" {
+		parts = append(parts, "text="+strconv.Quote(f.Text))
+	}
+	if f.CorrelationID != "" {
+		parts = append(parts, "correlation="+f.CorrelationID)
+	}
+	if f.ChainID != "" {
+		parts = append(parts, "chain="+f.ChainID)
+	}
+	if f.TimeRange != nil {
+		parts = append(parts, "from="+f.TimeRange.Start.Format("2006-01-02T15:04:05Z07:00"),
+			"to="+f.TimeRange.End.Format("2006-01-02T15:04:05Z07:00"))
+	}
+	return strings.Join(parts, " ")
+}
+
+func hasRole(p Principal, role string) bool {
+	return containsStr(p.Roles, role)
+}
+
+func containsStr(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
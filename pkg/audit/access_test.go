@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuardedReader(t *testing.T) {
+	ctx := context.Background()
+	storage, err := NewFileStorage(FileConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	defer storage.Close()
+
+	accessLog, err := NewFileStorage(FileConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	defer accessLog.Close()
+
+	entries := []*Entry{
+		NewEntry(TypeAuth).WithActor("alice", ActorUser).WithMetadata(MetadataTenant, "acme"),
+		NewEntry(TypeToken).WithActor("bob", ActorUser).WithMetadata(MetadataTenant, "acme"),
+		NewEntry(TypeAuth).WithActor("carol", ActorUser).WithMetadata(MetadataTenant, "globex"),
+	}
+	for _, e := range entries {
+		require.NoError(t, storage.Store(ctx, e))
+	}
+
+	policy := &AccessPolicy{Rules: []AccessRule{
+		{Role: "auditor", AllTenants: true},
+		{Role: "tenant-admin", Types: []string{TypeAuth}},
+		{Role: "user", OwnOnly: true},
+	}}
+	reader := NewGuardedReader(storage, policy, accessLog)
+
+	t.Run("Auditor Sees Everything", func(t *testing.T) {
+		results, err := reader.Search(ctx, Principal{ID: "aud", Roles: []string{"auditor"}}, &Filter{})
+		require.NoError(t, err)
+		assert.Len(t, results, 3)
+	})
+
+	t.Run("Tenant Admin Scoped By Type And Tenant", func(t *testing.T) {
+		results, err := reader.Search(ctx, Principal{ID: "adm", Tenant: "acme", Roles: []string{"tenant-admin"}}, &Filter{})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "alice", results[0].ActorID)
+	})
+
+	t.Run("User Sees Own Entries", func(t *testing.T) {
+		bob := Principal{ID: "bob", Tenant: "acme", Roles: []string{"user"}}
+		results, err := reader.Search(ctx, bob, &Filter{})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+
+		_, err = reader.GetByID(ctx, bob, entries[0].ID)
+		assert.ErrorIs(t, err, ErrAuditAccessDenied)
+	})
+
+	t.Run("Access Is Audited", func(t *testing.T) {
+		records, err := accessLog.Search(ctx, &Filter{Types: []string{TypeAuditAccess}})
+		require.NoError(t, err)
+		assert.Len(t, records, 4)
+
+		denied, err := accessLog.Search(ctx, &Filter{Results: []string{"denied"}})
+		require.NoError(t, err)
+		require.Len(t, denied, 1)
+		assert.Equal(t, "bob", denied[0].ActorID)
+	})
+}
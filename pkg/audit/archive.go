@@ -0,0 +1,265 @@
+package audit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ObjectInfo describes an object in an ObjectStore
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// ObjectStore is the object storage an Archiver writes to, such as an S3
+// bucket
+type ObjectStore interface {
+	// Put stores body under key, replacing any existing object
+	Put(ctx context.Context, key string, body []byte) error
+
+	// Get returns the object stored under key
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// List returns the objects whose keys start with prefix
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+
+	// Delete removes the object stored under key
+	Delete(ctx context.Context, key string) error
+}
+
+// ArchiverConfig configures an Archiver
+type ArchiverConfig struct {
+	// Store receives the archive objects
+	Store ObjectStore
+
+	// Prefix is prepended to every object key (default: "audit/")
+	Prefix string
+
+	// BatchSize is the number of entries per object (default: 1000)
+	BatchSize int
+
+	// FlushInterval is how often Run uploads a partial batch (default: 1m)
+	FlushInterval time.Duration
+
+	// Retention is how long objects are kept; zero keeps them forever
+	Retention time.Duration
+
+	// OnError receives failures of Run (optional)
+	OnError func(error)
+
+	// Now returns the current time (default: time.Now)
+	Now func() time.Time
+}
+
+// Archiver batches audit entries into gzipped JSON-lines objects in object
+// storage. Objects are keyed by the day of their oldest entry:
+//
+//	audit/2025/09/30/20250930T101500Z-<id>.jsonl.gz
+type Archiver struct {
+	config  ArchiverConfig
+	mu      sync.Mutex
+	pending []*Entry
+}
+
+// NewArchiver creates an archiver
+func NewArchiver(config ArchiverConfig) (*Archiver, error) {
+	if config.Store == nil {
+		return nil, fmt.Errorf("archiver needs an object store")
+	}
+	if config.Prefix == "" {
+		config.Prefix = "audit/"
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1000
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Minute
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Archiver{config: config}, nil
+}
+
+// Add queues an entry, uploading a batch once BatchSize entries are queued
+func (a *Archiver) Add(ctx context.Context, entry *Entry) error {
+	a.mu.Lock()
+	a.pending = append(a.pending, entry)
+	var batch []*Entry
+	if len(a.pending) >= a.config.BatchSize {
+		batch, a.pending = a.pending, nil
+	}
+	a.mu.Unlock()
+
+	if batch == nil {
+		return nil
+	}
+	if err := a.put(ctx, batch); err != nil {
+		a.requeue(batch)
+		return err
+	}
+	return nil
+}
+
+// Flush uploads the queued entries
+func (a *Archiver) Flush(ctx context.Context) error {
+	a.mu.Lock()
+	batch := a.pending
+	a.pending = nil
+	a.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := a.put(ctx, batch); err != nil {
+		a.requeue(batch)
+		return err
+	}
+	return nil
+}
+
+// requeue puts a failed batch back in front of the queue
+func (a *Archiver) requeue(batch []*Entry) {
+	a.mu.Lock()
+	a.pending = append(batch, a.pending...)
+	a.mu.Unlock()
+}
+
+// Archive uploads entries immediately in batches of BatchSize, bypassing
+// the queue. It returns once every batch is stored.
+func (a *Archiver) Archive(ctx context.Context, entries []*Entry) error {
+	for len(entries) > 0 {
+		n := min(len(entries), a.config.BatchSize)
+		if err := a.put(ctx, entries[:n]); err != nil {
+			return err
+		}
+		entries = entries[n:]
+	}
+	return nil
+}
+
+// put uploads one batch as a single object
+func (a *Archiver) put(ctx context.Context, batch []*Entry) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	oldest := batch[0].Timestamp
+	for _, e := range batch {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("failed to encode entry %s: %w", e.ID, err)
+		}
+		if e.Timestamp.Before(oldest) {
+			oldest = e.Timestamp
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress archive: %w", err)
+	}
+
+	oldest = oldest.UTC()
+	key := fmt.Sprintf("%s%s/%s-%s.jsonl.gz", a.config.Prefix,
+		oldest.Format("2006/01/02"), oldest.Format("20060102T150405Z"), uuid.New().String())
+	if err := a.config.Store.Put(ctx, key, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to upload archive %s: %w", key, err)
+	}
+	return nil
+}
+
+// Objects lists the archive objects
+func (a *Archiver) Objects(ctx context.Context) ([]ObjectInfo, error) {
+	objects, err := a.config.Store.List(ctx, a.config.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	out := objects[:0]
+	for _, o := range objects {
+		if strings.HasSuffix(o.Key, ".jsonl.gz") {
+			out = append(out, o)
+		}
+	}
+	return out, nil
+}
+
+// Read returns the entries of an archive object
+func (a *Archiver) Read(ctx context.Context, key string) ([]*Entry, error) {
+	body, err := a.config.Store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %w", key, err)
+	}
+	defer zr.Close()
+
+	var entries []*Entry
+	dec := json.NewDecoder(zr)
+	for dec.More() {
+		var e Entry
+		if err := dec.Decode(&e); err != nil {
+			return nil, fmt.Errorf("failed to decode archive %s: %w", key, err)
+		}
+		entries = append(entries, &e)
+	}
+	return entries, nil
+}
+
+// Expire deletes the archive objects older than Retention
+func (a *Archiver) Expire(ctx context.Context) error {
+	if a.config.Retention <= 0 {
+		return nil
+	}
+	objects, err := a.Objects(ctx)
+	if err != nil {
+		return err
+	}
+	cutoff := a.config.Now().Add(-a.config.Retention)
+	for _, o := range objects {
+		if o.LastModified.Before(cutoff) {
+			if err := a.config.Store.Delete(ctx, o.Key); err != nil {
+				return fmt.Errorf("failed to delete archive %s: %w", o.Key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Run flushes queued entries and expires old objects every FlushInterval
+// until ctx is cancelled, then flushes once more
+func (a *Archiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// Use a fresh context so the last batch is not lost to the
+			// cancellation that stopped the loop
+			a.report(a.Flush(context.Background()))
+			return
+		case <-ticker.C:
+			a.report(a.Flush(ctx))
+			a.report(a.Expire(ctx))
+		}
+	}
+}
+
+// report passes a failure of Run to OnError
+func (a *Archiver) report(err error) {
+	if err != nil && a.config.OnError != nil {
+		a.config.OnError(err)
+	}
+}
+
+// Close uploads the queued entries
+func (a *Archiver) Close(ctx context.Context) error {
+	return a.Flush(ctx)
+}
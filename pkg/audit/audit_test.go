@@ -221,7 +221,38 @@ func TestSQLStorage(t *testing.T) {
 		assert.Equal(t, entry.ActorID, retrieved.ActorID)
 	})
 
-	// Add more SQL-specific tests...
+	t.Run("Filtered Search", func(t *testing.T) {
+		actor := "filter-" + NewEntry(TypeAuth).ID
+		denied := NewEntry(TypeAuth).WithActor(actor, ActorUser).WithAction(ActionLogin).
+			WithResult("denied").WithMetadata("client", "cli")
+		ok := NewEntry(TypeAuth).WithActor(actor, ActorUser).WithAction(ActionLogin).
+			WithResult(ResultSuccess).WithMetadata("client", "web")
+		require.NoError(t, storage.Store(ctx, denied))
+		require.NoError(t, storage.Store(ctx, ok))
+
+		byResult, err := storage.Search(ctx, &Filter{ActorIDs: []string{actor}, Results: []string{"denied"}})
+		require.NoError(t, err)
+		require.Len(t, byResult, 1)
+		assert.Equal(t, denied.ID, byResult[0].ID)
+
+		byMetadata, err := storage.Search(ctx, &Filter{
+			ActorIDs: []string{actor},
+			Metadata: []MetadataFilter{{Key: "client", Value: "web", Operator: "eq"}},
+		})
+		require.NoError(t, err)
+		require.Len(t, byMetadata, 1)
+		assert.Equal(t, ok.ID, byMetadata[0].ID)
+
+		first, err := Query(ctx, storage, &Filter{ActorIDs: []string{actor}, Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, first.Entries, 1)
+		require.NotEmpty(t, first.NextCursor)
+		second, err := Query(ctx, storage, &Filter{ActorIDs: []string{actor}, Limit: 1, Cursor: first.NextCursor})
+		require.NoError(t, err)
+		require.Len(t, second.Entries, 1)
+		assert.NotEqual(t, first.Entries[0].ID, second.Entries[0].ID)
+		assert.Empty(t, second.NextCursor)
+	})
 }
 
 func TestMetrics(t *testing.T) {
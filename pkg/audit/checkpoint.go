@@ -0,0 +1,233 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCheckpointMismatch indicates audit entries no longer match their checkpoint hash
+var ErrCheckpointMismatch = errors.New("audit entries do not match checkpoint")
+
+// Checkpoint anchors a contiguous batch of audit entries. Checkpoints are
+// hash-chained to their predecessor and, when a TSA is configured, carry an
+// RFC 3161 timestamp over the checkpoint hash.
+type Checkpoint struct {
+	ID            string          `json:"id"`
+	Sequence      int64           `json:"sequence"`
+	PrevHash      string          `json:"prev_hash,omitempty"`
+	Hash          string          `json:"hash"`
+	FirstEntryID  string          `json:"first_entry_id"`
+	LastEntryID   string          `json:"last_entry_id"`
+	LastEntryTime time.Time       `json:"last_entry_time"`
+	EntryCount    int             `json:"entry_count"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Timestamp     *TimestampToken `json:"timestamp,omitempty"`
+}
+
+// CheckpointStore persists checkpoints alongside the audit trail
+type CheckpointStore interface {
+	// SaveCheckpoint stores a checkpoint
+	SaveCheckpoint(ctx context.Context, cp *Checkpoint) error
+
+	// LatestCheckpoint returns the most recent checkpoint, or nil if none exist
+	LatestCheckpoint(ctx context.Context) (*Checkpoint, error)
+
+	// ListCheckpoints returns all checkpoints in sequence order
+	ListCheckpoints(ctx context.Context) ([]*Checkpoint, error)
+}
+
+// EntrySource returns audit entries recorded after the given time, oldest first
+type EntrySource func(ctx context.Context, after time.Time) ([]*Entry, error)
+
+// MemoryCheckpointStore is an in-memory CheckpointStore
+type MemoryCheckpointStore struct {
+	mu          sync.RWMutex
+	checkpoints []*Checkpoint
+}
+
+// NewMemoryCheckpointStore creates an in-memory checkpoint store
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{}
+}
+
+// SaveCheckpoint implements CheckpointStore
+func (s *MemoryCheckpointStore) SaveCheckpoint(_ context.Context, cp *Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoints = append(s.checkpoints, cp)
+	return nil
+}
+
+// LatestCheckpoint implements CheckpointStore
+func (s *MemoryCheckpointStore) LatestCheckpoint(_ context.Context) (*Checkpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.checkpoints) == 0 {
+		return nil, nil
+	}
+	return s.checkpoints[len(s.checkpoints)-1], nil
+}
+
+// ListCheckpoints implements CheckpointStore
+func (s *MemoryCheckpointStore) ListCheckpoints(_ context.Context) ([]*Checkpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]*Checkpoint, len(s.checkpoints))
+	copy(result, s.checkpoints)
+	return result, nil
+}
+
+// Anchor creates and verifies timestamped audit checkpoints
+type Anchor struct {
+	tsa   TSAClient
+	store CheckpointStore
+	mu    sync.Mutex
+}
+
+// NewAnchor creates a checkpoint anchor. tsa may be nil, in which case
+// checkpoints are hash-chained but not externally timestamped.
+func NewAnchor(tsa TSAClient, store CheckpointStore) *Anchor {
+	if store == nil {
+		store = NewMemoryCheckpointStore()
+	}
+	return &Anchor{tsa: tsa, store: store}
+}
+
+// Checkpoint seals the given entries into a new checkpoint chained to the
+// previous one and timestamps it with the TSA.
+func (a *Anchor) Checkpoint(ctx context.Context, entries []*Entry) (*Checkpoint, error) {
+	if len(entries) == 0 {
+		return nil, errors.New("no entries to checkpoint")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	prev, err := a.store.LatestCheckpoint(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load latest checkpoint: %w", err)
+	}
+
+	cp := &Checkpoint{
+		ID:            generateID(),
+		Sequence:      1,
+		FirstEntryID:  entries[0].ID,
+		LastEntryID:   entries[len(entries)-1].ID,
+		LastEntryTime: entries[len(entries)-1].Timestamp,
+		EntryCount:    len(entries),
+		CreatedAt:     time.Now(),
+	}
+	if prev != nil {
+		cp.Sequence = prev.Sequence + 1
+		cp.PrevHash = prev.Hash
+	}
+
+	digest := checkpointDigest(cp.PrevHash, entries)
+	cp.Hash = hex.EncodeToString(digest)
+
+	if a.tsa != nil {
+		ts, err := a.tsa.Timestamp(ctx, digest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to timestamp checkpoint: %w", err)
+		}
+		cp.Timestamp = ts
+	}
+
+	if err := a.store.SaveCheckpoint(ctx, cp); err != nil {
+		return nil, fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+// Verify checks that entries still hash to the checkpoint and that its
+// timestamp, if present, covers the checkpoint hash.
+func (a *Anchor) Verify(ctx context.Context, cp *Checkpoint, entries []*Entry) error {
+	if len(entries) != cp.EntryCount {
+		return fmt.Errorf("%w: expected %d entries, got %d", ErrCheckpointMismatch, cp.EntryCount, len(entries))
+	}
+
+	digest := checkpointDigest(cp.PrevHash, entries)
+	if hex.EncodeToString(digest) != cp.Hash {
+		return ErrCheckpointMismatch
+	}
+
+	if cp.Timestamp != nil {
+		if a.tsa == nil {
+			return errors.New("checkpoint is timestamped but no TSA client is configured")
+		}
+		if err := a.tsa.Verify(ctx, cp.Timestamp, digest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyChain checks that stored checkpoints form an unbroken hash chain
+func (a *Anchor) VerifyChain(ctx context.Context) error {
+	checkpoints, err := a.store.ListCheckpoints(ctx)
+	if err != nil {
+		return err
+	}
+	prevHash := ""
+	for _, cp := range checkpoints {
+		if cp.PrevHash != prevHash {
+			return fmt.Errorf("%w: checkpoint %d breaks the chain", ErrCheckpointMismatch, cp.Sequence)
+		}
+		prevHash = cp.Hash
+	}
+	return nil
+}
+
+// Run creates a checkpoint every interval from entries recorded since the
+// last checkpoint, until ctx is cancelled. Errors are reported to onError.
+func (a *Anchor) Run(ctx context.Context, interval time.Duration, source EntrySource, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.checkpointSince(ctx, source); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func (a *Anchor) checkpointSince(ctx context.Context, source EntrySource) error {
+	var after time.Time
+	latest, err := a.store.LatestCheckpoint(ctx)
+	if err != nil {
+		return err
+	}
+	if latest != nil {
+		after = latest.LastEntryTime
+	}
+
+	entries, err := source(ctx, after)
+	if err != nil {
+		return fmt.Errorf("failed to load entries: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	_, err = a.Checkpoint(ctx, entries)
+	return err
+}
+
+// checkpointDigest chains the previous checkpoint hash with each entry hash
+func checkpointDigest(prevHash string, entries []*Entry) []byte {
+	h := sha256.New()
+	h.Write([]byte(prevHash))
+	for _, e := range entries {
+		h.Write([]byte(e.CalculateHash()))
+	}
+	return h.Sum(nil)
+}
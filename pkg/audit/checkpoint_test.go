@@ -0,0 +1,241 @@
+package audit
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTSA is a time stamping authority with its own CA
+type testTSA struct {
+	roots *x509.CertPool
+	cert  *x509.Certificate
+	key   *ecdsa.PrivateKey
+
+	// tamper alters the TSTInfo before it is signed (optional)
+	tamper func(info *tstInfo)
+}
+
+func newTestTSA(t *testing.T) *testTSA {
+	issue := func(template, parent *x509.Certificate, pub, signer any) *x509.Certificate {
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, signer)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+		return cert
+	}
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test TSA Root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	ca := issue(caTemplate, caTemplate, caKey.Public(), caKey)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	cert := issue(&x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test TSA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}, ca, key.Public(), caKey)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	return &testTSA{roots: roots, cert: cert, key: key}
+}
+
+// serve answers RFC 3161 requests with a token signed by the TSA
+func (tsa *testTSA) serve(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "application/timestamp-query", r.Header.Get("Content-Type"))
+
+		var req timeStampReq
+		_, err = asn1.Unmarshal(body, &req)
+		require.NoError(t, err)
+
+		info := tstInfo{
+			Version:        1,
+			Policy:         asn1.ObjectIdentifier{1, 2, 3, 4},
+			MessageImprint: req.MessageImprint,
+			SerialNumber:   big.NewInt(42),
+			GenTime:        time.Now().UTC().Truncate(time.Second),
+			Nonce:          req.Nonce,
+		}
+		if tsa.tamper != nil {
+			tsa.tamper(&info)
+		}
+
+		resp, err := asn1.Marshal(timeStampResp{
+			Status:         pkiStatusInfo{Status: 0},
+			TimeStampToken: asn1.RawValue{FullBytes: tsa.sign(t, info)},
+		})
+		require.NoError(t, err)
+
+		w.Header().Set("Content-Type", "application/timestamp-reply")
+		_, _ = w.Write(resp)
+	}))
+}
+
+// sign wraps a TSTInfo in a CMS SignedData TimeStampToken
+func (tsa *testTSA) sign(t *testing.T, info tstInfo) []byte {
+	der, err := asn1.Marshal(info)
+	require.NoError(t, err)
+	sd, err := pkcs7.NewSignedData(der)
+	require.NoError(t, err)
+	sd.SetContentType(asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4})
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	require.NoError(t, sd.AddSigner(tsa.cert, tsa.key, pkcs7.SignerInfoConfig{}))
+	token, err := sd.Finish()
+	require.NoError(t, err)
+	return token
+}
+
+func TestAnchor(t *testing.T) {
+	authority := newTestTSA(t)
+	server := authority.serve(t)
+	defer server.Close()
+
+	tsa, err := NewHTTPTSAClient(HTTPTSAConfig{URL: server.URL, Roots: authority.roots})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	anchor := NewAnchor(tsa, nil)
+
+	batch1 := []*Entry{
+		NewEntry(TypeAuth).WithActor("user1", ActorUser).WithAction(ActionLogin),
+		NewEntry(TypeToken).WithActor("user1", ActorUser).WithAction(ActionTokenGenerate),
+	}
+	batch2 := []*Entry{
+		NewEntry(TypeToken).WithActor("user1", ActorUser).WithAction(ActionTokenRevoke),
+	}
+
+	cp1, err := anchor.Checkpoint(ctx, batch1)
+	require.NoError(t, err)
+	require.NotNil(t, cp1.Timestamp)
+	assert.Equal(t, "42", cp1.Timestamp.SerialNumber)
+	assert.Equal(t, int64(1), cp1.Sequence)
+
+	cp2, err := anchor.Checkpoint(ctx, batch2)
+	require.NoError(t, err)
+	assert.Equal(t, cp1.Hash, cp2.PrevHash)
+
+	t.Run("Verify Intact", func(t *testing.T) {
+		assert.NoError(t, anchor.Verify(ctx, cp1, batch1))
+		assert.NoError(t, anchor.Verify(ctx, cp2, batch2))
+		assert.NoError(t, anchor.VerifyChain(ctx))
+	})
+
+	t.Run("Detect Tampering", func(t *testing.T) {
+		batch1[1].ActorID = "attacker"
+		assert.ErrorIs(t, anchor.Verify(ctx, cp1, batch1), ErrCheckpointMismatch)
+	})
+
+	t.Run("Detect Forged Timestamp", func(t *testing.T) {
+		forged := *cp2
+		forged.Timestamp = cp1.Timestamp
+		assert.ErrorIs(t, anchor.Verify(ctx, &forged, batch2), ErrTimestampMismatch)
+	})
+}
+
+func TestHTTPTSAClient(t *testing.T) {
+	ctx := context.Background()
+	digest := sha256.Sum256([]byte("checkpoint"))
+	authority := newTestTSA(t)
+	info := tstInfo{
+		Version: 1,
+		Policy:  asn1.ObjectIdentifier{1, 2, 3, 4},
+		MessageImprint: messageImprint{
+			HashAlgorithm: algorithmIdentifier{Algorithm: oidSHA256},
+			HashedMessage: digest[:],
+		},
+		SerialNumber: big.NewInt(7),
+		GenTime:      time.Now().UTC().Truncate(time.Second),
+	}
+	newClient := func(t *testing.T, url string) *HTTPTSAClient {
+		t.Helper()
+		client, err := NewHTTPTSAClient(HTTPTSAConfig{URL: url, Roots: authority.roots})
+		require.NoError(t, err)
+		return client
+	}
+
+	t.Run("Requires Roots", func(t *testing.T) {
+		_, err := NewHTTPTSAClient(HTTPTSAConfig{URL: "http://tsa.example.com"})
+		assert.Error(t, err)
+	})
+
+	t.Run("Accepts Trusted Token", func(t *testing.T) {
+		raw := authority.sign(t, info)
+		assert.NoError(t, newClient(t, "http://tsa.example.com").Verify(ctx, &TimestampToken{Raw: raw}, digest[:]))
+	})
+
+	t.Run("Rejects Untrusted Signer", func(t *testing.T) {
+		raw := newTestTSA(t).sign(t, info)
+		err := newClient(t, "http://tsa.example.com").Verify(ctx, &TimestampToken{Raw: raw}, digest[:])
+		assert.ErrorIs(t, err, ErrTimestampSignature)
+	})
+
+	t.Run("Rejects Unsigned Token", func(t *testing.T) {
+		der, err := asn1.Marshal(info)
+		require.NoError(t, err)
+		sd, err := asn1.Marshal(signedData{
+			Version:          3,
+			DigestAlgorithms: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true},
+			EncapContentInfo: encapContentInfo{
+				EContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4},
+				EContent:     der,
+			},
+		})
+		require.NoError(t, err)
+		explicit, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd})
+		require.NoError(t, err)
+		raw, err := asn1.Marshal(contentInfo{ContentType: oidSignedData, Content: asn1.RawValue{FullBytes: explicit}})
+		require.NoError(t, err)
+
+		err = newClient(t, "http://tsa.example.com").Verify(ctx, &TimestampToken{Raw: raw}, digest[:])
+		assert.ErrorIs(t, err, ErrTimestampSignature)
+	})
+
+	t.Run("Rejects Replayed Nonce", func(t *testing.T) {
+		replayer := *authority
+		replayer.tamper = func(info *tstInfo) { info.Nonce = big.NewInt(1) }
+		server := replayer.serve(t)
+		defer server.Close()
+
+		_, err := newClient(t, server.URL).Timestamp(ctx, digest[:])
+		assert.ErrorIs(t, err, ErrTimestampMismatch)
+	})
+
+	t.Run("Requires SHA-256 Imprint", func(t *testing.T) {
+		weak := info
+		weak.MessageImprint.HashAlgorithm.Algorithm = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+		raw := authority.sign(t, weak)
+		err := newClient(t, "http://tsa.example.com").Verify(ctx, &TimestampToken{Raw: raw}, digest[:])
+		assert.ErrorIs(t, err, ErrTimestampMismatch)
+	})
+}
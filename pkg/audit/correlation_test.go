@@ -0,0 +1,30 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrelationFilter(t *testing.T) {
+	storage, err := NewFileStorage(FileConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	defer storage.Close()
+
+	flow := events.ContextWithCorrelationID(context.Background(), "flow-1")
+	other := events.ContextWithCorrelationID(context.Background(), "flow-2")
+
+	require.NoError(t, storage.Store(flow, NewEntry(TypeAuth).WithAction(ActionLogin).WithContext(flow)))
+	require.NoError(t, storage.Store(flow, NewEntry(TypeToken).WithAction(ActionTokenGenerate).WithContext(flow)))
+	require.NoError(t, storage.Store(other, NewEntry(TypeToken).WithAction(ActionTokenGenerate).WithContext(other)))
+
+	results, err := storage.Search(context.Background(), &Filter{CorrelationID: "flow-1"})
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+	for _, e := range results {
+		assert.Equal(t, "flow-1", e.CorrelationID)
+	}
+}
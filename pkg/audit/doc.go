@@ -27,6 +27,21 @@
 // # Extension Points
 //   - Implement custom Logger or Store for integration with external systems
 //   - Add new event types for domain-specific auditing
+//   - FileStorage appends to daily files rotated by size and sealed read-only;
+//     SQLStorage indexes the PostgreSQL columns Filter queries
+//   - An Archiver batches entries into gzipped JSON-lines objects in an
+//     ObjectStore such as S3Store, and a RetentionPolicy expires entries per
+//     type, honouring legal holds and archiving before it deletes
+//   - Query pages through entries newest first with an opaque cursor,
+//     filtering by time, actor, type, resource, outcome and free text
+//   - A StreamingSink streams entries to SIEMs through a Publisher (Kafka REST
+//     proxy, NATS or syslog with JSON or CEF bodies) in batches, retrying with
+//     backoff and dead-lettering entries it cannot deliver; wrap a Storage in
+//     a StreamingStorage to stream everything it stores
+//   - Wrap a Storage in a RedactingStorage to mask, pseudonymize, encrypt or
+//     remove personal data before it is persisted
+//   - Wrap a Storage in an EnrichingStorage to resolve actor, target and client
+//     IDs to display names at write or query time; raw IDs stay authoritative
 //
 // # See Also
 //   - package token: for token lifecycle and revocation events
@@ -0,0 +1,208 @@
+package audit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Name enrichment constants
+const (
+	// MetadataClientID is the entry metadata key holding the OAuth client ID
+	MetadataClientID = "client_id"
+
+	// MetadataClientName receives the client's display name
+	MetadataClientName = "client_name"
+
+	// KindClient is the registry kind used to resolve MetadataClientID
+	KindClient = "client"
+)
+
+// NameResolver looks up the display name of an ID in a registry, such as a
+// user directory or the OAuth client registry. It returns "" for unknown IDs.
+type NameResolver interface {
+	ResolveName(ctx context.Context, id string) (string, error)
+}
+
+// NameResolverFunc adapts a function to the NameResolver interface
+type NameResolverFunc func(ctx context.Context, id string) (string, error)
+
+// ResolveName implements NameResolver
+func (f NameResolverFunc) ResolveName(ctx context.Context, id string) (string, error) {
+	return f(ctx, id)
+}
+
+// EnrichmentMode selects when names are resolved
+type EnrichmentMode int
+
+const (
+	// EnrichOnWrite stores names with the entry, as they were at the time
+	EnrichOnWrite EnrichmentMode = iota
+
+	// EnrichOnQuery resolves current names when entries are read, leaving
+	// stored entries untouched
+	EnrichOnQuery
+)
+
+// EnricherConfig configures a NameEnricher
+type EnricherConfig struct {
+	// Resolvers are keyed by actor or target type (e.g. "user", "resource")
+	// and by KindClient for the client_id metadata
+	Resolvers map[string]NameResolver
+
+	// Mode selects write-time or query-time enrichment (default: EnrichOnWrite)
+	Mode EnrichmentMode
+
+	// CacheTTL caches resolved names (default: 5m, negative disables)
+	CacheTTL time.Duration
+
+	// Timeout bounds each lookup (default: 200ms)
+	Timeout time.Duration
+
+	// OnError, when set, is told about failed lookups
+	OnError func(kind, id string, err error)
+}
+
+type cachedName struct {
+	name    string
+	expires time.Time
+}
+
+// NameEnricher fills the ActorName, TargetName and client_name of audit
+// entries from registries. Raw IDs stay authoritative: they are never
+// modified, names that are already set are kept, and a failed lookup leaves
+// the name empty rather than failing the write or query.
+type NameEnricher struct {
+	config EnricherConfig
+
+	mu    sync.Mutex
+	cache map[string]cachedName
+}
+
+// NewNameEnricher creates a name enricher
+func NewNameEnricher(config EnricherConfig) *NameEnricher {
+	if config.CacheTTL == 0 {
+		config.CacheTTL = 5 * time.Minute
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 200 * time.Millisecond
+	}
+	return &NameEnricher{config: config, cache: make(map[string]cachedName)}
+}
+
+// Enrich resolves the names of the entry's actor, target and client in place
+func (e *NameEnricher) Enrich(ctx context.Context, entry *Entry) {
+	if entry.ActorName == "" {
+		entry.ActorName = e.resolve(ctx, entry.ActorType, entry.ActorID)
+	}
+	if entry.TargetName == "" {
+		entry.TargetName = e.resolve(ctx, entry.TargetType, entry.TargetID)
+	}
+	if id := entry.Metadata[MetadataClientID]; id != "" && entry.Metadata[MetadataClientName] == "" {
+		if name := e.resolve(ctx, KindClient, id); name != "" {
+			entry.Metadata[MetadataClientName] = name
+		}
+	}
+}
+
+func (e *NameEnricher) resolve(ctx context.Context, kind, id string) string {
+	resolver, ok := e.config.Resolvers[kind]
+	if !ok || id == "" {
+		return ""
+	}
+	key := kind + "\x00" + id
+	now := time.Now()
+	if e.config.CacheTTL > 0 {
+		e.mu.Lock()
+		c, ok := e.cache[key]
+		e.mu.Unlock()
+		if ok && now.Before(c.expires) {
+			return c.name
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.config.Timeout)
+	defer cancel()
+	name, err := resolver.ResolveName(ctx, id)
+	if err != nil {
+		if e.config.OnError != nil {
+			e.config.OnError(kind, id, err)
+		}
+		return ""
+	}
+	if e.config.CacheTTL > 0 {
+		e.mu.Lock()
+		e.cache[key] = cachedName{name: name, expires: now.Add(e.config.CacheTTL)}
+		e.mu.Unlock()
+	}
+	return name
+}
+
+// EnrichingStorage wraps a Storage with a NameEnricher, resolving names when
+// entries are stored or when they are read, depending on the enricher's mode
+type EnrichingStorage struct {
+	Storage
+	enricher *NameEnricher
+}
+
+var _ Storage = (*EnrichingStorage)(nil)
+
+// NewEnrichingStorage wraps storage with the enricher
+func NewEnrichingStorage(storage Storage, enricher *NameEnricher) *EnrichingStorage {
+	return &EnrichingStorage{Storage: storage, enricher: enricher}
+}
+
+// Store implements Storage. In EnrichOnWrite mode the stored entry carries
+// the resolved names.
+func (s *EnrichingStorage) Store(ctx context.Context, entry *Entry) error {
+	if s.enricher.config.Mode == EnrichOnWrite {
+		s.enricher.Enrich(ctx, entry)
+	}
+	return s.Storage.Store(ctx, entry)
+}
+
+// Search implements Storage
+func (s *EnrichingStorage) Search(ctx context.Context, filter *Filter) ([]*Entry, error) {
+	entries, err := s.Storage.Search(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return s.enrichRead(ctx, entries), nil
+}
+
+// GetByID implements Storage
+func (s *EnrichingStorage) GetByID(ctx context.Context, id string) (*Entry, error) {
+	entry, err := s.Storage.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.enrichRead(ctx, []*Entry{entry})[0], nil
+}
+
+// GetChain implements Storage
+func (s *EnrichingStorage) GetChain(ctx context.Context, chainID string) ([]*Entry, error) {
+	entries, err := s.Storage.GetChain(ctx, chainID)
+	if err != nil {
+		return nil, err
+	}
+	return s.enrichRead(ctx, entries), nil
+}
+
+// enrichRead resolves names on copies of the entries in EnrichOnQuery mode,
+// so backends that return shared pointers are never modified
+func (s *EnrichingStorage) enrichRead(ctx context.Context, entries []*Entry) []*Entry {
+	if s.enricher.config.Mode != EnrichOnQuery {
+		return entries
+	}
+	out := make([]*Entry, len(entries))
+	for i, entry := range entries {
+		c := *entry
+		c.Metadata = make(Metadata, len(entry.Metadata))
+		for k, v := range entry.Metadata {
+			c.Metadata[k] = v
+		}
+		s.enricher.Enrich(ctx, &c)
+		out[i] = &c
+	}
+	return out
+}
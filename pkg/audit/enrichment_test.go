@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameEnrichment(t *testing.T) {
+	ctx := context.Background()
+	users := map[string]string{"u-1": "Alice Example"}
+	lookups := 0
+	resolvers := map[string]NameResolver{
+		ActorUser: NameResolverFunc(func(_ context.Context, id string) (string, error) {
+			lookups++
+			return users[id], nil
+		}),
+		TypeResource: NameResolverFunc(func(_ context.Context, id string) (string, error) {
+			return "", errors.New("registry unavailable")
+		}),
+		KindClient: NameResolverFunc(func(_ context.Context, id string) (string, error) {
+			return "Billing App", nil
+		}),
+	}
+	newEntry := func() *Entry {
+		return NewEntry(TypeAuth).
+			WithActor("u-1", ActorUser).
+			WithTarget("doc-9", TypeResource).
+			WithMetadata(MetadataClientID, "billing")
+	}
+
+	t.Run("On Write", func(t *testing.T) {
+		base, err := NewFileStorage(FileConfig{Directory: t.TempDir()})
+		require.NoError(t, err)
+		defer base.Close()
+		var failures []string
+		storage := NewEnrichingStorage(base, NewNameEnricher(EnricherConfig{
+			Resolvers: resolvers,
+			OnError:   func(kind, id string, _ error) { failures = append(failures, kind+":"+id) },
+		}))
+
+		entry := newEntry()
+		require.NoError(t, storage.Store(ctx, entry))
+		stored, err := base.GetByID(ctx, entry.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "u-1", stored.ActorID)
+		assert.Equal(t, "Alice Example", stored.ActorName)
+		assert.Equal(t, "Billing App", stored.Metadata[MetadataClientName])
+		assert.Empty(t, stored.TargetName, "failed lookups leave the name empty")
+		assert.Equal(t, "doc-9", stored.TargetID)
+		assert.Equal(t, []string{"resource:doc-9"}, failures)
+	})
+
+	t.Run("On Query", func(t *testing.T) {
+		base, err := NewFileStorage(FileConfig{Directory: t.TempDir()})
+		require.NoError(t, err)
+		defer base.Close()
+		storage := NewEnrichingStorage(base, NewNameEnricher(EnricherConfig{
+			Resolvers: resolvers,
+			Mode:      EnrichOnQuery,
+			CacheTTL:  -1,
+		}))
+
+		entry := newEntry()
+		require.NoError(t, storage.Store(ctx, entry))
+		stored, err := base.GetByID(ctx, entry.ID)
+		require.NoError(t, err)
+		assert.Empty(t, stored.ActorName, "stored entries stay raw")
+
+		users["u-1"] = "Alice Renamed"
+		results, err := storage.Search(ctx, &Filter{ActorIDs: []string{"u-1"}})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "Alice Renamed", results[0].ActorName)
+		assert.Equal(t, "Billing App", results[0].Metadata[MetadataClientName])
+	})
+
+	t.Run("Cache And Existing Names", func(t *testing.T) {
+		users["u-1"] = "Alice Example"
+		lookups = 0
+		enricher := NewNameEnricher(EnricherConfig{Resolvers: resolvers})
+		for i := 0; i < 3; i++ {
+			enricher.Enrich(ctx, newEntry())
+		}
+		assert.Equal(t, 1, lookups)
+
+		named := newEntry()
+		named.ActorName = "Recorded Name"
+		enricher.Enrich(ctx, named)
+		assert.Equal(t, "Recorded Name", named.ActorName)
+	})
+}
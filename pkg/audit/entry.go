@@ -1,9 +1,12 @@
 package audit
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
 )
 
 // Entry represents a single audit log entry.
@@ -33,6 +36,7 @@ type Entry struct {
 	TargetChanges Metadata `json:"target_changes,omitempty"`
 	Location      string   `json:"location,omitempty"`
 	TraceID       string   `json:"trace_id,omitempty"`
+	CorrelationID string   `json:"correlation_id,omitempty"`
 	Error         string   `json:"error,omitempty"`
 }
 
@@ -73,6 +77,20 @@ func (e *Entry) WithResult(result string) *Entry {
 	return e
 }
 
+// WithCorrelationID sets the correlation ID shared by all entries of one flow.
+func (e *Entry) WithCorrelationID(id string) *Entry {
+	e.CorrelationID = id
+	return e
+}
+
+// WithContext copies the correlation ID carried by ctx onto the entry.
+func (e *Entry) WithContext(ctx context.Context) *Entry {
+	if id := events.CorrelationIDFromContext(ctx); id != "" {
+		e.CorrelationID = id
+	}
+	return e
+}
+
 // WithMetadata adds a key-value pair to metadata.
 func (e *Entry) WithMetadata(key string, value string) *Entry {
 	e.Metadata[key] = value
@@ -7,16 +7,26 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
 )
 
-// FileStorage implements the Storage interface using JSON files
+// FileStorage implements the Storage interface using append-only JSON lines
+// files. The current file is rotated when the file name pattern yields a new
+// name, after RotateInterval or when it would exceed MaxFileSize; rotated
+// files are made read-only.
 type FileStorage struct {
 	directory string
+	config    FileConfig
+	glob      string
 	file      *os.File
 	writer    *bufio.Writer
+	name      string
+	base      string
+	size      int64
+	opened    time.Time
 	mu        sync.Mutex
 }
 
@@ -33,8 +43,14 @@ type FileConfig struct {
 
 	// MaxFileSize in bytes before rotation (default: 100MB)
 	MaxFileSize int64
+
+	// Now returns the current time (default: time.Now)
+	Now func() time.Time
 }
 
+// digitRuns matches the date fields of a formatted FilePattern
+var digitRuns = regexp.MustCompile(`[0-9]+`)
+
 // NewFileStorage creates a new file-based storage
 func NewFileStorage(config FileConfig) (*FileStorage, error) {
 	if config.Directory == "" {
@@ -46,8 +62,26 @@ func NewFileStorage(config FileConfig) (*FileStorage, error) {
 		return nil, fmt.Errorf("failed to create directory: %w", err)
 	}
 
+	if config.FilePattern == "" {
+		config.FilePattern = "audit-2006-01-02.log"
+	}
+	if config.RotateInterval <= 0 {
+		config.RotateInterval = 24 * time.Hour
+	}
+	if config.MaxFileSize <= 0 {
+		config.MaxFileSize = 100 << 20
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	if filepath.Base(config.FilePattern) != config.FilePattern {
+		return nil, fmt.Errorf("file pattern must be a file name")
+	}
+
 	fs := &FileStorage{
 		directory: config.Directory,
+		config:    config,
+		glob:      digitRuns.ReplaceAllString(config.FilePattern, "*"),
 	}
 
 	if err := fs.rotate(); err != nil {
@@ -67,16 +101,32 @@ func (fs *FileStorage) Store(_ context.Context, entry *Entry) error {
 		return fmt.Errorf("failed to marshal entry: %w", err)
 	}
 
+	if fs.rotationDue(int64(len(data)) + 1) {
+		if err := fs.rotate(); err != nil {
+			return err
+		}
+	}
+
 	if _, err := fs.writer.Write(data); err != nil {
 		return fmt.Errorf("failed to write entry: %w", err)
 	}
 	if _, err := fs.writer.WriteString("\n"); err != nil {
 		return fmt.Errorf("failed to write newline: %w", err)
 	}
+	fs.size += int64(len(data)) + 1
 
 	return fs.writer.Flush()
 }
 
+// Files returns the log files, current one included, in name order
+func (fs *FileStorage) Files() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(fs.directory, fs.glob))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list log files: %w", err)
+	}
+	return files, nil
+}
+
 // Search implements the Storage interface
 func (fs *FileStorage) Search(ctx context.Context, filter *Filter) ([]*Entry, error) {
 	fs.mu.Lock()
@@ -85,9 +135,9 @@ func (fs *FileStorage) Search(ctx context.Context, filter *Filter) ([]*Entry, er
 	var results []*Entry
 
 	// List all log files
-	files, err := filepath.Glob(filepath.Join(fs.directory, "audit-*.log"))
+	files, err := fs.Files()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list log files: %w", err)
+		return nil, err
 	}
 
 	// Process each file
@@ -108,9 +158,9 @@ func (fs *FileStorage) GetByID(_ context.Context, id string) (*Entry, error) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	files, err := filepath.Glob(filepath.Join(fs.directory, "audit-*.log"))
+	files, err := fs.Files()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list log files: %w", err)
+		return nil, err
 	}
 
 	for _, file := range files {
@@ -153,26 +203,48 @@ func (fs *FileStorage) GetChain(ctx context.Context, chainID string) ([]*Entry,
 }
 
 // Cleanup implements the Storage interface
-func (fs *FileStorage) Cleanup(_ context.Context, before time.Time) error {
+func (fs *FileStorage) Cleanup(ctx context.Context, before time.Time) error {
+	return fs.CleanupExcept(ctx, before, nil)
+}
+
+// CleanupExcept implements the RetentionCleaner interface
+func (fs *FileStorage) CleanupExcept(_ context.Context, before time.Time, keep func(*Entry) bool) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	files, err := filepath.Glob(filepath.Join(fs.directory, "audit-*.log"))
+	files, err := fs.Files()
 	if err != nil {
-		return fmt.Errorf("failed to list log files: %w", err)
+		return err
 	}
 
 	for _, file := range files {
-		if err := fs.cleanupFile(file, before); err != nil {
+		if err := fs.cleanupFile(file, before, keep); err != nil {
 			// Log error but continue with other files
 			fmt.Printf("Warning: failed to cleanup file %s: %v\n", file, err)
 		}
 	}
 
+	// The current file may have been rewritten or removed; continue
+	// appending to whatever is at its name now
+	if fs.file != nil {
+		if err := fs.closeFile(); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(fs.name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to reopen log file: %w", err)
+		}
+		info, err := file.Stat()
+		if err != nil {
+			_ = file.Close()
+			return fmt.Errorf("failed to reopen log file: %w", err)
+		}
+		fs.file, fs.writer, fs.size = file, bufio.NewWriter(file), info.Size()
+	}
 	return nil
 }
 
-func (fs *FileStorage) cleanupFile(file string, before time.Time) error {
+func (fs *FileStorage) cleanupFile(file string, before time.Time, keep func(*Entry) bool) error {
 	// Validate file path to prevent directory traversal
 	cleanFile := filepath.Clean(file)
 	if !strings.HasPrefix(cleanFile, filepath.Clean(fs.directory)) {
@@ -194,7 +266,7 @@ func (fs *FileStorage) cleanupFile(file string, before time.Time) error {
 		return err
 	}
 
-	kept, err := fs.filterEntriesBeforeDate(f, tmpFile, before)
+	kept, err := fs.filterEntriesBeforeDate(f, tmpFile, before, keep)
 	if err != nil {
 		fs.cleanupTempFile(tmpFile)
 		return err
@@ -225,7 +297,7 @@ func (fs *FileStorage) createSecureTempFile(cleanFile string) (string, error) {
 	return cleanTmpFile, nil
 }
 
-func (fs *FileStorage) filterEntriesBeforeDate(input *os.File, tmpFile string, before time.Time) (int, error) {
+func (fs *FileStorage) filterEntriesBeforeDate(input *os.File, tmpFile string, before time.Time, keep func(*Entry) bool) (int, error) {
 	// #nosec G304 - Security validation performed in createSecureTempFile
 	out, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
 	if err != nil {
@@ -246,7 +318,7 @@ func (fs *FileStorage) filterEntriesBeforeDate(input *os.File, tmpFile string, b
 		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
 			continue
 		}
-		if entry.Timestamp.After(before) {
+		if entry.Timestamp.After(before) || (keep != nil && keep(&entry)) {
 			if err := fs.writeEntry(writer, &entry); err != nil {
 				continue
 			}
@@ -288,6 +360,10 @@ func (fs *FileStorage) finalizeCleanup(originalFile, tmpFile string, kept int) e
 			fs.cleanupTempFile(tmpFile)
 			return err
 		}
+		if filepath.Clean(originalFile) != fs.name {
+			// #nosec G302 - rotated logs stay sealed read-only
+			return os.Chmod(originalFile, 0400)
+		}
 	}
 	return nil
 }
@@ -302,28 +378,84 @@ func (fs *FileStorage) cleanupTempFile(tmpFile string) {
 func (fs *FileStorage) Close() error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
+	return fs.closeFile()
+}
+
+// Helper methods
 
+func (fs *FileStorage) closeFile() error {
 	if fs.writer != nil {
 		if err := fs.writer.Flush(); err != nil {
 			return err
 		}
 	}
 	if fs.file != nil {
-		return fs.file.Close()
+		err := fs.file.Close()
+		fs.file, fs.writer = nil, nil
+		return err
 	}
 	return nil
 }
 
-// Helper methods
+// rotationDue reports whether writing n more bytes needs a new file
+func (fs *FileStorage) rotationDue(n int64) bool {
+	now := fs.config.Now()
+	switch {
+	case fs.file == nil:
+		return true
+	case now.Format(fs.config.FilePattern) != fs.base:
+		return true
+	case now.Sub(fs.opened) >= fs.config.RotateInterval:
+		return true
+	default:
+		return fs.size > 0 && fs.size+n > fs.config.MaxFileSize
+	}
+}
 
+// rotate seals the current file and opens the next one. The first file
+// opened continues the latest file of the day if it is still writable and
+// below MaxFileSize.
 func (fs *FileStorage) rotate() error {
+	first := fs.file == nil && fs.name == ""
 	if fs.file != nil {
-		if err := fs.Close(); err != nil {
+		if err := fs.closeFile(); err != nil {
 			return err
 		}
+		// #nosec G302 - rotated logs are sealed read-only
+		if err := os.Chmod(fs.name, 0400); err != nil {
+			return fmt.Errorf("failed to seal log file: %w", err)
+		}
+	}
+
+	now := fs.config.Now()
+	base := now.Format(fs.config.FilePattern)
+	ext := filepath.Ext(base)
+	var filename string
+	var size int64
+	for seq := 0; ; seq++ {
+		name := base
+		if seq > 0 {
+			name = fmt.Sprintf("%s.%d%s", strings.TrimSuffix(base, ext), seq, ext)
+		}
+		candidate := filepath.Join(fs.directory, name)
+		info, err := os.Stat(candidate)
+		if os.IsNotExist(err) {
+			filename = candidate
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to inspect log file: %w", err)
+		}
+		next := fmt.Sprintf("%s.%d%s", strings.TrimSuffix(base, ext), seq+1, ext)
+		if _, err := os.Stat(filepath.Join(fs.directory, next)); err == nil {
+			continue
+		}
+		if first && info.Mode().Perm()&0200 != 0 && info.Size() < fs.config.MaxFileSize {
+			filename, size = candidate, info.Size()
+			break
+		}
 	}
 
-	filename := filepath.Join(fs.directory, fmt.Sprintf("audit-%s.log", time.Now().Format("2006-01-02")))
 	// Validate filename to prevent path traversal
 	cleanFilename := filepath.Clean(filename)
 	if !strings.HasPrefix(cleanFilename, filepath.Clean(fs.directory)) {
@@ -336,6 +468,10 @@ func (fs *FileStorage) rotate() error {
 
 	fs.file = file
 	fs.writer = bufio.NewWriter(file)
+	fs.name = cleanFilename
+	fs.base = base
+	fs.size = size
+	fs.opened = now
 	return nil
 }
 
@@ -388,8 +524,10 @@ func (fs *FileStorage) matchesFilter(entry *Entry, filter *Filter) bool {
 		fs.matchesResults(entry, filter) &&
 		fs.matchesTimeRange(entry, filter) &&
 		fs.matchesChainID(entry, filter) &&
+		fs.matchesCorrelationID(entry, filter) &&
 		fs.matchesTags(entry, filter) &&
-		fs.matchesMetadata(entry, filter)
+		fs.matchesMetadata(entry, filter) &&
+		matchesQuery(entry, filter)
 }
 
 func (fs *FileStorage) matchesActorIDs(entry *Entry, filter *Filter) bool {
@@ -452,6 +590,10 @@ func (fs *FileStorage) matchesChainID(entry *Entry, filter *Filter) bool {
 	return filter.ChainID == "" || entry.ChainID == filter.ChainID
 }
 
+func (fs *FileStorage) matchesCorrelationID(entry *Entry, filter *Filter) bool {
+	return filter.CorrelationID == "" || entry.CorrelationID == filter.CorrelationID
+}
+
 func (fs *FileStorage) matchesTags(entry *Entry, filter *Filter) bool {
 	if len(filter.Tags) == 0 {
 		return true
@@ -0,0 +1,341 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Legal hold errors
+var (
+	// ErrHoldNotFound indicates no hold exists with the given ID
+	ErrHoldNotFound = errors.New("legal hold not found")
+
+	// ErrHoldReleased indicates the hold has already been released
+	ErrHoldReleased = errors.New("legal hold already released")
+
+	// ErrHoldUnsupported indicates the storage cannot skip held entries, so
+	// cleanup was refused rather than risk deleting them
+	ErrHoldUnsupported = errors.New("storage cannot honour legal holds")
+)
+
+// Legal hold constants
+const (
+	// TypeLegalHold is the entry type recording hold transitions
+	TypeLegalHold = "legal_hold"
+
+	// ActionHoldPlaced records a new hold
+	ActionHoldPlaced = "hold_placed"
+
+	// ActionHoldReleased records a released hold
+	ActionHoldReleased = "hold_released"
+
+	// MetadataMatter is the entry metadata key naming the legal matter
+	MetadataMatter = "matter"
+)
+
+// Hold exempts records from retention deletion until it is released. An
+// entry is held when its actor or target is one of Subjects, or when its
+// matter metadata equals Matter.
+type Hold struct {
+	ID         string     `json:"id"`
+	Matter     string     `json:"matter"`
+	Subjects   []string   `json:"subjects,omitempty"`
+	Reason     string     `json:"reason,omitempty"`
+	PlacedBy   string     `json:"placed_by"`
+	PlacedAt   time.Time  `json:"placed_at"`
+	ReleasedBy string     `json:"released_by,omitempty"`
+	ReleasedAt *time.Time `json:"released_at,omitempty"`
+}
+
+// Active reports whether the hold is still in force
+func (h *Hold) Active() bool {
+	return h.ReleasedAt == nil
+}
+
+// Covers reports whether the hold applies to the entry
+func (h *Hold) Covers(entry *Entry) bool {
+	if !h.Active() {
+		return false
+	}
+	if h.Matter != "" && entry.Metadata[MetadataMatter] == h.Matter {
+		return true
+	}
+	return containsStr(h.Subjects, entry.ActorID) || (entry.TargetID != "" && containsStr(h.Subjects, entry.TargetID))
+}
+
+// HoldStore persists holds so they survive restarts
+type HoldStore interface {
+	// SaveHold creates or replaces a hold
+	SaveHold(ctx context.Context, h *Hold) error
+
+	// LoadHolds returns every stored hold, active and released
+	LoadHolds(ctx context.Context) ([]*Hold, error)
+}
+
+// HoldPinner is implemented by storages that expire entries on their own.
+// PinHeld removes the expiry of every stored entry for which keep returns
+// true, so held entries outlive their TTL.
+type HoldPinner interface {
+	PinHeld(ctx context.Context, keep func(*Entry) bool) error
+}
+
+// HoldRegistry tracks legal holds
+type HoldRegistry struct {
+	mu      sync.RWMutex
+	holds   map[string]*Hold
+	sink    Storage
+	store   HoldStore
+	pinners []HoldPinner
+}
+
+// NewHoldRegistry creates an in-memory hold registry. When sink is non-nil,
+// every hold transition is recorded there.
+func NewHoldRegistry(sink Storage) *HoldRegistry {
+	return &HoldRegistry{holds: make(map[string]*Hold), sink: sink}
+}
+
+// LoadHoldRegistry creates a hold registry backed by store. The stored holds
+// are loaded first, and every later transition is saved before it takes
+// effect.
+func LoadHoldRegistry(ctx context.Context, sink Storage, store HoldStore) (*HoldRegistry, error) {
+	holds, err := store.LoadHolds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load legal holds: %w", err)
+	}
+	r := NewHoldRegistry(sink)
+	r.store = store
+	for _, h := range holds {
+		r.holds[h.ID] = h
+	}
+	return r, nil
+}
+
+// addPinner registers a storage whose entries are pinned when a hold is
+// placed
+func (r *HoldRegistry) addPinner(p HoldPinner) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pinners = append(r.pinners, p)
+}
+
+// Place puts a new hold in force
+func (r *HoldRegistry) Place(ctx context.Context, matter string, subjects []string, reason, placedBy string) (*Hold, error) {
+	if matter == "" && len(subjects) == 0 {
+		return nil, errors.New("hold needs a matter or at least one subject")
+	}
+	h := &Hold{
+		ID:       uuid.New().String(),
+		Matter:   matter,
+		Subjects: append([]string(nil), subjects...),
+		Reason:   reason,
+		PlacedBy: placedBy,
+		PlacedAt: time.Now(),
+	}
+
+	r.mu.Lock()
+	if r.store != nil {
+		if err := r.store.SaveHold(ctx, h); err != nil {
+			r.mu.Unlock()
+			return nil, fmt.Errorf("failed to save legal hold: %w", err)
+		}
+	}
+	r.holds[h.ID] = h
+	pinners := append([]HoldPinner(nil), r.pinners...)
+	r.mu.Unlock()
+
+	placed := *h
+	for _, p := range pinners {
+		if err := p.PinHeld(ctx, placed.Covers); err != nil {
+			return h, fmt.Errorf("failed to pin held entries: %w", err)
+		}
+	}
+	if err := r.record(ctx, h, ActionHoldPlaced, placedBy); err != nil {
+		return h, err
+	}
+	return h, nil
+}
+
+// Release lifts a hold; its records become subject to retention again
+func (r *HoldRegistry) Release(ctx context.Context, id, releasedBy string) error {
+	r.mu.Lock()
+	h, ok := r.holds[id]
+	if !ok {
+		r.mu.Unlock()
+		return ErrHoldNotFound
+	}
+	if !h.Active() {
+		r.mu.Unlock()
+		return ErrHoldReleased
+	}
+	now := time.Now()
+	released := *h
+	released.ReleasedAt = &now
+	released.ReleasedBy = releasedBy
+	if r.store != nil {
+		if err := r.store.SaveHold(ctx, &released); err != nil {
+			r.mu.Unlock()
+			return fmt.Errorf("failed to save legal hold: %w", err)
+		}
+	}
+	*h = released
+	r.mu.Unlock()
+
+	return r.record(ctx, &released, ActionHoldReleased, releasedBy)
+}
+
+// Get returns a hold by ID
+func (r *HoldRegistry) Get(id string) (*Hold, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h, ok := r.holds[id]
+	if !ok {
+		return nil, ErrHoldNotFound
+	}
+	cp := *h
+	return &cp, nil
+}
+
+// Holds returns every hold, active and released, oldest first
+func (r *HoldRegistry) Holds() []*Hold {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]*Hold, 0, len(r.holds))
+	for _, h := range r.holds {
+		cp := *h
+		out = append(out, &cp)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PlacedAt.Before(out[j].PlacedAt) })
+	return out
+}
+
+// Covering returns the active holds that apply to the entry
+func (r *HoldRegistry) Covering(entry *Entry) []*Hold {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []*Hold
+	for _, h := range r.holds {
+		if h.Covers(entry) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// IsHeld reports whether any active hold applies to the entry
+func (r *HoldRegistry) IsHeld(entry *Entry) bool {
+	return len(r.Covering(entry)) > 0
+}
+
+// HoldsSubject reports whether an active hold names the subject. It lets
+// token stores skip deleting a held subject's tokens.
+func (r *HoldRegistry) HoldsSubject(subject string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, h := range r.holds {
+		if h.Active() && containsStr(h.Subjects, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *HoldRegistry) hasActive() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, h := range r.holds {
+		if h.Active() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *HoldRegistry) record(ctx context.Context, h *Hold, action, actor string) error {
+	if r.sink == nil {
+		return nil
+	}
+	e := NewEntry(TypeLegalHold).
+		WithActor(actor, ActorUser).
+		WithAction(action).
+		WithTarget(h.ID, TypeLegalHold).
+		WithResult(ResultSuccess).
+		WithContext(ctx).
+		WithMetadata(MetadataMatter, h.Matter)
+	if h.Reason != "" {
+		e.WithMetadata("reason", h.Reason)
+	}
+	if err := r.sink.Store(ctx, e); err != nil {
+		return fmt.Errorf("failed to record legal hold: %w", err)
+	}
+	return nil
+}
+
+// RetentionCleaner is implemented by storages whose cleanup can keep
+// selected entries
+type RetentionCleaner interface {
+	// CleanupExcept removes entries older than before unless keep returns true
+	CleanupExcept(ctx context.Context, before time.Time, keep func(*Entry) bool) error
+}
+
+// ApplyRetention removes entries older than before while preserving every
+// entry covered by an active hold. If holds are active and the storage does
+// not implement RetentionCleaner, nothing is deleted and ErrHoldUnsupported
+// is returned.
+func ApplyRetention(ctx context.Context, storage Storage, before time.Time, holds *HoldRegistry) error {
+	if holds == nil || !holds.hasActive() {
+		return storage.Cleanup(ctx, before)
+	}
+	cleaner, ok := storage.(RetentionCleaner)
+	if !ok {
+		return ErrHoldUnsupported
+	}
+	return cleaner.CleanupExcept(ctx, before, holds.IsHeld)
+}
+
+// HoldSummary describes one hold in a compliance report
+type HoldSummary struct {
+	Hold         *Hold     `json:"hold"`
+	RecordCount  int       `json:"record_count"`
+	OldestRecord time.Time `json:"oldest_record,omitempty"`
+}
+
+// ComplianceReport lists legal holds and the records they preserve
+type ComplianceReport struct {
+	GeneratedAt time.Time     `json:"generated_at"`
+	ActiveHolds int           `json:"active_holds"`
+	Holds       []HoldSummary `json:"holds"`
+}
+
+// Report builds a compliance report of all holds and the entries in storage
+// each one currently preserves
+func (r *HoldRegistry) Report(ctx context.Context, storage Storage) (*ComplianceReport, error) {
+	entries, err := storage.Search(ctx, &Filter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit entries: %w", err)
+	}
+
+	report := &ComplianceReport{GeneratedAt: time.Now()}
+	for _, h := range r.Holds() {
+		summary := HoldSummary{Hold: h}
+		if h.Active() {
+			report.ActiveHolds++
+			for _, e := range entries {
+				if !h.Covers(e) {
+					continue
+				}
+				summary.RecordCount++
+				if summary.OldestRecord.IsZero() || e.Timestamp.Before(summary.OldestRecord) {
+					summary.OldestRecord = e.Timestamp
+				}
+			}
+		}
+		report.Holds = append(report.Holds, summary)
+	}
+	return report, nil
+}
@@ -0,0 +1,128 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegalHold(t *testing.T) {
+	ctx := context.Background()
+	storage, err := NewFileStorage(FileConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	defer storage.Close()
+
+	old := time.Now().Add(-48 * time.Hour)
+	held := NewEntry(TypeToken).WithActor("alice", ActorUser).WithAction(ActionTokenGenerate)
+	held.Timestamp = old
+	matter := NewEntry(TypeAuth).WithActor("carol", ActorUser).WithMetadata(MetadataMatter, "case-42")
+	matter.Timestamp = old
+	unheld := NewEntry(TypeToken).WithActor("bob", ActorUser).WithAction(ActionTokenGenerate)
+	unheld.Timestamp = old
+	for _, e := range []*Entry{held, matter, unheld} {
+		require.NoError(t, storage.Store(ctx, e))
+	}
+
+	holds := NewHoldRegistry(nil)
+	subjectHold, err := holds.Place(ctx, "", []string{"alice"}, "litigation", "counsel")
+	require.NoError(t, err)
+	_, err = holds.Place(ctx, "case-42", nil, "", "counsel")
+	require.NoError(t, err)
+	assert.True(t, holds.HoldsSubject("alice"))
+	assert.False(t, holds.HoldsSubject("bob"))
+
+	cutoff := time.Now().Add(-24 * time.Hour)
+	require.NoError(t, ApplyRetention(ctx, storage, cutoff, holds))
+
+	remaining, err := storage.Search(ctx, &Filter{})
+	require.NoError(t, err)
+	require.Len(t, remaining, 2)
+	for _, e := range remaining {
+		assert.NotEqual(t, unheld.ID, e.ID)
+	}
+
+	t.Run("Compliance Report", func(t *testing.T) {
+		report, err := holds.Report(ctx, storage)
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.ActiveHolds)
+		require.Len(t, report.Holds, 2)
+		for _, s := range report.Holds {
+			assert.Equal(t, 1, s.RecordCount)
+			assert.WithinDuration(t, old, s.OldestRecord, time.Second)
+		}
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		require.NoError(t, holds.Release(ctx, subjectHold.ID, "counsel"))
+		assert.ErrorIs(t, holds.Release(ctx, subjectHold.ID, "counsel"), ErrHoldReleased)
+		assert.False(t, holds.HoldsSubject("alice"))
+
+		require.NoError(t, ApplyRetention(ctx, storage, cutoff, holds))
+		remaining, err := storage.Search(ctx, &Filter{})
+		require.NoError(t, err)
+		require.Len(t, remaining, 1)
+		assert.Equal(t, matter.ID, remaining[0].ID)
+	})
+}
+
+func TestLegalHoldRedis(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	storage, err := NewRedisStorage(RedisConfig{
+		Addresses:         []string{mr.Addr()},
+		KeyPrefix:         "audit:",
+		DefaultExpiration: time.Hour,
+	})
+	require.NoError(t, err)
+	defer storage.Close()
+
+	store := NewRedisHoldStore(client, "")
+	holds, err := LoadHoldRegistry(ctx, nil, store)
+	require.NoError(t, err)
+	storage.SetHoldRegistry(holds)
+
+	alice := NewEntry(TypeToken).WithActor("alice", ActorUser)
+	bob := NewEntry(TypeToken).WithActor("bob", ActorUser)
+	require.NoError(t, storage.Store(ctx, alice))
+	require.NoError(t, storage.Store(ctx, bob))
+
+	hold, err := holds.Place(ctx, "", []string{"alice"}, "litigation", "counsel")
+	require.NoError(t, err)
+
+	t.Run("Held Entries Lose Expiry", func(t *testing.T) {
+		assert.Zero(t, mr.TTL(storage.entryKey(alice.ID)))
+		assert.Equal(t, time.Hour, mr.TTL(storage.entryKey(bob.ID)))
+
+		later := NewEntry(TypeAuth).WithActor("alice", ActorUser)
+		require.NoError(t, storage.Store(ctx, later))
+		assert.Zero(t, mr.TTL(storage.entryKey(later.ID)))
+
+		mr.FastForward(2 * time.Hour)
+		_, err := storage.GetByID(ctx, alice.ID)
+		assert.NoError(t, err)
+		_, err = storage.GetByID(ctx, bob.ID)
+		assert.Error(t, err)
+	})
+
+	t.Run("Holds Survive Restart", func(t *testing.T) {
+		reloaded, err := LoadHoldRegistry(ctx, nil, store)
+		require.NoError(t, err)
+		assert.True(t, reloaded.HoldsSubject("alice"))
+
+		require.NoError(t, reloaded.Release(ctx, hold.ID, "counsel"))
+		reloaded, err = LoadHoldRegistry(ctx, nil, store)
+		require.NoError(t, err)
+		assert.False(t, reloaded.HoldsSubject("alice"))
+		got, err := reloaded.Get(hold.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "counsel", got.ReleasedBy)
+	})
+}
@@ -0,0 +1,121 @@
+package audit
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/internal/natsconn"
+)
+
+// NATSConfig configures a NATSPublisher
+type NATSConfig struct {
+	// Address is the host:port of the NATS server
+	Address string
+
+	// Subject prefixes the subjects; entries are published to
+	// <Subject>.<type> (default: "gauth.audit")
+	Subject string
+
+	// User and Password, or Token, authenticate the connection (optional)
+	User     string
+	Password string
+	Token    string
+
+	// TLS is used when the server requires TLS (optional)
+	TLS *tls.Config
+
+	// Timeout bounds dialing and each publish (default: 5s)
+	Timeout time.Duration
+}
+
+// NATSPublisher publishes audit entries to NATS over the core text
+// protocol. Each batch ends with a flush, so Publish returns only once the
+// server has processed every message. A broken connection is redialed on
+// the next Publish.
+type NATSPublisher struct {
+	config NATSConfig
+	mu     sync.Mutex
+	conn   *natsconn.Conn
+}
+
+var _ Publisher = (*NATSPublisher)(nil)
+
+// NewNATSPublisher creates a NATS publisher; it connects on first use
+func NewNATSPublisher(config NATSConfig) (*NATSPublisher, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("nats publisher needs an address")
+	}
+	if config.Subject == "" {
+		config.Subject = "gauth.audit"
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	return &NATSPublisher{config: config}, nil
+}
+
+// Publish implements Publisher
+func (p *NATSPublisher) Publish(ctx context.Context, batch []*Entry) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil || !p.conn.Alive() {
+		p.closeConn()
+		conn, err := natsconn.Dial(ctx, natsconn.Config{
+			Address:  p.config.Address,
+			User:     p.config.User,
+			Password: p.config.Password,
+			Token:    p.config.Token,
+			TLS:      p.config.TLS,
+			Timeout:  p.config.Timeout,
+			Name:     "gauth-audit",
+		})
+		if err != nil {
+			return err
+		}
+		p.conn = conn
+	}
+	if err := p.publish(ctx, batch); err != nil {
+		p.closeConn()
+		return err
+	}
+	return nil
+}
+
+func (p *NATSPublisher) publish(ctx context.Context, batch []*Entry) error {
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+
+	for _, e := range batch {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to encode entry %s: %w", e.ID, err)
+		}
+		if err := p.conn.Publish(p.config.Subject+"."+natsconn.SubjectToken(e.Type), "", data); err != nil {
+			return fmt.Errorf("nats publish: %w", err)
+		}
+	}
+	if err := p.conn.Flush(ctx); err != nil {
+		return fmt.Errorf("nats publish: %w", err)
+	}
+	return nil
+}
+
+func (p *NATSPublisher) closeConn() {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+// Close implements Publisher
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeConn()
+	return nil
+}
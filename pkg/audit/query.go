@@ -0,0 +1,215 @@
+package audit
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor indicates a cursor that was not returned by Query
+var ErrInvalidCursor = errors.New("invalid audit cursor")
+
+const (
+	// DefaultPageSize is the page size of a Query without a Limit
+	DefaultPageSize = 100
+
+	// MaxPageSize caps the Limit of a Query
+	MaxPageSize = 1000
+)
+
+// Page is one page of Query results, newest entry first
+type Page struct {
+	Entries []*Entry `json:"entries"`
+
+	// NextCursor continues the query after the last entry of the page;
+	// empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Querier is implemented by storages that page through entries natively.
+// Query falls back to Search for storages that do not.
+type Querier interface {
+	Query(ctx context.Context, filter *Filter) (*Page, error)
+}
+
+var _ Querier = (*SQLStorage)(nil)
+
+// Query returns a page of the entries matching filter, newest first. Pass
+// the NextCursor of a page as filter.Cursor to get the next one; the cursor
+// marks a position, so entries stored meanwhile neither repeat nor shift
+// later pages. Offset is ignored.
+func Query(ctx context.Context, storage Storage, filter *Filter) (*Page, error) {
+	f := Filter{}
+	if filter != nil {
+		f = *filter
+	}
+	if _, err := decodeCursor(f.Cursor); err != nil {
+		return nil, err
+	}
+	f.Limit = pageSize(f.Limit)
+	f.Offset = 0
+	if q, ok := storage.(Querier); ok {
+		return q.Query(ctx, &f)
+	}
+
+	// Search limits in storage order, so fetch every match and page here
+	limit := f.Limit
+	f.Limit = 0
+	entries, err := storage.Search(ctx, &f)
+	if err != nil {
+		return nil, err
+	}
+	sortNewestFirst(entries)
+	return newPage(entries, limit), nil
+}
+
+// pageSize applies the default and maximum page size
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	return min(limit, MaxPageSize)
+}
+
+// newPage cuts entries, sorted newest first, to one page
+func newPage(entries []*Entry, limit int) *Page {
+	if len(entries) <= limit {
+		return &Page{Entries: entries}
+	}
+	entries = entries[:limit]
+	last := entries[len(entries)-1]
+	return &Page{Entries: entries, NextCursor: encodeCursor(last)}
+}
+
+// sortNewestFirst orders entries by timestamp, then ID, descending
+func sortNewestFirst(entries []*Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.After(b.Timestamp)
+		}
+		return a.ID > b.ID
+	})
+}
+
+// cursor is a decoded page position: the last entry of the previous page
+type cursor struct {
+	timestamp time.Time
+	id        string
+}
+
+// before reports whether e sorts after the cursor position
+func (c *cursor) before(e *Entry) bool {
+	if !e.Timestamp.Equal(c.timestamp) {
+		return e.Timestamp.Before(c.timestamp)
+	}
+	return e.ID < c.id
+}
+
+func encodeCursor(e *Entry) string {
+	raw := strconv.FormatInt(e.Timestamp.UnixNano(), 10) + ":" + e.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor returns nil for an empty cursor
+func decodeCursor(s string) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	ns, id, ok := strings.Cut(string(raw), ":")
+	if !ok || id == "" {
+		return nil, ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(ns, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor{timestamp: time.Unix(0, n), id: id}, nil
+}
+
+// matchesQuery applies the target, free-text and cursor criteria of a
+// filter for storages that filter in memory
+func matchesQuery(entry *Entry, filter *Filter) bool {
+	if len(filter.TargetIDs) > 0 && !containsStr(filter.TargetIDs, entry.TargetID) {
+		return false
+	}
+	if len(filter.TargetTypes) > 0 && !containsStr(filter.TargetTypes, entry.TargetType) {
+		return false
+	}
+	if filter.Text != "" && !matchesText(entry, filter.Text) {
+		return false
+	}
+	if c, err := decodeCursor(filter.Cursor); err != nil || (c != nil && !c.before(entry)) {
+		return false
+	}
+	return true
+}
+
+// matchesText reports whether any searchable field of the entry contains
+// text, ignoring case
+func matchesText(entry *Entry, text string) bool {
+	text = strings.ToLower(text)
+	fields := []string{
+		entry.Action, entry.ActorID, entry.ActorName, entry.TargetID,
+		entry.TargetName, entry.Error, entry.ClientIP,
+	}
+	for _, v := range entry.Metadata {
+		fields = append(fields, v)
+	}
+	for _, f := range fields {
+		if strings.Contains(strings.ToLower(f), text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Query returns the page of entries matching filter that the principal may
+// read. Withheld entries still advance the cursor, so a page may hold fewer
+// entries than the limit without being the last.
+func (r *GuardedReader) Query(ctx context.Context, principal Principal, filter *Filter) (*Page, error) {
+	page, err := Query(ctx, r.storage, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make([]*Entry, 0, len(page.Entries))
+	for _, e := range page.Entries {
+		if r.policy.CanRead(principal, e) {
+			visible = append(visible, e)
+		}
+	}
+
+	result := ResultSuccess
+	if len(visible) < len(page.Entries) {
+		result = "partial"
+	}
+	access := r.accessEntry(ctx, principal, ActionAuditQuery, result).
+		WithMetadata("returned", strconv.Itoa(len(visible))).
+		WithMetadata("withheld", strconv.Itoa(len(page.Entries)-len(visible))).
+		WithMetadata("filter", describeFilter(filter))
+	if err := r.sink.Store(ctx, access); err != nil {
+		return nil, fmt.Errorf("failed to record audit access: %w", err)
+	}
+
+	return &Page{Entries: visible, NextCursor: page.NextCursor}, nil
+}
+
+// Query implements Querier, resolving names like Search
+func (s *EnrichingStorage) Query(ctx context.Context, filter *Filter) (*Page, error) {
+	page, err := Query(ctx, s.Storage, filter)
+	if err != nil {
+		return nil, err
+	}
+	page.Entries = s.enrichRead(ctx, page.Entries)
+	return page, nil
+}
@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery(t *testing.T) {
+	ctx := context.Background()
+	storage, err := NewFileStorage(FileConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	defer storage.Close()
+
+	base := time.Now().Add(-time.Hour)
+	var stored []*Entry
+	for i := 0; i < 7; i++ {
+		e := NewEntry(TypeResource).WithActor("alice", ActorUser).WithAction("read").
+			WithTarget("invoice-1", "invoice").WithResult(ResultSuccess)
+		e.Timestamp = base.Add(time.Duration(i) * time.Minute)
+		stored = append(stored, e)
+	}
+	denied := NewEntry(TypeResource).WithActor("bob", ActorUser).WithAction("delete").
+		WithTarget("invoice-2", "invoice").WithResult("denied").WithMetadata("reason", "Outside Business Hours")
+	denied.Timestamp = base.Add(30 * time.Minute)
+	stored = append(stored, denied)
+	for _, e := range stored {
+		require.NoError(t, storage.Store(ctx, e))
+	}
+
+	t.Run("Cursor Pagination", func(t *testing.T) {
+		filter := &Filter{ActorIDs: []string{"alice"}, Limit: 3}
+		var seen []*Entry
+		pages := 0
+		for {
+			page, err := Query(ctx, storage, filter)
+			require.NoError(t, err)
+			seen = append(seen, page.Entries...)
+			pages++
+			if page.NextCursor == "" {
+				break
+			}
+			filter.Cursor = page.NextCursor
+
+			// Entries stored after the first page do not shift later pages
+			require.NoError(t, storage.Store(ctx, NewEntry(TypeResource).WithActor("alice", ActorUser)))
+		}
+		assert.Equal(t, 3, pages)
+		require.Len(t, seen, 7)
+		for i := range seen {
+			assert.Equal(t, stored[6-i].ID, seen[i].ID, "newest first")
+		}
+	})
+
+	t.Run("Resource, Outcome And Text", func(t *testing.T) {
+		page, err := Query(ctx, storage, &Filter{
+			TargetTypes: []string{"invoice"},
+			Results:     []string{"denied"},
+			Text:        "business hours",
+			TimeRange:   &TimeRange{Start: base, End: base.Add(time.Hour)},
+		})
+		require.NoError(t, err)
+		require.Len(t, page.Entries, 1)
+		assert.Equal(t, denied.ID, page.Entries[0].ID)
+		assert.Empty(t, page.NextCursor)
+
+		page, err = Query(ctx, storage, &Filter{TargetIDs: []string{"invoice-1"}, Text: "BOB"})
+		require.NoError(t, err)
+		assert.Empty(t, page.Entries)
+	})
+
+	t.Run("Invalid Cursor", func(t *testing.T) {
+		_, err := Query(ctx, storage, &Filter{Cursor: "not a cursor"})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("Guarded", func(t *testing.T) {
+		policy := &AccessPolicy{Rules: []AccessRule{{Role: "user", OwnOnly: true, AllTenants: true}}}
+		reader := NewGuardedReader(storage, policy, nil)
+		page, err := reader.Query(ctx, Principal{ID: "bob", Roles: []string{"user"}},
+			&Filter{TargetTypes: []string{"invoice"}, Limit: 5})
+		require.NoError(t, err)
+		require.Len(t, page.Entries, 1)
+		assert.Equal(t, denied.ID, page.Entries[0].ID)
+		assert.NotEmpty(t, page.NextCursor, "withheld entries remain")
+	})
+}
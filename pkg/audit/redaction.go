@@ -0,0 +1,302 @@
+package audit
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotEncrypted indicates a value that was not encrypted by a Redactor
+var ErrNotEncrypted = errors.New("value is not an encrypted audit field")
+
+// RedactionAction is what a redaction rule does to a field
+type RedactionAction string
+
+const (
+	// RedactMask keeps the first character and masks the rest, so records
+	// stay readable without identifying anyone
+	RedactMask RedactionAction = "mask"
+
+	// RedactHash replaces the value with a keyed HMAC-SHA256 pseudonym; the
+	// same value always gives the same pseudonym, so entries remain
+	// correlatable and searchable with Redactor.Pseudonym
+	RedactHash RedactionAction = "hash"
+
+	// RedactEncrypt encrypts the value with AES-256-GCM; Redactor.Reveal
+	// restores it for authorized access such as a data subject request
+	RedactEncrypt RedactionAction = "encrypt"
+
+	// RedactRemove deletes the value
+	RedactRemove RedactionAction = "remove"
+)
+
+// Redactable entry fields. Metadata values are addressed as "metadata.<key>".
+const (
+	FieldActorID    = "actor_id"
+	FieldActorName  = "actor_name"
+	FieldTargetID   = "target_id"
+	FieldTargetName = "target_name"
+	FieldSessionID  = "session_id"
+	FieldClientIP   = "client_ip"
+	FieldClientInfo = "client_info"
+	FieldLocation   = "location"
+	FieldError      = "error"
+
+	metadataFieldPrefix = "metadata."
+)
+
+// Prefixes marking redacted values
+const (
+	hashPrefix      = "hmac:"
+	encryptedPrefix = "enc:"
+	maskSuffix      = "***"
+)
+
+// RedactionRule applies an action to one field
+type RedactionRule struct {
+	Field  string
+	Action RedactionAction
+}
+
+// RedactionConfig configures a Redactor
+type RedactionConfig struct {
+	// Rules are applied in order
+	Rules []RedactionRule
+
+	// HashKey keys the pseudonyms of RedactHash; keep it secret, or
+	// pseudonyms of guessable values such as IPs can be reversed by brute
+	// force (required for RedactHash)
+	HashKey []byte
+
+	// EncryptionKey is the 32-byte AES-256 key of RedactEncrypt (required
+	// for RedactEncrypt)
+	EncryptionKey []byte
+}
+
+// Redactor applies a redaction pipeline to audit entries, so trails can be
+// kept long-term without holding personal data in the clear
+type Redactor struct {
+	rules   []RedactionRule
+	hashKey []byte
+	gcm     cipher.AEAD
+}
+
+// NewRedactor validates the rules and keys and creates a redactor
+func NewRedactor(config RedactionConfig) (*Redactor, error) {
+	r := &Redactor{rules: config.Rules, hashKey: config.HashKey}
+	for _, rule := range config.Rules {
+		if !validField(rule.Field) {
+			return nil, fmt.Errorf("cannot redact unknown field %q", rule.Field)
+		}
+		switch rule.Action {
+		case RedactMask, RedactRemove:
+		case RedactHash:
+			if len(config.HashKey) == 0 {
+				return nil, errors.New("hash redaction needs a hash key")
+			}
+		case RedactEncrypt:
+			if r.gcm != nil {
+				continue
+			}
+			if len(config.EncryptionKey) != 32 {
+				return nil, errors.New("encryption key must be 32 bytes for AES-256")
+			}
+			block, err := aes.NewCipher(config.EncryptionKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create cipher: %w", err)
+			}
+			if r.gcm, err = cipher.NewGCM(block); err != nil {
+				return nil, fmt.Errorf("failed to create GCM: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("unknown redaction action %q", rule.Action)
+		}
+	}
+	return r, nil
+}
+
+func validField(field string) bool {
+	switch field {
+	case FieldActorID, FieldActorName, FieldTargetID, FieldTargetName, FieldSessionID,
+		FieldClientIP, FieldClientInfo, FieldLocation, FieldError:
+		return true
+	}
+	return strings.HasPrefix(field, metadataFieldPrefix) && len(field) > len(metadataFieldPrefix)
+}
+
+// Redact returns a redacted copy of the entry; the entry itself is not
+// modified
+func (r *Redactor) Redact(entry *Entry) (*Entry, error) {
+	c := copyEntry(entry)
+	for _, rule := range r.rules {
+		value, set := field(c, rule.Field)
+		if value == "" {
+			continue
+		}
+		redacted, err := r.apply(rule.Action, value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to redact %s: %w", rule.Field, err)
+		}
+		set(redacted)
+	}
+	return c, nil
+}
+
+func (r *Redactor) apply(action RedactionAction, value string) (string, error) {
+	switch action {
+	case RedactMask:
+		if strings.HasSuffix(value, maskSuffix) {
+			return value, nil
+		}
+		first := []rune(value)[0]
+		return string(first) + maskSuffix, nil
+	case RedactHash:
+		if strings.HasPrefix(value, hashPrefix) {
+			return value, nil
+		}
+		return r.Pseudonym(value), nil
+	case RedactEncrypt:
+		if strings.HasPrefix(value, encryptedPrefix) {
+			return value, nil
+		}
+		return r.encrypt(value)
+	default:
+		return "", nil
+	}
+}
+
+// Pseudonym returns the pseudonym RedactHash stores for value, for
+// searching redacted entries by subject
+func (r *Redactor) Pseudonym(value string) string {
+	mac := hmac.New(sha256.New, r.hashKey)
+	mac.Write([]byte(value))
+	return hashPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (r *Redactor) encrypt(value string) (string, error) {
+	nonce := make([]byte, r.gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := r.gcm.Seal(nonce, nonce, []byte(value), nil)
+	return encryptedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the plaintext of a value encrypted by RedactEncrypt
+func (r *Redactor) Decrypt(value string) (string, error) {
+	if r.gcm == nil || !strings.HasPrefix(value, encryptedPrefix) {
+		return "", ErrNotEncrypted
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil || len(sealed) < r.gcm.NonceSize() {
+		return "", ErrNotEncrypted
+	}
+	nonce, ciphertext := sealed[:r.gcm.NonceSize()], sealed[r.gcm.NonceSize():]
+	plain, err := r.gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt audit field: %w", err)
+	}
+	return string(plain), nil
+}
+
+// Reveal returns a copy of the entry with its encrypted fields decrypted.
+// Masked, hashed and removed fields cannot be restored.
+func (r *Redactor) Reveal(entry *Entry) (*Entry, error) {
+	c := copyEntry(entry)
+	for _, rule := range r.rules {
+		if rule.Action != RedactEncrypt {
+			continue
+		}
+		value, set := field(c, rule.Field)
+		if !strings.HasPrefix(value, encryptedPrefix) {
+			continue
+		}
+		plain, err := r.Decrypt(value)
+		if err != nil {
+			return nil, err
+		}
+		set(plain)
+	}
+	return c, nil
+}
+
+// copyEntry copies an entry with its own metadata map
+func copyEntry(entry *Entry) *Entry {
+	c := *entry
+	if entry.Metadata != nil {
+		c.Metadata = make(Metadata, len(entry.Metadata))
+		for k, v := range entry.Metadata {
+			c.Metadata[k] = v
+		}
+	}
+	return &c
+}
+
+// field returns the value of a field and a function setting it; setting ""
+// removes a metadata key
+func field(e *Entry, name string) (string, func(string)) {
+	var p *string
+	switch name {
+	case FieldActorID:
+		p = &e.ActorID
+	case FieldActorName:
+		p = &e.ActorName
+	case FieldTargetID:
+		p = &e.TargetID
+	case FieldTargetName:
+		p = &e.TargetName
+	case FieldSessionID:
+		p = &e.SessionID
+	case FieldClientIP:
+		p = &e.ClientIP
+	case FieldClientInfo:
+		p = &e.ClientInfo
+	case FieldLocation:
+		p = &e.Location
+	case FieldError:
+		p = &e.Error
+	default:
+		key := strings.TrimPrefix(name, metadataFieldPrefix)
+		return e.Metadata[key], func(v string) {
+			if v == "" {
+				delete(e.Metadata, key)
+			} else {
+				e.Metadata[key] = v
+			}
+		}
+	}
+	return *p, func(v string) { *p = v }
+}
+
+// RedactingStorage wraps a Storage so entries are redacted before they are
+// persisted. Make it the outermost wrapper, so a StreamingStorage inside it
+// streams redacted entries too. Search hashed fields with Redactor.Pseudonym
+// of the value.
+type RedactingStorage struct {
+	Storage
+	redactor *Redactor
+}
+
+var _ Storage = (*RedactingStorage)(nil)
+
+// NewRedactingStorage wraps storage with the redactor
+func NewRedactingStorage(storage Storage, redactor *Redactor) *RedactingStorage {
+	return &RedactingStorage{Storage: storage, redactor: redactor}
+}
+
+// Store implements Storage, persisting the redacted copy of the entry
+func (s *RedactingStorage) Store(ctx context.Context, entry *Entry) error {
+	redacted, err := s.redactor.Redact(entry)
+	if err != nil {
+		return err
+	}
+	return s.Storage.Store(ctx, redacted)
+}
@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedaction(t *testing.T) {
+	ctx := context.Background()
+	redactor, err := NewRedactor(RedactionConfig{
+		Rules: []RedactionRule{
+			{Field: FieldActorID, Action: RedactHash},
+			{Field: FieldActorName, Action: RedactMask},
+			{Field: FieldClientIP, Action: RedactHash},
+			{Field: "metadata.email", Action: RedactEncrypt},
+			{Field: "metadata.phone", Action: RedactRemove},
+		},
+		HashKey:       []byte("pseudonym-key"),
+		EncryptionKey: []byte("0123456789abcdef0123456789abcdef"),
+	})
+	require.NoError(t, err)
+
+	storage, err := NewFileStorage(FileConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	redacting := NewRedactingStorage(storage, redactor)
+	defer redacting.Close()
+
+	entry := NewEntry(TypeAuth).WithActor("alice", ActorUser).WithAction(ActionLogin).
+		WithMetadata("email", "alice@example.com").WithMetadata("phone", "+49 30 1234").
+		WithMetadata("tenant", "acme")
+	entry.ActorName = "Alice Example"
+	entry.ClientIP = "203.0.113.7"
+	require.NoError(t, redacting.Store(ctx, entry))
+	assert.Equal(t, "alice", entry.ActorID, "the caller's entry is not modified")
+
+	stored, err := storage.Search(ctx, &Filter{ActorIDs: []string{redactor.Pseudonym("alice")}})
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	got := stored[0]
+	assert.Equal(t, "A***", got.ActorName)
+	assert.True(t, strings.HasPrefix(got.ClientIP, "hmac:"))
+	assert.NotContains(t, got.Metadata["email"], "alice")
+	assert.NotContains(t, got.Metadata, "phone")
+	assert.Equal(t, "acme", got.Metadata["tenant"])
+
+	again, err := redactor.Redact(got)
+	require.NoError(t, err)
+	assert.Equal(t, got.ActorID, again.ActorID, "redaction is idempotent")
+	assert.Equal(t, got.Metadata["email"], again.Metadata["email"])
+
+	revealed, err := redactor.Reveal(got)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", revealed.Metadata["email"])
+	assert.Equal(t, got.ActorID, revealed.ActorID, "hashes cannot be revealed")
+
+	_, err = redactor.Decrypt("alice@example.com")
+	assert.ErrorIs(t, err, ErrNotEncrypted)
+
+	_, err = NewRedactor(RedactionConfig{Rules: []RedactionRule{{Field: "password", Action: RedactMask}}})
+	assert.Error(t, err)
+	_, err = NewRedactor(RedactionConfig{Rules: []RedactionRule{{Field: FieldClientIP, Action: RedactHash}}})
+	assert.Error(t, err, "hashing without a key")
+}
@@ -14,6 +14,7 @@ type RedisStorage struct {
 	client     redis.UniversalClient
 	keyPrefix  string
 	expiration time.Duration
+	holds      *HoldRegistry
 }
 
 // RedisConfig holds configuration for Redis storage
@@ -82,6 +83,14 @@ func NewRedisStorage(config RedisConfig) (*RedisStorage, error) {
 	}, nil
 }
 
+// SetHoldRegistry makes the storage honour holds before cleanup runs: held
+// entries are stored without expiry, and placing a hold removes the expiry
+// of the entries it covers. Call it before storing entries.
+func (rs *RedisStorage) SetHoldRegistry(holds *HoldRegistry) {
+	rs.holds = holds
+	holds.addPinner(rs)
+}
+
 // Store implements the Storage interface
 func (rs *RedisStorage) Store(ctx context.Context, entry *Entry) error {
 	data, err := json.Marshal(entry)
@@ -94,6 +103,13 @@ func (rs *RedisStorage) Store(ctx context.Context, entry *Entry) error {
 	if err := rs.client.Set(ctx, key, data, rs.expiration).Err(); err != nil {
 		return fmt.Errorf("failed to store entry: %w", err)
 	}
+	// Checked after the write, so a hold placed concurrently either sees
+	// the key when pinning or is seen here
+	if rs.expiration > 0 && rs.holds != nil && rs.holds.IsHeld(entry) {
+		if err := rs.client.Persist(ctx, key).Err(); err != nil {
+			return fmt.Errorf("failed to pin held entry: %w", err)
+		}
+	}
 
 	// Add to type index
 	typeKey := rs.typeKey(entry.Type)
@@ -117,6 +133,14 @@ func (rs *RedisStorage) Store(ctx context.Context, entry *Entry) error {
 		}
 	}
 
+	// Add to correlation index
+	if entry.CorrelationID != "" {
+		correlationKey := rs.correlationKey(entry.CorrelationID)
+		if err := rs.client.SAdd(ctx, correlationKey, entry.ID).Err(); err != nil {
+			return fmt.Errorf("failed to index by correlation: %w", err)
+		}
+	}
+
 	// Add to time index
 	timeKey := rs.timeKey(entry.Timestamp)
 	if err := rs.client.SAdd(ctx, timeKey, entry.ID).Err(); err != nil {
@@ -176,6 +200,13 @@ func (rs *RedisStorage) GetChain(ctx context.Context, chainID string) ([]*Entry,
 
 // Cleanup implements the Storage interface
 func (rs *RedisStorage) Cleanup(ctx context.Context, before time.Time) error {
+	return rs.CleanupExcept(ctx, before, nil)
+}
+
+// CleanupExcept implements the RetentionCleaner interface. Kept entries
+// lose their expiry and stay in their day's time index so later cleanups
+// see them again.
+func (rs *RedisStorage) CleanupExcept(ctx context.Context, before time.Time, keep func(*Entry) bool) error {
 	pattern := rs.keyPrefix + "time:*"
 	var cursor uint64
 	for {
@@ -196,10 +227,22 @@ func (rs *RedisStorage) Cleanup(ctx context.Context, before time.Time) error {
 
 				// Delete entries and indices
 				pipe := rs.client.Pipeline()
+				kept := 0
 				for _, id := range ids {
+					if keep != nil {
+						if entry, err := rs.GetByID(ctx, id); err == nil && keep(entry) {
+							// Held entries must not be pruned by key expiry either
+							pipe.Persist(ctx, rs.entryKey(id))
+							kept++
+							continue
+						}
+					}
 					pipe.Del(ctx, rs.entryKey(id))
+					pipe.SRem(ctx, key, id)
+				}
+				if kept == 0 {
+					pipe.Del(ctx, key)
 				}
-				pipe.Del(ctx, key)
 				if _, err := pipe.Exec(ctx); err != nil {
 					return fmt.Errorf("failed to cleanup entries: %w", err)
 				}
@@ -214,6 +257,33 @@ func (rs *RedisStorage) Cleanup(ctx context.Context, before time.Time) error {
 	return nil
 }
 
+// PinHeld implements the HoldPinner interface
+func (rs *RedisStorage) PinHeld(ctx context.Context, keep func(*Entry) bool) error {
+	if rs.expiration <= 0 {
+		return nil
+	}
+	var cursor uint64
+	for {
+		keys, next, err := rs.client.Scan(ctx, cursor, rs.entryKey("*"), 100).Result()
+		if err != nil {
+			return fmt.Errorf("failed to scan entries: %w", err)
+		}
+		for _, key := range keys {
+			entry, err := rs.GetByID(ctx, rs.extractID(key))
+			if err != nil || !keep(entry) {
+				continue
+			}
+			if err := rs.client.Persist(ctx, key).Err(); err != nil {
+				return fmt.Errorf("failed to pin held entry: %w", err)
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
 // Close implements io.Closer
 func (rs *RedisStorage) Close() error {
 	return rs.client.Close()
@@ -237,6 +307,10 @@ func (rs *RedisStorage) chainKey(chainID string) string {
 	return fmt.Sprintf("%schain:%s", rs.keyPrefix, chainID)
 }
 
+func (rs *RedisStorage) correlationKey(correlationID string) string {
+	return fmt.Sprintf("%scorrelation:%s", rs.keyPrefix, correlationID)
+}
+
 func (rs *RedisStorage) timeKey(t time.Time) string {
 	return fmt.Sprintf("%stime:%s", rs.keyPrefix, t.Format("2006-01-02"))
 }
@@ -289,17 +363,28 @@ func (rs *RedisStorage) matchesFilter(entry *Entry, filter *Filter) bool {
 			return false
 		}
 	}
+	// Action and result match
+	if len(filter.Actions) > 0 && !containsStr(filter.Actions, entry.Action) {
+		return false
+	}
+	if len(filter.Results) > 0 && !containsStr(filter.Results, entry.Result) {
+		return false
+	}
 	// Chain match
 	if filter.ChainID != "" && entry.ChainID != filter.ChainID {
 		return false
 	}
+	// Correlation match
+	if filter.CorrelationID != "" && entry.CorrelationID != filter.CorrelationID {
+		return false
+	}
 	// Time range match
 	if filter.TimeRange != nil {
 		if entry.Timestamp.Before(filter.TimeRange.Start) || entry.Timestamp.After(filter.TimeRange.End) {
 			return false
 		}
 	}
-	return true
+	return matchesQuery(entry, filter)
 }
 
 // Helper function to compute set intersection
@@ -323,7 +408,11 @@ func (rs *RedisStorage) getFilteredIDs(ctx context.Context, filter *Filter) ([]s
 	var ids []string
 	var err error
 
-	if len(filter.Types) > 0 {
+	if filter.CorrelationID != "" {
+		// The correlation index is the narrowest; remaining criteria are
+		// applied by matchesFilter
+		ids, err = rs.client.SMembers(ctx, rs.correlationKey(filter.CorrelationID)).Result()
+	} else if len(filter.Types) > 0 {
 		// Union of all type sets
 		typeKeys := make([]string, len(filter.Types))
 		for i, t := range filter.Types {
@@ -431,3 +520,43 @@ func (rs *RedisStorage) fetchAndFilterEntries(ctx context.Context, ids []string,
 
 	return entries, nil
 }
+
+// RedisHoldStore implements HoldStore as a Redis hash of JSON holds
+type RedisHoldStore struct {
+	client redis.UniversalClient
+	key    string
+}
+
+// NewRedisHoldStore creates a Redis-backed hold store (prefix default: "gauth:audit:")
+func NewRedisHoldStore(client redis.UniversalClient, prefix string) *RedisHoldStore {
+	if prefix == "" {
+		prefix = "gauth:audit:"
+	}
+	return &RedisHoldStore{client: client, key: prefix + "holds"}
+}
+
+// SaveHold implements HoldStore
+func (s *RedisHoldStore) SaveHold(ctx context.Context, h *Hold) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("failed to marshal hold: %w", err)
+	}
+	return s.client.HSet(ctx, s.key, h.ID, data).Err()
+}
+
+// LoadHolds implements HoldStore
+func (s *RedisHoldStore) LoadHolds(ctx context.Context) ([]*Hold, error) {
+	values, err := s.client.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return nil, err
+	}
+	holds := make([]*Hold, 0, len(values))
+	for id, data := range values {
+		var h Hold
+		if err := json.Unmarshal([]byte(data), &h); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal hold %s: %w", id, err)
+		}
+		holds = append(holds, &h)
+	}
+	return holds, nil
+}
@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RetentionPolicy decides how long audit entries are kept, which entries
+// survive their expiry and where expired entries go before deletion
+type RetentionPolicy struct {
+	// MaxAge is how long entries are kept; zero keeps them forever
+	MaxAge time.Duration
+
+	// TypeMaxAge overrides MaxAge per entry type, for example a shorter
+	// age for high-volume access records (optional)
+	TypeMaxAge map[string]time.Duration
+
+	// Holds preserve entries under an active legal hold (optional)
+	Holds *HoldRegistry
+
+	// Archive receives expired entries before they are deleted; if the
+	// upload fails nothing is deleted (optional)
+	Archive *Archiver
+
+	// Interval is how often Run applies the policy (default: 1h)
+	Interval time.Duration
+
+	// OnError receives failures of Run (optional)
+	OnError func(error)
+
+	// Now returns the current time (default: time.Now)
+	Now func() time.Time
+}
+
+// maxAge returns the age after which entries of the type expire
+func (p *RetentionPolicy) maxAge(typ string) time.Duration {
+	if age, ok := p.TypeMaxAge[typ]; ok {
+		return age
+	}
+	return p.MaxAge
+}
+
+// shortest returns the smallest positive age, or zero if nothing expires
+func (p *RetentionPolicy) shortest() time.Duration {
+	shortest := p.MaxAge
+	for _, age := range p.TypeMaxAge {
+		if age > 0 && (shortest <= 0 || age < shortest) {
+			shortest = age
+		}
+	}
+	return shortest
+}
+
+// Apply deletes the expired entries from storage, archiving them first
+// when an archive is configured. Per-type ages, holds and archiving need a
+// storage implementing RetentionCleaner.
+func (p *RetentionPolicy) Apply(ctx context.Context, storage Storage) error {
+	shortest := p.shortest()
+	if shortest <= 0 {
+		return nil
+	}
+	now := time.Now()
+	if p.Now != nil {
+		now = p.Now()
+	}
+	cutoff := now.Add(-shortest)
+
+	simple := len(p.TypeMaxAge) == 0 && p.Archive == nil && (p.Holds == nil || !p.Holds.hasActive())
+	if simple {
+		return storage.Cleanup(ctx, cutoff)
+	}
+	cleaner, ok := storage.(RetentionCleaner)
+	if !ok {
+		if p.Holds != nil && p.Holds.hasActive() {
+			return ErrHoldUnsupported
+		}
+		return errors.New("storage cannot apply per-type retention or archiving")
+	}
+
+	keep := func(e *Entry) bool {
+		if p.Holds != nil && p.Holds.IsHeld(e) {
+			return true
+		}
+		age := p.maxAge(e.Type)
+		return age <= 0 || e.Timestamp.After(now.Add(-age))
+	}
+
+	if p.Archive != nil {
+		// First pass only collects, so nothing is deleted before the
+		// archive holds it
+		var expired []*Entry
+		if err := cleaner.CleanupExcept(ctx, cutoff, func(e *Entry) bool {
+			if !keep(e) {
+				expired = append(expired, e)
+			}
+			return true
+		}); err != nil {
+			return err
+		}
+		if err := p.Archive.Archive(ctx, expired); err != nil {
+			return fmt.Errorf("failed to archive expired entries: %w", err)
+		}
+	}
+	return cleaner.CleanupExcept(ctx, cutoff, keep)
+}
+
+// Run applies the policy every Interval until ctx is cancelled
+func (p *RetentionPolicy) Run(ctx context.Context, storage Storage) {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Apply(ctx, storage); err != nil && p.OnError != nil {
+				p.OnError(err)
+			}
+		}
+	}
+}
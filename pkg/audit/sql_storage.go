@@ -59,6 +59,7 @@ CREATE TABLE IF NOT EXISTS audit_entries (
     tags TEXT[],
     metadata JSONB,
     error TEXT,
+    correlation_id TEXT,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -66,8 +67,16 @@ CREATE INDEX IF NOT EXISTS idx_audit_type ON audit_entries(type);
 CREATE INDEX IF NOT EXISTS idx_audit_timestamp ON audit_entries(timestamp);
 CREATE INDEX IF NOT EXISTS idx_audit_actor_id ON audit_entries(actor_id);
 CREATE INDEX IF NOT EXISTS idx_audit_chain_id ON audit_entries(chain_id);
+ALTER TABLE audit_entries ADD COLUMN IF NOT EXISTS correlation_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_audit_correlation_id ON audit_entries(correlation_id);
 CREATE INDEX IF NOT EXISTS idx_audit_target_id ON audit_entries(target_id);
 CREATE INDEX IF NOT EXISTS idx_audit_tags ON audit_entries USING gin(tags);
+CREATE INDEX IF NOT EXISTS idx_audit_action ON audit_entries(action);
+CREATE INDEX IF NOT EXISTS idx_audit_result ON audit_entries(result);
+CREATE INDEX IF NOT EXISTS idx_audit_metadata ON audit_entries USING gin(metadata jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_audit_actor_timestamp ON audit_entries(actor_id, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_type_timestamp ON audit_entries(type, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_timestamp_id ON audit_entries(timestamp DESC, id DESC);
 `
 
 // NewSQLStorage creates a new SQL-backed storage
@@ -106,10 +115,10 @@ func (s *SQLStorage) Store(ctx context.Context, entry *Entry) error {
 			id, type, action, result, level, timestamp, chain_id, prev_hash,
 			actor_id, actor_type, actor_name, session_id, client_ip, client_info,
 			target_id, target_type, target_name, target_changes,
-			location, trace_id, tags, metadata, error
+			location, trace_id, tags, metadata, error, correlation_id
 		) VALUES (
 			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14,
-			$15, $16, $17, $18, $19, $20, $21, $22, $23
+			$15, $16, $17, $18, $19, $20, $21, $22, $23, $24
 		)`
 
 	targetChanges, err := json.Marshal(entry.TargetChanges)
@@ -129,7 +138,7 @@ func (s *SQLStorage) Store(ctx context.Context, entry *Entry) error {
 		entry.ClientIP, entry.ClientInfo,
 		entry.TargetID, entry.TargetType, entry.TargetName, targetChanges,
 		entry.Location, entry.TraceID, pq.Array(entry.Tags), metadata,
-		entry.Error,
+		entry.Error, entry.CorrelationID,
 	)
 
 	if err != nil {
@@ -151,16 +160,24 @@ func (s *SQLStorage) Search(ctx context.Context, filter *Filter) ([]*Entry, erro
 	return s.executeQueryAndScanResults(ctx, query, args)
 }
 
+// Query implements Querier, paging with the (timestamp, id) cursor in SQL
+func (s *SQLStorage) Query(ctx context.Context, filter *Filter) (*Page, error) {
+	f := *filter
+	limit := pageSize(f.Limit)
+	// One extra row tells whether another page follows
+	f.Limit = limit + 1
+	f.Offset = 0
+	entries, err := s.Search(ctx, &f)
+	if err != nil {
+		return nil, err
+	}
+	return newPage(entries, limit), nil
+}
+
 // GetByID implements the Storage interface
 func (s *SQLStorage) GetByID(ctx context.Context, id string) (*Entry, error) {
-	entries, err := s.Search(ctx, &Filter{
-		Metadata: []MetadataFilter{{
-			Key:      "id",
-			Value:    id,
-			Operator: "eq",
-		}},
-		Limit: 1,
-	})
+	entries, err := s.executeQueryAndScanResults(ctx,
+		"SELECT "+selectColumns+" FROM audit_entries WHERE id = $1", []interface{}{id})
 	if err != nil {
 		return nil, err
 	}
@@ -196,6 +213,46 @@ func (s *SQLStorage) buildWhereConditions(filter *Filter) ([]string, []interface
 		conditions = append(conditions, fmt.Sprintf("actor_id = ANY(ARRAY[%s])", strings.Join(placeholders, ",")))
 	}
 
+	if len(filter.Actions) > 0 {
+		conditions = append(conditions, fmt.Sprintf("action = ANY($%d)", argCount))
+		args = append(args, pq.Array(filter.Actions))
+		argCount++
+	}
+
+	if len(filter.Results) > 0 {
+		conditions = append(conditions, fmt.Sprintf("result = ANY($%d)", argCount))
+		args = append(args, pq.Array(filter.Results))
+		argCount++
+	}
+
+	if len(filter.Tags) > 0 {
+		// Any of the tags, using the GIN index
+		conditions = append(conditions, fmt.Sprintf("tags && $%d", argCount))
+		args = append(args, pq.Array(filter.Tags))
+		argCount++
+	}
+
+	for _, mf := range filter.Metadata {
+		switch mf.Operator {
+		case "eq":
+			// Containment uses the GIN index on metadata
+			doc, _ := json.Marshal(map[string]string{mf.Key: fmt.Sprint(mf.Value)})
+			conditions = append(conditions, fmt.Sprintf("metadata @> $%d", argCount))
+			args = append(args, string(doc))
+			argCount++
+		case "ne":
+			conditions = append(conditions, fmt.Sprintf("metadata->>$%d <> $%d", argCount, argCount+1))
+			args = append(args, mf.Key, fmt.Sprint(mf.Value))
+			argCount += 2
+		case "contains":
+			conditions = append(conditions, fmt.Sprintf("strpos(metadata->>$%d, $%d) > 0", argCount, argCount+1))
+			args = append(args, mf.Key, fmt.Sprint(mf.Value))
+			argCount += 2
+		default:
+			conditions = append(conditions, "FALSE")
+		}
+	}
+
 	if filter.TimeRange != nil {
 		conditions = append(conditions, fmt.Sprintf("timestamp BETWEEN $%d AND $%d", argCount, argCount+1))
 		args = append(args, filter.TimeRange.Start, filter.TimeRange.End)
@@ -208,18 +265,67 @@ func (s *SQLStorage) buildWhereConditions(filter *Filter) ([]string, []interface
 		argCount++
 	}
 
+	if filter.CorrelationID != "" {
+		conditions = append(conditions, fmt.Sprintf("correlation_id = $%d", argCount))
+		args = append(args, filter.CorrelationID)
+		argCount++
+	}
+
+	if len(filter.TargetIDs) > 0 {
+		conditions = append(conditions, fmt.Sprintf("target_id = ANY($%d)", argCount))
+		args = append(args, pq.Array(filter.TargetIDs))
+		argCount++
+	}
+
+	if len(filter.TargetTypes) > 0 {
+		conditions = append(conditions, fmt.Sprintf("target_type = ANY($%d)", argCount))
+		args = append(args, pq.Array(filter.TargetTypes))
+		argCount++
+	}
+
+	if filter.Text != "" {
+		// strpos needs no escaping of LIKE wildcards in the text
+		var matches []string
+		for _, column := range textColumns {
+			matches = append(matches, fmt.Sprintf("strpos(lower(%s), $%d) > 0", column, argCount))
+		}
+		matches = append(matches, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM jsonb_each_text(metadata) m WHERE strpos(lower(m.value), $%d) > 0)", argCount))
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+		args = append(args, strings.ToLower(filter.Text))
+		argCount++
+	}
+
+	if c, err := decodeCursor(filter.Cursor); err != nil {
+		conditions = append(conditions, "FALSE")
+	} else if c != nil {
+		conditions = append(conditions, fmt.Sprintf("(timestamp, id) < ($%d, $%d)", argCount, argCount+1))
+		args = append(args, c.timestamp, c.id)
+		argCount += 2
+	}
+
 	return conditions, args, argCount
 }
 
+// textColumns are the columns Filter.Text searches besides metadata values
+var textColumns = []string{"action", "actor_id", "actor_name", "target_id", "target_name", "error", "client_ip"}
+
+// selectColumns lists columns in scan order; correlation_id may be NULL on
+// rows written before the column existed
+const selectColumns = `id, type, action, result, level, timestamp, chain_id, prev_hash,
+	actor_id, actor_type, actor_name, session_id, client_ip, client_info,
+	target_id, target_type, target_name, target_changes,
+	location, trace_id, tags, metadata, error, COALESCE(correlation_id, '')`
+
 // buildQuery builds the complete SQL query with ORDER BY and LIMIT/OFFSET
 func (s *SQLStorage) buildQuery(
 	conditions []string, args []interface{}, argCount int, filter *Filter,
 ) (string, []interface{}) {
-	query := "SELECT * FROM audit_entries"
+	query := "SELECT " + selectColumns + " FROM audit_entries"
 	if len(conditions) > 0 {
 		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
-	query += " ORDER BY timestamp DESC"
+	query += " ORDER BY timestamp DESC, id DESC"
 
 	if filter.Limit > 0 {
 		query += fmt.Sprintf(" LIMIT $%d", argCount)
@@ -258,7 +364,7 @@ func (s *SQLStorage) executeQueryAndScanResults(
 			&entry.ClientIP, &entry.ClientInfo,
 			&entry.TargetID, &entry.TargetType, &entry.TargetName, &targetChanges,
 			&entry.Location, &entry.TraceID, pq.Array(&tags), &metadata,
-			&entry.Error,
+			&entry.Error, &entry.CorrelationID,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan entry: %w", err)
@@ -302,6 +408,35 @@ func (s *SQLStorage) Cleanup(ctx context.Context, before time.Time) error {
 	return nil
 }
 
+// CleanupExcept implements the RetentionCleaner interface. Expired entries
+// are read back so keep can inspect them; only the rest are deleted.
+func (s *SQLStorage) CleanupExcept(ctx context.Context, before time.Time, keep func(*Entry) bool) error {
+	if keep == nil {
+		return s.Cleanup(ctx, before)
+	}
+
+	expired, err := s.executeQueryAndScanResults(ctx,
+		"SELECT "+selectColumns+" FROM audit_entries WHERE timestamp < $1", []interface{}{before})
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	for _, entry := range expired {
+		if !keep(entry) {
+			ids = append(ids, entry.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM audit_entries WHERE id = ANY($1)", pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to cleanup entries: %w", err)
+	}
+	return nil
+}
+
 // Close implements io.Closer
 func (s *SQLStorage) Close() error {
 	return s.db.Close()
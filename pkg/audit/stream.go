@@ -0,0 +1,274 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrSinkClosed indicates a write to a closed StreamingSink
+var ErrSinkClosed = errors.New("audit sink closed")
+
+// Publisher delivers batches of audit entries to an external system such as
+// a SIEM. Publish either delivers the whole batch or returns an error; the
+// sink then retries the batch, so delivery is at least once.
+type Publisher interface {
+	Publish(ctx context.Context, batch []*Entry) error
+	Close() error
+}
+
+// StreamConfig configures a StreamingSink
+type StreamConfig struct {
+	// Publisher receives the batches
+	Publisher Publisher
+
+	// BatchSize is the most entries per Publish call (default: 100)
+	BatchSize int
+
+	// FlushInterval is how long an entry waits for its batch to fill
+	// (default: 1s)
+	FlushInterval time.Duration
+
+	// BufferSize is how many entries may wait for delivery; entries beyond
+	// it go straight to the dead letter (default: 10000)
+	BufferSize int
+
+	// MaxRetries is how often a failed batch is retried; negative disables
+	// retries (default: 5)
+	MaxRetries int
+
+	// BackoffBase is the delay before the first retry, doubled for each
+	// further retry (default: 100ms)
+	BackoffBase time.Duration
+
+	// MaxBackoff caps the retry delay (default: 10s)
+	MaxBackoff time.Duration
+
+	// DeadLetter stores the entries that could not be delivered, for
+	// replay once the publisher is reachable again (optional)
+	DeadLetter Storage
+
+	// OnError receives delivery failures (optional)
+	OnError func(error)
+}
+
+// StreamingSink streams audit entries to a Publisher in batches, retrying
+// with exponential backoff and dead-lettering what cannot be delivered.
+// Store never blocks on the publisher.
+type StreamingSink struct {
+	config  StreamConfig
+	entries chan *Entry
+	done    chan struct{}
+	once    sync.Once
+	mu      sync.RWMutex
+	closed  bool
+	lastErr error
+}
+
+// NewStreamingSink creates a sink and starts delivering in the background
+func NewStreamingSink(config StreamConfig) (*StreamingSink, error) {
+	if config.Publisher == nil {
+		return nil, fmt.Errorf("streaming sink needs a publisher")
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = 10000
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	} else if config.MaxRetries == 0 {
+		config.MaxRetries = 5
+	}
+	if config.BackoffBase <= 0 {
+		config.BackoffBase = 100 * time.Millisecond
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 10 * time.Second
+	}
+
+	s := &StreamingSink{
+		config:  config,
+		entries: make(chan *Entry, config.BufferSize),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Store queues an entry for delivery. When the buffer is full the entry is
+// dead-lettered instead.
+func (s *StreamingSink) Store(ctx context.Context, entry *Entry) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrSinkClosed
+	}
+	select {
+	case s.entries <- entry:
+		return nil
+	default:
+		return s.deadLetter(ctx, []*Entry{entry}, errors.New("audit sink buffer full"))
+	}
+}
+
+// Close delivers the queued entries and closes the publisher
+func (s *StreamingSink) Close() error {
+	s.once.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		close(s.entries)
+		s.mu.Unlock()
+	})
+	<-s.done
+	return s.config.Publisher.Close()
+}
+
+// Health reports whether the sink delivers, for health probes: it fails
+// once the sink is closed, while the buffer is over 90% full, or when the
+// last batch could not be published
+func (s *StreamingSink) Health(_ context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	switch {
+	case s.closed:
+		return ErrSinkClosed
+	case len(s.entries) > cap(s.entries)*9/10:
+		return fmt.Errorf("audit sink buffer %d of %d full", len(s.entries), cap(s.entries))
+	case s.lastErr != nil:
+		return fmt.Errorf("failed to publish audit entries: %w", s.lastErr)
+	}
+	return nil
+}
+
+// run batches queued entries until the sink is closed
+func (s *StreamingSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Entry, 0, s.config.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			s.deliver(batch)
+			batch = make([]*Entry, 0, s.config.BatchSize)
+		}
+	}
+	for {
+		select {
+		case e, ok := <-s.entries:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= s.config.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// deliver publishes a batch, retrying with backoff, and dead-letters it when
+// every attempt fails
+func (s *StreamingSink) deliver(batch []*Entry) {
+	ctx := context.Background()
+	delay := s.config.BackoffBase
+	var err error
+	defer func() {
+		s.mu.Lock()
+		s.lastErr = err
+		s.mu.Unlock()
+	}()
+	for attempt := 0; ; attempt++ {
+		if err = s.config.Publisher.Publish(ctx, batch); err == nil {
+			return
+		}
+		if attempt == s.config.MaxRetries {
+			break
+		}
+		time.Sleep(delay)
+		delay = min(delay*2, s.config.MaxBackoff)
+	}
+	if dlErr := s.deadLetter(ctx, batch, err); dlErr != nil && s.config.OnError != nil {
+		s.config.OnError(dlErr)
+	}
+}
+
+// deadLetter stores undeliverable entries in the dead letter storage
+func (s *StreamingSink) deadLetter(ctx context.Context, entries []*Entry, cause error) error {
+	if s.config.OnError != nil {
+		s.config.OnError(fmt.Errorf("failed to deliver %d audit entries: %w", len(entries), cause))
+	}
+	if s.config.DeadLetter == nil {
+		return fmt.Errorf("audit entries dropped: %w", cause)
+	}
+	for _, e := range entries {
+		if err := s.config.DeadLetter.Store(ctx, e); err != nil {
+			return fmt.Errorf("failed to dead-letter audit entry %s: %w", e.ID, err)
+		}
+	}
+	return nil
+}
+
+// Replay publishes the dead-lettered entries matching filter directly,
+// returning how many were delivered. Replayed entries stay in the dead
+// letter storage until the caller cleans it up.
+func (s *StreamingSink) Replay(ctx context.Context, filter *Filter) (int, error) {
+	if s.config.DeadLetter == nil {
+		return 0, nil
+	}
+	entries, err := s.config.DeadLetter.Search(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < len(entries); i += s.config.BatchSize {
+		batch := entries[i:min(i+s.config.BatchSize, len(entries))]
+		if err := s.config.Publisher.Publish(ctx, batch); err != nil {
+			return i, err
+		}
+	}
+	return len(entries), nil
+}
+
+// StreamingStorage wraps a Storage so every stored entry is also streamed
+// to a sink. The storage stays the system of record: a sink failure is
+// reported through the sink's OnError and never fails Store.
+type StreamingStorage struct {
+	Storage
+	sink *StreamingSink
+}
+
+var _ Storage = (*StreamingStorage)(nil)
+
+// NewStreamingStorage wraps storage with the sink
+func NewStreamingStorage(storage Storage, sink *StreamingSink) *StreamingStorage {
+	return &StreamingStorage{Storage: storage, sink: sink}
+}
+
+// Store implements Storage
+func (s *StreamingStorage) Store(ctx context.Context, entry *Entry) error {
+	if err := s.Storage.Store(ctx, entry); err != nil {
+		return err
+	}
+	_ = s.sink.Store(ctx, entry)
+	return nil
+}
+
+// Close implements Storage, closing the sink first so queued entries are
+// delivered
+func (s *StreamingStorage) Close() error {
+	sinkErr := s.sink.Close()
+	if err := s.Storage.Close(); err != nil {
+		return err
+	}
+	return sinkErr
+}
@@ -0,0 +1,254 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyPublisher fails its first failures calls
+type flakyPublisher struct {
+	mu        sync.Mutex
+	failures  int
+	calls     int
+	published []*Entry
+}
+
+func (p *flakyPublisher) Publish(_ context.Context, batch []*Entry) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("unavailable")
+	}
+	p.published = append(p.published, batch...)
+	return nil
+}
+
+func (p *flakyPublisher) Close() error { return nil }
+
+func TestStreamingSink(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Batches And Retries", func(t *testing.T) {
+		publisher := &flakyPublisher{failures: 2}
+		sink, err := NewStreamingSink(StreamConfig{
+			Publisher:     publisher,
+			BatchSize:     3,
+			FlushInterval: time.Hour,
+			BackoffBase:   time.Millisecond,
+		})
+		require.NoError(t, err)
+		storage, err := NewFileStorage(FileConfig{Directory: t.TempDir()})
+		require.NoError(t, err)
+		streaming := NewStreamingStorage(storage, sink)
+
+		for i := 0; i < 4; i++ {
+			require.NoError(t, streaming.Store(ctx, NewEntry(TypeAuth).WithActor("alice", ActorUser)))
+		}
+		require.NoError(t, streaming.Close())
+
+		assert.Len(t, publisher.published, 4, "the partial batch is flushed on close")
+		assert.Equal(t, 4, publisher.calls, "two failures, the full batch, the partial batch")
+		assert.ErrorIs(t, sink.Store(ctx, NewEntry(TypeAuth)), ErrSinkClosed)
+	})
+
+	t.Run("Dead Letter And Replay", func(t *testing.T) {
+		publisher := &flakyPublisher{failures: 3}
+		deadLetter, err := NewFileStorage(FileConfig{Directory: t.TempDir()})
+		require.NoError(t, err)
+		defer deadLetter.Close()
+
+		var failures []error
+		var mu sync.Mutex
+		sink, err := NewStreamingSink(StreamConfig{
+			Publisher:     publisher,
+			FlushInterval: time.Hour,
+			MaxRetries:    2,
+			BackoffBase:   time.Millisecond,
+			DeadLetter:    deadLetter,
+			OnError: func(err error) {
+				mu.Lock()
+				failures = append(failures, err)
+				mu.Unlock()
+			},
+		})
+		require.NoError(t, err)
+		entry := NewEntry(TypeToken).WithActor("bob", ActorUser)
+		require.NoError(t, sink.Store(ctx, entry))
+		require.NoError(t, sink.Close())
+
+		assert.Empty(t, publisher.published)
+		assert.Len(t, failures, 1)
+		dead, err := deadLetter.Search(ctx, &Filter{})
+		require.NoError(t, err)
+		require.Len(t, dead, 1)
+		assert.Equal(t, entry.ID, dead[0].ID)
+
+		n, err := sink.Replay(ctx, &Filter{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		require.Len(t, publisher.published, 1)
+		assert.Equal(t, entry.ID, publisher.published[0].ID)
+	})
+
+	t.Run("Health", func(t *testing.T) {
+		publisher := &flakyPublisher{failures: 1}
+		sink, err := NewStreamingSink(StreamConfig{
+			Publisher:  publisher,
+			BatchSize:  1,
+			MaxRetries: -1,
+		})
+		require.NoError(t, err)
+		assert.NoError(t, sink.Health(ctx))
+
+		require.NoError(t, sink.Store(ctx, NewEntry(TypeAuth)))
+		assert.Eventually(t, func() bool { return sink.Health(ctx) != nil }, time.Second, time.Millisecond,
+			"a batch that could not be published fails the probe")
+
+		require.NoError(t, sink.Store(ctx, NewEntry(TypeAuth)))
+		assert.Eventually(t, func() bool { return sink.Health(ctx) == nil }, time.Second, time.Millisecond,
+			"the next delivery clears it")
+
+		require.NoError(t, sink.Close())
+		assert.ErrorIs(t, sink.Health(ctx), ErrSinkClosed)
+	})
+}
+
+func TestKafkaPublisher(t *testing.T) {
+	var records []kafkaRecord
+	reject := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/topics/audit", r.URL.Path)
+		assert.Equal(t, "application/vnd.kafka.json.v2+json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Basic abc", r.Header.Get("Authorization"))
+		var body struct {
+			Records []kafkaRecord `json:"records"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		records = append(records, body.Records...)
+		if reject {
+			fmt.Fprint(w, `{"offsets":[{"partition":null,"offset":null,"error_code":50003,"error":"broker down"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"offsets":[{"partition":0,"offset":1}]}`)
+	}))
+	defer server.Close()
+
+	publisher, err := NewKafkaPublisher(KafkaConfig{
+		Endpoint: server.URL,
+		Topic:    "audit",
+		Header:   http.Header{"Authorization": {"Basic abc"}},
+	})
+	require.NoError(t, err)
+	entry := NewEntry(TypeAuth).WithActor("alice", ActorUser).WithAction(ActionLogin)
+	require.NoError(t, publisher.Publish(context.Background(), []*Entry{entry}))
+	require.Len(t, records, 1)
+	assert.Equal(t, "alice", records[0].Key)
+	assert.Equal(t, entry.ID, records[0].Value.ID)
+
+	reject = true
+	assert.ErrorContains(t, publisher.Publish(context.Background(), []*Entry{entry}), "broker down")
+}
+
+// fakeNATS accepts one connection and records the published messages
+func fakeNATS(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	messages := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "INFO {\"server_id\":\"test\",\"max_payload\":1048576}\r\n")
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "CONNECT":
+				if !strings.Contains(line, `"auth_token":"s3cret"`) {
+					fmt.Fprint(conn, "-ERR 'Authorization Violation'\r\n")
+					return
+				}
+			case "PING":
+				fmt.Fprint(conn, "PONG\r\n")
+			case "PUB":
+				n, _ := strconv.Atoi(fields[2])
+				payload := make([]byte, n+2)
+				if _, err := io.ReadFull(r, payload); err != nil {
+					return
+				}
+				messages <- fields[1] + " " + string(payload[:n])
+			}
+		}
+	}()
+	return ln.Addr().String(), messages
+}
+
+func TestNATSPublisher(t *testing.T) {
+	addr, messages := fakeNATS(t)
+	publisher, err := NewNATSPublisher(NATSConfig{Address: addr, Token: "s3cret"})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	entry := NewEntry(TypeToken).WithActor("alice", ActorUser)
+	require.NoError(t, publisher.Publish(context.Background(), []*Entry{entry}))
+	msg := <-messages
+	assert.True(t, strings.HasPrefix(msg, "gauth.audit.token {"), msg)
+	assert.Contains(t, msg, entry.ID)
+
+	addr, _ = fakeNATS(t)
+	denied, err := NewNATSPublisher(NATSConfig{Address: addr})
+	require.NoError(t, err)
+	assert.ErrorContains(t, denied.Publish(context.Background(), []*Entry{entry}), "Authorization Violation")
+}
+
+func TestSyslogPublisher(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	publisher, err := NewSyslogPublisher(SyslogConfig{Address: pc.LocalAddr().String(), Hostname: "gw1"})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	entry := NewEntry(TypeResource).WithActor("bob", ActorUser).WithAction("delete").
+		WithTarget("invoice=7", "invoice").WithResult("denied")
+	entry.Timestamp = time.Date(2025, 9, 30, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, publisher.Publish(context.Background(), []*Entry{entry}))
+
+	buf := make([]byte, 2048)
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	require.NoError(t, err)
+	msg := string(buf[:n])
+
+	// authpriv (10) * 8 + warning (4)
+	assert.True(t, strings.HasPrefix(msg, "<84>1 2025-09-30T10:00:00Z gw1 gauth - resource - CEF:0|Gimel Foundation|GAuth|1.0|resource:delete|delete|6|"), msg)
+	assert.Contains(t, msg, "rt=1759226400000")
+	assert.Contains(t, msg, `duser=invoice\=7`)
+	assert.Contains(t, msg, "outcome=denied")
+	assert.Contains(t, msg, "cs1Label=targetType cs1=invoice")
+}
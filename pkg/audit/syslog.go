@@ -0,0 +1,270 @@
+package audit
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyslogFormat selects the message body of a SyslogPublisher
+type SyslogFormat string
+
+const (
+	// SyslogJSON sends each entry as JSON in an RFC 5424 message
+	SyslogJSON SyslogFormat = "json"
+
+	// SyslogCEF sends each entry as an ArcSight Common Event Format record
+	// in an RFC 5424 message, which most SIEMs parse without configuration
+	SyslogCEF SyslogFormat = "cef"
+)
+
+// Syslog severities used for audit entries
+const (
+	severityCritical      = 2
+	severityError         = 3
+	severityWarning       = 4
+	severityInformational = 6
+)
+
+// SyslogConfig configures a SyslogPublisher
+type SyslogConfig struct {
+	// Network is "udp", "tcp" or "tcp+tls" (default: udp)
+	Network string
+
+	// Address is the host:port of the syslog receiver
+	Address string
+
+	// Format of the message body (default: SyslogCEF)
+	Format SyslogFormat
+
+	// Facility of the messages (default: 10, authpriv)
+	Facility int
+
+	// AppName identifies the sender (default: "gauth")
+	AppName string
+
+	// Hostname identifies the sending host (default: os.Hostname)
+	Hostname string
+
+	// TLS configures tcp+tls connections (optional)
+	TLS *tls.Config
+
+	// Timeout bounds dialing and each publish (default: 5s)
+	Timeout time.Duration
+}
+
+// SyslogPublisher publishes audit entries as RFC 5424 syslog messages,
+// framed by octet counting (RFC 6587) on TCP. A broken connection is
+// redialed on the next Publish.
+type SyslogPublisher struct {
+	config SyslogConfig
+	mu     sync.Mutex
+	conn   net.Conn
+}
+
+var _ Publisher = (*SyslogPublisher)(nil)
+
+// NewSyslogPublisher creates a syslog publisher; it connects on first use
+func NewSyslogPublisher(config SyslogConfig) (*SyslogPublisher, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("syslog publisher needs an address")
+	}
+	switch config.Network {
+	case "":
+		config.Network = "udp"
+	case "udp", "tcp", "tcp+tls":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network %q", config.Network)
+	}
+	if config.Format == "" {
+		config.Format = SyslogCEF
+	}
+	if config.Facility == 0 {
+		config.Facility = 10
+	}
+	if config.AppName == "" {
+		config.AppName = "gauth"
+	}
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	return &SyslogPublisher{config: config}, nil
+}
+
+// Publish implements Publisher
+func (p *SyslogPublisher) Publish(ctx context.Context, batch []*Entry) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil {
+		if err := p.connect(ctx); err != nil {
+			return err
+		}
+	}
+	_ = p.conn.SetWriteDeadline(time.Now().Add(p.config.Timeout))
+	for _, e := range batch {
+		msg, err := p.Format(e)
+		if err != nil {
+			return err
+		}
+		if p.config.Network != "udp" {
+			msg = strconv.Itoa(len(msg)) + " " + msg
+		}
+		if _, err := p.conn.Write([]byte(msg)); err != nil {
+			p.closeConn()
+			return fmt.Errorf("syslog publish: %w", err)
+		}
+	}
+	return nil
+}
+
+func (p *SyslogPublisher) connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: p.config.Timeout}
+	var conn net.Conn
+	var err error
+	if p.config.Network == "tcp+tls" {
+		td := &tls.Dialer{NetDialer: dialer, Config: p.config.TLS}
+		conn, err = td.DialContext(ctx, "tcp", p.config.Address)
+	} else {
+		conn, err = dialer.DialContext(ctx, p.config.Network, p.config.Address)
+	}
+	if err != nil {
+		return fmt.Errorf("syslog connect: %w", err)
+	}
+	p.conn = conn
+	return nil
+}
+
+func (p *SyslogPublisher) closeConn() {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+// Close implements Publisher
+func (p *SyslogPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeConn()
+	return nil
+}
+
+// Format renders an entry as an RFC 5424 message without transport framing
+func (p *SyslogPublisher) Format(e *Entry) (string, error) {
+	var body string
+	switch p.config.Format {
+	case SyslogJSON:
+		data, err := json.Marshal(e)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode entry %s: %w", e.ID, err)
+		}
+		body = string(data)
+	default:
+		body = FormatCEF(e)
+	}
+
+	pri := p.config.Facility*8 + syslogSeverity(e)
+	return fmt.Sprintf("<%d>1 %s %s %s - %s - %s",
+		pri, e.Timestamp.UTC().Format(time.RFC3339Nano), syslogField(p.config.Hostname),
+		syslogField(p.config.AppName), syslogField(e.Type), body), nil
+}
+
+// syslogSeverity maps an entry's level and result to a syslog severity
+func syslogSeverity(e *Entry) int {
+	switch strings.ToLower(e.Level) {
+	case "critical":
+		return severityCritical
+	case "error":
+		return severityError
+	}
+	if e.Result == "" || e.Result == ResultSuccess {
+		return severityInformational
+	}
+	return severityWarning
+}
+
+// syslogField returns a printable header field, or the nil value "-"
+func syslogField(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// FormatCEF renders an entry as a Common Event Format record
+func FormatCEF(e *Entry) string {
+	severity := 3
+	switch syslogSeverity(e) {
+	case severityCritical:
+		severity = 10
+	case severityError:
+		severity = 8
+	case severityWarning:
+		severity = 6
+	}
+	name := e.Action
+	if name == "" {
+		name = e.Type
+	}
+
+	ext := []string{
+		"rt=" + strconv.FormatInt(e.Timestamp.UnixMilli(), 10),
+		"externalId=" + cefValue(e.ID),
+	}
+	add := func(key, value string) {
+		if value != "" {
+			ext = append(ext, key+"="+cefValue(value))
+		}
+	}
+	add("suser", e.ActorID)
+	add("duser", e.TargetID)
+	add("act", e.Action)
+	add("outcome", e.Result)
+	add("src", e.ClientIP)
+	add("msg", e.Error)
+	if e.TargetType != "" {
+		add("cs1Label", "targetType")
+		add("cs1", e.TargetType)
+	}
+	if e.CorrelationID != "" {
+		add("cs2Label", "correlationId")
+		add("cs2", e.CorrelationID)
+	}
+	if e.ChainID != "" {
+		add("cs3Label", "chainId")
+		add("cs3", e.ChainID)
+	}
+
+	return strings.Join([]string{
+		"CEF:0", "Gimel Foundation", "GAuth", "1.0",
+		cefHeader(e.Type + ":" + e.Action), cefHeader(name), strconv.Itoa(severity),
+		strings.Join(ext, " "),
+	}, "|")
+}
+
+// cefHeader escapes a CEF header field
+func cefHeader(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ").Replace(s)
+}
+
+// cefValue escapes a CEF extension value
+func cefValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`).Replace(s)
+}
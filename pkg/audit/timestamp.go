@@ -0,0 +1,379 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+)
+
+// TimestampToken is an RFC 3161 timestamp over a checkpoint digest
+type TimestampToken struct {
+	// Raw is the DER-encoded TimeStampToken (CMS ContentInfo) as returned by the TSA
+	Raw []byte `json:"raw"`
+
+	// GenTime is the time asserted by the TSA
+	GenTime time.Time `json:"gen_time"`
+
+	// SerialNumber is the TSA-assigned serial number
+	SerialNumber string `json:"serial_number"`
+
+	// Policy is the TSA policy OID under which the token was issued
+	Policy string `json:"policy,omitempty"`
+
+	// Digest is the SHA-256 message imprint covered by the token
+	Digest []byte `json:"digest"`
+}
+
+// TSAClient obtains and verifies trusted timestamps for digests
+type TSAClient interface {
+	// Timestamp requests a timestamp token for a SHA-256 digest
+	Timestamp(ctx context.Context, digest []byte) (*TimestampToken, error)
+
+	// Verify checks that the token covers the given digest
+	Verify(ctx context.Context, token *TimestampToken, digest []byte) error
+}
+
+// Timestamp errors
+var (
+	// ErrTimestampRejected indicates the TSA refused the request
+	ErrTimestampRejected = errors.New("timestamp request rejected")
+
+	// ErrTimestampMismatch indicates the token does not cover the expected digest
+	ErrTimestampMismatch = errors.New("timestamp does not match digest")
+
+	// ErrTimestampSignature indicates the token is not signed by a trusted TSA
+	ErrTimestampSignature = errors.New("timestamp signature invalid")
+)
+
+// HTTPTSAConfig configures an RFC 3161 TSA client
+type HTTPTSAConfig struct {
+	// URL of the time stamping authority
+	URL string
+
+	// Client is the HTTP client to use (default: client with 10s timeout)
+	Client *http.Client
+
+	// Policy optionally requests a specific TSA policy OID (e.g. "1.2.3.4")
+	Policy string
+
+	// Roots are the TSA root certificates. Tokens must carry a CMS
+	// signature by a certificate for time stamping that chains to one of
+	// them. Required unless VerifySignature is set.
+	Roots *x509.CertPool
+
+	// VerifySignature validates the CMS signature of a raw token in place
+	// of Roots, such as against a TSA key held elsewhere. With Roots set
+	// both must pass.
+	VerifySignature func(raw []byte) error
+}
+
+// HTTPTSAClient implements TSAClient over the RFC 3161 HTTP transport
+type HTTPTSAClient struct {
+	config HTTPTSAConfig
+}
+
+// NewHTTPTSAClient creates an RFC 3161 client
+func NewHTTPTSAClient(config HTTPTSAConfig) (*HTTPTSAClient, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("TSA URL is required")
+	}
+	if config.Roots == nil && config.VerifySignature == nil {
+		return nil, fmt.Errorf("TSA roots are required to verify timestamp signatures")
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &HTTPTSAClient{config: config}, nil
+}
+
+var (
+	oidSHA256     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+)
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type messageImprint struct {
+	HashAlgorithm algorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional,default:false"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional,utf8"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type encapContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo encapContentInfo
+}
+
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	Accuracy       accuracy  `asn1:"optional"`
+	Ordering       bool      `asn1:"optional,default:false"`
+	Nonce          *big.Int  `asn1:"optional"`
+}
+
+// parsedTimestamp is a decoded TimeStampToken with the fields checked
+// against the request
+type parsedTimestamp struct {
+	token   *TimestampToken
+	nonce   *big.Int
+	content []byte
+}
+
+// Timestamp implements TSAClient
+func (c *HTTPTSAClient) Timestamp(ctx context.Context, digest []byte) (*TimestampToken, error) {
+	if len(digest) != sha256.Size {
+		return nil, fmt.Errorf("digest must be %d bytes", sha256.Size)
+	}
+
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	req := timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: algorithmIdentifier{Algorithm: oidSHA256},
+			HashedMessage: digest,
+		},
+		Nonce:   nonce,
+		CertReq: true,
+	}
+	if c.config.Policy != "" {
+		policy, err := parseOID(c.config.Policy)
+		if err != nil {
+			return nil, err
+		}
+		req.ReqPolicy = policy
+	}
+
+	body, err := asn1.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode timestamp request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/timestamp-query")
+
+	resp, err := c.config.Client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("timestamp request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: HTTP %d", ErrTimestampRejected, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read timestamp response: %w", err)
+	}
+
+	raw, err := timestampFromResponse(data)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := c.verify(raw, digest)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.nonce == nil || parsed.nonce.Cmp(nonce) != 0 {
+		return nil, fmt.Errorf("%w: nonce does not match the request", ErrTimestampMismatch)
+	}
+	return parsed.token, nil
+}
+
+// Verify implements TSAClient
+func (c *HTTPTSAClient) Verify(_ context.Context, token *TimestampToken, digest []byte) error {
+	if token == nil {
+		return ErrTimestampMismatch
+	}
+	_, err := c.verify(token.Raw, digest)
+	return err
+}
+
+// verify checks that a raw token covers the digest and is signed by a
+// trusted TSA
+func (c *HTTPTSAClient) verify(raw, digest []byte) (*parsedTimestamp, error) {
+	parsed, err := parseTimestampToken(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(parsed.token.Digest, digest) {
+		return nil, ErrTimestampMismatch
+	}
+	if c.config.Roots != nil {
+		if err := verifyTimestampSignature(parsed, c.config.Roots); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrTimestampSignature, err)
+		}
+	}
+	if c.config.VerifySignature != nil {
+		if err := c.config.VerifySignature(raw); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrTimestampSignature, err)
+		}
+	}
+	return parsed, nil
+}
+
+// verifyTimestampSignature checks the CMS signature over the TSTInfo and
+// that the signer is certified for time stamping by one of the roots at
+// the asserted time
+func verifyTimestampSignature(parsed *parsedTimestamp, roots *x509.CertPool) error {
+	p7, err := pkcs7.Parse(parsed.token.Raw)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(p7.Content, parsed.content) {
+		return errors.New("signed content is not the TSTInfo")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range p7.Certificates {
+		intermediates.AddCert(cert)
+	}
+	return p7.VerifyWithOpts(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   parsed.token.GenTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	})
+}
+
+// ParseTimestampResponse decodes a DER TimeStampResp and checks that it
+// covers the expected digest. It does not check the signature; use
+// HTTPTSAClient.Verify on the result.
+func ParseTimestampResponse(data, digest []byte) (*TimestampToken, error) {
+	raw, err := timestampFromResponse(data)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := parseTimestampToken(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(parsed.token.Digest, digest) {
+		return nil, ErrTimestampMismatch
+	}
+	return parsed.token, nil
+}
+
+// timestampFromResponse extracts the TimeStampToken of a granted response
+func timestampFromResponse(data []byte) ([]byte, error) {
+	var resp timeStampResp
+	if _, err := asn1.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode timestamp response: %w", err)
+	}
+	// 0 = granted, 1 = grantedWithMods
+	if resp.Status.Status > 1 {
+		return nil, fmt.Errorf("%w: status %d", ErrTimestampRejected, resp.Status.Status)
+	}
+	if len(resp.TimeStampToken.FullBytes) == 0 {
+		return nil, fmt.Errorf("%w: no token in response", ErrTimestampRejected)
+	}
+	return resp.TimeStampToken.FullBytes, nil
+}
+
+func parseTimestampToken(raw []byte) (*parsedTimestamp, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(raw, &ci); err != nil {
+		return nil, fmt.Errorf("failed to decode timestamp token: %w", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("unexpected timestamp content type %s", ci.ContentType)
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("failed to decode signed data: %w", err)
+	}
+
+	var info tstInfo
+	if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent, &info); err != nil {
+		return nil, fmt.Errorf("failed to decode TSTInfo: %w", err)
+	}
+	if alg := info.MessageImprint.HashAlgorithm.Algorithm; !alg.Equal(oidSHA256) {
+		return nil, fmt.Errorf("%w: message imprint uses hash algorithm %s, not SHA-256", ErrTimestampMismatch, alg)
+	}
+
+	serial := ""
+	if info.SerialNumber != nil {
+		serial = info.SerialNumber.String()
+	}
+
+	token := &TimestampToken{
+		Raw:          raw,
+		GenTime:      info.GenTime,
+		SerialNumber: serial,
+		Policy:       info.Policy.String(),
+		Digest:       info.MessageImprint.HashedMessage,
+	}
+	return &parsedTimestamp{token: token, nonce: info.Nonce, content: sd.EncapContentInfo.EContent}, nil
+}
+
+func parseOID(s string) (asn1.ObjectIdentifier, error) {
+	var oid asn1.ObjectIdentifier
+	for _, part := range bytes.Split([]byte(s), []byte(".")) {
+		var n int
+		if _, err := fmt.Sscanf(string(part), "%d", &n); err != nil {
+			return nil, fmt.Errorf("invalid OID %q", s)
+		}
+		oid = append(oid, n)
+	}
+	return oid, nil
+}
@@ -1,21 +1,59 @@
 package audit
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
-// Entry represents a single audit log entry.
+// Storage is implemented by audit entry backends (file, SQL, Redis)
+type Storage interface {
+	// Store persists an entry
+	Store(ctx context.Context, entry *Entry) error
+
+	// Search returns entries matching the filter
+	Search(ctx context.Context, filter *Filter) ([]*Entry, error)
+
+	// GetByID returns a single entry
+	GetByID(ctx context.Context, id string) (*Entry, error)
+
+	// GetChain returns all entries in a chain
+	GetChain(ctx context.Context, chainID string) ([]*Entry, error)
+
+	// Cleanup removes entries older than the given time
+	Cleanup(ctx context.Context, before time.Time) error
+
+	// Close releases resources
+	Close() error
+}
+
+var (
+	_ Storage = (*FileStorage)(nil)
+	_ Storage = (*SQLStorage)(nil)
+	_ Storage = (*RedisStorage)(nil)
+)
 
 // Filter represents filtering options for searching audit entries.
 type Filter struct {
-	ActorIDs  []string
-	Types     []string
-	Actions   []string
-	Results   []string
-	ChainID   string
+	ActorIDs []string
+	Types    []string
+	Actions  []string
+	Results  []string
+	ChainID  string
+	// CorrelationID selects every entry of one end-to-end flow
+	CorrelationID string
+	// TargetIDs and TargetTypes select entries by the resource acted on
+	TargetIDs   []string
+	TargetTypes []string
+	// Text selects entries with the text, ignoring case, in the action,
+	// actor, target, error, client IP or a metadata value
+	Text      string
 	Tags      []string
 	Metadata  []MetadataFilter
 	TimeRange *TimeRange
 	Limit     int
 	Offset    int
+	// Cursor selects the entries after a page returned by Query
+	Cursor string
 }
 
 type MetadataFilter struct {
@@ -0,0 +1,576 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+
+	autherrors "github.com/Gimel-Foundation/gauth/pkg/errors"
+	"github.com/Gimel-Foundation/gauth/pkg/scope"
+)
+
+// API key errors
+var (
+	// ErrAPIKeyNotFound indicates the key does not exist
+	ErrAPIKeyNotFound = errors.New("api key not found")
+
+	// ErrInvalidAPIKey indicates a key that cannot be used: malformed,
+	// unknown, a wrong secret, revoked or expired
+	ErrInvalidAPIKey = errors.New("invalid api key")
+
+	// ErrAPIKeyRevoked indicates the key has been revoked. Authenticate
+	// wraps it in ErrInvalidAPIKey, and only once the secret is verified.
+	ErrAPIKeyRevoked = errors.New("api key revoked")
+
+	// ErrAPIKeyExpired indicates the key has passed its expiry. Authenticate
+	// wraps it in ErrInvalidAPIKey, and only once the secret is verified.
+	ErrAPIKeyExpired = errors.New("api key expired")
+
+	// ErrAPIKeyRateLimited indicates the key has used up its rate limit
+	ErrAPIKeyRateLimited = errors.New("api key rate limit exceeded")
+)
+
+// apiKeyPrefixPattern restricts key prefixes to characters that cannot be
+// confused with the key's separators
+var apiKeyPrefixPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// APIKeyRateLimit caps the requests a key may make per window
+type APIKeyRateLimit struct {
+	Requests int64         `json:"requests"`
+	Window   time.Duration `json:"window"`
+}
+
+// APIKeySecret is one secret of a key. Only its Argon2id hash is kept.
+type APIKeySecret struct {
+	Hash      string     `json:"hash"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// APIKey is a long-lived credential for integrations that cannot run an
+// OAuth flow. The presented key is "<prefix>_<id>.<secret>"; the ID locates
+// the record and the secret is checked against its hashes.
+type APIKey struct {
+	ID        string           `json:"id"`
+	Prefix    string           `json:"prefix"`
+	Name      string           `json:"name"`
+	Owner     string           `json:"owner"`
+	Scopes    []string         `json:"scopes"`
+	RateLimit *APIKeyRateLimit `json:"rate_limit,omitempty"`
+	Secrets   []APIKeySecret   `json:"secrets"`
+	CreatedAt time.Time        `json:"created_at"`
+	RotatedAt time.Time        `json:"rotated_at"`
+	ExpiresAt *time.Time       `json:"expires_at,omitempty"`
+	RevokedAt *time.Time       `json:"revoked_at,omitempty"`
+}
+
+// HasScopes reports whether the key grants every scope, directly or through
+// a wildcard
+func (k *APIKey) HasScopes(scopes ...string) bool {
+	return scope.Subset(k.Scopes, scopes)
+}
+
+// APIKeyStore persists API keys
+type APIKeyStore interface {
+	Save(ctx context.Context, key *APIKey) error
+	Get(ctx context.Context, id string) (*APIKey, error)
+	List(ctx context.Context, owner string) ([]*APIKey, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// Argon2Params are the Argon2id cost parameters for hashing key secrets
+type Argon2Params struct {
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+}
+
+// DefaultArgon2Params follow the OWASP baseline for Argon2id
+var DefaultArgon2Params = Argon2Params{Time: 2, Memory: 19 * 1024, Threads: 1}
+
+// APIKeyConfig configures API key management
+type APIKeyConfig struct {
+	// Store persists the keys (default: in memory)
+	Store APIKeyStore
+
+	// DefaultPrefix is used for keys created without one (default: "gk")
+	DefaultPrefix string
+
+	// RotationOverlap keeps the previous secret valid after rotation so
+	// integrations can switch over (default: 24h)
+	RotationOverlap time.Duration
+
+	// Argon2 sets the hashing cost (default: DefaultArgon2Params)
+	Argon2 *Argon2Params
+
+	// Now returns the current time; defaults to time.Now
+	Now func() time.Time
+}
+
+// APIKeyRequest describes a key to create
+type APIKeyRequest struct {
+	Name      string
+	Owner     string
+	Prefix    string
+	Scopes    []string
+	RateLimit *APIKeyRateLimit
+
+	// TTL bounds the key's life; zero keeps it until revoked
+	TTL time.Duration
+}
+
+// APIKeyManager creates, rotates, revokes and authenticates API keys
+type APIKeyManager struct {
+	config APIKeyConfig
+	argon  Argon2Params
+
+	// writeMu serializes the read-modify-write of Rotate and Revoke
+	writeMu sync.Mutex
+
+	mu        sync.Mutex
+	windows   map[string]*apiKeyWindow
+	nextSweep time.Time
+
+	decoyOnce sync.Once
+	decoy     string
+}
+
+// apiKeyWindow counts a key's requests in the current fixed window
+type apiKeyWindow struct {
+	reset time.Time
+	count int64
+}
+
+// apiKeyWindowSweep is how often windows that have ended are dropped
+const apiKeyWindowSweep = time.Minute
+
+// NewAPIKeyManager creates an API key manager
+func NewAPIKeyManager(config APIKeyConfig) (*APIKeyManager, error) {
+	if config.Store == nil {
+		config.Store = NewMemoryAPIKeyStore()
+	}
+	if config.DefaultPrefix == "" {
+		config.DefaultPrefix = "gk"
+	}
+	if !apiKeyPrefixPattern.MatchString(config.DefaultPrefix) {
+		return nil, fmt.Errorf("invalid api key prefix %q", config.DefaultPrefix)
+	}
+	if config.RotationOverlap <= 0 {
+		config.RotationOverlap = 24 * time.Hour
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	argon := DefaultArgon2Params
+	if config.Argon2 != nil {
+		argon = *config.Argon2
+	}
+	return &APIKeyManager{config: config, argon: argon, windows: make(map[string]*apiKeyWindow)}, nil
+}
+
+// Create registers a key and returns it with its plaintext value. The value
+// is returned only once.
+func (m *APIKeyManager) Create(ctx context.Context, req APIKeyRequest) (*APIKey, string, error) {
+	if req.Name == "" || req.Owner == "" {
+		return nil, "", errors.New("name and owner are required")
+	}
+	prefix := req.Prefix
+	if prefix == "" {
+		prefix = m.config.DefaultPrefix
+	}
+	if !apiKeyPrefixPattern.MatchString(prefix) {
+		return nil, "", fmt.Errorf("invalid api key prefix %q", prefix)
+	}
+	if req.RateLimit != nil && (req.RateLimit.Requests <= 0 || req.RateLimit.Window <= 0) {
+		return nil, "", errors.New("rate limit requires positive requests and window")
+	}
+	id, err := randomHex(12)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, hashed, err := m.newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := m.config.Now()
+	key := &APIKey{
+		ID:        id,
+		Prefix:    prefix,
+		Name:      req.Name,
+		Owner:     req.Owner,
+		Scopes:    append([]string(nil), req.Scopes...),
+		RateLimit: req.RateLimit,
+		Secrets:   []APIKeySecret{{Hash: hashed, CreatedAt: now}},
+		CreatedAt: now,
+		RotatedAt: now,
+	}
+	if req.TTL > 0 {
+		expires := now.Add(req.TTL)
+		key.ExpiresAt = &expires
+	}
+	if err := m.config.Store.Save(ctx, key); err != nil {
+		return nil, "", fmt.Errorf("failed to save api key: %w", err)
+	}
+	return key, formatAPIKey(key, secret), nil
+}
+
+// Rotate adds a new secret to the key and expires the previous ones after
+// RotationOverlap. The new plaintext value is returned only once.
+func (m *APIKeyManager) Rotate(ctx context.Context, id string) (string, error) {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	key, err := m.config.Store.Get(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	now := m.config.Now()
+	if err := checkAPIKeyState(key, now); err != nil {
+		return "", err
+	}
+	secret, hashed, err := m.newSecret()
+	if err != nil {
+		return "", err
+	}
+
+	expires := now.Add(m.config.RotationOverlap)
+	kept := key.Secrets[:0]
+	for _, s := range key.Secrets {
+		if s.ExpiresAt != nil && !now.Before(*s.ExpiresAt) {
+			continue
+		}
+		if s.ExpiresAt == nil || s.ExpiresAt.After(expires) {
+			s.ExpiresAt = &expires
+		}
+		kept = append(kept, s)
+	}
+	key.Secrets = append(kept, APIKeySecret{Hash: hashed, CreatedAt: now})
+	key.RotatedAt = now
+	if err := m.config.Store.Save(ctx, key); err != nil {
+		return "", fmt.Errorf("failed to save api key: %w", err)
+	}
+	return formatAPIKey(key, secret), nil
+}
+
+// Revoke permanently disables a key
+func (m *APIKeyManager) Revoke(ctx context.Context, id string) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	key, err := m.config.Store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+	now := m.config.Now()
+	key.RevokedAt = &now
+	if err := m.config.Store.Save(ctx, key); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	delete(m.windows, id)
+	m.mu.Unlock()
+	return nil
+}
+
+// List returns the keys of an owner
+func (m *APIKeyManager) List(ctx context.Context, owner string) ([]*APIKey, error) {
+	return m.config.Store.List(ctx, owner)
+}
+
+// Authenticate resolves a presented key to its record. Every failure is
+// ErrInvalidAPIKey, so callers cannot probe for key IDs or key states: the
+// secret is verified first, and unknown keys cost a hash all the same. Only
+// a caller holding a live secret learns, through a wrapped ErrAPIKeyRevoked
+// or ErrAPIKeyExpired, why the key is refused.
+func (m *APIKeyManager) Authenticate(ctx context.Context, presented string) (*APIKey, error) {
+	prefix, id, secret, ok := parseAPIKey(presented)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	key, err := m.config.Store.Get(ctx, id)
+	if err != nil && !errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, err
+	}
+	if err != nil || key.Prefix != prefix {
+		verifyArgon2id(m.decoyHash(), secret)
+		return nil, ErrInvalidAPIKey
+	}
+	now := m.config.Now()
+	verified := false
+	for _, s := range key.Secrets {
+		if s.ExpiresAt != nil && !now.Before(*s.ExpiresAt) {
+			continue
+		}
+		if verifyArgon2id(s.Hash, secret) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrInvalidAPIKey
+	}
+	if err := checkAPIKeyState(key, now); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidAPIKey, err)
+	}
+	return key, nil
+}
+
+// decoyHash is hashed against when no key matches, so unknown IDs take as
+// long to refuse as wrong secrets
+func (m *APIKeyManager) decoyHash() string {
+	m.decoyOnce.Do(func() {
+		m.decoy, _ = hashArgon2id("decoy", m.argon)
+	})
+	return m.decoy
+}
+
+// Allow counts a request against the key's rate limit and reports
+// ErrAPIKeyRateLimited, with the time the window resets, once it is used up
+func (m *APIKeyManager) Allow(key *APIKey) (time.Time, error) {
+	if key.RateLimit == nil {
+		return time.Time{}, nil
+	}
+	now := m.config.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweepWindows(now)
+	w, ok := m.windows[key.ID]
+	if !ok || !now.Before(w.reset) {
+		w = &apiKeyWindow{reset: now.Add(key.RateLimit.Window)}
+		m.windows[key.ID] = w
+	}
+	if w.count >= key.RateLimit.Requests {
+		return w.reset, ErrAPIKeyRateLimited
+	}
+	w.count++
+	return w.reset, nil
+}
+
+// sweepWindows drops the windows that have ended, at most once per
+// apiKeyWindowSweep; m.mu must be held
+func (m *APIKeyManager) sweepWindows(now time.Time) {
+	if now.Before(m.nextSweep) {
+		return
+	}
+	m.nextSweep = now.Add(apiKeyWindowSweep)
+	for id, w := range m.windows {
+		if !now.Before(w.reset) {
+			delete(m.windows, id)
+		}
+	}
+}
+
+// Middleware returns HTTP middleware that authenticates the API key in the
+// X-API-Key header or an "Authorization: ApiKey" header, enforces the
+// key's rate limit and the given scopes, and injects the key into the
+// request context
+func (m *APIKeyManager) Middleware(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, err := m.Authenticate(r.Context(), APIKeyFromRequest(r))
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `ApiKey realm="gauth"`)
+				autherrors.New(autherrors.ErrInvalidClient, "invalid api key").WithCause(err).WriteHTTP(w)
+				return
+			}
+			if reset, err := m.Allow(key); err != nil {
+				autherrors.New(autherrors.ErrRateLimited, "api key rate limit exceeded").
+					WithRetryAfter(reset.Sub(m.config.Now())).WriteHTTP(w)
+				return
+			}
+			if !key.HasScopes(scopes...) {
+				autherrors.New(autherrors.ErrInsufficientScope, "the api key lacks a required scope").WriteHTTP(w)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ContextWithAPIKey(r.Context(), key)))
+		})
+	}
+}
+
+// APIKeyFromRequest reads a presented key from the X-API-Key header or an
+// "Authorization: ApiKey <key>" header
+func APIKeyFromRequest(r *http.Request) string {
+	if v := r.Header.Get("X-API-Key"); v != "" {
+		return strings.TrimSpace(v)
+	}
+	scheme, value, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "ApiKey") {
+		return strings.TrimSpace(value)
+	}
+	return ""
+}
+
+type apiKeyContextKey struct{}
+
+// ContextWithAPIKey returns ctx carrying the authenticated API key
+func ContextWithAPIKey(ctx context.Context, key *APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, key)
+}
+
+// APIKeyFromContext returns the API key injected by the middleware, if any
+func APIKeyFromContext(ctx context.Context) (*APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey{}).(*APIKey)
+	return key, ok && key != nil
+}
+
+func checkAPIKeyState(key *APIKey, now time.Time) error {
+	if key.RevokedAt != nil {
+		return ErrAPIKeyRevoked
+	}
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return ErrAPIKeyExpired
+	}
+	return nil
+}
+
+func formatAPIKey(key *APIKey, secret string) string {
+	return key.Prefix + "_" + key.ID + "." + secret
+}
+
+// parseAPIKey splits "<prefix>_<id>.<secret>"; the secret is base64url and
+// the ID hex, so neither contains the separators
+func parseAPIKey(s string) (prefix, id, secret string, ok bool) {
+	head, secret, ok := strings.Cut(s, ".")
+	if !ok || secret == "" {
+		return "", "", "", false
+	}
+	i := strings.LastIndexByte(head, '_')
+	if i <= 0 || i == len(head)-1 {
+		return "", "", "", false
+	}
+	return head[:i], head[i+1:], secret, true
+}
+
+func (m *APIKeyManager) newSecret() (secret, hashed string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	secret = base64.RawURLEncoding.EncodeToString(buf)
+	hashed, err = hashArgon2id(secret, m.argon)
+	return secret, hashed, err
+}
+
+// hashArgon2id returns the secret's hash in the PHC string format
+func hashArgon2id(secret string, p Argon2Params) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	sum := argon2.IDKey([]byte(secret), salt, p.Time, p.Memory, p.Threads, 32)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(sum)), nil
+}
+
+// verifyArgon2id checks a secret against a PHC-format Argon2id hash, using
+// the parameters recorded in the hash
+func verifyArgon2id(encoded, secret string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	var p Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false
+	}
+	got := argon2.IDKey([]byte(secret), salt, p.Time, p.Memory, p.Threads, uint32(len(want)))
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// MemoryAPIKeyStore keeps API keys in memory
+type MemoryAPIKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]*APIKey
+}
+
+// NewMemoryAPIKeyStore creates an empty in-memory key store
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{keys: make(map[string]*APIKey)}
+}
+
+// Save implements APIKeyStore
+func (s *MemoryAPIKeyStore) Save(_ context.Context, key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.ID] = cloneAPIKey(key)
+	return nil
+}
+
+// Get implements APIKeyStore
+func (s *MemoryAPIKeyStore) Get(_ context.Context, id string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	return cloneAPIKey(key), nil
+}
+
+// List implements APIKeyStore
+func (s *MemoryAPIKeyStore) List(_ context.Context, owner string) ([]*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []*APIKey
+	for _, key := range s.keys {
+		if key.Owner == owner {
+			out = append(out, cloneAPIKey(key))
+		}
+	}
+	return out, nil
+}
+
+// Delete implements APIKeyStore
+func (s *MemoryAPIKeyStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[id]; !ok {
+		return ErrAPIKeyNotFound
+	}
+	delete(s.keys, id)
+	return nil
+}
+
+func cloneAPIKey(key *APIKey) *APIKey {
+	out := *key
+	out.Scopes = append([]string(nil), key.Scopes...)
+	out.Secrets = append([]APIKeySecret(nil), key.Secrets...)
+	if key.RateLimit != nil {
+		limit := *key.RateLimit
+		out.RateLimit = &limit
+	}
+	return &out
+}
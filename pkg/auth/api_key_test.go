@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	manager, err := NewAPIKeyManager(APIKeyConfig{
+		RotationOverlap: time.Hour,
+		Argon2:          &Argon2Params{Time: 1, Memory: 64, Threads: 1},
+		Now:             func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatalf("NewAPIKeyManager() error: %v", err)
+	}
+
+	key, value, err := manager.Create(ctx, APIKeyRequest{Name: "billing sync", Owner: "acme", Prefix: "gk_live", Scopes: []string{"invoices:read"}})
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if !strings.HasPrefix(value, "gk_live_"+key.ID+".") {
+		t.Fatalf("key value %q lacks prefix and ID", value)
+	}
+	if !strings.HasPrefix(key.Secrets[0].Hash, "$argon2id$") || strings.Contains(key.Secrets[0].Hash, value) {
+		t.Fatalf("secret not stored as an Argon2id hash: %q", key.Secrets[0].Hash)
+	}
+	if got, err := manager.Authenticate(ctx, value); err != nil || got.ID != key.ID {
+		t.Fatalf("Authenticate() = %v, %v", got, err)
+	}
+	for _, bad := range []string{"", "garbage", "gk_test_" + key.ID + "." + strings.SplitN(value, ".", 2)[1], value + "x"} {
+		if _, err := manager.Authenticate(ctx, bad); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("Authenticate(%q) error = %v, want ErrInvalidAPIKey", bad, err)
+		}
+	}
+
+	rotated, err := manager.Rotate(ctx, key.ID)
+	if err != nil {
+		t.Fatalf("Rotate() error: %v", err)
+	}
+	for _, v := range []string{value, rotated} {
+		if _, err := manager.Authenticate(ctx, v); err != nil {
+			t.Errorf("Authenticate() during overlap error: %v", err)
+		}
+	}
+	now = now.Add(time.Hour)
+	if _, err := manager.Authenticate(ctx, value); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Authenticate(old key) after overlap error = %v, want ErrInvalidAPIKey", err)
+	}
+	if _, err := manager.Authenticate(ctx, rotated); err != nil {
+		t.Errorf("Authenticate(rotated key) error: %v", err)
+	}
+
+	if err := manager.Revoke(ctx, key.ID); err != nil {
+		t.Fatalf("Revoke() error: %v", err)
+	}
+	if _, err := manager.Authenticate(ctx, rotated); !errors.Is(err, ErrInvalidAPIKey) || !errors.Is(err, ErrAPIKeyRevoked) {
+		t.Errorf("Authenticate(revoked key) error = %v, want ErrInvalidAPIKey wrapping ErrAPIKeyRevoked", err)
+	}
+	// Without the secret, a revoked key is indistinguishable from a wrong one
+	wrong := strings.SplitN(rotated, ".", 2)[0] + ".wrong-secret"
+	if _, err := manager.Authenticate(ctx, wrong); !errors.Is(err, ErrInvalidAPIKey) || errors.Is(err, ErrAPIKeyRevoked) {
+		t.Errorf("Authenticate(revoked key, wrong secret) error = %v, want only ErrInvalidAPIKey", err)
+	}
+}
+
+func TestAPIKeyRotateConcurrently(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewAPIKeyManager(APIKeyConfig{Argon2: &Argon2Params{Time: 1, Memory: 64, Threads: 1}})
+	if err != nil {
+		t.Fatalf("NewAPIKeyManager() error: %v", err)
+	}
+	key, _, err := manager.Create(ctx, APIKeyRequest{Name: "ci", Owner: "acme"})
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	const n = 8
+	values := make(chan string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := manager.Rotate(ctx, key.ID)
+			if err != nil {
+				t.Errorf("Rotate() error: %v", err)
+				return
+			}
+			values <- v
+		}()
+	}
+	wg.Wait()
+	close(values)
+	// Every rotation is kept; none overwrote another's secret
+	for v := range values {
+		if _, err := manager.Authenticate(ctx, v); err != nil {
+			t.Errorf("Authenticate(rotated value) error: %v", err)
+		}
+	}
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	manager, err := NewAPIKeyManager(APIKeyConfig{
+		Argon2: &Argon2Params{Time: 1, Memory: 64, Threads: 1},
+		Now:    func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatalf("NewAPIKeyManager() error: %v", err)
+	}
+	key, value, err := manager.Create(ctx, APIKeyRequest{
+		Name:      "reporting",
+		Owner:     "acme",
+		Scopes:    []string{"reports:read"},
+		RateLimit: &APIKeyRateLimit{Requests: 2, Window: time.Minute},
+	})
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	var owner string
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, found := APIKeyFromContext(r.Context())
+		if !found {
+			t.Fatal("APIKeyFromContext() found no key")
+		}
+		owner = key.Owner
+	})
+	serve := func(h http.Handler, header, v string) int {
+		req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+		if header != "" {
+			req.Header.Set(header, v)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	reports := manager.Middleware("reports:read")(ok)
+	if code := serve(reports, "", ""); code != http.StatusUnauthorized {
+		t.Errorf("no key: status = %d, want 401", code)
+	}
+	if code := serve(reports, "X-API-Key", value); code != http.StatusOK || owner != "acme" {
+		t.Errorf("X-API-Key: status = %d, owner = %q", code, owner)
+	}
+	if code := serve(manager.Middleware("reports:write")(ok), "Authorization", "ApiKey "+value); code != http.StatusForbidden {
+		t.Errorf("missing scope: status = %d, want 403", code)
+	}
+	if code := serve(reports, "X-API-Key", value); code != http.StatusTooManyRequests {
+		t.Errorf("over limit: status = %d, want 429", code)
+	}
+	now = now.Add(time.Minute)
+	if code := serve(reports, "X-API-Key", value); code != http.StatusOK {
+		t.Errorf("next window: status = %d, want 200", code)
+	}
+
+	// Windows that have ended are dropped rather than kept per key forever
+	now = now.Add(2 * time.Minute)
+	_, _ = manager.Allow(&APIKey{ID: "other", RateLimit: &APIKeyRateLimit{Requests: 1, Window: time.Minute}})
+	manager.mu.Lock()
+	_, stale := manager.windows[key.ID]
+	windows := len(manager.windows)
+	manager.mu.Unlock()
+	if stale || windows != 1 {
+		t.Errorf("Expected only the current window kept, got %d (stale %v)", windows, stale)
+	}
+}
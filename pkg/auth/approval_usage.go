@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ApprovalUsageStore counts the uses of offline approvals until they expire
+type ApprovalUsageStore interface {
+	// Use counts one use of approval id, failing with ErrApprovalExhausted
+	// once max uses were counted, and returns the count including this use
+	Use(ctx context.Context, id string, max int, expiresAt time.Time) (int, error)
+
+	// Used returns the uses counted for id, or -1 when none were counted or
+	// the approval has expired
+	Used(ctx context.Context, id string) (int, error)
+}
+
+type approvalUse struct {
+	used      int
+	expiresAt time.Time
+}
+
+// MemoryApprovalUsage counts approval uses in memory, for a single instance
+type MemoryApprovalUsage struct {
+	now  func() time.Time
+	mu   sync.Mutex
+	uses map[string]*approvalUse
+}
+
+// NewMemoryApprovalUsage creates an in-memory usage store. now defaults to
+// time.Now.
+func NewMemoryApprovalUsage(now func() time.Time) *MemoryApprovalUsage {
+	if now == nil {
+		now = time.Now
+	}
+	return &MemoryApprovalUsage{now: now, uses: make(map[string]*approvalUse)}
+}
+
+// Use implements ApprovalUsageStore
+func (m *MemoryApprovalUsage) Use(_ context.Context, id string, max int, expiresAt time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked(m.now())
+	use, ok := m.uses[id]
+	if !ok {
+		use = &approvalUse{expiresAt: expiresAt}
+		m.uses[id] = use
+	}
+	if use.used >= max {
+		return use.used, fmt.Errorf("%w: %d of %d uses", ErrApprovalExhausted, use.used, max)
+	}
+	use.used++
+	return use.used, nil
+}
+
+// Used implements ApprovalUsageStore
+func (m *MemoryApprovalUsage) Used(_ context.Context, id string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	use, ok := m.uses[id]
+	if !ok || !m.now().Before(use.expiresAt) {
+		return -1, nil
+	}
+	return use.used, nil
+}
+
+// pruneLocked drops usage counts of expired approvals
+func (m *MemoryApprovalUsage) pruneLocked(now time.Time) {
+	for id, use := range m.uses {
+		if !now.Before(use.expiresAt) {
+			delete(m.uses, id)
+		}
+	}
+}
+
+// useScript increments the counter unless it reached the cap and expires it
+// with the approval
+var useScript = redis.NewScript(`
+local used = tonumber(redis.call("GET", KEYS[1]) or "0")
+if used >= tonumber(ARGV[1]) then
+	return -used - 1
+end
+used = redis.call("INCR", KEYS[1])
+redis.call("PEXPIREAT", KEYS[1], ARGV[2])
+return used
+`)
+
+// RedisApprovalUsage counts approval uses in Redis, shared by every
+// instance and kept across restarts until the approval expires
+type RedisApprovalUsage struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisApprovalUsage creates a Redis usage store. Keys are prefixed with
+// prefix (default: "gauth:approval:").
+func NewRedisApprovalUsage(client redis.UniversalClient, prefix string) *RedisApprovalUsage {
+	if prefix == "" {
+		prefix = "gauth:approval:"
+	}
+	return &RedisApprovalUsage{client: client, prefix: prefix}
+}
+
+// Use implements ApprovalUsageStore
+func (r *RedisApprovalUsage) Use(ctx context.Context, id string, max int, expiresAt time.Time) (int, error) {
+	n, err := useScript.Run(ctx, r.client, []string{r.prefix + id}, max, expiresAt.UnixMilli()).Int()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count approval use: %w", err)
+	}
+	if n < 0 {
+		return -n - 1, fmt.Errorf("%w: %d of %d uses", ErrApprovalExhausted, -n-1, max)
+	}
+	return n, nil
+}
+
+// Used implements ApprovalUsageStore
+func (r *RedisApprovalUsage) Used(ctx context.Context, id string) (int, error) {
+	n, err := r.client.Get(ctx, r.prefix+id).Int()
+	if err == redis.Nil {
+		return -1, nil
+	}
+	if err != nil {
+		return -1, fmt.Errorf("failed to read approval use: %w", err)
+	}
+	return n, nil
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/Gimel-Foundation/gauth/pkg/audit"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
 )
 
 type Type string
@@ -51,6 +52,9 @@ type Config struct {
 	// ApprovalRules for compliance (added for RFC111/core example compatibility)
 	ApprovalRules []ApprovalRule
 
+	// Calendars resolves holiday calendars named by time restrictions
+	Calendars token.CalendarProvider
+
 	// ExtraConfig for OAuth2 and other advanced flows
 	ExtraConfig interface{}
 }
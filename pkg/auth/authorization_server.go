@@ -0,0 +1,730 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	autherrors "github.com/Gimel-Foundation/gauth/pkg/errors"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// Client registry errors
+var (
+	// ErrClientNotFound indicates no client is registered with the ID
+	ErrClientNotFound = errors.New("client not found")
+
+	// ErrClientExists indicates a client with the ID is already registered
+	ErrClientExists = errors.New("client already registered")
+)
+
+// PKCE code challenge methods (RFC 7636)
+const (
+	PKCEMethodS256  = "S256"
+	PKCEMethodPlain = "plain"
+)
+
+// OAuthClient is an OAuth2 client registered with the authorization server
+type OAuthClient struct {
+	ID   string `json:"client_id"`
+	Name string `json:"client_name,omitempty"`
+
+	// SecretHash is the SHA-256 of the client secret; empty for public
+	// clients, which must use PKCE
+	SecretHash string `json:"-"`
+
+	// RedirectURIs are the exact redirect URIs the client may use
+	RedirectURIs []string `json:"redirect_uris"`
+
+	// Scopes limits the scopes the client may request (empty = any scope)
+	Scopes []string `json:"scope,omitempty"`
+
+	// GrantTypes limits the grants the client may use (empty =
+	// authorization_code and refresh_token)
+	GrantTypes []string `json:"grant_types,omitempty"`
+
+	// PowerOfAttorney is embedded in client_credentials tokens
+	PowerOfAttorney *ClientPowerOfAttorney `json:"power_of_attorney,omitempty"`
+}
+
+// allowsGrant reports whether the client may use the grant type
+func (c *OAuthClient) allowsGrant(grant string) bool {
+	if len(c.GrantTypes) == 0 {
+		return grant == GrantTypeAuthCode || grant == GrantTypeRefreshToken
+	}
+	return contains(c.GrantTypes, grant)
+}
+
+// Public reports whether the client has no secret
+func (c *OAuthClient) Public() bool {
+	return c.SecretHash == ""
+}
+
+// checkSecret compares secret with the stored hash in constant time
+func (c *OAuthClient) checkSecret(secret string) bool {
+	sum := sha256.Sum256([]byte(secret))
+	hash := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(c.SecretHash), []byte(hash)) == 1
+}
+
+// ClientRegistry looks up registered OAuth2 clients
+type ClientRegistry interface {
+	// Client returns the client or ErrClientNotFound
+	Client(ctx context.Context, id string) (*OAuthClient, error)
+}
+
+// MemoryClientRegistry is an in-memory ClientRegistry
+type MemoryClientRegistry struct {
+	mu      sync.RWMutex
+	clients map[string]*OAuthClient
+}
+
+// NewMemoryClientRegistry creates an empty client registry
+func NewMemoryClientRegistry() *MemoryClientRegistry {
+	return &MemoryClientRegistry{clients: make(map[string]*OAuthClient)}
+}
+
+// Register adds a client. Confidential clients get a generated secret,
+// which is returned only once; public clients get "".
+func (r *MemoryClientRegistry) Register(client OAuthClient, confidential bool) (string, error) {
+	if client.ID == "" {
+		return "", errors.New("client ID is required")
+	}
+	if len(client.RedirectURIs) == 0 && client.allowsGrant(GrantTypeAuthCode) {
+		return "", errors.New("clients using authorization_code need a redirect URI")
+	}
+	var secret string
+	if confidential {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("failed to generate client secret: %w", err)
+		}
+		secret = base64.RawURLEncoding.EncodeToString(buf)
+		sum := sha256.Sum256([]byte(secret))
+		client.SecretHash = base64.RawURLEncoding.EncodeToString(sum[:])
+	} else {
+		client.SecretHash = ""
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clients[client.ID]; ok {
+		return "", fmt.Errorf("%w: %s", ErrClientExists, client.ID)
+	}
+	r.clients[client.ID] = &client
+	return secret, nil
+}
+
+// Client implements ClientRegistry
+func (r *MemoryClientRegistry) Client(_ context.Context, id string) (*OAuthClient, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.clients[id]
+	if !ok {
+		return nil, ErrClientNotFound
+	}
+	cp := *c
+	return &cp, nil
+}
+
+// ConsentDecision is the resource owner's answer to an authorization request
+type ConsentDecision struct {
+	// Subject identifies the authenticated resource owner
+	Subject string
+
+	// Scopes are the scopes granted, a subset of those requested
+	// (empty = everything requested)
+	Scopes []string
+
+	// Denied rejects the request with access_denied
+	Denied bool
+
+	// AuthTime is when the resource owner authenticated (zero = now)
+	AuthTime time.Time
+}
+
+// ConsentFunc authenticates the resource owner and asks for consent to an
+// authorization request. It returns a nil decision and nil error when it has
+// written the response itself, e.g. a login or consent page that sends the
+// user agent back to the authorization endpoint with the same parameters.
+type ConsentFunc func(w http.ResponseWriter, r *http.Request, req *ServiceAuthorizationRequest, client *OAuthClient) (*ConsentDecision, error)
+
+// AuthorizationServerConfig configures an AuthorizationServer
+type AuthorizationServerConfig struct {
+	// Tokens issues, refreshes and revokes tokens
+	Tokens token.ServiceAPI
+
+	// Clients looks up registered clients
+	Clients ClientRegistry
+
+	// Consent authenticates resource owners and records their consent
+	Consent ConsentFunc
+
+	// Issuer is set on issued tokens
+	Issuer string
+
+	// Audience is set on issued access tokens
+	Audience []string
+
+	// CodeTTL is the lifetime of authorization codes (default: 1m)
+	CodeTTL time.Duration
+
+	// RefreshTokens issues a refresh token with every code exchange
+	RefreshTokens bool
+
+	// RefreshTTL is the lifetime of refresh tokens (default: 720h)
+	RefreshTTL time.Duration
+
+	// RequirePKCE requires PKCE from confidential clients too; public
+	// clients must always use it
+	RequirePKCE bool
+
+	// AllowPlainPKCE accepts the "plain" challenge method besides S256
+	AllowPlainPKCE bool
+
+	// AllowMissingState accepts authorization requests without a state
+	// parameter (default: state is required)
+	AllowMissingState bool
+
+	// OIDC, if set, makes the server an OpenID Connect provider: openid
+	// requests get ID tokens, and discovery, UserInfo and JWKS are served
+	OIDC *OIDCConfig
+}
+
+// authorizationCode is an issued, not yet expired authorization code
+type authorizationCode struct {
+	clientID            string
+	subject             string
+	scopes              []string
+	redirectURI         string
+	redirectExplicit    bool
+	codeChallenge       string
+	codeChallengeMethod string
+	nonce               string
+	authTime            time.Time
+	expiresAt           time.Time
+	used                bool
+	issued              []*token.Token
+}
+
+// AuthorizationServer implements the OAuth2 authorization code and client
+// credentials flows (RFC 6749) with PKCE (RFC 7636) and token revocation
+// (RFC 7009):
+//
+//	/authorize  validates the request, asks Consent and redirects with a code
+//	/token      exchanges codes, refresh tokens and client credentials for tokens
+//	/revoke     revokes access and refresh tokens
+//
+// Codes are single-use: presenting one twice revokes the tokens issued for it.
+// With OIDC configured it also serves /.well-known/openid-configuration,
+// /userinfo and /jwks.
+type AuthorizationServer struct {
+	config AuthorizationServerConfig
+
+	mu    sync.Mutex
+	codes map[string]*authorizationCode
+}
+
+// NewAuthorizationServer creates an authorization server
+func NewAuthorizationServer(config AuthorizationServerConfig) (*AuthorizationServer, error) {
+	if config.Tokens == nil || config.Clients == nil || config.Consent == nil {
+		return nil, errors.New("token service, client registry and consent callback are required")
+	}
+	if config.CodeTTL <= 0 {
+		config.CodeTTL = time.Minute
+	}
+	if config.RefreshTTL <= 0 {
+		config.RefreshTTL = 720 * time.Hour
+	}
+	if err := validateOIDC(&config); err != nil {
+		return nil, err
+	}
+	return &AuthorizationServer{config: config, codes: make(map[string]*authorizationCode)}, nil
+}
+
+// Handler serves /authorize, /token and /revoke, plus the OpenID Connect
+// endpoints if OIDC is configured
+func (s *AuthorizationServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", s.HandleAuthorize)
+	mux.HandleFunc("/token", s.HandleToken)
+	mux.HandleFunc("/revoke", s.HandleRevoke)
+	if s.config.OIDC != nil {
+		mux.HandleFunc("/.well-known/openid-configuration", s.HandleDiscovery)
+		mux.HandleFunc("/userinfo", s.HandleUserInfo)
+		mux.Handle("/jwks", s.config.OIDC.Keys.Handler(0))
+	}
+	return mux
+}
+
+// HandleAuthorize serves the authorization endpoint. Errors found before the
+// client and redirect URI are verified are answered directly; later ones are
+// sent to the client's redirect URI.
+func (s *AuthorizationServer) HandleAuthorize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		autherrors.New(autherrors.ErrInvalidRequest, "method not allowed").
+			WithHTTPInfo(r.URL.Path, r.Method, http.StatusMethodNotAllowed, "").
+			WriteHTTP(w)
+		return
+	}
+	req := &ServiceAuthorizationRequest{
+		ClientID:            r.FormValue("client_id"),
+		ResponseType:        r.FormValue("response_type"),
+		RedirectURI:         r.FormValue("redirect_uri"),
+		Scope:               r.FormValue("scope"),
+		State:               r.FormValue("state"),
+		CodeChallenge:       r.FormValue("code_challenge"),
+		CodeChallengeMethod: r.FormValue("code_challenge_method"),
+		Nonce:               r.FormValue("nonce"),
+		Prompt:              r.FormValue("prompt"),
+		LoginHint:           r.FormValue("login_hint"),
+	}
+	client, err := s.config.Clients.Client(r.Context(), req.ClientID)
+	if err != nil {
+		autherrors.New(autherrors.ErrInvalidClient, "unknown client").WithCause(err).WriteHTTP(w)
+		return
+	}
+	redirectURI, explicit := req.RedirectURI, req.RedirectURI != ""
+	if !explicit && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if !contains(client.RedirectURIs, redirectURI) {
+		autherrors.New(autherrors.ErrInvalidRequest, "redirect_uri is not registered for the client").WriteHTTP(w)
+		return
+	}
+	req.RedirectURI = redirectURI
+
+	// From here on errors go back to the client
+	if code, msg := s.checkAuthorizeRequest(req, client); code != "" {
+		redirectError(w, r, req, code, msg)
+		return
+	}
+
+	decision, err := s.config.Consent(w, r, req, client)
+	if err != nil {
+		redirectError(w, r, req, autherrors.ErrServerError, "consent failed")
+		return
+	}
+	if decision == nil {
+		return
+	}
+	if decision.Denied || decision.Subject == "" {
+		redirectError(w, r, req, autherrors.ErrAccessDenied, "the resource owner denied the request")
+		return
+	}
+	requested := strings.Fields(req.Scope)
+	granted := decision.Scopes
+	if len(granted) == 0 {
+		granted = requested
+	}
+	for _, scope := range granted {
+		if !contains(requested, scope) {
+			redirectError(w, r, req, autherrors.ErrInvalidScope, "consent granted a scope that was not requested")
+			return
+		}
+	}
+
+	code, err := randomValue()
+	if err != nil {
+		redirectError(w, r, req, autherrors.ErrServerError, "failed to issue code")
+		return
+	}
+	authTime := decision.AuthTime
+	if authTime.IsZero() {
+		authTime = time.Now()
+	}
+	method := req.CodeChallengeMethod
+	if req.CodeChallenge != "" && method == "" {
+		method = PKCEMethodPlain
+	}
+	s.mu.Lock()
+	s.purgeCodes(time.Now())
+	s.codes[code] = &authorizationCode{
+		clientID:            client.ID,
+		subject:             decision.Subject,
+		scopes:              granted,
+		redirectURI:         redirectURI,
+		redirectExplicit:    explicit,
+		codeChallenge:       req.CodeChallenge,
+		codeChallengeMethod: method,
+		nonce:               req.Nonce,
+		authTime:            authTime,
+		expiresAt:           time.Now().Add(s.config.CodeTTL),
+	}
+	s.mu.Unlock()
+
+	redirect(w, r, req, url.Values{"code": {code}})
+}
+
+// checkAuthorizeRequest validates the request parameters other than the
+// client and redirect URI
+func (s *AuthorizationServer) checkAuthorizeRequest(req *ServiceAuthorizationRequest, client *OAuthClient) (autherrors.ErrorCode, string) {
+	if req.ResponseType != "code" {
+		return autherrors.ErrUnsupportedResponse, "only response_type=code is supported"
+	}
+	if req.State == "" && !s.config.AllowMissingState {
+		return autherrors.ErrInvalidRequest, "state is required"
+	}
+	if req.Scope == "" {
+		return autherrors.ErrInvalidScope, "scope is required"
+	}
+	if s.config.OIDC != nil && s.config.OIDC.RequireNonce && req.Nonce == "" && contains(strings.Fields(req.Scope), ScopeOpenID) {
+		return autherrors.ErrInvalidRequest, "nonce is required"
+	}
+	if len(client.Scopes) > 0 {
+		for _, scope := range strings.Fields(req.Scope) {
+			if !contains(client.Scopes, scope) {
+				return autherrors.ErrInvalidScope, fmt.Sprintf("client may not request scope %s", scope)
+			}
+		}
+	}
+	if req.CodeChallenge == "" {
+		if client.Public() || s.config.RequirePKCE {
+			return autherrors.ErrInvalidRequest, "code_challenge is required"
+		}
+		return "", ""
+	}
+	switch req.CodeChallengeMethod {
+	case PKCEMethodS256:
+	case "", PKCEMethodPlain:
+		if !s.config.AllowPlainPKCE {
+			return autherrors.ErrInvalidRequest, "code_challenge_method must be S256"
+		}
+	default:
+		return autherrors.ErrInvalidRequest, "unsupported code_challenge_method"
+	}
+	return "", ""
+}
+
+// HandleToken serves the token endpoint
+func (s *AuthorizationServer) HandleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		autherrors.New(autherrors.ErrInvalidRequest, "method not allowed").
+			WithHTTPInfo(r.URL.Path, r.Method, http.StatusMethodNotAllowed, "").
+			WriteHTTP(w)
+		return
+	}
+	client, authErr := s.authenticateClient(r)
+	if authErr != nil {
+		writeClientAuthError(w, authErr)
+		return
+	}
+
+	var (
+		resp *OAuth2TokenResponse
+		err  *autherrors.Error
+	)
+	grant := r.PostFormValue("grant_type")
+	switch {
+	case grant == "":
+		err = autherrors.New(autherrors.ErrInvalidRequest, "grant_type is required")
+	case grant != GrantTypeAuthCode && grant != GrantTypeRefreshToken && grant != GrantTypeClientCreds:
+		err = autherrors.New(autherrors.ErrUnsupportedGrantType, fmt.Sprintf("grant_type %s is not supported", grant))
+	case !client.allowsGrant(grant):
+		err = autherrors.New(autherrors.ErrUnauthorizedClient, fmt.Sprintf("client may not use grant_type %s", grant))
+	case grant == GrantTypeAuthCode:
+		resp, err = s.exchangeCode(r, client)
+	case grant == GrantTypeRefreshToken:
+		resp, err = s.refresh(r, client)
+	default:
+		resp, err = s.clientCredentials(r, client)
+	}
+	if err != nil {
+		err.WriteHTTP(w)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// exchangeCode redeems an authorization code
+func (s *AuthorizationServer) exchangeCode(r *http.Request, client *OAuthClient) (*OAuth2TokenResponse, *autherrors.Error) {
+	ctx := r.Context()
+	value := r.PostFormValue("code")
+
+	s.mu.Lock()
+	code, ok := s.codes[value]
+	replay := ok && code.used
+	var issued []*token.Token
+	if replay {
+		issued = code.issued
+		delete(s.codes, value)
+	} else if ok {
+		code.used = true
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, autherrors.New(autherrors.ErrInvalidGrant, "unknown authorization code")
+	}
+	if replay {
+		for _, t := range issued {
+			_ = s.config.Tokens.Revoke(ctx, t, token.ReasonSecurityIncident)
+		}
+		return nil, autherrors.New(autherrors.ErrInvalidGrant, "authorization code was already used")
+	}
+	switch {
+	case time.Now().After(code.expiresAt):
+		return nil, autherrors.New(autherrors.ErrInvalidGrant, "authorization code has expired")
+	case code.clientID != client.ID:
+		return nil, autherrors.New(autherrors.ErrInvalidGrant, "authorization code was issued to another client")
+	case code.redirectExplicit && r.PostFormValue("redirect_uri") != code.redirectURI:
+		return nil, autherrors.New(autherrors.ErrInvalidGrant, "redirect_uri does not match the authorization request")
+	}
+	if !verifyPKCE(code.codeChallenge, code.codeChallengeMethod, r.PostFormValue("code_verifier")) {
+		return nil, autherrors.New(autherrors.ErrInvalidGrant, "code_verifier does not match the code challenge")
+	}
+
+	access, refresh, err := s.issue(ctx, client, code.subject, code.scopes, code.nonce)
+	if err != nil {
+		return nil, autherrors.New(autherrors.ErrServerError, "failed to issue tokens").WithCause(err)
+	}
+	issued = []*token.Token{access}
+	if refresh != nil {
+		issued = append(issued, refresh)
+	}
+	s.mu.Lock()
+	c, stillValid := s.codes[value]
+	if stillValid {
+		c.issued = issued
+	}
+	s.mu.Unlock()
+	if !stillValid {
+		// The code was replayed while these tokens were being issued
+		for _, t := range issued {
+			_ = s.config.Tokens.Revoke(ctx, t, token.ReasonSecurityIncident)
+		}
+		return nil, autherrors.New(autherrors.ErrInvalidGrant, "authorization code was already used")
+	}
+	resp := tokenResponse(access, refresh)
+	if s.config.OIDC != nil && contains(code.scopes, ScopeOpenID) {
+		if resp.IDToken, err = s.idToken(ctx, client, code, access); err != nil {
+			for _, t := range issued {
+				_ = s.config.Tokens.Revoke(ctx, t, token.ReasonSecurityIncident)
+			}
+			return nil, autherrors.New(autherrors.ErrServerError, "failed to issue ID token").WithCause(err)
+		}
+	}
+	return resp, nil
+}
+
+// refresh exchanges a refresh token issued to the client
+func (s *AuthorizationServer) refresh(r *http.Request, client *OAuthClient) (*OAuth2TokenResponse, *autherrors.Error) {
+	ctx := r.Context()
+	rt, err := s.config.Tokens.Parse(ctx, r.PostFormValue("refresh_token"))
+	if err != nil || rt.Type != token.Refresh || clientIDOf(rt) != client.ID {
+		return nil, autherrors.New(autherrors.ErrInvalidGrant, "invalid refresh token")
+	}
+	access, err := s.config.Tokens.Refresh(ctx, rt)
+	if err != nil {
+		return nil, autherrors.New(autherrors.ErrInvalidGrant, "invalid refresh token").WithCause(err)
+	}
+	return tokenResponse(access, nil), nil
+}
+
+// issue issues an access token and, if configured, a refresh token
+func (s *AuthorizationServer) issue(ctx context.Context, client *OAuthClient, subject string, scopes []string, nonce string) (*token.Token, *token.Token, error) {
+	metadata := func() *token.Metadata {
+		md := &token.Metadata{AppID: client.ID}
+		if nonce != "" {
+			md.AppData = map[string]string{"nonce": nonce}
+		}
+		return md
+	}
+	access, err := s.config.Tokens.Issue(ctx, &token.Token{
+		ID:       token.NewID(),
+		Type:     token.Access,
+		Issuer:   s.config.Issuer,
+		Subject:  subject,
+		Audience: s.config.Audience,
+		Scopes:   scopes,
+		Metadata: metadata(),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if !s.config.RefreshTokens {
+		return access, nil, nil
+	}
+	refresh, err := s.config.Tokens.Issue(ctx, &token.Token{
+		ID:        token.NewID(),
+		Type:      token.Refresh,
+		Issuer:    s.config.Issuer,
+		Subject:   subject,
+		Audience:  s.config.Audience,
+		Scopes:    scopes,
+		ExpiresAt: time.Now().Add(s.config.RefreshTTL),
+		Metadata:  metadata(),
+	})
+	if err != nil {
+		_ = s.config.Tokens.Revoke(ctx, access, token.ReasonSecurityIncident)
+		return nil, nil, err
+	}
+	return access, refresh, nil
+}
+
+// HandleRevoke serves the RFC 7009 revocation endpoint. Unknown and invalid
+// tokens are answered with 200, as the RFC requires.
+func (s *AuthorizationServer) HandleRevoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		autherrors.New(autherrors.ErrInvalidRequest, "method not allowed").
+			WithHTTPInfo(r.URL.Path, r.Method, http.StatusMethodNotAllowed, "").
+			WriteHTTP(w)
+		return
+	}
+	client, authErr := s.authenticateClient(r)
+	if authErr != nil {
+		writeClientAuthError(w, authErr)
+		return
+	}
+	value := r.PostFormValue("token")
+	if value == "" {
+		autherrors.New(autherrors.ErrInvalidRequest, "token is required").WriteHTTP(w)
+		return
+	}
+	t, err := s.config.Tokens.Parse(r.Context(), value)
+	if err == nil {
+		if clientIDOf(t) != client.ID {
+			autherrors.New(autherrors.ErrUnauthorizedClient, "token was issued to another client").WriteHTTP(w)
+			return
+		}
+		if err := s.config.Tokens.Revoke(r.Context(), t, token.ReasonPrincipalRequest); err != nil && !errors.Is(err, token.ErrTokenNotFound) {
+			autherrors.New(autherrors.ErrServerError, "failed to revoke token").WithCause(err).WriteHTTP(w)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// authenticateClient identifies the client by HTTP Basic credentials or by
+// client_id and client_secret form parameters. Public clients send only
+// their client_id.
+func (s *AuthorizationServer) authenticateClient(r *http.Request) (*OAuthClient, *autherrors.Error) {
+	id, secret, basic := r.BasicAuth()
+	if basic {
+		var err1, err2 error
+		id, err1 = url.QueryUnescape(id)
+		secret, err2 = url.QueryUnescape(secret)
+		if err1 != nil || err2 != nil {
+			return nil, autherrors.New(autherrors.ErrInvalidClient, "malformed client credentials")
+		}
+	} else {
+		id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if id == "" {
+		return nil, autherrors.New(autherrors.ErrInvalidClient, "client authentication is required")
+	}
+	client, err := s.config.Clients.Client(r.Context(), id)
+	if err != nil {
+		return nil, autherrors.New(autherrors.ErrInvalidClient, "client authentication failed")
+	}
+	if !client.Public() && !client.checkSecret(secret) {
+		return nil, autherrors.New(autherrors.ErrInvalidClient, "client authentication failed")
+	}
+	return client, nil
+}
+
+// writeClientAuthError answers a failed client authentication with the
+// challenge RFC 6749 §5.2 requires alongside 401 invalid_client
+func writeClientAuthError(w http.ResponseWriter, err *autherrors.Error) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="gauth"`)
+	err.WriteHTTP(w)
+}
+
+// purgeCodes drops expired codes. Callers hold s.mu.
+func (s *AuthorizationServer) purgeCodes(now time.Time) {
+	for k, c := range s.codes {
+		// Redeemed codes are kept until expiry so a replay can be detected
+		if now.After(c.expiresAt.Add(s.config.CodeTTL)) {
+			delete(s.codes, k)
+		}
+	}
+}
+
+// verifyPKCE checks the code verifier against the stored challenge. Codes
+// issued without a challenge accept no verifier.
+func verifyPKCE(challenge, method, verifier string) bool {
+	if challenge == "" {
+		return verifier == ""
+	}
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	expected := verifier
+	if method == PKCEMethodS256 {
+		sum := sha256.Sum256([]byte(verifier))
+		expected = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(expected)) == 1
+}
+
+// PKCEChallenge returns the S256 code challenge of a code verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func tokenResponse(access, refresh *token.Token) *OAuth2TokenResponse {
+	resp := &OAuth2TokenResponse{
+		AccessToken: access.Value,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(access.ExpiresAt).Seconds()),
+		Scope:       strings.Join(access.Scopes, " "),
+	}
+	if refresh != nil {
+		resp.RefreshToken = refresh.Value
+	}
+	return resp
+}
+
+func clientIDOf(t *token.Token) string {
+	if t.Metadata == nil {
+		return ""
+	}
+	return t.Metadata.AppID
+}
+
+func randomValue() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// redirect sends the user agent back to the client with params and the state
+func redirect(w http.ResponseWriter, r *http.Request, req *ServiceAuthorizationRequest, params url.Values) {
+	target, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		autherrors.New(autherrors.ErrInvalidRequest, "invalid redirect_uri").WriteHTTP(w)
+		return
+	}
+	q := target.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	if req.State != "" {
+		q.Set("state", req.State)
+	}
+	target.RawQuery = q.Encode()
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func redirectError(w http.ResponseWriter, r *http.Request, req *ServiceAuthorizationRequest, code autherrors.ErrorCode, description string) {
+	redirect(w, r, req, url.Values{"error": {string(code)}, "error_description": {description}})
+}
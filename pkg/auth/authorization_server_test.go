@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+func TestAuthorizationServer(t *testing.T) {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	tokens := token.NewService(token.Config{SigningKey: key, ValidityPeriod: time.Hour}, token.NewMemoryStore(time.Hour))
+
+	clients := NewMemoryClientRegistry()
+	const redirectURI = "https://app.example.com/callback"
+	if _, err := clients.Register(OAuthClient{ID: "spa", RedirectURIs: []string{redirectURI}, Scopes: []string{"read", "write"}}, false); err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+	secret, err := clients.Register(OAuthClient{ID: "backend", RedirectURIs: []string{redirectURI}}, true)
+	if err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+
+	deny := false
+	server, err := NewAuthorizationServer(AuthorizationServerConfig{
+		Tokens:  tokens,
+		Clients: clients,
+		Consent: func(w http.ResponseWriter, r *http.Request, req *ServiceAuthorizationRequest, _ *OAuthClient) (*ConsentDecision, error) {
+			user := r.Header.Get("X-User")
+			if user == "" {
+				http.Error(w, "login required", http.StatusUnauthorized)
+				return nil, nil
+			}
+			return &ConsentDecision{Subject: user, Denied: deny}, nil
+		},
+		Issuer:        "https://auth.example.com",
+		RefreshTokens: true,
+	})
+	if err != nil {
+		t.Fatalf("NewAuthorizationServer() error: %v", err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+	noFollow := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	verifier := strings.Repeat("v", 50)
+	authorize := func(t *testing.T, params url.Values, user string) *url.URL {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/authorize?"+params.Encode(), nil)
+		if user != "" {
+			req.Header.Set("X-User", user)
+		}
+		resp, err := noFollow.Do(req)
+		if err != nil {
+			t.Fatalf("authorize error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusFound {
+			return nil
+		}
+		loc, _ := url.Parse(resp.Header.Get("Location"))
+		return loc
+	}
+	spaParams := func() url.Values {
+		return url.Values{
+			"client_id":             {"spa"},
+			"response_type":         {"code"},
+			"redirect_uri":          {redirectURI},
+			"scope":                 {"read"},
+			"state":                 {"xyz"},
+			"code_challenge":        {PKCEChallenge(verifier)},
+			"code_challenge_method": {PKCEMethodS256},
+		}
+	}
+	exchange := func(form url.Values) (int, OAuth2TokenResponse, string) {
+		resp, err := http.PostForm(ts.URL+"/token", form)
+		if err != nil {
+			t.Fatalf("token error: %v", err)
+		}
+		defer resp.Body.Close()
+		var body map[string]interface{}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		raw, _ := json.Marshal(body)
+		var tr OAuth2TokenResponse
+		_ = json.Unmarshal(raw, &tr)
+		errCode, _ := body["error"].(string)
+		return resp.StatusCode, tr, errCode
+	}
+	codeForm := func(code string) url.Values {
+		return url.Values{
+			"grant_type":    {GrantTypeAuthCode},
+			"code":          {code},
+			"redirect_uri":  {redirectURI},
+			"client_id":     {"spa"},
+			"code_verifier": {verifier},
+		}
+	}
+
+	t.Run("Code Flow With PKCE", func(t *testing.T) {
+		loc := authorize(t, spaParams(), "alice")
+		if loc == nil || loc.Query().Get("state") != "xyz" || loc.Query().Get("code") == "" {
+			t.Fatalf("Expected redirect with code and state, got %v", loc)
+		}
+		status, tr, _ := exchange(codeForm(loc.Query().Get("code")))
+		if status != http.StatusOK || tr.AccessToken == "" || tr.RefreshToken == "" || tr.TokenType != "Bearer" {
+			t.Fatalf("Unexpected token response %d %+v", status, tr)
+		}
+		access, err := tokens.Parse(ctx, tr.AccessToken)
+		if err != nil || access.Subject != "alice" || access.Metadata.AppID != "spa" || tokens.Validate(ctx, access) != nil {
+			t.Errorf("Expected a valid token for alice, got %+v (%v)", access, err)
+		}
+
+		status, refreshed, _ := exchange(url.Values{"grant_type": {GrantTypeRefreshToken}, "refresh_token": {tr.RefreshToken}, "client_id": {"spa"}})
+		if status != http.StatusOK || refreshed.AccessToken == "" {
+			t.Errorf("Expected refresh to succeed, got %d %+v", status, refreshed)
+		}
+	})
+
+	t.Run("Code Replay Revokes Tokens", func(t *testing.T) {
+		code := authorize(t, spaParams(), "alice").Query().Get("code")
+		_, tr, _ := exchange(codeForm(code))
+		if _, _, errCode := exchange(codeForm(code)); errCode != "invalid_grant" {
+			t.Errorf("Expected invalid_grant on replay, got %q", errCode)
+		}
+		access, _ := tokens.Parse(ctx, tr.AccessToken)
+		if err := tokens.Validate(ctx, access); err == nil {
+			t.Error("Expected tokens from a replayed code to be revoked")
+		}
+	})
+
+	t.Run("Bad Verifier Or Redirect", func(t *testing.T) {
+		form := codeForm(authorize(t, spaParams(), "alice").Query().Get("code"))
+		form.Set("code_verifier", strings.Repeat("x", 50))
+		if _, _, errCode := exchange(form); errCode != "invalid_grant" {
+			t.Errorf("Expected invalid_grant for wrong verifier, got %q", errCode)
+		}
+		form = codeForm(authorize(t, spaParams(), "alice").Query().Get("code"))
+		form.Set("redirect_uri", "https://app.example.com/other")
+		if _, _, errCode := exchange(form); errCode != "invalid_grant" {
+			t.Errorf("Expected invalid_grant for another redirect_uri, got %q", errCode)
+		}
+	})
+
+	t.Run("Request Validation", func(t *testing.T) {
+		params := spaParams()
+		params.Del("code_challenge")
+		if loc := authorize(t, params, "alice"); loc == nil || loc.Query().Get("error") != "invalid_request" {
+			t.Errorf("Expected public client without PKCE to be refused, got %v", loc)
+		}
+		params = spaParams()
+		params.Del("state")
+		if loc := authorize(t, params, "alice"); loc == nil || loc.Query().Get("error") != "invalid_request" {
+			t.Errorf("Expected missing state to be refused, got %v", loc)
+		}
+		params = spaParams()
+		params.Set("scope", "admin")
+		if loc := authorize(t, params, "alice"); loc == nil || loc.Query().Get("error") != "invalid_scope" {
+			t.Errorf("Expected unregistered scope to be refused, got %v", loc)
+		}
+		params = spaParams()
+		params.Set("redirect_uri", "https://evil.example.com/")
+		if loc := authorize(t, params, "alice"); loc != nil {
+			t.Errorf("Expected no redirect to an unregistered URI, got %v", loc)
+		}
+		if loc := authorize(t, spaParams(), ""); loc != nil {
+			t.Errorf("Expected the consent callback to answer unauthenticated users, got %v", loc)
+		}
+		deny = true
+		defer func() { deny = false }()
+		if loc := authorize(t, spaParams(), "alice"); loc == nil || loc.Query().Get("error") != "access_denied" || loc.Query().Get("state") != "xyz" {
+			t.Errorf("Expected access_denied with state, got %v", loc)
+		}
+	})
+
+	t.Run("Confidential Client And Revocation", func(t *testing.T) {
+		params := url.Values{"client_id": {"backend"}, "response_type": {"code"}, "scope": {"read"}, "state": {"s"}}
+		code := authorize(t, params, "bob").Query().Get("code")
+		form := url.Values{"grant_type": {GrantTypeAuthCode}, "code": {code}, "client_id": {"backend"}, "client_secret": {"wrong"}}
+		if status, _, errCode := exchange(form); status != http.StatusUnauthorized || errCode != "invalid_client" {
+			t.Errorf("Expected invalid_client, got %d %q", status, errCode)
+		}
+		form.Set("client_secret", secret)
+		_, tr, _ := exchange(form)
+		if tr.AccessToken == "" {
+			t.Fatal("Expected confidential client to redeem the code")
+		}
+
+		revoke := func(client, secret, tok string) int {
+			req, _ := http.NewRequest(http.MethodPost, ts.URL+"/revoke", strings.NewReader(url.Values{"token": {tok}}.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth(client, secret)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("revoke error: %v", err)
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		if status := revoke("spa", "", tr.AccessToken); status != http.StatusBadRequest {
+			t.Errorf("Expected another client to be refused, got %d", status)
+		}
+		if status := revoke("backend", secret, tr.AccessToken); status != http.StatusOK {
+			t.Errorf("Expected revocation to succeed, got %d", status)
+		}
+		access, _ := tokens.Parse(ctx, tr.AccessToken)
+		if err := tokens.Validate(ctx, access); err == nil {
+			t.Error("Expected the token to be revoked")
+		}
+		if status := revoke("backend", secret, "garbage"); status != http.StatusOK {
+			t.Errorf("Expected unknown tokens to be answered with 200, got %d", status)
+		}
+	})
+
+	t.Run("Unsupported Grant", func(t *testing.T) {
+		if _, _, errCode := exchange(url.Values{"grant_type": {"password"}, "client_id": {"spa"}}); errCode != "unsupported_grant_type" {
+			t.Errorf("Expected unsupported_grant_type, got %q", errCode)
+		}
+	})
+}
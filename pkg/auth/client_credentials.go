@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	autherrors "github.com/Gimel-Foundation/gauth/pkg/errors"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// ClientPowerOfAttorney is the RFC111 power of attorney a principal has
+// registered for a machine client. Tokens issued to the client with the
+// client_credentials grant carry it as a one-link delegation chain.
+type ClientPowerOfAttorney struct {
+	// Principal is the party granting the power
+	Principal string `json:"principal"`
+
+	// Scopes are the delegated scopes; client_credentials tokens carry only
+	// those that are also registered for the client
+	Scopes []string `json:"scopes"`
+
+	// Restrictions limit what the client may do with the scopes
+	Restrictions *token.Restrictions `json:"restrictions,omitempty"`
+
+	// Attestations are the notary or witness attestations of the grant
+	Attestations []token.Attestation `json:"attestations,omitempty"`
+
+	// GrantedAt is when the power was granted
+	GrantedAt time.Time `json:"granted_at"`
+
+	// ValidUntil ends the power (zero = no end)
+	ValidUntil time.Time `json:"valid_until,omitempty"`
+}
+
+// link returns the delegation link granting the power to the client
+func (p *ClientPowerOfAttorney) link(clientID string) token.DelegationLink {
+	var restrictions *token.Restrictions
+	if p.Restrictions != nil {
+		r := *p.Restrictions
+		restrictions = &r
+	}
+	return token.DelegationLink{
+		Principal:    p.Principal,
+		Delegate:     clientID,
+		Scopes:       append([]string(nil), p.Scopes...),
+		Restrictions: restrictions,
+		Attestations: append([]token.Attestation(nil), p.Attestations...),
+		GrantedAt:    p.GrantedAt,
+		ExpiresAt:    p.ValidUntil,
+	}
+}
+
+// clientCredentials issues a token to a confidential client acting on its
+// own behalf. The token's subject is the client ID. A client with a
+// registered power of attorney gets its delegation chain embedded and may
+// only request scopes that are both registered and delegated.
+func (s *AuthorizationServer) clientCredentials(r *http.Request, client *OAuthClient) (*OAuth2TokenResponse, *autherrors.Error) {
+	if client.Public() {
+		return nil, autherrors.New(autherrors.ErrUnauthorizedClient, "public clients cannot use client_credentials")
+	}
+	// Only registered scopes are granted; a power of attorney narrows them
+	// to the delegated ones. Nothing registered means nothing is granted.
+	allowed := client.Scopes
+	if poa := client.PowerOfAttorney; poa != nil {
+		allowed = nil
+		for _, scope := range poa.Scopes {
+			if contains(client.Scopes, scope) {
+				allowed = append(allowed, scope)
+			}
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, autherrors.New(autherrors.ErrInvalidScope, "client has no scopes it may request")
+	}
+	scopes := strings.Fields(r.PostFormValue("scope"))
+	if len(scopes) == 0 {
+		scopes = allowed
+	}
+	for _, scope := range scopes {
+		if !contains(allowed, scope) {
+			return nil, autherrors.New(autherrors.ErrInvalidScope, "client may not request scope "+scope)
+		}
+	}
+
+	t := &token.Token{
+		ID:       token.NewID(),
+		Type:     token.Access,
+		Issuer:   s.config.Issuer,
+		Subject:  client.ID,
+		Audience: s.config.Audience,
+		Scopes:   scopes,
+		Metadata: &token.Metadata{AppID: client.ID},
+	}
+	if poa := client.PowerOfAttorney; poa != nil {
+		if !poa.ValidUntil.IsZero() && !time.Now().Before(poa.ValidUntil) {
+			return nil, autherrors.New(autherrors.ErrUnauthorizedClient, "the client's power of attorney has expired")
+		}
+		// Validation fails once the power ends, even if the token has not expired
+		t.Metadata.Delegation = &token.DelegationChain{Links: []token.DelegationLink{poa.link(client.ID)}}
+	}
+	issued, err := s.config.Tokens.Issue(r.Context(), t)
+	if err != nil {
+		return nil, autherrors.New(autherrors.ErrServerError, "failed to issue token").WithCause(err)
+	}
+	return tokenResponse(issued, nil), nil
+}
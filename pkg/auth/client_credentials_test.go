@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+func TestClientCredentialsGrant(t *testing.T) {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	tokens := token.NewService(token.Config{SigningKey: key, ValidityPeriod: time.Hour}, token.NewMemoryStore(time.Hour))
+
+	clients := NewMemoryClientRegistry()
+	agentSecret, err := clients.Register(OAuthClient{
+		ID:         "payment-agent",
+		GrantTypes: []string{GrantTypeClientCreds},
+		Scopes:     []string{"payments:initiate", "payments:read", "payments:refund"},
+		PowerOfAttorney: &ClientPowerOfAttorney{
+			Principal: "acme-corp",
+			Scopes:    []string{"payments:initiate", "payments:read"},
+			Restrictions: &token.Restrictions{
+				ValueLimits: &token.ValueLimits{MaxTransactionValue: 1000, Currency: "EUR"},
+			},
+			GrantedAt:  time.Now(),
+			ValidUntil: time.Now().Add(24 * time.Hour),
+		},
+	}, true)
+	if err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+	plainSecret, err := clients.Register(OAuthClient{ID: "reporter", GrantTypes: []string{GrantTypeClientCreds}, Scopes: []string{"reports:read"}}, true)
+	if err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+	// A power of attorney without scopes, and one delegating an unregistered scope
+	emptySecret, err := clients.Register(OAuthClient{
+		ID: "empty-poa", GrantTypes: []string{GrantTypeClientCreds}, Scopes: []string{"payments:read"},
+		PowerOfAttorney: &ClientPowerOfAttorney{Principal: "acme-corp", GrantedAt: time.Now()},
+	}, true)
+	if err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+	narrowSecret, err := clients.Register(OAuthClient{
+		ID: "narrow-poa", GrantTypes: []string{GrantTypeClientCreds}, Scopes: []string{"payments:read"},
+		PowerOfAttorney: &ClientPowerOfAttorney{Principal: "acme-corp", Scopes: []string{"payments:read", "payments:initiate"}, GrantedAt: time.Now()},
+	}, true)
+	if err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+	unscopedSecret, err := clients.Register(OAuthClient{ID: "unscoped", GrantTypes: []string{GrantTypeClientCreds}}, true)
+	if err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+	webSecret, err := clients.Register(OAuthClient{ID: "web", RedirectURIs: []string{"https://web.example.com/cb"}}, true)
+	if err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+
+	server, err := NewAuthorizationServer(AuthorizationServerConfig{
+		Tokens:  tokens,
+		Clients: clients,
+		Consent: func(http.ResponseWriter, *http.Request, *ServiceAuthorizationRequest, *OAuthClient) (*ConsentDecision, error) {
+			return nil, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewAuthorizationServer() error: %v", err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	request := func(clientID, secret, scope string) (*token.Token, string) {
+		form := url.Values{"grant_type": {GrantTypeClientCreds}}
+		if scope != "" {
+			form.Set("scope", scope)
+		}
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(clientID, secret)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("token error: %v", err)
+		}
+		defer resp.Body.Close()
+		var body struct {
+			AccessToken string `json:"access_token"`
+			Error       string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		if body.AccessToken == "" {
+			return nil, body.Error
+		}
+		tok, err := tokens.Parse(ctx, body.AccessToken)
+		if err != nil {
+			t.Fatalf("Parse() error: %v", err)
+		}
+		return tok, ""
+	}
+
+	t.Run("Embeds Power Of Attorney", func(t *testing.T) {
+		tok, errCode := request("payment-agent", agentSecret, "")
+		if tok == nil {
+			t.Fatalf("Expected a token, got %q", errCode)
+		}
+		if tok.Subject != "payment-agent" || len(tok.Scopes) != 2 {
+			t.Errorf("Expected the client as subject with every delegated scope, got %+v", tok)
+		}
+		chain := token.DelegationChainOf(tok)
+		if chain == nil || chain.Root() != "acme-corp" || chain.Holder() != "payment-agent" {
+			t.Fatalf("Expected the power of attorney in the token, got %+v", chain)
+		}
+		if err := token.CheckDelegatedValue(tok, 1500, "EUR", time.Now()); err == nil {
+			t.Error("Expected the registered value limit to travel with the token")
+		}
+		if err := tokens.Validate(ctx, tok); err != nil {
+			t.Errorf("Validate() error: %v", err)
+		}
+	})
+
+	t.Run("Scope Narrowing", func(t *testing.T) {
+		tok, _ := request("payment-agent", agentSecret, "payments:read")
+		if tok == nil || len(tok.Scopes) != 1 {
+			t.Fatalf("Expected a narrowed token, got %+v", tok)
+		}
+		if _, errCode := request("payment-agent", agentSecret, "payments:refund"); errCode != "invalid_scope" {
+			t.Errorf("Expected undelegated scope to be refused, got %q", errCode)
+		}
+	})
+
+	t.Run("Empty Scopes Deny", func(t *testing.T) {
+		if _, errCode := request("empty-poa", emptySecret, "payments:read"); errCode != "invalid_scope" {
+			t.Errorf("Expected a power of attorney without scopes to grant nothing, got %q", errCode)
+		}
+		if _, errCode := request("unscoped", unscopedSecret, "anything"); errCode != "invalid_scope" {
+			t.Errorf("Expected a client without scopes to be refused, got %q", errCode)
+		}
+		tok, _ := request("narrow-poa", narrowSecret, "")
+		if tok == nil || len(tok.Scopes) != 1 || tok.Scopes[0] != "payments:read" {
+			t.Errorf("Expected the delegated scopes intersected with the registered ones, got %+v", tok)
+		}
+		if _, errCode := request("narrow-poa", narrowSecret, "payments:initiate"); errCode != "invalid_scope" {
+			t.Errorf("Expected an unregistered delegated scope to be refused, got %q", errCode)
+		}
+	})
+
+	t.Run("Client Without Power Of Attorney", func(t *testing.T) {
+		tok, errCode := request("reporter", plainSecret, "")
+		if tok == nil || token.DelegationChainOf(tok) != nil || tok.Scopes[0] != "reports:read" {
+			t.Errorf("Expected a plain client token, got %+v (%q)", tok, errCode)
+		}
+	})
+
+	t.Run("Grant Not Registered", func(t *testing.T) {
+		if _, errCode := request("web", webSecret, ""); errCode != "unauthorized_client" {
+			t.Errorf("Expected unauthorized_client, got %q", errCode)
+		}
+		if _, errCode := request("payment-agent", "wrong", ""); errCode != "invalid_client" {
+			t.Errorf("Expected invalid_client, got %q", errCode)
+		}
+	})
+}
@@ -202,40 +202,15 @@ func (c *StandardComplianceChecker) checkGeographicConstraints(_ context.Context
 	return nil
 }
 
-func (c *StandardComplianceChecker) checkTimeConstraints(_ context.Context, constraints *token.TimeConstraints) error {
-	if len(constraints.AllowedTimeWindows) == 0 {
-		return nil
-	}
-
-	now := time.Now()
-	loc, err := time.LoadLocation(constraints.TimeZone)
-	if err != nil {
-		return NewError(ErrRuleViolation, "invalid timezone", err)
+func (c *StandardComplianceChecker) checkTimeConstraints(ctx context.Context, constraints *token.TimeConstraints) error {
+	var calendars token.CalendarProvider
+	if c.config != nil {
+		calendars = c.config.Calendars
 	}
-
-	localNow := now.In(loc)
-	weekday := localNow.Weekday()
-
-	for _, window := range constraints.AllowedTimeWindows {
-		// Check if current day is allowed
-		dayAllowed := false
-		for _, day := range window.DaysOfWeek {
-			if int(weekday) == day {
-				dayAllowed = true
-				break
-			}
-		}
-		if !dayAllowed {
-			continue
-		}
-
-		// Check if current time is within window
-		if isTimeInWindow(localNow, window) {
-			return nil
-		}
+	if err := constraints.Check(ctx, time.Now(), calendars); err != nil {
+		return NewError(ErrRuleViolation, "action not allowed at current time", err)
 	}
-
-	return NewError(ErrRuleViolation, "action not allowed at current time", nil)
+	return nil
 }
 
 func (c *StandardComplianceChecker) checkCustomLimits(_ context.Context, _ map[string]interface{}, _ string) error {
@@ -255,28 +230,3 @@ func (c *StandardComplianceChecker) enforceRule(_ context.Context, _ ApprovalRul
 	// Implement rule enforcement logic
 	return nil
 }
-
-// Helper function to check if a time is within a time window
-func isTimeInWindow(t time.Time, window token.TimeWindow) bool {
-	// Parse window times
-	start, err := time.Parse("15:04", window.StartTime)
-	if err != nil {
-		return false
-	}
-	end, err := time.Parse("15:04", window.EndTime)
-	if err != nil {
-		return false
-	}
-
-	// Extract time of day
-	timeOfDay := time.Date(0, 1, 1, t.Hour(), t.Minute(), 0, 0, time.UTC)
-	start = time.Date(0, 1, 1, start.Hour(), start.Minute(), 0, 0, time.UTC)
-	end = time.Date(0, 1, 1, end.Hour(), end.Minute(), 0, 0, time.UTC)
-
-	// Handle windows that span midnight
-	if end.Before(start) {
-		return timeOfDay.After(start) || timeOfDay.Before(end)
-	}
-
-	return timeOfDay.After(start) && timeOfDay.Before(end)
-}
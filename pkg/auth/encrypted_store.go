@@ -26,6 +26,22 @@ type EncryptedStoreConfig struct {
 	TokenTTL time.Duration
 }
 
+// EscrowEncryptionKey deposits the store's encryption key with the given
+// escrow under keyID and returns the custodian shares. Use KeyEscrow.Recover
+// to reconstruct the key for disaster recovery.
+func (c EncryptedStoreConfig) EscrowEncryptionKey(
+	ctx context.Context, escrow *KeyEscrow, keyID string,
+) ([]EscrowShare, error) {
+	if len(c.EncryptionKey) != 32 {
+		return nil, errors.New("encryption key must be 32 bytes for AES-256")
+	}
+	_, shares, err := escrow.Deposit(ctx, keyID, c.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to escrow encryption key: %w", err)
+	}
+	return shares, nil
+}
+
 // encryptedTokenStore implements token.EnhancedStore with encryption
 type encryptedTokenStore struct {
 	config EncryptedStoreConfig
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -38,6 +39,46 @@ type JurisdictionRules struct {
 	RequiredRoles []string
 }
 
+// Dual control errors
+var (
+	// ErrNoJurisdictionRules indicates no rules are configured for a token,
+	// so dual control cannot be decided and the action is refused
+	ErrNoJurisdictionRules = errors.New("no jurisdiction rules configured")
+
+	// ErrSelfApproval indicates an approver tried to approve their own action
+	ErrSelfApproval = errors.New("approver may not approve their own action")
+
+	// ErrApproverRole indicates the approver does not hold a required role
+	ErrApproverRole = errors.New("approver roles do not meet requirements")
+)
+
+// JurisdictionRulesSource returns the rules that apply to a token
+type JurisdictionRulesSource interface {
+	RulesFor(ctx context.Context, token *token.EnhancedToken) (*JurisdictionRules, error)
+}
+
+// JurisdictionRulesFunc adapts a function to JurisdictionRulesSource
+type JurisdictionRulesFunc func(ctx context.Context, token *token.EnhancedToken) (*JurisdictionRules, error)
+
+// RulesFor implements JurisdictionRulesSource
+func (f JurisdictionRulesFunc) RulesFor(ctx context.Context, token *token.EnhancedToken) (*JurisdictionRules, error) {
+	return f(ctx, token)
+}
+
+// ApproverRoles is the authoritative record of which roles approvers hold.
+// Roles claimed in an approval are only trusted once confirmed here.
+type ApproverRoles interface {
+	HasRole(ctx context.Context, approver, role string) (bool, error)
+}
+
+// StaticApproverRoles maps approver IDs to the roles they hold
+type StaticApproverRoles map[string][]string
+
+// HasRole implements ApproverRoles
+func (r StaticApproverRoles) HasRole(_ context.Context, approver, role string) (bool, error) {
+	return containsRole(r[approver], role), nil
+}
+
 // AuthorizationEnforcer handles enhanced authorization rules
 type AuthorizationEnforcer interface {
 	// VerifyHumanInChain ensures human accountability
@@ -52,9 +93,12 @@ type AuthorizationEnforcer interface {
 
 // StandardAuthorizationEnforcer implements AuthorizationEnforcer
 type StandardAuthorizationEnforcer struct {
-	store    token.EnhancedStore
-	verifier token.VerificationSystem
-	registry RegistryVerifier
+	store     token.EnhancedStore
+	verifier  token.VerificationSystem
+	registry  RegistryVerifier
+	approvals *OfflineApprovals
+	rules     JurisdictionRulesSource
+	roles     ApproverRoles
 }
 
 func NewStandardAuthorizationEnforcer(
@@ -69,6 +113,24 @@ func NewStandardAuthorizationEnforcer(
 	}
 }
 
+// SetOfflineApprovals lets dual control accept offline approval tokens
+// attached with WithOfflineApproval in place of a stored second approval
+func (e *StandardAuthorizationEnforcer) SetOfflineApprovals(approvals *OfflineApprovals) {
+	e.approvals = approvals
+}
+
+// SetJurisdictionRules configures where dual control looks up the rules for
+// a token. Without rules every action subject to dual control is refused.
+func (e *StandardAuthorizationEnforcer) SetJurisdictionRules(rules JurisdictionRulesSource) {
+	e.rules = rules
+}
+
+// SetApproverRoles configures the registry that confirms approver roles.
+// Without it, rules that require roles cannot be satisfied.
+func (e *StandardAuthorizationEnforcer) SetApproverRoles(roles ApproverRoles) {
+	e.roles = roles
+}
+
 // VerifyHumanInChain ensures there's always a human at the top of the authorization chain
 func (e *StandardAuthorizationEnforcer) VerifyHumanInChain(ctx context.Context, token *token.EnhancedToken) error {
 	verification, err := e.store.GetHumanVerification(ctx, token)
@@ -95,7 +157,9 @@ func (e *StandardAuthorizationEnforcer) VerifyHumanInChain(ctx context.Context,
 	return nil
 }
 
-// EnforceSecondLevelApproval implements the dual control principle
+// EnforceSecondLevelApproval implements the dual control principle. It
+// fails closed: without jurisdiction rules for the token the action is
+// refused.
 func (e *StandardAuthorizationEnforcer) EnforceSecondLevelApproval(
 	ctx context.Context, token *token.EnhancedToken, action string,
 ) error {
@@ -107,6 +171,10 @@ func (e *StandardAuthorizationEnforcer) EnforceSecondLevelApproval(
 	// Determine required approval level
 	requiredLevel := rules.RequiredApprovals[action]
 	if requiredLevel >= DualApproval {
+		if raw, ok := OfflineApprovalFromContext(ctx); ok && e.approvals != nil {
+			return e.consumeOfflineApproval(ctx, token, raw, action, rules.RequiredRoles)
+		}
+
 		approval, err := e.store.GetSecondLevelApproval(ctx, token)
 		if err != nil {
 			return fmt.Errorf("failed to get second level approval: %w", err)
@@ -117,9 +185,15 @@ func (e *StandardAuthorizationEnforcer) EnforceSecondLevelApproval(
 			return fmt.Errorf("secondary approval required")
 		}
 
+		// The second approver must be someone other than the requester and
+		// the first approver
+		if approval.SecondaryApprover == subjectOf(token) || approval.SecondaryApprover == approval.PrimaryApprover {
+			return ErrSelfApproval
+		}
+
 		// Verify roles meet requirements
-		if !e.verifyApproverRoles(ctx, approval, rules.RequiredRoles) {
-			return fmt.Errorf("approver roles do not meet requirements")
+		if err := e.verifyApproverRole(ctx, approval.SecondaryApprover, approval.SecondaryRole, rules.RequiredRoles); err != nil {
+			return err
 		}
 
 		// Verify approval is still valid
@@ -155,6 +229,66 @@ func (e *StandardAuthorizationEnforcer) ValidateJurisdictionRules(
 
 // Helper methods
 
+// consumeOfflineApproval stands in for the second approver with a signed
+// offline approval whose role meets the jurisdiction requirements
+func (e *StandardAuthorizationEnforcer) consumeOfflineApproval(
+	ctx context.Context, tok *token.EnhancedToken, raw, action string, requiredRoles []string,
+) error {
+	approval, err := e.approvals.Verify(raw)
+	if err != nil {
+		return fmt.Errorf("offline approval rejected: %w", err)
+	}
+	subject := subjectOf(tok)
+	if approval.Approver == subject {
+		return ErrSelfApproval
+	}
+	if err := e.verifyApproverRole(ctx, approval.Approver, approval.Role, requiredRoles); err != nil {
+		return err
+	}
+	if _, err := e.approvals.Consume(ctx, raw, subject, action); err != nil {
+		return fmt.Errorf("offline approval rejected: %w", err)
+	}
+	return nil
+}
+
+// verifyApproverRole checks that role is one of the required roles and that
+// the role registry confirms the approver holds it
+func (e *StandardAuthorizationEnforcer) verifyApproverRole(ctx context.Context, approver, role string, required []string) error {
+	if len(required) == 0 {
+		return nil
+	}
+	if !containsRole(required, role) {
+		return fmt.Errorf("%w: role %q not accepted", ErrApproverRole, role)
+	}
+	if e.roles == nil {
+		return fmt.Errorf("%w: no approver role registry configured", ErrApproverRole)
+	}
+	ok, err := e.roles.HasRole(ctx, approver, role)
+	if err != nil {
+		return fmt.Errorf("failed to verify approver role: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s does not hold role %q", ErrApproverRole, approver, role)
+	}
+	return nil
+}
+
+func subjectOf(tok *token.EnhancedToken) string {
+	if tok == nil || tok.Token == nil {
+		return ""
+	}
+	return tok.Token.Subject
+}
+
+func containsRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
 func (e *StandardAuthorizationEnforcer) verifyDelegationChain(_ context.Context, chain []common.DelegationLink) error {
 	if len(chain) == 0 {
 		return fmt.Errorf("empty delegation chain")
@@ -173,16 +307,20 @@ func (e *StandardAuthorizationEnforcer) verifyDelegationChain(_ context.Context,
 	return nil
 }
 
-func (e *StandardAuthorizationEnforcer) verifyApproverRoles(_ context.Context, _ *common.SecondLevelApproval, _ []string) bool {
-	// Implementation would verify that approvers have required roles
-	return true
-}
-
 func (e *StandardAuthorizationEnforcer) getJurisdictionRules(
-	_ context.Context, _ *token.EnhancedToken,
+	ctx context.Context, tok *token.EnhancedToken,
 ) (*JurisdictionRules, error) {
-	// Implementation would get rules for token's jurisdiction
-	return nil, nil
+	if e.rules == nil {
+		return nil, ErrNoJurisdictionRules
+	}
+	rules, err := e.rules.RulesFor(ctx, tok)
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		return nil, ErrNoJurisdictionRules
+	}
+	return rules, nil
 }
 
 func (e *StandardAuthorizationEnforcer) verifyFiduciaryDuties(
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// EscrowStore persists escrow records and recovery requests. It must outlive
+// the deployment whose keys it escrows: recovery usually runs in a fresh
+// process after the original one is lost.
+type EscrowStore interface {
+	// SaveRecord stores a new escrow record. It fails with ErrEscrowExists
+	// when the key ID is already escrowed, so a record is never overwritten.
+	SaveRecord(ctx context.Context, record *EscrowRecord) error
+
+	// GetRecord returns the record for a key ID, or ErrEscrowNotFound
+	GetRecord(ctx context.Context, keyID string) (*EscrowRecord, error)
+
+	// SaveRequest stores a recovery request, replacing one with the same ID
+	SaveRequest(ctx context.Context, req *RecoveryRequest) error
+
+	// GetRequest returns a recovery request, or ErrRecoveryNotFound
+	GetRequest(ctx context.Context, id string) (*RecoveryRequest, error)
+}
+
+// MemoryEscrowStore keeps escrow state in memory. It does not survive a
+// restart and is meant for tests and for wrapping by other stores.
+type MemoryEscrowStore struct {
+	mu       sync.RWMutex
+	records  map[string][]byte
+	requests map[string][]byte
+}
+
+// NewMemoryEscrowStore creates an in-memory escrow store
+func NewMemoryEscrowStore() *MemoryEscrowStore {
+	return &MemoryEscrowStore{records: make(map[string][]byte), requests: make(map[string][]byte)}
+}
+
+// SaveRecord implements EscrowStore
+func (s *MemoryEscrowStore) SaveRecord(_ context.Context, record *EscrowRecord) error {
+	return s.put(s.records, record.KeyID, record, ErrEscrowExists)
+}
+
+// GetRecord implements EscrowStore
+func (s *MemoryEscrowStore) GetRecord(_ context.Context, keyID string) (*EscrowRecord, error) {
+	record := &EscrowRecord{}
+	return record, s.get(s.records, keyID, record, ErrEscrowNotFound)
+}
+
+// SaveRequest implements EscrowStore
+func (s *MemoryEscrowStore) SaveRequest(_ context.Context, req *RecoveryRequest) error {
+	return s.put(s.requests, req.ID, req, nil)
+}
+
+// GetRequest implements EscrowStore
+func (s *MemoryEscrowStore) GetRequest(_ context.Context, id string) (*RecoveryRequest, error) {
+	req := &RecoveryRequest{}
+	return req, s.get(s.requests, id, req, ErrRecoveryNotFound)
+}
+
+// put stores a JSON copy so callers cannot change stored state in place.
+// With a non-nil exists error, an existing entry is kept and exists returned.
+func (s *MemoryEscrowStore) put(m map[string][]byte, id string, v any, exists error) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode escrow state: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := m[id]; ok && exists != nil {
+		return exists
+	}
+	m[id] = data
+	return nil
+}
+
+func (s *MemoryEscrowStore) get(m map[string][]byte, id string, v any, notFound error) error {
+	s.mu.RLock()
+	data, ok := m[id]
+	s.mu.RUnlock()
+	if !ok {
+		return notFound
+	}
+	return json.Unmarshal(data, v)
+}
+
+// FileEscrowStore persists escrow state as JSON files readable only by the
+// owner, one per record and recovery request. Point it at a volume that
+// survives the loss of the deployment, or back it up with the other
+// recovery material.
+type FileEscrowStore struct {
+	dir string
+}
+
+// NewFileEscrowStore creates an escrow store in dir
+func NewFileEscrowStore(dir string) (*FileEscrowStore, error) {
+	for _, sub := range []string{"records", "requests"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create escrow directory: %w", err)
+		}
+	}
+	return &FileEscrowStore{dir: dir}, nil
+}
+
+// path names files by the hex of the ID, so key IDs may contain any
+// character
+func (s *FileEscrowStore) path(kind, id string) string {
+	return filepath.Join(s.dir, kind, hex.EncodeToString([]byte(id))+".json")
+}
+
+// SaveRecord implements EscrowStore
+func (s *FileEscrowStore) SaveRecord(_ context.Context, record *EscrowRecord) error {
+	return s.write(s.path("records", record.KeyID), record, ErrEscrowExists)
+}
+
+// GetRecord implements EscrowStore
+func (s *FileEscrowStore) GetRecord(_ context.Context, keyID string) (*EscrowRecord, error) {
+	record := &EscrowRecord{}
+	return record, s.read(s.path("records", keyID), record, ErrEscrowNotFound)
+}
+
+// SaveRequest implements EscrowStore
+func (s *FileEscrowStore) SaveRequest(_ context.Context, req *RecoveryRequest) error {
+	return s.write(s.path("requests", req.ID), req, nil)
+}
+
+// GetRequest implements EscrowStore
+func (s *FileEscrowStore) GetRequest(_ context.Context, id string) (*RecoveryRequest, error) {
+	req := &RecoveryRequest{}
+	return req, s.read(s.path("requests", id), req, ErrRecoveryNotFound)
+}
+
+// write replaces a file atomically. With a non-nil exists error, an existing
+// file is kept and exists returned; the new file is linked into place, which
+// fails atomically when the name is taken.
+func (s *FileEscrowStore) write(path string, v any, exists error) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode escrow state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".escrow-*")
+	if err != nil {
+		return fmt.Errorf("failed to create escrow file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write escrow file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write escrow file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write escrow file: %w", err)
+	}
+	if exists != nil {
+		if err := os.Link(tmp.Name(), path); err != nil {
+			if errors.Is(err, os.ErrExist) {
+				return exists
+			}
+			return fmt.Errorf("failed to create escrow file: %w", err)
+		}
+		return nil
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace escrow file: %w", err)
+	}
+	return nil
+}
+
+func (s *FileEscrowStore) read(path string, v any, notFound error) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return notFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read escrow file: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode escrow file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,455 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/audit"
+	autherrors "github.com/Gimel-Foundation/gauth/pkg/errors"
+)
+
+// Federation errors
+var (
+	// ErrUnknownProvider indicates no identity provider is registered under the name
+	ErrUnknownProvider = errors.New("unknown identity provider")
+
+	// ErrFederatedLogin indicates the identity provider's response was refused
+	ErrFederatedLogin = errors.New("federated login failed")
+
+	// ErrLoginExpired indicates the callback arrived for an unknown or expired login
+	ErrLoginExpired = errors.New("login state unknown or expired")
+)
+
+// Federation audit event type and actions
+const (
+	TypeFederation       = "federation"
+	ActionFederatedLogin = "federated_login"
+	ActionLoginRefused   = "federated_login_refused"
+)
+
+// ExternalIdentity is a user authenticated by an external identity provider
+type ExternalIdentity struct {
+	// Provider is the name of the provider that authenticated the user
+	Provider string `json:"provider"`
+
+	// Subject is the user's identifier at the provider
+	Subject       string    `json:"sub"`
+	Email         string    `json:"email,omitempty"`
+	EmailVerified bool      `json:"email_verified,omitempty"`
+	Name          string    `json:"name,omitempty"`
+	Username      string    `json:"preferred_username,omitempty"`
+	Groups        []string  `json:"groups,omitempty"`
+	AuthTime      time.Time `json:"auth_time"`
+
+	// Attributes holds every claim or SAML attribute the provider sent
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// FederatedLogin is a login in progress at an external provider
+type FederatedLogin struct {
+	// State identifies the login and must come back to the callback
+	State string
+
+	// Nonce binds the provider's response to this login: the OIDC nonce or
+	// the SAML request ID
+	Nonce string
+
+	// CodeVerifier is the PKCE verifier for OIDC providers
+	CodeVerifier string
+
+	// CallbackURL is where the provider sends the user agent back
+	CallbackURL string
+
+	// ReturnTo is the authorization request to resume after login
+	ReturnTo string
+
+	Provider  string
+	ExpiresAt time.Time
+}
+
+// Provider is an external identity provider the authorization server
+// federates authentication to, such as Okta, Azure AD or Keycloak
+type Provider interface {
+	// Name identifies the provider in callback URLs and subjects
+	Name() string
+
+	// LoginURL returns the URL that starts the login at the provider
+	LoginURL(ctx context.Context, login *FederatedLogin) (string, error)
+
+	// Callback verifies the provider's response to the callback URL and
+	// returns the authenticated user
+	Callback(ctx context.Context, r *http.Request, login *FederatedLogin) (*ExternalIdentity, error)
+}
+
+// FederatedSession is a user signed in through a provider
+type FederatedSession struct {
+	ID        string
+	Subject   string
+	Identity  *ExternalIdentity
+	ExpiresAt time.Time
+}
+
+// FederatedConsentFunc asks a federated user for consent. Like ConsentFunc,
+// it returns a nil decision and nil error when it has written the response.
+type FederatedConsentFunc func(w http.ResponseWriter, r *http.Request, req *ServiceAuthorizationRequest, client *OAuthClient, session *FederatedSession) (*ConsentDecision, error)
+
+// FederationConfig configures a Federation
+type FederationConfig struct {
+	// Providers are the external identity providers
+	Providers []Provider
+
+	// BaseURL is the external URL Handler is served at; provider callbacks
+	// are BaseURL/<provider>/callback
+	BaseURL string
+
+	// DefaultProvider is used when the authorization request has no idp
+	// parameter (default: the first provider)
+	DefaultProvider string
+
+	// Subject maps an external identity to the subject of issued tokens
+	// (default: "<provider>|<subject>")
+	Subject func(ctx context.Context, identity *ExternalIdentity) (string, error)
+
+	// Consent is asked once the user is signed in (optional; without it
+	// everything requested is granted)
+	Consent FederatedConsentFunc
+
+	// SessionTTL is how long a federated sign-in is reused (default: 8h)
+	SessionTTL time.Duration
+
+	// LoginTTL is how long a user may take at the provider (default: 10m)
+	LoginTTL time.Duration
+
+	// CookieName names the session cookie; the login in progress is bound
+	// to the user agent by a cookie with a "_login" suffix
+	// (default: gauth_federation)
+	CookieName string
+
+	// Audit records federated logins (optional)
+	Audit audit.Storage
+}
+
+// Federation brokers authentication to external identity providers while
+// the AuthorizationServer keeps issuing its own tokens. Use its Consent
+// method as AuthorizationServerConfig.Consent and serve Handler at BaseURL.
+type Federation struct {
+	config    FederationConfig
+	providers map[string]Provider
+	secure    bool
+	basePath  string
+
+	mu       sync.Mutex
+	logins   map[string]*FederatedLogin
+	sessions map[string]*FederatedSession
+}
+
+// NewFederation creates a federation broker
+func NewFederation(config FederationConfig) (*Federation, error) {
+	if len(config.Providers) == 0 {
+		return nil, errors.New("at least one identity provider is required")
+	}
+	base, err := url.Parse(config.BaseURL)
+	if err != nil || !base.IsAbs() {
+		return nil, errors.New("an absolute base URL is required")
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	basePath := strings.TrimSuffix(base.Path, "/")
+	if basePath == "" {
+		basePath = "/"
+	}
+	providers := make(map[string]Provider, len(config.Providers))
+	for _, p := range config.Providers {
+		if p.Name() == "" || strings.Contains(p.Name(), "/") {
+			return nil, fmt.Errorf("invalid provider name %q", p.Name())
+		}
+		if _, ok := providers[p.Name()]; ok {
+			return nil, fmt.Errorf("duplicate provider %q", p.Name())
+		}
+		providers[p.Name()] = p
+	}
+	if config.DefaultProvider == "" {
+		config.DefaultProvider = config.Providers[0].Name()
+	}
+	if _, ok := providers[config.DefaultProvider]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, config.DefaultProvider)
+	}
+	if config.Subject == nil {
+		config.Subject = func(_ context.Context, identity *ExternalIdentity) (string, error) {
+			return identity.Provider + "|" + identity.Subject, nil
+		}
+	}
+	if config.SessionTTL <= 0 {
+		config.SessionTTL = 8 * time.Hour
+	}
+	if config.LoginTTL <= 0 {
+		config.LoginTTL = 10 * time.Minute
+	}
+	if config.CookieName == "" {
+		config.CookieName = "gauth_federation"
+	}
+	return &Federation{
+		config:    config,
+		providers: providers,
+		secure:    base.Scheme == "https",
+		basePath:  basePath,
+		logins:    make(map[string]*FederatedLogin),
+		sessions:  make(map[string]*FederatedSession),
+	}, nil
+}
+
+// Consent is a ConsentFunc. A signed-in user is passed on to the configured
+// consent callback; anyone else is sent to the identity provider named by
+// the idp parameter and returns to the authorization request afterwards.
+// prompt=login forces a new sign-in.
+func (f *Federation) Consent(w http.ResponseWriter, r *http.Request, req *ServiceAuthorizationRequest, client *OAuthClient) (*ConsentDecision, error) {
+	if session := f.Session(r); session != nil && req.Prompt != "login" {
+		if f.config.Consent != nil {
+			return f.config.Consent(w, r, req, client, session)
+		}
+		return &ConsentDecision{Subject: session.Subject, AuthTime: session.Identity.AuthTime}, nil
+	}
+
+	name := r.FormValue("idp")
+	if name == "" {
+		name = f.config.DefaultProvider
+	}
+	provider, ok := f.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+	login := &FederatedLogin{
+		CallbackURL: f.config.BaseURL + "/" + name + "/callback",
+		ReturnTo:    returnTo(r),
+		Provider:    name,
+		ExpiresAt:   time.Now().Add(f.config.LoginTTL),
+	}
+	var err error
+	if login.State, err = randomValue(); err != nil {
+		return nil, err
+	}
+	if login.Nonce, err = randomValue(); err != nil {
+		return nil, err
+	}
+	if login.CodeVerifier, err = randomValue(); err != nil {
+		return nil, err
+	}
+	target, err := provider.LoginURL(r.Context(), login)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start login at %s: %w", name, err)
+	}
+
+	f.mu.Lock()
+	f.sweep(time.Now())
+	f.logins[login.State] = login
+	f.mu.Unlock()
+	f.setLoginCookie(w, login.State, login.ExpiresAt)
+	http.Redirect(w, r, target, http.StatusFound)
+	return nil, nil
+}
+
+// Session returns the caller's federated session, or nil
+func (f *Federation) Session(r *http.Request) *FederatedSession {
+	cookie, err := r.Cookie(f.config.CookieName)
+	if err != nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	session, ok := f.sessions[cookie.Value]
+	if !ok || time.Now().After(session.ExpiresAt) {
+		return nil
+	}
+	return session
+}
+
+// Logout ends the caller's federated session
+func (f *Federation) Logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(f.config.CookieName); err == nil {
+		f.mu.Lock()
+		delete(f.sessions, cookie.Value)
+		f.mu.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: f.config.CookieName, Value: "", Path: "/", MaxAge: -1, HttpOnly: true, Secure: f.secure})
+}
+
+// Handler serves the provider callbacks at /<provider>/callback
+func (f *Federation) Handler() http.Handler {
+	return http.HandlerFunc(f.HandleCallback)
+}
+
+// HandleCallback completes a login at a provider, signs the user in and
+// resumes the authorization request
+func (f *Federation) HandleCallback(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/"), "/callback")
+	provider, known := f.providers[name]
+	if !ok || !known {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		autherrors.New(autherrors.ErrInvalidRequest, "method not allowed").
+			WithHTTPInfo(r.URL.Path, r.Method, http.StatusMethodNotAllowed, "").
+			WriteHTTP(w)
+		return
+	}
+	ctx := r.Context()
+	state := r.FormValue("state")
+	if state == "" {
+		state = r.FormValue("RelayState")
+	}
+
+	// The state must come back in the cookie of the user agent that started
+	// the login, or an attacker could complete their own login in a
+	// victim's browser
+	bound := false
+	if cookie, err := r.Cookie(f.loginCookieName()); err == nil && state != "" {
+		bound = subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) == 1
+	}
+	f.setLoginCookie(w, "", time.Time{})
+
+	f.mu.Lock()
+	login, ok := f.logins[state]
+	if bound {
+		delete(f.logins, state)
+	}
+	f.mu.Unlock()
+	if !bound || !ok || login.Provider != name || time.Now().After(login.ExpiresAt) {
+		f.record(ctx, name, nil, "", ErrLoginExpired)
+		autherrors.New(autherrors.ErrInvalidRequest, ErrLoginExpired.Error()).WriteHTTP(w)
+		return
+	}
+
+	identity, err := provider.Callback(ctx, r, login)
+	if err != nil {
+		f.record(ctx, name, nil, "", err)
+		autherrors.New(autherrors.ErrAccessDenied, "login at the identity provider failed").WithCause(err).WriteHTTP(w)
+		return
+	}
+	identity.Provider = name
+	if identity.AuthTime.IsZero() {
+		identity.AuthTime = time.Now()
+	}
+	subject, err := f.config.Subject(ctx, identity)
+	if err == nil && subject == "" {
+		err = errors.New("no subject for the identity")
+	}
+	if err != nil {
+		f.record(ctx, name, identity, "", err)
+		autherrors.New(autherrors.ErrAccessDenied, "identity is not allowed to sign in").WithCause(err).WriteHTTP(w)
+		return
+	}
+	id, err := randomValue()
+	if err != nil {
+		autherrors.New(autherrors.ErrServerError, "failed to create session").WithCause(err).WriteHTTP(w)
+		return
+	}
+	session := &FederatedSession{ID: id, Subject: subject, Identity: identity, ExpiresAt: time.Now().Add(f.config.SessionTTL)}
+	f.mu.Lock()
+	f.sessions[id] = session
+	f.mu.Unlock()
+	f.record(ctx, name, identity, subject, nil)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     f.config.CookieName,
+		Value:    id,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   f.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, login.ReturnTo, http.StatusSeeOther)
+}
+
+// setLoginCookie binds a login to the user agent, or clears the binding when
+// state is empty. SAML responses are posted cross-site, so over HTTPS the
+// cookie is sent with SameSite=None.
+func (f *Federation) setLoginCookie(w http.ResponseWriter, state string, expires time.Time) {
+	cookie := &http.Cookie{
+		Name:     f.loginCookieName(),
+		Value:    state,
+		Path:     f.basePath,
+		HttpOnly: true,
+		Secure:   f.secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if f.secure {
+		cookie.SameSite = http.SameSiteNoneMode
+	}
+	if state == "" {
+		cookie.MaxAge = -1
+	} else {
+		cookie.Expires = expires
+	}
+	http.SetCookie(w, cookie)
+}
+
+func (f *Federation) loginCookieName() string {
+	return f.config.CookieName + "_login"
+}
+
+// sweep drops expired logins and sessions; f.mu must be held
+func (f *Federation) sweep(now time.Time) {
+	for state, login := range f.logins {
+		if now.After(login.ExpiresAt) {
+			delete(f.logins, state)
+		}
+	}
+	for id, session := range f.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(f.sessions, id)
+		}
+	}
+}
+
+func (f *Federation) record(ctx context.Context, provider string, identity *ExternalIdentity, subject string, err error) {
+	if f.config.Audit == nil {
+		return
+	}
+	if subject == "" {
+		subject = "anonymous"
+	}
+	e := audit.NewEntry(TypeFederation).
+		WithActor(subject, audit.ActorUser).
+		WithContext(ctx).
+		WithMetadata("provider", provider)
+	if identity != nil {
+		e = e.WithMetadata("external_subject", identity.Subject)
+	}
+	if err != nil {
+		e = e.WithAction(ActionLoginRefused).WithResult("failure")
+		e.Error = err.Error()
+	} else {
+		e = e.WithAction(ActionFederatedLogin).WithResult(audit.ResultSuccess)
+	}
+	_ = f.config.Audit.Store(ctx, e)
+}
+
+// returnTo rebuilds the authorization request as seen by the user agent,
+// including parameters that were posted. prompt=login is dropped, as the
+// user has signed in by the time it is resumed.
+func returnTo(r *http.Request) string {
+	_ = r.ParseForm()
+	path, _, _ := strings.Cut(r.RequestURI, "?")
+	if !strings.HasPrefix(path, "/") {
+		path = r.URL.Path
+	}
+	params := url.Values{}
+	for k, v := range r.Form {
+		if k != "prompt" || r.Form.Get("prompt") != "login" {
+			params[k] = v
+		}
+	}
+	if len(params) == 0 {
+		return path
+	}
+	return path + "?" + params.Encode()
+}
@@ -0,0 +1,296 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCProviderConfig configures an upstream OpenID Connect provider
+type OIDCProviderConfig struct {
+	// Name identifies the provider, e.g. "okta"
+	Name string
+
+	// Issuer is the provider's issuer URL; ID tokens must carry it as iss
+	Issuer string
+
+	// ClientID and ClientSecret are the credentials registered at the
+	// provider for this authorization server
+	ClientID     string
+	ClientSecret string
+
+	// Scopes are requested at the provider (default: openid profile email)
+	Scopes []string
+
+	// AuthorizationEndpoint, TokenEndpoint and JWKSURI are read from the
+	// issuer's discovery document when empty
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	JWKSURI               string
+
+	// GroupsClaim names the ID token claim listing the user's groups
+	// (default: groups)
+	GroupsClaim string
+
+	// ClockSkew is tolerated on ID token times (default: 1m)
+	ClockSkew time.Duration
+
+	// HTTPClient calls the provider (default: a client with a 10s timeout)
+	HTTPClient *http.Client
+}
+
+// OIDCProvider federates to an OpenID Connect provider such as Okta, Azure
+// AD or Keycloak with the authorization code flow and PKCE
+type OIDCProvider struct {
+	config OIDCProviderConfig
+
+	mu   sync.Mutex
+	keys *token.KeySet
+}
+
+// NewOIDCProvider creates an OIDC provider, fetching the issuer's discovery
+// document when endpoints are not configured
+func NewOIDCProvider(ctx context.Context, config OIDCProviderConfig) (*OIDCProvider, error) {
+	if config.Name == "" || config.Issuer == "" || config.ClientID == "" {
+		return nil, errors.New("name, issuer and client ID are required")
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+	} else if !contains(config.Scopes, ScopeOpenID) {
+		config.Scopes = append([]string{ScopeOpenID}, config.Scopes...)
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	if config.ClockSkew <= 0 {
+		config.ClockSkew = time.Minute
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	p := &OIDCProvider{config: config}
+	if config.AuthorizationEndpoint == "" || config.TokenEndpoint == "" || config.JWKSURI == "" {
+		var doc DiscoveryDocument
+		discovery := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+		if err := p.getJSON(ctx, discovery, &doc); err != nil {
+			return nil, fmt.Errorf("failed to fetch discovery document: %w", err)
+		}
+		if doc.Issuer != config.Issuer {
+			return nil, fmt.Errorf("discovery document is for issuer %q, not %q", doc.Issuer, config.Issuer)
+		}
+		if p.config.AuthorizationEndpoint == "" {
+			p.config.AuthorizationEndpoint = doc.AuthorizationEndpoint
+		}
+		if p.config.TokenEndpoint == "" {
+			p.config.TokenEndpoint = doc.TokenEndpoint
+		}
+		if p.config.JWKSURI == "" {
+			p.config.JWKSURI = doc.JWKSURI
+		}
+	}
+	if p.config.AuthorizationEndpoint == "" || p.config.TokenEndpoint == "" || p.config.JWKSURI == "" {
+		return nil, errors.New("provider endpoints are incomplete")
+	}
+	return p, nil
+}
+
+// Name returns the provider name
+func (p *OIDCProvider) Name() string {
+	return p.config.Name
+}
+
+// LoginURL returns the provider's authorization endpoint with the request
+func (p *OIDCProvider) LoginURL(_ context.Context, login *FederatedLogin) (string, error) {
+	target, err := url.Parse(p.config.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	q := target.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.config.ClientID)
+	q.Set("redirect_uri", login.CallbackURL)
+	q.Set("scope", strings.Join(p.config.Scopes, " "))
+	q.Set("state", login.State)
+	q.Set("nonce", login.Nonce)
+	q.Set("code_challenge", PKCEChallenge(login.CodeVerifier))
+	q.Set("code_challenge_method", PKCEMethodS256)
+	target.RawQuery = q.Encode()
+	return target.String(), nil
+}
+
+// Callback redeems the authorization code and verifies the ID token
+func (p *OIDCProvider) Callback(ctx context.Context, r *http.Request, login *FederatedLogin) (*ExternalIdentity, error) {
+	if e := r.FormValue("error"); e != "" {
+		return nil, fmt.Errorf("%w: provider returned %s: %s", ErrFederatedLogin, e, r.FormValue("error_description"))
+	}
+	code := r.FormValue("code")
+	if code == "" {
+		return nil, fmt.Errorf("%w: no authorization code", ErrFederatedLogin)
+	}
+	form := url.Values{
+		"grant_type":    {GrantTypeAuthCode},
+		"code":          {code},
+		"redirect_uri":  {login.CallbackURL},
+		"code_verifier": {login.CodeVerifier},
+	}
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeem authorization code: %w", err)
+	}
+	defer resp.Body.Close()
+	var tr OAuth2TokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tr); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || tr.IDToken == "" {
+		return nil, fmt.Errorf("%w: token endpoint returned %d without an ID token", ErrFederatedLogin, resp.StatusCode)
+	}
+	claims, err := p.verifyIDToken(ctx, tr.IDToken, login.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	return p.identity(claims), nil
+}
+
+// verifyIDToken checks the ID token signature against the provider's JWKS,
+// refetching it once for an unknown key ID, then its claims
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, raw, nonce string) (jwt.MapClaims, error) {
+	var parsed *jwt.Token
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		var keys *token.KeySet
+		if keys, err = p.keySet(ctx, attempt > 0); err != nil {
+			return nil, err
+		}
+		parsed, err = jwt.Parse(raw, func(t *jwt.Token) (interface{}, error) {
+			kid, _ := t.Header["kid"].(string)
+			pub, alg, err := keys.Lookup(kid)
+			if err != nil {
+				return nil, err
+			}
+			if t.Method.Alg() != string(alg) {
+				return nil, fmt.Errorf("unexpected signing algorithm %s", t.Method.Alg())
+			}
+			return pub, nil
+		}, jwt.WithIssuer(p.config.Issuer), jwt.WithAudience(p.config.ClientID),
+			jwt.WithExpirationRequired(), jwt.WithLeeway(p.config.ClockSkew))
+		if err == nil || !errors.Is(err, token.ErrKeyNotFound) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid ID token: %v", ErrFederatedLogin, err)
+	}
+	claims := parsed.Claims.(jwt.MapClaims)
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, fmt.Errorf("%w: ID token nonce does not match", ErrFederatedLogin)
+	}
+	if aud, _ := claims.GetAudience(); len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.config.ClientID {
+			return nil, fmt.Errorf("%w: ID token was issued to another party", ErrFederatedLogin)
+		}
+	}
+	if sub, _ := claims.GetSubject(); sub == "" {
+		return nil, fmt.Errorf("%w: ID token has no subject", ErrFederatedLogin)
+	}
+	return claims, nil
+}
+
+// keySet returns the provider's signing keys, fetching them when missing or
+// when refresh is set
+func (p *OIDCProvider) keySet(ctx context.Context, refresh bool) (*token.KeySet, error) {
+	p.mu.Lock()
+	keys := p.keys
+	p.mu.Unlock()
+	if keys != nil && !refresh {
+		return keys, nil
+	}
+	var doc token.JWKS
+	if err := p.getJSON(ctx, p.config.JWKSURI, &doc); err != nil {
+		return nil, fmt.Errorf("failed to fetch provider keys: %w", err)
+	}
+	signing := doc.Keys[:0]
+	for _, k := range doc.Keys {
+		if k.Use == "" || k.Use == "sig" {
+			signing = append(signing, k)
+		}
+	}
+	doc.Keys = signing
+	keys, err := doc.KeySet()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+	return keys, nil
+}
+
+// identity maps standard ID token claims to an ExternalIdentity
+func (p *OIDCProvider) identity(claims jwt.MapClaims) *ExternalIdentity {
+	str := func(name string) string {
+		v, _ := claims[name].(string)
+		return v
+	}
+	identity := &ExternalIdentity{
+		Subject:    str("sub"),
+		Email:      str("email"),
+		Name:       str("name"),
+		Username:   str("preferred_username"),
+		Attributes: map[string]interface{}(claims),
+	}
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	if authTime, ok := claims["auth_time"].(float64); ok {
+		identity.AuthTime = time.Unix(int64(authTime), 0)
+	}
+	switch groups := claims[p.config.GroupsClaim].(type) {
+	case []interface{}:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				identity.Groups = append(identity.Groups, s)
+			}
+		}
+	case string:
+		identity.Groups = []string{groups}
+	}
+	return identity
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, target string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", target, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,331 @@
+package auth
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SAML 2.0 identifiers
+const (
+	samlPOSTBinding = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	samlStatusOK    = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	samlBearer      = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+
+	// SAMLNameIDUnspecified lets the identity provider choose the NameID format
+	SAMLNameIDUnspecified = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+
+	// SAMLNameIDEmail requests the user's email address as NameID
+	SAMLNameIDEmail = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+
+	// SAMLNameIDPersistent requests a stable, opaque NameID
+	SAMLNameIDPersistent = "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent"
+)
+
+// ErrNoSAMLVerifier indicates a SAML provider was configured without a
+// signature verifier
+var ErrNoSAMLVerifier = errors.New("a SAML signature verifier is required")
+
+// SAMLVerifier checks the XML signature of a SAML response against the
+// identity provider's signing certificate and returns the signed Assertion
+// element. No verifier is built in: implementations wrap an XML-DSig library
+// that canonicalizes the document, such as goxmldsig. Only the returned
+// assertion is trusted.
+type SAMLVerifier interface {
+	VerifyAssertion(response []byte) (assertion []byte, err error)
+}
+
+// SAMLVerifierFunc adapts a function to SAMLVerifier
+type SAMLVerifierFunc func(response []byte) ([]byte, error)
+
+// VerifyAssertion calls f
+func (f SAMLVerifierFunc) VerifyAssertion(response []byte) ([]byte, error) {
+	return f(response)
+}
+
+// SAMLProviderConfig configures an upstream SAML 2.0 identity provider
+type SAMLProviderConfig struct {
+	// Name identifies the provider, e.g. "azure-ad"
+	Name string
+
+	// EntityID is this service provider's entity ID, the audience of
+	// assertions
+	EntityID string
+
+	// IdPEntityID is the identity provider's entity ID, the issuer of
+	// assertions
+	IdPEntityID string
+
+	// SSOURL is the identity provider's HTTP-Redirect single sign-on URL
+	SSOURL string
+
+	// Verifier checks assertion signatures (required)
+	Verifier SAMLVerifier
+
+	// NameIDFormat is requested from the identity provider
+	// (default: SAMLNameIDUnspecified)
+	NameIDFormat string
+
+	// EmailAttribute, NameAttribute and GroupsAttribute name the attributes
+	// mapped to the identity (default: email, name and groups)
+	EmailAttribute  string
+	NameAttribute   string
+	GroupsAttribute string
+
+	// ClockSkew is tolerated on assertion times (default: 2m)
+	ClockSkew time.Duration
+}
+
+// SAMLProvider federates to a SAML 2.0 identity provider with the
+// HTTP-Redirect binding for requests and HTTP-POST for responses
+type SAMLProvider struct {
+	config SAMLProviderConfig
+}
+
+// NewSAMLProvider creates a SAML provider
+func NewSAMLProvider(config SAMLProviderConfig) (*SAMLProvider, error) {
+	if config.Name == "" || config.EntityID == "" || config.IdPEntityID == "" || config.SSOURL == "" {
+		return nil, errors.New("name, entity IDs and SSO URL are required")
+	}
+	if f, ok := config.Verifier.(SAMLVerifierFunc); config.Verifier == nil || (ok && f == nil) {
+		return nil, ErrNoSAMLVerifier
+	}
+	if config.NameIDFormat == "" {
+		config.NameIDFormat = SAMLNameIDUnspecified
+	}
+	if config.EmailAttribute == "" {
+		config.EmailAttribute = "email"
+	}
+	if config.NameAttribute == "" {
+		config.NameAttribute = "name"
+	}
+	if config.GroupsAttribute == "" {
+		config.GroupsAttribute = "groups"
+	}
+	if config.ClockSkew <= 0 {
+		config.ClockSkew = 2 * time.Minute
+	}
+	return &SAMLProvider{config: config}, nil
+}
+
+// Name returns the provider name
+func (p *SAMLProvider) Name() string {
+	return p.config.Name
+}
+
+// samlAuthnRequest is a SAML AuthnRequest (SAML Core §3.4.1)
+type samlAuthnRequest struct {
+	XMLName                     xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol AuthnRequest"`
+	ID                          string   `xml:"ID,attr"`
+	Version                     string   `xml:"Version,attr"`
+	IssueInstant                string   `xml:"IssueInstant,attr"`
+	Destination                 string   `xml:"Destination,attr"`
+	AssertionConsumerServiceURL string   `xml:"AssertionConsumerServiceURL,attr"`
+	ProtocolBinding             string   `xml:"ProtocolBinding,attr"`
+	Issuer                      struct {
+		XMLName xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+		Value   string   `xml:",chardata"`
+	}
+	NameIDPolicy struct {
+		XMLName     xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol NameIDPolicy"`
+		Format      string   `xml:"Format,attr"`
+		AllowCreate bool     `xml:"AllowCreate,attr"`
+	}
+}
+
+// LoginURL returns the SSO URL with a deflated AuthnRequest whose ID is the
+// login nonce; the state travels as RelayState
+func (p *SAMLProvider) LoginURL(_ context.Context, login *FederatedLogin) (string, error) {
+	req := samlAuthnRequest{
+		ID:                          samlID(login.Nonce),
+		Version:                     "2.0",
+		IssueInstant:                time.Now().UTC().Format(time.RFC3339),
+		Destination:                 p.config.SSOURL,
+		AssertionConsumerServiceURL: login.CallbackURL,
+		ProtocolBinding:             samlPOSTBinding,
+	}
+	req.Issuer.Value = p.config.EntityID
+	req.NameIDPolicy.Format = p.config.NameIDFormat
+	req.NameIDPolicy.AllowCreate = true
+	raw, err := xml.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	target, err := url.Parse(p.config.SSOURL)
+	if err != nil {
+		return "", err
+	}
+	q := target.Query()
+	q.Set("SAMLRequest", base64.StdEncoding.EncodeToString(buf.Bytes()))
+	q.Set("RelayState", login.State)
+	target.RawQuery = q.Encode()
+	return target.String(), nil
+}
+
+// samlResponse holds the unsigned parts of a SAML Response read before the
+// assertion is verified
+type samlResponse struct {
+	InResponseTo string `xml:"InResponseTo,attr"`
+	Status       struct {
+		StatusCode struct {
+			Value string `xml:"Value,attr"`
+		} `xml:"StatusCode"`
+		StatusMessage string `xml:"StatusMessage"`
+	} `xml:"Status"`
+}
+
+// samlAssertion is the subset of a SAML Assertion (SAML Core §2.3.3) that
+// is checked and mapped
+type samlAssertion struct {
+	XMLName xml.Name `xml:"Assertion"`
+	Issuer  string   `xml:"Issuer"`
+	Subject struct {
+		NameID struct {
+			Format string `xml:"Format,attr"`
+			Value  string `xml:",chardata"`
+		} `xml:"NameID"`
+		Confirmations []struct {
+			Method string `xml:"Method,attr"`
+			Data   struct {
+				InResponseTo string    `xml:"InResponseTo,attr"`
+				Recipient    string    `xml:"Recipient,attr"`
+				NotOnOrAfter time.Time `xml:"NotOnOrAfter,attr"`
+			} `xml:"SubjectConfirmationData"`
+		} `xml:"SubjectConfirmation"`
+	} `xml:"Subject"`
+	Conditions struct {
+		NotBefore    time.Time `xml:"NotBefore,attr"`
+		NotOnOrAfter time.Time `xml:"NotOnOrAfter,attr"`
+		Audiences    []string  `xml:"AudienceRestriction>Audience"`
+	} `xml:"Conditions"`
+	AuthnStatement struct {
+		AuthnInstant time.Time `xml:"AuthnInstant,attr"`
+	} `xml:"AuthnStatement"`
+	Attributes []struct {
+		Name   string   `xml:"Name,attr"`
+		Values []string `xml:"AttributeValue"`
+	} `xml:"AttributeStatement>Attribute"`
+}
+
+// Callback verifies the posted SAML response and maps its assertion
+func (p *SAMLProvider) Callback(_ context.Context, r *http.Request, login *FederatedLogin) (*ExternalIdentity, error) {
+	encoded := r.PostFormValue("SAMLResponse")
+	if encoded == "" {
+		return nil, fmt.Errorf("%w: no SAML response", ErrFederatedLogin)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed SAML response: %v", ErrFederatedLogin, err)
+	}
+	var resp samlResponse
+	if err := xml.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("%w: malformed SAML response: %v", ErrFederatedLogin, err)
+	}
+	if resp.Status.StatusCode.Value != samlStatusOK {
+		return nil, fmt.Errorf("%w: identity provider returned %s %s", ErrFederatedLogin,
+			resp.Status.StatusCode.Value, resp.Status.StatusMessage)
+	}
+	if resp.InResponseTo != "" && resp.InResponseTo != samlID(login.Nonce) {
+		return nil, fmt.Errorf("%w: response is for another request", ErrFederatedLogin)
+	}
+	signed, err := p.config.Verifier.VerifyAssertion(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: assertion signature: %v", ErrFederatedLogin, err)
+	}
+	var a samlAssertion
+	if err := xml.Unmarshal(signed, &a); err != nil {
+		return nil, fmt.Errorf("%w: malformed assertion: %v", ErrFederatedLogin, err)
+	}
+	if err := p.checkAssertion(&a, login, time.Now()); err != nil {
+		return nil, err
+	}
+	return p.identity(&a), nil
+}
+
+// checkAssertion applies the SAML Web Browser SSO profile checks (SAML
+// Profiles §4.1.4.3)
+func (p *SAMLProvider) checkAssertion(a *samlAssertion, login *FederatedLogin, now time.Time) error {
+	skew := p.config.ClockSkew
+	switch {
+	case strings.TrimSpace(a.Issuer) != p.config.IdPEntityID:
+		return fmt.Errorf("%w: assertion issued by %q", ErrFederatedLogin, a.Issuer)
+	case strings.TrimSpace(a.Subject.NameID.Value) == "":
+		return fmt.Errorf("%w: assertion has no NameID", ErrFederatedLogin)
+	case !a.Conditions.NotBefore.IsZero() && now.Add(skew).Before(a.Conditions.NotBefore):
+		return fmt.Errorf("%w: assertion is not yet valid", ErrFederatedLogin)
+	case !a.Conditions.NotOnOrAfter.IsZero() && !now.Add(-skew).Before(a.Conditions.NotOnOrAfter):
+		return fmt.Errorf("%w: assertion has expired", ErrFederatedLogin)
+	case len(a.Conditions.Audiences) == 0:
+		return fmt.Errorf("%w: assertion has no audience restriction", ErrFederatedLogin)
+	case !contains(a.Conditions.Audiences, p.config.EntityID):
+		return fmt.Errorf("%w: assertion is for another audience", ErrFederatedLogin)
+	}
+	for _, c := range a.Subject.Confirmations {
+		if c.Method != samlBearer {
+			continue
+		}
+		d := c.Data
+		if d.InResponseTo == samlID(login.Nonce) && (d.Recipient == "" || d.Recipient == login.CallbackURL) &&
+			(d.NotOnOrAfter.IsZero() || now.Add(-skew).Before(d.NotOnOrAfter)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: no bearer confirmation for this login", ErrFederatedLogin)
+}
+
+// identity maps the assertion's NameID and attributes
+func (p *SAMLProvider) identity(a *samlAssertion) *ExternalIdentity {
+	identity := &ExternalIdentity{
+		Subject:    strings.TrimSpace(a.Subject.NameID.Value),
+		AuthTime:   a.AuthnStatement.AuthnInstant,
+		Attributes: make(map[string]interface{}, len(a.Attributes)),
+	}
+	for _, attr := range a.Attributes {
+		values := make([]string, len(attr.Values))
+		for i, v := range attr.Values {
+			values[i] = strings.TrimSpace(v)
+		}
+		identity.Attributes[attr.Name] = values
+		switch attr.Name {
+		case p.config.GroupsAttribute:
+			identity.Groups = append(identity.Groups, values...)
+		case p.config.EmailAttribute:
+			if len(values) > 0 {
+				identity.Email = values[0]
+			}
+		case p.config.NameAttribute:
+			if len(values) > 0 {
+				identity.Name = values[0]
+			}
+		}
+	}
+	if identity.Email == "" && a.Subject.NameID.Format == SAMLNameIDEmail {
+		identity.Email = identity.Subject
+	}
+	return identity
+}
+
+// samlID turns a login nonce into an XML ID, which must not start with a
+// digit
+func samlID(nonce string) string {
+	return "_" + nonce
+}
@@ -0,0 +1,284 @@
+package auth
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+func TestFederation(t *testing.T) {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+
+	// Upstream OpenID provider
+	idpKeys := token.NewKeySet()
+	if _, err := idpKeys.Add(key, token.RS256, "okta-1"); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+	var mu sync.Mutex
+	nonces := make(map[string]string) // code -> nonce
+	idp := httptest.NewServer(nil)
+	defer idp.Close()
+	idpMux := http.NewServeMux()
+	idpMux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(DiscoveryDocument{
+			Issuer: idp.URL, AuthorizationEndpoint: idp.URL + "/authorize", TokenEndpoint: idp.URL + "/token", JWKSURI: idp.URL + "/keys",
+		})
+	})
+	idpMux.Handle("/keys", idpKeys.Handler(0))
+	idpMux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, _ := r.BasicAuth(); id != "gauth" || secret != "s3cret" {
+			http.Error(w, "bad client", http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		nonce, ok := nonces[r.PostFormValue("code")]
+		mu.Unlock()
+		if !ok || r.PostFormValue("code_verifier") == "" {
+			http.Error(w, "bad code", http.StatusBadRequest)
+			return
+		}
+		idToken, _, _ := idpKeys.SignClaims(map[string]interface{}{
+			"iss": idp.URL, "aud": "gauth", "sub": "00u1", "nonce": nonce, "email": "alice@example.com",
+			"groups": []string{"finance"}, "iat": time.Now().Unix(), "exp": time.Now().Add(time.Minute).Unix(),
+		})
+		_ = json.NewEncoder(w).Encode(OAuth2TokenResponse{AccessToken: "upstream", TokenType: "Bearer", IDToken: idToken})
+	})
+	idp.Config.Handler = idpMux
+
+	okta, err := NewOIDCProvider(ctx, OIDCProviderConfig{Name: "okta", Issuer: idp.URL, ClientID: "gauth", ClientSecret: "s3cret"})
+	if err != nil {
+		t.Fatalf("NewOIDCProvider() error: %v", err)
+	}
+
+	// SAML provider whose verifier accepts the embedded assertion
+	var assertion string
+	saml, err := NewSAMLProvider(SAMLProviderConfig{
+		Name: "adfs", EntityID: "https://auth.example.com", IdPEntityID: "https://adfs.example.com",
+		SSOURL: "https://adfs.example.com/sso", NameIDFormat: SAMLNameIDEmail,
+		Verifier: SAMLVerifierFunc(func([]byte) ([]byte, error) {
+			if assertion == "" {
+				return nil, errors.New("signature mismatch")
+			}
+			return []byte(assertion), nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewSAMLProvider() error: %v", err)
+	}
+
+	// GAuth authorization server brokering to both
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	federation, err := NewFederation(FederationConfig{Providers: []Provider{okta, saml}, BaseURL: ts.URL + "/federation"})
+	if err != nil {
+		t.Fatalf("NewFederation() error: %v", err)
+	}
+	clients := NewMemoryClientRegistry()
+	const redirectURI = "https://app.example.com/callback"
+	if _, err := clients.Register(OAuthClient{ID: "spa", RedirectURIs: []string{redirectURI}}, false); err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+	tokens := token.NewService(token.Config{SigningKey: key, ValidityPeriod: time.Hour}, token.NewMemoryStore(time.Hour))
+	server, err := NewAuthorizationServer(AuthorizationServerConfig{Tokens: tokens, Clients: clients, Consent: federation.Consent})
+	if err != nil {
+		t.Fatalf("NewAuthorizationServer() error: %v", err)
+	}
+	mux.Handle("/federation/", http.StripPrefix("/federation", federation.Handler()))
+	mux.Handle("/", server.Handler())
+
+	noFollow := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	verifier := strings.Repeat("f", 50)
+	authorizeURL := func(idpName string) string {
+		return ts.URL + "/authorize?" + url.Values{
+			"client_id": {"spa"}, "response_type": {"code"}, "redirect_uri": {redirectURI}, "scope": {"read"},
+			"state": {"s"}, "code_challenge": {PKCEChallenge(verifier)}, "code_challenge_method": {PKCEMethodS256},
+			"idp": {idpName},
+		}.Encode()
+	}
+	do := func(t *testing.T, req *http.Request, cookies ...*http.Cookie) *http.Response {
+		t.Helper()
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := noFollow.Do(req)
+		if err != nil {
+			t.Fatalf("request error: %v", err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+	// finish resumes the authorization request with the session cookie and
+	// redeems the code, returning the subject of the issued token
+	finish := func(t *testing.T, callback *http.Response) string {
+		t.Helper()
+		if callback.StatusCode != http.StatusSeeOther || len(callback.Cookies()) == 0 {
+			t.Fatalf("Expected a session and a redirect, got %d", callback.StatusCode)
+		}
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+callback.Header.Get("Location"), nil)
+		resp := do(t, req, callback.Cookies()...)
+		loc, _ := url.Parse(resp.Header.Get("Location"))
+		if loc.Query().Get("code") == "" {
+			t.Fatalf("Expected a code for the client, got %s", loc)
+		}
+		tr, err := http.PostForm(ts.URL+"/token", url.Values{
+			"grant_type": {GrantTypeAuthCode}, "code": {loc.Query().Get("code")}, "redirect_uri": {redirectURI},
+			"client_id": {"spa"}, "code_verifier": {verifier},
+		})
+		if err != nil {
+			t.Fatalf("token error: %v", err)
+		}
+		defer tr.Body.Close()
+		var body OAuth2TokenResponse
+		_ = json.NewDecoder(tr.Body).Decode(&body)
+		issued, err := tokens.Parse(ctx, body.AccessToken)
+		if err != nil {
+			t.Fatalf("Parse() error: %v", err)
+		}
+		return issued.Subject
+	}
+
+	t.Run("OIDC Broker", func(t *testing.T) {
+		resp := do(t, mustRequest(t, http.MethodGet, authorizeURL("okta"), nil))
+		login, _ := url.Parse(resp.Header.Get("Location"))
+		if !strings.HasPrefix(login.String(), idp.URL+"/authorize") || login.Query().Get("code_challenge") == "" {
+			t.Fatalf("Expected a redirect to the provider, got %s", login)
+		}
+		q := login.Query()
+		mu.Lock()
+		nonces["upstream-code"] = q.Get("nonce")
+		nonces["replayed-nonce"] = "other"
+		mu.Unlock()
+
+		bad := do(t, mustRequest(t, http.MethodGet, q.Get("redirect_uri")+"?"+url.Values{"code": {"replayed-nonce"}, "state": {q.Get("state")}}.Encode(), nil), resp.Cookies()...)
+		if bad.StatusCode == http.StatusSeeOther {
+			t.Fatal("Expected an ID token with another nonce to be refused")
+		}
+		again := do(t, mustRequest(t, http.MethodGet, authorizeURL("okta"), nil))
+		if again.StatusCode != http.StatusFound {
+			t.Fatalf("Expected a new login, got %d", again.StatusCode)
+		}
+		login, _ = url.Parse(again.Header.Get("Location"))
+		q = login.Query()
+		mu.Lock()
+		nonces["upstream-code"] = q.Get("nonce")
+		mu.Unlock()
+		callbackURL := q.Get("redirect_uri") + "?" + url.Values{"code": {"upstream-code"}, "state": {q.Get("state")}}.Encode()
+
+		// Login CSRF: the state is only accepted from the browser that started the login
+		if forged := do(t, mustRequest(t, http.MethodGet, callbackURL, nil)); forged.StatusCode == http.StatusSeeOther {
+			t.Fatal("Expected a callback without the login cookie to be refused")
+		}
+		callback := do(t, mustRequest(t, http.MethodGet, callbackURL, nil), again.Cookies()...)
+		if subject := finish(t, callback); subject != "okta|00u1" {
+			t.Errorf("Expected a GAuth token for the federated user, got subject %q", subject)
+		}
+		if replay := do(t, mustRequest(t, http.MethodGet, callbackURL, nil), again.Cookies()...); replay.StatusCode == http.StatusSeeOther {
+			t.Error("Expected a used login state to be refused")
+		}
+	})
+
+	t.Run("SAML Broker", func(t *testing.T) {
+		resp := do(t, mustRequest(t, http.MethodGet, authorizeURL("adfs"), nil))
+		login, _ := url.Parse(resp.Header.Get("Location"))
+		deflated, _ := base64.StdEncoding.DecodeString(login.Query().Get("SAMLRequest"))
+		raw, _ := io.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+		var authn samlAuthnRequest
+		if err := xml.Unmarshal(raw, &authn); err != nil || authn.AssertionConsumerServiceURL != ts.URL+"/federation/adfs/callback" {
+			t.Fatalf("Unexpected AuthnRequest %s, %v", raw, err)
+		}
+		post := func(audience string, cookies []*http.Cookie) *http.Response {
+			now := time.Now().UTC()
+			restriction := ""
+			if audience != "" {
+				restriction = "<saml:AudienceRestriction><saml:Audience>" + audience + "</saml:Audience></saml:AudienceRestriction>"
+			}
+			assertion = fmt.Sprintf(`<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">
+<saml:Issuer>https://adfs.example.com</saml:Issuer>
+<saml:Subject><saml:NameID Format="%s">alice@example.com</saml:NameID>
+<saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer"><saml:SubjectConfirmationData InResponseTo="%s" Recipient="%s" NotOnOrAfter="%s"/></saml:SubjectConfirmation></saml:Subject>
+<saml:Conditions NotBefore="%s" NotOnOrAfter="%s">%s</saml:Conditions>
+<saml:AttributeStatement><saml:Attribute Name="groups"><saml:AttributeValue>finance</saml:AttributeValue></saml:Attribute></saml:AttributeStatement>
+</saml:Assertion>`, SAMLNameIDEmail, authn.ID, authn.AssertionConsumerServiceURL, now.Add(5*time.Minute).Format(time.RFC3339),
+				now.Add(-time.Minute).Format(time.RFC3339), now.Add(5*time.Minute).Format(time.RFC3339), restriction)
+			response := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" InResponseTo="` + authn.ID + `">` +
+				`<samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>` + assertion + `</samlp:Response>`
+			form := url.Values{"SAMLResponse": {base64.StdEncoding.EncodeToString([]byte(response))}, "RelayState": {login.Query().Get("RelayState")}}
+			req := mustRequest(t, http.MethodPost, authn.AssertionConsumerServiceURL, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return do(t, req, cookies...)
+		}
+		restart := func() *http.Response {
+			resp := do(t, mustRequest(t, http.MethodGet, authorizeURL("adfs"), nil))
+			login, _ = url.Parse(resp.Header.Get("Location"))
+			deflated, _ := base64.StdEncoding.DecodeString(login.Query().Get("SAMLRequest"))
+			raw, _ := io.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+			_ = xml.Unmarshal(raw, &authn)
+			return resp
+		}
+		if resp := post("https://other-sp.example.com", resp.Cookies()); resp.StatusCode == http.StatusSeeOther {
+			t.Fatal("Expected an assertion for another audience to be refused")
+		}
+		resp = restart()
+		if resp := post("", resp.Cookies()); resp.StatusCode == http.StatusSeeOther {
+			t.Fatal("Expected an assertion without an audience restriction to be refused")
+		}
+		resp = restart()
+		if subject := finish(t, post("https://auth.example.com", resp.Cookies())); subject != "adfs|alice@example.com" {
+			t.Errorf("Expected a GAuth token for the SAML user, got subject %q", subject)
+		}
+	})
+
+	t.Run("SAML Verifier Required", func(t *testing.T) {
+		config := SAMLProviderConfig{
+			Name: "adfs", EntityID: "https://auth.example.com", IdPEntityID: "https://adfs.example.com",
+			SSOURL: "https://adfs.example.com/sso",
+		}
+		if _, err := NewSAMLProvider(config); !errors.Is(err, ErrNoSAMLVerifier) {
+			t.Errorf("Expected ErrNoSAMLVerifier, got %v", err)
+		}
+		config.Verifier = SAMLVerifierFunc(nil)
+		if _, err := NewSAMLProvider(config); !errors.Is(err, ErrNoSAMLVerifier) {
+			t.Errorf("Expected ErrNoSAMLVerifier for a nil func, got %v", err)
+		}
+	})
+
+	t.Run("Unknown Provider", func(t *testing.T) {
+		resp := do(t, mustRequest(t, http.MethodGet, authorizeURL("nope"), nil))
+		loc, _ := url.Parse(resp.Header.Get("Location"))
+		if loc.Query().Get("error") != "server_error" {
+			t.Errorf("Expected the authorization request to fail, got %s", loc)
+		}
+	})
+}
+
+func mustRequest(t *testing.T, method, target string, body io.Reader) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		t.Fatalf("NewRequest() error: %v", err)
+	}
+	return req
+}
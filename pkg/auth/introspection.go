@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// IntrospectionResponse is an RFC 7662 token introspection response.
+// Metadata carries token application data such as power-of-attorney limits.
+type IntrospectionResponse struct {
+	Active    bool              `json:"active"`
+	Scope     string            `json:"scope,omitempty"`
+	ClientID  string            `json:"client_id,omitempty"`
+	TokenType string            `json:"token_type,omitempty"`
+	Exp       int64             `json:"exp,omitempty"`
+	Iat       int64             `json:"iat,omitempty"`
+	Nbf       int64             `json:"nbf,omitempty"`
+	Sub       string            `json:"sub,omitempty"`
+	Aud       []string          `json:"aud,omitempty"`
+	Iss       string            `json:"iss,omitempty"`
+	Jti       string            `json:"jti,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+
+	// Cnf is the RFC 8705 confirmation of a certificate-bound token
+	Cnf map[string]string `json:"cnf,omitempty"`
+
+	// RevocationReason explains why an inactive token was revoked. It is an
+	// extension member, returned only to callers allowed to see
+	// "revocation_reason".
+	RevocationReason token.RevocationReason `json:"revocation_reason,omitempty"`
+}
+
+// NewIntrospectionResponse builds the full introspection response for a token
+func NewIntrospectionResponse(t *token.Token) *IntrospectionResponse {
+	if t != nil && t.RevocationStatus != nil {
+		return &IntrospectionResponse{Active: false, RevocationReason: t.RevocationStatus.Reason}
+	}
+	if t == nil || time.Now().After(t.ExpiresAt) {
+		return &IntrospectionResponse{Active: false}
+	}
+
+	resp := &IntrospectionResponse{
+		Active:    true,
+		Scope:     strings.Join(t.Scopes, " "),
+		TokenType: string(t.Type),
+		Exp:       t.ExpiresAt.Unix(),
+		Iat:       t.IssuedAt.Unix(),
+		Nbf:       t.NotBefore.Unix(),
+		Sub:       t.Subject,
+		Aud:       t.Audience,
+		Iss:       t.Issuer,
+		Jti:       t.ID,
+	}
+	if t.Metadata != nil {
+		resp.ClientID = t.Metadata.AppID
+		if len(t.Metadata.AppData) > 0 {
+			resp.Metadata = make(map[string]string, len(t.Metadata.AppData))
+			for k, v := range t.Metadata.AppData {
+				resp.Metadata[k] = v
+			}
+		}
+	}
+	if thumbprint := token.CertThumbprintOf(t); thumbprint != "" {
+		resp.Cnf = map[string]string{token.AppDataCertThumbprint: thumbprint}
+	}
+	return resp
+}
+
+// MaskRule grants callers holding Scope visibility of Fields in
+// introspection responses. Field names are the RFC 7662 member names
+// ("scope", "sub", "exp", ...), "metadata.<key>" for a single metadata entry,
+// "metadata.*" for all metadata, or "*" for everything.
+type MaskRule struct {
+	Scope  string
+	Fields []string
+}
+
+// IntrospectionMasker shapes introspection responses per caller, based on
+// the scopes of the caller's own token. The caller's token is validated
+// before its scopes are trusted. "active" is always visible.
+type IntrospectionMasker struct {
+	defaultFields []string
+	rules         []MaskRule
+}
+
+// NewIntrospectionMasker creates a masker. defaultFields are visible to every
+// authenticated caller.
+func NewIntrospectionMasker(defaultFields []string, rules ...MaskRule) *IntrospectionMasker {
+	return &IntrospectionMasker{defaultFields: defaultFields, rules: rules}
+}
+
+// Introspect validates callerToken with svc, looks up tokenStr and returns
+// the response masked for the caller. An invalid caller token fails with
+// ErrInvalidToken.
+func (m *IntrospectionMasker) Introspect(ctx context.Context, svc Service, callerToken, tokenStr string) (*IntrospectionResponse, error) {
+	caller, err := m.caller(ctx, svc, callerToken)
+	if err != nil {
+		return nil, err
+	}
+	t, err := svc.Introspect(ctx, tokenStr)
+	if err != nil {
+		// RFC 7662: unknown tokens are reported as inactive
+		return &IntrospectionResponse{Active: false}, nil
+	}
+	return m.mask(caller, NewIntrospectionResponse(t)), nil
+}
+
+// Mask validates callerToken with svc and returns a copy of resp containing
+// only the fields the caller may see. An invalid caller token fails with
+// ErrInvalidToken.
+func (m *IntrospectionMasker) Mask(ctx context.Context, svc Service, callerToken string, resp *IntrospectionResponse) (*IntrospectionResponse, error) {
+	caller, err := m.caller(ctx, svc, callerToken)
+	if err != nil {
+		return nil, err
+	}
+	return m.mask(caller, resp), nil
+}
+
+// caller validates the caller's token; its scopes are trusted only then
+func (m *IntrospectionMasker) caller(ctx context.Context, svc Service, callerToken string) (*token.Token, error) {
+	if callerToken == "" {
+		return nil, fmt.Errorf("%w: no caller token", ErrInvalidToken)
+	}
+	caller, err := svc.Validate(ctx, callerToken, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: caller token: %v", ErrInvalidToken, err)
+	}
+	return caller, nil
+}
+
+// mask returns a copy of resp containing only the fields a validated caller
+// may see
+func (m *IntrospectionMasker) mask(caller *token.Token, resp *IntrospectionResponse) *IntrospectionResponse {
+	if resp == nil {
+		return &IntrospectionResponse{Active: false}
+	}
+	visible := m.visibleFields(caller)
+	if !resp.Active {
+		out := &IntrospectionResponse{Active: false}
+		if visible["*"] || visible["revocation_reason"] {
+			out.RevocationReason = resp.RevocationReason
+		}
+		return out
+	}
+
+	if visible["*"] {
+		out := *resp
+		return &out
+	}
+
+	out := &IntrospectionResponse{Active: true}
+	if visible["scope"] {
+		out.Scope = resp.Scope
+	}
+	if visible["client_id"] {
+		out.ClientID = resp.ClientID
+	}
+	if visible["token_type"] {
+		out.TokenType = resp.TokenType
+	}
+	if visible["exp"] {
+		out.Exp = resp.Exp
+	}
+	if visible["iat"] {
+		out.Iat = resp.Iat
+	}
+	if visible["nbf"] {
+		out.Nbf = resp.Nbf
+	}
+	if visible["sub"] {
+		out.Sub = resp.Sub
+	}
+	if visible["aud"] {
+		out.Aud = resp.Aud
+	}
+	if visible["iss"] {
+		out.Iss = resp.Iss
+	}
+	if visible["jti"] {
+		out.Jti = resp.Jti
+	}
+	if visible["cnf"] {
+		out.Cnf = resp.Cnf
+	}
+	for k, v := range resp.Metadata {
+		if visible["metadata.*"] || visible["metadata."+k] {
+			if out.Metadata == nil {
+				out.Metadata = make(map[string]string)
+			}
+			out.Metadata[k] = v
+		}
+	}
+	return out
+}
+
+func (m *IntrospectionMasker) visibleFields(caller *token.Token) map[string]bool {
+	visible := make(map[string]bool)
+	if caller == nil {
+		return visible
+	}
+	for _, f := range m.defaultFields {
+		visible[f] = true
+	}
+	for _, rule := range m.rules {
+		if caller.HasScope(rule.Scope) {
+			for _, f := range rule.Fields {
+				visible[f] = true
+			}
+		}
+	}
+	return visible
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+func TestIntrospectionMasker(t *testing.T) {
+	target := &token.Token{
+		ID:        "tok-1",
+		Type:      token.Access,
+		Subject:   "agent-1",
+		Issuer:    "gauth",
+		Scopes:    []string{"shipments:write"},
+		IssuedAt:  time.Now(),
+		NotBefore: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+		Metadata: &token.Metadata{AppData: map[string]string{
+			"monetary_limit": "5000 EUR",
+			"region":         "EU",
+		}},
+	}
+	full := NewIntrospectionResponse(target)
+
+	masker := NewIntrospectionMasker(
+		[]string{"scope", "exp"},
+		MaskRule{Scope: "logistics", Fields: []string{"sub", "metadata.region"}},
+		MaskRule{Scope: "audit:read", Fields: []string{"*"}},
+	)
+
+	t.Run("Logistics Caller", func(t *testing.T) {
+		caller := &token.Token{Scopes: []string{"logistics"}}
+		resp := masker.mask(caller, full)
+		if !resp.Active || resp.Scope != "shipments:write" || resp.Sub != "agent-1" {
+			t.Errorf("Expected scope and subject to be visible, got %+v", resp)
+		}
+		if _, ok := resp.Metadata["monetary_limit"]; ok {
+			t.Error("Expected monetary limit to be masked")
+		}
+		if resp.Metadata["region"] != "EU" {
+			t.Error("Expected region to be visible")
+		}
+		if resp.Iss != "" {
+			t.Error("Expected issuer to be masked")
+		}
+	})
+
+	t.Run("Auditor Caller", func(t *testing.T) {
+		caller := &token.Token{Scopes: []string{"audit:read"}}
+		resp := masker.mask(caller, full)
+		if resp.Metadata["monetary_limit"] != "5000 EUR" || resp.Iss != "gauth" {
+			t.Errorf("Expected full response for auditor, got %+v", resp)
+		}
+	})
+
+	t.Run("Inactive Token", func(t *testing.T) {
+		expired := *target
+		expired.ExpiresAt = time.Now().Add(-time.Minute)
+		resp := masker.mask(&token.Token{Scopes: []string{"audit:read"}}, NewIntrospectionResponse(&expired))
+		if resp.Active || resp.Sub != "" {
+			t.Errorf("Expected inactive response with no claims, got %+v", resp)
+		}
+	})
+	t.Run("Revocation Reason", func(t *testing.T) {
+		revoked := *target
+		revoked.RevocationStatus = &token.RevocationStatus{RevokedAt: time.Now(), Reason: token.ReasonCompromise}
+		resp := masker.mask(&token.Token{Scopes: []string{"audit:read"}}, NewIntrospectionResponse(&revoked))
+		if resp.Active || resp.Sub != "" || resp.RevocationReason != token.ReasonCompromise {
+			t.Errorf("Expected inactive response with the reason, got %+v", resp)
+		}
+		if resp := masker.mask(&token.Token{Scopes: []string{"logistics"}}, NewIntrospectionResponse(&revoked)); resp.RevocationReason != "" {
+			t.Errorf("Expected the reason to be masked, got %+v", resp)
+		}
+	})
+
+	t.Run("Validated Caller", func(t *testing.T) {
+		ctx := context.Background()
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("GenerateKey() error: %v", err)
+		}
+		tokens := token.NewService(token.Config{SigningKey: key, ValidityPeriod: time.Hour}, token.NewMemoryStore(time.Hour))
+		svc := NewService(tokens)
+		auditor, err := tokens.Issue(ctx, &token.Token{ID: token.NewID(), Type: token.Access, Subject: "auditor", Scopes: []string{"audit:read"}})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+
+		resp, err := masker.Mask(ctx, svc, auditor.ID, full)
+		if err != nil || resp.Metadata["monetary_limit"] != "5000 EUR" {
+			t.Errorf("Expected full response for a valid auditor token, got %+v, %v", resp, err)
+		}
+		if _, err := masker.Mask(ctx, svc, "forged", full); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Expected ErrInvalidToken for an unknown caller token, got %v", err)
+		}
+		if err := tokens.Revoke(ctx, auditor, token.ReasonCompromise); err != nil {
+			t.Fatalf("Revoke() error: %v", err)
+		}
+		if _, err := masker.Introspect(ctx, svc, auditor.ID, auditor.ID); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Expected ErrInvalidToken for a revoked caller token, got %v", err)
+		}
+	})
+}
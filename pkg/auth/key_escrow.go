@@ -0,0 +1,436 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// Key escrow errors
+var (
+	// ErrEscrowNotFound indicates no escrow record exists for the key ID
+	ErrEscrowNotFound = errors.New("escrow record not found")
+
+	// ErrEscrowExists indicates the key ID is already escrowed. Records are
+	// never replaced, as that would orphan the shares custodians hold.
+	ErrEscrowExists = errors.New("escrow record already exists")
+
+	// ErrRecoveryNotFound indicates the recovery request does not exist
+	ErrRecoveryNotFound = errors.New("recovery request not found")
+
+	// ErrQuorumNotMet indicates too few custodians approved the recovery
+	ErrQuorumNotMet = errors.New("recovery quorum not met")
+
+	// ErrUnknownCustodian indicates the approver is not a custodian of the key
+	ErrUnknownCustodian = errors.New("unknown custodian")
+
+	// ErrRecoveryCompleted indicates the recovery request was already used
+	ErrRecoveryCompleted = errors.New("recovery request already completed")
+
+	// ErrKeyFingerprintMismatch indicates the recovered key does not match the escrowed key
+	ErrKeyFingerprintMismatch = errors.New("recovered key fingerprint mismatch")
+
+	// ErrShareMismatch indicates a share is not the one issued to the
+	// custodian it claims to come from
+	ErrShareMismatch = errors.New("share does not match its custodian")
+)
+
+// KeyWrapper wraps and unwraps keys using an external key management system.
+// Implementations typically call a cloud KMS or HSM; the wrapped blob is
+// stored in the escrow record and can only be unwrapped by the KMS.
+type KeyWrapper interface {
+	WrapKey(ctx context.Context, key []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// EscrowConfig configures key escrow
+type EscrowConfig struct {
+	// Custodians receive one Shamir share each and approve recoveries
+	Custodians []string
+
+	// Threshold is the number of custodian shares and approvals required for recovery
+	Threshold int
+
+	// Store persists escrow records and recovery requests (required). Use a
+	// FileEscrowStore or another store that survives the deployment.
+	Store EscrowStore
+
+	// Wrapper optionally stores a KMS-wrapped copy of the key in the escrow record
+	Wrapper KeyWrapper
+}
+
+// EscrowShare is a single custodian's share of an escrowed key
+type EscrowShare struct {
+	KeyID     string `json:"key_id"`
+	Custodian string `json:"custodian"`
+	Index     byte   `json:"index"`
+	Value     []byte `json:"value"`
+}
+
+// Escrowed key formats
+const (
+	// EscrowFormatRaw is a raw symmetric key, such as the AES key of an
+	// encrypted token store
+	EscrowFormatRaw = "raw"
+
+	// EscrowFormatJWK is a JWE key encoded as a JSON Web Key; decode the
+	// recovered bytes with DecodeJWEKey
+	EscrowFormatJWK = "jwk"
+)
+
+// EscrowRecord describes an escrowed key without revealing it. Custodians[i]
+// holds the share with index i+1.
+type EscrowRecord struct {
+	KeyID        string            `json:"key_id"`
+	Format       string            `json:"format"`
+	Fingerprint  string            `json:"fingerprint"`
+	Threshold    int               `json:"threshold"`
+	Custodians   []string          `json:"custodians"`
+	ShareDigests map[string]string `json:"share_digests"`
+	WrappedKey   []byte            `json:"wrapped_key,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+}
+
+// RecoveryRequest tracks a pending key recovery and its custodian approvals
+type RecoveryRequest struct {
+	ID          string               `json:"id"`
+	KeyID       string               `json:"key_id"`
+	Requester   string               `json:"requester"`
+	Reason      string               `json:"reason"`
+	Approvals   map[string]time.Time `json:"approvals"`
+	CreatedAt   time.Time            `json:"created_at"`
+	CompletedAt *time.Time           `json:"completed_at,omitempty"`
+}
+
+// KeyEscrow holds escrow records for encryption keys, such as the
+// EncryptedStoreConfig.EncryptionKey and JWE decryption keys, and performs
+// quorum-approved recovery. Records and requests live in the EscrowStore,
+// so recovery works from a new process after the original one is lost.
+//
+// Recovery procedure:
+//  1. An operator calls RequestRecovery with the key ID and a reason.
+//  2. At least Threshold custodians call Approve for that request.
+//  3. The approving custodians submit their shares to Recover, or, when a
+//     KeyWrapper is configured, RecoverFromKMS unwraps the escrowed copy.
+//     Each share must carry the index registered for its custodian.
+//  4. The recovered key is checked against the escrowed fingerprint and the
+//     request is marked completed so it cannot be replayed.
+type KeyEscrow struct {
+	config EscrowConfig
+
+	// mu serializes read-modify-write cycles on the store
+	mu sync.Mutex
+}
+
+// NewKeyEscrow creates a key escrow with the given configuration
+func NewKeyEscrow(config EscrowConfig) (*KeyEscrow, error) {
+	if len(config.Custodians) < 2 {
+		return nil, errors.New("at least two custodians are required")
+	}
+	if config.Threshold < 2 || config.Threshold > len(config.Custodians) {
+		return nil, fmt.Errorf("threshold must be between 2 and %d", len(config.Custodians))
+	}
+	if config.Store == nil {
+		return nil, errors.New("an escrow store is required")
+	}
+	seen := make(map[string]bool, len(config.Custodians))
+	for _, c := range config.Custodians {
+		if c == "" || seen[c] {
+			return nil, errors.New("custodians must be unique and non-empty")
+		}
+		seen[c] = true
+	}
+
+	return &KeyEscrow{config: config}, nil
+}
+
+// Deposit escrows a raw key and returns one share per custodian. Shares must
+// be distributed to their custodians out of band; the escrow keeps only the
+// key fingerprint, a digest of each share and, if configured, a KMS-wrapped
+// copy.
+func (e *KeyEscrow) Deposit(ctx context.Context, keyID string, key []byte) (*EscrowRecord, []EscrowShare, error) {
+	return e.deposit(ctx, keyID, EscrowFormatRaw, key)
+}
+
+// DepositJWEKey escrows a JWE decryption key, such as an RSA-OAEP or
+// ECDH-ES private key or a symmetric key-wrapping key, under its key ID.
+// The key is kept as a JWK so it is recovered with its algorithm and use.
+func (e *KeyEscrow) DepositJWEKey(ctx context.Context, key jose.JSONWebKey) (*EscrowRecord, []EscrowShare, error) {
+	if key.KeyID == "" {
+		return nil, nil, errors.New("JWE key needs a key ID")
+	}
+	if !key.Valid() || key.IsPublic() {
+		return nil, nil, errors.New("JWE key must be a valid private or symmetric key")
+	}
+	if key.Use != "" && key.Use != "enc" {
+		return nil, nil, fmt.Errorf("JWE key has use %q, not enc", key.Use)
+	}
+	data, err := key.MarshalJSON()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode JWE key: %w", err)
+	}
+	return e.deposit(ctx, key.KeyID, EscrowFormatJWK, data)
+}
+
+// DecodeJWEKey decodes a key recovered from a record in EscrowFormatJWK
+func DecodeJWEKey(recovered []byte) (*jose.JSONWebKey, error) {
+	var key jose.JSONWebKey
+	if err := key.UnmarshalJSON(recovered); err != nil {
+		return nil, fmt.Errorf("failed to decode JWE key: %w", err)
+	}
+	return &key, nil
+}
+
+func (e *KeyEscrow) deposit(ctx context.Context, keyID, format string, key []byte) (*EscrowRecord, []EscrowShare, error) {
+	if keyID == "" {
+		return nil, nil, errors.New("key ID is required")
+	}
+	// Checked up front to spare the KMS call; the store enforces it
+	if _, err := e.config.Store.GetRecord(ctx, keyID); err == nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrEscrowExists, keyID)
+	} else if !errors.Is(err, ErrEscrowNotFound) {
+		return nil, nil, fmt.Errorf("failed to read escrow record: %w", err)
+	}
+
+	parts, err := splitSecret(key, len(e.config.Custodians), e.config.Threshold)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to split key: %w", err)
+	}
+
+	record := &EscrowRecord{
+		KeyID:        keyID,
+		Format:       format,
+		Fingerprint:  keyFingerprint(key),
+		Threshold:    e.config.Threshold,
+		Custodians:   append([]string(nil), e.config.Custodians...),
+		ShareDigests: make(map[string]string, len(parts)),
+		CreatedAt:    time.Now(),
+	}
+
+	if e.config.Wrapper != nil {
+		wrapped, err := e.config.Wrapper.WrapKey(ctx, key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to wrap key: %w", err)
+		}
+		record.WrappedKey = wrapped
+	}
+
+	shares := make([]EscrowShare, 0, len(parts))
+	for i, custodian := range e.config.Custodians {
+		share := EscrowShare{
+			KeyID:     keyID,
+			Custodian: custodian,
+			Index:     byte(i + 1),
+			Value:     parts[byte(i+1)],
+		}
+		record.ShareDigests[custodian] = shareDigest(share)
+		shares = append(shares, share)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.config.Store.SaveRecord(ctx, record); errors.Is(err, ErrEscrowExists) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrEscrowExists, keyID)
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to store escrow record: %w", err)
+	}
+	return record, shares, nil
+}
+
+// GetRecord returns the escrow record for a key
+func (e *KeyEscrow) GetRecord(ctx context.Context, keyID string) (*EscrowRecord, error) {
+	return e.config.Store.GetRecord(ctx, keyID)
+}
+
+// RequestRecovery opens a recovery request for an escrowed key
+func (e *KeyEscrow) RequestRecovery(ctx context.Context, keyID, requester, reason string) (*RecoveryRequest, error) {
+	if reason == "" {
+		return nil, errors.New("recovery reason is required")
+	}
+	if _, err := e.config.Store.GetRecord(ctx, keyID); err != nil {
+		return nil, err
+	}
+
+	req := &RecoveryRequest{
+		ID:        token.NewID(),
+		KeyID:     keyID,
+		Requester: requester,
+		Reason:    reason,
+		Approvals: make(map[string]time.Time),
+		CreatedAt: time.Now(),
+	}
+	if err := e.config.Store.SaveRequest(ctx, req); err != nil {
+		return nil, fmt.Errorf("failed to store recovery request: %w", err)
+	}
+	return req, nil
+}
+
+// Approve records a custodian's approval of a recovery request
+func (e *KeyEscrow) Approve(ctx context.Context, requestID, custodian string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	req, err := e.config.Store.GetRequest(ctx, requestID)
+	if err != nil {
+		return err
+	}
+	if req.CompletedAt != nil {
+		return ErrRecoveryCompleted
+	}
+	record, err := e.config.Store.GetRecord(ctx, req.KeyID)
+	if err != nil {
+		return err
+	}
+	if custodianIndex(record, custodian) == 0 {
+		return ErrUnknownCustodian
+	}
+
+	if req.Approvals == nil {
+		req.Approvals = make(map[string]time.Time)
+	}
+	req.Approvals[custodian] = time.Now()
+	if err := e.config.Store.SaveRequest(ctx, req); err != nil {
+		return fmt.Errorf("failed to store approval: %w", err)
+	}
+	return nil
+}
+
+// Recover reconstructs the key from custodian shares once the request has
+// quorum approval. Only shares from approving custodians are used, and each
+// must be the share issued to its custodian.
+func (e *KeyEscrow) Recover(ctx context.Context, requestID string, shares []EscrowShare) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	req, record, err := e.approvedRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make(map[byte][]byte)
+	for _, s := range shares {
+		if s.KeyID != req.KeyID {
+			continue
+		}
+		if idx := custodianIndex(record, s.Custodian); idx == 0 || idx != s.Index {
+			return nil, fmt.Errorf("%w: share %d submitted for %q", ErrShareMismatch, s.Index, s.Custodian)
+		}
+		if digest, ok := record.ShareDigests[s.Custodian]; ok &&
+			subtle.ConstantTimeCompare([]byte(shareDigest(s)), []byte(digest)) != 1 {
+			return nil, fmt.Errorf("%w: share of %q was altered", ErrShareMismatch, s.Custodian)
+		}
+		if _, approved := req.Approvals[s.Custodian]; !approved {
+			continue
+		}
+		parts[s.Index] = s.Value
+	}
+	if len(parts) < record.Threshold {
+		return nil, fmt.Errorf("%w: %d of %d shares from approving custodians", ErrQuorumNotMet, len(parts), record.Threshold)
+	}
+
+	key, err := combineShares(parts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to combine shares: %w", err)
+	}
+
+	return e.complete(ctx, req, record, key)
+}
+
+// RecoverFromKMS unwraps the KMS-wrapped copy of the key once the request has
+// quorum approval.
+func (e *KeyEscrow) RecoverFromKMS(ctx context.Context, requestID string) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.config.Wrapper == nil {
+		return nil, errors.New("no key wrapper configured")
+	}
+
+	req, record, err := e.approvedRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if len(record.WrappedKey) == 0 {
+		return nil, errors.New("escrow record has no wrapped key")
+	}
+
+	key, err := e.config.Wrapper.UnwrapKey(ctx, record.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap key: %w", err)
+	}
+
+	return e.complete(ctx, req, record, key)
+}
+
+// approvedRequest loads an open request with quorum approval and its
+// record; e.mu must be held
+func (e *KeyEscrow) approvedRequest(ctx context.Context, requestID string) (*RecoveryRequest, *EscrowRecord, error) {
+	req, err := e.config.Store.GetRequest(ctx, requestID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req.CompletedAt != nil {
+		return nil, nil, ErrRecoveryCompleted
+	}
+	record, err := e.config.Store.GetRecord(ctx, req.KeyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	approvals := 0
+	for custodian := range req.Approvals {
+		if custodianIndex(record, custodian) != 0 {
+			approvals++
+		}
+	}
+	if approvals < record.Threshold {
+		return nil, nil, fmt.Errorf("%w: %d of %d approvals", ErrQuorumNotMet, approvals, record.Threshold)
+	}
+	return req, record, nil
+}
+
+// complete checks the recovered key and closes the request; the key is
+// only released once the closed request is stored
+func (e *KeyEscrow) complete(ctx context.Context, req *RecoveryRequest, record *EscrowRecord, key []byte) ([]byte, error) {
+	if subtle.ConstantTimeCompare([]byte(keyFingerprint(key)), []byte(record.Fingerprint)) != 1 {
+		return nil, ErrKeyFingerprintMismatch
+	}
+	now := time.Now()
+	req.CompletedAt = &now
+	if err := e.config.Store.SaveRequest(ctx, req); err != nil {
+		return nil, fmt.Errorf("failed to close recovery request: %w", err)
+	}
+	return key, nil
+}
+
+// custodianIndex returns the share index registered for a custodian, or 0
+func custodianIndex(record *EscrowRecord, custodian string) byte {
+	for i, c := range record.Custodians {
+		if c == custodian {
+			return byte(i + 1)
+		}
+	}
+	return 0
+}
+
+// shareDigest binds a share's value to its key, custodian and index
+func shareDigest(s EscrowShare) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%d\x00", s.KeyID, s.Custodian, s.Index)
+	h.Write(s.Value)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// keyFingerprint returns the hex SHA-256 of a key
+func keyFingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])
+}
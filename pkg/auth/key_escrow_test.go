@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+type xorWrapper struct{ mask byte }
+
+func (w xorWrapper) WrapKey(_ context.Context, key []byte) ([]byte, error) {
+	out := make([]byte, len(key))
+	for i, b := range key {
+		out[i] = b ^ w.mask
+	}
+	return out, nil
+}
+
+func (w xorWrapper) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	return w.WrapKey(ctx, wrapped)
+}
+
+func TestShamirRoundTrip(t *testing.T) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatal(err)
+	}
+
+	shares, err := splitSecret(secret, 5, 3)
+	if err != nil {
+		t.Fatalf("splitSecret failed: %v", err)
+	}
+
+	subset := map[byte][]byte{2: shares[2], 4: shares[4], 5: shares[5]}
+	got, err := combineShares(subset)
+	if err != nil {
+		t.Fatalf("combineShares failed: %v", err)
+	}
+	if !bytes.Equal(got, secret) {
+		t.Error("recovered secret does not match original")
+	}
+
+	short := map[byte][]byte{1: shares[1], 3: shares[3]}
+	got, _ = combineShares(short)
+	if bytes.Equal(got, secret) {
+		t.Error("secret should not be recoverable below threshold")
+	}
+}
+
+func TestKeyEscrowRecovery(t *testing.T) {
+	ctx := context.Background()
+	cfg := EncryptedStoreConfig{EncryptionKey: bytes.Repeat([]byte{0x42}, 32)}
+
+	store, err := NewFileEscrowStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileEscrowStore failed: %v", err)
+	}
+	config := EscrowConfig{
+		Custodians: []string{"alice", "bob", "carol"},
+		Threshold:  2,
+		Store:      store,
+		Wrapper:    xorWrapper{mask: 0x5a},
+	}
+	escrow, err := NewKeyEscrow(config)
+	if err != nil {
+		t.Fatalf("NewKeyEscrow failed: %v", err)
+	}
+
+	shares, err := cfg.EscrowEncryptionKey(ctx, escrow, "store-key-1")
+	if err != nil {
+		t.Fatalf("EscrowEncryptionKey failed: %v", err)
+	}
+	if len(shares) != 3 {
+		t.Fatalf("expected 3 shares, got %d", len(shares))
+	}
+
+	t.Run("Duplicate Deposit", func(t *testing.T) {
+		if _, _, err := escrow.Deposit(ctx, "store-key-1", bytes.Repeat([]byte{0x17}, 32)); !errors.Is(err, ErrEscrowExists) {
+			t.Errorf("expected ErrEscrowExists, got %v", err)
+		}
+		// The store refuses it too, for a deposit racing from another process
+		record, err := store.GetRecord(ctx, "store-key-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fingerprint := record.Fingerprint
+		record.Fingerprint = "replaced"
+		if err := store.SaveRecord(ctx, record); !errors.Is(err, ErrEscrowExists) {
+			t.Errorf("expected the file store to refuse the record, got %v", err)
+		}
+		memory := NewMemoryEscrowStore()
+		if err := memory.SaveRecord(ctx, record); err != nil {
+			t.Fatal(err)
+		}
+		if err := memory.SaveRecord(ctx, record); !errors.Is(err, ErrEscrowExists) {
+			t.Errorf("expected the memory store to refuse the record, got %v", err)
+		}
+		kept, _ := store.GetRecord(ctx, "store-key-1")
+		if kept.Fingerprint != fingerprint {
+			t.Error("expected the original record kept")
+		}
+	})
+
+	t.Run("Quorum Required", func(t *testing.T) {
+		req, err := escrow.RequestRecovery(ctx, "store-key-1", "ops", "disk loss")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := escrow.Approve(ctx, req.ID, "alice"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := escrow.Recover(ctx, req.ID, shares); !errors.Is(err, ErrQuorumNotMet) {
+			t.Errorf("expected ErrQuorumNotMet, got %v", err)
+		}
+		if err := escrow.Approve(ctx, req.ID, "mallory"); !errors.Is(err, ErrUnknownCustodian) {
+			t.Errorf("expected ErrUnknownCustodian, got %v", err)
+		}
+	})
+
+	t.Run("Recover From Shares", func(t *testing.T) {
+		req, _ := escrow.RequestRecovery(ctx, "store-key-1", "ops", "region failover")
+		_ = escrow.Approve(ctx, req.ID, "alice")
+		_ = escrow.Approve(ctx, req.ID, "carol")
+
+		key, err := escrow.Recover(ctx, req.ID, shares)
+		if err != nil {
+			t.Fatalf("Recover failed: %v", err)
+		}
+		if !bytes.Equal(key, cfg.EncryptionKey) {
+			t.Error("recovered key does not match")
+		}
+		if _, err := escrow.Recover(ctx, req.ID, shares); !errors.Is(err, ErrRecoveryCompleted) {
+			t.Errorf("expected ErrRecoveryCompleted on replay, got %v", err)
+		}
+	})
+
+	t.Run("Recover From KMS", func(t *testing.T) {
+		req, _ := escrow.RequestRecovery(ctx, "store-key-1", "ops", "custodian unavailable")
+		_ = escrow.Approve(ctx, req.ID, "bob")
+		_ = escrow.Approve(ctx, req.ID, "carol")
+
+		key, err := escrow.RecoverFromKMS(ctx, req.ID)
+		if err != nil {
+			t.Fatalf("RecoverFromKMS failed: %v", err)
+		}
+		if !bytes.Equal(key, cfg.EncryptionKey) {
+			t.Error("recovered key does not match")
+		}
+	})
+	t.Run("Shares Are Bound To Custodians", func(t *testing.T) {
+		req, _ := escrow.RequestRecovery(ctx, "store-key-1", "ops", "region failover")
+		_ = escrow.Approve(ctx, req.ID, "alice")
+		_ = escrow.Approve(ctx, req.ID, "bob")
+
+		// alice presents carol's share as her own second share
+		stolen := shares[2]
+		stolen.Custodian = "alice"
+		if _, err := escrow.Recover(ctx, req.ID, []EscrowShare{shares[0], stolen}); !errors.Is(err, ErrShareMismatch) {
+			t.Errorf("expected ErrShareMismatch for a relabelled share, got %v", err)
+		}
+
+		altered := shares[1]
+		altered.Value = append([]byte(nil), altered.Value...)
+		altered.Value[0] ^= 0xff
+		if _, err := escrow.Recover(ctx, req.ID, []EscrowShare{shares[0], altered}); !errors.Is(err, ErrShareMismatch) {
+			t.Errorf("expected ErrShareMismatch for an altered share, got %v", err)
+		}
+	})
+
+	t.Run("Recovery Survives Restart", func(t *testing.T) {
+		req, _ := escrow.RequestRecovery(ctx, "store-key-1", "ops", "cluster rebuilt")
+		_ = escrow.Approve(ctx, req.ID, "alice")
+
+		// the process is lost; a new one opens the same store
+		restarted, err := NewKeyEscrow(config)
+		if err != nil {
+			t.Fatalf("NewKeyEscrow failed: %v", err)
+		}
+		if err := restarted.Approve(ctx, req.ID, "carol"); err != nil {
+			t.Fatalf("Approve after restart failed: %v", err)
+		}
+		key, err := restarted.Recover(ctx, req.ID, []EscrowShare{shares[0], shares[2]})
+		if err != nil {
+			t.Fatalf("Recover after restart failed: %v", err)
+		}
+		if !bytes.Equal(key, cfg.EncryptionKey) {
+			t.Error("recovered key does not match")
+		}
+		if _, err := escrow.Recover(ctx, req.ID, shares); !errors.Is(err, ErrRecoveryCompleted) {
+			t.Errorf("expected the completed request to be shared, got %v", err)
+		}
+	})
+
+	t.Run("JWE Key", func(t *testing.T) {
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jwk := jose.JSONWebKey{Key: priv, KeyID: "jwe-2025", Algorithm: string(jose.RSA_OAEP_256), Use: "enc"}
+		if _, _, err := escrow.DepositJWEKey(ctx, jwk.Public()); err == nil {
+			t.Error("expected a public key to be refused")
+		}
+		record, jweShares, err := escrow.DepositJWEKey(ctx, jwk)
+		if err != nil {
+			t.Fatalf("DepositJWEKey failed: %v", err)
+		}
+		if record.Format != EscrowFormatJWK {
+			t.Errorf("expected format %s, got %s", EscrowFormatJWK, record.Format)
+		}
+
+		req, _ := escrow.RequestRecovery(ctx, "jwe-2025", "ops", "decryption key lost")
+		_ = escrow.Approve(ctx, req.ID, "bob")
+		_ = escrow.Approve(ctx, req.ID, "carol")
+		recovered, err := escrow.Recover(ctx, req.ID, jweShares[1:])
+		if err != nil {
+			t.Fatalf("Recover failed: %v", err)
+		}
+		key, err := DecodeJWEKey(recovered)
+		if err != nil {
+			t.Fatalf("DecodeJWEKey failed: %v", err)
+		}
+		if !priv.Equal(key.Key) || key.Algorithm != string(jose.RSA_OAEP_256) || key.KeyID != "jwe-2025" {
+			t.Errorf("recovered JWK does not match: %+v", key)
+		}
+	})
+
+	if _, err := NewKeyEscrow(EscrowConfig{Custodians: []string{"alice", "bob"}, Threshold: 2}); err == nil {
+		t.Error("expected an error without a store")
+	}
+}
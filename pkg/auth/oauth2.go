@@ -41,6 +41,7 @@ type OAuth2TokenResponse struct {
 	ExpiresIn    int64  `json:"expires_in"`
 	RefreshToken string `json:"refresh_token,omitempty"`
 	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
 }
 
 // oauth2Authenticator implements the Authenticator interface for OAuth2
@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/audit"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Offline approval errors
+var (
+	// ErrApprovalTokenInvalid indicates a malformed, forged or expired approval token
+	ErrApprovalTokenInvalid = errors.New("invalid approval token")
+
+	// ErrUnknownApprover indicates the token was signed by an unregistered approver
+	ErrUnknownApprover = errors.New("unknown approver")
+
+	// ErrApprovalNotCovered indicates the action or subject is outside the approval
+	ErrApprovalNotCovered = errors.New("action not covered by approval")
+
+	// ErrApprovalExhausted indicates the approval has no uses left
+	ErrApprovalExhausted = errors.New("approval usage exhausted")
+)
+
+// Audit trail constants
+const (
+	// TypeOfflineApproval is the audit entry type for offline approvals
+	TypeOfflineApproval = "offline_approval"
+
+	// ActionApprovalConsumed records an action authorized by an offline approval
+	ActionApprovalConsumed = "offline_approval_consumed"
+
+	// ActionApprovalRejected records a refused offline approval
+	ActionApprovalRejected = "offline_approval_rejected"
+)
+
+// OfflineApproval pre-authorizes a class of actions for a limited window.
+// Approvers sign it ahead of time so that urgent actions can pass dual
+// control while the approver is briefly unreachable.
+type OfflineApproval struct {
+	ID       string `json:"id"`
+	Approver string `json:"approver"`
+	Role     string `json:"role,omitempty"`
+
+	// Actions lists the covered actions; a trailing "*" matches a prefix,
+	// e.g. "payments:*"
+	Actions []string `json:"actions"`
+
+	// Subject restricts the approval to one requesting subject (optional)
+	Subject string `json:"subject,omitempty"`
+
+	// MaxUses caps how often the approval can be consumed
+	MaxUses int `json:"max_uses"`
+
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Covers reports whether the approval covers action
+func (a *OfflineApproval) Covers(action string) bool {
+	for _, pattern := range a.Actions {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(action, prefix) {
+				return true
+			}
+		} else if pattern == action {
+			return true
+		}
+	}
+	return false
+}
+
+type approvalClaims struct {
+	jwt.RegisteredClaims
+	Role    string   `json:"role,omitempty"`
+	Actions []string `json:"actions"`
+	Holder  string   `json:"holder,omitempty"`
+	MaxUses int      `json:"max_uses"`
+}
+
+// SignOfflineApproval signs an approval with the approver's Ed25519 key.
+// ID and IssuedAt are filled in when empty.
+func SignOfflineApproval(key ed25519.PrivateKey, a OfflineApproval) (string, error) {
+	if a.Approver == "" || len(a.Actions) == 0 || a.ExpiresAt.IsZero() || a.MaxUses <= 0 {
+		return "", errors.New("approval needs an approver, actions, an expiry and a usage cap")
+	}
+	if a.ID == "" {
+		a.ID = generateTokenID()
+	}
+	if a.IssuedAt.IsZero() {
+		a.IssuedAt = time.Now()
+	}
+	claims := approvalClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        a.ID,
+			Issuer:    a.Approver,
+			IssuedAt:  jwt.NewNumericDate(a.IssuedAt),
+			ExpiresAt: jwt.NewNumericDate(a.ExpiresAt),
+		},
+		Role:    a.Role,
+		Actions: a.Actions,
+		Holder:  a.Subject,
+		MaxUses: a.MaxUses,
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims).SignedString(key)
+}
+
+// OfflineApprovalConfig configures OfflineApprovals
+type OfflineApprovalConfig struct {
+	// Approvers maps approver IDs to their Ed25519 public keys
+	Approvers map[string]ed25519.PublicKey
+
+	// MaxWindow rejects approvals valid for longer than this (default: 4h)
+	MaxWindow time.Duration
+
+	// MaxUses caps the usage of any approval, whatever it claims (default: 10)
+	MaxUses int
+
+	// Audit receives an entry for every consumed or rejected approval
+	Audit audit.Storage
+
+	// Usage counts approval uses; share one store between instances so a
+	// token cannot be replayed on another node or after a restart
+	// (default: in memory)
+	Usage ApprovalUsageStore
+
+	// Now returns the current time (default: time.Now)
+	Now func() time.Time
+}
+
+// OfflineApprovals verifies and consumes offline approval tokens. It keeps
+// the usage count of every approval until the approval expires, so a token
+// cannot be replayed beyond its cap.
+type OfflineApprovals struct {
+	config OfflineApprovalConfig
+}
+
+// NewOfflineApprovals creates an offline approval verifier
+func NewOfflineApprovals(config OfflineApprovalConfig) (*OfflineApprovals, error) {
+	if len(config.Approvers) == 0 {
+		return nil, errors.New("at least one approver key is required")
+	}
+	if config.MaxWindow <= 0 {
+		config.MaxWindow = 4 * time.Hour
+	}
+	if config.MaxUses <= 0 {
+		config.MaxUses = 10
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	if config.Usage == nil {
+		config.Usage = NewMemoryApprovalUsage(config.Now)
+	}
+	return &OfflineApprovals{config: config}, nil
+}
+
+// Verify checks an approval token's signature, expiry and window without
+// consuming it
+func (o *OfflineApprovals) Verify(raw string) (*OfflineApproval, error) {
+	var claims approvalClaims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(t *jwt.Token) (interface{}, error) {
+		c := t.Claims.(*approvalClaims)
+		key, ok := o.config.Approvers[c.Issuer]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownApprover, c.Issuer)
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithTimeFunc(o.config.Now),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if errors.Is(err, ErrUnknownApprover) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrApprovalTokenInvalid, err)
+	}
+	if claims.ID == "" || claims.IssuedAt == nil || claims.MaxUses <= 0 {
+		return nil, fmt.Errorf("%w: missing id, issue time or usage cap", ErrApprovalTokenInvalid)
+	}
+	a := &OfflineApproval{
+		ID:        claims.ID,
+		Approver:  claims.Issuer,
+		Role:      claims.Role,
+		Actions:   claims.Actions,
+		Subject:   claims.Holder,
+		MaxUses:   min(claims.MaxUses, o.config.MaxUses),
+		IssuedAt:  claims.IssuedAt.Time,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+	if a.ExpiresAt.Sub(a.IssuedAt) > o.config.MaxWindow {
+		return nil, fmt.Errorf("%w: valid for longer than %s", ErrApprovalTokenInvalid, o.config.MaxWindow)
+	}
+	return a, nil
+}
+
+// Consume authorizes action for subject with an approval token and counts
+// one use against its cap. Every outcome is audited.
+func (o *OfflineApprovals) Consume(ctx context.Context, raw, subject, action string) (*OfflineApproval, error) {
+	a, err := o.consume(ctx, raw, subject, action)
+	o.record(ctx, a, subject, action, err)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (o *OfflineApprovals) consume(ctx context.Context, raw, subject, action string) (*OfflineApproval, error) {
+	a, err := o.Verify(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !a.Covers(action) {
+		return a, fmt.Errorf("%w: %s", ErrApprovalNotCovered, action)
+	}
+	if a.Subject != "" && a.Subject != subject {
+		return a, fmt.Errorf("%w: issued for another subject", ErrApprovalNotCovered)
+	}
+
+	if _, err := o.config.Usage.Use(ctx, a.ID, a.MaxUses, a.ExpiresAt); err != nil {
+		return a, err
+	}
+	return a, nil
+}
+
+// Remaining returns how many uses an approval has left, or -1 when the
+// approval has never been consumed or has expired
+func (o *OfflineApprovals) Remaining(ctx context.Context, a *OfflineApproval) (int, error) {
+	used, err := o.config.Usage.Used(ctx, a.ID)
+	if err != nil || used < 0 {
+		return -1, err
+	}
+	return a.MaxUses - used, nil
+}
+
+func (o *OfflineApprovals) record(ctx context.Context, a *OfflineApproval, subject, action string, err error) {
+	if o.config.Audit == nil {
+		return
+	}
+	e := audit.NewEntry(TypeOfflineApproval).
+		WithActor(subject, audit.ActorUser).
+		WithTarget(action, "action").
+		WithContext(ctx)
+	if a != nil {
+		e = e.WithCorrelationID(a.ID).
+			WithMetadata("approval_id", a.ID).
+			WithMetadata("approver", a.Approver).
+			WithMetadata("max_uses", strconv.Itoa(a.MaxUses)).
+			WithMetadata("expires_at", a.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	if err != nil {
+		e = e.WithAction(ActionApprovalRejected).WithResult("failure")
+		e.Error = err.Error()
+	} else {
+		e = e.WithAction(ActionApprovalConsumed).WithResult(audit.ResultSuccess)
+	}
+	_ = o.config.Audit.Store(ctx, e)
+}
+
+type offlineApprovalKey struct{}
+
+// WithOfflineApproval attaches an offline approval token to ctx for the
+// dual-control check of the action performed with it
+func WithOfflineApproval(ctx context.Context, raw string) context.Context {
+	return context.WithValue(ctx, offlineApprovalKey{}, raw)
+}
+
+// OfflineApprovalFromContext returns the approval token carried by ctx
+func OfflineApprovalFromContext(ctx context.Context) (string, bool) {
+	raw, ok := ctx.Value(offlineApprovalKey{}).(string)
+	return raw, ok && raw != ""
+}
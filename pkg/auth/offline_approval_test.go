@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/audit"
+	"github.com/Gimel-Foundation/gauth/pkg/common"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+type recordingAudit struct {
+	audit.Storage
+	entries []*audit.Entry
+}
+
+func (a *recordingAudit) Store(_ context.Context, e *audit.Entry) error {
+	a.entries = append(a.entries, e)
+	return nil
+}
+
+func TestOfflineApprovals(t *testing.T) {
+	ctx := context.Background()
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	_, otherPriv, _ := ed25519.GenerateKey(nil)
+	now := time.Now()
+	trail := &recordingAudit{}
+	approvals, err := NewOfflineApprovals(OfflineApprovalConfig{
+		Approvers: map[string]ed25519.PublicKey{"cfo": pub},
+		MaxUses:   3,
+		Audit:     trail,
+	})
+	if err != nil {
+		t.Fatalf("NewOfflineApprovals() error: %v", err)
+	}
+	sign := func(key ed25519.PrivateKey, a OfflineApproval) string {
+		if a.Approver == "" {
+			a.Approver = "cfo"
+		}
+		if a.ExpiresAt.IsZero() {
+			a.ExpiresAt = now.Add(time.Hour)
+		}
+		if a.MaxUses == 0 {
+			a.MaxUses = 5
+		}
+		raw, err := SignOfflineApproval(key, a)
+		if err != nil {
+			t.Fatalf("SignOfflineApproval() error: %v", err)
+		}
+		return raw
+	}
+
+	t.Run("Usage Capped", func(t *testing.T) {
+		raw := sign(priv, OfflineApproval{Role: "finance", Actions: []string{"payments:*"}, Subject: "agent-1"})
+		for i := 0; i < 3; i++ {
+			if _, err := approvals.Consume(ctx, raw, "agent-1", "payments:wire"); err != nil {
+				t.Fatalf("Consume() #%d error: %v", i+1, err)
+			}
+		}
+		a, err := approvals.Consume(ctx, raw, "agent-1", "payments:wire")
+		if !errors.Is(err, ErrApprovalExhausted) || a != nil {
+			t.Errorf("Expected ErrApprovalExhausted after the configured cap, got %v", err)
+		}
+		last := trail.entries[len(trail.entries)-1]
+		if len(trail.entries) != 4 || last.Action != ActionApprovalRejected || last.Metadata["approver"] != "cfo" {
+			t.Errorf("Expected every use audited, got %d entries, last %+v", len(trail.entries), last)
+		}
+	})
+
+	t.Run("Coverage", func(t *testing.T) {
+		raw := sign(priv, OfflineApproval{Actions: []string{"payments:*", "deploy"}, Subject: "agent-1"})
+		if _, err := approvals.Consume(ctx, raw, "agent-1", "hr:terminate"); !errors.Is(err, ErrApprovalNotCovered) {
+			t.Errorf("Expected ErrApprovalNotCovered for other action, got %v", err)
+		}
+		if _, err := approvals.Consume(ctx, raw, "agent-2", "deploy"); !errors.Is(err, ErrApprovalNotCovered) {
+			t.Errorf("Expected ErrApprovalNotCovered for other subject, got %v", err)
+		}
+	})
+
+	t.Run("Rejected Tokens", func(t *testing.T) {
+		forged := sign(otherPriv, OfflineApproval{Actions: []string{"deploy"}})
+		if _, err := approvals.Verify(forged); !errors.Is(err, ErrApprovalTokenInvalid) {
+			t.Errorf("Expected forged token rejected, got %v", err)
+		}
+		unknown := sign(priv, OfflineApproval{Approver: "intern", Actions: []string{"deploy"}})
+		if _, err := approvals.Verify(unknown); !errors.Is(err, ErrUnknownApprover) {
+			t.Errorf("Expected ErrUnknownApprover, got %v", err)
+		}
+		long := sign(priv, OfflineApproval{Actions: []string{"deploy"}, ExpiresAt: now.Add(24 * time.Hour)})
+		if _, err := approvals.Verify(long); !errors.Is(err, ErrApprovalTokenInvalid) {
+			t.Errorf("Expected window beyond MaxWindow rejected, got %v", err)
+		}
+		expired := sign(priv, OfflineApproval{Actions: []string{"deploy"}, IssuedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)})
+		if _, err := approvals.Verify(expired); !errors.Is(err, ErrApprovalTokenInvalid) {
+			t.Errorf("Expected expired token rejected, got %v", err)
+		}
+	})
+
+	t.Run("Redis Usage", func(t *testing.T) {
+		mr, err := miniredis.Run()
+		if err != nil {
+			t.Fatalf("miniredis.Run() error: %v", err)
+		}
+		defer mr.Close()
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		defer client.Close()
+
+		newApprovals := func() *OfflineApprovals {
+			a, err := NewOfflineApprovals(OfflineApprovalConfig{
+				Approvers: map[string]ed25519.PublicKey{"cfo": pub},
+				Usage:     NewRedisApprovalUsage(client, ""),
+			})
+			if err != nil {
+				t.Fatalf("NewOfflineApprovals() error: %v", err)
+			}
+			return a
+		}
+		raw := sign(priv, OfflineApproval{Actions: []string{"deploy"}, MaxUses: 2})
+		if _, err := newApprovals().Consume(ctx, raw, "agent-1", "deploy"); err != nil {
+			t.Fatalf("Consume() error: %v", err)
+		}
+		// A second instance, or a restarted one, sees the same count
+		restarted := newApprovals()
+		if _, err := restarted.Consume(ctx, raw, "agent-1", "deploy"); err != nil {
+			t.Fatalf("Consume() error: %v", err)
+		}
+		if _, err := restarted.Consume(ctx, raw, "agent-1", "deploy"); !errors.Is(err, ErrApprovalExhausted) {
+			t.Errorf("Expected ErrApprovalExhausted across instances, got %v", err)
+		}
+		a, _ := restarted.Verify(raw)
+		if left, err := restarted.Remaining(ctx, a); err != nil || left != 0 {
+			t.Errorf("Expected no uses left, got %d, %v", left, err)
+		}
+	})
+}
+
+type approvalStore struct {
+	token.EnhancedStore
+	approval *common.SecondLevelApproval
+}
+
+func (s *approvalStore) GetSecondLevelApproval(context.Context, *token.EnhancedToken) (*common.SecondLevelApproval, error) {
+	return s.approval, nil
+}
+
+func TestEnforceSecondLevelApproval(t *testing.T) {
+	ctx := context.Background()
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	approvals, err := NewOfflineApprovals(OfflineApprovalConfig{
+		Approvers: map[string]ed25519.PublicKey{"cfo": pub, "agent-1": pub},
+	})
+	if err != nil {
+		t.Fatalf("NewOfflineApprovals() error: %v", err)
+	}
+	sign := func(approver, role string) context.Context {
+		raw, err := SignOfflineApproval(priv, OfflineApproval{
+			Approver:  approver,
+			Role:      role,
+			Actions:   []string{"payments:*"},
+			MaxUses:   5,
+			ExpiresAt: time.Now().Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("SignOfflineApproval() error: %v", err)
+		}
+		return WithOfflineApproval(ctx, raw)
+	}
+	rules := JurisdictionRulesFunc(func(context.Context, *token.EnhancedToken) (*JurisdictionRules, error) {
+		return &JurisdictionRules{
+			RequiredApprovals: map[string]ApprovalLevel{"payments:wire": DualApproval},
+			RequiredRoles:     []string{"finance"},
+		}, nil
+	})
+	store := &approvalStore{}
+	tok := &token.EnhancedToken{Token: &token.Token{Subject: "agent-1"}}
+
+	t.Run("No Rules", func(t *testing.T) {
+		enforcer := NewStandardAuthorizationEnforcer(store, nil, nil)
+		if err := enforcer.EnforceSecondLevelApproval(ctx, tok, "payments:wire"); !errors.Is(err, ErrNoJurisdictionRules) {
+			t.Errorf("Expected ErrNoJurisdictionRules, got %v", err)
+		}
+	})
+
+	enforcer := NewStandardAuthorizationEnforcer(store, nil, nil)
+	enforcer.SetJurisdictionRules(rules)
+	enforcer.SetOfflineApprovals(approvals)
+	enforcer.SetApproverRoles(StaticApproverRoles{"cfo": {"finance"}, "agent-1": {"finance"}, "clerk": {"ops"}})
+
+	t.Run("Offline Approval", func(t *testing.T) {
+		if err := enforcer.EnforceSecondLevelApproval(sign("cfo", "finance"), tok, "payments:wire"); err != nil {
+			t.Errorf("Expected offline approval to satisfy dual control, got %v", err)
+		}
+		if err := enforcer.EnforceSecondLevelApproval(sign("cfo", "legal"), tok, "payments:wire"); !errors.Is(err, ErrApproverRole) {
+			t.Errorf("Expected a role outside the rules rejected, got %v", err)
+		}
+		if err := enforcer.EnforceSecondLevelApproval(sign("agent-1", "finance"), tok, "payments:wire"); !errors.Is(err, ErrSelfApproval) {
+			t.Errorf("Expected ErrSelfApproval, got %v", err)
+		}
+	})
+
+	t.Run("Unregistered Role", func(t *testing.T) {
+		unverified := NewStandardAuthorizationEnforcer(store, nil, nil)
+		unverified.SetJurisdictionRules(rules)
+		unverified.SetOfflineApprovals(approvals)
+		if err := unverified.EnforceSecondLevelApproval(sign("cfo", "finance"), tok, "payments:wire"); !errors.Is(err, ErrApproverRole) {
+			t.Errorf("Expected a claimed role without a registry rejected, got %v", err)
+		}
+	})
+
+	t.Run("Stored Approval", func(t *testing.T) {
+		store.approval = &common.SecondLevelApproval{
+			PrimaryApprover:       "cfo",
+			SecondaryApprover:     "agent-1",
+			SecondaryRole:         "finance",
+			SecondaryApprovalTime: time.Now(),
+			ApprovalDuration:      time.Hour,
+		}
+		if err := enforcer.EnforceSecondLevelApproval(ctx, tok, "payments:wire"); !errors.Is(err, ErrSelfApproval) {
+			t.Errorf("Expected ErrSelfApproval, got %v", err)
+		}
+		store.approval.SecondaryApprover = "clerk"
+		if err := enforcer.EnforceSecondLevelApproval(ctx, tok, "payments:wire"); !errors.Is(err, ErrApproverRole) {
+			t.Errorf("Expected a role the registry does not confirm rejected, got %v", err)
+		}
+		store.approval.SecondaryApprover = "cfo"
+		store.approval.PrimaryApprover = "ceo"
+		if err := enforcer.EnforceSecondLevelApproval(ctx, tok, "payments:wire"); err != nil {
+			t.Errorf("Expected stored approval accepted, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,282 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash"
+	"net/http"
+	"strings"
+	"time"
+
+	autherrors "github.com/Gimel-Foundation/gauth/pkg/errors"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// OpenID Connect scopes (OIDC Core §5.4)
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+	ScopePhone   = "phone"
+)
+
+// UserInfo holds the standard claims of an end user (OIDC Core §5.1).
+// Empty fields are omitted.
+type UserInfo struct {
+	Subject             string `json:"sub"`
+	Name                string `json:"name,omitempty"`
+	GivenName           string `json:"given_name,omitempty"`
+	FamilyName          string `json:"family_name,omitempty"`
+	PreferredUsername   string `json:"preferred_username,omitempty"`
+	Picture             string `json:"picture,omitempty"`
+	Locale              string `json:"locale,omitempty"`
+	UpdatedAt           int64  `json:"updated_at,omitempty"`
+	Email               string `json:"email,omitempty"`
+	EmailVerified       bool   `json:"email_verified,omitempty"`
+	PhoneNumber         string `json:"phone_number,omitempty"`
+	PhoneNumberVerified bool   `json:"phone_number_verified,omitempty"`
+}
+
+// UserInfoProvider looks up the claims of an end user
+type UserInfoProvider interface {
+	UserInfo(ctx context.Context, subject string) (*UserInfo, error)
+}
+
+// UserInfoFunc adapts a function to UserInfoProvider
+type UserInfoFunc func(ctx context.Context, subject string) (*UserInfo, error)
+
+// UserInfo calls f
+func (f UserInfoFunc) UserInfo(ctx context.Context, subject string) (*UserInfo, error) {
+	return f(ctx, subject)
+}
+
+// OIDCConfig turns an AuthorizationServer into an OpenID Connect provider
+type OIDCConfig struct {
+	// Keys signs ID tokens and is published at /jwks
+	Keys *token.KeySet
+
+	// UserInfo supplies profile, email and phone claims (optional; without
+	// it only sub is released)
+	UserInfo UserInfoProvider
+
+	// IDTokenTTL is the lifetime of ID tokens (default: 10m)
+	IDTokenTTL time.Duration
+
+	// RequireNonce refuses openid authorization requests without a nonce
+	RequireNonce bool
+
+	// ClaimsInIDToken also puts the scope's user claims in the ID token,
+	// for clients that do not call the UserInfo endpoint
+	ClaimsInIDToken bool
+}
+
+// DiscoveryDocument is the OpenID Provider metadata (OIDC Discovery §3)
+type DiscoveryDocument struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// Discovery returns the provider metadata. Endpoints are relative to the
+// issuer, so Handler must be served at the issuer URL.
+func (s *AuthorizationServer) Discovery() *DiscoveryDocument {
+	issuer := strings.TrimSuffix(s.config.Issuer, "/")
+	var algs []string
+	if s.config.OIDC != nil {
+		for _, k := range s.config.OIDC.Keys.Keys() {
+			if !contains(algs, string(k.Algorithm)) {
+				algs = append(algs, string(k.Algorithm))
+			}
+		}
+	}
+	methods := []string{PKCEMethodS256}
+	if s.config.AllowPlainPKCE {
+		methods = append(methods, PKCEMethodPlain)
+	}
+	return &DiscoveryDocument{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/authorize",
+		TokenEndpoint:                     issuer + "/token",
+		UserInfoEndpoint:                  issuer + "/userinfo",
+		RevocationEndpoint:                issuer + "/revoke",
+		JWKSURI:                           issuer + "/jwks",
+		ScopesSupported:                   []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopePhone},
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{GrantTypeAuthCode, GrantTypeRefreshToken, GrantTypeClientCreds},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  algs,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     methods,
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "at_hash",
+			"name", "given_name", "family_name", "preferred_username", "picture", "locale", "updated_at",
+			"email", "email_verified", "phone_number", "phone_number_verified",
+		},
+	}
+}
+
+// HandleDiscovery serves /.well-known/openid-configuration
+func (s *AuthorizationServer) HandleDiscovery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		autherrors.New(autherrors.ErrInvalidRequest, "method not allowed").
+			WithHTTPInfo(r.URL.Path, r.Method, http.StatusMethodNotAllowed, "").
+			WriteHTTP(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	_ = json.NewEncoder(w).Encode(s.Discovery())
+}
+
+// HandleUserInfo serves the UserInfo endpoint (OIDC Core §5.3). The access
+// token must carry the openid scope; the claims released follow its other
+// scopes.
+func (s *AuthorizationServer) HandleUserInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		autherrors.New(autherrors.ErrInvalidRequest, "method not allowed").
+			WithHTTPInfo(r.URL.Path, r.Method, http.StatusMethodNotAllowed, "").
+			WriteHTTP(w)
+		return
+	}
+	ctx := r.Context()
+	value, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || value == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		autherrors.New(autherrors.ErrInvalidToken, "a bearer access token is required").WriteHTTP(w)
+		return
+	}
+	t, err := s.config.Tokens.Parse(ctx, value)
+	if err == nil {
+		err = s.config.Tokens.Validate(ctx, t)
+	}
+	if err != nil || t.Type != token.Access {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		autherrors.New(autherrors.ErrInvalidToken, "invalid access token").WithCause(err).WriteHTTP(w)
+		return
+	}
+	if !contains(t.Scopes, ScopeOpenID) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		autherrors.New(autherrors.ErrInsufficientScope, "the access token lacks the openid scope").WriteHTTP(w)
+		return
+	}
+	claims, err := s.userClaims(ctx, t.Subject, t.Scopes)
+	if err != nil {
+		autherrors.New(autherrors.ErrServerError, "failed to look up user").WithCause(err).WriteHTTP(w)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(claims)
+}
+
+// idToken signs the ID token for a redeemed code
+func (s *AuthorizationServer) idToken(ctx context.Context, client *OAuthClient, code *authorizationCode, access *token.Token) (string, error) {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"sub": code.subject,
+	}
+	if s.config.OIDC.ClaimsInIDToken {
+		user, err := s.userClaims(ctx, code.subject, code.scopes)
+		if err != nil {
+			return "", err
+		}
+		claims = user
+	}
+	claims["iss"] = strings.TrimSuffix(s.config.Issuer, "/")
+	claims["aud"] = client.ID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(s.config.OIDC.IDTokenTTL).Unix()
+	claims["auth_time"] = code.authTime.Unix()
+	if code.nonce != "" {
+		claims["nonce"] = code.nonce
+	}
+	_, _, alg, err := s.config.OIDC.Keys.Active()
+	if err != nil {
+		return "", err
+	}
+	claims["at_hash"] = halfHash(alg, access.Value)
+	signed, _, err := s.config.OIDC.Keys.SignClaims(claims)
+	return signed, err
+}
+
+// userClaims returns sub plus the standard claims released by the scopes
+func (s *AuthorizationServer) userClaims(ctx context.Context, subject string, scopes []string) (map[string]interface{}, error) {
+	claims := map[string]interface{}{"sub": subject}
+	if s.config.OIDC.UserInfo == nil {
+		return claims, nil
+	}
+	info, err := s.config.OIDC.UserInfo.UserInfo(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]interface{}
+	if err := json.Unmarshal(raw, &all); err != nil {
+		return nil, err
+	}
+	released := map[string][]string{
+		ScopeProfile: {"name", "given_name", "family_name", "preferred_username", "picture", "locale", "updated_at"},
+		ScopeEmail:   {"email", "email_verified"},
+		ScopePhone:   {"phone_number", "phone_number_verified"},
+	}
+	for _, scope := range scopes {
+		for _, name := range released[scope] {
+			if v, ok := all[name]; ok {
+				claims[name] = v
+			}
+		}
+	}
+	return claims, nil
+}
+
+// validateOIDC checks the OIDC configuration and applies its defaults
+func validateOIDC(config *AuthorizationServerConfig) error {
+	if config.OIDC == nil {
+		return nil
+	}
+	if config.OIDC.Keys == nil {
+		return errors.New("OIDC needs a key set to sign ID tokens")
+	}
+	if config.Issuer == "" {
+		return errors.New("OIDC needs an issuer URL")
+	}
+	if config.OIDC.IDTokenTTL <= 0 {
+		config.OIDC.IDTokenTTL = 10 * time.Minute
+	}
+	return nil
+}
+
+// halfHash is the at_hash of an access token: the left half of its hash
+// with the ID token algorithm's hash function (OIDC Core §3.1.3.6)
+func halfHash(alg token.Algorithm, value string) string {
+	var h hash.Hash
+	switch alg {
+	case token.EdDSA:
+		h = sha512.New()
+	default:
+		h = sha256.New()
+	}
+	h.Write([]byte(value))
+	sum := h.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestOIDCProvider(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	tokens := token.NewService(token.Config{SigningKey: key, ValidityPeriod: time.Hour}, token.NewMemoryStore(time.Hour))
+	keys := token.NewKeySet()
+	if _, err := keys.Add(key, token.RS256, "idp-1"); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+	clients := NewMemoryClientRegistry()
+	const redirectURI = "https://rp.example.com/callback"
+	if _, err := clients.Register(OAuthClient{ID: "rp", RedirectURIs: []string{redirectURI}}, false); err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	authTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+	server, err := NewAuthorizationServer(AuthorizationServerConfig{
+		Tokens:  tokens,
+		Clients: clients,
+		Consent: func(http.ResponseWriter, *http.Request, *ServiceAuthorizationRequest, *OAuthClient) (*ConsentDecision, error) {
+			return &ConsentDecision{Subject: "alice", AuthTime: authTime}, nil
+		},
+		Issuer: ts.URL,
+		OIDC: &OIDCConfig{
+			Keys: keys,
+			UserInfo: UserInfoFunc(func(_ context.Context, subject string) (*UserInfo, error) {
+				return &UserInfo{Subject: subject, Name: "Alice Example", Email: "alice@example.com", EmailVerified: true, PhoneNumber: "+1 555 0100"}, nil
+			}),
+			RequireNonce: true,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewAuthorizationServer() error: %v", err)
+	}
+	mux.Handle("/", server.Handler())
+
+	if _, err := NewAuthorizationServer(AuthorizationServerConfig{Tokens: tokens, Clients: clients,
+		Consent: func(http.ResponseWriter, *http.Request, *ServiceAuthorizationRequest, *OAuthClient) (*ConsentDecision, error) {
+			return nil, nil
+		}, OIDC: &OIDCConfig{}}); err == nil {
+		t.Error("Expected OIDC without keys to be refused")
+	}
+
+	noFollow := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	verifier := strings.Repeat("n", 48)
+	login := func(t *testing.T, scope, nonce string) (url.Values, OAuth2TokenResponse) {
+		t.Helper()
+		params := url.Values{
+			"client_id": {"rp"}, "response_type": {"code"}, "redirect_uri": {redirectURI}, "scope": {scope},
+			"state": {"s"}, "code_challenge": {PKCEChallenge(verifier)}, "code_challenge_method": {PKCEMethodS256},
+		}
+		if nonce != "" {
+			params.Set("nonce", nonce)
+		}
+		resp, err := noFollow.Get(ts.URL + "/authorize?" + params.Encode())
+		if err != nil {
+			t.Fatalf("authorize error: %v", err)
+		}
+		resp.Body.Close()
+		loc, _ := url.Parse(resp.Header.Get("Location"))
+		if loc.Query().Get("code") == "" {
+			return loc.Query(), OAuth2TokenResponse{}
+		}
+		resp, err = http.PostForm(ts.URL+"/token", url.Values{
+			"grant_type": {GrantTypeAuthCode}, "code": {loc.Query().Get("code")}, "redirect_uri": {redirectURI},
+			"client_id": {"rp"}, "code_verifier": {verifier},
+		})
+		if err != nil {
+			t.Fatalf("token error: %v", err)
+		}
+		defer resp.Body.Close()
+		var tr OAuth2TokenResponse
+		_ = json.NewDecoder(resp.Body).Decode(&tr)
+		return loc.Query(), tr
+	}
+
+	t.Run("Discovery", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "/.well-known/openid-configuration")
+		if err != nil {
+			t.Fatalf("discovery error: %v", err)
+		}
+		defer resp.Body.Close()
+		var doc DiscoveryDocument
+		_ = json.NewDecoder(resp.Body).Decode(&doc)
+		if doc.Issuer != ts.URL || doc.JWKSURI != ts.URL+"/jwks" || len(doc.IDTokenSigningAlgValuesSupported) != 1 ||
+			doc.IDTokenSigningAlgValuesSupported[0] != "RS256" {
+			t.Errorf("Unexpected discovery document %+v", doc)
+		}
+		jwks, err := http.Get(doc.JWKSURI)
+		if err != nil || jwks.StatusCode != http.StatusOK {
+			t.Fatalf("Expected the key set at jwks_uri, got %v", err)
+		}
+		jwks.Body.Close()
+	})
+
+	t.Run("ID Token", func(t *testing.T) {
+		_, tr := login(t, "openid email", "n-0S6_WzA2Mj")
+		if tr.IDToken == "" {
+			t.Fatalf("Expected an ID token, got %+v", tr)
+		}
+		parsed, err := jwt.Parse(tr.IDToken, func(*jwt.Token) (interface{}, error) { return &key.PublicKey, nil },
+			jwt.WithIssuer(ts.URL), jwt.WithAudience("rp"))
+		if err != nil {
+			t.Fatalf("Expected a verifiable ID token, got %v", err)
+		}
+		claims := parsed.Claims.(jwt.MapClaims)
+		if claims["sub"] != "alice" || claims["nonce"] != "n-0S6_WzA2Mj" || parsed.Header["kid"] != "idp-1" {
+			t.Errorf("Unexpected ID token claims %v", claims)
+		}
+		if claims["auth_time"] != float64(authTime.Unix()) || claims["at_hash"] != halfHash(token.RS256, tr.AccessToken) {
+			t.Errorf("Expected auth_time and at_hash, got %v", claims)
+		}
+		if _, ok := claims["email"]; ok {
+			t.Error("Expected user claims only at the UserInfo endpoint by default")
+		}
+	})
+
+	t.Run("Nonce And Scope", func(t *testing.T) {
+		if q, _ := login(t, "openid", ""); q.Get("error") != "invalid_request" {
+			t.Errorf("Expected a missing nonce to be refused, got %v", q)
+		}
+		if _, tr := login(t, "read", ""); tr.AccessToken == "" || tr.IDToken != "" {
+			t.Errorf("Expected plain OAuth2 without openid, got %+v", tr)
+		}
+	})
+
+	t.Run("UserInfo", func(t *testing.T) {
+		userinfo := func(access string) (int, map[string]interface{}) {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+"/userinfo", nil)
+			req.Header.Set("Authorization", "Bearer "+access)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("userinfo error: %v", err)
+			}
+			defer resp.Body.Close()
+			var body map[string]interface{}
+			_ = json.NewDecoder(resp.Body).Decode(&body)
+			return resp.StatusCode, body
+		}
+		_, tr := login(t, "openid email", "n")
+		status, claims := userinfo(tr.AccessToken)
+		if status != http.StatusOK || claims["sub"] != "alice" || claims["email"] != "alice@example.com" || claims["email_verified"] != true {
+			t.Errorf("Unexpected userinfo %d %v", status, claims)
+		}
+		if _, ok := claims["phone_number"]; ok {
+			t.Error("Expected phone claims to need the phone scope")
+		}
+		_, plain := login(t, "read", "")
+		if status, _ := userinfo(plain.AccessToken); status != http.StatusForbidden {
+			t.Errorf("Expected a token without openid to be refused, got %d", status)
+		}
+		if status, _ := userinfo("garbage"); status != http.StatusUnauthorized {
+			t.Errorf("Expected an invalid token to be refused, got %d", status)
+		}
+	})
+}
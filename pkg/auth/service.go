@@ -143,8 +143,8 @@ type Service interface {
 	// Validate validates a token
 	Validate(ctx context.Context, tokenStr string, requiredScopes []string) (*token.Token, error)
 
-	// Revoke revokes a token
-	Revoke(ctx context.Context, tokenStr string, reason string) error
+	// Revoke revokes a token for the given reason
+	Revoke(ctx context.Context, tokenStr string, reason token.RevocationReason) error
 
 	// Introspect provides detailed token information
 	Introspect(ctx context.Context, tokenStr string) (*token.Token, error)
@@ -255,14 +255,14 @@ func (s *DefaultService) Validate(ctx context.Context, tokenStr string, _ []stri
 }
 
 // Revoke implements the Service.Revoke method
-func (s *DefaultService) Revoke(ctx context.Context, tokenStr string, _ string) error {
+func (s *DefaultService) Revoke(ctx context.Context, tokenStr string, reason token.RevocationReason) error {
 	// Retrieve the token by ID
 	t, err := s.tokenService.GetToken(ctx, tokenStr)
 	if err != nil {
 		return err
 	}
 	// Revoke the token
-	return s.tokenService.Revoke(ctx, t)
+	return s.tokenService.Revoke(ctx, t, reason)
 }
 
 // Introspect implements the Service.Introspect method
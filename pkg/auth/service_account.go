@@ -0,0 +1,363 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// Service account errors
+var (
+	// ErrServiceAccountNotFound indicates the service account does not exist
+	ErrServiceAccountNotFound = errors.New("service account not found")
+
+	// ErrServiceAccountInactive indicates the account is suspended or disabled
+	ErrServiceAccountInactive = errors.New("service account is not active")
+
+	// ErrInvalidServiceAccountSecret indicates the presented credential is unknown or expired
+	ErrInvalidServiceAccountSecret = errors.New("invalid service account credential")
+)
+
+// ServiceAccountState is the lifecycle state of a service account
+type ServiceAccountState string
+
+const (
+	// ServiceAccountActive accounts may obtain tokens
+	ServiceAccountActive ServiceAccountState = "active"
+
+	// ServiceAccountSuspended accounts are temporarily blocked; their tokens are revoked
+	ServiceAccountSuspended ServiceAccountState = "suspended"
+
+	// ServiceAccountDisabled accounts are permanently retired; their tokens are revoked
+	ServiceAccountDisabled ServiceAccountState = "disabled"
+)
+
+// serviceAccountSubjectPrefix prefixes the token subject of service account tokens
+const serviceAccountSubjectPrefix = "serviceaccount:"
+
+// ServiceAccountCredential is a rotating secret for a service account. Only
+// the hash of the secret is kept.
+type ServiceAccountCredential struct {
+	ID         string     `json:"id"`
+	SecretHash string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// ServiceAccount is a non-human principal bound to a workload
+type ServiceAccount struct {
+	ID          string                     `json:"id"`
+	Name        string                     `json:"name"`
+	Workload    string                     `json:"workload"`
+	Scopes      []string                   `json:"scopes"`
+	State       ServiceAccountState        `json:"state"`
+	Credentials []ServiceAccountCredential `json:"credentials"`
+	RotatedAt   time.Time                  `json:"rotated_at"`
+	CreatedAt   time.Time                  `json:"created_at"`
+	UpdatedAt   time.Time                  `json:"updated_at"`
+}
+
+// Subject returns the token subject used for the account's tokens
+func (a *ServiceAccount) Subject() string {
+	return serviceAccountSubjectPrefix + a.ID
+}
+
+// ServiceAccountConfig configures service account management
+type ServiceAccountConfig struct {
+	// Tokens issues and revokes service account tokens
+	Tokens token.ServiceAPI
+
+	// TokenTTL is the lifetime of issued tokens (default: 5m)
+	TokenTTL time.Duration
+
+	// RotationPeriod is how often credentials are rotated (default: 24h)
+	RotationPeriod time.Duration
+
+	// RotationOverlap keeps the previous credential valid after rotation so
+	// workloads can pick up the new one (default: 1h)
+	RotationOverlap time.Duration
+}
+
+// ServiceAccountManager manages service accounts, their credentials and tokens
+type ServiceAccountManager struct {
+	config   ServiceAccountConfig
+	mu       sync.RWMutex
+	accounts map[string]*ServiceAccount
+}
+
+// NewServiceAccountManager creates a service account manager
+func NewServiceAccountManager(config ServiceAccountConfig) (*ServiceAccountManager, error) {
+	if config.Tokens == nil {
+		return nil, errors.New("token service is required")
+	}
+	if config.TokenTTL <= 0 {
+		config.TokenTTL = 5 * time.Minute
+	}
+	if config.RotationPeriod <= 0 {
+		config.RotationPeriod = 24 * time.Hour
+	}
+	if config.RotationOverlap <= 0 {
+		config.RotationOverlap = time.Hour
+	}
+	return &ServiceAccountManager{
+		config:   config,
+		accounts: make(map[string]*ServiceAccount),
+	}, nil
+}
+
+// Create registers a service account and returns its first secret. The
+// secret is returned only once.
+func (m *ServiceAccountManager) Create(name, workload string, scopes []string) (*ServiceAccount, string, error) {
+	if name == "" || workload == "" {
+		return nil, "", errors.New("name and workload are required")
+	}
+
+	cred, secret, err := newServiceAccountCredential()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	account := &ServiceAccount{
+		ID:          token.NewID(),
+		Name:        name,
+		Workload:    workload,
+		Scopes:      append([]string(nil), scopes...),
+		State:       ServiceAccountActive,
+		Credentials: []ServiceAccountCredential{cred},
+		RotatedAt:   now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	m.mu.Lock()
+	m.accounts[account.ID] = account
+	m.mu.Unlock()
+
+	return account.clone(), secret, nil
+}
+
+// Get returns a copy of a service account by ID
+func (m *ServiceAccountManager) Get(id string) (*ServiceAccount, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	account, ok := m.accounts[id]
+	if !ok {
+		return nil, ErrServiceAccountNotFound
+	}
+	return account.clone(), nil
+}
+
+// Rotate issues a new credential and schedules the previous ones to expire
+// after RotationOverlap. The new secret is returned only once.
+func (m *ServiceAccountManager) Rotate(id string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	account, ok := m.accounts[id]
+	if !ok {
+		return "", ErrServiceAccountNotFound
+	}
+	if account.State != ServiceAccountActive {
+		return "", ErrServiceAccountInactive
+	}
+	return m.rotateLocked(account, time.Now())
+}
+
+func (m *ServiceAccountManager) rotateLocked(account *ServiceAccount, now time.Time) (string, error) {
+	cred, secret, err := newServiceAccountCredential()
+	if err != nil {
+		return "", err
+	}
+
+	expires := now.Add(m.config.RotationOverlap)
+	kept := account.Credentials[:0]
+	for _, c := range account.Credentials {
+		if c.ExpiresAt != nil && now.After(*c.ExpiresAt) {
+			continue
+		}
+		if c.ExpiresAt == nil || c.ExpiresAt.After(expires) {
+			c.ExpiresAt = &expires
+		}
+		kept = append(kept, c)
+	}
+	account.Credentials = append(kept, cred)
+	account.RotatedAt = now
+	account.UpdatedAt = now
+	return secret, nil
+}
+
+// RotateDue rotates every active account whose credential is older than
+// RotationPeriod and returns the new secrets by account ID
+func (m *ServiceAccountManager) RotateDue(now time.Time) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rotated := make(map[string]string)
+	for id, account := range m.accounts {
+		if account.State != ServiceAccountActive || now.Sub(account.RotatedAt) < m.config.RotationPeriod {
+			continue
+		}
+		secret, err := m.rotateLocked(account, now)
+		if err != nil {
+			return rotated, fmt.Errorf("failed to rotate service account %s: %w", id, err)
+		}
+		rotated[id] = secret
+	}
+	return rotated, nil
+}
+
+// RunRotation calls RotateDue every interval until ctx is cancelled and
+// hands new secrets to deliver (e.g. a secret store the agent reads from)
+func (m *ServiceAccountManager) RunRotation(ctx context.Context, interval time.Duration, deliver func(accountID, secret string), onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			rotated, err := m.RotateDue(now)
+			if err != nil && onError != nil {
+				onError(err)
+			}
+			for id, secret := range rotated {
+				deliver(id, secret)
+			}
+		}
+	}
+}
+
+// IssueToken authenticates the account with its secret and issues a
+// short-lived access token. Requested scopes must be a subset of the
+// account's scopes; an empty request yields all of them. The read lock is
+// held until the token is stored, so SetState cannot suspend the account
+// in between and miss the new token when it revokes.
+func (m *ServiceAccountManager) IssueToken(ctx context.Context, id, secret string, scopes []string) (*token.Token, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	account, ok := m.accounts[id]
+	if !ok {
+		return nil, ErrServiceAccountNotFound
+	}
+	if account.State != ServiceAccountActive {
+		return nil, ErrServiceAccountInactive
+	}
+	if !account.checkSecret(secret, time.Now()) {
+		return nil, ErrInvalidServiceAccountSecret
+	}
+	allowed := account.Scopes
+
+	if len(scopes) == 0 {
+		scopes = allowed
+	}
+	for _, s := range scopes {
+		if !contains(allowed, s) {
+			return nil, NewError(ErrScopeExceeded, fmt.Sprintf("scope %q not granted to service account", s), nil)
+		}
+	}
+
+	now := time.Now()
+	return m.config.Tokens.Issue(ctx, &token.Token{
+		ID:        token.NewID(),
+		Type:      token.Access,
+		Subject:   account.Subject(),
+		Scopes:    append([]string(nil), scopes...),
+		IssuedAt:  now,
+		NotBefore: now,
+		ExpiresAt: now.Add(m.config.TokenTTL),
+		Metadata: &token.Metadata{
+			AppID:  id,
+			Labels: map[string]string{"workload": account.Workload, "principal_type": "service_account"},
+		},
+	})
+}
+
+// SetState changes the account's lifecycle state. Suspending or disabling an
+// account revokes all of its outstanding tokens.
+func (m *ServiceAccountManager) SetState(ctx context.Context, id string, state ServiceAccountState) error {
+	m.mu.Lock()
+	account, ok := m.accounts[id]
+	if !ok {
+		m.mu.Unlock()
+		return ErrServiceAccountNotFound
+	}
+	account.State = state
+	account.UpdatedAt = time.Now()
+	subject := account.Subject()
+	m.mu.Unlock()
+
+	if state == ServiceAccountActive {
+		return nil
+	}
+	return m.revokeTokens(ctx, subject)
+}
+
+func (m *ServiceAccountManager) revokeTokens(ctx context.Context, subject string) error {
+	tokens, err := m.config.Tokens.List(ctx, token.Filter{Subject: subject})
+	if err != nil {
+		return fmt.Errorf("failed to list service account tokens: %w", err)
+	}
+	for _, t := range tokens {
+		if err := m.config.Tokens.Revoke(ctx, t, token.ReasonPolicyChange); err != nil {
+			return fmt.Errorf("failed to revoke token %s: %w", t.ID, err)
+		}
+	}
+	return nil
+}
+
+// clone returns a copy that shares no slices with the account
+func (a *ServiceAccount) clone() *ServiceAccount {
+	out := *a
+	out.Scopes = append([]string(nil), a.Scopes...)
+	out.Credentials = make([]ServiceAccountCredential, len(a.Credentials))
+	for i, c := range a.Credentials {
+		if c.ExpiresAt != nil {
+			expires := *c.ExpiresAt
+			c.ExpiresAt = &expires
+		}
+		out.Credentials[i] = c
+	}
+	return &out
+}
+
+func (a *ServiceAccount) checkSecret(secret string, now time.Time) bool {
+	hash := hashServiceAccountSecret(secret)
+	for _, c := range a.Credentials {
+		if c.ExpiresAt != nil && now.After(*c.ExpiresAt) {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(c.SecretHash), []byte(hash)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func newServiceAccountCredential() (ServiceAccountCredential, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return ServiceAccountCredential{}, "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	secret := base64.RawURLEncoding.EncodeToString(buf)
+	return ServiceAccountCredential{
+		ID:         token.NewID(),
+		SecretHash: hashServiceAccountSecret(secret),
+		CreatedAt:  time.Now(),
+	}, secret, nil
+}
+
+func hashServiceAccountSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
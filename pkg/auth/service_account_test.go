@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+func TestServiceAccounts(t *testing.T) {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	store := token.NewMemoryStore(time.Hour)
+	tokens := token.NewService(token.Config{SigningKey: key}, store)
+
+	mgr, err := NewServiceAccountManager(ServiceAccountConfig{
+		Tokens:          tokens,
+		TokenTTL:        2 * time.Minute,
+		RotationPeriod:  time.Hour,
+		RotationOverlap: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewServiceAccountManager() error: %v", err)
+	}
+
+	account, secret, err := mgr.Create("billing-exporter", "billing/exporter", []string{"invoices:read", "metrics:write"})
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	t.Run("Issue Scoped Token", func(t *testing.T) {
+		tok, err := mgr.IssueToken(ctx, account.ID, secret, []string{"invoices:read"})
+		if err != nil {
+			t.Fatalf("IssueToken() error: %v", err)
+		}
+		if tok.Subject != account.Subject() || len(tok.Scopes) != 1 {
+			t.Errorf("Unexpected token %+v", tok)
+		}
+		if ttl := time.Until(tok.ExpiresAt); ttl > 2*time.Minute {
+			t.Errorf("Expected short TTL, got %s", ttl)
+		}
+
+		if _, err := mgr.IssueToken(ctx, account.ID, secret, []string{"admin"}); !IsError(err, ErrScopeExceeded) {
+			t.Errorf("Expected scope error, got %v", err)
+		}
+		if _, err := mgr.IssueToken(ctx, account.ID, "wrong", nil); !errors.Is(err, ErrInvalidServiceAccountSecret) {
+			t.Errorf("Expected invalid secret error, got %v", err)
+		}
+	})
+
+	t.Run("Rotation Overlap", func(t *testing.T) {
+		rotated, err := mgr.RotateDue(time.Now().Add(2 * time.Hour))
+		if err != nil {
+			t.Fatalf("RotateDue() error: %v", err)
+		}
+		newSecret, ok := rotated[account.ID]
+		if !ok {
+			t.Fatal("Expected account to be rotated")
+		}
+		if _, err := mgr.IssueToken(ctx, account.ID, newSecret, nil); err != nil {
+			t.Errorf("Expected new secret to work, got %v", err)
+		}
+		// The old secret stays valid during the overlap window
+		if _, err := mgr.IssueToken(ctx, account.ID, secret, nil); err != nil {
+			t.Errorf("Expected old secret to work during overlap, got %v", err)
+		}
+	})
+
+	t.Run("Get Returns A Copy", func(t *testing.T) {
+		got, err := mgr.Get(account.ID)
+		if err != nil {
+			t.Fatalf("Get() error: %v", err)
+		}
+		got.State = ServiceAccountDisabled
+		got.Scopes[0] = "admin"
+		got.Credentials = nil
+		again, _ := mgr.Get(account.ID)
+		if again.State != ServiceAccountActive || again.Scopes[0] != "invoices:read" || len(again.Credentials) == 0 {
+			t.Errorf("Expected the stored account unchanged, got %+v", again)
+		}
+	})
+
+	t.Run("Suspend While Issuing", func(t *testing.T) {
+		racer, racerSecret, err := mgr.Create("racer", "batch/racer", []string{"jobs:run"})
+		if err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 50; i++ {
+				if _, err := mgr.IssueToken(ctx, racer.ID, racerSecret, nil); err != nil {
+					return
+				}
+			}
+		}()
+		if err := mgr.SetState(ctx, racer.ID, ServiceAccountSuspended); err != nil {
+			t.Fatalf("SetState() error: %v", err)
+		}
+		<-done
+		// No token issued around the suspension may outlive it
+		remaining, err := tokens.List(ctx, token.Filter{Subject: racer.Subject()})
+		if err != nil {
+			t.Fatalf("List() error: %v", err)
+		}
+		if len(remaining) != 0 {
+			t.Errorf("Expected no tokens after suspension, %d remain", len(remaining))
+		}
+	})
+
+	t.Run("Suspension Revokes Tokens", func(t *testing.T) {
+		if err := mgr.SetState(ctx, account.ID, ServiceAccountSuspended); err != nil {
+			t.Fatalf("SetState() error: %v", err)
+		}
+		remaining, err := tokens.List(ctx, token.Filter{Subject: account.Subject()})
+		if err != nil {
+			t.Fatalf("List() error: %v", err)
+		}
+		if len(remaining) != 0 {
+			t.Errorf("Expected all tokens revoked, %d remain", len(remaining))
+		}
+		if _, err := mgr.IssueToken(ctx, account.ID, secret, nil); !errors.Is(err, ErrServiceAccountInactive) {
+			t.Errorf("Expected inactive error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,404 @@
+// Package session manages server-side sessions for signed-in principals.
+//
+// Sessions have two lifetimes: an idle timeout that slides forward with each
+// use, and an absolute timeout from sign-in that it never passes. The number
+// of concurrent sessions per subject can be capped, and all sessions of a
+// subject are revoked when their credentials change:
+//
+//	manager, _ := session.NewManager(session.Config{
+//	    Store:           session.NewMemoryStore(),
+//	    IdleTimeout:     30 * time.Minute,
+//	    AbsoluteTimeout: 12 * time.Hour,
+//	    MaxPerSubject:   5,
+//	})
+//	s, err := manager.Create(ctx, "alice", nil)
+//	s, err = manager.Touch(ctx, s.ID) // on every request
+//	_, err = manager.CredentialsChanged(ctx, "alice", s.ID)
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/audit"
+)
+
+// Session errors
+var (
+	// ErrNotFound indicates the session does not exist or was revoked
+	ErrNotFound = errors.New("session not found")
+
+	// ErrExpired indicates the session passed its idle or absolute timeout
+	ErrExpired = errors.New("session expired")
+
+	// ErrLimitReached indicates the subject has the maximum number of sessions
+	ErrLimitReached = errors.New("concurrent session limit reached")
+
+	// ErrInvalidConfig indicates the manager configuration is unusable
+	ErrInvalidConfig = errors.New("invalid session configuration")
+
+	// ErrConflict indicates the session changed since it was read
+	ErrConflict = errors.New("session was modified concurrently")
+)
+
+// Session audit event type and actions
+const (
+	TypeSession          = "session"
+	ActionSessionCreated = "session_created"
+	ActionSessionRevoked = "session_revoked"
+	ActionSessionEvicted = "session_evicted"
+)
+
+// Revocation reasons recorded in audit entries
+const (
+	ReasonLogout             = "logout"
+	ReasonCredentialsChanged = "credentials_changed"
+	ReasonAdministrative     = "administrative"
+)
+
+// LimitPolicy decides what happens when a subject exceeds MaxPerSubject
+type LimitPolicy string
+
+const (
+	// EvictOldest revokes the least recently used sessions to make room
+	EvictOldest LimitPolicy = "evict_oldest"
+
+	// RejectNew refuses the new session with ErrLimitReached
+	RejectNew LimitPolicy = "reject_new"
+)
+
+// Session is a server-side session
+type Session struct {
+	ID        string    `json:"id"`
+	Subject   string    `json:"subject"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// LastSeenAt is when the session was last used
+	LastSeenAt time.Time `json:"last_seen_at"`
+
+	// ExpiresAt is when the session ends unless used again; it never passes
+	// AbsoluteExpiresAt
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// AbsoluteExpiresAt is when the session ends regardless of use
+	AbsoluteExpiresAt time.Time `json:"absolute_expires_at"`
+
+	// Metadata holds application data such as the client IP or user agent
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Version counts updates, so stores can detect concurrent changes
+	Version int64 `json:"version"`
+}
+
+// Expired reports whether the session has ended at the given time
+func (s *Session) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// Store persists sessions
+type Store interface {
+	// Save creates or replaces a session. ttl is how long the store needs
+	// to keep it.
+	Save(ctx context.Context, s *Session, ttl time.Duration) error
+
+	// Update replaces a session only if it still exists with s.Version, and
+	// increments s.Version. It returns ErrNotFound if the session was
+	// deleted and ErrConflict if it was updated since it was read; it never
+	// re-creates a deleted session.
+	Update(ctx context.Context, s *Session, ttl time.Duration) error
+
+	// Get returns a session or ErrNotFound
+	Get(ctx context.Context, id string) (*Session, error)
+
+	// Delete removes a session; deleting a missing session is not an error
+	Delete(ctx context.Context, id string) error
+
+	// ListBySubject returns the stored sessions of a subject
+	ListBySubject(ctx context.Context, subject string) ([]*Session, error)
+}
+
+// Config configures a Manager
+type Config struct {
+	// Store keeps the sessions (required)
+	Store Store
+
+	// IdleTimeout ends a session that is not used for this long (default: 30m)
+	IdleTimeout time.Duration
+
+	// AbsoluteTimeout ends a session this long after it was created, however
+	// active it is (default: 12h)
+	AbsoluteTimeout time.Duration
+
+	// TouchInterval is the least time between two writes of LastSeenAt, so
+	// busy sessions do not write on every request (default: 1m, at most a
+	// tenth of IdleTimeout)
+	TouchInterval time.Duration
+
+	// MaxPerSubject caps concurrent sessions per subject (0 = unlimited)
+	MaxPerSubject int
+
+	// LimitPolicy applies when MaxPerSubject is reached (default: EvictOldest)
+	LimitPolicy LimitPolicy
+
+	// Audit records session creation and revocation (optional)
+	Audit audit.Storage
+
+	// Now returns the current time (default: time.Now)
+	Now func() time.Time
+}
+
+// Manager creates, validates and revokes sessions
+type Manager struct {
+	config Config
+}
+
+// NewManager creates a session manager
+func NewManager(config Config) (*Manager, error) {
+	if config.Store == nil {
+		return nil, fmt.Errorf("%w: a store is required", ErrInvalidConfig)
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = 30 * time.Minute
+	}
+	if config.AbsoluteTimeout <= 0 {
+		config.AbsoluteTimeout = 12 * time.Hour
+	}
+	if config.AbsoluteTimeout < config.IdleTimeout {
+		return nil, fmt.Errorf("%w: absolute timeout is shorter than the idle timeout", ErrInvalidConfig)
+	}
+	if config.TouchInterval <= 0 {
+		config.TouchInterval = time.Minute
+	}
+	config.TouchInterval = min(config.TouchInterval, config.IdleTimeout/10)
+	switch config.LimitPolicy {
+	case "":
+		config.LimitPolicy = EvictOldest
+	case EvictOldest, RejectNew:
+	default:
+		return nil, fmt.Errorf("%w: unknown limit policy %q", ErrInvalidConfig, config.LimitPolicy)
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Manager{config: config}, nil
+}
+
+// Create starts a session for subject, enforcing the concurrent session limit
+func (m *Manager) Create(ctx context.Context, subject string, metadata map[string]string) (*Session, error) {
+	if subject == "" {
+		return nil, errors.New("subject is required")
+	}
+	if m.config.MaxPerSubject > 0 {
+		if err := m.makeRoom(ctx, subject); err != nil {
+			return nil, err
+		}
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	now := m.config.Now()
+	s := &Session{
+		ID:                id,
+		Subject:           subject,
+		CreatedAt:         now,
+		LastSeenAt:        now,
+		AbsoluteExpiresAt: now.Add(m.config.AbsoluteTimeout),
+		Metadata:          metadata,
+	}
+	s.ExpiresAt = m.slide(s, now)
+	if err := m.config.Store.Save(ctx, s, s.ExpiresAt.Sub(now)); err != nil {
+		return nil, fmt.Errorf("failed to save session: %w", err)
+	}
+	m.record(ctx, ActionSessionCreated, s, "")
+	return s, nil
+}
+
+// Get returns a live session without extending it
+func (m *Manager) Get(ctx context.Context, id string) (*Session, error) {
+	s, err := m.config.Store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if s.Expired(m.config.Now()) {
+		_ = m.config.Store.Delete(ctx, id)
+		return nil, ErrExpired
+	}
+	return s, nil
+}
+
+// Touch validates a session and slides its idle timeout forward. Call it on
+// each request made with the session. A session revoked while it is being
+// touched stays revoked.
+func (m *Manager) Touch(ctx context.Context, id string) (*Session, error) {
+	s, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := m.config.Now()
+	if now.Sub(s.LastSeenAt) < m.config.TouchInterval {
+		return s, nil
+	}
+	s.LastSeenAt = now
+	s.ExpiresAt = m.slide(s, now)
+	err = m.config.Store.Update(ctx, s, s.ExpiresAt.Sub(now))
+	if errors.Is(err, ErrConflict) {
+		// A concurrent request touched the session first
+		return m.Get(ctx, id)
+	}
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save session: %w", err)
+	}
+	return s, nil
+}
+
+// List returns the live sessions of a subject, most recently used first
+func (m *Manager) List(ctx context.Context, subject string) ([]*Session, error) {
+	sessions, err := m.config.Store.ListBySubject(ctx, subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	now := m.config.Now()
+	live := sessions[:0]
+	for _, s := range sessions {
+		if s.Expired(now) {
+			_ = m.config.Store.Delete(ctx, s.ID)
+			continue
+		}
+		live = append(live, s)
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].LastSeenAt.After(live[j].LastSeenAt) })
+	return live, nil
+}
+
+// Revoke ends a session, e.g. on logout
+func (m *Manager) Revoke(ctx context.Context, id, reason string) error {
+	s, err := m.config.Store.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := m.config.Store.Delete(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	m.record(ctx, ActionSessionRevoked, s, reason)
+	return nil
+}
+
+// RevokeSubject ends every session of a subject except those listed in
+// keep and returns how many were revoked
+func (m *Manager) RevokeSubject(ctx context.Context, subject, reason string, keep ...string) (int, error) {
+	sessions, err := m.config.Store.ListBySubject(ctx, subject)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	revoked := 0
+	for _, s := range sessions {
+		if containsID(keep, s.ID) {
+			continue
+		}
+		if err := m.config.Store.Delete(ctx, s.ID); err != nil {
+			return revoked, fmt.Errorf("failed to delete session: %w", err)
+		}
+		m.record(ctx, ActionSessionRevoked, s, reason)
+		revoked++
+	}
+	return revoked, nil
+}
+
+// CredentialsChanged revokes the subject's sessions after a password or
+// other credential change. current, if not empty, is the session that made
+// the change and is kept.
+func (m *Manager) CredentialsChanged(ctx context.Context, subject, current string) (int, error) {
+	if current == "" {
+		return m.RevokeSubject(ctx, subject, ReasonCredentialsChanged)
+	}
+	return m.RevokeSubject(ctx, subject, ReasonCredentialsChanged, current)
+}
+
+// makeRoom enforces MaxPerSubject before a new session is created
+func (m *Manager) makeRoom(ctx context.Context, subject string) error {
+	live, err := m.List(ctx, subject)
+	if err != nil {
+		return err
+	}
+	excess := len(live) - m.config.MaxPerSubject + 1
+	if excess <= 0 {
+		return nil
+	}
+	if m.config.LimitPolicy == RejectNew {
+		return fmt.Errorf("%w: %d sessions for %s", ErrLimitReached, len(live), subject)
+	}
+	// live is most recently used first
+	for _, s := range live[len(live)-excess:] {
+		if err := m.config.Store.Delete(ctx, s.ID); err != nil {
+			return fmt.Errorf("failed to evict session: %w", err)
+		}
+		m.record(ctx, ActionSessionEvicted, s, "")
+	}
+	return nil
+}
+
+// slide returns the idle expiry from now, capped by the absolute expiry
+func (m *Manager) slide(s *Session, now time.Time) time.Time {
+	expires := now.Add(m.config.IdleTimeout)
+	if expires.After(s.AbsoluteExpiresAt) {
+		return s.AbsoluteExpiresAt
+	}
+	return expires
+}
+
+func (m *Manager) record(ctx context.Context, action string, s *Session, reason string) {
+	if m.config.Audit == nil {
+		return
+	}
+	e := audit.NewEntry(TypeSession).
+		WithActor(s.Subject, audit.ActorUser).
+		WithAction(action).
+		WithResult(audit.ResultSuccess).
+		WithContext(ctx).
+		WithMetadata("session_ref", Ref(s.ID))
+	if reason != "" {
+		e = e.WithMetadata("reason", reason)
+	}
+	_ = m.config.Audit.Store(ctx, e)
+}
+
+func containsID(ids []string, id string) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Ref returns a non-secret reference to a session for logs and audit
+// entries. The session ID itself is a bearer credential and must not be
+// logged.
+func Ref(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:8])
+}
+
+// newID returns a random, URL-safe session ID
+func newID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
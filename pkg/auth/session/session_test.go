@@ -0,0 +1,240 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/audit"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestManager(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis.Run() error: %v", err)
+	}
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	stores := map[string]Store{
+		"Memory": NewMemoryStore(),
+		"Redis":  NewRedisStore(client, "test:session:"),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			testManager(t, store)
+		})
+	}
+}
+
+func testManager(t *testing.T, store Store) {
+	ctx := context.Background()
+	now := time.Now()
+	clock := func() time.Time { return now }
+	manager, err := NewManager(Config{
+		Store:           store,
+		IdleTimeout:     30 * time.Minute,
+		AbsoluteTimeout: time.Hour,
+		MaxPerSubject:   2,
+		Now:             clock,
+	})
+	if err != nil {
+		t.Fatalf("NewManager() error: %v", err)
+	}
+
+	t.Run("Sliding Expiration", func(t *testing.T) {
+		s, err := manager.Create(ctx, "alice", map[string]string{"ip": "203.0.113.7"})
+		if err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		start := now
+		defer func() { now = start }()
+
+		now = now.Add(20 * time.Minute)
+		touched, err := manager.Touch(ctx, s.ID)
+		if err != nil {
+			t.Fatalf("Touch() error: %v", err)
+		}
+		if !touched.ExpiresAt.Equal(now.Add(30*time.Minute)) || touched.Metadata["ip"] != "203.0.113.7" {
+			t.Errorf("Expected the idle timeout to slide, got %+v", touched)
+		}
+
+		now = now.Add(25 * time.Minute)
+		touched, err = manager.Touch(ctx, s.ID)
+		if err != nil {
+			t.Fatalf("Touch() error: %v", err)
+		}
+		if !touched.ExpiresAt.Equal(s.AbsoluteExpiresAt) {
+			t.Errorf("Expected the absolute timeout to cap the session, got %v", touched.ExpiresAt)
+		}
+
+		now = start.Add(time.Hour)
+		if _, err := manager.Touch(ctx, s.ID); !errors.Is(err, ErrExpired) {
+			t.Errorf("Expected ErrExpired after the absolute timeout, got %v", err)
+		}
+		if _, err := manager.Get(ctx, s.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected the expired session removed, got %v", err)
+		}
+	})
+
+	t.Run("Idle Timeout", func(t *testing.T) {
+		s, err := manager.Create(ctx, "bob", nil)
+		if err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		start := now
+		defer func() { now = start }()
+		now = now.Add(31 * time.Minute)
+		if _, err := manager.Touch(ctx, s.ID); !errors.Is(err, ErrExpired) {
+			t.Errorf("Expected ErrExpired after the idle timeout, got %v", err)
+		}
+	})
+
+	t.Run("Concurrent Session Limit", func(t *testing.T) {
+		first, _ := manager.Create(ctx, "carol", nil)
+		now = now.Add(time.Second)
+		second, _ := manager.Create(ctx, "carol", nil)
+		now = now.Add(time.Second)
+		third, err := manager.Create(ctx, "carol", nil)
+		if err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		if _, err := manager.Get(ctx, first.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected the oldest session evicted, got %v", err)
+		}
+		live, err := manager.List(ctx, "carol")
+		if err != nil {
+			t.Fatalf("List() error: %v", err)
+		}
+		if len(live) != 2 || live[0].ID != third.ID || live[1].ID != second.ID {
+			t.Errorf("Expected the two newest sessions, got %+v", live)
+		}
+
+		strict, _ := NewManager(Config{Store: store, MaxPerSubject: 2, LimitPolicy: RejectNew, Now: clock})
+		if _, err := strict.Create(ctx, "carol", nil); !errors.Is(err, ErrLimitReached) {
+			t.Errorf("Expected ErrLimitReached, got %v", err)
+		}
+	})
+
+	t.Run("Credentials Changed", func(t *testing.T) {
+		current, _ := manager.Create(ctx, "dave", nil)
+		other, _ := manager.Create(ctx, "dave", nil)
+		revoked, err := manager.CredentialsChanged(ctx, "dave", current.ID)
+		if err != nil {
+			t.Fatalf("CredentialsChanged() error: %v", err)
+		}
+		if revoked != 1 {
+			t.Errorf("Expected 1 session revoked, got %d", revoked)
+		}
+		if _, err := manager.Get(ctx, other.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected the other session revoked, got %v", err)
+		}
+		if _, err := manager.Get(ctx, current.ID); err != nil {
+			t.Errorf("Expected the current session kept, got %v", err)
+		}
+		if err := manager.Revoke(ctx, current.ID, ReasonLogout); err != nil {
+			t.Fatalf("Revoke() error: %v", err)
+		}
+		if live, _ := manager.List(ctx, "dave"); len(live) != 0 {
+			t.Errorf("Expected no sessions left, got %d", len(live))
+		}
+	})
+
+	t.Run("Revoked While Touched", func(t *testing.T) {
+		s, err := manager.Create(ctx, "erin", nil)
+		if err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		start := now
+		defer func() { now = start }()
+		now = now.Add(10 * time.Minute)
+
+		racing, err := NewManager(Config{
+			Store:           &revokeOnGet{Store: store},
+			IdleTimeout:     30 * time.Minute,
+			AbsoluteTimeout: time.Hour,
+			Now:             clock,
+		})
+		if err != nil {
+			t.Fatalf("NewManager() error: %v", err)
+		}
+		if _, err := racing.Touch(ctx, s.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for a session revoked mid-touch, got %v", err)
+		}
+		if _, err := store.Get(ctx, s.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected the revoked session to stay deleted, got %v", err)
+		}
+	})
+
+	t.Run("Concurrent Touch", func(t *testing.T) {
+		s, _ := manager.Create(ctx, "frank", nil)
+		stale, _ := store.Get(ctx, s.ID)
+		start := now
+		defer func() { now = start }()
+		now = now.Add(10 * time.Minute)
+		if _, err := manager.Touch(ctx, s.ID); err != nil {
+			t.Fatalf("Touch() error: %v", err)
+		}
+		if err := store.Update(ctx, stale, time.Minute); !errors.Is(err, ErrConflict) {
+			t.Errorf("Expected ErrConflict for a stale version, got %v", err)
+		}
+	})
+}
+
+// revokeOnGet deletes a session right after reading it, as a concurrent
+// Revoke would
+type revokeOnGet struct {
+	Store
+}
+
+func (r *revokeOnGet) Get(ctx context.Context, id string) (*Session, error) {
+	s, err := r.Store.Get(ctx, id)
+	if err == nil {
+		_ = r.Store.Delete(ctx, id)
+	}
+	return s, err
+}
+
+func TestAuditRef(t *testing.T) {
+	trail := &auditTrail{}
+	manager, err := NewManager(Config{Store: NewMemoryStore(), Audit: trail})
+	if err != nil {
+		t.Fatalf("NewManager() error: %v", err)
+	}
+	s, err := manager.Create(context.Background(), "gina", nil)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	e := trail.entries[0]
+	if e.Metadata["session_ref"] != Ref(s.ID) {
+		t.Errorf("Expected the session reference, got %v", e.Metadata)
+	}
+	for _, v := range e.Metadata {
+		if v == s.ID {
+			t.Error("Expected the session ID not to be audited")
+		}
+	}
+}
+
+type auditTrail struct {
+	audit.Storage
+	entries []*audit.Entry
+}
+
+func (a *auditTrail) Store(_ context.Context, e *audit.Entry) error {
+	a.entries = append(a.entries, e)
+	return nil
+}
+
+func TestNewManager(t *testing.T) {
+	if _, err := NewManager(Config{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Expected ErrInvalidConfig without a store, got %v", err)
+	}
+	if _, err := NewManager(Config{Store: NewMemoryStore(), IdleTimeout: time.Hour, AbsoluteTimeout: time.Minute}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Expected ErrInvalidConfig for an absolute timeout below the idle timeout, got %v", err)
+	}
+}
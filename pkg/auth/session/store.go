@@ -0,0 +1,295 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// MemoryStore keeps sessions in process memory. Sessions are lost on
+// restart and not shared between instances.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	sessions  map[string]*Session
+	expires   map[string]time.Time
+	bySubject map[string]map[string]bool
+}
+
+// NewMemoryStore creates an in-memory session store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions:  make(map[string]*Session),
+		expires:   make(map[string]time.Time),
+		bySubject: make(map[string]map[string]bool),
+	}
+}
+
+// Save implements Store
+func (s *MemoryStore) Save(_ context.Context, session *Session, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(time.Now())
+	s.sessions[session.ID] = copySession(session)
+	s.expires[session.ID] = time.Now().Add(ttl)
+	if s.bySubject[session.Subject] == nil {
+		s.bySubject[session.Subject] = make(map[string]bool)
+	}
+	s.bySubject[session.Subject][session.ID] = true
+	return nil
+}
+
+// Update implements Store
+func (s *MemoryStore) Update(_ context.Context, session *Session, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.sessions[session.ID]
+	if !ok || !time.Now().Before(s.expires[session.ID]) {
+		return ErrNotFound
+	}
+	if current.Version != session.Version {
+		return ErrConflict
+	}
+	session.Version++
+	s.sessions[session.ID] = copySession(session)
+	s.expires[session.ID] = time.Now().Add(ttl)
+	return nil
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(_ context.Context, id string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	session, ok := s.sessions[id]
+	if !ok || !time.Now().Before(s.expires[id]) {
+		return nil, ErrNotFound
+	}
+	return copySession(session), nil
+}
+
+// Delete implements Store
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(id)
+	return nil
+}
+
+// ListBySubject implements Store
+func (s *MemoryStore) ListBySubject(_ context.Context, subject string) ([]*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now()
+	var sessions []*Session
+	for id := range s.bySubject[subject] {
+		if now.Before(s.expires[id]) {
+			sessions = append(sessions, copySession(s.sessions[id]))
+		}
+	}
+	return sessions, nil
+}
+
+// sweep drops expired sessions; s.mu must be held
+func (s *MemoryStore) sweep(now time.Time) {
+	for id, expires := range s.expires {
+		if !now.Before(expires) {
+			s.remove(id)
+		}
+	}
+}
+
+// remove deletes a session and its index entry; s.mu must be held
+func (s *MemoryStore) remove(id string) {
+	session, ok := s.sessions[id]
+	if !ok {
+		return
+	}
+	delete(s.sessions, id)
+	delete(s.expires, id)
+	if ids := s.bySubject[session.Subject]; ids != nil {
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(s.bySubject, session.Subject)
+		}
+	}
+}
+
+// RedisStore keeps sessions in Redis so they are shared between instances.
+// Each session is a key that expires with the session; a set per subject
+// indexes them.
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore creates a Redis-backed session store (prefix default: "session:")
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = "session:"
+	}
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Save implements Store
+func (s *RedisStore) Save(ctx context.Context, session *Session, ttl time.Duration) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	subjectKey := s.subjectKey(session.Subject)
+	// The index lives as long as the longest session it lists
+	deadline := session.AbsoluteExpiresAt
+	if current, err := s.client.PTTL(ctx, subjectKey).Result(); err == nil && current > 0 {
+		if until := time.Now().Add(current); until.After(deadline) {
+			deadline = until
+		}
+	}
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, s.key(session.ID), data, ttl)
+	pipe.SAdd(ctx, subjectKey, session.ID)
+	pipe.ExpireAt(ctx, subjectKey, deadline)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// Update implements Store. The key is watched while the stored version is
+// compared, so a concurrent delete or update aborts the write.
+func (s *RedisStore) Update(ctx context.Context, session *Session, ttl time.Duration) error {
+	key := s.key(session.ID)
+	next := *session
+	next.Version++
+	data, err := json.Marshal(&next)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	err = s.client.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var stored Session
+		if err := json.Unmarshal(current, &stored); err != nil {
+			return fmt.Errorf("failed to decode session: %w", err)
+		}
+		if stored.Version != session.Version {
+			return ErrConflict
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetXX(ctx, key, data, ttl)
+			return nil
+		})
+		return err
+	}, key)
+	switch {
+	case errors.Is(err, redis.TxFailedErr):
+		return ErrConflict
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrConflict):
+		return err
+	case err != nil:
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	session.Version = next.Version
+	return nil
+}
+
+// Get implements Store
+func (s *RedisStore) Get(ctx context.Context, id string) (*Session, error) {
+	data, err := s.client.Get(ctx, s.key(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return &session, nil
+}
+
+// Delete implements Store
+func (s *RedisStore) Delete(ctx context.Context, id string) error {
+	session, err := s.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, s.key(id))
+	pipe.SRem(ctx, s.subjectKey(session.Subject), id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// ListBySubject implements Store. Index entries of expired sessions are
+// removed on the way.
+func (s *RedisStore) ListBySubject(ctx context.Context, subject string) ([]*Session, error) {
+	subjectKey := s.subjectKey(subject)
+	ids, err := s.client.SMembers(ctx, subjectKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.key(id)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	var sessions []*Session
+	var stale []interface{}
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			stale = append(stale, ids[i])
+			continue
+		}
+		var session Session
+		if err := json.Unmarshal([]byte(data), &session); err != nil {
+			return nil, fmt.Errorf("failed to decode session: %w", err)
+		}
+		sessions = append(sessions, &session)
+	}
+	if len(stale) > 0 {
+		s.client.SRem(ctx, subjectKey, stale...)
+	}
+	return sessions, nil
+}
+
+func (s *RedisStore) key(id string) string {
+	return s.prefix + id
+}
+
+func (s *RedisStore) subjectKey(subject string) string {
+	return s.prefix + "subject:" + subject
+}
+
+// copySession returns a copy that does not share metadata with the original
+func copySession(s *Session) *Session {
+	c := *s
+	if s.Metadata != nil {
+		c.Metadata = make(map[string]string, len(s.Metadata))
+		for k, v := range s.Metadata {
+			c.Metadata[k] = v
+		}
+	}
+	return &c
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// Shamir secret sharing over GF(2^8), used by KeyEscrow to split encryption
+// keys into custodian shares. Each byte of the secret is the constant term of
+// a random polynomial of degree threshold-1; share i holds the polynomial
+// evaluated at x = i for every byte.
+
+// splitSecret splits secret into n shares, any threshold of which recover it.
+// The returned map is keyed by the share's x-coordinate (1..n).
+func splitSecret(secret []byte, n, threshold int) (map[byte][]byte, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("secret must not be empty")
+	}
+	if threshold < 2 || threshold > n {
+		return nil, fmt.Errorf("threshold must be between 2 and %d", n)
+	}
+	if n > 255 {
+		return nil, errors.New("at most 255 shares are supported")
+	}
+
+	shares := make(map[byte][]byte, n)
+	for x := 1; x <= n; x++ {
+		shares[byte(x)] = make([]byte, len(secret))
+	}
+
+	coeffs := make([]byte, threshold)
+	for i, b := range secret {
+		coeffs[0] = b
+		if _, err := rand.Read(coeffs[1:]); err != nil {
+			return nil, fmt.Errorf("failed to generate coefficients: %w", err)
+		}
+		for x := 1; x <= n; x++ {
+			shares[byte(x)][i] = evalPolynomial(coeffs, byte(x))
+		}
+	}
+
+	return shares, nil
+}
+
+// combineShares recovers the secret from threshold or more shares using
+// Lagrange interpolation at x = 0.
+func combineShares(shares map[byte][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("at least two shares are required")
+	}
+
+	length := -1
+	xs := make([]byte, 0, len(shares))
+	for x, v := range shares {
+		if x == 0 {
+			return nil, errors.New("invalid share index 0")
+		}
+		if length == -1 {
+			length = len(v)
+		} else if len(v) != length {
+			return nil, errors.New("shares have inconsistent lengths")
+		}
+		xs = append(xs, x)
+	}
+
+	secret := make([]byte, length)
+	for i := range secret {
+		var acc byte
+		for _, xi := range xs {
+			num, den := byte(1), byte(1)
+			for _, xj := range xs {
+				if xi == xj {
+					continue
+				}
+				num = gfMul(num, xj)
+				den = gfMul(den, xi^xj)
+			}
+			acc ^= gfMul(shares[xi][i], gfDiv(num, den))
+		}
+		secret[i] = acc
+	}
+
+	return secret, nil
+}
+
+func evalPolynomial(coeffs []byte, x byte) byte {
+	// Horner's method, highest degree first
+	var result byte
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		result = gfMul(result, x) ^ coeffs[i]
+	}
+	return result
+}
+
+// gfMul multiplies in GF(2^8) with the AES reduction polynomial x^8+x^4+x^3+x+1.
+func gfMul(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+// gfInv returns the multiplicative inverse (a^254) in GF(2^8).
+func gfInv(a byte) byte {
+	result := byte(1)
+	base := a
+	for e := 254; e > 0; e >>= 1 {
+		if e&1 != 0 {
+			result = gfMul(result, base)
+		}
+		base = gfMul(base, base)
+	}
+	return result
+}
+
+func gfDiv(a, b byte) byte {
+	return gfMul(a, gfInv(b))
+}
@@ -18,16 +18,17 @@ func (a *memoryAuthorizer) Authorize(
 		Action:   action,
 		Resource: resource,
 	}
-	resp, err := a.IsAllowed(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-	return &Decision{
+	trace := beginTrace(ctx, req)
+	resp := a.isAllowed(ctx, req, trace)
+	decision := &Decision{
 		Allowed:   resp.Allowed,
 		Reason:    resp.Reason,
 		Policy:    resp.PolicyID,
 		Timestamp: time.Now(),
-	}, nil
+	}
+	trace.finish(decision)
+	decision.Trace = trace
+	return decision, nil
 }
 
 // Permission represents an action that can be performed on a resource
@@ -125,6 +126,13 @@ func (a *memoryAuthorizer) ListPolicies(_ context.Context) ([]*Policy, error) {
 }
 
 func (a *memoryAuthorizer) IsAllowed(ctx context.Context, request *AccessRequest) (*AccessResponse, error) {
+	trace := beginTrace(ctx, request)
+	resp := a.isAllowed(ctx, request, trace)
+	trace.finish(&Decision{Allowed: resp.Allowed, Reason: resp.Reason, Policy: resp.PolicyID})
+	return resp, nil
+}
+
+func (a *memoryAuthorizer) isAllowed(ctx context.Context, request *AccessRequest, trace *DecisionTrace) *AccessResponse {
 	var matchingPolicies []*Policy
 
 	// Collect all applicable policies
@@ -134,6 +142,8 @@ func (a *memoryAuthorizer) IsAllowed(ctx context.Context, request *AccessRequest
 		// Check if policy applies to this request
 		if a.policyApplies(policy, request) {
 			matchingPolicies = append(matchingPolicies, policy)
+		} else if trace != nil {
+			trace.Skipped++
 		}
 		return true
 	})
@@ -143,14 +153,14 @@ func (a *memoryAuthorizer) IsAllowed(ctx context.Context, request *AccessRequest
 
 	// Evaluate policies in order
 	for _, policy := range matchingPolicies {
-		allowed, reason := a.evaluatePolicy(ctx, policy, request)
+		allowed, reason := a.evaluatePolicy(ctx, policy, request, trace)
 		if allowed || policy.Effect == "deny" {
 			return &AccessResponse{
 				Allowed:     allowed,
 				Reason:      reason,
 				PolicyID:    policy.ID,
 				Annotations: make(map[string]string),
-			}, nil
+			}
 		}
 	}
 
@@ -159,7 +169,7 @@ func (a *memoryAuthorizer) IsAllowed(ctx context.Context, request *AccessRequest
 		Allowed:     false,
 		Reason:      "no matching policies found",
 		Annotations: make(map[string]string),
-	}, nil
+	}
 }
 
 func (a *memoryAuthorizer) AddRole(_ context.Context, role Role, permissions []Permission) error {
@@ -231,16 +241,17 @@ func (a *memoryAuthorizer) policyApplies(policy *Policy, request *AccessRequest)
 		actionMatches(policy.Actions, request.Action)
 }
 
-func (a *memoryAuthorizer) evaluatePolicy(ctx context.Context, policy *Policy, request *AccessRequest) (bool, string) {
-	// Evaluate all conditions
-	for name, condition := range policy.Conditions {
-		allowed, err := condition.Evaluate(ctx, request)
-		if err != nil {
-			return false, fmt.Sprintf("condition %s evaluation failed: %v", name, err)
-		}
-		if !allowed {
-			return false, fmt.Sprintf("condition %s not met", name)
-		}
+func (a *memoryAuthorizer) evaluatePolicy(ctx context.Context, policy *Policy, request *AccessRequest, trace *DecisionTrace) (bool, string) {
+	start := time.Now()
+	met, failed, conditions, err := evaluateConditions(ctx, policy, request, trace != nil)
+	if trace != nil {
+		trace.Policies = append(trace.Policies, policyTrace(policy, met, conditions, err, time.Since(start)))
+	}
+	if err != nil {
+		return false, fmt.Sprintf("condition %s evaluation failed: %v", failed, err)
+	}
+	if !met {
+		return false, fmt.Sprintf("condition %s not met", failed)
 	}
 
 	if policy.Effect == "allow" {
@@ -0,0 +1,386 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExceeded indicates a stage did not finish within its share of the
+// decision budget
+var ErrBudgetExceeded = errors.New("decision budget exceeded")
+
+// Stage is a phase of an authorization decision that draws on the budget
+type Stage string
+
+const (
+	// StagePIP covers attribute lookups
+	StagePIP Stage = "pip"
+
+	// StageStore covers loading policies
+	StageStore Stage = "store"
+
+	// StageEvaluation covers matching policies and evaluating conditions
+	StageEvaluation Stage = "evaluation"
+)
+
+// stageOrder is the order in which stages consume the budget
+var stageOrder = []Stage{StagePIP, StageStore, StageEvaluation}
+
+// PartialResultPolicy decides what happens when a stage runs out of budget
+type PartialResultPolicy string
+
+const (
+	// FailClosed denies the request
+	FailClosed PartialResultPolicy = "fail_closed"
+
+	// FailOpen continues without the missing result: a timed-out attribute
+	// source contributes nothing, a timed-out store falls back to the last
+	// policies loaded, and a timed-out policy is treated as not applying
+	FailOpen PartialResultPolicy = "fail_open"
+)
+
+// AttributeSource supplies request attributes from a slow or remote system
+type AttributeSource struct {
+	// Name identifies the source in decisions and usage reports
+	Name string
+
+	// Class selects the partial-result policy applied when the lookup fails
+	Class string
+
+	// Lookup returns attributes that are merged into the request context
+	Lookup func(ctx context.Context, request *AccessRequest) (map[string]string, error)
+}
+
+// BudgetConfig configures a BudgetedAuthorizer
+type BudgetConfig struct {
+	// Authorizer supplies the policies to evaluate
+	Authorizer Authorizer
+
+	// Sources are queried concurrently during the PIP stage
+	Sources []AttributeSource
+
+	// Total is the overall budget per request (default: 50ms)
+	Total time.Duration
+
+	// Shares split Total across stages (default: pip 40%, store 20%,
+	// evaluation 40%). Time a stage leaves unused rolls over to later stages.
+	Shares map[Stage]float64
+
+	// ClassPolicies map a source or policy class to its partial-result
+	// policy. The store stage uses the class "store".
+	ClassPolicies map[string]PartialResultPolicy
+
+	// DefaultPolicy applies to classes without an entry (default: FailClosed)
+	DefaultPolicy PartialResultPolicy
+}
+
+// BudgetUsage reports how a request spent its budget. Missing names the
+// sources, stages and policies that produced no result.
+type BudgetUsage struct {
+	Total    time.Duration           `json:"total"`
+	Elapsed  time.Duration           `json:"elapsed"`
+	Stages   map[Stage]time.Duration `json:"stages"`
+	Missing  []string                `json:"missing,omitempty"`
+	Degraded bool                    `json:"degraded"`
+}
+
+// BudgetedAuthorizer makes decisions within a fixed time budget so slow
+// attribute sources, stores or conditions cannot stall the request path
+type BudgetedAuthorizer struct {
+	config BudgetConfig
+
+	mu          sync.RWMutex
+	lastLoaded  []*Policy
+	cumulatives map[Stage]float64
+}
+
+// NewBudgetedAuthorizer creates a budgeted authorizer
+func NewBudgetedAuthorizer(config BudgetConfig) (*BudgetedAuthorizer, error) {
+	if config.Authorizer == nil {
+		return nil, errors.New("authorizer is required")
+	}
+	if config.Total <= 0 {
+		config.Total = 50 * time.Millisecond
+	}
+	if config.Shares == nil {
+		config.Shares = map[Stage]float64{StagePIP: 0.4, StageStore: 0.2, StageEvaluation: 0.4}
+	}
+	if config.DefaultPolicy == "" {
+		config.DefaultPolicy = FailClosed
+	}
+
+	var sum float64
+	for _, stage := range stageOrder {
+		if config.Shares[stage] < 0 {
+			return nil, fmt.Errorf("negative budget share for stage %s", stage)
+		}
+		sum += config.Shares[stage]
+	}
+	if sum <= 0 {
+		return nil, errors.New("budget shares must not all be zero")
+	}
+	cumulatives := make(map[Stage]float64, len(stageOrder))
+	var running float64
+	for _, stage := range stageOrder {
+		running += config.Shares[stage] / sum
+		cumulatives[stage] = running
+	}
+	return &BudgetedAuthorizer{config: config, cumulatives: cumulatives}, nil
+}
+
+// Authorize implements Authorizer
+func (a *BudgetedAuthorizer) Authorize(ctx context.Context, subject Subject, action Action, resource Resource) (*Decision, error) {
+	decision, _, err := a.Evaluate(ctx, &AccessRequest{Subject: subject, Action: action, Resource: resource})
+	return decision, err
+}
+
+// AddPolicy implements Authorizer
+func (a *BudgetedAuthorizer) AddPolicy(ctx context.Context, policy *Policy) error {
+	return a.config.Authorizer.AddPolicy(ctx, policy)
+}
+
+// RemovePolicy implements Authorizer
+func (a *BudgetedAuthorizer) RemovePolicy(ctx context.Context, policyID string) error {
+	return a.config.Authorizer.RemovePolicy(ctx, policyID)
+}
+
+// ListPolicies implements Authorizer
+func (a *BudgetedAuthorizer) ListPolicies(ctx context.Context) ([]*Policy, error) {
+	return a.config.Authorizer.ListPolicies(ctx)
+}
+
+// Prime loads policies so a fail-open store stage has a fallback from the
+// first request
+func (a *BudgetedAuthorizer) Prime(ctx context.Context) error {
+	policies, err := a.config.Authorizer.ListPolicies(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load policies: %w", err)
+	}
+	a.mu.Lock()
+	a.lastLoaded = policies
+	a.mu.Unlock()
+	return nil
+}
+
+// Evaluate decides the request within the budget and reports how the budget
+// was spent. Running out of budget is not an error; it yields a decision
+// according to the partial-result policy of the affected class.
+func (a *BudgetedAuthorizer) Evaluate(ctx context.Context, request *AccessRequest) (*Decision, *BudgetUsage, error) {
+	start := time.Now()
+	usage := &BudgetUsage{Total: a.config.Total, Stages: make(map[Stage]time.Duration, len(stageOrder))}
+	trace := beginTrace(ctx, request)
+	finish := func(d *Decision) (*Decision, *BudgetUsage, error) {
+		d.Timestamp = time.Now()
+		usage.Elapsed = d.Timestamp.Sub(start)
+		if trace != nil {
+			trace.Stages = usage.Stages
+			trace.finish(d)
+			d.Trace = trace
+		}
+		return d, usage, nil
+	}
+
+	req := *request
+	req.Context = make(map[string]string, len(request.Context))
+	for k, v := range request.Context {
+		req.Context[k] = v
+	}
+
+	// PIP lookups
+	stageCtx, cancel := a.stageContext(ctx, start, StagePIP)
+	denied := a.lookupAttributes(stageCtx, &req, usage, trace)
+	cancel()
+	usage.Stages[StagePIP] = time.Since(start)
+	if denied != "" {
+		return finish(&Decision{Reason: fmt.Sprintf("attribute source %s returned no result within budget", denied)})
+	}
+
+	// Policy store
+	storeStart := time.Now()
+	stageCtx, cancel = a.stageContext(ctx, start, StageStore)
+	policies, err := runWithin(stageCtx, func() ([]*Policy, error) {
+		return a.config.Authorizer.ListPolicies(stageCtx)
+	})
+	cancel()
+	usage.Stages[StageStore] = time.Since(storeStart)
+	switch {
+	case err == nil:
+		a.mu.Lock()
+		a.lastLoaded = policies
+		a.mu.Unlock()
+	case errors.Is(err, ErrBudgetExceeded):
+		usage.Missing = append(usage.Missing, string(StageStore))
+		if a.policyFor(string(StageStore)) == FailClosed {
+			return finish(&Decision{Reason: "policy store exceeded budget"})
+		}
+		usage.Degraded = true
+		a.mu.RLock()
+		policies = a.lastLoaded
+		a.mu.RUnlock()
+	default:
+		return nil, usage, fmt.Errorf("failed to load policies: %w", err)
+	}
+
+	// Evaluation
+	evalStart := time.Now()
+	stageCtx, cancel = a.stageContext(ctx, start, StageEvaluation)
+	defer cancel()
+	decision := a.evaluate(stageCtx, policies, &req, usage, trace)
+	usage.Stages[StageEvaluation] = time.Since(evalStart)
+	return finish(decision)
+}
+
+// stageContext bounds a stage by its cumulative share of the budget
+func (a *BudgetedAuthorizer) stageContext(ctx context.Context, start time.Time, stage Stage) (context.Context, context.CancelFunc) {
+	share := time.Duration(float64(a.config.Total) * a.cumulatives[stage])
+	return context.WithDeadline(ctx, start.Add(share))
+}
+
+func (a *BudgetedAuthorizer) policyFor(class string) PartialResultPolicy {
+	if p, ok := a.config.ClassPolicies[class]; ok {
+		return p
+	}
+	return a.config.DefaultPolicy
+}
+
+// lookupAttributes queries all sources concurrently and merges their
+// attributes in source order. It returns the name of a fail-closed source
+// that did not answer in time.
+func (a *BudgetedAuthorizer) lookupAttributes(ctx context.Context, req *AccessRequest, usage *BudgetUsage, trace *DecisionTrace) string {
+	if len(a.config.Sources) == 0 {
+		return ""
+	}
+	type result struct {
+		attrs    map[string]string
+		err      error
+		duration time.Duration
+	}
+	results := make([]result, len(a.config.Sources))
+	var wg sync.WaitGroup
+	for i, src := range a.config.Sources {
+		wg.Add(1)
+		go func(i int, src AttributeSource) {
+			defer wg.Done()
+			// Sources see a snapshot so a late one cannot race the merge
+			snapshot := *req
+			lookupStart := time.Now()
+			attrs, err := runWithin(ctx, func() (map[string]string, error) {
+				return src.Lookup(ctx, &snapshot)
+			})
+			results[i] = result{attrs, err, time.Since(lookupStart)}
+		}(i, src)
+	}
+	wg.Wait()
+
+	for i, src := range a.config.Sources {
+		if trace != nil {
+			at := AttributeTrace{Source: src.Name, Attributes: results[i].attrs, Duration: results[i].duration}
+			if results[i].err != nil {
+				at.Error = results[i].err.Error()
+			}
+			trace.Attributes = append(trace.Attributes, at)
+		}
+		if results[i].err != nil {
+			usage.Missing = append(usage.Missing, src.Name)
+			if a.policyFor(src.Class) == FailClosed {
+				return src.Name
+			}
+			usage.Degraded = true
+			continue
+		}
+		for k, v := range results[i].attrs {
+			req.Context[k] = v
+		}
+	}
+	return ""
+}
+
+// evaluate applies policies in priority order within the stage deadline
+func (a *BudgetedAuthorizer) evaluate(ctx context.Context, policies []*Policy, req *AccessRequest, usage *BudgetUsage, trace *DecisionTrace) *Decision {
+	matching := make([]*Policy, 0, len(policies))
+	for _, p := range policies {
+		if subjectMatches(p.Subjects, req.Subject) && resourceMatches(p.Resources, req.Resource) && actionMatches(p.Actions, req.Action) {
+			matching = append(matching, p)
+		} else if trace != nil {
+			trace.Skipped++
+		}
+	}
+	sort.SliceStable(matching, func(i, j int) bool { return matching[i].Priority > matching[j].Priority })
+
+	for _, policy := range matching {
+		policyStart := time.Now()
+		result, err := runWithin(ctx, func() (conditionResult, error) {
+			return conditionsMet(ctx, policy, req, trace != nil)
+		})
+		met := result.met
+		if trace != nil {
+			pt := policyTrace(policy, met, result.traces, err, time.Since(policyStart))
+			if errors.Is(err, ErrBudgetExceeded) {
+				pt.Outcome = "timeout"
+			}
+			trace.Policies = append(trace.Policies, pt)
+		}
+		if errors.Is(err, ErrBudgetExceeded) {
+			usage.Missing = append(usage.Missing, policy.ID)
+			if a.policyFor(policy.Class) == FailClosed {
+				return &Decision{Reason: "policy evaluation exceeded budget", Policy: policy.ID}
+			}
+			usage.Degraded = true
+			continue
+		}
+		if err != nil && policy.Effect == Deny {
+			return &Decision{Reason: fmt.Sprintf("condition evaluation failed: %v", err), Policy: policy.ID}
+		}
+		if err != nil || !met {
+			continue
+		}
+		if policy.Effect == Allow {
+			return &Decision{Allowed: true, Reason: "policy allows access", Policy: policy.ID}
+		}
+		return &Decision{Reason: "policy denies access", Policy: policy.ID}
+	}
+	return &Decision{Reason: "no matching policies found"}
+}
+
+// conditionResult is the outcome of a policy's conditions evaluated within
+// the budget
+type conditionResult struct {
+	met    bool
+	traces []ConditionTrace
+}
+
+func conditionsMet(ctx context.Context, policy *Policy, req *AccessRequest, traced bool) (conditionResult, error) {
+	met, failed, traces, err := evaluateConditions(ctx, policy, req, traced)
+	if err != nil {
+		return conditionResult{traces: traces}, fmt.Errorf("condition %s: %w", failed, err)
+	}
+	return conditionResult{met: met, traces: traces}, nil
+}
+
+// runWithin runs fn and gives up when ctx is done, since sources and
+// conditions are not required to honour cancellation
+func runWithin[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := fn()
+		done <- result{v, err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil && ctx.Err() != nil {
+			return r.v, fmt.Errorf("%w: %v", ErrBudgetExceeded, r.err)
+		}
+		return r.v, r.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ErrBudgetExceeded
+	}
+}
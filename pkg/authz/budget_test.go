@@ -0,0 +1,118 @@
+package authz
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type slowCondition struct {
+	delay time.Duration
+}
+
+func (c slowCondition) Evaluate(_ context.Context, _ *AccessRequest) (bool, error) {
+	time.Sleep(c.delay)
+	return true, nil
+}
+
+func TestBudgetedAuthorizer(t *testing.T) {
+	ctx := context.Background()
+	subject := Subject{ID: "agent-1"}
+	action := Action{Name: "read"}
+	resource := Resource{ID: "doc-1"}
+
+	inner := NewMemoryAuthorizer()
+	if err := inner.AddPolicy(ctx, &Policy{
+		ID:     "clearance",
+		Effect: Allow,
+		Conditions: map[string]Condition{
+			"clearance": &AttributeCondition{Attribute: "clearance", Value: "high", Operator: "eq"},
+		},
+	}); err != nil {
+		t.Fatalf("AddPolicy() error: %v", err)
+	}
+
+	fast := AttributeSource{Name: "hr", Class: "identity", Lookup: func(context.Context, *AccessRequest) (map[string]string, error) {
+		return map[string]string{"clearance": "high"}, nil
+	}}
+	slow := AttributeSource{Name: "risk", Class: "risk", Lookup: func(ctx context.Context, _ *AccessRequest) (map[string]string, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+
+	t.Run("Within Budget", func(t *testing.T) {
+		a, err := NewBudgetedAuthorizer(BudgetConfig{Authorizer: inner, Sources: []AttributeSource{fast}})
+		if err != nil {
+			t.Fatalf("NewBudgetedAuthorizer() error: %v", err)
+		}
+		decision, usage, err := a.Evaluate(ctx, &AccessRequest{Subject: subject, Action: action, Resource: resource})
+		if err != nil {
+			t.Fatalf("Evaluate() error: %v", err)
+		}
+		if !decision.Allowed {
+			t.Errorf("Expected access allowed, got %q", decision.Reason)
+		}
+		if usage.Degraded || len(usage.Missing) != 0 {
+			t.Errorf("Expected full result, got %+v", usage)
+		}
+	})
+
+	t.Run("Slow Source Fail Closed", func(t *testing.T) {
+		a, _ := NewBudgetedAuthorizer(BudgetConfig{
+			Authorizer: inner,
+			Sources:    []AttributeSource{fast, slow},
+			Total:      20 * time.Millisecond,
+		})
+		start := time.Now()
+		decision, err := a.Authorize(ctx, subject, action, resource)
+		if err != nil {
+			t.Fatalf("Authorize() error: %v", err)
+		}
+		if decision.Allowed {
+			t.Error("Expected fail-closed source to deny access")
+		}
+		if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+			t.Errorf("Expected decision within budget, took %v", elapsed)
+		}
+	})
+
+	t.Run("Slow Source Fail Open", func(t *testing.T) {
+		a, _ := NewBudgetedAuthorizer(BudgetConfig{
+			Authorizer:    inner,
+			Sources:       []AttributeSource{fast, slow},
+			Total:         20 * time.Millisecond,
+			ClassPolicies: map[string]PartialResultPolicy{"risk": FailOpen},
+		})
+		decision, usage, err := a.Evaluate(ctx, &AccessRequest{Subject: subject, Action: action, Resource: resource})
+		if err != nil {
+			t.Fatalf("Evaluate() error: %v", err)
+		}
+		if !decision.Allowed {
+			t.Errorf("Expected access allowed without risk attributes, got %q", decision.Reason)
+		}
+		if !usage.Degraded || len(usage.Missing) != 1 || usage.Missing[0] != "risk" {
+			t.Errorf("Expected degraded result missing risk, got %+v", usage)
+		}
+	})
+
+	t.Run("Slow Condition", func(t *testing.T) {
+		store := NewMemoryAuthorizer()
+		_ = store.AddPolicy(ctx, &Policy{
+			ID:         "slow",
+			Effect:     Allow,
+			Class:      "reporting",
+			Conditions: map[string]Condition{"slow": slowCondition{delay: 100 * time.Millisecond}},
+		})
+		a, _ := NewBudgetedAuthorizer(BudgetConfig{Authorizer: store, Total: 10 * time.Millisecond})
+		decision, usage, err := a.Evaluate(ctx, &AccessRequest{Subject: subject, Action: action, Resource: resource})
+		if err != nil {
+			t.Fatalf("Evaluate() error: %v", err)
+		}
+		if decision.Allowed || decision.Policy != "slow" {
+			t.Errorf("Expected deny from timed-out policy, got %+v", decision)
+		}
+		if len(usage.Missing) != 1 || usage.Missing[0] != "slow" {
+			t.Errorf("Expected slow policy reported missing, got %+v", usage)
+		}
+	})
+}
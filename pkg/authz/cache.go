@@ -0,0 +1,250 @@
+package authz
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CacheConfig configures a CachedAuthorizer
+type CacheConfig struct {
+	// Authorizer makes the decisions that are cached
+	Authorizer Authorizer
+
+	// TTL bounds how long a decision is reused (default: 1m)
+	TTL time.Duration
+
+	// Size bounds the number of cached decisions; the least recently used
+	// are evicted first (default: 10000)
+	Size int
+
+	// PolicyVersion reports the version of the policies in force, so that a
+	// new version never serves decisions made under an old one. It defaults
+	// to the policy set version when Authorizer is a PolicyEngine.
+	PolicyVersion func() int64
+
+	// Namespace prefixes the cache metrics (default: "gauth")
+	Namespace string
+
+	// Now returns the current time (default: time.Now)
+	Now func() time.Time
+}
+
+// CachedAuthorizer reuses decisions keyed by subject, action, resource and
+// policy version. Policy changes made through it, and Invalidate calls for
+// changes made elsewhere, such as role assignments, drop stale decisions.
+// Traced requests bypass the cache so their trace describes a real
+// evaluation. It implements prometheus.Collector for hit and miss counters.
+type CachedAuthorizer struct {
+	config CacheConfig
+	hits   prometheus.Counter
+	misses prometheus.Counter
+
+	mu         sync.Mutex
+	generation int64
+	entries    map[[sha256.Size]byte]*list.Element
+	lru        *list.List
+}
+
+type cacheEntry struct {
+	key       [sha256.Size]byte
+	subjectID string
+	decision  Decision
+	expires   time.Time
+}
+
+// NewCachedAuthorizer creates a caching authorizer
+func NewCachedAuthorizer(config CacheConfig) (*CachedAuthorizer, error) {
+	if config.Authorizer == nil {
+		return nil, errors.New("authorizer is required")
+	}
+	if config.TTL <= 0 {
+		config.TTL = time.Minute
+	}
+	if config.Size <= 0 {
+		config.Size = 10000
+	}
+	if config.PolicyVersion == nil {
+		if engine, ok := config.Authorizer.(*PolicyEngine); ok {
+			config.PolicyVersion = func() int64 { return engine.PolicySet().Version }
+		}
+	}
+	if config.Namespace == "" {
+		config.Namespace = "gauth"
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &CachedAuthorizer{
+		config: config,
+		hits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Subsystem: "authz",
+			Name:      "cache_hits_total",
+			Help:      "Authorization decisions served from the cache",
+		}),
+		misses: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Subsystem: "authz",
+			Name:      "cache_misses_total",
+			Help:      "Authorization decisions not found in the cache",
+		}),
+		entries: make(map[[sha256.Size]byte]*list.Element),
+		lru:     list.New(),
+	}, nil
+}
+
+// Authorize implements Authorizer
+func (c *CachedAuthorizer) Authorize(ctx context.Context, subject Subject, action Action, resource Resource) (*Decision, error) {
+	if TraceFromContext(ctx) != nil {
+		return c.config.Authorizer.Authorize(ctx, subject, action, resource)
+	}
+
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+	key, err := c.key(generation, subject, action, resource)
+	if err != nil {
+		return c.config.Authorizer.Authorize(ctx, subject, action, resource)
+	}
+
+	if d, ok := c.get(key); ok {
+		c.hits.Inc()
+		return d, nil
+	}
+	c.misses.Inc()
+
+	decision, err := c.config.Authorizer.Authorize(ctx, subject, action, resource)
+	if err != nil {
+		return nil, err
+	}
+	c.put(generation, key, subject.ID, decision)
+	return decision, nil
+}
+
+// key hashes everything a decision depends on
+func (c *CachedAuthorizer) key(generation int64, subject Subject, action Action, resource Resource) ([sha256.Size]byte, error) {
+	var version int64
+	if c.config.PolicyVersion != nil {
+		version = c.config.PolicyVersion()
+	}
+	data, err := json.Marshal(struct {
+		Generation int64
+		Version    int64
+		Subject    Subject
+		Action     Action
+		Resource   Resource
+	}{generation, version, subject, action, resource})
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+func (c *CachedAuthorizer) get(key [sha256.Size]byte) (*Decision, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if !c.config.Now().Before(entry.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	d := entry.decision
+	return &d, true
+}
+
+func (c *CachedAuthorizer) put(generation int64, key [sha256.Size]byte, subjectID string, decision *Decision) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// An invalidation during evaluation makes the decision stale already
+	if generation != c.generation {
+		return
+	}
+	entry := &cacheEntry{key: key, subjectID: subjectID, decision: *decision, expires: c.config.Now().Add(c.config.TTL)}
+	entry.decision.Trace = nil
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.config.Size {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *CachedAuthorizer) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
+
+// Invalidate drops every cached decision, for example after a role
+// definition changed
+func (c *CachedAuthorizer) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.entries = make(map[[sha256.Size]byte]*list.Element)
+	c.lru.Init()
+}
+
+// InvalidateSubject drops the cached decisions of one subject, for example
+// after its role assignments changed
+func (c *CachedAuthorizer) InvalidateSubject(subjectID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for el := c.lru.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*cacheEntry).subjectID == subjectID {
+			c.remove(el)
+		}
+		el = next
+	}
+}
+
+// Len returns the number of cached decisions
+func (c *CachedAuthorizer) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// AddPolicy implements Authorizer and invalidates the cache
+func (c *CachedAuthorizer) AddPolicy(ctx context.Context, policy *Policy) error {
+	defer c.Invalidate()
+	return c.config.Authorizer.AddPolicy(ctx, policy)
+}
+
+// RemovePolicy implements Authorizer and invalidates the cache
+func (c *CachedAuthorizer) RemovePolicy(ctx context.Context, policyID string) error {
+	defer c.Invalidate()
+	return c.config.Authorizer.RemovePolicy(ctx, policyID)
+}
+
+// ListPolicies implements Authorizer
+func (c *CachedAuthorizer) ListPolicies(ctx context.Context) ([]*Policy, error) {
+	return c.config.Authorizer.ListPolicies(ctx)
+}
+
+// Describe implements prometheus.Collector
+func (c *CachedAuthorizer) Describe(ch chan<- *prometheus.Desc) {
+	c.hits.Describe(ch)
+	c.misses.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *CachedAuthorizer) Collect(ch chan<- prometheus.Metric) {
+	c.hits.Collect(ch)
+	c.misses.Collect(ch)
+}
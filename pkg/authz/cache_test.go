@@ -0,0 +1,153 @@
+package authz
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type countingAuthorizer struct {
+	Authorizer
+	calls int
+}
+
+func (a *countingAuthorizer) Authorize(ctx context.Context, subject Subject, action Action, resource Resource) (*Decision, error) {
+	a.calls++
+	return a.Authorizer.Authorize(ctx, subject, action, resource)
+}
+
+func TestCachedAuthorizer(t *testing.T) {
+	ctx := context.Background()
+	alice, read, doc := Subject{ID: "alice"}, Action{Name: "read"}, Resource{ID: "doc"}
+
+	setup := func(t *testing.T, config CacheConfig) (*CachedAuthorizer, *countingAuthorizer) {
+		inner := &countingAuthorizer{Authorizer: NewMemoryAuthorizer()}
+		if err := inner.AddPolicy(ctx, &Policy{ID: "read", Effect: Allow, Subjects: []Subject{{ID: "alice"}}, Actions: []Action{read}}); err != nil {
+			t.Fatalf("AddPolicy() error: %v", err)
+		}
+		config.Authorizer = inner
+		cache, err := NewCachedAuthorizer(config)
+		if err != nil {
+			t.Fatalf("NewCachedAuthorizer() error: %v", err)
+		}
+		return cache, inner
+	}
+
+	t.Run("Hits And Misses", func(t *testing.T) {
+		cache, inner := setup(t, CacheConfig{})
+		for i := 0; i < 3; i++ {
+			d, err := cache.Authorize(ctx, alice, read, doc)
+			if err != nil || !d.Allowed {
+				t.Fatalf("Authorize() = %+v, %v", d, err)
+			}
+		}
+		if inner.calls != 1 {
+			t.Errorf("inner authorizer called %d times, want 1", inner.calls)
+		}
+		if hits, misses := testutil.ToFloat64(cache.hits), testutil.ToFloat64(cache.misses); hits != 2 || misses != 1 {
+			t.Errorf("hits, misses = %v, %v, want 2, 1", hits, misses)
+		}
+		if n := testutil.CollectAndCount(cache); n != 2 {
+			t.Errorf("collected %d metrics, want 2", n)
+		}
+
+		// Traced requests are always evaluated
+		tctx, _ := WithTrace(ctx)
+		if _, err := cache.Authorize(tctx, alice, read, doc); err != nil {
+			t.Fatalf("Authorize() error: %v", err)
+		}
+		if inner.calls != 2 {
+			t.Error("traced request was served from the cache")
+		}
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		now := time.Now()
+		cache, inner := setup(t, CacheConfig{TTL: time.Minute, Now: func() time.Time { return now }})
+		_, _ = cache.Authorize(ctx, alice, read, doc)
+		now = now.Add(time.Minute)
+		_, _ = cache.Authorize(ctx, alice, read, doc)
+		if inner.calls != 2 {
+			t.Errorf("expired decision was reused")
+		}
+	})
+
+	t.Run("LRU", func(t *testing.T) {
+		cache, inner := setup(t, CacheConfig{Size: 2})
+		for _, id := range []string{"a", "b", "a", "c", "a"} {
+			_, _ = cache.Authorize(ctx, alice, read, Resource{ID: id})
+		}
+		// a stays as most recently used; b is evicted by c
+		if inner.calls != 3 || cache.Len() != 2 {
+			t.Errorf("calls = %d, len = %d, want 3, 2", inner.calls, cache.Len())
+		}
+		_, _ = cache.Authorize(ctx, alice, read, Resource{ID: "b"})
+		if inner.calls != 4 {
+			t.Error("evicted decision was reused")
+		}
+	})
+
+	t.Run("Policy Change", func(t *testing.T) {
+		cache, _ := setup(t, CacheConfig{})
+		_, _ = cache.Authorize(ctx, alice, read, doc)
+		if err := cache.RemovePolicy(ctx, "read"); err != nil {
+			t.Fatalf("RemovePolicy() error: %v", err)
+		}
+		d, _ := cache.Authorize(ctx, alice, read, doc)
+		if d.Allowed {
+			t.Error("decision survived the removal of its policy")
+		}
+	})
+
+	t.Run("Invalidate Subject", func(t *testing.T) {
+		cache, inner := setup(t, CacheConfig{})
+		_, _ = cache.Authorize(ctx, alice, read, doc)
+		_, _ = cache.Authorize(ctx, Subject{ID: "bob"}, read, doc)
+		cache.InvalidateSubject("alice")
+		_, _ = cache.Authorize(ctx, alice, read, doc)
+		_, _ = cache.Authorize(ctx, Subject{ID: "bob"}, read, doc)
+		if inner.calls != 3 {
+			t.Errorf("inner authorizer called %d times, want 3", inner.calls)
+		}
+	})
+
+	t.Run("Subject Roles Are Part Of The Key", func(t *testing.T) {
+		cache, inner := setup(t, CacheConfig{})
+		_, _ = cache.Authorize(ctx, Subject{ID: "alice", Roles: []string{"user"}}, read, doc)
+		_, _ = cache.Authorize(ctx, Subject{ID: "alice", Roles: []string{"admin"}}, read, doc)
+		if inner.calls != 2 {
+			t.Error("decision was shared between subjects with different roles")
+		}
+	})
+}
+
+func TestCachedAuthorizerPolicyEngine(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryPolicyStore()
+	engine, err := NewPolicyEngine(ctx, PolicyEngineConfig{Store: store})
+	if err != nil {
+		t.Fatalf("NewPolicyEngine() error: %v", err)
+	}
+	cache, err := NewCachedAuthorizer(CacheConfig{Authorizer: engine})
+	if err != nil {
+		t.Fatalf("NewCachedAuthorizer() error: %v", err)
+	}
+
+	alice, read, doc := Subject{ID: "alice"}, Action{Name: "read"}, Resource{ID: "doc"}
+	if d, _ := cache.Authorize(ctx, alice, read, doc); d.Allowed {
+		t.Fatal("Authorize() allowed without policies")
+	}
+
+	// A reload picked up from another instance changes the policy version
+	if err := store.Store(ctx, &Policy{ID: "read", Effect: Allow}); err != nil {
+		t.Fatalf("Store() error: %v", err)
+	}
+	if err := engine.Reload(ctx); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if d, _ := cache.Authorize(ctx, alice, read, doc); !d.Allowed {
+		t.Errorf("decision from policy version 0 served under version %d", engine.PolicySet().Version)
+	}
+}
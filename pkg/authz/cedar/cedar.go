@@ -0,0 +1,268 @@
+package cedar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	cedargo "github.com/cedar-policy/cedar-go"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+	"github.com/Gimel-Foundation/gauth/pkg/resources"
+)
+
+// Entity types and namespace of translated requests
+const (
+	Namespace           = "GAuth"
+	DefaultPrincipal    = "User"
+	DefaultResource     = "Resource"
+	ActionType          = Namespace + "::Action"
+	RoleType            = Namespace + "::Role"
+	GroupType           = Namespace + "::Group"
+	ServiceResourceType = "service"
+)
+
+// ErrNativePolicies is returned by AddPolicy and RemovePolicy; the backend
+// evaluates Cedar policies only
+var ErrNativePolicies = errors.New("native policies are not supported by the cedar backend")
+
+// Config configures a Cedar authorizer
+type Config struct {
+	// Policies is a Cedar policy document
+	Policies []byte
+
+	// Entities are added to every entity slice, for example a static
+	// group hierarchy (optional)
+	Entities cedargo.EntityMap
+
+	// Roles expands the principal's roles into their inherited roles
+	// (optional)
+	Roles *authz.RoleHierarchy
+
+	// Services resolves resources of type "service" (optional)
+	Services *resources.Manager
+
+	// Now returns the current time (default: time.Now)
+	Now func() time.Time
+}
+
+// Authorizer makes authorization decisions with Cedar policies
+type Authorizer struct {
+	config   Config
+	policies atomic.Pointer[cedargo.PolicySet]
+}
+
+// New parses the policies and creates an authorizer
+func New(config Config) (*Authorizer, error) {
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	a := &Authorizer{config: config}
+	if err := a.SetPolicies(config.Policies); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// SetPolicies parses a Cedar policy document and replaces the policies in
+// force; on a parse error the previous policies stay. Policies are named by
+// their @id annotation, or policy<n> by position.
+func (a *Authorizer) SetPolicies(document []byte) error {
+	list, err := cedargo.NewPolicyListFromBytes("policies.cedar", document)
+	if err != nil {
+		return fmt.Errorf("failed to parse cedar policies: %w", err)
+	}
+	ps := cedargo.NewPolicySet()
+	for i, p := range list {
+		id := cedargo.PolicyID(fmt.Sprintf("policy%d", i))
+		if annotated, ok := p.Annotations()["id"]; ok {
+			id = cedargo.PolicyID(annotated)
+		}
+		if !ps.Add(id, p) {
+			return fmt.Errorf("duplicate cedar policy id %s", id)
+		}
+	}
+	a.policies.Store(ps)
+	return nil
+}
+
+// Request translates an access request into a Cedar query and the entity
+// slice it needs
+func (a *Authorizer) Request(request *authz.AccessRequest) (cedargo.Request, cedargo.EntityMap, error) {
+	entities := make(cedargo.EntityMap, len(a.config.Entities)+4)
+	for uid, e := range a.config.Entities {
+		entities[uid] = e
+	}
+
+	principal := entityUID(request.Subject.Type, DefaultPrincipal, request.Subject.ID)
+	parents := make([]cedargo.EntityUID, 0, len(request.Subject.Roles)+len(request.Subject.Groups))
+	for _, role := range request.Subject.Roles {
+		parents = append(parents, cedargo.NewEntityUID(RoleType, cedargo.String(role)))
+	}
+	for _, group := range request.Subject.Groups {
+		parents = append(parents, cedargo.NewEntityUID(GroupType, cedargo.String(group)))
+	}
+	entities[principal] = cedargo.Entity{
+		UID:        principal,
+		Parents:    cedargo.NewEntityUIDSet(parents...),
+		Attributes: stringRecord(request.Subject.Attributes, nil),
+	}
+	a.addRoles(entities, request.Subject.Roles)
+
+	resource := entityUID(request.Resource.Type, DefaultResource, request.Resource.ID)
+	attrs := cedargo.RecordMap{}
+	if request.Resource.Owner != "" {
+		attrs["owner"] = entityUID(request.Subject.Type, DefaultPrincipal, request.Resource.Owner)
+	}
+	if len(request.Resource.Tags) > 0 {
+		tags := make([]cedargo.Value, 0, len(request.Resource.Tags))
+		for _, t := range request.Resource.Tags {
+			tags = append(tags, cedargo.String(t))
+		}
+		attrs["tags"] = cedargo.NewSet(tags...)
+	}
+	if request.Resource.Type == ServiceResourceType && a.config.Services != nil {
+		if err := a.addService(attrs, request.Resource.ID); err != nil {
+			return cedargo.Request{}, nil, err
+		}
+	}
+	entities[resource] = cedargo.Entity{UID: resource, Attributes: stringRecord(request.Resource.Attributes, attrs)}
+
+	return cedargo.Request{
+		Principal: principal,
+		Action:    cedargo.NewEntityUID(ActionType, cedargo.String(request.Action.Name)),
+		Resource:  resource,
+		Context:   stringRecord(request.Context, nil),
+	}, entities, nil
+}
+
+// addRoles adds the role entities with their inherited roles as parents
+func (a *Authorizer) addRoles(entities cedargo.EntityMap, roles []string) {
+	names := make([]authz.Role, 0, len(roles))
+	for _, r := range roles {
+		names = append(names, authz.Role(r))
+	}
+	if a.config.Roles != nil {
+		names = a.config.Roles.EffectiveRoles(names...)
+	}
+	for _, name := range names {
+		uid := cedargo.NewEntityUID(RoleType, cedargo.String(name))
+		if _, ok := entities[uid]; ok {
+			continue
+		}
+		var parents []cedargo.EntityUID
+		if a.config.Roles != nil {
+			if def, ok := a.config.Roles.Role(name); ok {
+				for _, p := range def.Inherits {
+					parents = append(parents, cedargo.NewEntityUID(RoleType, cedargo.String(p)))
+				}
+			}
+		}
+		entities[uid] = cedargo.Entity{UID: uid, Parents: cedargo.NewEntityUIDSet(parents...)}
+	}
+}
+
+// addService adds the registered service's state to the resource
+// attributes
+func (a *Authorizer) addService(attrs cedargo.RecordMap, id string) error {
+	state, err := a.config.Services.GetService(resources.ServiceType(id))
+	if err != nil {
+		return fmt.Errorf("failed to resolve service %s: %w", id, err)
+	}
+	attrs["name"] = cedargo.String(state.Config.Name)
+	attrs["version"] = cedargo.String(state.Config.Version)
+	attrs["status"] = cedargo.String(state.Status)
+	deps := make([]cedargo.Value, 0, len(state.Config.Dependencies))
+	for _, d := range state.Config.Dependencies {
+		deps = append(deps, entityUID(ServiceResourceType, DefaultResource, string(d)))
+	}
+	attrs["dependencies"] = cedargo.NewSet(deps...)
+	return nil
+}
+
+// entityUID names an entity GAuth::<Type>; a lower case type such as
+// "service" becomes Service
+func entityUID(typ, fallback, id string) cedargo.EntityUID {
+	if typ == "" {
+		typ = fallback
+	}
+	return cedargo.NewEntityUID(cedargo.EntityType(Namespace+"::"+strings.ToUpper(typ[:1])+typ[1:]), cedargo.String(id))
+}
+
+// stringRecord converts string attributes into a record, adding extra
+func stringRecord(values map[string]string, extra cedargo.RecordMap) cedargo.Record {
+	m := make(cedargo.RecordMap, len(values)+len(extra))
+	for k, v := range values {
+		m[cedargo.String(k)] = cedargo.String(v)
+	}
+	for k, v := range extra {
+		m[k] = v
+	}
+	return cedargo.NewRecord(m)
+}
+
+// EvaluateRequest decides an access request, including its context
+func (a *Authorizer) EvaluateRequest(_ context.Context, request *authz.AccessRequest) (*authz.Decision, error) {
+	req, entities, err := a.Request(request)
+	if err != nil {
+		return nil, err
+	}
+	decision, diag := a.policies.Load().IsAuthorized(entities, req)
+
+	result := &authz.Decision{Allowed: decision == cedargo.Allow, Timestamp: a.config.Now()}
+	var ids []string
+	for _, r := range diag.Reasons {
+		ids = append(ids, string(r.PolicyID))
+	}
+	sort.Strings(ids)
+	switch {
+	case len(ids) > 0 && result.Allowed:
+		result.Policy = ids[0]
+		result.Reason = "permitted by cedar policy " + strings.Join(ids, ", ")
+	case len(ids) > 0:
+		result.Policy = ids[0]
+		result.Reason = "forbidden by cedar policy " + strings.Join(ids, ", ")
+	default:
+		result.Reason = "no cedar policy permits the request"
+	}
+	for _, e := range diag.Errors {
+		result.Reason += fmt.Sprintf("; policy %s failed: %s", e.PolicyID, e.Message)
+	}
+	return result, nil
+}
+
+// Authorize implements authz.Authorizer
+func (a *Authorizer) Authorize(ctx context.Context, subject authz.Subject, action authz.Action, resource authz.Resource) (*authz.Decision, error) {
+	return a.EvaluateRequest(ctx, &authz.AccessRequest{Subject: subject, Action: action, Resource: resource})
+}
+
+// AddPolicy implements authz.Authorizer; it always fails with
+// ErrNativePolicies
+func (a *Authorizer) AddPolicy(context.Context, *authz.Policy) error {
+	return ErrNativePolicies
+}
+
+// RemovePolicy implements authz.Authorizer; it always fails with
+// ErrNativePolicies
+func (a *Authorizer) RemovePolicy(context.Context, string) error {
+	return ErrNativePolicies
+}
+
+// ListPolicies implements authz.Authorizer with the IDs and effects of the
+// Cedar policies
+func (a *Authorizer) ListPolicies(context.Context) ([]*authz.Policy, error) {
+	var policies []*authz.Policy
+	for id, p := range a.policies.Load().All() {
+		effect := authz.Allow
+		if p.Effect() == cedargo.Forbid {
+			effect = authz.Deny
+		}
+		policies = append(policies, &authz.Policy{ID: string(id), Effect: effect})
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].ID < policies[j].ID })
+	return policies, nil
+}
@@ -0,0 +1,158 @@
+package cedar
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+	"github.com/Gimel-Foundation/gauth/pkg/resources"
+)
+
+const testPolicies = `
+@id("managers-approve")
+permit (
+    principal in GAuth::Role::"manager",
+    action == GAuth::Action::"approve",
+    resource is GAuth::Resource
+) when { resource.owner != principal };
+
+@id("owners-read")
+permit (principal, action == GAuth::Action::"read", resource)
+    when { resource has owner && resource.owner == principal };
+
+@id("audit-context")
+permit (principal, action == GAuth::Action::"export", resource)
+    when { context.purpose == "audit" && principal.department == "finance" };
+
+@id("services-call")
+permit (principal in GAuth::Group::"backend", action == GAuth::Action::"call", resource is GAuth::Service);
+
+@id("services-maintenance")
+forbid (principal, action, resource is GAuth::Service)
+    when { resource.status == "maintenance" };
+`
+
+func newTestAuthorizer(t *testing.T, services *resources.Manager) *Authorizer {
+	t.Helper()
+	roles, err := authz.NewRoleHierarchy([]*authz.RoleDefinition{
+		{Name: "manager"},
+		{Name: "admin", Inherits: []authz.Role{"manager"}},
+	})
+	if err != nil {
+		t.Fatalf("NewRoleHierarchy() error: %v", err)
+	}
+	a, err := New(Config{Policies: []byte(testPolicies), Roles: roles, Services: services})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	return a
+}
+
+func TestAuthorize(t *testing.T) {
+	ctx := context.Background()
+	a := newTestAuthorizer(t, nil)
+	report := authz.Resource{ID: "report-1", Owner: "alice"}
+
+	tests := []struct {
+		name     string
+		request  *authz.AccessRequest
+		want     bool
+		inReason string
+	}{
+		{"Manager Approves", &authz.AccessRequest{
+			Subject: authz.Subject{ID: "bob", Roles: []string{"manager"}}, Action: authz.Action{Name: "approve"}, Resource: report,
+		}, true, "managers-approve"},
+		{"Inherited Role Approves", &authz.AccessRequest{
+			Subject: authz.Subject{ID: "carol", Roles: []string{"admin"}}, Action: authz.Action{Name: "approve"}, Resource: report,
+		}, true, "managers-approve"},
+		{"Owner Cannot Approve", &authz.AccessRequest{
+			Subject: authz.Subject{ID: "alice", Roles: []string{"manager"}}, Action: authz.Action{Name: "approve"}, Resource: report,
+		}, false, "no cedar policy"},
+		{"Owner Reads", &authz.AccessRequest{
+			Subject: authz.Subject{ID: "alice"}, Action: authz.Action{Name: "read"}, Resource: report,
+		}, true, "owners-read"},
+		{"Context And Attributes", &authz.AccessRequest{
+			Subject:  authz.Subject{ID: "dave", Attributes: map[string]string{"department": "finance"}},
+			Action:   authz.Action{Name: "export"},
+			Resource: report,
+			Context:  map[string]string{"purpose": "audit"},
+		}, true, "audit-context"},
+		{"Missing Context", &authz.AccessRequest{
+			Subject:  authz.Subject{ID: "dave", Attributes: map[string]string{"department": "finance"}},
+			Action:   authz.Action{Name: "export"},
+			Resource: report,
+		}, false, "audit-context failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := a.EvaluateRequest(ctx, tt.request)
+			if err != nil {
+				t.Fatalf("EvaluateRequest() error: %v", err)
+			}
+			if d.Allowed != tt.want || !strings.Contains(d.Reason, tt.inReason) {
+				t.Errorf("EvaluateRequest() = %v (%s), want %v containing %q", d.Allowed, d.Reason, tt.want, tt.inReason)
+			}
+		})
+	}
+}
+
+func TestServiceEntities(t *testing.T) {
+	ctx := context.Background()
+	services := resources.NewManager(resources.NewInMemoryConfigStore(), nil)
+	if err := services.RegisterService(ctx, resources.ServiceConfig{Type: resources.PaymentService, Name: "payments", Version: "2.1"}); err != nil {
+		t.Fatalf("RegisterService() error: %v", err)
+	}
+	a := newTestAuthorizer(t, services)
+
+	caller := authz.Subject{ID: "orders", Type: "service", Groups: []string{"backend"}}
+	payment := authz.Resource{ID: "payment", Type: ServiceResourceType}
+	if d, err := a.Authorize(ctx, caller, authz.Action{Name: "call"}, payment); err != nil || !d.Allowed {
+		t.Fatalf("Authorize() = %+v, %v, want allowed", d, err)
+	}
+
+	if err := services.UpdateStatus(resources.PaymentService, resources.StatusMaintenance); err != nil {
+		t.Fatalf("UpdateStatus() error: %v", err)
+	}
+	d, err := a.Authorize(ctx, caller, authz.Action{Name: "call"}, payment)
+	if err != nil || d.Allowed || d.Policy != "services-maintenance" {
+		t.Errorf("Authorize() during maintenance = %+v, %v, want forbidden", d, err)
+	}
+
+	if _, err := a.Authorize(ctx, caller, authz.Action{Name: "call"}, authz.Resource{ID: "unknown", Type: ServiceResourceType}); err == nil {
+		t.Error("Authorize() on an unregistered service succeeded")
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	ctx := context.Background()
+	a := newTestAuthorizer(t, nil)
+
+	policies, err := a.ListPolicies(ctx)
+	if err != nil || len(policies) != 5 {
+		t.Fatalf("ListPolicies() = %d policies, %v", len(policies), err)
+	}
+	for _, p := range policies {
+		if p.ID == "services-maintenance" && p.Effect != authz.Deny {
+			t.Errorf("forbid policy has effect %s", p.Effect)
+		}
+	}
+
+	if err := a.SetPolicies([]byte("permit (")); err == nil {
+		t.Error("SetPolicies() accepted an invalid document")
+	}
+	if policies, _ := a.ListPolicies(ctx); len(policies) != 5 {
+		t.Error("invalid document replaced the policies in force")
+	}
+	if err := a.SetPolicies([]byte(`permit (principal, action, resource);`)); err != nil {
+		t.Fatalf("SetPolicies() error: %v", err)
+	}
+	if d, _ := a.Authorize(ctx, authz.Subject{ID: "anyone"}, authz.Action{Name: "delete"}, authz.Resource{ID: "x"}); !d.Allowed {
+		t.Error("replaced policies not in force")
+	}
+
+	if err := a.AddPolicy(ctx, &authz.Policy{ID: "p"}); !errors.Is(err, ErrNativePolicies) {
+		t.Errorf("AddPolicy() error = %v, want ErrNativePolicies", err)
+	}
+}
@@ -0,0 +1,31 @@
+// Package cedar evaluates GAuth authorization requests with the Cedar
+// policy language, as an alternative to the native policy engine.
+//
+// A request becomes a Cedar query: the subject is the principal, the
+// action is GAuth::Action::"<name>" and the resource is the resource. Only
+// the entities the query needs are built (the entity slice):
+//
+//   - the principal, with its attributes and its roles and groups as
+//     parents (GAuth::Role, GAuth::Group)
+//   - each role of the principal, with the roles it inherits as parents
+//     when a role hierarchy is configured
+//   - the resource, with its attributes, tags and owner
+//   - for resources of type "service", the service registered with the
+//     pkg/resources manager, with its name, version, status and
+//     dependencies
+//
+// Entity types come from Subject.Type and Resource.Type, defaulting to
+// User and Resource, in the GAuth namespace:
+//
+//	permit (
+//	    principal in GAuth::Role::"manager",
+//	    action == GAuth::Action::"approve",
+//	    resource is GAuth::Resource
+//	) when { resource.owner != principal };
+//
+//	forbid (principal, action, resource is GAuth::Service)
+//	    when { resource.status == "maintenance" };
+//
+// The Authorizer implements authz.Authorizer; policies are Cedar text
+// loaded with New or SetPolicies rather than authz.Policy values.
+package cedar
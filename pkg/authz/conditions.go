@@ -6,9 +6,14 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Gimel-Foundation/gauth/pkg/scope"
 	"github.com/Gimel-Foundation/gauth/pkg/util"
 )
 
+// ScopeContextKey is the request context key carrying the caller's granted
+// scopes, space-delimited as in an OAuth scope parameter
+const ScopeContextKey = "scope"
+
 // TimeRangeCondition allows access only during specific time periods
 type TimeRangeCondition struct {
 	TimeRange *util.TimeRange
@@ -56,15 +61,24 @@ func (c *ResourceOwnerCondition) Evaluate(_ context.Context, request *AccessRequ
 // RoleCondition allows access based on user roles
 type RoleCondition struct {
 	RequiredRoles []Role
+
+	// Hierarchy, when set, lets roles satisfy the roles they inherit from
+	Hierarchy *RoleHierarchy
 }
 
 func (c *RoleCondition) Evaluate(_ context.Context, request *AccessRequest) (bool, error) {
 	if rolesStr, ok := request.Context["roles"]; ok {
-		roles := strings.Split(rolesStr, ",")
+		var roles []Role
+		for _, r := range strings.Split(rolesStr, ",") {
+			roles = append(roles, Role(r))
+		}
+		if c.Hierarchy != nil {
+			roles = c.Hierarchy.EffectiveRoles(roles...)
+		}
 		for _, required := range c.RequiredRoles {
 			found := false
 			for _, role := range roles {
-				if role == string(required) {
+				if role == required {
 					found = true
 					break
 				}
@@ -115,6 +129,21 @@ func (c *AttributeCondition) Evaluate(_ context.Context, request *AccessRequest)
 	return false, nil
 }
 
+// ScopeCondition allows access only when the scopes granted to the caller,
+// read from the ScopeContextKey context entry, grant every required scope.
+// Granted wildcards such as payments:* apply.
+type ScopeCondition struct {
+	Required []string
+}
+
+func (c *ScopeCondition) Evaluate(_ context.Context, request *AccessRequest) (bool, error) {
+	granted, err := scope.ParseSet(request.Context[ScopeContextKey])
+	if err != nil {
+		return false, err
+	}
+	return scope.Subset(granted.Strings(), c.Required), nil
+}
+
 // MultiCondition combines multiple conditions with AND/OR logic
 type MultiCondition struct {
 	Conditions []Condition
@@ -0,0 +1,33 @@
+package authz
+
+import (
+	"context"
+	"testing"
+)
+
+func TestScopeCondition(t *testing.T) {
+	cond := &ScopeCondition{Required: []string{"payments:invoices:read"}}
+	tests := []struct {
+		granted string
+		met     bool
+	}{
+		{"payments:invoices:read", true},
+		{"openid payments:*", true},
+		{"payments:*:read", true},
+		{"payments", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		req := &AccessRequest{Context: map[string]string{ScopeContextKey: tt.granted}}
+		met, err := cond.Evaluate(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Evaluate(%q) error: %v", tt.granted, err)
+		}
+		if met != tt.met {
+			t.Errorf("Evaluate(%q) = %v, want %v", tt.granted, met, tt.met)
+		}
+	}
+	if _, err := cond.Evaluate(context.Background(), &AccessRequest{Context: map[string]string{ScopeContextKey: "a::b"}}); err == nil {
+		t.Error("Evaluate() accepted an invalid scope")
+	}
+}
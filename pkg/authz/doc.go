@@ -32,7 +32,10 @@
 //     and enforcement.
 //   - P*P architecture: Interfaces and logic support Power Enforcement Point (PEP),
 //     Power Decision Point (PDP), Power Information Point (PIP), Power Administration
-//     Point (PAP), and Power Verification Point (PVP) roles.
+//     Point (PAP), and Power Verification Point (PVP) roles, as the EnforcementPoint,
+//     DecisionPoint, InformationPoint, AdministrationPoint and VerificationPoint
+//     interfaces. Package authz/remote serves and calls a PDP over HTTP or gRPC;
+//     package authz/pap is a PAP with a reviewed policy lifecycle and REST API.
 //   - Centralized authorization: All authorization decisions are enforced centrally;
 //     decentralized/team-based delegation is explicitly prevented (see enforcement
 //     in pkg/auth/extended_controls.go).
@@ -177,7 +180,8 @@ package authz
 //
 //  - authz_checks_total: Total number of authorization checks
 //  - authz_check_errors_total: Total number of failed checks
-//  - authz_cache_hits_total: Total number of cache hits
+//  - gauth_authz_cache_hits_total: Decisions served by CachedAuthorizer
+//  - gauth_authz_cache_misses_total: Decisions CachedAuthorizer evaluated
 //  - authz_evaluation_duration: Policy evaluation duration
 //
 // # Integration
@@ -0,0 +1,40 @@
+// Package opa delegates GAuth authorization decisions to Open Policy Agent,
+// so existing Rego policies can serve as the policy decision point.
+//
+// An Evaluator runs Rego modules in process or queries a remote OPA server.
+// Either way the request becomes the OPA input document:
+//
+//	{
+//	  "subject":  {"id": "alice", "roles": ["admin"], ...},
+//	  "action":   {"name": "read", ...},
+//	  "resource": {"id": "doc-1", "type": "document", ...},
+//	  "context":  {"purpose": "audit"},
+//	  "policy":   {...}  // only when evaluating one GAuth policy
+//	}
+//
+// The decision document at Path is either a boolean or an object with an
+// "allow" boolean and optional "reason" and "obligations":
+//
+//	package gauth.authz
+//
+//	default allow := false
+//
+//	allow if input.subject.id == input.resource.owner
+//
+// An undefined decision denies the request.
+//
+// # Embedded
+//
+//	eval, err := opa.New(ctx, opa.Config{
+//	    Modules: map[string]string{"authz.rego": source},
+//	})
+//
+// # Remote
+//
+//	eval, err := opa.New(ctx, opa.Config{URL: "http://localhost:8181"})
+//
+// The Evaluator implements authz.Authorizer and authz.PolicyEvaluator, so it
+// plugs into the PEP, the middleware and CachedAuthorizer. DecisionLogger
+// receives every decision with its input and raw result, for shipping to
+// the same decision log pipeline OPA itself uses.
+package opa
@@ -0,0 +1,293 @@
+package opa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/open-policy-agent/opa/v1/rego"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+)
+
+// DefaultPath is the decision document queried when Config.Path is empty
+const DefaultPath = "gauth/authz/allow"
+
+// ErrExternalPolicies is returned by policy management calls; the policies
+// of an Evaluator live in Rego
+var ErrExternalPolicies = errors.New("policies are managed in OPA")
+
+// Config configures an Evaluator. Set Modules to evaluate in process or URL
+// to query an OPA server.
+type Config struct {
+	// Modules are Rego sources by file name
+	Modules map[string]string
+
+	// URL is the base URL of a remote OPA server
+	URL string
+
+	// Path is the decision document, slash separated (default: DefaultPath)
+	Path string
+
+	// HTTPClient queries the remote server (default: 5s timeout)
+	HTTPClient *http.Client
+
+	// DecisionLogger receives every decision (optional)
+	DecisionLogger func(context.Context, *DecisionLog)
+
+	// Now returns the current time (default: time.Now)
+	Now func() time.Time
+}
+
+// Input is the OPA input document
+type Input struct {
+	Subject  authz.Subject     `json:"subject"`
+	Action   authz.Action      `json:"action"`
+	Resource authz.Resource    `json:"resource"`
+	Context  map[string]string `json:"context,omitempty"`
+	Policy   *authz.Policy     `json:"policy,omitempty"`
+}
+
+// DecisionLog records one decision
+type DecisionLog struct {
+	DecisionID string          `json:"decision_id"`
+	Path       string          `json:"path"`
+	Input      *Input          `json:"input"`
+	Result     interface{}     `json:"result,omitempty"`
+	Decision   *authz.Decision `json:"decision,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Duration   time.Duration   `json:"duration"`
+}
+
+// Evaluator makes authorization decisions with OPA
+type Evaluator struct {
+	config   Config
+	prepared *rego.PreparedEvalQuery
+}
+
+// New creates an evaluator; embedded modules are compiled up front
+func New(ctx context.Context, config Config) (*Evaluator, error) {
+	if (len(config.Modules) == 0) == (config.URL == "") {
+		return nil, errors.New("exactly one of modules and URL is required")
+	}
+	if config.Path == "" {
+		config.Path = DefaultPath
+	}
+	config.Path = strings.Trim(config.Path, "/")
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 5 * time.Second}
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	e := &Evaluator{config: config}
+	if config.URL != "" {
+		return e, nil
+	}
+
+	options := []func(*rego.Rego){rego.Query("data." + strings.ReplaceAll(config.Path, "/", "."))}
+	names := make([]string, 0, len(config.Modules))
+	for name := range config.Modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		options = append(options, rego.Module(name, config.Modules[name]))
+	}
+	prepared, err := rego.New(options...).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile rego modules: %w", err)
+	}
+	e.prepared = &prepared
+	return e, nil
+}
+
+// EvaluateRequest decides an access request
+func (e *Evaluator) EvaluateRequest(ctx context.Context, request *authz.AccessRequest) (*authz.Decision, error) {
+	return e.decide(ctx, &Input{
+		Subject:  request.Subject,
+		Action:   request.Action,
+		Resource: request.Resource,
+		Context:  request.Context,
+	})
+}
+
+// Evaluate implements authz.PolicyEvaluator; the policy is passed to Rego as
+// input.policy
+func (e *Evaluator) Evaluate(ctx context.Context, policy *authz.Policy, subject authz.Subject, action authz.Action, resource authz.Resource) (*authz.Decision, error) {
+	return e.decide(ctx, &Input{Subject: subject, Action: action, Resource: resource, Policy: policy})
+}
+
+// Authorize implements authz.Authorizer
+func (e *Evaluator) Authorize(ctx context.Context, subject authz.Subject, action authz.Action, resource authz.Resource) (*authz.Decision, error) {
+	return e.decide(ctx, &Input{Subject: subject, Action: action, Resource: resource})
+}
+
+// AddPolicy implements authz.Authorizer; it always fails with
+// ErrExternalPolicies
+func (e *Evaluator) AddPolicy(context.Context, *authz.Policy) error {
+	return ErrExternalPolicies
+}
+
+// RemovePolicy implements authz.Authorizer; it always fails with
+// ErrExternalPolicies
+func (e *Evaluator) RemovePolicy(context.Context, string) error {
+	return ErrExternalPolicies
+}
+
+// ListPolicies implements authz.Authorizer; Rego policies are not listed
+func (e *Evaluator) ListPolicies(context.Context) ([]*authz.Policy, error) {
+	return nil, nil
+}
+
+func (e *Evaluator) decide(ctx context.Context, input *Input) (*authz.Decision, error) {
+	start := e.config.Now()
+	var (
+		result     interface{}
+		defined    bool
+		decisionID string
+		err        error
+	)
+	if e.prepared != nil {
+		decisionID = uuid.New().String()
+		result, defined, err = e.evalEmbedded(ctx, input)
+	} else {
+		result, defined, decisionID, err = e.evalRemote(ctx, input)
+	}
+
+	var decision *authz.Decision
+	if err == nil {
+		decision, err = e.toDecision(result, defined, start)
+	}
+	if e.config.DecisionLogger != nil {
+		log := &DecisionLog{
+			DecisionID: decisionID,
+			Path:       e.config.Path,
+			Input:      input,
+			Result:     result,
+			Decision:   decision,
+			Timestamp:  start,
+			Duration:   e.config.Now().Sub(start),
+		}
+		if err != nil {
+			log.Error = err.Error()
+		}
+		e.config.DecisionLogger(ctx, log)
+	}
+	return decision, err
+}
+
+func (e *Evaluator) evalEmbedded(ctx context.Context, input *Input) (interface{}, bool, error) {
+	doc, err := inputDocument(input)
+	if err != nil {
+		return nil, false, err
+	}
+	rs, err := e.prepared.Eval(ctx, rego.EvalInput(doc))
+	if err != nil {
+		return nil, false, fmt.Errorf("opa evaluation failed: %w", err)
+	}
+	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+		return nil, false, nil
+	}
+	return rs[0].Expressions[0].Value, true, nil
+}
+
+// inputDocument converts the input to plain JSON values, the form Rego
+// evaluates fastest
+func inputDocument(input *Input) (interface{}, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode opa input: %w", err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to encode opa input: %w", err)
+	}
+	return doc, nil
+}
+
+func (e *Evaluator) evalRemote(ctx context.Context, input *Input) (interface{}, bool, string, error) {
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return nil, false, "", fmt.Errorf("failed to encode opa input: %w", err)
+	}
+	url := strings.TrimRight(e.config.URL, "/") + "/v1/data/" + e.config.Path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, false, "", fmt.Errorf("opa request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, "", fmt.Errorf("opa server returned status %d", resp.StatusCode)
+	}
+	var out struct {
+		Result     *json.RawMessage `json:"result"`
+		DecisionID string           `json:"decision_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, false, "", fmt.Errorf("failed to decode opa response: %w", err)
+	}
+	if out.Result == nil {
+		return nil, false, out.DecisionID, nil
+	}
+	var result interface{}
+	if err := json.Unmarshal(*out.Result, &result); err != nil {
+		return nil, false, out.DecisionID, fmt.Errorf("failed to decode opa response: %w", err)
+	}
+	return result, true, out.DecisionID, nil
+}
+
+// toDecision maps a boolean or object decision document to a Decision
+func (e *Evaluator) toDecision(result interface{}, defined bool, now time.Time) (*authz.Decision, error) {
+	decision := &authz.Decision{Policy: "opa:" + e.config.Path, Timestamp: now}
+	if !defined {
+		decision.Reason = "opa decision undefined"
+		return decision, nil
+	}
+	switch v := result.(type) {
+	case bool:
+		decision.Allowed = v
+	case map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid opa decision: %w", err)
+		}
+		var doc struct {
+			Allow       *bool              `json:"allow"`
+			Reason      string             `json:"reason"`
+			Obligations []authz.Obligation `json:"obligations"`
+		}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid opa decision: %w", err)
+		}
+		if doc.Allow == nil {
+			return nil, errors.New("invalid opa decision: missing allow")
+		}
+		decision.Allowed = *doc.Allow
+		decision.Reason = doc.Reason
+		decision.Obligations = doc.Obligations
+	default:
+		return nil, fmt.Errorf("invalid opa decision of type %T", result)
+	}
+	if decision.Reason == "" {
+		if decision.Allowed {
+			decision.Reason = "allowed by opa"
+		} else {
+			decision.Reason = "denied by opa"
+		}
+	}
+	return decision, nil
+}
@@ -0,0 +1,161 @@
+package opa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+)
+
+const testModule = `package gauth.authz
+
+default allow := false
+
+allow if input.subject.id == input.resource.owner
+
+allow if "admin" in input.subject.roles
+
+decision := {"allow": true, "reason": "audited read", "obligations": [{"type": "log"}]} if {
+	input.action.name == "read"
+	input.context.purpose == "audit"
+}
+`
+
+func TestEmbedded(t *testing.T) {
+	ctx := context.Background()
+	var logs []*DecisionLog
+	eval, err := New(ctx, Config{
+		Modules:        map[string]string{"authz.rego": testModule},
+		DecisionLogger: func(_ context.Context, l *DecisionLog) { logs = append(logs, l) },
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		subject authz.Subject
+		want    bool
+	}{
+		{"Owner", authz.Subject{ID: "alice"}, true},
+		{"Admin", authz.Subject{ID: "bob", Roles: []string{"admin"}}, true},
+		{"Other", authz.Subject{ID: "carol"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := eval.Authorize(ctx, tt.subject, authz.Action{Name: "write"}, authz.Resource{ID: "doc", Owner: "alice"})
+			if err != nil {
+				t.Fatalf("Authorize() error: %v", err)
+			}
+			if d.Allowed != tt.want {
+				t.Errorf("Authorize() allowed = %v, want %v", d.Allowed, tt.want)
+			}
+		})
+	}
+
+	if len(logs) != 3 || logs[0].DecisionID == "" || logs[0].Input.Subject.ID != "alice" || logs[0].Result != true {
+		t.Errorf("decision logs = %+v", logs)
+	}
+}
+
+func TestEmbeddedDecisionDocument(t *testing.T) {
+	ctx := context.Background()
+	eval, err := New(ctx, Config{
+		Modules: map[string]string{"authz.rego": testModule},
+		Path:    "gauth/authz/decision",
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	d, err := eval.EvaluateRequest(ctx, &authz.AccessRequest{
+		Subject: authz.Subject{ID: "alice"},
+		Action:  authz.Action{Name: "read"},
+		Context: map[string]string{"purpose": "audit"},
+	})
+	if err != nil {
+		t.Fatalf("EvaluateRequest() error: %v", err)
+	}
+	if !d.Allowed || d.Reason != "audited read" || len(d.Obligations) != 1 || d.Obligations[0].Type != "log" {
+		t.Errorf("EvaluateRequest() = %+v", d)
+	}
+
+	// Without the purpose the decision is undefined, which denies
+	d, err = eval.EvaluateRequest(ctx, &authz.AccessRequest{Action: authz.Action{Name: "read"}})
+	if err != nil || d.Allowed {
+		t.Errorf("EvaluateRequest() of an undefined decision = %+v, %v", d, err)
+	}
+}
+
+func TestRemote(t *testing.T) {
+	ctx := context.Background()
+	var input map[string]map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/data/gauth/authz/allow" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		subject := input["input"]["subject"].(map[string]interface{})
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"decision_id": "d-1",
+			"result":      subject["id"] == "alice",
+		})
+	}))
+	defer server.Close()
+
+	var log *DecisionLog
+	eval, err := New(ctx, Config{
+		URL:            server.URL,
+		DecisionLogger: func(_ context.Context, l *DecisionLog) { log = l },
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	d, err := eval.Authorize(ctx, authz.Subject{ID: "alice"}, authz.Action{Name: "read"}, authz.Resource{ID: "doc"})
+	if err != nil || !d.Allowed {
+		t.Fatalf("Authorize() = %+v, %v", d, err)
+	}
+	if log == nil || log.DecisionID != "d-1" {
+		t.Errorf("decision log = %+v, want the server's decision ID", log)
+	}
+	if d, _ := eval.Authorize(ctx, authz.Subject{ID: "bob"}, authz.Action{Name: "read"}, authz.Resource{ID: "doc"}); d.Allowed {
+		t.Error("Authorize() allowed bob")
+	}
+}
+
+func TestRemoteFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	eval, err := New(context.Background(), Config{URL: server.URL})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if d, err := eval.Authorize(context.Background(), authz.Subject{ID: "alice"}, authz.Action{}, authz.Resource{}); err == nil || d != nil {
+		t.Errorf("Authorize() = %+v, %v, want an error", d, err)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	ctx := context.Background()
+	if _, err := New(ctx, Config{}); err == nil {
+		t.Error("New() without modules or URL succeeded")
+	}
+	if _, err := New(ctx, Config{Modules: map[string]string{"bad.rego": "package"}}); err == nil {
+		t.Error("New() with an invalid module succeeded")
+	}
+	eval, _ := New(ctx, Config{URL: "http://localhost:8181"})
+	if err := eval.AddPolicy(ctx, &authz.Policy{ID: "p"}); !errors.Is(err, ErrExternalPolicies) {
+		t.Errorf("AddPolicy() error = %v, want ErrExternalPolicies", err)
+	}
+}
@@ -0,0 +1,34 @@
+// Package pap is the Power Administration Point: a management API for the
+// authorization policies a PolicyEngine evaluates.
+//
+// Policies are versioned. Each change creates a version that moves through
+// a review lifecycle before it takes effect:
+//
+//	draft ──submit──▶ review ──approve──▶ approved ──publish──▶ active
+//	                    │                                        │
+//	                    └──reject──▶ rejected        superseded ◀┘ (next publish)
+//	                                                 retired    ◀┘ (retire)
+//
+// Only drafts can be edited. A version must be approved by someone other
+// than its author, and only one version of a policy can be open (draft,
+// review or approved) at a time. Publishing swaps the version into the
+// engine's policy set in a single step.
+//
+// Every operation is checked against the caller's roles (PermissionRead,
+// PermissionWrite, PermissionApprove, PermissionPublish) and every change,
+// as well as every refused attempt, is written to the audit trail.
+//
+// Service.Handler serves the REST API:
+//
+//	GET    /policies                                     latest version of each policy
+//	POST   /policies                                     create a draft
+//	GET    /policies/{id}                                all versions of a policy
+//	DELETE /policies/{id}                                retire the active version
+//	GET    /policies/{id}/versions/{version}             one version
+//	PUT    /policies/{id}/versions/{version}             edit a draft
+//	POST   /policies/{id}/versions/{version}/submit      submit for review
+//	POST   /policies/{id}/versions/{version}/approve     approve
+//	POST   /policies/{id}/versions/{version}/reject      reject
+//	POST   /policies/{id}/versions/{version}/publish     put in force
+//	POST   /policies/{id}/versions/{version}/simulate    dry run against the policies in force
+package pap
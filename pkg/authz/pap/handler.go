@@ -0,0 +1,149 @@
+package pap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+	autherrors "github.com/Gimel-Foundation/gauth/pkg/errors"
+)
+
+// maxBodySize bounds decoded request bodies
+const maxBodySize = 1 << 20
+
+// reviewRequest is the optional body of approve and reject
+type reviewRequest struct {
+	Comment string `json:"comment"`
+}
+
+// Handler serves the REST API described in the package documentation.
+// actor returns the authenticated caller, typically the subject of the
+// request's token; requests without one are answered 401.
+func (s *Service) Handler(actor func(r *http.Request) string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /policies", func(w http.ResponseWriter, r *http.Request) {
+		out, err := s.List(r.Context(), actor(r))
+		respond(w, r, http.StatusOK, out, err)
+	})
+	mux.HandleFunc("POST /policies", func(w http.ResponseWriter, r *http.Request) {
+		var policy authz.Policy
+		if !decode(w, r, &policy) {
+			return
+		}
+		out, err := s.Create(r.Context(), actor(r), &policy)
+		respond(w, r, http.StatusCreated, out, err)
+	})
+	mux.HandleFunc("GET /policies/{id}", func(w http.ResponseWriter, r *http.Request) {
+		out, err := s.Versions(r.Context(), actor(r), r.PathValue("id"))
+		respond(w, r, http.StatusOK, out, err)
+	})
+	mux.HandleFunc("DELETE /policies/{id}", func(w http.ResponseWriter, r *http.Request) {
+		out, err := s.Retire(r.Context(), actor(r), r.PathValue("id"))
+		respond(w, r, http.StatusOK, out, err)
+	})
+	mux.HandleFunc("GET /policies/{id}/versions/{version}", s.versionHandler(actor, func(r *http.Request, a, id string, n int) (*PolicyVersion, error) {
+		return s.Get(r.Context(), a, id, n)
+	}))
+	mux.HandleFunc("PUT /policies/{id}/versions/{version}", func(w http.ResponseWriter, r *http.Request) {
+		var policy authz.Policy
+		if !decode(w, r, &policy) {
+			return
+		}
+		s.versionHandler(actor, func(r *http.Request, a, id string, n int) (*PolicyVersion, error) {
+			return s.Update(r.Context(), a, id, n, &policy)
+		})(w, r)
+	})
+	mux.HandleFunc("POST /policies/{id}/versions/{version}/submit", s.versionHandler(actor, func(r *http.Request, a, id string, n int) (*PolicyVersion, error) {
+		return s.Submit(r.Context(), a, id, n)
+	}))
+	mux.HandleFunc("POST /policies/{id}/versions/{version}/approve", s.reviewHandler(actor, s.Approve))
+	mux.HandleFunc("POST /policies/{id}/versions/{version}/reject", s.reviewHandler(actor, s.Reject))
+	mux.HandleFunc("POST /policies/{id}/versions/{version}/publish", s.versionHandler(actor, func(r *http.Request, a, id string, n int) (*PolicyVersion, error) {
+		return s.Publish(r.Context(), a, id, n)
+	}))
+	mux.HandleFunc("POST /policies/{id}/versions/{version}/simulate", func(w http.ResponseWriter, r *http.Request) {
+		var requests []*authz.AccessRequest
+		if !decode(w, r, &requests) {
+			return
+		}
+		n, err := strconv.Atoi(r.PathValue("version"))
+		if err != nil {
+			autherrors.New(autherrors.ErrInvalidRequest, "invalid version number").WithCause(err).WriteHTTP(w)
+			return
+		}
+		out, err := s.Simulate(r.Context(), actor(r), r.PathValue("id"), n, requests)
+		respond(w, r, http.StatusOK, out, err)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if actor(r) == "" {
+			autherrors.New(autherrors.ErrInvalidToken, "authentication required").WriteHTTP(w)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// versionHandler parses the policy ID and version number of the path
+func (s *Service) versionHandler(actor func(r *http.Request) string, op func(r *http.Request, actor, policyID string, number int) (*PolicyVersion, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(r.PathValue("version"))
+		if err != nil {
+			autherrors.New(autherrors.ErrInvalidRequest, "invalid version number").WithCause(err).WriteHTTP(w)
+			return
+		}
+		out, err := op(r, actor(r), r.PathValue("id"), n)
+		respond(w, r, http.StatusOK, out, err)
+	}
+}
+
+// reviewHandler serves approve and reject, which take an optional comment
+func (s *Service) reviewHandler(actor func(r *http.Request) string, op func(ctx context.Context, actor, policyID string, number int, comment string) (*PolicyVersion, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body reviewRequest
+		if r.ContentLength != 0 && !decode(w, r, &body) {
+			return
+		}
+		s.versionHandler(actor, func(r *http.Request, a, id string, n int) (*PolicyVersion, error) {
+			return op(r.Context(), a, id, n, body.Comment)
+		})(w, r)
+	}
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBodySize)).Decode(v); err != nil {
+		autherrors.New(autherrors.ErrInvalidRequest, "invalid request body").WithCause(err).WriteHTTP(w)
+		return false
+	}
+	return true
+}
+
+// respond writes the result, or the error with the status it maps to
+func respond(w http.ResponseWriter, r *http.Request, status int, v any, err error) {
+	if err != nil {
+		code, httpStatus, msg := autherrors.ErrServerError, http.StatusInternalServerError, "policy administration failed"
+		switch {
+		case errors.Is(err, ErrForbidden), errors.Is(err, ErrSelfApproval):
+			code, httpStatus = autherrors.ErrAccessDenied, http.StatusForbidden
+		case errors.Is(err, ErrNotFound):
+			code, httpStatus = autherrors.ErrInvalidRequest, http.StatusNotFound
+		case errors.Is(err, ErrInvalidTransition), errors.Is(err, ErrOpenVersion), errors.Is(err, authz.ErrPolicyVersionConflict):
+			code, httpStatus = autherrors.ErrInvalidRequest, http.StatusConflict
+		case errors.Is(err, ErrInvalidPolicy):
+			code, httpStatus = autherrors.ErrInvalidRequest, http.StatusBadRequest
+		}
+		if httpStatus != http.StatusInternalServerError {
+			msg = err.Error()
+		}
+		autherrors.New(code, msg).WithCause(err).WithHTTPInfo(r.URL.Path, r.Method, httpStatus, "").WriteHTTP(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,466 @@
+package pap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/audit"
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+)
+
+// Policy administration errors
+var (
+	// ErrNotFound indicates the policy or version does not exist
+	ErrNotFound = errors.New("policy version not found")
+
+	// ErrInvalidTransition indicates the version is not in the state the
+	// operation requires
+	ErrInvalidTransition = errors.New("invalid policy lifecycle transition")
+
+	// ErrOpenVersion indicates the policy already has a version in draft,
+	// review or approved
+	ErrOpenVersion = errors.New("policy has an open version")
+
+	// ErrSelfApproval indicates the author tried to approve their own
+	// version
+	ErrSelfApproval = errors.New("policy versions must be approved by someone other than the author")
+
+	// ErrForbidden indicates the caller lacks the required permission
+	ErrForbidden = errors.New("policy administration not permitted")
+
+	// ErrInvalidPolicy indicates the policy document is incomplete
+	ErrInvalidPolicy = errors.New("invalid policy")
+)
+
+// Permissions checked against the caller's roles
+const (
+	// PermissionRead allows listing and reading policy versions
+	PermissionRead authz.Permission = "policies:read"
+
+	// PermissionWrite allows creating, editing and submitting drafts
+	PermissionWrite authz.Permission = "policies:write"
+
+	// PermissionApprove allows approving and rejecting versions in review
+	PermissionApprove authz.Permission = "policies:approve"
+
+	// PermissionPublish allows publishing approved versions and retiring
+	// active ones
+	PermissionPublish authz.Permission = "policies:publish"
+)
+
+// Status is the lifecycle state of a policy version
+type Status string
+
+// Lifecycle states
+const (
+	StatusDraft      Status = "draft"
+	StatusReview     Status = "review"
+	StatusApproved   Status = "approved"
+	StatusRejected   Status = "rejected"
+	StatusActive     Status = "active"
+	StatusSuperseded Status = "superseded"
+	StatusRetired    Status = "retired"
+)
+
+// open reports whether the version is still on its way to publication
+func (s Status) open() bool {
+	return s == StatusDraft || s == StatusReview || s == StatusApproved
+}
+
+// Audit trail constants
+const (
+	// TypePolicyAdmin is the audit entry type for policy administration
+	TypePolicyAdmin = "policy_admin"
+
+	ActionPolicyCreated   = "policy_created"
+	ActionPolicyUpdated   = "policy_updated"
+	ActionPolicySubmitted = "policy_submitted"
+	ActionPolicyApproved  = "policy_approved"
+	ActionPolicyRejected  = "policy_rejected"
+	ActionPolicyPublished = "policy_published"
+	ActionPolicyRetired   = "policy_retired"
+)
+
+// publishAttempts bounds the retries of a publish that races with another
+// writer of the policy set
+const publishAttempts = 3
+
+// PolicyVersion is one version of a policy and its review history
+type PolicyVersion struct {
+	PolicyID    string        `json:"policy_id"`
+	Number      int           `json:"version"`
+	Status      Status        `json:"status"`
+	Policy      *authz.Policy `json:"policy"`
+	Author      string        `json:"author"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	Reviewer    string        `json:"reviewer,omitempty"`
+	ReviewedAt  time.Time     `json:"reviewed_at,omitempty"`
+	Comment     string        `json:"comment,omitempty"`
+	Publisher   string        `json:"publisher,omitempty"`
+	PublishedAt time.Time     `json:"published_at,omitempty"`
+}
+
+// Config configures the administration service
+type Config struct {
+	// Engine receives published policies
+	Engine *authz.PolicyEngine
+
+	// Roles grants callers the Permission* permissions
+	Roles authz.RoleManager
+
+	// Audit receives an entry for every change and every refused change
+	Audit audit.Storage
+
+	// Now returns the current time (default: time.Now)
+	Now func() time.Time
+}
+
+// Service manages policy versions and their lifecycle. Versions are kept in
+// memory; what is published lives in the engine's policy store.
+type Service struct {
+	config   Config
+	mu       sync.Mutex
+	policies map[string][]*PolicyVersion
+}
+
+// New creates the service. The policies already in force in the engine
+// become active version 1.
+func New(config Config) (*Service, error) {
+	if config.Engine == nil {
+		return nil, errors.New("policy engine is required")
+	}
+	if config.Roles == nil {
+		return nil, errors.New("role manager is required")
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	s := &Service{config: config, policies: make(map[string][]*PolicyVersion)}
+	set := config.Engine.PolicySet()
+	for _, p := range set.Policies {
+		s.policies[p.ID] = []*PolicyVersion{{
+			PolicyID:    p.ID,
+			Number:      1,
+			Status:      StatusActive,
+			Policy:      p,
+			CreatedAt:   p.CreatedAt,
+			UpdatedAt:   p.UpdatedAt,
+			PublishedAt: set.UpdatedAt,
+		}}
+	}
+	return s, nil
+}
+
+// List returns the latest version of every policy, ordered by ID
+func (s *Service) List(ctx context.Context, actor string) ([]*PolicyVersion, error) {
+	if err := s.check(ctx, actor, PermissionRead, "", ""); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*PolicyVersion, 0, len(s.policies))
+	for _, versions := range s.policies {
+		v := *versions[len(versions)-1]
+		out = append(out, &v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PolicyID < out[j].PolicyID })
+	return out, nil
+}
+
+// Versions returns all versions of a policy, oldest first
+func (s *Service) Versions(ctx context.Context, actor, policyID string) ([]*PolicyVersion, error) {
+	if err := s.check(ctx, actor, PermissionRead, "", ""); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions, ok := s.policies[policyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, policyID)
+	}
+	out := make([]*PolicyVersion, len(versions))
+	for i, v := range versions {
+		cp := *v
+		out[i] = &cp
+	}
+	return out, nil
+}
+
+// Get returns one version of a policy
+func (s *Service) Get(ctx context.Context, actor, policyID string, number int) (*PolicyVersion, error) {
+	if err := s.check(ctx, actor, PermissionRead, "", ""); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.find(policyID, number)
+	if err != nil {
+		return nil, err
+	}
+	cp := *v
+	return &cp, nil
+}
+
+// Create starts a new draft: version 1 of a new policy, or the next version
+// of an existing one
+func (s *Service) Create(ctx context.Context, actor string, policy *authz.Policy) (*PolicyVersion, error) {
+	if err := validate(policy); err != nil {
+		return nil, err
+	}
+	if err := s.check(ctx, actor, PermissionWrite, ActionPolicyCreated, policy.ID); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions := s.policies[policy.ID]
+	if n := len(versions); n > 0 && versions[n-1].Status.open() {
+		return nil, fmt.Errorf("%w: %s version %d is %s", ErrOpenVersion, policy.ID, n, versions[n-1].Status)
+	}
+	now := s.config.Now()
+	p := *policy
+	v := &PolicyVersion{
+		PolicyID:  p.ID,
+		Number:    len(versions) + 1,
+		Status:    StatusDraft,
+		Policy:    &p,
+		Author:    actor,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.policies[p.ID] = append(versions, v)
+	cp := *v
+	return &cp, s.record(ctx, actor, ActionPolicyCreated, audit.ResultSuccess, v)
+}
+
+// Update replaces the content of a draft
+func (s *Service) Update(ctx context.Context, actor, policyID string, number int, policy *authz.Policy) (*PolicyVersion, error) {
+	if err := validate(policy); err != nil {
+		return nil, err
+	}
+	if policy.ID != policyID {
+		return nil, fmt.Errorf("%w: policy ID %s does not match %s", ErrInvalidPolicy, policy.ID, policyID)
+	}
+	p := *policy
+	return s.transition(ctx, actor, PermissionWrite, ActionPolicyUpdated, policyID, number, StatusDraft, StatusDraft,
+		func(v *PolicyVersion, _ time.Time) error {
+			v.Policy = &p
+			return nil
+		})
+}
+
+// Submit sends a draft for review
+func (s *Service) Submit(ctx context.Context, actor, policyID string, number int) (*PolicyVersion, error) {
+	return s.transition(ctx, actor, PermissionWrite, ActionPolicySubmitted, policyID, number, StatusDraft, StatusReview, nil)
+}
+
+// Approve approves a version in review; the author cannot approve their own
+// version
+func (s *Service) Approve(ctx context.Context, actor, policyID string, number int, comment string) (*PolicyVersion, error) {
+	return s.transition(ctx, actor, PermissionApprove, ActionPolicyApproved, policyID, number, StatusReview, StatusApproved,
+		func(v *PolicyVersion, now time.Time) error {
+			if v.Author == actor {
+				return ErrSelfApproval
+			}
+			v.Reviewer, v.ReviewedAt, v.Comment = actor, now, comment
+			return nil
+		})
+}
+
+// Reject closes a version in review without publishing it
+func (s *Service) Reject(ctx context.Context, actor, policyID string, number int, comment string) (*PolicyVersion, error) {
+	return s.transition(ctx, actor, PermissionApprove, ActionPolicyRejected, policyID, number, StatusReview, StatusRejected,
+		func(v *PolicyVersion, now time.Time) error {
+			v.Reviewer, v.ReviewedAt, v.Comment = actor, now, comment
+			return nil
+		})
+}
+
+// Publish puts an approved version in force, superseding the policy's
+// active version
+func (s *Service) Publish(ctx context.Context, actor, policyID string, number int) (*PolicyVersion, error) {
+	return s.transition(ctx, actor, PermissionPublish, ActionPolicyPublished, policyID, number, StatusApproved, StatusActive,
+		func(v *PolicyVersion, now time.Time) error {
+			if err := s.swap(ctx, policyID, v.Policy); err != nil {
+				return err
+			}
+			for _, other := range s.policies[policyID] {
+				if other.Status == StatusActive {
+					other.Status, other.UpdatedAt = StatusSuperseded, now
+				}
+			}
+			v.Publisher, v.PublishedAt = actor, now
+			return nil
+		})
+}
+
+// Retire takes the active version of a policy out of force
+func (s *Service) Retire(ctx context.Context, actor, policyID string) (*PolicyVersion, error) {
+	if err := s.check(ctx, actor, PermissionPublish, ActionPolicyRetired, policyID); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	var active *PolicyVersion
+	for _, v := range s.policies[policyID] {
+		if v.Status == StatusActive {
+			active = v
+		}
+	}
+	s.mu.Unlock()
+	if active == nil {
+		return nil, fmt.Errorf("%w: %s has no active version", ErrNotFound, policyID)
+	}
+	return s.transition(ctx, actor, PermissionPublish, ActionPolicyRetired, policyID, active.Number, StatusActive, StatusRetired,
+		func(*PolicyVersion, time.Time) error {
+			return s.swap(ctx, policyID, nil)
+		})
+}
+
+// Simulate evaluates requests as if the version were published, comparing
+// with the policies in force. Nothing changes, so it can be run on a version
+// in any state.
+func (s *Service) Simulate(ctx context.Context, actor, policyID string, number int, requests []*authz.AccessRequest) (*authz.Simulation, error) {
+	if err := s.check(ctx, actor, PermissionRead, "", ""); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	v, err := s.find(policyID, number)
+	var policy *authz.Policy
+	if err == nil {
+		policy = v.Policy
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var proposed []*authz.Policy
+	for _, p := range s.config.Engine.PolicySet().Policies {
+		if p.ID != policyID {
+			proposed = append(proposed, p)
+		}
+	}
+	return s.config.Engine.Simulate(ctx, append(proposed, policy), requests)
+}
+
+// transition moves a version from one state to another. apply runs with
+// the lock held and may veto the change.
+func (s *Service) transition(ctx context.Context, actor string, perm authz.Permission, action, policyID string, number int, from, to Status, apply func(*PolicyVersion, time.Time) error) (*PolicyVersion, error) {
+	if err := s.check(ctx, actor, perm, action, policyID); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.find(policyID, number)
+	if err != nil {
+		return nil, err
+	}
+	if v.Status != from {
+		return nil, fmt.Errorf("%w: %s version %d is %s, not %s", ErrInvalidTransition, policyID, number, v.Status, from)
+	}
+	now := s.config.Now()
+	if apply != nil {
+		if err := apply(v, now); err != nil {
+			if errors.Is(err, ErrSelfApproval) {
+				_ = s.record(ctx, actor, action, "denied", v)
+			}
+			return nil, err
+		}
+	}
+	v.Status, v.UpdatedAt = to, now
+	cp := *v
+	return &cp, s.record(ctx, actor, action, audit.ResultSuccess, v)
+}
+
+// find returns a stored version; the caller holds the lock
+func (s *Service) find(policyID string, number int) (*PolicyVersion, error) {
+	versions := s.policies[policyID]
+	if number < 1 || number > len(versions) {
+		return nil, fmt.Errorf("%w: %s version %d", ErrNotFound, policyID, number)
+	}
+	return versions[number-1], nil
+}
+
+// swap replaces or, with a nil policy, removes a policy in the engine's
+// set, retrying when another writer changed the set in between
+func (s *Service) swap(ctx context.Context, policyID string, policy *authz.Policy) error {
+	engine := s.config.Engine
+	for attempt := 1; ; attempt++ {
+		current := engine.PolicySet().Policies
+		next := make([]*authz.Policy, 0, len(current)+1)
+		for _, p := range current {
+			if p.ID != policyID {
+				next = append(next, p)
+			}
+		}
+		if policy != nil {
+			next = append(next, policy)
+		}
+		_, err := engine.Swap(ctx, next)
+		if !errors.Is(err, authz.ErrPolicyVersionConflict) || attempt == publishAttempts {
+			return err
+		}
+		if err := engine.Reload(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// check verifies the caller holds perm. Refused changes are audited;
+// refused reads are not.
+func (s *Service) check(ctx context.Context, actor string, perm authz.Permission, action, policyID string) error {
+	allowed := false
+	if actor != "" {
+		var err error
+		if allowed, err = authz.SubjectHasPermission(ctx, s.config.Roles, actor, perm); err != nil {
+			return fmt.Errorf("failed to resolve roles: %w", err)
+		}
+	}
+	if allowed {
+		return nil
+	}
+	if action != "" {
+		_ = s.record(ctx, actor, action, "denied", &PolicyVersion{PolicyID: policyID})
+	}
+	return fmt.Errorf("%w: %s requires %s", ErrForbidden, action, perm)
+}
+
+func (s *Service) record(ctx context.Context, actor, action, result string, v *PolicyVersion) error {
+	if s.config.Audit == nil {
+		return nil
+	}
+	e := audit.NewEntry(TypePolicyAdmin).
+		WithActor(actor, audit.ActorUser).
+		WithAction(action).
+		WithTarget(v.PolicyID, "policy").
+		WithResult(result).
+		WithContext(ctx)
+	if v.Number > 0 {
+		e.WithMetadata("version", strconv.Itoa(v.Number)).
+			WithMetadata("status", string(v.Status))
+	}
+	if v.Comment != "" {
+		e.WithMetadata("comment", v.Comment)
+	}
+	if err := s.config.Audit.Store(ctx, e); err != nil {
+		return fmt.Errorf("failed to record policy change: %w", err)
+	}
+	return nil
+}
+
+// validate checks what the policy store needs to persist the policy
+func validate(policy *authz.Policy) error {
+	switch {
+	case policy == nil || policy.ID == "":
+		return fmt.Errorf("%w: policy ID is required", ErrInvalidPolicy)
+	case policy.Effect != authz.Allow && policy.Effect != authz.Deny:
+		return fmt.Errorf("%w: effect must be %s or %s", ErrInvalidPolicy, authz.Allow, authz.Deny)
+	case len(policy.Conditions) > 0:
+		return fmt.Errorf("%w: %w", ErrInvalidPolicy, authz.ErrPolicyNotPersistable)
+	}
+	return nil
+}
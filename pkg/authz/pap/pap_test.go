@@ -0,0 +1,277 @@
+package pap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Gimel-Foundation/gauth/pkg/audit"
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+)
+
+type recordingAudit struct {
+	audit.Storage
+	entries []*audit.Entry
+}
+
+func (a *recordingAudit) Store(_ context.Context, e *audit.Entry) error {
+	a.entries = append(a.entries, e)
+	return nil
+}
+
+func newTestService(t *testing.T) (*Service, *authz.PolicyEngine, *recordingAudit) {
+	t.Helper()
+	ctx := context.Background()
+	engine, err := authz.NewPolicyEngine(ctx, authz.PolicyEngineConfig{Store: authz.NewMemoryPolicyStore()})
+	if err != nil {
+		t.Fatalf("NewPolicyEngine() error: %v", err)
+	}
+	roles := authz.NewMemoryRoleManager()
+	for _, def := range []*authz.RoleDefinition{
+		{Name: "viewer", Permissions: []authz.Permission{PermissionRead}},
+		{Name: "author", Permissions: []authz.Permission{PermissionWrite}, Inherits: []authz.Role{"viewer"}},
+		{Name: "approver", Permissions: []authz.Permission{PermissionApprove, PermissionPublish}, Inherits: []authz.Role{"author"}},
+	} {
+		if err := roles.DefineRole(ctx, def); err != nil {
+			t.Fatalf("DefineRole() error: %v", err)
+		}
+	}
+	for subject, role := range map[string]authz.Role{"alice": "author", "bob": "approver", "carol": "approver", "eve": "viewer"} {
+		if err := roles.AssignRole(ctx, subject, role); err != nil {
+			t.Fatalf("AssignRole() error: %v", err)
+		}
+	}
+	trail := &recordingAudit{}
+	svc, err := New(Config{Engine: engine, Roles: roles, Audit: trail})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	return svc, engine, trail
+}
+
+func readPolicy(effect authz.Effect) *authz.Policy {
+	return &authz.Policy{
+		ID:        "docs-read",
+		Effect:    effect,
+		Subjects:  []authz.Subject{{ID: "user1"}},
+		Resources: []authz.Resource{{ID: "doc1"}},
+		Actions:   []authz.Action{{Name: "read"}},
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	ctx := context.Background()
+	svc, engine, trail := newTestService(t)
+
+	v, err := svc.Create(ctx, "alice", readPolicy(authz.Allow))
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if v.Number != 1 || v.Status != StatusDraft || v.Author != "alice" {
+		t.Fatalf("Create() = %+v", v)
+	}
+	if _, err := svc.Create(ctx, "alice", readPolicy(authz.Deny)); !errors.Is(err, ErrOpenVersion) {
+		t.Errorf("second Create() error = %v, want ErrOpenVersion", err)
+	}
+	if _, err := svc.Publish(ctx, "bob", "docs-read", 1); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Publish() of a draft error = %v, want ErrInvalidTransition", err)
+	}
+	if _, err := svc.Submit(ctx, "alice", "docs-read", 1); err != nil {
+		t.Fatalf("Submit() error: %v", err)
+	}
+	if _, err := svc.Update(ctx, "alice", "docs-read", 1, readPolicy(authz.Deny)); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Update() in review error = %v, want ErrInvalidTransition", err)
+	}
+	if _, err := svc.Approve(ctx, "alice", "docs-read", 1, ""); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Approve() by author role error = %v, want ErrForbidden", err)
+	}
+	if _, err := svc.Approve(ctx, "bob", "docs-read", 1, "looks good"); err != nil {
+		t.Fatalf("Approve() error: %v", err)
+	}
+	v, err = svc.Publish(ctx, "bob", "docs-read", 1)
+	if err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+	if v.Status != StatusActive || v.Reviewer != "bob" || v.Publisher != "bob" {
+		t.Errorf("Publish() = %+v", v)
+	}
+
+	decision, err := engine.Authorize(ctx, authz.Subject{ID: "user1"}, authz.Action{Name: "read"}, authz.Resource{ID: "doc1"})
+	if err != nil || !decision.Allowed {
+		t.Fatalf("published policy not in force: %+v, %v", decision, err)
+	}
+
+	// Version 2 by bob must be approved by someone else
+	if _, err := svc.Create(ctx, "bob", readPolicy(authz.Deny)); err != nil {
+		t.Fatalf("Create() v2 error: %v", err)
+	}
+	if _, err := svc.Submit(ctx, "bob", "docs-read", 2); err != nil {
+		t.Fatalf("Submit() v2 error: %v", err)
+	}
+	if _, err := svc.Approve(ctx, "bob", "docs-read", 2, ""); !errors.Is(err, ErrSelfApproval) {
+		t.Errorf("self Approve() error = %v, want ErrSelfApproval", err)
+	}
+	if _, err := svc.Approve(ctx, "carol", "docs-read", 2, ""); err != nil {
+		t.Fatalf("Approve() v2 error: %v", err)
+	}
+	if _, err := svc.Publish(ctx, "carol", "docs-read", 2); err != nil {
+		t.Fatalf("Publish() v2 error: %v", err)
+	}
+	versions, err := svc.Versions(ctx, "eve", "docs-read")
+	if err != nil {
+		t.Fatalf("Versions() error: %v", err)
+	}
+	if versions[0].Status != StatusSuperseded || versions[1].Status != StatusActive {
+		t.Errorf("statuses = %s, %s", versions[0].Status, versions[1].Status)
+	}
+	decision, _ = engine.Authorize(ctx, authz.Subject{ID: "user1"}, authz.Action{Name: "read"}, authz.Resource{ID: "doc1"})
+	if decision.Allowed {
+		t.Error("v2 deny policy not in force")
+	}
+
+	if _, err := svc.Retire(ctx, "carol", "docs-read"); err != nil {
+		t.Fatalf("Retire() error: %v", err)
+	}
+	if len(engine.PolicySet().Policies) != 0 {
+		t.Errorf("retired policy still in force")
+	}
+
+	var actions []string
+	denied := 0
+	for _, e := range trail.entries {
+		if e.Result == "denied" {
+			denied++
+			continue
+		}
+		actions = append(actions, e.Action)
+	}
+	want := []string{
+		ActionPolicyCreated, ActionPolicySubmitted, ActionPolicyApproved, ActionPolicyPublished,
+		ActionPolicyCreated, ActionPolicySubmitted, ActionPolicyApproved, ActionPolicyPublished,
+		ActionPolicyRetired,
+	}
+	if len(actions) != len(want) {
+		t.Fatalf("audited actions = %v, want %v", actions, want)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Errorf("audited action %d = %s, want %s", i, actions[i], want[i])
+		}
+	}
+	if denied != 2 {
+		t.Errorf("denied entries = %d, want 2", denied)
+	}
+}
+
+func TestRejectAndValidation(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t)
+
+	if _, err := svc.Create(ctx, "alice", &authz.Policy{ID: "p"}); !errors.Is(err, ErrInvalidPolicy) {
+		t.Errorf("Create() without effect error = %v, want ErrInvalidPolicy", err)
+	}
+	if _, err := svc.Create(ctx, "eve", readPolicy(authz.Allow)); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Create() by viewer error = %v, want ErrForbidden", err)
+	}
+	if _, err := svc.Create(ctx, "alice", readPolicy(authz.Allow)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Submit(ctx, "alice", "docs-read", 1); err != nil {
+		t.Fatal(err)
+	}
+	v, err := svc.Reject(ctx, "bob", "docs-read", 1, "too broad")
+	if err != nil {
+		t.Fatalf("Reject() error: %v", err)
+	}
+	if v.Status != StatusRejected || v.Comment != "too broad" {
+		t.Errorf("Reject() = %+v", v)
+	}
+	// A rejected version is closed, so a new draft can start
+	v, err = svc.Create(ctx, "alice", readPolicy(authz.Allow))
+	if err != nil || v.Number != 2 {
+		t.Fatalf("Create() after reject = %+v, %v", v, err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	svc, engine, _ := newTestService(t)
+	srv := httptest.NewServer(svc.Handler(func(r *http.Request) string { return r.Header.Get("X-User") }))
+	defer srv.Close()
+
+	do := func(user, method, path string, body any) (*http.Response, *PolicyVersion) {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			_ = json.NewEncoder(&buf).Encode(body)
+		}
+		req, _ := http.NewRequest(method, srv.URL+path, &buf)
+		req.Header.Set("X-User", user)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		var v PolicyVersion
+		if resp.StatusCode < 300 {
+			_ = json.NewDecoder(resp.Body).Decode(&v)
+		}
+		return resp, &v
+	}
+
+	if resp, _ := do("", http.MethodGet, "/policies", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("anonymous status = %d, want 401", resp.StatusCode)
+	}
+	if resp, _ := do("eve", http.MethodPost, "/policies", readPolicy(authz.Allow)); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("viewer create status = %d, want 403", resp.StatusCode)
+	}
+	resp, v := do("alice", http.MethodPost, "/policies", readPolicy(authz.Allow))
+	if resp.StatusCode != http.StatusCreated || v.Number != 1 {
+		t.Fatalf("create status = %d, version = %+v", resp.StatusCode, v)
+	}
+	if resp, _ := do("alice", http.MethodPut, "/policies/docs-read/versions/1", readPolicy(authz.Deny)); resp.StatusCode != http.StatusOK {
+		t.Errorf("update status = %d", resp.StatusCode)
+	}
+	if resp, _ := do("alice", http.MethodPost, "/policies/docs-read/versions/1/publish", nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("author publish status = %d, want 403", resp.StatusCode)
+	}
+	var sim authz.Simulation
+	req := []*authz.AccessRequest{{Subject: authz.Subject{ID: "user1"}, Action: authz.Action{Name: "read"}, Resource: authz.Resource{ID: "doc1"}}}
+	body, _ := json.Marshal(req)
+	simReq, _ := http.NewRequest(http.MethodPost, srv.URL+"/policies/docs-read/versions/1/simulate", bytes.NewReader(body))
+	simReq.Header.Set("X-User", "eve")
+	simResp, err := http.DefaultClient.Do(simReq)
+	if err != nil {
+		t.Fatalf("simulate: %v", err)
+	}
+	_ = json.NewDecoder(simResp.Body).Decode(&sim)
+	simResp.Body.Close()
+	if simResp.StatusCode != http.StatusOK || len(sim.Results) != 1 || sim.Results[0].Proposed.Allowed || len(engine.PolicySet().Policies) != 0 {
+		t.Errorf("simulate status = %d, simulation = %+v", simResp.StatusCode, sim)
+	}
+	if resp, _ := do("bob", http.MethodPost, "/policies/docs-read/versions/1/publish", nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("publish draft status = %d, want 409", resp.StatusCode)
+	}
+	do("alice", http.MethodPost, "/policies/docs-read/versions/1/submit", nil)
+	if resp, v := do("bob", http.MethodPost, "/policies/docs-read/versions/1/approve", reviewRequest{Comment: "ok"}); resp.StatusCode != http.StatusOK || v.Comment != "ok" {
+		t.Errorf("approve status = %d, version = %+v", resp.StatusCode, v)
+	}
+	if resp, v := do("bob", http.MethodPost, "/policies/docs-read/versions/1/publish", nil); resp.StatusCode != http.StatusOK || v.Status != StatusActive {
+		t.Errorf("publish status = %d, version = %+v", resp.StatusCode, v)
+	}
+	if p, ok := engine.PolicySet().Find("docs-read"); !ok || p.Effect != authz.Deny {
+		t.Errorf("engine policy = %+v", p)
+	}
+	if resp, _ := do("eve", http.MethodGet, "/policies/docs-read/versions/7", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing version status = %d, want 404", resp.StatusCode)
+	}
+	if resp, _ := do("eve", http.MethodGet, "/policies/docs-read/versions/x", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("bad version status = %d, want 400", resp.StatusCode)
+	}
+	if resp, _ := do("bob", http.MethodDelete, "/policies/docs-read", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("retire status = %d", resp.StatusCode)
+	}
+}
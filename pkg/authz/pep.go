@@ -0,0 +1,198 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// Built-in obligation types
+const (
+	// ObligationLogLevel raises the log level for the request ("level" attribute)
+	ObligationLogLevel = "log_level"
+
+	// ObligationWatermark requires the response to be watermarked ("text" attribute)
+	ObligationWatermark = "watermark"
+
+	// ObligationRequireHeader requires a request header ("name" and optional "value" attributes)
+	ObligationRequireHeader = "require_header"
+)
+
+// Obligation is an instruction returned by the PDP that the PEP must carry
+// out for the decision to take effect (RFC111: need-to-do requirement)
+type Obligation struct {
+	ID         string            `json:"id,omitempty"`
+	Type       string            `json:"type"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Enforcement carries the request state obligation handlers act on
+type Enforcement struct {
+	Subject  Subject
+	Resource Resource
+	Action   Action
+
+	// RequestHeaders are the headers of the incoming request
+	RequestHeaders http.Header
+
+	// ResponseHeaders receive headers added by obligations, if non-nil
+	ResponseHeaders http.Header
+
+	// LogLevel is set by the log_level obligation
+	LogLevel string
+
+	// Watermark is set by the watermark obligation
+	Watermark string
+}
+
+// NewHTTPEnforcement creates an enforcement context bound to an HTTP exchange
+func NewHTTPEnforcement(r *http.Request, w http.ResponseWriter, subject Subject, action Action, resource Resource) *Enforcement {
+	e := &Enforcement{
+		Subject:        subject,
+		Action:         action,
+		Resource:       resource,
+		RequestHeaders: r.Header,
+	}
+	if w != nil {
+		e.ResponseHeaders = w.Header()
+	}
+	return e
+}
+
+// ObligationHandler enforces one type of obligation
+type ObligationHandler interface {
+	Enforce(ctx context.Context, obligation Obligation, enforcement *Enforcement) error
+}
+
+// ObligationHandlerFunc adapts a function to the ObligationHandler interface
+type ObligationHandlerFunc func(ctx context.Context, obligation Obligation, enforcement *Enforcement) error
+
+// Enforce implements ObligationHandler
+func (f ObligationHandlerFunc) Enforce(ctx context.Context, obligation Obligation, enforcement *Enforcement) error {
+	return f(ctx, obligation, enforcement)
+}
+
+// PEPConfig configures a policy enforcement point
+type PEPConfig struct {
+	// Events receives enforcement outcomes (optional)
+	Events events.EventHandler
+}
+
+// PEP enforces PDP decisions and their obligations at a resource server.
+// Decisions carrying an obligation without a registered handler are denied.
+type PEP struct {
+	config   PEPConfig
+	mu       sync.RWMutex
+	handlers map[string]ObligationHandler
+}
+
+// NewPEP creates a PEP with the built-in obligation handlers registered
+func NewPEP(config PEPConfig) *PEP {
+	p := &PEP{
+		config:   config,
+		handlers: make(map[string]ObligationHandler),
+	}
+	p.RegisterHandler(ObligationLogLevel, ObligationHandlerFunc(enforceLogLevel))
+	p.RegisterHandler(ObligationWatermark, ObligationHandlerFunc(enforceWatermark))
+	p.RegisterHandler(ObligationRequireHeader, ObligationHandlerFunc(enforceRequireHeader))
+	return p
+}
+
+// RegisterHandler registers or replaces the handler for an obligation type
+func (p *PEP) RegisterHandler(obligationType string, handler ObligationHandler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers[obligationType] = handler
+}
+
+// Enforce applies a decision. It returns an error if access was denied or
+// any obligation could not be fulfilled; in both cases access must be refused.
+func (p *PEP) Enforce(ctx context.Context, decision *Decision, enforcement *Enforcement) error {
+	if decision == nil || !decision.Allowed {
+		reason := "no decision"
+		if decision != nil {
+			reason = decision.Reason
+		}
+		p.report(ctx, events.ActionAuthorizationDenied, events.StatusFailure, enforcement, "", reason)
+		return &Error{Code: "access_denied", Message: "access denied", Details: reason}
+	}
+
+	for _, obligation := range decision.Obligations {
+		p.mu.RLock()
+		handler, ok := p.handlers[obligation.Type]
+		p.mu.RUnlock()
+
+		if !ok {
+			p.report(ctx, events.ActionObligationFailed, events.StatusFailure, enforcement, obligation.Type, "no handler registered")
+			return &Error{Code: "obligation_unsupported", Message: "unsupported obligation", Details: obligation.Type}
+		}
+		if err := handler.Enforce(ctx, obligation, enforcement); err != nil {
+			p.report(ctx, events.ActionObligationFailed, events.StatusFailure, enforcement, obligation.Type, err.Error())
+			return &Error{Code: "obligation_failed", Message: "obligation not fulfilled", Details: fmt.Sprintf("%s: %v", obligation.Type, err)}
+		}
+		p.report(ctx, events.ActionObligationEnforced, events.StatusSuccess, enforcement, obligation.Type, "")
+	}
+
+	p.report(ctx, events.ActionAuthorizationGranted, events.StatusSuccess, enforcement, "", decision.Reason)
+	return nil
+}
+
+func (p *PEP) report(ctx context.Context, action events.EventAction, status events.EventStatus, enforcement *Enforcement, obligationType, message string) {
+	if p.config.Events == nil {
+		return
+	}
+	event := events.NewAuthzEvent(action, status).WithMessage(message).WithContext(ctx)
+	if enforcement != nil {
+		event = event.WithSubject(enforcement.Subject.ID).WithResource(enforcement.Resource.ID).
+			WithStringMetadata("action", enforcement.Action.Name)
+	}
+	if obligationType != "" {
+		event = event.WithStringMetadata("obligation", obligationType)
+	}
+	p.config.Events.Handle(event)
+}
+
+func enforceLogLevel(_ context.Context, o Obligation, e *Enforcement) error {
+	level := strings.ToLower(o.Attributes["level"])
+	switch level {
+	case "debug", "info", "warn", "error":
+		e.LogLevel = level
+		return nil
+	default:
+		return fmt.Errorf("invalid log level %q", o.Attributes["level"])
+	}
+}
+
+func enforceWatermark(_ context.Context, o Obligation, e *Enforcement) error {
+	text := o.Attributes["text"]
+	if text == "" {
+		text = e.Subject.ID
+	}
+	if text == "" {
+		return fmt.Errorf("watermark text is required")
+	}
+	e.Watermark = text
+	if e.ResponseHeaders != nil {
+		e.ResponseHeaders.Set("X-Watermark", text)
+	}
+	return nil
+}
+
+func enforceRequireHeader(_ context.Context, o Obligation, e *Enforcement) error {
+	name := o.Attributes["name"]
+	if name == "" {
+		return fmt.Errorf("header name is required")
+	}
+	got := e.RequestHeaders.Get(name)
+	if got == "" {
+		return fmt.Errorf("missing required header %s", name)
+	}
+	if want, ok := o.Attributes["value"]; ok && want != got {
+		return fmt.Errorf("header %s has unexpected value", name)
+	}
+	return nil
+}
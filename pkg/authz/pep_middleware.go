@@ -0,0 +1,61 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	autherrors "github.com/Gimel-Foundation/gauth/pkg/errors"
+)
+
+// PEPMiddlewareConfig configures PEP.Middleware
+type PEPMiddlewareConfig struct {
+	// PDP decides each request, locally or in another service
+	PDP DecisionPoint
+
+	// Request maps an HTTP request to the access request sent to the PDP,
+	// typically from the authenticated token and the route
+	Request func(r *http.Request) (*AccessRequest, error)
+}
+
+type enforcementKey struct{}
+
+// EnforcementFromContext returns the enforcement state of a request that
+// passed PEP.Middleware, including log level and watermark obligations
+func EnforcementFromContext(ctx context.Context) *Enforcement {
+	e, _ := ctx.Value(enforcementKey{}).(*Enforcement)
+	return e
+}
+
+// Middleware returns HTTP middleware that asks the PDP for a decision on
+// every request and enforces it before calling the next handler. Denials
+// and unfulfilled obligations answer 403; a PDP that cannot be reached
+// answers 503 so that enforcement fails closed.
+func (p *PEP) Middleware(config PEPMiddlewareConfig) (func(http.Handler) http.Handler, error) {
+	if config.PDP == nil {
+		return nil, errors.New("PDP is required")
+	}
+	if config.Request == nil {
+		return nil, errors.New("request mapper is required")
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, err := config.Request(r)
+			if err != nil {
+				autherrors.New(autherrors.ErrInvalidRequest, "cannot build access request").WithCause(err).WriteHTTP(w)
+				return
+			}
+			decision, err := config.PDP.Decide(r.Context(), req)
+			if err != nil {
+				autherrors.New(autherrors.ErrTemporarilyUnavailable, "authorization decision unavailable").WithCause(err).WriteHTTP(w)
+				return
+			}
+			enforcement := NewHTTPEnforcement(r, w, req.Subject, req.Action, req.Resource)
+			if err := p.Enforce(r.Context(), decision, enforcement); err != nil {
+				autherrors.New(autherrors.ErrAccessDenied, "access denied").WithCause(err).WriteHTTP(w)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), enforcementKey{}, enforcement)))
+		})
+	}, nil
+}
@@ -0,0 +1,83 @@
+package authz
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+type recordingHandler struct {
+	actions []string
+}
+
+func (h *recordingHandler) Handle(e events.Event) {
+	h.actions = append(h.actions, e.Action)
+}
+
+func TestPEP(t *testing.T) {
+	ctx := context.Background()
+	subject := Subject{ID: "agent-1"}
+	action := Action{Name: "read"}
+	resource := Resource{ID: "doc-1"}
+
+	t.Run("Obligations Enforced", func(t *testing.T) {
+		handler := &recordingHandler{}
+		pep := NewPEP(PEPConfig{Events: handler})
+
+		req := httptest.NewRequest("GET", "/doc-1", nil)
+		req.Header.Set("X-Purpose", "audit")
+		rec := httptest.NewRecorder()
+		enf := NewHTTPEnforcement(req, rec, subject, action, resource)
+
+		decision := &Decision{Allowed: true, Obligations: []Obligation{
+			{Type: ObligationLogLevel, Attributes: map[string]string{"level": "DEBUG"}},
+			{Type: ObligationWatermark},
+			{Type: ObligationRequireHeader, Attributes: map[string]string{"name": "X-Purpose", "value": "audit"}},
+		}}
+		if err := pep.Enforce(ctx, decision, enf); err != nil {
+			t.Fatalf("Enforce() error: %v", err)
+		}
+		if enf.LogLevel != "debug" {
+			t.Errorf("Expected log level debug, got %q", enf.LogLevel)
+		}
+		if got := rec.Header().Get("X-Watermark"); got != "agent-1" {
+			t.Errorf("Expected watermark header agent-1, got %q", got)
+		}
+		if len(handler.actions) != 4 || handler.actions[3] != string(events.ActionAuthorizationGranted) {
+			t.Errorf("Unexpected events %v", handler.actions)
+		}
+	})
+
+	t.Run("Missing Header", func(t *testing.T) {
+		pep := NewPEP(PEPConfig{})
+		enf := NewHTTPEnforcement(httptest.NewRequest("GET", "/", nil), nil, subject, action, resource)
+		decision := &Decision{Allowed: true, Obligations: []Obligation{
+			{Type: ObligationRequireHeader, Attributes: map[string]string{"name": "X-Purpose"}},
+		}}
+		if err := pep.Enforce(ctx, decision, enf); err == nil {
+			t.Error("Expected enforcement to fail")
+		}
+	})
+
+	t.Run("Unknown Obligation", func(t *testing.T) {
+		handler := &recordingHandler{}
+		pep := NewPEP(PEPConfig{Events: handler})
+		decision := &Decision{Allowed: true, Obligations: []Obligation{{Type: "encrypt_response"}}}
+		err := pep.Enforce(ctx, decision, &Enforcement{Subject: subject})
+		if authzErr, ok := err.(*Error); !ok || authzErr.Code != "obligation_unsupported" {
+			t.Errorf("Expected obligation_unsupported error, got %v", err)
+		}
+		if len(handler.actions) != 1 || handler.actions[0] != string(events.ActionObligationFailed) {
+			t.Errorf("Unexpected events %v", handler.actions)
+		}
+	})
+
+	t.Run("Denied", func(t *testing.T) {
+		pep := NewPEP(PEPConfig{})
+		if err := pep.Enforce(ctx, &Decision{Allowed: false, Reason: "no policy"}, &Enforcement{}); err == nil {
+			t.Error("Expected denial")
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DecisionPoint is the Power Decision Point (PDP): it decides access
+// requests. It may run in the same process as the PEP or, through
+// pkg/authz/remote, in another service.
+type DecisionPoint interface {
+	Decide(ctx context.Context, request *AccessRequest) (*Decision, error)
+}
+
+// EnforcementPoint is the Power Enforcement Point (PEP): it applies a
+// decision and its obligations at the resource. PEP implements it.
+type EnforcementPoint interface {
+	Enforce(ctx context.Context, decision *Decision, enforcement *Enforcement) error
+}
+
+// InformationPoint is the Power Information Point (PIP): it supplies
+// attributes the decision depends on. AttributeSource implements it.
+type InformationPoint interface {
+	Attributes(ctx context.Context, request *AccessRequest) (map[string]string, error)
+}
+
+// AdministrationPoint is the Power Administration Point (PAP): it manages
+// the policies decisions are made from. Every Authorizer implements it.
+type AdministrationPoint interface {
+	AddPolicy(ctx context.Context, policy *Policy) error
+	RemovePolicy(ctx context.Context, policyID string) error
+	ListPolicies(ctx context.Context) ([]*Policy, error)
+}
+
+// VerificationPoint is the Power Verification Point (PVP): it verifies that
+// the subject holds the powers and credentials it claims, before any
+// policy is considered. A non-nil error denies the request.
+type VerificationPoint interface {
+	Verify(ctx context.Context, request *AccessRequest) error
+}
+
+var (
+	_ EnforcementPoint    = (*PEP)(nil)
+	_ InformationPoint    = AttributeSource{}
+	_ AdministrationPoint = Authorizer(nil)
+)
+
+// DecisionPointFunc adapts a function to the DecisionPoint interface
+type DecisionPointFunc func(ctx context.Context, request *AccessRequest) (*Decision, error)
+
+// Decide implements DecisionPoint
+func (f DecisionPointFunc) Decide(ctx context.Context, request *AccessRequest) (*Decision, error) {
+	return f(ctx, request)
+}
+
+// VerificationPointFunc adapts a function to the VerificationPoint interface
+type VerificationPointFunc func(ctx context.Context, request *AccessRequest) error
+
+// Verify implements VerificationPoint
+func (f VerificationPointFunc) Verify(ctx context.Context, request *AccessRequest) error {
+	return f(ctx, request)
+}
+
+// Attributes implements InformationPoint
+func (s AttributeSource) Attributes(ctx context.Context, request *AccessRequest) (map[string]string, error) {
+	return s.Lookup(ctx, request)
+}
+
+// AuthorizerDecisionPoint makes an Authorizer usable as a DecisionPoint.
+// The request context is not passed on, since Authorize does not take it.
+func AuthorizerDecisionPoint(a Authorizer) DecisionPoint {
+	return DecisionPointFunc(func(ctx context.Context, request *AccessRequest) (*Decision, error) {
+		return a.Authorize(ctx, request.Subject, request.Action, request.Resource)
+	})
+}
+
+// DecisionPointConfig assembles a PDP from the other roles
+type DecisionPointConfig struct {
+	// Decider makes the decision once verification passed and attributes
+	// were gathered
+	Decider DecisionPoint
+
+	// Verifiers must all accept the request (optional)
+	Verifiers []VerificationPoint
+
+	// Information points add attributes to the request context; keys the
+	// request already has and earlier points win on conflicts (optional)
+	Information []InformationPoint
+}
+
+type composedDecisionPoint struct {
+	config DecisionPointConfig
+}
+
+// NewDecisionPoint creates a PDP that runs the verifiers, merges the
+// information points' attributes into the request context and then asks
+// the decider
+func NewDecisionPoint(config DecisionPointConfig) (DecisionPoint, error) {
+	if config.Decider == nil {
+		return nil, errors.New("decider is required")
+	}
+	return &composedDecisionPoint{config: config}, nil
+}
+
+// Decide implements DecisionPoint
+func (p *composedDecisionPoint) Decide(ctx context.Context, request *AccessRequest) (*Decision, error) {
+	for _, v := range p.config.Verifiers {
+		if err := v.Verify(ctx, request); err != nil {
+			return &Decision{Reason: fmt.Sprintf("verification failed: %v", err), Timestamp: time.Now()}, nil
+		}
+	}
+
+	if len(p.config.Information) > 0 {
+		enriched := *request
+		enriched.Context = make(map[string]string, len(request.Context))
+		for k, v := range request.Context {
+			enriched.Context[k] = v
+		}
+		for _, ip := range p.config.Information {
+			attrs, err := ip.Attributes(ctx, request)
+			if err != nil {
+				return nil, fmt.Errorf("attribute lookup failed: %w", err)
+			}
+			for k, v := range attrs {
+				if _, exists := enriched.Context[k]; !exists {
+					enriched.Context[k] = v
+				}
+			}
+		}
+		request = &enriched
+	}
+	return p.config.Decider.Decide(ctx, request)
+}
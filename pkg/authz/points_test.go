@@ -0,0 +1,101 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDecisionPoint(t *testing.T) {
+	ctx := context.Background()
+	var seen *AccessRequest
+	decider := DecisionPointFunc(func(_ context.Context, r *AccessRequest) (*Decision, error) {
+		seen = r
+		return &Decision{Allowed: r.Context["risk"] == "low"}, nil
+	})
+	pdp, err := NewDecisionPoint(DecisionPointConfig{
+		Decider: decider,
+		Verifiers: []VerificationPoint{VerificationPointFunc(func(_ context.Context, r *AccessRequest) error {
+			if r.Subject.ID == "" {
+				return errors.New("no subject")
+			}
+			return nil
+		})},
+		Information: []InformationPoint{
+			AttributeSource{Name: "risk", Lookup: func(context.Context, *AccessRequest) (map[string]string, error) {
+				return map[string]string{"risk": "low", "region": "eu"}, nil
+			}},
+			AttributeSource{Name: "geo", Lookup: func(context.Context, *AccessRequest) (map[string]string, error) {
+				return map[string]string{"region": "us"}, nil
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewDecisionPoint() error: %v", err)
+	}
+
+	req := &AccessRequest{Subject: Subject{ID: "alice"}, Context: map[string]string{"purpose": "audit"}}
+	d, err := pdp.Decide(ctx, req)
+	if err != nil || !d.Allowed {
+		t.Fatalf("Decide() = %+v, %v", d, err)
+	}
+	if seen.Context["region"] != "eu" || seen.Context["purpose"] != "audit" {
+		t.Errorf("decider saw context %v", seen.Context)
+	}
+	if len(req.Context) != 1 {
+		t.Error("Decide() modified the caller's request")
+	}
+
+	d, err = pdp.Decide(ctx, &AccessRequest{})
+	if err != nil || d.Allowed {
+		t.Errorf("Decide() of an unverified request = %+v, %v, want denied", d, err)
+	}
+}
+
+func TestPEPMiddleware(t *testing.T) {
+	var decision *Decision
+	var pdpErr error
+	pdp := DecisionPointFunc(func(context.Context, *AccessRequest) (*Decision, error) {
+		return decision, pdpErr
+	})
+	mw, err := NewPEP(PEPConfig{}).Middleware(PEPMiddlewareConfig{
+		PDP: pdp,
+		Request: func(r *http.Request) (*AccessRequest, error) {
+			return &AccessRequest{Subject: Subject{ID: "alice"}, Action: Action{Name: r.Method}, Resource: Resource{ID: r.URL.Path}}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Middleware() error: %v", err)
+	}
+	var watermark string
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		watermark = EnforcementFromContext(r.Context()).Watermark
+	}))
+
+	tests := []struct {
+		name     string
+		decision *Decision
+		err      error
+		want     int
+	}{
+		{"Allowed", &Decision{Allowed: true, Obligations: []Obligation{{Type: ObligationWatermark, Attributes: map[string]string{"text": "confidential"}}}}, nil, http.StatusOK},
+		{"Denied", &Decision{Reason: "no"}, nil, http.StatusForbidden},
+		{"Unsupported Obligation", &Decision{Allowed: true, Obligations: []Obligation{{Type: "unknown"}}}, nil, http.StatusForbidden},
+		{"PDP Unavailable", nil, errors.New("connection refused"), http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, pdpErr = tt.decision, tt.err
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/doc", nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+	if watermark != "confidential" {
+		t.Errorf("handler saw watermark %q", watermark)
+	}
+}
@@ -0,0 +1,146 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// PolicyEngineConfig configures a store-backed policy engine
+type PolicyEngineConfig struct {
+	// Store holds the policy sets
+	Store PolicySetStore
+
+	// ReloadInterval is how often Run polls the store for a new version
+	// (default: 5s)
+	ReloadInterval time.Duration
+
+	// OnReload is called after a new policy set takes effect (optional)
+	OnReload func(*PolicySet)
+
+	// OnError receives reload failures; the previous set stays in force
+	// (optional)
+	OnError func(error)
+}
+
+// PolicyEngine is an Authorizer evaluating the current policy set of a
+// PolicySetStore. A new set replaces the old one in a single step, so no
+// decision sees a mix of both.
+type PolicyEngine struct {
+	config  PolicyEngineConfig
+	current atomic.Pointer[policyEngineState]
+}
+
+// policyEngineState pairs a policy set with the authorizer evaluating it
+type policyEngineState struct {
+	set        *PolicySet
+	authorizer *memoryAuthorizer
+}
+
+// NewPolicyEngine creates an engine and loads the store's current set
+func NewPolicyEngine(ctx context.Context, config PolicyEngineConfig) (*PolicyEngine, error) {
+	if config.Store == nil {
+		return nil, errors.New("policy store is required")
+	}
+	if config.ReloadInterval <= 0 {
+		config.ReloadInterval = 5 * time.Second
+	}
+	e := &PolicyEngine{config: config}
+	e.current.Store(newPolicyEngineState(&PolicySet{}))
+	if err := e.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func newPolicyEngineState(set *PolicySet) *policyEngineState {
+	a := &memoryAuthorizer{}
+	for _, p := range set.Policies {
+		a.policies.Store(p.ID, p)
+	}
+	return &policyEngineState{set: set, authorizer: a}
+}
+
+// PolicySet returns the policy set in force
+func (e *PolicyEngine) PolicySet() *PolicySet {
+	return e.current.Load().set
+}
+
+// Reload loads the store's set if its version differs from the one in force
+func (e *PolicyEngine) Reload(ctx context.Context) error {
+	version, err := e.config.Store.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if version == e.current.Load().set.Version {
+		return nil
+	}
+	set, err := e.config.Store.Load(ctx)
+	if err != nil {
+		return err
+	}
+	e.install(set)
+	return nil
+}
+
+// install puts a set in force
+func (e *PolicyEngine) install(set *PolicySet) {
+	e.current.Store(newPolicyEngineState(set))
+	if e.config.OnReload != nil {
+		e.config.OnReload(set)
+	}
+}
+
+// Run reloads the policy set every ReloadInterval until ctx is cancelled
+func (e *PolicyEngine) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.config.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.Reload(ctx); err != nil && e.config.OnError != nil {
+				e.config.OnError(err)
+			}
+		}
+	}
+}
+
+// Swap atomically replaces the whole policy set, provided nobody changed it
+// since the version in force, and puts the new set in force
+func (e *PolicyEngine) Swap(ctx context.Context, policies []*Policy) (*PolicySet, error) {
+	set, err := e.config.Store.Swap(ctx, policies, e.PolicySet().Version)
+	if err != nil {
+		return nil, err
+	}
+	e.install(set)
+	return set, nil
+}
+
+// Authorize implements Authorizer against the policy set in force
+func (e *PolicyEngine) Authorize(ctx context.Context, subject Subject, action Action, resource Resource) (*Decision, error) {
+	return e.current.Load().authorizer.Authorize(ctx, subject, action, resource)
+}
+
+// AddPolicy implements Authorizer by storing the policy and reloading
+func (e *PolicyEngine) AddPolicy(ctx context.Context, policy *Policy) error {
+	if err := e.config.Store.Store(ctx, policy); err != nil {
+		return err
+	}
+	return e.Reload(ctx)
+}
+
+// RemovePolicy implements Authorizer by deleting the policy and reloading
+func (e *PolicyEngine) RemovePolicy(ctx context.Context, policyID string) error {
+	if err := e.config.Store.Delete(ctx, policyID); err != nil {
+		return err
+	}
+	return e.Reload(ctx)
+}
+
+// ListPolicies implements Authorizer
+func (e *PolicyEngine) ListPolicies(_ context.Context) ([]*Policy, error) {
+	return e.PolicySet().Policies, nil
+}
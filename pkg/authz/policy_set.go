@@ -0,0 +1,252 @@
+package authz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Policy store errors
+var (
+	// ErrPolicyNotFound indicates the policy does not exist in the store
+	ErrPolicyNotFound = errors.New("policy not found")
+
+	// ErrPolicyVersionConflict indicates the policy set changed since the
+	// version a swap was based on
+	ErrPolicyVersionConflict = errors.New("policy set version conflict")
+
+	// ErrPolicyNotPersistable indicates a policy with conditions, which are
+	// code and cannot be stored
+	ErrPolicyNotPersistable = errors.New("policy conditions cannot be persisted")
+)
+
+// maxSwapAttempts bounds the retries of single-policy updates that race
+// with other writers
+const maxSwapAttempts = 5
+
+// PolicySet is the complete set of policies in force at one version. Stores
+// replace it as a whole, so readers never see half of an update.
+type PolicySet struct {
+	Version   int64     `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Policies  []*Policy `json:"policies"`
+}
+
+// PolicySetStore is a PolicyStore that keeps policies as versioned sets.
+// Store and Delete are single-policy swaps.
+type PolicySetStore interface {
+	PolicyStore
+
+	// Load returns the current set; an empty store has version 0
+	Load(ctx context.Context) (*PolicySet, error)
+
+	// Swap atomically replaces the set if it is still at expectedVersion
+	// and returns the new set. Otherwise it fails with
+	// ErrPolicyVersionConflict.
+	Swap(ctx context.Context, policies []*Policy, expectedVersion int64) (*PolicySet, error)
+
+	// Version returns the current set version, for cheap change detection
+	Version(ctx context.Context) (int64, error)
+}
+
+// Find returns the policy with the given ID
+func (s *PolicySet) Find(id string) (*Policy, bool) {
+	for _, p := range s.Policies {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// nextPolicySet builds the set that follows prev. Policies that are new or
+// changed since prev are stamped with the new version and time; unchanged
+// ones keep theirs.
+func nextPolicySet(prev *PolicySet, policies []*Policy, now time.Time) (*PolicySet, error) {
+	next := &PolicySet{Version: prev.Version + 1, UpdatedAt: now}
+	version := strconv.FormatInt(next.Version, 10)
+	seen := make(map[string]bool, len(policies))
+	for _, p := range policies {
+		if p == nil || p.ID == "" {
+			return nil, errors.New("policy ID is required")
+		}
+		if seen[p.ID] {
+			return nil, fmt.Errorf("duplicate policy %s", p.ID)
+		}
+		seen[p.ID] = true
+		if len(p.Conditions) > 0 {
+			return nil, fmt.Errorf("%w: policy %s", ErrPolicyNotPersistable, p.ID)
+		}
+
+		cp := *p
+		old, existed := prev.Find(p.ID)
+		switch {
+		case existed && samePolicy(old, p):
+			cp.Version, cp.CreatedAt, cp.UpdatedAt = old.Version, old.CreatedAt, old.UpdatedAt
+		case existed:
+			cp.Version, cp.CreatedAt, cp.UpdatedAt = version, old.CreatedAt, now
+		default:
+			cp.Version, cp.UpdatedAt = version, now
+			if cp.CreatedAt.IsZero() {
+				cp.CreatedAt = now
+			}
+		}
+		next.Policies = append(next.Policies, &cp)
+	}
+	return next, nil
+}
+
+// samePolicy compares the content of two policies, ignoring the version
+// bookkeeping
+func samePolicy(a, b *Policy) bool {
+	ca, cb := *a, *b
+	ca.Version, ca.CreatedAt, ca.UpdatedAt = "", time.Time{}, time.Time{}
+	cb.Version, cb.CreatedAt, cb.UpdatedAt = "", time.Time{}, time.Time{}
+	ja, errA := json.Marshal(&ca)
+	jb, errB := json.Marshal(&cb)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
+
+// storePolicy upserts a policy with a single-policy swap, retrying when
+// other writers get in first
+func storePolicy(ctx context.Context, s PolicySetStore, policy *Policy) error {
+	if policy == nil || policy.ID == "" {
+		return errors.New("policy ID is required")
+	}
+	return updatePolicySet(ctx, s, func(set *PolicySet) ([]*Policy, error) {
+		policies := make([]*Policy, 0, len(set.Policies)+1)
+		replaced := false
+		for _, p := range set.Policies {
+			if p.ID == policy.ID {
+				p, replaced = policy, true
+			}
+			policies = append(policies, p)
+		}
+		if !replaced {
+			policies = append(policies, policy)
+		}
+		return policies, nil
+	})
+}
+
+// deletePolicy removes a policy with a single-policy swap
+func deletePolicy(ctx context.Context, s PolicySetStore, id string) error {
+	return updatePolicySet(ctx, s, func(set *PolicySet) ([]*Policy, error) {
+		if _, ok := set.Find(id); !ok {
+			return nil, fmt.Errorf("%w: %s", ErrPolicyNotFound, id)
+		}
+		policies := make([]*Policy, 0, len(set.Policies))
+		for _, p := range set.Policies {
+			if p.ID != id {
+				policies = append(policies, p)
+			}
+		}
+		return policies, nil
+	})
+}
+
+// getPolicy returns one policy of the current set
+func getPolicy(ctx context.Context, s PolicySetStore, id string) (*Policy, error) {
+	set, err := s.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := set.Find(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPolicyNotFound, id)
+	}
+	return p, nil
+}
+
+// listPolicies returns the policies of the current set
+func listPolicies(ctx context.Context, s PolicySetStore) ([]*Policy, error) {
+	set, err := s.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return set.Policies, nil
+}
+
+func updatePolicySet(ctx context.Context, s PolicySetStore, update func(*PolicySet) ([]*Policy, error)) error {
+	var err error
+	for attempt := 0; attempt < maxSwapAttempts; attempt++ {
+		var set *PolicySet
+		if set, err = s.Load(ctx); err != nil {
+			return err
+		}
+		var policies []*Policy
+		if policies, err = update(set); err != nil {
+			return err
+		}
+		if _, err = s.Swap(ctx, policies, set.Version); !errors.Is(err, ErrPolicyVersionConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+// MemoryPolicyStore keeps the policy set in memory
+type MemoryPolicyStore struct {
+	mu  sync.RWMutex
+	set *PolicySet
+	now func() time.Time
+}
+
+// NewMemoryPolicyStore creates an empty in-memory policy store
+func NewMemoryPolicyStore() *MemoryPolicyStore {
+	return &MemoryPolicyStore{set: &PolicySet{}, now: time.Now}
+}
+
+// Load implements PolicySetStore
+func (s *MemoryPolicyStore) Load(_ context.Context) (*PolicySet, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set, nil
+}
+
+// Swap implements PolicySetStore
+func (s *MemoryPolicyStore) Swap(_ context.Context, policies []*Policy, expectedVersion int64) (*PolicySet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.set.Version != expectedVersion {
+		return nil, ErrPolicyVersionConflict
+	}
+	next, err := nextPolicySet(s.set, policies, s.now())
+	if err != nil {
+		return nil, err
+	}
+	s.set = next
+	return next, nil
+}
+
+// Version implements PolicySetStore
+func (s *MemoryPolicyStore) Version(_ context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.Version, nil
+}
+
+// Store implements PolicyStore
+func (s *MemoryPolicyStore) Store(ctx context.Context, policy *Policy) error {
+	return storePolicy(ctx, s, policy)
+}
+
+// Get implements PolicyStore
+func (s *MemoryPolicyStore) Get(ctx context.Context, id string) (*Policy, error) {
+	return getPolicy(ctx, s, id)
+}
+
+// Delete implements PolicyStore
+func (s *MemoryPolicyStore) Delete(ctx context.Context, id string) error {
+	return deletePolicy(ctx, s, id)
+}
+
+// List implements PolicyStore
+func (s *MemoryPolicyStore) List(ctx context.Context) ([]*Policy, error) {
+	return listPolicies(ctx, s)
+}
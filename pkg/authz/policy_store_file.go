@@ -0,0 +1,165 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FilePolicyStore keeps the policy set in a JSON or YAML file, chosen by
+// the file extension. Writes replace the file atomically by renaming a
+// temporary file over it. Operators editing the file by hand must increase
+// its version for engines to pick the change up.
+type FilePolicyStore struct {
+	path string
+	yaml bool
+	mu   sync.Mutex
+	now  func() time.Time
+}
+
+// NewFilePolicyStore creates a store backed by path (.json, .yaml or .yml).
+// A missing file is an empty policy set.
+func NewFilePolicyStore(path string) (*FilePolicyStore, error) {
+	s := &FilePolicyStore{path: path, now: time.Now}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+	case ".yaml", ".yml":
+		s.yaml = true
+	default:
+		return nil, fmt.Errorf("unsupported policy file extension %q", filepath.Ext(path))
+	}
+	return s, nil
+}
+
+// Load implements PolicySetStore
+func (s *FilePolicyStore) Load(_ context.Context) (*PolicySet, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &PolicySet{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	if s.yaml {
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, fmt.Errorf("failed to parse policy file: %w", err)
+		}
+	}
+	var set PolicySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+	return &set, nil
+}
+
+// Swap implements PolicySetStore
+func (s *FilePolicyStore) Swap(ctx context.Context, policies []*Policy, expectedVersion int64) (*PolicySet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, err := s.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if prev.Version != expectedVersion {
+		return nil, ErrPolicyVersionConflict
+	}
+	next, err := nextPolicySet(prev, policies, s.now())
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(next, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode policy set: %w", err)
+	}
+	if s.yaml {
+		if data, err = jsonToYAML(data); err != nil {
+			return nil, fmt.Errorf("failed to encode policy set: %w", err)
+		}
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// Version implements PolicySetStore
+func (s *FilePolicyStore) Version(ctx context.Context) (int64, error) {
+	set, err := s.Load(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return set.Version, nil
+}
+
+// Store implements PolicyStore
+func (s *FilePolicyStore) Store(ctx context.Context, policy *Policy) error {
+	return storePolicy(ctx, s, policy)
+}
+
+// Get implements PolicyStore
+func (s *FilePolicyStore) Get(ctx context.Context, id string) (*Policy, error) {
+	return getPolicy(ctx, s, id)
+}
+
+// Delete implements PolicyStore
+func (s *FilePolicyStore) Delete(ctx context.Context, id string) error {
+	return deletePolicy(ctx, s, id)
+}
+
+// List implements PolicyStore
+func (s *FilePolicyStore) List(ctx context.Context) ([]*Policy, error) {
+	return listPolicies(ctx, s)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it over path, so readers see either the old or the new content
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write policy file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write policy file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write policy file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write policy file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace policy file: %w", err)
+	}
+	return nil
+}
+
+// yamlToJSON converts a YAML document to JSON so that policies decode with
+// their JSON field names
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// jsonToYAML converts a JSON document to YAML with the same field names
+func jsonToYAML(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(doc)
+}
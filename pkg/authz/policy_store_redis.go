@@ -0,0 +1,118 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisPolicyStore keeps the policy set in Redis. Swaps run in a WATCH/MULTI
+// transaction, so concurrent writers on different instances cannot
+// overwrite each other.
+type RedisPolicyStore struct {
+	client redis.UniversalClient
+	prefix string
+	now    func() time.Time
+}
+
+// NewRedisPolicyStore creates a store under the given key prefix (default:
+// "authz:")
+func NewRedisPolicyStore(client redis.UniversalClient, prefix string) *RedisPolicyStore {
+	if prefix == "" {
+		prefix = "authz:"
+	}
+	return &RedisPolicyStore{client: client, prefix: prefix, now: time.Now}
+}
+
+func (s *RedisPolicyStore) setKey() string     { return s.prefix + "policyset" }
+func (s *RedisPolicyStore) versionKey() string { return s.prefix + "policyset:version" }
+
+// Load implements PolicySetStore
+func (s *RedisPolicyStore) Load(ctx context.Context) (*PolicySet, error) {
+	return s.load(ctx, s.client)
+}
+
+func (s *RedisPolicyStore) load(ctx context.Context, c redis.Cmdable) (*PolicySet, error) {
+	data, err := c.Get(ctx, s.setKey()).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return &PolicySet{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policy set: %w", err)
+	}
+	var set PolicySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to decode policy set: %w", err)
+	}
+	return &set, nil
+}
+
+// Swap implements PolicySetStore
+func (s *RedisPolicyStore) Swap(ctx context.Context, policies []*Policy, expectedVersion int64) (*PolicySet, error) {
+	var next *PolicySet
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		prev, err := s.load(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if prev.Version != expectedVersion {
+			return ErrPolicyVersionConflict
+		}
+		if next, err = nextPolicySet(prev, policies, s.now()); err != nil {
+			return err
+		}
+		data, err := json.Marshal(next)
+		if err != nil {
+			return fmt.Errorf("failed to encode policy set: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, s.setKey(), data, 0)
+			pipe.Set(ctx, s.versionKey(), next.Version, 0)
+			return nil
+		})
+		return err
+	}, s.setKey())
+	if errors.Is(err, redis.TxFailedErr) {
+		return nil, ErrPolicyVersionConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// Version implements PolicySetStore
+func (s *RedisPolicyStore) Version(ctx context.Context) (int64, error) {
+	v, err := s.client.Get(ctx, s.versionKey()).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read policy set version: %w", err)
+	}
+	return v, nil
+}
+
+// Store implements PolicyStore
+func (s *RedisPolicyStore) Store(ctx context.Context, policy *Policy) error {
+	return storePolicy(ctx, s, policy)
+}
+
+// Get implements PolicyStore
+func (s *RedisPolicyStore) Get(ctx context.Context, id string) (*Policy, error) {
+	return getPolicy(ctx, s, id)
+}
+
+// Delete implements PolicyStore
+func (s *RedisPolicyStore) Delete(ctx context.Context, id string) error {
+	return deletePolicy(ctx, s, id)
+}
+
+// List implements PolicyStore
+func (s *RedisPolicyStore) List(ctx context.Context) ([]*Policy, error) {
+	return listPolicies(ctx, s)
+}
@@ -0,0 +1,138 @@
+package authz
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// pqUniqueViolation is the PostgreSQL error code of a unique key conflict
+const pqUniqueViolation = "23505"
+
+// SQLPolicyStore keeps every version of the policy set in PostgreSQL. The
+// version is the primary key, so of two concurrent swaps from the same
+// version only one commits; earlier versions stay available to LoadVersion
+// for audits and rollbacks.
+type SQLPolicyStore struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// NewSQLPolicyStore wraps an open database and creates the policy table if
+// needed
+func NewSQLPolicyStore(ctx context.Context, db *sql.DB) (*SQLPolicyStore, error) {
+	if _, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS gauth_policy_sets (
+			version BIGINT PRIMARY KEY,
+			document JSONB NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)`); err != nil {
+		return nil, fmt.Errorf("failed to create policy table: %w", err)
+	}
+	return &SQLPolicyStore{db: db, now: time.Now}, nil
+}
+
+// Load implements PolicySetStore
+func (s *SQLPolicyStore) Load(ctx context.Context) (*PolicySet, error) {
+	return s.scan(s.db.QueryRowContext(ctx,
+		"SELECT document FROM gauth_policy_sets ORDER BY version DESC LIMIT 1"))
+}
+
+// LoadVersion returns an earlier policy set
+func (s *SQLPolicyStore) LoadVersion(ctx context.Context, version int64) (*PolicySet, error) {
+	set, err := s.scan(s.db.QueryRowContext(ctx,
+		"SELECT document FROM gauth_policy_sets WHERE version = $1", version))
+	if err == nil && set.Version == 0 {
+		return nil, fmt.Errorf("%w: policy set version %d", ErrPolicyNotFound, version)
+	}
+	return set, err
+}
+
+func (s *SQLPolicyStore) scan(row *sql.Row) (*PolicySet, error) {
+	var data []byte
+	err := row.Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &PolicySet{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policy set: %w", err)
+	}
+	var set PolicySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to decode policy set: %w", err)
+	}
+	return &set, nil
+}
+
+// Swap implements PolicySetStore
+func (s *SQLPolicyStore) Swap(ctx context.Context, policies []*Policy, expectedVersion int64) (*PolicySet, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin policy swap: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	prev, err := s.scan(tx.QueryRowContext(ctx,
+		"SELECT document FROM gauth_policy_sets ORDER BY version DESC LIMIT 1"))
+	if err != nil {
+		return nil, err
+	}
+	if prev.Version != expectedVersion {
+		return nil, ErrPolicyVersionConflict
+	}
+	next, err := nextPolicySet(prev, policies, s.now())
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(next)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode policy set: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO gauth_policy_sets (version, document) VALUES ($1, $2)", next.Version, data); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation {
+			return nil, ErrPolicyVersionConflict
+		}
+		return nil, fmt.Errorf("failed to store policy set: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit policy swap: %w", err)
+	}
+	return next, nil
+}
+
+// Version implements PolicySetStore
+func (s *SQLPolicyStore) Version(ctx context.Context) (int64, error) {
+	var version int64
+	if err := s.db.QueryRowContext(ctx,
+		"SELECT COALESCE(MAX(version), 0) FROM gauth_policy_sets").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read policy set version: %w", err)
+	}
+	return version, nil
+}
+
+// Store implements PolicyStore
+func (s *SQLPolicyStore) Store(ctx context.Context, policy *Policy) error {
+	return storePolicy(ctx, s, policy)
+}
+
+// Get implements PolicyStore
+func (s *SQLPolicyStore) Get(ctx context.Context, id string) (*Policy, error) {
+	return getPolicy(ctx, s, id)
+}
+
+// Delete implements PolicyStore
+func (s *SQLPolicyStore) Delete(ctx context.Context, id string) error {
+	return deletePolicy(ctx, s, id)
+}
+
+// List implements PolicyStore
+func (s *SQLPolicyStore) List(ctx context.Context) ([]*Policy, error) {
+	return listPolicies(ctx, s)
+}
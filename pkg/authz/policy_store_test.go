@@ -0,0 +1,221 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestPolicySetStores(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis.Run() error: %v", err)
+	}
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	dir := t.TempDir()
+	jsonStore, err := NewFilePolicyStore(filepath.Join(dir, "policies.json"))
+	if err != nil {
+		t.Fatalf("NewFilePolicyStore() error: %v", err)
+	}
+	yamlStore, err := NewFilePolicyStore(filepath.Join(dir, "policies.yaml"))
+	if err != nil {
+		t.Fatalf("NewFilePolicyStore() error: %v", err)
+	}
+
+	stores := map[string]PolicySetStore{
+		"Memory": NewMemoryPolicyStore(),
+		"JSON":   jsonStore,
+		"YAML":   yamlStore,
+		"Redis":  NewRedisPolicyStore(client, "test:authz:"),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			testPolicySetStore(t, store)
+		})
+	}
+}
+
+func testPolicySetStore(t *testing.T, store PolicySetStore) {
+	ctx := context.Background()
+	read := &Policy{ID: "read", Effect: Allow, Subjects: []Subject{{ID: "alice"}}, Actions: []Action{{Name: "read"}}}
+	write := &Policy{ID: "write", Effect: Allow, Subjects: []Subject{{ID: "alice"}}, Actions: []Action{{Name: "write"}}}
+
+	if v, err := store.Version(ctx); err != nil || v != 0 {
+		t.Fatalf("Version() of empty store = %d, %v", v, err)
+	}
+	if err := store.Store(ctx, read); err != nil {
+		t.Fatalf("Store() error: %v", err)
+	}
+	if err := store.Store(ctx, write); err != nil {
+		t.Fatalf("Store() error: %v", err)
+	}
+	got, err := store.Get(ctx, "read")
+	if err != nil {
+		t.Fatalf("Get() error: %v", err)
+	}
+	if got.Version != "1" || got.Subjects[0].ID != "alice" {
+		t.Errorf("Get() = %+v, want version 1 for alice", got)
+	}
+
+	// A swap keeps unchanged policies at their version
+	changed := *write
+	changed.Subjects = []Subject{{ID: "bob"}}
+	set, err := store.Swap(ctx, []*Policy{read, &changed}, 2)
+	if err != nil {
+		t.Fatalf("Swap() error: %v", err)
+	}
+	if set.Version != 3 {
+		t.Errorf("Swap() version = %d, want 3", set.Version)
+	}
+	if p, _ := set.Find("read"); p.Version != "1" {
+		t.Errorf("unchanged policy version = %q, want 1", p.Version)
+	}
+	if p, _ := set.Find("write"); p.Version != "3" {
+		t.Errorf("changed policy version = %q, want 3", p.Version)
+	}
+
+	if _, err := store.Swap(ctx, []*Policy{read}, 2); !errors.Is(err, ErrPolicyVersionConflict) {
+		t.Errorf("Swap() from a stale version error = %v, want ErrPolicyVersionConflict", err)
+	}
+	if _, err := store.Swap(ctx, []*Policy{{ID: "cond", Conditions: map[string]Condition{"owner": &ResourceOwnerCondition{}}}}, 3); !errors.Is(err, ErrPolicyNotPersistable) {
+		t.Errorf("Swap() with conditions error = %v, want ErrPolicyNotPersistable", err)
+	}
+
+	if err := store.Delete(ctx, "read"); err != nil {
+		t.Fatalf("Delete() error: %v", err)
+	}
+	if _, err := store.Get(ctx, "read"); !errors.Is(err, ErrPolicyNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want ErrPolicyNotFound", err)
+	}
+	if err := store.Delete(ctx, "read"); !errors.Is(err, ErrPolicyNotFound) {
+		t.Errorf("Delete() of a missing policy error = %v, want ErrPolicyNotFound", err)
+	}
+	policies, err := store.List(ctx)
+	if err != nil || len(policies) != 1 || policies[0].ID != "write" {
+		t.Errorf("List() = %v, %v", policies, err)
+	}
+
+	// Concurrent single-policy writers all land
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for _, id := range []string{"a", "b", "c", "d"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			errs <- store.Store(ctx, &Policy{ID: id, Effect: Allow})
+		}(id)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent Store() error: %v", err)
+		}
+	}
+	if policies, _ := store.List(ctx); len(policies) != 5 {
+		t.Errorf("List() after concurrent writes has %d policies, want 5", len(policies))
+	}
+}
+
+func TestFilePolicyStoreYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.yml")
+	doc := `version: 7
+policies:
+  - id: docs
+    effect: allow
+    subjects:
+      - id: alice
+    resources:
+      - id: /docs/*
+    actions:
+      - name: read
+`
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	store, err := NewFilePolicyStore(path)
+	if err != nil {
+		t.Fatalf("NewFilePolicyStore() error: %v", err)
+	}
+	set, err := store.Load(context.Background())
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	p, ok := set.Find("docs")
+	if set.Version != 7 || !ok || p.Resources[0].ID != "/docs/*" || p.Effect != Allow {
+		t.Fatalf("Load() = %+v", set)
+	}
+
+	if _, err := store.Swap(context.Background(), set.Policies, 7); err != nil {
+		t.Fatalf("Swap() error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	if !strings.Contains(string(data), "version: 8") || !strings.Contains(string(data), "effect: allow") {
+		t.Errorf("rewritten YAML lacks the new version or JSON field names:\n%s", data)
+	}
+}
+
+func TestPolicyEngine(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryPolicyStore()
+	if err := store.Store(ctx, &Policy{ID: "read", Effect: Allow, Subjects: []Subject{{ID: "alice"}}, Actions: []Action{{Name: "read"}}}); err != nil {
+		t.Fatalf("Store() error: %v", err)
+	}
+	var reloads []int64
+	engine, err := NewPolicyEngine(ctx, PolicyEngineConfig{
+		Store:    store,
+		OnReload: func(set *PolicySet) { reloads = append(reloads, set.Version) },
+	})
+	if err != nil {
+		t.Fatalf("NewPolicyEngine() error: %v", err)
+	}
+
+	allowed := func(subject, action string) bool {
+		d, err := engine.Authorize(ctx, Subject{ID: subject}, Action{Name: action}, Resource{ID: "doc"})
+		if err != nil {
+			t.Fatalf("Authorize() error: %v", err)
+		}
+		return d.Allowed
+	}
+	if !allowed("alice", "read") || allowed("alice", "write") {
+		t.Fatal("initial policy set not in force")
+	}
+
+	// Another instance changes the store; Reload picks it up
+	if err := store.Store(ctx, &Policy{ID: "write", Effect: Allow, Subjects: []Subject{{ID: "alice"}}, Actions: []Action{{Name: "write"}}}); err != nil {
+		t.Fatalf("Store() error: %v", err)
+	}
+	if allowed("alice", "write") {
+		t.Fatal("store change took effect before reload")
+	}
+	if err := engine.Reload(ctx); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if !allowed("alice", "write") {
+		t.Fatal("reloaded policy set not in force")
+	}
+
+	// Swapping the set replaces every policy at once
+	if _, err := engine.Swap(ctx, []*Policy{{ID: "bob", Effect: Allow, Subjects: []Subject{{ID: "bob"}}}}); err != nil {
+		t.Fatalf("Swap() error: %v", err)
+	}
+	if allowed("alice", "read") || !allowed("bob", "write") {
+		t.Fatal("swapped policy set not in force")
+	}
+	if len(reloads) != 3 || reloads[2] != 3 {
+		t.Errorf("OnReload versions = %v, want [1 2 3]", reloads)
+	}
+}
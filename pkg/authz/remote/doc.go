@@ -0,0 +1,26 @@
+// Package remote splits enforcement from decision-making across services.
+// A PDP service exposes any authz.DecisionPoint over HTTP or gRPC; PEPs in
+// other services reach it through a client that is itself an
+// authz.DecisionPoint, so it plugs into authz.PEP.Middleware unchanged.
+//
+// PDP service:
+//
+//	pdp, _ := authz.NewDecisionPoint(authz.DecisionPointConfig{
+//	    Decider:     authz.AuthorizerDecisionPoint(engine),
+//	    Information: []authz.InformationPoint{riskSource},
+//	})
+//	http.Handle(remote.HTTPPath, remote.NewHTTPHandler(pdp))
+//	remote.RegisterGRPCServer(grpcServer, pdp)
+//
+// Resource service:
+//
+//	client, _ := remote.NewHTTPClient(remote.HTTPClientConfig{URL: "https://pdp.internal"})
+//	mw, _ := authz.NewPEP(authz.PEPConfig{}).Middleware(authz.PEPMiddlewareConfig{
+//	    PDP:     client,
+//	    Request: accessRequestFromRoute,
+//	})
+//
+// The gRPC service, gauth.authz.v1.DecisionService, exchanges the JSON
+// encodings of authz.AccessRequest and authz.Decision, so no generated
+// code is needed on either side.
+package remote
@@ -0,0 +1,105 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+	autherrors "github.com/Gimel-Foundation/gauth/pkg/errors"
+)
+
+// HTTPPath is the path the HTTP PDP is served at
+const HTTPPath = "/v1/authz/decide"
+
+// maxRequestSize bounds decoded access requests
+const maxRequestSize = 1 << 20
+
+// NewHTTPHandler serves a decision point: POST an authz.AccessRequest as
+// JSON and receive the authz.Decision
+func NewHTTPHandler(pdp authz.DecisionPoint) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			autherrors.New(autherrors.ErrInvalidRequest, "method not allowed").
+				WithHTTPInfo(r.URL.Path, r.Method, http.StatusMethodNotAllowed, "").WriteHTTP(w)
+			return
+		}
+		var req authz.AccessRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestSize)).Decode(&req); err != nil {
+			autherrors.New(autherrors.ErrInvalidRequest, "invalid access request").WithCause(err).WriteHTTP(w)
+			return
+		}
+		decision, err := pdp.Decide(r.Context(), &req)
+		if err != nil {
+			autherrors.New(autherrors.ErrTemporarilyUnavailable, "decision failed").WithCause(err).WriteHTTP(w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(decision)
+	})
+}
+
+// HTTPClientConfig configures an HTTP PDP client
+type HTTPClientConfig struct {
+	// URL is the base URL of the PDP service; HTTPPath is appended
+	URL string
+
+	// Client sends the requests; give it a transport that authenticates to
+	// the PDP service (default: 5s timeout)
+	Client *http.Client
+}
+
+// HTTPClient is a DecisionPoint answered by a remote HTTP PDP
+type HTTPClient struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPClient creates an HTTP PDP client
+func NewHTTPClient(config HTTPClientConfig) (*HTTPClient, error) {
+	if config.URL == "" {
+		return nil, errors.New("PDP URL is required")
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &HTTPClient{url: strings.TrimRight(config.URL, "/") + HTTPPath, client: config.Client}, nil
+}
+
+// Decide implements authz.DecisionPoint. Failures from the PDP are returned
+// as *autherrors.Error.
+func (c *HTTPClient) Decide(ctx context.Context, request *authz.AccessRequest) (*authz.Decision, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode access request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, autherrors.New(autherrors.ErrTemporarilyUnavailable, "PDP unreachable").WithCause(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRequestSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDP response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, autherrors.NewHTTPError(resp, data)
+	}
+	var decision authz.Decision
+	if err := json.Unmarshal(data, &decision); err != nil {
+		return nil, fmt.Errorf("failed to decode PDP response: %w", err)
+	}
+	return &decision, nil
+}
@@ -0,0 +1,123 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+	autherrors "github.com/Gimel-Foundation/gauth/pkg/errors"
+)
+
+// testPDP allows alice to read and fails for the subject "broken"
+var testPDP = authz.DecisionPointFunc(func(_ context.Context, r *authz.AccessRequest) (*authz.Decision, error) {
+	if r.Subject.ID == "broken" {
+		return nil, errors.New("policy store down")
+	}
+	d := &authz.Decision{Allowed: r.Subject.ID == "alice" && r.Action.Name == "read", Reason: "test"}
+	if d.Allowed && r.Context["purpose"] == "audit" {
+		d.Obligations = []authz.Obligation{{Type: authz.ObligationLogLevel, Attributes: map[string]string{"level": "debug"}}}
+	}
+	return d, nil
+})
+
+func testDecisionPoint(t *testing.T, client authz.DecisionPoint) {
+	ctx := context.Background()
+	d, err := client.Decide(ctx, &authz.AccessRequest{
+		Subject: authz.Subject{ID: "alice"},
+		Action:  authz.Action{Name: "read"},
+		Context: map[string]string{"purpose": "audit"},
+	})
+	if err != nil {
+		t.Fatalf("Decide() error: %v", err)
+	}
+	if !d.Allowed || len(d.Obligations) != 1 || d.Obligations[0].Attributes["level"] != "debug" {
+		t.Errorf("Decide() = %+v, want allowed with the log level obligation", d)
+	}
+	if d, err := client.Decide(ctx, &authz.AccessRequest{Subject: authz.Subject{ID: "bob"}, Action: authz.Action{Name: "read"}}); err != nil || d.Allowed {
+		t.Errorf("Decide() for bob = %+v, %v, want denied", d, err)
+	}
+	if _, err := client.Decide(ctx, &authz.AccessRequest{Subject: authz.Subject{ID: "broken"}}); err == nil {
+		t.Error("Decide() hid a PDP failure")
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle(HTTPPath, NewHTTPHandler(testPDP))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := NewHTTPClient(HTTPClientConfig{URL: server.URL + "/"})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() error: %v", err)
+	}
+	testDecisionPoint(t, client)
+
+	_, err = client.Decide(context.Background(), &authz.AccessRequest{Subject: authz.Subject{ID: "broken"}})
+	var authErr *autherrors.Error
+	if !errors.As(err, &authErr) || authErr.Code != autherrors.ErrTemporarilyUnavailable {
+		t.Errorf("Decide() error = %v, want temporarily_unavailable", err)
+	}
+
+	resp, err := http.Get(server.URL + HTTPPath)
+	if err != nil {
+		t.Fatalf("GET error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want 405", resp.StatusCode)
+	}
+}
+
+func TestGRPC(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	RegisterGRPCServer(srv, testPDP)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient() error: %v", err)
+	}
+	defer conn.Close()
+	testDecisionPoint(t, NewGRPCClient(conn))
+}
+
+func TestRemotePEP(t *testing.T) {
+	server := httptest.NewServer(NewHTTPHandler(testPDP))
+	defer server.Close()
+	client, _ := NewHTTPClient(HTTPClientConfig{URL: server.URL})
+
+	mw, err := authz.NewPEP(authz.PEPConfig{}).Middleware(authz.PEPMiddlewareConfig{
+		PDP: client,
+		Request: func(r *http.Request) (*authz.AccessRequest, error) {
+			return &authz.AccessRequest{Subject: authz.Subject{ID: r.Header.Get("X-User")}, Action: authz.Action{Name: "read"}}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Middleware() error: %v", err)
+	}
+	handler := mw(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for user, want := range map[string]int{"alice": http.StatusOK, "bob": http.StatusForbidden, "broken": http.StatusServiceUnavailable} {
+		req := httptest.NewRequest(http.MethodGet, "/doc", nil)
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", user, rec.Code, want)
+		}
+	}
+}
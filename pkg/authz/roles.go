@@ -0,0 +1,385 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Role hierarchy errors
+var (
+	// ErrRoleNotFound indicates the role is not defined
+	ErrRoleNotFound = errors.New("role not found")
+
+	// ErrRoleCycle indicates a role would inherit from itself
+	ErrRoleCycle = errors.New("role inheritance cycle")
+
+	// ErrRoleInUse indicates a role that other roles still inherit from
+	ErrRoleInUse = errors.New("role is inherited by other roles")
+)
+
+// AllPermissions is the permission granting every permission
+const AllPermissions Permission = "*"
+
+// RoleDefinition is a role in a hierarchy. A role has its own permissions
+// and those of every role it inherits from, directly or indirectly, so
+// "admin" inheriting "manager" inheriting "user" gives admin all three.
+type RoleDefinition struct {
+	Name        Role         `json:"name"`
+	Permissions []Permission `json:"permissions,omitempty"`
+	Inherits    []Role       `json:"inherits,omitempty"`
+}
+
+// RoleManager is the backend holding role definitions and assignments
+type RoleManager interface {
+	// DefineRole creates or replaces a role. Inherited roles must exist and
+	// the change must not create a cycle.
+	DefineRole(ctx context.Context, role *RoleDefinition) error
+
+	// RemoveRole deletes a role and its assignments. It fails with
+	// ErrRoleInUse while other roles inherit from it.
+	RemoveRole(ctx context.Context, role Role) error
+
+	// ListRoles returns all role definitions
+	ListRoles(ctx context.Context) ([]*RoleDefinition, error)
+
+	// AssignRole gives a subject a role
+	AssignRole(ctx context.Context, subjectID string, role Role) error
+
+	// UnassignRole takes a role from a subject
+	UnassignRole(ctx context.Context, subjectID string, role Role) error
+
+	// AssignedRoles returns the roles assigned directly to a subject
+	AssignedRoles(ctx context.Context, subjectID string) ([]Role, error)
+
+	// Hierarchy returns the current role hierarchy
+	Hierarchy(ctx context.Context) (*RoleHierarchy, error)
+}
+
+// RoleHierarchy is an immutable, validated set of roles with the transitive
+// closure of their inheritance computed up front, so lookups do not walk
+// the hierarchy.
+type RoleHierarchy struct {
+	roles       map[Role]*RoleDefinition
+	closure     map[Role][]Role
+	permissions map[Role]map[Permission]struct{}
+}
+
+// NewRoleHierarchy validates role definitions and computes their closure
+func NewRoleHierarchy(roles []*RoleDefinition) (*RoleHierarchy, error) {
+	h := &RoleHierarchy{
+		roles:       make(map[Role]*RoleDefinition, len(roles)),
+		closure:     make(map[Role][]Role, len(roles)),
+		permissions: make(map[Role]map[Permission]struct{}, len(roles)),
+	}
+	for _, r := range roles {
+		if r == nil || r.Name == "" {
+			return nil, errors.New("role name is required")
+		}
+		if _, exists := h.roles[r.Name]; exists {
+			return nil, fmt.Errorf("duplicate role %s", r.Name)
+		}
+		h.roles[r.Name] = r
+	}
+	for _, r := range roles {
+		for _, parent := range r.Inherits {
+			if _, ok := h.roles[parent]; !ok {
+				return nil, fmt.Errorf("%w: %s inherited by %s", ErrRoleNotFound, parent, r.Name)
+			}
+		}
+	}
+
+	// Depth-first search; a role met again while still on the path closes
+	// a cycle
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[Role]int, len(roles))
+	var path []Role
+	var visit func(Role) error
+	visit = func(name Role) error {
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("%w: %s", ErrRoleCycle, cyclePath(path, name))
+		}
+		state[name] = visiting
+		path = append(path, name)
+
+		seen := map[Role]bool{name: true}
+		closure := []Role{name}
+		perms := make(map[Permission]struct{})
+		for _, p := range h.roles[name].Permissions {
+			perms[p] = struct{}{}
+		}
+		for _, parent := range h.roles[name].Inherits {
+			if err := visit(parent); err != nil {
+				return err
+			}
+			for _, r := range h.closure[parent] {
+				if !seen[r] {
+					seen[r] = true
+					closure = append(closure, r)
+				}
+			}
+			for p := range h.permissions[parent] {
+				perms[p] = struct{}{}
+			}
+		}
+		h.closure[name] = closure
+		h.permissions[name] = perms
+
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+	for _, r := range roles {
+		if err := visit(r.Name); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// cyclePath renders the part of path that loops back to name
+func cyclePath(path []Role, name Role) string {
+	parts := []string{string(name)}
+	for i := len(path) - 1; i >= 0 && path[i] != name; i-- {
+		parts = append(parts, string(path[i]))
+	}
+	parts = append(parts, string(name))
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return strings.Join(parts, " > ")
+}
+
+// Role returns a role definition
+func (h *RoleHierarchy) Role(name Role) (*RoleDefinition, bool) {
+	r, ok := h.roles[name]
+	return r, ok
+}
+
+// EffectiveRoles returns the given roles and every role they inherit,
+// without duplicates. Roles the hierarchy does not define stand for
+// themselves only.
+func (h *RoleHierarchy) EffectiveRoles(roles ...Role) []Role {
+	var out []Role
+	seen := make(map[Role]bool)
+	for _, role := range roles {
+		closure, ok := h.closure[role]
+		if !ok {
+			closure = []Role{role}
+		}
+		for _, r := range closure {
+			if !seen[r] {
+				seen[r] = true
+				out = append(out, r)
+			}
+		}
+	}
+	return out
+}
+
+// Includes reports whether role is other or inherits from it
+func (h *RoleHierarchy) Includes(role, other Role) bool {
+	for _, r := range h.closure[role] {
+		if r == other {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions returns the sorted permissions of the given roles, inherited
+// ones included
+func (h *RoleHierarchy) Permissions(roles ...Role) []Permission {
+	set := make(map[Permission]struct{})
+	for _, role := range roles {
+		for p := range h.permissions[role] {
+			set[p] = struct{}{}
+		}
+	}
+	out := make([]Permission, 0, len(set))
+	for p := range set {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// HasPermission reports whether any of the roles grants the permission,
+// directly, by inheritance or through AllPermissions
+func (h *RoleHierarchy) HasPermission(permission Permission, roles ...Role) bool {
+	for _, role := range roles {
+		perms := h.permissions[role]
+		if _, ok := perms[permission]; ok {
+			return true
+		}
+		if _, ok := perms[AllPermissions]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SubjectHasPermission reports whether the roles assigned to a subject grant
+// a permission
+func SubjectHasPermission(ctx context.Context, m RoleManager, subjectID string, permission Permission) (bool, error) {
+	roles, err := m.AssignedRoles(ctx, subjectID)
+	if err != nil {
+		return false, err
+	}
+	h, err := m.Hierarchy(ctx)
+	if err != nil {
+		return false, err
+	}
+	return h.HasPermission(permission, roles...), nil
+}
+
+// MemoryRoleManager keeps roles and assignments in memory
+type MemoryRoleManager struct {
+	mu          sync.RWMutex
+	roles       map[Role]*RoleDefinition
+	assignments map[string][]Role
+	hierarchy   *RoleHierarchy
+}
+
+// NewMemoryRoleManager creates an empty in-memory role manager
+func NewMemoryRoleManager() *MemoryRoleManager {
+	h, _ := NewRoleHierarchy(nil)
+	return &MemoryRoleManager{
+		roles:       make(map[Role]*RoleDefinition),
+		assignments: make(map[string][]Role),
+		hierarchy:   h,
+	}
+}
+
+// DefineRole implements RoleManager
+func (m *MemoryRoleManager) DefineRole(_ context.Context, role *RoleDefinition) error {
+	if role == nil || role.Name == "" {
+		return errors.New("role name is required")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	roles := make([]*RoleDefinition, 0, len(m.roles)+1)
+	for name, r := range m.roles {
+		if name != role.Name {
+			roles = append(roles, r)
+		}
+	}
+	cp := *role
+	roles = append(roles, &cp)
+	h, err := NewRoleHierarchy(roles)
+	if err != nil {
+		return err
+	}
+	m.roles[role.Name] = &cp
+	m.hierarchy = h
+	return nil
+}
+
+// RemoveRole implements RoleManager
+func (m *MemoryRoleManager) RemoveRole(_ context.Context, role Role) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.roles[role]; !ok {
+		return fmt.Errorf("%w: %s", ErrRoleNotFound, role)
+	}
+	roles := make([]*RoleDefinition, 0, len(m.roles))
+	for name, r := range m.roles {
+		if name == role {
+			continue
+		}
+		for _, parent := range r.Inherits {
+			if parent == role {
+				return fmt.Errorf("%w: %s inherits %s", ErrRoleInUse, name, role)
+			}
+		}
+		roles = append(roles, r)
+	}
+	h, err := NewRoleHierarchy(roles)
+	if err != nil {
+		return err
+	}
+	delete(m.roles, role)
+	m.hierarchy = h
+	for subject := range m.assignments {
+		m.unassign(subject, role)
+	}
+	return nil
+}
+
+// ListRoles implements RoleManager
+func (m *MemoryRoleManager) ListRoles(_ context.Context) ([]*RoleDefinition, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	roles := make([]*RoleDefinition, 0, len(m.roles))
+	for _, r := range m.roles {
+		roles = append(roles, r)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+// AssignRole implements RoleManager
+func (m *MemoryRoleManager) AssignRole(_ context.Context, subjectID string, role Role) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.roles[role]; !ok {
+		return fmt.Errorf("%w: %s", ErrRoleNotFound, role)
+	}
+	for _, r := range m.assignments[subjectID] {
+		if r == role {
+			return nil
+		}
+	}
+	m.assignments[subjectID] = append(m.assignments[subjectID], role)
+	return nil
+}
+
+// UnassignRole implements RoleManager
+func (m *MemoryRoleManager) UnassignRole(_ context.Context, subjectID string, role Role) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.unassign(subjectID, role) {
+		return fmt.Errorf("role %s not assigned to subject %s", role, subjectID)
+	}
+	return nil
+}
+
+func (m *MemoryRoleManager) unassign(subjectID string, role Role) bool {
+	roles := m.assignments[subjectID]
+	for i, r := range roles {
+		if r == role {
+			roles = append(roles[:i:i], roles[i+1:]...)
+			if len(roles) == 0 {
+				delete(m.assignments, subjectID)
+			} else {
+				m.assignments[subjectID] = roles
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// AssignedRoles implements RoleManager
+func (m *MemoryRoleManager) AssignedRoles(_ context.Context, subjectID string) ([]Role, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Role(nil), m.assignments[subjectID]...), nil
+}
+
+// Hierarchy implements RoleManager
+func (m *MemoryRoleManager) Hierarchy(_ context.Context) (*RoleHierarchy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.hierarchy, nil
+}
@@ -0,0 +1,233 @@
+package authz
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// pqForeignKeyViolation is the PostgreSQL error code of a missing
+// referenced row
+const pqForeignKeyViolation = "23503"
+
+// SQLRoleManager keeps roles and assignments in PostgreSQL. Role changes
+// lock the role table, so concurrent definitions cannot together form a
+// cycle that each alone would not.
+type SQLRoleManager struct {
+	db *sql.DB
+}
+
+// NewSQLRoleManager wraps an open database and creates the role tables if
+// needed
+func NewSQLRoleManager(ctx context.Context, db *sql.DB) (*SQLRoleManager, error) {
+	if _, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS gauth_roles (
+			name TEXT PRIMARY KEY,
+			permissions JSONB NOT NULL,
+			inherits JSONB NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS gauth_role_assignments (
+			subject_id TEXT NOT NULL,
+			role TEXT NOT NULL REFERENCES gauth_roles(name) ON DELETE CASCADE,
+			PRIMARY KEY (subject_id, role)
+		)`); err != nil {
+		return nil, fmt.Errorf("failed to create role tables: %w", err)
+	}
+	return &SQLRoleManager{db: db}, nil
+}
+
+// queryer is satisfied by *sql.DB and *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func loadRoles(ctx context.Context, q queryer) ([]*RoleDefinition, error) {
+	rows, err := q.QueryContext(ctx, "SELECT name, permissions, inherits FROM gauth_roles ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []*RoleDefinition
+	for rows.Next() {
+		var r RoleDefinition
+		var perms, inherits []byte
+		if err := rows.Scan(&r.Name, &perms, &inherits); err != nil {
+			return nil, fmt.Errorf("failed to load roles: %w", err)
+		}
+		if err := json.Unmarshal(perms, &r.Permissions); err != nil {
+			return nil, fmt.Errorf("failed to decode role %s: %w", r.Name, err)
+		}
+		if err := json.Unmarshal(inherits, &r.Inherits); err != nil {
+			return nil, fmt.Errorf("failed to decode role %s: %w", r.Name, err)
+		}
+		roles = append(roles, &r)
+	}
+	return roles, rows.Err()
+}
+
+// lockRoles starts a transaction holding the role table against other
+// writers and returns the roles it contains
+func (m *SQLRoleManager) lockRoles(ctx context.Context) (*sql.Tx, []*RoleDefinition, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin role update: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE gauth_roles IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		_ = tx.Rollback()
+		return nil, nil, fmt.Errorf("failed to lock roles: %w", err)
+	}
+	roles, err := loadRoles(ctx, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, nil, err
+	}
+	return tx, roles, nil
+}
+
+// DefineRole implements RoleManager
+func (m *SQLRoleManager) DefineRole(ctx context.Context, role *RoleDefinition) error {
+	if role == nil || role.Name == "" {
+		return errors.New("role name is required")
+	}
+	tx, roles, err := m.lockRoles(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	next := make([]*RoleDefinition, 0, len(roles)+1)
+	for _, r := range roles {
+		if r.Name != role.Name {
+			next = append(next, r)
+		}
+	}
+	if _, err := NewRoleHierarchy(append(next, role)); err != nil {
+		return err
+	}
+
+	perms, err := json.Marshal(nonNil(role.Permissions))
+	if err != nil {
+		return fmt.Errorf("failed to encode role: %w", err)
+	}
+	inherits, err := json.Marshal(nonNil(role.Inherits))
+	if err != nil {
+		return fmt.Errorf("failed to encode role: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO gauth_roles (name, permissions, inherits) VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET permissions = EXCLUDED.permissions, inherits = EXCLUDED.inherits`,
+		role.Name, perms, inherits); err != nil {
+		return fmt.Errorf("failed to store role: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit role: %w", err)
+	}
+	return nil
+}
+
+// nonNil keeps empty lists as JSON arrays rather than null
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+// RemoveRole implements RoleManager
+func (m *SQLRoleManager) RemoveRole(ctx context.Context, role Role) error {
+	tx, roles, err := m.lockRoles(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	found := false
+	for _, r := range roles {
+		if r.Name == role {
+			found = true
+			continue
+		}
+		for _, parent := range r.Inherits {
+			if parent == role {
+				return fmt.Errorf("%w: %s inherits %s", ErrRoleInUse, r.Name, role)
+			}
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", ErrRoleNotFound, role)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM gauth_roles WHERE name = $1", role); err != nil {
+		return fmt.Errorf("failed to remove role: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit role removal: %w", err)
+	}
+	return nil
+}
+
+// ListRoles implements RoleManager
+func (m *SQLRoleManager) ListRoles(ctx context.Context) ([]*RoleDefinition, error) {
+	return loadRoles(ctx, m.db)
+}
+
+// AssignRole implements RoleManager
+func (m *SQLRoleManager) AssignRole(ctx context.Context, subjectID string, role Role) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT INTO gauth_role_assignments (subject_id, role) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, subjectID, role)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pqForeignKeyViolation {
+		return fmt.Errorf("%w: %s", ErrRoleNotFound, role)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+	return nil
+}
+
+// UnassignRole implements RoleManager
+func (m *SQLRoleManager) UnassignRole(ctx context.Context, subjectID string, role Role) error {
+	res, err := m.db.ExecContext(ctx,
+		"DELETE FROM gauth_role_assignments WHERE subject_id = $1 AND role = $2", subjectID, role)
+	if err != nil {
+		return fmt.Errorf("failed to unassign role: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("role %s not assigned to subject %s", role, subjectID)
+	}
+	return nil
+}
+
+// AssignedRoles implements RoleManager
+func (m *SQLRoleManager) AssignedRoles(ctx context.Context, subjectID string) ([]Role, error) {
+	rows, err := m.db.QueryContext(ctx,
+		"SELECT role FROM gauth_role_assignments WHERE subject_id = $1 ORDER BY role", subjectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load role assignments: %w", err)
+	}
+	defer rows.Close()
+	var roles []Role
+	for rows.Next() {
+		var r Role
+		if err := rows.Scan(&r); err != nil {
+			return nil, fmt.Errorf("failed to load role assignments: %w", err)
+		}
+		roles = append(roles, r)
+	}
+	return roles, rows.Err()
+}
+
+// Hierarchy implements RoleManager. It reads the role table on every call;
+// callers evaluating many requests should hold on to the result.
+func (m *SQLRoleManager) Hierarchy(ctx context.Context) (*RoleHierarchy, error) {
+	roles, err := loadRoles(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+	return NewRoleHierarchy(roles)
+}
@@ -0,0 +1,100 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// SimulationResult compares the decision on one request under the current
+// and the proposed policies
+type SimulationResult struct {
+	Request  *AccessRequest  `json:"request"`
+	Current  *AccessResponse `json:"current"`
+	Proposed *AccessResponse `json:"proposed"`
+
+	// Changed reports whether the request would be allowed under one set
+	// and denied under the other
+	Changed bool `json:"changed"`
+}
+
+// Simulation is the outcome of evaluating requests against a proposed policy
+// set without putting it in force
+type Simulation struct {
+	CurrentVersion  int64              `json:"current_version"`
+	ProposedVersion int64              `json:"proposed_version"`
+	Results         []SimulationResult `json:"results"`
+
+	// Granted counts requests denied now that the proposed set allows
+	Granted int `json:"granted"`
+
+	// Revoked counts requests allowed now that the proposed set denies
+	Revoked int `json:"revoked"`
+}
+
+// Changes returns the results whose decision differs between the sets
+func (s *Simulation) Changes() []SimulationResult {
+	var out []SimulationResult
+	for _, r := range s.Results {
+		if r.Changed {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Simulate evaluates requests against a proposed policy set and the current
+// one and reports how each decision would change. Neither set is put in
+// force and the requests are not traced.
+func Simulate(ctx context.Context, current, proposed *PolicySet, requests []*AccessRequest) (*Simulation, error) {
+	if current == nil {
+		current = &PolicySet{}
+	}
+	if proposed == nil {
+		return nil, errors.New("proposed policy set is required")
+	}
+	seen := make(map[string]bool, len(proposed.Policies))
+	for _, p := range proposed.Policies {
+		if p == nil || p.ID == "" {
+			return nil, errors.New("policy ID is required")
+		}
+		if seen[p.ID] {
+			return nil, fmt.Errorf("duplicate policy %s", p.ID)
+		}
+		seen[p.ID] = true
+	}
+
+	before := newPolicyEngineState(current).authorizer
+	after := newPolicyEngineState(proposed).authorizer
+	sim := &Simulation{
+		CurrentVersion:  current.Version,
+		ProposedVersion: proposed.Version,
+		Results:         make([]SimulationResult, 0, len(requests)),
+	}
+	for i, req := range requests {
+		if req == nil {
+			return nil, fmt.Errorf("request %d is nil", i)
+		}
+		r := SimulationResult{
+			Request:  req,
+			Current:  before.isAllowed(ctx, req, nil),
+			Proposed: after.isAllowed(ctx, req, nil),
+		}
+		r.Changed = r.Current.Allowed != r.Proposed.Allowed
+		switch {
+		case r.Changed && r.Proposed.Allowed:
+			sim.Granted++
+		case r.Changed:
+			sim.Revoked++
+		}
+		sim.Results = append(sim.Results, r)
+	}
+	return sim, nil
+}
+
+// Simulate evaluates requests against a proposed set of policies, comparing
+// with the set in force, without changing the engine
+func (e *PolicyEngine) Simulate(ctx context.Context, policies []*Policy, requests []*AccessRequest) (*Simulation, error) {
+	current := e.PolicySet()
+	return Simulate(ctx, current, &PolicySet{Version: current.Version + 1, Policies: policies}, requests)
+}
@@ -0,0 +1,62 @@
+package authz
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	ctx := context.Background()
+	engine, err := NewPolicyEngine(ctx, PolicyEngineConfig{Store: NewMemoryPolicyStore()})
+	if err != nil {
+		t.Fatalf("NewPolicyEngine() error: %v", err)
+	}
+	readDocs := &Policy{
+		ID:        "read-docs",
+		Effect:    Allow,
+		Subjects:  []Subject{{ID: "alice"}, {ID: "bob"}},
+		Resources: []Resource{{ID: "doc1"}},
+		Actions:   []Action{{Name: "read"}},
+	}
+	if _, err := engine.Swap(ctx, []*Policy{readDocs}); err != nil {
+		t.Fatalf("Swap() error: %v", err)
+	}
+
+	// The proposal drops bob and adds writing for alice
+	proposed := []*Policy{
+		{ID: "read-docs", Effect: Allow, Subjects: []Subject{{ID: "alice"}}, Resources: readDocs.Resources, Actions: readDocs.Actions},
+		{ID: "write-docs", Effect: Allow, Subjects: []Subject{{ID: "alice"}}, Resources: readDocs.Resources, Actions: []Action{{Name: "write"}}},
+	}
+	requests := []*AccessRequest{
+		{Subject: Subject{ID: "alice"}, Action: Action{Name: "read"}, Resource: Resource{ID: "doc1"}},
+		{Subject: Subject{ID: "bob"}, Action: Action{Name: "read"}, Resource: Resource{ID: "doc1"}},
+		{Subject: Subject{ID: "alice"}, Action: Action{Name: "write"}, Resource: Resource{ID: "doc1"}},
+		{Subject: Subject{ID: "carol"}, Action: Action{Name: "read"}, Resource: Resource{ID: "doc1"}},
+	}
+	sim, err := engine.Simulate(ctx, proposed, requests)
+	if err != nil {
+		t.Fatalf("Simulate() error: %v", err)
+	}
+	if sim.CurrentVersion != 1 || sim.ProposedVersion != 2 {
+		t.Errorf("versions = %d, %d", sim.CurrentVersion, sim.ProposedVersion)
+	}
+	if sim.Granted != 1 || sim.Revoked != 1 {
+		t.Errorf("granted = %d, revoked = %d, want 1, 1", sim.Granted, sim.Revoked)
+	}
+	changes := sim.Changes()
+	if len(changes) != 2 || changes[0].Request.Subject.ID != "bob" || changes[1].Request.Action.Name != "write" {
+		t.Fatalf("Changes() = %+v", changes)
+	}
+	if changes[1].Proposed.PolicyID != "write-docs" {
+		t.Errorf("proposed policy = %s, want write-docs", changes[1].Proposed.PolicyID)
+	}
+
+	// Nothing was put in force
+	if engine.PolicySet().Version != 1 || len(engine.PolicySet().Policies) != 1 {
+		t.Errorf("engine changed by simulation: %+v", engine.PolicySet())
+	}
+
+	if _, err := Simulate(ctx, nil, &PolicySet{Policies: []*Policy{readDocs, readDocs}}, requests); err == nil {
+		t.Error("Simulate() with duplicate policies succeeded")
+	}
+}
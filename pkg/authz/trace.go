@@ -0,0 +1,265 @@
+package authz
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/scope"
+)
+
+// Decision tracing constants
+const (
+	// TraceHeader requests decision traces for a single HTTP request
+	TraceHeader = "X-GAuth-Trace"
+
+	// TraceResponseHeader carries the traces back as base64url-encoded JSON
+	TraceResponseHeader = "X-GAuth-Decision-Trace"
+
+	// ScopeDebugTrace is the admin scope a caller needs to request traces
+	ScopeDebugTrace = "gauth:admin:trace"
+)
+
+// ConditionTrace records the evaluation of one policy condition
+type ConditionTrace struct {
+	Name     string        `json:"name"`
+	Met      bool          `json:"met"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// PolicyTrace records the evaluation of one applicable policy
+type PolicyTrace struct {
+	ID         string           `json:"id"`
+	Effect     Effect           `json:"effect"`
+	Priority   int              `json:"priority"`
+	Conditions []ConditionTrace `json:"conditions,omitempty"`
+	// Outcome is "allow", "deny", "not_met", "error" or "timeout"
+	Outcome  string        `json:"outcome"`
+	Duration time.Duration `json:"duration"`
+}
+
+// AttributeTrace records one attribute source lookup
+type AttributeTrace struct {
+	Source     string            `json:"source"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Duration   time.Duration     `json:"duration"`
+}
+
+// DecisionTrace explains a single authorization decision
+type DecisionTrace struct {
+	Subject  string    `json:"subject"`
+	Action   string    `json:"action"`
+	Resource string    `json:"resource"`
+	Started  time.Time `json:"started"`
+
+	Attributes []AttributeTrace `json:"attributes,omitempty"`
+	Policies   []PolicyTrace    `json:"policies,omitempty"`
+	// Skipped counts policies whose subjects, resources or actions did not
+	// match the request
+	Skipped int                     `json:"skipped"`
+	Stages  map[Stage]time.Duration `json:"stages,omitempty"`
+
+	Allowed bool          `json:"allowed"`
+	Reason  string        `json:"reason"`
+	Policy  string        `json:"policy,omitempty"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// Trace collects the decision traces of one request. It is safe for
+// concurrent use.
+type Trace struct {
+	mu        sync.Mutex
+	decisions []*DecisionTrace
+}
+
+type traceKey struct{}
+
+// WithTrace returns a context that makes authorizers record a DecisionTrace
+// for every decision made with it
+func WithTrace(ctx context.Context) (context.Context, *Trace) {
+	t := &Trace{}
+	return context.WithValue(ctx, traceKey{}, t), t
+}
+
+// TraceFromContext returns the trace carried by ctx, or nil
+func TraceFromContext(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
+
+// Decisions returns the traces recorded so far, in decision order
+func (t *Trace) Decisions() []*DecisionTrace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*DecisionTrace(nil), t.decisions...)
+}
+
+// beginTrace starts tracing a decision; it returns nil when ctx is not traced
+func beginTrace(ctx context.Context, req *AccessRequest) *DecisionTrace {
+	t := TraceFromContext(ctx)
+	if t == nil {
+		return nil
+	}
+	dt := &DecisionTrace{
+		Subject:  req.Subject.ID,
+		Action:   req.Action.ID,
+		Resource: req.Resource.ID,
+		Started:  time.Now(),
+	}
+	if dt.Action == "" {
+		dt.Action = req.Action.Name
+	}
+	t.mu.Lock()
+	t.decisions = append(t.decisions, dt)
+	t.mu.Unlock()
+	return dt
+}
+
+// finish records the outcome; it is a no-op on a nil trace
+func (dt *DecisionTrace) finish(d *Decision) {
+	if dt == nil || d == nil {
+		return
+	}
+	dt.Allowed, dt.Reason, dt.Policy = d.Allowed, d.Reason, d.Policy
+	dt.Elapsed = time.Since(dt.Started)
+}
+
+// evaluateConditions evaluates a policy's conditions in name order until one
+// is not met or fails, which it names. Traces are collected when traced.
+func evaluateConditions(ctx context.Context, policy *Policy, req *AccessRequest, traced bool) (met bool, failed string, traces []ConditionTrace, err error) {
+	names := make([]string, 0, len(policy.Conditions))
+	for name := range policy.Conditions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		start := time.Now()
+		ok, err := policy.Conditions[name].Evaluate(ctx, req)
+		if traced {
+			ct := ConditionTrace{Name: name, Met: ok && err == nil, Duration: time.Since(start)}
+			if err != nil {
+				ct.Error = err.Error()
+			}
+			traces = append(traces, ct)
+		}
+		if err != nil {
+			return false, name, traces, err
+		}
+		if !ok {
+			return false, name, traces, nil
+		}
+	}
+	return true, "", traces, nil
+}
+
+func policyTrace(policy *Policy, met bool, conditions []ConditionTrace, err error, d time.Duration) PolicyTrace {
+	pt := PolicyTrace{ID: policy.ID, Effect: policy.Effect, Priority: policy.Priority, Conditions: conditions, Duration: d}
+	switch {
+	case err != nil:
+		pt.Outcome = "error"
+	case !met:
+		pt.Outcome = "not_met"
+	default:
+		pt.Outcome = string(policy.Effect)
+	}
+	return pt
+}
+
+// TraceConfig configures TraceMiddleware
+type TraceConfig struct {
+	// Scopes returns the scopes granted to the caller of r
+	Scopes func(r *http.Request) []string
+
+	// Scope is required to request traces (default: ScopeDebugTrace)
+	Scope string
+}
+
+// TraceMiddleware enables decision tracing for requests carrying a truthy
+// TraceHeader from callers holding the admin scope; the header is ignored
+// for everyone else. Traces of every decision made with the request context
+// are returned in TraceResponseHeader, so a single production request can be
+// diagnosed without raising the log level globally.
+func TraceMiddleware(config TraceConfig, next http.Handler) http.Handler {
+	if config.Scope == "" {
+		config.Scope = ScopeDebugTrace
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !traceRequested(r) || config.Scopes == nil || !scope.Allows(config.Scopes(r), config.Scope) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, trace := WithTrace(r.Context())
+		tw := &traceWriter{ResponseWriter: w, trace: trace}
+		next.ServeHTTP(tw, r.WithContext(ctx))
+		if !tw.wroteHeader {
+			tw.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+func traceRequested(r *http.Request) bool {
+	switch r.Header.Get(TraceHeader) {
+	case "1", "true", "on":
+		return true
+	}
+	return false
+}
+
+// EncodeTrace encodes decision traces for TraceResponseHeader
+func EncodeTrace(decisions []*DecisionTrace) (string, error) {
+	body, err := json.Marshal(decisions)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(body), nil
+}
+
+// DecodeTrace decodes a TraceResponseHeader value
+func DecodeTrace(header string) ([]*DecisionTrace, error) {
+	body, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return nil, err
+	}
+	var decisions []*DecisionTrace
+	if err := json.Unmarshal(body, &decisions); err != nil {
+		return nil, err
+	}
+	return decisions, nil
+}
+
+// traceWriter adds the trace header before the response is committed
+type traceWriter struct {
+	http.ResponseWriter
+	trace       *Trace
+	wroteHeader bool
+}
+
+func (w *traceWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if encoded, err := EncodeTrace(w.trace.Decisions()); err == nil {
+		w.Header().Set(TraceResponseHeader, encoded)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *traceWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *traceWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
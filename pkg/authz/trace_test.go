@@ -0,0 +1,125 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecisionTrace(t *testing.T) {
+	ctx := context.Background()
+	subject := Subject{ID: "agent-1"}
+	action := Action{Name: "read"}
+	resource := Resource{ID: "doc-1"}
+
+	inner := NewMemoryAuthorizer()
+	_ = inner.AddPolicy(ctx, &Policy{
+		ID:       "clearance",
+		Effect:   Allow,
+		Priority: 10,
+		Conditions: map[string]Condition{
+			"clearance": &AttributeCondition{Attribute: "clearance", Value: "high", Operator: "eq"},
+		},
+	})
+	_ = inner.AddPolicy(ctx, &Policy{ID: "other", Effect: Allow, Subjects: []Subject{{ID: "someone-else"}}})
+
+	t.Run("Untraced Context", func(t *testing.T) {
+		decision, err := inner.Authorize(ctx, subject, action, resource)
+		if err != nil {
+			t.Fatalf("Authorize() error: %v", err)
+		}
+		if decision.Trace != nil {
+			t.Error("Expected no trace without WithTrace")
+		}
+	})
+
+	t.Run("Memory Authorizer", func(t *testing.T) {
+		tctx, trace := WithTrace(ctx)
+		decision, err := inner.Authorize(tctx, subject, action, resource)
+		if err != nil {
+			t.Fatalf("Authorize() error: %v", err)
+		}
+		dt := decision.Trace
+		if dt == nil || len(trace.Decisions()) != 1 {
+			t.Fatal("Expected one recorded decision trace")
+		}
+		if dt.Skipped != 1 || len(dt.Policies) != 1 {
+			t.Fatalf("Expected one evaluated and one skipped policy, got %+v", dt)
+		}
+		p := dt.Policies[0]
+		if p.ID != "clearance" || p.Outcome != "not_met" || len(p.Conditions) != 1 || p.Conditions[0].Met {
+			t.Errorf("Expected unmet clearance condition, got %+v", p)
+		}
+		if dt.Allowed || dt.Reason != decision.Reason {
+			t.Errorf("Expected trace to record the denial, got %+v", dt)
+		}
+	})
+
+	t.Run("Budgeted Authorizer Records Attributes", func(t *testing.T) {
+		a, _ := NewBudgetedAuthorizer(BudgetConfig{Authorizer: inner, Sources: []AttributeSource{{
+			Name: "hr",
+			Lookup: func(context.Context, *AccessRequest) (map[string]string, error) {
+				return map[string]string{"clearance": "high"}, nil
+			},
+		}}})
+		tctx, _ := WithTrace(ctx)
+		decision, err := a.Authorize(tctx, subject, action, resource)
+		if err != nil || !decision.Allowed {
+			t.Fatalf("Expected access allowed, got %+v, %v", decision, err)
+		}
+		dt := decision.Trace
+		if dt == nil || len(dt.Attributes) != 1 || dt.Attributes[0].Attributes["clearance"] != "high" {
+			t.Fatalf("Expected attribute lookup in trace, got %+v", dt)
+		}
+		if len(dt.Stages) != 3 || dt.Policies[0].Outcome != "allow" {
+			t.Errorf("Expected stage timings and allowing policy, got %+v", dt)
+		}
+	})
+}
+
+func TestTraceMiddleware(t *testing.T) {
+	az := NewMemoryAuthorizer()
+	_ = az.AddPolicy(context.Background(), &Policy{ID: "deny-all", Effect: Deny})
+
+	handler := TraceMiddleware(TraceConfig{Scopes: func(r *http.Request) []string {
+		return strings.Fields(r.Header.Get("X-Test-Scopes"))
+	}}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision, _ := az.Authorize(r.Context(), Subject{ID: "u1"}, Action{Name: "read"}, Resource{ID: "r1"})
+		if !decision.Allowed {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	do := func(scopes string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/doc", nil)
+		req.Header.Set(TraceHeader, "1")
+		req.Header.Set("X-Test-Scopes", scopes)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Admin Scope Gets Trace", func(t *testing.T) {
+		rec := do("read " + ScopeDebugTrace)
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("Expected 403, got %d", rec.Code)
+		}
+		decisions, err := DecodeTrace(rec.Header().Get(TraceResponseHeader))
+		if err != nil || len(decisions) != 1 {
+			t.Fatalf("Expected one decision trace, got %v, %v", decisions, err)
+		}
+		if decisions[0].Policy != "deny-all" || decisions[0].Reason != "policy denies access" {
+			body, _ := json.Marshal(decisions[0])
+			t.Errorf("Unexpected trace %s", body)
+		}
+	})
+
+	t.Run("Header Ignored Without Scope", func(t *testing.T) {
+		rec := do("read")
+		if rec.Header().Get(TraceResponseHeader) != "" {
+			t.Error("Expected no trace for callers without the admin scope")
+		}
+	})
+}
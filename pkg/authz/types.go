@@ -62,6 +62,10 @@ type Policy struct {
 
 	// Status of the policy
 	Status string `json:"status"`
+
+	// Class groups policies for partial-result handling when the decision
+	// budget runs out
+	Class string `json:"class,omitempty"`
 }
 
 // AccessRequest represents a request to perform an action on a resource
@@ -80,6 +84,7 @@ type AccessResponse struct {
 	Reason      string            `json:"reason"`
 	PolicyID    string            `json:"policy_id,omitempty"`
 	Annotations map[string]string `json:"annotations,omitempty"`
+	Obligations []Obligation      `json:"obligations,omitempty"`
 }
 
 // Effect represents the policy effect (RFC111: allow/deny decision)
@@ -100,10 +105,14 @@ type Condition interface {
 
 // Decision represents an authorization decision (RFC111: PDP output, includes reason, policy, timestamp)
 type Decision struct {
-	Allowed   bool      `json:"allowed"`
-	Reason    string    `json:"reason"`
-	Policy    string    `json:"policy"`
-	Timestamp time.Time `json:"timestamp"`
+	Allowed     bool         `json:"allowed"`
+	Reason      string       `json:"reason"`
+	Policy      string       `json:"policy"`
+	Timestamp   time.Time    `json:"timestamp"`
+	Obligations []Obligation `json:"obligations,omitempty"`
+
+	// Trace explains the decision when the context requested tracing
+	Trace *DecisionTrace `json:"trace,omitempty"`
 }
 
 // Authorizer evaluates authorization requests (RFC111: PDP interface, central authority for all decisions)
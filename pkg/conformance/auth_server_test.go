@@ -0,0 +1,89 @@
+package conformance
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/auth"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// startAuthorizationServer serves the pkg/auth AuthorizationServer as an
+// OpenID Connect provider with one client_credentials client holding a
+// power of attorney for "read"
+func startAuthorizationServer(t *testing.T) *Target {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	keys := token.NewKeySet()
+	if _, err := keys.Add(key, token.RS256, "idp-1"); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+	tokens := token.NewService(token.Config{SigningKey: key, ValidityPeriod: time.Hour}, token.NewMemoryStore(time.Hour))
+
+	clients := auth.NewMemoryClientRegistry()
+	secret, err := clients.Register(auth.OAuthClient{
+		ID:         "agent",
+		GrantTypes: []string{auth.GrantTypeClientCreds},
+		Scopes:     []string{"read"},
+		PowerOfAttorney: &auth.ClientPowerOfAttorney{
+			Principal:  "acme-corp",
+			Scopes:     []string{"read"},
+			GrantedAt:  time.Now(),
+			ValidUntil: time.Now().Add(24 * time.Hour),
+		},
+	}, true)
+	if err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	server, err := auth.NewAuthorizationServer(auth.AuthorizationServerConfig{
+		Tokens:  tokens,
+		Clients: clients,
+		Consent: func(http.ResponseWriter, *http.Request, *auth.ServiceAuthorizationRequest, *auth.OAuthClient) (*auth.ConsentDecision, error) {
+			return nil, nil
+		},
+		Issuer: srv.URL,
+		OIDC:   &auth.OIDCConfig{Keys: keys},
+	})
+	if err != nil {
+		t.Fatalf("NewAuthorizationServer() error: %v", err)
+	}
+	mux.Handle("/", server.Handler())
+
+	return &Target{
+		TokenURL:      srv.URL + "/token",
+		RevocationURL: srv.URL + "/revoke",
+		DiscoveryURL:  srv.URL + "/.well-known/openid-configuration",
+		ClientID:      "agent",
+		ClientSecret:  secret,
+		Scope:         "read",
+	}
+}
+
+func TestAuthorizationServer(t *testing.T) {
+	m := DefaultSuite().Run(context.Background(), startAuthorizationServer(t))
+	for _, r := range m.Results {
+		switch {
+		case r.Outcome == OutcomeFail:
+			t.Errorf("%s: %s", r.ID, r.Message)
+		case r.Outcome == OutcomeSkip && !strings.Contains(r.Message, "introspection"):
+			// the server has no introspection endpoint; every other case applies
+			t.Errorf("%s skipped: %s", r.ID, r.Message)
+		}
+	}
+	if got := m.BySpec()["RFC 6749"].Passed; got != 6 {
+		t.Errorf("Expected 6 RFC 6749 passes, got %d", got)
+	}
+}
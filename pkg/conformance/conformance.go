@@ -0,0 +1,238 @@
+// Package conformance checks a running GAuth deployment against OAuth2 and
+// OpenID Connect test vectors and the GAuth-specific RFC111/RFC115 cases.
+// Each case names the specification section it exercises; a run produces a
+// Matrix that can be written as JSON for CI dashboards.
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Outcome is the result of one conformance case
+type Outcome string
+
+const (
+	// OutcomePass means the target behaved as specified
+	OutcomePass Outcome = "pass"
+
+	// OutcomeFail means the target violated the specification
+	OutcomeFail Outcome = "fail"
+
+	// OutcomeSkip means the case could not run against this target, e.g.
+	// because the endpoint it needs was not configured
+	OutcomeSkip Outcome = "skip"
+)
+
+// ErrSkipped is returned by a case that does not apply to the target
+var ErrSkipped = errors.New("case skipped")
+
+// skipf returns an ErrSkipped with a reason
+func skipf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrSkipped, fmt.Sprintf(format, args...))
+}
+
+// Target describes the deployment under test. Endpoints left empty cause
+// the cases that need them to be skipped.
+type Target struct {
+	// TokenURL is the OAuth2 token endpoint (RFC 6749 §3.2)
+	TokenURL string
+
+	// IntrospectionURL is the RFC 7662 introspection endpoint
+	IntrospectionURL string
+
+	// RevocationURL is the RFC 7009 revocation endpoint
+	RevocationURL string
+
+	// DiscoveryURL is the OpenID Connect discovery document
+	DiscoveryURL string
+
+	// ClientID and ClientSecret are valid client credentials
+	ClientID     string
+	ClientSecret string
+
+	// Scope is a scope the client is granted
+	Scope string
+
+	// ExcessScope is a scope the client's power of attorney does not cover
+	// (default: "gauth:unrestricted")
+	ExcessScope string
+
+	// HTTPClient sends the requests (default: client with 10s timeout)
+	HTTPClient *http.Client
+}
+
+func (t *Target) client() *http.Client {
+	if t.HTTPClient != nil {
+		return t.HTTPClient
+	}
+	return &http.Client{Timeout: 10 * time.Second}
+}
+
+// Case is a single conformance check
+type Case struct {
+	// ID uniquely identifies the case, e.g. "rfc6749-5.2-invalid-client"
+	ID string
+
+	// Spec is the specification and section the case exercises
+	Spec string
+
+	// Description says what is being checked
+	Description string
+
+	// Run returns nil on pass, an ErrSkipped-wrapped error to skip, and any
+	// other error on failure
+	Run func(ctx context.Context, target *Target) error
+}
+
+// Result is the outcome of one case
+type Result struct {
+	ID          string        `json:"id"`
+	Spec        string        `json:"spec"`
+	Description string        `json:"description"`
+	Outcome     Outcome       `json:"outcome"`
+	Message     string        `json:"message,omitempty"`
+	Duration    time.Duration `json:"duration"`
+}
+
+// Summary counts results by outcome
+type Summary struct {
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Skipped int `json:"skipped"`
+}
+
+// Matrix is the machine-readable outcome of a conformance run
+type Matrix struct {
+	StartedAt time.Time `json:"started_at"`
+	Target    string    `json:"target"`
+	Results   []Result  `json:"results"`
+	Summary   Summary   `json:"summary"`
+}
+
+// Conformant reports whether no case failed
+func (m *Matrix) Conformant() bool {
+	return m.Summary.Failed == 0
+}
+
+// BySpec groups the results by specification, e.g. "RFC 6749"
+func (m *Matrix) BySpec() map[string]Summary {
+	out := make(map[string]Summary)
+	for _, r := range m.Results {
+		spec := r.Spec
+		if i := strings.Index(spec, " §"); i >= 0 {
+			spec = spec[:i]
+		}
+		s := out[spec]
+		switch r.Outcome {
+		case OutcomePass:
+			s.Passed++
+		case OutcomeFail:
+			s.Failed++
+		case OutcomeSkip:
+			s.Skipped++
+		}
+		out[spec] = s
+	}
+	return out
+}
+
+// WriteJSON writes the matrix as indented JSON
+func (m *Matrix) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// WriteText writes one line per case followed by a summary
+func (m *Matrix) WriteText(w io.Writer) error {
+	for _, r := range m.Results {
+		line := fmt.Sprintf("[%s] %-40s %s", strings.ToUpper(string(r.Outcome)), r.ID, r.Spec)
+		if r.Message != "" {
+			line += ": " + r.Message
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d passed, %d failed, %d skipped\n",
+		m.Summary.Passed, m.Summary.Failed, m.Summary.Skipped)
+	return err
+}
+
+// Suite is an ordered set of conformance cases
+type Suite struct {
+	cases []Case
+}
+
+// NewSuite creates a suite of the given cases
+func NewSuite(cases ...Case) *Suite {
+	return &Suite{cases: cases}
+}
+
+// DefaultSuite returns a suite of every built-in case
+func DefaultSuite() *Suite {
+	var cases []Case
+	cases = append(cases, OAuth2Cases()...)
+	cases = append(cases, OIDCCases()...)
+	cases = append(cases, GAuthCases()...)
+	return NewSuite(cases...)
+}
+
+// Add appends cases to the suite
+func (s *Suite) Add(cases ...Case) {
+	s.cases = append(s.cases, cases...)
+}
+
+// Cases returns the suite's cases
+func (s *Suite) Cases() []Case {
+	return append([]Case(nil), s.cases...)
+}
+
+// Filter returns a suite of the cases whose ID or Spec contains any of the
+// given substrings
+func (s *Suite) Filter(match ...string) *Suite {
+	out := &Suite{}
+	for _, c := range s.cases {
+		for _, m := range match {
+			if strings.Contains(c.ID, m) || strings.Contains(c.Spec, m) {
+				out.cases = append(out.cases, c)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Run executes every case in order against the target
+func (s *Suite) Run(ctx context.Context, target *Target) *Matrix {
+	m := &Matrix{StartedAt: time.Now(), Target: target.TokenURL}
+	for _, c := range s.cases {
+		start := time.Now()
+		err := c.Run(ctx, target)
+		r := Result{ID: c.ID, Spec: c.Spec, Description: c.Description, Duration: time.Since(start)}
+		switch {
+		case err == nil:
+			r.Outcome = OutcomePass
+			m.Summary.Passed++
+		case errors.Is(err, ErrSkipped):
+			r.Outcome = OutcomeSkip
+			r.Message = strings.TrimPrefix(err.Error(), ErrSkipped.Error()+": ")
+			m.Summary.Skipped++
+		default:
+			r.Outcome = OutcomeFail
+			r.Message = err.Error()
+			m.Summary.Failed++
+		}
+		m.Results = append(m.Results, r)
+	}
+	sort.SliceStable(m.Results, func(i, j int) bool { return m.Results[i].Spec < m.Results[j].Spec })
+	return m
+}
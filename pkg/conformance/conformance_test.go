@@ -0,0 +1,182 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// referenceServer is a minimal conformant authorization server
+type referenceServer struct {
+	mu      sync.Mutex
+	issued  map[string]bool
+	next    int
+	noStore bool
+}
+
+func (s *referenceServer) handler(base *string) http.Handler {
+	mux := http.NewServeMux()
+	authenticate := func(w http.ResponseWriter, r *http.Request) bool {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "client" || secret != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="gauth"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+			return false
+		}
+		return true
+	}
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if !authenticate(w, r) {
+			return
+		}
+		switch r.PostFormValue("grant_type") {
+		case "":
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+			return
+		case "client_credentials":
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+			return
+		}
+		scope := r.PostFormValue("scope")
+		if scope != "" && scope != "read" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_scope"})
+			return
+		}
+		s.mu.Lock()
+		s.next++
+		tok := "tok-" + strings.Repeat("x", s.next)
+		s.issued[tok] = true
+		s.mu.Unlock()
+		if !s.noStore {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"access_token": tok, "token_type": "Bearer", "expires_in": 3600, "scope": scope,
+		})
+	})
+	mux.HandleFunc("/introspect", func(w http.ResponseWriter, r *http.Request) {
+		if !authenticate(w, r) {
+			return
+		}
+		s.mu.Lock()
+		active := s.issued[r.PostFormValue("token")]
+		s.mu.Unlock()
+		if !active {
+			writeJSON(w, http.StatusOK, map[string]bool{"active": false})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"active": true, "client_id": "client", "sub": "principal"})
+	})
+	mux.HandleFunc("/revoke", func(w http.ResponseWriter, r *http.Request) {
+		if !authenticate(w, r) {
+			return
+		}
+		s.mu.Lock()
+		delete(s.issued, r.PostFormValue("token"))
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                *base,
+			"authorization_endpoint":                *base + "/authorize",
+			"jwks_uri":                              *base + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+			"grant_types_supported":                 []string{"client_credentials"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"keys": []map[string]string{{"kty": "RSA", "kid": "k1", "n": "AQAB", "e": "AQAB"}},
+		})
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func startServer(t *testing.T, ref *referenceServer) *Target {
+	t.Helper()
+	var base string
+	srv := httptest.NewServer(ref.handler(&base))
+	t.Cleanup(srv.Close)
+	base = srv.URL
+	return &Target{
+		TokenURL:         base + "/token",
+		IntrospectionURL: base + "/introspect",
+		RevocationURL:    base + "/revoke",
+		DiscoveryURL:     base + "/.well-known/openid-configuration",
+		ClientID:         "client",
+		ClientSecret:     "secret",
+		Scope:            "read",
+	}
+}
+
+func TestSuite(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Conformant Server", func(t *testing.T) {
+		target := startServer(t, &referenceServer{issued: make(map[string]bool)})
+		m := DefaultSuite().Run(ctx, target)
+		for _, r := range m.Results {
+			if r.Outcome != OutcomePass {
+				t.Errorf("%s: %s %s", r.ID, r.Outcome, r.Message)
+			}
+		}
+		if !m.Conformant() {
+			t.Error("Expected conformant matrix")
+		}
+		if got := m.BySpec()["RFC 6749"].Passed; got != 6 {
+			t.Errorf("Expected 6 RFC 6749 passes, got %d", got)
+		}
+	})
+
+	t.Run("Violation Reported", func(t *testing.T) {
+		target := startServer(t, &referenceServer{issued: make(map[string]bool), noStore: true})
+		m := DefaultSuite().Filter("rfc6749-5.1").Run(ctx, target)
+		if len(m.Results) != 1 || m.Results[0].Outcome != OutcomeFail {
+			t.Fatalf("Expected one failure, got %+v", m.Results)
+		}
+		if !strings.Contains(m.Results[0].Message, "no-store") {
+			t.Errorf("Expected Cache-Control failure, got %q", m.Results[0].Message)
+		}
+	})
+
+	t.Run("Missing Endpoints Skipped", func(t *testing.T) {
+		m := DefaultSuite().Run(ctx, &Target{})
+		if m.Summary.Failed != 0 || m.Summary.Passed != 1 {
+			t.Errorf("Expected only the offline vector to run, got %+v", m.Summary)
+		}
+	})
+
+	t.Run("JSON Matrix", func(t *testing.T) {
+		m := NewSuite(OAuth2Cases()...).Filter("rfc7636").Run(ctx, &Target{})
+		var buf bytes.Buffer
+		if err := m.WriteJSON(&buf); err != nil {
+			t.Fatalf("WriteJSON() error: %v", err)
+		}
+		var decoded Matrix
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+			t.Fatalf("Unmarshal() error: %v", err)
+		}
+		if decoded.Summary.Passed != 1 || decoded.Results[0].Spec != "RFC 7636 §4.2" {
+			t.Errorf("Unexpected matrix %+v", decoded)
+		}
+	})
+}
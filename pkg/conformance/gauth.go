@@ -0,0 +1,234 @@
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Gimel-Foundation/gauth/pkg/doctor"
+)
+
+// discovery fetches and decodes the OpenID Connect discovery document
+func discovery(ctx context.Context, target *Target) (map[string]interface{}, error) {
+	if target.DiscoveryURL == "" {
+		return nil, skipf("no discovery document configured")
+	}
+	resp, err := send(ctx, target, http.MethodGet, target.DiscoveryURL, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	if resp.status != http.StatusOK {
+		return nil, fmt.Errorf("discovery failed with status %d", resp.status)
+	}
+	var doc map[string]interface{}
+	if err := resp.decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// OIDCCases returns the OpenID Connect discovery and JWKS cases
+func OIDCCases() []Case {
+	return []Case{
+		{
+			ID:          "oidc-discovery-3-required",
+			Spec:        "OIDC Discovery §3",
+			Description: "the discovery document contains every required member",
+			Run: func(ctx context.Context, target *Target) error {
+				doc, err := discovery(ctx, target)
+				if err != nil {
+					return err
+				}
+				var missing []string
+				for _, field := range []string{
+					"issuer", "authorization_endpoint", "jwks_uri", "response_types_supported",
+					"subject_types_supported", "id_token_signing_alg_values_supported",
+				} {
+					if _, ok := doc[field]; !ok {
+						missing = append(missing, field)
+					}
+				}
+				if len(missing) > 0 {
+					return fmt.Errorf("missing %s", strings.Join(missing, ", "))
+				}
+				return nil
+			},
+		},
+		{
+			ID:          "oidc-discovery-4.3-issuer",
+			Spec:        "OIDC Discovery §4.3",
+			Description: "the issuer is the URL the document was retrieved from",
+			Run: func(ctx context.Context, target *Target) error {
+				doc, err := discovery(ctx, target)
+				if err != nil {
+					return err
+				}
+				issuer, _ := doc["issuer"].(string)
+				want := strings.TrimSuffix(target.DiscoveryURL, "/.well-known/openid-configuration")
+				if strings.TrimSuffix(issuer, "/") != strings.TrimSuffix(want, "/") {
+					return fmt.Errorf("issuer %q does not match %q", issuer, want)
+				}
+				return nil
+			},
+		},
+		{
+			ID:          "rfc7517-5-jwks",
+			Spec:        "RFC 7517 §5",
+			Description: "jwks_uri serves a key set with identified signing keys",
+			Run: func(ctx context.Context, target *Target) error {
+				doc, err := discovery(ctx, target)
+				if err != nil {
+					return err
+				}
+				jwksURI, _ := doc["jwks_uri"].(string)
+				if jwksURI == "" {
+					return fmt.Errorf("discovery document has no jwks_uri")
+				}
+				resp, err := send(ctx, target, http.MethodGet, jwksURI, nil, false)
+				if err != nil {
+					return err
+				}
+				if resp.status != http.StatusOK {
+					return fmt.Errorf("jwks_uri returned status %d", resp.status)
+				}
+				var set struct {
+					Keys []map[string]interface{} `json:"keys"`
+				}
+				if err := resp.decode(&set); err != nil {
+					return err
+				}
+				if len(set.Keys) == 0 {
+					return fmt.Errorf("key set is empty")
+				}
+				for i, k := range set.Keys {
+					if k["kty"] == nil {
+						return fmt.Errorf("key %d lacks kty", i)
+					}
+					if _, private := k["d"]; private {
+						return fmt.Errorf("key %d exposes private material", i)
+					}
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// GAuthCases returns the RFC111 power-of-attorney and RFC115 delegation cases
+func GAuthCases() []Case {
+	return []Case{
+		{
+			ID:          "rfc111-scope-beyond-grant",
+			Spec:        "GAuth RFC111",
+			Description: "a scope the power of attorney does not cover is refused with invalid_scope",
+			Run: func(ctx context.Context, target *Target) error {
+				if err := requireTokenEndpoint(target); err != nil {
+					return err
+				}
+				excess := target.ExcessScope
+				if excess == "" {
+					excess = "gauth:unrestricted"
+				}
+				resp, err := send(ctx, target, http.MethodPost, target.TokenURL, url.Values{
+					"grant_type": {"client_credentials"},
+					"scope":      {excess},
+				}, true)
+				if err != nil {
+					return err
+				}
+				return resp.expectError(http.StatusBadRequest, "invalid_scope")
+			},
+		},
+		{
+			ID:          "rfc111-issued-scope-subset",
+			Spec:        "GAuth RFC111",
+			Description: "issued scopes never exceed the requested scopes",
+			Run: func(ctx context.Context, target *Target) error {
+				if err := requireTokenEndpoint(target); err != nil {
+					return err
+				}
+				if target.Scope == "" {
+					return skipf("no granted scope configured")
+				}
+				tr, _, err := issue(ctx, target, target.Scope)
+				if err != nil {
+					return err
+				}
+				requested := strings.Fields(target.Scope)
+				for _, s := range strings.Fields(tr.Scope) {
+					if !contains(requested, s) {
+						return fmt.Errorf("issued scope %q was not requested", s)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID:          "rfc111-exclusions",
+			Spec:        "GAuth RFC111",
+			Description: "advertised grants and scopes use no mechanism RFC111 excludes",
+			Run: func(ctx context.Context, target *Target) error {
+				doc, err := discovery(ctx, target)
+				if err != nil {
+					return err
+				}
+				for _, field := range []string{"grant_types_supported", "scopes_supported"} {
+					values, _ := doc[field].([]interface{})
+					for _, v := range values {
+						s, _ := v.(string)
+						for _, term := range doctor.ExcludedTerms {
+							if strings.Contains(strings.ToLower(s), term) {
+								return fmt.Errorf("%s advertises %q", field, s)
+							}
+						}
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID:          "rfc115-accountable-introspection",
+			Spec:        "GAuth RFC115",
+			Description: "introspection of a delegated token identifies the acting client and the principal",
+			Run: func(ctx context.Context, target *Target) error {
+				if err := requireTokenEndpoint(target); err != nil {
+					return err
+				}
+				if target.IntrospectionURL == "" {
+					return skipf("no introspection endpoint configured")
+				}
+				tr, _, err := issue(ctx, target, target.Scope)
+				if err != nil {
+					return err
+				}
+				resp, err := send(ctx, target, http.MethodPost, target.IntrospectionURL,
+					url.Values{"token": {tr.AccessToken}}, true)
+				if err != nil {
+					return err
+				}
+				var ir map[string]json.RawMessage
+				if err := resp.decode(&ir); err != nil {
+					return err
+				}
+				for _, field := range []string{"client_id", "sub"} {
+					if len(ir[field]) == 0 || string(ir[field]) == `""` {
+						return fmt.Errorf("introspection response lacks %s", field)
+					}
+				}
+				return nil
+			},
+		},
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
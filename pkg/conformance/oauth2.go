@@ -0,0 +1,378 @@
+package conformance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// tokenResponse is the RFC 6749 §5.1 successful response
+type tokenResponse struct {
+	AccessToken string      `json:"access_token"`
+	TokenType   string      `json:"token_type"`
+	ExpiresIn   json.Number `json:"expires_in"`
+	Scope       string      `json:"scope"`
+}
+
+// errorResponse is the RFC 6749 §5.2 error response
+type errorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// response is a received HTTP response with its body read
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (r *response) decode(v interface{}) error {
+	if err := json.Unmarshal(r.body, v); err != nil {
+		return fmt.Errorf("response is not JSON (status %d): %v", r.status, err)
+	}
+	return nil
+}
+
+// expectError checks an RFC 6749 §5.2 error response
+func (r *response) expectError(status int, code string) error {
+	if r.status != status {
+		return fmt.Errorf("expected status %d, got %d", status, r.status)
+	}
+	var e errorResponse
+	if err := r.decode(&e); err != nil {
+		return err
+	}
+	if e.Error != code {
+		return fmt.Errorf("expected error %q, got %q", code, e.Error)
+	}
+	return nil
+}
+
+func send(ctx context.Context, target *Target, method, endpoint string, form url.Values, basicAuth bool) (*response, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.Header.Set("Accept", "application/json")
+	if basicAuth {
+		req.SetBasicAuth(url.QueryEscape(target.ClientID), url.QueryEscape(target.ClientSecret))
+	}
+	resp, err := target.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return &response{status: resp.StatusCode, header: resp.Header, body: data}, nil
+}
+
+func requireTokenEndpoint(target *Target) error {
+	if target.TokenURL == "" {
+		return skipf("no token endpoint configured")
+	}
+	if target.ClientID == "" {
+		return skipf("no client credentials configured")
+	}
+	return nil
+}
+
+// issue obtains an access token with the client credentials grant
+func issue(ctx context.Context, target *Target, scope string) (*tokenResponse, *response, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+	resp, err := send(ctx, target, http.MethodPost, target.TokenURL, form, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.status != http.StatusOK {
+		return nil, resp, fmt.Errorf("token request failed with status %d: %s", resp.status, resp.body)
+	}
+	var tr tokenResponse
+	if err := resp.decode(&tr); err != nil {
+		return nil, resp, err
+	}
+	return &tr, resp, nil
+}
+
+// introspect returns the RFC 7662 "active" member for the token
+func introspect(ctx context.Context, target *Target, tok string) (bool, error) {
+	resp, err := send(ctx, target, http.MethodPost, target.IntrospectionURL, url.Values{"token": {tok}}, true)
+	if err != nil {
+		return false, err
+	}
+	if resp.status != http.StatusOK {
+		return false, fmt.Errorf("introspection failed with status %d", resp.status)
+	}
+	var ir struct {
+		Active *bool `json:"active"`
+	}
+	if err := resp.decode(&ir); err != nil {
+		return false, err
+	}
+	if ir.Active == nil {
+		return false, fmt.Errorf("introspection response lacks the required active member")
+	}
+	return *ir.Active, nil
+}
+
+// OAuth2Cases returns the RFC 6749, RFC 7009, RFC 7662 and RFC 7636 cases
+func OAuth2Cases() []Case {
+	return []Case{
+		{
+			ID:          "rfc6749-4.4-client-credentials",
+			Spec:        "RFC 6749 §4.4",
+			Description: "client credentials grant returns a bearer access token",
+			Run: func(ctx context.Context, target *Target) error {
+				if err := requireTokenEndpoint(target); err != nil {
+					return err
+				}
+				tr, _, err := issue(ctx, target, target.Scope)
+				if err != nil {
+					return err
+				}
+				if tr.AccessToken == "" {
+					return fmt.Errorf("response lacks access_token")
+				}
+				if !strings.EqualFold(tr.TokenType, "bearer") {
+					return fmt.Errorf("expected token_type bearer, got %q", tr.TokenType)
+				}
+				return nil
+			},
+		},
+		{
+			ID:          "rfc6749-5.1-response-fields",
+			Spec:        "RFC 6749 §5.1",
+			Description: "token responses are not cacheable and expires_in is numeric",
+			Run: func(ctx context.Context, target *Target) error {
+				if err := requireTokenEndpoint(target); err != nil {
+					return err
+				}
+				tr, resp, err := issue(ctx, target, target.Scope)
+				if err != nil {
+					return err
+				}
+				if !strings.Contains(strings.ToLower(resp.header.Get("Cache-Control")), "no-store") {
+					return fmt.Errorf("expected Cache-Control: no-store, got %q", resp.header.Get("Cache-Control"))
+				}
+				if !strings.HasPrefix(resp.header.Get("Content-Type"), "application/json") {
+					return fmt.Errorf("expected application/json, got %q", resp.header.Get("Content-Type"))
+				}
+				if tr.ExpiresIn != "" {
+					if _, err := tr.ExpiresIn.Int64(); err != nil {
+						return fmt.Errorf("expires_in is not an integer: %q", tr.ExpiresIn)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID:          "rfc6749-5.2-unsupported-grant-type",
+			Spec:        "RFC 6749 §5.2",
+			Description: "unknown grant types are rejected with unsupported_grant_type",
+			Run: func(ctx context.Context, target *Target) error {
+				if err := requireTokenEndpoint(target); err != nil {
+					return err
+				}
+				resp, err := send(ctx, target, http.MethodPost, target.TokenURL,
+					url.Values{"grant_type": {"urn:gauth:conformance:unknown"}}, true)
+				if err != nil {
+					return err
+				}
+				return resp.expectError(http.StatusBadRequest, "unsupported_grant_type")
+			},
+		},
+		{
+			ID:          "rfc6749-5.2-invalid-request",
+			Spec:        "RFC 6749 §5.2",
+			Description: "requests without grant_type are rejected with invalid_request",
+			Run: func(ctx context.Context, target *Target) error {
+				if err := requireTokenEndpoint(target); err != nil {
+					return err
+				}
+				resp, err := send(ctx, target, http.MethodPost, target.TokenURL, url.Values{}, true)
+				if err != nil {
+					return err
+				}
+				return resp.expectError(http.StatusBadRequest, "invalid_request")
+			},
+		},
+		{
+			ID:          "rfc6749-5.2-invalid-client",
+			Spec:        "RFC 6749 §5.2",
+			Description: "wrong client secrets are rejected with 401 invalid_client",
+			Run: func(ctx context.Context, target *Target) error {
+				if err := requireTokenEndpoint(target); err != nil {
+					return err
+				}
+				wrong := *target
+				wrong.ClientSecret = target.ClientSecret + "-conformance-wrong"
+				resp, err := send(ctx, &wrong, http.MethodPost, target.TokenURL,
+					url.Values{"grant_type": {"client_credentials"}}, true)
+				if err != nil {
+					return err
+				}
+				if err := resp.expectError(http.StatusUnauthorized, "invalid_client"); err != nil {
+					return err
+				}
+				if resp.header.Get("WWW-Authenticate") == "" {
+					return fmt.Errorf("401 response lacks WWW-Authenticate")
+				}
+				return nil
+			},
+		},
+		{
+			ID:          "rfc6749-3.2-post-only",
+			Spec:        "RFC 6749 §3.2",
+			Description: "the token endpoint does not issue tokens over GET",
+			Run: func(ctx context.Context, target *Target) error {
+				if err := requireTokenEndpoint(target); err != nil {
+					return err
+				}
+				u := target.TokenURL + "?" + url.Values{"grant_type": {"client_credentials"}}.Encode()
+				resp, err := send(ctx, target, http.MethodGet, u, nil, true)
+				if err != nil {
+					return err
+				}
+				if resp.status == http.StatusOK {
+					return fmt.Errorf("GET request was accepted")
+				}
+				return nil
+			},
+		},
+		{
+			ID:          "rfc7662-2.2-active-token",
+			Spec:        "RFC 7662 §2.2",
+			Description: "introspection reports an issued token as active",
+			Run: func(ctx context.Context, target *Target) error {
+				if err := requireTokenEndpoint(target); err != nil {
+					return err
+				}
+				if target.IntrospectionURL == "" {
+					return skipf("no introspection endpoint configured")
+				}
+				tr, _, err := issue(ctx, target, target.Scope)
+				if err != nil {
+					return err
+				}
+				active, err := introspect(ctx, target, tr.AccessToken)
+				if err != nil {
+					return err
+				}
+				if !active {
+					return fmt.Errorf("freshly issued token reported inactive")
+				}
+				return nil
+			},
+		},
+		{
+			ID:          "rfc7662-2.2-unknown-token",
+			Spec:        "RFC 7662 §2.2",
+			Description: "introspection reports an unknown token as inactive, not as an error",
+			Run: func(ctx context.Context, target *Target) error {
+				if target.IntrospectionURL == "" {
+					return skipf("no introspection endpoint configured")
+				}
+				active, err := introspect(ctx, target, "conformance-unknown-token")
+				if err != nil {
+					return err
+				}
+				if active {
+					return fmt.Errorf("unknown token reported active")
+				}
+				return nil
+			},
+		},
+		{
+			ID:          "rfc7009-2.2-unknown-token",
+			Spec:        "RFC 7009 §2.2",
+			Description: "revoking an unknown token succeeds with 200",
+			Run: func(ctx context.Context, target *Target) error {
+				if target.RevocationURL == "" {
+					return skipf("no revocation endpoint configured")
+				}
+				resp, err := send(ctx, target, http.MethodPost, target.RevocationURL,
+					url.Values{"token": {"conformance-unknown-token"}}, true)
+				if err != nil {
+					return err
+				}
+				if resp.status != http.StatusOK {
+					return fmt.Errorf("expected status 200, got %d", resp.status)
+				}
+				return nil
+			},
+		},
+		{
+			ID:          "rfc7009-2-revoked-inactive",
+			Spec:        "RFC 7009 §2",
+			Description: "a revoked token is no longer active",
+			Run: func(ctx context.Context, target *Target) error {
+				if err := requireTokenEndpoint(target); err != nil {
+					return err
+				}
+				if target.RevocationURL == "" || target.IntrospectionURL == "" {
+					return skipf("revocation and introspection endpoints are both required")
+				}
+				tr, _, err := issue(ctx, target, target.Scope)
+				if err != nil {
+					return err
+				}
+				resp, err := send(ctx, target, http.MethodPost, target.RevocationURL,
+					url.Values{"token": {tr.AccessToken}, "token_type_hint": {"access_token"}}, true)
+				if err != nil {
+					return err
+				}
+				if resp.status != http.StatusOK {
+					return fmt.Errorf("revocation failed with status %d", resp.status)
+				}
+				active, err := introspect(ctx, target, tr.AccessToken)
+				if err != nil {
+					return err
+				}
+				if active {
+					return fmt.Errorf("revoked token still reported active")
+				}
+				return nil
+			},
+		},
+		{
+			ID:          "rfc7636-b-s256-vector",
+			Spec:        "RFC 7636 §4.2",
+			Description: "S256 code challenge matches the Appendix B test vector",
+			Run: func(_ context.Context, _ *Target) error {
+				const (
+					verifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+					challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+				)
+				if got := S256Challenge(verifier); got != challenge {
+					return fmt.Errorf("expected %s, got %s", challenge, got)
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// S256Challenge derives an RFC 7636 S256 code challenge from a verifier
+func S256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,262 @@
+// Package deprecation tracks deprecated APIs, packages and configuration
+// options at runtime. Deprecated code paths report each use to a Registry,
+// which logs a structured warning naming the sunset date and replacement,
+// counts the use in a Prometheus metric and remembers it, so operators can
+// list everything a deployment still relies on before upgrading.
+package deprecation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Kind classifies a deprecated feature
+type Kind string
+
+const (
+	// KindAPI is a deprecated function, method or type
+	KindAPI Kind = "api"
+
+	// KindPackage is a deprecated package
+	KindPackage Kind = "package"
+
+	// KindConfig is a deprecated configuration option
+	KindConfig Kind = "config"
+)
+
+// Notice describes a deprecated feature
+type Notice struct {
+	// ID names the feature, e.g. "pkg/tokenstore" or "config:rate.legacy_window"
+	ID   string `json:"id"`
+	Kind Kind   `json:"kind"`
+
+	// Since is the release that deprecated the feature
+	Since string `json:"since,omitempty"`
+
+	// Sunset is when the feature is removed (zero = not scheduled)
+	Sunset time.Time `json:"sunset,omitempty"`
+
+	// Replacement tells users what to use instead
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// Usage is a deprecated feature that was used
+type Usage struct {
+	Notice
+	Count     int64     `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// PastSunset reports whether the feature is still used after its sunset date
+func (u Usage) PastSunset(now time.Time) bool {
+	return !u.Sunset.IsZero() && !now.Before(u.Sunset)
+}
+
+// Config configures a Registry
+type Config struct {
+	// Logger receives the deprecation warnings (default: slog.Default())
+	Logger *slog.Logger
+
+	// Namespace prefixes the usage metric (default: gauth)
+	Namespace string
+
+	// WarnInterval limits warnings to one per feature per interval
+	// (default: 1h)
+	WarnInterval time.Duration
+
+	// Now returns the current time (default: time.Now)
+	Now func() time.Time
+}
+
+type usage struct {
+	count     int64
+	firstSeen time.Time
+	lastSeen  time.Time
+	warnedAt  time.Time
+}
+
+// Registry records deprecated features and their use. It implements
+// prometheus.Collector for the gauth_deprecated_usage_total metric.
+type Registry struct {
+	config  Config
+	counter *prometheus.CounterVec
+
+	mu      sync.Mutex
+	notices map[string]Notice
+	usages  map[string]*usage
+}
+
+// New creates a deprecation registry
+func New(config Config) *Registry {
+	if config.Namespace == "" {
+		config.Namespace = "gauth"
+	}
+	if config.WarnInterval <= 0 {
+		config.WarnInterval = time.Hour
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Registry{
+		config: config,
+		counter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "deprecated_usage_total",
+			Help:      "Uses of deprecated APIs, packages and configuration options",
+		}, []string{"id", "kind"}),
+		notices: make(map[string]Notice),
+		usages:  make(map[string]*usage),
+	}
+}
+
+// Register declares a deprecated feature. Registering an ID again replaces
+// its notice.
+func (r *Registry) Register(n Notice) {
+	if n.Kind == "" {
+		n.Kind = KindAPI
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notices[n.ID] = n
+}
+
+// Use records one use of a deprecated feature. Unregistered IDs are recorded
+// as APIs so no use goes unnoticed.
+func (r *Registry) Use(id string) {
+	now := r.config.Now()
+
+	r.mu.Lock()
+	n, ok := r.notices[id]
+	if !ok {
+		n = Notice{ID: id, Kind: KindAPI}
+		r.notices[id] = n
+	}
+	u, ok := r.usages[id]
+	if !ok {
+		u = &usage{firstSeen: now}
+		r.usages[id] = u
+	}
+	u.count++
+	u.lastSeen = now
+	warn := u.warnedAt.IsZero() || now.Sub(u.warnedAt) >= r.config.WarnInterval
+	if warn {
+		u.warnedAt = now
+	}
+	r.mu.Unlock()
+
+	r.counter.WithLabelValues(id, string(n.Kind)).Inc()
+	if warn {
+		r.warn(n, now)
+	}
+}
+
+func (r *Registry) warn(n Notice, now time.Time) {
+	logger := r.config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	attrs := []any{slog.String("id", n.ID), slog.String("kind", string(n.Kind))}
+	if n.Since != "" {
+		attrs = append(attrs, slog.String("since", n.Since))
+	}
+	if !n.Sunset.IsZero() {
+		attrs = append(attrs, slog.Time("sunset", n.Sunset), slog.Bool("past_sunset", !now.Before(n.Sunset)))
+	}
+	if n.Replacement != "" {
+		attrs = append(attrs, slog.String("replacement", n.Replacement))
+	}
+	logger.Warn("deprecated feature used", attrs...)
+}
+
+// Notices returns every registered feature, sorted by ID
+func (r *Registry) Notices() []Notice {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Notice, 0, len(r.notices))
+	for _, n := range r.notices {
+		out = append(out, n)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Usages returns the deprecated features used so far, sorted by ID
+func (r *Registry) Usages() []Usage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Usage, 0, len(r.usages))
+	for id, u := range r.usages {
+		out = append(out, Usage{
+			Notice:    r.notices[id],
+			Count:     u.count,
+			FirstSeen: u.firstSeen,
+			LastSeen:  u.lastSeen,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Describe implements prometheus.Collector
+func (r *Registry) Describe(ch chan<- *prometheus.Desc) {
+	r.counter.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (r *Registry) Collect(ch chan<- prometheus.Metric) {
+	r.counter.Collect(ch)
+}
+
+// Handler serves the usage report as JSON, for gauthctl doctor -deprecations
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(r.Usages())
+	})
+}
+
+// FetchUsages reads the usage report served by Handler at url
+func FetchUsages(ctx context.Context, client *http.Client, url string) ([]Usage, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch deprecation report: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch deprecation report: %s", resp.Status)
+	}
+	var usages []Usage
+	if err := json.NewDecoder(resp.Body).Decode(&usages); err != nil {
+		return nil, fmt.Errorf("failed to decode deprecation report: %w", err)
+	}
+	return usages, nil
+}
+
+// Default is the process-wide registry used by GAuth's own deprecated APIs
+var Default = New(Config{})
+
+// Register declares a deprecated feature in the default registry
+func Register(n Notice) {
+	Default.Register(n)
+}
+
+// Use records a use of a deprecated feature in the default registry
+func Use(id string) {
+	Default.Use(id)
+}
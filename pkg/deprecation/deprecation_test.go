@@ -0,0 +1,81 @@
+package deprecation
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRegistry(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var logs bytes.Buffer
+	reg := New(Config{
+		Logger:       slog.New(slog.NewJSONHandler(&logs, nil)),
+		WarnInterval: time.Hour,
+		Now:          func() time.Time { return now },
+	})
+	reg.Register(Notice{
+		ID:          "config:rate.legacy_window",
+		Kind:        KindConfig,
+		Since:       "1.4.0",
+		Sunset:      time.Date(2026, 6, 30, 0, 0, 0, 0, time.UTC),
+		Replacement: "rate.window",
+	})
+
+	t.Run("Structured Warning Once Per Interval", func(t *testing.T) {
+		reg.Use("config:rate.legacy_window")
+		reg.Use("config:rate.legacy_window")
+		if n := strings.Count(logs.String(), "deprecated feature used"); n != 1 {
+			t.Fatalf("Expected one warning, got %d:\n%s", n, logs.String())
+		}
+		for _, want := range []string{`"id":"config:rate.legacy_window"`, `"kind":"config"`, `"sunset":"2026-06-30T00:00:00Z"`, `"replacement":"rate.window"`} {
+			if !strings.Contains(logs.String(), want) {
+				t.Errorf("Expected %s in warning: %s", want, logs.String())
+			}
+		}
+
+		now = now.Add(2 * time.Hour)
+		reg.Use("config:rate.legacy_window")
+		if n := strings.Count(logs.String(), "deprecated feature used"); n != 2 {
+			t.Errorf("Expected a new warning after the interval, got %d", n)
+		}
+	})
+
+	t.Run("Metrics", func(t *testing.T) {
+		if got := testutil.ToFloat64(reg.counter.WithLabelValues("config:rate.legacy_window", "config")); got != 3 {
+			t.Errorf("Expected 3 counted uses, got %v", got)
+		}
+		if n := testutil.CollectAndCount(reg, "gauth_deprecated_usage_total"); n != 1 {
+			t.Errorf("Expected one series, got %d", n)
+		}
+	})
+
+	t.Run("Unregistered Features Are Recorded", func(t *testing.T) {
+		reg.Use("LegacyFunc")
+		usages := reg.Usages()
+		if len(usages) != 2 || usages[0].ID != "LegacyFunc" || usages[0].Kind != KindAPI {
+			t.Fatalf("Expected LegacyFunc to be recorded as an API, got %+v", usages)
+		}
+		if u := usages[1]; u.Count != 3 || u.PastSunset(now) || !u.PastSunset(u.Sunset) {
+			t.Errorf("Unexpected usage %+v", u)
+		}
+	})
+
+	t.Run("Remote Report", func(t *testing.T) {
+		srv := httptest.NewServer(reg.Handler())
+		defer srv.Close()
+		usages, err := FetchUsages(context.Background(), srv.Client(), srv.URL)
+		if err != nil {
+			t.Fatalf("FetchUsages: %v", err)
+		}
+		if len(usages) != 2 || usages[1].Replacement != "rate.window" || usages[1].Count != 3 {
+			t.Errorf("Unexpected report %+v", usages)
+		}
+	})
+}
@@ -0,0 +1,208 @@
+package doctor
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/Gimel-Foundation/gauth/pkg/deprecation"
+	"github.com/Gimel-Foundation/gauth/pkg/provision"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// ExcludedTerms are identifiers that indicate mechanisms RFC111 excludes:
+// Web3/blockchain, DNA-based identity and decentralized authorization
+var ExcludedTerms = []string{"web3", "blockchain", "smart_contract", "dna", "genetic", "decentralized", "did:"}
+
+// PingCheck verifies connectivity to a store through its ping function
+func PingCheck(name string, ping func(ctx context.Context) error) Check {
+	return Check{Name: name, Run: func(ctx context.Context) Result {
+		if err := ping(ctx); err != nil {
+			return Result{Status: StatusFail, Message: fmt.Sprintf("unreachable: %v", err),
+				Remedy: "check the address, credentials and network policy for this store"}
+		}
+		return Result{Status: StatusOK, Message: "reachable"}
+	}}
+}
+
+// RedisCheck verifies Redis connectivity
+func RedisCheck(client *redis.Client) Check {
+	return PingCheck("redis", func(ctx context.Context) error { return client.Ping(ctx).Err() })
+}
+
+// SQLCheck verifies database connectivity
+func SQLCheck(db *sql.DB) Check {
+	return PingCheck("database", db.PingContext)
+}
+
+// KeyCheck verifies a signing key is usable and strong enough
+func KeyCheck(name string, key crypto.Signer) Check {
+	return Check{Name: name, Run: func(context.Context) Result {
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			if err := k.Validate(); err != nil {
+				return Result{Status: StatusFail, Message: fmt.Sprintf("invalid RSA key: %v", err),
+					Remedy: "regenerate the signing key"}
+			}
+			if bits := k.N.BitLen(); bits < 2048 {
+				return Result{Status: StatusFail, Message: fmt.Sprintf("RSA key is %d bits", bits),
+					Remedy: "use an RSA key of at least 2048 bits"}
+			}
+			return Result{Status: StatusOK, Message: fmt.Sprintf("RSA %d-bit key", k.N.BitLen())}
+		case *ecdsa.PrivateKey:
+			if _, err := k.ECDH(); err != nil {
+				return Result{Status: StatusFail, Message: fmt.Sprintf("invalid ECDSA key: %v", err),
+					Remedy: "regenerate the signing key"}
+			}
+			return Result{Status: StatusOK, Message: fmt.Sprintf("ECDSA %s key", k.Curve.Params().Name)}
+		case ed25519.PrivateKey:
+			if len(k) != ed25519.PrivateKeySize {
+				return Result{Status: StatusFail, Message: "malformed Ed25519 key", Remedy: "regenerate the signing key"}
+			}
+			return Result{Status: StatusOK, Message: "Ed25519 key"}
+		case nil:
+			return Result{Status: StatusFail, Message: "no signing key configured", Remedy: "configure a signing key"}
+		default:
+			return Result{Status: StatusWarn, Message: fmt.Sprintf("unrecognised key type %T", key)}
+		}
+	}}
+}
+
+// CertificateCheck verifies a certificate is within its validity period and
+// warns when it expires within warnWithin
+func CertificateCheck(name string, cert *x509.Certificate, warnWithin time.Duration) Check {
+	return Check{Name: name, Run: func(context.Context) Result {
+		now := time.Now()
+		switch {
+		case now.Before(cert.NotBefore):
+			return Result{Status: StatusFail, Message: fmt.Sprintf("not valid until %s", cert.NotBefore.Format(time.RFC3339)),
+				Remedy: "check the system clock or reissue the certificate"}
+		case now.After(cert.NotAfter):
+			return Result{Status: StatusFail, Message: fmt.Sprintf("expired at %s", cert.NotAfter.Format(time.RFC3339)),
+				Remedy: "rotate the certificate"}
+		case cert.NotAfter.Sub(now) < warnWithin:
+			return Result{Status: StatusWarn, Message: fmt.Sprintf("expires in %s", cert.NotAfter.Sub(now).Round(time.Hour)),
+				Remedy: "schedule certificate rotation"}
+		}
+		return Result{Status: StatusOK, Message: fmt.Sprintf("valid until %s", cert.NotAfter.Format(time.RFC3339))}
+	}}
+}
+
+// PolicyCheck validates a provisioning document
+func PolicyCheck(doc *provision.Document) Check {
+	return Check{Name: "policies", Run: func(context.Context) Result {
+		if err := provision.Validate(doc); err != nil {
+			return Result{Status: StatusFail, Message: err.Error(), Remedy: "fix the configuration document and re-apply it"}
+		}
+		return Result{Status: StatusOK, Message: fmt.Sprintf("%d policies, %d clients valid", len(doc.Policies), len(doc.Clients))}
+	}}
+}
+
+// ClockCheck compares the local clock to a reference
+func ClockCheck(ref token.ClockReference, threshold time.Duration) Check {
+	return Check{Name: "clock", Run: func(ctx context.Context) Result {
+		offset, err := ref.Offset(ctx)
+		if err != nil {
+			return Result{Status: StatusWarn, Message: fmt.Sprintf("could not reach time reference: %v", err),
+				Remedy: "allow NTP traffic or configure a reachable time server"}
+		}
+		if offset > threshold || offset < -threshold {
+			return Result{Status: StatusFail, Message: fmt.Sprintf("clock is off by %s", offset),
+				Remedy: "synchronise the host clock (e.g. enable chronyd or systemd-timesyncd)"}
+		}
+		return Result{Status: StatusOK, Message: fmt.Sprintf("offset %s", offset)}
+	}}
+}
+
+// ExclusionCheck verifies a configuration does not rely on mechanisms
+// excluded by RFC111
+func ExclusionCheck(doc *provision.Document) Check {
+	return Check{Name: "exclusions", Run: func(context.Context) Result {
+		var hits []string
+		flag := func(where, value string) {
+			lower := strings.ToLower(value)
+			for _, term := range ExcludedTerms {
+				if strings.Contains(lower, term) {
+					hits = append(hits, fmt.Sprintf("%s %q", where, value))
+					return
+				}
+			}
+		}
+		for _, c := range doc.Clients {
+			flag("client type", c.Type)
+			for _, g := range c.GrantTypes {
+				flag("grant type", g)
+			}
+		}
+		for _, s := range doc.Scopes {
+			flag("scope", s.Name)
+		}
+		for _, p := range doc.Policies {
+			if p == nil {
+				continue
+			}
+			for _, s := range p.Subjects {
+				flag("policy subject type", s.Type)
+			}
+			for _, r := range p.Resources {
+				flag("policy resource type", r.Type)
+			}
+		}
+		for _, d := range doc.Delegations {
+			flag("delegation principal", d.Principal)
+			flag("delegation delegate", d.Delegate)
+		}
+		if len(hits) > 0 {
+			return Result{Status: StatusFail, Message: "excluded mechanisms referenced: " + strings.Join(hits, ", "),
+				Remedy: "remove Web3, DNA-based and decentralized identity references; RFC111 excludes them"}
+		}
+		return Result{Status: StatusOK, Message: "no excluded mechanisms referenced"}
+	}}
+}
+
+// DeprecationCheck lists the deprecated features a deployment still uses.
+// usages is typically deprecation.Default.Usages wrapped, or a
+// deprecation.FetchUsages call against a running server.
+func DeprecationCheck(usages func(ctx context.Context) ([]deprecation.Usage, error)) Check {
+	return Check{Name: "deprecations", Run: func(ctx context.Context) Result {
+		used, err := usages(ctx)
+		if err != nil {
+			return Result{Status: StatusWarn, Message: fmt.Sprintf("could not read deprecation report: %v", err),
+				Remedy: "expose the deprecation report of the server and pass its URL"}
+		}
+		if len(used) == 0 {
+			return Result{Status: StatusOK, Message: "no deprecated features in use"}
+		}
+		now := time.Now()
+		items := make([]string, 0, len(used))
+		var remedies []string
+		for _, u := range used {
+			item := fmt.Sprintf("%s (%d uses", u.ID, u.Count)
+			if !u.Sunset.IsZero() {
+				if u.PastSunset(now) {
+					item += ", past sunset " + u.Sunset.Format("2006-01-02")
+				} else {
+					item += ", sunset " + u.Sunset.Format("2006-01-02")
+				}
+			}
+			items = append(items, item+")")
+			if u.Replacement != "" {
+				remedies = append(remedies, fmt.Sprintf("%s → %s", u.ID, u.Replacement))
+			}
+		}
+		res := Result{Status: StatusWarn, Message: "still in use: " + strings.Join(items, ", ")}
+		if len(remedies) > 0 {
+			res.Remedy = "migrate " + strings.Join(remedies, "; ")
+		}
+		return res
+	}}
+}
@@ -0,0 +1,169 @@
+// Package doctor runs startup self-tests: store connectivity, key validity
+// and expiry, policy syntax, clock sanity and RFC111 exclusion compliance.
+// The report names what is wrong and how to fix it, so problems surface
+// before traffic is served rather than as request failures.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/warmup"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	// StatusOK means the check passed
+	StatusOK Status = "ok"
+
+	// StatusWarn means the check passed but needs attention soon
+	StatusWarn Status = "warn"
+
+	// StatusFail means the server should not serve traffic
+	StatusFail Status = "fail"
+)
+
+// ErrUnhealthy indicates at least one check failed
+var ErrUnhealthy = errors.New("self-test failed")
+
+// Result is the outcome of one check
+type Result struct {
+	Check    string        `json:"check"`
+	Status   Status        `json:"status"`
+	Message  string        `json:"message"`
+	Remedy   string        `json:"remedy,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Check is a single self-test
+type Check struct {
+	// Name identifies the check in the report
+	Name string
+
+	// Run performs the check
+	Run func(ctx context.Context) Result
+}
+
+// Report is the outcome of a self-test run
+type Report struct {
+	StartedAt time.Time `json:"started_at"`
+	Results   []Result  `json:"results"`
+}
+
+// Healthy reports whether no check failed
+func (r *Report) Healthy() bool {
+	for _, res := range r.Results {
+		if res.Status == StatusFail {
+			return false
+		}
+	}
+	return true
+}
+
+// Write prints the report in a human-readable form
+func (r *Report) Write(w io.Writer) error {
+	for _, res := range r.Results {
+		if _, err := fmt.Fprintf(w, "[%-4s] %-20s %s\n", res.Status, res.Check, res.Message); err != nil {
+			return err
+		}
+		if res.Remedy != "" && res.Status != StatusOK {
+			if _, err := fmt.Fprintf(w, "       %-20s fix: %s\n", "", res.Remedy); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Doctor runs registered checks
+type Doctor struct {
+	// Timeout bounds each check (default: 10s)
+	Timeout time.Duration
+
+	mu     sync.RWMutex
+	checks []Check
+}
+
+// New creates a doctor with the given checks
+func New(checks ...Check) *Doctor {
+	return &Doctor{Timeout: 10 * time.Second, checks: checks}
+}
+
+// Register adds a check
+func (d *Doctor) Register(check Check) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.checks = append(d.checks, check)
+}
+
+// Run executes all checks concurrently and returns the results in
+// registration order
+func (d *Doctor) Run(ctx context.Context) *Report {
+	d.mu.RLock()
+	checks := append([]Check(nil), d.checks...)
+	d.mu.RUnlock()
+
+	report := &Report{StartedAt: time.Now(), Results: make([]Result, len(checks))}
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			report.Results[i] = d.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+	return report
+}
+
+func (d *Doctor) run(ctx context.Context, check Check) Result {
+	timeout := d.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan Result, 1)
+	go func() { done <- check.Run(ctx) }()
+
+	var res Result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		res = Result{Status: StatusFail, Message: fmt.Sprintf("check did not finish within %s", timeout),
+			Remedy: "verify the dependency is reachable from this host"}
+	}
+	res.Check = check.Name
+	res.Duration = time.Since(start)
+	return res
+}
+
+// Hook runs the self-test as a critical warm-up hook so readiness is only
+// reported once every check passes
+func (d *Doctor) Hook() warmup.Hook {
+	return warmup.Hook{
+		Name:     "doctor",
+		Critical: true,
+		Run: func(ctx context.Context) error {
+			report := d.Run(ctx)
+			if report.Healthy() {
+				return nil
+			}
+			var failed []string
+			for _, res := range report.Results {
+				if res.Status == StatusFail {
+					failed = append(failed, res.Check+": "+res.Message)
+				}
+			}
+			return fmt.Errorf("%w: %v", ErrUnhealthy, failed)
+		},
+	}
+}
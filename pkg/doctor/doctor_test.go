@@ -0,0 +1,175 @@
+package doctor
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+	"github.com/Gimel-Foundation/gauth/pkg/deprecation"
+	"github.com/Gimel-Foundation/gauth/pkg/provision"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+func TestDoctor(t *testing.T) {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	doc := &provision.Document{
+		Scopes:   []provision.Scope{{Name: "read"}},
+		Clients:  []provision.Client{{ID: "app", Type: "confidential", Scopes: []string{"read"}}},
+		Policies: []*authz.Policy{{ID: "p1", Effect: authz.Allow}},
+	}
+
+	t.Run("Healthy", func(t *testing.T) {
+		d := New(
+			PingCheck("store", func(context.Context) error { return nil }),
+			KeyCheck("signing key", key),
+			PolicyCheck(doc),
+			ExclusionCheck(doc),
+			ClockCheck(token.ClockReferenceFunc(func(context.Context) (time.Duration, error) {
+				return 100 * time.Millisecond, nil
+			}), time.Second),
+		)
+		report := d.Run(ctx)
+		if !report.Healthy() {
+			var b strings.Builder
+			_ = report.Write(&b)
+			t.Fatalf("Expected healthy report, got:\n%s", b.String())
+		}
+		if err := d.Hook().Run(ctx); err != nil {
+			t.Errorf("Expected hook to pass, got %v", err)
+		}
+	})
+
+	t.Run("Actionable Failures", func(t *testing.T) {
+		bad := &provision.Document{
+			Clients:  []provision.Client{{ID: "wallet", Type: "web3-wallet"}},
+			Policies: []*authz.Policy{{ID: "p1", Effect: "maybe"}},
+		}
+		d := New(
+			PingCheck("store", func(context.Context) error { return errors.New("connection refused") }),
+			PolicyCheck(bad),
+			ExclusionCheck(bad),
+		)
+		d.Timeout = time.Second
+		report := d.Run(ctx)
+		if report.Healthy() {
+			t.Fatal("Expected unhealthy report")
+		}
+		for _, res := range report.Results {
+			if res.Status != StatusFail || res.Remedy == "" {
+				t.Errorf("Expected failure with remedy for %s, got %+v", res.Check, res)
+			}
+		}
+		if err := d.Hook().Run(ctx); !errors.Is(err, ErrUnhealthy) {
+			t.Errorf("Expected ErrUnhealthy, got %v", err)
+		}
+	})
+
+	t.Run("Slow Check", func(t *testing.T) {
+		d := New(Check{Name: "hang", Run: func(ctx context.Context) Result {
+			<-ctx.Done()
+			time.Sleep(10 * time.Millisecond)
+			return Result{Status: StatusOK}
+		}})
+		d.Timeout = 10 * time.Millisecond
+		if res := d.Run(ctx).Results[0]; res.Status != StatusFail {
+			t.Errorf("Expected timed-out check to fail, got %+v", res)
+		}
+	})
+
+	t.Run("Deprecations", func(t *testing.T) {
+		reg := deprecation.New(deprecation.Config{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+		check := DeprecationCheck(func(context.Context) ([]deprecation.Usage, error) { return reg.Usages(), nil })
+		if res := New(check).Run(ctx).Results[0]; res.Status != StatusOK {
+			t.Errorf("Expected ok without deprecated uses, got %+v", res)
+		}
+
+		reg.Register(deprecation.Notice{ID: "pkg/tokenstore", Kind: deprecation.KindPackage,
+			Sunset: time.Now().Add(-time.Hour), Replacement: "pkg/token"})
+		reg.Use("pkg/tokenstore")
+		res := New(check).Run(ctx).Results[0]
+		if res.Status != StatusWarn || !strings.Contains(res.Message, "pkg/tokenstore (1 uses, past sunset") ||
+			!strings.Contains(res.Remedy, "pkg/tokenstore → pkg/token") {
+			t.Errorf("Expected warning listing the deprecated package, got %+v", res)
+		}
+	})
+}
+
+func TestFingerprint(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	type settings struct {
+		Issuer   string
+		TTL      time.Duration
+		Scopes   map[string][]string
+		Key      interface{}
+		Store    token.Store
+		OnChange func()
+		Address  string `fingerprint:"-"`
+		internal int
+	}
+	base := func() settings {
+		return settings{
+			Issuer: "https://auth.example.com",
+			TTL:    time.Hour,
+			Scopes: map[string][]string{"app": {"read"}, "admin": {"read", "write"}},
+			Key:    key,
+			Store:  token.NewMemoryStore(time.Hour),
+		}
+	}
+	want, err := Fingerprint(base())
+	if err != nil {
+		t.Fatalf("Fingerprint() error: %v", err)
+	}
+	if !strings.HasPrefix(want, "sha256:") || len(ShortFingerprint(want)) != 12 {
+		t.Errorf("Unexpected fingerprint format %q", want)
+	}
+
+	t.Run("Stable", func(t *testing.T) {
+		same := base()
+		same.Address = "10.0.0.7:8443"
+		same.internal = 42
+		_ = same.Store.Save(context.Background(), "tok-1", &token.Token{ID: "tok-1", ExpiresAt: time.Now().Add(time.Hour)})
+		for i := 0; i < 5; i++ {
+			if got, _ := Fingerprint(&same); got != want {
+				t.Fatalf("Expected %s, got %s", want, got)
+			}
+		}
+	})
+
+	t.Run("Changes With Settings", func(t *testing.T) {
+		other, _ := rsa.GenerateKey(rand.Reader, 2048)
+		changes := map[string]func(*settings){
+			"ttl":      func(s *settings) { s.TTL = 2 * time.Hour },
+			"scopes":   func(s *settings) { s.Scopes["app"] = append(s.Scopes["app"], "write") },
+			"key":      func(s *settings) { s.Key = other },
+			"callback": func(s *settings) { s.OnChange = func() {} },
+		}
+		for name, change := range changes {
+			s := base()
+			change(&s)
+			if got, _ := Fingerprint(s); got == want {
+				t.Errorf("Expected the fingerprint to change with %s", name)
+			}
+		}
+	})
+
+	t.Run("Config Check", func(t *testing.T) {
+		report := New(ConfigCheck(want)).Run(context.Background())
+		if report.Results[0].Status != StatusOK || !strings.Contains(report.Results[0].Message, want) {
+			t.Errorf("Unexpected result %+v", report.Results[0])
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package doctor
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// maxFingerprintDepth bounds how deep Fingerprint follows nested values
+const maxFingerprintDepth = 32
+
+// Fingerprint returns a stable hash of the effective configuration, e.g.
+// "sha256:3f1a…", so operators can tell which configuration an instance
+// runs. Instances with equal settings get equal fingerprints regardless of
+// map order or where the values came from.
+//
+// Exported fields are hashed by name; fields tagged `fingerprint:"-"` (such
+// as per-instance addresses) are skipped. Interface-typed components like
+// stores and event handlers contribute only their type, functions only
+// whether they are set, and keys their public half, so neither runtime
+// state nor private key material affects the result.
+func Fingerprint(config interface{}) (string, error) {
+	canonical, err := json.Marshal(canonicalize(reflect.ValueOf(config), 0, false))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	sum := sha256.Sum256(canonical)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// ShortFingerprint abbreviates a fingerprint for logs and metric labels
+func ShortFingerprint(fingerprint string) string {
+	const prefix = "sha256:"
+	if len(fingerprint) > len(prefix)+12 && fingerprint[:len(prefix)] == prefix {
+		return fingerprint[len(prefix) : len(prefix)+12]
+	}
+	return fingerprint
+}
+
+// ConfigCheck reports the configuration fingerprint in the self-test report
+func ConfigCheck(fingerprint string) Check {
+	return Check{Name: "config", Run: func(context.Context) Result {
+		if fingerprint == "" {
+			return Result{Status: StatusWarn, Message: "no configuration fingerprint",
+				Remedy: "compute one with doctor.Fingerprint at startup"}
+		}
+		return Result{Status: StatusOK, Message: "fingerprint " + fingerprint}
+	}}
+}
+
+// canonicalize converts v into plain maps, slices and scalars.
+// component is set for values reached through an interface.
+func canonicalize(v reflect.Value, depth int, component bool) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if depth > maxFingerprintDepth {
+		return v.Type().String()
+	}
+	if v.CanInterface() {
+		switch x := v.Interface().(type) {
+		case time.Duration:
+			return x.String()
+		case time.Time:
+			return x.UTC().Format(time.RFC3339Nano)
+		case *time.Location:
+			if x == nil {
+				return nil
+			}
+			return x.String()
+		case interface{ Public() crypto.PublicKey }:
+			if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Slice) && v.IsNil() {
+				return nil
+			}
+			der, err := x509.MarshalPKIXPublicKey(x.Public())
+			if err != nil {
+				return v.Type().String()
+			}
+			sum := sha256.Sum256(der)
+			return "key:" + hex.EncodeToString(sum[:])
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return canonicalize(v.Elem(), depth+1, true)
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		if component && v.Elem().Kind() == reflect.Struct {
+			// Stores, handlers and clients carry state, not settings
+			return v.Type().String()
+		}
+		return canonicalize(v.Elem(), depth+1, component)
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		if v.IsNil() {
+			return nil
+		}
+		return v.Type().String()
+	case reflect.Struct:
+		if component {
+			return v.Type().String()
+		}
+		out := make(map[string]interface{})
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() || field.Tag.Get("fingerprint") == "-" {
+				continue
+			}
+			out[field.Name] = canonicalize(v.Field(i), depth+1, false)
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[fmt.Sprint(iter.Key().Interface())] = canonicalize(iter.Value(), depth+1, component)
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			sum := sha256.Sum256(v.Bytes())
+			return "bytes:" + hex.EncodeToString(sum[:])
+		}
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			out[i] = canonicalize(v.Index(i), depth+1, component)
+		}
+		return out
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	default:
+		return v.Type().String()
+	}
+}
@@ -27,6 +27,9 @@ const (
 	ErrInvalidScope           ErrorCode = "invalid_scope"
 	ErrServerError            ErrorCode = "server_error"
 	ErrTemporarilyUnavailable ErrorCode = "temporarily_unavailable"
+	ErrAccessDenied           ErrorCode = "access_denied"
+	ErrUnsupportedGrantType   ErrorCode = "unsupported_grant_type"
+	ErrUnsupportedResponse    ErrorCode = "unsupported_response_type"
 
 	// Token store related errors
 	ErrMissingEncryptionKey ErrorCode = "missing_encryption_key"
@@ -100,13 +103,25 @@ type Error struct {
 
 	// Cause is the underlying error
 	Cause error
+
+	// Retryable reports whether repeating the request may succeed
+	Retryable bool
+
+	// RetryAfter is the delay the server asks for before a retry (0 = use
+	// the code's backoff)
+	RetryAfter time.Duration
+
+	// DocURL points at the documentation of the error code
+	DocURL string
 }
 
 // New creates a new structured error
 func New(code ErrorCode, message string) *Error {
 	return &Error{
-		Code:    code,
-		Message: message,
+		Code:      code,
+		Message:   message,
+		Retryable: Info(code).Retryable,
+		DocURL:    code.DocURL(),
 		Details: &ErrorDetails{
 			Timestamp:      time.Now(),
 			AdditionalInfo: make(map[string]string),
@@ -0,0 +1,197 @@
+package errors
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	stderrors "errors"
+)
+
+// DocsBaseURL is the page documenting every error code; each code has an
+// anchor named after it
+const DocsBaseURL = "https://github.com/Gimel-Foundation/gauth/blob/main/docs/ERROR_CODES.md"
+
+// MaxBackoff caps the delays computed by RetryDelay
+const MaxBackoff = 5 * time.Minute
+
+// CodeInfo describes how clients should react to an error code
+type CodeInfo struct {
+	// HTTPStatus is the status servers answer with
+	HTTPStatus int
+
+	// Retryable reports whether repeating the same request may succeed
+	Retryable bool
+
+	// Backoff is the suggested delay before the first retry
+	Backoff time.Duration
+}
+
+// codeInfo holds the defaults of the predefined codes
+var codeInfo = map[ErrorCode]CodeInfo{
+	ErrTokenExpired:           {HTTPStatus: http.StatusUnauthorized},
+	ErrInvalidToken:           {HTTPStatus: http.StatusUnauthorized},
+	ErrInsufficientScope:      {HTTPStatus: http.StatusForbidden},
+	ErrRateLimited:            {HTTPStatus: http.StatusTooManyRequests, Retryable: true, Backoff: time.Second},
+	ErrInvalidRequest:         {HTTPStatus: http.StatusBadRequest},
+	ErrInvalidClient:          {HTTPStatus: http.StatusUnauthorized},
+	ErrInvalidGrant:           {HTTPStatus: http.StatusBadRequest},
+	ErrUnauthorizedClient:     {HTTPStatus: http.StatusBadRequest},
+	ErrInvalidScope:           {HTTPStatus: http.StatusBadRequest},
+	ErrServerError:            {HTTPStatus: http.StatusInternalServerError, Retryable: true, Backoff: time.Second},
+	ErrTemporarilyUnavailable: {HTTPStatus: http.StatusServiceUnavailable, Retryable: true, Backoff: 2 * time.Second},
+	ErrAccessDenied:           {HTTPStatus: http.StatusForbidden},
+	ErrUnsupportedGrantType:   {HTTPStatus: http.StatusBadRequest},
+	ErrUnsupportedResponse:    {HTTPStatus: http.StatusBadRequest},
+	ErrMissingEncryptionKey:   {HTTPStatus: http.StatusInternalServerError},
+	ErrMissingUserID:          {HTTPStatus: http.StatusBadRequest},
+	ErrMissingClientID:        {HTTPStatus: http.StatusBadRequest},
+	ErrMissingExpiry:          {HTTPStatus: http.StatusBadRequest},
+	ErrTokenNotFound:          {HTTPStatus: http.StatusNotFound},
+	ErrStoreFull:              {HTTPStatus: http.StatusServiceUnavailable, Retryable: true, Backoff: 5 * time.Second},
+	ErrInvalidData:            {HTTPStatus: http.StatusBadRequest},
+}
+
+// Info returns the client-facing defaults of a code. Unknown codes are
+// answered with 500 and are not retryable.
+func Info(code ErrorCode) CodeInfo {
+	if info, ok := codeInfo[code]; ok {
+		return info
+	}
+	return CodeInfo{HTTPStatus: http.StatusInternalServerError}
+}
+
+// DocURL returns the documentation URL of the code
+func (e ErrorCode) DocURL() string {
+	return DocsBaseURL + "#" + string(e)
+}
+
+// WithRetryAfter marks the error retryable after the given delay
+func (e *Error) WithRetryAfter(delay time.Duration) *Error {
+	e.Retryable = true
+	e.RetryAfter = delay
+	return e
+}
+
+// WithRetryable overrides the retryability of the error's code
+func (e *Error) WithRetryable(retryable bool) *Error {
+	e.Retryable = retryable
+	if !retryable {
+		e.RetryAfter = 0
+	}
+	return e
+}
+
+// WithDocURL overrides the documentation URL of the error's code
+func (e *Error) WithDocURL(url string) *Error {
+	e.DocURL = url
+	return e
+}
+
+// HTTPStatus returns the status the error is served with
+func (e *Error) HTTPStatus() int {
+	if e.Details != nil && e.Details.HTTPStatusCode > 0 {
+		return e.Details.HTTPStatusCode
+	}
+	return Info(e.Code).HTTPStatus
+}
+
+// IsRetryable reports whether err carries a retryable *Error
+func IsRetryable(err error) bool {
+	var authErr *Error
+	return stderrors.As(err, &authErr) && authErr.Retryable
+}
+
+// RetryDelay returns how long to wait before retry number attempt (counting
+// from 0) of a request that failed with err. A server-provided RetryAfter is
+// used as is; otherwise the code's backoff doubles per attempt up to
+// MaxBackoff. It returns false when err should not be retried.
+func RetryDelay(err error, attempt int) (time.Duration, bool) {
+	var authErr *Error
+	if !stderrors.As(err, &authErr) || !authErr.Retryable {
+		return 0, false
+	}
+	if authErr.RetryAfter > 0 {
+		return authErr.RetryAfter, true
+	}
+	delay := Info(authErr.Code).Backoff
+	if delay <= 0 {
+		delay = time.Second
+	}
+	for i := 0; i < attempt && delay < MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > MaxBackoff {
+		delay = MaxBackoff
+	}
+	return delay, true
+}
+
+// errorResponse is the JSON body of an error served over HTTP, an RFC 6749
+// error response extended with retry hints
+type errorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+	ErrorURI         string `json:"error_uri,omitempty"`
+	Retryable        bool   `json:"retryable"`
+	RetryAfter       int    `json:"retry_after,omitempty"`
+}
+
+// WriteHTTP serves the error as a JSON response carrying its retry hints.
+// A Retry-After header is set when the error has a retry delay.
+func (e *Error) WriteHTTP(w http.ResponseWriter) {
+	resp := errorResponse{
+		Error:            string(e.Code),
+		ErrorDescription: e.Message,
+		ErrorURI:         e.DocURL,
+		Retryable:        e.Retryable,
+	}
+	if e.RetryAfter > 0 {
+		resp.RetryAfter = int((e.RetryAfter + time.Second - 1) / time.Second)
+		w.Header().Set("Retry-After", strconv.Itoa(resp.RetryAfter))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(e.HTTPStatus())
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// headerRetryDelay reads the delay a response asks for from its
+// Retry-After header, or else from the reset of its rate limit: the
+// RateLimit-Reset header of the IETF draft, in seconds, or X-RateLimit-Reset,
+// which GAuth and many others send as a Unix time
+func headerRetryDelay(h http.Header, now time.Time) (time.Duration, bool) {
+	if delay, ok := parseRetryAfter(h.Get("Retry-After"), now); ok {
+		return delay, true
+	}
+	if secs, err := strconv.Atoi(h.Get("RateLimit-Reset")); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil && reset >= 0 {
+		// small values are delays in seconds rather than times
+		if reset < 1e9 {
+			return time.Duration(reset) * time.Second, true
+		}
+		return max(time.Unix(reset, 0).Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an
+// HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
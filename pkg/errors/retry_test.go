@@ -0,0 +1,130 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRetryHints(t *testing.T) {
+	t.Run("Defaults From Code", func(t *testing.T) {
+		err := New(ErrTemporarilyUnavailable, "down for maintenance")
+		if !err.Retryable {
+			t.Error("temporarily_unavailable should be retryable")
+		}
+		if err.DocURL != DocsBaseURL+"#temporarily_unavailable" {
+			t.Errorf("unexpected doc URL %q", err.DocURL)
+		}
+		if New(ErrInvalidGrant, "bad grant").Retryable {
+			t.Error("invalid_grant should not be retryable")
+		}
+	})
+
+	t.Run("Retry Delay", func(t *testing.T) {
+		err := New(ErrServerError, "boom")
+		first, ok := RetryDelay(err, 0)
+		if !ok || first != time.Second {
+			t.Fatalf("expected 1s for the first retry, got %v %v", first, ok)
+		}
+		third, _ := RetryDelay(err, 2)
+		if third != 4*time.Second {
+			t.Errorf("expected backoff to double per attempt, got %v", third)
+		}
+		capped, _ := RetryDelay(err, 30)
+		if capped != MaxBackoff {
+			t.Errorf("expected backoff capped at %v, got %v", MaxBackoff, capped)
+		}
+
+		hinted := New(ErrRateLimited, "slow down").WithRetryAfter(7 * time.Second)
+		if d, _ := RetryDelay(fmt.Errorf("call failed: %w", hinted), 5); d != 7*time.Second {
+			t.Errorf("expected server hint to win through wrapping, got %v", d)
+		}
+		if _, ok := RetryDelay(New(ErrInvalidClient, "bad secret"), 0); ok {
+			t.Error("invalid_client should not be retried")
+		}
+		if _, ok := RetryDelay(fmt.Errorf("plain"), 0); ok {
+			t.Error("plain errors carry no retry hint")
+		}
+	})
+
+	t.Run("Round Trip Over HTTP", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		New(ErrRateLimited, "quota exhausted").WithRetryAfter(1500 * time.Millisecond).WriteHTTP(rec)
+
+		if rec.Code != http.StatusTooManyRequests {
+			t.Errorf("expected 429, got %d", rec.Code)
+		}
+		if got := rec.Header().Get("Retry-After"); got != "2" {
+			t.Errorf("expected Retry-After rounded up to 2, got %q", got)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid JSON body: %v", err)
+		}
+		if body["error_uri"] != ErrRateLimited.DocURL() || body["retryable"] != true {
+			t.Errorf("unexpected body %v", body)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/token", nil)
+		resp := rec.Result()
+		resp.Request = req
+		parsed := NewHTTPError(resp, rec.Body.Bytes())
+		if parsed.Code != ErrRateLimited || !parsed.Retryable || parsed.RetryAfter != 2*time.Second {
+			t.Errorf("hints lost in round trip: %+v", parsed)
+		}
+		if parsed.DocURL != ErrRateLimited.DocURL() {
+			t.Errorf("expected doc URL from body, got %q", parsed.DocURL)
+		}
+	})
+
+	t.Run("Service Unavailable With HTTP Date", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/api", nil)
+		resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Request: req, Header: http.Header{}}
+		resp.Header.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+
+		err := NewHTTPError(resp, []byte("upstream down"))
+		if err.Code != ErrTemporarilyUnavailable || !err.Retryable {
+			t.Fatalf("expected retryable temporarily_unavailable, got %+v", err)
+		}
+		if err.RetryAfter < 55*time.Second || err.RetryAfter > time.Minute {
+			t.Errorf("expected about a minute, got %v", err.RetryAfter)
+		}
+	})
+
+	t.Run("Rate Limit Reset Headers", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/token", nil)
+		headers := []http.Header{
+			{"Ratelimit-Reset": {"30"}},
+			{"X-Ratelimit-Reset": {strconv.FormatInt(time.Now().Add(30*time.Second).Unix(), 10)}},
+			{"X-Ratelimit-Reset": {"30"}},
+		}
+		for _, h := range headers {
+			resp := &http.Response{StatusCode: http.StatusTooManyRequests, Request: req, Header: h}
+			err := NewHTTPError(resp, nil)
+			if !err.Retryable || err.RetryAfter < 28*time.Second || err.RetryAfter > 30*time.Second {
+				t.Errorf("expected about 30s from %v, got %+v", h, err)
+			}
+		}
+
+		resp := &http.Response{StatusCode: http.StatusTooManyRequests, Request: req, Header: http.Header{
+			"Retry-After":     {"5"},
+			"Ratelimit-Reset": {"30"},
+		}}
+		if err := NewHTTPError(resp, nil); err.RetryAfter != 5*time.Second {
+			t.Errorf("Retry-After should win over RateLimit-Reset, got %v", err.RetryAfter)
+		}
+	})
+
+	t.Run("Body Overrides Retryability", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/api", nil)
+		resp := &http.Response{StatusCode: http.StatusInternalServerError, Request: req, Header: http.Header{}}
+		err := NewHTTPError(resp, []byte(`{"error":"server_error","retryable":false}`))
+		if IsRetryable(err) {
+			t.Error("explicit retryable=false should be honored")
+		}
+	})
+}
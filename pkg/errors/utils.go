@@ -132,10 +132,13 @@ func IsRateLimitError(err error) bool {
 	return false
 }
 
-// GetRetryAfter extracts the retry-after value if present
+// GetRetryAfter extracts the retry-after value in seconds if present
 func GetRetryAfter(err error) (int, bool) {
 	var authErr *Error
-	if stderrors.As(err, &authErr) && authErr.Details != nil {
+	if stderrors.As(err, &authErr) && authErr.RetryAfter > 0 {
+		return int((authErr.RetryAfter + time.Second - 1) / time.Second), true
+	}
+	if authErr != nil && authErr.Details != nil {
 		if val, ok := authErr.Details.AdditionalInfo["retry_after"]; ok {
 			if retryAfter, err := strconv.Atoi(val); err == nil {
 				return retryAfter, true
@@ -145,7 +148,9 @@ func GetRetryAfter(err error) (int, bool) {
 	return 0, false
 }
 
-// NewHTTPError creates an error from an HTTP response
+// NewHTTPError creates an error from an HTTP response. The error code,
+// documentation URL and retry hints of a GAuth error body are kept, and the
+// rate limit headers of 429 and 5xx responses set the retry delay.
 func NewHTTPError(resp *http.Response, body []byte) *Error {
 	var code ErrorCode
 	var message string
@@ -164,6 +169,9 @@ func NewHTTPError(resp *http.Response, body []byte) *Error {
 	case http.StatusBadRequest:
 		code = ErrInvalidRequest
 		message = "Invalid request"
+	case http.StatusServiceUnavailable:
+		code = ErrTemporarilyUnavailable
+		message = "Service temporarily unavailable"
 	default:
 		code = ErrServerError
 		message = "Server error"
@@ -171,7 +179,11 @@ func NewHTTPError(resp *http.Response, body []byte) *Error {
 
 	// Try to parse response body as JSON
 	var jsonResp map[string]interface{}
-	if err := json.Unmarshal(body, &jsonResp); err == nil {
+	parsed := json.Unmarshal(body, &jsonResp) == nil
+	if parsed {
+		if errCode, ok := jsonResp["error"].(string); ok && errCode != "" {
+			code = ErrorCode(errCode)
+		}
 		// Override message if available in response
 		if errMsg, ok := jsonResp["error_description"].(string); ok && errMsg != "" {
 			message = errMsg
@@ -182,12 +194,32 @@ func NewHTTPError(resp *http.Response, body []byte) *Error {
 
 	// Create error with HTTP details
 	err := New(code, message)
-	err = err.WithHTTPInfo(
-		resp.Request.URL.Path,
-		resp.Request.Method,
-		resp.StatusCode,
-		"",
-	)
+	if resp.StatusCode >= 500 {
+		err.Retryable = true
+	}
+	if parsed {
+		if uri, ok := jsonResp["error_uri"].(string); ok && uri != "" {
+			err.DocURL = uri
+		}
+		if retryable, ok := jsonResp["retryable"].(bool); ok {
+			err.Retryable = retryable
+		}
+		if secs, ok := jsonResp["retry_after"].(float64); ok && secs > 0 {
+			err = err.WithRetryAfter(time.Duration(secs * float64(time.Second)))
+		}
+	}
+	// Retry hints only ask for a retry on overload and outage responses
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		if delay, ok := headerRetryDelay(resp.Header, time.Now()); ok {
+			err = err.WithRetryAfter(delay)
+		}
+	}
+
+	path, method := "", ""
+	if resp.Request != nil {
+		path, method = resp.Request.URL.Path, resp.Request.Method
+	}
+	err = err.WithHTTPInfo(path, method, resp.StatusCode, "")
 
 	// Add response headers as additional info
 	for k, v := range resp.Header {
@@ -39,6 +39,8 @@ const (
 	ActionRoleRevoked          EventAction = "role_revoked"
 	ActionPermissionGranted    EventAction = "permission_granted"
 	ActionPermissionRevoked    EventAction = "permission_revoked"
+	ActionObligationEnforced   EventAction = "obligation_enforced"
+	ActionObligationFailed     EventAction = "obligation_failed"
 )
 
 // Token event actions
@@ -51,6 +53,9 @@ const (
 	ActionTokenValidationFailed EventAction = "token_validation_failed"
 	ActionTokenExpired          EventAction = "token_expired"
 	ActionTokenIntrospected     EventAction = "token_introspected"
+	ActionTokenRestoreApproved  EventAction = "token_restore_approved"
+	ActionTokenRestored         EventAction = "token_restored"
+	ActionTokenRevocationFinal  EventAction = "token_revocation_finalized"
 )
 
 // User activity event actions
@@ -87,6 +92,9 @@ const (
 	ActionAlertTriggered       EventAction = "alert_triggered"
 	ActionMaintenanceStarted   EventAction = "maintenance_started"
 	ActionMaintenanceCompleted EventAction = "maintenance_completed"
+	ActionClockDriftDetected   EventAction = "clock_drift_detected"
+	ActionConfigDriftDetected  EventAction = "config_drift_detected"
+	ActionConfigReconciled     EventAction = "config_reconciled"
 )
 
 // EventStatus represents the status of an event
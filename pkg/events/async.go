@@ -0,0 +1,361 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrPublisherClosed indicates a publish to a closed AsyncPublisher
+var ErrPublisherClosed = errors.New("event publisher closed")
+
+// BackpressurePolicy decides what publishing does when a handler's queue is
+// full
+type BackpressurePolicy int
+
+const (
+	// BackpressureBlock waits for queue space until the publisher's context
+	// is done; use it for handlers that must see every event, such as audit
+	BackpressureBlock BackpressurePolicy = iota
+
+	// BackpressureDrop drops events that find the queue full
+	BackpressureDrop
+
+	// BackpressureSample keeps one in SampleRate events once the queue is
+	// half full, and drops the rest; use it for metrics-style handlers
+	BackpressureSample
+)
+
+// AsyncConfig configures an AsyncPublisher. Its values are the defaults of
+// every subscription; HandlerOptions override them per handler.
+type AsyncConfig struct {
+	// Workers is the number of goroutines per handler (default: NumCPU)
+	Workers int
+
+	// QueueSize bounds the events waiting per handler (default: 1024)
+	QueueSize int
+
+	// Policy applies when a queue is full (default: BackpressureBlock)
+	Policy BackpressurePolicy
+
+	// SampleRate is the 1-in-N rate of BackpressureSample (default: 10)
+	SampleRate int
+
+	// HandlerTimeout bounds each handler call (default: none)
+	HandlerTimeout time.Duration
+
+	// PublishTimeout bounds how long Publish, which has no context, waits
+	// for queue space under BackpressureBlock (default: 5s)
+	PublishTimeout time.Duration
+
+	// OnError receives handler errors and recovered panics (optional)
+	OnError func(*HandlerError)
+
+	// OnDrop receives the events dropped by backpressure (optional)
+	OnDrop func(name string, event Event)
+}
+
+// HandlerOptions override the AsyncConfig defaults for one handler; zero
+// fields inherit them
+type HandlerOptions struct {
+	// Name identifies the handler in errors and stats (default: handler-N)
+	Name string
+
+	Workers        int
+	QueueSize      int
+	Policy         *BackpressurePolicy
+	SampleRate     int
+	HandlerTimeout time.Duration
+}
+
+// HandlerError is a failure of one handler, isolated from the publisher and
+// the other handlers
+type HandlerError struct {
+	Handler string
+	Event   Event
+	Err     error
+}
+
+func (e *HandlerError) Error() string {
+	return fmt.Sprintf("event handler %s failed on %s: %v", e.Handler, e.Event.ID, e.Err)
+}
+
+func (e *HandlerError) Unwrap() error {
+	return e.Err
+}
+
+// HandlerStats are the delivery counters of one handler
+type HandlerStats struct {
+	Name      string
+	Queued    int
+	Delivered uint64
+	Failed    uint64
+	Dropped   uint64
+}
+
+// AsyncPublisher dispatches events to handlers asynchronously. Every handler
+// has its own bounded queue and workers, so a slow or failing handler only
+// backs up its own queue and never stalls the publisher or other handlers.
+// Events reach a handler in publish order only with one worker.
+type AsyncPublisher struct {
+	config AsyncConfig
+	mu     sync.RWMutex
+	subs   []*subscription
+	closed bool
+
+	// done is closed by Close and releases blocked publishers; inflight
+	// counts the publishers still sending, whose queues stay open
+	done     chan struct{}
+	drained  chan struct{}
+	inflight sync.WaitGroup
+}
+
+type queuedEvent struct {
+	ctx   context.Context
+	event Event
+}
+
+type subscription struct {
+	name      string
+	handler   ContextHandler
+	policy    BackpressurePolicy
+	rate      uint64
+	timeout   time.Duration
+	queue     chan queuedEvent
+	wg        sync.WaitGroup
+	seen      atomic.Uint64
+	delivered atomic.Uint64
+	failed    atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// NewAsyncPublisher creates an asynchronous publisher
+func NewAsyncPublisher(config AsyncConfig) *AsyncPublisher {
+	if config.Workers <= 0 {
+		config.Workers = runtime.NumCPU()
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 1024
+	}
+	if config.SampleRate <= 0 {
+		config.SampleRate = 10
+	}
+	if config.PublishTimeout <= 0 {
+		config.PublishTimeout = 5 * time.Second
+	}
+	return &AsyncPublisher{config: config, done: make(chan struct{})}
+}
+
+// Subscribe adds an event handler with the default options
+func (p *AsyncPublisher) Subscribe(handler EventHandler) {
+	p.SubscribeWith(AdaptHandler(handler), HandlerOptions{})
+}
+
+// SubscribeContext adds a context-aware event handler with the default
+// options
+func (p *AsyncPublisher) SubscribeContext(handler ContextHandler) {
+	p.SubscribeWith(handler, HandlerOptions{})
+}
+
+// SubscribeWith adds a context-aware event handler and starts its workers
+func (p *AsyncPublisher) SubscribeWith(handler ContextHandler, opts HandlerOptions) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+
+	s := &subscription{
+		name:    opts.Name,
+		handler: handler,
+		policy:  p.config.Policy,
+		rate:    uint64(p.config.SampleRate),
+		timeout: p.config.HandlerTimeout,
+	}
+	if s.name == "" {
+		s.name = fmt.Sprintf("handler-%d", len(p.subs)+1)
+	}
+	if opts.Policy != nil {
+		s.policy = *opts.Policy
+	}
+	if opts.SampleRate > 0 {
+		s.rate = uint64(opts.SampleRate)
+	}
+	if opts.HandlerTimeout > 0 {
+		s.timeout = opts.HandlerTimeout
+	}
+	queueSize, workers := p.config.QueueSize, p.config.Workers
+	if opts.QueueSize > 0 {
+		queueSize = opts.QueueSize
+	}
+	if opts.Workers > 0 {
+		workers = opts.Workers
+	}
+	s.queue = make(chan queuedEvent, queueSize)
+
+	s.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work(s)
+	}
+	p.subs = append(p.subs, s)
+}
+
+// Publish queues an event for all handlers, waiting at most PublishTimeout
+// for space in blocking queues
+func (p *AsyncPublisher) Publish(event Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.PublishTimeout)
+	defer cancel()
+	_ = p.PublishContext(ctx, event)
+}
+
+// PublishContext queues an event for all handlers and returns without
+// waiting for them. Handlers run under ctx's values but not its
+// cancellation, since they outlive the call. It fails only when the
+// publisher is closed, or when ctx ends or the publisher closes while a
+// blocking queue is full; handler errors go to OnError.
+func (p *AsyncPublisher) PublishContext(ctx context.Context, event Event) error {
+	if event.CorrelationID == "" {
+		event = event.WithContext(ctx)
+	}
+	// The lock only guards the subscription list; waiting for queue space
+	// happens outside it so Close and other publishers are never held up
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return ErrPublisherClosed
+	}
+	subs := p.subs
+	p.inflight.Add(1)
+	p.mu.RUnlock()
+	defer p.inflight.Done()
+
+	q := queuedEvent{ctx: context.WithoutCancel(ctx), event: event}
+	var errs []error
+	for _, s := range subs {
+		if err := p.enqueue(ctx, s, q); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// enqueue applies the subscription's backpressure policy
+func (p *AsyncPublisher) enqueue(ctx context.Context, s *subscription, q queuedEvent) error {
+	if s.policy == BackpressureSample && len(s.queue) >= cap(s.queue)/2 {
+		if s.seen.Add(1)%s.rate != 0 {
+			p.drop(s, q.event)
+			return nil
+		}
+	}
+	select {
+	case s.queue <- q:
+		return nil
+	default:
+	}
+	if s.policy != BackpressureBlock {
+		p.drop(s, q.event)
+		return nil
+	}
+	select {
+	case s.queue <- q:
+		return nil
+	case <-ctx.Done():
+		p.drop(s, q.event)
+		return fmt.Errorf("event handler %s: %w", s.name, ctx.Err())
+	case <-p.done:
+		p.drop(s, q.event)
+		return fmt.Errorf("event handler %s: %w", s.name, ErrPublisherClosed)
+	}
+}
+
+func (p *AsyncPublisher) drop(s *subscription, event Event) {
+	s.dropped.Add(1)
+	if p.config.OnDrop != nil {
+		p.config.OnDrop(s.name, event)
+	}
+}
+
+// work runs a subscription's handler until its queue is closed
+func (p *AsyncPublisher) work(s *subscription) {
+	defer s.wg.Done()
+	for q := range s.queue {
+		if err := s.handle(q); err != nil {
+			s.failed.Add(1)
+			if p.config.OnError != nil {
+				p.config.OnError(&HandlerError{Handler: s.name, Event: q.event, Err: err})
+			}
+			continue
+		}
+		s.delivered.Add(1)
+	}
+}
+
+// handle calls the handler, turning a panic into an error
+func (s *subscription) handle(q queuedEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	ctx := q.ctx
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	return s.handler.Handle(ctx, q.event)
+}
+
+// Stats returns the delivery counters of every handler
+func (p *AsyncPublisher) Stats() []HandlerStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	stats := make([]HandlerStats, len(p.subs))
+	for i, s := range p.subs {
+		stats[i] = HandlerStats{
+			Name:      s.name,
+			Queued:    len(s.queue),
+			Delivered: s.delivered.Load(),
+			Failed:    s.failed.Load(),
+			Dropped:   s.dropped.Load(),
+		}
+	}
+	return stats
+}
+
+// Close stops accepting events and waits until the queued events are
+// handled or ctx is done. Publishers waiting for queue space are released
+// with ErrPublisherClosed. Closing again waits for the same drain.
+func (p *AsyncPublisher) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.done)
+		p.drained = make(chan struct{})
+		subs := p.subs
+		go func() {
+			// Queues are closed once no publisher can still send on them
+			p.inflight.Wait()
+			for _, s := range subs {
+				close(s.queue)
+			}
+			for _, s := range subs {
+				s.wg.Wait()
+			}
+			close(p.drained)
+		}()
+	}
+	drained := p.drained
+	p.mu.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,192 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAsyncPublisher(t *testing.T) {
+	t.Run("Slow Handler Does Not Stall Others", func(t *testing.T) {
+		var failures []*HandlerError
+		var mu sync.Mutex
+		p := NewAsyncPublisher(AsyncConfig{
+			Workers:   1,
+			QueueSize: 2,
+			Policy:    BackpressureDrop,
+			OnError: func(err *HandlerError) {
+				mu.Lock()
+				failures = append(failures, err)
+				mu.Unlock()
+			},
+		})
+		release := make(chan struct{})
+		p.SubscribeWith(ContextHandlerFunc(func(context.Context, Event) error {
+			<-release
+			return nil
+		}), HandlerOptions{Name: "slow"})
+		var fast atomic.Int32
+		p.SubscribeWith(ContextHandlerFunc(func(context.Context, Event) error {
+			fast.Add(1)
+			return nil
+		}), HandlerOptions{Name: "fast", QueueSize: 100})
+		p.SubscribeWith(ContextHandlerFunc(func(context.Context, Event) error {
+			panic("boom")
+		}), HandlerOptions{Name: "panicky", QueueSize: 100})
+
+		start := time.Now()
+		for i := 0; i < 10; i++ {
+			if err := p.PublishContext(context.Background(), NewEvent()); err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("publishing took %v behind a slow handler", elapsed)
+		}
+		close(release)
+		if err := p.Close(context.Background()); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+
+		if got := fast.Load(); got != 10 {
+			t.Errorf("fast handler saw %d events, want 10", got)
+		}
+		stats := p.Stats()
+		if stats[0].Name != "slow" || stats[0].Dropped == 0 || stats[0].Delivered+stats[0].Dropped != 10 {
+			t.Errorf("slow handler stats = %+v", stats[0])
+		}
+		if stats[2].Failed != 10 || len(failures) != 10 || failures[0].Handler != "panicky" {
+			t.Errorf("panics not isolated: stats %+v, %d failures", stats[2], len(failures))
+		}
+		if err := p.PublishContext(context.Background(), NewEvent()); !errors.Is(err, ErrPublisherClosed) {
+			t.Errorf("publish after close = %v", err)
+		}
+	})
+
+	t.Run("Block Delivers Every Event", func(t *testing.T) {
+		p := NewAsyncPublisher(AsyncConfig{Workers: 2, QueueSize: 1})
+		var n atomic.Int32
+		p.Subscribe(LegacyHandler(ContextHandlerFunc(func(context.Context, Event) error {
+			time.Sleep(time.Millisecond)
+			n.Add(1)
+			return nil
+		})))
+		for i := 0; i < 20; i++ {
+			p.Publish(NewEvent())
+		}
+		if err := p.Close(context.Background()); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		if n.Load() != 20 {
+			t.Errorf("handled %d events, want 20", n.Load())
+		}
+	})
+
+	t.Run("Block Honors Context", func(t *testing.T) {
+		p := NewAsyncPublisher(AsyncConfig{Workers: 1, QueueSize: 1})
+		release := make(chan struct{})
+		p.SubscribeContext(ContextHandlerFunc(func(context.Context, Event) error {
+			<-release
+			return nil
+		}))
+		defer func() {
+			close(release)
+			_ = p.Close(context.Background())
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		var err error
+		for i := 0; i < 3 && err == nil; i++ {
+			err = p.PublishContext(ctx, NewEvent())
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected DeadlineExceeded, got %v", err)
+		}
+	})
+
+	t.Run("Close With Stuck Handler", func(t *testing.T) {
+		p := NewAsyncPublisher(AsyncConfig{Workers: 1, QueueSize: 1})
+		release := make(chan struct{})
+		defer close(release)
+		p.SubscribeContext(ContextHandlerFunc(func(context.Context, Event) error {
+			<-release
+			return nil
+		}))
+		for i := 0; i < 2; i++ {
+			if err := p.PublishContext(context.Background(), NewEvent()); err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+		}
+
+		// A publisher blocked on the full queue must not hold up Close
+		blocked := make(chan error, 1)
+		go func() { blocked <- p.PublishContext(context.Background(), NewEvent()) }()
+		time.Sleep(20 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := p.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Close with a stuck handler = %v, want DeadlineExceeded", err)
+		}
+		select {
+		case err := <-blocked:
+			if !errors.Is(err, ErrPublisherClosed) {
+				t.Errorf("blocked publish = %v, want ErrPublisherClosed", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("blocked publisher was not released by Close")
+		}
+		if err := p.PublishContext(context.Background(), NewEvent()); !errors.Is(err, ErrPublisherClosed) {
+			t.Errorf("publish after close = %v", err)
+		}
+	})
+
+	t.Run("Publish Is Bounded", func(t *testing.T) {
+		p := NewAsyncPublisher(AsyncConfig{Workers: 1, QueueSize: 1, PublishTimeout: 20 * time.Millisecond})
+		release := make(chan struct{})
+		p.SubscribeContext(ContextHandlerFunc(func(context.Context, Event) error {
+			<-release
+			return nil
+		}))
+		defer func() {
+			close(release)
+			_ = p.Close(context.Background())
+		}()
+		start := time.Now()
+		for i := 0; i < 4; i++ {
+			p.Publish(NewEvent())
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Publish blocked for %v behind a full queue", elapsed)
+		}
+		if s := p.Stats()[0]; s.Dropped == 0 {
+			t.Errorf("expected events dropped after PublishTimeout, got %+v", s)
+		}
+	})
+
+	t.Run("Sample Thins A Backlog", func(t *testing.T) {
+		policy := BackpressureSample
+		p := NewAsyncPublisher(AsyncConfig{})
+		release := make(chan struct{})
+		p.SubscribeWith(ContextHandlerFunc(func(context.Context, Event) error {
+			<-release
+			return nil
+		}), HandlerOptions{Workers: 1, QueueSize: 100, Policy: &policy, SampleRate: 5})
+		for i := 0; i < 150; i++ {
+			p.Publish(NewEvent())
+		}
+		close(release)
+		_ = p.Close(context.Background())
+
+		s := p.Stats()[0]
+		// 50 fill half the queue (one taken by the blocked worker), then
+		// one in five of the rest
+		if s.Delivered < 60 || s.Delivered > 75 || s.Delivered+s.Dropped != 150 {
+			t.Errorf("sampled stats = %+v", s)
+		}
+	})
+}
@@ -1,22 +1,30 @@
 package events
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 // EventBus manages event publishing and subscriptions
 type EventBus struct {
-	handlers []EventHandler
+	handlers []ContextHandler
 	mu       sync.RWMutex
 }
 
 // NewEventBus creates a new event bus
 func NewEventBus() *EventBus {
 	return &EventBus{
-		handlers: make([]EventHandler, 0),
+		handlers: make([]ContextHandler, 0),
 	}
 }
 
 // Subscribe adds a new event handler
 func (bus *EventBus) Subscribe(handler EventHandler) {
+	bus.SubscribeContext(AdaptHandler(handler))
+}
+
+// SubscribeContext adds a context-aware event handler
+func (bus *EventBus) SubscribeContext(handler ContextHandler) {
 	bus.mu.Lock()
 	defer bus.mu.Unlock()
 	bus.handlers = append(bus.handlers, handler)
@@ -24,12 +32,16 @@ func (bus *EventBus) Subscribe(handler EventHandler) {
 
 // Publish sends an event to all subscribers
 func (bus *EventBus) Publish(event Event) {
+	_ = bus.PublishContext(context.Background(), event)
+}
+
+// PublishContext sends an event to all subscribers under ctx. Remaining
+// handlers are skipped once ctx is done; handler errors are joined.
+func (bus *EventBus) PublishContext(ctx context.Context, event Event) error {
 	bus.mu.RLock()
-	handlers := make([]EventHandler, len(bus.handlers))
+	handlers := make([]ContextHandler, len(bus.handlers))
 	copy(handlers, bus.handlers)
 	bus.mu.RUnlock()
 
-	for _, handler := range handlers {
-		handler.Handle(event)
-	}
+	return dispatch(ctx, handlers, event)
 }
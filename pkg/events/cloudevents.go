@@ -0,0 +1,369 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CloudEvents 1.0 constants
+const (
+	CloudEventsSpecVersion = "1.0"
+
+	// CloudEventsContentType is the media type of a structured CloudEvent
+	CloudEventsContentType = "application/cloudevents+json"
+
+	// CloudEventsBatchContentType is the media type of a JSON batch
+	CloudEventsBatchContentType = "application/cloudevents-batch+json"
+)
+
+// ErrInvalidCloudEvent indicates a CloudEvent that violates the spec or
+// cannot be mapped to an Event
+var ErrInvalidCloudEvent = errors.New("invalid cloud event")
+
+// PoAExtensions maps the power-of-attorney metadata keys of delegation
+// events to CloudEvents extension attributes, so brokers can filter on them
+var PoAExtensions = map[string]string{
+	"principal":   "poaprincipal",
+	"delegator":   "poadelegator",
+	"power_type":  "poapowertype",
+	"scope":       "poascope",
+	"valid_until": "poavaliduntil",
+}
+
+// CloudEventOptions configures the mapping between Events and CloudEvents
+type CloudEventOptions struct {
+	// Source identifies the producing instance (default: "/gauth")
+	Source string
+
+	// TypePrefix prefixes the CloudEvent type, which is
+	// <TypePrefix>.<type>.<action> (default: "foundation.gimel.gauth")
+	TypePrefix string
+
+	// Extensions maps metadata keys to extension attributes (default:
+	// PoAExtensions). Metadata stays in the data either way.
+	Extensions map[string]string
+}
+
+func (o CloudEventOptions) withDefaults() CloudEventOptions {
+	if o.Source == "" {
+		o.Source = "/gauth"
+	}
+	if o.TypePrefix == "" {
+		o.TypePrefix = "foundation.gimel.gauth"
+	}
+	if o.Extensions == nil {
+		o.Extensions = PoAExtensions
+	}
+	return o
+}
+
+// CloudEvent is a CloudEvents 1.0 event. Data is JSON when DataContentType
+// is a JSON media type, and Extensions hold the extension attributes, which
+// are top-level members in JSON.
+type CloudEvent struct {
+	SpecVersion     string
+	ID              string
+	Source          string
+	Type            string
+	Subject         string
+	Time            time.Time
+	DataContentType string
+	Data            []byte
+	Extensions      map[string]string
+}
+
+// Extension attributes for the Event fields that have no CloudEvents
+// counterpart
+const (
+	extStatus        = "gauthstatus"
+	extResource      = "gauthresource"
+	extCorrelationID = "correlationid"
+	extSchemaVersion = "gauthschemaversion"
+)
+
+// cloudEventData is the data of a GAuth CloudEvent
+type cloudEventData struct {
+	Status   string    `json:"status,omitempty"`
+	Resource string    `json:"resource,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Metadata *Metadata `json:"metadata,omitempty"`
+}
+
+// ToCloudEvent converts an event. Status, resource, correlation ID and
+// schema version become extension attributes, the first two as well as
+// data, for filtering.
+func ToCloudEvent(event Event, opts CloudEventOptions) (*CloudEvent, error) {
+	opts = opts.withDefaults()
+	data, err := json.Marshal(cloudEventData{
+		Status:   event.Status,
+		Resource: event.Resource,
+		Message:  event.Message,
+		Error:    event.Error,
+		Metadata: event.Metadata,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event %s: %w", event.ID, err)
+	}
+
+	ce := &CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              event.ID,
+		Source:          opts.Source,
+		Type:            opts.TypePrefix + "." + string(event.Type) + "." + event.Action,
+		Subject:         event.Subject,
+		Time:            event.Timestamp,
+		DataContentType: "application/json",
+		Data:            data,
+		Extensions:      map[string]string{},
+	}
+	setExt := func(name, value string) error {
+		if !validExtensionName(name) {
+			return fmt.Errorf("%w: extension name %q", ErrInvalidCloudEvent, name)
+		}
+		if value != "" {
+			ce.Extensions[name] = value
+		}
+		return nil
+	}
+	_ = setExt(extStatus, event.Status)
+	_ = setExt(extResource, event.Resource)
+	_ = setExt(extCorrelationID, event.CorrelationID)
+	if event.SchemaVersion > 0 {
+		_ = setExt(extSchemaVersion, strconv.Itoa(event.SchemaVersion))
+	}
+	if event.Metadata != nil {
+		for key, name := range opts.Extensions {
+			if v, ok := event.Metadata.Get(key); ok {
+				if err := setExt(name, v.ToString()); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return ce, nil
+}
+
+// FromCloudEvent converts a CloudEvent back into an event. Types outside
+// TypePrefix keep the whole CloudEvent type as the event type, and
+// extension attributes missing from the metadata are added as strings.
+func FromCloudEvent(ce *CloudEvent, opts CloudEventOptions) (Event, error) {
+	opts = opts.withDefaults()
+	if ce.SpecVersion != CloudEventsSpecVersion {
+		return Event{}, fmt.Errorf("%w: unsupported specversion %q", ErrInvalidCloudEvent, ce.SpecVersion)
+	}
+	if ce.ID == "" || ce.Source == "" || ce.Type == "" {
+		return Event{}, fmt.Errorf("%w: id, source and type are required", ErrInvalidCloudEvent)
+	}
+
+	event := Event{
+		ID:            ce.ID,
+		Type:          EventType(ce.Type),
+		Subject:       ce.Subject,
+		Timestamp:     ce.Time,
+		Status:        ce.Extensions[extStatus],
+		Resource:      ce.Extensions[extResource],
+		CorrelationID: ce.Extensions[extCorrelationID],
+	}
+	if v, ok := ce.Extensions[extSchemaVersion]; ok {
+		version, err := strconv.Atoi(v)
+		if err != nil || version < 1 {
+			return Event{}, fmt.Errorf("%w: schema version %q", ErrInvalidCloudEvent, v)
+		}
+		event.SchemaVersion = version
+	}
+	if rest, ok := strings.CutPrefix(ce.Type, opts.TypePrefix+"."); ok {
+		typ, action, _ := strings.Cut(rest, ".")
+		event.Type, event.Action = EventType(typ), action
+	}
+
+	if len(ce.Data) > 0 && isJSONContentType(ce.DataContentType) {
+		var data cloudEventData
+		if err := json.Unmarshal(ce.Data, &data); err != nil {
+			return Event{}, fmt.Errorf("%w: data: %v", ErrInvalidCloudEvent, err)
+		}
+		if data.Status != "" {
+			event.Status = data.Status
+		}
+		if data.Resource != "" {
+			event.Resource = data.Resource
+		}
+		event.Message, event.Error, event.Metadata = data.Message, data.Error, data.Metadata
+	}
+	if event.Metadata == nil {
+		event.Metadata = NewMetadata()
+	}
+
+	known := map[string]string{}
+	for key, name := range opts.Extensions {
+		known[name] = key
+	}
+	for name, value := range ce.Extensions {
+		switch name {
+		case extStatus, extResource, extCorrelationID, extSchemaVersion:
+			continue
+		}
+		key := name
+		if k, ok := known[name]; ok {
+			key = k
+		}
+		if !event.Metadata.Has(key) {
+			event.Metadata.SetString(key, value)
+		}
+	}
+	return event, nil
+}
+
+// MarshalCloudEvent encodes an event in the structured CloudEvents JSON
+// format
+func MarshalCloudEvent(event Event, opts CloudEventOptions) ([]byte, error) {
+	ce, err := ToCloudEvent(event, opts)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(ce)
+}
+
+// UnmarshalCloudEvent decodes an event from the structured CloudEvents JSON
+// format
+func UnmarshalCloudEvent(data []byte, opts CloudEventOptions) (Event, error) {
+	var ce CloudEvent
+	if err := json.Unmarshal(data, &ce); err != nil {
+		return Event{}, err
+	}
+	return FromCloudEvent(&ce, opts)
+}
+
+// cloudEventAttributes are the context attributes with a fixed meaning;
+// every other top-level member is an extension
+var cloudEventAttributes = map[string]bool{
+	"specversion": true, "id": true, "source": true, "type": true, "subject": true,
+	"time": true, "datacontenttype": true, "dataschema": true, "data": true, "data_base64": true,
+}
+
+// MarshalJSON implements json.Marshaler
+func (ce *CloudEvent) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(ce.Extensions)+8)
+	for name, value := range ce.Extensions {
+		m[name] = value
+	}
+	m["specversion"] = ce.SpecVersion
+	m["id"] = ce.ID
+	m["source"] = ce.Source
+	m["type"] = ce.Type
+	if ce.Subject != "" {
+		m["subject"] = ce.Subject
+	}
+	if !ce.Time.IsZero() {
+		m["time"] = ce.Time.UTC().Format(time.RFC3339Nano)
+	}
+	if ce.DataContentType != "" {
+		m["datacontenttype"] = ce.DataContentType
+	}
+	if len(ce.Data) > 0 {
+		if isJSONContentType(ce.DataContentType) {
+			m["data"] = json.RawMessage(ce.Data)
+		} else {
+			m["data_base64"] = ce.Data
+		}
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (ce *CloudEvent) UnmarshalJSON(data []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCloudEvent, err)
+	}
+	str := func(name string) (string, error) {
+		raw, ok := m[name]
+		if !ok {
+			return "", nil
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "", fmt.Errorf("%w: %s is not a string", ErrInvalidCloudEvent, name)
+		}
+		return s, nil
+	}
+
+	*ce = CloudEvent{Extensions: map[string]string{}}
+	var err error
+	for name, dst := range map[string]*string{
+		"specversion": &ce.SpecVersion, "id": &ce.ID, "source": &ce.Source, "type": &ce.Type,
+		"subject": &ce.Subject, "datacontenttype": &ce.DataContentType,
+	} {
+		if *dst, err = str(name); err != nil {
+			return err
+		}
+	}
+	if t, err := str("time"); err != nil {
+		return err
+	} else if t != "" {
+		if ce.Time, err = time.Parse(time.RFC3339Nano, t); err != nil {
+			return fmt.Errorf("%w: time: %v", ErrInvalidCloudEvent, err)
+		}
+	}
+	if raw, ok := m["data"]; ok {
+		if ce.DataContentType == "" {
+			ce.DataContentType = "application/json"
+		}
+		if isJSONContentType(ce.DataContentType) {
+			ce.Data = raw
+		} else {
+			// non-JSON data in JSON is a string
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return fmt.Errorf("%w: data: %v", ErrInvalidCloudEvent, err)
+			}
+			ce.Data = []byte(s)
+		}
+	} else if raw, ok := m["data_base64"]; ok {
+		var b []byte
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return fmt.Errorf("%w: data_base64: %v", ErrInvalidCloudEvent, err)
+		}
+		ce.Data = b
+	}
+
+	for name, raw := range m {
+		if cloudEventAttributes[name] {
+			continue
+		}
+		// extension values may be any JSON scalar; keep their text form
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			s = string(raw)
+		}
+		ce.Extensions[name] = s
+	}
+	return nil
+}
+
+// isJSONContentType reports whether data of the content type is embedded
+// as JSON rather than base64
+func isJSONContentType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	return mediaType == "" || mediaType == "application/json" || mediaType == "text/json" ||
+		strings.HasSuffix(mediaType, "+json")
+}
+
+// validExtensionName reports whether name is a valid CloudEvents attribute
+// name: lowercase letters and digits only
+func validExtensionName(name string) bool {
+	if name == "" || cloudEventAttributes[name] {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,301 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CloudEventMode is the content mode of the CloudEvents HTTP binding
+type CloudEventMode int
+
+const (
+	// CloudEventStructured sends the whole event as the JSON body
+	CloudEventStructured CloudEventMode = iota
+
+	// CloudEventBinary sends the attributes as ce-* headers and the data as
+	// the body
+	CloudEventBinary
+)
+
+// maxCloudEventBody bounds the request bodies a CloudEventReceiver reads
+const maxCloudEventBody = 4 << 20
+
+// CloudEventSenderConfig configures a CloudEventSender
+type CloudEventSenderConfig struct {
+	// URL receives the events, such as a Knative broker or an EventBridge
+	// API destination
+	URL string
+
+	// Mode is the content mode (default: CloudEventStructured)
+	Mode CloudEventMode
+
+	// Options configure the event mapping
+	Options CloudEventOptions
+
+	// Header is added to every request, for authentication (optional)
+	Header http.Header
+
+	// Client sends the requests (default: http.Client with 10s timeout)
+	Client *http.Client
+}
+
+// CloudEventSender publishes events as CloudEvents over HTTP
+type CloudEventSender struct {
+	config CloudEventSenderConfig
+}
+
+var _ Publisher = (*CloudEventSender)(nil)
+
+// NewCloudEventSender creates a CloudEvents HTTP sender
+func NewCloudEventSender(config CloudEventSenderConfig) (*CloudEventSender, error) {
+	if config.URL == "" {
+		return nil, errors.New("cloud event sender needs a URL")
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &CloudEventSender{config: config}, nil
+}
+
+// Publish sends an event; the error is dropped
+func (s *CloudEventSender) Publish(event Event) {
+	_ = s.PublishContext(context.Background(), event)
+}
+
+// PublishContext sends an event and fails unless the receiver answers 2xx
+func (s *CloudEventSender) PublishContext(ctx context.Context, event Event) error {
+	if event.CorrelationID == "" {
+		event = event.WithContext(ctx)
+	}
+	req, err := NewCloudEventRequest(ctx, s.config.URL, event, s.config.Mode, s.config.Options)
+	if err != nil {
+		return err
+	}
+	for name, values := range s.config.Header {
+		req.Header[name] = values
+	}
+
+	resp, err := s.config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send event %s: %w", event.ID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to send event %s: %s: %s", event.ID, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// NewCloudEventRequest builds a POST request carrying the event in the
+// given content mode
+func NewCloudEventRequest(ctx context.Context, url string, event Event, mode CloudEventMode, opts CloudEventOptions) (*http.Request, error) {
+	ce, err := ToCloudEvent(event, opts)
+	if err != nil {
+		return nil, err
+	}
+	var body []byte
+	header := http.Header{}
+	if mode == CloudEventBinary {
+		body = ce.Data
+		header.Set("Content-Type", ce.DataContentType)
+		header.Set("ce-specversion", ce.SpecVersion)
+		header.Set("ce-id", encodeHeaderValue(ce.ID))
+		header.Set("ce-source", encodeHeaderValue(ce.Source))
+		header.Set("ce-type", encodeHeaderValue(ce.Type))
+		if ce.Subject != "" {
+			header.Set("ce-subject", encodeHeaderValue(ce.Subject))
+		}
+		if !ce.Time.IsZero() {
+			header.Set("ce-time", ce.Time.UTC().Format(time.RFC3339Nano))
+		}
+		for name, value := range ce.Extensions {
+			header.Set("ce-"+name, encodeHeaderValue(value))
+		}
+	} else {
+		if body, err = json.Marshal(ce); err != nil {
+			return nil, fmt.Errorf("failed to encode event %s: %w", event.ID, err)
+		}
+		header.Set("Content-Type", CloudEventsContentType)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	if event.CorrelationID != "" {
+		req.Header.Set(CorrelationHeader, event.CorrelationID)
+	}
+	return req, nil
+}
+
+// ReadCloudEvents reads the events of a request in any content mode:
+// structured, batched or binary
+func ReadCloudEvents(r *http.Request, opts CloudEventOptions) ([]Event, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch {
+	case mediaType == CloudEventsBatchContentType:
+		var batch []*CloudEvent
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCloudEvent, err)
+		}
+		events := make([]Event, 0, len(batch))
+		for _, ce := range batch {
+			event, err := FromCloudEvent(ce, opts)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+		}
+		return events, nil
+
+	case mediaType == CloudEventsContentType:
+		event, err := UnmarshalCloudEvent(body, opts)
+		if err != nil {
+			return nil, err
+		}
+		return []Event{event}, nil
+
+	case r.Header.Get("ce-specversion") != "":
+		ce := &CloudEvent{
+			DataContentType: r.Header.Get("Content-Type"),
+			Data:            body,
+			Extensions:      map[string]string{},
+		}
+		for name, values := range r.Header {
+			name = strings.ToLower(name)
+			attr, ok := strings.CutPrefix(name, "ce-")
+			if !ok || len(values) == 0 {
+				continue
+			}
+			value := decodeHeaderValue(values[0])
+			switch attr {
+			case "specversion":
+				ce.SpecVersion = value
+			case "id":
+				ce.ID = value
+			case "source":
+				ce.Source = value
+			case "type":
+				ce.Type = value
+			case "subject":
+				ce.Subject = value
+			case "time":
+				if ce.Time, err = time.Parse(time.RFC3339Nano, value); err != nil {
+					return nil, fmt.Errorf("%w: time: %v", ErrInvalidCloudEvent, err)
+				}
+			case "dataschema", "datacontenttype":
+			default:
+				ce.Extensions[attr] = value
+			}
+		}
+		event, err := FromCloudEvent(ce, opts)
+		if err != nil {
+			return nil, err
+		}
+		return []Event{event}, nil
+	}
+	return nil, fmt.Errorf("%w: not a cloud event request", ErrInvalidCloudEvent)
+}
+
+// CloudEventReceiver is an http.Handler that accepts CloudEvents in any
+// content mode and passes them to a handler. Handler errors answer 500, so
+// brokers such as Knative retry the delivery.
+type CloudEventReceiver struct {
+	handler ContextHandler
+	opts    CloudEventOptions
+}
+
+// NewCloudEventReceiver creates a CloudEvents HTTP receiver
+func NewCloudEventReceiver(handler ContextHandler, opts CloudEventOptions) *CloudEventReceiver {
+	return &CloudEventReceiver{handler: handler, opts: opts}
+}
+
+// ServeHTTP implements http.Handler
+func (rc *CloudEventReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxCloudEventBody)
+	events, err := ReadCloudEvents(r, rc.opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, event := range events {
+		ctx := r.Context()
+		if event.CorrelationID != "" {
+			ctx = ContextWithCorrelationID(ctx, event.CorrelationID)
+		}
+		if err := rc.handler.Handle(ctx, event); err != nil {
+			http.Error(w, fmt.Sprintf("event %s: %v", event.ID, err), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// encodeHeaderValue percent-encodes the characters the HTTP binding does
+// not allow verbatim in ce-* headers: space, '"', '%' and non-printable or
+// non-ASCII bytes
+func encodeHeaderValue(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c == '"' || c == '%' || c > '~' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// decodeHeaderValue reverses encodeHeaderValue; malformed escapes are kept
+// as they are
+func decodeHeaderValue(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			b.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	}
+	return c - '0'
+}
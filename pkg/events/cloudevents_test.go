@@ -0,0 +1,193 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func delegationEvent() Event {
+	return CreateEvent().
+		WithType(EventTypeAuthz).
+		WithActionEnum(ActionDelegationExercised).
+		WithStatusEnum(StatusSuccess).
+		WithSubject("agent 7").
+		WithResource("token-1").
+		WithMessage("exercised").
+		WithCorrelationID("flow-9").
+		WithStringMetadata("principal", "acme-corp").
+		WithStringMetadata("currency", "EUR").
+		WithIntMetadata("level", 2)
+}
+
+func TestCloudEventJSON(t *testing.T) {
+	event := delegationEvent()
+	event.Timestamp = time.Date(2025, 3, 1, 12, 0, 0, 5, time.UTC)
+
+	data, err := MarshalCloudEvent(event, CloudEventOptions{Source: "/gauth/test"})
+	if err != nil {
+		t.Fatalf("MarshalCloudEvent: %v", err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	for attr, want := range map[string]string{
+		"specversion":   "1.0",
+		"id":            event.ID,
+		"source":        "/gauth/test",
+		"type":          "foundation.gimel.gauth.authz.delegation_exercised",
+		"subject":       "agent 7",
+		"time":          "2025-03-01T12:00:00.000000005Z",
+		"gauthstatus":   "success",
+		"correlationid": "flow-9",
+		"poaprincipal":  "acme-corp",
+	} {
+		if raw[attr] != want {
+			t.Errorf("%s = %v, want %q", attr, raw[attr], want)
+		}
+	}
+	if _, ok := raw["data"].(map[string]interface{}); !ok {
+		t.Errorf("data is not embedded JSON: %v", raw["data"])
+	}
+
+	got, err := UnmarshalCloudEvent(data, CloudEventOptions{})
+	if err != nil {
+		t.Fatalf("UnmarshalCloudEvent: %v", err)
+	}
+	if got.ID != event.ID || got.Type != event.Type || got.Action != event.Action ||
+		got.Status != event.Status || got.Subject != event.Subject || got.Resource != event.Resource ||
+		got.Message != event.Message || got.CorrelationID != event.CorrelationID || !got.Timestamp.Equal(event.Timestamp) {
+		t.Errorf("round trip changed the event:\n got %+v\nwant %+v", got, event)
+	}
+	if v, _ := got.Metadata.GetString("principal"); v != "acme-corp" {
+		t.Errorf("principal = %q", v)
+	}
+	if v, _ := got.Metadata.GetInt("level"); v != 2 {
+		t.Errorf("level = %d", v)
+	}
+
+	t.Run("Foreign Events", func(t *testing.T) {
+		got, err := UnmarshalCloudEvent([]byte(`{"specversion":"1.0","id":"1","source":"s3","type":"aws.s3.put","region":"eu-west-1","data_base64":"aGk=","datacontenttype":"text/plain"}`), CloudEventOptions{})
+		if err != nil {
+			t.Fatalf("UnmarshalCloudEvent: %v", err)
+		}
+		if got.Type != "aws.s3.put" || got.Action != "" {
+			t.Errorf("type %q action %q", got.Type, got.Action)
+		}
+		if v, _ := got.Metadata.GetString("region"); v != "eu-west-1" {
+			t.Errorf("extension not kept as metadata: %q", v)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, doc := range []string{
+			`{"specversion":"0.3","id":"1","source":"s","type":"t"}`,
+			`{"specversion":"1.0","source":"s","type":"t"}`,
+			`{"specversion":"1.0","id":"1","source":"s","type":"t","time":"yesterday"}`,
+			`[]`,
+		} {
+			if _, err := UnmarshalCloudEvent([]byte(doc), CloudEventOptions{}); !errors.Is(err, ErrInvalidCloudEvent) {
+				t.Errorf("%s: got %v", doc, err)
+			}
+		}
+		if _, err := ToCloudEvent(event, CloudEventOptions{Extensions: map[string]string{"principal": "PoA-Principal"}}); !errors.Is(err, ErrInvalidCloudEvent) {
+			t.Errorf("invalid extension name accepted: %v", err)
+		}
+	})
+}
+
+func TestCloudEventHTTP(t *testing.T) {
+	var mu sync.Mutex
+	var received []Event
+	var correlation []string
+	fail := false
+	receiver := NewCloudEventReceiver(ContextHandlerFunc(func(ctx context.Context, e Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			return errors.New("downstream unavailable")
+		}
+		received = append(received, e)
+		correlation = append(correlation, CorrelationIDFromContext(ctx))
+		return nil
+	}), CloudEventOptions{})
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	for _, mode := range []CloudEventMode{CloudEventStructured, CloudEventBinary} {
+		sender, err := NewCloudEventSender(CloudEventSenderConfig{URL: srv.URL, Mode: mode})
+		if err != nil {
+			t.Fatalf("NewCloudEventSender: %v", err)
+		}
+		if err := sender.PublishContext(context.Background(), delegationEvent()); err != nil {
+			t.Fatalf("mode %d: PublishContext: %v", mode, err)
+		}
+	}
+	if len(received) != 2 {
+		t.Fatalf("received %d events, want 2", len(received))
+	}
+	for i, e := range received {
+		if e.Subject != "agent 7" || e.Action != string(ActionDelegationExercised) || correlation[i] != "flow-9" {
+			t.Errorf("event %d: %+v, correlation %q", i, e, correlation[i])
+		}
+		if v, _ := e.Metadata.GetString("principal"); v != "acme-corp" {
+			t.Errorf("event %d: principal = %q", i, v)
+		}
+	}
+
+	t.Run("Binary Headers", func(t *testing.T) {
+		req, err := NewCloudEventRequest(context.Background(), srv.URL, delegationEvent(), CloudEventBinary, CloudEventOptions{})
+		if err != nil {
+			t.Fatalf("NewCloudEventRequest: %v", err)
+		}
+		if got := req.Header.Get("ce-subject"); got != "agent%207" {
+			t.Errorf("ce-subject = %q", got)
+		}
+		if got := req.Header.Get("ce-poaprincipal"); got != "acme-corp" {
+			t.Errorf("ce-poaprincipal = %q", got)
+		}
+		if got := req.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q", got)
+		}
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		a, _ := ToCloudEvent(delegationEvent(), CloudEventOptions{})
+		b, _ := ToCloudEvent(NewTokenEvent(ActionTokenIssued, StatusSuccess), CloudEventOptions{})
+		body, _ := json.Marshal([]*CloudEvent{a, b})
+		resp, err := http.Post(srv.URL, CloudEventsBatchContentType, strings.NewReader(string(body)))
+		if err != nil {
+			t.Fatalf("post: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted || len(received) != 4 {
+			t.Errorf("status %d, %d events received", resp.StatusCode, len(received))
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		resp, err := http.Post(srv.URL, "application/json", strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatalf("post: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("non-cloud event: status %d", resp.StatusCode)
+		}
+
+		mu.Lock()
+		fail = true
+		mu.Unlock()
+		sender, _ := NewCloudEventSender(CloudEventSenderConfig{URL: srv.URL})
+		if err := sender.PublishContext(context.Background(), delegationEvent()); err == nil || !strings.Contains(err.Error(), "500") {
+			t.Errorf("handler failure not reported: %v", err)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ContextHandler handles an event under the publisher's context, so it can
+// honor cancellation, deadlines and the trace carried by ctx. It supersedes
+// EventHandler, which becomes events.Handler in the v2 module.
+type ContextHandler interface {
+	Handle(ctx context.Context, event Event) error
+}
+
+// ContextHandlerFunc adapts a function to ContextHandler
+type ContextHandlerFunc func(ctx context.Context, event Event) error
+
+// Handle implements ContextHandler
+func (f ContextHandlerFunc) Handle(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// AdaptHandler wraps a context-less EventHandler as a ContextHandler. The
+// event is skipped with ctx.Err() when ctx is already done.
+func AdaptHandler(h EventHandler) ContextHandler {
+	if l, ok := h.(*legacyHandler); ok {
+		return l.handler
+	}
+	return &adaptedHandler{handler: h}
+}
+
+type adaptedHandler struct {
+	handler EventHandler
+}
+
+func (a *adaptedHandler) Handle(ctx context.Context, event Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	a.handler.Handle(event)
+	return nil
+}
+
+// LegacyHandler wraps a ContextHandler for APIs that still take an
+// EventHandler. The handler runs under a background context carrying the
+// event's correlation ID; its error is dropped.
+func LegacyHandler(h ContextHandler) EventHandler {
+	if a, ok := h.(*adaptedHandler); ok {
+		return a.handler
+	}
+	return &legacyHandler{handler: h}
+}
+
+type legacyHandler struct {
+	handler ContextHandler
+}
+
+func (l *legacyHandler) Handle(event Event) {
+	ctx := context.Background()
+	if event.CorrelationID != "" {
+		ctx = ContextWithCorrelationID(ctx, event.CorrelationID)
+	}
+	_ = l.handler.Handle(ctx, event)
+}
+
+// TimeoutHandler bounds every call of h to d, on top of any deadline the
+// publisher set
+func TimeoutHandler(h ContextHandler, d time.Duration) ContextHandler {
+	return ContextHandlerFunc(func(ctx context.Context, event Event) error {
+		ctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		return h.Handle(ctx, event)
+	})
+}
+
+// dispatch runs handlers in order until ctx is done and joins their errors
+func dispatch(ctx context.Context, handlers []ContextHandler, event Event) error {
+	if event.CorrelationID == "" {
+		event = event.WithContext(ctx)
+	}
+	var errs []error
+	for _, h := range handlers {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if err := h.Handle(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
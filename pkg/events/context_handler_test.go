@@ -0,0 +1,118 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type recordingHandler struct {
+	events []Event
+}
+
+func (h *recordingHandler) Handle(event Event) {
+	h.events = append(h.events, event)
+}
+
+func TestContextHandlers(t *testing.T) {
+	t.Run("Handlers Receive Publisher Context", func(t *testing.T) {
+		bus := NewEventBus()
+		var got string
+		bus.SubscribeContext(ContextHandlerFunc(func(ctx context.Context, event Event) error {
+			got = CorrelationIDFromContext(ctx)
+			if event.CorrelationID != got {
+				t.Errorf("event correlation ID %q, context %q", event.CorrelationID, got)
+			}
+			return nil
+		}))
+
+		ctx := ContextWithCorrelationID(context.Background(), "corr-1")
+		if err := bus.PublishContext(ctx, NewSystemEvent(ActionSystemStartup, StatusSuccess)); err != nil {
+			t.Fatalf("PublishContext: %v", err)
+		}
+		if got != "corr-1" {
+			t.Errorf("handler saw correlation ID %q", got)
+		}
+	})
+
+	t.Run("Cancelled Context Stops Dispatch", func(t *testing.T) {
+		bus := NewEventBus()
+		legacy := &recordingHandler{}
+		ctx, cancel := context.WithCancel(context.Background())
+		bus.SubscribeContext(ContextHandlerFunc(func(context.Context, Event) error {
+			cancel()
+			return nil
+		}))
+		bus.Subscribe(legacy)
+
+		err := bus.PublishContext(ctx, NewEvent())
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+		if len(legacy.events) != 0 {
+			t.Error("handler after cancellation should not run")
+		}
+	})
+
+	t.Run("Handler Errors Are Joined", func(t *testing.T) {
+		errA, errB := errors.New("a"), errors.New("b")
+		p := NewEventPublisher()
+		p.SubscribeContext(ContextHandlerFunc(func(context.Context, Event) error { return errA }))
+		p.SubscribeContext(ContextHandlerFunc(func(context.Context, Event) error { return errB }))
+
+		err := p.PublishContext(context.Background(), NewEvent())
+		if !errors.Is(err, errA) || !errors.Is(err, errB) {
+			t.Errorf("expected both handler errors, got %v", err)
+		}
+	})
+
+	t.Run("Legacy Publish Still Works", func(t *testing.T) {
+		p := NewEventPublisher()
+		legacy := &recordingHandler{}
+		called := false
+		p.Subscribe(legacy)
+		p.SubscribeContext(ContextHandlerFunc(func(context.Context, Event) error {
+			called = true
+			return errors.New("ignored")
+		}))
+
+		p.Publish(NewEvent())
+		if len(legacy.events) != 1 || !called {
+			t.Errorf("expected both handlers to run, legacy=%d context=%v", len(legacy.events), called)
+		}
+	})
+
+	t.Run("Adapters Round Trip", func(t *testing.T) {
+		legacy := &recordingHandler{}
+		if LegacyHandler(AdaptHandler(legacy)) != EventHandler(legacy) {
+			t.Error("LegacyHandler should unwrap an adapted handler")
+		}
+
+		d := NewSimpleDispatcher()
+		var deadline bool
+		d.RegisterHandler(EventTypeAuth, LegacyHandler(ContextHandlerFunc(func(ctx context.Context, _ Event) error {
+			_, deadline = ctx.Deadline()
+			return nil
+		})))
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := d.DispatchContext(ctx, NewAuthEvent(ActionLogin, StatusSuccess)); err != nil {
+			t.Fatalf("DispatchContext: %v", err)
+		}
+		if !deadline {
+			t.Error("registered context handler should see the dispatch deadline")
+		}
+	})
+
+	t.Run("Timeout Handler", func(t *testing.T) {
+		h := TimeoutHandler(ContextHandlerFunc(func(ctx context.Context, _ Event) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}), 10*time.Millisecond)
+
+		if err := h.Handle(context.Background(), NewEvent()); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+	})
+}
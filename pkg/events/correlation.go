@@ -0,0 +1,57 @@
+package events
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// CorrelationHeader carries the correlation ID on HTTP requests and responses
+const CorrelationHeader = "X-Correlation-ID"
+
+type correlationKey struct{}
+
+// NewCorrelationID generates a new correlation ID
+func NewCorrelationID() string {
+	return uuid.New().String()
+}
+
+// ContextWithCorrelationID returns a context carrying the correlation ID
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID carried by ctx, if any
+func CorrelationIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// EnsureCorrelationID returns ctx unchanged if it already carries a
+// correlation ID, otherwise a context with a freshly generated one
+func EnsureCorrelationID(ctx context.Context) (context.Context, string) {
+	if id := CorrelationIDFromContext(ctx); id != "" {
+		return ctx, id
+	}
+	id := NewCorrelationID()
+	return ContextWithCorrelationID(ctx, id), id
+}
+
+// CorrelationMiddleware adopts the caller's X-Correlation-ID (or generates
+// one), stores it in the request context and echoes it on the response, so
+// every event and audit entry for the request shares one ID
+func CorrelationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if id := r.Header.Get(CorrelationHeader); id != "" && len(id) <= 128 {
+			ctx = ContextWithCorrelationID(ctx, id)
+		}
+		ctx, id := EnsureCorrelationID(ctx)
+		w.Header().Set(CorrelationHeader, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,43 @@
+package events
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCorrelationMiddleware(t *testing.T) {
+	var seen string
+	handler := CorrelationMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		seen = NewAuthzEvent(ActionAuthorizationGranted, StatusSuccess).WithContext(r.Context()).CorrelationID
+	}))
+
+	t.Run("Adopts Caller ID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(CorrelationHeader, "flow-42")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if seen != "flow-42" {
+			t.Errorf("Expected event correlation ID flow-42, got %q", seen)
+		}
+		if got := rec.Header().Get(CorrelationHeader); got != "flow-42" {
+			t.Errorf("Expected response header flow-42, got %q", got)
+		}
+	})
+
+	t.Run("Generates ID", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if seen == "" || rec.Header().Get(CorrelationHeader) != seen {
+			t.Errorf("Expected generated ID echoed in response, got %q and %q", seen, rec.Header().Get(CorrelationHeader))
+		}
+	})
+
+	t.Run("Ensure Keeps Existing", func(t *testing.T) {
+		ctx := ContextWithCorrelationID(context.Background(), "existing")
+		if _, id := EnsureCorrelationID(ctx); id != "existing" {
+			t.Errorf("Expected existing ID, got %q", id)
+		}
+	})
+}
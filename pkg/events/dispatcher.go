@@ -2,6 +2,7 @@
 package events
 
 import (
+	"context"
 	"sync"
 )
 
@@ -51,6 +52,22 @@ func (d *SimpleDispatcher) Dispatch(event Event) {
 	}
 }
 
+// DispatchContext sends an event to all registered handlers under ctx,
+// skipping the remaining handlers once ctx is done
+func (d *SimpleDispatcher) DispatchContext(ctx context.Context, event Event) error {
+	d.mu.RLock()
+	var handlers []ContextHandler
+	for _, h := range d.handlers[string(event.Type)] {
+		handlers = append(handlers, AdaptHandler(h))
+	}
+	for _, h := range d.handlers["*"] {
+		handlers = append(handlers, AdaptHandler(h))
+	}
+	d.mu.RUnlock()
+
+	return dispatch(ctx, handlers, event)
+}
+
 // RegisterHandler registers a handler for a specific event type
 func (d *SimpleDispatcher) RegisterHandler(eventType EventType, handler EventHandler) {
 	d.mu.Lock()
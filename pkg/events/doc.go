@@ -88,6 +88,47 @@ Implement the EventHandler interface to process events:
 		// Process the event
 	}
 
+Handlers that need the caller's deadline, cancellation or trace implement
+ContextHandler and are published to with PublishContext:
+
+	bus.SubscribeContext(events.ContextHandlerFunc(func(ctx context.Context, e events.Event) error {
+		return sink.Write(ctx, e)
+	}))
+	err := bus.PublishContext(ctx, event)
+
+Asynchronous Publishing:
+
+AsyncPublisher queues events for each handler in a bounded queue served by
+its own workers, so a slow handler cannot stall the publisher. Full queues
+block, drop or sample according to the BackpressurePolicy, and handler
+errors and panics are isolated and reported to OnError:
+
+	p := events.NewAsyncPublisher(events.AsyncConfig{Policy: events.BackpressureDrop})
+	p.SubscribeContext(handler)
+	err := p.PublishContext(ctx, event)
+	defer p.Close(ctx)
+
+Durable Transports:
+
+Package events/transport implements Publisher on Redis Streams, NATS
+JetStream and Kafka, with consumer groups, at-least-once delivery and replay.
+
+CloudEvents:
+
+MarshalCloudEvent and UnmarshalCloudEvent convert events to and from the
+CloudEvents 1.0 JSON format, with power-of-attorney metadata as extension
+attributes. CloudEventSender and CloudEventReceiver implement the HTTP
+binding for Knative, EventBridge and other CloudEvents consumers.
+
+Schema Versions:
+
+SchemaRegistry validates event payloads against versioned schemas and
+migrates older events to the version a consumer expects, while consumers on
+older versions still accept newer events:
+
+	registry := events.NewDefaultSchemaRegistry()
+	handler = registry.Handler(2, handler)
+
 Event Dispatching:
 
 Use the Dispatcher to send events to registered handlers:
@@ -3,6 +3,7 @@
 package events
 
 import (
+	"context"
 	"time"
 )
 
@@ -54,6 +55,26 @@ func (e Event) WithMessage(message string) Event {
 	return e
 }
 
+// WithCorrelationID sets the correlation ID
+func (e Event) WithCorrelationID(id string) Event {
+	e.CorrelationID = id
+	return e
+}
+
+// WithSchemaVersion sets the payload schema version
+func (e Event) WithSchemaVersion(version int) Event {
+	e.SchemaVersion = version
+	return e
+}
+
+// WithContext copies the correlation ID carried by ctx onto the event
+func (e Event) WithContext(ctx context.Context) Event {
+	if id := CorrelationIDFromContext(ctx); id != "" {
+		e.CorrelationID = id
+	}
+	return e
+}
+
 // WithError adds an error to the event
 func (e Event) WithError(err error) Event {
 	if err != nil {
@@ -88,6 +88,12 @@ type Event struct {
 	Message   string    `json:"message,omitempty"`
 	Metadata  *Metadata `json:"metadata,omitempty"`
 	Error     string    `json:"error,omitempty"`
+
+	// CorrelationID ties together every event of one end-to-end flow
+	CorrelationID string `json:"correlation_id,omitempty"`
+
+	// SchemaVersion is the version of the payload schema; zero means 1
+	SchemaVersion int `json:"schema_version,omitempty"`
 }
 
 // NewEvent creates a basic event with required fields
@@ -2,28 +2,53 @@
 // This file implements the publisher functionality for events
 package events
 
+import "context"
+
+// Publisher is implemented by everything events are published to: the
+// synchronous EventPublisher and EventBus, AsyncPublisher and the durable
+// transports of package events/transport
+type Publisher interface {
+	Publish(event Event)
+	PublishContext(ctx context.Context, event Event) error
+}
+
+var (
+	_ Publisher = (*EventPublisher)(nil)
+	_ Publisher = (*EventBus)(nil)
+	_ Publisher = (*AsyncPublisher)(nil)
+)
+
 // EventPublisher manages event subscriptions and publishing
 type EventPublisher struct {
-	handlers []EventHandler
+	handlers []ContextHandler
 }
 
 // NewEventPublisher creates a new event publisher
 func NewEventPublisher() *EventPublisher {
 	return &EventPublisher{
-		handlers: make([]EventHandler, 0),
+		handlers: make([]ContextHandler, 0),
 	}
 }
 
 // Subscribe adds a new event handler
 func (p *EventPublisher) Subscribe(handler EventHandler) {
+	p.handlers = append(p.handlers, AdaptHandler(handler))
+}
+
+// SubscribeContext adds a context-aware event handler
+func (p *EventPublisher) SubscribeContext(handler ContextHandler) {
 	p.handlers = append(p.handlers, handler)
 }
 
 // Publish sends an event to all subscribed handlers
 func (p *EventPublisher) Publish(event Event) {
-	for _, handler := range p.handlers {
-		handler.Handle(event)
-	}
+	_ = p.PublishContext(context.Background(), event)
+}
+
+// PublishContext sends an event to all subscribed handlers under ctx.
+// Remaining handlers are skipped once ctx is done; handler errors are joined.
+func (p *EventPublisher) PublishContext(ctx context.Context, event Event) error {
+	return dispatch(ctx, p.handlers, event)
 }
 
 // DefaultPublisher is a singleton event publisher
@@ -34,7 +59,17 @@ func Subscribe(handler EventHandler) {
 	DefaultPublisher.Subscribe(handler)
 }
 
+// SubscribeContext adds a context-aware handler to the default publisher
+func SubscribeContext(handler ContextHandler) {
+	DefaultPublisher.SubscribeContext(handler)
+}
+
 // Publish sends an event to the default publisher
 func Publish(event Event) {
 	DefaultPublisher.Publish(event)
 }
+
+// PublishContext sends an event to the default publisher under ctx
+func PublishContext(ctx context.Context, event Event) error {
+	return DefaultPublisher.PublishContext(ctx, event)
+}
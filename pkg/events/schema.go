@@ -0,0 +1,291 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Schema registry errors
+var (
+	// ErrSchemaViolation indicates an event that does not match its schema
+	ErrSchemaViolation = errors.New("event violates its schema")
+
+	// ErrNoMigration indicates a version gap no Migrate hook bridges
+	ErrNoMigration = errors.New("no migration between schema versions")
+)
+
+// Schema describes one version of the payload of an event type and action.
+// The payload is the event's metadata.
+type Schema struct {
+	Type    EventType
+	Action  string
+	Version int
+
+	// Fields maps metadata keys to their MetadataType* constants; a key
+	// that is present must have its type
+	Fields map[string]string
+
+	// Required lists the metadata keys every event must carry
+	Required []string
+
+	// Migrate upgrades an event of this version to Version+1 (optional).
+	// It receives a copy and need not set SchemaVersion.
+	Migrate func(Event) (Event, error)
+}
+
+// schemaKey identifies the schemas of one event type and action
+type schemaKey struct {
+	typ    EventType
+	action string
+}
+
+// SchemaRegistry holds the versioned payload schemas of events. Producers
+// stamp events with a version; consumers resolve what they receive to the
+// version they were written for. Older events are migrated up through the
+// Migrate hooks, and events newer than any registered version are checked
+// against the newest one and passed on, since adding fields is
+// backward compatible. That lets producers and consumers upgrade in any
+// order.
+type SchemaRegistry struct {
+	mu      sync.RWMutex
+	schemas map[schemaKey]map[int]*Schema
+}
+
+// NewSchemaRegistry creates an empty schema registry
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{schemas: make(map[schemaKey]map[int]*Schema)}
+}
+
+// Register adds a schema version
+func (r *SchemaRegistry) Register(schema Schema) error {
+	if schema.Type == "" || schema.Action == "" {
+		return errors.New("schema needs an event type and action")
+	}
+	if schema.Version < 1 {
+		return fmt.Errorf("schema %s.%s: version must be at least 1", schema.Type, schema.Action)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := schemaKey{schema.Type, schema.Action}
+	if r.schemas[key] == nil {
+		r.schemas[key] = make(map[int]*Schema)
+	}
+	if _, exists := r.schemas[key][schema.Version]; exists {
+		return fmt.Errorf("schema %s.%s v%d already registered", schema.Type, schema.Action, schema.Version)
+	}
+	r.schemas[key][schema.Version] = &schema
+	return nil
+}
+
+// Versions returns the registered versions of an event type and action in
+// ascending order
+func (r *SchemaRegistry) Versions(typ EventType, action string) []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	versions := make([]int, 0, len(r.schemas[schemaKey{typ, action}]))
+	for v := range r.schemas[schemaKey{typ, action}] {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// Latest returns the newest registered version, or 0 if there is none
+func (r *SchemaRegistry) Latest(typ EventType, action string) int {
+	versions := r.Versions(typ, action)
+	if len(versions) == 0 {
+		return 0
+	}
+	return versions[len(versions)-1]
+}
+
+// Stamp sets the event's schema version to the latest registered one, for
+// producers; events without a schema are returned unchanged
+func (r *SchemaRegistry) Stamp(event Event) Event {
+	if latest := r.Latest(event.Type, event.Action); latest > 0 {
+		event.SchemaVersion = latest
+	}
+	return event
+}
+
+// Validate checks an event against the schema of its version, or the newest
+// registered version if its own is unknown. Events without a schema are
+// valid.
+func (r *SchemaRegistry) Validate(event Event) error {
+	schema := r.schemaFor(event)
+	if schema == nil {
+		return nil
+	}
+	return schema.validate(event)
+}
+
+// Resolve returns the event at target version; a target of 0 means the
+// newest registered version. Older events are validated and migrated step
+// by step. Events at or beyond target are returned as they are once they
+// satisfy the target schema, so a consumer still on an old version reads
+// newer events and ignores the fields it does not know.
+func (r *SchemaRegistry) Resolve(event Event, target int) (Event, error) {
+	versions := r.Versions(event.Type, event.Action)
+	if len(versions) == 0 {
+		return event, nil
+	}
+	if target == 0 {
+		target = versions[len(versions)-1]
+	}
+	version := versionOf(event)
+	if version >= target {
+		if schema := r.schema(event.Type, event.Action, target); schema != nil {
+			return event, schema.validate(event)
+		}
+		return event, r.Validate(event)
+	}
+
+	for version < target {
+		schema := r.schema(event.Type, event.Action, version)
+		if schema == nil || schema.Migrate == nil {
+			return Event{}, fmt.Errorf("%w: %s.%s v%d to v%d", ErrNoMigration, event.Type, event.Action, version, version+1)
+		}
+		if err := schema.validate(event); err != nil {
+			return Event{}, err
+		}
+		migrated, err := schema.Migrate(event.clone())
+		if err != nil {
+			return Event{}, fmt.Errorf("failed to migrate %s.%s v%d: %w", event.Type, event.Action, version, err)
+		}
+		version++
+		migrated.SchemaVersion = version
+		event = migrated
+	}
+	return event, r.Validate(event)
+}
+
+// Decode unmarshals an event from JSON and resolves it to target version
+func (r *SchemaRegistry) Decode(data []byte, target int) (Event, error) {
+	var event Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return Event{}, fmt.Errorf("failed to decode event: %w", err)
+	}
+	return r.Resolve(event, target)
+}
+
+// Handler wraps a handler so it receives events resolved to target version.
+// Events that cannot be resolved fail with the resolution error instead of
+// reaching the handler.
+func (r *SchemaRegistry) Handler(target int, handler ContextHandler) ContextHandler {
+	return ContextHandlerFunc(func(ctx context.Context, event Event) error {
+		resolved, err := r.Resolve(event, target)
+		if err != nil {
+			return err
+		}
+		return handler.Handle(ctx, resolved)
+	})
+}
+
+func (r *SchemaRegistry) schema(typ EventType, action string, version int) *Schema {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.schemas[schemaKey{typ, action}][version]
+}
+
+// schemaFor returns the schema of the event's version, falling back to the
+// newest version below it
+func (r *SchemaRegistry) schemaFor(event Event) *Schema {
+	versions := r.Versions(event.Type, event.Action)
+	version := versionOf(event)
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i] <= version {
+			return r.schema(event.Type, event.Action, versions[i])
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validate(event Event) error {
+	for _, key := range s.Required {
+		if event.Metadata == nil || !event.Metadata.Has(key) {
+			return fmt.Errorf("%w: %s.%s v%d requires %s", ErrSchemaViolation, s.Type, s.Action, s.Version, key)
+		}
+	}
+	for key, typ := range s.Fields {
+		if event.Metadata == nil {
+			break
+		}
+		if v, ok := event.Metadata.Get(key); ok && v.Type != typ {
+			return fmt.Errorf("%w: %s.%s v%d: %s is %s, want %s", ErrSchemaViolation, s.Type, s.Action, s.Version, key, v.Type, typ)
+		}
+	}
+	return nil
+}
+
+func versionOf(event Event) int {
+	if event.SchemaVersion < 1 {
+		return 1
+	}
+	return event.SchemaVersion
+}
+
+// clone copies an event with its own metadata, so migrations cannot change
+// the caller's event
+func (e Event) clone() Event {
+	if e.Metadata != nil {
+		m := NewMetadata()
+		for _, key := range e.Metadata.Keys() {
+			v, _ := e.Metadata.Get(key)
+			m.values[key] = v
+		}
+		e.Metadata = m
+	}
+	return e
+}
+
+// TokenIssued payload schemas. Version 1 carries the lifetime as
+// expires_in seconds; version 2 carries an absolute expires_at and may add
+// the granted scope.
+var (
+	TokenIssuedV1 = Schema{
+		Type:     EventTypeToken,
+		Action:   string(ActionTokenIssued),
+		Version:  1,
+		Fields:   map[string]string{"token_type": MetadataTypeString, "expires_in": MetadataTypeInt},
+		Required: []string{"token_type"},
+		Migrate:  migrateTokenIssuedV1,
+	}
+	TokenIssuedV2 = Schema{
+		Type:     EventTypeToken,
+		Action:   string(ActionTokenIssued),
+		Version:  2,
+		Fields:   map[string]string{"token_type": MetadataTypeString, "expires_at": MetadataTypeTime, "scope": MetadataTypeString},
+		Required: []string{"token_type", "expires_at"},
+	}
+)
+
+// migrateTokenIssuedV1 turns expires_in into expires_at, counted from the
+// event timestamp
+func migrateTokenIssuedV1(event Event) (Event, error) {
+	v, ok := event.Metadata.Get("expires_in")
+	if !ok {
+		return event, errors.New("expires_in is required to derive expires_at")
+	}
+	seconds, err := v.ToInt()
+	if err != nil {
+		return event, err
+	}
+	event.Metadata.Delete("expires_in")
+	event.Metadata.SetTime("expires_at", event.Timestamp.Add(time.Duration(seconds)*time.Second))
+	return event, nil
+}
+
+// NewDefaultSchemaRegistry creates a registry with the schemas of the
+// built-in events
+func NewDefaultSchemaRegistry() *SchemaRegistry {
+	r := NewSchemaRegistry()
+	for _, s := range []Schema{TokenIssuedV1, TokenIssuedV2} {
+		_ = r.Register(s)
+	}
+	return r
+}
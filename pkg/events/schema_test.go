@@ -0,0 +1,111 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func tokenIssuedV1(expiresIn int) Event {
+	return CreateTokenEvent(ActionTokenIssued, StatusSuccess).
+		WithStringMetadata("token_type", "access_token").
+		WithIntMetadata("expires_in", expiresIn)
+}
+
+func TestSchemaRegistry(t *testing.T) {
+	r := NewDefaultSchemaRegistry()
+	if err := r.Register(TokenIssuedV2); err == nil {
+		t.Error("duplicate version registered")
+	}
+	if got := r.Versions(EventTypeToken, string(ActionTokenIssued)); len(got) != 2 || got[1] != 2 {
+		t.Errorf("Versions = %v", got)
+	}
+
+	t.Run("Migrates Old Events", func(t *testing.T) {
+		v1 := tokenIssuedV1(3600)
+		v1.Timestamp = time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+		got, err := r.Resolve(v1, 0)
+		if err != nil {
+			t.Fatalf("Resolve: %v", err)
+		}
+		if got.SchemaVersion != 2 {
+			t.Errorf("SchemaVersion = %d", got.SchemaVersion)
+		}
+		if at, err := got.Metadata.GetTime("expires_at"); err != nil || !at.Equal(v1.Timestamp.Add(time.Hour)) {
+			t.Errorf("expires_at = %v, %v", at, err)
+		}
+		if got.Metadata.Has("expires_in") || !v1.Metadata.Has("expires_in") {
+			t.Error("migration must replace expires_in on a copy only")
+		}
+	})
+
+	t.Run("Old Consumers Read New Events", func(t *testing.T) {
+		v2 := r.Stamp(CreateTokenEvent(ActionTokenIssued, StatusSuccess).
+			WithStringMetadata("token_type", "access_token").
+			WithTimeMetadata("expires_at", time.Now().Add(time.Hour)).
+			WithStringMetadata("scope", "read write").
+			WithStringMetadata("added_in_v3", "x"))
+		data, _ := json.Marshal(v2.WithSchemaVersion(3))
+
+		// a consumer that only knows v1
+		old := NewSchemaRegistry()
+		_ = old.Register(TokenIssuedV1)
+		got, err := old.Decode(data, 1)
+		if err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		if got.SchemaVersion != 3 || !got.Metadata.Has("added_in_v3") {
+			t.Errorf("newer event changed: %+v", got)
+		}
+	})
+
+	t.Run("Violations", func(t *testing.T) {
+		bad := tokenIssuedV1(60)
+		bad.Metadata.SetString("expires_in", "60")
+		if _, err := r.Resolve(bad, 0); !errors.Is(err, ErrSchemaViolation) {
+			t.Errorf("wrong field type: %v", err)
+		}
+		missing := CreateTokenEvent(ActionTokenIssued, StatusSuccess).WithSchemaVersion(2)
+		if err := r.Validate(missing); !errors.Is(err, ErrSchemaViolation) {
+			t.Errorf("missing required field: %v", err)
+		}
+
+		gap := NewSchemaRegistry()
+		_ = gap.Register(Schema{Type: EventTypeToken, Action: string(ActionTokenIssued), Version: 1})
+		_ = gap.Register(Schema{Type: EventTypeToken, Action: string(ActionTokenIssued), Version: 2})
+		if _, err := gap.Resolve(tokenIssuedV1(60), 2); !errors.Is(err, ErrNoMigration) {
+			t.Errorf("missing migration: %v", err)
+		}
+	})
+
+	t.Run("Handler", func(t *testing.T) {
+		var seen []int
+		h := r.Handler(2, ContextHandlerFunc(func(_ context.Context, e Event) error {
+			seen = append(seen, e.SchemaVersion)
+			return nil
+		}))
+		if err := h.Handle(context.Background(), tokenIssuedV1(60)); err != nil {
+			t.Errorf("Handle: %v", err)
+		}
+		// stamped v2 without expires_at never reaches the handler
+		if err := h.Handle(context.Background(), r.Stamp(tokenIssuedV1(60))); !errors.Is(err, ErrSchemaViolation) {
+			t.Errorf("Handle invalid: %v", err)
+		}
+		if len(seen) != 1 || seen[0] != 2 {
+			t.Errorf("handler saw versions %v", seen)
+		}
+	})
+
+	t.Run("CloudEvents Carry The Version", func(t *testing.T) {
+		data, err := MarshalCloudEvent(tokenIssuedV1(60).WithSchemaVersion(1), CloudEventOptions{})
+		if err != nil {
+			t.Fatalf("MarshalCloudEvent: %v", err)
+		}
+		got, err := UnmarshalCloudEvent(data, CloudEventOptions{})
+		if err != nil || got.SchemaVersion != 1 {
+			t.Errorf("version %d, %v", got.SchemaVersion, err)
+		}
+	})
+}
@@ -0,0 +1,361 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/internal/natsconn"
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// JetStreamConfig configures a JetStream transport
+type JetStreamConfig struct {
+	// Address is the host:port of a NATS server with JetStream enabled
+	Address string
+
+	// Stream is the JetStream stream name (default: "GAUTH_EVENTS")
+	Stream string
+
+	// Subject prefixes the subjects; events are published to
+	// <Subject>.<type> (default: "gauth.events")
+	Subject string
+
+	// CreateStream creates the stream on <Subject>.> if it does not exist;
+	// otherwise it must be provisioned beforehand
+	CreateStream bool
+
+	// MaxAge limits how long a created stream keeps events (default: none)
+	MaxAge time.Duration
+
+	// User and Password, or Token, authenticate the connection (optional)
+	User     string
+	Password string
+	Token    string
+
+	// TLS is used when the server requires TLS (optional)
+	TLS *tls.Config
+
+	// Timeout bounds dialing and each API request (default: 5s)
+	Timeout time.Duration
+
+	// FromBeginning makes a new consumer group start with the oldest
+	// retained event instead of the next one published
+	FromBeginning bool
+
+	// BatchSize is the most events fetched per request (default: 100)
+	BatchSize int
+
+	// Wait is how long a fetch waits for new events (default: 5s)
+	Wait time.Duration
+
+	// RetryAfter is how long a failed or unacknowledged event waits before
+	// it is redelivered (default: 30s)
+	RetryAfter time.Duration
+
+	// OnError receives handler and decoding failures (optional)
+	OnError func(error)
+}
+
+// JetStream is a Transport on a NATS JetStream stream, spoken over the NATS
+// text protocol. Consumer groups are durable pull consumers named after the
+// group. Publishes carry the event ID as Nats-Msg-Id, so the server drops
+// duplicates of retried publishes.
+type JetStream struct {
+	config JetStreamConfig
+	mu     sync.Mutex
+	conn   *natsconn.Conn
+	ready  bool
+}
+
+var _ Transport = (*JetStream)(nil)
+
+// NewJetStream creates a JetStream transport; it connects on first use
+func NewJetStream(config JetStreamConfig) (*JetStream, error) {
+	if config.Address == "" {
+		return nil, errors.New("jetstream transport needs an address")
+	}
+	if config.Stream == "" {
+		config.Stream = "GAUTH_EVENTS"
+	}
+	if config.Subject == "" {
+		config.Subject = "gauth.events"
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.Wait <= 0 {
+		config.Wait = 5 * time.Second
+	}
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = 30 * time.Second
+	}
+	return &JetStream{config: config}, nil
+}
+
+// jsAPIError is the error member of JetStream API responses
+type jsAPIError struct {
+	Code        int    `json:"code"`
+	ErrCode     int    `json:"err_code"`
+	Description string `json:"description"`
+}
+
+func (e *jsAPIError) Error() string {
+	return fmt.Sprintf("jetstream error %d: %s", e.Code, e.Description)
+}
+
+// errStreamExists is the err_code of creating a stream that exists
+const errStreamExists = 10058
+
+// Publish implements Transport
+func (j *JetStream) Publish(ctx context.Context, event events.Event) error {
+	data, err := encode(event)
+	if err != nil {
+		return err
+	}
+	c, err := j.connection(ctx)
+	if err != nil {
+		return err
+	}
+	subject := j.config.Subject + "." + natsconn.SubjectToken(string(event.Type))
+	header := "Nats-Msg-Id: " + event.ID
+	var ack struct {
+		Error *jsAPIError `json:"error"`
+	}
+	if err := j.request(ctx, c, subject, header, data, &ack); err != nil {
+		return fmt.Errorf("failed to publish event %s: %w", event.ID, err)
+	}
+	if ack.Error != nil {
+		return fmt.Errorf("failed to publish event %s: %w", event.ID, ack.Error)
+	}
+	return nil
+}
+
+// Consume implements Transport. A failed event is negatively acknowledged
+// and redelivered after RetryAfter.
+func (j *JetStream) Consume(ctx context.Context, group string, handler events.ContextHandler) error {
+	policy := "new"
+	if j.config.FromBeginning {
+		policy = "all"
+	}
+	c, err := j.connection(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = j.createConsumer(ctx, c, "$JS.API.CONSUMER.CREATE."+j.config.Stream+"."+group, map[string]interface{}{
+		"durable_name":   group,
+		"deliver_policy": policy,
+		"ack_policy":     "explicit",
+		"ack_wait":       j.config.RetryAfter.Nanoseconds(),
+		"filter_subject": j.config.Subject + ".>",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create consumer group %s: %w", group, err)
+	}
+
+	next := "$JS.API.CONSUMER.MSG.NEXT." + j.config.Stream + "." + group
+	request, _ := json.Marshal(map[string]interface{}{
+		"batch":   j.config.BatchSize,
+		"expires": j.config.Wait.Nanoseconds(),
+	})
+	nak := []byte(fmt.Sprintf(`-NAK {"delay":%d}`, j.config.RetryAfter.Nanoseconds()))
+	for ctx.Err() == nil {
+		if c, err = j.connection(ctx); err != nil {
+			return err
+		}
+		err := c.Fetch(ctx, next, "", request, j.config.BatchSize, j.config.Wait+j.config.Timeout, func(m natsconn.Msg) {
+			event, err := decode(m.Data)
+			if err == nil {
+				if err = handle(ctx, handler, event); err == nil {
+					_ = c.Publish(m.Reply, "", []byte("+ACK"))
+					return
+				}
+				_ = c.Publish(m.Reply, "", nak)
+			} else {
+				_ = c.Publish(m.Reply, "", []byte("+TERM"))
+			}
+			j.report(fmt.Errorf("event on %s in group %s: %w", m.Subject, group, err))
+		})
+		if err != nil && ctx.Err() == nil {
+			j.drop(c)
+			return fmt.Errorf("failed to fetch events: %w", err)
+		}
+	}
+	return ctx.Err()
+}
+
+// Replay implements Transport with an ephemeral consumer starting at since,
+// which the server deletes when the replay ends
+func (j *JetStream) Replay(ctx context.Context, since time.Time, handler events.ContextHandler) error {
+	c, err := j.connection(ctx)
+	if err != nil {
+		return err
+	}
+	name, err := j.createConsumer(ctx, c, "$JS.API.CONSUMER.CREATE."+j.config.Stream, map[string]interface{}{
+		"deliver_policy":     "by_start_time",
+		"opt_start_time":     since.UTC().Format(time.RFC3339Nano),
+		"ack_policy":         "none",
+		"filter_subject":     j.config.Subject + ".>",
+		"inactive_threshold": time.Minute.Nanoseconds(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create replay consumer: %w", err)
+	}
+	defer func() {
+		var deleted struct{}
+		_ = j.request(context.Background(), c, "$JS.API.CONSUMER.DELETE."+j.config.Stream+"."+name, "", nil, &deleted)
+	}()
+
+	next := "$JS.API.CONSUMER.MSG.NEXT." + j.config.Stream + "." + name
+	request, _ := json.Marshal(map[string]interface{}{"batch": j.config.BatchSize, "no_wait": true})
+	for {
+		var handlerErr error
+		n := 0
+		err := c.Fetch(ctx, next, "", request, j.config.BatchSize, j.config.Timeout, func(m natsconn.Msg) {
+			n++
+			if handlerErr != nil {
+				return
+			}
+			event, err := decode(m.Data)
+			if err != nil {
+				j.report(fmt.Errorf("event on %s: %w", m.Subject, err))
+				return
+			}
+			handlerErr = handle(ctx, handler, event)
+		})
+		if handlerErr != nil {
+			return handlerErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read events: %w", err)
+		}
+		if n == 0 {
+			return nil
+		}
+	}
+}
+
+// createConsumer sends a consumer create request and returns the consumer
+// name
+func (j *JetStream) createConsumer(ctx context.Context, c *natsconn.Conn, subject string, config map[string]interface{}) (string, error) {
+	var resp struct {
+		Name  string      `json:"name"`
+		Error *jsAPIError `json:"error"`
+	}
+	err := j.request(ctx, c, subject, "", mustJSON(map[string]interface{}{
+		"stream_name": j.config.Stream,
+		"config":      config,
+	}), &resp)
+	if err != nil {
+		return "", err
+	}
+	if resp.Error != nil {
+		return "", resp.Error
+	}
+	return resp.Name, nil
+}
+
+// Close implements Transport
+func (j *JetStream) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.conn != nil {
+		j.conn.Close()
+		j.conn = nil
+	}
+	return nil
+}
+
+func (j *JetStream) report(err error) {
+	if j.config.OnError != nil {
+		j.config.OnError(err)
+	}
+}
+
+// connection returns the live connection, dialing a new one if the last
+// broke, and creates the stream on first use if configured to
+func (j *JetStream) connection(ctx context.Context) (*natsconn.Conn, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.conn != nil && j.conn.Alive() {
+		return j.conn, nil
+	}
+	c, err := natsconn.Dial(ctx, natsconn.Config{
+		Address:  j.config.Address,
+		User:     j.config.User,
+		Password: j.config.Password,
+		Token:    j.config.Token,
+		TLS:      j.config.TLS,
+		Timeout:  j.config.Timeout,
+		Name:     "gauth-events",
+		Headers:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if j.config.CreateStream && !j.ready {
+		var resp struct {
+			Error *jsAPIError `json:"error"`
+		}
+		config := map[string]interface{}{
+			"name":     j.config.Stream,
+			"subjects": []string{j.config.Subject + ".>"},
+			"storage":  "file",
+		}
+		if j.config.MaxAge > 0 {
+			config["max_age"] = j.config.MaxAge.Nanoseconds()
+		}
+		err := j.request(ctx, c, "$JS.API.STREAM.CREATE."+j.config.Stream, "", mustJSON(config), &resp)
+		if err == nil && resp.Error != nil && resp.Error.ErrCode != errStreamExists {
+			err = resp.Error
+		}
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to create stream %s: %w", j.config.Stream, err)
+		}
+		j.ready = true
+	}
+	j.conn = c
+	return c, nil
+}
+
+// drop discards a connection after a protocol failure
+func (j *JetStream) drop(c *natsconn.Conn) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	c.Close()
+	if j.conn == c {
+		j.conn = nil
+	}
+}
+
+// request sends a request and decodes the single reply into out
+func (j *JetStream) request(ctx context.Context, c *natsconn.Conn, subject, header string, data []byte, out interface{}) error {
+	var reply *natsconn.Msg
+	err := c.Fetch(ctx, subject, header, data, 1, j.config.Timeout, func(m natsconn.Msg) { reply = &m })
+	if err == nil && reply == nil {
+		err = errors.New("no reply; is JetStream enabled?")
+	}
+	if err != nil {
+		if ctx.Err() == nil && !errors.Is(err, natsconn.ErrNoResponders) {
+			j.drop(c)
+		}
+		return err
+	}
+	if err := json.Unmarshal(reply.Data, out); err != nil {
+		return fmt.Errorf("invalid reply: %w", err)
+	}
+	return nil
+}
+
+func mustJSON(v interface{}) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
@@ -0,0 +1,317 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// Kafka REST proxy v2 content types
+const (
+	kafkaJSON   = "application/vnd.kafka.json.v2+json"
+	kafkaV2JSON = "application/vnd.kafka.v2+json"
+)
+
+// KafkaConfig configures a Kafka transport
+type KafkaConfig struct {
+	// Endpoint is the base URL of a Kafka REST proxy speaking the v2 API,
+	// such as the Confluent REST Proxy
+	Endpoint string
+
+	// Topic holds the events (default: "gauth.events")
+	Topic string
+
+	// Header is added to every request, for proxy authentication (optional)
+	Header http.Header
+
+	// FromBeginning makes a new consumer group start with the oldest
+	// retained event instead of the next one published
+	FromBeginning bool
+
+	// PollInterval is the pause after an empty fetch (default: 1s)
+	PollInterval time.Duration
+
+	// OnError receives handler and decoding failures (optional)
+	OnError func(error)
+
+	// Client performs the requests (default: http.Client with 30s timeout)
+	Client *http.Client
+}
+
+// Kafka is a Transport on a Kafka topic, reached through a REST proxy.
+// Events are keyed by subject, so one subject's events stay in order on one
+// partition; consumer groups are Kafka consumer groups.
+type Kafka struct {
+	config KafkaConfig
+	base   string
+}
+
+var _ Transport = (*Kafka)(nil)
+
+// NewKafka creates a Kafka transport
+func NewKafka(config KafkaConfig) (*Kafka, error) {
+	if config.Endpoint == "" {
+		return nil, errors.New("kafka transport needs a REST proxy endpoint")
+	}
+	if config.Topic == "" {
+		config.Topic = "gauth.events"
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = time.Second
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Kafka{config: config, base: strings.TrimSuffix(config.Endpoint, "/")}, nil
+}
+
+// Publish implements Transport
+func (k *Kafka) Publish(ctx context.Context, event events.Event) error {
+	record := map[string]interface{}{"value": event}
+	if event.Subject != "" {
+		record["key"] = event.Subject
+	}
+	var result struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	err := k.do(ctx, http.MethodPost, k.base+"/topics/"+url.PathEscape(k.config.Topic), kafkaJSON,
+		map[string]interface{}{"records": []interface{}{record}}, &result)
+	if err != nil {
+		return fmt.Errorf("failed to publish event %s: %w", event.ID, err)
+	}
+	for _, o := range result.Offsets {
+		if o.ErrorCode != nil {
+			return fmt.Errorf("failed to publish event %s: code %d: %s", event.ID, *o.ErrorCode, o.Error)
+		}
+	}
+	return nil
+}
+
+// kafkaRecord is a fetched record
+type kafkaRecord struct {
+	Topic     string          `json:"topic"`
+	Partition int             `json:"partition"`
+	Offset    int64           `json:"offset"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// kafkaConsumer is a consumer instance on the proxy
+type kafkaConsumer struct {
+	k   *Kafka
+	uri string
+}
+
+// newConsumer creates a consumer instance in group, subscribed to the topic
+func (k *Kafka) newConsumer(ctx context.Context, group, reset string) (*kafkaConsumer, error) {
+	var created struct {
+		BaseURI string `json:"base_uri"`
+	}
+	err := k.do(ctx, http.MethodPost, k.base+"/consumers/"+url.PathEscape(group), kafkaV2JSON, map[string]string{
+		"name":               consumerName(),
+		"format":             "json",
+		"auto.offset.reset":  reset,
+		"auto.commit.enable": "false",
+	}, &created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer in group %s: %w", group, err)
+	}
+	c := &kafkaConsumer{k: k, uri: created.BaseURI}
+	if err := k.do(ctx, http.MethodPost, c.uri+"/subscription", kafkaV2JSON,
+		map[string][]string{"topics": {k.config.Topic}}, nil); err != nil {
+		c.close()
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", k.config.Topic, err)
+	}
+	return c, nil
+}
+
+func (c *kafkaConsumer) fetch(ctx context.Context) ([]kafkaRecord, error) {
+	var records []kafkaRecord
+	if err := c.k.do(ctx, http.MethodGet, c.uri+"/records", kafkaJSON, nil, &records); err != nil {
+		return nil, fmt.Errorf("failed to fetch events: %w", err)
+	}
+	return records, nil
+}
+
+// commit marks every record up to and including r as consumed
+func (c *kafkaConsumer) commit(ctx context.Context, r kafkaRecord) error {
+	return c.k.do(ctx, http.MethodPost, c.uri+"/offsets", kafkaV2JSON, map[string]interface{}{
+		"offsets": []map[string]interface{}{{"topic": r.Topic, "partition": r.Partition, "offset": r.Offset}},
+	}, nil)
+}
+
+// seek rewinds the partitions of r to it, so a failed record is fetched again
+func (c *kafkaConsumer) seek(ctx context.Context, r kafkaRecord) error {
+	return c.k.do(ctx, http.MethodPost, c.uri+"/positions", kafkaV2JSON, map[string]interface{}{
+		"offsets": []map[string]interface{}{{"topic": r.Topic, "partition": r.Partition, "offset": r.Offset}},
+	}, nil)
+}
+
+// close deletes the consumer instance, so its partitions are reassigned
+func (c *kafkaConsumer) close() {
+	_ = c.k.do(context.Background(), http.MethodDelete, c.uri, kafkaV2JSON, nil, nil)
+}
+
+// Consume implements Transport. A failed event is retried after
+// PollInterval, and later events of its partition wait for it, keeping
+// each subject's events in order.
+func (k *Kafka) Consume(ctx context.Context, group string, handler events.ContextHandler) error {
+	reset := "latest"
+	if k.config.FromBeginning {
+		reset = "earliest"
+	}
+	c, err := k.newConsumer(ctx, group, reset)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	for ctx.Err() == nil {
+		records, err := c.fetch(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return err
+		}
+		failed := map[int]bool{}
+		for _, r := range records {
+			if failed[r.Partition] {
+				continue
+			}
+			if err := k.deliver(ctx, r, handler); err != nil {
+				k.report(fmt.Errorf("event at %s/%d/%d in group %s: %w", r.Topic, r.Partition, r.Offset, group, err))
+				failed[r.Partition] = true
+				if err := c.seek(ctx, r); err != nil && ctx.Err() == nil {
+					return fmt.Errorf("failed to rewind partition %d: %w", r.Partition, err)
+				}
+				continue
+			}
+			if err := c.commit(ctx, r); err != nil && ctx.Err() == nil {
+				return fmt.Errorf("failed to commit offset: %w", err)
+			}
+		}
+		if len(records) == 0 || len(failed) > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(k.config.PollInterval):
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// deliver decodes and handles a record; undecodable records count as
+// handled, since retrying cannot fix them
+func (k *Kafka) deliver(ctx context.Context, r kafkaRecord, handler events.ContextHandler) error {
+	event, err := decode(r.Value)
+	if err != nil {
+		k.report(fmt.Errorf("event at %s/%d/%d: %w", r.Topic, r.Partition, r.Offset, err))
+		return nil
+	}
+	return handle(ctx, handler, event)
+}
+
+// Replay implements Transport by reading the topic from the start in a
+// throwaway consumer group and skipping events before since. Events are in
+// log order per partition.
+func (k *Kafka) Replay(ctx context.Context, since time.Time, handler events.ContextHandler) error {
+	c, err := k.newConsumer(ctx, "gauth-replay-"+uuid.New().String(), "earliest")
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	// The first fetches may be empty while the group is assigned its
+	// partitions
+	for empty := 0; empty < 3; {
+		records, err := c.fetch(ctx)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			empty++
+			continue
+		}
+		empty = 0
+		for _, r := range records {
+			event, err := decode(r.Value)
+			if err != nil {
+				k.report(err)
+				continue
+			}
+			if event.Timestamp.Before(since) {
+				continue
+			}
+			if err := handle(ctx, handler, event); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close implements Transport
+func (k *Kafka) Close() error {
+	return nil
+}
+
+func (k *Kafka) report(err error) {
+	if k.config.OnError != nil {
+		k.config.OnError(err)
+	}
+}
+
+// do sends a request to the proxy and decodes the JSON response into out
+func (k *Kafka) do(ctx context.Context, method, url, contentType string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	for name, values := range k.config.Header {
+		req.Header[name] = values
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Accept", contentType)
+	} else {
+		req.Header.Set("Accept", kafkaV2JSON)
+	}
+
+	resp, err := k.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: %s: %s", method, url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,242 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// RedisConfig configures a RedisStreams transport
+type RedisConfig struct {
+	// Client connects to Redis 6.2 or later
+	Client redis.UniversalClient
+
+	// Stream is the stream key (default: "gauth:events")
+	Stream string
+
+	// MaxLen trims the stream to about this many events (default: 1000000,
+	// negative keeps everything)
+	MaxLen int64
+
+	// FromBeginning makes a new consumer group start with the oldest
+	// retained event instead of the next one published
+	FromBeginning bool
+
+	// BatchSize is the most events read per call (default: 100)
+	BatchSize int64
+
+	// Block is how long a read waits for new events (default: 5s)
+	Block time.Duration
+
+	// RetryAfter is how long a failed event stays pending before a group
+	// member claims it again (default: 30s)
+	RetryAfter time.Duration
+
+	// OnError receives handler and decoding failures (optional)
+	OnError func(error)
+}
+
+// RedisStreams is a Transport on a Redis stream; consumer groups are Redis
+// stream consumer groups
+type RedisStreams struct {
+	config RedisConfig
+}
+
+var _ Transport = (*RedisStreams)(nil)
+
+// eventField is the stream entry field holding the encoded event
+const eventField = "event"
+
+// NewRedisStreams creates a Redis Streams transport
+func NewRedisStreams(config RedisConfig) (*RedisStreams, error) {
+	if config.Client == nil {
+		return nil, errors.New("redis transport needs a client")
+	}
+	if config.Stream == "" {
+		config.Stream = "gauth:events"
+	}
+	if config.MaxLen == 0 {
+		config.MaxLen = 1000000
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.Block <= 0 {
+		config.Block = 5 * time.Second
+	}
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = 30 * time.Second
+	}
+	return &RedisStreams{config: config}, nil
+}
+
+// Publish implements Transport
+func (r *RedisStreams) Publish(ctx context.Context, event events.Event) error {
+	data, err := encode(event)
+	if err != nil {
+		return err
+	}
+	args := &redis.XAddArgs{Stream: r.config.Stream, Values: map[string]interface{}{eventField: data}}
+	if r.config.MaxLen > 0 {
+		args.MaxLen, args.Approx = r.config.MaxLen, true
+	}
+	if err := r.config.Client.XAdd(ctx, args).Err(); err != nil {
+		return fmt.Errorf("failed to publish event %s: %w", event.ID, err)
+	}
+	return nil
+}
+
+// Consume implements Transport. Events any member left pending for
+// RetryAfter, because its handler failed or the member crashed, are claimed
+// and retried.
+func (r *RedisStreams) Consume(ctx context.Context, group string, handler events.ContextHandler) error {
+	start := "$"
+	if r.config.FromBeginning {
+		start = "0"
+	}
+	err := r.config.Client.XGroupCreateMkStream(ctx, r.config.Stream, group, start).Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group %s: %w", group, err)
+	}
+
+	consumer := consumerName()
+	defer r.config.Client.XGroupDelConsumer(context.Background(), r.config.Stream, group, consumer)
+
+	for ctx.Err() == nil {
+		claimed, err := r.claim(ctx, group, consumer)
+		if err != nil && ctx.Err() == nil {
+			return fmt.Errorf("failed to claim pending events: %w", err)
+		}
+		r.deliver(ctx, group, claimed, handler)
+
+		streams, err := r.config.Client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    group,
+			Consumer: consumer,
+			Streams:  []string{r.config.Stream, ">"},
+			Count:    r.config.BatchSize,
+			Block:    r.config.Block,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return fmt.Errorf("failed to read events: %w", err)
+		}
+		for _, s := range streams {
+			r.deliver(ctx, group, s.Messages, handler)
+		}
+	}
+	return ctx.Err()
+}
+
+// claim takes over the events left pending for RetryAfter. It pairs
+// XPENDING with XCLAIM rather than using XAUTOCLAIM, whose reply changed
+// shape in Redis 7.
+func (r *RedisStreams) claim(ctx context.Context, group, consumer string) ([]redis.XMessage, error) {
+	pending, err := r.config.Client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: r.config.Stream,
+		Group:  group,
+		Idle:   r.config.RetryAfter,
+		Start:  "-",
+		End:    "+",
+		Count:  r.config.BatchSize,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return nil, err
+	}
+	ids := make([]string, len(pending))
+	for i, p := range pending {
+		ids[i] = p.ID
+	}
+	return r.config.Client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   r.config.Stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  r.config.RetryAfter,
+		Messages: ids,
+	}).Result()
+}
+
+// deliver handles messages and acknowledges the handled ones. Undecodable
+// messages are acknowledged too, since retrying cannot fix them.
+func (r *RedisStreams) deliver(ctx context.Context, group string, msgs []redis.XMessage, handler events.ContextHandler) {
+	var acks []string
+	for _, msg := range msgs {
+		event, err := decodeMessage(msg)
+		if err == nil {
+			if err = handle(ctx, handler, event); err == nil {
+				acks = append(acks, msg.ID)
+				continue
+			}
+		} else {
+			acks = append(acks, msg.ID)
+		}
+		r.report(fmt.Errorf("event %s in group %s: %w", msg.ID, group, err))
+	}
+	if len(acks) > 0 {
+		if err := r.config.Client.XAck(context.WithoutCancel(ctx), r.config.Stream, group, acks...).Err(); err != nil {
+			r.report(fmt.Errorf("failed to acknowledge events: %w", err))
+		}
+	}
+}
+
+// Replay implements Transport
+func (r *RedisStreams) Replay(ctx context.Context, since time.Time, handler events.ContextHandler) error {
+	start := strconv.FormatInt(since.UnixMilli(), 10) + "-0"
+	for {
+		msgs, err := r.config.Client.XRangeN(ctx, r.config.Stream, start, "+", r.config.BatchSize).Result()
+		if err != nil {
+			return fmt.Errorf("failed to read events: %w", err)
+		}
+		for _, msg := range msgs {
+			event, err := decodeMessage(msg)
+			if err != nil {
+				r.report(fmt.Errorf("event %s: %w", msg.ID, err))
+				continue
+			}
+			if err := handle(ctx, handler, event); err != nil {
+				return err
+			}
+		}
+		if int64(len(msgs)) < r.config.BatchSize {
+			return nil
+		}
+		start = nextID(msgs[len(msgs)-1].ID)
+	}
+}
+
+// Close implements Transport; the client belongs to the caller
+func (r *RedisStreams) Close() error {
+	return nil
+}
+
+func (r *RedisStreams) report(err error) {
+	if r.config.OnError != nil {
+		r.config.OnError(err)
+	}
+}
+
+func decodeMessage(msg redis.XMessage) (events.Event, error) {
+	data, ok := msg.Values[eventField].(string)
+	if !ok {
+		return events.Event{}, errors.New("entry has no event field")
+	}
+	return decode([]byte(data))
+}
+
+// nextID returns the smallest stream ID after id
+func nextID(id string) string {
+	ms, seq, _ := strings.Cut(id, "-")
+	n, _ := strconv.ParseUint(seq, 10, 64)
+	return ms + "-" + strconv.FormatUint(n+1, 10)
+}
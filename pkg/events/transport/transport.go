@@ -0,0 +1,108 @@
+// Package transport provides durable event bus backends. Events published
+// through a Transport are appended to a log in Redis Streams, NATS JetStream
+// or Kafka, so they survive restarts and can be consumed by other services:
+//
+//	t, _ := transport.NewRedisStreams(transport.RedisConfig{Client: rdb})
+//	pub := transport.NewPublisher(t)
+//	pub.Publish(events.NewTokenEvent(events.ActionTokenIssued, events.StatusSuccess))
+//
+//	// in each instance of a consuming service
+//	err := t.Consume(ctx, "billing", handler)
+//
+// Members of one consumer group share the events, each event going to one
+// member; separate groups each see every event. Delivery is at least once:
+// an event is acknowledged only when the handler returns nil, and
+// redelivered otherwise, so handlers must be idempotent. Replay reads the
+// retained history from a point in time without affecting any group.
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// Transport is a durable event log
+type Transport interface {
+	// Publish appends an event to the log once the backend has stored it
+	Publish(ctx context.Context, event events.Event) error
+
+	// Consume delivers events to handler as a member of group until ctx is
+	// done, then returns ctx.Err(). Handler errors are retried later and
+	// never stop consumption.
+	Consume(ctx context.Context, group string, handler events.ContextHandler) error
+
+	// Replay delivers the retained events published at or after since, in
+	// log order, and stops at the first handler error
+	Replay(ctx context.Context, since time.Time, handler events.ContextHandler) error
+
+	// Close releases the connection
+	Close() error
+}
+
+// Publisher publishes events to a Transport, so a durable log can replace
+// an in-process publisher
+type Publisher struct {
+	transport Transport
+}
+
+var _ events.Publisher = (*Publisher)(nil)
+
+// NewPublisher creates a publisher on the transport
+func NewPublisher(t Transport) *Publisher {
+	return &Publisher{transport: t}
+}
+
+// Publish implements events.Publisher; the error is dropped
+func (p *Publisher) Publish(event events.Event) {
+	_ = p.PublishContext(context.Background(), event)
+}
+
+// PublishContext implements events.Publisher
+func (p *Publisher) PublishContext(ctx context.Context, event events.Event) error {
+	if event.CorrelationID == "" {
+		event = event.WithContext(ctx)
+	}
+	return p.transport.Publish(ctx, event)
+}
+
+// encode serializes an event for the log
+func encode(event events.Event) ([]byte, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event %s: %w", event.ID, err)
+	}
+	return data, nil
+}
+
+// decode deserializes an event from the log
+func decode(data []byte) (events.Event, error) {
+	var event events.Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return events.Event{}, fmt.Errorf("failed to decode event: %w", err)
+	}
+	return event, nil
+}
+
+// handle runs a handler under a context carrying the event's correlation ID
+func handle(ctx context.Context, handler events.ContextHandler, event events.Event) error {
+	if event.CorrelationID != "" {
+		ctx = events.ContextWithCorrelationID(ctx, event.CorrelationID)
+	}
+	return handler.Handle(ctx, event)
+}
+
+// consumerName returns a name unique to this process and call
+func consumerName() string {
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "gauth"
+	}
+	return host + "-" + uuid.New().String()[:8]
+}
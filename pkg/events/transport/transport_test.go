@@ -0,0 +1,429 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// collector records the events a handler sees and fails the first delivery
+// of the IDs in failOnce
+type collector struct {
+	mu       sync.Mutex
+	ids      []string
+	failOnce map[string]bool
+}
+
+func (c *collector) Handle(ctx context.Context, event events.Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failOnce[event.ID] {
+		delete(c.failOnce, event.ID)
+		return errors.New("try again")
+	}
+	if got := events.CorrelationIDFromContext(ctx); got != event.CorrelationID {
+		return fmt.Errorf("correlation ID %q in context, want %q", got, event.CorrelationID)
+	}
+	c.ids = append(c.ids, event.ID)
+	return nil
+}
+
+func (c *collector) seen() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.ids...)
+}
+
+// waitFor polls until c has seen n events
+func waitFor(t *testing.T, c *collector, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if ids := c.seen(); len(ids) >= n {
+			return ids
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("saw %d events, want %d", len(c.seen()), n)
+	return nil
+}
+
+func publishN(t *testing.T, tr Transport, n int) []string {
+	t.Helper()
+	pub := NewPublisher(tr)
+	ctx := events.ContextWithCorrelationID(context.Background(), "flow-1")
+	var ids []string
+	for i := 0; i < n; i++ {
+		event := events.NewTokenEvent(events.ActionTokenIssued, events.StatusSuccess)
+		if err := pub.PublishContext(ctx, event); err != nil {
+			t.Fatalf("PublishContext: %v", err)
+		}
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func sameIDs(a, b []string) bool {
+	return strings.Join(a, ",") == strings.Join(b, ",")
+}
+
+func TestRedisStreams(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	tr, err := NewRedisStreams(RedisConfig{
+		Client:        client,
+		FromBeginning: true,
+		Block:         20 * time.Millisecond,
+		RetryAfter:    50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewRedisStreams: %v", err)
+	}
+	start := time.Now().Add(-time.Second)
+	ids := publishN(t, tr, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	billing := &collector{failOnce: map[string]bool{ids[1]: true}}
+	reports := &collector{}
+	done := make(chan error, 2)
+	go func() { done <- tr.Consume(ctx, "billing", billing) }()
+	go func() { done <- tr.Consume(ctx, "reports", reports) }()
+
+	if got := waitFor(t, reports, 3); !sameIDs(got, ids) {
+		t.Errorf("reports saw %v, want %v", got, ids)
+	}
+	// the failed event is claimed again after RetryAfter
+	if got := waitFor(t, billing, 3); !sameIDs(got, []string{ids[0], ids[2], ids[1]}) {
+		t.Errorf("billing saw %v", got)
+	}
+	cancel()
+	for i := 0; i < 2; i++ {
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Consume returned %v", err)
+		}
+	}
+
+	replayed := &collector{}
+	if err := tr.Replay(context.Background(), start, replayed); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if !sameIDs(replayed.seen(), ids) {
+		t.Errorf("replayed %v, want %v", replayed.seen(), ids)
+	}
+	replayed = &collector{}
+	if err := tr.Replay(context.Background(), time.Now().Add(time.Hour), replayed); err != nil || len(replayed.seen()) != 0 {
+		t.Errorf("replay from the future: %v, %v", replayed.seen(), err)
+	}
+}
+
+// fakeKafkaProxy is a single-partition Kafka REST proxy with committed
+// offsets per consumer group
+type fakeKafkaProxy struct {
+	mu        sync.Mutex
+	records   []json.RawMessage
+	committed map[string]int64
+	positions map[string]int64 // per consumer instance
+	groups    map[string]string
+}
+
+func newFakeKafkaProxy() *httptest.Server {
+	f := &fakeKafkaProxy{committed: map[string]int64{}, positions: map[string]int64{}, groups: map[string]string{}}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		var body map[string]json.RawMessage
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case parts[0] == "topics" && r.Method == http.MethodPost:
+			var records []struct {
+				Value json.RawMessage `json:"value"`
+			}
+			_ = json.Unmarshal(body["records"], &records)
+			for _, rec := range records {
+				f.records = append(f.records, rec.Value)
+			}
+			fmt.Fprint(w, `{"offsets":[{"partition":0,"offset":0}]}`)
+		case parts[0] == "consumers" && len(parts) == 2:
+			var cfg map[string]string
+			raw, _ := json.Marshal(body)
+			_ = json.Unmarshal(raw, &cfg)
+			group, name := parts[1], cfg["name"]
+			f.groups[name] = group
+			if off, ok := f.committed[group]; ok {
+				f.positions[name] = off
+			} else if cfg["auto.offset.reset"] == "earliest" {
+				f.positions[name] = 0
+			} else {
+				f.positions[name] = int64(len(f.records))
+			}
+			fmt.Fprintf(w, `{"instance_id":%q,"base_uri":%q}`, name, srv.URL+"/consumers/"+group+"/instances/"+name)
+		case len(parts) == 4 && r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		case len(parts) == 5:
+			name := parts[3]
+			switch parts[4] {
+			case "subscription":
+				w.WriteHeader(http.StatusNoContent)
+			case "records":
+				var out []map[string]interface{}
+				for off := f.positions[name]; off < int64(len(f.records)); off++ {
+					out = append(out, map[string]interface{}{"topic": "gauth.events", "partition": 0, "offset": off, "value": f.records[off]})
+				}
+				f.positions[name] = int64(len(f.records))
+				_ = json.NewEncoder(w).Encode(out)
+			case "offsets", "positions":
+				var offsets []struct {
+					Offset int64 `json:"offset"`
+				}
+				_ = json.Unmarshal(body["offsets"], &offsets)
+				if parts[4] == "offsets" {
+					f.committed[f.groups[name]] = offsets[0].Offset + 1
+				} else {
+					f.positions[name] = offsets[0].Offset
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return srv
+}
+
+func TestKafka(t *testing.T) {
+	srv := newFakeKafkaProxy()
+	defer srv.Close()
+
+	tr, err := NewKafka(KafkaConfig{Endpoint: srv.URL, FromBeginning: true, PollInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewKafka: %v", err)
+	}
+	ids := publishN(t, tr, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	billing := &collector{failOnce: map[string]bool{ids[1]: true}}
+	done := make(chan error, 1)
+	go func() { done <- tr.Consume(ctx, "billing", billing) }()
+	// the failed event blocks its partition until it succeeds
+	if got := waitFor(t, billing, 3); !sameIDs(got, ids) {
+		t.Errorf("billing saw %v, want %v", got, ids)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Consume returned %v", err)
+	}
+
+	// a restarted member resumes after the committed offset
+	more := publishN(t, tr, 1)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	resumed := &collector{}
+	go func() { done <- tr.Consume(ctx, "billing", resumed) }()
+	if got := waitFor(t, resumed, 1); !sameIDs(got, more) {
+		t.Errorf("resumed member saw %v, want %v", got, more)
+	}
+
+	replayed := &collector{}
+	if err := tr.Replay(context.Background(), time.Time{}, replayed); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if !sameIDs(replayed.seen(), append(ids, more...)) {
+		t.Errorf("replayed %v", replayed.seen())
+	}
+}
+
+// fakeJetStream is a NATS server with a single JetStream stream and just
+// enough of the JetStream API for the transport
+type fakeJetStream struct {
+	ln      net.Listener
+	mu      sync.Mutex
+	msgs    [][]byte
+	msgIDs  map[string]bool
+	next    map[string]int   // next new message per consumer
+	pending map[string][]int // NAKed messages per consumer
+}
+
+func newFakeJetStream(t *testing.T) *fakeJetStream {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakeJetStream{ln: ln, msgIDs: map[string]bool{}, next: map[string]int{}, pending: map[string][]int{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return f
+}
+
+func (f *fakeJetStream) serve(conn net.Conn) {
+	defer conn.Close()
+	var wmu sync.Mutex
+	send := func(format string, args ...interface{}) {
+		wmu.Lock()
+		fmt.Fprintf(conn, format, args...)
+		wmu.Unlock()
+	}
+	reply := func(to, ack, data string) {
+		if ack != "" {
+			ack += " "
+		}
+		send("MSG %s 1 %s%d\r\n%s\r\n", to, ack, len(data), data)
+	}
+	status := func(to, code string) {
+		h := "NATS/1.0 " + code + "\r\n\r\n"
+		send("HMSG %s 1 %d %d\r\n%s\r\n", to, len(h), len(h), h)
+	}
+
+	send("INFO {\"headers\":true,\"jetstream\":true}\r\n")
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+		switch args[0] {
+		case "PING":
+			send("PONG\r\n")
+		case "PUB", "HPUB":
+			size, _ := strconv.Atoi(args[len(args)-1])
+			buf := make([]byte, size+2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			hsize, n := 0, 3
+			if args[0] == "HPUB" {
+				hsize, _ = strconv.Atoi(args[len(args)-2])
+				n = 4
+			}
+			to := ""
+			if len(args) > n {
+				to = args[2]
+			}
+			f.handle(args[1], to, string(buf[:hsize]), buf[hsize:size], reply, status)
+		}
+	}
+}
+
+func (f *fakeJetStream) handle(subject, to, header string, data []byte, reply func(to, ack, data string), status func(to, code string)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case strings.HasPrefix(subject, "gauth.events."):
+		id := strings.TrimSpace(strings.TrimPrefix(strings.Split(header, "\r\n")[1], "Nats-Msg-Id:"))
+		if !f.msgIDs[id] {
+			f.msgIDs[id] = true
+			f.msgs = append(f.msgs, data)
+		}
+		reply(to, "", fmt.Sprintf(`{"stream":"GAUTH_EVENTS","seq":%d}`, len(f.msgs)))
+	case strings.HasPrefix(subject, "$JS.API.STREAM.CREATE."):
+		reply(to, "", `{"error":{"code":400,"err_code":10058,"description":"stream name already in use"}}`)
+	case strings.HasPrefix(subject, "$JS.API.CONSUMER.CREATE."):
+		var req struct {
+			Config struct {
+				Durable string `json:"durable_name"`
+			} `json:"config"`
+		}
+		_ = json.Unmarshal(data, &req)
+		name := req.Config.Durable
+		if name == "" {
+			name = "ephemeral"
+			f.next[name] = 0
+		}
+		reply(to, "", fmt.Sprintf(`{"name":%q}`, name))
+	case strings.HasPrefix(subject, "$JS.API.CONSUMER.DELETE."):
+		reply(to, "", `{"success":true}`)
+	case strings.HasPrefix(subject, "$JS.API.CONSUMER.MSG.NEXT."):
+		name := subject[strings.LastIndex(subject, ".")+1:]
+		// redeliveries first, then new messages
+		seqs := f.pending[name]
+		f.pending[name] = nil
+		for ; f.next[name] < len(f.msgs); f.next[name]++ {
+			seqs = append(seqs, f.next[name])
+		}
+		for _, seq := range seqs {
+			reply(to, fmt.Sprintf("$JS.ACK.%s.%d", name, seq), string(f.msgs[seq]))
+		}
+		status(to, "404 No Messages")
+	case strings.HasPrefix(subject, "$JS.ACK."):
+		parts := strings.Split(subject, ".")
+		seq, _ := strconv.Atoi(parts[3])
+		if strings.HasPrefix(string(data), "-NAK") {
+			f.pending[parts[2]] = append(f.pending[parts[2]], seq)
+		}
+	}
+}
+
+func TestJetStream(t *testing.T) {
+	fs := newFakeJetStream(t)
+	tr, err := NewJetStream(JetStreamConfig{
+		Address:      fs.ln.Addr().String(),
+		CreateStream: true,
+		Wait:         20 * time.Millisecond,
+		Timeout:      time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewJetStream: %v", err)
+	}
+	defer tr.Close()
+
+	ids := publishN(t, tr, 3)
+	// a retried publish is deduplicated by its ID
+	pub := NewPublisher(tr)
+	event := events.NewTokenEvent(events.ActionTokenIssued, events.StatusSuccess)
+	event.ID = ids[0]
+	if err := pub.PublishContext(context.Background(), event); err != nil {
+		t.Fatalf("PublishContext: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	billing := &collector{failOnce: map[string]bool{ids[1]: true}}
+	done := make(chan error, 1)
+	go func() { done <- tr.Consume(ctx, "billing", billing) }()
+	if got := waitFor(t, billing, 3); !sameIDs(got, []string{ids[0], ids[2], ids[1]}) {
+		t.Errorf("billing saw %v", got)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Consume returned %v", err)
+	}
+
+	replayed := &collector{}
+	if err := tr.Replay(context.Background(), time.Now().Add(-time.Minute), replayed); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if !sameIDs(replayed.seen(), ids) {
+		t.Errorf("replayed %v, want %v", replayed.seen(), ids)
+	}
+}
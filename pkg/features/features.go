@@ -0,0 +1,274 @@
+// Package features gates capabilities per tenant. Every flag is declared
+// once with a default; tenants can override it at runtime, and each change
+// is written to the audit trail. Code checks capabilities through a single
+// typed API:
+//
+//	if features.Enabled(ctx, flags, features.TokenExchange) { ... }
+package features
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/Gimel-Foundation/gauth/pkg/audit"
+)
+
+// Feature flag errors
+var (
+	// ErrUnknownFlag indicates the flag was never declared
+	ErrUnknownFlag = errors.New("unknown feature flag")
+
+	// ErrFeatureDisabled indicates the capability is off for the tenant
+	ErrFeatureDisabled = errors.New("feature disabled for tenant")
+)
+
+// Flag names a gated capability
+type Flag string
+
+// Built-in flags
+const (
+	// TokenExchange enables RFC 8693 token exchange
+	TokenExchange Flag = "token_exchange"
+
+	// PQSigning enables post-quantum token signatures
+	PQSigning Flag = "pq_signing"
+
+	// StepUpMFA enables step-up multi-factor authentication
+	StepUpMFA Flag = "step_up_mfa"
+)
+
+// Audit trail constants
+const (
+	// TypeFeatureFlag is the audit entry type for flag changes
+	TypeFeatureFlag = "feature_flag"
+
+	// ActionFlagChanged records a flag change
+	ActionFlagChanged = "feature_flag_changed"
+)
+
+// Definition declares a flag
+type Definition struct {
+	Flag        Flag   `json:"flag"`
+	Description string `json:"description"`
+	Default     bool   `json:"default"`
+}
+
+// DefaultDefinitions declares the built-in flags, all off by default
+var DefaultDefinitions = []Definition{
+	{Flag: TokenExchange, Description: "RFC 8693 token exchange"},
+	{Flag: PQSigning, Description: "post-quantum token signatures"},
+	{Flag: StepUpMFA, Description: "step-up multi-factor authentication"},
+}
+
+// Config configures a Flags set
+type Config struct {
+	// Definitions declares the known flags (default: DefaultDefinitions)
+	Definitions []Definition
+
+	// Audit receives an entry for every change
+	Audit audit.Storage
+}
+
+// Flags holds flag defaults and per-tenant overrides
+type Flags struct {
+	audit     audit.Storage
+	mu        sync.RWMutex
+	defs      map[Flag]*Definition
+	overrides map[string]map[Flag]bool
+}
+
+// New creates a flag set
+func New(config Config) *Flags {
+	if config.Definitions == nil {
+		config.Definitions = DefaultDefinitions
+	}
+	f := &Flags{
+		audit:     config.Audit,
+		defs:      make(map[Flag]*Definition, len(config.Definitions)),
+		overrides: make(map[string]map[Flag]bool),
+	}
+	for i := range config.Definitions {
+		def := config.Definitions[i]
+		f.defs[def.Flag] = &def
+	}
+	return f
+}
+
+// Declare adds a flag; declaring an existing flag is a no-op
+func (f *Flags) Declare(def Definition) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.defs[def.Flag]; !ok {
+		f.defs[def.Flag] = &def
+	}
+}
+
+// IsEnabled reports whether the flag is on for the tenant. Unknown flags are
+// always off.
+func (f *Flags) IsEnabled(tenant string, flag Flag) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	def, ok := f.defs[flag]
+	if !ok {
+		return false
+	}
+	if v, ok := f.overrides[tenant][flag]; ok {
+		return v
+	}
+	return def.Default
+}
+
+// Set overrides a flag for one tenant
+func (f *Flags) Set(ctx context.Context, tenant string, flag Flag, enabled bool, actor, reason string) error {
+	f.mu.Lock()
+	if _, ok := f.defs[flag]; !ok {
+		f.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrUnknownFlag, flag)
+	}
+	previous, hadOverride := f.overrides[tenant][flag]
+	if !hadOverride {
+		previous = f.defs[flag].Default
+	}
+	if f.overrides[tenant] == nil {
+		f.overrides[tenant] = make(map[Flag]bool)
+	}
+	f.overrides[tenant][flag] = enabled
+	f.mu.Unlock()
+
+	return f.record(ctx, tenant, flag, previous, enabled, actor, reason)
+}
+
+// Clear removes a tenant override so the default applies again
+func (f *Flags) Clear(ctx context.Context, tenant string, flag Flag, actor, reason string) error {
+	f.mu.Lock()
+	def, ok := f.defs[flag]
+	if !ok {
+		f.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrUnknownFlag, flag)
+	}
+	previous, hadOverride := f.overrides[tenant][flag]
+	delete(f.overrides[tenant], flag)
+	f.mu.Unlock()
+
+	if !hadOverride {
+		return nil
+	}
+	return f.record(ctx, tenant, flag, previous, def.Default, actor, reason)
+}
+
+// SetDefault changes a flag for every tenant without an override
+func (f *Flags) SetDefault(ctx context.Context, flag Flag, enabled bool, actor, reason string) error {
+	f.mu.Lock()
+	def, ok := f.defs[flag]
+	if !ok {
+		f.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrUnknownFlag, flag)
+	}
+	previous := def.Default
+	def.Default = enabled
+	f.mu.Unlock()
+
+	return f.record(ctx, "", flag, previous, enabled, actor, reason)
+}
+
+// Snapshot returns the effective value of every flag for the tenant
+func (f *Flags) Snapshot(tenant string) map[Flag]bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	out := make(map[Flag]bool, len(f.defs))
+	for flag, def := range f.defs {
+		out[flag] = def.Default
+		if v, ok := f.overrides[tenant][flag]; ok {
+			out[flag] = v
+		}
+	}
+	return out
+}
+
+// Definitions returns the declared flags sorted by name
+func (f *Flags) Definitions() []Definition {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	out := make([]Definition, 0, len(f.defs))
+	for _, def := range f.defs {
+		out = append(out, *def)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Flag < out[j].Flag })
+	return out
+}
+
+func (f *Flags) record(ctx context.Context, tenant string, flag Flag, from, to bool, actor, reason string) error {
+	if f.audit == nil {
+		return nil
+	}
+	e := audit.NewEntry(TypeFeatureFlag).
+		WithActor(actor, audit.ActorUser).
+		WithAction(ActionFlagChanged).
+		WithTarget(string(flag), TypeFeatureFlag).
+		WithResult(audit.ResultSuccess).
+		WithContext(ctx).
+		WithMetadata(audit.MetadataTenant, tenant).
+		WithMetadata("from", strconv.FormatBool(from)).
+		WithMetadata("to", strconv.FormatBool(to))
+	if reason != "" {
+		e.WithMetadata("reason", reason)
+	}
+	if err := f.audit.Store(ctx, e); err != nil {
+		return fmt.Errorf("failed to record flag change: %w", err)
+	}
+	return nil
+}
+
+type tenantKey struct{}
+
+// ContextWithTenant returns a context carrying the tenant
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant carried by ctx
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// Enabled reports whether the flag is on for the tenant in ctx. A nil flag
+// set disables everything.
+func Enabled(ctx context.Context, flags *Flags, flag Flag) bool {
+	return flags != nil && flags.IsEnabled(TenantFromContext(ctx), flag)
+}
+
+// Require returns ErrFeatureDisabled unless the flag is on for the tenant in ctx
+func Require(ctx context.Context, flags *Flags, flag Flag) error {
+	if !Enabled(ctx, flags, flag) {
+		return fmt.Errorf("%w: %s for tenant %q", ErrFeatureDisabled, flag, TenantFromContext(ctx))
+	}
+	return nil
+}
+
+// Gate rejects requests with 404 unless the flag is on for the request's
+// tenant, so disabled capabilities look absent
+func Gate(flags *Flags, flag Flag, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !Enabled(r.Context(), flags, flag) {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// TenantMiddleware stores the tenant named by header in the request context
+func TenantMiddleware(header string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tenant := r.Header.Get(header); tenant != "" {
+			r = r.WithContext(ContextWithTenant(r.Context(), tenant))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,88 @@
+package features
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Gimel-Foundation/gauth/pkg/audit"
+)
+
+func TestFlags(t *testing.T) {
+	ctx := context.Background()
+	storage, err := audit.NewFileStorage(audit.FileConfig{Directory: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFileStorage() error: %v", err)
+	}
+	defer storage.Close()
+
+	flags := New(Config{Audit: storage})
+	acme := ContextWithTenant(ctx, "acme")
+	globex := ContextWithTenant(ctx, "globex")
+
+	t.Run("Per Tenant", func(t *testing.T) {
+		if Enabled(acme, flags, TokenExchange) {
+			t.Error("Expected token exchange off by default")
+		}
+		if err := flags.Set(ctx, "acme", TokenExchange, true, "ops", "pilot"); err != nil {
+			t.Fatalf("Set() error: %v", err)
+		}
+		if !Enabled(acme, flags, TokenExchange) {
+			t.Error("Expected token exchange on for acme")
+		}
+		if err := Require(globex, flags, TokenExchange); !errors.Is(err, ErrFeatureDisabled) {
+			t.Errorf("Expected ErrFeatureDisabled for globex, got %v", err)
+		}
+		if err := flags.Set(ctx, "acme", "teleport", true, "ops", ""); !errors.Is(err, ErrUnknownFlag) {
+			t.Errorf("Expected ErrUnknownFlag, got %v", err)
+		}
+	})
+
+	t.Run("Defaults And Clear", func(t *testing.T) {
+		if err := flags.SetDefault(ctx, StepUpMFA, true, "ops", "rollout"); err != nil {
+			t.Fatalf("SetDefault() error: %v", err)
+		}
+		if err := flags.Set(ctx, "globex", StepUpMFA, false, "ops", "legacy clients"); err != nil {
+			t.Fatalf("Set() error: %v", err)
+		}
+		if !Enabled(acme, flags, StepUpMFA) || Enabled(globex, flags, StepUpMFA) {
+			t.Error("Expected tenant override to win over the default")
+		}
+		if err := flags.Clear(ctx, "globex", StepUpMFA, "ops", ""); err != nil {
+			t.Fatalf("Clear() error: %v", err)
+		}
+		if !flags.Snapshot("globex")[StepUpMFA] {
+			t.Error("Expected default to apply after clearing override")
+		}
+	})
+
+	t.Run("Audit Trail", func(t *testing.T) {
+		entries, err := storage.Search(ctx, &audit.Filter{Types: []string{TypeFeatureFlag}})
+		if err != nil {
+			t.Fatalf("Search() error: %v", err)
+		}
+		if len(entries) != 4 {
+			t.Fatalf("Expected 4 flag changes, got %d", len(entries))
+		}
+		if entries[0].Metadata[audit.MetadataTenant] != "acme" || entries[0].Metadata["to"] != "true" {
+			t.Errorf("Unexpected first entry metadata %v", entries[0].Metadata)
+		}
+	})
+
+	t.Run("HTTP Gate", func(t *testing.T) {
+		handler := TenantMiddleware("X-Tenant", Gate(flags, TokenExchange, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})))
+		for tenant, want := range map[string]int{"acme": http.StatusNoContent, "globex": http.StatusNotFound} {
+			req := httptest.NewRequest("POST", "/token/exchange", nil)
+			req.Header.Set("X-Tenant", tenant)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != want {
+				t.Errorf("Expected %d for %s, got %d", want, tenant, rec.Code)
+			}
+		}
+	})
+}
@@ -145,15 +145,16 @@ examples/    - Standalone example applications
 
 2. HTTP Authentication:
 
-	import "github.com/Gimel-Foundation/gauth/pkg/auth"
+	import "github.com/Gimel-Foundation/gauth/pkg/middleware"
 
 	// Create auth middleware
-	auth := auth.NewMiddleware(auth.Config{
-		TokenService: tokenService,
+	mw, err := middleware.New(middleware.Config{
+		Tokens: tokenService,
 	})
 
 	// Protect routes
-	http.Handle("/api", auth.RequireToken(handler))
+	http.Handle("/api", mw.RequireToken(handler))
+	http.Handle("/admin", mw.RequireScopes("admin")(adminHandler))
 
 3. Event Monitoring:
 
@@ -215,15 +216,18 @@ GAuth supports advanced delegation and attestation flows:
 
 Example:
 
-	tok := token.NewDelegatedToken("ai-agent-123", gauth.DelegationOptions{
+	delegator, err := token.NewDelegator(token.DelegatorConfig{Service: svc, Events: auditHandler})
+	tok, err := delegator.Issue(ctx, "ai-agent-123", token.DelegationOptions{
 	    Principal: "owner-456",
 	    Scope:     "sign_contract",
-	    Restrictions: gauth.Restrictions{MaxValue: 10000},
-	    Attestation:  gauth.Attestation{Notary: "notary-xyz", Version: "v2"},
-	    ValidUntil:   time.Now().Add(24 * time.Hour),
+	    Restrictions: &token.Restrictions{
+	        ValueLimits: &token.ValueLimits{MaxTransactionValue: 10000, Currency: "EUR"},
+	    },
+	    Attestation: &token.Attestation{Type: "notary", AttesterID: "notary-xyz", AttestationDate: time.Now()},
+	    ValidUntil:  time.Now().Add(24 * time.Hour),
 	})
-	// Store the token using the canonical GAuth API, e.g.:
-	// err := svc.RequestToken(...)
+	// Later, before acting on the principal's behalf:
+	err = delegator.Exercise(ctx, tok.Token, 2500, "EUR")
 
 See LIBRARY.md and examples/ for more advanced flows.
 */
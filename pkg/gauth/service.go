@@ -235,14 +235,14 @@ func (s *Service) RequestToken(ctx context.Context, req *TokenRequest) (*TokenRe
 	return resp, nil
 }
 
-// RevokeToken revokes a token
-func (s *Service) RevokeToken(ctx context.Context, token string) error {
+// RevokeToken revokes a token for the given reason
+func (s *Service) RevokeToken(ctx context.Context, token string, reason token.RevocationReason) error {
 	// Retrieve the token by value to get the full struct (including subject)
 	tok, err := s.tokenSvc.GetToken(ctx, token)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve token for revocation: %w", err)
 	}
-	if err := s.tokenSvc.Revoke(ctx, tok); err != nil {
+	if err := s.tokenSvc.Revoke(ctx, tok, reason); err != nil {
 		return fmt.Errorf("failed to revoke token: %w", err)
 	}
 
@@ -250,6 +250,8 @@ func (s *Service) RevokeToken(ctx context.Context, token string) error {
 	if subject == "" {
 		subject = "unknown"
 	}
+	metadata := events.NewMetadata()
+	metadata.SetString("reason", string(reason))
 
 	s.eventBus.Publish(events.Event{
 		Type:      events.EventTypeToken,
@@ -257,13 +259,14 @@ func (s *Service) RevokeToken(ctx context.Context, token string) error {
 		Subject:   subject,
 		Resource:  "token",
 		Timestamp: time.Now(),
-		Metadata:  nil, // Add as needed
+		Metadata:  metadata,
 	})
 
 	s.audit.Log(ctx, audit.NewEntry(audit.TypeToken).
 		WithAction("token_revoke").
 		WithResult(audit.ResultSuccess).
-		WithMetadata("token", token),
+		WithMetadata("token", token).
+		WithMetadata("reason", string(reason)),
 	)
 
 	return nil
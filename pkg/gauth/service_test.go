@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/Gimel-Foundation/gauth/pkg/common"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -224,7 +225,7 @@ func TestService_RevokeToken(t *testing.T) {
 
 	// Test token revocation - instead of expecting an error,
 	// we should expect success when token exists
-	err = svc.RevokeToken(ctx, tokenValue)
+	err = svc.RevokeToken(ctx, tokenValue, token.ReasonPrincipalRequest)
 	if err != nil {
 		// If token not found, it might be due to storage implementation
 		// For now, skip this test if the underlying token store doesn't support lookup by value
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricType is the Prometheus type of a metric
+type MetricType string
+
+// Metric types
+const (
+	TypeCounter   MetricType = "counter"
+	TypeGauge     MetricType = "gauge"
+	TypeHistogram MetricType = "histogram"
+)
+
+// Definition describes a metric GAuth exports
+type Definition struct {
+	Name   string     `json:"name"`
+	Help   string     `json:"help"`
+	Type   MetricType `json:"type"`
+	Labels []string   `json:"labels,omitempty"`
+}
+
+// descPattern reads the name, help and variable labels from Desc.String,
+// since prometheus.Desc has no accessors
+var descPattern = regexp.MustCompile(`^Desc\{fqName: ("(?:[^"\\]|\\.)*"), help: ("(?:[^"\\]|\\.)*"), constLabels: \{[^}]*\}, variableLabels: \{([^}]*)\}\}$`)
+
+// Definitions describes the metrics RegisterMetrics registers, in
+// registration order, for generating dashboards and alerting rules
+func Definitions() []Definition {
+	var defs []Definition
+	for _, c := range collectors() {
+		var typ MetricType
+		switch c.(type) {
+		case *prometheus.CounterVec, prometheus.Counter:
+			typ = TypeCounter
+		case *prometheus.HistogramVec, prometheus.Histogram:
+			typ = TypeHistogram
+		default:
+			typ = TypeGauge
+		}
+
+		descs := make(chan *prometheus.Desc, 1)
+		go func() {
+			c.Describe(descs)
+			close(descs)
+		}()
+		for d := range descs {
+			m := descPattern.FindStringSubmatch(d.String())
+			if m == nil {
+				continue
+			}
+			def := Definition{Type: typ}
+			def.Name, _ = strconv.Unquote(m[1])
+			def.Help, _ = strconv.Unquote(m[2])
+			for _, l := range strings.Split(m[3], ",") {
+				if l = strings.TrimSuffix(strings.TrimPrefix(l, "c("), ")"); l != "" {
+					def.Labels = append(def.Labels, l)
+				}
+			}
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
@@ -110,6 +110,110 @@ var (
 		},
 		[]string{"resource", "action", "allowed"},
 	)
+
+	// Issuance check metrics
+	issuanceChecks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gauth_issuance_checks_total",
+			Help: "Total number of pre-issuance checks by check and result",
+		},
+		[]string{"check", "result"},
+	)
+
+	issuanceCheckLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "gauth_issuance_check_duration_seconds",
+			Help:    "Pre-issuance check duration in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 2, 12),
+		},
+		[]string{"check"},
+	)
+
+	// Clock metrics
+	clockDrift = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gauth_clock_drift_seconds",
+			Help: "Local clock offset from the reference clock in seconds (positive means local is ahead)",
+		},
+		[]string{"reference"},
+	)
+
+	// Rate limit metrics
+	rateLimitAdjustments = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gauth_rate_limit_adjustments_total",
+			Help: "Total number of adaptive rate limit adjustments by direction",
+		},
+		[]string{"limiter", "direction"},
+	)
+
+	rateLimitAdaptiveLimit = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gauth_rate_limit_adaptive_limit",
+			Help: "Limit set by the most recent adaptive adjustment, in requests per window",
+		},
+		[]string{"limiter"},
+	)
+
+	// Replicated store metrics
+	storeMirrorOperations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gauth_store_mirror_operations_total",
+			Help: "Total number of writes mirrored to a secondary token store by result",
+		},
+		[]string{"store", "result"},
+	)
+
+	storeFallbackReads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gauth_store_fallback_reads_total",
+			Help: "Total number of reads served by the secondary token store",
+		},
+		[]string{"store", "operation"},
+	)
+
+	storeDivergence = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gauth_store_divergence",
+			Help: "Tokens that differed between primary and secondary at the last reconciliation",
+		},
+		[]string{"store"},
+	)
+
+	// Resilience metrics
+	fallbackExecutions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gauth_resilience_fallback_total",
+			Help: "Total number of fallback chain stage executions by result",
+		},
+		[]string{"chain", "stage", "result"},
+	)
+
+	circuitState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gauth_circuit_state",
+			Help: "Circuit breaker state: 0 closed, 1 open, 2 half-open",
+		},
+		[]string{"breaker"},
+	)
+
+	// Token lifecycle metrics
+	tokenExpiryBacklog = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gauth_token_expiry_backlog",
+			Help: "Expired tokens still held by a store, awaiting cleanup",
+		},
+		[]string{"store"},
+	)
+
+	// Configuration metrics
+	configInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gauth_config_info",
+			Help: "Always 1; the fingerprint label identifies the configuration the instance runs",
+		},
+		[]string{"fingerprint"},
+	)
 )
 
 // RegisterMetrics registers all GAuth metrics with Prometheus
@@ -120,7 +224,14 @@ func RegisterMetrics() {
 	}
 
 	// Register all metrics
-	prometheus.MustRegister(
+	prometheus.MustRegister(collectors()...)
+
+	metricsRegistered = true
+}
+
+// collectors returns every metric RegisterMetrics registers
+func collectors() []prometheus.Collector {
+	return []prometheus.Collector{
 		authAttempts,
 		authLatency,
 		tokenOperations,
@@ -131,9 +242,19 @@ func RegisterMetrics() {
 		policyEvaluations,
 		cacheOperations,
 		resourceAccess,
-	)
-
-	metricsRegistered = true
+		issuanceChecks,
+		issuanceCheckLatency,
+		clockDrift,
+		rateLimitAdjustments,
+		rateLimitAdaptiveLimit,
+		storeMirrorOperations,
+		storeFallbackReads,
+		storeDivergence,
+		fallbackExecutions,
+		circuitState,
+		tokenExpiryBacklog,
+		configInfo,
+	}
 }
 
 // Collector provides methods to record various metrics
@@ -200,6 +321,67 @@ func (m *Collector) RecordResourceAccess(resource, action string, allowed bool)
 	resourceAccess.WithLabelValues(resource, action, boolToString(allowed)).Inc()
 }
 
+// RecordIssuanceCheck records the outcome and duration of a pre-issuance check
+func (m *Collector) RecordIssuanceCheck(check, result string, duration time.Duration) {
+	issuanceChecks.WithLabelValues(check, result).Inc()
+	issuanceCheckLatency.WithLabelValues(check).Observe(duration.Seconds())
+}
+
+// SetClockDrift records the measured offset of the local clock from a reference
+func (m *Collector) SetClockDrift(reference string, drift time.Duration) {
+	clockDrift.WithLabelValues(reference).Set(drift.Seconds())
+}
+
+// RecordRateLimitAdjustment records an adaptive limiter changing its limit
+func (m *Collector) RecordRateLimitAdjustment(limiter string, oldLimit, newLimit int) {
+	direction := "up"
+	if newLimit < oldLimit {
+		direction = "down"
+	}
+	rateLimitAdjustments.WithLabelValues(limiter, direction).Inc()
+	rateLimitAdaptiveLimit.WithLabelValues(limiter).Set(float64(newLimit))
+}
+
+// RecordStoreMirror records the result (ok, failed, dropped) of mirroring a
+// write to a secondary store
+func (m *Collector) RecordStoreMirror(store, result string) {
+	storeMirrorOperations.WithLabelValues(store, result).Inc()
+}
+
+// RecordStoreFallbackRead records a read served by a secondary store
+func (m *Collector) RecordStoreFallbackRead(store, operation string) {
+	storeFallbackReads.WithLabelValues(store, operation).Inc()
+}
+
+// SetStoreDivergence records how many tokens differed between replicas
+func (m *Collector) SetStoreDivergence(store string, count int) {
+	storeDivergence.WithLabelValues(store).Set(float64(count))
+}
+
+// RecordFallback records the result (served, failed) of a stage of
+// a fallback chain
+func (m *Collector) RecordFallback(chain, stage, result string) {
+	fallbackExecutions.WithLabelValues(chain, stage, result).Inc()
+}
+
+// SetCircuitState records the state of a circuit breaker: 0 closed, 1 open,
+// 2 half-open
+func (m *Collector) SetCircuitState(breaker string, state int) {
+	circuitState.WithLabelValues(breaker).Set(float64(state))
+}
+
+// SetTokenExpiryBacklog records how many expired tokens a store still holds
+func (m *Collector) SetTokenExpiryBacklog(store string, count int64) {
+	tokenExpiryBacklog.WithLabelValues(store).Set(float64(count))
+}
+
+// SetConfigFingerprint exports the fingerprint of the running configuration,
+// replacing the previous one after a reload
+func (m *Collector) SetConfigFingerprint(fingerprint string) {
+	configInfo.Reset()
+	configInfo.WithLabelValues(fingerprint).Set(1)
+}
+
 // Timer provides a convenient way to measure and record operation duration
 type Timer struct {
 	start     time.Time
@@ -0,0 +1,51 @@
+// Package middleware protects HTTP routes and gRPC methods with GAuth bearer
+// tokens.
+//
+// The middleware extracts the bearer token from the request, parses and
+// validates it with the token service, enforces the scopes and RFC111
+// powers of attorney a route requires, and injects the token into the
+// request context for the handler:
+//
+//	mw, err := middleware.New(middleware.Config{Tokens: tokenService})
+//	if err != nil {
+//	    return err
+//	}
+//	mux.Handle("/api", mw.RequireToken(handler))
+//	mux.Handle("/payments", mw.Require(middleware.Requirements{
+//	    Scopes: []string{"payments:write"},
+//	    Powers: []string{"sign_contracts"},
+//	})(paymentsHandler))
+//
+//	func handler(w http.ResponseWriter, r *http.Request) {
+//	    t, _ := middleware.TokenFromContext(r.Context())
+//	    // ...
+//	}
+//
+// Gin routes use the same checks through Gin:
+//
+//	router.GET("/api", mw.Gin(middleware.Requirements{Scopes: []string{"read"}}), ginHandler)
+//
+// gRPC servers install the unary and stream server interceptors, which read
+// the token from the "authorization" metadata, apply per-method
+// Requirements, ask an authz.Authorizer whether the subject may invoke the
+// method and emit audit events. The client certificate of a mutual-TLS
+// connection is checked against certificate-bound tokens. The client
+// interceptors send a token from a TokenSource; inside a handler they
+// propagate the caller's token only to a downstream service named in
+// ForwardAudience, and only if the token was issued for it:
+//
+//	srv := grpc.NewServer(
+//	    grpc.UnaryInterceptor(mw.UnaryServerInterceptor(middleware.GRPCConfig{
+//	        Authorizer: authorizer,
+//	        Events:     auditHandler,
+//	        Public:     []string{"/grpc.health.v1.Health/Check"},
+//	    })),
+//	)
+//	conn, err := grpc.NewClient(target, grpc.WithUnaryInterceptor(middleware.UnaryClientInterceptor(
+//	    middleware.GRPCClientConfig{ForwardAudience: "ledger"},
+//	)))
+//
+// HTTP failures are served as RFC 6750 bearer errors: 401 with an invalid_token
+// challenge for missing or invalid tokens, 403 with insufficient_scope for
+// missing scopes or powers.
+package middleware
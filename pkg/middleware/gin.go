@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// GinTokenKey is the gin context key holding the authenticated token
+const GinTokenKey = "gauth.token"
+
+// Gin returns gin middleware enforcing req. The authenticated token is
+// stored under GinTokenKey and injected into the request context, where
+// TokenFromContext finds it.
+func (m *Middleware) Gin(req Requirements) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, err := m.Authenticate(c.Request, req)
+		if err != nil {
+			m.writeError(c.Writer, req, err)
+			c.Abort()
+			return
+		}
+		c.Set(GinTokenKey, t)
+		c.Request = c.Request.WithContext(ContextWithToken(c.Request.Context(), t))
+		c.Next()
+	}
+}
+
+// GinToken returns the token stored by the gin middleware, if any
+func GinToken(c *gin.Context) (*token.Token, bool) {
+	v, ok := c.Get(GinTokenKey)
+	if !ok {
+		return nil, false
+	}
+	t, ok := v.(*token.Token)
+	return t, ok && t != nil
+}
@@ -0,0 +1,282 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// MetadataKey is the gRPC metadata key carrying the bearer token
+const MetadataKey = "authorization"
+
+// GRPCActionInvoke is the authz action checked for every gRPC call
+const GRPCActionInvoke = "invoke"
+
+// GRPCResourceType is the authz resource type of a gRPC method
+const GRPCResourceType = "grpc_method"
+
+// GRPCConfig configures the gRPC server interceptors
+type GRPCConfig struct {
+	// Default applies to methods without an entry in Methods
+	Default Requirements
+
+	// Methods holds per-method requirements keyed by full method name,
+	// such as "/payments.v1.Payments/Transfer"
+	Methods map[string]Requirements
+
+	// Public lists full method names served without a token, such as
+	// health checks
+	Public []string
+
+	// Authorizer, when set, must allow the token's subject to invoke the
+	// method, with the full method name as the resource
+	Authorizer authz.Authorizer
+
+	// Events receives an audit event for every authenticated or rejected
+	// call (optional)
+	Events events.EventHandler
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor that authenticates and
+// authorizes unary calls and injects the token into the handler's context
+func (m *Middleware) UnaryServerInterceptor(cfg GRPCConfig) grpc.UnaryServerInterceptor {
+	g := m.newGRPCGuard(cfg)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := g.guard(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns the streaming counterpart of
+// UnaryServerInterceptor
+func (m *Middleware) StreamServerInterceptor(cfg GRPCConfig) grpc.StreamServerInterceptor {
+	g := m.newGRPCGuard(cfg)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := g.guard(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &tokenServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// TokenSource returns the token value a client sends with a call
+type TokenSource func(ctx context.Context) (string, error)
+
+// GRPCClientConfig configures the gRPC client interceptors
+type GRPCClientConfig struct {
+	// Source supplies the token sent with each call (optional)
+	Source TokenSource
+
+	// ForwardAudience opts in to propagating the caller's token: when Source
+	// is nil or returns "", the token injected into ctx by a server
+	// interceptor is sent if it was issued for this audience. Without it the
+	// caller's token is never forwarded (optional)
+	ForwardAudience string
+}
+
+// UnaryClientInterceptor returns a gRPC interceptor that sends a bearer
+// token in the call metadata, from cfg.Source or, for a downstream service
+// that accepts it, the caller's own token
+func UnaryClientInterceptor(cfg GRPCClientConfig) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, err := outgoingContext(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor returns the streaming counterpart of
+// UnaryClientInterceptor
+func StreamClientInterceptor(cfg GRPCClientConfig) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, err := outgoingContext(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// outgoingContext attaches the token to the outgoing metadata of ctx
+func outgoingContext(ctx context.Context, cfg GRPCClientConfig) (context.Context, error) {
+	var value string
+	if cfg.Source != nil {
+		v, err := cfg.Source(ctx)
+		if err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "failed to obtain token: %v", err)
+		}
+		value = v
+	}
+	if value == "" && cfg.ForwardAudience != "" {
+		if t, ok := TokenFromContext(ctx); ok && containsAudience(t.Audience, cfg.ForwardAudience) {
+			value = t.Value
+		}
+	}
+	if value == "" {
+		return ctx, nil
+	}
+	return metadata.AppendToOutgoingContext(ctx, MetadataKey, "Bearer "+value), nil
+}
+
+func containsAudience(audience []string, target string) bool {
+	for _, aud := range audience {
+		if aud == target {
+			return true
+		}
+	}
+	return false
+}
+
+// grpcGuard applies a GRPCConfig to incoming calls
+type grpcGuard struct {
+	m      *Middleware
+	config GRPCConfig
+	public map[string]bool
+	pep    *authz.PEP
+}
+
+func (m *Middleware) newGRPCGuard(cfg GRPCConfig) *grpcGuard {
+	g := &grpcGuard{m: m, config: cfg, public: make(map[string]bool, len(cfg.Public))}
+	for _, method := range cfg.Public {
+		g.public[method] = true
+	}
+	if cfg.Authorizer != nil {
+		g.pep = authz.NewPEP(authz.PEPConfig{Events: cfg.Events})
+	}
+	return g
+}
+
+// guard authenticates and authorizes a call to method and returns the
+// context the handler runs with
+func (g *grpcGuard) guard(ctx context.Context, method string) (context.Context, error) {
+	if g.public[method] {
+		return ctx, nil
+	}
+	req, ok := g.config.Methods[method]
+	if !ok {
+		req = g.config.Default
+	}
+	t, err := g.m.authenticate(withPeerCertificate(ctx), incomingToken(ctx), req)
+	if err != nil {
+		g.report(ctx, events.ActionTokenValidationFailed, events.StatusFailure, "", method, err.Error())
+		return nil, grpcError(err)
+	}
+	ctx = ContextWithToken(ctx, t)
+	if g.config.Authorizer == nil {
+		g.report(ctx, events.ActionTokenValidated, events.StatusSuccess, t.Subject, method, "")
+		return ctx, nil
+	}
+
+	subject := authz.Subject{ID: t.Subject}
+	action := authz.Action{Name: GRPCActionInvoke}
+	resource := authz.Resource{ID: method, Type: GRPCResourceType}
+	decision, err := g.config.Authorizer.Authorize(ctx, subject, action, resource)
+	if err != nil {
+		g.report(ctx, events.ActionAuthorizationDenied, events.StatusFailure, t.Subject, method, err.Error())
+		return nil, status.Error(codes.Internal, "authorization failed")
+	}
+	enforcement := &authz.Enforcement{
+		Subject:        subject,
+		Action:         action,
+		Resource:       resource,
+		RequestHeaders: incomingHeaders(ctx),
+	}
+	if err := g.pep.Enforce(ctx, decision, enforcement); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	return ctx, nil
+}
+
+// report emits an audit event for a call, if events are configured
+func (g *grpcGuard) report(ctx context.Context, action events.EventAction, st events.EventStatus, subject, method, message string) {
+	if g.config.Events == nil {
+		return
+	}
+	event := events.NewTokenEvent(action, st).WithSubject(subject).WithResource(method).
+		WithMessage(message).WithStringMetadata("protocol", "grpc").WithContext(ctx)
+	g.config.Events.Handle(event)
+}
+
+// withPeerCertificate passes the client certificate of a mutual-TLS
+// connection to the token service, so certificate-bound tokens can be
+// checked
+func withPeerCertificate(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ctx
+	}
+	return token.WithTLSState(ctx, &info.State)
+}
+
+// incomingToken reads the bearer token from the incoming call metadata
+func incomingToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, v := range md.Get(MetadataKey) {
+		scheme, value, ok := strings.Cut(v, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// incomingHeaders exposes the incoming call metadata to obligation
+// handlers as HTTP headers
+func incomingHeaders(ctx context.Context) http.Header {
+	h := http.Header{}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, vs := range md {
+		for _, v := range vs {
+			h.Add(k, v)
+		}
+	}
+	return h
+}
+
+// grpcError maps an authentication failure to a gRPC status
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, ErrMissingToken):
+		return status.Error(codes.Unauthenticated, "a bearer token is required")
+	case errors.Is(err, token.ErrInsufficientScope), errors.Is(err, ErrInsufficientPower):
+		return status.Error(codes.PermissionDenied, err.Error())
+	default:
+		return status.Error(codes.Unauthenticated, "invalid token")
+	}
+}
+
+// tokenServerStream overrides the context of a server stream
+type tokenServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context carrying the authenticated token
+func (s *tokenServerStream) Context() context.Context {
+	return s.ctx
+}
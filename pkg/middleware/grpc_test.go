@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+func (r *eventRecorder) Handle(e events.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) actions() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for _, e := range r.events {
+		out = append(out, e.Action)
+	}
+	return out
+}
+
+func TestGRPCInterceptors(t *testing.T) {
+	ctx := context.Background()
+	mw, tokens := newTestMiddleware(t)
+	alice := issue(t, tokens, &token.Token{ID: token.GenerateID(), Type: token.Access, Subject: "alice", Scopes: []string{"health"}})
+	bob := issue(t, tokens, &token.Token{ID: token.GenerateID(), Type: token.Access, Subject: "bob", Scopes: []string{"health"}})
+
+	authorizer := authz.NewMemoryAuthorizer()
+	if err := authorizer.AddPolicy(ctx, &authz.Policy{
+		ID:        "alice-health",
+		Effect:    authz.Allow,
+		Subjects:  []authz.Subject{{ID: "alice"}},
+		Resources: []authz.Resource{{ID: "/grpc.health.v1.Health/*"}},
+		Actions:   []authz.Action{{Name: GRPCActionInvoke}},
+	}); err != nil {
+		t.Fatalf("AddPolicy() error: %v", err)
+	}
+	recorder := &eventRecorder{}
+	cfg := GRPCConfig{
+		Default:    Requirements{Scopes: []string{"health"}},
+		Authorizer: authorizer,
+		Events:     recorder,
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(mw.UnaryServerInterceptor(cfg)),
+		grpc.StreamInterceptor(mw.StreamServerInterceptor(cfg)),
+	)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	var current string
+	source := func(context.Context) (string, error) { return current, nil }
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(GRPCClientConfig{Source: source})),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(GRPCClientConfig{Source: source})),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error: %v", err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	tests := []struct {
+		name  string
+		token string
+		code  codes.Code
+	}{
+		{"missing token", "", codes.Unauthenticated},
+		{"invalid token", "garbage", codes.Unauthenticated},
+		{"denied by policy", bob, codes.PermissionDenied},
+		{"allowed", alice, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current = tt.token
+			_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+			if status.Code(err) != tt.code {
+				t.Errorf("Check() = %v, want %s", err, tt.code)
+			}
+
+			stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			if status.Code(err) != tt.code {
+				t.Errorf("Watch() = %v, want %s", err, tt.code)
+			}
+		})
+	}
+
+	got := map[string]int{}
+	for _, a := range recorder.actions() {
+		got[a]++
+	}
+	if got[string(events.ActionTokenValidationFailed)] != 4 || got[string(events.ActionAuthorizationDenied)] != 2 || got[string(events.ActionAuthorizationGranted)] != 2 {
+		t.Errorf("audit events = %v", got)
+	}
+}
+
+func TestGRPCClientPropagatesToken(t *testing.T) {
+	tok := &token.Token{Value: "upstream-token", Audience: []string{"ledger"}}
+	ctx := ContextWithToken(context.Background(), tok)
+	for _, tt := range []struct {
+		name string
+		cfg  GRPCClientConfig
+		want []string
+	}{
+		{"not opted in", GRPCClientConfig{}, nil},
+		{"other audience", GRPCClientConfig{ForwardAudience: "billing"}, nil},
+		{"token audience", GRPCClientConfig{ForwardAudience: "ledger"}, []string{"Bearer upstream-token"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := outgoingContext(ctx, tt.cfg)
+			if err != nil {
+				t.Fatalf("outgoingContext() error: %v", err)
+			}
+			md, _ := metadata.FromOutgoingContext(out)
+			if got := md[MetadataKey]; len(got) != len(tt.want) || (len(got) == 1 && got[0] != tt.want[0]) {
+				t.Errorf("outgoing %s = %v, want %v", MetadataKey, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGRPCCertificateBoundToken(t *testing.T) {
+	mw, tokens := newTestMiddleware(t)
+	cert, other := testClientCert(t, "agent-1"), testClientCert(t, "agent-2")
+	issued, err := tokens.Issue(token.WithClientCertificate(context.Background(), cert),
+		&token.Token{ID: token.GenerateID(), Type: token.Access, Subject: "agent-1"})
+	if err != nil {
+		t.Fatalf("Issue() error: %v", err)
+	}
+	g := mw.newGRPCGuard(GRPCConfig{})
+	call := func(peerCert *x509.Certificate) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "Bearer "+issued.Value))
+		if peerCert != nil {
+			state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{peerCert}}
+			ctx = peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}})
+		}
+		_, err := g.guard(ctx, "/ledger.v1.Ledger/Post")
+		return err
+	}
+	if err := call(cert); err != nil {
+		t.Errorf("Expected the bound certificate accepted, got %v", err)
+	}
+	if err := call(other); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected another certificate rejected, got %v", err)
+	}
+	if err := call(nil); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected a call without a certificate rejected, got %v", err)
+	}
+}
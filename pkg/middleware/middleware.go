@@ -0,0 +1,243 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	autherrors "github.com/Gimel-Foundation/gauth/pkg/errors"
+	"github.com/Gimel-Foundation/gauth/pkg/scope"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// ErrMissingToken indicates a request without a bearer token
+var ErrMissingToken = errors.New("missing bearer token")
+
+// ErrInsufficientPower indicates a token whose power of attorney does not
+// cover a required power
+var ErrInsufficientPower = errors.New("insufficient power of attorney")
+
+// Config configures the authentication middleware
+type Config struct {
+	// Tokens parses and validates the bearer tokens
+	Tokens token.ServiceAPI
+
+	// Realm is reported in WWW-Authenticate challenges
+	Realm string
+
+	// Extractor reads the raw token from the request; defaults to
+	// BearerToken
+	Extractor func(*http.Request) string
+
+	// AllowedTypes lists the token types accepted; defaults to access
+	// tokens only
+	AllowedTypes []token.Type
+
+	// Now returns the current time; defaults to time.Now
+	Now func() time.Time
+}
+
+// Requirements are what a route demands of the caller's token
+type Requirements struct {
+	// Scopes must all be granted to the token
+	Scopes []string
+
+	// Powers must all be delegated to the token's subject by its RFC111
+	// power of attorney
+	Powers []string
+}
+
+// Middleware authenticates requests with bearer tokens and enforces
+// per-route scope and power-of-attorney requirements
+type Middleware struct {
+	config Config
+}
+
+// New creates a middleware for the given configuration
+func New(cfg Config) (*Middleware, error) {
+	if cfg.Tokens == nil {
+		return nil, fmt.Errorf("%w: token service is required", token.ErrInvalidConfig)
+	}
+	if cfg.Extractor == nil {
+		cfg.Extractor = BearerToken
+	}
+	if len(cfg.AllowedTypes) == 0 {
+		cfg.AllowedTypes = []token.Type{token.Access}
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &Middleware{config: cfg}, nil
+}
+
+// RequireToken rejects requests without a valid token and injects the
+// token into the request context of the next handler
+func (m *Middleware) RequireToken(next http.Handler) http.Handler {
+	return m.Require(Requirements{})(next)
+}
+
+// RequireScopes returns middleware that also demands the given scopes
+func (m *Middleware) RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return m.Require(Requirements{Scopes: scopes})
+}
+
+// RequirePowers returns middleware that also demands the given powers of
+// attorney
+func (m *Middleware) RequirePowers(powers ...string) func(http.Handler) http.Handler {
+	return m.Require(Requirements{Powers: powers})
+}
+
+// Require returns middleware enforcing req on every request it wraps
+func (m *Middleware) Require(req Requirements) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t, err := m.Authenticate(r, req)
+			if err != nil {
+				m.writeError(w, req, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ContextWithToken(r.Context(), t)))
+		})
+	}
+}
+
+// Authenticate extracts, validates and checks the request's token against
+// req. The client certificate of a mutual-TLS connection is passed to the
+// token service, so certificate-bound tokens (RFC 8705) are checked against
+// it. Errors wrap ErrMissingToken, token.ErrInsufficientScope,
+// ErrInsufficientPower or the token service's validation error.
+func (m *Middleware) Authenticate(r *http.Request, req Requirements) (*token.Token, error) {
+	return m.authenticate(token.WithTLSState(r.Context(), r.TLS), m.config.Extractor(r), req)
+}
+
+// authenticate validates a raw token value and checks it against req
+func (m *Middleware) authenticate(ctx context.Context, value string, req Requirements) (*token.Token, error) {
+	if value == "" {
+		return nil, ErrMissingToken
+	}
+	t, err := m.config.Tokens.Parse(ctx, value)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.config.Tokens.Validate(ctx, t); err != nil {
+		return nil, err
+	}
+	if !m.allowedType(t.Type) {
+		return nil, fmt.Errorf("%w: %s tokens are not accepted", token.ErrInvalidType, t.Type)
+	}
+	if missing := missingScopes(t.Scopes, req.Scopes); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: missing %s", token.ErrInsufficientScope, strings.Join(missing, " "))
+	}
+	if err := m.checkPowers(t, req.Powers); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// checkPowers verifies the token carries an active delegation chain held by
+// its subject that delegates every required power
+func (m *Middleware) checkPowers(t *token.Token, powers []string) error {
+	if len(powers) == 0 {
+		return nil
+	}
+	chain := token.DelegationChainOf(t)
+	if chain == nil {
+		return fmt.Errorf("%w: token carries no power of attorney", ErrInsufficientPower)
+	}
+	if chain.Holder() != t.Subject || !chain.ActiveAt(m.config.Now()) {
+		return fmt.Errorf("%w: power of attorney is not held by %s", ErrInsufficientPower, t.Subject)
+	}
+	if missing := missingScopes(chain.Scopes(), powers); len(missing) > 0 {
+		return fmt.Errorf("%w: missing %s", ErrInsufficientPower, strings.Join(missing, " "))
+	}
+	return nil
+}
+
+// allowedType reports whether tokens of type typ are accepted
+func (m *Middleware) allowedType(typ token.Type) bool {
+	for _, allowed := range m.config.AllowedTypes {
+		if typ == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// writeError serves err as an RFC 6750 bearer error
+func (m *Middleware) writeError(w http.ResponseWriter, req Requirements, err error) {
+	w.Header().Set("WWW-Authenticate", m.challenge(req, err))
+	ToError(err).WriteHTTP(w)
+}
+
+// challenge builds the WWW-Authenticate header value for err
+func (m *Middleware) challenge(req Requirements, err error) string {
+	params := []string{}
+	if m.config.Realm != "" {
+		params = append(params, fmt.Sprintf("realm=%q", m.config.Realm))
+	}
+	switch {
+	case errors.Is(err, ErrMissingToken):
+	case errors.Is(err, token.ErrInsufficientScope):
+		params = append(params, `error="insufficient_scope"`, fmt.Sprintf("scope=%q", strings.Join(req.Scopes, " ")))
+	case errors.Is(err, ErrInsufficientPower):
+		params = append(params, `error="insufficient_scope"`)
+	default:
+		params = append(params, `error="invalid_token"`)
+	}
+	if len(params) == 0 {
+		return "Bearer"
+	}
+	return "Bearer " + strings.Join(params, ", ")
+}
+
+// ToError maps an authentication failure to the error served to the client
+func ToError(err error) *autherrors.Error {
+	switch {
+	case errors.Is(err, ErrMissingToken):
+		return autherrors.New(autherrors.ErrInvalidToken, "a bearer token is required")
+	case errors.Is(err, token.ErrInsufficientScope):
+		return autherrors.New(autherrors.ErrInsufficientScope, "the token lacks a required scope").WithCause(err)
+	case errors.Is(err, ErrInsufficientPower):
+		return autherrors.New(autherrors.ErrAccessDenied, "the token lacks a required power of attorney").WithCause(err)
+	default:
+		return autherrors.New(autherrors.ErrInvalidToken, "invalid token").WithCause(err)
+	}
+}
+
+// BearerToken reads a token from an RFC 6750 Authorization header
+func BearerToken(r *http.Request) string {
+	scheme, value, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(value)
+}
+
+// missingScopes returns the entries of required that granted, including its
+// wildcards, does not grant
+func missingScopes(granted, required []string) []string {
+	var missing []string
+	for _, r := range required {
+		if !scope.Allows(granted, r) {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
+
+type contextKey struct{}
+
+// ContextWithToken returns ctx carrying the authenticated token and its
+// correlation ID
+func ContextWithToken(ctx context.Context, t *token.Token) context.Context {
+	return context.WithValue(token.ContextForToken(ctx, t), contextKey{}, t)
+}
+
+// TokenFromContext returns the token injected by the middleware, if any
+func TokenFromContext(ctx context.Context) (*token.Token, bool) {
+	t, ok := ctx.Value(contextKey{}).(*token.Token)
+	return t, ok && t != nil
+}
@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+func newTestMiddleware(t *testing.T) (*Middleware, token.ServiceAPI) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	tokens := token.NewService(token.Config{SigningKey: key, ValidityPeriod: time.Hour}, token.NewMemoryStore(time.Hour))
+	mw, err := New(Config{Tokens: tokens, Realm: "api"})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	return mw, tokens
+}
+
+func issue(t *testing.T, tokens token.ServiceAPI, tok *token.Token) string {
+	t.Helper()
+	issued, err := tokens.Issue(context.Background(), tok)
+	if err != nil {
+		t.Fatalf("Issue() error: %v", err)
+	}
+	return issued.Value
+}
+
+func TestMiddleware(t *testing.T) {
+	mw, tokens := newTestMiddleware(t)
+	plain := issue(t, tokens, &token.Token{ID: token.GenerateID(), Type: token.Access, Subject: "alice", Scopes: []string{"read"}})
+	poa := issue(t, tokens, &token.Token{
+		ID:      token.GenerateID(),
+		Type:    token.Access,
+		Subject: "agent-1",
+		Scopes:  []string{"read"},
+		Metadata: &token.Metadata{Delegation: &token.DelegationChain{Links: []token.DelegationLink{{
+			Principal: "alice",
+			Delegate:  "agent-1",
+			Scopes:    []string{"read", "sign_contracts"},
+			GrantedAt: time.Now(),
+		}}}},
+	})
+	refresh := issue(t, tokens, &token.Token{ID: token.GenerateID(), Type: token.Refresh, Subject: "alice", Scopes: []string{"read"}})
+
+	var seen string
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tok, found := TokenFromContext(r.Context())
+		if !found {
+			t.Fatal("TokenFromContext() found no token")
+		}
+		seen = tok.Subject
+	})
+
+	tests := []struct {
+		name      string
+		handler   http.Handler
+		auth      string
+		status    int
+		challenge string
+		subject   string
+	}{
+		{"missing token", mw.RequireToken(ok), "", http.StatusUnauthorized, `Bearer realm="api"`, ""},
+		{"malformed token", mw.RequireToken(ok), "Bearer nope", http.StatusUnauthorized, `error="invalid_token"`, ""},
+		{"refresh token", mw.RequireToken(ok), "Bearer " + refresh, http.StatusUnauthorized, `error="invalid_token"`, ""},
+		{"valid token", mw.RequireToken(ok), "Bearer " + plain, http.StatusOK, "", "alice"},
+		{"lowercase scheme", mw.RequireToken(ok), "bearer " + plain, http.StatusOK, "", "alice"},
+		{"missing scope", mw.RequireScopes("write")(ok), "Bearer " + plain, http.StatusForbidden, `scope="write"`, ""},
+		{"granted scope", mw.RequireScopes("read")(ok), "Bearer " + plain, http.StatusOK, "", "alice"},
+		{"no power of attorney", mw.RequirePowers("sign_contracts")(ok), "Bearer " + plain, http.StatusForbidden, `error="insufficient_scope"`, ""},
+		{"power not delegated", mw.RequirePowers("transfer_funds")(ok), "Bearer " + poa, http.StatusForbidden, `error="insufficient_scope"`, ""},
+		{"delegated power", mw.RequirePowers("sign_contracts")(ok), "Bearer " + poa, http.StatusOK, "", "agent-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = ""
+			req := httptest.NewRequest(http.MethodGet, "/api", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); !strings.Contains(got, tt.challenge) {
+				t.Errorf("WWW-Authenticate = %q, want it to contain %q", got, tt.challenge)
+			}
+			if seen != tt.subject {
+				t.Errorf("handler saw subject %q, want %q", seen, tt.subject)
+			}
+		})
+	}
+}
+
+func testClientCert(t *testing.T, cn string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error: %v", err)
+	}
+	return cert
+}
+
+func TestMiddlewareCertificateBoundToken(t *testing.T) {
+	mw, tokens := newTestMiddleware(t)
+	cert, other := testClientCert(t, "agent-1"), testClientCert(t, "agent-2")
+	issued, err := tokens.Issue(token.WithClientCertificate(context.Background(), cert),
+		&token.Token{ID: token.GenerateID(), Type: token.Access, Subject: "agent-1"})
+	if err != nil {
+		t.Fatalf("Issue() error: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		state  *tls.ConnectionState
+		status int
+	}{
+		{"bound certificate", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}, http.StatusOK},
+		{"other certificate", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{other}}, http.StatusUnauthorized},
+		{"no certificate", nil, http.StatusUnauthorized},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api", nil)
+			req.Header.Set("Authorization", "Bearer "+issued.Value)
+			req.TLS = tt.state
+			rec := httptest.NewRecorder()
+			mw.RequireToken(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRejectsRevokedToken(t *testing.T) {
+	mw, tokens := newTestMiddleware(t)
+	ctx := context.Background()
+	issued, err := tokens.Issue(ctx, &token.Token{ID: token.GenerateID(), Type: token.Access, Subject: "alice"})
+	if err != nil {
+		t.Fatalf("Issue() error: %v", err)
+	}
+	if err := tokens.Revoke(ctx, issued, token.ReasonCompromise); err != nil {
+		t.Fatalf("Revoke() error: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.Header.Set("Authorization", "Bearer "+issued.Value)
+	if _, err := mw.Authenticate(req, Requirements{}); err == nil {
+		t.Fatal("Authenticate() accepted a revoked token")
+	}
+}
+
+func TestGin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mw, tokens := newTestMiddleware(t)
+	value := issue(t, tokens, &token.Token{ID: token.GenerateID(), Type: token.Access, Subject: "alice", Scopes: []string{"read"}})
+
+	router := gin.New()
+	handler := func(c *gin.Context) {
+		tok, ok := GinToken(c)
+		if !ok {
+			t.Fatal("GinToken() found no token")
+		}
+		if fromCtx, ok := TokenFromContext(c.Request.Context()); !ok || fromCtx != tok {
+			t.Error("token not injected into the request context")
+		}
+		c.String(http.StatusOK, tok.Subject)
+	}
+	router.GET("/read", mw.Gin(Requirements{Scopes: []string{"read"}}), handler)
+	router.GET("/write", mw.Gin(Requirements{Scopes: []string{"write"}}), handler)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+value)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := serve("/read"); rec.Code != http.StatusOK || rec.Body.String() != "alice" {
+		t.Errorf("GET /read = %d %q, want 200 alice", rec.Code, rec.Body)
+	}
+	if rec := serve("/write"); rec.Code != http.StatusForbidden {
+		t.Errorf("GET /write = %d, want 403", rec.Code)
+	}
+}
@@ -0,0 +1,176 @@
+package monitoring
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Gimel-Foundation/gauth/pkg/metrics"
+)
+
+// AlertRulesConfig configures AlertRules
+type AlertRulesConfig struct {
+	// Group names the rule group (default: "gauth")
+	Group string
+
+	// Selector is added to every query, such as `job="gauth"` (optional)
+	Selector string
+
+	// RateInterval is the range of rate() and histogram queries
+	// (default: "5m")
+	RateInterval string
+
+	// AuthFailureRatio is the share of failed authentication attempts that
+	// alerts (default: 0.25)
+	AuthFailureRatio float64
+
+	// ValidationErrorRate is the token validation errors per second that
+	// alert (default: 1)
+	ValidationErrorRate float64
+
+	// AuthLatency is the 95th percentile authentication latency that alerts
+	// (default: 1s)
+	AuthLatency time.Duration
+
+	// ClockDrift is the clock offset from a reference that alerts
+	// (default: 2s)
+	ClockDrift time.Duration
+
+	// ExpiryBacklog is the number of expired tokens a store may hold before
+	// cleanup is considered stuck (default: 10000)
+	ExpiryBacklog int
+}
+
+// alertRule is a Prometheus alerting rule
+type alertRule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+type ruleGroup struct {
+	Name  string      `yaml:"name"`
+	Rules []alertRule `yaml:"rules"`
+}
+
+type ruleFile struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+// AlertRules builds Prometheus alerting rules for the given metrics,
+// usually metrics.Definitions(), as a rule file ready to load. Rules are
+// only emitted for metrics in defs: a high authentication failure ratio or
+// latency, token validation errors, an open circuit breaker, a growing
+// token expiry backlog, clock drift and store divergence.
+func AlertRules(defs []metrics.Definition, config AlertRulesConfig) ([]byte, error) {
+	if config.Group == "" {
+		config.Group = "gauth"
+	}
+	if config.RateInterval == "" {
+		config.RateInterval = "5m"
+	}
+	if config.AuthFailureRatio <= 0 {
+		config.AuthFailureRatio = 0.25
+	}
+	if config.ValidationErrorRate <= 0 {
+		config.ValidationErrorRate = 1
+	}
+	if config.AuthLatency <= 0 {
+		config.AuthLatency = time.Second
+	}
+	if config.ClockDrift <= 0 {
+		config.ClockDrift = 2 * time.Second
+	}
+	if config.ExpiryBacklog <= 0 {
+		config.ExpiryBacklog = 10000
+	}
+	q := querier{selector: config.Selector, interval: config.RateInterval}
+	has := definedMetrics(defs)
+
+	var rules []alertRule
+	add := func(metric string, rule alertRule) {
+		if has[metric] {
+			rules = append(rules, rule)
+		}
+	}
+	add(metricAuthAttempts, alertRule{
+		Alert:  "GAuthHighAuthFailureRatio",
+		Expr:   fmt.Sprintf("%s > %g", q.failureRatio(metricAuthAttempts, "status"), config.AuthFailureRatio),
+		For:    "10m",
+		Labels: severity(SeverityWarning),
+		Annotations: annotations("Authentication failure ratio is high",
+			"{{ $value | humanizePercentage }} of authentication attempts failed over the last "+config.RateInterval+"."),
+	})
+	add(metricAuthLatency, alertRule{
+		Alert:  "GAuthSlowAuthentication",
+		Expr:   fmt.Sprintf("%s > %g", q.quantile(0.95, metricAuthLatency), config.AuthLatency.Seconds()),
+		For:    "10m",
+		Labels: severity(SeverityWarning),
+		Annotations: annotations("Authentication is slow",
+			"95th percentile authentication latency is {{ $value | humanizeDuration }}."),
+	})
+	add(metricValidationErrors, alertRule{
+		Alert:  "GAuthTokenValidationErrors",
+		Expr:   fmt.Sprintf("sum by (error) (%s) > %g", q.rate(metricValidationErrors), config.ValidationErrorRate),
+		For:    "10m",
+		Labels: severity(SeverityWarning),
+		Annotations: annotations("Tokens fail validation",
+			"{{ $value | humanize }} tokens per second fail validation with {{ $labels.error }}."),
+	})
+	add(metricCircuitState, alertRule{
+		Alert:  "GAuthCircuitOpen",
+		Expr:   fmt.Sprintf("max by (breaker) (%s) == 1", q.metric(metricCircuitState)),
+		For:    "5m",
+		Labels: severity(SeverityCritical),
+		Annotations: annotations("Circuit breaker is open",
+			"Circuit breaker {{ $labels.breaker }} has been open for 5 minutes; calls to its dependency are rejected."),
+	})
+	add(metricExpiryBacklog, alertRule{
+		Alert: "GAuthTokenExpiryBacklog",
+		Expr: fmt.Sprintf("%s > %d and delta(%s[1h]) > 0",
+			q.metric(metricExpiryBacklog), config.ExpiryBacklog, q.metric(metricExpiryBacklog)),
+		For:    "30m",
+		Labels: severity(SeverityWarning),
+		Annotations: annotations("Expired tokens are not cleaned up",
+			"Store {{ $labels.store }} holds {{ $value | humanize }} expired tokens and the backlog keeps growing."),
+	})
+	add(metricClockDrift, alertRule{
+		Alert:  "GAuthClockDrift",
+		Expr:   fmt.Sprintf("abs(%s) > %g", q.metric(metricClockDrift), config.ClockDrift.Seconds()),
+		For:    "5m",
+		Labels: severity(SeverityCritical),
+		Annotations: annotations("Clock drifts from its reference",
+			"The clock is {{ $value | humanizeDuration }} off {{ $labels.reference }}; token lifetimes are evaluated wrongly."),
+	})
+	add(metricStoreDivergence, alertRule{
+		Alert:  "GAuthStoreDivergence",
+		Expr:   fmt.Sprintf("%s > 0", q.metric(metricStoreDivergence)),
+		For:    "15m",
+		Labels: severity(SeverityWarning),
+		Annotations: annotations("Replicated token stores diverge",
+			"{{ $value | humanize }} tokens differed between the primary and secondary of store {{ $labels.store }} at the last reconciliation."),
+	})
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(ruleFile{Groups: []ruleGroup{{Name: config.Group, Rules: rules}}}); err != nil {
+		return nil, fmt.Errorf("failed to encode alert rules: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode alert rules: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func severity(s Severity) map[string]string {
+	return map[string]string{"severity": string(s)}
+}
+
+func annotations(summary, description string) map[string]string {
+	return map[string]string{"summary": summary, "description": description}
+}
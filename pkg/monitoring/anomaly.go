@@ -0,0 +1,191 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// Severity ranks anomalies
+type Severity string
+
+const (
+	// SeverityWarning marks unusual activity worth a look
+	SeverityWarning Severity = "warning"
+
+	// SeverityCritical marks activity that indicates an attack or a leaked
+	// credential
+	SeverityCritical Severity = "critical"
+)
+
+// Anomaly is suspicious activity a rule found in the event stream
+type Anomaly struct {
+	// Rule is the name of the rule that found it
+	Rule string
+
+	// Severity ranks the anomaly
+	Severity Severity
+
+	// Key is what the anomaly concerns, such as a subject, token ID or
+	// client address; alerts for the same rule and key are rate limited
+	Key string
+
+	// Message describes the anomaly
+	Message string
+
+	// Trigger is the event that revealed it
+	Trigger events.Event
+}
+
+// AnomalyRule inspects events for one kind of anomaly. Rules keep their own
+// state and are called one event at a time.
+type AnomalyRule interface {
+	// Name identifies the rule in alerts
+	Name() string
+
+	// Evaluate inspects an event and returns the anomaly it reveals, or nil
+	Evaluate(event events.Event) *Anomaly
+}
+
+// AnomalyRuleFunc adapts a function to the AnomalyRule interface
+type AnomalyRuleFunc struct {
+	RuleName string
+	Fn       func(event events.Event) *Anomaly
+}
+
+// Name implements AnomalyRule
+func (f AnomalyRuleFunc) Name() string { return f.RuleName }
+
+// Evaluate implements AnomalyRule
+func (f AnomalyRuleFunc) Evaluate(event events.Event) *Anomaly { return f.Fn(event) }
+
+// AnomalyConfig configures an AnomalyDetector
+type AnomalyConfig struct {
+	// Rules are evaluated against every event
+	Rules []AnomalyRule
+
+	// Alerts receive an alert_triggered system event per anomaly, such as
+	// the event bus and a CloudEventSender posting to a security webhook.
+	// They are called from Handle; wrap slow ones in an AsyncPublisher.
+	Alerts []events.Publisher
+
+	// Cooldown suppresses further alerts of a rule for the same key
+	// (default: 5m)
+	Cooldown time.Duration
+
+	// OnAnomaly is called for every alert raised (optional)
+	OnAnomaly func(Anomaly)
+
+	// OnError receives failures to publish alerts (optional)
+	OnError func(error)
+}
+
+// AnomalyDetector evaluates events against rules, such as a spike of failed
+// validations, impossible travel or replay of revoked tokens, and raises a
+// security alert for each anomaly. Subscribe it to the event bus:
+//
+//	bus.Subscribe(detector)
+//
+// Alert events are ignored when they come back through the bus.
+type AnomalyDetector struct {
+	config AnomalyConfig
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+}
+
+var _ events.EventHandler = (*AnomalyDetector)(nil)
+
+// NewAnomalyDetector creates an anomaly detector
+func NewAnomalyDetector(config AnomalyConfig) (*AnomalyDetector, error) {
+	if len(config.Rules) == 0 {
+		return nil, fmt.Errorf("anomaly detector needs at least one rule")
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = 5 * time.Minute
+	}
+	return &AnomalyDetector{config: config, lastSent: make(map[string]time.Time)}, nil
+}
+
+// Handle implements events.EventHandler
+func (d *AnomalyDetector) Handle(event events.Event) {
+	if event.Type == events.EventTypeSystem && event.Action == string(events.ActionAlertTriggered) {
+		return
+	}
+
+	d.mu.Lock()
+	var found []Anomaly
+	for _, rule := range d.config.Rules {
+		a := rule.Evaluate(event)
+		if a == nil {
+			continue
+		}
+		a.Rule, a.Trigger = rule.Name(), event
+		if d.coolingDown(a, eventTime(event)) {
+			continue
+		}
+		found = append(found, *a)
+	}
+	d.mu.Unlock()
+
+	for _, a := range found {
+		d.alert(a)
+	}
+}
+
+// coolingDown reports whether the rule alerted for the key within the
+// cooldown, and otherwise starts a new one; d.mu must be held
+func (d *AnomalyDetector) coolingDown(a *Anomaly, now time.Time) bool {
+	key := a.Rule + "\x00" + a.Key
+	if last, ok := d.lastSent[key]; ok && now.Sub(last) < d.config.Cooldown {
+		return true
+	}
+	d.lastSent[key] = now
+
+	// forget expired cooldowns once they could make up most of the map
+	if len(d.lastSent) > 1024 {
+		for k, last := range d.lastSent {
+			if now.Sub(last) >= d.config.Cooldown {
+				delete(d.lastSent, k)
+			}
+		}
+	}
+	return false
+}
+
+// alert publishes an anomaly as an alert_triggered system event
+func (d *AnomalyDetector) alert(a Anomaly) {
+	status := events.StatusWarning
+	if a.Severity == SeverityCritical {
+		status = events.StatusFailure
+	}
+	event := events.NewSystemEvent(events.ActionAlertTriggered, status).
+		WithSubject(a.Trigger.Subject).
+		WithResource(a.Key).
+		WithMessage(a.Message).
+		WithCorrelationID(a.Trigger.CorrelationID).
+		WithStringMetadata("rule", a.Rule).
+		WithStringMetadata("severity", string(a.Severity)).
+		WithStringMetadata("trigger_id", a.Trigger.ID).
+		WithStringMetadata("trigger_action", a.Trigger.Action)
+
+	if d.config.OnAnomaly != nil {
+		d.config.OnAnomaly(a)
+	}
+	for _, p := range d.config.Alerts {
+		if err := p.PublishContext(context.Background(), event); err != nil && d.config.OnError != nil {
+			d.config.OnError(fmt.Errorf("failed to publish %s alert: %w", a.Rule, err))
+		}
+	}
+}
+
+// eventTime is when an event happened, so rules work on replayed streams
+func eventTime(event events.Event) time.Time {
+	if event.Timestamp.IsZero() {
+		return time.Now()
+	}
+	return event.Timestamp
+}
@@ -0,0 +1,335 @@
+package monitoring
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// Metadata keys the built-in rules read by default
+const (
+	// MetadataLatitude and MetadataLongitude locate where an event
+	// happened, in degrees
+	MetadataLatitude  = "latitude"
+	MetadataLongitude = "longitude"
+
+	// MetadataTokenID names the token an event concerns when its Resource
+	// does not
+	MetadataTokenID = "token_id"
+)
+
+// FailureSpikeConfig configures a FailureSpikeRule
+type FailureSpikeConfig struct {
+	// Actions are the failures counted
+	// (default: token_validation_failed and login_failed)
+	Actions []events.EventAction
+
+	// Threshold is how many failures within Window are a spike
+	// (default: 20)
+	Threshold int
+
+	// Window is the period failures are counted over (default: 1m)
+	Window time.Duration
+
+	// GroupBy is the metadata key failures are counted per, such as
+	// "ip_address"; empty counts all failures together
+	GroupBy string
+}
+
+// FailureSpikeRule finds bursts of failures, such as credential stuffing
+// or a client probing for valid tokens
+type FailureSpikeRule struct {
+	config  FailureSpikeConfig
+	actions map[string]bool
+
+	mu      sync.Mutex
+	windows map[string]*spikeWindow
+}
+
+// spikeWindow counts the failures of a key in its current window
+type spikeWindow struct {
+	start time.Time
+	count int
+}
+
+var _ AnomalyRule = (*FailureSpikeRule)(nil)
+
+// NewFailureSpikeRule creates a failure spike rule
+func NewFailureSpikeRule(config FailureSpikeConfig) *FailureSpikeRule {
+	if len(config.Actions) == 0 {
+		config.Actions = []events.EventAction{events.ActionTokenValidationFailed, events.ActionLoginFailed}
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = 20
+	}
+	if config.Window <= 0 {
+		config.Window = time.Minute
+	}
+	actions := make(map[string]bool, len(config.Actions))
+	for _, a := range config.Actions {
+		actions[string(a)] = true
+	}
+	return &FailureSpikeRule{config: config, actions: actions, windows: make(map[string]*spikeWindow)}
+}
+
+// Name implements AnomalyRule
+func (r *FailureSpikeRule) Name() string { return "failure_spike" }
+
+// Evaluate implements AnomalyRule
+func (r *FailureSpikeRule) Evaluate(event events.Event) *Anomaly {
+	if !r.actions[event.Action] {
+		return nil
+	}
+	key := "all"
+	if r.config.GroupBy != "" {
+		key = metadataString(event, r.config.GroupBy)
+		if key == "" {
+			return nil
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := eventTime(event)
+	w, ok := r.windows[key]
+	if !ok || now.Sub(w.start) >= r.config.Window {
+		if !ok && len(r.windows) > 1024 {
+			r.prune(now)
+		}
+		w = &spikeWindow{start: now}
+		r.windows[key] = w
+	}
+	w.count++
+	if w.count < r.config.Threshold {
+		return nil
+	}
+	return &Anomaly{
+		Severity: SeverityWarning,
+		Key:      key,
+		Message:  fmt.Sprintf("%d failures within %s", w.count, r.config.Window),
+	}
+}
+
+// prune drops the windows that have ended; r.mu must be held
+func (r *FailureSpikeRule) prune(now time.Time) {
+	for k, w := range r.windows {
+		if now.Sub(w.start) >= r.config.Window {
+			delete(r.windows, k)
+		}
+	}
+}
+
+// ImpossibleTravelConfig configures an ImpossibleTravelRule
+type ImpossibleTravelConfig struct {
+	// MaxSpeed is the fastest plausible travel in km/h (default: 1000)
+	MaxSpeed float64
+
+	// MinDistance is the shortest jump in km considered, since IP
+	// geolocation is imprecise (default: 100)
+	MinDistance float64
+
+	// MaxAge is how long a subject's last location is remembered
+	// (default: 24h)
+	MaxAge time.Duration
+
+	// Locate returns where an event happened (default: the latitude and
+	// longitude metadata)
+	Locate func(event events.Event) (lat, lon float64, ok bool)
+}
+
+// ImpossibleTravelRule finds a subject active at two places further apart
+// than anyone could travel in between, which suggests shared or stolen
+// credentials
+type ImpossibleTravelRule struct {
+	config ImpossibleTravelConfig
+
+	mu   sync.Mutex
+	last map[string]sighting
+}
+
+// sighting is where and when a subject was last seen
+type sighting struct {
+	lat, lon float64
+	at       time.Time
+}
+
+var _ AnomalyRule = (*ImpossibleTravelRule)(nil)
+
+// NewImpossibleTravelRule creates an impossible travel rule
+func NewImpossibleTravelRule(config ImpossibleTravelConfig) *ImpossibleTravelRule {
+	if config.MaxSpeed <= 0 {
+		config.MaxSpeed = 1000
+	}
+	if config.MinDistance <= 0 {
+		config.MinDistance = 100
+	}
+	if config.MaxAge <= 0 {
+		config.MaxAge = 24 * time.Hour
+	}
+	if config.Locate == nil {
+		config.Locate = metadataLocation
+	}
+	return &ImpossibleTravelRule{config: config, last: make(map[string]sighting)}
+}
+
+// Name implements AnomalyRule
+func (r *ImpossibleTravelRule) Name() string { return "impossible_travel" }
+
+// Evaluate implements AnomalyRule
+func (r *ImpossibleTravelRule) Evaluate(event events.Event) *Anomaly {
+	if event.Subject == "" {
+		return nil
+	}
+	lat, lon, ok := r.config.Locate(event)
+	if !ok {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := eventTime(event)
+	prev, seen := r.last[event.Subject]
+	if !seen && len(r.last) > 1024 {
+		for k, s := range r.last {
+			if now.Sub(s.at) >= r.config.MaxAge {
+				delete(r.last, k)
+			}
+		}
+	}
+	r.last[event.Subject] = sighting{lat: lat, lon: lon, at: now}
+	if !seen || now.Sub(prev.at) >= r.config.MaxAge {
+		return nil
+	}
+
+	distance := haversine(prev.lat, prev.lon, lat, lon)
+	if distance < r.config.MinDistance {
+		return nil
+	}
+	hours := math.Abs(now.Sub(prev.at).Hours())
+	if hours > 0 && distance/hours <= r.config.MaxSpeed {
+		return nil
+	}
+	return &Anomaly{
+		Severity: SeverityCritical,
+		Key:      event.Subject,
+		Message:  fmt.Sprintf("%.0f km from the previous location within %s", distance, now.Sub(prev.at).Round(time.Second)),
+	}
+}
+
+// RevokedReplayConfig configures a RevokedReplayRule
+type RevokedReplayConfig struct {
+	// Retention is how long revoked token IDs are remembered (default: 24h)
+	Retention time.Duration
+
+	// TokenID returns the token an event concerns (default: the token_id
+	// metadata, or else the Resource)
+	TokenID func(event events.Event) string
+}
+
+// RevokedReplayRule finds tokens presented again after they were revoked,
+// which suggests a copy of the token is in other hands. It learns the
+// revoked tokens from token_revoked events.
+type RevokedReplayRule struct {
+	config RevokedReplayConfig
+
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+var _ AnomalyRule = (*RevokedReplayRule)(nil)
+
+// NewRevokedReplayRule creates a revoked token replay rule
+func NewRevokedReplayRule(config RevokedReplayConfig) *RevokedReplayRule {
+	if config.Retention <= 0 {
+		config.Retention = 24 * time.Hour
+	}
+	if config.TokenID == nil {
+		config.TokenID = metadataTokenID
+	}
+	return &RevokedReplayRule{config: config, revoked: make(map[string]time.Time)}
+}
+
+// Name implements AnomalyRule
+func (r *RevokedReplayRule) Name() string { return "revoked_token_replay" }
+
+// Evaluate implements AnomalyRule
+func (r *RevokedReplayRule) Evaluate(event events.Event) *Anomaly {
+	if event.Type != events.EventTypeToken {
+		return nil
+	}
+	id := r.config.TokenID(event)
+	if id == "" {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := eventTime(event)
+	if event.Action == string(events.ActionTokenRevoked) {
+		if event.Status == string(events.StatusSuccess) {
+			if len(r.revoked) > 1024 {
+				for k, at := range r.revoked {
+					if now.Sub(at) >= r.config.Retention {
+						delete(r.revoked, k)
+					}
+				}
+			}
+			r.revoked[id] = now
+		}
+		return nil
+	}
+	revokedAt, ok := r.revoked[id]
+	if !ok || now.Sub(revokedAt) >= r.config.Retention || now.Before(revokedAt) {
+		return nil
+	}
+	return &Anomaly{
+		Severity: SeverityCritical,
+		Key:      id,
+		Message:  fmt.Sprintf("token %s used (%s) %s after its revocation", id, event.Action, now.Sub(revokedAt).Round(time.Second)),
+	}
+}
+
+// metadataString returns a string metadata value of an event
+func metadataString(event events.Event, key string) string {
+	if event.Metadata == nil {
+		return ""
+	}
+	s, _ := event.Metadata.GetString(key)
+	return s
+}
+
+// metadataLocation reads the latitude and longitude metadata of an event
+func metadataLocation(event events.Event) (float64, float64, bool) {
+	if event.Metadata == nil {
+		return 0, 0, false
+	}
+	lat, ok := event.Metadata.GetFloat(MetadataLatitude)
+	if !ok {
+		return 0, 0, false
+	}
+	lon, ok := event.Metadata.GetFloat(MetadataLongitude)
+	return lat, lon, ok
+}
+
+// metadataTokenID reads the token_id metadata of an event, or else its
+// Resource
+func metadataTokenID(event events.Event) string {
+	if id := metadataString(event, MetadataTokenID); id != "" {
+		return id
+	}
+	return event.Resource
+}
+
+// haversine returns the great-circle distance in km between two points
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371.0
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
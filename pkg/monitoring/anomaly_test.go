@@ -0,0 +1,171 @@
+package monitoring
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// alertRecorder collects the alert events published on a bus
+type alertRecorder struct {
+	mu     sync.Mutex
+	alerts []events.Event
+}
+
+func (r *alertRecorder) Handle(e events.Event) {
+	if e.Action != string(events.ActionAlertTriggered) {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = append(r.alerts, e)
+}
+
+func (r *alertRecorder) rules() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var rules []string
+	for _, e := range r.alerts {
+		rule, _ := e.Metadata.GetString("rule")
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func TestAnomalyDetector(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(e events.Event, offset time.Duration) events.Event {
+		e.Timestamp = start.Add(offset)
+		return e
+	}
+	located := func(subject string, lat, lon float64, offset time.Duration) events.Event {
+		e := at(events.NewAuthEvent(events.ActionLogin, events.StatusSuccess).WithSubject(subject), offset)
+		e.Metadata.SetFloat(MetadataLatitude, lat)
+		e.Metadata.SetFloat(MetadataLongitude, lon)
+		return e
+	}
+
+	newDetector := func(t *testing.T, rules ...AnomalyRule) (*events.EventBus, *alertRecorder) {
+		t.Helper()
+		bus := events.NewEventBus()
+		detector, err := NewAnomalyDetector(AnomalyConfig{Rules: rules, Alerts: []events.Publisher{bus}})
+		if err != nil {
+			t.Fatalf("NewAnomalyDetector() error: %v", err)
+		}
+		recorder := &alertRecorder{}
+		bus.Subscribe(detector)
+		bus.Subscribe(recorder)
+		return bus, recorder
+	}
+
+	t.Run("Failed Validation Spike", func(t *testing.T) {
+		bus, recorder := newDetector(t, NewFailureSpikeRule(FailureSpikeConfig{Threshold: 5, GroupBy: "ip_address"}))
+		failure := func(ip string, offset time.Duration) events.Event {
+			return at(events.NewTokenEvent(events.ActionTokenValidationFailed, events.StatusFailure).
+				WithStringMetadata("ip_address", ip), offset)
+		}
+
+		for i := 0; i < 4; i++ {
+			bus.Publish(failure("203.0.113.7", time.Duration(i)*time.Second))
+			bus.Publish(failure("198.51.100.2", time.Duration(i)*time.Second))
+		}
+		bus.Publish(failure("203.0.113.7", 70*time.Second))
+		if alerts := recorder.rules(); len(alerts) != 0 {
+			t.Fatalf("Expected no alert below the threshold or across windows, got %v", alerts)
+		}
+
+		for i := 0; i < 10; i++ {
+			bus.Publish(failure("203.0.113.7", 71*time.Second+time.Duration(i)*time.Second))
+		}
+		if alerts := recorder.rules(); len(alerts) != 1 || alerts[0] != "failure_spike" {
+			t.Fatalf("Expected one alert within the cooldown, got %v", alerts)
+		}
+		alert := recorder.alerts[0]
+		if alert.Type != events.EventTypeSystem || alert.Resource != "203.0.113.7" || alert.Status != string(events.StatusWarning) {
+			t.Errorf("Unexpected alert event %+v", alert)
+		}
+	})
+
+	t.Run("Impossible Travel", func(t *testing.T) {
+		bus, recorder := newDetector(t, NewImpossibleTravelRule(ImpossibleTravelConfig{}))
+
+		bus.Publish(located("alice", 52.52, 13.40, 0))             // Berlin
+		bus.Publish(located("alice", 48.14, 11.58, 2*time.Hour))   // Munich, 500 km later
+		bus.Publish(located("bob", 40.71, -74.01, 0))              // New York
+		bus.Publish(located("alice", 48.14, 11.59, 2*time.Hour+1)) // same city
+		bus.Publish(located("alice", 35.68, 139.69, 3*time.Hour))  // Tokyo an hour later
+		if alerts := recorder.rules(); len(alerts) != 1 || alerts[0] != "impossible_travel" {
+			t.Fatalf("Expected one impossible travel alert, got %v", alerts)
+		}
+		if alert := recorder.alerts[0]; alert.Subject != "alice" || alert.Status != string(events.StatusFailure) {
+			t.Errorf("Expected a critical alert for alice, got %+v", alert)
+		}
+	})
+
+	t.Run("Revoked Token Replay To Webhook", func(t *testing.T) {
+		received := make(chan events.Event, 4)
+		webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			batch, err := events.ReadCloudEvents(r, events.CloudEventOptions{})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for _, e := range batch {
+				received <- e
+			}
+		}))
+		defer webhook.Close()
+		sender, err := events.NewCloudEventSender(events.CloudEventSenderConfig{URL: webhook.URL})
+		if err != nil {
+			t.Fatalf("NewCloudEventSender() error: %v", err)
+		}
+		detector, err := NewAnomalyDetector(AnomalyConfig{
+			Rules:   []AnomalyRule{NewRevokedReplayRule(RevokedReplayConfig{})},
+			Alerts:  []events.Publisher{sender},
+			OnError: func(err error) { t.Errorf("alert not delivered: %v", err) },
+		})
+		if err != nil {
+			t.Fatalf("NewAnomalyDetector() error: %v", err)
+		}
+
+		detector.Handle(at(events.NewTokenEvent(events.ActionTokenValidated, events.StatusSuccess).WithResource("tok-1"), 0))
+		detector.Handle(at(events.NewTokenEvent(events.ActionTokenRevoked, events.StatusFailure).WithResource("tok-2"), 0))
+		detector.Handle(at(events.NewTokenEvent(events.ActionTokenRevoked, events.StatusSuccess).WithResource("tok-1"), time.Minute))
+		detector.Handle(at(events.NewTokenEvent(events.ActionTokenValidated, events.StatusSuccess).WithResource("tok-2"), 2*time.Minute))
+		detector.Handle(at(events.NewTokenEvent(events.ActionTokenIntrospected, events.StatusSuccess).
+			WithStringMetadata(MetadataTokenID, "tok-1").WithResource("/introspect"), 2*time.Minute))
+
+		select {
+		case alert := <-received:
+			if rule, _ := alert.Metadata.GetString("rule"); rule != "revoked_token_replay" || alert.Resource != "tok-1" {
+				t.Errorf("Unexpected alert %+v", alert)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected an alert at the webhook")
+		}
+		if len(received) != 0 {
+			t.Errorf("Expected exactly one alert, got %d more", len(received))
+		}
+	})
+
+	t.Run("Custom Rule", func(t *testing.T) {
+		bus, recorder := newDetector(t, AnomalyRuleFunc{RuleName: "admin_login", Fn: func(e events.Event) *Anomaly {
+			if e.Action == string(events.ActionLogin) && e.Subject == "root" {
+				return &Anomaly{Severity: SeverityWarning, Key: e.Subject, Message: "root logged in"}
+			}
+			return nil
+		}})
+		bus.Publish(located("root", 0, 0, 0))
+		if alerts := recorder.rules(); len(alerts) != 1 || alerts[0] != "admin_login" {
+			t.Errorf("Expected the custom rule to alert, got %v", alerts)
+		}
+	})
+
+	if _, err := NewAnomalyDetector(AnomalyConfig{}); err == nil {
+		t.Error("Expected an error without rules")
+	}
+}
@@ -0,0 +1,265 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Gimel-Foundation/gauth/pkg/metrics"
+)
+
+// Metrics the generated dashboards and alerting rules treat specially
+const (
+	metricAuthAttempts     = "gauth_authentication_attempts_total"
+	metricAuthLatency      = "gauth_authentication_duration_seconds"
+	metricValidationErrors = "gauth_token_validation_errors_total"
+	metricCircuitState     = "gauth_circuit_state"
+	metricExpiryBacklog    = "gauth_token_expiry_backlog"
+	metricClockDrift       = "gauth_clock_drift_seconds"
+	metricStoreDivergence  = "gauth_store_divergence"
+)
+
+// DashboardConfig configures GrafanaDashboard
+type DashboardConfig struct {
+	// Title of the dashboard (default: "GAuth")
+	Title string
+
+	// UID identifies the dashboard in Grafana, so importing it again
+	// replaces the previous version (default: "gauth")
+	UID string
+
+	// Selector is added to every query, such as `job="gauth"` (optional)
+	Selector string
+
+	// RateInterval is the range of rate() and histogram queries
+	// (default: "5m")
+	RateInterval string
+}
+
+// GrafanaDashboard builds a dashboard for the given metrics, usually
+// metrics.Definitions(), as JSON ready to import into Grafana. Overview
+// panels for the authentication failure ratio, open circuit breakers and
+// the token expiry backlog come first, followed by one panel per metric:
+// request rates for counters, quantiles for histograms and current values
+// for gauges. The Prometheus datasource is chosen on import.
+func GrafanaDashboard(defs []metrics.Definition, config DashboardConfig) ([]byte, error) {
+	if config.Title == "" {
+		config.Title = "GAuth"
+	}
+	if config.UID == "" {
+		config.UID = "gauth"
+	}
+	if config.RateInterval == "" {
+		config.RateInterval = "5m"
+	}
+	q := querier{selector: config.Selector, interval: config.RateInterval}
+	has := definedMetrics(defs)
+
+	layout := &panelLayout{}
+	if has[metricAuthAttempts] {
+		layout.add(stat("Authentication failure ratio", "percentunit",
+			q.failureRatio(metricAuthAttempts, "status")), 8, 4)
+	}
+	if has[metricCircuitState] {
+		layout.add(stat("Open circuit breakers", "short",
+			fmt.Sprintf("count(%s == 1) or vector(0)", q.metric(metricCircuitState))), 8, 4)
+	}
+	if has[metricExpiryBacklog] {
+		layout.add(stat("Expired tokens awaiting cleanup", "short",
+			fmt.Sprintf("sum(%s)", q.metric(metricExpiryBacklog))), 8, 4)
+	}
+	layout.row("Metrics")
+	for _, def := range defs {
+		layout.add(q.panel(def), 12, 8)
+	}
+
+	dashboard := map[string]any{
+		"title":         config.Title,
+		"uid":           config.UID,
+		"tags":          []string{"gauth"},
+		"schemaVersion": 39,
+		"refresh":       "30s",
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"templating": map[string]any{"list": []any{map[string]any{
+			"name":  "datasource",
+			"label": "Datasource",
+			"type":  "datasource",
+			"query": "prometheus",
+		}}},
+		"panels": layout.panels,
+	}
+	data, err := json.MarshalIndent(dashboard, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode dashboard: %w", err)
+	}
+	return data, nil
+}
+
+// panelLayout places panels left to right in a 24 column grid
+type panelLayout struct {
+	panels  []map[string]any
+	x, y, h int
+}
+
+func (l *panelLayout) add(panel map[string]any, w, h int) {
+	if l.x+w > 24 {
+		l.x, l.y, l.h = 0, l.y+l.h, 0
+	}
+	panel["id"] = len(l.panels) + 1
+	panel["gridPos"] = map[string]int{"x": l.x, "y": l.y, "w": w, "h": h}
+	l.panels = append(l.panels, panel)
+	l.x += w
+	l.h = max(l.h, h)
+}
+
+func (l *panelLayout) row(title string) {
+	if l.x > 0 {
+		l.x, l.y, l.h = 0, l.y+l.h, 0
+	}
+	l.add(map[string]any{"type": "row", "title": title, "collapsed": false}, 24, 1)
+}
+
+// querier writes PromQL for the generated panels and rules
+type querier struct {
+	selector string
+	interval string
+}
+
+// metric selects a metric with the given label matchers and the configured
+// selector
+func (q querier) metric(name string, matchers ...string) string {
+	if q.selector != "" {
+		matchers = append(matchers, q.selector)
+	}
+	if len(matchers) == 0 {
+		return name
+	}
+	return name + "{" + strings.Join(matchers, ",") + "}"
+}
+
+func (q querier) rate(name string, matchers ...string) string {
+	return fmt.Sprintf("rate(%s[%s])", q.metric(name, matchers...), q.interval)
+}
+
+// failureRatio is the share of a counter's events whose label is "failure"
+func (q querier) failureRatio(name, label string) string {
+	return fmt.Sprintf("sum(%s) / sum(%s)", q.rate(name, label+`="failure"`), q.rate(name))
+}
+
+// quantile estimates a quantile of a histogram, per value of the given labels
+func (q querier) quantile(phi float64, name string, by ...string) string {
+	return fmt.Sprintf("histogram_quantile(%g, sum by (%s) (%s))",
+		phi, strings.Join(append([]string{"le"}, by...), ", "), q.rate(name+"_bucket"))
+}
+
+// panel builds the panel of one metric according to its type
+func (q querier) panel(def metrics.Definition) map[string]any {
+	legend := legendFormat(def.Labels)
+	var targets []map[string]any
+	panel := map[string]any{
+		"type":        "timeseries",
+		"title":       def.Name,
+		"description": def.Help,
+		"datasource":  datasource(),
+	}
+
+	switch def.Type {
+	case metrics.TypeCounter:
+		expr := fmt.Sprintf("sum(%s)", q.rate(def.Name))
+		if len(def.Labels) > 0 {
+			expr = fmt.Sprintf("sum by (%s) (%s)", strings.Join(def.Labels, ", "), q.rate(def.Name))
+		}
+		targets = append(targets, target(expr, legend))
+		panel["fieldConfig"] = fieldConfig("ops")
+	case metrics.TypeHistogram:
+		for _, phi := range []float64{0.5, 0.95, 0.99} {
+			targets = append(targets, target(q.quantile(phi, def.Name, def.Labels...),
+				strings.TrimSpace(fmt.Sprintf("p%g %s", phi*100, legend))))
+		}
+		panel["fieldConfig"] = fieldConfig(unitOf(def.Name))
+	default:
+		targets = append(targets, target(q.metric(def.Name), legend))
+		panel["fieldConfig"] = fieldConfig(unitOf(def.Name))
+		if def.Name == metricCircuitState {
+			panel["type"] = "state-timeline"
+			panel["fieldConfig"] = circuitStateFieldConfig()
+		}
+	}
+
+	for i, t := range targets {
+		t["refId"] = string(rune('A' + i))
+	}
+	panel["targets"] = targets
+	return panel
+}
+
+func stat(title, unit, expr string) map[string]any {
+	return map[string]any{
+		"type":        "stat",
+		"title":       title,
+		"datasource":  datasource(),
+		"fieldConfig": fieldConfig(unit),
+		"targets":     []map[string]any{target(expr, "")},
+	}
+}
+
+func target(expr, legend string) map[string]any {
+	t := map[string]any{"refId": "A", "expr": expr, "datasource": datasource()}
+	if legend != "" {
+		t["legendFormat"] = legend
+	}
+	return t
+}
+
+func datasource() map[string]string {
+	return map[string]string{"type": "prometheus", "uid": "${datasource}"}
+}
+
+func fieldConfig(unit string) map[string]any {
+	return map[string]any{"defaults": map[string]any{"unit": unit}, "overrides": []any{}}
+}
+
+// circuitStateFieldConfig names and colours the states of gauth_circuit_state
+func circuitStateFieldConfig() map[string]any {
+	state := func(text, color string) map[string]string {
+		return map[string]string{"text": text, "color": color}
+	}
+	return map[string]any{
+		"defaults": map[string]any{
+			"mappings": []any{map[string]any{
+				"type": "value",
+				"options": map[string]any{
+					"0": state("closed", "green"),
+					"1": state("open", "red"),
+					"2": state("half-open", "yellow"),
+				},
+			}},
+		},
+		"overrides": []any{},
+	}
+}
+
+// legendFormat shows the label values of a series
+func legendFormat(labels []string) string {
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = "{{" + l + "}}"
+	}
+	return strings.Join(parts, " ")
+}
+
+// unitOf derives the Grafana unit from the metric name suffix
+func unitOf(name string) string {
+	if strings.HasSuffix(name, "_seconds") {
+		return "s"
+	}
+	return "short"
+}
+
+func definedMetrics(defs []metrics.Definition) map[string]bool {
+	has := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		has[def.Name] = true
+	}
+	return has
+}
@@ -0,0 +1,132 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Gimel-Foundation/gauth/pkg/metrics"
+)
+
+func TestGrafanaDashboard(t *testing.T) {
+	defs := metrics.Definitions()
+	byName := make(map[string]metrics.Definition)
+	for _, def := range defs {
+		byName[def.Name] = def
+	}
+	if def := byName["gauth_token_operations_total"]; def.Type != metrics.TypeCounter ||
+		strings.Join(def.Labels, ",") != "operation,type,status" || def.Help == "" {
+		t.Fatalf("Unexpected token operations definition %+v", def)
+	}
+	if def := byName[metricAuthLatency]; def.Type != metrics.TypeHistogram {
+		t.Errorf("Expected %s to be a histogram, got %+v", metricAuthLatency, def)
+	}
+	if def := byName[metricCircuitState]; def.Type != metrics.TypeGauge {
+		t.Errorf("Expected %s to be a gauge, got %+v", metricCircuitState, def)
+	}
+
+	data, err := GrafanaDashboard(defs, DashboardConfig{Selector: `job="gauth"`})
+	if err != nil {
+		t.Fatalf("GrafanaDashboard() error: %v", err)
+	}
+	var dashboard struct {
+		UID    string `json:"uid"`
+		Panels []struct {
+			ID      int    `json:"id"`
+			Type    string `json:"type"`
+			Title   string `json:"title"`
+			GridPos struct {
+				X, Y, W int
+			} `json:"gridPos"`
+			Targets []struct {
+				Expr string `json:"expr"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}
+	if err := json.Unmarshal(data, &dashboard); err != nil {
+		t.Fatalf("invalid dashboard JSON: %v", err)
+	}
+	if dashboard.UID != "gauth" || len(dashboard.Panels) != len(defs)+4 {
+		t.Fatalf("Expected 3 overview panels, a row and one panel per metric, got %d panels", len(dashboard.Panels))
+	}
+
+	exprs := make(map[string]string)
+	for _, p := range dashboard.Panels {
+		if p.GridPos.X+p.GridPos.W > 24 {
+			t.Errorf("Panel %q overflows the grid: %+v", p.Title, p.GridPos)
+		}
+		for _, target := range p.Targets {
+			if !strings.Contains(target.Expr, `job="gauth"`) {
+				t.Errorf("Query of %q lacks the selector: %s", p.Title, target.Expr)
+			}
+		}
+		if len(p.Targets) > 0 {
+			exprs[p.Title] = p.Targets[0].Expr
+		}
+		if p.Title == metricCircuitState && p.Type != "state-timeline" {
+			t.Errorf("Expected a state timeline for the breaker state, got %s", p.Type)
+		}
+	}
+	for title, want := range map[string]string{
+		"Authentication failure ratio": `sum(rate(gauth_authentication_attempts_total{status="failure",job="gauth"}[5m])) / sum(rate(gauth_authentication_attempts_total{job="gauth"}[5m]))`,
+		metricAuthLatency:              `histogram_quantile(0.5, sum by (le, method) (rate(gauth_authentication_duration_seconds_bucket{job="gauth"}[5m])))`,
+		"gauth_cache_operations_total": `sum by (operation, status) (rate(gauth_cache_operations_total{job="gauth"}[5m]))`,
+		metricExpiryBacklog:            `gauth_token_expiry_backlog{job="gauth"}`,
+	} {
+		if exprs[title] != want {
+			t.Errorf("Panel %q queries %q, want %q", title, exprs[title], want)
+		}
+	}
+}
+
+func TestAlertRules(t *testing.T) {
+	data, err := AlertRules(metrics.Definitions(), AlertRulesConfig{})
+	if err != nil {
+		t.Fatalf("AlertRules() error: %v", err)
+	}
+	var file struct {
+		Groups []struct {
+			Name  string
+			Rules []alertRule
+		}
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		t.Fatalf("invalid rule file: %v", err)
+	}
+	if len(file.Groups) != 1 || file.Groups[0].Name != "gauth" {
+		t.Fatalf("Expected a single gauth group, got %+v", file.Groups)
+	}
+	rules := make(map[string]alertRule)
+	for _, r := range file.Groups[0].Rules {
+		if r.Labels["severity"] == "" || r.Annotations["summary"] == "" {
+			t.Errorf("Rule %s lacks a severity or summary", r.Alert)
+		}
+		rules[r.Alert] = r
+	}
+	for _, name := range []string{"GAuthHighAuthFailureRatio", "GAuthSlowAuthentication", "GAuthTokenValidationErrors",
+		"GAuthCircuitOpen", "GAuthTokenExpiryBacklog", "GAuthClockDrift", "GAuthStoreDivergence"} {
+		if _, ok := rules[name]; !ok {
+			t.Errorf("Expected rule %s", name)
+		}
+	}
+	if r := rules["GAuthCircuitOpen"]; r.Expr != "max by (breaker) (gauth_circuit_state) == 1" || r.Labels["severity"] != "critical" {
+		t.Errorf("Unexpected breaker rule %+v", r)
+	}
+	if r := rules["GAuthClockDrift"]; r.Expr != "abs(gauth_clock_drift_seconds) > 2" {
+		t.Errorf("Unexpected clock drift rule %+v", r)
+	}
+
+	// rules follow the metrics given
+	data, err = AlertRules([]metrics.Definition{{Name: metricCircuitState, Type: metrics.TypeGauge}}, AlertRulesConfig{Group: "breakers"})
+	if err != nil {
+		t.Fatalf("AlertRules() error: %v", err)
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		t.Fatalf("invalid rule file: %v", err)
+	}
+	if got := file.Groups[0].Rules; len(got) != 1 || got[0].Alert != "GAuthCircuitOpen" {
+		t.Errorf("Expected only the breaker rule, got %+v", got)
+	}
+}
@@ -44,5 +44,55 @@ Basic Usage:
 	// Create a trace span for an operation
 	span := monitor.StartSpan(ctx, "token_validation")
 	defer span.End()
+
+Health Checks:
+
+A HealthRegistry collects liveness and readiness probes of stores, limiters
+and event sinks, runs them concurrently within a timeout, and serves the
+aggregated status with per-dependency JSON. Failing required probes answer
+503; failing optional ones mark the service degraded:
+
+	health := monitoring.NewHealthRegistry(monitoring.HealthConfig{})
+	health.Register(monitoring.PingProbe("token-store", db.PingContext))
+	health.Register(monitoring.Probe{Name: "rate-limiter", Optional: true, Check: limiter.Ping})
+	health.Register(monitoring.Probe{Name: "audit-sink", Optional: true, Check: sink.Health})
+
+	mux.Handle("/healthz", health.LivenessHandler())
+	mux.Handle("/readyz", health.ReadinessHandler())
+
+Anomaly Detection:
+
+An AnomalyDetector subscribes to the event bus, evaluates every event
+against its rules and raises an alert_triggered system event per anomaly,
+published to the bus and to webhooks such as a CloudEventSender. Built-in
+rules find spikes of failed validations, impossible travel between the
+locations of a subject's events and revoked tokens used again;
+AnomalyRuleFunc adds custom ones. Repeated alerts of a rule for the same key
+are suppressed for a cooldown:
+
+	webhook, _ := events.NewCloudEventSender(events.CloudEventSenderConfig{URL: siemURL})
+	detector, _ := monitoring.NewAnomalyDetector(monitoring.AnomalyConfig{
+		Rules: []monitoring.AnomalyRule{
+			monitoring.NewFailureSpikeRule(monitoring.FailureSpikeConfig{GroupBy: "ip_address"}),
+			monitoring.NewImpossibleTravelRule(monitoring.ImpossibleTravelConfig{}),
+			monitoring.NewRevokedReplayRule(monitoring.RevokedReplayConfig{}),
+		},
+		Alerts: []events.Publisher{bus, webhook},
+	})
+	bus.Subscribe(detector)
+
+Dashboards and Alerting Rules:
+
+GrafanaDashboard and AlertRules turn the metrics GAuth exports, as described
+by metrics.Definitions, into a dashboard ready to import into Grafana and a
+Prometheus rule file covering authentication failures and latency, token
+validation errors, open circuit breakers, the token expiry backlog, clock
+drift and store divergence. gauthctl monitoring writes both:
+
+	dashboard, _ := monitoring.GrafanaDashboard(metrics.Definitions(), monitoring.DashboardConfig{Selector: `job="gauth"`})
+	rules, _ := monitoring.AlertRules(metrics.Definitions(), monitoring.AlertRulesConfig{Selector: `job="gauth"`})
+
+The breaker and backlog gauges are fed by CircuitConfig.Metrics in
+resilience and by token.ReportExpiryBacklog.
 */
 package monitoring
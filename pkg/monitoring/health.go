@@ -0,0 +1,223 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrDuplicateProbe indicates a probe with the same name is registered
+var ErrDuplicateProbe = errors.New("health probe already registered")
+
+// HealthStatus is the health of a dependency or of the whole service
+type HealthStatus string
+
+const (
+	// HealthOK means every probe passed
+	HealthOK HealthStatus = "ok"
+
+	// HealthDegraded means only optional probes failed; the service still
+	// serves traffic
+	HealthDegraded HealthStatus = "degraded"
+
+	// HealthFail means a required probe failed
+	HealthFail HealthStatus = "fail"
+)
+
+// Probe checks one dependency, such as a token store, a distributed rate
+// limiter or an event sink
+type Probe struct {
+	// Name identifies the dependency in reports
+	Name string
+
+	// Liveness probes check the process itself and fail /healthz as well
+	// as /readyz; other probes only fail /readyz. Keep dependencies out of
+	// liveness, or an outage restarts every replica.
+	Liveness bool
+
+	// Optional probes are reported but leave the service ready, marking it
+	// degraded instead
+	Optional bool
+
+	// Timeout bounds the probe (default: HealthConfig.Timeout)
+	Timeout time.Duration
+
+	// Check returns nil while the dependency is healthy
+	Check func(ctx context.Context) error
+}
+
+// PingProbe builds a required readiness probe from a ping function, such
+// as sql.DB.PingContext or a limiter's Ping
+func PingProbe(name string, ping func(ctx context.Context) error) Probe {
+	return Probe{Name: name, Check: ping}
+}
+
+// HealthConfig configures a HealthRegistry
+type HealthConfig struct {
+	// Timeout bounds each probe without its own timeout (default: 2s)
+	Timeout time.Duration
+}
+
+// ProbeResult is the outcome of one probe
+type ProbeResult struct {
+	Name     string        `json:"name"`
+	Status   HealthStatus  `json:"status"`
+	Optional bool          `json:"optional,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// HealthReport is the aggregated outcome of a health check
+type HealthReport struct {
+	Status       HealthStatus  `json:"status"`
+	CheckedAt    time.Time     `json:"checked_at"`
+	Dependencies []ProbeResult `json:"dependencies"`
+}
+
+// HealthRegistry collects the probes of a service's dependencies and
+// serves their aggregated status on /healthz and /readyz
+type HealthRegistry struct {
+	config HealthConfig
+	mu     sync.RWMutex
+	probes []Probe
+}
+
+// NewHealthRegistry creates a health registry
+func NewHealthRegistry(config HealthConfig) *HealthRegistry {
+	if config.Timeout <= 0 {
+		config.Timeout = 2 * time.Second
+	}
+	return &HealthRegistry{config: config}
+}
+
+// Register adds a probe
+func (h *HealthRegistry) Register(probe Probe) error {
+	if probe.Name == "" || probe.Check == nil {
+		return errors.New("probe needs a name and a check function")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, p := range h.probes {
+		if p.Name == probe.Name {
+			return fmt.Errorf("%w: %s", ErrDuplicateProbe, probe.Name)
+		}
+	}
+	h.probes = append(h.probes, probe)
+	return nil
+}
+
+// Unregister removes the named probe, such as for a store that was closed
+func (h *HealthRegistry) Unregister(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, p := range h.probes {
+		if p.Name == name {
+			h.probes = append(h.probes[:i], h.probes[i+1:]...)
+			return
+		}
+	}
+}
+
+// Liveness runs the liveness probes
+func (h *HealthRegistry) Liveness(ctx context.Context) *HealthReport {
+	return h.check(ctx, true)
+}
+
+// Readiness runs every probe
+func (h *HealthRegistry) Readiness(ctx context.Context) *HealthReport {
+	return h.check(ctx, false)
+}
+
+// LivenessHandler serves /healthz: 503 when a required liveness probe
+// fails, 200 otherwise, with the report as JSON
+func (h *HealthRegistry) LivenessHandler() http.Handler {
+	return h.handler(h.Liveness)
+}
+
+// ReadinessHandler serves /readyz: 503 when a required probe fails, 200
+// otherwise, with the report as JSON
+func (h *HealthRegistry) ReadinessHandler() http.Handler {
+	return h.handler(h.Readiness)
+}
+
+func (h *HealthRegistry) handler(check func(context.Context) *HealthReport) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := check(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if report.Status == HealthFail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
+
+// check runs the selected probes concurrently and returns the results in
+// registration order
+func (h *HealthRegistry) check(ctx context.Context, livenessOnly bool) *HealthReport {
+	h.mu.RLock()
+	probes := make([]Probe, 0, len(h.probes))
+	for _, p := range h.probes {
+		if p.Liveness || !livenessOnly {
+			probes = append(probes, p)
+		}
+	}
+	h.mu.RUnlock()
+
+	report := &HealthReport{Status: HealthOK, CheckedAt: time.Now(), Dependencies: make([]ProbeResult, len(probes))}
+	var wg sync.WaitGroup
+	for i, probe := range probes {
+		wg.Add(1)
+		go func(i int, probe Probe) {
+			defer wg.Done()
+			report.Dependencies[i] = h.run(ctx, probe)
+		}(i, probe)
+	}
+	wg.Wait()
+
+	for _, res := range report.Dependencies {
+		switch {
+		case res.Status == HealthOK:
+		case res.Optional:
+			if report.Status == HealthOK {
+				report.Status = HealthDegraded
+			}
+		default:
+			report.Status = HealthFail
+		}
+	}
+	return report
+}
+
+// run executes a probe within its timeout. A probe that ignores its
+// context is abandoned when the time is up.
+func (h *HealthRegistry) run(ctx context.Context, probe Probe) ProbeResult {
+	timeout := probe.Timeout
+	if timeout <= 0 {
+		timeout = h.config.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- probe.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("probe did not finish within %s", timeout)
+	}
+
+	res := ProbeResult{Name: probe.Name, Status: HealthOK, Optional: probe.Optional, Duration: time.Since(start)}
+	if err != nil {
+		res.Status = HealthFail
+		res.Error = err.Error()
+	}
+	return res
+}
@@ -0,0 +1,98 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthRegistry(t *testing.T) {
+	ctx := context.Background()
+	ok := func(context.Context) error { return nil }
+	down := func(context.Context) error { return errors.New("connection refused") }
+
+	serve := func(t *testing.T, h http.Handler) (int, HealthReport) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		var report HealthReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("invalid JSON body: %v", err)
+		}
+		return rec.Code, report
+	}
+
+	t.Run("Register", func(t *testing.T) {
+		h := NewHealthRegistry(HealthConfig{})
+		if err := h.Register(PingProbe("token-store", ok)); err != nil {
+			t.Fatalf("Register() error: %v", err)
+		}
+		if err := h.Register(PingProbe("token-store", ok)); !errors.Is(err, ErrDuplicateProbe) {
+			t.Errorf("Expected ErrDuplicateProbe, got %v", err)
+		}
+		if err := h.Register(Probe{Name: "no-check"}); err == nil {
+			t.Error("Expected an error for a probe without a check")
+		}
+		h.Unregister("token-store")
+		if report := h.Readiness(ctx); len(report.Dependencies) != 0 || report.Status != HealthOK {
+			t.Errorf("Expected an empty healthy report, got %+v", report)
+		}
+	})
+
+	t.Run("Liveness Ignores Dependencies", func(t *testing.T) {
+		h := NewHealthRegistry(HealthConfig{})
+		_ = h.Register(Probe{Name: "event-loop", Liveness: true, Check: ok})
+		_ = h.Register(PingProbe("redis-limiter", down))
+
+		code, report := serve(t, h.LivenessHandler())
+		if code != http.StatusOK || report.Status != HealthOK {
+			t.Errorf("Expected 200 ok, got %d %s", code, report.Status)
+		}
+		if len(report.Dependencies) != 1 || report.Dependencies[0].Name != "event-loop" {
+			t.Errorf("Expected only the liveness probe, got %+v", report.Dependencies)
+		}
+
+		code, report = serve(t, h.ReadinessHandler())
+		if code != http.StatusServiceUnavailable || report.Status != HealthFail {
+			t.Errorf("Expected 503 fail, got %d %s", code, report.Status)
+		}
+		if len(report.Dependencies) != 2 || report.Dependencies[1].Error != "connection refused" {
+			t.Errorf("Expected both probes in registration order, got %+v", report.Dependencies)
+		}
+	})
+
+	t.Run("Optional Probes Degrade", func(t *testing.T) {
+		h := NewHealthRegistry(HealthConfig{})
+		_ = h.Register(PingProbe("token-store", ok))
+		_ = h.Register(Probe{Name: "audit-sink", Optional: true, Check: down})
+
+		code, report := serve(t, h.ReadinessHandler())
+		if code != http.StatusOK || report.Status != HealthDegraded {
+			t.Errorf("Expected 200 degraded, got %d %s", code, report.Status)
+		}
+		if res := report.Dependencies[1]; res.Status != HealthFail || !res.Optional {
+			t.Errorf("Expected the optional probe to fail, got %+v", res)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		h := NewHealthRegistry(HealthConfig{Timeout: 20 * time.Millisecond})
+		_ = h.Register(PingProbe("stuck", func(context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		}))
+
+		start := time.Now()
+		report := h.Readiness(ctx)
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Expected the probe to be abandoned, took %v", elapsed)
+		}
+		if report.Status != HealthFail || report.Dependencies[0].Error == "" {
+			t.Errorf("Expected a timed out probe to fail, got %+v", report)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	autherrors "github.com/Gimel-Foundation/gauth/pkg/errors"
+)
+
+// adminHandler serves the preference admin API
+type adminHandler struct {
+	notifier     *Notifier
+	authenticate func(r *http.Request) (string, error)
+}
+
+// NewAdminHandler returns the preference admin API. Mount it under a prefix
+// with http.StripPrefix; the remaining path names the principal:
+//
+//	GET    /{principal}  effective preferences
+//	PUT    /{principal}  replace stored preferences
+//	DELETE /{principal}  reset to the defaults
+//
+// authenticate identifies the administrator recorded in the audit trail.
+func NewAdminHandler(n *Notifier, authenticate func(r *http.Request) (actor string, err error)) http.Handler {
+	return &adminHandler{notifier: n, authenticate: authenticate}
+}
+
+func (h *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	actor, err := h.authenticate(r)
+	if err != nil {
+		autherrors.New(autherrors.ErrInvalidClient, "administrator authentication failed").WriteHTTP(w)
+		return
+	}
+	principal := strings.Trim(r.URL.Path, "/")
+	if principal == "" || strings.Contains(principal, "/") {
+		autherrors.New(autherrors.ErrInvalidRequest, "path must name one principal").WriteHTTP(w)
+		return
+	}
+
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet:
+		prefs, err := h.notifier.Preferences(ctx, principal)
+		if err != nil {
+			autherrors.New(autherrors.ErrServerError, "failed to load preferences").WithCause(err).WriteHTTP(w)
+			return
+		}
+		writeJSON(w, http.StatusOK, prefs)
+
+	case http.MethodPut:
+		var prefs Preferences
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&prefs); err != nil {
+			autherrors.New(autherrors.ErrInvalidRequest, "invalid preferences document").WriteHTTP(w)
+			return
+		}
+		prefs.Principal = principal
+		if err := h.notifier.SetPreferences(ctx, &prefs, actor); err != nil {
+			writeChangeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, &prefs)
+
+	case http.MethodDelete:
+		if err := h.notifier.ResetPreferences(ctx, principal, actor); err != nil {
+			writeChangeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		autherrors.New(autherrors.ErrInvalidRequest, "method not allowed").
+			WithHTTPInfo(r.URL.Path, r.Method, http.StatusMethodNotAllowed, "").
+			WriteHTTP(w)
+	}
+}
+
+func writeChangeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrInvalidPreferences) {
+		autherrors.New(autherrors.ErrInvalidRequest, err.Error()).WriteHTTP(w)
+		return
+	}
+	autherrors.New(autherrors.ErrServerError, "failed to update preferences").WithCause(err).WriteHTTP(w)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,500 @@
+// Package notify delivers expiry, approval and security notifications to
+// principals according to their preferences. Each principal chooses, per
+// category, whether notifications arrive immediately or in a periodic digest
+// and over which channels:
+//
+//	n, _ := notify.New(notify.Config{Senders: map[notify.Channel]notify.Sender{"email": mailer}})
+//	_ = n.SetPreferences(ctx, &notify.Preferences{
+//		Principal: "alice",
+//		Categories: map[notify.Category]notify.CategoryPreference{
+//			notify.CategoryExpiry: {Delivery: notify.DeliveryDigest, Channels: []notify.Channel{"email"}},
+//		},
+//	}, "admin")
+//	go n.Run(ctx, nil)
+//
+// Preferences are managed over HTTP with NewAdminHandler.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/audit"
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// Notification errors
+var (
+	// ErrInvalidConfig indicates a notifier was configured incorrectly
+	ErrInvalidConfig = errors.New("invalid notifier config")
+
+	// ErrInvalidPreferences indicates preferences that cannot be applied
+	ErrInvalidPreferences = errors.New("invalid notification preferences")
+
+	// ErrPreferencesNotFound indicates the principal has no stored preferences
+	ErrPreferencesNotFound = errors.New("notification preferences not found")
+)
+
+// Category groups notifications a principal configures together
+type Category string
+
+// Notification categories
+const (
+	// CategoryExpiry covers tokens and delegations about to expire
+	CategoryExpiry Category = "expiry"
+
+	// CategoryApproval covers requests waiting for or resolved by approval
+	CategoryApproval Category = "approval"
+
+	// CategorySecurity covers revocations, failed logins and similar
+	CategorySecurity Category = "security"
+)
+
+// Categories lists every category
+var Categories = []Category{CategoryExpiry, CategoryApproval, CategorySecurity}
+
+// Delivery is how notifications of a category reach the principal
+type Delivery string
+
+// Delivery modes
+const (
+	// DeliveryImmediate sends each notification as it happens
+	DeliveryImmediate Delivery = "immediate"
+
+	// DeliveryDigest collects notifications and sends them once per
+	// digest interval
+	DeliveryDigest Delivery = "digest"
+
+	// DeliveryOff drops the notifications; not allowed for security
+	DeliveryOff Delivery = "off"
+)
+
+// Channel names a configured Sender, e.g. "email" or "webhook"
+type Channel string
+
+// Audit trail constants
+const (
+	// TypeNotificationPreferences is the audit entry type for preference changes
+	TypeNotificationPreferences = "notification_preferences"
+
+	// ActionPreferencesChanged records a preference change
+	ActionPreferencesChanged = "notification_preferences_changed"
+)
+
+// CategoryPreference is a principal's choice for one category
+type CategoryPreference struct {
+	Delivery Delivery  `json:"delivery"`
+	Channels []Channel `json:"channels,omitempty"`
+}
+
+// Preferences are a principal's notification preferences. Categories
+// missing from the map use the notifier defaults.
+type Preferences struct {
+	Principal  string                          `json:"principal"`
+	Categories map[Category]CategoryPreference `json:"categories"`
+	UpdatedAt  time.Time                       `json:"updated_at,omitempty"`
+	UpdatedBy  string                          `json:"updated_by,omitempty"`
+}
+
+// PreferenceStore persists notification preferences
+type PreferenceStore interface {
+	// Get returns ErrPreferencesNotFound for unknown principals
+	Get(ctx context.Context, principal string) (*Preferences, error)
+	Put(ctx context.Context, prefs *Preferences) error
+	Delete(ctx context.Context, principal string) error
+}
+
+// MemoryPreferenceStore keeps preferences in memory
+type MemoryPreferenceStore struct {
+	mu    sync.RWMutex
+	prefs map[string]*Preferences
+}
+
+// NewMemoryPreferenceStore creates an empty in-memory preference store
+func NewMemoryPreferenceStore() *MemoryPreferenceStore {
+	return &MemoryPreferenceStore{prefs: make(map[string]*Preferences)}
+}
+
+// Get implements PreferenceStore
+func (s *MemoryPreferenceStore) Get(_ context.Context, principal string) (*Preferences, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.prefs[principal]
+	if !ok {
+		return nil, ErrPreferencesNotFound
+	}
+	return p.clone(), nil
+}
+
+// Put implements PreferenceStore
+func (s *MemoryPreferenceStore) Put(_ context.Context, prefs *Preferences) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prefs[prefs.Principal] = prefs.clone()
+	return nil
+}
+
+// Delete implements PreferenceStore
+func (s *MemoryPreferenceStore) Delete(_ context.Context, principal string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.prefs, principal)
+	return nil
+}
+
+func (p *Preferences) clone() *Preferences {
+	c := *p
+	c.Categories = make(map[Category]CategoryPreference, len(p.Categories))
+	for cat, pref := range p.Categories {
+		pref.Channels = append([]Channel(nil), pref.Channels...)
+		c.Categories[cat] = pref
+	}
+	return &c
+}
+
+// Notification is a message for one principal
+type Notification struct {
+	Principal string            `json:"principal"`
+	Category  Category          `json:"category"`
+	Subject   string            `json:"subject"`
+	Body      string            `json:"body,omitempty"`
+	At        time.Time         `json:"at"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// Sender delivers notifications over one channel. Immediate notifications
+// arrive as batches of one; digests as all pending notifications of the
+// principal, oldest first.
+type Sender interface {
+	Send(ctx context.Context, principal string, batch []Notification) error
+}
+
+// SenderFunc adapts a function to the Sender interface
+type SenderFunc func(ctx context.Context, principal string, batch []Notification) error
+
+// Send implements Sender
+func (f SenderFunc) Send(ctx context.Context, principal string, batch []Notification) error {
+	return f(ctx, principal, batch)
+}
+
+// Config configures a Notifier
+type Config struct {
+	// Senders maps channel names to senders; at least one is required
+	Senders map[Channel]Sender
+
+	// Preferences stores per-principal preferences (default: in memory)
+	Preferences PreferenceStore
+
+	// Defaults apply to categories a principal has not configured
+	// (default: immediate over every channel)
+	Defaults map[Category]CategoryPreference
+
+	// DigestInterval is how often Run sends digests (default: 24h)
+	DigestInterval time.Duration
+
+	// Audit receives an entry for every preference change
+	Audit audit.Storage
+}
+
+type digestKey struct {
+	principal string
+	channel   Channel
+}
+
+// Notifier routes notifications according to principal preferences
+type Notifier struct {
+	config  Config
+	mu      sync.Mutex
+	pending map[digestKey][]Notification
+}
+
+// New creates a notifier
+func New(config Config) (*Notifier, error) {
+	if len(config.Senders) == 0 {
+		return nil, fmt.Errorf("%w: at least one sender is required", ErrInvalidConfig)
+	}
+	if config.Preferences == nil {
+		config.Preferences = NewMemoryPreferenceStore()
+	}
+	if config.DigestInterval <= 0 {
+		config.DigestInterval = 24 * time.Hour
+	}
+	n := &Notifier{config: config, pending: make(map[digestKey][]Notification)}
+	if config.Defaults == nil {
+		all := n.channels()
+		n.config.Defaults = make(map[Category]CategoryPreference, len(Categories))
+		for _, cat := range Categories {
+			n.config.Defaults[cat] = CategoryPreference{Delivery: DeliveryImmediate, Channels: all}
+		}
+	}
+	defaults := &Preferences{Categories: n.config.Defaults}
+	if err := n.validate(defaults); err != nil {
+		return nil, fmt.Errorf("%w: defaults: %v", ErrInvalidConfig, err)
+	}
+	return n, nil
+}
+
+// channels returns the configured channel names, sorted
+func (n *Notifier) channels() []Channel {
+	out := make([]Channel, 0, len(n.config.Senders))
+	for ch := range n.config.Senders {
+		out = append(out, ch)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+func (n *Notifier) validate(prefs *Preferences) error {
+	for cat, pref := range prefs.Categories {
+		switch cat {
+		case CategoryExpiry, CategoryApproval, CategorySecurity:
+		default:
+			return fmt.Errorf("%w: unknown category %q", ErrInvalidPreferences, cat)
+		}
+		switch pref.Delivery {
+		case DeliveryImmediate, DeliveryDigest:
+			if len(pref.Channels) == 0 {
+				return fmt.Errorf("%w: %s needs at least one channel", ErrInvalidPreferences, cat)
+			}
+		case DeliveryOff:
+			if cat == CategorySecurity {
+				return fmt.Errorf("%w: security notifications cannot be turned off", ErrInvalidPreferences)
+			}
+		default:
+			return fmt.Errorf("%w: unknown delivery %q", ErrInvalidPreferences, pref.Delivery)
+		}
+		for _, ch := range pref.Channels {
+			if _, ok := n.config.Senders[ch]; !ok {
+				return fmt.Errorf("%w: unknown channel %q", ErrInvalidPreferences, ch)
+			}
+		}
+	}
+	return nil
+}
+
+// Preferences returns the principal's effective preferences: stored choices
+// with the defaults filled in for the remaining categories
+func (n *Notifier) Preferences(ctx context.Context, principal string) (*Preferences, error) {
+	prefs, err := n.config.Preferences.Get(ctx, principal)
+	if errors.Is(err, ErrPreferencesNotFound) {
+		prefs, err = &Preferences{Principal: principal, Categories: map[Category]CategoryPreference{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load preferences: %w", err)
+	}
+	for _, cat := range Categories {
+		if _, ok := prefs.Categories[cat]; !ok {
+			def := n.config.Defaults[cat]
+			def.Channels = append([]Channel(nil), def.Channels...)
+			prefs.Categories[cat] = def
+		}
+	}
+	return prefs, nil
+}
+
+// SetPreferences validates and stores a principal's preferences
+func (n *Notifier) SetPreferences(ctx context.Context, prefs *Preferences, actor string) error {
+	if prefs == nil || prefs.Principal == "" {
+		return fmt.Errorf("%w: principal is required", ErrInvalidPreferences)
+	}
+	if err := n.validate(prefs); err != nil {
+		return err
+	}
+	prefs.UpdatedAt = time.Now()
+	prefs.UpdatedBy = actor
+	if err := n.config.Preferences.Put(ctx, prefs); err != nil {
+		return fmt.Errorf("failed to store preferences: %w", err)
+	}
+	return n.record(ctx, prefs.Principal, actor, "set")
+}
+
+// ResetPreferences deletes a principal's preferences so the defaults apply
+func (n *Notifier) ResetPreferences(ctx context.Context, principal, actor string) error {
+	if err := n.config.Preferences.Delete(ctx, principal); err != nil {
+		return fmt.Errorf("failed to delete preferences: %w", err)
+	}
+	return n.record(ctx, principal, actor, "reset")
+}
+
+func (n *Notifier) record(ctx context.Context, principal, actor, change string) error {
+	if n.config.Audit == nil {
+		return nil
+	}
+	e := audit.NewEntry(TypeNotificationPreferences).
+		WithActor(actor, audit.ActorUser).
+		WithAction(ActionPreferencesChanged).
+		WithTarget(principal, TypeNotificationPreferences).
+		WithResult(audit.ResultSuccess).
+		WithContext(ctx).
+		WithMetadata("change", change)
+	if err := n.config.Audit.Store(ctx, e); err != nil {
+		return fmt.Errorf("failed to record preference change: %w", err)
+	}
+	return nil
+}
+
+// Notify delivers or queues a notification according to the principal's
+// preferences for its category
+func (n *Notifier) Notify(ctx context.Context, note Notification) error {
+	if note.Principal == "" {
+		return fmt.Errorf("%w: notification has no principal", ErrInvalidPreferences)
+	}
+	if note.At.IsZero() {
+		note.At = time.Now()
+	}
+	prefs, err := n.Preferences(ctx, note.Principal)
+	if err != nil {
+		return err
+	}
+	pref, ok := prefs.Categories[note.Category]
+	if !ok {
+		return fmt.Errorf("%w: unknown category %q", ErrInvalidPreferences, note.Category)
+	}
+
+	switch pref.Delivery {
+	case DeliveryOff:
+		return nil
+	case DeliveryDigest:
+		n.mu.Lock()
+		for _, ch := range pref.Channels {
+			key := digestKey{principal: note.Principal, channel: ch}
+			n.pending[key] = append(n.pending[key], note)
+		}
+		n.mu.Unlock()
+		return nil
+	}
+
+	var errs []error
+	for _, ch := range pref.Channels {
+		if err := n.config.Senders[ch].Send(ctx, note.Principal, []Notification{note}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send over %s: %w", ch, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Pending returns how many notifications wait in the principal's digests
+func (n *Notifier) Pending(principal string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	count := 0
+	for key, batch := range n.pending {
+		if key.principal == principal {
+			count += len(batch)
+		}
+	}
+	return count
+}
+
+// FlushDigests sends every pending digest and returns how many digests were
+// sent. Digests that fail to send are kept for the next flush.
+func (n *Notifier) FlushDigests(ctx context.Context) (int, error) {
+	n.mu.Lock()
+	pending := n.pending
+	n.pending = make(map[digestKey][]Notification)
+	n.mu.Unlock()
+
+	keys := make([]digestKey, 0, len(pending))
+	for key := range pending {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].principal != keys[j].principal {
+			return keys[i].principal < keys[j].principal
+		}
+		return keys[i].channel < keys[j].channel
+	})
+
+	sent := 0
+	var errs []error
+	for _, key := range keys {
+		batch := pending[key]
+		sender, ok := n.config.Senders[key.channel]
+		if !ok {
+			continue
+		}
+		if err := sender.Send(ctx, key.principal, batch); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send digest to %s over %s: %w", key.principal, key.channel, err))
+			n.mu.Lock()
+			n.pending[key] = append(batch, n.pending[key]...)
+			n.mu.Unlock()
+			continue
+		}
+		sent++
+	}
+	return sent, errors.Join(errs...)
+}
+
+// Run sends digests every DigestInterval until ctx is cancelled. Errors go to
+// onError when it is non-nil.
+func (n *Notifier) Run(ctx context.Context, onError func(error)) {
+	ticker := time.NewTicker(n.config.DigestInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := n.FlushDigests(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// eventCategories maps the events that concern a principal to categories
+var eventCategories = map[string]Category{
+	string(events.ActionTokenExpired):         CategoryExpiry,
+	string(events.ActionSessionExpired):       CategoryExpiry,
+	string(events.ActionTokenRestoreApproved): CategoryApproval,
+	string(events.ActionConsentGiven):         CategoryApproval,
+	string(events.ActionDelegationCreated):    CategoryApproval,
+	string(events.ActionTokenRevoked):         CategorySecurity,
+	string(events.ActionLoginFailed):          CategorySecurity,
+	string(events.ActionMultiFactorFailed):    CategorySecurity,
+	string(events.ActionPasswordChanged):      CategorySecurity,
+	string(events.ActionDelegationRevoked):    CategorySecurity,
+}
+
+// eventHandler turns events into notifications for their subject
+type eventHandler struct {
+	notifier *Notifier
+	onError  func(error)
+}
+
+// EventHandler returns an event handler that notifies each event's subject
+// of expiry, approval and security events. Other events are ignored. Errors
+// go to onError when it is non-nil.
+func (n *Notifier) EventHandler(onError func(error)) events.EventHandler {
+	return &eventHandler{notifier: n, onError: onError}
+}
+
+// Handle implements events.EventHandler
+func (h *eventHandler) Handle(event events.Event) {
+	category, ok := eventCategories[event.Action]
+	if !ok || event.Subject == "" {
+		return
+	}
+	subject := event.Message
+	if subject == "" {
+		subject = event.Action
+	}
+	note := Notification{
+		Principal: event.Subject,
+		Category:  category,
+		Subject:   subject,
+		At:        event.Timestamp,
+		Metadata:  map[string]string{"event_id": event.ID, "action": event.Action},
+	}
+	if event.Resource != "" {
+		note.Metadata["resource"] = event.Resource
+	}
+	if err := h.notifier.Notify(context.Background(), note); err != nil && h.onError != nil {
+		h.onError(err)
+	}
+}
@@ -0,0 +1,170 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+type recordingSender struct {
+	mu      sync.Mutex
+	batches [][]Notification
+	fail    bool
+}
+
+func (s *recordingSender) Send(_ context.Context, _ string, batch []Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errors.New("smtp down")
+	}
+	s.batches = append(s.batches, batch)
+	return nil
+}
+
+func TestNotifier(t *testing.T) {
+	ctx := context.Background()
+	email, webhook := &recordingSender{}, &recordingSender{}
+	n, err := New(Config{Senders: map[Channel]Sender{"email": email, "webhook": webhook}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	t.Run("Defaults Are Immediate", func(t *testing.T) {
+		if err := n.Notify(ctx, Notification{Principal: "bob", Category: CategoryExpiry, Subject: "token expires soon"}); err != nil {
+			t.Fatalf("Notify() error: %v", err)
+		}
+		if len(email.batches) != 1 || len(webhook.batches) != 1 {
+			t.Errorf("Expected one send per channel, got %d/%d", len(email.batches), len(webhook.batches))
+		}
+	})
+
+	t.Run("Digest Mode", func(t *testing.T) {
+		email.batches, webhook.batches = nil, nil
+		err := n.SetPreferences(ctx, &Preferences{
+			Principal: "alice",
+			Categories: map[Category]CategoryPreference{
+				CategoryExpiry:   {Delivery: DeliveryDigest, Channels: []Channel{"email"}},
+				CategoryApproval: {Delivery: DeliveryOff},
+			},
+		}, "admin")
+		if err != nil {
+			t.Fatalf("SetPreferences() error: %v", err)
+		}
+		for _, subject := range []string{"first", "second"} {
+			_ = n.Notify(ctx, Notification{Principal: "alice", Category: CategoryExpiry, Subject: subject})
+		}
+		_ = n.Notify(ctx, Notification{Principal: "alice", Category: CategoryApproval, Subject: "muted"})
+		if len(email.batches) != 0 || n.Pending("alice") != 2 {
+			t.Fatalf("Expected two queued notifications, got %d sent and %d pending", len(email.batches), n.Pending("alice"))
+		}
+
+		email.fail = true
+		if _, err := n.FlushDigests(ctx); err == nil {
+			t.Fatal("Expected flush error while the sender is down")
+		}
+		if n.Pending("alice") != 2 {
+			t.Errorf("Expected failed digest to be kept, got %d pending", n.Pending("alice"))
+		}
+
+		email.fail = false
+		sent, err := n.FlushDigests(ctx)
+		if err != nil || sent != 1 {
+			t.Fatalf("FlushDigests() = %d, %v", sent, err)
+		}
+		if len(email.batches) != 1 || len(email.batches[0]) != 2 || email.batches[0][0].Subject != "first" {
+			t.Errorf("Expected one digest with both notifications in order, got %+v", email.batches)
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		cases := map[string]CategoryPreference{
+			"security off":    {Delivery: DeliveryOff},
+			"unknown channel": {Delivery: DeliveryImmediate, Channels: []Channel{"pager"}},
+			"no channel":      {Delivery: DeliveryDigest},
+		}
+		for name, pref := range cases {
+			err := n.SetPreferences(ctx, &Preferences{Principal: "carol",
+				Categories: map[Category]CategoryPreference{CategorySecurity: pref}}, "admin")
+			if !errors.Is(err, ErrInvalidPreferences) {
+				t.Errorf("%s: expected ErrInvalidPreferences, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("Event Handler", func(t *testing.T) {
+		webhook.batches = nil
+		event := events.NewTokenEvent(events.ActionTokenRevoked, events.StatusSuccess).
+			WithSubject("alice").
+			WithResource("tok-1").
+			WithMessage("token revoked")
+		n.EventHandler(nil).Handle(event)
+		if len(webhook.batches) != 1 || webhook.batches[0][0].Category != CategorySecurity {
+			t.Errorf("Expected an immediate security notification, got %+v", webhook.batches)
+		}
+	})
+}
+
+func TestAdminHandler(t *testing.T) {
+	n, err := New(Config{Senders: map[Channel]Sender{"email": &recordingSender{}}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	h := http.StripPrefix("/admin/notifications", NewAdminHandler(n, func(r *http.Request) (string, error) {
+		if r.Header.Get("Authorization") != "Bearer admin" {
+			return "", errors.New("unauthorized")
+		}
+		return "admin", nil
+	}))
+	do := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/notifications/alice", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer admin")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Put And Get", func(t *testing.T) {
+		rec := do(http.MethodPut, `{"categories":{"expiry":{"delivery":"digest","channels":["email"]}}}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		rec = do(http.MethodGet, "")
+		if !strings.Contains(rec.Body.String(), `"expiry":{"delivery":"digest"`) ||
+			!strings.Contains(rec.Body.String(), `"security":{"delivery":"immediate"`) {
+			t.Errorf("Expected stored and default categories, got %s", rec.Body)
+		}
+	})
+
+	t.Run("Invalid Preferences", func(t *testing.T) {
+		rec := do(http.MethodPut, `{"categories":{"security":{"delivery":"off"}}}`)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d", rec.Code)
+		}
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		if rec := do(http.MethodDelete, ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("Expected 204, got %d", rec.Code)
+		}
+		prefs, _ := n.Preferences(context.Background(), "alice")
+		if prefs.Categories[CategoryExpiry].Delivery != DeliveryImmediate {
+			t.Errorf("Expected defaults after reset, got %+v", prefs.Categories)
+		}
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/admin/notifications/alice", nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401, got %d", rec.Code)
+		}
+	})
+}
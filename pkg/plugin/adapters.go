@@ -0,0 +1,59 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// Check implements token.IssuanceCheck. A plugin error denies issuance.
+func (p *Plugin) Check(ctx context.Context, t *token.Token) error {
+	req := &CheckRequest{
+		Kind:     KindIssuance,
+		Subject:  t.Subject,
+		Scopes:   t.Scopes,
+		Audience: t.Audience,
+	}
+	if t.Metadata != nil {
+		req.Claims = t.Metadata.Attributes
+	}
+	resp, err := p.Evaluate(ctx, req)
+	if err != nil {
+		return err
+	}
+	if !resp.Allow {
+		if resp.Reason == "" {
+			return errors.New("denied by plugin " + p.name)
+		}
+		return errors.New(resp.Reason)
+	}
+	return nil
+}
+
+var _ token.IssuanceCheck = (*Plugin)(nil)
+
+// Condition adapts the plugin to an authz.Condition. A plugin error is
+// returned to the authorizer, which treats it as the condition failing.
+func (p *Plugin) Condition() authz.Condition {
+	return &condition{plugin: p}
+}
+
+type condition struct {
+	plugin *Plugin
+}
+
+func (c *condition) Evaluate(ctx context.Context, request *authz.AccessRequest) (bool, error) {
+	resp, err := c.plugin.Evaluate(ctx, &CheckRequest{
+		Kind:     KindAuthorization,
+		Subject:  request.Subject.ID,
+		Action:   request.Action.Name,
+		Resource: request.Resource.ID,
+		Context:  request.Context,
+	})
+	if err != nil {
+		return false, err
+	}
+	return resp.Allow, nil
+}
@@ -0,0 +1,219 @@
+// Package plugin runs deployment-supplied issuance and authorization checks
+// as WebAssembly modules. Modules run in a sandboxed runtime with no file,
+// network or process access, bounded memory and a per-call deadline, and can
+// only reach the host through the typed API described by Host.
+//
+// NewWazeroRuntime provides the engine, built on wazero; deployments may
+// plug in another by implementing Runtime.
+//
+// Guest ABI: a module exports "memory", "alloc(size i32) -> ptr i32" and
+// "check(ptr i32, len i32) -> i64". The host writes a JSON CheckRequest to
+// memory reserved with alloc and calls check, which returns the location of
+// a JSON CheckResponse as ptr<<32 | len. The only imports available are
+// gauth.log(level_ptr, level_len, msg_ptr, msg_len) and
+// gauth.now_unix_ms() -> i64.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Plugin errors
+var (
+	// ErrInvalidModule indicates the bytes are not a WebAssembly module
+	ErrInvalidModule = errors.New("invalid wasm module")
+
+	// ErrLimitExceeded indicates a call exceeded a resource limit
+	ErrLimitExceeded = errors.New("plugin resource limit exceeded")
+
+	// ErrPluginFailed indicates the module trapped or returned garbage
+	ErrPluginFailed = errors.New("plugin execution failed")
+)
+
+// CheckFunction is the guest export invoked for every check
+const CheckFunction = "check"
+
+// wasmHeader is the magic number and version 1 of the binary format
+var wasmHeader = []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+
+// Limits bound a module's resource use
+type Limits struct {
+	// MaxModuleBytes rejects larger modules at load time (default: 4MiB)
+	MaxModuleBytes int
+
+	// MemoryPages caps linear memory in 64KiB pages (default: 16, i.e. 1MiB)
+	MemoryPages uint32
+
+	// Timeout bounds each call (default: 50ms)
+	Timeout time.Duration
+
+	// MaxInputBytes rejects larger requests before calling in (default: 64KiB)
+	MaxInputBytes int
+
+	// MaxOutputBytes rejects larger responses (default: 16KiB)
+	MaxOutputBytes int
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxModuleBytes <= 0 {
+		l.MaxModuleBytes = 4 << 20
+	}
+	if l.MemoryPages == 0 {
+		l.MemoryPages = 16
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = 50 * time.Millisecond
+	}
+	if l.MaxInputBytes <= 0 {
+		l.MaxInputBytes = 64 << 10
+	}
+	if l.MaxOutputBytes <= 0 {
+		l.MaxOutputBytes = 16 << 10
+	}
+	return l
+}
+
+// Host is the API a module may import from the "gauth" host module. A
+// Runtime must expose exactly these functions and nothing else.
+type Host struct {
+	// Log backs gauth.log(level, message)
+	Log func(level, message string)
+
+	// Now backs gauth.now_unix_ms(); it lets tests pin time
+	Now func() time.Time
+}
+
+// Runtime compiles modules into sandboxes. Implementations must not grant
+// WASI filesystem, network or environment access, must cap memory at
+// Limits.MemoryPages and must abort a call when its context is done.
+type Runtime interface {
+	Compile(ctx context.Context, name string, wasm []byte, limits Limits, host Host) (Module, error)
+}
+
+// Module is a compiled, instantiable plugin
+type Module interface {
+	// Call invokes an export with input and returns its output
+	Call(ctx context.Context, function string, input []byte) ([]byte, error)
+
+	// Close releases the module
+	Close(ctx context.Context) error
+}
+
+// CheckRequest is passed to the guest
+type CheckRequest struct {
+	// Kind is "issuance" or "authorization"
+	Kind string `json:"kind"`
+
+	Subject  string              `json:"subject,omitempty"`
+	Action   string              `json:"action,omitempty"`
+	Resource string              `json:"resource,omitempty"`
+	Scopes   []string            `json:"scopes,omitempty"`
+	Audience []string            `json:"audience,omitempty"`
+	Claims   map[string][]string `json:"claims,omitempty"`
+	Context  map[string]string   `json:"context,omitempty"`
+}
+
+// Check kinds
+const (
+	KindIssuance      = "issuance"
+	KindAuthorization = "authorization"
+)
+
+// CheckResponse is returned by the guest
+type CheckResponse struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Plugin is a loaded check module
+type Plugin struct {
+	name   string
+	digest string
+	limits Limits
+	mu     sync.Mutex
+	module Module
+}
+
+// Load validates and compiles a module
+func Load(ctx context.Context, runtime Runtime, name string, wasm []byte, limits Limits, host Host) (*Plugin, error) {
+	limits = limits.withDefaults()
+	if len(wasm) > limits.MaxModuleBytes {
+		return nil, fmt.Errorf("%w: module is %d bytes, limit %d", ErrLimitExceeded, len(wasm), limits.MaxModuleBytes)
+	}
+	if !bytes.HasPrefix(wasm, wasmHeader) {
+		return nil, ErrInvalidModule
+	}
+	if host.Log == nil {
+		host.Log = func(string, string) {}
+	}
+	if host.Now == nil {
+		host.Now = time.Now
+	}
+
+	module, err := runtime.Compile(ctx, name, wasm, limits, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile plugin %s: %w", name, err)
+	}
+	sum := sha256.Sum256(wasm)
+	return &Plugin{name: name, digest: hex.EncodeToString(sum[:]), limits: limits, module: module}, nil
+}
+
+// Name returns the plugin name
+func (p *Plugin) Name() string {
+	return p.name
+}
+
+// Digest returns the SHA-256 of the module, for audit records
+func (p *Plugin) Digest() string {
+	return p.digest
+}
+
+// Evaluate runs the guest check within the plugin's limits. Calls are
+// serialised because a module instance is single-threaded.
+func (p *Plugin) Evaluate(ctx context.Context, req *CheckRequest) (*CheckResponse, error) {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode check request: %w", err)
+	}
+	if len(input) > p.limits.MaxInputBytes {
+		return nil, fmt.Errorf("%w: request is %d bytes", ErrLimitExceeded, len(input))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.limits.Timeout)
+	defer cancel()
+
+	p.mu.Lock()
+	output, err := p.module.Call(ctx, CheckFunction, input)
+	p.mu.Unlock()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("%w: plugin %s exceeded %s", ErrLimitExceeded, p.name, p.limits.Timeout)
+	}
+	if errors.Is(err, ErrLimitExceeded) {
+		return nil, fmt.Errorf("plugin %s: %w", p.name, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrPluginFailed, p.name, err)
+	}
+	if len(output) > p.limits.MaxOutputBytes {
+		return nil, fmt.Errorf("%w: response is %d bytes", ErrLimitExceeded, len(output))
+	}
+
+	var resp CheckResponse
+	if err := json.Unmarshal(output, &resp); err != nil {
+		return nil, fmt.Errorf("%w: %s returned malformed response: %v", ErrPluginFailed, p.name, err)
+	}
+	return &resp, nil
+}
+
+// Close releases the module
+func (p *Plugin) Close(ctx context.Context) error {
+	return p.module.Close(ctx)
+}
@@ -0,0 +1,126 @@
+package plugin
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// fakeRuntime stands in for a Wasm engine; the module body is ignored and
+// fn plays the guest
+type fakeRuntime struct {
+	fn func(ctx context.Context, req CheckRequest) ([]byte, error)
+}
+
+func (r fakeRuntime) Compile(_ context.Context, _ string, _ []byte, _ Limits, _ Host) (Module, error) {
+	return fakeModule(r), nil
+}
+
+type fakeModule fakeRuntime
+
+func (m fakeModule) Call(ctx context.Context, _ string, input []byte) ([]byte, error) {
+	var req CheckRequest
+	if err := json.Unmarshal(input, &req); err != nil {
+		return nil, err
+	}
+	return m.fn(ctx, req)
+}
+
+func (m fakeModule) Close(context.Context) error { return nil }
+
+var module = append(append([]byte(nil), wasmHeader...), 0x00)
+
+func TestPlugin(t *testing.T) {
+	ctx := context.Background()
+
+	deny := fakeRuntime{fn: func(_ context.Context, req CheckRequest) ([]byte, error) {
+		for _, s := range req.Scopes {
+			if s == "admin" {
+				return []byte(`{"allow":false,"reason":"admin scope needs approval"}`), nil
+			}
+		}
+		allow := req.Kind == KindIssuance || req.Context["region"] == "eu"
+		return json.Marshal(CheckResponse{Allow: allow})
+	}}
+
+	t.Run("Invalid Module", func(t *testing.T) {
+		if _, err := Load(ctx, deny, "bad", []byte("not wasm"), Limits{}, Host{}); !errors.Is(err, ErrInvalidModule) {
+			t.Errorf("Expected ErrInvalidModule, got %v", err)
+		}
+		if _, err := Load(ctx, deny, "big", module, Limits{MaxModuleBytes: 4}, Host{}); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("Expected ErrLimitExceeded, got %v", err)
+		}
+	})
+
+	t.Run("Issuance Check", func(t *testing.T) {
+		p, err := Load(ctx, deny, "scope-guard", module, Limits{}, Host{})
+		if err != nil {
+			t.Fatalf("Load() error: %v", err)
+		}
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("GenerateKey() error: %v", err)
+		}
+		checks := token.NewIssuancePipeline()
+		if err := checks.Register(p); err != nil {
+			t.Fatalf("Register() error: %v", err)
+		}
+		svc := token.NewService(token.Config{SigningKey: key, ValidityPeriod: time.Hour, IssuanceChecks: checks}, token.NewMemoryStore(time.Hour))
+
+		if _, err := svc.Issue(ctx, &token.Token{ID: token.NewID(), Type: token.Access, Subject: "u", Scopes: []string{"read"}}); err != nil {
+			t.Errorf("Expected read token to be issued, got %v", err)
+		}
+		_, err = svc.Issue(ctx, &token.Token{ID: token.NewID(), Type: token.Access, Subject: "u", Scopes: []string{"admin"}})
+		if !errors.Is(err, token.ErrIssuanceDenied) {
+			t.Errorf("Expected ErrIssuanceDenied, got %v", err)
+		}
+	})
+
+	t.Run("Authz Condition", func(t *testing.T) {
+		p, _ := Load(ctx, deny, "region", module, Limits{}, Host{})
+		cond := p.Condition()
+		req := authz.NewAccessRequest(authz.Subject{ID: "u"}, authz.Resource{ID: "doc"}, authz.Action{Name: "read"})
+		req.Context = map[string]string{"region": "eu"}
+		if ok, err := cond.Evaluate(ctx, req); err != nil || !ok {
+			t.Errorf("Expected condition met, got %v, %v", ok, err)
+		}
+		req.Context["region"] = "us"
+		if ok, _ := cond.Evaluate(ctx, req); ok {
+			t.Error("Expected condition not met")
+		}
+	})
+
+	t.Run("Limits Enforced", func(t *testing.T) {
+		slow := fakeRuntime{fn: func(ctx context.Context, _ CheckRequest) ([]byte, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}}
+		p, _ := Load(ctx, slow, "slow", module, Limits{Timeout: 10 * time.Millisecond}, Host{})
+		if _, err := p.Evaluate(ctx, &CheckRequest{Kind: KindAuthorization}); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("Expected ErrLimitExceeded for timeout, got %v", err)
+		}
+
+		chatty := fakeRuntime{fn: func(context.Context, CheckRequest) ([]byte, error) {
+			return make([]byte, 1024), nil
+		}}
+		p, _ = Load(ctx, chatty, "chatty", module, Limits{MaxOutputBytes: 512}, Host{})
+		if _, err := p.Evaluate(ctx, &CheckRequest{}); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("Expected ErrLimitExceeded for output, got %v", err)
+		}
+
+		trap := fakeRuntime{fn: func(context.Context, CheckRequest) ([]byte, error) {
+			return nil, errors.New("unreachable executed")
+		}}
+		p, _ = Load(ctx, trap, "trap", module, Limits{}, Host{})
+		if _, err := p.Evaluate(ctx, &CheckRequest{}); !errors.Is(err, ErrPluginFailed) {
+			t.Errorf("Expected ErrPluginFailed, got %v", err)
+		}
+	})
+}
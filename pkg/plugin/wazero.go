@@ -0,0 +1,178 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// Names of the guest ABI
+const (
+	// HostModule is the only module a guest may import from
+	HostModule = "gauth"
+
+	// AllocFunction is the guest export that reserves input memory:
+	// alloc(size i32) -> ptr i32
+	AllocFunction = "alloc"
+
+	// MemoryExport is the guest's exported linear memory
+	MemoryExport = "memory"
+)
+
+// hostFunctions are the imports HostModule provides
+var hostFunctions = map[string]bool{"log": true, "now_unix_ms": true}
+
+// WazeroRuntime runs modules on the wazero engine, in pure Go. Each module
+// gets its own engine instance capped at Limits.MemoryPages, is refused at
+// load if it imports anything but the gauth host functions, so WASI is not
+// available, and runs every call in a fresh instance that is closed when
+// the call's context is done.
+type WazeroRuntime struct{}
+
+// NewWazeroRuntime creates a wazero backed Runtime
+func NewWazeroRuntime() *WazeroRuntime {
+	return &WazeroRuntime{}
+}
+
+// Compile implements Runtime
+func (*WazeroRuntime) Compile(ctx context.Context, name string, wasm []byte, limits Limits, host Host) (Module, error) {
+	limits = limits.withDefaults()
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(limits.MemoryPages).
+		WithCloseOnContextDone(true))
+
+	compiled, err := r.CompileModule(ctx, wasm)
+	if err != nil {
+		_ = r.Close(ctx)
+		return nil, classifyCompileError(ctx, wasm, err)
+	}
+	if err := checkABI(compiled); err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+	if err := instantiateHost(ctx, r, host); err != nil {
+		_ = r.Close(ctx)
+		return nil, fmt.Errorf("failed to instantiate host module: %w", err)
+	}
+	return &wazeroModule{name: name, runtime: r, compiled: compiled, limits: limits}, nil
+}
+
+// classifyCompileError tells a module that only fails because its declared
+// memory is over the cap from one that is malformed
+func classifyCompileError(ctx context.Context, wasm []byte, err error) error {
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+	if _, uncapped := r.CompileModule(ctx, wasm); uncapped == nil {
+		return fmt.Errorf("%w: %v", ErrLimitExceeded, err)
+	}
+	return fmt.Errorf("%w: %v", ErrInvalidModule, err)
+}
+
+// checkABI refuses modules that import outside the host API or lack the
+// guest exports
+func checkABI(compiled wazero.CompiledModule) error {
+	for _, f := range compiled.ImportedFunctions() {
+		module, name, _ := f.Import()
+		if module != HostModule || !hostFunctions[name] {
+			return fmt.Errorf("%w: imports %s.%s; only the %s host functions are available", ErrInvalidModule, module, name, HostModule)
+		}
+	}
+	for _, m := range compiled.ImportedMemories() {
+		module, name, _ := m.Import()
+		return fmt.Errorf("%w: imports memory %s.%s", ErrInvalidModule, module, name)
+	}
+	exports := compiled.ExportedFunctions()
+	for _, name := range []string{AllocFunction, CheckFunction} {
+		if _, ok := exports[name]; !ok {
+			return fmt.Errorf("%w: missing export %q", ErrInvalidModule, name)
+		}
+	}
+	if _, ok := compiled.ExportedMemories()[MemoryExport]; !ok {
+		return fmt.Errorf("%w: missing export %q", ErrInvalidModule, MemoryExport)
+	}
+	return nil
+}
+
+// instantiateHost provides gauth.log(level_ptr, level_len, msg_ptr, msg_len)
+// and gauth.now_unix_ms()
+func instantiateHost(ctx context.Context, r wazero.Runtime, host Host) error {
+	if host.Log == nil {
+		host.Log = func(string, string) {}
+	}
+	if host.Now == nil {
+		host.Now = time.Now
+	}
+	_, err := r.NewHostModuleBuilder(HostModule).
+		NewFunctionBuilder().
+		WithFunc(func(_ context.Context, m api.Module, levelPtr, levelLen, msgPtr, msgLen uint32) {
+			level, ok1 := m.Memory().Read(levelPtr, levelLen)
+			msg, ok2 := m.Memory().Read(msgPtr, msgLen)
+			if ok1 && ok2 {
+				host.Log(string(level), string(msg))
+			}
+		}).
+		Export("log").
+		NewFunctionBuilder().
+		WithFunc(func(context.Context) int64 { return host.Now().UnixMilli() }).
+		Export("now_unix_ms").
+		Instantiate(ctx)
+	return err
+}
+
+// wazeroModule is a compiled module and the engine it runs on
+type wazeroModule struct {
+	name     string
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	limits   Limits
+}
+
+// Call instantiates the module, copies input into memory reserved with
+// alloc and calls function(ptr, len), which returns its output as
+// ptr<<32 | len. The instance is discarded afterwards, so no state carries
+// over between calls.
+func (m *wazeroModule) Call(ctx context.Context, function string, input []byte) ([]byte, error) {
+	mod, err := m.runtime.InstantiateModule(ctx, m.compiled, wazero.NewModuleConfig().WithName("").WithStartFunctions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate %s: %w", m.name, err)
+	}
+	defer mod.Close(context.WithoutCancel(ctx))
+
+	fn := mod.ExportedFunction(function)
+	if fn == nil {
+		return nil, fmt.Errorf("module does not export %q", function)
+	}
+	res, err := mod.ExportedFunction(AllocFunction).Call(ctx, uint64(len(input)))
+	if err != nil {
+		return nil, fmt.Errorf("alloc failed: %w", err)
+	}
+	ptr := uint32(res[0])
+	if !mod.Memory().Write(ptr, input) {
+		return nil, fmt.Errorf("alloc returned %d, outside memory", ptr)
+	}
+
+	res, err = fn.Call(ctx, uint64(ptr), uint64(len(input)))
+	if err != nil {
+		return nil, err
+	}
+	outPtr, outLen := uint32(res[0]>>32), uint32(res[0])
+	if int(outLen) > m.limits.MaxOutputBytes {
+		return nil, fmt.Errorf("%w: response is %d bytes", ErrLimitExceeded, outLen)
+	}
+	output, ok := mod.Memory().Read(outPtr, outLen)
+	if !ok {
+		return nil, fmt.Errorf("response at %d+%d is outside memory", outPtr, outLen)
+	}
+	// the view dies with the instance
+	return append([]byte(nil), output...), nil
+}
+
+// Close releases the engine and everything compiled on it
+func (m *wazeroModule) Close(ctx context.Context) error {
+	return m.runtime.Close(ctx)
+}
+
+var _ Runtime = (*WazeroRuntime)(nil)
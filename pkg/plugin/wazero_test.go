@@ -0,0 +1,223 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Wasm value and section encodings used by the hand-assembled guests
+const (
+	i32 = 0x7f
+	i64 = 0x7e
+)
+
+func uleb(v uint64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if v == 0 {
+			return out
+		}
+	}
+}
+
+func sleb(v int64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func vec(items ...[]byte) []byte {
+	return append(uleb(uint64(len(items))), bytes.Join(items, nil)...)
+}
+
+func str(s string) []byte {
+	return append(uleb(uint64(len(s))), s...)
+}
+
+func section(id byte, body []byte) []byte {
+	return append(append([]byte{id}, uleb(uint64(len(body)))...), body...)
+}
+
+func funcType(params, results []byte) []byte {
+	return append(append([]byte{0x60}, vec(splitBytes(params)...)...), vec(splitBytes(results)...)...)
+}
+
+func splitBytes(b []byte) [][]byte {
+	out := make([][]byte, len(b))
+	for i := range b {
+		out[i] = b[i : i+1]
+	}
+	return out
+}
+
+// guest describes a module with the plugin ABI: alloc always returns 1024
+// and check runs the given body, with data placed at offset 0
+type guest struct {
+	imports [][2]string
+	memory  uint32
+	data    string
+	check   []byte
+}
+
+// Types of the guest, indexed by the import and function sections
+var guestTypes = map[string]int{"alloc": 0, "check": 1, "log": 2, "now_unix_ms": 3, "fd_write": 4}
+
+func (g guest) wasm() []byte {
+	types := vec(
+		funcType([]byte{i32}, []byte{i32}),
+		funcType([]byte{i32, i32}, []byte{i64}),
+		funcType([]byte{i32, i32, i32, i32}, nil),
+		funcType(nil, []byte{i64}),
+		funcType([]byte{i32, i32, i32, i32}, []byte{i32}),
+	)
+	var imports [][]byte
+	for _, imp := range g.imports {
+		imports = append(imports, append(append(str(imp[0]), str(imp[1])...), 0x00, byte(guestTypes[imp[1]])))
+	}
+	n := uint64(len(g.imports))
+	alloc := []byte{0x00, 0x41, 0x80, 0x08, 0x0b} // i32.const 1024
+	check := append([]byte{0x00}, g.check...)
+
+	out := append([]byte(nil), wasmHeader...)
+	out = append(out, section(1, types)...)
+	out = append(out, section(2, vec(imports...))...)
+	out = append(out, section(3, vec([]byte{0}, []byte{1}))...)
+	out = append(out, section(5, vec(append([]byte{0x00}, uleb(uint64(g.memory))...)))...)
+	out = append(out, section(7, vec(
+		append(str(MemoryExport), 0x02, 0x00),
+		append(str(AllocFunction), append([]byte{0x00}, uleb(n)...)...),
+		append(str(CheckFunction), append([]byte{0x00}, uleb(n+1)...)...),
+	))...)
+	out = append(out, section(10, vec(
+		append(uleb(uint64(len(alloc))), alloc...),
+		append(uleb(uint64(len(check))), check...),
+	))...)
+	if g.data != "" {
+		out = append(out, section(11, vec(append([]byte{0x00, 0x41, 0x00, 0x0b}, str(g.data)...)))...)
+	}
+	return out
+}
+
+// respond returns the data at off as the response and ends the body
+func respond(off, n int) []byte {
+	return append(append([]byte{0x42}, sleb(int64(off)<<32|int64(n))...), 0x0b)
+}
+
+func TestWazeroRuntime(t *testing.T) {
+	ctx := context.Background()
+	runtime := NewWazeroRuntime()
+	const allow = `{"allow":true,"reason":"wasm"}`
+
+	t.Run("Check", func(t *testing.T) {
+		var logged []string
+		const data = "info" + "checked" + allow
+		// gauth.log("info", "checked")
+		body := []byte{0x41, 0x00, 0x41, 0x04, 0x41, 0x04, 0x41, 0x07, 0x10, 0x00}
+		p, err := Load(ctx, runtime, "allow", guest{
+			imports: [][2]string{{HostModule, "log"}},
+			memory:  1,
+			data:    data,
+			check:   append(body, respond(11, len(allow))...),
+		}.wasm(), Limits{}, Host{Log: func(level, msg string) { logged = append(logged, level+": "+msg) }})
+		if err != nil {
+			t.Fatalf("Load() error: %v", err)
+		}
+		defer p.Close(ctx)
+
+		for i := 0; i < 2; i++ {
+			resp, err := p.Evaluate(ctx, &CheckRequest{Kind: KindIssuance, Subject: "u"})
+			if err != nil {
+				t.Fatalf("Evaluate() error: %v", err)
+			}
+			if !resp.Allow || resp.Reason != "wasm" {
+				t.Errorf("Unexpected response %+v", resp)
+			}
+		}
+		if len(logged) != 2 || logged[0] != "info: checked" {
+			t.Errorf("Expected the guest to log through the host, got %v", logged)
+		}
+	})
+
+	t.Run("WASI Denied", func(t *testing.T) {
+		_, err := Load(ctx, runtime, "wasi", guest{
+			imports: [][2]string{{"wasi_snapshot_preview1", "fd_write"}},
+			memory:  1,
+			check:   respond(0, 0),
+		}.wasm(), Limits{}, Host{})
+		if !errors.Is(err, ErrInvalidModule) {
+			t.Errorf("Expected ErrInvalidModule for a WASI import, got %v", err)
+		}
+	})
+
+	t.Run("CPU Limit", func(t *testing.T) {
+		// loop br 0 end
+		spin := append([]byte{0x03, 0x40, 0x0c, 0x00, 0x0b}, respond(0, 0)...)
+		p, err := Load(ctx, runtime, "spin", guest{memory: 1, check: spin}.wasm(), Limits{Timeout: 20 * time.Millisecond}, Host{})
+		if err != nil {
+			t.Fatalf("Load() error: %v", err)
+		}
+		defer p.Close(ctx)
+		start := time.Now()
+		if _, err := p.Evaluate(ctx, &CheckRequest{}); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("Expected ErrLimitExceeded for an endless loop, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected the call to be cut off, took %s", elapsed)
+		}
+	})
+
+	t.Run("Memory Limit", func(t *testing.T) {
+		_, err := Load(ctx, runtime, "big", guest{memory: 32, check: respond(0, 0)}.wasm(), Limits{MemoryPages: 16}, Host{})
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("Expected ErrLimitExceeded for 32 initial pages, got %v", err)
+		}
+
+		// memory.grow 64 pages; trap if refused
+		grow := append([]byte{0x41, 0xc0, 0x00, 0x40, 0x00, 0x41, 0x7f, 0x46, 0x04, 0x40, 0x00, 0x0b},
+			respond(0, len(allow))...)
+		module := guest{memory: 1, data: allow, check: grow}.wasm()
+		p, err := Load(ctx, runtime, "grow", module, Limits{MemoryPages: 16}, Host{})
+		if err != nil {
+			t.Fatalf("Load() error: %v", err)
+		}
+		defer p.Close(ctx)
+		if _, err := p.Evaluate(ctx, &CheckRequest{}); !errors.Is(err, ErrPluginFailed) {
+			t.Errorf("Expected growth past the cap to be refused, got %v", err)
+		}
+
+		roomy, err := Load(ctx, runtime, "grow", module, Limits{MemoryPages: 128}, Host{})
+		if err != nil {
+			t.Fatalf("Load() error: %v", err)
+		}
+		defer roomy.Close(ctx)
+		if resp, err := roomy.Evaluate(ctx, &CheckRequest{}); err != nil || !resp.Allow {
+			t.Errorf("Expected growth within the cap, got %+v, %v", resp, err)
+		}
+	})
+
+	t.Run("Output Limit", func(t *testing.T) {
+		p, err := Load(ctx, runtime, "chatty", guest{memory: 1, check: respond(0, 1<<15)}.wasm(), Limits{}, Host{})
+		if err != nil {
+			t.Fatalf("Load() error: %v", err)
+		}
+		defer p.Close(ctx)
+		if _, err := p.Evaluate(ctx, &CheckRequest{}); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("Expected ErrLimitExceeded for a 32KiB response, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,525 @@
+// Package portal is the backend of a self-service delegation portal. It
+// lets a signed-in user list their tokens and delegations, revoke the
+// powers they granted, download their audit history and request new
+// delegations that take effect once approved. Authentication is left to the
+// caller: every method takes the already authenticated user ID.
+package portal
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/audit"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// Portal errors
+var (
+	// ErrInvalidConfig indicates a Config that cannot be used
+	ErrInvalidConfig = errors.New("invalid portal configuration")
+
+	// ErrForbidden indicates the user is not a party to the token or request
+	ErrForbidden = errors.New("not permitted for this user")
+
+	// ErrRequestNotFound indicates an unknown delegation request
+	ErrRequestNotFound = errors.New("delegation request not found")
+
+	// ErrNotPending indicates a request that was already decided
+	ErrNotPending = errors.New("delegation request is not pending")
+
+	// ErrInvalidRequest indicates a delegation request that cannot be granted
+	ErrInvalidRequest = errors.New("invalid delegation request")
+)
+
+// Audit trail constants
+const (
+	// TypePortal is the audit entry type of portal actions
+	TypePortal = "portal"
+
+	ActionTokenRevoked       = "token_revoked"
+	ActionGrantRevoked       = "grant_revoked"
+	ActionAuditExported      = "audit_exported"
+	ActionDelegationRequest  = "delegation_requested"
+	ActionDelegationApproved = "delegation_approved"
+	ActionDelegationRejected = "delegation_rejected"
+	ActionRequestCancelled   = "delegation_request_cancelled"
+)
+
+// ExportFormat is the encoding of an audit history download
+type ExportFormat string
+
+// Export formats
+const (
+	// ExportJSON is a JSON array of audit entries
+	ExportJSON ExportFormat = "json"
+
+	// ExportCSV is a CSV file with a header row
+	ExportCSV ExportFormat = "csv"
+)
+
+// Direction tells whether the user granted or received a delegation
+type Direction string
+
+// Delegation directions
+const (
+	Granted  Direction = "granted"
+	Received Direction = "received"
+)
+
+// Config configures a Portal
+type Config struct {
+	// Tokens lists and revokes tokens
+	Tokens token.ServiceAPI
+
+	// Delegator issues approved delegations; without it requests can be
+	// opened but not approved
+	Delegator *token.Delegator
+
+	// Requests stores delegation requests (default: in-memory)
+	Requests RequestStore
+
+	// Audit records portal actions and serves the audit history download.
+	// Optional; without it nothing is recorded and exports fail.
+	Audit audit.Storage
+
+	// CanApprove decides whether approver may decide a request. The
+	// request's principal and delegate are always excluded. Without it no
+	// one may approve requests (optional)
+	CanApprove func(ctx context.Context, approver string, r *DelegationRequest) bool
+
+	// MaxValidity caps how long a requested delegation may last (default: 90 days)
+	MaxValidity time.Duration
+
+	// Now returns the current time (default: time.Now)
+	Now func() time.Time
+}
+
+// Portal serves the self-service operations of one deployment
+type Portal struct {
+	config Config
+
+	// mu serializes request decisions so a request is approved at most once
+	mu sync.Mutex
+}
+
+// New creates a portal
+func New(config Config) (*Portal, error) {
+	if config.Tokens == nil {
+		return nil, fmt.Errorf("%w: token service is required", ErrInvalidConfig)
+	}
+	if config.Requests == nil {
+		config.Requests = NewMemoryRequestStore()
+	}
+	if config.MaxValidity <= 0 {
+		config.MaxValidity = 90 * 24 * time.Hour
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Portal{config: config}, nil
+}
+
+// Delegation is one delegation the user is a party to
+type Delegation struct {
+	TokenID      string              `json:"token_id"`
+	Direction    Direction           `json:"direction"`
+	Principal    string              `json:"principal"`
+	Delegate     string              `json:"delegate"`
+	Scopes       []string            `json:"scopes"`
+	Restrictions *token.Restrictions `json:"restrictions,omitempty"`
+	GrantedAt    time.Time           `json:"granted_at"`
+	ExpiresAt    time.Time           `json:"expires_at,omitempty"`
+
+	// Chain is the full delegation chain of the token
+	Chain *token.DelegationChain `json:"chain"`
+}
+
+// Tokens returns the user's active tokens. Signed values are not returned.
+func (p *Portal) Tokens(ctx context.Context, user string) ([]*token.Token, error) {
+	tokens, err := p.config.Tokens.List(ctx, token.Filter{Subject: user, Active: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+	for _, t := range tokens {
+		t.Value = ""
+	}
+	sortTokens(tokens)
+	return tokens, nil
+}
+
+// Delegations returns the active delegations the user granted, as a
+// principal anywhere in a chain, or received, as the holder of a token
+func (p *Portal) Delegations(ctx context.Context, user string) ([]Delegation, error) {
+	tokens, err := p.config.Tokens.List(ctx, token.Filter{Active: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+	sortTokens(tokens)
+	var out []Delegation
+	for _, t := range tokens {
+		chain := token.DelegationChainOf(t)
+		if chain == nil {
+			continue
+		}
+		for _, link := range chain.Links {
+			var dir Direction
+			switch {
+			case link.Principal == user:
+				dir = Granted
+			case link.Delegate == user && t.Subject == user:
+				dir = Received
+			default:
+				continue
+			}
+			out = append(out, Delegation{
+				TokenID:      t.ID,
+				Direction:    dir,
+				Principal:    link.Principal,
+				Delegate:     link.Delegate,
+				Scopes:       link.Scopes,
+				Restrictions: link.Restrictions,
+				GrantedAt:    link.GrantedAt,
+				ExpiresAt:    link.ExpiresAt,
+				Chain:        chain,
+			})
+		}
+	}
+	return out, nil
+}
+
+// RevokeToken revokes one of the user's own tokens
+func (p *Portal) RevokeToken(ctx context.Context, user, tokenID string, reason token.RevocationReason) error {
+	t, err := p.config.Tokens.GetToken(ctx, tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to get token: %w", err)
+	}
+	if t.Subject != user {
+		p.record(ctx, user, ActionTokenRevoked, tokenID, ErrForbidden, "reason", string(reason))
+		return ErrForbidden
+	}
+	err = p.config.Tokens.Revoke(ctx, t, reason)
+	p.record(ctx, user, ActionTokenRevoked, tokenID, err, "reason", string(reason))
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// RevokeGrant revokes a delegated token whose chain includes a power the
+// user granted
+func (p *Portal) RevokeGrant(ctx context.Context, user, tokenID string, reason token.RevocationReason) error {
+	t, err := p.config.Tokens.GetToken(ctx, tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to get token: %w", err)
+	}
+	if !grantedBy(t, user) {
+		p.record(ctx, user, ActionGrantRevoked, tokenID, ErrForbidden, "reason", string(reason))
+		return ErrForbidden
+	}
+	err = p.config.Tokens.Revoke(ctx, t, reason)
+	p.record(ctx, user, ActionGrantRevoked, tokenID, err, "reason", string(reason))
+	if err != nil {
+		return fmt.Errorf("failed to revoke grant: %w", err)
+	}
+	return nil
+}
+
+// RevokeAllGrants revokes every active delegated token carrying a power the
+// user granted and returns how many were revoked
+func (p *Portal) RevokeAllGrants(ctx context.Context, user string, reason token.RevocationReason) (int, error) {
+	delegations, err := p.Delegations(ctx, user)
+	if err != nil {
+		return 0, err
+	}
+	revoked := 0
+	seen := make(map[string]bool)
+	for _, d := range delegations {
+		if d.Direction != Granted || seen[d.TokenID] {
+			continue
+		}
+		seen[d.TokenID] = true
+		if err := p.RevokeGrant(ctx, user, d.TokenID, reason); err != nil {
+			if errors.Is(err, token.ErrTokenNotFound) {
+				continue
+			}
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// ExportOptions limits an audit history download
+type ExportOptions struct {
+	// Since and Until bound the entry timestamps (zero = unbounded)
+	Since time.Time
+	Until time.Time
+}
+
+// ExportAuditHistory writes the audit entries in which the user is the
+// actor or the target, oldest first
+func (p *Portal) ExportAuditHistory(ctx context.Context, user string, w io.Writer, format ExportFormat, opts ExportOptions) error {
+	if p.config.Audit == nil {
+		return fmt.Errorf("%w: audit storage is required to export history", ErrInvalidConfig)
+	}
+	if format != ExportJSON && format != ExportCSV {
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+	var timeRange *audit.TimeRange
+	if !opts.Since.IsZero() || !opts.Until.IsZero() {
+		until := opts.Until
+		if until.IsZero() {
+			until = p.config.Now()
+		}
+		timeRange = &audit.TimeRange{Start: opts.Since, End: until}
+	}
+	acted, err := p.config.Audit.Search(ctx, &audit.Filter{ActorIDs: []string{user}, TimeRange: timeRange})
+	if err != nil {
+		return fmt.Errorf("failed to search audit entries: %w", err)
+	}
+	// Storage cannot filter by target, so entries about the user are
+	// selected here
+	all, err := p.config.Audit.Search(ctx, &audit.Filter{TimeRange: timeRange})
+	if err != nil {
+		return fmt.Errorf("failed to search audit entries: %w", err)
+	}
+	var entries []*audit.Entry
+	for _, e := range append(acted, all...) {
+		if e.ActorID == user || e.TargetID == user {
+			entries = append(entries, e)
+		}
+	}
+	entries = dedupe(entries)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Timestamp.Before(entries[j].Timestamp) })
+
+	if format == ExportJSON {
+		err = json.NewEncoder(w).Encode(entries)
+	} else {
+		err = writeCSV(w, entries)
+	}
+	p.record(ctx, user, ActionAuditExported, user, err, "format", string(format))
+	if err != nil {
+		return fmt.Errorf("failed to write audit history: %w", err)
+	}
+	return nil
+}
+
+// RequestInput describes a delegation a user asks to grant
+type RequestInput struct {
+	Delegate     string
+	Scope        string
+	Restrictions *token.Restrictions
+	ValidUntil   time.Time
+	Reason       string
+}
+
+// RequestDelegation opens a pending request for the user to delegate a
+// power to an agent. No token is issued until the request is approved.
+func (p *Portal) RequestDelegation(ctx context.Context, user string, in RequestInput) (*DelegationRequest, error) {
+	now := p.config.Now()
+	r := &DelegationRequest{
+		ID:           token.NewID(),
+		Principal:    user,
+		Delegate:     in.Delegate,
+		Scope:        in.Scope,
+		Restrictions: in.Restrictions,
+		ValidUntil:   in.ValidUntil,
+		Reason:       in.Reason,
+		Status:       StatusPending,
+		CreatedAt:    now,
+	}
+	if err := r.options().Validate(r.Delegate, now); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	if r.ValidUntil.Sub(now) > p.config.MaxValidity {
+		return nil, fmt.Errorf("%w: delegations may last at most %s", ErrInvalidRequest, p.config.MaxValidity)
+	}
+	if err := p.config.Requests.Save(ctx, r); err != nil {
+		return nil, fmt.Errorf("failed to save delegation request: %w", err)
+	}
+	p.record(ctx, user, ActionDelegationRequest, r.ID, nil, "delegate", r.Delegate, "scope", r.Scope)
+	return r, nil
+}
+
+// Requests returns the delegation requests the user opened
+func (p *Portal) Requests(ctx context.Context, user string) ([]*DelegationRequest, error) {
+	return p.config.Requests.List(ctx, RequestFilter{Principal: user})
+}
+
+// PendingApprovals returns the pending requests the approver may decide
+func (p *Portal) PendingApprovals(ctx context.Context, approver string) ([]*DelegationRequest, error) {
+	pending, err := p.config.Requests.List(ctx, RequestFilter{Status: StatusPending})
+	if err != nil {
+		return nil, err
+	}
+	var out []*DelegationRequest
+	for _, r := range pending {
+		if p.canApprove(ctx, approver, r) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// Approve issues the delegation of a pending request through the Delegator
+func (p *Portal) Approve(ctx context.Context, approver, id string) (*DelegationRequest, error) {
+	if p.config.Delegator == nil {
+		return nil, fmt.Errorf("%w: a delegator is required to approve requests", ErrInvalidConfig)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r, err := p.pending(ctx, approver, id, ActionDelegationApproved)
+	if err != nil {
+		return nil, err
+	}
+	et, err := p.config.Delegator.Issue(ctx, r.Delegate, r.options())
+	if err != nil {
+		p.record(ctx, approver, ActionDelegationApproved, r.ID, err)
+		return nil, fmt.Errorf("failed to issue delegation: %w", err)
+	}
+	r.Status, r.TokenID = StatusApproved, et.ID
+	return r, p.decide(ctx, approver, r, ActionDelegationApproved, "token_id", et.ID)
+}
+
+// Reject closes a pending request without issuing anything
+func (p *Portal) Reject(ctx context.Context, approver, id, comment string) (*DelegationRequest, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r, err := p.pending(ctx, approver, id, ActionDelegationRejected)
+	if err != nil {
+		return nil, err
+	}
+	r.Status, r.Comment = StatusRejected, comment
+	return r, p.decide(ctx, approver, r, ActionDelegationRejected)
+}
+
+// Cancel withdraws one of the user's own pending requests
+func (p *Portal) Cancel(ctx context.Context, user, id string) (*DelegationRequest, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r, err := p.config.Requests.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if r.Principal != user {
+		return nil, ErrForbidden
+	}
+	if r.Status != StatusPending {
+		return nil, fmt.Errorf("%w: %s", ErrNotPending, r.Status)
+	}
+	r.Status = StatusCancelled
+	return r, p.decide(ctx, user, r, ActionRequestCancelled)
+}
+
+// canApprove applies CanApprove, never letting the parties of a request
+// decide it
+func (p *Portal) canApprove(ctx context.Context, approver string, r *DelegationRequest) bool {
+	if p.config.CanApprove == nil || approver == r.Principal || approver == r.Delegate {
+		return false
+	}
+	return p.config.CanApprove(ctx, approver, r)
+}
+
+// pending loads a request the approver may decide
+func (p *Portal) pending(ctx context.Context, approver, id, action string) (*DelegationRequest, error) {
+	r, err := p.config.Requests.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !p.canApprove(ctx, approver, r) {
+		p.record(ctx, approver, action, r.ID, ErrForbidden)
+		return nil, ErrForbidden
+	}
+	if r.Status != StatusPending {
+		return nil, fmt.Errorf("%w: %s", ErrNotPending, r.Status)
+	}
+	return r, nil
+}
+
+// decide stores a decided request and records the decision
+func (p *Portal) decide(ctx context.Context, actor string, r *DelegationRequest, action string, kv ...string) error {
+	r.DecidedBy, r.DecidedAt = actor, p.config.Now()
+	if err := p.config.Requests.Save(ctx, r); err != nil {
+		return fmt.Errorf("failed to save delegation request: %w", err)
+	}
+	p.record(ctx, actor, action, r.ID, nil, append([]string{"principal", r.Principal, "delegate", r.Delegate}, kv...)...)
+	return nil
+}
+
+// record writes an audit entry for a portal action. Audit failures do not
+// fail the action.
+func (p *Portal) record(ctx context.Context, actor, action, target string, err error, kv ...string) {
+	if p.config.Audit == nil {
+		return
+	}
+	e := audit.NewEntry(TypePortal).
+		WithActor(actor, audit.ActorUser).
+		WithAction(action).
+		WithTarget(target, "").
+		WithResult(audit.ResultSuccess).
+		WithContext(ctx)
+	e.Timestamp = p.config.Now()
+	if err != nil {
+		e.Result = "failure"
+		e.Error = err.Error()
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		e.WithMetadata(kv[i], kv[i+1])
+	}
+	_ = p.config.Audit.Store(ctx, e)
+}
+
+// grantedBy reports whether user granted a link of the token's chain
+func grantedBy(t *token.Token, user string) bool {
+	chain := token.DelegationChainOf(t)
+	if chain == nil {
+		return false
+	}
+	for _, link := range chain.Links {
+		if link.Principal == user {
+			return true
+		}
+	}
+	return false
+}
+
+// dedupe drops entries whose ID was already seen
+func dedupe(entries []*audit.Entry) []*audit.Entry {
+	seen := make(map[string]bool, len(entries))
+	out := entries[:0]
+	for _, e := range entries {
+		if e.ID != "" && seen[e.ID] {
+			continue
+		}
+		seen[e.ID] = true
+		out = append(out, e)
+	}
+	return out
+}
+
+func sortTokens(tokens []*token.Token) {
+	sort.Slice(tokens, func(i, j int) bool {
+		if !tokens[i].IssuedAt.Equal(tokens[j].IssuedAt) {
+			return tokens[i].IssuedAt.Before(tokens[j].IssuedAt)
+		}
+		return tokens[i].ID < tokens[j].ID
+	})
+}
+
+func writeCSV(w io.Writer, entries []*audit.Entry) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"id", "timestamp", "type", "action", "result", "actor_id", "target_id", "target_type", "error"})
+	for _, e := range entries {
+		_ = cw.Write([]string{e.ID, e.Timestamp.UTC().Format(time.RFC3339), e.Type, e.Action, e.Result,
+			e.ActorID, e.TargetID, e.TargetType, e.Error})
+	}
+	cw.Flush()
+	return cw.Error()
+}
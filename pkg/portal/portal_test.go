@@ -0,0 +1,213 @@
+package portal
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/audit"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+type memoryAudit struct {
+	audit.Storage
+	mu      sync.Mutex
+	entries []*audit.Entry
+}
+
+func (a *memoryAudit) Store(_ context.Context, e *audit.Entry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	e.ID = token.NewID()
+	a.entries = append(a.entries, e)
+	return nil
+}
+
+func (a *memoryAudit) Search(_ context.Context, f *audit.Filter) ([]*audit.Entry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var out []*audit.Entry
+	for _, e := range a.entries {
+		if len(f.ActorIDs) > 0 && e.ActorID != f.ActorIDs[0] {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+func TestPortal(t *testing.T) {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	tokens := token.NewService(token.Config{SigningKey: key, ValidityPeriod: time.Hour}, token.NewMemoryStore(time.Hour))
+	delegator, err := token.NewDelegator(token.DelegatorConfig{Service: tokens})
+	if err != nil {
+		t.Fatalf("NewDelegator() error: %v", err)
+	}
+	trail := &memoryAudit{}
+	// Everyone may approve, to show the parties are excluded anyway
+	anyone := func(context.Context, string, *DelegationRequest) bool { return true }
+	p, err := New(Config{Tokens: tokens, Delegator: delegator, Audit: trail, CanApprove: anyone})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if _, err := New(Config{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Expected ErrInvalidConfig without a token service, got %v", err)
+	}
+	closed, err := New(Config{Tokens: tokens, Delegator: delegator})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	own, err := tokens.Issue(ctx, &token.Token{ID: token.NewID(), Type: token.Access, Subject: "alice", Scopes: []string{"read"}})
+	if err != nil {
+		t.Fatalf("Issue() error: %v", err)
+	}
+	input := RequestInput{Delegate: "agent-1", Scope: "payments:initiate", ValidUntil: time.Now().Add(24 * time.Hour), Reason: "monthly invoices"}
+
+	t.Run("Default Deny", func(t *testing.T) {
+		r, err := closed.RequestDelegation(ctx, "alice", input)
+		if err != nil {
+			t.Fatalf("RequestDelegation() error: %v", err)
+		}
+		if _, err := closed.Approve(ctx, "bob", r.ID); !errors.Is(err, ErrForbidden) {
+			t.Errorf("Expected approval refused without CanApprove, got %v", err)
+		}
+	})
+
+	t.Run("Request And Approve", func(t *testing.T) {
+		r, err := p.RequestDelegation(ctx, "alice", input)
+		if err != nil || r.Status != StatusPending {
+			t.Fatalf("RequestDelegation() = %+v, %v", r, err)
+		}
+		if ds, _ := p.Delegations(ctx, "alice"); len(ds) != 0 {
+			t.Errorf("Expected nothing issued before approval, got %+v", ds)
+		}
+		if _, err := p.Approve(ctx, "alice", r.ID); !errors.Is(err, ErrForbidden) {
+			t.Errorf("Expected the principal not to approve their own request, got %v", err)
+		}
+		if _, err := p.Approve(ctx, "agent-1", r.ID); !errors.Is(err, ErrForbidden) {
+			t.Errorf("Expected the delegate to be refused, got %v", err)
+		}
+		if pending, _ := p.PendingApprovals(ctx, "agent-1"); len(pending) != 0 {
+			t.Errorf("Expected nothing pending for the delegate, got %d", len(pending))
+		}
+		if pending, _ := p.PendingApprovals(ctx, "bob"); len(pending) != 1 {
+			t.Errorf("Expected one pending approval for bob, got %d", len(pending))
+		}
+		approved, err := p.Approve(ctx, "bob", r.ID)
+		if err != nil || approved.Status != StatusApproved || approved.TokenID == "" || approved.DecidedBy != "bob" {
+			t.Fatalf("Approve() = %+v, %v", approved, err)
+		}
+		if _, err := p.Approve(ctx, "bob", r.ID); !errors.Is(err, ErrNotPending) {
+			t.Errorf("Expected a second approval to fail, got %v", err)
+		}
+
+		granted, _ := p.Delegations(ctx, "alice")
+		if len(granted) != 1 || granted[0].Direction != Granted || granted[0].Delegate != "agent-1" || granted[0].TokenID != approved.TokenID {
+			t.Errorf("Expected alice to see the grant, got %+v", granted)
+		}
+		received, _ := p.Delegations(ctx, "agent-1")
+		if len(received) != 1 || received[0].Direction != Received || received[0].Principal != "alice" {
+			t.Errorf("Expected agent-1 to see the received power, got %+v", received)
+		}
+	})
+
+	t.Run("Reject And Cancel", func(t *testing.T) {
+		r, _ := p.RequestDelegation(ctx, "alice", input)
+		rejected, err := p.Reject(ctx, "bob", r.ID, "not this month")
+		if err != nil || rejected.Status != StatusRejected || rejected.Comment != "not this month" {
+			t.Errorf("Reject() = %+v, %v", rejected, err)
+		}
+		r, _ = p.RequestDelegation(ctx, "alice", input)
+		if _, err := p.Cancel(ctx, "bob", r.ID); !errors.Is(err, ErrForbidden) {
+			t.Errorf("Expected only the principal to cancel, got %v", err)
+		}
+		if c, err := p.Cancel(ctx, "alice", r.ID); err != nil || c.Status != StatusCancelled {
+			t.Errorf("Cancel() = %+v, %v", c, err)
+		}
+		if _, err := p.Approve(ctx, "bob", "missing"); !errors.Is(err, ErrRequestNotFound) {
+			t.Errorf("Expected ErrRequestNotFound, got %v", err)
+		}
+		if mine, _ := p.Requests(ctx, "alice"); len(mine) != 3 {
+			t.Errorf("Expected alice's three requests, got %d", len(mine))
+		}
+	})
+
+	t.Run("Invalid Requests", func(t *testing.T) {
+		bad := input
+		bad.Delegate = "alice"
+		if _, err := p.RequestDelegation(ctx, "alice", bad); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("Expected self-delegation to be refused, got %v", err)
+		}
+		bad = input
+		bad.ValidUntil = time.Now().AddDate(1, 0, 0)
+		if _, err := p.RequestDelegation(ctx, "alice", bad); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("Expected validity above MaxValidity to be refused, got %v", err)
+		}
+	})
+
+	t.Run("Tokens And Revocation", func(t *testing.T) {
+		mine, err := p.Tokens(ctx, "alice")
+		if err != nil || len(mine) != 1 || mine[0].ID != own.ID || mine[0].Value != "" {
+			t.Fatalf("Tokens() = %+v, %v", mine, err)
+		}
+		if err := p.RevokeToken(ctx, "bob", own.ID, token.ReasonPrincipalRequest); !errors.Is(err, ErrForbidden) {
+			t.Errorf("Expected bob not to revoke alice's token, got %v", err)
+		}
+		grants, _ := p.Delegations(ctx, "alice")
+		if err := p.RevokeGrant(ctx, "bob", grants[0].TokenID, token.ReasonPrincipalRequest); !errors.Is(err, ErrForbidden) {
+			t.Errorf("Expected bob not to revoke alice's grant, got %v", err)
+		}
+		n, err := p.RevokeAllGrants(ctx, "alice", token.ReasonPrincipalRequest)
+		if err != nil || n != 1 {
+			t.Errorf("RevokeAllGrants() = %d, %v", n, err)
+		}
+		if left, _ := p.Delegations(ctx, "agent-1"); len(left) != 0 {
+			t.Errorf("Expected the grant to be gone, got %+v", left)
+		}
+		if err := p.RevokeToken(ctx, "alice", own.ID, token.ReasonCompromise); err != nil {
+			t.Errorf("RevokeToken() error: %v", err)
+		}
+	})
+
+	t.Run("Audit Export", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := p.ExportAuditHistory(ctx, "alice", &buf, ExportJSON, ExportOptions{}); err != nil {
+			t.Fatalf("ExportAuditHistory() error: %v", err)
+		}
+		var entries []audit.Entry
+		if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+			t.Fatalf("Unmarshal() error: %v", err)
+		}
+		for _, e := range entries {
+			if e.ActorID != "alice" && e.TargetID != "alice" {
+				t.Errorf("Expected only alice's history, got %+v", e)
+			}
+		}
+		if len(entries) < 5 {
+			t.Errorf("Expected alice's requests and revocations, got %d entries", len(entries))
+		}
+
+		buf.Reset()
+		if err := p.ExportAuditHistory(ctx, "bob", &buf, ExportCSV, ExportOptions{}); err != nil {
+			t.Fatalf("ExportAuditHistory() error: %v", err)
+		}
+		if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); !strings.HasPrefix(lines[0], "id,timestamp") || len(lines) < 3 {
+			t.Errorf("Unexpected CSV export %q", buf.String())
+		}
+		if err := p.ExportAuditHistory(ctx, "bob", &buf, "xml", ExportOptions{}); err == nil {
+			t.Error("Expected an unsupported format to fail")
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package portal
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// RequestStatus is the state of a delegation request
+type RequestStatus string
+
+// Request states
+const (
+	StatusPending   RequestStatus = "pending"
+	StatusApproved  RequestStatus = "approved"
+	StatusRejected  RequestStatus = "rejected"
+	StatusCancelled RequestStatus = "cancelled"
+)
+
+// DelegationRequest is a delegation a user asked for that waits for
+// approval before a token is issued
+type DelegationRequest struct {
+	ID string `json:"id"`
+
+	// Principal is the user granting the power; they open the request
+	Principal string `json:"principal"`
+
+	// Delegate is the agent that receives the power once approved
+	Delegate string `json:"delegate"`
+
+	Scope        string              `json:"scope"`
+	Restrictions *token.Restrictions `json:"restrictions,omitempty"`
+	ValidUntil   time.Time           `json:"valid_until"`
+	Reason       string              `json:"reason,omitempty"`
+
+	Status    RequestStatus `json:"status"`
+	CreatedAt time.Time     `json:"created_at"`
+
+	// DecidedBy and DecidedAt record who approved, rejected or cancelled it
+	DecidedBy string    `json:"decided_by,omitempty"`
+	DecidedAt time.Time `json:"decided_at,omitempty"`
+
+	// Comment is the approver's note, e.g. the rejection reason
+	Comment string `json:"comment,omitempty"`
+
+	// TokenID is the delegated token issued on approval
+	TokenID string `json:"token_id,omitempty"`
+}
+
+// options returns the delegation options the request asks for
+func (r *DelegationRequest) options() token.DelegationOptions {
+	return token.DelegationOptions{
+		Principal:    r.Principal,
+		Scope:        r.Scope,
+		Restrictions: r.Restrictions,
+		ValidUntil:   r.ValidUntil,
+	}
+}
+
+// RequestFilter selects delegation requests; empty fields match everything
+type RequestFilter struct {
+	Principal string
+	Delegate  string
+	Status    RequestStatus
+}
+
+func (f RequestFilter) matches(r *DelegationRequest) bool {
+	return (f.Principal == "" || r.Principal == f.Principal) &&
+		(f.Delegate == "" || r.Delegate == f.Delegate) &&
+		(f.Status == "" || r.Status == f.Status)
+}
+
+// RequestStore persists delegation requests
+type RequestStore interface {
+	// Save creates or replaces a request
+	Save(ctx context.Context, r *DelegationRequest) error
+
+	// Get returns a request or ErrRequestNotFound
+	Get(ctx context.Context, id string) (*DelegationRequest, error)
+
+	// List returns the matching requests, oldest first
+	List(ctx context.Context, filter RequestFilter) ([]*DelegationRequest, error)
+}
+
+// MemoryRequestStore is an in-memory RequestStore
+type MemoryRequestStore struct {
+	mu       sync.RWMutex
+	requests map[string]*DelegationRequest
+}
+
+// NewMemoryRequestStore creates an empty in-memory request store
+func NewMemoryRequestStore() *MemoryRequestStore {
+	return &MemoryRequestStore{requests: make(map[string]*DelegationRequest)}
+}
+
+// Save creates or replaces a request
+func (s *MemoryRequestStore) Save(_ context.Context, r *DelegationRequest) error {
+	c := *r
+	s.mu.Lock()
+	s.requests[r.ID] = &c
+	s.mu.Unlock()
+	return nil
+}
+
+// Get returns a copy of a request
+func (s *MemoryRequestStore) Get(_ context.Context, id string) (*DelegationRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.requests[id]
+	if !ok {
+		return nil, ErrRequestNotFound
+	}
+	c := *r
+	return &c, nil
+}
+
+// List returns copies of the matching requests, oldest first
+func (s *MemoryRequestStore) List(_ context.Context, filter RequestFilter) ([]*DelegationRequest, error) {
+	s.mu.RLock()
+	var out []*DelegationRequest
+	for _, r := range s.requests {
+		if filter.matches(r) {
+			c := *r
+			out = append(out, &c)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out, nil
+}
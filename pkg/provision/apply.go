@@ -0,0 +1,398 @@
+package provision
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+)
+
+// ErrInvalidDocument indicates the desired-state document failed validation
+var ErrInvalidDocument = errors.New("invalid provisioning document")
+
+// PermissionApply allows callers of the apply API to plan and apply documents
+const PermissionApply authz.Permission = "provision:apply"
+
+// Backend holds the live configuration that documents are applied to
+type Backend interface {
+	// Snapshot returns the current live state
+	Snapshot(ctx context.Context) (*Document, error)
+
+	// Create adds a resource
+	Create(ctx context.Context, r Resource) error
+
+	// Update replaces a resource
+	Update(ctx context.Context, r Resource) error
+
+	// Delete removes a resource
+	Delete(ctx context.Context, kind Kind, key string) error
+}
+
+// ApplyOptions controls how a document is applied
+type ApplyOptions struct {
+	// DryRun computes the plan without changing anything
+	DryRun bool `json:"dry_run"`
+
+	// Prune deletes live resources that are absent from the document
+	Prune bool `json:"prune"`
+}
+
+// ApplyResult reports the outcome of an apply
+type ApplyResult struct {
+	Plan    *Plan     `json:"plan"`
+	DryRun  bool      `json:"dry_run"`
+	Applied int       `json:"applied"`
+	At      time.Time `json:"at"`
+}
+
+// Applier computes and applies plans against a backend
+type Applier struct {
+	backend     Backend
+	mu          sync.Mutex
+	lastApplied *Document
+	lastOptions ApplyOptions
+	appliedAt   time.Time
+}
+
+// NewApplier creates an applier for the backend
+func NewApplier(backend Backend) *Applier {
+	return &Applier{backend: backend}
+}
+
+// Plan validates the document and computes the changes needed to apply it
+func (a *Applier) Plan(ctx context.Context, doc *Document, prune bool) (*Plan, error) {
+	if err := Validate(doc); err != nil {
+		return nil, err
+	}
+	current, err := a.backend.Snapshot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read live state: %w", err)
+	}
+	return Diff(current, doc, prune)
+}
+
+// Apply computes the plan for doc and, unless DryRun is set, executes it in
+// order. On failure the result reports how many changes were applied.
+func (a *Applier) Apply(ctx context.Context, doc *Document, opts ApplyOptions) (*ApplyResult, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	plan, err := a.Plan(ctx, doc, opts.Prune)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ApplyResult{Plan: plan, DryRun: opts.DryRun, At: time.Now()}
+	if opts.DryRun {
+		return result, nil
+	}
+
+	if err := a.execute(ctx, plan, &result.Applied); err != nil {
+		return result, err
+	}
+
+	a.lastApplied = doc
+	a.lastOptions = opts
+	a.appliedAt = result.At
+	return result, nil
+}
+
+func (a *Applier) execute(ctx context.Context, plan *Plan, applied *int) error {
+	for _, c := range plan.Changes {
+		var err error
+		switch c.Action {
+		case ActionCreate:
+			err = a.backend.Create(ctx, Resource{Kind: c.Kind, Key: c.Key, Value: c.Desired})
+		case ActionUpdate:
+			err = a.backend.Update(ctx, Resource{Kind: c.Kind, Key: c.Key, Value: c.Desired})
+		case ActionDelete:
+			err = a.backend.Delete(ctx, c.Kind, c.Key)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to %s %s %q: %w", c.Action, c.Kind, c.Key, err)
+		}
+		*applied++
+	}
+	return nil
+}
+
+// LastApplied returns the most recently applied document and when it was applied
+func (a *Applier) LastApplied() (*Document, time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.lastApplied, a.appliedAt
+}
+
+// Handler serves the apply API. POST a JSON document; the query parameters
+// dry_run=true and prune=true map to ApplyOptions. The response is the
+// ApplyResult as JSON. actor returns the authenticated caller, typically the
+// subject of the request's token, whose roles must grant PermissionApply;
+// requests without one are answered 401, and every request is refused when
+// roles is nil.
+func (a *Applier) Handler(actor func(r *http.Request) string, roles authz.RoleManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		caller := ""
+		if actor != nil {
+			caller = actor(r)
+		}
+		if caller == "" {
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+		allowed := false
+		if roles != nil {
+			var err error
+			if allowed, err = authz.SubjectHasPermission(r.Context(), roles, caller, PermissionApply); err != nil {
+				http.Error(w, "failed to resolve roles", http.StatusInternalServerError)
+				return
+			}
+		}
+		if !allowed {
+			http.Error(w, fmt.Sprintf("applying requires %s", PermissionApply), http.StatusForbidden)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 4<<20))
+		if err != nil {
+			http.Error(w, "invalid document: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := checkConditions(body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var doc Document
+		if err := json.Unmarshal(body, &doc); err != nil {
+			http.Error(w, "invalid document: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+		prune, _ := strconv.ParseBool(r.URL.Query().Get("prune"))
+
+		result, err := a.Apply(r.Context(), &doc, ApplyOptions{DryRun: dryRun, Prune: prune})
+		status := http.StatusOK
+		switch {
+		case errors.Is(err, ErrInvalidDocument):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil && result == nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		case err != nil:
+			status = http.StatusConflict
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(struct {
+			*ApplyResult
+			Error string `json:"error,omitempty"`
+		}{result, errString(err)})
+	})
+}
+
+// checkConditions refuses documents whose policies carry conditions. They
+// are code, cannot be decoded from JSON and would otherwise fail with a
+// decoding error that does not name the policy.
+func checkConditions(body []byte) error {
+	var raw struct {
+		Policies []struct {
+			ID         string          `json:"id"`
+			Conditions json.RawMessage `json:"conditions"`
+		} `json:"policies"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return fmt.Errorf("invalid document: %w", err)
+	}
+	for _, p := range raw.Policies {
+		switch string(bytes.TrimSpace(p.Conditions)) {
+		case "", "null", "{}":
+		default:
+			return fmt.Errorf("%w: policy %q: %w", ErrInvalidDocument, p.ID, authz.ErrPolicyNotPersistable)
+		}
+	}
+	return nil
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// Validate checks that every resource has a key, keys are unique per kind,
+// and client and delegation scopes refer to declared scopes (when the
+// document declares any)
+func Validate(doc *Document) error {
+	if doc == nil {
+		return fmt.Errorf("%w: empty document", ErrInvalidDocument)
+	}
+
+	seen := make(map[Kind]map[string]bool)
+	check := func(kind Kind, key string) error {
+		if key == "" {
+			return fmt.Errorf("%w: %s without id or name", ErrInvalidDocument, kind)
+		}
+		if seen[kind] == nil {
+			seen[kind] = make(map[string]bool)
+		}
+		if seen[kind][key] {
+			return fmt.Errorf("%w: duplicate %s %q", ErrInvalidDocument, kind, key)
+		}
+		seen[kind][key] = true
+		return nil
+	}
+
+	for _, s := range doc.Scopes {
+		if err := check(KindScope, s.Name); err != nil {
+			return err
+		}
+	}
+	for _, r := range doc.Roles {
+		if err := check(KindRole, r.Name); err != nil {
+			return err
+		}
+	}
+	for _, c := range doc.Clients {
+		if err := check(KindClient, c.ID); err != nil {
+			return err
+		}
+		if err := checkScopes(doc, seen[KindScope], KindClient, c.ID, c.Scopes); err != nil {
+			return err
+		}
+	}
+	for _, p := range doc.Policies {
+		if p == nil {
+			return fmt.Errorf("%w: null policy", ErrInvalidDocument)
+		}
+		if err := check(KindPolicy, p.ID); err != nil {
+			return err
+		}
+		if p.Effect != authz.Allow && p.Effect != authz.Deny {
+			return fmt.Errorf("%w: policy %q has invalid effect %q", ErrInvalidDocument, p.ID, p.Effect)
+		}
+		if len(p.Conditions) > 0 {
+			return fmt.Errorf("%w: policy %q: %w", ErrInvalidDocument, p.ID, authz.ErrPolicyNotPersistable)
+		}
+	}
+	for _, d := range doc.Delegations {
+		if err := check(KindDelegation, d.ID); err != nil {
+			return err
+		}
+		if d.Principal == "" || d.Delegate == "" {
+			return fmt.Errorf("%w: delegation %q needs a principal and a delegate", ErrInvalidDocument, d.ID)
+		}
+		if err := checkScopes(doc, seen[KindScope], KindDelegation, d.ID, d.Scopes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkScopes(doc *Document, declared map[string]bool, kind Kind, key string, scopes []string) error {
+	if len(doc.Scopes) == 0 {
+		return nil
+	}
+	for _, s := range scopes {
+		if !declared[s] {
+			return fmt.Errorf("%w: %s %q references undeclared scope %q", ErrInvalidDocument, kind, key, s)
+		}
+	}
+	return nil
+}
+
+// MemoryBackend is an in-memory Backend, useful for tests and as a staging
+// area before changes are pushed to real stores
+type MemoryBackend struct {
+	mu        sync.RWMutex
+	resources map[Kind]map[string]interface{}
+}
+
+// NewMemoryBackend creates an empty in-memory backend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{resources: (&Document{}).Resources()}
+}
+
+// Snapshot implements Backend
+func (b *MemoryBackend) Snapshot(_ context.Context) (*Document, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	doc := &Document{}
+	for _, key := range sortedKeys(b.resources[KindScope]) {
+		doc.Scopes = append(doc.Scopes, b.resources[KindScope][key].(Scope))
+	}
+	for _, key := range sortedKeys(b.resources[KindRole]) {
+		doc.Roles = append(doc.Roles, b.resources[KindRole][key].(Role))
+	}
+	for _, key := range sortedKeys(b.resources[KindClient]) {
+		doc.Clients = append(doc.Clients, b.resources[KindClient][key].(Client))
+	}
+	for _, key := range sortedKeys(b.resources[KindPolicy]) {
+		p := b.resources[KindPolicy][key].(authz.Policy)
+		doc.Policies = append(doc.Policies, &p)
+	}
+	for _, key := range sortedKeys(b.resources[KindDelegation]) {
+		doc.Delegations = append(doc.Delegations, b.resources[KindDelegation][key].(Delegation))
+	}
+	return doc, nil
+}
+
+// Create implements Backend
+func (b *MemoryBackend) Create(_ context.Context, r Resource) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, exists := b.resources[r.Kind][r.Key]; exists {
+		return fmt.Errorf("%s %q already exists", r.Kind, r.Key)
+	}
+	b.resources[r.Kind][r.Key] = r.Value
+	return nil
+}
+
+// CreateAll implements BatchBackend; nothing is created if any resource
+// already exists
+func (b *MemoryBackend) CreateAll(_ context.Context, resources []Resource) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, r := range resources {
+		if _, exists := b.resources[r.Kind][r.Key]; exists {
+			return fmt.Errorf("%s %q already exists", r.Kind, r.Key)
+		}
+	}
+	for _, r := range resources {
+		b.resources[r.Kind][r.Key] = r.Value
+	}
+	return nil
+}
+
+// Update implements Backend
+func (b *MemoryBackend) Update(_ context.Context, r Resource) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, exists := b.resources[r.Kind][r.Key]; !exists {
+		return fmt.Errorf("%s %q not found", r.Kind, r.Key)
+	}
+	b.resources[r.Kind][r.Key] = r.Value
+	return nil
+}
+
+// Delete implements Backend
+func (b *MemoryBackend) Delete(_ context.Context, kind Kind, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.resources[kind], key)
+	return nil
+}
@@ -0,0 +1,166 @@
+package provision
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+)
+
+func testDocument() *Document {
+	return &Document{
+		Scopes: []Scope{{Name: "read"}, {Name: "write"}},
+		Roles:  []Role{{Name: "editor", Permissions: []string{"read", "write"}}},
+		Clients: []Client{
+			{ID: "web", Name: "Web App", GrantTypes: []string{"authorization_code"}, Scopes: []string{"read"}},
+		},
+		Policies: []*authz.Policy{{ID: "allow-editors", Effect: authz.Allow, Priority: 10}},
+		Delegations: []Delegation{
+			{ID: "d1", Principal: "alice", Delegate: "agent-1", Scopes: []string{"read"}},
+		},
+	}
+}
+
+func TestApplier(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryBackend()
+	applier := NewApplier(backend)
+
+	t.Run("Dry Run", func(t *testing.T) {
+		result, err := applier.Apply(ctx, testDocument(), ApplyOptions{DryRun: true})
+		if err != nil {
+			t.Fatalf("Apply() error: %v", err)
+		}
+		if create, _, _ := result.Plan.Counts(); create != 6 {
+			t.Errorf("Expected 6 creates, got %d", create)
+		}
+		if result.Plan.Changes[0].Kind != KindScope {
+			t.Errorf("Expected scopes to be created first, got %s", result.Plan.Changes[0].Kind)
+		}
+		snapshot, _ := backend.Snapshot(ctx)
+		if len(snapshot.Scopes) != 0 {
+			t.Error("Expected dry run to leave backend untouched")
+		}
+	})
+
+	t.Run("Apply And Converge", func(t *testing.T) {
+		if _, err := applier.Apply(ctx, testDocument(), ApplyOptions{}); err != nil {
+			t.Fatalf("Apply() error: %v", err)
+		}
+		plan, err := applier.Plan(ctx, testDocument(), true)
+		if err != nil {
+			t.Fatalf("Plan() error: %v", err)
+		}
+		if !plan.Empty() {
+			t.Errorf("Expected empty plan after apply, got %+v", plan.Changes)
+		}
+	})
+
+	t.Run("Update And Prune", func(t *testing.T) {
+		doc := testDocument()
+		doc.Clients[0].Scopes = []string{"read", "write"}
+		doc.Delegations = nil
+
+		plan, err := applier.Plan(ctx, doc, true)
+		if err != nil {
+			t.Fatalf("Plan() error: %v", err)
+		}
+		_, update, del := plan.Counts()
+		if update != 1 || del != 1 {
+			t.Fatalf("Expected 1 update and 1 delete, got %+v", plan.Changes)
+		}
+		if plan.Changes[0].Fields[0].Field != "scopes" {
+			t.Errorf("Expected scopes field change, got %+v", plan.Changes[0].Fields)
+		}
+
+		noPrune, _ := applier.Plan(ctx, doc, false)
+		if _, _, del := noPrune.Counts(); del != 0 {
+			t.Error("Expected no deletes without prune")
+		}
+	})
+
+	t.Run("Invalid Document", func(t *testing.T) {
+		doc := testDocument()
+		doc.Clients[0].Scopes = []string{"admin"}
+		if _, err := applier.Apply(ctx, doc, ApplyOptions{}); !errors.Is(err, ErrInvalidDocument) {
+			t.Errorf("Expected ErrInvalidDocument, got %v", err)
+		}
+	})
+
+	t.Run("HTTP Dry Run", func(t *testing.T) {
+		handler := applier.Handler(func(r *http.Request) string { return r.Header.Get("X-User") }, testRoles(t))
+		post := func(user string, body []byte) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/apply?dry_run=true", bytes.NewReader(body))
+			req.Header.Set("X-User", user)
+			handler.ServeHTTP(rec, req)
+			return rec
+		}
+		body, _ := json.Marshal(testDocument())
+		if rec := post("", body); rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for an anonymous caller, got %d", rec.Code)
+		}
+		if rec := post("eve", body); rec.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 without %s, got %d", PermissionApply, rec.Code)
+		}
+		rec := post("ops", body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		var result ApplyResult
+		if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+			t.Fatalf("Decode() error: %v", err)
+		}
+		if !result.DryRun || !result.Plan.Empty() {
+			t.Errorf("Expected empty dry-run plan, got %+v", result)
+		}
+
+		conditional := []byte(`{"policies":[{"id":"owner-only","effect":"allow","conditions":{"owner":{}}}]}`)
+		if rec := post("ops", conditional); rec.Code != http.StatusBadRequest || !bytes.Contains(rec.Body.Bytes(), []byte(authz.ErrPolicyNotPersistable.Error())) {
+			t.Errorf("Expected policy conditions refused, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("No Role Manager", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/apply?dry_run=true", bytes.NewReader([]byte(`{}`)))
+		applier.Handler(func(*http.Request) string { return "ops" }, nil).ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 without a role manager, got %d", rec.Code)
+		}
+	})
+
+	t.Run("Policy Conditions", func(t *testing.T) {
+		doc := testDocument()
+		doc.Policies[0].Conditions = map[string]authz.Condition{"owner": &authz.ResourceOwnerCondition{}}
+		if _, err := applier.Apply(ctx, doc, ApplyOptions{DryRun: true}); !errors.Is(err, authz.ErrPolicyNotPersistable) {
+			t.Errorf("Expected ErrPolicyNotPersistable, got %v", err)
+		}
+	})
+}
+
+// testRoles grants ops PermissionApply; eve has no permissions
+func testRoles(t *testing.T) authz.RoleManager {
+	t.Helper()
+	ctx := context.Background()
+	roles := authz.NewMemoryRoleManager()
+	for _, def := range []*authz.RoleDefinition{
+		{Name: "operator", Permissions: []authz.Permission{PermissionApply}},
+		{Name: "viewer"},
+	} {
+		if err := roles.DefineRole(ctx, def); err != nil {
+			t.Fatalf("DefineRole() error: %v", err)
+		}
+	}
+	for subject, role := range map[string]authz.Role{"ops": "operator", "eve": "viewer"} {
+		if err := roles.AssignRole(ctx, subject, role); err != nil {
+			t.Fatalf("AssignRole() error: %v", err)
+		}
+	}
+	return roles
+}
@@ -0,0 +1,104 @@
+// Package provision implements declarative management of authorization
+// configuration. A desired-state Document (clients, scopes, roles, policies
+// and delegations) is compared with the live state held by a Backend; the
+// resulting Plan can be reviewed (dry run) and applied, which enables GitOps
+// workflows for GAuth configuration.
+package provision
+
+import (
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+)
+
+// Kind identifies a type of provisioned resource
+type Kind string
+
+// Resource kinds, in the order they are created
+const (
+	KindScope      Kind = "scope"
+	KindRole       Kind = "role"
+	KindClient     Kind = "client"
+	KindPolicy     Kind = "policy"
+	KindDelegation Kind = "delegation"
+)
+
+// kindOrder lists kinds in dependency order; deletes run in reverse
+var kindOrder = []Kind{KindScope, KindRole, KindClient, KindPolicy, KindDelegation}
+
+// Document is a desired-state description of authorization configuration
+type Document struct {
+	Clients     []Client        `json:"clients,omitempty" yaml:"clients,omitempty"`
+	Scopes      []Scope         `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	Roles       []Role          `json:"roles,omitempty" yaml:"roles,omitempty"`
+	Policies    []*authz.Policy `json:"policies,omitempty" yaml:"policies,omitempty"`
+	Delegations []Delegation    `json:"delegations,omitempty" yaml:"delegations,omitempty"`
+}
+
+// Client is an OAuth client registration
+type Client struct {
+	ID           string   `json:"id" yaml:"id"`
+	Name         string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Type         string   `json:"type,omitempty" yaml:"type,omitempty"`
+	RedirectURIs []string `json:"redirect_uris,omitempty" yaml:"redirect_uris,omitempty"`
+	GrantTypes   []string `json:"grant_types,omitempty" yaml:"grant_types,omitempty"`
+	Scopes       []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+}
+
+// Scope is a named permission that tokens may carry
+type Scope struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// Role groups permissions that can be assigned to subjects
+type Role struct {
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Permissions []string `json:"permissions,omitempty" yaml:"permissions,omitempty"`
+}
+
+// Delegation is a standing grant of authority from a principal to a delegate
+type Delegation struct {
+	ID        string     `json:"id" yaml:"id"`
+	Principal string     `json:"principal" yaml:"principal"`
+	Delegate  string     `json:"delegate" yaml:"delegate"`
+	Scopes    []string   `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+}
+
+// Resource is a single provisioned object identified by kind and key
+type Resource struct {
+	Kind  Kind        `json:"kind"`
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// Resources flattens the document into resources grouped by kind and keyed by ID/name
+func (d *Document) Resources() map[Kind]map[string]interface{} {
+	out := make(map[Kind]map[string]interface{}, len(kindOrder))
+	for _, k := range kindOrder {
+		out[k] = make(map[string]interface{})
+	}
+	if d == nil {
+		return out
+	}
+	for _, c := range d.Clients {
+		out[KindClient][c.ID] = c
+	}
+	for _, s := range d.Scopes {
+		out[KindScope][s.Name] = s
+	}
+	for _, r := range d.Roles {
+		out[KindRole][r.Name] = r
+	}
+	for _, p := range d.Policies {
+		if p != nil {
+			out[KindPolicy][p.ID] = *p
+		}
+	}
+	for _, del := range d.Delegations {
+		out[KindDelegation][del.ID] = del
+	}
+	return out
+}
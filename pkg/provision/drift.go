@@ -0,0 +1,209 @@
+package provision
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// DefaultSensitiveKinds are never reconciled automatically; drift in them is
+// only reported so an operator can decide whether to re-apply
+var DefaultSensitiveKinds = []Kind{KindClient, KindPolicy, KindDelegation}
+
+// DriftReport describes the difference between live state and the last
+// applied document
+type DriftReport struct {
+	DetectedAt time.Time `json:"detected_at"`
+	AppliedAt  time.Time `json:"applied_at"`
+
+	// Changes are the steps that would restore the last applied state
+	Changes []Change `json:"changes"`
+
+	// Reconciled are the changes that were applied automatically
+	Reconciled []Change `json:"reconciled,omitempty"`
+}
+
+// Drifted reports whether any drift was found
+func (r *DriftReport) Drifted() bool {
+	return r != nil && len(r.Changes) > 0
+}
+
+// Pending returns the changes that were not reconciled
+func (r *DriftReport) Pending() []Change {
+	done := make(map[string]bool, len(r.Reconciled))
+	for _, c := range r.Reconciled {
+		done[string(c.Kind)+"/"+c.Key] = true
+	}
+	var pending []Change
+	for _, c := range r.Changes {
+		if !done[string(c.Kind)+"/"+c.Key] {
+			pending = append(pending, c)
+		}
+	}
+	return pending
+}
+
+// DriftMonitorConfig configures a DriftMonitor
+type DriftMonitorConfig struct {
+	// Applier supplies the last applied document and the live backend
+	Applier *Applier
+
+	// Interval is how often Run checks for drift (default: 5m)
+	Interval time.Duration
+
+	// Events receives a config_drift_detected warning per drifted resource
+	// and config_reconciled for each automatic fix
+	Events events.EventHandler
+
+	// AutoReconcile re-applies drifted resources whose kind is not sensitive
+	AutoReconcile bool
+
+	// SensitiveKinds overrides DefaultSensitiveKinds
+	SensitiveKinds []Kind
+
+	// OnDrift is called with every report that contains drift
+	OnDrift func(*DriftReport)
+}
+
+// DriftMonitor periodically compares live state with the last applied
+// document and reports (and optionally repairs) out-of-band changes
+type DriftMonitor struct {
+	config    DriftMonitorConfig
+	sensitive map[Kind]bool
+	mu        sync.RWMutex
+	last      *DriftReport
+}
+
+// NewDriftMonitor creates a drift monitor
+func NewDriftMonitor(config DriftMonitorConfig) (*DriftMonitor, error) {
+	if config.Applier == nil {
+		return nil, errors.New("applier is required")
+	}
+	if config.Interval <= 0 {
+		config.Interval = 5 * time.Minute
+	}
+	if config.SensitiveKinds == nil {
+		config.SensitiveKinds = DefaultSensitiveKinds
+	}
+	sensitive := make(map[Kind]bool, len(config.SensitiveKinds))
+	for _, k := range config.SensitiveKinds {
+		sensitive[k] = true
+	}
+	return &DriftMonitor{config: config, sensitive: sensitive}, nil
+}
+
+// Check compares live state to the last applied document. It returns nil if
+// nothing has been applied yet. Events and OnDrift run after the applier
+// lock is released, so they may call back into the applier.
+func (m *DriftMonitor) Check(ctx context.Context) (*DriftReport, error) {
+	report, reconcileErr, err := m.detect(ctx)
+	if report == nil || err != nil {
+		return nil, err
+	}
+
+	for _, c := range report.Changes {
+		m.emit(ctx, events.ActionConfigDriftDetected, events.StatusWarning, c,
+			fmt.Sprintf("%s %q drifted from applied configuration", c.Kind, c.Key))
+	}
+	for _, c := range report.Reconciled {
+		m.emit(ctx, events.ActionConfigReconciled, events.StatusSuccess, c,
+			fmt.Sprintf("%s %q reconciled to applied configuration", c.Kind, c.Key))
+	}
+
+	m.mu.Lock()
+	m.last = report
+	m.mu.Unlock()
+
+	if report.Drifted() && m.config.OnDrift != nil {
+		m.config.OnDrift(report)
+	}
+	if reconcileErr != nil {
+		return report, fmt.Errorf("failed to reconcile drift: %w", reconcileErr)
+	}
+	return report, nil
+}
+
+// detect diffs live state and reconciles what it may while holding the
+// applier lock
+func (m *DriftMonitor) detect(ctx context.Context) (report *DriftReport, reconcileErr, err error) {
+	a := m.config.Applier
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.lastApplied == nil {
+		return nil, nil, nil
+	}
+
+	current, err := a.backend.Snapshot(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read live state: %w", err)
+	}
+	plan, err := Diff(current, a.lastApplied, a.lastOptions.Prune)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report = &DriftReport{DetectedAt: time.Now(), AppliedAt: a.appliedAt, Changes: plan.Changes}
+	if m.config.AutoReconcile {
+		for _, c := range plan.Changes {
+			if m.sensitive[c.Kind] {
+				continue
+			}
+			var applied int
+			if err := a.execute(ctx, &Plan{Changes: []Change{c}}, &applied); err != nil {
+				reconcileErr = errors.Join(reconcileErr, err)
+				continue
+			}
+			report.Reconciled = append(report.Reconciled, c)
+		}
+	}
+	return report, reconcileErr, nil
+}
+
+func (m *DriftMonitor) emit(ctx context.Context, action events.EventAction, status events.EventStatus, c Change, message string) {
+	if m.config.Events == nil {
+		return
+	}
+	event := events.NewSystemEvent(action, status).WithContext(ctx).
+		WithMessage(message).
+		WithResource(string(c.Kind)+"/"+c.Key).
+		WithStringMetadata("kind", string(c.Kind)).
+		WithStringMetadata("key", c.Key).
+		WithStringMetadata("change", string(c.Action))
+	if len(c.Fields) > 0 {
+		if diff, err := json.Marshal(c.Fields); err == nil {
+			event = event.WithStringMetadata("diff", string(diff))
+		}
+	}
+	m.config.Events.Handle(event)
+}
+
+// Run checks for drift every Interval until ctx is cancelled. Errors are
+// reported to onError when it is non-nil.
+func (m *DriftMonitor) Run(ctx context.Context, onError func(error)) {
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := m.Check(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// LastReport returns the most recent drift report
+func (m *DriftMonitor) LastReport() *DriftReport {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.last
+}
@@ -0,0 +1,127 @@
+package provision
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+type recordingHandler struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+func (h *recordingHandler) Handle(e events.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, e)
+}
+
+func (h *recordingHandler) count(action events.EventAction) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for _, e := range h.events {
+		if e.Action == string(action) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestDriftMonitor(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryBackend()
+	applier := NewApplier(backend)
+	handler := &recordingHandler{}
+
+	monitor, err := NewDriftMonitor(DriftMonitorConfig{
+		Applier:       applier,
+		Events:        handler,
+		AutoReconcile: true,
+	})
+	if err != nil {
+		t.Fatalf("NewDriftMonitor() error: %v", err)
+	}
+
+	t.Run("Nothing Applied", func(t *testing.T) {
+		report, err := monitor.Check(ctx)
+		if err != nil || report != nil {
+			t.Errorf("Expected no report before first apply, got %+v, %v", report, err)
+		}
+	})
+
+	if _, err := applier.Apply(ctx, testDocument(), ApplyOptions{}); err != nil {
+		t.Fatalf("Apply() error: %v", err)
+	}
+
+	t.Run("No Drift", func(t *testing.T) {
+		report, err := monitor.Check(ctx)
+		if err != nil {
+			t.Fatalf("Check() error: %v", err)
+		}
+		if report.Drifted() {
+			t.Errorf("Expected no drift, got %+v", report.Changes)
+		}
+	})
+
+	t.Run("Drift Reported And Reconciled", func(t *testing.T) {
+		_ = backend.Update(ctx, Resource{Kind: KindRole, Key: "editor", Value: Role{Name: "editor", Permissions: []string{"read", "write", "admin"}}})
+		_ = backend.Update(ctx, Resource{Kind: KindClient, Key: "web", Value: Client{ID: "web", Scopes: []string{"read", "write"}}})
+
+		report, err := monitor.Check(ctx)
+		if err != nil {
+			t.Fatalf("Check() error: %v", err)
+		}
+		if len(report.Changes) != 2 {
+			t.Fatalf("Expected 2 drifted resources, got %+v", report.Changes)
+		}
+		if len(report.Reconciled) != 1 || report.Reconciled[0].Kind != KindRole {
+			t.Errorf("Expected only the role to be reconciled, got %+v", report.Reconciled)
+		}
+		if pending := report.Pending(); len(pending) != 1 || pending[0].Kind != KindClient {
+			t.Errorf("Expected client drift to remain pending, got %+v", pending)
+		}
+		if n := handler.count(events.ActionConfigDriftDetected); n != 2 {
+			t.Errorf("Expected 2 drift events, got %d", n)
+		}
+		if n := handler.count(events.ActionConfigReconciled); n != 1 {
+			t.Errorf("Expected 1 reconcile event, got %d", n)
+		}
+
+		report, _ = monitor.Check(ctx)
+		if len(report.Changes) != 1 {
+			t.Errorf("Expected only client drift after reconcile, got %+v", report.Changes)
+		}
+	})
+	t.Run("Callback May Use Applier", func(t *testing.T) {
+		var applied *Document
+		callbacks, err := NewDriftMonitor(DriftMonitorConfig{
+			Applier: applier,
+			OnDrift: func(*DriftReport) { applied, _ = applier.LastApplied() },
+		})
+		if err != nil {
+			t.Fatalf("NewDriftMonitor() error: %v", err)
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := callbacks.Check(ctx)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Check() error: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Check() deadlocked calling OnDrift")
+		}
+		if applied == nil {
+			t.Error("Expected OnDrift to read the last applied document")
+		}
+	})
+}
@@ -0,0 +1,446 @@
+package provision
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/audit"
+)
+
+// Import errors
+var (
+	// ErrInvalidImport indicates an import file that cannot be read at all
+	ErrInvalidImport = errors.New("invalid delegation import")
+
+	// ErrImportRejected indicates an import had invalid rows and created nothing
+	ErrImportRejected = errors.New("delegation import rejected")
+)
+
+// ImportFormat is the encoding of a delegation import file
+type ImportFormat string
+
+// Import formats
+const (
+	// FormatCSV is a CSV file with a header row naming the columns id,
+	// principal, delegate, scopes (space separated) and expires_at (RFC 3339)
+	FormatCSV ImportFormat = "csv"
+
+	// FormatJSON is a JSON array of Delegation objects
+	FormatJSON ImportFormat = "json"
+)
+
+// Audit trail constants
+const (
+	// TypeDelegationImport is the audit entry type for bulk imports
+	TypeDelegationImport = "delegation_import"
+
+	// ActionDelegationsImported records a committed bulk import
+	ActionDelegationsImported = "delegations_imported"
+)
+
+// ImportRecord is one parsed row of an import file
+type ImportRecord struct {
+	// Row is the 1-based record number; for CSV it excludes the header
+	Row        int        `json:"row"`
+	Delegation Delegation `json:"delegation"`
+}
+
+// RowError describes why a row was rejected
+type RowError struct {
+	Row     int    `json:"row"`
+	ID      string `json:"id,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e RowError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("row %d: %s: %s", e.Row, e.Field, e.Message)
+	}
+	return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+}
+
+// ImportReport is the validation report of an import
+type ImportReport struct {
+	Total   int        `json:"total"`
+	Valid   int        `json:"valid"`
+	Created int        `json:"created"`
+	Errors  []RowError `json:"errors,omitempty"`
+	DryRun  bool       `json:"dry_run"`
+
+	// AuditID identifies the audit entry recording a committed import
+	AuditID string `json:"audit_id,omitempty"`
+}
+
+// OK reports whether every row passed validation
+func (r *ImportReport) OK() bool {
+	return len(r.Errors) == 0
+}
+
+// BatchBackend is implemented by backends that create many resources
+// atomically. Importers fall back to single creates, deleting what was
+// created when one fails, for other backends.
+type BatchBackend interface {
+	CreateAll(ctx context.Context, resources []Resource) error
+}
+
+// ImportOptions controls a single import
+type ImportOptions struct {
+	// DryRun validates the file and reports without creating anything
+	DryRun bool `json:"dry_run"`
+
+	// Actor is recorded as the actor of the audit entry
+	Actor string `json:"actor,omitempty"`
+}
+
+// ImporterConfig configures an Importer
+type ImporterConfig struct {
+	// Backend receives the delegations
+	Backend Backend
+
+	// Audit receives one entry per committed import
+	Audit audit.Storage
+
+	// MaxValidity caps how far in the future a delegation may expire
+	// (0 = unlimited)
+	MaxValidity time.Duration
+
+	// MaxRows caps the number of records in one file (default: 10000)
+	MaxRows int
+
+	// Now returns the current time (default: time.Now)
+	Now func() time.Time
+}
+
+// Importer bulk-imports existing powers of attorney as delegations. Every
+// row is validated against the RFC115 delegation rules; an import either
+// creates all of its delegations or none.
+type Importer struct {
+	config ImporterConfig
+}
+
+// NewImporter creates a delegation importer
+func NewImporter(config ImporterConfig) (*Importer, error) {
+	if config.Backend == nil {
+		return nil, errors.New("backend is required")
+	}
+	if config.MaxRows <= 0 {
+		config.MaxRows = 10000
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Importer{config: config}, nil
+}
+
+// ParseDelegations decodes an import file. Rows that cannot be decoded are
+// returned as row errors; a malformed file as a whole is an error.
+func ParseDelegations(r io.Reader, format ImportFormat) ([]ImportRecord, []RowError, error) {
+	switch format {
+	case FormatJSON:
+		var raw []json.RawMessage
+		if err := json.NewDecoder(r).Decode(&raw); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		records := make([]ImportRecord, 0, len(raw))
+		var rowErrors []RowError
+		for i, msg := range raw {
+			var d Delegation
+			if err := json.Unmarshal(msg, &d); err != nil {
+				rowErrors = append(rowErrors, RowError{Row: i + 1, Message: err.Error()})
+				continue
+			}
+			records = append(records, ImportRecord{Row: i + 1, Delegation: d})
+		}
+		return records, rowErrors, nil
+	case FormatCSV:
+		return parseCSV(r)
+	}
+	return nil, nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidImport, format)
+}
+
+var csvColumns = []string{"id", "principal", "delegate", "scopes", "expires_at"}
+
+func parseCSV(r io.Reader) ([]ImportRecord, []RowError, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: failed to read CSV header: %v", ErrInvalidImport, err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, col := range csvColumns {
+		if _, ok := index[col]; !ok {
+			return nil, nil, fmt.Errorf("%w: CSV header lacks column %q", ErrInvalidImport, col)
+		}
+	}
+
+	var records []ImportRecord
+	var rowErrors []RowError
+	for row := 1; ; row++ {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: failed to read CSV row %d: %v", ErrInvalidImport, row, err)
+		}
+		get := func(col string) string {
+			if i := index[col]; i < len(fields) {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+		d := Delegation{
+			ID:        get("id"),
+			Principal: get("principal"),
+			Delegate:  get("delegate"),
+			Scopes:    strings.Fields(get("scopes")),
+		}
+		if v := get("expires_at"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				rowErrors = append(rowErrors, RowError{Row: row, ID: d.ID, Field: "expires_at", Message: "not an RFC 3339 time"})
+				continue
+			}
+			d.ExpiresAt = &t
+		}
+		records = append(records, ImportRecord{Row: row, Delegation: d})
+	}
+	return records, rowErrors, nil
+}
+
+// Import parses, validates and, unless DryRun is set, creates the
+// delegations in r. When any row is invalid nothing is created and the
+// report is returned with ErrImportRejected.
+func (im *Importer) Import(ctx context.Context, r io.Reader, format ImportFormat, opts ImportOptions) (*ImportReport, error) {
+	records, rowErrors, err := ParseDelegations(r, format)
+	if err != nil {
+		return nil, err
+	}
+	report := &ImportReport{Total: len(records) + len(rowErrors), Errors: rowErrors, DryRun: opts.DryRun}
+	if report.Total > im.config.MaxRows {
+		return nil, fmt.Errorf("%w: %d rows, more than the limit of %d", ErrInvalidImport, report.Total, im.config.MaxRows)
+	}
+
+	live, err := im.config.Backend.Snapshot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read live state: %w", err)
+	}
+	report.Errors = append(report.Errors, im.validate(live, records)...)
+	sortRowErrors(report.Errors)
+	report.Valid = report.Total - countRows(report.Errors)
+
+	if !report.OK() {
+		return report, fmt.Errorf("%w: %d of %d rows invalid", ErrImportRejected, report.Total-report.Valid, report.Total)
+	}
+	if opts.DryRun || len(records) == 0 {
+		return report, nil
+	}
+
+	resources := make([]Resource, len(records))
+	for i, rec := range records {
+		resources[i] = Resource{Kind: KindDelegation, Key: rec.Delegation.ID, Value: rec.Delegation}
+	}
+	if err := im.createAll(ctx, resources); err != nil {
+		return report, fmt.Errorf("failed to create delegations: %w", err)
+	}
+	report.Created = len(resources)
+
+	if im.config.Audit != nil {
+		ids := make([]string, len(records))
+		for i, rec := range records {
+			ids[i] = rec.Delegation.ID
+		}
+		e := audit.NewEntry(TypeDelegationImport).
+			WithActor(opts.Actor, audit.ActorUser).
+			WithAction(ActionDelegationsImported).
+			WithTarget(strconv.Itoa(len(ids))+" delegations", string(KindDelegation)).
+			WithResult(audit.ResultSuccess).
+			WithContext(ctx).
+			WithMetadata("count", strconv.Itoa(len(ids))).
+			WithMetadata("format", string(format)).
+			WithMetadata("ids", strings.Join(ids, ","))
+		if err := im.config.Audit.Store(ctx, e); err != nil {
+			return report, fmt.Errorf("failed to record import: %w", err)
+		}
+		report.AuditID = e.ID
+	}
+	return report, nil
+}
+
+// validate applies the RFC115 delegation rules to every record: a known,
+// distinct principal and delegate, at least one declared scope, a bounded
+// future expiry, unique IDs, and no delegation cycles with the live state
+func (im *Importer) validate(live *Document, records []ImportRecord) []RowError {
+	var errs []RowError
+	now := im.config.Now()
+	declared := make(map[string]bool, len(live.Scopes))
+	for _, s := range live.Scopes {
+		declared[s.Name] = true
+	}
+	existing := make(map[string]bool, len(live.Delegations))
+	graph := make(map[string][]string)
+	for _, d := range live.Delegations {
+		existing[d.ID] = true
+		graph[d.Principal] = append(graph[d.Principal], d.Delegate)
+	}
+
+	seen := make(map[string]int, len(records))
+	for _, rec := range records {
+		d := rec.Delegation
+		fail := func(field, msg string) {
+			errs = append(errs, RowError{Row: rec.Row, ID: d.ID, Field: field, Message: msg})
+		}
+		n := len(errs)
+
+		switch {
+		case d.ID == "":
+			fail("id", "required")
+		case existing[d.ID]:
+			fail("id", "delegation already exists")
+		case seen[d.ID] != 0:
+			fail("id", fmt.Sprintf("duplicate of row %d", seen[d.ID]))
+		default:
+			seen[d.ID] = rec.Row
+		}
+		if d.Principal == "" {
+			fail("principal", "required")
+		}
+		if d.Delegate == "" {
+			fail("delegate", "required")
+		}
+		if d.Principal != "" && d.Principal == d.Delegate {
+			fail("delegate", "a principal cannot delegate to itself")
+		}
+		if len(d.Scopes) == 0 {
+			fail("scopes", "at least one scope is required")
+		}
+		for _, s := range d.Scopes {
+			if len(declared) > 0 && !declared[s] {
+				fail("scopes", fmt.Sprintf("undeclared scope %q", s))
+			}
+		}
+		switch {
+		case d.ExpiresAt == nil:
+			fail("expires_at", "required; delegations must be time-bounded")
+		case !d.ExpiresAt.After(now):
+			fail("expires_at", "already expired")
+		case im.config.MaxValidity > 0 && d.ExpiresAt.Sub(now) > im.config.MaxValidity:
+			fail("expires_at", fmt.Sprintf("exceeds the maximum validity of %s", im.config.MaxValidity))
+		}
+
+		if len(errs) == n && d.Principal != "" && d.Delegate != "" {
+			if reaches(graph, d.Delegate, d.Principal) {
+				fail("delegate", fmt.Sprintf("%s already holds authority delegated from %s", d.Principal, d.Delegate))
+			} else {
+				graph[d.Principal] = append(graph[d.Principal], d.Delegate)
+			}
+		}
+	}
+	return errs
+}
+
+// reaches reports whether to can be reached from from along delegations
+func reaches(graph map[string][]string, from, to string) bool {
+	visited := map[string]bool{from: true}
+	queue := []string{from}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if node == to {
+			return true
+		}
+		for _, next := range graph[node] {
+			if !visited[next] {
+				visited[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return false
+}
+
+func (im *Importer) createAll(ctx context.Context, resources []Resource) error {
+	if bb, ok := im.config.Backend.(BatchBackend); ok {
+		return bb.CreateAll(ctx, resources)
+	}
+	for i, r := range resources {
+		if err := im.config.Backend.Create(ctx, r); err != nil {
+			for _, created := range resources[:i] {
+				_ = im.config.Backend.Delete(ctx, created.Kind, created.Key)
+			}
+			return fmt.Errorf("%s %q: %w", r.Kind, r.Key, err)
+		}
+	}
+	return nil
+}
+
+func sortRowErrors(errs []RowError) {
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Row < errs[j].Row })
+}
+
+func countRows(errs []RowError) int {
+	rows := make(map[int]bool, len(errs))
+	for _, e := range errs {
+		rows[e.Row] = true
+	}
+	return len(rows)
+}
+
+// Handler serves the import API. POST a CSV (text/csv) or JSON body; the
+// query parameter dry_run=true validates only. The response is the
+// ImportReport as JSON, with status 422 when rows were rejected.
+func (im *Importer) Handler(actor func(r *http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		format := FormatJSON
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "text/csv" {
+			format = FormatCSV
+		}
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+		opts := ImportOptions{DryRun: dryRun}
+		if actor != nil {
+			opts.Actor = actor(r)
+		}
+
+		report, err := im.Import(r.Context(), http.MaxBytesReader(w, r.Body, 16<<20), format, opts)
+		status := http.StatusOK
+		switch {
+		case errors.Is(err, ErrInvalidImport):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case report == nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		case errors.Is(err, ErrImportRejected):
+			status = http.StatusUnprocessableEntity
+		case err != nil:
+			status = http.StatusInternalServerError
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(struct {
+			*ImportReport
+			Error string `json:"error,omitempty"`
+		}{report, errString(err)})
+	})
+}
@@ -0,0 +1,164 @@
+package provision
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/audit"
+)
+
+type recordingAudit struct {
+	audit.Storage
+	entries []*audit.Entry
+}
+
+func (a *recordingAudit) Store(_ context.Context, e *audit.Entry) error {
+	a.entries = append(a.entries, e)
+	return nil
+}
+
+// failingBackend fails the nth Create. Embedding the interface hides
+// CreateAll, exercising the rollback path.
+type failingBackend struct {
+	Backend
+	creates, failAt int
+}
+
+func (b *failingBackend) Create(ctx context.Context, r Resource) error {
+	b.creates++
+	if b.creates == b.failAt {
+		return errors.New("backend unavailable")
+	}
+	return b.Backend.Create(ctx, r)
+}
+
+func TestImporter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	newImporter := func(backend Backend, trail audit.Storage) *Importer {
+		im, err := NewImporter(ImporterConfig{Backend: backend, Audit: trail, MaxValidity: 365 * 24 * time.Hour,
+			Now: func() time.Time { return now }})
+		if err != nil {
+			t.Fatalf("NewImporter() error: %v", err)
+		}
+		return im
+	}
+	seeded := func() *MemoryBackend {
+		b := NewMemoryBackend()
+		_ = b.Create(ctx, Resource{Kind: KindScope, Key: "read", Value: Scope{Name: "read"}})
+		_ = b.Create(ctx, Resource{Kind: KindScope, Key: "sign", Value: Scope{Name: "sign"}})
+		_ = b.Create(ctx, Resource{Kind: KindDelegation, Key: "d0", Value: Delegation{ID: "d0", Principal: "bob", Delegate: "alice", Scopes: []string{"read"}}})
+		return b
+	}
+	const valid = `id,principal,delegate,scopes,expires_at
+d1,alice,agent-1,read sign,2026-06-01T00:00:00Z
+d2,alice,agent-2,read,2026-06-01T00:00:00Z
+`
+
+	t.Run("CSV Commit With Single Audit Record", func(t *testing.T) {
+		backend, trail := seeded(), &recordingAudit{}
+		report, err := newImporter(backend, trail).Import(ctx, strings.NewReader(valid), FormatCSV, ImportOptions{Actor: "admin"})
+		if err != nil {
+			t.Fatalf("Import() error: %v", err)
+		}
+		if report.Total != 2 || report.Created != 2 || !report.OK() {
+			t.Errorf("Unexpected report %+v", report)
+		}
+		snapshot, _ := backend.Snapshot(ctx)
+		if len(snapshot.Delegations) != 3 || snapshot.Delegations[1].Scopes[1] != "sign" {
+			t.Errorf("Expected imported delegations, got %+v", snapshot.Delegations)
+		}
+		if len(trail.entries) != 1 || trail.entries[0].Metadata["ids"] != "d1,d2" || report.AuditID != trail.entries[0].ID {
+			t.Errorf("Expected one audit record for the batch, got %+v", trail.entries)
+		}
+	})
+
+	t.Run("Per Row Errors Reject The Whole Import", func(t *testing.T) {
+		backend := seeded()
+		input := valid + `d1,carol,agent-3,read,2026-06-01T00:00:00Z
+d3,alice,alice,read,2026-06-01T00:00:00Z
+d4,carol,agent-4,write,2026-06-01T00:00:00Z
+d5,carol,agent-5,read,
+d6,carol,agent-6,read,2030-01-01T00:00:00Z
+d7,alice,bob,read,2026-06-01T00:00:00Z
+d8,carol,agent-8,read,yesterday
+`
+		report, err := newImporter(backend, nil).Import(ctx, strings.NewReader(input), FormatCSV, ImportOptions{})
+		if !errors.Is(err, ErrImportRejected) {
+			t.Fatalf("Expected ErrImportRejected, got %v", err)
+		}
+		want := map[int]string{3: "id", 4: "delegate", 5: "scopes", 6: "expires_at", 7: "expires_at", 8: "delegate", 9: "expires_at"}
+		if len(report.Errors) != len(want) || report.Valid != 2 {
+			t.Fatalf("Expected %d row errors and 2 valid rows, got %+v", len(want), report)
+		}
+		for _, e := range report.Errors {
+			if want[e.Row] != e.Field {
+				t.Errorf("Row %d: expected %s error, got %v", e.Row, want[e.Row], e)
+			}
+		}
+		if snapshot, _ := backend.Snapshot(ctx); len(snapshot.Delegations) != 1 {
+			t.Error("Expected nothing created")
+		}
+	})
+
+	t.Run("JSON Dry Run", func(t *testing.T) {
+		backend, trail := seeded(), &recordingAudit{}
+		input := `[{"id":"d1","principal":"alice","delegate":"agent-1","scopes":["read"],"expires_at":"2026-06-01T00:00:00Z"}]`
+		report, err := newImporter(backend, trail).Import(ctx, strings.NewReader(input), FormatJSON, ImportOptions{DryRun: true})
+		if err != nil || report.Valid != 1 || report.Created != 0 {
+			t.Fatalf("Expected one valid row and nothing created, got %+v, %v", report, err)
+		}
+		if snapshot, _ := backend.Snapshot(ctx); len(snapshot.Delegations) != 1 || len(trail.entries) != 0 {
+			t.Error("Expected dry run to leave backend and audit trail untouched")
+		}
+	})
+
+	t.Run("Failed Create Rolls Back", func(t *testing.T) {
+		backend := &failingBackend{Backend: seeded(), failAt: 2}
+		report, err := newImporter(backend, nil).Import(ctx, strings.NewReader(valid), FormatCSV, ImportOptions{})
+		if err == nil || report.Created != 0 {
+			t.Fatalf("Expected creation failure, got %+v, %v", report, err)
+		}
+		if snapshot, _ := backend.Snapshot(ctx); len(snapshot.Delegations) != 1 {
+			t.Errorf("Expected created delegations rolled back, have %d", len(snapshot.Delegations))
+		}
+	})
+
+	t.Run("Handler", func(t *testing.T) {
+		handler := newImporter(seeded(), nil).Handler(nil)
+		for _, tc := range []struct {
+			body, contentType string
+			status            int
+		}{
+			{valid, "text/csv", http.StatusOK},
+			{"id,principal\n", "text/csv", http.StatusBadRequest},
+			{`[{"id":"x"}]`, "application/json", http.StatusUnprocessableEntity},
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/import?dry_run=true", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tc.status {
+				t.Errorf("%s: expected %d, got %d: %s", tc.contentType, tc.status, rec.Code, rec.Body)
+			}
+		}
+	})
+
+	t.Run("Row Limit", func(t *testing.T) {
+		im, _ := NewImporter(ImporterConfig{Backend: seeded(), MaxRows: 1})
+		var b strings.Builder
+		b.WriteString("id,principal,delegate,scopes,expires_at\n")
+		for i := 0; i < 2; i++ {
+			fmt.Fprintf(&b, "d%d,alice,agent,read,2026-06-01T00:00:00Z\n", i+10)
+		}
+		if _, err := im.Import(ctx, strings.NewReader(b.String()), FormatCSV, ImportOptions{}); !errors.Is(err, ErrInvalidImport) {
+			t.Errorf("Expected ErrInvalidImport, got %v", err)
+		}
+	})
+}
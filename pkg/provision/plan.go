@@ -0,0 +1,178 @@
+package provision
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+)
+
+// Action is the operation a change performs
+type Action string
+
+// Change actions
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// FieldChange describes a single changed field of an updated resource
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old,omitempty"`
+	New   interface{} `json:"new,omitempty"`
+}
+
+// Change is one step of a plan
+type Change struct {
+	Action  Action        `json:"action"`
+	Kind    Kind          `json:"kind"`
+	Key     string        `json:"key"`
+	Fields  []FieldChange `json:"fields,omitempty"`
+	Desired interface{}   `json:"desired,omitempty"`
+}
+
+// Plan is the ordered set of changes that moves live state to desired state
+type Plan struct {
+	Changes []Change `json:"changes"`
+}
+
+// Empty reports whether the plan has no changes
+func (p *Plan) Empty() bool {
+	return p == nil || len(p.Changes) == 0
+}
+
+// Counts returns the number of creates, updates and deletes in the plan
+func (p *Plan) Counts() (create, update, del int) {
+	for _, c := range p.Changes {
+		switch c.Action {
+		case ActionCreate:
+			create++
+		case ActionUpdate:
+			update++
+		case ActionDelete:
+			del++
+		}
+	}
+	return create, update, del
+}
+
+// Diff computes the plan that turns current into desired. Resources present
+// only in current are deleted when prune is set and left untouched otherwise.
+// Creates and updates are ordered by kind dependency (scopes before clients,
+// policies before delegations); deletes run afterwards in reverse order.
+func Diff(current, desired *Document, prune bool) (*Plan, error) {
+	cur := current.Resources()
+	want := desired.Resources()
+	plan := &Plan{}
+
+	for _, kind := range kindOrder {
+		for _, key := range sortedKeys(want[kind]) {
+			desiredValue := want[kind][key]
+			currentValue, exists := cur[kind][key]
+			if !exists {
+				plan.Changes = append(plan.Changes, Change{Action: ActionCreate, Kind: kind, Key: key, Desired: desiredValue})
+				continue
+			}
+			fields, err := fieldChanges(kind, currentValue, desiredValue)
+			if err != nil {
+				return nil, fmt.Errorf("failed to diff %s %q: %w", kind, key, err)
+			}
+			if len(fields) > 0 {
+				plan.Changes = append(plan.Changes, Change{Action: ActionUpdate, Kind: kind, Key: key, Fields: fields, Desired: desiredValue})
+			}
+		}
+	}
+
+	if prune {
+		for i := len(kindOrder) - 1; i >= 0; i-- {
+			kind := kindOrder[i]
+			for _, key := range sortedKeys(cur[kind]) {
+				if _, wanted := want[kind][key]; !wanted {
+					plan.Changes = append(plan.Changes, Change{Action: ActionDelete, Kind: kind, Key: key})
+				}
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// fieldChanges compares two resources field by field using their JSON form
+func fieldChanges(kind Kind, current, desired interface{}) ([]FieldChange, error) {
+	a, err := normalize(kind, current)
+	if err != nil {
+		return nil, err
+	}
+	b, err := normalize(kind, desired)
+	if err != nil {
+		return nil, err
+	}
+
+	fieldSet := make(map[string]struct{})
+	for k := range a {
+		fieldSet[k] = struct{}{}
+	}
+	for k := range b {
+		fieldSet[k] = struct{}{}
+	}
+
+	var changes []FieldChange
+	for _, field := range sortedKeys(fieldSet) {
+		if !reflect.DeepEqual(a[field], b[field]) {
+			changes = append(changes, FieldChange{Field: field, Old: a[field], New: b[field]})
+		}
+	}
+	return changes, nil
+}
+
+// normalize converts a resource to a generic map, dropping server-managed fields
+func normalize(kind Kind, v interface{}) (map[string]interface{}, error) {
+	if kind == KindPolicy {
+		if p, ok := v.(authz.Policy); ok {
+			p.CreatedAt, p.UpdatedAt = time.Time{}, time.Time{}
+			v = p
+		}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	for k, val := range m {
+		if isEmptyValue(val) {
+			delete(m, k)
+		}
+	}
+	return m, nil
+}
+
+func isEmptyValue(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case string:
+		return t == ""
+	case []interface{}:
+		return len(t) == 0
+	case map[string]interface{}:
+		return len(t) == 0
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,197 @@
+package provision
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+)
+
+// RedisBackend keeps the live configuration in Redis, so it survives
+// restarts and is shared between instances. Each kind is a hash of JSON
+// values keyed by ID or name.
+type RedisBackend struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisBackend creates a Redis-backed backend (prefix default: "gauth:provision:")
+func NewRedisBackend(client redis.UniversalClient, prefix string) *RedisBackend {
+	if prefix == "" {
+		prefix = "gauth:provision:"
+	}
+	return &RedisBackend{client: client, prefix: prefix}
+}
+
+// Snapshot implements Backend
+func (b *RedisBackend) Snapshot(ctx context.Context) (*Document, error) {
+	doc := &Document{}
+	for _, kind := range kindOrder {
+		values, err := b.client.HGetAll(ctx, b.key(kind)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s resources: %w", kind, err)
+		}
+		for _, key := range sortedKeys(values) {
+			if err := decodeInto(doc, kind, []byte(values[key])); err != nil {
+				return nil, fmt.Errorf("failed to decode %s %q: %w", kind, key, err)
+			}
+		}
+	}
+	return doc, nil
+}
+
+// Create implements Backend
+func (b *RedisBackend) Create(ctx context.Context, r Resource) error {
+	data, err := encodeResource(r)
+	if err != nil {
+		return err
+	}
+	created, err := b.client.HSetNX(ctx, b.key(r.Kind), r.Key, data).Result()
+	if err != nil {
+		return fmt.Errorf("failed to create %s %q: %w", r.Kind, r.Key, err)
+	}
+	if !created {
+		return fmt.Errorf("%s %q already exists", r.Kind, r.Key)
+	}
+	return nil
+}
+
+// CreateAll implements BatchBackend; nothing is created if any resource
+// already exists. The kinds involved are watched, so a concurrent write
+// aborts the batch.
+func (b *RedisBackend) CreateAll(ctx context.Context, resources []Resource) error {
+	encoded := make([][]byte, len(resources))
+	var keys []string
+	watched := make(map[string]bool)
+	for i, r := range resources {
+		data, err := encodeResource(r)
+		if err != nil {
+			return err
+		}
+		encoded[i] = data
+		if key := b.key(r.Kind); !watched[key] {
+			watched[key] = true
+			keys = append(keys, key)
+		}
+	}
+	if len(resources) == 0 {
+		return nil
+	}
+	err := b.client.Watch(ctx, func(tx *redis.Tx) error {
+		for _, r := range resources {
+			exists, err := tx.HExists(ctx, b.key(r.Kind), r.Key).Result()
+			if err != nil {
+				return err
+			}
+			if exists {
+				return fmt.Errorf("%s %q already exists", r.Kind, r.Key)
+			}
+		}
+		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, r := range resources {
+				pipe.HSet(ctx, b.key(r.Kind), r.Key, encoded[i])
+			}
+			return nil
+		})
+		return err
+	}, keys...)
+	if errors.Is(err, redis.TxFailedErr) {
+		return fmt.Errorf("failed to create resources: concurrent change")
+	}
+	return err
+}
+
+// Update implements Backend
+func (b *RedisBackend) Update(ctx context.Context, r Resource) error {
+	data, err := encodeResource(r)
+	if err != nil {
+		return err
+	}
+	key := b.key(r.Kind)
+	err = b.client.Watch(ctx, func(tx *redis.Tx) error {
+		exists, err := tx.HExists(ctx, key, r.Key).Result()
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%s %q not found", r.Kind, r.Key)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, r.Key, data)
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, redis.TxFailedErr) {
+		return fmt.Errorf("failed to update %s %q: concurrent change", r.Kind, r.Key)
+	}
+	return err
+}
+
+// Delete implements Backend
+func (b *RedisBackend) Delete(ctx context.Context, kind Kind, key string) error {
+	if err := b.client.HDel(ctx, b.key(kind), key).Err(); err != nil {
+		return fmt.Errorf("failed to delete %s %q: %w", kind, key, err)
+	}
+	return nil
+}
+
+func (b *RedisBackend) key(kind Kind) string {
+	return b.prefix + string(kind)
+}
+
+// encodeResource marshals a resource value. Policy conditions are code and
+// cannot be stored.
+func encodeResource(r Resource) ([]byte, error) {
+	if p, ok := r.Value.(authz.Policy); ok && len(p.Conditions) > 0 {
+		return nil, fmt.Errorf("%w: policy %s", authz.ErrPolicyNotPersistable, p.ID)
+	}
+	data, err := json.Marshal(r.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s %q: %w", r.Kind, r.Key, err)
+	}
+	return data, nil
+}
+
+// decodeInto appends a stored value of the kind to the document
+func decodeInto(doc *Document, kind Kind, data []byte) error {
+	switch kind {
+	case KindScope:
+		var v Scope
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		doc.Scopes = append(doc.Scopes, v)
+	case KindRole:
+		var v Role
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		doc.Roles = append(doc.Roles, v)
+	case KindClient:
+		var v Client
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		doc.Clients = append(doc.Clients, v)
+	case KindPolicy:
+		var v authz.Policy
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		doc.Policies = append(doc.Policies, &v)
+	case KindDelegation:
+		var v Delegation
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		doc.Delegations = append(doc.Delegations, v)
+	default:
+		return fmt.Errorf("unknown kind %q", kind)
+	}
+	return nil
+}
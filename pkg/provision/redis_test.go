@@ -0,0 +1,63 @@
+package provision
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+)
+
+func TestRedisBackend(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis.Run() error: %v", err)
+	}
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	if _, err := NewApplier(NewRedisBackend(client, "")).Apply(ctx, testDocument(), ApplyOptions{}); err != nil {
+		t.Fatalf("Apply() error: %v", err)
+	}
+
+	t.Run("Survives Restart", func(t *testing.T) {
+		applier := NewApplier(NewRedisBackend(client, ""))
+		plan, err := applier.Plan(ctx, testDocument(), true)
+		if err != nil {
+			t.Fatalf("Plan() error: %v", err)
+		}
+		if !plan.Empty() {
+			t.Errorf("Expected the applied state to be read back, got %+v", plan.Changes)
+		}
+	})
+
+	t.Run("Create Existing", func(t *testing.T) {
+		backend := NewRedisBackend(client, "")
+		if err := backend.Create(ctx, Resource{Kind: KindScope, Key: "read", Value: Scope{Name: "read"}}); err == nil {
+			t.Error("Expected creating an existing scope to fail")
+		}
+		err := backend.CreateAll(ctx, []Resource{
+			{Kind: KindScope, Key: "admin", Value: Scope{Name: "admin"}},
+			{Kind: KindRole, Key: "editor", Value: Role{Name: "editor"}},
+		})
+		if err == nil {
+			t.Error("Expected a batch with an existing role to fail")
+		}
+		if exists, _ := client.HExists(ctx, backend.key(KindScope), "admin").Result(); exists {
+			t.Error("Expected nothing created from a failed batch")
+		}
+	})
+
+	t.Run("Policy Conditions", func(t *testing.T) {
+		p := authz.Policy{ID: "owner-only", Effect: authz.Allow, Conditions: map[string]authz.Condition{"owner": &authz.ResourceOwnerCondition{}}}
+		err := NewRedisBackend(client, "").Create(ctx, Resource{Kind: KindPolicy, Key: p.ID, Value: p})
+		if !errors.Is(err, authz.ErrPolicyNotPersistable) {
+			t.Errorf("Expected ErrPolicyNotPersistable, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package rate
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/internal/ratelimit"
+	"github.com/Gimel-Foundation/gauth/pkg/metrics"
+)
+
+// AdaptiveConfig configures an Adaptive limiter
+type AdaptiveConfig struct {
+	// Name labels the limiter in metrics (default: "adaptive")
+	Name string
+
+	// InitialLimit is the starting number of requests per window for each key
+	InitialLimit int
+
+	// MinLimit is the lowest the limit is scaled down to
+	MinLimit int
+
+	// MaxLimit is the highest the limit is scaled up to
+	MaxLimit int
+
+	// Window is the period each limit applies to (default: 1m)
+	Window time.Duration
+
+	// ScaleUpFactor multiplies the limit when usage is low (default: 1.1)
+	ScaleUpFactor float64
+
+	// ScaleDownFactor multiplies the limit when usage is high (default: 0.9)
+	ScaleDownFactor float64
+
+	// Metrics records limit adjustments when set
+	Metrics *metrics.Collector
+
+	// OnAdjust is called whenever a key's limit changes. It runs while the
+	// key's state is locked and must not call back into the limiter.
+	OnAdjust func(key string, oldLimit, newLimit int)
+}
+
+// Quota describes a key's allowance in the current window
+type Quota struct {
+	Limit     int64     `json:"limit"`
+	Remaining int64     `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// QuotaProvider is implemented by limiters that can report a key's full
+// quota, not just the remaining requests
+type QuotaProvider interface {
+	Quota(id string) Quota
+}
+
+// Adaptive is a Limiter whose per-key limit follows observed usage: keys
+// that keep using most of their allowance are scaled down towards MinLimit,
+// keys using little are scaled up towards MaxLimit.
+type Adaptive struct {
+	config AdaptiveConfig
+	mu     sync.Mutex
+	keys   map[string]*ratelimit.AdaptiveRateLimiter
+}
+
+// NewAdaptive creates an adaptive limiter
+func NewAdaptive(config AdaptiveConfig) (*Adaptive, error) {
+	if config.InitialLimit <= 0 {
+		return nil, fmt.Errorf("%w: initial limit must be positive", ErrInvalidLimit)
+	}
+	if config.MinLimit <= 0 {
+		config.MinLimit = 1
+	}
+	if config.MaxLimit == 0 {
+		config.MaxLimit = config.InitialLimit
+	}
+	if config.MinLimit > config.InitialLimit || config.InitialLimit > config.MaxLimit {
+		return nil, fmt.Errorf("%w: need MinLimit <= InitialLimit <= MaxLimit", ErrInvalidLimit)
+	}
+	if config.Window <= 0 {
+		config.Window = time.Minute
+	}
+	if config.ScaleUpFactor == 0 {
+		config.ScaleUpFactor = 1.1
+	}
+	if config.ScaleDownFactor == 0 {
+		config.ScaleDownFactor = 0.9
+	}
+	if config.ScaleUpFactor < 1 || config.ScaleDownFactor <= 0 || config.ScaleDownFactor > 1 {
+		return nil, fmt.Errorf("%w: scale factors must satisfy 0 < down <= 1 <= up", ErrInvalidConfig)
+	}
+	if config.Name == "" {
+		config.Name = "adaptive"
+	}
+	return &Adaptive{config: config, keys: make(map[string]*ratelimit.AdaptiveRateLimiter)}, nil
+}
+
+func (a *Adaptive) limiter(id string) *ratelimit.AdaptiveRateLimiter {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	l, ok := a.keys[id]
+	if !ok {
+		l = ratelimit.NewAdaptiveRateLimiter(ratelimit.AdaptiveConfig{
+			InitialLimit:    a.config.InitialLimit,
+			MinLimit:        a.config.MinLimit,
+			MaxLimit:        a.config.MaxLimit,
+			Window:          a.config.Window,
+			ScaleUpFactor:   a.config.ScaleUpFactor,
+			ScaleDownFactor: a.config.ScaleDownFactor,
+			OnAdjust: func(oldLimit, newLimit int, _ float64) {
+				if a.config.Metrics != nil {
+					a.config.Metrics.RecordRateLimitAdjustment(a.config.Name, oldLimit, newLimit)
+				}
+				if a.config.OnAdjust != nil {
+					a.config.OnAdjust(id, oldLimit, newLimit)
+				}
+			},
+		})
+		a.keys[id] = l
+	}
+	return l
+}
+
+// Allow implements the Limiter interface
+func (a *Adaptive) Allow(_ context.Context, id string) error {
+	if !a.limiter(id).Allow() {
+		return ErrRateLimitExceeded
+	}
+	return nil
+}
+
+// GetRemainingRequests implements the Limiter interface
+func (a *Adaptive) GetRemainingRequests(id string) int64 {
+	remaining, _ := a.limiter(id).Remaining()
+	return int64(remaining)
+}
+
+// Reset implements the Limiter interface. The learned limit is kept.
+func (a *Adaptive) Reset(id string) {
+	a.limiter(id).Reset()
+}
+
+// Quota implements QuotaProvider
+func (a *Adaptive) Quota(id string) Quota {
+	l := a.limiter(id)
+	remaining, resetAt := l.Remaining()
+	return Quota{Limit: int64(l.GetCurrentLimit()), Remaining: int64(remaining), ResetAt: resetAt}
+}
+
+// CurrentLimit returns the key's current per-window limit
+func (a *Adaptive) CurrentLimit(id string) int {
+	return a.limiter(id).GetCurrentLimit()
+}
@@ -0,0 +1,240 @@
+package rate
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/metrics"
+)
+
+// Observer receives the outcome of requests a limiter admitted, so limits
+// can follow the health of what they protect
+type Observer interface {
+	Observe(id string, err error, latency time.Duration)
+}
+
+// AIMDConfig configures an AIMD limiter
+type AIMDConfig struct {
+	// Name labels the limiter in metrics (default: "aimd")
+	Name string
+
+	// InitialLimit is the starting number of requests per window for each key
+	InitialLimit int64
+
+	// MinLimit is the floor the limit is never cut below (default: 1)
+	MinLimit int64
+
+	// MaxLimit is the ceiling the limit never grows above
+	// (default: InitialLimit)
+	MaxLimit int64
+
+	// Window is the period each limit applies to (default: 1s)
+	Window time.Duration
+
+	// Interval is how often the observed outcomes are evaluated
+	// (default: Window)
+	Interval time.Duration
+
+	// MinSamples is the number of observations an evaluation needs
+	// (default: 10)
+	MinSamples int64
+
+	// Increase is added to the limit after a healthy interval (default: 1)
+	Increase int64
+
+	// DecreaseFactor multiplies the limit after an unhealthy interval
+	// (default: 0.5)
+	DecreaseFactor float64
+
+	// ErrorThreshold is the error ratio above which an interval is
+	// unhealthy (default: 0.05)
+	ErrorThreshold float64
+
+	// LatencyThreshold is the mean latency above which an interval is
+	// unhealthy (optional)
+	LatencyThreshold time.Duration
+
+	// Metrics records limit adjustments and the current limit when set
+	Metrics *metrics.Collector
+
+	// OnAdjust is called whenever a key's limit changes. It runs while the
+	// limiter is locked and must not call back into it.
+	OnAdjust func(key string, oldLimit, newLimit int64)
+}
+
+// AIMD is a Limiter whose per-key limit follows the health of the requests
+// it admits, using additive increase, multiplicative decrease: every
+// Interval with an error ratio or mean latency over its threshold cuts the
+// limit by DecreaseFactor, and every healthy one raises it by Increase.
+// Outcomes are reported through Observe, or by Middleware when the limiter
+// is also its Observer.
+//
+// For one limit across all callers, use a single key or put the limiter at
+// the global level of a Hierarchical limiter.
+type AIMD struct {
+	config AIMDConfig
+	mu     sync.Mutex
+	keys   map[string]*aimdState
+}
+
+// aimdState is the limit, window count and observations of a key
+type aimdState struct {
+	limit       float64
+	windowStart time.Time
+	count       int64
+
+	evalStart time.Time
+	samples   int64
+	errors    int64
+	latency   time.Duration
+}
+
+var (
+	_ Limiter       = (*AIMD)(nil)
+	_ QuotaProvider = (*AIMD)(nil)
+	_ Observer      = (*AIMD)(nil)
+)
+
+// NewAIMD creates an AIMD limiter
+func NewAIMD(config AIMDConfig) (*AIMD, error) {
+	if config.InitialLimit <= 0 {
+		return nil, fmt.Errorf("%w: initial limit must be positive", ErrInvalidLimit)
+	}
+	if config.MinLimit <= 0 {
+		config.MinLimit = 1
+	}
+	if config.MaxLimit == 0 {
+		config.MaxLimit = config.InitialLimit
+	}
+	if config.MinLimit > config.InitialLimit || config.InitialLimit > config.MaxLimit {
+		return nil, fmt.Errorf("%w: need MinLimit <= InitialLimit <= MaxLimit", ErrInvalidLimit)
+	}
+	if config.Window <= 0 {
+		config.Window = time.Second
+	}
+	if config.Interval <= 0 {
+		config.Interval = config.Window
+	}
+	if config.MinSamples <= 0 {
+		config.MinSamples = 10
+	}
+	if config.Increase <= 0 {
+		config.Increase = 1
+	}
+	if config.DecreaseFactor == 0 {
+		config.DecreaseFactor = 0.5
+	}
+	if config.DecreaseFactor <= 0 || config.DecreaseFactor >= 1 {
+		return nil, fmt.Errorf("%w: decrease factor must be between 0 and 1", ErrInvalidConfig)
+	}
+	if config.ErrorThreshold == 0 {
+		config.ErrorThreshold = 0.05
+	}
+	if config.Name == "" {
+		config.Name = "aimd"
+	}
+	return &AIMD{config: config, keys: make(map[string]*aimdState)}, nil
+}
+
+// Allow implements the Limiter interface
+func (a *AIMD) Allow(_ context.Context, id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s := a.state(id, time.Now())
+	if s.count >= int64(s.limit) {
+		return ErrRateLimitExceeded
+	}
+	s.count++
+	return nil
+}
+
+// Observe implements Observer. A non-nil err counts as a failed request.
+func (a *AIMD) Observe(id string, err error, latency time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	s := a.state(id, now)
+	s.samples++
+	s.latency += latency
+	if err != nil {
+		s.errors++
+	}
+	if now.Sub(s.evalStart) >= a.config.Interval && s.samples >= a.config.MinSamples {
+		a.adjust(id, s)
+		s.evalStart, s.samples, s.errors, s.latency = now, 0, 0, 0
+	}
+}
+
+// adjust applies one AIMD step from the key's observations
+func (a *AIMD) adjust(id string, s *aimdState) {
+	errorRatio := float64(s.errors) / float64(s.samples)
+	meanLatency := s.latency / time.Duration(s.samples)
+	unhealthy := errorRatio > a.config.ErrorThreshold ||
+		(a.config.LatencyThreshold > 0 && meanLatency > a.config.LatencyThreshold)
+
+	old := int64(s.limit)
+	if unhealthy {
+		s.limit = max(s.limit*a.config.DecreaseFactor, float64(a.config.MinLimit))
+	} else {
+		s.limit = min(s.limit+float64(a.config.Increase), float64(a.config.MaxLimit))
+	}
+	if limit := int64(s.limit); limit != old {
+		if a.config.Metrics != nil {
+			a.config.Metrics.RecordRateLimitAdjustment(a.config.Name, int(old), int(limit))
+		}
+		if a.config.OnAdjust != nil {
+			a.config.OnAdjust(id, old, limit)
+		}
+	}
+}
+
+// GetRemainingRequests implements the Limiter interface
+func (a *AIMD) GetRemainingRequests(id string) int64 {
+	return a.Quota(id).Remaining
+}
+
+// Quota implements QuotaProvider
+func (a *AIMD) Quota(id string) Quota {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s := a.state(id, time.Now())
+	return Quota{
+		Limit:     int64(s.limit),
+		Remaining: max(int64(s.limit)-s.count, 0),
+		ResetAt:   s.windowStart.Add(a.config.Window),
+	}
+}
+
+// Reset implements the Limiter interface. The learned limit is kept.
+func (a *AIMD) Reset(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if s, ok := a.keys[id]; ok {
+		s.count = 0
+	}
+}
+
+// CurrentLimit returns the key's current per-window limit
+func (a *AIMD) CurrentLimit(id string) int64 {
+	return a.Quota(id).Limit
+}
+
+// state returns the key's state, starting a new window when the last one
+// has ended
+func (a *AIMD) state(id string, now time.Time) *aimdState {
+	s, ok := a.keys[id]
+	if !ok {
+		s = &aimdState{limit: float64(a.config.InitialLimit), windowStart: now, evalStart: now}
+		a.keys[id] = s
+	}
+	if now.Sub(s.windowStart) >= a.config.Window {
+		s.windowStart, s.count = now, 0
+	}
+	return s
+}
@@ -2,104 +2,317 @@ package rate
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
 )
 
-// DistributedLimiter implements distributed rate limiting using Redis
+// DistributedAlgorithm selects the algorithm of a DistributedLimiter
+type DistributedAlgorithm string
+
+const (
+	// DistributedSlidingWindow allows Rate requests in any Window
+	DistributedSlidingWindow DistributedAlgorithm = "sliding_window"
+
+	// DistributedTokenBucket refills Rate tokens per Window up to BurstSize
+	DistributedTokenBucket DistributedAlgorithm = "token_bucket"
+)
+
+// The scripts read the clock of the Redis server, so instances with skewed
+// clocks still share one window. Times are in microseconds.
+var (
+	slidingWindowAllow = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+if count >= limit then
+	return 0
+end
+redis.call('ZADD', KEYS[1], now, ARGV[3])
+redis.call('PEXPIRE', KEYS[1], math.ceil(window / 1000))
+return 1
+`)
+
+	slidingWindowRemaining = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local count = redis.call('ZCOUNT', KEYS[1], now - tonumber(ARGV[1]), '+inf')
+return math.max(tonumber(ARGV[2]) - count, 0)
+`)
+
+	tokenBucketAllow = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local window = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+if now > ts then
+	tokens = math.min(burst, tokens + (now - ts) * rate / window)
+end
+
+local allowed = 0
+if tokens >= cost then
+	tokens = tokens - cost
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * window / rate / 1000) + 1000)
+return allowed
+`)
+
+	tokenBucketRemaining = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local burst = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+if not tokens then
+	return burst
+end
+local ts = tonumber(state[2]) or now
+if now > ts then
+	tokens = math.min(burst, tokens + (now - ts) * tonumber(ARGV[2]) / tonumber(ARGV[1]))
+end
+return math.floor(tokens)
+`)
+)
+
+// DistributedLimiter enforces one limit across every instance sharing a
+// Redis deployment. Each decision is a single Lua script, so concurrent
+// requests on different instances cannot overshoot the limit.
+//
+// When Redis is unreachable the limiter falls back to a local limiter of
+// the same algorithm and configuration for RetryInterval before trying
+// Redis again, so an outage degrades limits to per-instance instead of
+// failing every request.
 type DistributedLimiter struct {
-	client    *redis.Client
-	keyPrefix string
-	window    time.Duration
-	rate      int64
-	burstSize int64
+	client    redis.UniversalClient
+	ownClient bool
+	config    Config
+	redis     RedisConfig
+	member    string
+	seq       atomic.Uint64
+
+	fallback Limiter
+	mu       sync.Mutex
+	downTill time.Time
 }
 
-// NewDistributedLimiter creates a new distributed rate limiter
+var (
+	_ Limiter       = (*DistributedLimiter)(nil)
+	_ QuotaProvider = (*DistributedLimiter)(nil)
+)
+
+// NewDistributedLimiter creates a Redis-backed rate limiter. cfg.Rate is
+// the limit per cfg.Window (default: 1s), and cfg.BurstSize the token
+// bucket capacity (default: Rate).
 func NewDistributedLimiter(cfg Config) (*DistributedLimiter, error) {
 	if cfg.DistributedConfig == nil {
 		return nil, ErrInvalidConfig
 	}
+	if cfg.Rate <= 0 {
+		return nil, ErrInvalidLimit
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = time.Second
+	}
+	if cfg.BurstSize <= 0 {
+		cfg.BurstSize = cfg.Rate
+	}
+	rc := *cfg.DistributedConfig
+	if rc.Algorithm == "" {
+		rc.Algorithm = DistributedSlidingWindow
+	}
+	if rc.Algorithm != DistributedSlidingWindow && rc.Algorithm != DistributedTokenBucket {
+		return nil, fmt.Errorf("%w: unknown algorithm %q", ErrInvalidConfig, rc.Algorithm)
+	}
+	if rc.KeyPrefix == "" {
+		rc.KeyPrefix = "gauth:rate:"
+	}
+	if rc.OpTimeout <= 0 {
+		rc.OpTimeout = 100 * time.Millisecond
+	}
+	if rc.RetryInterval <= 0 {
+		rc.RetryInterval = time.Second
+	}
 
-	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.DistributedConfig.Addresses[0], // TODO: Support cluster
-		Password: cfg.DistributedConfig.Password,
-		DB:       cfg.DistributedConfig.DB,
-	})
-
-	return &DistributedLimiter{
-		client:    client,
-		keyPrefix: cfg.DistributedConfig.KeyPrefix,
-		window:    cfg.Window,
-		rate:      cfg.Rate,
-		burstSize: cfg.BurstSize,
-	}, nil
+	dl := &DistributedLimiter{
+		client: rc.Client,
+		config: cfg,
+		redis:  rc,
+		member: uuid.New().String()[:8],
+	}
+	if dl.client == nil {
+		if len(rc.Addresses) == 0 {
+			return nil, fmt.Errorf("%w: no redis addresses", ErrInvalidConfig)
+		}
+		dl.client = redis.NewUniversalClient(&redis.UniversalOptions{
+			Addrs:        rc.Addresses,
+			Password:     rc.Password,
+			DB:           rc.DB,
+			PoolSize:     rc.PoolSize,
+			MinIdleConns: rc.MinIdleConns,
+			DialTimeout:  rc.OpTimeout * 10,
+			ReadTimeout:  rc.OpTimeout,
+			WriteTimeout: rc.OpTimeout,
+		})
+		dl.ownClient = true
+	}
+	if !rc.DisableFallback {
+		if rc.Algorithm == DistributedTokenBucket {
+			dl.fallback = NewTokenBucket(cfg)
+		} else {
+			dl.fallback = NewSlidingWindow(cfg)
+		}
+	}
+	return dl, nil
 }
 
 // Allow implements the Limiter interface
 func (dl *DistributedLimiter) Allow(ctx context.Context, id string) error {
-	key := dl.keyPrefix + id
-
-	// Lua script for atomic rate limiting
-	script := `
-		local key = KEYS[1]
-		local now = tonumber(ARGV[1])
-		local window = tonumber(ARGV[2])
-		local rate = tonumber(ARGV[3])
-
-		-- Clean old data
-		redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
-
-		-- Count requests in window
-		local count = redis.call('ZCARD', key)
-		
-		if count >= rate then
-			return 0
-		end
-
-		-- Add new request
-		redis.call('ZADD', key, now, now)
-		redis.call('EXPIRE', key, window)
-		
-		return 1
-	`
-
-	result, err := dl.client.Eval(ctx, script, []string{key},
-		time.Now().Unix(),
-		int64(dl.window.Seconds()),
-		dl.rate).Result()
-
+	if dl.fallingBack() {
+		return dl.fallback.Allow(ctx, id)
+	}
+	allowed, err := dl.allow(ctx, id)
 	if err != nil {
-		return err
+		if dl.fail(err) {
+			return dl.fallback.Allow(ctx, id)
+		}
+		return fmt.Errorf("failed to evaluate rate limit: %w", err)
 	}
-
-	if result.(int64) == 0 {
+	if !allowed {
 		return ErrRateLimitExceeded
 	}
-
 	return nil
 }
 
+// allow runs the algorithm's script
+func (dl *DistributedLimiter) allow(ctx context.Context, id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, dl.redis.OpTimeout)
+	defer cancel()
+
+	window := dl.config.Window.Microseconds()
+	var cmd *redis.Cmd
+	if dl.redis.Algorithm == DistributedTokenBucket {
+		cmd = tokenBucketAllow.Run(ctx, dl.client, []string{dl.key(id)},
+			window, dl.config.Rate, dl.config.BurstSize, 1)
+	} else {
+		member := dl.member + "-" + strconv.FormatUint(dl.seq.Add(1), 36)
+		cmd = slidingWindowAllow.Run(ctx, dl.client, []string{dl.key(id)},
+			window, dl.config.Rate, member)
+	}
+	allowed, err := cmd.Int64()
+	return allowed == 1, err
+}
+
 // GetRemainingRequests implements the Limiter interface
 func (dl *DistributedLimiter) GetRemainingRequests(id string) int64 {
-	key := dl.keyPrefix + id
+	if dl.fallingBack() {
+		return dl.fallback.GetRemainingRequests(id)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dl.redis.OpTimeout)
+	defer cancel()
 
-	count, err := dl.client.ZCard(context.Background(), key).Result()
+	script := slidingWindowRemaining
+	if dl.redis.Algorithm == DistributedTokenBucket {
+		script = tokenBucketRemaining
+	}
+	remaining, err := script.Run(ctx, dl.client, []string{dl.key(id)},
+		dl.config.Window.Microseconds(), dl.config.Rate, dl.config.BurstSize).Int64()
 	if err != nil {
+		if dl.fail(err) {
+			return dl.fallback.GetRemainingRequests(id)
+		}
 		return 0
 	}
+	return remaining
+}
 
-	return dl.rate - count
+// Quota implements QuotaProvider. ResetAt is when the key's full
+// allowance is available again, at the latest.
+func (dl *DistributedLimiter) Quota(id string) Quota {
+	limit, refill := dl.config.Rate, dl.config.Window
+	if dl.redis.Algorithm == DistributedTokenBucket {
+		limit = dl.config.BurstSize
+		refill = time.Duration(float64(dl.config.Window) * float64(limit) / float64(dl.config.Rate))
+	}
+	return Quota{
+		Limit:     limit,
+		Remaining: dl.GetRemainingRequests(id),
+		ResetAt:   time.Now().Add(refill),
+	}
 }
 
 // Reset implements the Limiter interface
 func (dl *DistributedLimiter) Reset(id string) {
-	key := dl.keyPrefix + id
-	dl.client.Del(context.Background(), key)
+	ctx, cancel := context.WithTimeout(context.Background(), dl.redis.OpTimeout)
+	defer cancel()
+	dl.client.Del(ctx, dl.key(id))
+	if dl.fallback != nil {
+		dl.fallback.Reset(id)
+	}
+}
+
+// Available reports whether the limiter is using Redis rather than its
+// local fallback
+func (dl *DistributedLimiter) Available() bool {
+	return !dl.fallingBack()
+}
+
+// Ping checks the Redis connection, for health probes. The limiter keeps
+// serving from its local fallback while Redis is down, so register it as
+// an optional probe.
+func (dl *DistributedLimiter) Ping(ctx context.Context) error {
+	return dl.client.Ping(ctx).Err()
 }
 
-// Close releases resources used by the limiter
+// Close releases the Redis connections, unless the client was supplied
+// through RedisConfig.Client
 func (dl *DistributedLimiter) Close() error {
-	return dl.client.Close()
+	if dl.ownClient {
+		return dl.client.Close()
+	}
+	return nil
+}
+
+func (dl *DistributedLimiter) key(id string) string {
+	return dl.redis.KeyPrefix + id
+}
+
+// fallingBack reports whether Redis failed within RetryInterval
+func (dl *DistributedLimiter) fallingBack() bool {
+	if dl.fallback == nil {
+		return false
+	}
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return time.Now().Before(dl.downTill)
+}
+
+// fail records a Redis failure and reports whether to fall back
+func (dl *DistributedLimiter) fail(err error) bool {
+	if dl.fallback == nil {
+		return false
+	}
+	dl.mu.Lock()
+	dl.downTill = time.Now().Add(dl.redis.RetryInterval)
+	dl.mu.Unlock()
+	if dl.redis.OnFallback != nil {
+		dl.redis.OnFallback(err)
+	}
+	return true
 }
@@ -38,6 +38,23 @@ package rate
 //	    Window:   time.Minute,
 //	})
 //
+// FixedWindow counts requests in clock-aligned windows, one counter per key:
+//
+//	window := rate.NewFixedWindow(rate.Config{
+//	    Rate:   1000,
+//	    Window: time.Minute,
+//	})
+//
+// LeakyBucket leaks Rate requests per Window out of a bucket of BurstSize.
+// Wait blocks until a request leaks out, for a constant outflow:
+//
+//	bucket := rate.NewLeakyBucket(rate.Config{
+//	    Rate:      100,
+//	    Window:    time.Second,
+//	    BurstSize: 20,
+//	})
+//	err := bucket.Wait(ctx, "user-123")
+//
 // # Usage Examples
 //
 // Basic usage:
@@ -56,13 +73,48 @@ package rate
 //
 //	http.Handle("/api", rate.Middleware(limiter)(handler))
 //
-// # Distributed Rate Limiting
+// # Adaptive Limits
 //
-// The package supports distributed rate limiting through Redis:
+// AIMD scales each key's limit with the health of the requests it admits:
+// intervals with too many errors or too slow responses cut the limit by a
+// factor, healthy ones raise it by a step. Outcomes arrive through Observe
+// or the Observer of Middleware:
+//
+//	limiter, err := rate.NewAIMD(rate.AIMDConfig{
+//	    InitialLimit:     200,
+//	    MinLimit:         20,
+//	    MaxLimit:         1000,
+//	    LatencyThreshold: 250 * time.Millisecond,
+//	})
+//	limiter.Observe("upstream", err, latency)
+//
+// # Hierarchical Limits
+//
+// Hierarchical enforces a global, a per-client and a per-token limit
+// together, and reports the level that rejected a request in a
+// *LimitError:
+//
+//	limiter, err := rate.NewHierarchical(rate.HierarchicalConfig{
+//	    Global: rate.NewSlidingWindow(rate.Config{Rate: 10000, Window: time.Minute}),
+//	    Client: rate.NewFixedWindow(rate.Config{Rate: 1000, Window: time.Minute}),
+//	    Token:  rate.NewLeakyBucket(rate.Config{Rate: 100, Window: time.Minute}),
+//	})
+//	err = limiter.Allow(ctx, rate.HierarchicalKey("client-123", tokenID))
+//
+// # Distributed Rate Limiting
 //
-//	limiter := rate.NewDistributedLimiter(rate.RedisConfig{
-//	    Addresses: []string{"localhost:6379"},
-//	    Password:  "secret",
+// DistributedLimiter shares one limit across instances through Redis,
+// deciding each request in a Lua script. It falls back to a local limiter
+// while Redis is unreachable:
+//
+//	limiter, err := rate.NewDistributedLimiter(rate.Config{
+//	    Rate:   1000,
+//	    Window: time.Minute,
+//	    DistributedConfig: &rate.RedisConfig{
+//	        Addresses: []string{"localhost:6379"},
+//	        Password:  "secret",
+//	        Algorithm: rate.DistributedSlidingWindow,
+//	    },
 //	})
 //
 // # Error Types
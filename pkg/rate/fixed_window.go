@@ -0,0 +1,106 @@
+package rate
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// FixedWindow implements the fixed window counter algorithm. Each key may
+// make Rate requests per Window, counted from window boundaries aligned to
+// the wall clock, so every key and every instance resets at the same
+// moment.
+//
+// It keeps one counter per key, the least memory of the algorithms, at the
+// cost of accuracy: a client that sends its requests around a boundary can
+// make up to twice Rate requests within one Window.
+type FixedWindow struct {
+	requests int64
+	window   time.Duration
+	mu       sync.Mutex
+	windows  map[string]*fixedWindowInfo
+}
+
+// fixedWindowInfo holds a key's count in the current window
+type fixedWindowInfo struct {
+	start time.Time
+	count int64
+}
+
+var (
+	_ Limiter       = (*FixedWindow)(nil)
+	_ QuotaProvider = (*FixedWindow)(nil)
+)
+
+// NewFixedWindow creates a new fixed window rate limiter. cfg.Rate is the
+// limit per cfg.Window (default: 1s).
+func NewFixedWindow(cfg Config) *FixedWindow {
+	if cfg.Window <= 0 {
+		cfg.Window = time.Second
+	}
+	return &FixedWindow{
+		requests: cfg.Rate,
+		window:   cfg.Window,
+		windows:  make(map[string]*fixedWindowInfo),
+	}
+}
+
+// Allow implements the Limiter interface
+func (fw *FixedWindow) Allow(_ context.Context, id string) error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	info := fw.current(id, time.Now())
+	if info.count >= fw.requests {
+		return ErrRateLimitExceeded
+	}
+	info.count++
+	return nil
+}
+
+// GetRemainingRequests implements the Limiter interface
+func (fw *FixedWindow) GetRemainingRequests(id string) int64 {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	info, ok := fw.windows[id]
+	if !ok || time.Since(info.start) >= fw.window {
+		return fw.requests
+	}
+	return max(fw.requests-info.count, 0)
+}
+
+// Quota implements QuotaProvider. ResetAt is the end of the current window.
+func (fw *FixedWindow) Quota(id string) Quota {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	info := fw.current(id, time.Now())
+	return Quota{
+		Limit:     fw.requests,
+		Remaining: max(fw.requests-info.count, 0),
+		ResetAt:   info.start.Add(fw.window),
+	}
+}
+
+// Reset implements the Limiter interface
+func (fw *FixedWindow) Reset(id string) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	delete(fw.windows, id)
+}
+
+// current returns the key's counter, starting a new window when the last
+// one has ended
+func (fw *FixedWindow) current(id string, now time.Time) *fixedWindowInfo {
+	info, ok := fw.windows[id]
+	if !ok {
+		info = &fixedWindowInfo{}
+		fw.windows[id] = info
+	}
+	if now.Sub(info.start) >= fw.window {
+		info.start = now.Truncate(fw.window)
+		info.count = 0
+	}
+	return info
+}
@@ -0,0 +1,214 @@
+package rate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Level names a level of a Hierarchical limiter
+type Level string
+
+const (
+	// LevelGlobal is the ceiling shared by all requests
+	LevelGlobal Level = "global"
+
+	// LevelClient limits each client
+	LevelClient Level = "client"
+
+	// LevelToken limits each token of a client
+	LevelToken Level = "token"
+)
+
+// hierarchicalSeparator joins the client and token IDs of a key. It cannot
+// appear in header values, so IDs taken from requests never contain it.
+const hierarchicalSeparator = "\x1f"
+
+// LimitError reports which level of a Hierarchical limiter rejected a
+// request. It wraps ErrRateLimitExceeded, so errors.Is keeps working.
+type LimitError struct {
+	Level Level
+
+	// Quota is the rejecting level's quota, when its limiter reports one
+	Quota Quota
+
+	// RetryAfter is how long until the rejecting level admits requests
+	// again, or 0 if its limiter cannot tell
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded at %s level", e.Level)
+}
+
+// Unwrap returns ErrRateLimitExceeded
+func (e *LimitError) Unwrap() error {
+	return ErrRateLimitExceeded
+}
+
+// HierarchicalConfig configures a Hierarchical limiter. Each level is
+// optional, but at least one is required.
+type HierarchicalConfig struct {
+	// Global limits all requests together
+	Global Limiter
+
+	// Client limits the requests of each client ID
+	Client Limiter
+
+	// Token limits the requests of each token ID
+	Token Limiter
+}
+
+// Hierarchical enforces nested quotas: a global ceiling, a limit per
+// client and a limit per token. A request must pass every level, and a
+// rejection is a *LimitError naming the level.
+//
+// Keys combine the client and token IDs; build them with HierarchicalKey
+// or HierarchicalKeyFunc. Token limits are kept per client, so the same
+// token ID under two clients counts separately.
+//
+// Levels are charged from the most specific to the global one and stop at
+// the first rejection, so a token over its limit cannot use up its client's
+// or the global quota. Levels passed before a rejection stay charged.
+type Hierarchical struct {
+	config HierarchicalConfig
+}
+
+var (
+	_ Limiter       = (*Hierarchical)(nil)
+	_ QuotaProvider = (*Hierarchical)(nil)
+)
+
+// NewHierarchical creates a hierarchical limiter
+func NewHierarchical(config HierarchicalConfig) (*Hierarchical, error) {
+	if config.Global == nil && config.Client == nil && config.Token == nil {
+		return nil, fmt.Errorf("%w: no levels configured", ErrInvalidConfig)
+	}
+	return &Hierarchical{config: config}, nil
+}
+
+// HierarchicalKey builds the key of a client and token. Either may be
+// empty, which skips its level; a plain client ID is a valid key too.
+func HierarchicalKey(clientID, tokenID string) string {
+	return clientID + hierarchicalSeparator + tokenID
+}
+
+// HierarchicalKeyFunc builds request keys for a Hierarchical limiter used
+// with Middleware
+func HierarchicalKeyFunc(clientFunc, tokenFunc func(*http.Request) string) func(*http.Request) string {
+	return func(r *http.Request) string {
+		var clientID, tokenID string
+		if clientFunc != nil {
+			clientID = clientFunc(r)
+		}
+		if tokenFunc != nil {
+			tokenID = tokenFunc(r)
+		}
+		return HierarchicalKey(clientID, tokenID)
+	}
+}
+
+// BearerTokenID identifies the bearer token of a request by a hash, so raw
+// tokens are never kept as limiter keys. It returns "" without a bearer
+// token.
+func BearerTokenID(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.TrimSpace(auth[7:])))
+	return hex.EncodeToString(sum[:12])
+}
+
+// Allow implements the Limiter interface. It returns a *LimitError when a
+// level rejects the request.
+func (h *Hierarchical) Allow(ctx context.Context, id string) error {
+	for _, l := range h.levels(id) {
+		err := l.limiter.Allow(ctx, l.key)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, ErrRateLimitExceeded) {
+			return fmt.Errorf("failed to check %s rate limit: %w", l.level, err)
+		}
+		le := &LimitError{Level: l.level}
+		if qp, ok := l.limiter.(QuotaProvider); ok {
+			le.Quota = qp.Quota(l.key)
+			le.RetryAfter = max(time.Until(le.Quota.ResetAt), 0)
+		}
+		return le
+	}
+	return nil
+}
+
+// GetRemainingRequests implements the Limiter interface. It returns the
+// remaining requests of the tightest level.
+func (h *Hierarchical) GetRemainingRequests(id string) int64 {
+	remaining := int64(-1)
+	for _, l := range h.levels(id) {
+		if r := l.limiter.GetRemainingRequests(l.key); remaining < 0 || r < remaining {
+			remaining = r
+		}
+	}
+	return max(remaining, 0)
+}
+
+// Quota implements QuotaProvider. It returns the quota of the level with
+// the fewest remaining requests among those that report one.
+func (h *Hierarchical) Quota(id string) Quota {
+	var tightest Quota
+	found := false
+	for _, l := range h.levels(id) {
+		qp, ok := l.limiter.(QuotaProvider)
+		if !ok {
+			continue
+		}
+		if q := qp.Quota(l.key); !found || q.Remaining < tightest.Remaining {
+			tightest, found = q, true
+		}
+	}
+	if !found {
+		tightest.Remaining = h.GetRemainingRequests(id)
+	}
+	return tightest
+}
+
+// Reset implements the Limiter interface. It resets the client and token
+// levels of the key; the global level is shared and left alone.
+func (h *Hierarchical) Reset(id string) {
+	for _, l := range h.levels(id) {
+		if l.level != LevelGlobal {
+			l.limiter.Reset(l.key)
+		}
+	}
+}
+
+// hierarchyLevel is a limiter and the key it is charged with
+type hierarchyLevel struct {
+	level   Level
+	limiter Limiter
+	key     string
+}
+
+// levels returns the configured levels that apply to the key, most
+// specific first
+func (h *Hierarchical) levels(id string) []hierarchyLevel {
+	clientID, tokenID, _ := strings.Cut(id, hierarchicalSeparator)
+	levels := make([]hierarchyLevel, 0, 3)
+	if h.config.Token != nil && tokenID != "" {
+		levels = append(levels, hierarchyLevel{LevelToken, h.config.Token, HierarchicalKey(clientID, tokenID)})
+	}
+	if h.config.Client != nil && clientID != "" {
+		levels = append(levels, hierarchyLevel{LevelClient, h.config.Client, clientID})
+	}
+	if h.config.Global != nil {
+		levels = append(levels, hierarchyLevel{LevelGlobal, h.config.Global, string(LevelGlobal)})
+	}
+	return levels
+}
@@ -0,0 +1,135 @@
+package rate
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LeakyBucket implements the leaky bucket algorithm. Requests fill a
+// bucket of BurstSize that leaks at a constant Rate per Window; a request
+// that would overflow the bucket is rejected.
+//
+// The bucket is kept as the time it will be empty again, so each key costs
+// a single timestamp regardless of its rate. Allow meters requests as they
+// come, while Wait delays each request until its turn to leave the bucket,
+// shaping bursts into a constant outflow.
+type LeakyBucket struct {
+	capacity int64
+	interval time.Duration
+	mu       sync.Mutex
+	drained  map[string]time.Time
+}
+
+var (
+	_ Limiter       = (*LeakyBucket)(nil)
+	_ QuotaProvider = (*LeakyBucket)(nil)
+)
+
+// NewLeakyBucket creates a new leaky bucket rate limiter. cfg.Rate is the
+// number of requests leaking out per cfg.Window (default: 1s), and
+// cfg.BurstSize the bucket capacity (default: Rate).
+func NewLeakyBucket(cfg Config) *LeakyBucket {
+	if cfg.Window <= 0 {
+		cfg.Window = time.Second
+	}
+	if cfg.BurstSize <= 0 {
+		cfg.BurstSize = cfg.Rate
+	}
+	interval := cfg.Window
+	if cfg.Rate > 0 {
+		interval = cfg.Window / time.Duration(cfg.Rate)
+	}
+	return &LeakyBucket{
+		capacity: cfg.BurstSize,
+		interval: interval,
+		drained:  make(map[string]time.Time),
+	}
+}
+
+// Allow implements the Limiter interface
+func (lb *LeakyBucket) Allow(_ context.Context, id string) error {
+	_, err := lb.add(id, time.Now())
+	return err
+}
+
+// Wait adds a request to the bucket and blocks until it leaks out, so
+// callers proceed at a constant rate. It fails at once with
+// ErrRateLimitExceeded when the bucket is full. A request whose context
+// ends while waiting keeps its place in the bucket.
+func (lb *LeakyBucket) Wait(ctx context.Context, id string) error {
+	now := time.Now()
+	leaveAt, err := lb.add(id, now)
+	if err != nil {
+		return err
+	}
+	delay := leaveAt.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// add puts a request in the bucket and returns when it leaks out
+func (lb *LeakyBucket) add(id string, now time.Time) (time.Time, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	leaveAt := lb.drained[id]
+	if leaveAt.Before(now) {
+		leaveAt = now
+	}
+	if leaveAt.Add(lb.interval).Sub(now) > time.Duration(lb.capacity)*lb.interval {
+		return time.Time{}, ErrRateLimitExceeded
+	}
+	lb.drained[id] = leaveAt.Add(lb.interval)
+	return leaveAt, nil
+}
+
+// GetRemainingRequests implements the Limiter interface
+func (lb *LeakyBucket) GetRemainingRequests(id string) int64 {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.remaining(id, time.Now())
+}
+
+// Quota implements QuotaProvider. ResetAt is when the bucket is empty.
+func (lb *LeakyBucket) Quota(id string) Quota {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	now := time.Now()
+	resetAt := lb.drained[id]
+	if resetAt.Before(now) {
+		resetAt = now
+	}
+	return Quota{
+		Limit:     lb.capacity,
+		Remaining: lb.remaining(id, now),
+		ResetAt:   resetAt,
+	}
+}
+
+// Reset implements the Limiter interface
+func (lb *LeakyBucket) Reset(id string) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	delete(lb.drained, id)
+}
+
+// remaining returns the free space in the bucket
+func (lb *LeakyBucket) remaining(id string, now time.Time) int64 {
+	level := lb.drained[id].Sub(now)
+	if level <= 0 {
+		return lb.capacity
+	}
+	queued := int64((level + lb.interval - 1) / lb.interval)
+	return max(lb.capacity-queued, 0)
+}
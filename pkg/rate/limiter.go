@@ -6,6 +6,8 @@ import (
 	"errors"
 	"sync"
 	"time"
+
+	"github.com/go-redis/redis/v8"
 )
 
 // Common errors
@@ -42,6 +44,33 @@ type RedisConfig struct {
 
 	// KeyPrefix for Redis keys
 	KeyPrefix string
+
+	// Client is used instead of dialing Addresses, to share a pool (optional)
+	Client redis.UniversalClient
+
+	// Algorithm of a DistributedLimiter (default: DistributedSlidingWindow)
+	Algorithm DistributedAlgorithm
+
+	// PoolSize and MinIdleConns size the connection pool (default: the
+	// go-redis defaults)
+	PoolSize     int
+	MinIdleConns int
+
+	// OpTimeout bounds each Redis round trip, keeping a slow Redis off the
+	// request path (default: 100ms)
+	OpTimeout time.Duration
+
+	// RetryInterval is how long the local fallback serves requests after
+	// Redis fails before Redis is tried again (default: 1s)
+	RetryInterval time.Duration
+
+	// DisableFallback makes requests fail with the Redis error instead of
+	// using a local limiter
+	DisableFallback bool
+
+	// OnFallback receives each Redis failure that switches to the local
+	// limiter (optional)
+	OnFallback func(error)
 }
 
 // Limiter defines the interface for rate limiting
@@ -1,10 +1,12 @@
 package rate
 
 import (
+	"errors"
 	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // HTTPLimiterConfig configures HTTP rate limiting
@@ -26,6 +28,39 @@ type HTTPLimiterConfig struct {
 
 	// Headers determines if rate limit headers should be included in responses
 	Headers bool
+
+	// Observer receives the outcome and latency of each admitted request,
+	// with 5xx responses counted as errors; set it to an AIMD limiter to
+	// adapt its limit (optional)
+	Observer Observer
+}
+
+// errServerResponse is observed for requests answered with a 5xx status
+var errServerResponse = errors.New("server error response")
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
 }
 
 // Middleware creates a new HTTP middleware for rate limiting
@@ -55,19 +90,30 @@ func Middleware(cfg HTTPLimiterConfig) func(http.Handler) http.Handler {
 
 			// Check rate limit
 			err := cfg.Limiter.Allow(r.Context(), key)
-			if err == ErrRateLimitExceeded {
+			if errors.Is(err, ErrRateLimitExceeded) {
 				if cfg.Headers {
-					setRateLimitHeaders(w, cfg.Limiter.GetRemainingRequests(key))
+					setLimiterHeaders(w, cfg.Limiter, key, err)
 				}
 				http.Error(w, cfg.Message, cfg.StatusCode)
 				return
 			}
 
 			if cfg.Headers {
-				setRateLimitHeaders(w, cfg.Limiter.GetRemainingRequests(key))
+				setLimiterHeaders(w, cfg.Limiter, key, nil)
 			}
 
-			next.ServeHTTP(w, r)
+			if cfg.Observer == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rec := &statusRecorder{ResponseWriter: w}
+			start := time.Now()
+			next.ServeHTTP(rec, r)
+			var outcome error
+			if rec.status >= http.StatusInternalServerError {
+				outcome = errServerResponse
+			}
+			cfg.Observer.Observe(key, outcome, time.Since(start))
 		})
 	}
 }
@@ -128,6 +174,41 @@ func getIP(r *http.Request) string {
 	return ip
 }
 
+// setLimiterHeaders sets the remaining-requests header and, for limiters
+// that report a full quota, the limit, reset and Retry-After headers.
+// rejected is the error of a rejected request, nil otherwise; a
+// *LimitError supplies the quota of the level that rejected it.
+func setLimiterHeaders(w http.ResponseWriter, limiter Limiter, key string, rejected error) {
+	var le *LimitError
+	if errors.As(rejected, &le) {
+		w.Header().Set("X-RateLimit-Level", string(le.Level))
+		if !le.Quota.ResetAt.IsZero() {
+			setQuotaHeaders(w, le.Quota, true)
+			return
+		}
+	}
+	qp, ok := limiter.(QuotaProvider)
+	if !ok {
+		setRateLimitHeaders(w, limiter.GetRemainingRequests(key))
+		return
+	}
+	setQuotaHeaders(w, qp.Quota(key), rejected != nil)
+}
+
+// setQuotaHeaders sets the headers of a quota
+func setQuotaHeaders(w http.ResponseWriter, q Quota, limited bool) {
+	setRateLimitHeaders(w, q.Remaining)
+	w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(q.Limit, 10))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(q.ResetAt.Unix(), 10))
+	if limited {
+		retry := int64(time.Until(q.ResetAt).Seconds() + 0.999)
+		if retry < 1 {
+			retry = 1
+		}
+		w.Header().Set("Retry-After", strconv.FormatInt(retry, 10))
+	}
+}
+
 // setRateLimitHeaders sets rate limit headers on the response
 func setRateLimitHeaders(w http.ResponseWriter, remaining int64) {
 	w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
@@ -0,0 +1,451 @@
+package rate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// KeyState is the persisted limiter state for a single rate limit key
+type KeyState struct {
+	// Tokens is the remaining token count (token bucket)
+	Tokens int64 `json:"tokens,omitempty"`
+
+	// LastUpdate is when Tokens was last updated (token bucket)
+	LastUpdate time.Time `json:"last_update,omitempty"`
+
+	// Timestamps are the requests inside the current window (sliding window)
+	Timestamps []time.Time `json:"timestamps,omitempty"`
+
+	// WindowStart and Count are the current window and the requests made
+	// in it (fixed window, AIMD)
+	WindowStart time.Time `json:"window_start,omitempty"`
+	Count       int64     `json:"count,omitempty"`
+
+	// DrainedAt is when the bucket will be empty (leaky bucket)
+	DrainedAt time.Time `json:"drained_at,omitempty"`
+
+	// Limit is the learned per-window limit (AIMD)
+	Limit int64 `json:"limit,omitempty"`
+}
+
+// StatefulLimiter is a limiter whose per-key state can be exported and restored
+type StatefulLimiter interface {
+	Limiter
+
+	// ExportState returns a copy of the current per-key state
+	ExportState() map[string]KeyState
+
+	// ImportState merges previously exported state into the limiter
+	ImportState(state map[string]KeyState)
+}
+
+var (
+	_ StatefulLimiter = (*TokenBucket)(nil)
+	_ StatefulLimiter = (*SlidingWindow)(nil)
+	_ StatefulLimiter = (*FixedWindow)(nil)
+	_ StatefulLimiter = (*LeakyBucket)(nil)
+	_ StatefulLimiter = (*AIMD)(nil)
+	_ StatefulLimiter = (*Hierarchical)(nil)
+)
+
+// StateStore persists limiter state between process restarts
+type StateStore interface {
+	// SaveState stores the state for the named limiter
+	SaveState(ctx context.Context, name string, state map[string]KeyState) error
+
+	// LoadState returns the stored state for the named limiter, or nil if none exists
+	LoadState(ctx context.Context, name string) (map[string]KeyState, error)
+}
+
+// FileStateStore persists limiter state as JSON snapshot files
+type FileStateStore struct {
+	dir string
+}
+
+// NewFileStateStore creates a snapshot store in dir
+func NewFileStateStore(dir string) (*FileStateStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return &FileStateStore{dir: dir}, nil
+}
+
+func (s *FileStateStore) path(name string) string {
+	return filepath.Join(s.dir, filepath.Base(name)+".json")
+}
+
+// SaveState implements StateStore. The snapshot is written atomically.
+func (s *FileStateStore) SaveState(_ context.Context, name string, state map[string]KeyState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode limiter state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".snapshot-*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return os.Rename(tmp.Name(), s.path(name))
+}
+
+// LoadState implements StateStore
+func (s *FileStateStore) LoadState(_ context.Context, name string) (map[string]KeyState, error) {
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var state map[string]KeyState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	return state, nil
+}
+
+// RedisStateStore persists limiter state in Redis so it survives rolling deploys
+type RedisStateStore struct {
+	client redis.UniversalClient
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisStateStore creates a Redis-backed state store. ttl bounds how long
+// stale state is kept (0 = no expiry).
+func NewRedisStateStore(client redis.UniversalClient, prefix string, ttl time.Duration) *RedisStateStore {
+	if prefix == "" {
+		prefix = "ratelimit:state:"
+	}
+	return &RedisStateStore{client: client, prefix: prefix, ttl: ttl}
+}
+
+// SaveState implements StateStore
+func (s *RedisStateStore) SaveState(ctx context.Context, name string, state map[string]KeyState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode limiter state: %w", err)
+	}
+	if err := s.client.Set(ctx, s.prefix+name, data, s.ttl).Err(); err != nil {
+		return fmt.Errorf("%w: %v", ErrStoreFailed, err)
+	}
+	return nil
+}
+
+// LoadState implements StateStore
+func (s *RedisStateStore) LoadState(ctx context.Context, name string) (map[string]KeyState, error) {
+	data, err := s.client.Get(ctx, s.prefix+name).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStoreFailed, err)
+	}
+	var state map[string]KeyState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode limiter state: %w", err)
+	}
+	return state, nil
+}
+
+// PersisterConfig configures limiter state persistence
+type PersisterConfig struct {
+	// Name identifies the limiter in the state store
+	Name string
+
+	// Limiter is the limiter whose state is persisted
+	Limiter StatefulLimiter
+
+	// Store is where state is saved
+	Store StateStore
+
+	// Interval is how often Run saves state (default: 10s)
+	Interval time.Duration
+}
+
+// Persister restores limiter state on startup and saves it periodically and
+// on shutdown, so counters survive restarts instead of resetting to full burst
+type Persister struct {
+	config PersisterConfig
+}
+
+// NewPersister creates a limiter state persister
+func NewPersister(config PersisterConfig) (*Persister, error) {
+	if config.Name == "" || config.Limiter == nil {
+		return nil, ErrInvalidConfig
+	}
+	if config.Store == nil {
+		return nil, ErrStoreRequired
+	}
+	if config.Interval <= 0 {
+		config.Interval = 10 * time.Second
+	}
+	return &Persister{config: config}, nil
+}
+
+// Restore loads saved state into the limiter
+func (p *Persister) Restore(ctx context.Context) error {
+	state, err := p.config.Store.LoadState(ctx, p.config.Name)
+	if err != nil {
+		return err
+	}
+	if state != nil {
+		p.config.Limiter.ImportState(state)
+	}
+	return nil
+}
+
+// Save writes the limiter's current state to the store
+func (p *Persister) Save(ctx context.Context) error {
+	return p.config.Store.SaveState(ctx, p.config.Name, p.config.Limiter.ExportState())
+}
+
+// Run saves state every Interval until ctx is cancelled, then performs a
+// final save. Errors are reported to onError when it is non-nil.
+func (p *Persister) Run(ctx context.Context, onError func(error)) {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Final save with a fresh context so shutdown does not lose state
+			saveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := p.Save(saveCtx); err != nil && onError != nil {
+				onError(err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := p.Save(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// ExportState implements StatefulLimiter
+func (tb *TokenBucket) ExportState() map[string]KeyState {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+
+	state := make(map[string]KeyState)
+	tb.tokens.Range(func(k, v interface{}) bool {
+		id := k.(string)
+		ks := KeyState{Tokens: v.(int64)}
+		if last, ok := tb.lastTime.Load(id); ok {
+			ks.LastUpdate = last.(time.Time)
+		}
+		state[id] = ks
+		return true
+	})
+	return state
+}
+
+// ImportState implements StatefulLimiter
+func (tb *TokenBucket) ImportState(state map[string]KeyState) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	for id, ks := range state {
+		tokens := ks.Tokens
+		if tokens > tb.burstSize {
+			tokens = tb.burstSize
+		}
+		last := ks.LastUpdate
+		if last.IsZero() {
+			last = time.Now()
+		}
+		tb.tokens.Store(id, tokens)
+		tb.lastTime.Store(id, last)
+	}
+}
+
+// ExportState implements StatefulLimiter
+func (sw *SlidingWindow) ExportState() map[string]KeyState {
+	sw.mu.RLock()
+	defer sw.mu.RUnlock()
+
+	cutoff := time.Now().Add(-sw.window)
+	state := make(map[string]KeyState)
+	sw.counts.Range(func(k, v interface{}) bool {
+		info := v.(*windowInfo)
+		var timestamps []time.Time
+		for _, ts := range info.timestamps {
+			if ts.After(cutoff) {
+				timestamps = append(timestamps, ts)
+			}
+		}
+		if len(timestamps) > 0 {
+			state[k.(string)] = KeyState{Timestamps: timestamps}
+		}
+		return true
+	})
+	return state
+}
+
+// ImportState implements StatefulLimiter
+func (sw *SlidingWindow) ImportState(state map[string]KeyState) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	for id, ks := range state {
+		timestamps := append([]time.Time(nil), ks.Timestamps...)
+		sw.counts.Store(id, &windowInfo{count: int64(len(timestamps)), timestamps: timestamps})
+	}
+}
+
+// ExportState implements StatefulLimiter. Keys whose window has ended are
+// left out.
+func (fw *FixedWindow) ExportState() map[string]KeyState {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	now := time.Now()
+	state := make(map[string]KeyState)
+	for id, info := range fw.windows {
+		if info.count > 0 && now.Sub(info.start) < fw.window {
+			state[id] = KeyState{WindowStart: info.start, Count: info.count}
+		}
+	}
+	return state
+}
+
+// ImportState implements StatefulLimiter
+func (fw *FixedWindow) ImportState(state map[string]KeyState) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	for id, ks := range state {
+		fw.windows[id] = &fixedWindowInfo{start: ks.WindowStart, count: ks.Count}
+	}
+}
+
+// ExportState implements StatefulLimiter. Empty buckets are left out.
+func (lb *LeakyBucket) ExportState() map[string]KeyState {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	now := time.Now()
+	state := make(map[string]KeyState)
+	for id, drained := range lb.drained {
+		if drained.After(now) {
+			state[id] = KeyState{DrainedAt: drained}
+		}
+	}
+	return state
+}
+
+// ImportState implements StatefulLimiter. Buckets are capped at their
+// capacity, in case it shrank since the export.
+func (lb *LeakyBucket) ImportState(state map[string]KeyState) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	full := time.Now().Add(time.Duration(lb.capacity) * lb.interval)
+	for id, ks := range state {
+		if ks.DrainedAt.After(full) {
+			ks.DrainedAt = full
+		}
+		lb.drained[id] = ks.DrainedAt
+	}
+}
+
+// ExportState implements StatefulLimiter. The learned limits are exported
+// along with the window counts, so a restart does not lose what the
+// limiter learned about its backend.
+func (a *AIMD) ExportState() map[string]KeyState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state := make(map[string]KeyState, len(a.keys))
+	for id, s := range a.keys {
+		state[id] = KeyState{WindowStart: s.windowStart, Count: s.count, Limit: int64(s.limit)}
+	}
+	return state
+}
+
+// ImportState implements StatefulLimiter. Limits are kept between MinLimit
+// and MaxLimit.
+func (a *AIMD) ImportState(state map[string]KeyState) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for id, ks := range state {
+		limit := a.config.InitialLimit
+		if ks.Limit > 0 {
+			limit = min(max(ks.Limit, a.config.MinLimit), a.config.MaxLimit)
+		}
+		a.keys[id] = &aimdState{
+			limit:       float64(limit),
+			windowStart: ks.WindowStart,
+			count:       ks.Count,
+			evalStart:   now,
+		}
+	}
+}
+
+// ExportState implements StatefulLimiter. Keys are prefixed with their
+// level; levels whose limiter is not a StatefulLimiter are left out.
+func (h *Hierarchical) ExportState() map[string]KeyState {
+	state := make(map[string]KeyState)
+	for level, limiter := range h.statefulLevels() {
+		for id, ks := range limiter.ExportState() {
+			state[string(level)+":"+id] = ks
+		}
+	}
+	return state
+}
+
+// ImportState implements StatefulLimiter
+func (h *Hierarchical) ImportState(state map[string]KeyState) {
+	byLevel := make(map[Level]map[string]KeyState)
+	for key, ks := range state {
+		level, id, ok := strings.Cut(key, ":")
+		if !ok {
+			continue
+		}
+		if byLevel[Level(level)] == nil {
+			byLevel[Level(level)] = make(map[string]KeyState)
+		}
+		byLevel[Level(level)][id] = ks
+	}
+	for level, limiter := range h.statefulLevels() {
+		if levelState := byLevel[level]; len(levelState) > 0 {
+			limiter.ImportState(levelState)
+		}
+	}
+}
+
+// statefulLevels returns the configured levels that can export state
+func (h *Hierarchical) statefulLevels() map[Level]StatefulLimiter {
+	levels := make(map[Level]StatefulLimiter)
+	for level, limiter := range map[Level]Limiter{
+		LevelGlobal: h.config.Global,
+		LevelClient: h.config.Client,
+		LevelToken:  h.config.Token,
+	} {
+		if sl, ok := limiter.(StatefulLimiter); ok {
+			levels[level] = sl
+		}
+	}
+	return levels
+}
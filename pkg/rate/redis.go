@@ -126,6 +126,11 @@ func (rl *RedisLimiter) getKey(id string) string {
 	return fmt.Sprintf("%s:%s", rl.keyPrefix, id)
 }
 
+// Ping checks the Redis connection, for health probes
+func (rl *RedisLimiter) Ping(ctx context.Context) error {
+	return rl.client.Ping(ctx).Err()
+}
+
 // Close closes the Redis connection
 func (rl *RedisLimiter) Close() error {
 	return rl.client.Close()
@@ -236,6 +241,11 @@ func (rcl *RedisClusterLimiter) getKey(id string) string {
 	return fmt.Sprintf("%s:%s", rcl.keyPrefix, id)
 }
 
+// Ping checks the Redis Cluster connection, for health probes
+func (rcl *RedisClusterLimiter) Ping(ctx context.Context) error {
+	return rcl.cluster.Ping(ctx).Err()
+}
+
 // Close closes the Redis Cluster connection
 func (rcl *RedisClusterLimiter) Close() error {
 	return rcl.cluster.Close()
@@ -41,7 +41,7 @@ func (sw *SlidingWindow) Allow(_ context.Context, id string) error {
 	info := infoIface.(*windowInfo)
 
 	// Remove timestamps outside the window
-	validIdx := 0
+	validIdx := len(info.timestamps)
 	for i, ts := range info.timestamps {
 		if ts.After(cutoff) {
 			validIdx = i
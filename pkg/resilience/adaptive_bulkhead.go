@@ -0,0 +1,233 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// AdaptiveBulkheadConfig configures an AdaptiveBulkhead
+type AdaptiveBulkheadConfig struct {
+	// InitialLimit is the starting concurrency limit (default: 20)
+	InitialLimit int
+
+	// MinLimit is the floor the limit is never cut below (default: 1)
+	MinLimit int
+
+	// MaxLimit is the ceiling the limit never grows above (default: 200)
+	MaxLimit int
+
+	// MaxWaitTime is how long a call waits for a free slot (0 fails at
+	// once when all are taken)
+	MaxWaitTime time.Duration
+
+	// Window is how often the limit is recomputed from the latencies
+	// observed since (default: 1s)
+	Window time.Duration
+
+	// MinSamples is how many calls a window needs to update the limit
+	// (default: 10)
+	MinSamples int
+
+	// Tolerance is how far recent latency may exceed the baseline before
+	// the limit shrinks, as a ratio (default: 1.5)
+	Tolerance float64
+
+	// Smoothing weighs each new limit against the current one, between 0
+	// and 1 (default: 0.2)
+	Smoothing float64
+
+	// OnLimitChange is called whenever the limit changes. It runs while the
+	// bulkhead is locked and must not call back into it.
+	OnLimitChange func(oldLimit, newLimit int)
+}
+
+// AdaptiveBulkhead limits how many calls run at once, like Bulkhead, but
+// finds the limit itself from observed latency instead of a fixed
+// MaxConcurrent.
+//
+// By Little's law, the calls a backend holds equal its throughput times
+// its latency, so once a backend is saturated, more concurrency only adds
+// queueing delay. The bulkhead takes the lowest mean latency of a window as
+// the backend's latency without queueing, and compares each window against
+// it: while latency stays
+// within Tolerance of the baseline the limit grows by its square root,
+// a small queue allowance; as latency rises above it the limit shrinks in
+// proportion.
+type AdaptiveBulkhead struct {
+	config AdaptiveBulkheadConfig
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	waiters  []chan struct{}
+
+	windowStart time.Time
+	samples     int
+	latencySum  time.Duration
+	maxInflight int
+	baseline    float64
+}
+
+var _ Executor = (*AdaptiveBulkhead)(nil)
+
+// NewAdaptiveBulkhead creates an adaptive bulkhead
+func NewAdaptiveBulkhead(config AdaptiveBulkheadConfig) (*AdaptiveBulkhead, error) {
+	if config.MinLimit <= 0 {
+		config.MinLimit = 1
+	}
+	if config.MaxLimit <= 0 {
+		config.MaxLimit = 200
+	}
+	if config.InitialLimit <= 0 {
+		config.InitialLimit = min(20, config.MaxLimit)
+	}
+	if config.MinLimit > config.InitialLimit || config.InitialLimit > config.MaxLimit {
+		return nil, errors.New("adaptive bulkhead needs MinLimit <= InitialLimit <= MaxLimit")
+	}
+	if config.Window <= 0 {
+		config.Window = time.Second
+	}
+	if config.MinSamples <= 0 {
+		config.MinSamples = 10
+	}
+	if config.Tolerance < 1 {
+		config.Tolerance = 1.5
+	}
+	if config.Smoothing <= 0 || config.Smoothing > 1 {
+		config.Smoothing = 0.2
+	}
+	return &AdaptiveBulkhead{
+		config:      config,
+		limit:       float64(config.InitialLimit),
+		windowStart: time.Now(),
+	}, nil
+}
+
+// Execute runs fn in a free slot, or fails with ErrBulkheadFull when none
+// frees up within MaxWaitTime. The latency of the call feeds the limit;
+// calls cancelled by the caller are not counted.
+func (b *AdaptiveBulkhead) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := b.acquire(ctx); err != nil {
+		return err
+	}
+	start := time.Now()
+	err := fn(ctx)
+	b.release(time.Since(start), errors.Is(err, context.Canceled))
+	return err
+}
+
+// Limit returns the current concurrency limit
+func (b *AdaptiveBulkhead) Limit() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return int(b.limit)
+}
+
+// InFlight returns the number of calls running
+func (b *AdaptiveBulkhead) InFlight() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inflight
+}
+
+func (b *AdaptiveBulkhead) acquire(ctx context.Context) error {
+	b.mu.Lock()
+	if b.inflight < int(b.limit) {
+		b.take()
+		b.mu.Unlock()
+		return nil
+	}
+	if b.config.MaxWaitTime <= 0 {
+		b.mu.Unlock()
+		return ErrBulkheadFull
+	}
+	granted := make(chan struct{})
+	b.waiters = append(b.waiters, granted)
+	b.mu.Unlock()
+
+	timer := time.NewTimer(b.config.MaxWaitTime)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-granted:
+		return nil
+	case <-timer.C:
+		err = ErrBulkheadFull
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, w := range b.waiters {
+		if w == granted {
+			b.waiters = append(b.waiters[:i], b.waiters[i+1:]...)
+			return err
+		}
+	}
+	// a slot was granted while giving up; hand it on
+	b.inflight--
+	b.grant()
+	return err
+}
+
+// take claims a slot; b.mu must be held
+func (b *AdaptiveBulkhead) take() {
+	b.inflight++
+	b.maxInflight = max(b.maxInflight, b.inflight)
+}
+
+// grant hands free slots to waiters in arrival order; b.mu must be held
+func (b *AdaptiveBulkhead) grant() {
+	for len(b.waiters) > 0 && b.inflight < int(b.limit) {
+		close(b.waiters[0])
+		b.waiters = b.waiters[1:]
+		b.take()
+	}
+}
+
+func (b *AdaptiveBulkhead) release(latency time.Duration, cancelled bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.inflight--
+	if !cancelled {
+		b.samples++
+		b.latencySum += latency
+	}
+	if time.Since(b.windowStart) >= b.config.Window && b.samples >= b.config.MinSamples {
+		b.adjust()
+	}
+	b.grant()
+}
+
+// adjust updates the limit from the window's latencies; b.mu must be held
+func (b *AdaptiveBulkhead) adjust() {
+	recent := float64(b.latencySum) / float64(b.samples)
+	if b.baseline == 0 || recent < b.baseline || b.limit <= float64(b.config.MinLimit) {
+		// the lowest latency seen is the backend without queueing; at the
+		// floor there is no queueing left to blame, so the backend itself
+		// has slowed and the baseline moves with it
+		b.baseline = recent
+	}
+
+	gradient := math.Max(0.5, math.Min(1, b.config.Tolerance*b.baseline/recent))
+	target := b.limit*gradient + math.Sqrt(b.limit)
+	if gradient == 1 && b.maxInflight < int(b.limit)/2 {
+		// demand never came near the limit, so it says nothing about
+		// whether more concurrency would hurt
+		target = b.limit
+	}
+	limit := b.limit*(1-b.config.Smoothing) + target*b.config.Smoothing
+	limit = math.Max(float64(b.config.MinLimit), math.Min(float64(b.config.MaxLimit), limit))
+
+	old := int(b.limit)
+	b.limit = limit
+	if int(limit) != old && b.config.OnLimitChange != nil {
+		b.config.OnLimitChange(old, int(limit))
+	}
+	b.windowStart, b.samples, b.latencySum, b.maxInflight = time.Now(), 0, 0, b.inflight
+}
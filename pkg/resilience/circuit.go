@@ -5,6 +5,8 @@ import (
 	"errors"
 	"sync"
 	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/metrics"
 )
 
 // ErrCircuitOpen is returned when the circuit breaker is open and requests are not allowed
@@ -24,37 +26,127 @@ const (
 	StateHalfOpen
 )
 
+// Outcome classifies the result of an operation for a circuit breaker
+type Outcome int
+
+const (
+	// OutcomeSuccess counts towards closing the circuit
+	OutcomeSuccess Outcome = iota
+
+	// OutcomeFailure counts towards opening the circuit
+	OutcomeFailure
+
+	// OutcomeIgnored is not counted at all, such as a caller giving up
+	OutcomeIgnored
+)
+
+// DefaultClassify treats nil as success, context.Canceled as ignored, since
+// the caller rather than the service ended the call, and any other error
+// as failure
+func DefaultClassify(err error) Outcome {
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.Is(err, context.Canceled):
+		return OutcomeIgnored
+	default:
+		return OutcomeFailure
+	}
+}
+
+// circuitBuckets is the number of buckets of the failure rate window
+const circuitBuckets = 10
+
 // CircuitConfig configures a circuit breaker
 type CircuitConfig struct {
 	// Name identifies the circuit breaker
 	Name string
 
-	// MaxFailures is how many failures trigger opening the circuit
+	// MaxFailures is how many failures trigger opening the circuit. 0
+	// disables the count when FailureRateThreshold is set, so the circuit
+	// only opens on the failure rate (default: 5)
 	MaxFailures int
 
 	// Timeout is how long to wait before trying again
 	Timeout time.Duration
 
-	// Interval is how often to reset failure counts
+	// Interval is how often to reset failure counts. With 0 they are never
+	// reset while closed, so MaxFailures counts every failure since the
+	// circuit last closed (optional)
 	Interval time.Duration
 
+	// FailureRateThreshold opens the circuit when the share of failures in
+	// FailureRateWindow reaches it, between 0 and 1 (0 disables the rate)
+	FailureRateThreshold float64
+
+	// FailureRateWindow is the sliding window the failure rate is measured
+	// over (default: 10s)
+	FailureRateWindow time.Duration
+
+	// MinRequests is how many calls the window needs before the failure
+	// rate applies (default: 10)
+	MinRequests int
+
+	// HalfOpenProbes is how many trial calls are let through while half
+	// open; all must succeed to close the circuit (default: 1)
+	HalfOpenProbes int
+
+	// Classify maps operation errors to outcomes (default: DefaultClassify)
+	Classify func(error) Outcome
+
 	// OnStateChange is called when circuit state changes
 	OnStateChange func(from, to CircuitState)
+
+	// Metrics records the circuit state under Name when set
+	Metrics *metrics.Collector
+}
+
+// outcomeBucket counts the outcomes of one slice of the failure rate window
+type outcomeBucket struct {
+	start     int64
+	successes int
+	failures  int
 }
 
-// CircuitBreaker implements the circuit breaker pattern
+// CircuitBreaker implements the circuit breaker pattern. The circuit opens
+// after MaxFailures failures within Interval, or when the failure rate over
+// a sliding window reaches FailureRateThreshold. After Timeout it lets
+// HalfOpenProbes trial calls through and closes once they all succeed.
 type CircuitBreaker struct {
 	config CircuitConfig
 
-	mu          sync.RWMutex
-	state       CircuitState
-	failures    int
-	lastFailure time.Time
-	lastReset   time.Time
+	mu        sync.RWMutex
+	state     CircuitState
+	failures  int
+	lastReset time.Time
+	openedAt  time.Time
+
+	buckets        [circuitBuckets]outcomeBucket
+	probes         int
+	probeSuccesses int
 }
 
 // NewCircuitBreaker creates a new circuit breaker
 func NewCircuitBreaker(config CircuitConfig) *CircuitBreaker {
+	if config.MaxFailures <= 0 && config.FailureRateThreshold <= 0 {
+		// without either threshold the circuit could never open
+		config.MaxFailures = 5
+	}
+	if config.FailureRateWindow <= 0 {
+		config.FailureRateWindow = 10 * time.Second
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = 10
+	}
+	if config.HalfOpenProbes <= 0 {
+		config.HalfOpenProbes = 1
+	}
+	if config.Classify == nil {
+		config.Classify = DefaultClassify
+	}
+	if config.Metrics != nil {
+		config.Metrics.SetCircuitState(config.Name, int(StateClosed))
+	}
 	return &CircuitBreaker{
 		config:    config,
 		state:     StateClosed,
@@ -81,6 +173,14 @@ func (cb *CircuitBreaker) State() CircuitState {
 	return cb.state
 }
 
+// FailureRate returns the share of failures in the sliding window, and the
+// number of calls it is based on
+func (cb *CircuitBreaker) FailureRate() (float64, int) {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+	return cb.failureRate(time.Now())
+}
+
 func (cb *CircuitBreaker) beforeExecute() error {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
@@ -91,9 +191,14 @@ func (cb *CircuitBreaker) beforeExecute() error {
 			return ErrCircuitOpen
 		}
 		cb.transitionTo(StateHalfOpen)
+		cb.probes++
 		return nil
 
 	case StateHalfOpen:
+		if cb.probes >= cb.config.HalfOpenProbes {
+			return ErrCircuitOpen
+		}
+		cb.probes++
 		return nil
 
 	default:
@@ -105,16 +210,28 @@ func (cb *CircuitBreaker) afterExecute(err error) {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
+	outcome := cb.config.Classify(err)
 	switch cb.state {
 	case StateHalfOpen:
-		if err != nil {
+		switch outcome {
+		case OutcomeFailure:
 			cb.transitionTo(StateOpen)
-		} else {
-			cb.transitionTo(StateClosed)
+		case OutcomeSuccess:
+			cb.probeSuccesses++
+			if cb.probeSuccesses >= cb.config.HalfOpenProbes {
+				cb.transitionTo(StateClosed)
+			}
+		default:
+			// the probe told nothing; free its slot for another
+			cb.probes--
 		}
 
 	case StateClosed:
-		if err != nil {
+		if outcome == OutcomeIgnored {
+			return
+		}
+		cb.recordOutcome(time.Now(), outcome == OutcomeFailure)
+		if outcome == OutcomeFailure {
 			cb.recordFailure()
 		}
 
@@ -123,23 +240,60 @@ func (cb *CircuitBreaker) afterExecute(err error) {
 }
 
 func (cb *CircuitBreaker) shouldAttemptReset() bool {
-	return time.Since(cb.lastFailure) > cb.config.Timeout
+	return time.Since(cb.openedAt) > cb.config.Timeout
 }
 
 func (cb *CircuitBreaker) recordFailure() {
 	// Reset failure count if interval has elapsed
-	if time.Since(cb.lastReset) > cb.config.Interval {
+	if cb.config.Interval > 0 && time.Since(cb.lastReset) > cb.config.Interval {
 		cb.failures = 0
 		cb.lastReset = time.Now()
 	}
 
 	cb.failures++
-	cb.lastFailure = time.Now()
+}
+
+// recordOutcome counts a call in the bucket of the sliding window it falls in
+func (cb *CircuitBreaker) recordOutcome(now time.Time, failed bool) {
+	width := max(int64(cb.config.FailureRateWindow/circuitBuckets), 1)
+	start := now.UnixNano() / width * width
+	b := &cb.buckets[(start/width)%circuitBuckets]
+	if b.start != start {
+		*b = outcomeBucket{start: start}
+	}
+	if failed {
+		b.failures++
+	} else {
+		b.successes++
+	}
+}
+
+// failureRate sums the buckets inside the sliding window
+func (cb *CircuitBreaker) failureRate(now time.Time) (float64, int) {
+	oldest := now.Add(-cb.config.FailureRateWindow).UnixNano()
+	var calls, failures int
+	for _, b := range cb.buckets {
+		if b.start > oldest {
+			calls += b.successes + b.failures
+			failures += b.failures
+		}
+	}
+	if calls == 0 {
+		return 0, 0
+	}
+	return float64(failures) / float64(calls), calls
 }
 
 func (cb *CircuitBreaker) checkFailureThreshold() {
-	if cb.failures >= cb.config.MaxFailures {
+	if cb.config.MaxFailures > 0 && cb.failures >= cb.config.MaxFailures {
 		cb.transitionTo(StateOpen)
+		return
+	}
+	if cb.config.FailureRateThreshold > 0 {
+		rate, calls := cb.failureRate(time.Now())
+		if calls >= cb.config.MinRequests && rate >= cb.config.FailureRateThreshold {
+			cb.transitionTo(StateOpen)
+		}
 	}
 }
 
@@ -150,6 +304,9 @@ func (cb *CircuitBreaker) transitionTo(newState CircuitState) {
 
 	oldState := cb.state
 	cb.state = newState
+	if cb.config.Metrics != nil {
+		cb.config.Metrics.SetCircuitState(cb.config.Name, int(newState))
+	}
 
 	if cb.config.OnStateChange != nil {
 		cb.config.OnStateChange(oldState, newState)
@@ -158,4 +315,11 @@ func (cb *CircuitBreaker) transitionTo(newState CircuitState) {
 	// Reset counters on state change
 	cb.failures = 0
 	cb.lastReset = time.Now()
+	cb.probes, cb.probeSuccesses = 0, 0
+	switch newState {
+	case StateOpen:
+		cb.openedAt = time.Now()
+	case StateClosed:
+		cb.buckets = [circuitBuckets]outcomeBucket{}
+	}
 }
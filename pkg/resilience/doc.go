@@ -28,6 +28,9 @@ Key Patterns:
     JitterFactor:  0.1,
     })
 
+    A delay a throttled server asks for through Retry-After or
+    RateLimit-Reset is waited out, up to MaxRetryAfter.
+
  3. Timeout:
     Ensures operations complete within expected time bounds.
 
@@ -43,6 +46,9 @@ Key Patterns:
     MaxWaitTime:   100 * time.Millisecond,
     })
 
+    AdaptiveBulkhead finds the limit from observed latency instead, cutting
+    it as queueing pushes latency above the backend's unloaded latency.
+
 Pattern Composition:
 
 Patterns can be combined for comprehensive resilience:
@@ -58,6 +64,24 @@ Patterns can be combined for comprehensive resilience:
 		return callService()
 	})
 
+Typed Results:
+
+ExecuteT runs a function returning a value under any Executor:
+
+	user, err := resilience.ExecuteT(ctx, combined, func(ctx context.Context) (*User, error) {
+		return client.GetUser(ctx, id)
+	})
+
+Fallback:
+
+Fallback chains stages of degradation and reports the one that served:
+
+	chain, err := resilience.NewFallback(resilience.FallbackConfig{Name: "token-validation"},
+		resilience.Stage[*Claims]{Name: "remote-introspection", Run: introspect},
+		resilience.Stage[*Claims]{Name: "local-cache", Run: validateCached},
+	)
+	claims, stage, err := chain.Execute(ctx)
+
 Monitoring:
 
 All patterns expose metrics for monitoring:
@@ -101,11 +125,15 @@ Best Practices:
 
 1. Circuit Breaker:
   - Set appropriate failure thresholds
+  - Prefer FailureRateThreshold for busy services, where a count trips too early
   - Configure reasonable timeout and reset intervals
+  - Use HalfOpenProbes > 1 so one lucky call does not close the circuit
+  - Classify errors the service is not to blame for as OutcomeIgnored
   - Monitor state changes
 
 2. Retry Strategy:
   - Use exponential backoff
+  - Honor server retry hints rather than retrying early
   - Set appropriate max attempts
   - Consider operation idempotency
 
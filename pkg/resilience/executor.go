@@ -0,0 +1,65 @@
+package resilience
+
+import (
+	"context"
+	"sync"
+)
+
+// Executor runs an operation under a resilience pattern
+type Executor interface {
+	Execute(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+var (
+	_ Executor = (*CircuitBreaker)(nil)
+	_ Executor = (*Retry)(nil)
+	_ Executor = (*Timeout)(nil)
+	_ Executor = (*Bulkhead)(nil)
+	_ Executor = (*Combined)(nil)
+)
+
+// ExecutorFunc adapts a function to the Executor interface
+type ExecutorFunc func(ctx context.Context, fn func(ctx context.Context) error) error
+
+// Execute implements Executor
+func (f ExecutorFunc) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	return f(ctx, fn)
+}
+
+// ExecuteT runs fn under an executor and returns its result, so callers
+// need not capture it in a closure:
+//
+//	user, err := resilience.ExecuteT(ctx, breaker, func(ctx context.Context) (*User, error) {
+//	    return client.GetUser(ctx, id)
+//	})
+//
+// The result is that of a call that succeeded, such as the last attempt of
+// a Retry; when the executor fails, it is the zero value. Calls abandoned
+// by a Timeout cannot change the result once ExecuteT has returned.
+func ExecuteT[T any](ctx context.Context, e Executor, fn func(ctx context.Context) (T, error)) (T, error) {
+	var (
+		mu       sync.Mutex
+		result   T
+		returned bool
+	)
+	err := e.Execute(ctx, func(ctx context.Context) error {
+		v, err := fn(ctx)
+		if err == nil {
+			mu.Lock()
+			if !returned {
+				result = v
+			}
+			mu.Unlock()
+		}
+		return err
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	returned = true
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
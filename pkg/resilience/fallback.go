@@ -0,0 +1,138 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Gimel-Foundation/gauth/pkg/metrics"
+)
+
+// ErrFallbackExhausted is returned when every stage of a fallback chain failed
+var ErrFallbackExhausted = errors.New("all fallback stages failed")
+
+// Stage is one way of serving a request in a Fallback chain
+type Stage[T any] struct {
+	// Name identifies the stage in results and metrics
+	Name string
+
+	// Run serves the request
+	Run func(ctx context.Context) (T, error)
+
+	// Executor runs the stage under a resilience pattern, such as a
+	// circuit breaker around a remote call (optional)
+	Executor Executor
+}
+
+// FallbackConfig configures a Fallback chain
+type FallbackConfig struct {
+	// Name labels the chain in errors and metrics (default: "fallback")
+	Name string
+
+	// ShouldFallback reports whether an error of a stage moves on to the
+	// next one (default: every error except context.Canceled)
+	ShouldFallback func(error) bool
+
+	// Metrics records the result of every stage when set
+	Metrics *metrics.Collector
+
+	// OnFallback is called when a stage failed and the next one is tried
+	// (optional)
+	OnFallback func(stage string, err error)
+}
+
+// Fallback serves requests from the first stage of a degradation chain
+// that succeeds, such as remote token introspection falling back to
+// validation against a local cache. Each request reports the stage that
+// served it, so callers can tell degraded answers apart.
+type Fallback[T any] struct {
+	config FallbackConfig
+	stages []Stage[T]
+
+	mu     sync.Mutex
+	served map[string]uint64
+}
+
+// NewFallback creates a fallback chain. The first stage is the primary
+// one; the others are tried in order when it fails.
+func NewFallback[T any](config FallbackConfig, stages ...Stage[T]) (*Fallback[T], error) {
+	if len(stages) == 0 {
+		return nil, errors.New("fallback chain needs at least one stage")
+	}
+	seen := make(map[string]bool, len(stages))
+	for i, s := range stages {
+		if s.Name == "" || s.Run == nil {
+			return nil, fmt.Errorf("fallback stage %d needs a name and a Run function", i)
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("duplicate fallback stage %q", s.Name)
+		}
+		seen[s.Name] = true
+	}
+	if config.Name == "" {
+		config.Name = "fallback"
+	}
+	if config.ShouldFallback == nil {
+		config.ShouldFallback = func(err error) bool { return !errors.Is(err, context.Canceled) }
+	}
+	return &Fallback[T]{config: config, stages: stages, served: make(map[string]uint64)}, nil
+}
+
+// Execute serves a request and returns the value with the name of the
+// stage that produced it. An error that ShouldFallback rejects is returned
+// as it is; when every stage fails, the error wraps ErrFallbackExhausted
+// and each stage's error.
+func (f *Fallback[T]) Execute(ctx context.Context) (T, string, error) {
+	var zero T
+	var errs []error
+	for i, stage := range f.stages {
+		if i > 0 {
+			if err := ctx.Err(); err != nil {
+				return zero, "", err
+			}
+		}
+
+		v, err := f.run(ctx, stage)
+		if err == nil {
+			f.record(stage.Name, "served")
+			f.mu.Lock()
+			f.served[stage.Name]++
+			f.mu.Unlock()
+			return v, stage.Name, nil
+		}
+		f.record(stage.Name, "failed")
+		if !f.config.ShouldFallback(err) {
+			return zero, stage.Name, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", stage.Name, err))
+		if i < len(f.stages)-1 && f.config.OnFallback != nil {
+			f.config.OnFallback(stage.Name, err)
+		}
+	}
+	return zero, "", fmt.Errorf("%s: %w: %w", f.config.Name, ErrFallbackExhausted, errors.Join(errs...))
+}
+
+// Served returns how many requests each stage has served
+func (f *Fallback[T]) Served() map[string]uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	served := make(map[string]uint64, len(f.served))
+	for name, n := range f.served {
+		served[name] = n
+	}
+	return served
+}
+
+func (f *Fallback[T]) run(ctx context.Context, stage Stage[T]) (T, error) {
+	if stage.Executor != nil {
+		return ExecuteT(ctx, stage.Executor, stage.Run)
+	}
+	return stage.Run(ctx)
+}
+
+func (f *Fallback[T]) record(stage, result string) {
+	if f.config.Metrics != nil {
+		f.config.Metrics.RecordFallback(f.config.Name, stage, result)
+	}
+}
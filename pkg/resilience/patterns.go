@@ -2,91 +2,244 @@ package resilience
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"sync"
 	"time"
+
+	autherrors "github.com/Gimel-Foundation/gauth/pkg/errors"
 )
 
-// --- BEGIN STUBS FOR EXAMPLES AND DOCS ---
-// RetryConfig is a stub for retry configuration
+// RetryConfig configures retries with exponential backoff
 type RetryConfig struct {
-	MaxAttempts  int
+	// MaxAttempts is the number of tries, the first included (default: 3)
+	MaxAttempts int
+
+	// InitialDelay is the delay before the second try (default: 100ms)
 	InitialDelay time.Duration
-	MaxDelay     time.Duration
-	Multiplier   float64
+
+	// MaxDelay caps the delay between tries (default: 10s)
+	MaxDelay time.Duration
+
+	// Multiplier grows the delay after each try (default: 2)
+	Multiplier float64
+
+	// JitterFactor randomizes each delay by up to this fraction (optional)
+	JitterFactor float64
+
+	// Retryable reports whether an error is worth another try
+	// (default: every error)
+	Retryable func(error) bool
+
+	// RetryAfter returns the delay the server asked for in an error, such
+	// as a throttled token endpoint's Retry-After (default: DefaultRetryAfter)
+	RetryAfter func(error) (time.Duration, bool)
+
+	// MaxRetryAfter is the longest server-requested delay waited for; a
+	// longer one ends the retries (default: MaxDelay)
+	MaxRetryAfter time.Duration
+}
+
+// DefaultRetryAfter reads the retry delay of a wrapped *errors.Error, which
+// NewHTTPError fills from the Retry-After or RateLimit-Reset headers of 429
+// and 5xx responses
+func DefaultRetryAfter(err error) (time.Duration, bool) {
+	var authErr *autherrors.Error
+	if errors.As(err, &authErr) && authErr.RetryAfter > 0 {
+		return authErr.RetryAfter, true
+	}
+	return 0, false
 }
 
-// TimeoutConfig is a stub for timeout configuration
+// TimeoutConfig configures a timeout
 type TimeoutConfig struct {
+	// Timeout bounds each call (0 disables it)
 	Timeout time.Duration
 }
 
-// BulkheadConfig is a stub for bulkhead configuration
+// BulkheadConfig configures a bulkhead
 type BulkheadConfig struct {
+	// MaxConcurrent is how many calls may run at once (default: 10)
 	MaxConcurrent int
-	MaxWaitTime   time.Duration
+
+	// MaxWaitTime is how long a call waits for a free slot (0 fails at
+	// once when all are taken)
+	MaxWaitTime time.Duration
 }
 
-// ErrBulkheadFull is a sentinel error for bulkhead full
+// ErrBulkheadFull is returned when a bulkhead has no free slot in time
 var ErrBulkheadFull = fmt.Errorf("bulkhead capacity exceeded")
 
-// Retry is a stub for retry pattern
+// Retry retries failed calls with exponential backoff
 type Retry struct {
 	config RetryConfig
 }
 
+// NewRetry creates a retry pattern
 func NewRetry(config RetryConfig) *Retry {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+	if config.InitialDelay <= 0 {
+		config.InitialDelay = 100 * time.Millisecond
+	}
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = 10 * time.Second
+	}
+	if config.Multiplier < 1 {
+		config.Multiplier = 2
+	}
+	if config.RetryAfter == nil {
+		config.RetryAfter = DefaultRetryAfter
+	}
+	if config.MaxRetryAfter <= 0 {
+		config.MaxRetryAfter = config.MaxDelay
+	}
 	return &Retry{config: config}
 }
 
+// Execute runs fn until it succeeds, returns an error that is not
+// retryable, or runs out of attempts, and returns the last error. It stops
+// waiting when ctx is done.
+//
+// When an error carries a server-requested delay, the next try waits at
+// least that long, so retries do not add load to a throttled endpoint. A
+// delay over MaxRetryAfter returns the error at once instead of retrying
+// early.
 func (r *Retry) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
-	// Minimal stub: just call fn once
-	return fn(ctx)
+	delay := r.config.InitialDelay
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= r.config.MaxAttempts ||
+			(r.config.Retryable != nil && !r.config.Retryable(err)) {
+			return err
+		}
+
+		wait := delay
+		if r.config.JitterFactor > 0 {
+			wait += time.Duration((rand.Float64()*2 - 1) * r.config.JitterFactor * float64(delay))
+		}
+		if after, ok := r.config.RetryAfter(err); ok {
+			if after > r.config.MaxRetryAfter {
+				return err
+			}
+			if after > wait {
+				// jitter only lengthens a requested delay, so clients
+				// throttled together do not all return at once
+				wait = after
+				if r.config.JitterFactor > 0 {
+					wait += time.Duration(rand.Float64() * r.config.JitterFactor * float64(after))
+				}
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay = min(time.Duration(float64(delay)*r.config.Multiplier), r.config.MaxDelay)
+	}
 }
 
-// Timeout is a stub for timeout pattern
+// Timeout bounds how long a call may take
 type Timeout struct {
 	config TimeoutConfig
 }
 
+// NewTimeout creates a timeout pattern
 func NewTimeout(config TimeoutConfig) *Timeout {
 	return &Timeout{config: config}
 }
 
+// Execute runs fn with a context that ends after the timeout. A call that
+// ignores its context is abandoned when the time is up and
+// context.DeadlineExceeded is returned; it keeps running in the background.
 func (t *Timeout) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
-	// Minimal stub: just call fn once
-	return fn(ctx)
+	if t.config.Timeout <= 0 {
+		return fn(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, t.config.Timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- fn(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-// Bulkhead is a stub for bulkhead pattern
+// Bulkhead limits how many calls run at once
 type Bulkhead struct {
 	config BulkheadConfig
+	slots  chan struct{}
 }
 
+// NewBulkhead creates a bulkhead pattern
 func NewBulkhead(config BulkheadConfig) *Bulkhead {
-	return &Bulkhead{config: config}
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = 10
+	}
+	return &Bulkhead{config: config, slots: make(chan struct{}, config.MaxConcurrent)}
 }
 
+// Execute runs fn in a free slot, or fails with ErrBulkheadFull when none
+// frees up within MaxWaitTime
 func (b *Bulkhead) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
-	// Minimal stub: just call fn once
+	select {
+	case b.slots <- struct{}{}:
+	default:
+		if b.config.MaxWaitTime <= 0 {
+			return ErrBulkheadFull
+		}
+		timer := time.NewTimer(b.config.MaxWaitTime)
+		defer timer.Stop()
+		select {
+		case b.slots <- struct{}{}:
+		case <-timer.C:
+			return ErrBulkheadFull
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	defer func() { <-b.slots }()
 	return fn(ctx)
 }
 
-// Combined is a stub for pattern composition
+// Combined applies several patterns to a call
 type Combined struct {
-	patterns []interface{}
+	executors []Executor
 }
 
+// Combine composes patterns into a pipeline; the first is the outermost,
+// so Combine(breaker, retry, timeout) retries timed-out calls inside the
+// circuit breaker. Patterns that are not Executors are ignored.
 func Combine(patterns ...interface{}) *Combined {
-	return &Combined{patterns: patterns}
+	c := &Combined{}
+	for _, p := range patterns {
+		if e, ok := p.(Executor); ok {
+			c.executors = append(c.executors, e)
+		}
+	}
+	return c
 }
 
+// Execute runs fn through every pattern of the pipeline
 func (c *Combined) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
-	// Minimal stub: just call fn once
+	for i := len(c.executors) - 1; i >= 0; i-- {
+		inner, e := fn, c.executors[i]
+		fn = func(ctx context.Context) error {
+			return e.Execute(ctx, inner)
+		}
+	}
 	return fn(ctx)
 }
 
-// --- END STUBS FOR EXAMPLES AND DOCS ---
 // Package resilience provides type-safe implementations of common resilience patterns
 // like circuit breakers, rate limiting, retry with backoff, and bulkheads.
 
@@ -205,6 +358,13 @@ func (p *Patterns) Execute(ctx context.Context, fn func() error) error {
 	return p.executeWithRetry(ctx, fn)
 }
 
+// Executor returns the patterns as an Executor, for Combine and ExecuteT
+func (p *Patterns) Executor() Executor {
+	return ExecutorFunc(func(ctx context.Context, fn func(ctx context.Context) error) error {
+		return p.Execute(ctx, func() error { return fn(ctx) })
+	})
+}
+
 func (p *Patterns) changeState(newState CircuitState) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
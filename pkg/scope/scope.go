@@ -0,0 +1,261 @@
+// Package scope implements the GAuth scope grammar shared by tokens and
+// authorization. A scope is a hierarchy of segments separated by colons,
+// most general first:
+//
+//	payments:invoices:read
+//
+// A "*" segment is a wildcard. At the end of a scope it matches one or more
+// further segments, so payments:* grants every scope below payments; inside
+// a scope it matches exactly one segment, so payments:*:read grants read on
+// every payments resource. A scope without wildcards grants only itself; in
+// particular payments does not grant payments:invoices.
+//
+// Sets are serialized canonically, as RFC 6749 space-delimited lists with
+// duplicates and scopes granted by a wildcard in the set removed, sorted:
+//
+//	s, _ := scope.ParseSet("payments:invoices:read,payments:*")
+//	s.String() // "payments:*"
+package scope
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidScope indicates a scope that does not follow the grammar
+var ErrInvalidScope = errors.New("invalid scope")
+
+const (
+	// Separator separates the segments of a scope
+	Separator = ":"
+
+	// Wildcard is the wildcard segment
+	Wildcard = "*"
+)
+
+// Scope is a single scope
+type Scope string
+
+// Parse validates a scope. Segments may not be empty and may only contain
+// the characters RFC 6749 allows in scope tokens.
+func Parse(s string) (Scope, error) {
+	if s == "" {
+		return "", fmt.Errorf("%w: empty scope", ErrInvalidScope)
+	}
+	for _, seg := range strings.Split(s, Separator) {
+		if seg == "" {
+			return "", fmt.Errorf("%w: %q has an empty segment", ErrInvalidScope, s)
+		}
+		if seg != Wildcard && strings.Contains(seg, Wildcard) {
+			return "", fmt.Errorf("%w: %q mixes a wildcard into a segment", ErrInvalidScope, s)
+		}
+		for _, c := range seg {
+			if c <= ' ' || c == '"' || c == '\\' || c == ',' || c > '~' {
+				return "", fmt.Errorf("%w: %q contains %q", ErrInvalidScope, s, c)
+			}
+		}
+	}
+	return Scope(s), nil
+}
+
+// Segments returns the segments of the scope
+func (s Scope) Segments() []string {
+	return strings.Split(string(s), Separator)
+}
+
+// Parent returns the scope one level up, or "" for a top-level scope
+func (s Scope) Parent() Scope {
+	i := strings.LastIndex(string(s), Separator)
+	if i < 0 {
+		return ""
+	}
+	return s[:i]
+}
+
+// IsWildcard reports whether the scope contains a wildcard segment
+func (s Scope) IsWildcard() bool {
+	for _, seg := range s.Segments() {
+		if seg == Wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers reports whether s grants everything other grants. other may itself
+// contain wildcards.
+func (s Scope) Covers(other Scope) bool {
+	p, q := s.Segments(), other.Segments()
+	for i := range p {
+		if i == len(p)-1 && p[i] == Wildcard {
+			return i < len(q)
+		}
+		if i >= len(q) {
+			return false
+		}
+		if i == len(q)-1 && q[i] == Wildcard && len(q) < len(p) {
+			return false
+		}
+		if p[i] != Wildcard && p[i] != q[i] {
+			return false
+		}
+	}
+	return len(p) == len(q)
+}
+
+// meet returns the scope granting exactly what both a and b grant, if
+// anything
+func meet(a, b Scope) (Scope, bool) {
+	p, q := a.Segments(), b.Segments()
+	out := make([]string, 0, max(len(p), len(q)))
+	for i := 0; ; i++ {
+		switch {
+		case i < len(p) && i == len(p)-1 && p[i] == Wildcard && i < len(q):
+			return Scope(strings.Join(append(out, q[i:]...), Separator)), true
+		case i < len(q) && i == len(q)-1 && q[i] == Wildcard && i < len(p):
+			return Scope(strings.Join(append(out, p[i:]...), Separator)), true
+		case i == len(p) && i == len(q):
+			return Scope(strings.Join(out, Separator)), true
+		case i == len(p) || i == len(q):
+			return "", false
+		case p[i] == Wildcard:
+			out = append(out, q[i])
+		case q[i] == Wildcard || p[i] == q[i]:
+			out = append(out, p[i])
+		default:
+			return "", false
+		}
+	}
+}
+
+// Set is a set of scopes
+type Set []Scope
+
+// NewSet validates scopes and returns their canonical set
+func NewSet(scopes ...string) (Set, error) {
+	set := make(Set, 0, len(scopes))
+	for _, s := range scopes {
+		sc, err := Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, sc)
+	}
+	return set.Canonical(), nil
+}
+
+// ParseSet parses a list of scopes separated by spaces or commas
+func ParseSet(s string) (Set, error) {
+	return NewSet(strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' })...)
+}
+
+// Allows reports whether any scope of the set grants want
+func (s Set) Allows(want Scope) bool {
+	for _, sc := range s {
+		if sc.Covers(want) {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers reports whether the set grants every scope of other
+func (s Set) Covers(other Set) bool {
+	for _, sc := range other {
+		if !s.Allows(sc) {
+			return false
+		}
+	}
+	return true
+}
+
+// Canonical returns the set sorted, without duplicates and without scopes
+// another scope of the set already grants
+func (s Set) Canonical() Set {
+	out := make(Set, 0, len(s))
+	for i, sc := range s {
+		redundant := false
+		for j, other := range s {
+			// Of two equal scopes, keep the first
+			if i != j && other.Covers(sc) && (sc != other || j < i) {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			out = append(out, sc)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// Intersect returns the canonical set of scopes granted by both a and b,
+// for narrowing a delegation to what the delegator holds
+func Intersect(a, b Set) Set {
+	var out Set
+	for _, x := range a {
+		for _, y := range b {
+			if m, ok := meet(x, y); ok {
+				out = append(out, m)
+			}
+		}
+	}
+	return out.Canonical()
+}
+
+// Strings returns the scopes as strings
+func (s Set) Strings() []string {
+	out := make([]string, len(s))
+	for i, sc := range s {
+		out[i] = string(sc)
+	}
+	return out
+}
+
+// String returns the canonical space-delimited form
+func (s Set) String() string {
+	return strings.Join(s.Canonical().Strings(), " ")
+}
+
+// MarshalText implements encoding.TextMarshaler with the canonical form
+func (s Set) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (s *Set) UnmarshalText(text []byte) error {
+	set, err := ParseSet(string(text))
+	if err != nil {
+		return err
+	}
+	*s = set
+	return nil
+}
+
+// Allows reports whether granted, a list of scope strings such as a token
+// carries, grants want. Invalid granted scopes grant nothing but themselves.
+func Allows(granted []string, want string) bool {
+	w := Scope(want)
+	for _, g := range granted {
+		if g == want {
+			return true
+		}
+		if sc, err := Parse(g); err == nil && sc.Covers(w) {
+			return true
+		}
+	}
+	return false
+}
+
+// Subset reports whether granted grants every scope of requested
+func Subset(granted, requested []string) bool {
+	for _, r := range requested {
+		if !Allows(granted, r) {
+			return false
+		}
+	}
+	return true
+}
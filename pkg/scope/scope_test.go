@@ -0,0 +1,91 @@
+package scope
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, s := range []string{"read", "payments:invoices:read", "payments:*", "*", "payments:*:read", "openid"} {
+		if _, err := Parse(s); err != nil {
+			t.Errorf("Parse(%q) error: %v", s, err)
+		}
+	}
+	for _, s := range []string{"", "payments::read", "payments:", "pay*", "a b", "a,b", `a"b`} {
+		if _, err := Parse(s); !errors.Is(err, ErrInvalidScope) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalidScope", s, err)
+		}
+	}
+	if p := Scope("payments:invoices:read").Parent(); p != "payments:invoices" {
+		t.Errorf("Parent() = %q", p)
+	}
+}
+
+func TestCovers(t *testing.T) {
+	tests := []struct {
+		granted, want string
+		covers        bool
+	}{
+		{"payments:invoices:read", "payments:invoices:read", true},
+		{"payments", "payments:invoices", false},
+		{"payments:*", "payments:invoices", true},
+		{"payments:*", "payments:invoices:read", true},
+		{"payments:*", "payments", false},
+		{"payments:*", "billing:invoices", false},
+		{"*", "payments:invoices:read", true},
+		{"payments:*:read", "payments:invoices:read", true},
+		{"payments:*:read", "payments:invoices:write", false},
+		{"payments:*:read", "payments:invoices:lines:read", false},
+		{"payments:*", "payments:*:read", true},
+		{"payments:*:read", "payments:*", false},
+		{"payments:invoices", "payments:*", false},
+		{"payments:*:*", "payments:*", false},
+	}
+	for _, tt := range tests {
+		if got := Scope(tt.granted).Covers(Scope(tt.want)); got != tt.covers {
+			t.Errorf("%q.Covers(%q) = %v, want %v", tt.granted, tt.want, got, tt.covers)
+		}
+	}
+}
+
+func TestSets(t *testing.T) {
+	s, err := ParseSet("payments:invoices:read, payments:* openid openid")
+	if err != nil {
+		t.Fatalf("ParseSet() error: %v", err)
+	}
+	if got := s.String(); got != "openid payments:*" {
+		t.Errorf("String() = %q", got)
+	}
+	if !s.Allows("payments:refunds:create") || s.Allows("billing:read") {
+		t.Error("Allows() wrong")
+	}
+
+	held, _ := NewSet("payments:*:read", "billing:*")
+	requested, _ := NewSet("payments:invoices:*", "billing:reports:read", "admin")
+	got := Intersect(held, requested).String()
+	if want := "billing:reports:read payments:invoices:read"; got != want {
+		t.Errorf("Intersect() = %q, want %q", got, want)
+	}
+	if !held.Covers(Intersect(held, requested)) {
+		t.Error("intersection widens the held scopes")
+	}
+
+	var doc struct {
+		Scope Set `json:"scope"`
+	}
+	if err := json.Unmarshal([]byte(`{"scope":"b a:* a:x"}`), &doc); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	out, _ := json.Marshal(doc)
+	if string(out) != `{"scope":"a:* b"}` {
+		t.Errorf("Marshal() = %s", out)
+	}
+
+	if !Allows([]string{"payments:*"}, "payments:invoices:read") || Allows([]string{"payments"}, "payments:invoices") {
+		t.Error("Allows() on strings wrong")
+	}
+	if !Subset([]string{"a:*", "b"}, []string{"a:x", "b"}) || Subset([]string{"a:*"}, []string{"a:x", "b"}) {
+		t.Error("Subset() wrong")
+	}
+}
@@ -0,0 +1,213 @@
+package token
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Attestation errors
+var (
+	// ErrAttestationInvalid indicates a forged, stale or unauthorized attestation
+	ErrAttestationInvalid = errors.New("invalid attestation")
+
+	// ErrAttestationRequired indicates a delegation lacks required attestations
+	ErrAttestationRequired = errors.New("attestation requirement not met")
+)
+
+// AttestationRequirement is the RFC115 attestation a delegation needs before
+// it is accepted
+type AttestationRequirement struct {
+	// Types lists the accepted attestation types, e.g. "notary", "witness"
+	// (empty = any type)
+	Types []string `json:"types,omitempty"`
+
+	// MinAttesters is the number of distinct attesters required (0 = none)
+	MinAttesters int `json:"min_attesters"`
+
+	// MaxAge rejects attestations older than this (0 = no limit)
+	MaxAge time.Duration `json:"max_age,omitempty"`
+}
+
+// Attester is a notary or witness trusted to attest delegations
+type Attester struct {
+	// PublicKey verifies the attester's signatures
+	PublicKey ed25519.PublicKey
+
+	// Types limits which attestation types the attester may sign
+	// (empty = any type)
+	Types []string
+}
+
+// attestationPayload is what an attester signs: the attestation bound to
+// the delegation link it witnesses
+type attestationPayload struct {
+	Type      string   `json:"type"`
+	Attester  string   `json:"attester"`
+	Date      string   `json:"date"`
+	Principal string   `json:"principal"`
+	Delegate  string   `json:"delegate"`
+	Scopes    []string `json:"scopes"`
+}
+
+// AttestationPayload returns the canonical bytes an attester signs for a
+// attestation of link
+func AttestationPayload(link DelegationLink, a Attestation) []byte {
+	scopes := append([]string(nil), link.Scopes...)
+	sort.Strings(scopes)
+	body, _ := json.Marshal(attestationPayload{
+		Type:      a.Type,
+		Attester:  a.AttesterID,
+		Date:      a.AttestationDate.UTC().Format(time.RFC3339Nano),
+		Principal: link.Principal,
+		Delegate:  link.Delegate,
+		Scopes:    scopes,
+	})
+	return body
+}
+
+// SignAttestation signs an attestation of link with the attester's key and
+// returns it with the signature in Evidence
+func SignAttestation(key ed25519.PrivateKey, link DelegationLink, a Attestation) Attestation {
+	if a.AttestationDate.IsZero() {
+		a.AttestationDate = time.Now()
+	}
+	a.Evidence = base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, AttestationPayload(link, a)))
+	return a
+}
+
+// AttestationVerifierConfig configures an AttestationVerifier
+type AttestationVerifierConfig struct {
+	// Attesters maps attester IDs to their keys
+	Attesters map[string]Attester
+
+	// Requirement applies to every delegation link
+	Requirement AttestationRequirement
+
+	// ScopeRequirements add requirements for links delegating a scope
+	ScopeRequirements map[string]AttestationRequirement
+
+	// ClockSkew tolerates attestations dated slightly in the future
+	// (default: 1m)
+	ClockSkew time.Duration
+
+	// Now returns the current time (default: time.Now)
+	Now func() time.Time
+}
+
+// AttestationVerifier checks the attestations on every link of a token's
+// delegation chain: each must carry a valid signature of a known attester
+// allowed to sign its type, and each link must collect enough distinct,
+// fresh attesters to meet its requirements. It implements IssuanceCheck.
+type AttestationVerifier struct {
+	config AttestationVerifierConfig
+}
+
+// NewAttestationVerifier creates an attestation verifier
+func NewAttestationVerifier(config AttestationVerifierConfig) (*AttestationVerifier, error) {
+	if len(config.Attesters) == 0 {
+		return nil, fmt.Errorf("%w: at least one attester is required", ErrInvalidConfig)
+	}
+	if config.ClockSkew <= 0 {
+		config.ClockSkew = time.Minute
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &AttestationVerifier{config: config}, nil
+}
+
+// Name implements IssuanceCheck
+func (v *AttestationVerifier) Name() string { return "delegation_attestation" }
+
+// Check implements IssuanceCheck
+func (v *AttestationVerifier) Check(_ context.Context, t *Token) error {
+	return v.Verify(t)
+}
+
+// Verify checks the attestations of the token's delegation chain. Tokens
+// without a chain pass.
+func (v *AttestationVerifier) Verify(t *Token) error {
+	chain := DelegationChainOf(t)
+	if chain == nil {
+		return nil
+	}
+	for i, link := range chain.Links {
+		if err := v.VerifyLink(link); err != nil {
+			return fmt.Errorf("link %d (%s → %s): %w", i, link.Principal, link.Delegate, err)
+		}
+	}
+	return nil
+}
+
+// VerifyLink checks the attestations of one delegation link
+func (v *AttestationVerifier) VerifyLink(link DelegationLink) error {
+	now := v.config.Now()
+	for _, a := range link.Attestations {
+		if err := v.verifyAttestation(link, a, now); err != nil {
+			return err
+		}
+	}
+	if err := v.meets(link, v.config.Requirement, now); err != nil {
+		return err
+	}
+	for _, scope := range link.Scopes {
+		if req, ok := v.config.ScopeRequirements[scope]; ok {
+			if err := v.meets(link, req, now); err != nil {
+				return fmt.Errorf("%w (scope %s)", err, scope)
+			}
+		}
+	}
+	return nil
+}
+
+func (v *AttestationVerifier) verifyAttestation(link DelegationLink, a Attestation, now time.Time) error {
+	attester, ok := v.config.Attesters[a.AttesterID]
+	if !ok {
+		return fmt.Errorf("%w: unknown attester %q", ErrAttestationInvalid, a.AttesterID)
+	}
+	if len(attester.Types) > 0 && !containsString(attester.Types, a.Type) {
+		return fmt.Errorf("%w: %s may not sign %s attestations", ErrAttestationInvalid, a.AttesterID, a.Type)
+	}
+	if a.AttestationDate.IsZero() || a.AttestationDate.After(now.Add(v.config.ClockSkew)) {
+		return fmt.Errorf("%w: attestation by %s has no valid date", ErrAttestationInvalid, a.AttesterID)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(a.Evidence)
+	if err != nil || !ed25519.Verify(attester.PublicKey, AttestationPayload(link, a), sig) {
+		return fmt.Errorf("%w: bad signature from %s", ErrAttestationInvalid, a.AttesterID)
+	}
+	return nil
+}
+
+// meets reports whether the link has enough distinct, fresh attesters of
+// an accepted type
+func (v *AttestationVerifier) meets(link DelegationLink, req AttestationRequirement, now time.Time) error {
+	if req.MinAttesters <= 0 {
+		return nil
+	}
+	attesters := make(map[string]bool)
+	stale := 0
+	for _, a := range link.Attestations {
+		if len(req.Types) > 0 && !containsString(req.Types, a.Type) {
+			continue
+		}
+		if req.MaxAge > 0 && now.Sub(a.AttestationDate) > req.MaxAge {
+			stale++
+			continue
+		}
+		attesters[a.AttesterID] = true
+	}
+	if len(attesters) < req.MinAttesters {
+		msg := fmt.Sprintf("%d of %d attesters", len(attesters), req.MinAttesters)
+		if stale > 0 {
+			msg += fmt.Sprintf(", %d attestations too old", stale)
+		}
+		return fmt.Errorf("%w: %s", ErrAttestationRequired, msg)
+	}
+	return nil
+}
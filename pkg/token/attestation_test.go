@@ -0,0 +1,118 @@
+package token
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAttestationVerifier(t *testing.T) {
+	now := time.Now()
+	notaryPub, notaryKey, _ := ed25519.GenerateKey(rand.Reader)
+	witnessPub, witnessKey, _ := ed25519.GenerateKey(rand.Reader)
+	v, err := NewAttestationVerifier(AttestationVerifierConfig{
+		Attesters: map[string]Attester{
+			"notary-1":  {PublicKey: notaryPub, Types: []string{"notary"}},
+			"witness-1": {PublicKey: witnessPub, Types: []string{"witness"}},
+		},
+		Requirement: AttestationRequirement{MinAttesters: 1, MaxAge: 30 * 24 * time.Hour},
+		ScopeRequirements: map[string]AttestationRequirement{
+			"sign_contract": {Types: []string{"notary", "witness"}, MinAttesters: 2},
+		},
+		Now: func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatalf("NewAttestationVerifier() error: %v", err)
+	}
+	link := DelegationLink{Principal: "owner-456", Delegate: "ai-agent-123", Scopes: []string{"sign_contract"}}
+	notarized := SignAttestation(notaryKey, link, Attestation{Type: "notary", AttesterID: "notary-1", AttestationDate: now.Add(-time.Hour)})
+	witnessed := SignAttestation(witnessKey, link, Attestation{Type: "witness", AttesterID: "witness-1", AttestationDate: now.Add(-time.Hour)})
+	tokenWith := func(as ...Attestation) *Token {
+		l := link
+		l.Attestations = as
+		return &Token{Subject: "ai-agent-123", Metadata: &Metadata{Delegation: &DelegationChain{Links: []DelegationLink{l}}}}
+	}
+
+	t.Run("Signed And Sufficient", func(t *testing.T) {
+		if err := v.Verify(tokenWith(notarized, witnessed)); err != nil {
+			t.Errorf("Expected attestations to verify, got %v", err)
+		}
+		if err := v.Verify(&Token{Subject: "user"}); err != nil {
+			t.Errorf("Expected token without delegation to pass, got %v", err)
+		}
+	})
+
+	t.Run("Too Few Attesters", func(t *testing.T) {
+		if err := v.Verify(tokenWith(notarized)); !errors.Is(err, ErrAttestationRequired) {
+			t.Errorf("Expected ErrAttestationRequired, got %v", err)
+		}
+		if err := v.Verify(tokenWith(notarized, notarized)); !errors.Is(err, ErrAttestationRequired) {
+			t.Errorf("Expected a repeated attester to count once, got %v", err)
+		}
+	})
+
+	t.Run("Forged Or Unauthorized", func(t *testing.T) {
+		tampered := witnessed
+		tampered.Evidence = notarized.Evidence
+		unknown := SignAttestation(witnessKey, link, Attestation{Type: "witness", AttesterID: "witness-2", AttestationDate: now})
+		wrongType := SignAttestation(witnessKey, link, Attestation{Type: "notary", AttesterID: "witness-1", AttestationDate: now})
+		future := SignAttestation(witnessKey, link, Attestation{Type: "witness", AttesterID: "witness-1", AttestationDate: now.Add(time.Hour)})
+		other := link
+		other.Delegate = "ai-agent-999"
+		replayed := SignAttestation(witnessKey, other, Attestation{Type: "witness", AttesterID: "witness-1", AttestationDate: now})
+		for name, a := range map[string]Attestation{
+			"Tampered": tampered, "Unknown": unknown, "Wrong Type": wrongType, "Future": future, "Replayed": replayed,
+		} {
+			if err := v.Verify(tokenWith(notarized, a)); !errors.Is(err, ErrAttestationInvalid) {
+				t.Errorf("%s: expected ErrAttestationInvalid, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		old := SignAttestation(witnessKey, link, Attestation{Type: "witness", AttesterID: "witness-1", AttestationDate: now.Add(-60 * 24 * time.Hour)})
+		if err := v.Verify(tokenWith(notarized, old)); err != nil {
+			t.Errorf("Expected the scope requirement without MaxAge to accept it, got %v", err)
+		}
+		oldNotary := SignAttestation(notaryKey, link, Attestation{Type: "notary", AttesterID: "notary-1", AttestationDate: now.Add(-60 * 24 * time.Hour)})
+		if err := v.Verify(tokenWith(oldNotary, old)); !errors.Is(err, ErrAttestationRequired) {
+			t.Errorf("Expected stale attestations to fail the base requirement, got %v", err)
+		}
+	})
+
+	t.Run("Delegator Refuses Unattested", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("GenerateKey() error: %v", err)
+		}
+		d, err := NewDelegator(DelegatorConfig{
+			Service:      NewService(Config{SigningKey: key, ValidityPeriod: time.Hour}, NewMemoryStore()),
+			Attestations: v,
+		})
+		if err != nil {
+			t.Fatalf("NewDelegator() error: %v", err)
+		}
+		opts := DelegationOptions{
+			Principal:   "owner-456",
+			Scope:       "read",
+			Attestation: &Attestation{Type: "notary", AttesterID: "notary-1", AttestationDate: now, Evidence: "forged"},
+			ValidUntil:  now.Add(time.Hour),
+		}
+		if _, err := d.Issue(context.Background(), "ai-agent-123", opts); !errors.Is(err, ErrAttestationInvalid) {
+			t.Errorf("Expected forged attestation to be refused, got %v", err)
+		}
+		signed := SignAttestation(notaryKey, DelegationLink{Principal: "owner-456", Delegate: "ai-agent-123", Scopes: []string{"read"}}, *opts.Attestation)
+		opts.Attestation = &signed
+		tok, err := d.Issue(context.Background(), "ai-agent-123", opts)
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		if err := d.Exercise(context.Background(), tok.Token, 0, ""); err != nil {
+			t.Errorf("Exercise() error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/Gimel-Foundation/gauth/pkg/audit"
+)
+
+// auditEventPrefix prefixes the audit action of logged token events
+const auditEventPrefix = "token."
+
+// AuditEventLog is an EventLog kept in audit storage, so the audit trail of
+// token lifecycle events is the authoritative token state. Each event is one
+// audit entry of type "token" whose action is "token.<event type>".
+//
+// Sequence numbers are assigned in process; only one AuditEventLog may
+// append to a given storage.
+type AuditEventLog struct {
+	storage audit.Storage
+	mu      sync.Mutex
+	seq     uint64
+}
+
+// NewAuditEventLog creates an event log on top of audit storage, continuing
+// after the highest sequence number already stored
+func NewAuditEventLog(ctx context.Context, storage audit.Storage) (*AuditEventLog, error) {
+	if storage == nil {
+		return nil, fmt.Errorf("%w: audit storage is required", ErrInvalidConfig)
+	}
+	l := &AuditEventLog{storage: storage}
+	existing, err := l.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(existing); n > 0 {
+		l.seq = existing[n-1].Seq
+	}
+	return l, nil
+}
+
+// Append implements EventLog
+func (l *AuditEventLog) Append(ctx context.Context, event *TokenEvent) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	event.Seq = l.seq + 1
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token event: %w", err)
+	}
+
+	entry := audit.NewEntry(audit.TypeToken).
+		WithAction(auditEventPrefix+string(event.Type)).
+		WithTarget(event.Key, "token").
+		WithResult(audit.ResultSuccess).
+		WithMetadata("seq", strconv.FormatUint(event.Seq, 10)).
+		WithMetadata("event", string(body))
+	entry.ID = fmt.Sprintf("token-event-%d", event.Seq)
+	entry.Timestamp = event.At
+	entry.CorrelationID = event.CorrelationID
+	if event.Token != nil {
+		entry.ActorID = event.Token.Subject
+	}
+
+	if err := l.storage.Store(ctx, entry); err != nil {
+		return fmt.Errorf("failed to store token event: %w", err)
+	}
+	l.seq = event.Seq
+	return nil
+}
+
+// Replay implements EventLog
+func (l *AuditEventLog) Replay(ctx context.Context, after uint64, fn func(*TokenEvent) error) error {
+	logged, err := l.load(ctx)
+	if err != nil {
+		return err
+	}
+	for _, event := range logged {
+		if event.Seq <= after {
+			continue
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load reads every token event from storage, ordered by sequence number
+func (l *AuditEventLog) load(ctx context.Context) ([]*TokenEvent, error) {
+	actions := make([]string, 0, 6)
+	for _, t := range []TokenEventType{TokenEventIssued, TokenEventUsed, TokenEventRotated,
+		TokenEventRevoked, TokenEventDeleted, TokenEventExpired} {
+		actions = append(actions, auditEventPrefix+string(t))
+	}
+	entries, err := l.storage.Search(ctx, &audit.Filter{Types: []string{audit.TypeToken}, Actions: actions})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token events: %w", err)
+	}
+
+	logged := make([]*TokenEvent, 0, len(entries))
+	for _, entry := range entries {
+		body, ok := entry.Metadata["event"]
+		if !ok {
+			continue
+		}
+		var event TokenEvent
+		if err := json.Unmarshal([]byte(body), &event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal token event %s: %w", entry.ID, err)
+		}
+		logged = append(logged, &event)
+	}
+	sort.Slice(logged, func(i, j int) bool { return logged[i].Seq < logged[j].Seq })
+	return logged, nil
+}
@@ -0,0 +1,153 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// BatchStore is implemented by stores that apply many writes in one round
+// trip. Use SaveBatch, RevokeBatch and DeleteBatch to fall back to single
+// calls for stores that do not implement it.
+type BatchStore interface {
+	// SaveBatch stores every token under its ID
+	SaveBatch(ctx context.Context, tokens []*Token) error
+
+	// RevokeBatch revokes every token
+	RevokeBatch(ctx context.Context, tokens []*Token) error
+
+	// DeleteBatch removes the tokens stored under keys
+	DeleteBatch(ctx context.Context, keys []string) error
+}
+
+// BatchError lists the items of a batch operation that failed; the others
+// were applied. errors.Is matches the error of any failed item, so callers
+// can ignore ErrTokenNotFound for tokens that are already gone.
+type BatchError struct {
+	// Failed maps a token ID or key to its error
+	Failed map[string]error
+}
+
+func (e *BatchError) Error() string {
+	keys := e.Keys()
+	if len(keys) == 1 {
+		return fmt.Sprintf("batch operation failed for %s: %v", keys[0], e.Failed[keys[0]])
+	}
+	return fmt.Sprintf("batch operation failed for %d items, first %s: %v", len(keys), keys[0], e.Failed[keys[0]])
+}
+
+// Unwrap returns the errors of the failed items
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, k := range e.Keys() {
+		errs = append(errs, e.Failed[k])
+	}
+	return errs
+}
+
+// Keys returns the failed keys, sorted
+func (e *BatchError) Keys() []string {
+	keys := make([]string, 0, len(e.Failed))
+	for k := range e.Failed {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// batchErrors collects per-item failures of a batch
+type batchErrors map[string]error
+
+func (b batchErrors) err() error {
+	if len(b) == 0 {
+		return nil
+	}
+	return &BatchError{Failed: b}
+}
+
+// SaveBatch saves tokens under their IDs, in one call when the store
+// implements BatchStore
+func SaveBatch(ctx context.Context, store Store, tokens []*Token) error {
+	if bs, ok := store.(BatchStore); ok {
+		return bs.SaveBatch(ctx, tokens)
+	}
+	failed := batchErrors{}
+	for _, t := range tokens {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := store.Save(ctx, t.ID, t); err != nil {
+			failed[t.ID] = err
+		}
+	}
+	return failed.err()
+}
+
+// RevokeBatch records reason on the tokens and revokes them, in one call
+// when the store implements BatchStore
+func RevokeBatch(ctx context.Context, store Store, tokens []*Token, reason RevocationReason) error {
+	if err := checkReason(reason); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, t := range tokens {
+		t.RevocationStatus = &RevocationStatus{RevokedAt: now, Reason: reason}
+	}
+	if bs, ok := store.(BatchStore); ok {
+		return bs.RevokeBatch(ctx, tokens)
+	}
+	failed := batchErrors{}
+	for _, t := range tokens {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := store.Revoke(ctx, t); err != nil {
+			failed[t.ID] = err
+		}
+	}
+	return failed.err()
+}
+
+// DeleteBatch deletes the tokens stored under keys, in one call when the
+// store implements BatchStore
+func DeleteBatch(ctx context.Context, store Store, keys []string) error {
+	if bs, ok := store.(BatchStore); ok {
+		return bs.DeleteBatch(ctx, keys)
+	}
+	failed := batchErrors{}
+	for _, k := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := store.Delete(ctx, k); err != nil {
+			failed[k] = err
+		}
+	}
+	return failed.err()
+}
+
+// RevokeMatching revokes every token matching the filter, such as all tokens
+// issued to a compromised client, and returns how many were revoked. Pagination
+// fields of the filter are ignored.
+func RevokeMatching(ctx context.Context, store Store, filter Filter, reason RevocationReason) (int, error) {
+	if err := checkReason(reason); err != nil {
+		return 0, err
+	}
+	filter.SortBy, filter.Limit, filter.Cursor = "", 0, ""
+	tokens, err := store.List(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list tokens: %w", err)
+	}
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+	err = RevokeBatch(ctx, store, tokens, reason)
+	if be, ok := err.(*BatchError); ok {
+		return len(tokens) - len(be.Failed), err
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(tokens), nil
+}
@@ -0,0 +1,108 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestBatchOperations(t *testing.T) {
+	ctx := context.Background()
+	newTokens := func(n int, subject string) []*Token {
+		tokens := make([]*Token, n)
+		for i := range tokens {
+			id := subject + "-" + strconv.Itoa(i)
+			tokens[i] = &Token{ID: id, Value: "value-" + id, Type: Access, Subject: subject,
+				IssuedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+		}
+		return tokens
+	}
+
+	stores := map[string]Store{
+		"memory":  NewMemoryStore(),
+		"sharded": NewShardedMemoryStore(ShardedMemoryConfig{Shards: 8}),
+		// Embedding hides the batch methods, exercising the fallback
+		"fallback": struct{ Store }{NewMemoryStore()},
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			if err := SaveBatch(ctx, store, newTokens(50, "client-a")); err != nil {
+				t.Fatalf("SaveBatch() error: %v", err)
+			}
+			_ = SaveBatch(ctx, store, newTokens(10, "client-b"))
+
+			n, err := RevokeMatching(ctx, store, Filter{Subject: "client-a", Limit: 5}, ReasonCompromise)
+			if err != nil || n != 50 {
+				t.Fatalf("Expected 50 tokens revoked, got %d, %v", n, err)
+			}
+			if count, _ := store.Count(ctx, Filter{}); count != 10 {
+				t.Errorf("Expected client-b tokens to remain, have %d", count)
+			}
+
+			err = DeleteBatch(ctx, store, []string{"client-b-0", "client-b-1", "missing"})
+			var be *BatchError
+			if !errors.As(err, &be) || len(be.Failed) != 1 || !errors.Is(err, ErrTokenNotFound) {
+				t.Fatalf("Expected a BatchError for the missing key, got %v", err)
+			}
+			if count, _ := store.Count(ctx, Filter{}); count != 8 {
+				t.Errorf("Expected existing keys deleted despite the failure, have %d", count)
+			}
+		})
+	}
+
+	t.Run("Memory Capacity Rejects Whole Batch", func(t *testing.T) {
+		store := NewMemoryStore()
+		store.maxTokens = 5
+		if err := store.SaveBatch(ctx, newTokens(6, "c")); !errors.Is(err, ErrStorageFailure) {
+			t.Fatalf("Expected ErrStorageFailure, got %v", err)
+		}
+		if count, _ := store.Count(ctx, Filter{}); count != 0 {
+			t.Errorf("Expected nothing saved, have %d", count)
+		}
+	})
+
+	t.Run("Redis", func(t *testing.T) {
+		mr, err := miniredis.Run()
+		if err != nil {
+			t.Fatalf("miniredis: %v", err)
+		}
+		defer mr.Close()
+		store, err := NewRedisStore(RedisConfig{Addresses: []string{mr.Addr()}, KeyPrefix: "batch:", DefaultTTL: time.Hour})
+		if err != nil {
+			t.Fatalf("NewRedisStore() error: %v", err)
+		}
+		defer store.Close()
+
+		tokens := newTokens(20, "client-a")
+		if err := store.SaveBatch(ctx, tokens); err != nil {
+			t.Fatalf("SaveBatch() error: %v", err)
+		}
+		if got, err := store.GetByValue(ctx, tokens[3].Value); err != nil || got.ID != tokens[3].ID {
+			t.Fatalf("Expected value index written, got %v, %v", got, err)
+		}
+		listed, _ := store.List(ctx, Filter{Subject: "client-a"})
+		if len(listed) != 20 {
+			t.Fatalf("Expected 20 indexed tokens, got %d", len(listed))
+		}
+
+		if err := store.RevokeBatch(ctx, []string{tokens[0].ID, tokens[1].ID}, ReasonCompromise); err != nil {
+			t.Fatalf("RevokeBatch() error: %v", err)
+		}
+		got, _ := store.Get(ctx, tokens[0].ID)
+		if got.RevocationStatus == nil || got.RevocationStatus.Reason != ReasonCompromise {
+			t.Errorf("Expected token revoked, got %+v", got.RevocationStatus)
+		}
+
+		err = store.DeleteBatch(ctx, []string{tokens[0].ID, "missing"})
+		if !errors.Is(err, ErrTokenNotFound) {
+			t.Errorf("Expected ErrTokenNotFound for the missing ID, got %v", err)
+		}
+		if _, err := store.GetByValue(ctx, tokens[0].Value); !errors.Is(err, ErrTokenNotFound) {
+			t.Errorf("Expected value index removed, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Time restriction errors
+var (
+	// ErrOutsideTimeWindow indicates an operation outside the allowed hours
+	ErrOutsideTimeWindow = errors.New("operation not allowed at this time")
+
+	// ErrBlackoutDate indicates an operation on a holiday or blackout date
+	ErrBlackoutDate = errors.New("operation not allowed on this date")
+)
+
+// dateLayout is the format of blackout and holiday dates
+const dateLayout = "2006-01-02"
+
+// CalendarProvider resolves holiday calendars referenced by
+// TimeConstraints.Calendars, e.g. exchange or public holiday calendars
+type CalendarProvider interface {
+	// Holiday reports whether date is a holiday in the calendar and, if so,
+	// its name. Only the year, month and day of date are significant.
+	Holiday(ctx context.Context, calendar string, date time.Time) (name string, ok bool, err error)
+}
+
+// CalendarProviderFunc adapts a function to CalendarProvider
+type CalendarProviderFunc func(ctx context.Context, calendar string, date time.Time) (string, bool, error)
+
+// Holiday calls f
+func (f CalendarProviderFunc) Holiday(ctx context.Context, calendar string, date time.Time) (string, bool, error) {
+	return f(ctx, calendar, date)
+}
+
+// StaticCalendars is an in-memory CalendarProvider for holiday lists
+// loaded from configuration
+type StaticCalendars struct {
+	mu   sync.RWMutex
+	days map[string]map[string]string
+}
+
+// NewStaticCalendars creates an empty set of calendars
+func NewStaticCalendars() *StaticCalendars {
+	return &StaticCalendars{days: make(map[string]map[string]string)}
+}
+
+// Add marks date (YYYY-MM-DD) as a named holiday in calendar
+func (c *StaticCalendars) Add(calendar, date, name string) error {
+	if _, err := time.Parse(dateLayout, date); err != nil {
+		return fmt.Errorf("%w: holiday date %q: %v", ErrInvalidConfig, date, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.days[calendar] == nil {
+		c.days[calendar] = make(map[string]string)
+	}
+	c.days[calendar][date] = name
+	return nil
+}
+
+// Holiday implements CalendarProvider. Unknown calendars are an error so a
+// misspelled calendar name cannot silently allow trading.
+func (c *StaticCalendars) Holiday(_ context.Context, calendar string, date time.Time) (string, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	days, ok := c.days[calendar]
+	if !ok {
+		return "", false, fmt.Errorf("unknown holiday calendar %q", calendar)
+	}
+	name, ok := days[date.Format(dateLayout)]
+	return name, ok, nil
+}
+
+// Check reports whether an operation at now satisfies the constraints:
+// it must not fall on a blackout date or a holiday of any listed calendar,
+// and must fall within one of the allowed time windows, if any. Dates and
+// hours are evaluated in TimeZone. Calendars cannot be checked without a
+// provider, so constraints naming calendars fail closed when it is nil.
+func (tc *TimeConstraints) Check(ctx context.Context, now time.Time, calendars CalendarProvider) error {
+	if tc == nil {
+		return nil
+	}
+	loc, err := time.LoadLocation(tc.TimeZone)
+	if err != nil {
+		return fmt.Errorf("%w: time zone %q: %v", ErrInvalidConfig, tc.TimeZone, err)
+	}
+	local := now.In(loc)
+	date := local.Format(dateLayout)
+
+	if containsString(tc.BlackoutDates, date) {
+		return fmt.Errorf("%w: %s is a blackout date", ErrBlackoutDate, date)
+	}
+	if len(tc.Calendars) > 0 && calendars == nil {
+		return fmt.Errorf("%w: no calendar provider for %v", ErrInvalidConfig, tc.Calendars)
+	}
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	for _, calendar := range tc.Calendars {
+		name, holiday, err := calendars.Holiday(ctx, calendar, day)
+		if err != nil {
+			return fmt.Errorf("failed to check holiday calendar %s: %w", calendar, err)
+		}
+		if holiday {
+			return fmt.Errorf("%w: %s is %s in %s", ErrBlackoutDate, date, name, calendar)
+		}
+	}
+
+	if len(tc.AllowedTimeWindows) == 0 {
+		return nil
+	}
+	for _, window := range tc.AllowedTimeWindows {
+		if window.Contains(local) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s %s", ErrOutsideTimeWindow, local.Weekday(), local.Format("15:04"))
+}
+
+// Contains reports whether t, already in the window's time zone, falls on
+// an allowed day between StartTime (inclusive) and EndTime (exclusive).
+// Windows whose end is before their start span midnight.
+func (w TimeWindow) Contains(t time.Time) bool {
+	dayAllowed := false
+	for _, day := range w.DaysOfWeek {
+		if int(t.Weekday()) == day {
+			dayAllowed = true
+			break
+		}
+	}
+	if !dayAllowed {
+		return false
+	}
+	start, err := time.Parse("15:04", w.StartTime)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse("15:04", w.EndTime)
+	if err != nil {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	from, to := start.Hour()*60+start.Minute(), end.Hour()*60+end.Minute()
+	if to < from {
+		return minute >= from || minute < to
+	}
+	return minute >= from && minute < to
+}
+
+// checkChainTime checks the time constraints of every link of a
+// delegation chain
+func checkChainTime(ctx context.Context, chain *DelegationChain, now time.Time, calendars CalendarProvider) error {
+	if chain == nil {
+		return nil
+	}
+	for _, link := range chain.Links {
+		if link.Restrictions == nil {
+			continue
+		}
+		if err := link.Restrictions.TimeConstraints.Check(ctx, now, calendars); err != nil {
+			return fmt.Errorf("restriction granted by %s: %w", link.Principal, err)
+		}
+	}
+	return nil
+}
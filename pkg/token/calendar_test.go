@@ -0,0 +1,118 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTimeConstraints(t *testing.T) {
+	ctx := context.Background()
+	calendars := NewStaticCalendars()
+	if err := calendars.Add("XNYS", "2026-07-03", "Independence Day (observed)"); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+	if err := calendars.Add("XNYS", "July 4th", "bad"); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Expected a malformed date to be refused, got %v", err)
+	}
+	tc := &TimeConstraints{
+		AllowedTimeWindows: []TimeWindow{{StartTime: "09:30", EndTime: "16:00", DaysOfWeek: []int{1, 2, 3, 4, 5}}},
+		TimeZone:           "America/New_York",
+		Calendars:          []string{"XNYS"},
+		BlackoutDates:      []string{"2026-07-06"},
+	}
+	ny, _ := time.LoadLocation("America/New_York")
+	at := func(day, hour, minute int) time.Time { return time.Date(2026, 7, day, hour, minute, 0, 0, ny) }
+
+	t.Run("Business Hours", func(t *testing.T) {
+		if err := tc.Check(ctx, at(2, 9, 30), calendars); err != nil {
+			t.Errorf("Expected Thursday 09:30 to be allowed, got %v", err)
+		}
+		if err := tc.Check(ctx, at(2, 16, 0), calendars); !errors.Is(err, ErrOutsideTimeWindow) {
+			t.Errorf("Expected 16:00 to be outside the window, got %v", err)
+		}
+		if err := tc.Check(ctx, at(4, 12, 0), calendars); !errors.Is(err, ErrOutsideTimeWindow) {
+			t.Errorf("Expected Saturday to be outside the window, got %v", err)
+		}
+		// 13:00 UTC is 09:00 in New York
+		if err := tc.Check(ctx, time.Date(2026, 7, 2, 13, 0, 0, 0, time.UTC), calendars); !errors.Is(err, ErrOutsideTimeWindow) {
+			t.Errorf("Expected the window to be evaluated in the constraint's time zone, got %v", err)
+		}
+	})
+
+	t.Run("Holidays And Blackouts", func(t *testing.T) {
+		if err := tc.Check(ctx, at(3, 12, 0), calendars); !errors.Is(err, ErrBlackoutDate) {
+			t.Errorf("Expected the exchange holiday to be refused, got %v", err)
+		}
+		if err := tc.Check(ctx, at(6, 12, 0), calendars); !errors.Is(err, ErrBlackoutDate) {
+			t.Errorf("Expected the blackout date to be refused, got %v", err)
+		}
+		if err := tc.Check(ctx, at(7, 12, 0), calendars); err != nil {
+			t.Errorf("Expected the next business day to be allowed, got %v", err)
+		}
+	})
+
+	t.Run("Fails Closed", func(t *testing.T) {
+		if err := tc.Check(ctx, at(2, 12, 0), nil); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("Expected calendars without a provider to fail, got %v", err)
+		}
+		unknown := &TimeConstraints{Calendars: []string{"XLON"}}
+		if err := unknown.Check(ctx, at(2, 12, 0), calendars); err == nil {
+			t.Error("Expected an unknown calendar to fail")
+		}
+		var none *TimeConstraints
+		if err := none.Check(ctx, at(3, 12, 0), nil); err != nil {
+			t.Errorf("Expected nil constraints to allow everything, got %v", err)
+		}
+	})
+
+	t.Run("Window Across Midnight", func(t *testing.T) {
+		w := TimeWindow{StartTime: "22:00", EndTime: "06:00", DaysOfWeek: []int{0, 1, 2, 3, 4, 5, 6}}
+		if !w.Contains(at(2, 23, 0)) || !w.Contains(at(2, 5, 59)) || w.Contains(at(2, 6, 0)) {
+			t.Error("Expected the window to wrap around midnight")
+		}
+	})
+}
+
+func TestDelegatorEnforcesHolidays(t *testing.T) {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	today := time.Now().UTC().Format("2006-01-02")
+	calendars := NewStaticCalendars()
+	_ = calendars.Add("XNYS", today, "Exchange Holiday")
+	d, err := NewDelegator(DelegatorConfig{
+		Service:   NewService(Config{SigningKey: key, ValidityPeriod: time.Hour}, NewMemoryStore()),
+		Calendars: calendars,
+	})
+	if err != nil {
+		t.Fatalf("NewDelegator() error: %v", err)
+	}
+	issue := func(tc *TimeConstraints) *Token {
+		tok, err := d.Issue(ctx, "trading-agent", DelegationOptions{
+			Principal: "fund-1",
+			Scope:     "trade",
+			Restrictions: &Restrictions{
+				ValueLimits:     &ValueLimits{MaxTransactionValue: 1000, Currency: "USD"},
+				TimeConstraints: tc,
+			},
+			ValidUntil: time.Now().Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		return tok.Token
+	}
+
+	if err := d.Exercise(ctx, issue(&TimeConstraints{TimeZone: "UTC", Calendars: []string{"XNYS"}}), 100, "USD"); !errors.Is(err, ErrBlackoutDate) {
+		t.Errorf("Expected no trading on an exchange holiday, got %v", err)
+	}
+	if err := d.Exercise(ctx, issue(&TimeConstraints{TimeZone: "UTC"}), 100, "USD"); err != nil {
+		t.Errorf("Expected a delegation without calendars to be exercisable, got %v", err)
+	}
+}
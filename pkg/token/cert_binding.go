@@ -0,0 +1,93 @@
+package token
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+)
+
+// AppDataCertThumbprint records the SHA-256 thumbprint of the client
+// certificate a token is bound to (RFC 8705). Signed tokens also carry it in
+// the standard cnf claim.
+const AppDataCertThumbprint = "x5t#S256"
+
+// ValidationCodeCertificateMismatch indicates a certificate-bound token was
+// presented without its client certificate or with a different one
+const ValidationCodeCertificateMismatch ValidationErrorCode = "certificate_mismatch"
+
+// CertificateThumbprint returns the base64url-encoded SHA-256 digest of the
+// DER certificate, as used in the x5t#S256 confirmation claim
+func CertificateThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// CertThumbprintOf returns the certificate thumbprint a token is bound to,
+// or "" for unbound tokens
+func CertThumbprintOf(t *Token) string {
+	if t == nil || t.Metadata == nil {
+		return ""
+	}
+	return t.Metadata.AppData[AppDataCertThumbprint]
+}
+
+type clientCertKey struct{}
+
+// WithClientCertificate returns ctx carrying the client certificate of the
+// mutual-TLS connection. Tokens issued with it are bound to the certificate
+// and validation with it checks the binding.
+func WithClientCertificate(ctx context.Context, cert *x509.Certificate) context.Context {
+	return context.WithValue(ctx, clientCertKey{}, cert)
+}
+
+// WithTLSState is WithClientCertificate for the leaf certificate of a TLS
+// connection, such as http.Request.TLS. ctx is returned unchanged when the
+// peer presented no certificate.
+func WithTLSState(ctx context.Context, state *tls.ConnectionState) context.Context {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return ctx
+	}
+	return WithClientCertificate(ctx, state.PeerCertificates[0])
+}
+
+// ClientCertificateFromContext returns the client certificate carried by ctx
+func ClientCertificateFromContext(ctx context.Context) *x509.Certificate {
+	cert, _ := ctx.Value(clientCertKey{}).(*x509.Certificate)
+	return cert
+}
+
+// stampCertThumbprint binds the token to the client certificate carried by
+// ctx unless it is already bound
+func stampCertThumbprint(ctx context.Context, t *Token) {
+	cert := ClientCertificateFromContext(ctx)
+	if cert == nil || CertThumbprintOf(t) != "" {
+		return
+	}
+	if t.Metadata == nil {
+		t.Metadata = &Metadata{}
+	}
+	if t.Metadata.AppData == nil {
+		t.Metadata.AppData = make(map[string]string)
+	}
+	t.Metadata.AppData[AppDataCertThumbprint] = CertificateThumbprint(cert)
+}
+
+// validateCertBinding rejects a bound token unless ctx carries the same
+// client certificate; unbound tokens are accepted
+func validateCertBinding(ctx context.Context, t *Token) error {
+	bound := CertThumbprintOf(t)
+	if bound == "" {
+		return nil
+	}
+	cert := ClientCertificateFromContext(ctx)
+	if cert == nil {
+		return NewValidationError(ValidationCodeCertificateMismatch, "token is bound to a client certificate")
+	}
+	if subtle.ConstantTimeCompare([]byte(bound), []byte(CertificateThumbprint(cert))) != 1 {
+		return NewValidationError(ValidationCodeCertificateMismatch, "client certificate does not match token binding")
+	}
+	return nil
+}
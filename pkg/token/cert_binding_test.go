@@ -0,0 +1,91 @@
+package token
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func testClientCert(t *testing.T, cn string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error: %v", err)
+	}
+	return cert
+}
+
+func TestCertificateBoundTokens(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	svc := NewService(Config{SigningKey: key, ValidityPeriod: time.Hour}, NewMemoryStore())
+	client, other := testClientCert(t, "svc-a"), testClientCert(t, "svc-b")
+	mtls := WithTLSState(context.Background(), &tls.ConnectionState{PeerCertificates: []*x509.Certificate{client}})
+
+	issued, err := svc.Issue(mtls, &Token{ID: NewID(), Type: Access, Subject: "svc-a", Scopes: []string{"read"}})
+	if err != nil {
+		t.Fatalf("Issue() error: %v", err)
+	}
+	presented, err := svc.Parse(context.Background(), issued.Value)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if got := CertThumbprintOf(presented); got == "" || got != CertificateThumbprint(client) {
+		t.Fatalf("Expected signed cnf thumbprint of the client cert, got %q", got)
+	}
+
+	t.Run("Same Certificate", func(t *testing.T) {
+		if err := svc.Validate(mtls, presented); err != nil {
+			t.Errorf("Validate() error: %v", err)
+		}
+	})
+
+	t.Run("Different Or Missing Certificate", func(t *testing.T) {
+		for name, ctx := range map[string]context.Context{
+			"different": WithClientCertificate(context.Background(), other),
+			"missing":   context.Background(),
+		} {
+			err := svc.Validate(ctx, presented)
+			var ve *ValidationError
+			if !errors.As(err, &ve) || ve.Code != ValidationCodeCertificateMismatch {
+				t.Errorf("%s: expected certificate_mismatch, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("Unbound Tokens Unaffected", func(t *testing.T) {
+		plain, err := svc.Issue(context.Background(), &Token{ID: NewID(), Type: Access, Subject: "user-1", Scopes: []string{"read"}})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		if err := svc.Validate(WithClientCertificate(context.Background(), other), plain); err != nil {
+			t.Errorf("Validate() error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,216 @@
+package token
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+	"github.com/Gimel-Foundation/gauth/pkg/metrics"
+)
+
+// clockSkewFor returns the skew tolerance for a token type
+func clockSkewFor(typ Type, base time.Duration, byType map[Type]time.Duration) time.Duration {
+	if skew, ok := byType[typ]; ok {
+		return skew
+	}
+	return base
+}
+
+// ClockReference measures the local clock against a trusted time source
+type ClockReference interface {
+	// Offset returns how far the local clock is ahead of the reference
+	// (negative when it is behind)
+	Offset(ctx context.Context) (time.Duration, error)
+}
+
+// ClockReferenceFunc adapts a function to the ClockReference interface
+type ClockReferenceFunc func(ctx context.Context) (time.Duration, error)
+
+// Offset implements ClockReference
+func (f ClockReferenceFunc) Offset(ctx context.Context) (time.Duration, error) {
+	return f(ctx)
+}
+
+// ntpEpochOffset is the number of seconds between 1900-01-01 and 1970-01-01
+const ntpEpochOffset = 2208988800
+
+// SNTPReference queries an NTP server using SNTPv4 (RFC 4330)
+type SNTPReference struct {
+	// Server is the host:port of the NTP server (port defaults to 123)
+	Server string
+
+	// Timeout bounds a single query (default: 5s)
+	Timeout time.Duration
+}
+
+// Offset implements ClockReference
+func (r *SNTPReference) Offset(ctx context.Context) (time.Duration, error) {
+	addr := r.Server
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "123")
+	}
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return 0, fmt.Errorf("failed to dial NTP server: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return 0, err
+	}
+
+	req := make([]byte, 48)
+	req[0] = 0x23 // LI = 0, VN = 4, Mode = 3 (client)
+
+	t1 := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, fmt.Errorf("failed to send NTP request: %w", err)
+	}
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	t4 := time.Now()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read NTP response: %w", err)
+	}
+	if n < 48 {
+		return 0, errors.New("short NTP response")
+	}
+	if mode := resp[0] & 0x07; mode != 4 {
+		return 0, fmt.Errorf("unexpected NTP mode %d", mode)
+	}
+	if stratum := resp[1]; stratum == 0 || stratum > 15 {
+		return 0, fmt.Errorf("NTP server unsynchronized (stratum %d)", stratum)
+	}
+
+	t2 := ntpTime(resp[32:40])
+	t3 := ntpTime(resp[40:48])
+
+	// RFC 4330: offset of the server relative to the local clock
+	serverOffset := (t2.Sub(t1) + t3.Sub(t4)) / 2
+	return -serverOffset, nil
+}
+
+func ntpTime(b []byte) time.Time {
+	secs := binary.BigEndian.Uint32(b[0:4])
+	frac := binary.BigEndian.Uint32(b[4:8])
+	nsec := (int64(frac) * 1e9) >> 32
+	return time.Unix(int64(secs)-ntpEpochOffset, nsec)
+}
+
+// DriftDetectorConfig configures clock drift detection
+type DriftDetectorConfig struct {
+	// Reference is the trusted time source
+	Reference ClockReference
+
+	// Name labels the reference in metrics and events (default: "reference")
+	Name string
+
+	// Threshold is the drift beyond which a warning is raised (default: 1s).
+	// It should stay below the configured ClockSkew.
+	Threshold time.Duration
+
+	// Interval is how often Start checks the clock (default: 1m)
+	Interval time.Duration
+
+	// Events receives a clock_drift_detected warning when drift exceeds Threshold
+	Events events.EventHandler
+
+	// Metrics records the measured drift as gauth_clock_drift_seconds
+	Metrics *metrics.Collector
+}
+
+// DriftDetector periodically compares the local clock to a reference and
+// warns when they diverge, before skew starts surfacing as spurious
+// ErrTokenExpired or ErrTokenNotYetValid failures.
+type DriftDetector struct {
+	config    DriftDetectorConfig
+	mu        sync.RWMutex
+	lastDrift time.Duration
+	lastCheck time.Time
+}
+
+// NewDriftDetector creates a drift detector
+func NewDriftDetector(config DriftDetectorConfig) (*DriftDetector, error) {
+	if config.Reference == nil {
+		return nil, errors.New("clock reference is required")
+	}
+	if config.Name == "" {
+		config.Name = "reference"
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = time.Second
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Minute
+	}
+	return &DriftDetector{config: config}, nil
+}
+
+// Check measures the current drift, records it and raises a warning event
+// if it exceeds the threshold
+func (d *DriftDetector) Check(ctx context.Context) (time.Duration, error) {
+	drift, err := d.config.Reference.Offset(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure clock drift: %w", err)
+	}
+
+	d.mu.Lock()
+	d.lastDrift = drift
+	d.lastCheck = time.Now()
+	d.mu.Unlock()
+
+	if d.config.Metrics != nil {
+		d.config.Metrics.SetClockDrift(d.config.Name, drift)
+	}
+
+	if d.config.Events != nil && (drift > d.config.Threshold || drift < -d.config.Threshold) {
+		event := events.NewSystemEvent(events.ActionClockDriftDetected, events.StatusWarning).WithContext(ctx).
+			WithMessage(fmt.Sprintf("local clock drift of %s exceeds threshold %s", drift, d.config.Threshold)).
+			WithStringMetadata("reference", d.config.Name).
+			WithStringMetadata("drift", drift.String()).
+			WithStringMetadata("threshold", d.config.Threshold.String())
+		d.config.Events.Handle(event)
+	}
+
+	return drift, nil
+}
+
+// Start runs Check every Interval until ctx is cancelled. Measurement errors
+// are reported to onError when it is non-nil.
+func (d *DriftDetector) Start(ctx context.Context, onError func(error)) {
+	ticker := time.NewTicker(d.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.Check(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// LastDrift returns the most recent measurement and when it was taken
+func (d *DriftDetector) LastDrift() (time.Duration, time.Time) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.lastDrift, d.lastCheck
+}
@@ -0,0 +1,106 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+type captureHandler struct {
+	events []events.Event
+}
+
+func (h *captureHandler) Handle(e events.Event) {
+	h.events = append(h.events, e)
+}
+
+func TestClockSkewByType(t *testing.T) {
+	ctx := context.Background()
+	bl := NewBlacklist()
+	defer bl.Close()
+
+	chain := NewValidationChain(ValidationConfig{
+		ClockSkew:       time.Second,
+		ClockSkewByType: map[Type]time.Duration{Refresh: time.Minute},
+	}, bl)
+
+	expired := func(typ Type) *Token {
+		return &Token{
+			ID:        NewID(),
+			Type:      typ,
+			NotBefore: time.Now().Add(-time.Hour),
+			ExpiresAt: time.Now().Add(-30 * time.Second),
+		}
+	}
+
+	t.Run("Default Skew", func(t *testing.T) {
+		if err := chain.Validate(ctx, expired(Access)); !errors.Is(err, ErrTokenExpired) {
+			t.Errorf("Expected ErrTokenExpired, got %v", err)
+		}
+	})
+
+	t.Run("Per Type Skew", func(t *testing.T) {
+		if err := chain.Validate(ctx, expired(Refresh)); err != nil {
+			t.Errorf("Expected refresh token within skew, got %v", err)
+		}
+	})
+
+	t.Run("Service Skew", func(t *testing.T) {
+		svc := &Service{config: Config{ClockSkewByType: map[Type]time.Duration{Refresh: time.Minute}}}
+		if err := svc.validateTimeClaims(expired(Refresh)); err != nil {
+			t.Errorf("Expected refresh token within skew, got %v", err)
+		}
+		if err := svc.validateTimeClaims(expired(Access)); err == nil {
+			t.Error("Expected access token to be expired")
+		}
+	})
+}
+
+func TestDriftDetector(t *testing.T) {
+	ctx := context.Background()
+	drift := 200 * time.Millisecond
+	handler := &captureHandler{}
+
+	detector, err := NewDriftDetector(DriftDetectorConfig{
+		Reference: ClockReferenceFunc(func(context.Context) (time.Duration, error) {
+			return drift, nil
+		}),
+		Threshold: time.Second,
+		Events:    handler,
+	})
+	if err != nil {
+		t.Fatalf("NewDriftDetector() error: %v", err)
+	}
+
+	t.Run("Within Threshold", func(t *testing.T) {
+		if _, err := detector.Check(ctx); err != nil {
+			t.Fatalf("Check() error: %v", err)
+		}
+		if len(handler.events) != 0 {
+			t.Errorf("Expected no events, got %d", len(handler.events))
+		}
+	})
+
+	t.Run("Exceeds Threshold", func(t *testing.T) {
+		drift = -3 * time.Second
+		got, err := detector.Check(ctx)
+		if err != nil {
+			t.Fatalf("Check() error: %v", err)
+		}
+		if got != drift {
+			t.Errorf("Expected drift %s, got %s", drift, got)
+		}
+		if len(handler.events) != 1 {
+			t.Fatalf("Expected 1 event, got %d", len(handler.events))
+		}
+		if handler.events[0].Action != string(events.ActionClockDriftDetected) {
+			t.Errorf("Unexpected event action %q", handler.events[0].Action)
+		}
+		if last, _ := detector.LastDrift(); last != drift {
+			t.Errorf("Expected last drift %s, got %s", drift, last)
+		}
+	})
+}
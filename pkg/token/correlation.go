@@ -0,0 +1,46 @@
+package token
+
+import (
+	"context"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// AppDataCorrelationID records the correlation ID of the flow that issued a token
+const AppDataCorrelationID = "correlation_id"
+
+// CorrelationIDOf returns the correlation ID recorded on a token at issuance
+func CorrelationIDOf(t *Token) string {
+	if t == nil || t.Metadata == nil {
+		return ""
+	}
+	return t.Metadata.AppData[AppDataCorrelationID]
+}
+
+// ContextForToken returns ctx carrying the token's correlation ID, so that
+// later usage, authorization and revocation of the token are reported under
+// the flow that issued it. A correlation ID already on ctx takes precedence.
+func ContextForToken(ctx context.Context, t *Token) context.Context {
+	if events.CorrelationIDFromContext(ctx) != "" {
+		return ctx
+	}
+	if id := CorrelationIDOf(t); id != "" {
+		return events.ContextWithCorrelationID(ctx, id)
+	}
+	return ctx
+}
+
+// stampCorrelationID records the correlation ID carried by ctx on the token
+func stampCorrelationID(ctx context.Context, t *Token) {
+	id := events.CorrelationIDFromContext(ctx)
+	if id == "" || CorrelationIDOf(t) != "" {
+		return
+	}
+	if t.Metadata == nil {
+		t.Metadata = &Metadata{}
+	}
+	if t.Metadata.AppData == nil {
+		t.Metadata.AppData = make(map[string]string)
+	}
+	t.Metadata.AppData[AppDataCorrelationID] = id
+}
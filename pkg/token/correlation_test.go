@@ -0,0 +1,33 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+func TestCorrelationID(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	svc := NewService(Config{SigningKey: key, ValidityPeriod: time.Hour}, NewMemoryStore(time.Hour))
+
+	ctx := events.ContextWithCorrelationID(context.Background(), "flow-7")
+	issued, err := svc.Issue(ctx, &Token{ID: NewID(), Type: Access, Subject: "user-1", Scopes: []string{"read"}})
+	if err != nil {
+		t.Fatalf("Issue() error: %v", err)
+	}
+	if got := CorrelationIDOf(issued); got != "flow-7" {
+		t.Errorf("Expected correlation ID flow-7 on token, got %q", got)
+	}
+
+	later := ContextForToken(context.Background(), issued)
+	if got := events.CorrelationIDFromContext(later); got != "flow-7" {
+		t.Errorf("Expected token usage to resume flow-7, got %q", got)
+	}
+}
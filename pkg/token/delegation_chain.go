@@ -0,0 +1,241 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/scope"
+)
+
+// ErrInvalidDelegationChain indicates a broken or widening delegation chain
+var ErrInvalidDelegationChain = errors.New("invalid delegation chain")
+
+// DelegationLink is one principal → delegate hop of a power of attorney
+type DelegationLink struct {
+	Principal string   `json:"principal"`
+	Delegate  string   `json:"delegate"`
+	Scopes    []string `json:"scopes"`
+
+	// Restrictions limit what the delegate may do with the scopes
+	Restrictions *Restrictions `json:"restrictions,omitempty"`
+
+	// Attestations witness the grant of this link (notary, witness, ...)
+	Attestations []Attestation `json:"attestations,omitempty"`
+
+	GrantedAt time.Time `json:"granted_at"`
+
+	// ExpiresAt ends the link; zero means it lasts as long as its parent
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// DelegationChain is an RFC111 multi-level delegation, ordered from the
+// original principal to the final delegate. Every link re-delegates part of
+// the power its parent received.
+type DelegationChain struct {
+	Links []DelegationLink `json:"links"`
+}
+
+// Root returns the original principal of the chain
+func (c *DelegationChain) Root() string {
+	if c == nil || len(c.Links) == 0 {
+		return ""
+	}
+	return c.Links[0].Principal
+}
+
+// Holder returns the final delegate of the chain
+func (c *DelegationChain) Holder() string {
+	if c == nil || len(c.Links) == 0 {
+		return ""
+	}
+	return c.Links[len(c.Links)-1].Delegate
+}
+
+// Scopes returns the scopes held at the end of the chain
+func (c *DelegationChain) Scopes() []string {
+	if c == nil || len(c.Links) == 0 {
+		return nil
+	}
+	return c.Links[len(c.Links)-1].Scopes
+}
+
+// Narrow returns the requested scopes the current holder can re-delegate,
+// in canonical form: wildcards are narrowed to what both sides grant, so
+// holding payments:* and requesting payments:invoices:read yields the
+// latter.
+func (c *DelegationChain) Narrow(requested []string) ([]string, error) {
+	held, err := scope.NewSet(c.Scopes()...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDelegationChain, err)
+	}
+	want, err := scope.NewSet(requested...)
+	if err != nil {
+		return nil, err
+	}
+	return scope.Intersect(held, want).Strings(), nil
+}
+
+// Append adds a link re-delegating from the current holder and validates
+// the resulting chain; the chain is unchanged on error
+func (c *DelegationChain) Append(link DelegationLink) error {
+	if holder := c.Holder(); holder != "" && link.Principal == "" {
+		link.Principal = holder
+	}
+	next := &DelegationChain{Links: append(c.Clone().Links, link)}
+	if err := next.Validate(); err != nil {
+		return err
+	}
+	c.Links = next.Links
+	return nil
+}
+
+// Validate checks that the links are contiguous, that no party appears
+// twice and that every link narrows its parent: its scopes are a subset of
+// the parent's, it expires no later and it does not raise value limits.
+func (c *DelegationChain) Validate() error {
+	if c == nil || len(c.Links) == 0 {
+		return fmt.Errorf("%w: no links", ErrInvalidDelegationChain)
+	}
+	seen := make(map[string]bool, len(c.Links)+1)
+	for i, link := range c.Links {
+		if link.Principal == "" || link.Delegate == "" {
+			return fmt.Errorf("%w: link %d needs a principal and a delegate", ErrInvalidDelegationChain, i)
+		}
+		if len(link.Scopes) == 0 {
+			return fmt.Errorf("%w: link %d grants no scopes", ErrInvalidDelegationChain, i)
+		}
+		if i == 0 {
+			seen[link.Principal] = true
+		}
+		if seen[link.Delegate] {
+			return fmt.Errorf("%w: %s appears twice", ErrInvalidDelegationChain, link.Delegate)
+		}
+		seen[link.Delegate] = true
+		if i == 0 {
+			continue
+		}
+
+		parent := c.Links[i-1]
+		if link.Principal != parent.Delegate {
+			return fmt.Errorf("%w: link %d is granted by %s, not by %s", ErrInvalidDelegationChain, i, link.Principal, parent.Delegate)
+		}
+		for _, s := range link.Scopes {
+			if !scope.Allows(parent.Scopes, s) {
+				return fmt.Errorf("%w: link %d widens scope %q", ErrInvalidDelegationChain, i, s)
+			}
+		}
+		if !parent.ExpiresAt.IsZero() && (link.ExpiresAt.IsZero() || link.ExpiresAt.After(parent.ExpiresAt)) {
+			return fmt.Errorf("%w: link %d outlives its parent", ErrInvalidDelegationChain, i)
+		}
+		if err := narrowsValueLimits(parent.Restrictions, link.Restrictions); err != nil {
+			return fmt.Errorf("%w: link %d %v", ErrInvalidDelegationChain, i, err)
+		}
+	}
+	return nil
+}
+
+// narrowsValueLimits reports an error when child loosens parent's value limits
+func narrowsValueLimits(parent, child *Restrictions) error {
+	if parent == nil || parent.ValueLimits == nil {
+		return nil
+	}
+	if child == nil || child.ValueLimits == nil {
+		return errors.New("drops the parent's value limits")
+	}
+	p, c := parent.ValueLimits, child.ValueLimits
+	if c.Currency != p.Currency {
+		return fmt.Errorf("changes the currency from %s to %s", p.Currency, c.Currency)
+	}
+	if p.MaxTransactionValue > 0 && (c.MaxTransactionValue <= 0 || c.MaxTransactionValue > p.MaxTransactionValue) {
+		return errors.New("raises the transaction limit")
+	}
+	if p.DailyLimit > 0 && (c.DailyLimit <= 0 || c.DailyLimit > p.DailyLimit) {
+		return errors.New("raises the daily limit")
+	}
+	return nil
+}
+
+// ActiveAt reports whether every link of the chain is in force at t
+func (c *DelegationChain) ActiveAt(t time.Time) bool {
+	if c == nil {
+		return false
+	}
+	for _, link := range c.Links {
+		if !link.ExpiresAt.IsZero() && !t.Before(link.ExpiresAt) {
+			return false
+		}
+	}
+	return true
+}
+
+// Clone returns a deep copy of the chain
+func (c *DelegationChain) Clone() *DelegationChain {
+	if c == nil {
+		return nil
+	}
+	out := &DelegationChain{Links: make([]DelegationLink, len(c.Links))}
+	for i, link := range c.Links {
+		link.Scopes = append([]string(nil), link.Scopes...)
+		link.Attestations = append([]Attestation(nil), link.Attestations...)
+		link.Restrictions = cloneRestrictions(link.Restrictions)
+		out.Links[i] = link
+	}
+	return out
+}
+
+func cloneRestrictions(r *Restrictions) *Restrictions {
+	if r == nil {
+		return nil
+	}
+	out := *r
+	if r.ValueLimits != nil {
+		v := *r.ValueLimits
+		out.ValueLimits = &v
+	}
+	if r.TimeConstraints != nil {
+		tc := *r.TimeConstraints
+		tc.AllowedTimeWindows = append([]TimeWindow(nil), tc.AllowedTimeWindows...)
+		tc.Calendars = append([]string(nil), tc.Calendars...)
+		tc.BlackoutDates = append([]string(nil), tc.BlackoutDates...)
+		out.TimeConstraints = &tc
+	}
+	out.GeographicConstraints = append([]string(nil), r.GeographicConstraints...)
+	if r.CustomLimits != nil {
+		out.CustomLimits = make(map[string]float64, len(r.CustomLimits))
+		for k, v := range r.CustomLimits {
+			out.CustomLimits[k] = v
+		}
+	}
+	return &out
+}
+
+// DelegationChainOf returns the delegation chain carried by a token, if any
+func DelegationChainOf(t *Token) *DelegationChain {
+	if t == nil || t.Metadata == nil {
+		return nil
+	}
+	return t.Metadata.Delegation
+}
+
+// ValidateDelegation checks a token's delegation chain and that the token
+// acts for the final delegate within the delegated scopes. Tokens without a
+// chain pass.
+func ValidateDelegation(t *Token) error {
+	chain := DelegationChainOf(t)
+	if chain == nil {
+		return nil
+	}
+	if err := chain.Validate(); err != nil {
+		return err
+	}
+	if t.Subject != chain.Holder() {
+		return fmt.Errorf("%w: token subject %s is not the final delegate %s", ErrInvalidDelegationChain, t.Subject, chain.Holder())
+	}
+	for _, s := range t.Scopes {
+		if !scope.Allows(chain.Scopes(), s) {
+			return fmt.Errorf("%w: token scope %q was not delegated", ErrInvalidDelegationChain, s)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testDelegationChain() *DelegationChain {
+	expires := time.Now().Add(24 * time.Hour)
+	return &DelegationChain{Links: []DelegationLink{
+		{
+			Principal: "acme-corp", Delegate: "cfo",
+			Scopes:       []string{"payments:approve", "payments:read", "reports:read"},
+			Restrictions: &Restrictions{ValueLimits: &ValueLimits{MaxTransactionValue: 10000, Currency: "EUR"}},
+			Attestations: []Attestation{{Type: "notary", AttesterID: "notary-1", AttestationDate: time.Now()}},
+			ExpiresAt:    expires,
+		},
+		{
+			Principal: "cfo", Delegate: "ai-agent",
+			Scopes:       []string{"payments:approve", "payments:read"},
+			Restrictions: &Restrictions{ValueLimits: &ValueLimits{MaxTransactionValue: 500, Currency: "EUR"}},
+			ExpiresAt:    expires.Add(-time.Hour),
+		},
+	}}
+}
+
+func TestDelegationChain(t *testing.T) {
+	t.Run("Valid Chain", func(t *testing.T) {
+		chain := testDelegationChain()
+		if err := chain.Validate(); err != nil {
+			t.Fatalf("Validate() error: %v", err)
+		}
+		if chain.Root() != "acme-corp" || chain.Holder() != "ai-agent" {
+			t.Errorf("Expected acme-corp → ai-agent, got %s → %s", chain.Root(), chain.Holder())
+		}
+		if !chain.ActiveAt(time.Now()) || chain.ActiveAt(time.Now().Add(24*time.Hour)) {
+			t.Error("Expected chain to end with its shortest link")
+		}
+	})
+
+	invalid := map[string]func(c *DelegationChain){
+		"Widened Scope":      func(c *DelegationChain) { c.Links[1].Scopes = append(c.Links[1].Scopes, "payments:refund") },
+		"Broken Link":        func(c *DelegationChain) { c.Links[1].Principal = "ceo" },
+		"Cycle":              func(c *DelegationChain) { c.Links[1].Delegate = "acme-corp" },
+		"Outlives Parent":    func(c *DelegationChain) { c.Links[1].ExpiresAt = time.Time{} },
+		"Raised Value Limit": func(c *DelegationChain) { c.Links[1].Restrictions.ValueLimits.MaxTransactionValue = 20000 },
+		"Dropped Limits":     func(c *DelegationChain) { c.Links[1].Restrictions = nil },
+		"No Scopes":          func(c *DelegationChain) { c.Links[0].Scopes = nil },
+	}
+	for name, mutate := range invalid {
+		t.Run(name, func(t *testing.T) {
+			chain := testDelegationChain()
+			mutate(chain)
+			if err := chain.Validate(); !errors.Is(err, ErrInvalidDelegationChain) {
+				t.Errorf("Expected ErrInvalidDelegationChain, got %v", err)
+			}
+		})
+	}
+
+	t.Run("Append", func(t *testing.T) {
+		chain := testDelegationChain()
+		err := chain.Append(DelegationLink{Delegate: "sub-agent", Scopes: []string{"reports:read"}})
+		if !errors.Is(err, ErrInvalidDelegationChain) {
+			t.Fatalf("Expected scope outside the holder's to be rejected, got %v", err)
+		}
+		if len(chain.Links) != 2 {
+			t.Fatalf("Expected failed Append to leave the chain unchanged, got %d links", len(chain.Links))
+		}
+
+		err = chain.Append(DelegationLink{
+			Delegate:     "sub-agent",
+			Scopes:       []string{"payments:read"},
+			Restrictions: &Restrictions{ValueLimits: &ValueLimits{MaxTransactionValue: 100, Currency: "EUR"}},
+			ExpiresAt:    chain.Links[1].ExpiresAt,
+		})
+		if err != nil {
+			t.Fatalf("Append() error: %v", err)
+		}
+		if chain.Links[2].Principal != "ai-agent" || chain.Holder() != "sub-agent" {
+			t.Errorf("Expected link from ai-agent to sub-agent, got %+v", chain.Links[2])
+		}
+	})
+
+	t.Run("Wildcard Scopes", func(t *testing.T) {
+		chain := testDelegationChain()
+		chain.Links[0].Scopes = []string{"payments:*", "reports:read"}
+		if err := chain.Validate(); err != nil {
+			t.Fatalf("Expected payments:* to cover the delegated payments scopes, got %v", err)
+		}
+		narrowed, err := chain.Narrow([]string{"payments:*", "reports:read"})
+		if err != nil {
+			t.Fatalf("Narrow() error: %v", err)
+		}
+		if len(narrowed) != 2 || narrowed[0] != "payments:approve" || narrowed[1] != "payments:read" {
+			t.Errorf("Expected the request narrowed to the holder's scopes, got %v", narrowed)
+		}
+		if !(&Token{Scopes: []string{"payments:*"}}).HasScope("payments:invoices:read") {
+			t.Error("Expected HasScope to honour wildcards")
+		}
+	})
+
+	t.Run("Issued Tokens Carry The Chain", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("GenerateKey() error: %v", err)
+		}
+		store := NewMemoryStore()
+		svc := NewService(Config{SigningKey: key, ValidityPeriod: time.Hour}, store)
+		ctx := context.Background()
+
+		_, err = svc.Issue(ctx, &Token{
+			ID: NewID(), Type: Access, Subject: "ai-agent", Scopes: []string{"reports:read"},
+			Metadata: &Metadata{Delegation: testDelegationChain()},
+		})
+		if !errors.Is(err, ErrInvalidDelegationChain) {
+			t.Fatalf("Expected undelegated scope to be rejected at issuance, got %v", err)
+		}
+
+		issued, err := svc.Issue(ctx, &Token{
+			ID: NewID(), Type: Access, Subject: "ai-agent", Scopes: []string{"payments:approve"},
+			Metadata: &Metadata{Delegation: testDelegationChain()},
+		})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		parsed, err := svc.Parse(ctx, issued.Value)
+		if err != nil {
+			t.Fatalf("Parse() error: %v", err)
+		}
+		chain := DelegationChainOf(parsed)
+		if chain == nil || len(chain.Links) != 2 || chain.Links[1].Restrictions.ValueLimits.MaxTransactionValue != 500 {
+			t.Fatalf("Expected the signed chain to round-trip, got %+v", chain)
+		}
+
+		stored, err := store.Get(ctx, issued.ID)
+		if err != nil {
+			t.Fatalf("Get() error: %v", err)
+		}
+		DelegationChainOf(stored).Links[0].Scopes[0] = "tampered"
+		again, _ := store.Get(ctx, issued.ID)
+		if DelegationChainOf(again).Links[0].Scopes[0] != "payments:approve" {
+			t.Error("Expected stores to return a deep copy of the chain")
+		}
+
+		lapsed := testDelegationChain()
+		lapsed.Links[1].ExpiresAt = time.Now().Add(50 * time.Millisecond)
+		issued, err = svc.Issue(ctx, &Token{
+			ID: NewID(), Type: Access, Subject: "ai-agent", Scopes: []string{"payments:read"},
+			Metadata: &Metadata{Delegation: lapsed},
+		})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		var verr *ValidationError
+		if err := svc.Validate(ctx, issued); !errors.As(err, &verr) || verr.Code != ValidationCodeExpired {
+			t.Errorf("Expected a lapsed link to expire the token, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// Delegation errors
+var (
+	// ErrInvalidDelegation indicates DelegationOptions that cannot be granted
+	ErrInvalidDelegation = errors.New("invalid delegation")
+
+	// ErrDelegationExpired indicates the delegation is past its ValidUntil
+	ErrDelegationExpired = errors.New("delegation expired")
+
+	// ErrValueLimitExceeded indicates a transaction above the delegated value limits
+	ErrValueLimitExceeded = errors.New("delegated value limit exceeded")
+)
+
+// Validate checks that the options describe a grant from a principal to
+// agentID that is still valid at now
+func (o DelegationOptions) Validate(agentID string, now time.Time) error {
+	switch {
+	case o.Principal == "" || agentID == "":
+		return fmt.Errorf("%w: principal and agent are required", ErrInvalidDelegation)
+	case o.Principal == agentID:
+		return fmt.Errorf("%w: %s cannot delegate to itself", ErrInvalidDelegation, agentID)
+	case o.Scope == "":
+		return fmt.Errorf("%w: scope is required", ErrInvalidDelegation)
+	case o.ValidUntil.IsZero():
+		return fmt.Errorf("%w: ValidUntil is required", ErrInvalidDelegation)
+	case !o.ValidUntil.After(now):
+		return fmt.Errorf("%w: ValidUntil %s is in the past", ErrInvalidDelegation, o.ValidUntil.Format(time.RFC3339))
+	}
+	if o.Restrictions != nil && o.Restrictions.ValueLimits != nil {
+		v := o.Restrictions.ValueLimits
+		if v.MaxTransactionValue < 0 || v.DailyLimit < 0 {
+			return fmt.Errorf("%w: value limits must not be negative", ErrInvalidDelegation)
+		}
+		if v.MaxTransactionValue > 0 && v.DailyLimit > 0 && v.MaxTransactionValue > v.DailyLimit {
+			return fmt.Errorf("%w: transaction limit exceeds the daily limit", ErrInvalidDelegation)
+		}
+		if v.Currency == "" {
+			return fmt.Errorf("%w: value limits need a currency", ErrInvalidDelegation)
+		}
+	}
+	if o.Attestation != nil && (o.Attestation.Type == "" || o.Attestation.AttesterID == "") {
+		return fmt.Errorf("%w: attestation needs a type and an attester", ErrInvalidDelegation)
+	}
+	return nil
+}
+
+// CheckDelegatedValue checks that a transaction of amount in currency is
+// within the value limits of every link of the token's delegation chain
+// and that the delegation is still in force at now
+func CheckDelegatedValue(t *Token, amount float64, currency string, now time.Time) error {
+	chain := DelegationChainOf(t)
+	if chain == nil {
+		return fmt.Errorf("%w: token carries no delegation", ErrInvalidDelegation)
+	}
+	if !now.Before(t.ExpiresAt) || !chain.ActiveAt(now) {
+		return ErrDelegationExpired
+	}
+	for _, link := range chain.Links {
+		if link.Restrictions == nil || link.Restrictions.ValueLimits == nil {
+			continue
+		}
+		v := link.Restrictions.ValueLimits
+		if currency != v.Currency {
+			return fmt.Errorf("%w: %s delegated limits in %s, not %s", ErrValueLimitExceeded, link.Principal, v.Currency, currency)
+		}
+		if v.MaxTransactionValue > 0 && amount > v.MaxTransactionValue {
+			return fmt.Errorf("%w: %.2f above the %.2f %s granted by %s", ErrValueLimitExceeded, amount, v.MaxTransactionValue, v.Currency, link.Principal)
+		}
+	}
+	return nil
+}
+
+// DelegatorConfig configures a Delegator
+type DelegatorConfig struct {
+	// Service signs and stores delegated tokens
+	Service ServiceAPI
+
+	// Events receives delegation_created and delegation_exercised events for
+	// the audit trail, including refused requests
+	Events events.EventHandler
+
+	// Attestations, if set, verifies notary and witness attestations before
+	// a delegation is issued or exercised
+	Attestations *AttestationVerifier
+
+	// Calendars resolves the holiday calendars named by the time
+	// constraints of delegated tokens
+	Calendars CalendarProvider
+
+	// Now returns the current time (default: time.Now)
+	Now func() time.Time
+}
+
+// Delegator issues RFC111 power-of-attorney tokens built by
+// NewDelegatedToken and checks transactions against their limits
+type Delegator struct {
+	config DelegatorConfig
+}
+
+// NewDelegator creates a delegator
+func NewDelegator(config DelegatorConfig) (*Delegator, error) {
+	if config.Service == nil {
+		return nil, fmt.Errorf("%w: service is required", ErrInvalidConfig)
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Delegator{config: config}, nil
+}
+
+// Issue validates the options, then signs and stores a delegated token for
+// agentID
+func (d *Delegator) Issue(ctx context.Context, agentID string, opts DelegationOptions) (*EnhancedToken, error) {
+	if err := opts.Validate(agentID, d.config.Now()); err != nil {
+		d.emit(ctx, events.ActionDelegationCreated, "", opts.Principal, agentID, err)
+		return nil, err
+	}
+	et := NewDelegatedToken(agentID, opts)
+	if d.config.Attestations != nil {
+		if err := d.config.Attestations.Verify(et.Token); err != nil {
+			d.emit(ctx, events.ActionDelegationCreated, et.ID, opts.Principal, agentID, err)
+			return nil, err
+		}
+	}
+	issued, err := d.config.Service.Issue(ctx, et.Token)
+	if err != nil {
+		d.emit(ctx, events.ActionDelegationCreated, et.ID, opts.Principal, agentID, err)
+		return nil, fmt.Errorf("failed to issue delegated token: %w", err)
+	}
+	et.Token = issued
+	d.emit(ctx, events.ActionDelegationCreated, et.ID, opts.Principal, agentID, nil,
+		"scope", opts.Scope, "valid_until", opts.ValidUntil.UTC().Format(time.RFC3339))
+	return et, nil
+}
+
+// Exercise validates a delegated token and its attestations and checks a
+// transaction against its ValidUntil, value limits and time restrictions,
+// including holidays and blackout dates. Every attempt is recorded. The
+// restrictions and attestations are read from the stored token, since the
+// presented one may be minimized for its audience.
+func (d *Delegator) Exercise(ctx context.Context, t *Token, amount float64, currency string) error {
+	err := d.config.Service.Validate(ctx, t)
+	if err == nil {
+		var stored *Token
+		if stored, err = d.config.Service.GetToken(ctx, t.ID); err == nil {
+			t = stored
+		} else {
+			err = fmt.Errorf("failed to load delegated token: %w", err)
+		}
+	}
+	if err == nil && d.config.Attestations != nil {
+		err = d.config.Attestations.Verify(t)
+	}
+	if err == nil {
+		err = CheckDelegatedValue(t, amount, currency, d.config.Now())
+	}
+	chain := DelegationChainOf(t)
+	if err == nil {
+		err = checkChainTime(ctx, chain, d.config.Now(), d.config.Calendars)
+	}
+	d.emit(ctx, events.ActionDelegationExercised, t.ID, chain.Root(), t.Subject, err,
+		"amount", strconv.FormatFloat(amount, 'f', -1, 64), "currency", currency)
+	return err
+}
+
+func (d *Delegator) emit(ctx context.Context, action events.EventAction, tokenID, principal, agent string, err error, kv ...string) {
+	if d.config.Events == nil {
+		return
+	}
+	status := events.StatusSuccess
+	if err != nil {
+		status = events.StatusFailure
+	}
+	event := events.NewAuditEvent(action, status).
+		WithContext(ctx).
+		WithSubject(agent).
+		WithResource(tokenID).
+		WithStringMetadata("principal", principal)
+	if err != nil {
+		event.Error = err.Error()
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		event = event.WithStringMetadata(kv[i], kv[i+1])
+	}
+	d.config.Events.Handle(event)
+}
@@ -0,0 +1,115 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+func TestDelegator(t *testing.T) {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	store := NewMemoryStore()
+	handler := &captureHandler{}
+	d, err := NewDelegator(DelegatorConfig{
+		Service: NewService(Config{SigningKey: key, ValidityPeriod: time.Hour}, store),
+		Events:  handler,
+	})
+	if err != nil {
+		t.Fatalf("NewDelegator() error: %v", err)
+	}
+	opts := DelegationOptions{
+		Principal: "owner-456",
+		Scope:     "sign_contract",
+		Restrictions: &Restrictions{
+			ValueLimits: &ValueLimits{MaxTransactionValue: 10000, DailyLimit: 20000, Currency: "EUR"},
+		},
+		Attestation: &Attestation{Type: "notary", AttesterID: "notary-xyz", AttestationDate: time.Now()},
+		ValidUntil:  time.Now().Add(24 * time.Hour),
+		Version:     1,
+	}
+
+	t.Run("Issue Stores Signed Delegation", func(t *testing.T) {
+		handler.events = nil
+		tok, err := d.Issue(ctx, "ai-agent-123", opts)
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		if tok.Value == "" || tok.Owner.OwnerID != "owner-456" || len(tok.Attestations) != 1 {
+			t.Fatalf("Unexpected delegated token %+v", tok)
+		}
+		stored, err := store.Get(ctx, tok.ID)
+		if err != nil {
+			t.Fatalf("Get() error: %v", err)
+		}
+		chain := DelegationChainOf(stored)
+		if chain.Root() != "owner-456" || chain.Holder() != "ai-agent-123" || chain.Links[0].Attestations[0].AttesterID != "notary-xyz" {
+			t.Errorf("Expected the grant to be stored with the token, got %+v", chain)
+		}
+		if len(handler.events) != 1 || handler.events[0].Action != string(events.ActionDelegationCreated) ||
+			handler.events[0].Status != string(events.StatusSuccess) {
+			t.Errorf("Expected a delegation_created event, got %+v", handler.events)
+		}
+	})
+
+	t.Run("Invalid Options Are Refused And Audited", func(t *testing.T) {
+		cases := map[string]func(o *DelegationOptions){
+			"Past ValidUntil":   func(o *DelegationOptions) { o.ValidUntil = time.Now().Add(-time.Minute) },
+			"Missing Scope":     func(o *DelegationOptions) { o.Scope = "" },
+			"Negative Limit":    func(o *DelegationOptions) { o.Restrictions.ValueLimits.MaxTransactionValue = -1 },
+			"Limit Above Daily": func(o *DelegationOptions) { o.Restrictions.ValueLimits.MaxTransactionValue = 50000 },
+			"Self Delegation":   func(o *DelegationOptions) { o.Principal = "ai-agent-123" },
+		}
+		for name, mutate := range cases {
+			t.Run(name, func(t *testing.T) {
+				handler.events = nil
+				bad := opts
+				bad.Restrictions = cloneRestrictions(opts.Restrictions)
+				mutate(&bad)
+				if _, err := d.Issue(ctx, "ai-agent-123", bad); !errors.Is(err, ErrInvalidDelegation) {
+					t.Fatalf("Expected ErrInvalidDelegation, got %v", err)
+				}
+				if len(handler.events) != 1 || handler.events[0].Status != string(events.StatusFailure) || handler.events[0].Error == "" {
+					t.Errorf("Expected a failed delegation_created event, got %+v", handler.events)
+				}
+			})
+		}
+	})
+
+	t.Run("Exercise Enforces MaxValue", func(t *testing.T) {
+		tok, err := d.Issue(ctx, "ai-agent-123", opts)
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		handler.events = nil
+		if err := d.Exercise(ctx, tok.Token, 9500, "EUR"); err != nil {
+			t.Errorf("Expected transaction within limits to pass, got %v", err)
+		}
+		if err := d.Exercise(ctx, tok.Token, 10500, "EUR"); !errors.Is(err, ErrValueLimitExceeded) {
+			t.Errorf("Expected ErrValueLimitExceeded, got %v", err)
+		}
+		if err := d.Exercise(ctx, tok.Token, 100, "USD"); !errors.Is(err, ErrValueLimitExceeded) {
+			t.Errorf("Expected other currency to be refused, got %v", err)
+		}
+		if len(handler.events) != 3 || handler.events[1].Action != string(events.ActionDelegationExercised) ||
+			handler.events[1].Status != string(events.StatusFailure) {
+			t.Errorf("Expected every attempt to be recorded, got %+v", handler.events)
+		}
+	})
+
+	t.Run("Exercise After ValidUntil", func(t *testing.T) {
+		tok := NewDelegatedToken("ai-agent-123", opts)
+		later := opts.ValidUntil.Add(time.Second)
+		if err := CheckDelegatedValue(tok.Token, 10, "EUR", later); !errors.Is(err, ErrDelegationExpired) {
+			t.Errorf("Expected ErrDelegationExpired, got %v", err)
+		}
+	})
+}
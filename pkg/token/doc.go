@@ -47,6 +47,52 @@
 //   - Revocation capabilities
 //   - Token validation and verification
 //
+// # Signing
+//
+// Service signs tokens as JWTs with the configured SigningKey. RS256, ES256
+// and EdDSA are supported; when SigningMethod is empty it is chosen from the
+// key type. Token metadata travels in the "gauth" claim, so Parse recovers
+// the RFC111 delegation fields from the token value alone:
+//
+//	svc := token.NewService(token.Config{SigningKey: key, ValidityPeriod: time.Hour}, store)
+//	issued, err := svc.Issue(ctx, &token.Token{ID: token.NewID(), Type: token.Access, Subject: "agent-1"})
+//	parsed, err := svc.Parse(ctx, issued.Value)
+//	err = svc.Validate(ctx, parsed)
+//
+// To let resource servers verify tokens offline, configure a KeySet instead
+// of SigningKey and serve KeySet.Handler at the issuer's jwks_uri. Tokens
+// then carry a kid header, and JWKS.KeySet turns a fetched document back
+// into a verification-only set. KeyRotator replaces the active key on a
+// schedule, keeps History previous keys published and destroys older ones
+// through a KeyBackend, which may be a KMS.
+//
+// # Sandbox Mode
+//
+// A service configured with Mode: ModeSandbox marks every token it issues
+// with the gauth_sandbox claim. Production services reject such tokens, and
+// sandbox services reject unmarked ones, so an integration environment that
+// shares keys or stores with production still cannot mint authority
+// production honours. SandboxConfig swaps the issuance checks and claims
+// enrichers, which typically call registries and other external providers,
+// for fakes.
+//
+// # Revocation
+//
+// Refresh and StepDown record the parent token in the derived token's
+// metadata. RevokeChain revokes a token together with everything derived
+// from it, leaves first, and emits a token_revoked event per token.
+// NewRevocationHandler serves the same operation as an RFC 7009 endpoint:
+//
+//	h, err := token.NewRevocationHandler(token.RevocationEndpointConfig{
+//	    Service: svc, Store: store, Cascade: true, Authenticate: authenticateClient,
+//	})
+//	mux.Handle("/revoke", h)
+//
+// Revalidator re-checks long-lived tokens on a schedule against current
+// policy, such as SubjectStatusCheck for suspended agents and
+// AllowedScopesCheck for removed scopes, and revokes those that fail. Passes
+// are paced by RatePerSecond so they do not overload the store.
+//
 // # Implementations
 //
 // This package provides the following implementations:
@@ -90,8 +90,15 @@ type TimeConstraints struct {
 	// AllowedTimeWindows specifies when operations are allowed
 	AllowedTimeWindows []TimeWindow `json:"allowed_time_windows"`
 
-	// TimeZone for the windows
+	// TimeZone for the windows, blackout dates and holidays
 	TimeZone string `json:"time_zone"`
+
+	// Calendars names holiday calendars (e.g. "XNYS") on whose holidays
+	// operations are not allowed; a CalendarProvider resolves them
+	Calendars []string `json:"calendars,omitempty"`
+
+	// BlackoutDates are dates (YYYY-MM-DD) on which operations are not allowed
+	BlackoutDates []string `json:"blackout_dates,omitempty"`
 }
 
 // TimeWindow defines an allowed time period
@@ -197,14 +204,34 @@ type DelegationOptions struct {
 	Version      int           // Version for tracking
 }
 
-// NewDelegatedToken creates an EnhancedToken for advanced delegation/attestation flows
+// NewDelegatedToken creates an EnhancedToken for advanced delegation/attestation
+// flows. The grant is also recorded as a one-link DelegationChain in the
+// token metadata, so it is signed and stored with the token when issued.
+// Use a Delegator to validate the options, issue the token and audit it.
 func NewDelegatedToken(agentID string, opts DelegationOptions) *EnhancedToken {
+	now := time.Now()
+	var attestations []Attestation
+	if opts.Attestation != nil {
+		attestations = []Attestation{*opts.Attestation}
+	}
 	return &EnhancedToken{
 		Token: &Token{
+			ID:        NewID(),
+			Type:      Access,
 			Subject:   agentID, // RFC111: agent or AI being delegated to
 			Scopes:    []string{opts.Scope},
 			ExpiresAt: opts.ValidUntil,
-			IssuedAt:  time.Now(),
+			IssuedAt:  now,
+			NotBefore: now,
+			Metadata: &Metadata{Delegation: &DelegationChain{Links: []DelegationLink{{
+				Principal:    opts.Principal,
+				Delegate:     agentID,
+				Scopes:       []string{opts.Scope},
+				Restrictions: cloneRestrictions(opts.Restrictions),
+				Attestations: attestations,
+				GrantedAt:    now,
+				ExpiresAt:    opts.ValidUntil,
+			}}}},
 		},
 		Owner: &OwnerInfo{
 			OwnerID: opts.Principal,
@@ -214,15 +241,10 @@ func NewDelegatedToken(agentID string, opts DelegationOptions) *EnhancedToken {
 			Restrictions:         opts.Restrictions,
 			DelegationGuidelines: []string{}, // Can be set as needed
 		},
-		Attestations: func() []Attestation {
-			if opts.Attestation != nil {
-				return []Attestation{*opts.Attestation}
-			}
-			return nil
-		}(),
+		Attestations: attestations,
 		Versions: []VersionInfo{{
 			Version:       opts.Version,
-			UpdatedAt:     time.Now(),
+			UpdatedAt:     now,
 			UpdatedBy:     opts.Principal,
 			ChangeType:    "delegation_created",
 			ChangeSummary: "Initial delegation issued.",
@@ -0,0 +1,199 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Claims enrichment errors
+var (
+	// ErrEnrichmentFailed indicates a fail-closed enricher could not supply claims
+	ErrEnrichmentFailed = errors.New("claims enrichment failed")
+
+	// ErrDuplicateEnricher indicates an enricher with the same name is already registered
+	ErrDuplicateEnricher = errors.New("claims enricher already registered")
+)
+
+// ClaimsEnricher computes additional claims for a token at issuance, such as
+// an org unit or an entitlement snapshot from an external provider
+type ClaimsEnricher interface {
+	// Name identifies the enricher in errors
+	Name() string
+
+	// Enrich returns claims to add to the token's metadata attributes
+	Enrich(ctx context.Context, token *Token) (map[string][]string, error)
+}
+
+// ClaimsEnricherFunc adapts a function to the ClaimsEnricher interface
+type ClaimsEnricherFunc struct {
+	EnricherName string
+	Fn           func(ctx context.Context, token *Token) (map[string][]string, error)
+}
+
+// Name implements ClaimsEnricher
+func (f ClaimsEnricherFunc) Name() string { return f.EnricherName }
+
+// Enrich implements ClaimsEnricher
+func (f ClaimsEnricherFunc) Enrich(ctx context.Context, token *Token) (map[string][]string, error) {
+	return f.Fn(ctx, token)
+}
+
+// FailurePolicy decides what happens when an enricher errors or times out
+type FailurePolicy int
+
+const (
+	// FailOpen issues the token without the enricher's claims
+	FailOpen FailurePolicy = iota
+
+	// FailClosed aborts issuance
+	FailClosed
+)
+
+// EnricherOptions configures how a registered enricher runs
+type EnricherOptions struct {
+	// Timeout bounds a single Enrich call (default: 500ms)
+	Timeout time.Duration
+
+	// Policy applies when the enricher fails (default: FailOpen)
+	Policy FailurePolicy
+}
+
+// EnrichmentError identifies the enricher that aborted issuance
+type EnrichmentError struct {
+	Enricher string
+	Cause    error
+}
+
+func (e *EnrichmentError) Error() string {
+	return fmt.Sprintf("claims enricher %s failed: %v", e.Enricher, e.Cause)
+}
+
+// Unwrap returns the underlying cause
+func (e *EnrichmentError) Unwrap() error { return e.Cause }
+
+// Is reports ErrEnrichmentFailed for any aborted enrichment
+func (e *EnrichmentError) Is(target error) bool { return target == ErrEnrichmentFailed }
+
+type registeredEnricher struct {
+	enricher ClaimsEnricher
+	options  EnricherOptions
+}
+
+// ClaimsEnrichment runs registered enrichers in order during issuance.
+// Claims are merged into Metadata.Attributes; attributes already present on
+// the token are never overwritten, so caller-supplied values win.
+type ClaimsEnrichment struct {
+	mu        sync.RWMutex
+	enrichers []registeredEnricher
+
+	// OnError, when set, is told about fail-open enricher failures
+	OnError func(enricher string, err error)
+}
+
+// NewClaimsEnrichment creates an empty enrichment registry
+func NewClaimsEnrichment() *ClaimsEnrichment {
+	return &ClaimsEnrichment{}
+}
+
+// Register appends an enricher
+func (e *ClaimsEnrichment) Register(enricher ClaimsEnricher, options EnricherOptions) error {
+	if options.Timeout <= 0 {
+		options.Timeout = 500 * time.Millisecond
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range e.enrichers {
+		if r.enricher.Name() == enricher.Name() {
+			return fmt.Errorf("%w: %s", ErrDuplicateEnricher, enricher.Name())
+		}
+	}
+	e.enrichers = append(e.enrichers, registeredEnricher{enricher: enricher, options: options})
+	return nil
+}
+
+// Unregister removes an enricher by name
+func (e *ClaimsEnrichment) Unregister(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, r := range e.enrichers {
+		if r.enricher.Name() == name {
+			e.enrichers = append(e.enrichers[:i], e.enrichers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Enrichers returns the names of the registered enrichers in execution order
+func (e *ClaimsEnrichment) Enrichers() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	names := make([]string, len(e.enrichers))
+	for i, r := range e.enrichers {
+		names[i] = r.enricher.Name()
+	}
+	return names
+}
+
+// Enrich runs every enricher against the token and merges the claims
+func (e *ClaimsEnrichment) Enrich(ctx context.Context, token *Token) error {
+	e.mu.RLock()
+	enrichers := append([]registeredEnricher(nil), e.enrichers...)
+	e.mu.RUnlock()
+
+	for _, r := range enrichers {
+		claims, err := runEnricher(ctx, r, token)
+		if err != nil {
+			if r.options.Policy == FailClosed {
+				return &EnrichmentError{Enricher: r.enricher.Name(), Cause: err}
+			}
+			if e.OnError != nil {
+				e.OnError(r.enricher.Name(), err)
+			}
+			continue
+		}
+		if len(claims) == 0 {
+			continue
+		}
+		if token.Metadata == nil {
+			token.Metadata = &Metadata{}
+		}
+		if token.Metadata.Attributes == nil {
+			token.Metadata.Attributes = make(map[string][]string, len(claims))
+		}
+		for k, v := range claims {
+			if _, exists := token.Metadata.Attributes[k]; !exists {
+				token.Metadata.Attributes[k] = v
+			}
+		}
+	}
+	return nil
+}
+
+// runEnricher calls the enricher with its timeout. The enricher sees a deep
+// copy of the token, so a plugin that outlives its deadline cannot race with
+// issuance or change the token's metadata.
+func runEnricher(ctx context.Context, r registeredEnricher, token *Token) (map[string][]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.options.Timeout)
+	defer cancel()
+
+	snapshot := copyToken(token)
+	type result struct {
+		claims map[string][]string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		claims, err := r.enricher.Enrich(ctx, snapshot)
+		done <- result{claims, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.claims, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,108 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEnricherSeesCopy(t *testing.T) {
+	enrichment := NewClaimsEnrichment()
+	meddler := ClaimsEnricherFunc{EnricherName: "meddler", Fn: func(_ context.Context, tok *Token) (map[string][]string, error) {
+		tok.Scopes[0] = "admin"
+		tok.Metadata.AppData["owner"] = "mallory"
+		tok.Metadata.Attributes["region"][0] = "xx"
+		return nil, nil
+	}}
+	if err := enrichment.Register(meddler, EnricherOptions{}); err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+	tok := &Token{
+		Scopes: []string{"read"},
+		Metadata: &Metadata{
+			AppData:    map[string]string{"owner": "alice"},
+			Attributes: map[string][]string{"region": {"eu"}},
+		},
+	}
+	if err := enrichment.Enrich(context.Background(), tok); err != nil {
+		t.Fatalf("Enrich() error: %v", err)
+	}
+	if tok.Scopes[0] != "read" || tok.Metadata.AppData["owner"] != "alice" || tok.Metadata.Attributes["region"][0] != "eu" {
+		t.Errorf("Expected the enricher's changes not to reach the token, got %v %+v", tok.Scopes, tok.Metadata)
+	}
+}
+
+func TestClaimsEnrichment(t *testing.T) {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+
+	orgUnit := ClaimsEnricherFunc{EnricherName: "org_unit", Fn: func(_ context.Context, tok *Token) (map[string][]string, error) {
+		return map[string][]string{"org_unit": {"finance"}, "region": {"us"}}, nil
+	}}
+	slow := ClaimsEnricherFunc{EnricherName: "entitlements", Fn: func(ctx context.Context, _ *Token) (map[string][]string, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+
+	enrichment := NewClaimsEnrichment()
+	if err := enrichment.Register(orgUnit, EnricherOptions{}); err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+	if err := enrichment.Register(orgUnit, EnricherOptions{}); !errors.Is(err, ErrDuplicateEnricher) {
+		t.Errorf("Expected ErrDuplicateEnricher, got %v", err)
+	}
+	var failed []string
+	enrichment.OnError = func(name string, _ error) { failed = append(failed, name) }
+	if err := enrichment.Register(slow, EnricherOptions{Timeout: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+
+	svc := NewService(Config{
+		SigningKey:      key,
+		ValidityPeriod:  time.Hour,
+		ClaimsEnrichers: enrichment,
+	}, NewMemoryStore(time.Hour))
+
+	t.Run("Fail Open", func(t *testing.T) {
+		issued, err := svc.Issue(ctx, &Token{
+			ID:       NewID(),
+			Type:     Access,
+			Subject:  "user-1",
+			Metadata: &Metadata{Attributes: map[string][]string{"region": {"eu"}}},
+		})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		attrs := issued.Metadata.Attributes
+		if len(attrs["org_unit"]) != 1 || attrs["org_unit"][0] != "finance" {
+			t.Errorf("Expected org_unit claim, got %v", attrs)
+		}
+		if attrs["region"][0] != "eu" {
+			t.Errorf("Expected caller-supplied region to win, got %v", attrs["region"])
+		}
+		if len(failed) != 1 || failed[0] != "entitlements" {
+			t.Errorf("Expected entitlements failure to be reported, got %v", failed)
+		}
+	})
+
+	t.Run("Fail Closed", func(t *testing.T) {
+		enrichment.Unregister("entitlements")
+		if err := enrichment.Register(slow, EnricherOptions{Timeout: 10 * time.Millisecond, Policy: FailClosed}); err != nil {
+			t.Fatalf("Register() error: %v", err)
+		}
+		_, err := svc.Issue(ctx, &Token{ID: NewID(), Type: Access, Subject: "user-1"})
+		var enrichErr *EnrichmentError
+		if !errors.As(err, &enrichErr) || enrichErr.Enricher != "entitlements" {
+			t.Fatalf("Expected EnrichmentError from entitlements, got %v", err)
+		}
+		if !errors.Is(err, ErrEnrichmentFailed) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected ErrEnrichmentFailed wrapping deadline, got %v", err)
+		}
+	})
+}
@@ -55,6 +55,12 @@ var (
 
 	// ErrMissingClaims indicates required claims are missing
 	ErrMissingClaims = errors.New("missing required claims")
+
+	// ErrInvalidFilter indicates a List filter that cannot be applied
+	ErrInvalidFilter = errors.New("invalid token filter")
+
+	// ErrInvalidCursor indicates a malformed or mismatched pagination cursor
+	ErrInvalidCursor = errors.New("invalid list cursor")
 )
 
 // ValidationErrorCode type for standardized validation error codes
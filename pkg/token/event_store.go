@@ -0,0 +1,484 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// TokenEventType names a token lifecycle event
+type TokenEventType string
+
+const (
+	// TokenEventIssued records a token being stored
+	TokenEventIssued TokenEventType = "issued"
+	// TokenEventUsed records a token being presented
+	TokenEventUsed TokenEventType = "used"
+	// TokenEventRotated records a token being replaced by a new one
+	TokenEventRotated TokenEventType = "rotated"
+	// TokenEventRevoked records a token being revoked
+	TokenEventRevoked TokenEventType = "revoked"
+	// TokenEventDeleted records a token being removed
+	TokenEventDeleted TokenEventType = "deleted"
+	// TokenEventExpired records an expired token being cleaned up
+	TokenEventExpired TokenEventType = "expired"
+)
+
+// TokenEvent is one entry of the token event log. The token state of an
+// EventSourcedStore is exactly the fold of its events.
+type TokenEvent struct {
+	// Seq is assigned by the log and increases by one per event
+	Seq  uint64         `json:"seq"`
+	Type TokenEventType `json:"type"`
+	// Key is the store key the event applies to
+	Key string    `json:"key"`
+	At  time.Time `json:"at"`
+	// Token is the full token for issued and rotated events
+	Token *Token `json:"token,omitempty"`
+	// PreviousKey is the replaced key of a rotated event
+	PreviousKey string `json:"previous_key,omitempty"`
+	// Revocation is set on revoked events
+	Revocation    *RevocationStatus `json:"revocation,omitempty"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+}
+
+// EventLog is an append-only log of token events
+type EventLog interface {
+	// Append assigns the next sequence number to event and persists it
+	Append(ctx context.Context, event *TokenEvent) error
+
+	// Replay calls fn for every event after the given sequence number, in
+	// order
+	Replay(ctx context.Context, after uint64, fn func(*TokenEvent) error) error
+}
+
+// TokenSnapshot is the folded token state up to and including Seq
+type TokenSnapshot struct {
+	Seq     uint64            `json:"seq"`
+	TakenAt time.Time         `json:"taken_at"`
+	Tokens  map[string]*Token `json:"tokens"`
+}
+
+// SnapshotStore keeps snapshots so that loading does not replay the whole log
+type SnapshotStore interface {
+	SaveSnapshot(ctx context.Context, snapshot *TokenSnapshot) error
+
+	// LatestSnapshot returns nil and no error when there is no snapshot
+	LatestSnapshot(ctx context.Context) (*TokenSnapshot, error)
+}
+
+// MemoryEventLog keeps token events in memory
+type MemoryEventLog struct {
+	mu     sync.RWMutex
+	events []*TokenEvent
+}
+
+// NewMemoryEventLog creates an empty in-memory event log
+func NewMemoryEventLog() *MemoryEventLog {
+	return &MemoryEventLog{}
+}
+
+// Append implements EventLog
+func (l *MemoryEventLog) Append(_ context.Context, event *TokenEvent) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	event.Seq = uint64(len(l.events)) + 1
+	cp := *event
+	l.events = append(l.events, &cp)
+	return nil
+}
+
+// Replay implements EventLog
+func (l *MemoryEventLog) Replay(ctx context.Context, after uint64, fn func(*TokenEvent) error) error {
+	l.mu.RLock()
+	var pending []*TokenEvent
+	if after < uint64(len(l.events)) {
+		pending = append(pending, l.events[after:]...)
+	}
+	l.mu.RUnlock()
+
+	for _, event := range pending {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		cp := *event
+		if err := fn(&cp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MemorySnapshotStore keeps the latest snapshot in memory
+type MemorySnapshotStore struct {
+	mu     sync.RWMutex
+	latest *TokenSnapshot
+}
+
+// NewMemorySnapshotStore creates an empty in-memory snapshot store
+func NewMemorySnapshotStore() *MemorySnapshotStore {
+	return &MemorySnapshotStore{}
+}
+
+// SaveSnapshot implements SnapshotStore
+func (s *MemorySnapshotStore) SaveSnapshot(_ context.Context, snapshot *TokenSnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latest = snapshot
+	return nil
+}
+
+// LatestSnapshot implements SnapshotStore
+func (s *MemorySnapshotStore) LatestSnapshot(context.Context) (*TokenSnapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.latest, nil
+}
+
+// EventSourcedConfig configures an EventSourcedStore
+type EventSourcedConfig struct {
+	// Log is the authoritative event log
+	Log EventLog
+
+	// Snapshots stores periodic snapshots (optional)
+	Snapshots SnapshotStore
+
+	// SnapshotEvery is the number of events between snapshots (default: 1000)
+	SnapshotEvery int
+}
+
+// EventSourcedStore is a Store whose state is derived from an append-only
+// event log. Every write appends an event before it changes the in-memory
+// projection, so the log is the audit trail and the state at once and the
+// two cannot drift. Instances sharing a log call CatchUp to apply events
+// written by others; writes should go through a single instance.
+type EventSourcedStore struct {
+	config EventSourcedConfig
+	mu     sync.RWMutex
+	tokens map[string]*Token
+	holds  LegalHoldChecker
+
+	seq           uint64
+	sinceSnapshot int
+}
+
+// NewEventSourcedStore loads the latest snapshot, replays the events after
+// it and returns the resulting store
+func NewEventSourcedStore(ctx context.Context, config EventSourcedConfig) (*EventSourcedStore, error) {
+	if config.Log == nil {
+		return nil, fmt.Errorf("%w: event log is required", ErrInvalidConfig)
+	}
+	if config.SnapshotEvery <= 0 {
+		config.SnapshotEvery = 1000
+	}
+	s := &EventSourcedStore{config: config, tokens: make(map[string]*Token)}
+
+	if config.Snapshots != nil {
+		snap, err := config.Snapshots.LatestSnapshot(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load snapshot: %w", err)
+		}
+		if snap != nil {
+			for key, t := range snap.Tokens {
+				s.tokens[key] = copyToken(t)
+			}
+			s.seq = snap.Seq
+		}
+	}
+	if err := s.CatchUp(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// CatchUp applies events appended to the log since the last one applied
+func (s *EventSourcedStore) CatchUp(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.catchUp(ctx)
+}
+
+func (s *EventSourcedStore) catchUp(ctx context.Context) error {
+	err := s.config.Log.Replay(ctx, s.seq, func(event *TokenEvent) error {
+		if event.Seq != s.seq+1 {
+			return fmt.Errorf("%w: event log gap after seq %d", ErrStorageFailure, s.seq)
+		}
+		s.apply(event)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replay token events: %w", err)
+	}
+	return nil
+}
+
+// apply folds one event into the projection
+func (s *EventSourcedStore) apply(event *TokenEvent) {
+	s.seq = event.Seq
+	s.sinceSnapshot++
+
+	switch event.Type {
+	case TokenEventIssued:
+		if event.Token != nil {
+			s.tokens[event.Key] = copyToken(event.Token)
+		}
+	case TokenEventRotated:
+		delete(s.tokens, event.PreviousKey)
+		if event.Token != nil {
+			s.tokens[event.Key] = copyToken(event.Token)
+		}
+	case TokenEventUsed:
+		if t, ok := s.tokens[event.Key]; ok {
+			at := event.At
+			t.LastUsedAt = &at
+		}
+	case TokenEventRevoked:
+		if t, ok := s.tokens[event.Key]; ok && t.RevocationStatus == nil && event.Revocation != nil {
+			rs := *event.Revocation
+			t.RevocationStatus = &rs
+		}
+	case TokenEventDeleted, TokenEventExpired:
+		delete(s.tokens, event.Key)
+	}
+}
+
+// record appends an event and applies it. The caller holds the write lock.
+func (s *EventSourcedStore) record(ctx context.Context, event *TokenEvent) error {
+	if err := s.catchUp(ctx); err != nil {
+		return err
+	}
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	if event.CorrelationID == "" {
+		event.CorrelationID = events.CorrelationIDFromContext(ctx)
+	}
+	if err := s.config.Log.Append(ctx, event); err != nil {
+		return fmt.Errorf("%w: failed to append %s event: %v", ErrStorageFailure, event.Type, err)
+	}
+	s.apply(event)
+
+	if s.config.Snapshots != nil && s.sinceSnapshot >= s.config.SnapshotEvery {
+		// A failed snapshot only makes the next load slower
+		_ = s.snapshot(ctx)
+	}
+	return nil
+}
+
+// Snapshot saves the current state to the snapshot store
+func (s *EventSourcedStore) Snapshot(ctx context.Context) error {
+	if s.config.Snapshots == nil {
+		return fmt.Errorf("%w: no snapshot store configured", ErrInvalidConfig)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot(ctx)
+}
+
+func (s *EventSourcedStore) snapshot(ctx context.Context) error {
+	snap := &TokenSnapshot{Seq: s.seq, TakenAt: time.Now(), Tokens: make(map[string]*Token, len(s.tokens))}
+	for key, t := range s.tokens {
+		snap.Tokens[key] = copyToken(t)
+	}
+	if err := s.config.Snapshots.SaveSnapshot(ctx, snap); err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	s.sinceSnapshot = 0
+	return nil
+}
+
+// Seq returns the sequence number of the last applied event
+func (s *EventSourcedStore) Seq() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.seq
+}
+
+// History returns every logged event for the key, oldest first. A rotated
+// event is listed under both the new and the replaced key.
+func (s *EventSourcedStore) History(ctx context.Context, key string) ([]*TokenEvent, error) {
+	var history []*TokenEvent
+	err := s.config.Log.Replay(ctx, 0, func(event *TokenEvent) error {
+		if event.Key == key || event.PreviousKey == key {
+			history = append(history, event)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay token events: %w", err)
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].Seq < history[j].Seq })
+	return history, nil
+}
+
+// Save implements the Store interface
+func (s *EventSourcedStore) Save(ctx context.Context, key string, token *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.record(ctx, &TokenEvent{Type: TokenEventIssued, Key: key, Token: copyToken(token)})
+}
+
+// Get implements the Store interface
+func (s *EventSourcedStore) Get(ctx context.Context, key string) (*Token, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.tokens[key]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	return copyToken(t), nil
+}
+
+// RecordUse logs that the token was presented and sets its LastUsedAt
+func (s *EventSourcedStore) RecordUse(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tokens[key]; !ok {
+		return ErrTokenNotFound
+	}
+	return s.record(ctx, &TokenEvent{Type: TokenEventUsed, Key: key})
+}
+
+// Delete implements the Store interface
+func (s *EventSourcedStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tokens[key]; !ok {
+		return ErrTokenNotFound
+	}
+	return s.record(ctx, &TokenEvent{Type: TokenEventDeleted, Key: key})
+}
+
+// List implements the Store interface
+func (s *EventSourcedStore) List(ctx context.Context, filter Filter) ([]*Token, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var matches []*Token
+	for _, t := range s.tokens {
+		if matchesFilter(t, filter) {
+			matches = append(matches, copyToken(t))
+		}
+	}
+	return applyPage(matches, filter)
+}
+
+// Rotate implements the Store interface
+func (s *EventSourcedStore) Rotate(ctx context.Context, old, newToken *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tokens[old.ID]; !ok {
+		return ErrTokenNotFound
+	}
+	return s.record(ctx, &TokenEvent{
+		Type:        TokenEventRotated,
+		Key:         newToken.ID,
+		PreviousKey: old.ID,
+		Token:       copyToken(newToken),
+	})
+}
+
+// Revoke implements the Store interface. The token is kept, marked revoked,
+// so that its history stays queryable.
+func (s *EventSourcedStore) Revoke(ctx context.Context, token *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.tokens[token.ID]
+	if !ok {
+		return ErrTokenNotFound
+	}
+	if stored.RevocationStatus != nil {
+		return nil
+	}
+	status := &RevocationStatus{RevokedAt: time.Now()}
+	if token.RevocationStatus != nil {
+		rs := *token.RevocationStatus
+		status = &rs
+	}
+	return s.record(ctx, &TokenEvent{Type: TokenEventRevoked, Key: token.ID, Revocation: status})
+}
+
+// Validate implements the Store interface
+func (s *EventSourcedStore) Validate(ctx context.Context, token *Token) error {
+	stored, err := s.Get(ctx, token.ID)
+	if err != nil {
+		return err
+	}
+	if stored.Value != token.Value {
+		return ErrInvalidToken
+	}
+	if stored.RevocationStatus != nil {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// Refresh implements the Store interface
+func (s *EventSourcedStore) Refresh(ctx context.Context, refreshToken *Token) (*Token, error) {
+	if err := s.Validate(ctx, refreshToken); err != nil {
+		return nil, err
+	}
+	if refreshToken.Type != Refresh {
+		return nil, ErrInvalidType
+	}
+	return nil, ErrInvalidConfig // Actual refresh should be handled by Service
+}
+
+// Count implements the Store interface
+func (s *EventSourcedStore) Count(ctx context.Context, filter Filter) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var count int64
+	for _, t := range s.tokens {
+		if matchesFilter(t, filter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Cleanup implements the Store interface. Each removed token is logged as an
+// expired event.
+func (s *EventSourcedStore) Cleanup(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var expired []string
+	for key, t := range s.tokens {
+		if t.ExpiresAt.Before(now) && !isHeld(s.holds, t) {
+			expired = append(expired, key)
+		}
+	}
+	sort.Strings(expired)
+	for _, key := range expired {
+		if err := s.record(ctx, &TokenEvent{Type: TokenEventExpired, Key: key, At: now}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetLegalHolds makes Cleanup keep expired tokens of held subjects
+func (s *EventSourcedStore) SetLegalHolds(holds LegalHoldChecker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.holds = holds
+}
+
+// Close implements the Store interface
+func (s *EventSourcedStore) Close() error {
+	return nil
+}
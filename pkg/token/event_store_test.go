@@ -0,0 +1,146 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/audit"
+)
+
+// countingLog counts replayed events
+type countingLog struct {
+	*MemoryEventLog
+	replayed int
+}
+
+func (l *countingLog) Replay(ctx context.Context, after uint64, fn func(*TokenEvent) error) error {
+	return l.MemoryEventLog.Replay(ctx, after, func(e *TokenEvent) error {
+		l.replayed++
+		return fn(e)
+	})
+}
+
+func TestEventSourcedStore(t *testing.T) {
+	ctx := context.Background()
+	newToken := func(id string) *Token {
+		return &Token{ID: id, Value: "v-" + id, Type: Access, Subject: "agent-1", ExpiresAt: time.Now().Add(time.Hour)}
+	}
+	lifecycle := func(t *testing.T, s *EventSourcedStore) {
+		t.Helper()
+		for _, id := range []string{"a", "b", "c"} {
+			if err := s.Save(ctx, id, newToken(id)); err != nil {
+				t.Fatalf("Save() error: %v", err)
+			}
+		}
+		if err := s.RecordUse(ctx, "a"); err != nil {
+			t.Fatalf("RecordUse() error: %v", err)
+		}
+		if err := s.Rotate(ctx, newToken("a"), newToken("a2")); err != nil {
+			t.Fatalf("Rotate() error: %v", err)
+		}
+		if err := s.Revoke(ctx, &Token{ID: "b", RevocationStatus: &RevocationStatus{Reason: ReasonCompromise}}); err != nil {
+			t.Fatalf("Revoke() error: %v", err)
+		}
+		if err := s.Delete(ctx, "c"); err != nil {
+			t.Fatalf("Delete() error: %v", err)
+		}
+	}
+	assertState := func(t *testing.T, s *EventSourcedStore) {
+		t.Helper()
+		if _, err := s.Get(ctx, "a"); !errors.Is(err, ErrTokenNotFound) {
+			t.Errorf("Expected rotated token gone, got %v", err)
+		}
+		if _, err := s.Get(ctx, "a2"); err != nil {
+			t.Errorf("Expected new token, got %v", err)
+		}
+		if err := s.Validate(ctx, newToken("b")); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("Expected ErrTokenRevoked, got %v", err)
+		}
+		if n, _ := s.Count(ctx, Filter{}); n != 2 {
+			t.Errorf("Expected 2 tokens, got %d", n)
+		}
+	}
+
+	t.Run("State Rebuilt From Log", func(t *testing.T) {
+		log := NewMemoryEventLog()
+		s, err := NewEventSourcedStore(ctx, EventSourcedConfig{Log: log})
+		if err != nil {
+			t.Fatalf("NewEventSourcedStore() error: %v", err)
+		}
+		lifecycle(t, s)
+		assertState(t, s)
+
+		rebuilt, err := NewEventSourcedStore(ctx, EventSourcedConfig{Log: log})
+		if err != nil {
+			t.Fatalf("NewEventSourcedStore() error: %v", err)
+		}
+		assertState(t, rebuilt)
+		if rebuilt.Seq() != s.Seq() || s.Seq() != 7 {
+			t.Errorf("Expected seq 7, got %d and %d", s.Seq(), rebuilt.Seq())
+		}
+	})
+
+	t.Run("History", func(t *testing.T) {
+		s, _ := NewEventSourcedStore(ctx, EventSourcedConfig{Log: NewMemoryEventLog()})
+		lifecycle(t, s)
+		history, err := s.History(ctx, "a")
+		if err != nil {
+			t.Fatalf("History() error: %v", err)
+		}
+		var types []TokenEventType
+		for _, e := range history {
+			types = append(types, e.Type)
+		}
+		if len(types) != 3 || types[0] != TokenEventIssued || types[1] != TokenEventUsed || types[2] != TokenEventRotated {
+			t.Errorf("Unexpected history %v", types)
+		}
+	})
+
+	t.Run("Snapshot Shortens Replay", func(t *testing.T) {
+		log := &countingLog{MemoryEventLog: NewMemoryEventLog()}
+		snapshots := NewMemorySnapshotStore()
+		s, _ := NewEventSourcedStore(ctx, EventSourcedConfig{Log: log, Snapshots: snapshots, SnapshotEvery: 5})
+		lifecycle(t, s)
+
+		log.replayed = 0
+		rebuilt, err := NewEventSourcedStore(ctx, EventSourcedConfig{Log: log, Snapshots: snapshots})
+		if err != nil {
+			t.Fatalf("NewEventSourcedStore() error: %v", err)
+		}
+		assertState(t, rebuilt)
+		if log.replayed != 2 {
+			t.Errorf("Expected 2 events replayed after the snapshot, got %d", log.replayed)
+		}
+	})
+
+	t.Run("Audit Backed Log", func(t *testing.T) {
+		storage, err := audit.NewFileStorage(audit.FileConfig{Directory: t.TempDir()})
+		if err != nil {
+			t.Fatalf("NewFileStorage() error: %v", err)
+		}
+		defer storage.Close()
+		log, err := NewAuditEventLog(ctx, storage)
+		if err != nil {
+			t.Fatalf("NewAuditEventLog() error: %v", err)
+		}
+		s, _ := NewEventSourcedStore(ctx, EventSourcedConfig{Log: log})
+		lifecycle(t, s)
+
+		entries, _ := storage.Search(ctx, &audit.Filter{Actions: []string{"token.revoked"}})
+		if len(entries) != 1 || entries[0].TargetID != "b" {
+			t.Fatalf("Expected revocation in the audit trail, got %+v", entries)
+		}
+
+		reopened, err := NewAuditEventLog(ctx, storage)
+		if err != nil {
+			t.Fatalf("NewAuditEventLog() error: %v", err)
+		}
+		rebuilt, err := NewEventSourcedStore(ctx, EventSourcedConfig{Log: reopened})
+		if err != nil {
+			t.Fatalf("NewEventSourcedStore() error: %v", err)
+		}
+		assertState(t, rebuilt)
+	})
+}
@@ -0,0 +1,397 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/metrics"
+)
+
+// FailoverConfig configures a FailoverStore
+type FailoverConfig struct {
+	// Primary receives every write and serves reads while it is healthy
+	Primary Store
+
+	// Secondary mirrors the primary and serves reads when the primary fails
+	Secondary Store
+
+	// Name labels the store in metrics (default: "failover")
+	Name string
+
+	// QueueSize bounds the mirror queue; writes beyond it are not mirrored
+	// until the next reconciliation (default: 1024)
+	QueueSize int
+
+	// MirrorTimeout bounds each mirrored write (default: 5s)
+	MirrorTimeout time.Duration
+
+	// Metrics records mirror results, fallback reads and divergence
+	Metrics *metrics.Collector
+
+	// OnMirrorError is called when a mirrored write fails or is dropped
+	OnMirrorError func(error)
+}
+
+// FailoverStats counts mirror and fallback activity
+type FailoverStats struct {
+	Mirrored      int64 `json:"mirrored"`
+	MirrorFailed  int64 `json:"mirror_failed"`
+	MirrorDropped int64 `json:"mirror_dropped"`
+	FallbackReads int64 `json:"fallback_reads"`
+}
+
+// ReconcileReport describes one reconciliation pass
+type ReconcileReport struct {
+	Checked  int       `json:"checked"`
+	Repaired int       `json:"repaired"`
+	Removed  int       `json:"removed"`
+	RanAt    time.Time `json:"ran_at"`
+}
+
+// Diverged returns the number of tokens that differed between the stores
+func (r *ReconcileReport) Diverged() int {
+	return r.Repaired + r.Removed
+}
+
+// FailoverStore writes to a primary store and mirrors each write to a
+// secondary in the background. Reads fall back to the secondary when the
+// primary fails with anything other than a definitive answer such as
+// ErrTokenNotFound. Writes are never served by the secondary alone.
+//
+// Tokens are mirrored under their ID, which is how Service stores them;
+// Reconcile assumes the same keying.
+type FailoverStore struct {
+	config FailoverConfig
+	queue  chan mirrorOp
+	done   chan struct{}
+	mu     sync.RWMutex
+	closed bool
+
+	mirrored      atomic.Int64
+	mirrorFailed  atomic.Int64
+	mirrorDropped atomic.Int64
+	fallbackReads atomic.Int64
+}
+
+// mirrorOp is a queued secondary write, or a flush marker when run is nil
+type mirrorOp struct {
+	run    func(ctx context.Context) error
+	marker chan struct{}
+}
+
+// NewFailoverStore creates a failover store and starts its mirror worker
+func NewFailoverStore(config FailoverConfig) (*FailoverStore, error) {
+	if config.Primary == nil || config.Secondary == nil {
+		return nil, fmt.Errorf("%w: primary and secondary stores are required", ErrInvalidConfig)
+	}
+	if config.Name == "" {
+		config.Name = "failover"
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 1024
+	}
+	if config.MirrorTimeout <= 0 {
+		config.MirrorTimeout = 5 * time.Second
+	}
+	s := &FailoverStore{
+		config: config,
+		queue:  make(chan mirrorOp, config.QueueSize),
+		done:   make(chan struct{}),
+	}
+	go s.mirrorLoop()
+	return s, nil
+}
+
+func (s *FailoverStore) mirrorLoop() {
+	defer close(s.done)
+	for op := range s.queue {
+		if op.run == nil {
+			close(op.marker)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.MirrorTimeout)
+		err := op.run(ctx)
+		cancel()
+		if err != nil && !errors.Is(err, ErrTokenNotFound) {
+			s.mirrorFailed.Add(1)
+			s.recordMirror("failed")
+			if s.config.OnMirrorError != nil {
+				s.config.OnMirrorError(fmt.Errorf("failed to mirror write: %w", err))
+			}
+			continue
+		}
+		s.mirrored.Add(1)
+		s.recordMirror("ok")
+	}
+}
+
+// mirror queues a write for the secondary without blocking the caller
+func (s *FailoverStore) mirror(op func(ctx context.Context, secondary Store) error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.queue <- mirrorOp{run: func(ctx context.Context) error { return op(ctx, s.config.Secondary) }}:
+	default:
+		s.mirrorDropped.Add(1)
+		s.recordMirror("dropped")
+		if s.config.OnMirrorError != nil {
+			s.config.OnMirrorError(errors.New("mirror queue full, write not mirrored"))
+		}
+	}
+}
+
+func (s *FailoverStore) recordMirror(result string) {
+	if s.config.Metrics != nil {
+		s.config.Metrics.RecordStoreMirror(s.config.Name, result)
+	}
+}
+
+// primaryDown reports whether err means the primary could not answer, as
+// opposed to answering negatively
+func primaryDown(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, definitive := range []error{ErrTokenNotFound, ErrTokenRevoked, ErrInvalidToken, ErrInvalidType, ErrInvalidConfig} {
+		if errors.Is(err, definitive) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *FailoverStore) fallback(operation string) {
+	s.fallbackReads.Add(1)
+	if s.config.Metrics != nil {
+		s.config.Metrics.RecordStoreFallbackRead(s.config.Name, operation)
+	}
+}
+
+// Save implements the Store interface
+func (s *FailoverStore) Save(ctx context.Context, key string, token *Token) error {
+	if err := s.config.Primary.Save(ctx, key, token); err != nil {
+		return err
+	}
+	cp := *token
+	s.mirror(func(ctx context.Context, secondary Store) error { return secondary.Save(ctx, key, &cp) })
+	return nil
+}
+
+// Get implements the Store interface
+func (s *FailoverStore) Get(ctx context.Context, key string) (*Token, error) {
+	t, err := s.config.Primary.Get(ctx, key)
+	if primaryDown(err) {
+		s.fallback("get")
+		return s.config.Secondary.Get(ctx, key)
+	}
+	return t, err
+}
+
+// Delete implements the Store interface
+func (s *FailoverStore) Delete(ctx context.Context, key string) error {
+	if err := s.config.Primary.Delete(ctx, key); err != nil {
+		return err
+	}
+	s.mirror(func(ctx context.Context, secondary Store) error { return secondary.Delete(ctx, key) })
+	return nil
+}
+
+// List implements the Store interface
+func (s *FailoverStore) List(ctx context.Context, filter Filter) ([]*Token, error) {
+	tokens, err := s.config.Primary.List(ctx, filter)
+	if primaryDown(err) {
+		s.fallback("list")
+		return s.config.Secondary.List(ctx, filter)
+	}
+	return tokens, err
+}
+
+// Rotate implements the Store interface. The secondary receives the new
+// token and loses the old one even if it never saw the old token.
+func (s *FailoverStore) Rotate(ctx context.Context, old, newToken *Token) error {
+	if err := s.config.Primary.Rotate(ctx, old, newToken); err != nil {
+		return err
+	}
+	cp := *newToken
+	oldID := old.ID
+	s.mirror(func(ctx context.Context, secondary Store) error {
+		if err := secondary.Save(ctx, cp.ID, &cp); err != nil {
+			return err
+		}
+		return secondary.Delete(ctx, oldID)
+	})
+	return nil
+}
+
+// Revoke implements the Store interface
+func (s *FailoverStore) Revoke(ctx context.Context, token *Token) error {
+	if err := s.config.Primary.Revoke(ctx, token); err != nil {
+		return err
+	}
+	cp := *token
+	s.mirror(func(ctx context.Context, secondary Store) error { return secondary.Revoke(ctx, &cp) })
+	return nil
+}
+
+// Validate implements the Store interface
+func (s *FailoverStore) Validate(ctx context.Context, token *Token) error {
+	err := s.config.Primary.Validate(ctx, token)
+	if primaryDown(err) {
+		s.fallback("validate")
+		return s.config.Secondary.Validate(ctx, token)
+	}
+	return err
+}
+
+// Refresh implements the Store interface
+func (s *FailoverStore) Refresh(ctx context.Context, refreshToken *Token) (*Token, error) {
+	return s.config.Primary.Refresh(ctx, refreshToken)
+}
+
+// Count implements the Store interface
+func (s *FailoverStore) Count(ctx context.Context, filter Filter) (int64, error) {
+	n, err := s.config.Primary.Count(ctx, filter)
+	if primaryDown(err) {
+		s.fallback("count")
+		return s.config.Secondary.Count(ctx, filter)
+	}
+	return n, err
+}
+
+// Cleanup implements the Store interface. Secondary cleanup is best effort.
+func (s *FailoverStore) Cleanup(ctx context.Context) error {
+	if err := s.config.Primary.Cleanup(ctx); err != nil {
+		return err
+	}
+	s.mirror(func(ctx context.Context, secondary Store) error { return secondary.Cleanup(ctx) })
+	return nil
+}
+
+// SetLegalHolds passes legal holds to both stores
+func (s *FailoverStore) SetLegalHolds(holds LegalHoldChecker) {
+	for _, store := range []Store{s.config.Primary, s.config.Secondary} {
+		if h, ok := store.(interface{ SetLegalHolds(LegalHoldChecker) }); ok {
+			h.SetLegalHolds(holds)
+		}
+	}
+}
+
+// Stats returns the mirror and fallback counters
+func (s *FailoverStore) Stats() FailoverStats {
+	return FailoverStats{
+		Mirrored:      s.mirrored.Load(),
+		MirrorFailed:  s.mirrorFailed.Load(),
+		MirrorDropped: s.mirrorDropped.Load(),
+		FallbackReads: s.fallbackReads.Load(),
+	}
+}
+
+// Reconcile makes the secondary match the primary: missing or stale tokens
+// are copied and tokens the primary no longer holds are removed. It repairs
+// writes that were dropped or failed to mirror.
+func (s *FailoverStore) Reconcile(ctx context.Context) (*ReconcileReport, error) {
+	primary, err := s.config.Primary.List(ctx, Filter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list primary tokens: %w", err)
+	}
+	secondary, err := s.config.Secondary.List(ctx, Filter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secondary tokens: %w", err)
+	}
+
+	mirrored := make(map[string]*Token, len(secondary))
+	for _, t := range secondary {
+		mirrored[t.ID] = t
+	}
+
+	report := &ReconcileReport{RanAt: time.Now()}
+	for _, t := range primary {
+		report.Checked++
+		if m, ok := mirrored[t.ID]; ok && sameTokenState(t, m) {
+			delete(mirrored, t.ID)
+			continue
+		}
+		delete(mirrored, t.ID)
+		if err := s.config.Secondary.Save(ctx, t.ID, t); err != nil {
+			return report, fmt.Errorf("failed to repair token %s: %w", t.ID, err)
+		}
+		report.Repaired++
+	}
+	for id := range mirrored {
+		if err := s.config.Secondary.Delete(ctx, id); err != nil && !errors.Is(err, ErrTokenNotFound) {
+			return report, fmt.Errorf("failed to remove token %s: %w", id, err)
+		}
+		report.Removed++
+	}
+
+	if s.config.Metrics != nil {
+		s.config.Metrics.SetStoreDivergence(s.config.Name, report.Diverged())
+	}
+	return report, nil
+}
+
+func sameTokenState(a, b *Token) bool {
+	return a.Value == b.Value &&
+		a.ExpiresAt.Equal(b.ExpiresAt) &&
+		(a.RevocationStatus == nil) == (b.RevocationStatus == nil)
+}
+
+// RunReconciliation calls Reconcile every interval until ctx is cancelled
+func (s *FailoverStore) RunReconciliation(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Reconcile(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Flush waits until every queued mirror write has been attempted or ctx is
+// done
+func (s *FailoverStore) Flush(ctx context.Context) error {
+	marker := make(chan struct{})
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return nil
+	}
+	select {
+	case s.queue <- mirrorOp{marker: marker}:
+		s.mu.RUnlock()
+	case <-ctx.Done():
+		s.mu.RUnlock()
+		return ctx.Err()
+	}
+	select {
+	case <-marker:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close drains the mirror queue and closes both stores
+func (s *FailoverStore) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	<-s.done
+	return errors.Join(s.config.Primary.Close(), s.config.Secondary.Close())
+}
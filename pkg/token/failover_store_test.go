@@ -0,0 +1,134 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyStore fails every read while down
+type flakyStore struct {
+	*MemoryStore
+	down bool
+}
+
+func (s *flakyStore) Get(ctx context.Context, key string) (*Token, error) {
+	if s.down {
+		return nil, ErrStorageFailure
+	}
+	return s.MemoryStore.Get(ctx, key)
+}
+
+func (s *flakyStore) List(ctx context.Context, filter Filter) ([]*Token, error) {
+	if s.down {
+		return nil, ErrStorageFailure
+	}
+	return s.MemoryStore.List(ctx, filter)
+}
+
+func TestFailoverStore(t *testing.T) {
+	ctx := context.Background()
+	newToken := func(id string) *Token {
+		return &Token{ID: id, Value: "v-" + id, Type: Access, ExpiresAt: time.Now().Add(time.Hour)}
+	}
+	newStore := func(t *testing.T, config FailoverConfig) (*FailoverStore, *flakyStore, *MemoryStore) {
+		primary := &flakyStore{MemoryStore: NewMemoryStore(time.Hour)}
+		secondary := NewMemoryStore(time.Hour)
+		config.Primary, config.Secondary = primary, secondary
+		s, err := NewFailoverStore(config)
+		if err != nil {
+			t.Fatalf("NewFailoverStore() error: %v", err)
+		}
+		t.Cleanup(func() { _ = s.Close() })
+		return s, primary, secondary
+	}
+
+	t.Run("Writes Mirrored", func(t *testing.T) {
+		s, _, secondary := newStore(t, FailoverConfig{})
+		if err := s.Save(ctx, "a", newToken("a")); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+		if err := s.Flush(ctx); err != nil {
+			t.Fatalf("Flush() error: %v", err)
+		}
+		if _, err := secondary.Get(ctx, "a"); err != nil {
+			t.Errorf("Expected mirrored token, got %v", err)
+		}
+		if err := s.Delete(ctx, "a"); err != nil {
+			t.Fatalf("Delete() error: %v", err)
+		}
+		_ = s.Flush(ctx)
+		if _, err := secondary.Get(ctx, "a"); !errors.Is(err, ErrTokenNotFound) {
+			t.Errorf("Expected delete mirrored, got %v", err)
+		}
+		if got := s.Stats().Mirrored; got != 2 {
+			t.Errorf("Expected 2 mirrored writes, got %d", got)
+		}
+	})
+
+	t.Run("Fallback Reads", func(t *testing.T) {
+		s, primary, _ := newStore(t, FailoverConfig{})
+		_ = s.Save(ctx, "a", newToken("a"))
+		_ = s.Flush(ctx)
+
+		if _, err := s.Get(ctx, "missing"); !errors.Is(err, ErrTokenNotFound) {
+			t.Errorf("Expected ErrTokenNotFound, got %v", err)
+		}
+		if got := s.Stats().FallbackReads; got != 0 {
+			t.Errorf("Expected not-found to be served by the primary, got %d fallbacks", got)
+		}
+
+		primary.down = true
+		got, err := s.Get(ctx, "a")
+		if err != nil || got.ID != "a" {
+			t.Fatalf("Expected secondary read, got %v, %v", got, err)
+		}
+		if tokens, err := s.List(ctx, Filter{}); err != nil || len(tokens) != 1 {
+			t.Errorf("Expected secondary list, got %d, %v", len(tokens), err)
+		}
+		if got := s.Stats().FallbackReads; got != 2 {
+			t.Errorf("Expected 2 fallback reads, got %d", got)
+		}
+	})
+
+	t.Run("Reconcile", func(t *testing.T) {
+		s, primary, secondary := newStore(t, FailoverConfig{})
+		_ = primary.Save(ctx, "missed", newToken("missed"))
+		_ = secondary.Save(ctx, "stale", newToken("stale"))
+		_ = s.Save(ctx, "synced", newToken("synced"))
+		_ = s.Flush(ctx)
+
+		report, err := s.Reconcile(ctx)
+		if err != nil {
+			t.Fatalf("Reconcile() error: %v", err)
+		}
+		if report.Checked != 2 || report.Repaired != 1 || report.Removed != 1 || report.Diverged() != 2 {
+			t.Errorf("Unexpected report %+v", report)
+		}
+		if _, err := secondary.Get(ctx, "missed"); err != nil {
+			t.Errorf("Expected repaired token, got %v", err)
+		}
+		if _, err := secondary.Get(ctx, "stale"); !errors.Is(err, ErrTokenNotFound) {
+			t.Errorf("Expected stale token removed, got %v", err)
+		}
+
+		report, _ = s.Reconcile(ctx)
+		if report.Diverged() != 0 {
+			t.Errorf("Expected no divergence after repair, got %+v", report)
+		}
+	})
+
+	t.Run("Primary Write Failure", func(t *testing.T) {
+		s, primary, secondary := newStore(t, FailoverConfig{})
+		primary.maxTokens = 1
+		_ = s.Save(ctx, "a", newToken("a"))
+		if err := s.Save(ctx, "b", newToken("b")); !errors.Is(err, ErrStorageFailure) {
+			t.Fatalf("Expected ErrStorageFailure, got %v", err)
+		}
+		_ = s.Flush(ctx)
+		if _, err := secondary.Get(ctx, "b"); !errors.Is(err, ErrTokenNotFound) {
+			t.Errorf("Expected failed write not mirrored, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,220 @@
+package token
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SortField orders List results
+type SortField string
+
+// Sort fields
+const (
+	SortByID        SortField = "id"
+	SortByIssuedAt  SortField = "issued_at"
+	SortByExpiresAt SortField = "expires_at"
+	SortBySubject   SortField = "subject"
+)
+
+// MetadataOp compares a metadata value in a MetadataPredicate
+type MetadataOp string
+
+// Metadata predicate operators
+const (
+	// MetadataEquals matches tokens whose value equals Value
+	MetadataEquals MetadataOp = "eq"
+
+	// MetadataNotEquals matches tokens whose value is missing or differs
+	MetadataNotEquals MetadataOp = "ne"
+
+	// MetadataPrefix matches tokens whose value starts with Value
+	MetadataPrefix MetadataOp = "prefix"
+
+	// MetadataExists matches tokens that have the key
+	MetadataExists MetadataOp = "exists"
+
+	// MetadataMissing matches tokens that lack the key
+	MetadataMissing MetadataOp = "missing"
+)
+
+// MetadataPredicate tests one metadata key. Keys are looked up in
+// Metadata.AppData first and then in Metadata.Labels.
+type MetadataPredicate struct {
+	Key   string     `json:"key"`
+	Op    MetadataOp `json:"op"`
+	Value string     `json:"value,omitempty"`
+}
+
+// Match reports whether the token satisfies the predicate
+func (p MetadataPredicate) Match(t *Token) bool {
+	v, ok := metadataValue(t, p.Key)
+	switch p.Op {
+	case MetadataEquals, "":
+		return ok && v == p.Value
+	case MetadataNotEquals:
+		return !ok || v != p.Value
+	case MetadataPrefix:
+		return ok && strings.HasPrefix(v, p.Value)
+	case MetadataExists:
+		return ok
+	case MetadataMissing:
+		return !ok
+	}
+	return false
+}
+
+func matchesMetadataFilter(token *Token, filter Filter) bool {
+	for k, v := range filter.Metadata {
+		if got, ok := metadataValue(token, k); !ok || got != v {
+			return false
+		}
+	}
+	for _, p := range filter.MetadataPredicates {
+		if !p.Match(token) {
+			return false
+		}
+	}
+	return true
+}
+
+// paged reports whether the filter asks for ordered or paginated results
+func (f Filter) paged() bool {
+	return f.Limit > 0 || f.Cursor != "" || f.SortBy != ""
+}
+
+// listCursor is the decoded form of Filter.Cursor: the sort position of the
+// last token of the previous page
+type listCursor struct {
+	Sort SortField `json:"s"`
+	Desc bool      `json:"d,omitempty"`
+	Key  string    `json:"k"`
+	ID   string    `json:"i"`
+}
+
+func (f Filter) sortField() SortField {
+	if f.SortBy == "" {
+		return SortByID
+	}
+	return f.SortBy
+}
+
+// sortKey returns a string that orders tokens by field; times use a fixed
+// width format so they compare lexically
+func sortKey(t *Token, field SortField) string {
+	const fixed = "2006-01-02T15:04:05.000000000Z"
+	switch field {
+	case SortByIssuedAt:
+		return t.IssuedAt.UTC().Format(fixed)
+	case SortByExpiresAt:
+		return t.ExpiresAt.UTC().Format(fixed)
+	case SortBySubject:
+		return t.Subject
+	}
+	return t.ID
+}
+
+func encodeCursor(c listCursor) string {
+	body, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(body)
+}
+
+func decodeCursor(s string) (listCursor, error) {
+	var c listCursor
+	body, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if err := json.Unmarshal(body, &c); err != nil {
+		return c, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return c, nil
+}
+
+// applyPage sorts the matched tokens and cuts out the page the filter asks
+// for. Stores call it on their filtered results; tokens are ordered by the
+// sort field with the ID breaking ties, so pages never overlap.
+func applyPage(tokens []*Token, filter Filter) ([]*Token, error) {
+	if !filter.paged() {
+		return tokens, nil
+	}
+	field := filter.sortField()
+	switch field {
+	case SortByID, SortByIssuedAt, SortByExpiresAt, SortBySubject:
+	default:
+		return nil, fmt.Errorf("%w: unknown sort field %q", ErrInvalidFilter, field)
+	}
+	if filter.Limit < 0 {
+		return nil, fmt.Errorf("%w: negative limit", ErrInvalidFilter)
+	}
+
+	keys := make(map[*Token]string, len(tokens))
+	for _, t := range tokens {
+		keys[t] = sortKey(t, field)
+	}
+	less := func(a, b string, aID, bID string) bool {
+		if a == b {
+			a, b = aID, bID
+		}
+		if filter.Descending {
+			return a > b
+		}
+		return a < b
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return less(keys[tokens[i]], keys[tokens[j]], tokens[i].ID, tokens[j].ID)
+	})
+
+	if filter.Cursor != "" {
+		c, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sort != field || c.Desc != filter.Descending {
+			return nil, fmt.Errorf("%w: cursor belongs to a different sort order", ErrInvalidCursor)
+		}
+		start := sort.Search(len(tokens), func(i int) bool {
+			return less(c.Key, keys[tokens[i]], c.ID, tokens[i].ID)
+		})
+		tokens = tokens[start:]
+	}
+	if filter.Limit > 0 && len(tokens) > filter.Limit {
+		tokens = tokens[:filter.Limit]
+	}
+	return tokens, nil
+}
+
+// Page is one page of List results
+type Page struct {
+	Tokens []*Token `json:"tokens"`
+
+	// NextCursor continues the listing; empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Lister lists tokens; every token store implements it
+type Lister interface {
+	List(ctx context.Context, filter Filter) ([]*Token, error)
+}
+
+// ListPage lists one page of tokens. Pass NextCursor back in Filter.Cursor to
+// fetch the following page. Limit defaults to 100.
+func ListPage(ctx context.Context, store Lister, filter Filter) (*Page, error) {
+	if filter.Limit == 0 {
+		filter.Limit = 100
+	}
+	tokens, err := store.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	page := &Page{Tokens: tokens}
+	if len(tokens) == filter.Limit {
+		last := tokens[len(tokens)-1]
+		field := filter.sortField()
+		page.NextCursor = encodeCursor(listCursor{Sort: field, Desc: filter.Descending, Key: sortKey(last, field), ID: last.ID})
+	}
+	return page, nil
+}
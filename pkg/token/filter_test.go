@@ -0,0 +1,136 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestFilterPagination(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	redisStore, err := NewRedisStore(RedisConfig{Addresses: []string{mr.Addr()}, KeyPrefix: "filter:", DefaultTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("NewRedisStore() error: %v", err)
+	}
+	defer redisStore.Close()
+	memory := NewMemoryStore()
+
+	base := time.Now().Truncate(time.Second)
+	for i := 0; i < 25; i++ {
+		tok := &Token{
+			ID:        fmt.Sprintf("tok-%02d", i),
+			Value:     fmt.Sprintf("value-%02d", i),
+			Type:      Access,
+			Subject:   []string{"alice", "bob"}[i%2],
+			Issuer:    "gauth",
+			Scopes:    []string{"read"},
+			IssuedAt:  base.Add(-time.Duration(i) * time.Minute),
+			ExpiresAt: base.Add(time.Hour + time.Duration(i%5)*time.Minute),
+			Metadata:  &Metadata{AppData: map[string]string{"tenant": []string{"acme", "acme-eu", "globex"}[i%3]}},
+		}
+		if err := memory.Save(ctx, tok.ID, tok); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+		if err := redisStore.Save(ctx, tok); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+	}
+
+	stores := map[string]Lister{"memory": memory, "redis": redisStore}
+	collect := func(t *testing.T, store Lister, filter Filter) []string {
+		var ids []string
+		for pages := 0; ; pages++ {
+			if pages > 20 {
+				t.Fatal("Pagination did not terminate")
+			}
+			page, err := ListPage(ctx, store, filter)
+			if err != nil {
+				t.Fatalf("ListPage() error: %v", err)
+			}
+			for _, tok := range page.Tokens {
+				ids = append(ids, tok.ID)
+			}
+			if page.NextCursor == "" {
+				return ids
+			}
+			filter.Cursor = page.NextCursor
+		}
+	}
+
+	t.Run("Pages Cover Every Token Once", func(t *testing.T) {
+		for name, store := range stores {
+			ids := collect(t, store, Filter{Limit: 7})
+			if len(ids) != 25 || ids[0] != "tok-00" || ids[24] != "tok-24" {
+				t.Errorf("%s: expected 25 tokens in ID order, got %v", name, ids)
+			}
+		}
+	})
+
+	t.Run("Sort Order", func(t *testing.T) {
+		for name, store := range stores {
+			ids := collect(t, store, Filter{SortBy: SortByIssuedAt, Limit: 10})
+			if ids[0] != "tok-24" || ids[24] != "tok-00" {
+				t.Errorf("%s: expected oldest first, got %v", name, ids)
+			}
+			ids = collect(t, store, Filter{SortBy: SortByExpiresAt, Descending: true, Limit: 4})
+			if len(ids) != 25 || ids[0] != "tok-24" {
+				t.Errorf("%s: expected latest expiry first with ID tie-break, got %v", name, ids)
+			}
+		}
+	})
+
+	t.Run("Metadata Predicates", func(t *testing.T) {
+		filter := Filter{
+			Subject:            "alice",
+			Metadata:           map[string]string{"tenant": "acme"},
+			MetadataPredicates: []MetadataPredicate{{Key: "region", Op: MetadataMissing}},
+			Limit:              100,
+		}
+		prefix := Filter{MetadataPredicates: []MetadataPredicate{{Key: "tenant", Op: MetadataPrefix, Value: "acme"}}, Limit: 100}
+		for name, store := range stores {
+			if ids := collect(t, store, filter); strings.Join(ids, ",") != "tok-00,tok-06,tok-12,tok-18,tok-24" {
+				t.Errorf("%s: unexpected metadata matches %v", name, ids)
+			}
+			if ids := collect(t, store, prefix); len(ids) != 17 {
+				t.Errorf("%s: expected 17 tokens with an acme tenant prefix, got %d", name, len(ids))
+			}
+		}
+	})
+
+	t.Run("Cursor Must Match Sort", func(t *testing.T) {
+		page, _ := ListPage(ctx, memory, Filter{Limit: 5})
+		_, err := memory.List(ctx, Filter{Limit: 5, Cursor: page.NextCursor, SortBy: SortBySubject})
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Expected ErrInvalidCursor, got %v", err)
+		}
+		if _, err := memory.List(ctx, Filter{SortBy: "value"}); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Expected ErrInvalidFilter, got %v", err)
+		}
+	})
+
+	t.Run("SQL Metadata Predicates", func(t *testing.T) {
+		where, args, err := filterSQL(Filter{MetadataPredicates: []MetadataPredicate{
+			{Key: "tenant", Op: MetadataPrefix, Value: "acme"},
+			{Key: "region", Op: MetadataMissing},
+		}}, time.Now())
+		if err != nil {
+			t.Fatalf("filterSQL() error: %v", err)
+		}
+		if !strings.Contains(where, "left(COALESCE(") || !strings.Contains(where, "IS NULL") || len(args) != 3 {
+			t.Errorf("Unexpected clause %q with %d args", where, len(args))
+		}
+		if _, _, err := filterSQL(Filter{MetadataPredicates: []MetadataPredicate{{Key: "k", Op: "like"}}}, time.Now()); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Expected ErrInvalidFilter, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package token
+
+import "time"
+
+// GracePolicy allows access tokens that have just expired to be accepted as
+// stale for low-risk scopes while the client refreshes. This smooths refresh
+// storms when many tokens expire at the same moment.
+type GracePolicy struct {
+	// Period is how long after expiry (and any clock skew) a token may still be accepted
+	Period time.Duration
+
+	// Scopes lists the low-risk scopes eligible for grace. A token is only
+	// accepted if every one of its scopes is listed.
+	Scopes []string
+}
+
+// accepts reports whether an expired token falls within the grace window
+func (p *GracePolicy) accepts(token *Token, now time.Time, skew time.Duration) bool {
+	if p == nil || p.Period <= 0 || token.Type != Access || len(token.Scopes) == 0 {
+		return false
+	}
+	if now.After(token.ExpiresAt.Add(skew + p.Period)) {
+		return false
+	}
+	for _, scope := range token.Scopes {
+		if !containsString(p.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsStale reports whether a token that passed validation is past its expiry
+// and the given clock skew, so it was only accepted under a grace policy.
+// Callers should trigger a refresh. Pass the skew the token was validated
+// with, or use ValidationChain.IsStale or Service.IsStale which apply their
+// own.
+func IsStale(token *Token, skew time.Duration) bool {
+	return token != nil && time.Now().After(token.ExpiresAt.Add(skew))
+}
@@ -0,0 +1,78 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGracePolicy(t *testing.T) {
+	ctx := context.Background()
+	bl := NewBlacklist()
+	defer bl.Close()
+
+	chain := NewValidationChain(ValidationConfig{
+		Grace: &GracePolicy{Period: time.Minute, Scopes: []string{"read", "profile"}},
+	}, bl)
+
+	stale := func(typ Type, expiredFor time.Duration, scopes ...string) *Token {
+		return &Token{
+			ID:        NewID(),
+			Type:      typ,
+			Scopes:    scopes,
+			NotBefore: time.Now().Add(-time.Hour),
+			ExpiresAt: time.Now().Add(-expiredFor),
+		}
+	}
+
+	t.Run("Low Risk Scopes", func(t *testing.T) {
+		tok := stale(Access, 10*time.Second, "read")
+		if err := chain.Validate(ctx, tok); err != nil {
+			t.Fatalf("Expected stale token to be accepted, got %v", err)
+		}
+		if !chain.IsStale(tok) {
+			t.Error("Expected token to be reported stale")
+		}
+	})
+
+	t.Run("Within Clock Skew", func(t *testing.T) {
+		skewed := NewValidationChain(ValidationConfig{
+			ClockSkew: 30 * time.Second,
+			Grace:     &GracePolicy{Period: time.Minute, Scopes: []string{"read"}},
+		}, bl)
+		tok := stale(Access, 10*time.Second, "read")
+		if err := skewed.Validate(ctx, tok); err != nil {
+			t.Fatalf("Expected token within skew to be accepted, got %v", err)
+		}
+		if skewed.IsStale(tok) {
+			t.Error("Expected token within skew not to be reported stale")
+		}
+		if !IsStale(tok, 0) || IsStale(tok, 30*time.Second) {
+			t.Error("Expected IsStale to apply the given skew")
+		}
+
+		svc := &Service{config: Config{ClockSkew: 30 * time.Second}}
+		if svc.IsStale(tok) {
+			t.Error("Expected service to apply its clock skew")
+		}
+	})
+
+	t.Run("High Risk Scope", func(t *testing.T) {
+		if err := chain.Validate(ctx, stale(Access, 10*time.Second, "read", "write")); !errors.Is(err, ErrTokenExpired) {
+			t.Errorf("Expected ErrTokenExpired, got %v", err)
+		}
+	})
+
+	t.Run("Past Grace Period", func(t *testing.T) {
+		if err := chain.Validate(ctx, stale(Access, 2*time.Minute, "read")); !errors.Is(err, ErrTokenExpired) {
+			t.Errorf("Expected ErrTokenExpired, got %v", err)
+		}
+	})
+
+	t.Run("Refresh Token", func(t *testing.T) {
+		if err := chain.Validate(ctx, stale(Refresh, 10*time.Second, "read")); !errors.Is(err, ErrTokenExpired) {
+			t.Errorf("Expected ErrTokenExpired, got %v", err)
+		}
+	})
+}
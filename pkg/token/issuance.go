@@ -0,0 +1,292 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/metrics"
+)
+
+// Issuance check errors
+var (
+	// ErrIssuanceDenied indicates a pre-issuance check rejected the token
+	ErrIssuanceDenied = errors.New("token issuance denied")
+
+	// ErrDuplicateCheck indicates a check with the same name is already registered
+	ErrDuplicateCheck = errors.New("issuance check already registered")
+)
+
+// IssuanceCheck inspects a token before it is signed and stored. Returning
+// an error aborts issuance.
+type IssuanceCheck interface {
+	// Name identifies the check in metrics and errors
+	Name() string
+
+	// Check inspects the token about to be issued
+	Check(ctx context.Context, token *Token) error
+}
+
+// IssuanceCheckFunc adapts a function to the IssuanceCheck interface
+type IssuanceCheckFunc struct {
+	CheckName string
+	Fn        func(ctx context.Context, token *Token) error
+}
+
+// Name implements IssuanceCheck
+func (f IssuanceCheckFunc) Name() string { return f.CheckName }
+
+// Check implements IssuanceCheck
+func (f IssuanceCheckFunc) Check(ctx context.Context, token *Token) error { return f.Fn(ctx, token) }
+
+// IssuanceDeniedError identifies the check that rejected issuance
+type IssuanceDeniedError struct {
+	Check string
+	Cause error
+}
+
+func (e *IssuanceDeniedError) Error() string {
+	return fmt.Sprintf("issuance check %s failed: %v", e.Check, e.Cause)
+}
+
+// Unwrap returns the underlying cause
+func (e *IssuanceDeniedError) Unwrap() error { return e.Cause }
+
+// Is reports ErrIssuanceDenied for any rejected issuance
+func (e *IssuanceDeniedError) Is(target error) bool { return target == ErrIssuanceDenied }
+
+// IssuancePipeline runs an ordered chain of checks before tokens are minted
+type IssuancePipeline struct {
+	mu      sync.RWMutex
+	checks  []IssuanceCheck
+	metrics *metrics.Collector
+}
+
+// NewIssuancePipeline creates a pipeline with the given checks in order
+func NewIssuancePipeline(checks ...IssuanceCheck) *IssuancePipeline {
+	return &IssuancePipeline{checks: checks}
+}
+
+// WithMetrics records per-check outcomes and latency on the collector
+func (p *IssuancePipeline) WithMetrics(m *metrics.Collector) *IssuancePipeline {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.metrics = m
+	return p
+}
+
+// Register appends a check to the end of the pipeline
+func (p *IssuancePipeline) Register(check IssuanceCheck) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range p.checks {
+		if c.Name() == check.Name() {
+			return fmt.Errorf("%w: %s", ErrDuplicateCheck, check.Name())
+		}
+	}
+	p.checks = append(p.checks, check)
+	return nil
+}
+
+// Unregister removes a check by name
+func (p *IssuancePipeline) Unregister(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, c := range p.checks {
+		if c.Name() == name {
+			p.checks = append(p.checks[:i], p.checks[i+1:]...)
+			return
+		}
+	}
+}
+
+// Checks returns the names of the registered checks in execution order
+func (p *IssuancePipeline) Checks() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	names := make([]string, len(p.checks))
+	for i, c := range p.checks {
+		names[i] = c.Name()
+	}
+	return names
+}
+
+// Run executes the checks in order, stopping at the first failure
+func (p *IssuancePipeline) Run(ctx context.Context, token *Token) error {
+	p.mu.RLock()
+	checks := append([]IssuanceCheck(nil), p.checks...)
+	collector := p.metrics
+	p.mu.RUnlock()
+
+	for _, check := range checks {
+		start := time.Now()
+		err := check.Check(ctx, token)
+		if collector != nil {
+			result := "pass"
+			if err != nil {
+				result = "fail"
+			}
+			collector.RecordIssuanceCheck(check.Name(), result, time.Since(start))
+		}
+		if err != nil {
+			return &IssuanceDeniedError{Check: check.Name(), Cause: err}
+		}
+	}
+	return nil
+}
+
+// ScopePolicyCheck restricts the scopes that may be issued per token type
+type ScopePolicyCheck struct {
+	// Allowed maps a token type to the scopes it may carry. Types without an
+	// entry are unrestricted.
+	Allowed map[Type][]string
+
+	// MaxScopes caps the number of scopes on a single token (0 = no limit)
+	MaxScopes int
+}
+
+// Name implements IssuanceCheck
+func (c *ScopePolicyCheck) Name() string { return "scope_policy" }
+
+// Check implements IssuanceCheck
+func (c *ScopePolicyCheck) Check(_ context.Context, token *Token) error {
+	if c.MaxScopes > 0 && len(token.Scopes) > c.MaxScopes {
+		return fmt.Errorf("token requests %d scopes, limit is %d", len(token.Scopes), c.MaxScopes)
+	}
+	allowed, ok := c.Allowed[token.Type]
+	if !ok {
+		return nil
+	}
+	for _, scope := range token.Scopes {
+		if !containsString(allowed, scope) {
+			return fmt.Errorf("scope %q not allowed for %s", scope, token.Type)
+		}
+	}
+	return nil
+}
+
+// QuotaCheck limits the number of active tokens per subject
+type QuotaCheck struct {
+	Store Store
+
+	// MaxPerSubject is the maximum number of active tokens a subject may hold
+	MaxPerSubject int64
+}
+
+// Name implements IssuanceCheck
+func (c *QuotaCheck) Name() string { return "quota" }
+
+// Check implements IssuanceCheck
+func (c *QuotaCheck) Check(ctx context.Context, token *Token) error {
+	if c.Store == nil || c.MaxPerSubject <= 0 || token.Subject == "" {
+		return nil
+	}
+	count, err := c.Store.Count(ctx, Filter{Subject: token.Subject, Active: true})
+	if err != nil {
+		return fmt.Errorf("failed to count tokens: %w", err)
+	}
+	if count >= c.MaxPerSubject {
+		return fmt.Errorf("subject %s has %d active tokens, limit is %d", token.Subject, count, c.MaxPerSubject)
+	}
+	return nil
+}
+
+// RiskCheck rejects issuance when a risk score exceeds a threshold
+type RiskCheck struct {
+	// Score returns a risk score for the token, typically in [0, 1]
+	Score func(ctx context.Context, token *Token) (float64, error)
+
+	// Threshold is the highest acceptable score
+	Threshold float64
+}
+
+// Name implements IssuanceCheck
+func (c *RiskCheck) Name() string { return "risk" }
+
+// Check implements IssuanceCheck
+func (c *RiskCheck) Check(ctx context.Context, token *Token) error {
+	if c.Score == nil {
+		return nil
+	}
+	score, err := c.Score(ctx, token)
+	if err != nil {
+		return fmt.Errorf("failed to score risk: %w", err)
+	}
+	if score > c.Threshold {
+		return fmt.Errorf("risk score %.2f exceeds threshold %.2f", score, c.Threshold)
+	}
+	return nil
+}
+
+// JurisdictionCheck requires the token's jurisdiction label to be allowed
+type JurisdictionCheck struct {
+	// Label is the metadata label holding the jurisdiction (default: "jurisdiction")
+	Label string
+
+	// Allowed lists the permitted jurisdictions
+	Allowed []string
+}
+
+// Name implements IssuanceCheck
+func (c *JurisdictionCheck) Name() string { return "jurisdiction" }
+
+// Check implements IssuanceCheck
+func (c *JurisdictionCheck) Check(_ context.Context, token *Token) error {
+	label := c.Label
+	if label == "" {
+		label = "jurisdiction"
+	}
+	var jurisdiction string
+	if token.Metadata != nil {
+		jurisdiction = token.Metadata.Labels[label]
+	}
+	if jurisdiction == "" {
+		return errors.New("token has no jurisdiction")
+	}
+	if !containsString(c.Allowed, jurisdiction) {
+		return fmt.Errorf("jurisdiction %q not allowed", jurisdiction)
+	}
+	return nil
+}
+
+// AttestationCheck requires attestation evidence for the token
+type AttestationCheck struct {
+	// Key is the metadata AppData key holding the evidence (default: "attestation")
+	Key string
+
+	// Verify optionally validates the evidence
+	Verify func(ctx context.Context, token *Token, evidence string) error
+}
+
+// Name implements IssuanceCheck
+func (c *AttestationCheck) Name() string { return "attestation" }
+
+// Check implements IssuanceCheck
+func (c *AttestationCheck) Check(ctx context.Context, token *Token) error {
+	key := c.Key
+	if key == "" {
+		key = "attestation"
+	}
+	var evidence string
+	if token.Metadata != nil {
+		evidence = token.Metadata.AppData[key]
+	}
+	if evidence == "" {
+		return errors.New("token has no attestation")
+	}
+	if c.Verify != nil {
+		return c.Verify(ctx, token, evidence)
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIssuancePipeline(t *testing.T) {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+
+	var order []string
+	record := func(name string) IssuanceCheck {
+		return IssuanceCheckFunc{CheckName: name, Fn: func(context.Context, *Token) error {
+			order = append(order, name)
+			return nil
+		}}
+	}
+
+	pipeline := NewIssuancePipeline(
+		record("first"),
+		&ScopePolicyCheck{Allowed: map[Type][]string{Access: {"read", "write"}}},
+		&JurisdictionCheck{Allowed: []string{"EU"}},
+	)
+	if err := pipeline.Register(record("custom")); err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+	if err := pipeline.Register(record("custom")); !errors.Is(err, ErrDuplicateCheck) {
+		t.Errorf("Expected ErrDuplicateCheck, got %v", err)
+	}
+
+	store := NewMemoryStore(time.Hour)
+	svc := NewService(Config{
+		SigningKey:     key,
+		ValidityPeriod: time.Hour,
+		IssuanceChecks: pipeline,
+	}, store)
+
+	t.Run("All Checks Pass", func(t *testing.T) {
+		order = nil
+		_, err := svc.Issue(ctx, &Token{
+			ID:       NewID(),
+			Type:     Access,
+			Subject:  "user-1",
+			Scopes:   []string{"read"},
+			Metadata: &Metadata{Labels: map[string]string{"jurisdiction": "EU"}},
+		})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		if len(order) != 2 || order[0] != "first" || order[1] != "custom" {
+			t.Errorf("Unexpected check order %v", order)
+		}
+	})
+
+	t.Run("Check Rejects", func(t *testing.T) {
+		order = nil
+		_, err := svc.Issue(ctx, &Token{
+			ID:       NewID(),
+			Type:     Access,
+			Subject:  "user-1",
+			Scopes:   []string{"admin"},
+			Metadata: &Metadata{Labels: map[string]string{"jurisdiction": "EU"}},
+		})
+		if !errors.Is(err, ErrIssuanceDenied) {
+			t.Fatalf("Expected ErrIssuanceDenied, got %v", err)
+		}
+		var denied *IssuanceDeniedError
+		if !errors.As(err, &denied) || denied.Check != "scope_policy" {
+			t.Errorf("Expected scope_policy denial, got %v", err)
+		}
+		if len(order) != 1 {
+			t.Errorf("Expected pipeline to stop after failure, ran %v", order)
+		}
+	})
+
+	t.Run("Quota", func(t *testing.T) {
+		check := &QuotaCheck{Store: store, MaxPerSubject: 1}
+		if err := check.Check(ctx, &Token{Subject: "user-1"}); err == nil {
+			t.Error("Expected quota to be exceeded")
+		}
+		if err := check.Check(ctx, &Token{Subject: "user-2"}); err != nil {
+			t.Errorf("Expected quota check to pass, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,457 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key set errors
+var (
+	// ErrKeyNotFound indicates no key with the requested ID is in the set
+	ErrKeyNotFound = errors.New("key not found")
+
+	// ErrNoActiveKey indicates the set has no key to sign with
+	ErrNoActiveKey = errors.New("no active signing key")
+
+	// ErrUnsupportedKey indicates a key type that cannot be published as a JWK
+	ErrUnsupportedKey = errors.New("unsupported key type")
+)
+
+// JWK is a public key in RFC 7517 JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC and OKP
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JWKS is an RFC 7517 JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublishedKey is a key in a KeySet
+type PublishedKey struct {
+	KeyID     string           `json:"kid"`
+	Algorithm Algorithm        `json:"alg"`
+	Public    crypto.PublicKey `json:"-"`
+	AddedAt   time.Time        `json:"added_at"`
+
+	// Active marks the key new tokens are signed with
+	Active bool `json:"active"`
+
+	signer crypto.Signer
+}
+
+// CanSign reports whether the private key is held, not just the public key
+func (k *PublishedKey) CanSign() bool {
+	return k.signer != nil
+}
+
+// KeySet holds the keys tokens are signed and verified with. Exactly one key
+// with a private part is active for signing; every key in the set is
+// published so tokens signed with older keys keep verifying until the key is
+// removed.
+type KeySet struct {
+	mu     sync.RWMutex
+	keys   []*PublishedKey
+	active string
+}
+
+// NewKeySet creates an empty key set
+func NewKeySet() *KeySet {
+	return &KeySet{}
+}
+
+// Add adds a signing key and returns its key ID. An empty kid is replaced
+// by the RFC 7638 thumbprint of the public key. The first signing key added
+// becomes active.
+func (s *KeySet) Add(signer crypto.Signer, alg Algorithm, kid string) (string, error) {
+	if signer == nil {
+		return "", fmt.Errorf("%w: signing key is required", ErrInvalidConfig)
+	}
+	if alg == "" {
+		alg = algorithmForKey(signer)
+	}
+	if !keyMatches(alg, signer) {
+		return "", fmt.Errorf("%w: key type does not support %s", ErrUnsupportedKey, alg)
+	}
+	kid, err := s.add(signer.Public(), alg, kid, signer)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	if s.active == "" {
+		s.setActive(kid)
+	}
+	s.mu.Unlock()
+	return kid, nil
+}
+
+// AddPublic adds a verification-only key, e.g. one held by another issuer
+func (s *KeySet) AddPublic(pub crypto.PublicKey, alg Algorithm, kid string) (string, error) {
+	return s.add(pub, alg, kid, nil)
+}
+
+func (s *KeySet) add(pub crypto.PublicKey, alg Algorithm, kid string, signer crypto.Signer) (string, error) {
+	if kid == "" {
+		thumb, err := Thumbprint(pub)
+		if err != nil {
+			return "", err
+		}
+		kid = thumb
+	}
+	if _, err := publicJWK(pub, alg, kid); err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.keys {
+		if k.KeyID == kid {
+			return "", fmt.Errorf("%w: duplicate key ID %q", ErrInvalidConfig, kid)
+		}
+	}
+	s.keys = append(s.keys, &PublishedKey{
+		KeyID:     kid,
+		Algorithm: alg,
+		Public:    pub,
+		AddedAt:   time.Now(),
+		signer:    signer,
+	})
+	return kid, nil
+}
+
+// Activate makes the key new tokens are signed with
+func (s *KeySet) Activate(kid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := s.find(kid)
+	if k == nil {
+		return ErrKeyNotFound
+	}
+	if k.signer == nil {
+		return fmt.Errorf("%w: key %q has no private part", ErrNoActiveKey, kid)
+	}
+	s.setActive(kid)
+	return nil
+}
+
+func (s *KeySet) setActive(kid string) {
+	s.active = kid
+	for _, k := range s.keys {
+		k.Active = k.KeyID == kid
+	}
+}
+
+// Remove withdraws a key; tokens signed with it no longer verify. The
+// active key cannot be removed.
+func (s *KeySet) Remove(kid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if kid == s.active {
+		return fmt.Errorf("%w: cannot remove the active key", ErrInvalidConfig)
+	}
+	for i, k := range s.keys {
+		if k.KeyID == kid {
+			s.keys = append(s.keys[:i], s.keys[i+1:]...)
+			return nil
+		}
+	}
+	return ErrKeyNotFound
+}
+
+// Active returns the key new tokens are signed with
+func (s *KeySet) Active() (kid string, signer crypto.Signer, alg Algorithm, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k := s.find(s.active)
+	if k == nil {
+		return "", nil, "", ErrNoActiveKey
+	}
+	return k.KeyID, k.signer, k.Algorithm, nil
+}
+
+// Lookup returns the public key and algorithm for a key ID
+func (s *KeySet) Lookup(kid string) (crypto.PublicKey, Algorithm, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k := s.find(kid)
+	if k == nil {
+		return nil, "", ErrKeyNotFound
+	}
+	return k.Public, k.Algorithm, nil
+}
+
+// Keys returns every key in the set, oldest first
+func (s *KeySet) Keys() []PublishedKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]PublishedKey, len(s.keys))
+	for i, k := range s.keys {
+		out[i] = *k
+		out[i].signer = nil
+	}
+	return out
+}
+
+func (s *KeySet) find(kid string) *PublishedKey {
+	for _, k := range s.keys {
+		if k.KeyID == kid {
+			return k
+		}
+	}
+	return nil
+}
+
+// Sign signs a token with the active key, setting its algorithm and the
+// kid header
+func (s *KeySet) Sign(token *Token) (string, error) {
+	kid, signer, alg, err := s.Active()
+	if err != nil {
+		return "", err
+	}
+	token.Algorithm = alg
+	return NewJWTSigner(signer, alg).WithKeyID(kid).SignToken(token)
+}
+
+// SignClaims signs arbitrary JWT claims with the active key, for tokens
+// that are not access tokens, such as OpenID Connect ID tokens. It returns
+// the signed JWT and the algorithm used.
+func (s *KeySet) SignClaims(claims map[string]interface{}) (string, Algorithm, error) {
+	kid, signer, alg, err := s.Active()
+	if err != nil {
+		return "", "", err
+	}
+	jwtToken := jwt.NewWithClaims(jwtSigningMethod(alg), jwt.MapClaims(claims))
+	jwtToken.Header["kid"] = kid
+	signed, err := jwtToken.SignedString(signer)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to sign JWT: %w", err)
+	}
+	return signed, alg, nil
+}
+
+// Verify checks a JWT against the key named by its kid header and decodes
+// its claims. Expiry is not checked; see Service.Validate.
+func (s *KeySet) Verify(tokenString string) (*Token, error) {
+	kid, err := headerKeyID(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	pub, alg, err := s.Lookup(kid)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", err, kid)
+	}
+	verifier := &JWTSigner{verifyKey: pub, signingAlg: alg, keyID: kid}
+	return verifier.ParseToken(tokenString)
+}
+
+// JWKS returns the public keys as a JSON Web Key Set
+func (s *KeySet) JWKS() *JWKS {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	set := &JWKS{Keys: make([]JWK, 0, len(s.keys))}
+	for _, k := range s.keys {
+		if jwk, err := publicJWK(k.Public, k.Algorithm, k.KeyID); err == nil {
+			set.Keys = append(set.Keys, *jwk)
+		}
+	}
+	return set
+}
+
+// Handler serves the key set as a JWKS document. maxAge sets how long
+// resource servers may cache it (default: 5m).
+func (s *KeySet) Handler(maxAge time.Duration) http.Handler {
+	if maxAge <= 0 {
+		maxAge = 5 * time.Minute
+	}
+	cacheControl := "public, max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := json.Marshal(s.JWKS())
+		if err != nil {
+			http.Error(w, "failed to encode key set", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", cacheControl)
+		_, _ = w.Write(body)
+	})
+}
+
+// headerKeyID extracts the kid from a JWT header without verifying it
+func headerKeyID(tokenString string) (string, error) {
+	header, _, ok := strings.Cut(tokenString, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return "", fmt.Errorf("%w: malformed header", ErrInvalidToken)
+	}
+	var h struct {
+		KeyID string `json:"kid"`
+	}
+	if err := json.Unmarshal(raw, &h); err != nil {
+		return "", fmt.Errorf("%w: malformed header", ErrInvalidToken)
+	}
+	if h.KeyID == "" {
+		return "", fmt.Errorf("%w: header has no kid", ErrInvalidToken)
+	}
+	return h.KeyID, nil
+}
+
+// publicJWK encodes a public key as a JWK
+func publicJWK(pub crypto.PublicKey, alg Algorithm, kid string) (*JWK, error) {
+	jwk := &JWK{KeyID: kid, Use: "sig", Algorithm: string(alg)}
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = b64(k.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%w: only P-256 EC keys are supported", ErrUnsupportedKey)
+		}
+		jwk.KeyType = "EC"
+		jwk.Curve = "P-256"
+		ecdh, err := k.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedKey, err)
+		}
+		point := ecdh.Bytes() // 0x04 || X || Y
+		jwk.X = b64(point[1:33])
+		jwk.Y = b64(point[33:])
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = b64(k)
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, pub)
+	}
+	return jwk, nil
+}
+
+// PublicKey decodes the JWK into a public key
+func (j *JWK) PublicKey() (crypto.PublicKey, error) {
+	switch j.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %w", err)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if j.Curve != "P-256" {
+			return nil, fmt.Errorf("%w: curve %s", ErrUnsupportedKey, j.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		x, y = pad32(x), pad32(y)
+		point := append([]byte{4}, append(x, y...)...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, fmt.Errorf("%w: point is not on P-256", ErrUnsupportedKey)
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid Ed25519 key", ErrUnsupportedKey)
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("%w: kty %s", ErrUnsupportedKey, j.KeyType)
+	}
+}
+
+// Thumbprint computes the RFC 7638 JWK thumbprint of a public key
+func Thumbprint(pub crypto.PublicKey) (string, error) {
+	jwk, err := publicJWK(pub, "", "")
+	if err != nil {
+		return "", err
+	}
+	// Required members only, in lexicographic order
+	var canonical string
+	switch jwk.KeyType {
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, jwk.E, jwk.N)
+	case "EC":
+		canonical = fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":%q,"y":%q}`, jwk.X, jwk.Y)
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":%q}`, jwk.X)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return b64(sum[:]), nil
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func pad32(b []byte) []byte {
+	if len(b) >= 32 {
+		return b
+	}
+	return append(make([]byte, 32-len(b)), b...)
+}
+
+// KeySet builds a verification-only key set from a JWKS document, e.g. one
+// fetched from an issuer's jwks_uri by a resource server
+func (j *JWKS) KeySet() (*KeySet, error) {
+	set := NewKeySet()
+	for i := range j.Keys {
+		pub, err := j.Keys[i].PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key %q: %w", j.Keys[i].KeyID, err)
+		}
+		alg := Algorithm(j.Keys[i].Algorithm)
+		if alg == "" {
+			alg = map[string]Algorithm{"RSA": RS256, "EC": ES256, "OKP": EdDSA}[j.Keys[i].KeyType]
+		}
+		if _, err := set.AddPublic(pub, alg, j.Keys[i].KeyID); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
@@ -0,0 +1,153 @@
+package token
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestKeySet(t *testing.T) {
+	ctx := context.Background()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+
+	keys := NewKeySet()
+	rsaID, err := keys.Add(rsaKey, RS256, "rsa-1")
+	if err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+	ecID, err := keys.Add(ecKey, "", "")
+	if err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+	if _, err := keys.Add(edKey, EdDSA, "ed-1"); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+
+	t.Run("Thumbprint Key ID", func(t *testing.T) {
+		thumb, err := Thumbprint(ecKey.Public())
+		if err != nil {
+			t.Fatalf("Thumbprint() error: %v", err)
+		}
+		if ecID != thumb {
+			t.Errorf("Expected kid %s, got %s", thumb, ecID)
+		}
+	})
+
+	t.Run("RFC 7638 Vector", func(t *testing.T) {
+		jwk := JWK{
+			KeyType: "RSA",
+			N:       "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+			E:       "AQAB",
+		}
+		pub, err := jwk.PublicKey()
+		if err != nil {
+			t.Fatalf("PublicKey() error: %v", err)
+		}
+		thumb, err := Thumbprint(pub)
+		if err != nil {
+			t.Fatalf("Thumbprint() error: %v", err)
+		}
+		if thumb != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+			t.Errorf("Unexpected thumbprint %s", thumb)
+		}
+	})
+
+	t.Run("Offline Verification From JWKS", func(t *testing.T) {
+		svc := NewService(Config{KeySet: keys, ValidityPeriod: time.Hour}, NewMemoryStore(time.Hour))
+		issued, err := svc.Issue(ctx, &Token{ID: NewID(), Type: Access, Subject: "agent-1"})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		if issued.Algorithm != RS256 {
+			t.Errorf("Expected first key to be active, got %s", issued.Algorithm)
+		}
+
+		srv := httptest.NewServer(keys.Handler(time.Minute))
+		defer srv.Close()
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Get() error: %v", err)
+		}
+		defer resp.Body.Close()
+		if cc := resp.Header.Get("Cache-Control"); cc != "public, max-age=60" {
+			t.Errorf("Unexpected Cache-Control %q", cc)
+		}
+		var doc JWKS
+		if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+			t.Fatalf("Decode() error: %v", err)
+		}
+		if len(doc.Keys) != 3 {
+			t.Fatalf("Expected 3 published keys, got %d", len(doc.Keys))
+		}
+		for _, k := range doc.Keys {
+			if k.KeyID == "" || k.Use != "sig" {
+				t.Errorf("Incomplete JWK %+v", k)
+			}
+		}
+
+		remote, err := doc.KeySet()
+		if err != nil {
+			t.Fatalf("KeySet() error: %v", err)
+		}
+		parsed, err := remote.Verify(issued.Value)
+		if err != nil {
+			t.Fatalf("Verify() error: %v", err)
+		}
+		if parsed.Subject != "agent-1" {
+			t.Errorf("Unexpected subject %q", parsed.Subject)
+		}
+	})
+
+	t.Run("Rotation Keeps Old Tokens Verifiable", func(t *testing.T) {
+		svc := NewService(Config{KeySet: keys, ValidityPeriod: time.Hour}, NewMemoryStore(time.Hour))
+		old, err := svc.Issue(ctx, &Token{ID: NewID(), Type: Access, Subject: "agent-1"})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		if err := keys.Activate("ed-1"); err != nil {
+			t.Fatalf("Activate() error: %v", err)
+		}
+		fresh, err := svc.Issue(ctx, &Token{ID: NewID(), Type: Access, Subject: "agent-1"})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		if fresh.Algorithm != EdDSA {
+			t.Errorf("Expected EdDSA after activation, got %s", fresh.Algorithm)
+		}
+		for _, tok := range []*Token{old, fresh} {
+			if err := svc.Validate(ctx, tok); err != nil {
+				t.Errorf("Validate() error: %v", err)
+			}
+		}
+
+		if err := keys.Remove("ed-1"); err == nil {
+			t.Error("Expected removing the active key to fail")
+		}
+		if err := keys.Remove(rsaID); err != nil {
+			t.Fatalf("Remove() error: %v", err)
+		}
+		if _, err := keys.Verify(old.Value); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Expected ErrKeyNotFound after removal, got %v", err)
+		}
+	})
+}
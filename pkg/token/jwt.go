@@ -18,6 +18,9 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// ClaimRFC111 is the JWT claim carrying the token's RFC111 metadata
+const ClaimRFC111 = "gauth"
+
 // JWTSigner handles JWT token signing and verification
 type JWTSigner struct {
 	signingKey crypto.Signer
@@ -60,6 +63,19 @@ func (s *JWTSigner) SignToken(token *Token) (string, error) {
 		if token.Metadata.AppData != nil {
 			claims["meta"] = token.Metadata.AppData
 		}
+		// RFC111: the full metadata (device, delegation attributes, labels)
+		// travels with the token so relying parties need no store lookup
+		claims[ClaimRFC111] = token.Metadata
+	}
+
+	// RFC 8705 confirmation claim for certificate-bound tokens
+	if thumbprint := CertThumbprintOf(token); thumbprint != "" {
+		claims["cnf"] = map[string]string{AppDataCertThumbprint: thumbprint}
+	}
+
+	// Sandbox tokens are marked for relying parties that ignore metadata
+	if IsSandbox(token) {
+		claims[ClaimSandbox] = true
 	}
 
 	jwtToken := jwt.NewWithClaims(jwtSigningMethod(s.signingAlg), claims)
@@ -92,13 +108,29 @@ func (s *JWTSigner) VerifyToken(tokenString string) (*Token, error) {
 	return token, nil
 }
 
-func (s *JWTSigner) parseJWTToken(tokenString string) (*jwt.Token, error) {
+// ParseToken verifies the signature of a JWT and decodes its claims without
+// checking expiry or not-before, leaving time validation to the caller
+func (s *JWTSigner) ParseToken(tokenString string) (*Token, error) {
+	jwtToken, err := s.parseJWTToken(tokenString, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.extractClaims(jwtToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.createTokenFromClaims(tokenString, claims), nil
+}
+
+func (s *JWTSigner) parseJWTToken(tokenString string, opts ...jwt.ParserOption) (*jwt.Token, error) {
 	jwtToken, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if token.Method != jwtSigningMethod(s.signingAlg) {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return s.verifyKey, nil
-	})
+	}, opts...)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse JWT: %w", err)
@@ -128,6 +160,10 @@ func (s *JWTSigner) createTokenFromClaims(tokenString string, claims jwt.MapClai
 
 	s.parseStandardClaims(token, claims)
 	s.parseCustomClaims(token, claims)
+	parseConfirmationClaim(token, claims)
+	if sandbox, _ := claims[ClaimSandbox].(bool); sandbox {
+		stampSandbox(token)
+	}
 
 	return token
 }
@@ -186,6 +222,15 @@ func (s *JWTSigner) parseScopesClaim(token *Token, claims jwt.MapClaims) {
 }
 
 func (s *JWTSigner) parseCustomClaims(token *Token, claims jwt.MapClaims) {
+	if raw, ok := claims[ClaimRFC111]; ok {
+		if b, err := json.Marshal(raw); err == nil {
+			var md Metadata
+			if json.Unmarshal(b, &md) == nil {
+				token.Metadata = &md
+				return
+			}
+		}
+	}
 	if metaVal, ok := claims["meta"].(map[string]interface{}); ok {
 		appData := make(map[string]string, len(metaVal))
 		for k, v := range metaVal {
@@ -199,6 +244,23 @@ func (s *JWTSigner) parseCustomClaims(token *Token, claims jwt.MapClaims) {
 	}
 }
 
+// parseConfirmationClaim records the cnf certificate thumbprint of tokens
+// issued by other RFC 8705 servers
+func parseConfirmationClaim(token *Token, claims jwt.MapClaims) {
+	cnf, ok := claims["cnf"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	thumbprint, ok := cnf[AppDataCertThumbprint].(string)
+	if !ok || thumbprint == "" || CertThumbprintOf(token) != "" {
+		return
+	}
+	if token.Metadata.AppData == nil {
+		token.Metadata.AppData = make(map[string]string)
+	}
+	token.Metadata.AppData[AppDataCertThumbprint] = thumbprint
+}
+
 // jwtSigningMethod converts our Algorithm type to jwt.SigningMethod
 func jwtSigningMethod(alg Algorithm) jwt.SigningMethod {
 	switch alg {
@@ -210,6 +272,8 @@ func jwtSigningMethod(alg Algorithm) jwt.SigningMethod {
 		return jwt.SigningMethodHS256
 	case PS256:
 		return jwt.SigningMethodPS256
+	case EdDSA:
+		return jwt.SigningMethodEdDSA
 	default:
 		return jwt.SigningMethodRS256
 	}
@@ -1,6 +1,14 @@
 package token
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
 	"testing"
 	"time"
 )
@@ -68,3 +76,112 @@ func TestJWTSigner(t *testing.T) {
 		}
 	})
 }
+
+func TestServiceSigning(t *testing.T) {
+	ctx := context.Background()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+
+	keys := []struct {
+		alg Algorithm
+		key crypto.Signer
+	}{{RS256, rsaKey}, {ES256, ecKey}, {EdDSA, edKey}}
+
+	for _, k := range keys {
+		t.Run(string(k.alg), func(t *testing.T) {
+			svc := NewService(Config{SigningKey: k.key, ValidityPeriod: time.Hour}, NewMemoryStore(time.Hour))
+			issued, err := svc.Issue(ctx, &Token{
+				ID:      NewID(),
+				Type:    Access,
+				Subject: "agent-1",
+				Scopes:  []string{"payments:execute"},
+				Metadata: &Metadata{
+					AppData:    map[string]string{"principal": "alice"},
+					Attributes: map[string][]string{AttributeDerivationChain: {"poa-1"}},
+				},
+			})
+			if err != nil {
+				t.Fatalf("Issue() error: %v", err)
+			}
+			if issued.Algorithm != k.alg {
+				t.Errorf("Expected %s, got %s", k.alg, issued.Algorithm)
+			}
+
+			parsed, err := svc.Parse(ctx, issued.Value)
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			if parsed.ID != issued.ID || parsed.Subject != "agent-1" {
+				t.Errorf("Unexpected parsed token %+v", parsed)
+			}
+			if parsed.Metadata.Attributes[AttributeDerivationChain][0] != "poa-1" {
+				t.Errorf("Expected RFC111 metadata in claims, got %+v", parsed.Metadata)
+			}
+			if err := svc.Validate(ctx, parsed); err != nil {
+				t.Errorf("Validate() error: %v", err)
+			}
+
+			tampered := issued.Value[:len(issued.Value)-4] + "AAAA"
+			if _, err := svc.Parse(ctx, tampered); err == nil {
+				t.Error("Expected tampered token to be rejected")
+			}
+		})
+	}
+
+	t.Run("Signed Claims Win", func(t *testing.T) {
+		svc := NewService(Config{
+			SigningKey:     ecKey,
+			ValidityPeriod: time.Hour,
+			Grace:          &GracePolicy{Period: time.Hour, Scopes: []string{"read"}},
+		}, NewMemoryStore(time.Hour))
+		issued, err := svc.Issue(ctx, &Token{
+			ID:        NewID(),
+			Type:      Access,
+			Subject:   "agent-1",
+			Scopes:    []string{"payments:execute"},
+			ExpiresAt: time.Now().Add(-time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+
+		var verr *ValidationError
+		forged := *issued
+		forged.ExpiresAt = time.Now().Add(time.Hour)
+		if err := svc.Validate(ctx, &forged); !errors.As(err, &verr) || verr.Code != ValidationCodeExpired {
+			t.Errorf("Expected extended expiry to be ignored, got %v", err)
+		}
+		forged = *issued
+		forged.Scopes = []string{"read"}
+		if err := svc.Validate(ctx, &forged); !errors.As(err, &verr) || verr.Code != ValidationCodeExpired {
+			t.Errorf("Expected grace to follow the signed scopes, got %v", err)
+		}
+
+		access, err := svc.Issue(ctx, &Token{ID: NewID(), Type: Access, Subject: "agent-1"})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		forged = *access
+		forged.Type = Refresh
+		if _, err := svc.Refresh(ctx, &forged); !errors.As(err, &verr) || verr.Code != ValidationCodeInvalidType {
+			t.Errorf("Expected access token to be refused for refresh, got %v", err)
+		}
+	})
+
+	t.Run("Mismatched Key", func(t *testing.T) {
+		svc := NewService(Config{SigningKey: ecKey, SigningMethod: RS256, ValidityPeriod: time.Hour}, NewMemoryStore(time.Hour))
+		if _, err := svc.Issue(ctx, &Token{ID: NewID(), Type: Access, Subject: "agent-1"}); err == nil {
+			t.Error("Expected issuance to fail for an ECDSA key with RS256")
+		}
+	})
+}
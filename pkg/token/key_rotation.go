@@ -0,0 +1,214 @@
+package token
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// KeyBackend creates and destroys signing keys. A KMS or HSM backend
+// returns a crypto.Signer that delegates signing to the service, so the
+// private key never enters the process.
+type KeyBackend interface {
+	// GenerateKey creates a new signing key for the algorithm. An empty kid
+	// lets the key set derive one from the public key.
+	GenerateKey(ctx context.Context, alg Algorithm) (kid string, signer crypto.Signer, err error)
+
+	// DestroyKey is called once a key has dropped out of the retained
+	// history and no longer verifies any token
+	DestroyKey(ctx context.Context, kid string) error
+}
+
+// LocalKeyBackend generates keys in process memory
+type LocalKeyBackend struct{}
+
+// GenerateKey implements KeyBackend
+func (LocalKeyBackend) GenerateKey(_ context.Context, alg Algorithm) (string, crypto.Signer, error) {
+	var (
+		signer crypto.Signer
+		err    error
+	)
+	switch alg {
+	case RS256, PS256:
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	case ES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case EdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return "", nil, fmt.Errorf("%w: %s", ErrUnsupportedKey, alg)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate %s key: %w", alg, err)
+	}
+	return "", signer, nil
+}
+
+// DestroyKey implements KeyBackend; local keys are simply dropped
+func (LocalKeyBackend) DestroyKey(context.Context, string) error {
+	return nil
+}
+
+// KeyRotatorConfig configures a KeyRotator
+type KeyRotatorConfig struct {
+	// KeySet receives the new keys; its JWKS publishes them
+	KeySet *KeySet
+
+	// Backend creates and destroys keys (default: LocalKeyBackend)
+	Backend KeyBackend
+
+	// Algorithm of newly generated keys (default: RS256)
+	Algorithm Algorithm
+
+	// Interval between scheduled rotations (default: 24h)
+	Interval time.Duration
+
+	// History is how many previous keys stay published for verification
+	// (default: 2). History × Interval should exceed the longest token
+	// lifetime, or tokens stop verifying before they expire.
+	History int
+
+	// Events receives a key_rotation event for every rotation attempt
+	Events events.EventHandler
+}
+
+// KeyRotator rotates the active signing key of a KeySet on a schedule and
+// retires keys once they fall out of the retained history
+type KeyRotator struct {
+	config KeyRotatorConfig
+	mu     sync.Mutex
+
+	// managed are the key IDs this rotator created, oldest first
+	managed     []string
+	lastRotated time.Time
+}
+
+// NewKeyRotator creates a key rotator
+func NewKeyRotator(config KeyRotatorConfig) (*KeyRotator, error) {
+	if config.KeySet == nil {
+		return nil, fmt.Errorf("%w: key set is required", ErrInvalidConfig)
+	}
+	if config.Backend == nil {
+		config.Backend = LocalKeyBackend{}
+	}
+	if config.Algorithm == "" {
+		config.Algorithm = RS256
+	}
+	if config.Interval <= 0 {
+		config.Interval = 24 * time.Hour
+	}
+	if config.History <= 0 {
+		config.History = 2
+	}
+	return &KeyRotator{config: config}, nil
+}
+
+// Rotate generates a new key, makes it active and retires keys beyond the
+// retained history. It returns the new key's ID.
+func (r *KeyRotator) Rotate(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, _, _, _ := r.config.KeySet.Active()
+
+	kid, signer, err := r.config.Backend.GenerateKey(ctx, r.config.Algorithm)
+	if err != nil {
+		r.emit(ctx, events.StatusFailure, "", previous, fmt.Sprintf("key generation failed: %v", err))
+		return "", fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	kid, err = r.config.KeySet.Add(signer, r.config.Algorithm, kid)
+	if err != nil {
+		r.emit(ctx, events.StatusFailure, kid, previous, fmt.Sprintf("key rejected by key set: %v", err))
+		return "", fmt.Errorf("failed to add signing key: %w", err)
+	}
+	if err := r.config.KeySet.Activate(kid); err != nil {
+		r.emit(ctx, events.StatusFailure, kid, previous, fmt.Sprintf("key activation failed: %v", err))
+		return "", fmt.Errorf("failed to activate signing key: %w", err)
+	}
+	r.managed = append(r.managed, kid)
+	r.lastRotated = time.Now()
+
+	retired, retireErr := r.retire(ctx)
+	r.emit(ctx, events.StatusSuccess, kid, previous,
+		fmt.Sprintf("signing key rotated, %d key(s) retired", len(retired)))
+	if retireErr != nil {
+		return kid, fmt.Errorf("failed to retire old keys: %w", retireErr)
+	}
+	return kid, nil
+}
+
+// retire removes managed keys beyond the history from the key set and
+// destroys them in the backend
+func (r *KeyRotator) retire(ctx context.Context) ([]string, error) {
+	var retired []string
+	for len(r.managed) > r.config.History+1 {
+		kid := r.managed[0]
+		if err := r.config.KeySet.Remove(kid); err != nil && err != ErrKeyNotFound {
+			return retired, err
+		}
+		if err := r.config.Backend.DestroyKey(ctx, kid); err != nil {
+			return retired, fmt.Errorf("failed to destroy key %s: %w", kid, err)
+		}
+		r.managed = r.managed[1:]
+		retired = append(retired, kid)
+	}
+	return retired, nil
+}
+
+// LastRotated returns when the key was last rotated, or the zero time
+func (r *KeyRotator) LastRotated() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastRotated
+}
+
+// Run rotates every Interval until ctx is cancelled. If the key set has no
+// active key, it rotates immediately. Errors are reported to onError when it
+// is non-nil.
+func (r *KeyRotator) Run(ctx context.Context, onError func(error)) {
+	if _, _, _, err := r.config.KeySet.Active(); err != nil {
+		if _, err := r.Rotate(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Rotate(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func (r *KeyRotator) emit(ctx context.Context, status events.EventStatus, kid, previous, message string) {
+	if r.config.Events == nil {
+		return
+	}
+	event := events.NewSystemEvent(events.ActionKeyRotation, status).
+		WithContext(ctx).
+		WithMessage(message).
+		WithStringMetadata("algorithm", string(r.config.Algorithm))
+	if kid != "" {
+		event = event.WithResource(kid).WithStringMetadata("kid", kid)
+	}
+	if previous != "" {
+		event = event.WithStringMetadata("previous_kid", previous)
+	}
+	r.config.Events.Handle(event)
+}
@@ -0,0 +1,112 @@
+package token
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// recordingBackend wraps LocalKeyBackend and records destroyed keys
+type recordingBackend struct {
+	LocalKeyBackend
+	fail      error
+	destroyed []string
+}
+
+func (b *recordingBackend) GenerateKey(ctx context.Context, alg Algorithm) (string, crypto.Signer, error) {
+	if b.fail != nil {
+		return "", nil, b.fail
+	}
+	return b.LocalKeyBackend.GenerateKey(ctx, alg)
+}
+
+func (b *recordingBackend) DestroyKey(_ context.Context, kid string) error {
+	b.destroyed = append(b.destroyed, kid)
+	return nil
+}
+
+func TestKeyRotator(t *testing.T) {
+	ctx := context.Background()
+	keys := NewKeySet()
+	backend := &recordingBackend{}
+	handler := &captureHandler{}
+	rotator, err := NewKeyRotator(KeyRotatorConfig{
+		KeySet:    keys,
+		Backend:   backend,
+		Algorithm: ES256,
+		History:   1,
+		Events:    handler,
+	})
+	if err != nil {
+		t.Fatalf("NewKeyRotator() error: %v", err)
+	}
+	svc := NewService(Config{KeySet: keys, ValidityPeriod: time.Hour}, NewMemoryStore(time.Hour))
+
+	t.Run("History Retained", func(t *testing.T) {
+		first, err := rotator.Rotate(ctx)
+		if err != nil {
+			t.Fatalf("Rotate() error: %v", err)
+		}
+		old, err := svc.Issue(ctx, &Token{ID: NewID(), Type: Access, Subject: "agent-1"})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+
+		second, err := rotator.Rotate(ctx)
+		if err != nil {
+			t.Fatalf("Rotate() error: %v", err)
+		}
+		if active, _, _, _ := keys.Active(); active != second {
+			t.Errorf("Expected %s active, got %s", second, active)
+		}
+		if err := svc.Validate(ctx, old); err != nil {
+			t.Errorf("Expected token signed with previous key to validate, got %v", err)
+		}
+
+		if _, err := rotator.Rotate(ctx); err != nil {
+			t.Fatalf("Rotate() error: %v", err)
+		}
+		if len(keys.Keys()) != 2 {
+			t.Errorf("Expected active key plus 1 historical key, got %d", len(keys.Keys()))
+		}
+		if len(backend.destroyed) != 1 || backend.destroyed[0] != first {
+			t.Errorf("Expected %s destroyed, got %v", first, backend.destroyed)
+		}
+		if err := svc.Validate(ctx, old); err == nil {
+			t.Error("Expected token signed with a retired key to fail validation")
+		}
+	})
+
+	t.Run("Rotation Events", func(t *testing.T) {
+		if len(handler.events) != 3 {
+			t.Fatalf("Expected 3 events, got %d", len(handler.events))
+		}
+		last := handler.events[2]
+		if last.Action != string(events.ActionKeyRotation) || last.Status != string(events.StatusSuccess) {
+			t.Errorf("Unexpected event %s/%s", last.Action, last.Status)
+		}
+		if kid, _ := last.Metadata.GetString("previous_kid"); kid == "" {
+			t.Error("Expected previous_kid metadata")
+		}
+	})
+
+	t.Run("Backend Failure Keeps Active Key", func(t *testing.T) {
+		before, _, _, _ := keys.Active()
+		backend.fail = errors.New("kms unavailable")
+		defer func() { backend.fail = nil }()
+
+		if _, err := rotator.Rotate(ctx); err == nil {
+			t.Fatal("Expected rotation to fail")
+		}
+		if after, _, _, _ := keys.Active(); after != before {
+			t.Errorf("Expected active key unchanged, got %s", after)
+		}
+		if last := handler.events[len(handler.events)-1]; last.Status != string(events.StatusFailure) {
+			t.Errorf("Expected failure event, got %s", last.Status)
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package token
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type subjectHolds map[string]bool
+
+func (h subjectHolds) HoldsSubject(subject string) bool { return h[subject] }
+
+func TestMemoryStoreLegalHolds(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(time.Hour)
+	if err := WithLegalHolds(subjectHolds{"held": true})(store); err != nil {
+		t.Fatalf("WithLegalHolds() error: %v", err)
+	}
+
+	expired := time.Now().Add(-time.Minute)
+	for _, sub := range []string{"held", "free"} {
+		if err := store.Save(ctx, sub, &Token{ID: sub, Subject: sub, ExpiresAt: expired}); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+	}
+
+	if err := store.Cleanup(ctx); err != nil {
+		t.Fatalf("Cleanup() error: %v", err)
+	}
+	if _, ok := store.tokens["held"]; !ok {
+		t.Error("Expected held subject's token to survive cleanup")
+	}
+	if _, ok := store.tokens["free"]; ok {
+		t.Error("Expected unheld expired token to be removed")
+	}
+}
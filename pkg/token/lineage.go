@@ -0,0 +1,184 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Metadata keys and derivations for token lineage
+const (
+	// AppDataFamilyID holds the ID of the original grant a token descends from
+	AppDataFamilyID = "family_id"
+
+	// DerivationRotation marks tokens produced by Rotator.RotateToken
+	DerivationRotation = "rotation"
+
+	// DerivationExchange marks tokens obtained by exchanging another token,
+	// e.g. with an RFC 8693 token exchange
+	DerivationExchange = "exchange"
+)
+
+// LineageNode is one token of a family
+type LineageNode struct {
+	ID         string            `json:"id"`
+	ParentID   string            `json:"parent_id,omitempty"`
+	Derivation string            `json:"derivation,omitempty"`
+	Depth      int               `json:"depth"`
+	Type       Type              `json:"type"`
+	Subject    string            `json:"subject"`
+	Scopes     []string          `json:"scopes,omitempty"`
+	IssuedAt   time.Time         `json:"issued_at"`
+	ExpiresAt  time.Time         `json:"expires_at"`
+	Revocation *RevocationStatus `json:"revocation,omitempty"`
+}
+
+// Lineage is every stored token descended from one original grant
+type Lineage struct {
+	// FamilyID is the ID of the original grant
+	FamilyID string `json:"family_id"`
+
+	// Nodes are ordered by depth, root first, then by issue time
+	Nodes []LineageNode `json:"nodes"`
+}
+
+// Derive records parent as the origin of child: the parent ID, the
+// derivation, the family and the derivation chain. Use it when minting a
+// token from another one, e.g. for token exchange, before issuing child.
+func Derive(child, parent *Token, derivation string) {
+	if child.Metadata == nil {
+		child.Metadata = &Metadata{}
+	}
+	setLineage(child.Metadata, parent, derivation)
+}
+
+// setLineage writes the lineage of a token derived from parent to meta
+func setLineage(meta *Metadata, parent *Token, derivation string) {
+	if meta.AppData == nil {
+		meta.AppData = make(map[string]string)
+	}
+	if meta.Attributes == nil {
+		meta.Attributes = make(map[string][]string)
+	}
+	meta.AppData[AppDataParentTokenID] = parent.ID
+	meta.AppData[AppDataDerivation] = derivation
+	meta.AppData[AppDataFamilyID] = FamilyID(parent)
+	meta.Attributes[AttributeDerivationChain] = append(append([]string(nil), derivationChain(parent)...), parent.ID)
+}
+
+// FamilyID returns the ID of the original grant a token descends from; a
+// token that was not derived from another is its own family
+func FamilyID(t *Token) string {
+	if t.Metadata != nil {
+		if family := t.Metadata.AppData[AppDataFamilyID]; family != "" {
+			return family
+		}
+	}
+	if chain := derivationChain(t); len(chain) > 0 {
+		return chain[0]
+	}
+	return t.ID
+}
+
+// GetLineage returns the family of a token: the original grant and every
+// stored token refreshed, rotated, stepped down or exchanged from it.
+// tokenID may name any member or, once the member is gone, the family.
+func GetLineage(ctx context.Context, store Store, tokenID string) (*Lineage, error) {
+	family, members, err := familyOf(ctx, store, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(members, func(i, j int) bool {
+		di, dj := len(derivationChain(members[i])), len(derivationChain(members[j]))
+		if di != dj {
+			return di < dj
+		}
+		if !members[i].IssuedAt.Equal(members[j].IssuedAt) {
+			return members[i].IssuedAt.Before(members[j].IssuedAt)
+		}
+		return members[i].ID < members[j].ID
+	})
+	lineage := &Lineage{FamilyID: family, Nodes: make([]LineageNode, 0, len(members))}
+	for _, t := range members {
+		node := LineageNode{
+			ID:         t.ID,
+			ParentID:   parentTokenID(t),
+			Depth:      len(derivationChain(t)),
+			Type:       t.Type,
+			Subject:    t.Subject,
+			Scopes:     append([]string(nil), t.Scopes...),
+			IssuedAt:   t.IssuedAt,
+			ExpiresAt:  t.ExpiresAt,
+			Revocation: t.RevocationStatus,
+		}
+		if t.Metadata != nil {
+			node.Derivation = t.Metadata.AppData[AppDataDerivation]
+		}
+		lineage.Nodes = append(lineage.Nodes, node)
+	}
+	return lineage, nil
+}
+
+// RevokeFamily revokes every stored token of a token's family, ancestors
+// included, deepest first. tokenID may name any member or the family. It
+// returns the revoked token IDs.
+func RevokeFamily(ctx context.Context, store Store, tokenID string, opts RevokeChainOptions) ([]string, error) {
+	if err := checkReason(opts.Reason); err != nil {
+		return nil, err
+	}
+	family, members, err := familyOf(ctx, store, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(members, func(i, j int) bool {
+		di, dj := len(derivationChain(members[i])), len(derivationChain(members[j]))
+		if di != dj {
+			return di > dj
+		}
+		return members[i].ID < members[j].ID
+	})
+
+	now := time.Now()
+	revoked := make([]string, 0, len(members))
+	for _, t := range members {
+		if t.RevocationStatus != nil {
+			continue
+		}
+		t.RevocationStatus = &RevocationStatus{RevokedAt: now, Reason: opts.Reason, Detail: opts.Detail, RevokedBy: opts.RevokedBy}
+		if err := store.Revoke(ctx, t); err != nil && !errors.Is(err, ErrTokenNotFound) {
+			emitChainRevocation(ctx, opts, t, family, err)
+			return revoked, fmt.Errorf("failed to revoke token %s: %w", t.ID, err)
+		}
+		revoked = append(revoked, t.ID)
+		emitChainRevocation(ctx, opts, t, family, nil)
+	}
+	return revoked, nil
+}
+
+// familyOf resolves tokenID to a family and lists its stored members
+func familyOf(ctx context.Context, store Store, tokenID string) (string, []*Token, error) {
+	family := tokenID
+	t, err := store.Get(ctx, tokenID)
+	switch {
+	case err == nil:
+		family = FamilyID(t)
+	case !errors.Is(err, ErrTokenNotFound):
+		return "", nil, err
+	}
+	all, err := store.List(ctx, Filter{})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+	var members []*Token
+	for _, candidate := range all {
+		if FamilyID(candidate) == family {
+			members = append(members, candidate)
+		}
+	}
+	if len(members) == 0 {
+		return "", nil, ErrTokenNotFound
+	}
+	return family, members, nil
+}
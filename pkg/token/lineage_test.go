@@ -0,0 +1,92 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLineage(t *testing.T) {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	store := NewMemoryStore(time.Hour)
+	config := Config{SigningKey: key, ValidityPeriod: time.Hour, RefreshPeriod: time.Hour}
+	svc := NewService(config, store)
+
+	grant, err := svc.Issue(ctx, &Token{ID: NewID(), Type: Refresh, Subject: "agent-1", Scopes: []string{"read", "write"}})
+	if err != nil {
+		t.Fatalf("Issue() error: %v", err)
+	}
+	access, err := svc.Refresh(ctx, grant)
+	if err != nil {
+		t.Fatalf("Refresh() error: %v", err)
+	}
+	reduced, err := StepDown(ctx, svc, access, StepDownRequest{Scopes: []string{"read"}})
+	if err != nil {
+		t.Fatalf("StepDown() error: %v", err)
+	}
+	rotated, err := NewRotator(store, NewBlacklist(), config).RotateToken(ctx, reduced)
+	if err != nil {
+		t.Fatalf("RotateToken() error: %v", err)
+	}
+	exchanged := &Token{ID: NewID(), Type: Access, Subject: "service-b", Scopes: []string{"read"}}
+	Derive(exchanged, rotated, DerivationExchange)
+	if _, err := svc.Issue(ctx, exchanged); err != nil {
+		t.Fatalf("Issue() error: %v", err)
+	}
+	other, err := svc.Issue(ctx, &Token{ID: NewID(), Type: Access, Subject: "agent-2"})
+	if err != nil {
+		t.Fatalf("Issue() error: %v", err)
+	}
+
+	t.Run("Get Lineage", func(t *testing.T) {
+		lineage, err := GetLineage(ctx, store, reduced.ID)
+		if err != nil {
+			t.Fatalf("GetLineage() error: %v", err)
+		}
+		if lineage.FamilyID != grant.ID || len(lineage.Nodes) != 5 {
+			t.Fatalf("Expected the five tokens of the grant, got %+v", lineage)
+		}
+		want := []struct{ id, parent, derivation string }{
+			{grant.ID, "", ""},
+			{access.ID, grant.ID, DerivationRefresh},
+			{reduced.ID, access.ID, DerivationStepDown},
+			{rotated.ID, reduced.ID, DerivationRotation},
+			{exchanged.ID, rotated.ID, DerivationExchange},
+		}
+		for i, w := range want {
+			n := lineage.Nodes[i]
+			if n.ID != w.id || n.ParentID != w.parent || n.Derivation != w.derivation || n.Depth != i {
+				t.Errorf("Node %d: expected %+v, got %+v", i, w, n)
+			}
+		}
+		if FamilyID(other) != other.ID {
+			t.Errorf("Expected an original token to be its own family, got %q", FamilyID(other))
+		}
+	})
+
+	t.Run("Revoke Family", func(t *testing.T) {
+		revoked, err := RevokeFamily(ctx, store, exchanged.ID, RevokeChainOptions{Reason: ReasonCompromise, RevokedBy: "investigator"})
+		if err != nil {
+			t.Fatalf("RevokeFamily() error: %v", err)
+		}
+		if len(revoked) != 5 || revoked[0] != exchanged.ID || revoked[4] != grant.ID {
+			t.Errorf("Expected the family revoked deepest first, got %v", revoked)
+		}
+		if _, err := store.Get(ctx, other.ID); err != nil {
+			t.Errorf("Expected the unrelated token to survive, got %v", err)
+		}
+		if _, err := GetLineage(ctx, store, grant.ID); !errors.Is(err, ErrTokenNotFound) {
+			t.Errorf("Expected no stored members left, got %v", err)
+		}
+		if _, err := RevokeFamily(ctx, store, other.ID, RevokeChainOptions{}); !errors.Is(err, ErrInvalidRevocationReason) {
+			t.Errorf("Expected a reason to be required, got %v", err)
+		}
+	})
+}
@@ -20,6 +20,7 @@ type MemoryStore struct {
 	tokens    map[string]*Token
 	mu        sync.RWMutex
 	maxTokens int
+	holds     LegalHoldChecker
 }
 
 // NewMemoryStore creates a new memory-based token store
@@ -134,7 +135,7 @@ func (s *MemoryStore) List(ctx context.Context, filter Filter) ([]*Token, error)
 		matches = append(matches, copyToken(token))
 	}
 
-	return matches, nil
+	return applyPage(matches, filter)
 }
 
 // Rotate replaces an existing token with a new one
@@ -166,6 +167,63 @@ func (s *MemoryStore) Revoke(ctx context.Context, token *Token) error {
 	return s.Delete(ctx, token.ID)
 }
 
+// SaveBatch stores tokens under their IDs with one lock acquisition. The
+// batch is rejected whole when it would exceed the store's capacity.
+func (s *MemoryStore) SaveBatch(ctx context.Context, tokens []*Token) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxTokens > 0 {
+		added := 0
+		for _, token := range tokens {
+			if _, exists := s.tokens[token.ID]; !exists {
+				added++
+			}
+		}
+		if len(s.tokens)+added > s.maxTokens {
+			return ErrStorageFailure
+		}
+	}
+	for _, token := range tokens {
+		s.tokens[token.ID] = token
+	}
+	return nil
+}
+
+// RevokeBatch revokes tokens with one lock acquisition
+func (s *MemoryStore) RevokeBatch(ctx context.Context, tokens []*Token) error {
+	keys := make([]string, len(tokens))
+	for i, token := range tokens {
+		keys[i] = token.ID
+	}
+	return s.DeleteBatch(ctx, keys)
+}
+
+// DeleteBatch removes tokens with one lock acquisition. Missing keys are
+// reported in a BatchError.
+func (s *MemoryStore) DeleteBatch(ctx context.Context, keys []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failed := batchErrors{}
+	for _, key := range keys {
+		if _, exists := s.tokens[key]; !exists {
+			failed[key] = ErrTokenNotFound
+			continue
+		}
+		delete(s.tokens, key)
+	}
+	return failed.err()
+}
+
 // Validate checks if a token is valid
 func (s *MemoryStore) Validate(ctx context.Context, token *Token) error {
 	stored, err := s.Get(ctx, token.ID)
@@ -199,7 +257,9 @@ func matchesFilter(token *Token, filter Filter) bool {
 		matchesIdentityFilter(token, filter) &&
 		matchesTypeFilter(token, filter) &&
 		matchesScopeFilter(token, filter) &&
-		matchesActiveFilter(token, filter)
+		matchesActiveFilter(token, filter) &&
+		matchesMetadataFilter(token, filter) &&
+		filter.Query.Match(token)
 }
 
 func matchesTimeFilter(token *Token, filter Filter) bool {
@@ -319,7 +379,7 @@ func (s *MemoryStore) Cleanup(ctx context.Context) error {
 
 	now := time.Now()
 	for key, token := range s.tokens {
-		if token.ExpiresAt.Before(now) {
+		if token.ExpiresAt.Before(now) && !isHeld(s.holds, token) {
 			delete(s.tokens, key)
 		}
 	}
@@ -327,6 +387,17 @@ func (s *MemoryStore) Cleanup(ctx context.Context) error {
 	return nil
 }
 
+// SetLegalHolds makes Cleanup keep expired tokens of held subjects
+func (s *MemoryStore) SetLegalHolds(holds LegalHoldChecker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.holds = holds
+}
+
+func isHeld(holds LegalHoldChecker, token *Token) bool {
+	return holds != nil && token.Subject != "" && holds.HoldsSubject(token.Subject)
+}
+
 // Close releases resources used by the store
 // For MemoryStore, this is a no-op as there are no resources to release
 func (s *MemoryStore) Close() error {
@@ -393,6 +464,7 @@ func copyToken(t *Token) *Token {
 			metaCopy.Tags = make([]string, len(t.Metadata.Tags))
 			copy(metaCopy.Tags, t.Metadata.Tags)
 		}
+		metaCopy.Delegation = t.Metadata.Delegation.Clone()
 		tokCopy.Metadata = &metaCopy
 	}
 
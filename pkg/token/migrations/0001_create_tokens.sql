@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS gauth_tokens (
+    id TEXT PRIMARY KEY,
+    value TEXT,
+    subject TEXT NOT NULL DEFAULT '',
+    issuer TEXT NOT NULL DEFAULT '',
+    type TEXT NOT NULL DEFAULT '',
+    algorithm TEXT NOT NULL DEFAULT '',
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    audience TEXT[] NOT NULL DEFAULT '{}',
+    issued_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    not_before TIMESTAMP WITH TIME ZONE,
+    metadata JSONB,
+    body JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_gauth_tokens_subject ON gauth_tokens(subject);
+CREATE INDEX IF NOT EXISTS idx_gauth_tokens_issuer ON gauth_tokens(issuer);
+CREATE INDEX IF NOT EXISTS idx_gauth_tokens_type ON gauth_tokens(type);
+CREATE INDEX IF NOT EXISTS idx_gauth_tokens_expires_at ON gauth_tokens(expires_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_gauth_tokens_value ON gauth_tokens(value) WHERE value IS NOT NULL;
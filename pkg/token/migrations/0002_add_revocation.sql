@@ -0,0 +1,3 @@
+ALTER TABLE gauth_tokens ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMP WITH TIME ZONE;
+CREATE INDEX IF NOT EXISTS idx_gauth_tokens_active ON gauth_tokens(expires_at) WHERE revoked_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_gauth_tokens_scopes ON gauth_tokens USING gin(scopes);
@@ -0,0 +1,150 @@
+package token
+
+// Metadata claims that a ClaimPolicy can keep. Map claims also accept a
+// single key, e.g. "attributes.org_unit" or "app_data.poa_ref".
+const (
+	ClaimDevice     = "device"
+	ClaimApp        = "app" // AppID and AppVersion
+	ClaimAppData    = "app_data"
+	ClaimLabels     = "labels"
+	ClaimTags       = "tags"
+	ClaimAttributes = "attributes"
+
+	// ClaimDelegation keeps who delegated to whom and which scopes, without
+	// the restrictions and attestations of each link
+	ClaimDelegation = "delegation"
+
+	// ClaimDelegationRestrictions keeps the value, time and geographic
+	// restrictions of each link (implies ClaimDelegation)
+	ClaimDelegationRestrictions = "delegation.restrictions"
+
+	// ClaimDelegationAttestations keeps the attestations of each link
+	// (implies ClaimDelegation)
+	ClaimDelegationAttestations = "delegation.attestations"
+)
+
+// ClaimPolicy lists the metadata claims an audience needs. Standard claims
+// (subject, audience, scopes, times) are always kept, as are the app data
+// and attributes GAuth itself relies on: the certificate thumbprint,
+// correlation ID, parent token ID and derivation chain.
+type ClaimPolicy struct {
+	// Claims are kept for every token presented to the audience
+	Claims []string
+
+	// ScopeClaims are additionally kept for tokens carrying the scope
+	ScopeClaims map[string][]string
+}
+
+// ClaimMinimization strips the metadata claims a token's audiences do not
+// need before it is signed, so tokens presented to low-trust services do not
+// carry full power-of-attorney details. A token for several audiences keeps
+// the union of their claims. Only the signed value is minimized; the stored
+// token keeps every claim.
+type ClaimMinimization struct {
+	// Policies are keyed by audience
+	Policies map[string]ClaimPolicy
+
+	// Default applies to audiences without a policy and to tokens without an
+	// audience (nil = keep every claim)
+	Default *ClaimPolicy
+}
+
+// protectedAppData and protectedAttributes are never stripped
+var (
+	protectedAppData    = []string{AppDataCertThumbprint, AppDataCorrelationID, AppDataParentTokenID, AppDataFamilyID, AppDataSandbox}
+	protectedAttributes = []string{AttributeDerivationChain}
+)
+
+// Minimize strips the claims the token's audiences do not need
+func (m *ClaimMinimization) Minimize(token *Token) {
+	if m == nil || token.Metadata == nil {
+		return
+	}
+	keep, all := m.claimsFor(token)
+	if all {
+		return
+	}
+	md := token.Metadata
+	if !keep[ClaimDevice] {
+		md.Device = nil
+	}
+	if !keep[ClaimApp] {
+		md.AppID, md.AppVersion = "", ""
+	}
+	if !keep[ClaimTags] {
+		md.Tags = nil
+	}
+	md.AppData = minimizeMap(md.AppData, ClaimAppData, keep, protectedAppData)
+	md.Labels = minimizeMap(md.Labels, ClaimLabels, keep, nil)
+	md.Attributes = minimizeMap(md.Attributes, ClaimAttributes, keep, protectedAttributes)
+	md.Delegation = minimizeDelegation(md.Delegation, keep)
+}
+
+// claimsFor collects the claims needed by any of the token's audiences. It
+// reports all when one of them keeps every claim.
+func (m *ClaimMinimization) claimsFor(token *Token) (keep map[string]bool, all bool) {
+	var policies []*ClaimPolicy
+	for _, aud := range token.Audience {
+		if p, ok := m.Policies[aud]; ok {
+			policies = append(policies, &p)
+		} else {
+			policies = append(policies, m.Default)
+		}
+	}
+	if len(token.Audience) == 0 {
+		policies = append(policies, m.Default)
+	}
+	keep = make(map[string]bool)
+	for _, p := range policies {
+		if p == nil {
+			return nil, true
+		}
+		for _, c := range p.Claims {
+			keep[c] = true
+		}
+		for _, scope := range token.Scopes {
+			for _, c := range p.ScopeClaims[scope] {
+				keep[c] = true
+			}
+		}
+	}
+	return keep, false
+}
+
+// minimizeMap keeps the whole map if claim is kept, otherwise only the keys
+// kept as "claim.key" and the protected keys
+func minimizeMap[V any](m map[string]V, claim string, keep map[string]bool, protected []string) map[string]V {
+	if len(m) == 0 || keep[claim] {
+		return m
+	}
+	out := make(map[string]V)
+	for k, v := range m {
+		if keep[claim+"."+k] || containsString(protected, k) {
+			out[k] = v
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func minimizeDelegation(chain *DelegationChain, keep map[string]bool) *DelegationChain {
+	if chain == nil {
+		return nil
+	}
+	restrictions, attestations := keep[ClaimDelegationRestrictions], keep[ClaimDelegationAttestations]
+	if !keep[ClaimDelegation] && !restrictions && !attestations {
+		return nil
+	}
+	out := chain.Clone()
+	for i := range out.Links {
+		if !restrictions {
+			out.Links[i].Restrictions = nil
+		}
+		if !attestations {
+			out.Links[i].Attestations = nil
+		}
+	}
+	return out
+}
@@ -0,0 +1,164 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClaimMinimization(t *testing.T) {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	minimization := &ClaimMinimization{
+		Policies: map[string]ClaimPolicy{
+			"ledger": {Claims: []string{ClaimDelegationRestrictions, ClaimAttributes}},
+			"catalog": {
+				Claims:      []string{"attributes.org_unit"},
+				ScopeClaims: map[string][]string{"payments:read": {ClaimDelegation}},
+			},
+		},
+		Default: &ClaimPolicy{},
+	}
+	svc := NewService(Config{
+		SigningKey:        key,
+		ValidityPeriod:    time.Hour,
+		ClaimMinimization: minimization,
+	}, NewMemoryStore())
+
+	newToken := func(audience ...string) *Token {
+		return &Token{
+			ID:       NewID(),
+			Type:     Access,
+			Subject:  "ai-agent",
+			Audience: audience,
+			Scopes:   []string{"payments:read"},
+			Metadata: &Metadata{
+				AppID:      "treasury",
+				Tags:       []string{"vip"},
+				AppData:    map[string]string{"poa_ref": "deed-42", AppDataCorrelationID: "corr-1"},
+				Attributes: map[string][]string{"org_unit": {"finance"}, "clearance": {"high"}},
+				Delegation: &DelegationChain{Links: []DelegationLink{{
+					Principal:    "acme-corp",
+					Delegate:     "ai-agent",
+					Scopes:       []string{"payments:read"},
+					Restrictions: &Restrictions{ValueLimits: &ValueLimits{MaxTransactionValue: 500, Currency: "EUR"}},
+					Attestations: []Attestation{{Type: "notary", AttesterID: "notary-1"}},
+				}}},
+			},
+		}
+	}
+	issue := func(t *testing.T, tok *Token) *Metadata {
+		t.Helper()
+		issued, err := svc.Issue(ctx, tok)
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		parsed, err := svc.Parse(ctx, issued.Value)
+		if err != nil {
+			t.Fatalf("Parse() error: %v", err)
+		}
+		if parsed.Metadata == nil {
+			return &Metadata{}
+		}
+		return parsed.Metadata
+	}
+
+	t.Run("Trusted Audience Keeps Restrictions", func(t *testing.T) {
+		md := issue(t, newToken("ledger"))
+		link := md.Delegation.Links[0]
+		if link.Restrictions == nil || link.Attestations != nil {
+			t.Errorf("Expected restrictions without attestations, got %+v", link)
+		}
+		if len(md.Attributes) != 2 || md.AppID != "" || md.Tags != nil {
+			t.Errorf("Expected only attributes to be kept, got %+v", md)
+		}
+	})
+
+	t.Run("Low Trust Audience Gets Chain Only", func(t *testing.T) {
+		md := issue(t, newToken("catalog"))
+		if md.Delegation == nil || md.Delegation.Links[0].Restrictions != nil || md.Delegation.Holder() != "ai-agent" {
+			t.Errorf("Expected the chain without restrictions, got %+v", md.Delegation)
+		}
+		if len(md.Attributes) != 1 || md.Attributes["org_unit"][0] != "finance" {
+			t.Errorf("Expected only the org_unit attribute, got %+v", md.Attributes)
+		}
+		if md.AppData["poa_ref"] != "" || md.AppData[AppDataCorrelationID] != "corr-1" {
+			t.Errorf("Expected app data stripped except the correlation ID, got %+v", md.AppData)
+		}
+	})
+
+	t.Run("Unknown Audience Uses Default", func(t *testing.T) {
+		md := issue(t, newToken("partner"))
+		if md.Delegation != nil || md.Attributes != nil || md.AppID != "" {
+			t.Errorf("Expected every optional claim to be stripped, got %+v", md)
+		}
+	})
+
+	t.Run("Stored Token Keeps Every Claim", func(t *testing.T) {
+		issued, err := svc.Issue(ctx, newToken("partner"))
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		stored, err := svc.GetToken(ctx, issued.ID)
+		if err != nil {
+			t.Fatalf("GetToken() error: %v", err)
+		}
+		link := stored.Metadata.Delegation.Links[0]
+		if link.Restrictions == nil || link.Attestations == nil || stored.Metadata.AppID != "treasury" {
+			t.Errorf("Expected the stored token to be complete, got %+v", stored.Metadata)
+		}
+	})
+
+	t.Run("Exercise Enforces Limits Of Minimized Token", func(t *testing.T) {
+		d, err := NewDelegator(DelegatorConfig{Service: svc})
+		if err != nil {
+			t.Fatalf("NewDelegator() error: %v", err)
+		}
+		issued, err := d.Issue(ctx, "ai-agent", DelegationOptions{
+			Principal:    "acme-corp",
+			Scope:        "payments:write",
+			Restrictions: &Restrictions{ValueLimits: &ValueLimits{MaxTransactionValue: 500, Currency: "EUR"}},
+			ValidUntil:   time.Now().Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+
+		// the agent presents the signed value, which carries no limits
+		presented, err := svc.Parse(ctx, issued.Value)
+		if err != nil {
+			t.Fatalf("Parse() error: %v", err)
+		}
+		if presented.Metadata != nil && presented.Metadata.Delegation != nil {
+			t.Fatalf("Expected the default policy to strip the delegation, got %+v", presented.Metadata.Delegation)
+		}
+		if err := d.Exercise(ctx, presented, 400, "EUR"); err != nil {
+			t.Errorf("Expected a transaction within limits to pass, got %v", err)
+		}
+		if err := d.Exercise(ctx, presented, 900, "EUR"); !errors.Is(err, ErrValueLimitExceeded) {
+			t.Errorf("Expected ErrValueLimitExceeded, got %v", err)
+		}
+	})
+
+	t.Run("Audiences Keep The Union", func(t *testing.T) {
+		tok := newToken("catalog", "ledger")
+		minimization.Minimize(tok)
+		if tok.Metadata.Delegation.Links[0].Restrictions == nil || len(tok.Metadata.Attributes) != 2 {
+			t.Errorf("Expected the ledger claims to be kept, got %+v", tok.Metadata)
+		}
+	})
+
+	t.Run("No Default Keeps Everything", func(t *testing.T) {
+		tok := newToken("partner")
+		(&ClaimMinimization{Policies: minimization.Policies}).Minimize(tok)
+		if tok.Metadata.AppID != "treasury" || tok.Metadata.Delegation.Links[0].Attestations == nil {
+			t.Errorf("Expected claims to be untouched, got %+v", tok.Metadata)
+		}
+	})
+}
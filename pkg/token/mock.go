@@ -97,7 +97,7 @@ func (m *MockStore) List(_ context.Context, filter Filter) ([]*Token, error) {
 			matches = append(matches, copyToken(token))
 		}
 	}
-	return matches, nil
+	return applyPage(matches, filter)
 }
 
 // Rotate mocks token rotation
@@ -0,0 +1,646 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrInvalidQuery indicates a token query could not be parsed or compiled
+var ErrInvalidQuery = errors.New("invalid token query")
+
+// QueryExpr is a parsed token search expression. It is evaluated in memory by
+// Match, compiled to a SQL WHERE clause by SQL, and exposes index hints
+// (SubjectHints) for key-value stores.
+//
+// Syntax:
+//
+//	subject = "alice" AND scope CONTAINS "read"
+//	type = access_token AND expires_at < now+1h
+//	NOT (issuer = "legacy") OR metadata.tenant EXISTS
+//
+// Fields: id, subject, issuer, type, algorithm, scope, audience, issued_at,
+// expires_at, not_before and metadata.<key> (app data, then labels).
+// Operators: = != < <= > >= CONTAINS EXISTS, combined with AND, OR, NOT and
+// parentheses. Time values are RFC 3339 or now[+-duration].
+type QueryExpr struct {
+	root queryNode
+	text string
+}
+
+// ParseQuery parses a query expression
+func ParseQuery(s string) (*QueryExpr, error) {
+	tokens, err := lexQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidQuery, p.peek().text)
+	}
+	return &QueryExpr{root: root, text: s}, nil
+}
+
+// MustParseQuery is like ParseQuery but panics on error
+func MustParseQuery(s string) *QueryExpr {
+	q, err := ParseQuery(s)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// String returns the original expression
+func (q *QueryExpr) String() string {
+	if q == nil {
+		return ""
+	}
+	return q.text
+}
+
+// Match reports whether the token satisfies the query. A nil query matches
+// every token.
+func (q *QueryExpr) Match(t *Token) bool {
+	if q == nil {
+		return true
+	}
+	return q.root.match(t, time.Now())
+}
+
+// SubjectHints returns the subjects a matching token must have, when the
+// query pins the subject with equality at the top level. Stores with a
+// subject index use it to avoid full scans; nil means no hint.
+func (q *QueryExpr) SubjectHints() []string {
+	if q == nil {
+		return nil
+	}
+	return subjectHints(q.root)
+}
+
+func subjectHints(n queryNode) []string {
+	switch node := n.(type) {
+	case *queryCmp:
+		if node.field == "subject" && node.op == "=" {
+			return []string{node.value}
+		}
+	case *queryAnd:
+		for _, child := range node.children {
+			if hints := subjectHints(child); hints != nil {
+				return hints
+			}
+		}
+	case *queryOr:
+		var all []string
+		for _, child := range node.children {
+			hints := subjectHints(child)
+			if hints == nil {
+				return nil
+			}
+			all = append(all, hints...)
+		}
+		return all
+	}
+	return nil
+}
+
+// SQLColumns maps query fields to table columns. Scope and audience must be
+// TEXT[] columns and metadata a JSONB column holding the Metadata struct.
+type SQLColumns struct {
+	ID, Subject, Issuer, Type, Algorithm string
+	Scopes, Audience                     string
+	IssuedAt, ExpiresAt, NotBefore       string
+	Metadata                             string
+}
+
+// DefaultSQLColumns is the column layout used by the SQL token stores
+var DefaultSQLColumns = SQLColumns{
+	ID: "id", Subject: "subject", Issuer: "issuer", Type: "type", Algorithm: "algorithm",
+	Scopes: "scopes", Audience: "audience",
+	IssuedAt: "issued_at", ExpiresAt: "expires_at", NotBefore: "not_before",
+	Metadata: "metadata",
+}
+
+// SQL compiles the query to a PostgreSQL WHERE clause (without the WHERE
+// keyword) using numbered placeholders starting at firstArg
+func (q *QueryExpr) SQL(columns SQLColumns, firstArg int) (string, []interface{}, error) {
+	if q == nil {
+		return "TRUE", nil, nil
+	}
+	c := &sqlCompiler{columns: columns, next: firstArg, now: time.Now()}
+	clause, err := c.compile(q.root)
+	if err != nil {
+		return "", nil, err
+	}
+	return clause, c.args, nil
+}
+
+type queryNode interface {
+	match(t *Token, now time.Time) bool
+}
+
+type queryAnd struct{ children []queryNode }
+
+func (n *queryAnd) match(t *Token, now time.Time) bool {
+	for _, c := range n.children {
+		if !c.match(t, now) {
+			return false
+		}
+	}
+	return true
+}
+
+type queryOr struct{ children []queryNode }
+
+func (n *queryOr) match(t *Token, now time.Time) bool {
+	for _, c := range n.children {
+		if c.match(t, now) {
+			return true
+		}
+	}
+	return false
+}
+
+type queryNot struct{ child queryNode }
+
+func (n *queryNot) match(t *Token, now time.Time) bool { return !n.child.match(t, now) }
+
+type fieldKind int
+
+const (
+	fieldString fieldKind = iota
+	fieldList
+	fieldTime
+	fieldMetadata
+)
+
+var queryFields = map[string]fieldKind{
+	"id": fieldString, "subject": fieldString, "issuer": fieldString,
+	"type": fieldString, "algorithm": fieldString,
+	"scope": fieldList, "audience": fieldList,
+	"issued_at": fieldTime, "expires_at": fieldTime, "not_before": fieldTime,
+}
+
+// queryTime is an absolute time or an offset from evaluation time
+type queryTime struct {
+	abs      time.Time
+	relative bool
+	offset   time.Duration
+}
+
+func (qt queryTime) at(now time.Time) time.Time {
+	if qt.relative {
+		return now.Add(qt.offset)
+	}
+	return qt.abs
+}
+
+type queryCmp struct {
+	field   string
+	metaKey string
+	kind    fieldKind
+	op      string
+	value   string
+	when    queryTime
+}
+
+func (n *queryCmp) match(t *Token, now time.Time) bool {
+	switch n.kind {
+	case fieldString:
+		return compareString(n.op, n.stringField(t), n.value)
+	case fieldList:
+		values := t.Scopes
+		if n.field == "audience" {
+			values = t.Audience
+		}
+		if n.op == "EXISTS" {
+			return len(values) > 0
+		}
+		return containsString(values, n.value)
+	case fieldTime:
+		return compareTime(n.op, n.timeField(t), n.when.at(now))
+	case fieldMetadata:
+		v, ok := metadataValue(t, n.metaKey)
+		if n.op == "EXISTS" {
+			return ok
+		}
+		if !ok {
+			return n.op == "!="
+		}
+		return compareString(n.op, v, n.value)
+	}
+	return false
+}
+
+func (n *queryCmp) stringField(t *Token) string {
+	switch n.field {
+	case "id":
+		return t.ID
+	case "subject":
+		return t.Subject
+	case "issuer":
+		return t.Issuer
+	case "type":
+		return string(t.Type)
+	case "algorithm":
+		return string(t.Algorithm)
+	}
+	return ""
+}
+
+func (n *queryCmp) timeField(t *Token) time.Time {
+	switch n.field {
+	case "issued_at":
+		return t.IssuedAt
+	case "expires_at":
+		return t.ExpiresAt
+	}
+	return t.NotBefore
+}
+
+func metadataValue(t *Token, key string) (string, bool) {
+	if t.Metadata == nil {
+		return "", false
+	}
+	if v, ok := t.Metadata.AppData[key]; ok {
+		return v, true
+	}
+	v, ok := t.Metadata.Labels[key]
+	return v, ok
+}
+
+func compareString(op, a, b string) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case "CONTAINS":
+		return strings.Contains(a, b)
+	}
+	return false
+}
+
+func compareTime(op string, a, b time.Time) bool {
+	switch op {
+	case "=":
+		return a.Equal(b)
+	case "!=":
+		return !a.Equal(b)
+	case "<":
+		return a.Before(b)
+	case "<=":
+		return !a.After(b)
+	case ">":
+		return a.After(b)
+	case ">=":
+		return !a.Before(b)
+	}
+	return false
+}
+
+type queryToken struct {
+	text   string
+	quoted bool
+}
+
+func lexQuery(s string) ([]queryToken, error) {
+	var tokens []queryToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, queryToken{text: string(c)})
+			i++
+		case c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("%w: unterminated string", ErrInvalidQuery)
+			}
+			tokens = append(tokens, queryToken{text: b.String(), quoted: true})
+			i = j + 1
+		case strings.ContainsRune("=!<>", rune(c)):
+			j := i + 1
+			if j < len(s) && s[j] == '=' {
+				j++
+			}
+			op := s[i:j]
+			if op == "!" {
+				return nil, fmt.Errorf("%w: unexpected '!'", ErrInvalidQuery)
+			}
+			tokens = append(tokens, queryToken{text: op})
+			i = j
+		default:
+			j := i
+			for j < len(s) && !unicode.IsSpace(rune(s[j])) && !strings.ContainsRune("()\"=!<>", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, queryToken{text: s[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *queryParser) peek() queryToken {
+	if p.done() {
+		return queryToken{}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *queryParser) keyword(word string) bool {
+	t := p.peek()
+	if !t.quoted && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []queryNode{left}
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return &queryOr{children: children}, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	children := []queryNode{left}
+	for p.keyword("AND") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return &queryAnd{children: children}, nil
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	if p.keyword("NOT") {
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &queryNot{child: child}, nil
+	}
+	if t := p.peek(); !t.quoted && t.text == "(" {
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.quoted || t.text != ")" {
+			return nil, fmt.Errorf("%w: missing ')'", ErrInvalidQuery)
+		}
+		return node, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *queryParser) parseComparison() (queryNode, error) {
+	if p.done() {
+		return nil, fmt.Errorf("%w: unexpected end of query", ErrInvalidQuery)
+	}
+	name := p.next()
+	cmp := &queryCmp{field: strings.ToLower(name.text)}
+	if strings.HasPrefix(cmp.field, "metadata.") && len(cmp.field) > len("metadata.") {
+		cmp.kind = fieldMetadata
+		cmp.metaKey = name.text[len("metadata."):]
+	} else if kind, ok := queryFields[cmp.field]; ok && !name.quoted {
+		cmp.kind = kind
+	} else {
+		return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, name.text)
+	}
+
+	op := p.next()
+	cmp.op = strings.ToUpper(op.text)
+	if op.quoted {
+		return nil, fmt.Errorf("%w: expected operator after %s", ErrInvalidQuery, name.text)
+	}
+	if cmp.op == "EXISTS" {
+		if cmp.kind != fieldList && cmp.kind != fieldMetadata {
+			return nil, fmt.Errorf("%w: EXISTS is not supported for %s", ErrInvalidQuery, cmp.field)
+		}
+		return cmp, nil
+	}
+	if !validOperator(cmp.kind, cmp.op) {
+		return nil, fmt.Errorf("%w: operator %q is not supported for %s", ErrInvalidQuery, op.text, cmp.field)
+	}
+
+	if p.done() {
+		return nil, fmt.Errorf("%w: missing value for %s", ErrInvalidQuery, cmp.field)
+	}
+	cmp.value = p.next().text
+	if cmp.kind == fieldTime {
+		when, err := parseQueryTime(cmp.value)
+		if err != nil {
+			return nil, err
+		}
+		cmp.when = when
+	}
+	return cmp, nil
+}
+
+func validOperator(kind fieldKind, op string) bool {
+	switch kind {
+	case fieldList:
+		return op == "CONTAINS"
+	case fieldTime:
+		switch op {
+		case "=", "!=", "<", "<=", ">", ">=":
+			return true
+		}
+	default:
+		switch op {
+		case "=", "!=", "CONTAINS":
+			return true
+		}
+	}
+	return false
+}
+
+func parseQueryTime(s string) (queryTime, error) {
+	lower := strings.ToLower(s)
+	if strings.HasPrefix(lower, "now") {
+		rest := lower[len("now"):]
+		if rest == "" {
+			return queryTime{relative: true}, nil
+		}
+		d, err := time.ParseDuration(rest)
+		if err != nil {
+			return queryTime{}, fmt.Errorf("%w: bad relative time %q", ErrInvalidQuery, s)
+		}
+		return queryTime{relative: true, offset: d}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return queryTime{}, fmt.Errorf("%w: bad time %q", ErrInvalidQuery, s)
+	}
+	return queryTime{abs: t}, nil
+}
+
+type sqlCompiler struct {
+	columns SQLColumns
+	next    int
+	args    []interface{}
+	now     time.Time
+}
+
+func (c *sqlCompiler) arg(v interface{}) string {
+	c.args = append(c.args, v)
+	c.next++
+	return fmt.Sprintf("$%d", c.next-1)
+}
+
+func (c *sqlCompiler) compile(n queryNode) (string, error) {
+	switch node := n.(type) {
+	case *queryAnd:
+		return c.join(node.children, " AND ")
+	case *queryOr:
+		return c.join(node.children, " OR ")
+	case *queryNot:
+		inner, err := c.compile(node.child)
+		if err != nil {
+			return "", err
+		}
+		return "NOT (" + inner + ")", nil
+	case *queryCmp:
+		return c.comparison(node)
+	}
+	return "", fmt.Errorf("%w: unsupported expression", ErrInvalidQuery)
+}
+
+func (c *sqlCompiler) join(children []queryNode, sep string) (string, error) {
+	parts := make([]string, len(children))
+	for i, child := range children {
+		part, err := c.compile(child)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = part
+	}
+	return "(" + strings.Join(parts, sep) + ")", nil
+}
+
+func (c *sqlCompiler) column(field string) string {
+	switch field {
+	case "id":
+		return c.columns.ID
+	case "subject":
+		return c.columns.Subject
+	case "issuer":
+		return c.columns.Issuer
+	case "type":
+		return c.columns.Type
+	case "algorithm":
+		return c.columns.Algorithm
+	case "scope":
+		return c.columns.Scopes
+	case "audience":
+		return c.columns.Audience
+	case "issued_at":
+		return c.columns.IssuedAt
+	case "expires_at":
+		return c.columns.ExpiresAt
+	case "not_before":
+		return c.columns.NotBefore
+	}
+	return ""
+}
+
+func (c *sqlCompiler) comparison(n *queryCmp) (string, error) {
+	switch n.kind {
+	case fieldList:
+		col := c.column(n.field)
+		if n.op == "EXISTS" {
+			return fmt.Sprintf("cardinality(%s) > 0", col), nil
+		}
+		return fmt.Sprintf("%s = ANY(%s)", c.arg(n.value), col), nil
+	case fieldTime:
+		return fmt.Sprintf("%s %s %s", c.column(n.field), sqlOperator(n.op), c.arg(n.when.at(c.now))), nil
+	case fieldString:
+		return stringSQL(c.column(n.field), n.op, c.arg(sqlValue(n.op, n.value))), nil
+	case fieldMetadata:
+		key := c.arg(n.metaKey)
+		value := fmt.Sprintf("COALESCE(%[1]s->'app_data'->>%[2]s, %[1]s->'labels'->>%[2]s)", c.columns.Metadata, key)
+		if n.op == "EXISTS" {
+			return value + " IS NOT NULL", nil
+		}
+		if n.op == "!=" {
+			return fmt.Sprintf("%s IS DISTINCT FROM %s", value, c.arg(n.value)), nil
+		}
+		return stringSQL(value, n.op, c.arg(sqlValue(n.op, n.value))), nil
+	}
+	return "", fmt.Errorf("%w: unsupported field %s", ErrInvalidQuery, n.field)
+}
+
+func sqlOperator(op string) string {
+	if op == "!=" {
+		return "<>"
+	}
+	return op
+}
+
+func stringSQL(column, op, placeholder string) string {
+	if op == "CONTAINS" {
+		return fmt.Sprintf("%s LIKE %s ESCAPE '\\'", column, placeholder)
+	}
+	return fmt.Sprintf("%s %s %s", column, sqlOperator(op), placeholder)
+}
+
+// sqlValue wraps substring searches in LIKE wildcards, escaping the value
+func sqlValue(op, v string) string {
+	if op != "CONTAINS" {
+		return v
+	}
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(v) + "%"
+}
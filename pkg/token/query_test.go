@@ -0,0 +1,126 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func queryFixtures() []*Token {
+	now := time.Now()
+	return []*Token{
+		{ID: "t1", Type: Access, Subject: "alice", Issuer: "gauth", Scopes: []string{"read", "write"},
+			IssuedAt: now, NotBefore: now, ExpiresAt: now.Add(30 * time.Minute),
+			Metadata: &Metadata{AppData: map[string]string{"tenant": "acme"}}},
+		{ID: "t2", Type: Refresh, Subject: "alice", Issuer: "gauth", Scopes: []string{"read"},
+			IssuedAt: now, NotBefore: now, ExpiresAt: now.Add(48 * time.Hour)},
+		{ID: "t3", Type: Access, Subject: "bob", Issuer: "legacy", Scopes: []string{"admin"},
+			IssuedAt: now, NotBefore: now, ExpiresAt: now.Add(2 * time.Hour),
+			Metadata: &Metadata{Labels: map[string]string{"tenant": "globex"}}},
+	}
+}
+
+func TestQueryExpr(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`subject = "alice"`, "t1,t2"},
+		{`scope CONTAINS write`, "t1"},
+		{`type = access_token AND expires_at < now+1h`, "t1"},
+		{`metadata.tenant EXISTS`, "t1,t3"},
+		{`metadata.tenant = globex OR issuer = "gauth" AND type = refresh_token`, "t2,t3"},
+		{`NOT (issuer = legacy) AND (scope CONTAINS read)`, "t1,t2"},
+		{`expires_at >= 2000-01-01T00:00:00Z AND subject CONTAINS "ob"`, "t3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery() error: %v", err)
+			}
+			var ids []string
+			for _, tok := range queryFixtures() {
+				if q.Match(tok) {
+					ids = append(ids, tok.ID)
+				}
+			}
+			if got := strings.Join(ids, ","); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, bad := range []string{`colour = red`, `scope = read`, `subject =`, `(subject = a`, `expires_at < tomorrow`, `subject = "open`} {
+			if _, err := ParseQuery(bad); !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("Expected ErrInvalidQuery for %q, got %v", bad, err)
+			}
+		}
+	})
+
+	t.Run("SQL", func(t *testing.T) {
+		q := MustParseQuery(`subject = "alice" AND (scope CONTAINS read OR metadata.tenant = acme)`)
+		where, args, err := q.SQL(DefaultSQLColumns, 3)
+		if err != nil {
+			t.Fatalf("SQL() error: %v", err)
+		}
+		want := "(subject = $3 AND ($4 = ANY(scopes) OR COALESCE(metadata->'app_data'->>$5, metadata->'labels'->>$5) = $6))"
+		if where != want {
+			t.Errorf("Expected %s, got %s", want, where)
+		}
+		if len(args) != 4 || args[0] != "alice" || args[3] != "acme" {
+			t.Errorf("Unexpected args %v", args)
+		}
+	})
+
+	t.Run("Subject Hints", func(t *testing.T) {
+		if hints := MustParseQuery(`subject = a AND scope CONTAINS x`).SubjectHints(); len(hints) != 1 || hints[0] != "a" {
+			t.Errorf("Expected [a], got %v", hints)
+		}
+		if hints := MustParseQuery(`subject = a OR issuer = x`).SubjectHints(); hints != nil {
+			t.Errorf("Expected no hints, got %v", hints)
+		}
+	})
+}
+
+func TestQueryStores(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis.Run() error: %v", err)
+	}
+	defer mr.Close()
+	redisStore, err := NewRedisStore(RedisConfig{Addresses: []string{mr.Addr()}, KeyPrefix: "q:", DefaultTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("NewRedisStore() error: %v", err)
+	}
+	defer redisStore.Close()
+	memStore := NewMemoryStore(time.Hour)
+
+	for _, tok := range queryFixtures() {
+		if err := redisStore.Save(ctx, tok); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+		if err := memStore.Save(ctx, tok.ID, tok); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+	}
+
+	filter := Filter{Query: MustParseQuery(`subject = alice AND scope CONTAINS write`)}
+	fromRedis, err := redisStore.List(ctx, filter)
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	fromMemory, err := memStore.List(ctx, filter)
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	if len(fromRedis) != 1 || fromRedis[0].ID != "t1" || len(fromMemory) != 1 || fromMemory[0].ID != "t1" {
+		t.Errorf("Expected t1 from both stores, got %d from redis and %d from memory", len(fromRedis), len(fromMemory))
+	}
+}
@@ -11,7 +11,6 @@ package token
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"time"
 
@@ -81,7 +80,7 @@ func NewRedisStore(cfg RedisConfig) (*RedisStore, error) {
 
 // Save implements the Store interface
 func (s *RedisStore) Save(ctx context.Context, token *Token) error {
-	data, err := json.Marshal(token)
+	data, err := MarshalStored(token)
 	if err != nil {
 		return fmt.Errorf("%w: failed to marshal token: %v", ErrStorageFailure, err)
 	}
@@ -100,6 +99,13 @@ func (s *RedisStore) Save(ctx context.Context, token *Token) error {
 		}
 	}
 
+	// Index by subject for queries that pin the subject
+	if token.Subject != "" {
+		if err := s.client.SAdd(ctx, s.subjectKey(token.Subject), token.ID).Err(); err != nil {
+			return fmt.Errorf("%w: failed to index token: %v", ErrStorageFailure, err)
+		}
+	}
+
 	return nil
 }
 
@@ -113,12 +119,20 @@ func (s *RedisStore) Get(ctx context.Context, id string) (*Token, error) {
 		return nil, fmt.Errorf("%w: failed to get token: %v", ErrStorageFailure, err)
 	}
 
-	var token Token
-	if err := json.Unmarshal(data, &token); err != nil {
+	token, migrated, err := UnmarshalStored(data)
+	if err != nil {
 		return nil, fmt.Errorf("%w: failed to unmarshal token: %v", ErrStorageFailure, err)
 	}
 
-	return &token, nil
+	// Upgrade legacy records on read; a failed write-back is retried on the
+	// next read or by a bulk migration
+	if migrated {
+		if out, err := MarshalStored(token); err == nil {
+			_ = s.PutRaw(ctx, s.key(id), out)
+		}
+	}
+
+	return token, nil
 }
 
 // GetByValue implements the Store interface
@@ -155,6 +169,12 @@ func (s *RedisStore) Delete(ctx context.Context, id string) error {
 		}
 	}
 
+	if token != nil && token.Subject != "" {
+		if err := s.client.SRem(ctx, s.subjectKey(token.Subject), id).Err(); err != nil {
+			return fmt.Errorf("%w: failed to delete token index: %v", ErrStorageFailure, err)
+		}
+	}
+
 	return nil
 }
 
@@ -174,6 +194,15 @@ func (s *RedisStore) DeleteByValue(ctx context.Context, value string) error {
 
 // List implements the Store interface
 func (s *RedisStore) List(ctx context.Context, filter Filter) ([]*Token, error) {
+	// Filters and queries that pin the subject read the subject index.
+	// Tokens saved before the index existed are only found by a full scan.
+	if filter.Subject != "" {
+		return s.listBySubjects(ctx, []string{filter.Subject}, filter)
+	}
+	if subjects := filter.Query.SubjectHints(); subjects != nil {
+		return s.listBySubjects(ctx, subjects, filter)
+	}
+
 	// Scan for all token keys
 	pattern := s.key("*")
 	var tokens []*Token
@@ -204,13 +233,13 @@ func (s *RedisStore) List(ctx context.Context, filter Filter) ([]*Token, error)
 				continue // Skip failed tokens
 			}
 
-			var token Token
-			if err := json.Unmarshal(data, &token); err != nil {
+			token, _, err := UnmarshalStored(data)
+			if err != nil {
 				continue // Skip invalid tokens
 			}
 
-			if s.matchesFilter(&token, filter) {
-				tokens = append(tokens, &token)
+			if matchesFilter(token, filter) {
+				tokens = append(tokens, token)
 			}
 		}
 
@@ -219,11 +248,40 @@ func (s *RedisStore) List(ctx context.Context, filter Filter) ([]*Token, error)
 		}
 	}
 
-	return tokens, nil
+	return applyPage(tokens, filter)
+}
+
+// listBySubjects reads tokens through the subject index instead of scanning
+// the keyspace. Index entries whose token has expired are pruned.
+func (s *RedisStore) listBySubjects(ctx context.Context, subjects []string, filter Filter) ([]*Token, error) {
+	var tokens []*Token
+	for _, subject := range subjects {
+		ids, err := s.client.SMembers(ctx, s.subjectKey(subject)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to read token index: %v", ErrStorageFailure, err)
+		}
+		for _, id := range ids {
+			token, err := s.Get(ctx, id)
+			if err == ErrTokenNotFound {
+				s.client.SRem(ctx, s.subjectKey(subject), id)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if matchesFilter(token, filter) {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return applyPage(tokens, filter)
 }
 
 // Revoke implements the Store interface
-func (s *RedisStore) Revoke(ctx context.Context, id string, reason string) error {
+func (s *RedisStore) Revoke(ctx context.Context, id string, reason RevocationReason) error {
+	if err := checkReason(reason); err != nil {
+		return err
+	}
 	token, err := s.Get(ctx, id)
 	if err != nil {
 		return err
@@ -237,115 +295,184 @@ func (s *RedisStore) Revoke(ctx context.Context, id string, reason string) error
 	return s.Save(ctx, token)
 }
 
-// Close releases resources used by the store
-func (s *RedisStore) Close() error {
-	return s.client.Close()
-}
-
-// Helper methods
-
-func (s *RedisStore) key(id string) string {
-	return fmt.Sprintf("%stoken:%s", s.keyPrefix, id)
-}
-
-func (s *RedisStore) valueKey(value string) string {
-	return fmt.Sprintf("%svalue:%s", s.keyPrefix, value)
-}
-
-func (s *RedisStore) ttl(token *Token) time.Duration {
-	if token.ExpiresAt.IsZero() {
-		return s.defaultTTL
+// SaveBatch saves tokens and their indexes in one transactional pipeline
+func (s *RedisStore) SaveBatch(ctx context.Context, tokens []*Token) error {
+	encoded := make([][]byte, len(tokens))
+	for i, token := range tokens {
+		data, err := MarshalStored(token)
+		if err != nil {
+			return fmt.Errorf("%w: failed to marshal token %s: %v", ErrStorageFailure, token.ID, err)
+		}
+		encoded[i] = data
 	}
-	return time.Until(token.ExpiresAt)
-}
 
-func (s *RedisStore) matchesFilter(token *Token, filter Filter) bool {
-	return s.matchesTimeFilter(token, filter) &&
-		s.matchesIdentityFilter(token, filter) &&
-		s.matchesTypeFilter(token, filter) &&
-		s.matchesScopeFilter(token, filter) &&
-		s.matchesActiveFilter(token, filter)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, token := range tokens {
+			ttl := s.ttl(token)
+			pipe.Set(ctx, s.key(token.ID), encoded[i], ttl)
+			if token.Value != "" {
+				pipe.Set(ctx, s.valueKey(token.Value), token.ID, ttl)
+			}
+			if token.Subject != "" {
+				pipe.SAdd(ctx, s.subjectKey(token.Subject), token.ID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: failed to save tokens: %v", ErrStorageFailure, err)
+	}
+	return nil
 }
 
-func (s *RedisStore) matchesTimeFilter(token *Token, filter Filter) bool {
-	if !filter.ExpiresAfter.IsZero() && token.ExpiresAt.Before(filter.ExpiresAfter) {
-		return false
+// getBatch reads tokens by ID in one round trip; missing IDs are omitted
+func (s *RedisStore) getBatch(ctx context.Context, ids []string) (map[string]*Token, error) {
+	tokens := make(map[string]*Token, len(ids))
+	if len(ids) == 0 {
+		return tokens, nil
 	}
-	if !filter.ExpiresBefore.IsZero() && token.ExpiresAt.After(filter.ExpiresBefore) {
-		return false
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.key(id)
 	}
-	if !filter.IssuedAfter.IsZero() && token.IssuedAt.Before(filter.IssuedAfter) {
-		return false
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to get tokens: %v", ErrStorageFailure, err)
 	}
-	if !filter.IssuedBefore.IsZero() && token.IssuedAt.After(filter.IssuedBefore) {
-		return false
+	for i, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+		token, _, err := UnmarshalStored([]byte(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to unmarshal token %s: %v", ErrStorageFailure, ids[i], err)
+		}
+		tokens[ids[i]] = token
 	}
-	return true
+	return tokens, nil
 }
 
-func (s *RedisStore) matchesIdentityFilter(token *Token, filter Filter) bool {
-	if filter.Subject != "" && token.Subject != filter.Subject {
-		return false
+// DeleteBatch removes tokens and their indexes in two round trips. Unknown
+// IDs are reported in a BatchError.
+func (s *RedisStore) DeleteBatch(ctx context.Context, ids []string) error {
+	tokens, err := s.getBatch(ctx, ids)
+	if err != nil {
+		return err
 	}
-	if filter.Issuer != "" && token.Issuer != filter.Issuer {
-		return false
+
+	failed := batchErrors{}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range ids {
+			token, ok := tokens[id]
+			if !ok {
+				failed[id] = ErrTokenNotFound
+				continue
+			}
+			pipe.Del(ctx, s.key(id))
+			if token.Value != "" {
+				pipe.Del(ctx, s.valueKey(token.Value))
+			}
+			if token.Subject != "" {
+				pipe.SRem(ctx, s.subjectKey(token.Subject), id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: failed to delete tokens: %v", ErrStorageFailure, err)
 	}
-	return true
+	return failed.err()
 }
 
-func (s *RedisStore) matchesTypeFilter(token *Token, filter Filter) bool {
-	if len(filter.Types) == 0 {
-		return true
+// RevokeBatch marks tokens revoked in two round trips. Unknown IDs are
+// reported in a BatchError.
+func (s *RedisStore) RevokeBatch(ctx context.Context, ids []string, reason RevocationReason) error {
+	if err := checkReason(reason); err != nil {
+		return err
 	}
-	for _, t := range filter.Types {
-		if token.Type == t {
-			return true
-		}
+	tokens, err := s.getBatch(ctx, ids)
+	if err != nil {
+		return err
 	}
-	return false
-}
 
-func (s *RedisStore) matchesScopeFilter(token *Token, filter Filter) bool {
-	if len(filter.Scopes) == 0 {
-		return true
+	failed := batchErrors{}
+	revoked := make([]*Token, 0, len(tokens))
+	now := time.Now()
+	for _, id := range ids {
+		token, ok := tokens[id]
+		if !ok {
+			failed[id] = ErrTokenNotFound
+			continue
+		}
+		token.RevocationStatus = &RevocationStatus{RevokedAt: now, Reason: reason}
+		revoked = append(revoked, token)
 	}
-	if filter.RequireAllScopes {
-		return s.hasAllScopes(token.Scopes, filter.Scopes)
+	if len(revoked) > 0 {
+		if err := s.SaveBatch(ctx, revoked); err != nil {
+			return err
+		}
 	}
-	return s.hasAnyScope(token.Scopes, filter.Scopes)
+	return failed.err()
 }
 
-func (s *RedisStore) hasAllScopes(tokenScopes, requiredScopes []string) bool {
-	for _, required := range requiredScopes {
-		found := false
-		for _, scope := range tokenScopes {
-			if scope == required {
-				found = true
-				break
+// ForEachRaw implements RawTokenStore
+func (s *RedisStore) ForEachRaw(ctx context.Context, fn func(key string, data []byte) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := s.client.Scan(ctx, cursor, s.key("*"), 100).Result()
+		if err != nil {
+			return fmt.Errorf("%w: failed to scan tokens: %v", ErrStorageFailure, err)
+		}
+		for _, key := range keys {
+			data, err := s.client.Get(ctx, key).Bytes()
+			if err == redis.Nil {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("%w: failed to get token: %v", ErrStorageFailure, err)
+			}
+			if err := fn(key, data); err != nil {
+				return err
 			}
 		}
-		if !found {
-			return false
+		if cursor = next; cursor == 0 {
+			return nil
 		}
 	}
-	return true
 }
 
-func (s *RedisStore) hasAnyScope(tokenScopes, requiredScopes []string) bool {
-	for _, required := range requiredScopes {
-		for _, scope := range tokenScopes {
-			if scope == required {
-				return true
-			}
-		}
+// PutRaw implements RawTokenStore
+func (s *RedisStore) PutRaw(ctx context.Context, key string, data []byte) error {
+	if err := s.client.Set(ctx, key, data, redis.KeepTTL).Err(); err != nil {
+		return fmt.Errorf("%w: failed to write token: %v", ErrStorageFailure, err)
 	}
-	return false
+	return nil
+}
+
+// Close releases resources used by the store
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+// Helper methods
+
+func (s *RedisStore) key(id string) string {
+	return fmt.Sprintf("%stoken:%s", s.keyPrefix, id)
+}
+
+func (s *RedisStore) subjectKey(subject string) string {
+	return fmt.Sprintf("%ssubject:%s", s.keyPrefix, subject)
+}
+
+func (s *RedisStore) valueKey(value string) string {
+	return fmt.Sprintf("%svalue:%s", s.keyPrefix, value)
 }
 
-func (s *RedisStore) matchesActiveFilter(token *Token, filter Filter) bool {
-	if !filter.Active {
-		return true
+func (s *RedisStore) ttl(token *Token) time.Duration {
+	if token.ExpiresAt.IsZero() {
+		return s.defaultTTL
 	}
-	now := time.Now()
-	return now.Before(token.ExpiresAt) && now.After(token.NotBefore)
+	return time.Until(token.ExpiresAt)
 }
+
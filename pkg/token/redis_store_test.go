@@ -11,7 +11,7 @@ import (
 )
 
 const (
-	testRevocationReason = "test revocation"
+	testRevocationReason = ReasonCompromise
 )
 
 //
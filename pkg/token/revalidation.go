@@ -0,0 +1,219 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// RevalidationCheck decides whether a stored token still satisfies current
+// policy. It returns a non-empty reason when the token must be revoked, and
+// an error when the decision could not be made; such tokens are kept.
+type RevalidationCheck interface {
+	Check(ctx context.Context, token *Token) (reason string, err error)
+}
+
+// RevalidationCheckFunc adapts a function to the RevalidationCheck interface
+type RevalidationCheckFunc func(ctx context.Context, token *Token) (string, error)
+
+// Check implements RevalidationCheck
+func (f RevalidationCheckFunc) Check(ctx context.Context, token *Token) (string, error) {
+	return f(ctx, token)
+}
+
+// SubjectStatusCheck revokes tokens whose subject is no longer active, e.g.
+// an agent suspended in the registry. active reports the subject's status.
+func SubjectStatusCheck(active func(ctx context.Context, subject string) (bool, error)) RevalidationCheck {
+	return RevalidationCheckFunc(func(ctx context.Context, t *Token) (string, error) {
+		ok, err := active(ctx, t.Subject)
+		if err != nil {
+			return "", fmt.Errorf("failed to look up subject %s: %w", t.Subject, err)
+		}
+		if !ok {
+			return fmt.Sprintf("subject %s is no longer active", t.Subject), nil
+		}
+		return "", nil
+	})
+}
+
+// AllowedScopesCheck revokes tokens holding a scope the subject is no longer
+// granted. allowed returns the subject's current scopes.
+func AllowedScopesCheck(allowed func(ctx context.Context, subject string) ([]string, error)) RevalidationCheck {
+	return RevalidationCheckFunc(func(ctx context.Context, t *Token) (string, error) {
+		granted, err := allowed(ctx, t.Subject)
+		if err != nil {
+			return "", fmt.Errorf("failed to look up scopes of %s: %w", t.Subject, err)
+		}
+		for _, scope := range t.Scopes {
+			if !containsString(granted, scope) {
+				return fmt.Sprintf("scope %q was removed", scope), nil
+			}
+		}
+		return "", nil
+	})
+}
+
+// RevalidatorConfig configures a Revalidator
+type RevalidatorConfig struct {
+	// Store holds the tokens to revalidate
+	Store Store
+
+	// Checks are run in order; the first reason found revokes the token
+	Checks []RevalidationCheck
+
+	// MinLifetime selects long-lived tokens: only tokens issued for at least
+	// this long are revalidated (default: 1h)
+	MinLifetime time.Duration
+
+	// Interval between passes (default: 15m)
+	Interval time.Duration
+
+	// RatePerSecond caps how many tokens are checked per second so a pass
+	// does not overload the store or the policy sources (default: 50)
+	RatePerSecond int
+
+	// MaxRevocationsPerPass stops a pass after this many revocations, a
+	// brake against a faulty policy revoking everything (0 = no limit)
+	MaxRevocationsPerPass int
+
+	// Cascade revokes tokens derived from a revoked token as well
+	Cascade bool
+
+	// Events receives a token_revoked event per revoked token
+	Events events.EventHandler
+}
+
+// RevalidationReport summarizes one revalidation pass
+type RevalidationReport struct {
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	Checked   int           `json:"checked"`
+	Revoked   int           `json:"revoked"`
+	Errors    int           `json:"errors"`
+	// Halted is set when MaxRevocationsPerPass stopped the pass early
+	Halted bool `json:"halted,omitempty"`
+}
+
+// Revalidator periodically re-checks long-lived tokens against current
+// policy and registry state and revokes those that no longer pass
+type Revalidator struct {
+	config RevalidatorConfig
+}
+
+// NewRevalidator creates a revalidator
+func NewRevalidator(config RevalidatorConfig) (*Revalidator, error) {
+	if config.Store == nil {
+		return nil, fmt.Errorf("%w: store is required", ErrInvalidConfig)
+	}
+	if len(config.Checks) == 0 {
+		return nil, fmt.Errorf("%w: at least one check is required", ErrInvalidConfig)
+	}
+	if config.MinLifetime <= 0 {
+		config.MinLifetime = time.Hour
+	}
+	if config.Interval <= 0 {
+		config.Interval = 15 * time.Minute
+	}
+	if config.RatePerSecond <= 0 {
+		config.RatePerSecond = 50
+	}
+	return &Revalidator{config: config}, nil
+}
+
+// Revalidate runs one pass over the active long-lived tokens, oldest first
+func (r *Revalidator) Revalidate(ctx context.Context) (*RevalidationReport, error) {
+	report := &RevalidationReport{StartedAt: time.Now()}
+	defer func() { report.Duration = time.Since(report.StartedAt) }()
+
+	tokens, err := r.config.Store.List(ctx, Filter{Active: true})
+	if err != nil {
+		return report, fmt.Errorf("failed to list tokens: %w", err)
+	}
+	candidates := tokens[:0]
+	for _, t := range tokens {
+		if t.RevocationStatus == nil && t.ExpiresAt.Sub(t.IssuedAt) >= r.config.MinLifetime {
+			candidates = append(candidates, t)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].IssuedAt.Before(candidates[j].IssuedAt) })
+
+	pace := time.NewTicker(time.Second / time.Duration(r.config.RatePerSecond))
+	defer pace.Stop()
+
+	for i, t := range candidates {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return report, ctx.Err()
+			case <-pace.C:
+			}
+		}
+		report.Checked++
+
+		reason, err := r.check(ctx, t)
+		if err != nil {
+			report.Errors++
+			continue
+		}
+		if reason == "" {
+			continue
+		}
+		if err := r.revoke(ctx, t, reason); err != nil {
+			report.Errors++
+			continue
+		}
+		report.Revoked++
+		if r.config.MaxRevocationsPerPass > 0 && report.Revoked >= r.config.MaxRevocationsPerPass {
+			report.Halted = true
+			break
+		}
+	}
+	return report, nil
+}
+
+func (r *Revalidator) check(ctx context.Context, t *Token) (string, error) {
+	for _, c := range r.config.Checks {
+		reason, err := c.Check(ctx, t)
+		if err != nil || reason != "" {
+			return reason, err
+		}
+	}
+	return "", nil
+}
+
+func (r *Revalidator) revoke(ctx context.Context, t *Token, reason string) error {
+	opts := RevokeChainOptions{Reason: ReasonPolicyChange, Detail: reason, RevokedBy: "revalidation", Events: r.config.Events}
+	if r.config.Cascade {
+		_, err := RevokeChain(ctx, r.config.Store, t.ID, opts)
+		return err
+	}
+	t.RevocationStatus = &RevocationStatus{RevokedAt: time.Now(), Reason: opts.Reason, Detail: reason, RevokedBy: opts.RevokedBy}
+	err := r.config.Store.Revoke(ctx, t)
+	emitChainRevocation(ctx, opts, t, t.ID, err)
+	return err
+}
+
+// Run revalidates every Interval until ctx is cancelled. Reports and errors
+// go to the callbacks when they are non-nil.
+func (r *Revalidator) Run(ctx context.Context, onReport func(*RevalidationReport), onError func(error)) {
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := r.Revalidate(ctx)
+			if err != nil && onError != nil {
+				onError(err)
+			}
+			if onReport != nil {
+				onReport(report)
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRevalidator(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	seed := func(t *testing.T) *MemoryStore {
+		t.Helper()
+		store := NewMemoryStore(time.Hour)
+		for _, tok := range []*Token{
+			{ID: "ok", Value: "v", Subject: "agent-1", Scopes: []string{"read"}, IssuedAt: now.Add(-time.Minute), NotBefore: now.Add(-time.Minute), ExpiresAt: now.Add(24 * time.Hour)},
+			{ID: "suspended", Value: "v", Subject: "agent-2", Scopes: []string{"read"}, IssuedAt: now.Add(-time.Minute), NotBefore: now.Add(-time.Minute), ExpiresAt: now.Add(24 * time.Hour)},
+			{ID: "scope-removed", Value: "v", Subject: "agent-1", Scopes: []string{"read", "write"}, IssuedAt: now.Add(-time.Minute), NotBefore: now.Add(-time.Minute), ExpiresAt: now.Add(24 * time.Hour)},
+			{ID: "short-lived", Value: "v", Subject: "agent-2", Scopes: []string{"read"}, IssuedAt: now.Add(-time.Minute), NotBefore: now.Add(-time.Minute), ExpiresAt: now.Add(5 * time.Minute)},
+		} {
+			if err := store.Save(ctx, tok.ID, tok); err != nil {
+				t.Fatalf("Save() error: %v", err)
+			}
+		}
+		return store
+	}
+	checks := []RevalidationCheck{
+		SubjectStatusCheck(func(_ context.Context, subject string) (bool, error) {
+			return subject != "agent-2", nil
+		}),
+		AllowedScopesCheck(func(_ context.Context, _ string) ([]string, error) {
+			return []string{"read"}, nil
+		}),
+	}
+
+	t.Run("Revokes Failing Tokens", func(t *testing.T) {
+		store := seed(t)
+		handler := &captureHandler{}
+		r, err := NewRevalidator(RevalidatorConfig{Store: store, Checks: checks, RatePerSecond: 1000, Events: handler})
+		if err != nil {
+			t.Fatalf("NewRevalidator() error: %v", err)
+		}
+		report, err := r.Revalidate(ctx)
+		if err != nil {
+			t.Fatalf("Revalidate() error: %v", err)
+		}
+		if report.Checked != 3 || report.Revoked != 2 {
+			t.Errorf("Expected 3 checked and 2 revoked, got %+v", report)
+		}
+		for id, kept := range map[string]bool{"ok": true, "short-lived": true, "suspended": false, "scope-removed": false} {
+			_, err := store.Get(ctx, id)
+			if kept != (err == nil) {
+				t.Errorf("%s: expected kept=%v, got %v", id, kept, err)
+			}
+		}
+		if len(handler.events) != 2 {
+			t.Errorf("Expected 2 revocation events, got %d", len(handler.events))
+		}
+	})
+
+	t.Run("Check Errors Keep Tokens", func(t *testing.T) {
+		store := seed(t)
+		failing := SubjectStatusCheck(func(context.Context, string) (bool, error) {
+			return false, errors.New("registry unavailable")
+		})
+		r, _ := NewRevalidator(RevalidatorConfig{Store: store, Checks: []RevalidationCheck{failing}, RatePerSecond: 1000})
+		report, _ := r.Revalidate(ctx)
+		if report.Errors != 3 || report.Revoked != 0 {
+			t.Errorf("Expected 3 errors and no revocations, got %+v", report)
+		}
+	})
+
+	t.Run("Revocation Brake", func(t *testing.T) {
+		store := seed(t)
+		r, _ := NewRevalidator(RevalidatorConfig{Store: store, Checks: checks, RatePerSecond: 1000, MaxRevocationsPerPass: 1})
+		report, _ := r.Revalidate(ctx)
+		if report.Revoked != 1 || !report.Halted {
+			t.Errorf("Expected pass halted after one revocation, got %+v", report)
+		}
+	})
+
+	t.Run("Rate Limited", func(t *testing.T) {
+		store := seed(t)
+		r, _ := NewRevalidator(RevalidatorConfig{Store: store, Checks: checks, RatePerSecond: 20})
+		report, _ := r.Revalidate(ctx)
+		if report.Duration < 90*time.Millisecond {
+			t.Errorf("Expected 3 checks at 20/s to take ~100ms, took %v", report.Duration)
+		}
+	})
+}
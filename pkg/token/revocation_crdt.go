@@ -0,0 +1,379 @@
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RevocationEntry is a single revocation recorded in a RevocationSet
+type RevocationEntry struct {
+	TokenID   string           `json:"token_id"`
+	Reason    RevocationReason `json:"reason,omitempty"`
+	RevokedAt time.Time        `json:"revoked_at"`
+
+	// ExpiresAt is the revoked token's expiry; the entry can be pruned after it
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+
+	// Origin is the node that recorded the revocation
+	Origin string `json:"origin"`
+
+	// Seq is the origin node's sequence number for this entry
+	Seq uint64 `json:"seq"`
+}
+
+// RevocationSet is a conflict-free replicated set of revoked tokens for
+// deployments that may be partitioned (e.g. edge sites). Revocations only
+// ever grow the set, so replicas can revoke independently while offline and
+// converge to the same state however and in whatever order they merge.
+//
+// When two nodes revoke the same token, the earliest revocation wins, with
+// ties broken by origin, so every replica keeps the same entry.
+type RevocationSet struct {
+	nodeID  string
+	log     RevocationLog
+	mu      sync.RWMutex
+	entries map[string]RevocationEntry
+	clock   map[string]uint64
+}
+
+// RevocationLog persists a replica's revocation set, so a restarted node
+// keeps rejecting revoked tokens and continues its own sequence instead of
+// reusing numbers its peers have already seen. Each node needs its own log.
+type RevocationLog interface {
+	// SaveRevocations stores entries, replacing those for the same tokens,
+	// and the origins whose sequence advanced
+	SaveRevocations(ctx context.Context, entries []RevocationEntry, clock map[string]uint64) error
+
+	// LoadRevocations returns the stored entries and version vector
+	LoadRevocations(ctx context.Context) ([]RevocationEntry, map[string]uint64, error)
+
+	// DeleteRevocations removes pruned entries; the version vector is kept
+	DeleteRevocations(ctx context.Context, tokenIDs []string) error
+}
+
+// NewRevocationSet creates an empty in-memory revocation set owned by nodeID
+func NewRevocationSet(nodeID string) *RevocationSet {
+	return &RevocationSet{
+		nodeID:  nodeID,
+		entries: make(map[string]RevocationEntry),
+		clock:   make(map[string]uint64),
+	}
+}
+
+// LoadRevocationSet creates a revocation set owned by nodeID that restores
+// its state from log and saves every change there before applying it
+func LoadRevocationSet(ctx context.Context, nodeID string, log RevocationLog) (*RevocationSet, error) {
+	entries, clock, err := log.LoadRevocations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load revocations: %w", err)
+	}
+	s := NewRevocationSet(nodeID)
+	for _, e := range entries {
+		s.apply(e)
+		if e.Seq > s.clock[e.Origin] {
+			s.clock[e.Origin] = e.Seq
+		}
+	}
+	for origin, seq := range clock {
+		if seq > s.clock[origin] {
+			s.clock[origin] = seq
+		}
+	}
+	s.log = log
+	return s, nil
+}
+
+// Revoke records a local revocation and returns the stored entry
+func (s *RevocationSet) Revoke(ctx context.Context, tokenID string, reason RevocationReason, expiresAt time.Time) (RevocationEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seq := s.clock[s.nodeID] + 1
+	entry := RevocationEntry{
+		TokenID:   tokenID,
+		Reason:    reason,
+		RevokedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+		Origin:    s.nodeID,
+		Seq:       seq,
+	}
+	if current, ok := s.entries[tokenID]; ok && !revocationPrecedes(entry, current) {
+		entry = current
+	}
+	if err := s.save(ctx, []RevocationEntry{entry}, map[string]uint64{s.nodeID: seq}); err != nil {
+		return RevocationEntry{}, err
+	}
+	s.clock[s.nodeID] = seq
+	s.entries[tokenID] = entry
+	return entry, nil
+}
+
+// save writes changes to the log, if any; the caller must hold the write
+// lock
+func (s *RevocationSet) save(ctx context.Context, entries []RevocationEntry, clock map[string]uint64) error {
+	if s.log == nil || (len(entries) == 0 && len(clock) == 0) {
+		return nil
+	}
+	if err := s.log.SaveRevocations(ctx, entries, clock); err != nil {
+		return fmt.Errorf("failed to save revocations: %w", err)
+	}
+	return nil
+}
+
+// IsRevoked reports whether a token has been revoked on any merged replica
+func (s *RevocationSet) IsRevoked(tokenID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.entries[tokenID]
+	return ok
+}
+
+// Get returns the revocation entry for a token
+func (s *RevocationSet) Get(tokenID string) (RevocationEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.entries[tokenID]
+	return entry, ok
+}
+
+// Validate implements Validator so the set can be used in a ValidationChain
+func (s *RevocationSet) Validate(_ context.Context, token *Token) error {
+	if s.IsRevoked(token.ID) {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// Merge applies entries received from another replica and returns the
+// number of tokens newly marked as revoked. Nothing is applied if the
+// changes cannot be saved.
+func (s *RevocationSet) Merge(ctx context.Context, entries []RevocationEntry) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	added := 0
+	changed := make(map[string]RevocationEntry)
+	clock := make(map[string]uint64)
+	for _, e := range entries {
+		current, ok := changed[e.TokenID]
+		if !ok {
+			current, ok = s.entries[e.TokenID]
+		}
+		if !ok {
+			added++
+		}
+		if !ok || revocationPrecedes(e, current) {
+			changed[e.TokenID] = e
+		}
+		if e.Seq > s.clock[e.Origin] && e.Seq > clock[e.Origin] {
+			clock[e.Origin] = e.Seq
+		}
+	}
+
+	updates := make([]RevocationEntry, 0, len(changed))
+	for _, e := range changed {
+		updates = append(updates, e)
+	}
+	if err := s.save(ctx, updates, clock); err != nil {
+		return 0, err
+	}
+	for id, e := range changed {
+		s.entries[id] = e
+	}
+	for origin, seq := range clock {
+		s.clock[origin] = seq
+	}
+	return added, nil
+}
+
+// MergeSet merges the full state of another replica
+func (s *RevocationSet) MergeSet(ctx context.Context, other *RevocationSet) (int, error) {
+	return s.Merge(ctx, other.Entries())
+}
+
+// apply adds or replaces an entry; the caller must hold the write lock
+func (s *RevocationSet) apply(e RevocationEntry) {
+	if current, ok := s.entries[e.TokenID]; !ok || revocationPrecedes(e, current) {
+		s.entries[e.TokenID] = e
+	}
+}
+
+// revocationPrecedes orders entries for the same token deterministically
+func revocationPrecedes(a, b RevocationEntry) bool {
+	if !a.RevokedAt.Equal(b.RevokedAt) {
+		return a.RevokedAt.Before(b.RevokedAt)
+	}
+	if a.Origin != b.Origin {
+		return a.Origin < b.Origin
+	}
+	return a.Seq < b.Seq
+}
+
+// VersionVector returns the highest sequence number seen from each origin
+func (s *RevocationSet) VersionVector() map[string]uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	vv := make(map[string]uint64, len(s.clock))
+	for k, v := range s.clock {
+		vv[k] = v
+	}
+	return vv
+}
+
+// Delta returns the entries a replica with the given version vector has not
+// seen, ordered by origin and sequence
+func (s *RevocationSet) Delta(since map[string]uint64) []RevocationEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var delta []RevocationEntry
+	for _, e := range s.entries {
+		if e.Seq > since[e.Origin] {
+			delta = append(delta, e)
+		}
+	}
+	sortRevocations(delta)
+	return delta
+}
+
+// Entries returns all entries ordered by origin and sequence
+func (s *RevocationSet) Entries() []RevocationEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := make([]RevocationEntry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sortRevocations(entries)
+	return entries
+}
+
+// Len returns the number of revoked tokens
+func (s *RevocationSet) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+// Prune removes entries for tokens that expired before the given time. Pruning
+// is safe because an expired token is rejected regardless of revocation, and
+// re-merging a pruned entry is harmless.
+func (s *RevocationSet) Prune(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []string
+	for id, e := range s.entries {
+		if !e.ExpiresAt.IsZero() && e.ExpiresAt.Before(before) {
+			expired = append(expired, id)
+		}
+	}
+	if s.log != nil && len(expired) > 0 {
+		if err := s.log.DeleteRevocations(ctx, expired); err != nil {
+			return 0, fmt.Errorf("failed to delete revocations: %w", err)
+		}
+	}
+	for _, id := range expired {
+		delete(s.entries, id)
+	}
+	return len(expired), nil
+}
+
+func sortRevocations(entries []RevocationEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Origin != entries[j].Origin {
+			return entries[i].Origin < entries[j].Origin
+		}
+		return entries[i].Seq < entries[j].Seq
+	})
+}
+
+// RedisRevocationLog implements RevocationLog with two Redis hashes: the
+// entries as JSON keyed by token ID, and the version vector
+type RedisRevocationLog struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+var _ RevocationLog = (*RedisRevocationLog)(nil)
+
+// NewRedisRevocationLog creates a Redis-backed revocation log (prefix default: "gauth:revocations:")
+func NewRedisRevocationLog(client redis.UniversalClient, prefix string) *RedisRevocationLog {
+	if prefix == "" {
+		prefix = "gauth:revocations:"
+	}
+	return &RedisRevocationLog{client: client, prefix: prefix}
+}
+
+// SaveRevocations implements RevocationLog; entries and clock are written
+// in one transaction
+func (l *RedisRevocationLog) SaveRevocations(ctx context.Context, entries []RevocationEntry, clock map[string]uint64) error {
+	values := make([]interface{}, 0, 2*len(entries))
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to encode revocation %s: %w", e.TokenID, err)
+		}
+		values = append(values, e.TokenID, data)
+	}
+	seqs := make([]interface{}, 0, 2*len(clock))
+	for origin, seq := range clock {
+		seqs = append(seqs, origin, seq)
+	}
+	_, err := l.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(values) > 0 {
+			pipe.HSet(ctx, l.prefix+"entries", values...)
+		}
+		if len(seqs) > 0 {
+			pipe.HSet(ctx, l.prefix+"clock", seqs...)
+		}
+		return nil
+	})
+	return err
+}
+
+// LoadRevocations implements RevocationLog
+func (l *RedisRevocationLog) LoadRevocations(ctx context.Context) ([]RevocationEntry, map[string]uint64, error) {
+	stored, err := l.client.HGetAll(ctx, l.prefix+"entries").Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	entries := make([]RevocationEntry, 0, len(stored))
+	for id, data := range stored {
+		var e RevocationEntry
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode revocation %s: %w", id, err)
+		}
+		entries = append(entries, e)
+	}
+
+	seqs, err := l.client.HGetAll(ctx, l.prefix+"clock").Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	clock := make(map[string]uint64, len(seqs))
+	for origin, v := range seqs {
+		seq, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid sequence for origin %s: %w", origin, err)
+		}
+		clock[origin] = seq
+	}
+	return entries, clock, nil
+}
+
+// DeleteRevocations implements RevocationLog
+func (l *RedisRevocationLog) DeleteRevocations(ctx context.Context, tokenIDs []string) error {
+	if len(tokenIDs) == 0 {
+		return nil
+	}
+	return l.client.HDel(ctx, l.prefix+"entries", tokenIDs...).Err()
+}
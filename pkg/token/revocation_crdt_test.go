@@ -0,0 +1,157 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestRevocationSet(t *testing.T) {
+	ctx := context.Background()
+	expiry := time.Now().Add(time.Hour)
+	revoke := func(t *testing.T, set *RevocationSet, tokenID string, reason RevocationReason, expiresAt time.Time) {
+		t.Helper()
+		if _, err := set.Revoke(ctx, tokenID, reason, expiresAt); err != nil {
+			t.Fatalf("Revoke() error: %v", err)
+		}
+	}
+
+	t.Run("Partitioned Replicas Converge", func(t *testing.T) {
+		edge := NewRevocationSet("edge")
+		core := NewRevocationSet("core")
+
+		// Both sides revoke while partitioned, including the same token
+		revoke(t, edge, "tok-1", "lost device", expiry)
+		revoke(t, edge, "tok-shared", "edge", expiry)
+		revoke(t, core, "tok-2", "compromised", expiry)
+		revoke(t, core, "tok-shared", "core", expiry)
+
+		// Merge in opposite orders using deltas
+		edgeDelta := edge.Delta(core.VersionVector())
+		coreDelta := core.Delta(edge.VersionVector())
+		if added, err := core.Merge(ctx, edgeDelta); err != nil || added != 1 {
+			t.Errorf("Expected 1 new revocation on core, got %d, %v", added, err)
+		}
+		if _, err := edge.Merge(ctx, coreDelta); err != nil {
+			t.Fatalf("Merge() error: %v", err)
+		}
+
+		for _, id := range []string{"tok-1", "tok-2", "tok-shared"} {
+			if !edge.IsRevoked(id) || !core.IsRevoked(id) {
+				t.Errorf("Expected %s revoked on both replicas", id)
+			}
+		}
+
+		a, _ := edge.Get("tok-shared")
+		b, _ := core.Get("tok-shared")
+		if a != b {
+			t.Errorf("Replicas disagree on winning entry: %+v vs %+v", a, b)
+		}
+
+		if len(edge.Delta(core.VersionVector())) != 0 {
+			t.Error("Expected no delta after convergence")
+		}
+	})
+
+	t.Run("Merge Is Idempotent", func(t *testing.T) {
+		a := NewRevocationSet("a")
+		revoke(t, a, "tok-1", "", expiry)
+		b := NewRevocationSet("b")
+		for i := 0; i < 2; i++ {
+			if _, err := b.MergeSet(ctx, a); err != nil {
+				t.Fatalf("MergeSet() error: %v", err)
+			}
+		}
+		if b.Len() != 1 {
+			t.Errorf("Expected 1 entry, got %d", b.Len())
+		}
+	})
+
+	t.Run("Validator", func(t *testing.T) {
+		set := NewRevocationSet("a")
+		revoke(t, set, "tok-1", "", expiry)
+		if err := set.Validate(ctx, &Token{ID: "tok-1"}); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("Expected ErrTokenRevoked, got %v", err)
+		}
+	})
+
+	t.Run("Prune", func(t *testing.T) {
+		set := NewRevocationSet("a")
+		revoke(t, set, "old", "", time.Now().Add(-time.Minute))
+		revoke(t, set, "new", "", expiry)
+		if removed, err := set.Prune(ctx, time.Now()); err != nil || removed != 1 {
+			t.Errorf("Expected 1 pruned entry, got %d, %v", removed, err)
+		}
+		if !set.IsRevoked("new") {
+			t.Error("Expected unexpired revocation to be kept")
+		}
+	})
+	t.Run("Persisted Across Restart", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		defer client.Close()
+		log := NewRedisRevocationLog(client, "")
+
+		edge, err := LoadRevocationSet(ctx, "edge", log)
+		if err != nil {
+			t.Fatalf("LoadRevocationSet() error: %v", err)
+		}
+		revoke(t, edge, "tok-1", "lost device", expiry)
+		revoke(t, edge, "tok-old", "", time.Now().Add(-time.Minute))
+		core := NewRevocationSet("core")
+		revoke(t, core, "tok-2", "", expiry)
+		if _, err := edge.MergeSet(ctx, core); err != nil {
+			t.Fatalf("MergeSet() error: %v", err)
+		}
+		if _, err := edge.Prune(ctx, time.Now()); err != nil {
+			t.Fatalf("Prune() error: %v", err)
+		}
+		seen := edge.VersionVector()
+
+		restarted, err := LoadRevocationSet(ctx, "edge", log)
+		if err != nil {
+			t.Fatalf("LoadRevocationSet() error: %v", err)
+		}
+		for _, id := range []string{"tok-1", "tok-2"} {
+			if !restarted.IsRevoked(id) {
+				t.Errorf("Expected %s to stay revoked after restart", id)
+			}
+		}
+		if restarted.IsRevoked("tok-old") {
+			t.Error("Expected the pruned entry to stay pruned")
+		}
+
+		// The pruned entry held the highest sequence; it must not be reused
+		entry, err := restarted.Revoke(ctx, "tok-3", "", expiry)
+		if err != nil {
+			t.Fatalf("Revoke() error: %v", err)
+		}
+		if entry.Seq != 3 {
+			t.Errorf("Expected sequence 3 after restart, got %d", entry.Seq)
+		}
+		if delta := restarted.Delta(seen); len(delta) != 1 || delta[0].TokenID != "tok-3" {
+			t.Errorf("Expected peers to receive the new revocation, got %+v", delta)
+		}
+	})
+
+	t.Run("Save Failure", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		defer client.Close()
+		set, err := LoadRevocationSet(ctx, "a", NewRedisRevocationLog(client, ""))
+		if err != nil {
+			t.Fatalf("LoadRevocationSet() error: %v", err)
+		}
+		mr.Close()
+		if _, err := set.Revoke(ctx, "tok-1", "", expiry); err == nil {
+			t.Error("Expected Revoke to fail when the log is unavailable")
+		}
+		if set.IsRevoked("tok-1") || set.VersionVector()["a"] != 0 {
+			t.Error("Expected nothing to be applied when saving fails")
+		}
+	})
+}
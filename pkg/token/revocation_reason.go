@@ -0,0 +1,151 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// ErrInvalidRevocationReason indicates a missing or unknown revocation reason
+var ErrInvalidRevocationReason = errors.New("invalid revocation reason")
+
+// RevocationReason classifies why a token was revoked. Every revocation
+// carries one, so events, audit entries, introspection responses and
+// statistics can be grouped by cause; free text goes in
+// RevocationStatus.Detail.
+type RevocationReason string
+
+// Revocation reasons
+const (
+	// ReasonCompromise means the token or its key material was exposed
+	ReasonCompromise RevocationReason = "compromise"
+
+	// ReasonPolicyChange means the policy or permissions behind the token
+	// changed, e.g. a role or scope was withdrawn
+	ReasonPolicyChange RevocationReason = "policy_change"
+
+	// ReasonMandateExpired means the power of attorney or mandate the token
+	// was issued under has ended
+	ReasonMandateExpired RevocationReason = "mandate_expired"
+
+	// ReasonPrincipalRequest means the principal or token holder asked for
+	// the revocation
+	ReasonPrincipalRequest RevocationReason = "principal_request"
+
+	// ReasonSecurityIncident means the token was revoked as part of
+	// incident response, without evidence it was itself compromised
+	ReasonSecurityIncident RevocationReason = "security_incident"
+
+	// ReasonSuperseded means the token was replaced by a newer one, e.g. by
+	// rotation
+	ReasonSuperseded RevocationReason = "superseded"
+)
+
+// RevocationReasons returns every defined reason
+func RevocationReasons() []RevocationReason {
+	return []RevocationReason{
+		ReasonCompromise, ReasonPolicyChange, ReasonMandateExpired,
+		ReasonPrincipalRequest, ReasonSecurityIncident, ReasonSuperseded,
+	}
+}
+
+// Valid reports whether r is a defined reason
+func (r RevocationReason) Valid() bool {
+	for _, known := range RevocationReasons() {
+		if r == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRevocationReason converts a wire value, such as a form parameter,
+// to a reason
+func ParseRevocationReason(s string) (RevocationReason, error) {
+	r := RevocationReason(s)
+	if !r.Valid() {
+		return "", fmt.Errorf("%w: %q", ErrInvalidRevocationReason, s)
+	}
+	return r, nil
+}
+
+// checkReason returns an error unless r is a defined reason
+func checkReason(r RevocationReason) error {
+	if r == "" {
+		return fmt.Errorf("%w: a reason is required", ErrInvalidRevocationReason)
+	}
+	if !r.Valid() {
+		return fmt.Errorf("%w: %q", ErrInvalidRevocationReason, r)
+	}
+	return nil
+}
+
+// RevocationStats counts successful revocations by reason. It is an
+// events.EventHandler: register it where token_revoked events are sent,
+// such as Config.Events or RevokeChainOptions.Events, and it forwards every
+// event to Next.
+type RevocationStats struct {
+	// Next receives every event after it is counted (optional)
+	Next events.EventHandler
+
+	mu     sync.Mutex
+	counts map[RevocationReason]int64
+}
+
+// Handle counts token_revoked events and forwards them
+func (s *RevocationStats) Handle(e events.Event) {
+	if e.Action == string(events.ActionTokenRevoked) && e.Status == string(events.StatusSuccess) {
+		var reason RevocationReason
+		if e.Metadata != nil {
+			value, _ := e.Metadata.GetString("reason")
+			reason = RevocationReason(value)
+		}
+		s.mu.Lock()
+		if s.counts == nil {
+			s.counts = make(map[RevocationReason]int64)
+		}
+		s.counts[reason]++
+		s.mu.Unlock()
+	}
+	if s.Next != nil {
+		s.Next.Handle(e)
+	}
+}
+
+// Count returns the number of revocations recorded for a reason
+func (s *RevocationStats) Count(reason RevocationReason) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts[reason]
+}
+
+// Counts returns a copy of the counts by reason
+func (s *RevocationStats) Counts() map[RevocationReason]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[RevocationReason]int64, len(s.counts))
+	for r, n := range s.counts {
+		out[r] = n
+	}
+	return out
+}
+
+// Reasons returns the reasons with at least one revocation, most frequent
+// first
+func (s *RevocationStats) Reasons() []RevocationReason {
+	counts := s.Counts()
+	out := make([]RevocationReason, 0, len(counts))
+	for r := range counts {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if counts[out[i]] != counts[out[j]] {
+			return counts[out[i]] > counts[out[j]]
+		}
+		return out[i] < out[j]
+	})
+	return out
+}
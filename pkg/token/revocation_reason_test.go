@@ -0,0 +1,81 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+type eventRecorder []events.Event
+
+func (r *eventRecorder) Handle(e events.Event) { *r = append(*r, e) }
+
+func TestRevocationReasons(t *testing.T) {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	var recorded eventRecorder
+	stats := &RevocationStats{Next: &recorded}
+	svc := NewService(Config{SigningKey: key, ValidityPeriod: time.Hour, Events: stats}, NewMemoryStore())
+	issue := func(t *testing.T) *Token {
+		t.Helper()
+		issued, err := svc.Issue(ctx, &Token{ID: NewID(), Type: Access, Subject: "agent-1", Scopes: []string{"read"}})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		return issued
+	}
+
+	t.Run("Reason Required", func(t *testing.T) {
+		tok := issue(t)
+		for _, reason := range []RevocationReason{"", "because"} {
+			if err := svc.Revoke(ctx, tok, reason); !errors.Is(err, ErrInvalidRevocationReason) {
+				t.Errorf("Expected %q to be refused, got %v", reason, err)
+			}
+		}
+		if err := svc.Validate(ctx, tok); err != nil {
+			t.Errorf("Expected the token to stay valid, got %v", err)
+		}
+		if _, err := ParseRevocationReason("mandate_expired"); err != nil {
+			t.Errorf("ParseRevocationReason() error: %v", err)
+		}
+	})
+
+	t.Run("Recorded And Emitted", func(t *testing.T) {
+		tok := issue(t)
+		tok.RevocationStatus = &RevocationStatus{RevokedBy: "ops-1", Detail: "key found in a public repository"}
+		if err := svc.Revoke(ctx, tok, ReasonCompromise); err != nil {
+			t.Fatalf("Revoke() error: %v", err)
+		}
+		if status := tok.RevocationStatus; status.Reason != ReasonCompromise || status.RevokedBy != "ops-1" || status.RevokedAt.IsZero() {
+			t.Fatalf("Expected the revocation status to be recorded, got %+v", status)
+		}
+		last := recorded[len(recorded)-1]
+		reason, _ := last.Metadata.GetString("reason")
+		detail, _ := last.Metadata.GetString("detail")
+		if last.Action != string(events.ActionTokenRevoked) || reason != "compromise" || detail == "" {
+			t.Errorf("Unexpected revocation event %+v", last)
+		}
+	})
+
+	t.Run("Statistics", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if err := svc.Revoke(ctx, issue(t), ReasonMandateExpired); err != nil {
+				t.Fatalf("Revoke() error: %v", err)
+			}
+		}
+		if stats.Count(ReasonMandateExpired) != 2 || stats.Count(ReasonCompromise) != 1 {
+			t.Errorf("Unexpected counts %v", stats.Counts())
+		}
+		if reasons := stats.Reasons(); len(reasons) != 2 || reasons[0] != ReasonMandateExpired {
+			t.Errorf("Expected the most frequent reason first, got %v", reasons)
+		}
+	})
+}
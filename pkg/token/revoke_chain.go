@@ -0,0 +1,240 @@
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// RevokeChainOptions describes a cascading revocation
+type RevokeChainOptions struct {
+	// Reason is recorded on every revoked token (required)
+	Reason RevocationReason
+
+	// Detail is an optional free-text explanation
+	Detail string
+
+	// RevokedBy is the actor recorded on every revoked token
+	RevokedBy string
+
+	// Events receives a token_revoked event per revoked token
+	Events events.EventHandler
+}
+
+// RevokeChain revokes a token and every token derived from it: access
+// tokens minted by Refresh, StepDown tokens and delegated tokens, found
+// through their parent_token_id and derivation_chain metadata. Descendants
+// are revoked before the root so a failure never leaves a live child under
+// a revoked parent. It returns the revoked token IDs, root last.
+func RevokeChain(ctx context.Context, store Store, rootID string, opts RevokeChainOptions) ([]string, error) {
+	if err := checkReason(opts.Reason); err != nil {
+		return nil, err
+	}
+	root, err := store.Get(ctx, rootID)
+	if err != nil {
+		return nil, err
+	}
+	all, err := store.List(ctx, Filter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+
+	children := make(map[string][]*Token)
+	for _, t := range all {
+		if parent := parentTokenID(t); parent != "" {
+			children[parent] = append(children[parent], t)
+		}
+	}
+
+	// Breadth-first from the root; revoke in reverse so leaves go first
+	order := []*Token{root}
+	seen := map[string]bool{root.ID: true}
+	for i := 0; i < len(order); i++ {
+		kids := children[order[i].ID]
+		sort.Slice(kids, func(a, b int) bool { return kids[a].ID < kids[b].ID })
+		for _, kid := range kids {
+			if !seen[kid.ID] {
+				seen[kid.ID] = true
+				order = append(order, kid)
+			}
+		}
+	}
+	// Descendants whose direct parent is already gone still list the root in
+	// their derivation chain
+	for _, t := range all {
+		if !seen[t.ID] && containsString(derivationChain(t), root.ID) {
+			seen[t.ID] = true
+			order = append(order, t)
+		}
+	}
+
+	now := time.Now()
+	revoked := make([]string, 0, len(order))
+	for i := len(order) - 1; i >= 0; i-- {
+		t := order[i]
+		if t.RevocationStatus != nil {
+			continue
+		}
+		t.RevocationStatus = &RevocationStatus{RevokedAt: now, Reason: opts.Reason, Detail: opts.Detail, RevokedBy: opts.RevokedBy}
+		if err := store.Revoke(ctx, t); err != nil && !errors.Is(err, ErrTokenNotFound) {
+			emitChainRevocation(ctx, opts, t, root.ID, err)
+			return revoked, fmt.Errorf("failed to revoke token %s: %w", t.ID, err)
+		}
+		revoked = append(revoked, t.ID)
+		emitChainRevocation(ctx, opts, t, root.ID, nil)
+	}
+	return revoked, nil
+}
+
+func parentTokenID(t *Token) string {
+	if t.Metadata == nil {
+		return ""
+	}
+	return t.Metadata.AppData[AppDataParentTokenID]
+}
+
+func derivationChain(t *Token) []string {
+	if t.Metadata == nil {
+		return nil
+	}
+	return t.Metadata.Attributes[AttributeDerivationChain]
+}
+
+func emitChainRevocation(ctx context.Context, opts RevokeChainOptions, t *Token, rootID string, err error) {
+	if opts.Events == nil {
+		return
+	}
+	status, message := events.StatusSuccess, "token revoked"
+	if t.ID != rootID {
+		message = "derived token revoked with its ancestor"
+	}
+	if err != nil {
+		status, message = events.StatusFailure, fmt.Sprintf("revocation failed: %v", err)
+	}
+	event := events.NewTokenEvent(events.ActionTokenRevoked, status).
+		WithContext(ctx).
+		WithSubject(opts.RevokedBy).
+		WithResource(t.ID).
+		WithMessage(message).
+		WithStringMetadata("cascade_root", rootID).
+		WithStringMetadata("reason", string(opts.Reason))
+	if opts.Detail != "" {
+		event = event.WithStringMetadata("detail", opts.Detail)
+	}
+	if parent := parentTokenID(t); parent != "" {
+		event = event.WithStringMetadata("parent_token_id", parent)
+	}
+	opts.Events.Handle(event)
+}
+
+// RevocationEndpointConfig configures the RFC 7009 revocation endpoint
+type RevocationEndpointConfig struct {
+	// Service parses presented token values
+	Service ServiceAPI
+
+	// Store holds the tokens to revoke
+	Store Store
+
+	// Authenticate identifies the calling client. It returns an error for
+	// missing or invalid credentials.
+	Authenticate func(r *http.Request) (clientID string, err error)
+
+	// Cascade also revokes every token derived from the revoked one
+	Cascade bool
+
+	// Events receives a token_revoked event per revoked token
+	Events events.EventHandler
+}
+
+// revocationHandler implements the RFC 7009 revocation endpoint
+type revocationHandler struct {
+	config RevocationEndpointConfig
+}
+
+// NewRevocationHandler returns an RFC 7009 token revocation endpoint. The
+// client must authenticate; a token whose metadata names another client
+// (Metadata.AppID) is refused. Unknown and unparseable tokens are answered
+// with 200 as the RFC requires.
+func NewRevocationHandler(config RevocationEndpointConfig) (http.Handler, error) {
+	if config.Service == nil || config.Store == nil {
+		return nil, fmt.Errorf("%w: service and store are required", ErrInvalidConfig)
+	}
+	if config.Authenticate == nil {
+		return nil, fmt.Errorf("%w: client authentication is required", ErrInvalidConfig)
+	}
+	return &revocationHandler{config: config}, nil
+}
+
+func (h *revocationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "revocation requires POST")
+		return
+	}
+	clientID, err := h.config.Authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="gauth"`)
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	}
+	value := r.PostFormValue("token")
+	if value == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "token parameter is required")
+		return
+	}
+	// token_type_hint only speeds up lookup; every type is revocable here
+	switch r.PostFormValue("token_type_hint") {
+	case "", "access_token", "refresh_token":
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_token_type", "unknown token_type_hint")
+		return
+	}
+
+	ctx := r.Context()
+	parsed, err := h.config.Service.Parse(ctx, value)
+	if err != nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	stored, err := h.config.Store.Get(ctx, parsed.ID)
+	if errors.Is(err, ErrTokenNotFound) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err != nil {
+		writeOAuthError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "token store unavailable")
+		return
+	}
+	if stored.Metadata != nil && stored.Metadata.AppID != "" && stored.Metadata.AppID != clientID {
+		writeOAuthError(w, http.StatusBadRequest, "unauthorized_client", "token was not issued to this client")
+		return
+	}
+
+	opts := RevokeChainOptions{Reason: ReasonPrincipalRequest, Detail: "revoked via RFC 7009 endpoint", RevokedBy: clientID, Events: h.config.Events}
+	if h.config.Cascade {
+		_, err = RevokeChain(ctx, h.config.Store, stored.ID, opts)
+	} else if stored.RevocationStatus == nil {
+		stored.RevocationStatus = &RevocationStatus{RevokedAt: time.Now(), Reason: opts.Reason, Detail: opts.Detail, RevokedBy: clientID}
+		err = h.config.Store.Revoke(ctx, stored)
+		emitChainRevocation(ctx, opts, stored, stored.ID, err)
+	}
+	if err != nil && !errors.Is(err, ErrTokenNotFound) {
+		writeOAuthError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "revocation failed")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeOAuthError writes an RFC 6749 §5.2 error response
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": code, "error_description": description})
+}
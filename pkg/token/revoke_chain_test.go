@@ -0,0 +1,146 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRevokeChain(t *testing.T) {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+
+	// issueFamily issues a refresh token, an access token refreshed from it,
+	// a step-down token below that, and an unrelated token
+	issueFamily := func(t *testing.T) (ServiceAPI, Store, []*Token) {
+		t.Helper()
+		store := NewMemoryStore(time.Hour)
+		svc := NewService(Config{SigningKey: key, ValidityPeriod: time.Hour, RefreshPeriod: time.Hour}, store)
+		root, err := svc.Issue(ctx, &Token{ID: NewID(), Type: Refresh, Subject: "agent-1",
+			Scopes: []string{"read", "write"}, Metadata: &Metadata{AppID: "client-1"}})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		access, err := svc.Refresh(ctx, root)
+		if err != nil {
+			t.Fatalf("Refresh() error: %v", err)
+		}
+		reduced, err := StepDown(ctx, svc, access, StepDownRequest{Scopes: []string{"read"}})
+		if err != nil {
+			t.Fatalf("StepDown() error: %v", err)
+		}
+		other, err := svc.Issue(ctx, &Token{ID: NewID(), Type: Access, Subject: "agent-2"})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		return svc, store, []*Token{root, access, reduced, other}
+	}
+
+	t.Run("Cascade", func(t *testing.T) {
+		_, store, family := issueFamily(t)
+		handler := &captureHandler{}
+		revoked, err := RevokeChain(ctx, store, family[0].ID, RevokeChainOptions{
+			Reason: ReasonCompromise, RevokedBy: "admin", Events: handler,
+		})
+		if err != nil {
+			t.Fatalf("RevokeChain() error: %v", err)
+		}
+		want := []string{family[2].ID, family[1].ID, family[0].ID}
+		if strings.Join(revoked, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected leaves-first order %v, got %v", want, revoked)
+		}
+		for _, tok := range family[:3] {
+			if _, err := store.Get(ctx, tok.ID); !errors.Is(err, ErrTokenNotFound) {
+				t.Errorf("Expected %s revoked, got %v", tok.ID, err)
+			}
+		}
+		if _, err := store.Get(ctx, family[3].ID); err != nil {
+			t.Errorf("Expected unrelated token kept, got %v", err)
+		}
+		if len(handler.events) != 3 {
+			t.Fatalf("Expected 3 events, got %d", len(handler.events))
+		}
+		if got, _ := handler.events[0].Metadata.GetString("cascade_root"); got != family[0].ID {
+			t.Errorf("Expected cascade_root %s, got %q", family[0].ID, got)
+		}
+	})
+
+	t.Run("Orphaned Descendant", func(t *testing.T) {
+		_, store, family := issueFamily(t)
+		if err := store.Delete(ctx, family[1].ID); err != nil {
+			t.Fatalf("Delete() error: %v", err)
+		}
+		revoked, err := RevokeChain(ctx, store, family[0].ID, RevokeChainOptions{Reason: ReasonCompromise})
+		if err != nil {
+			t.Fatalf("RevokeChain() error: %v", err)
+		}
+		if len(revoked) != 2 || revoked[0] != family[2].ID {
+			t.Errorf("Expected grandchild reached through derivation chain, got %v", revoked)
+		}
+	})
+
+	t.Run("RFC 7009 Endpoint", func(t *testing.T) {
+		svc, store, family := issueFamily(t)
+		h, err := NewRevocationHandler(RevocationEndpointConfig{
+			Service: svc,
+			Store:   store,
+			Cascade: true,
+			Authenticate: func(r *http.Request) (string, error) {
+				id, secret, ok := r.BasicAuth()
+				if !ok || secret != "secret" {
+					return "", errors.New("bad credentials")
+				}
+				return id, nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("NewRevocationHandler() error: %v", err)
+		}
+		post := func(client string, form url.Values) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/revoke", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth(client, "secret")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			return rec
+		}
+
+		if rec := post("client-1", url.Values{"token": {"not-a-token"}}); rec.Code != http.StatusOK {
+			t.Errorf("Expected 200 for unknown token, got %d", rec.Code)
+		}
+		if rec := post("client-1", url.Values{}); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 without token, got %d", rec.Code)
+		}
+		if rec := post("client-1", url.Values{"token": {family[0].Value}, "token_type_hint": {"id_token"}}); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "unsupported_token_type") {
+			t.Errorf("Expected unsupported_token_type, got %d %s", rec.Code, rec.Body)
+		}
+		if rec := post("client-2", url.Values{"token": {family[0].Value}}); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected other client refused, got %d", rec.Code)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/revoke", strings.NewReader("token=x"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("Expected 401 with challenge, got %d", rec.Code)
+		}
+
+		if rec := post("client-1", url.Values{"token": {family[0].Value}, "token_type_hint": {"refresh_token"}}); rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body)
+		}
+		if err := svc.Validate(ctx, family[2]); err == nil {
+			t.Error("Expected derived token rejected after cascade")
+		}
+	})
+}
@@ -27,7 +27,7 @@ type BlacklistedToken struct {
 	RevokedAt time.Time
 
 	// Reason explains why the token was revoked
-	Reason string
+	Reason RevocationReason
 }
 
 // Blacklist manages revoked tokens
@@ -49,7 +49,7 @@ func NewBlacklist() *Blacklist {
 }
 
 // Add blacklists a token
-func (bl *Blacklist) Add(_ context.Context, token *Token, reason string) error {
+func (bl *Blacklist) Add(_ context.Context, token *Token, reason RevocationReason) error {
 	bl.mu.Lock()
 	defer bl.mu.Unlock()
 
@@ -132,9 +132,14 @@ func NewRotator(store Store, blacklist *Blacklist, config Config) *Rotator {
 	}
 }
 
-// RotateToken creates a new token and revokes the old one
+// RotateToken creates a new token in the old one's family and revokes the
+// old one
 func (r *Rotator) RotateToken(ctx context.Context, oldToken *Token) (*Token, error) {
 	// Create new token
+	var metadata *Metadata
+	if oldToken.Metadata != nil {
+		metadata = copyToken(oldToken).Metadata
+	}
 	newToken := &Token{
 		ID:        NewID(), // Implement NewID() helper
 		Type:      oldToken.Type,
@@ -144,8 +149,9 @@ func (r *Rotator) RotateToken(ctx context.Context, oldToken *Token) (*Token, err
 		NotBefore: time.Now(),
 		ExpiresAt: time.Now().Add(r.config.ValidityPeriod),
 		Scopes:    oldToken.Scopes,
-		Metadata:  oldToken.Metadata,
+		Metadata:  metadata,
 	}
+	Derive(newToken, oldToken, DerivationRotation)
 
 	// Store new token
 	if err := r.store.Save(ctx, newToken.ID, newToken); err != nil {
@@ -153,7 +159,7 @@ func (r *Rotator) RotateToken(ctx context.Context, oldToken *Token) (*Token, err
 	}
 
 	// Blacklist old token
-	if err := r.blacklist.Add(ctx, oldToken, "rotated"); err != nil {
+	if err := r.blacklist.Add(ctx, oldToken, ReasonSuperseded); err != nil {
 		return nil, err
 	}
 
@@ -27,7 +27,7 @@ func TestBlacklist(t *testing.T) {
 		}
 
 		// Add to blacklist
-		err := bl.Add(ctx, token, "test revocation")
+		err := bl.Add(ctx, token, ReasonCompromise)
 		if err != nil {
 			t.Fatalf("Failed to add token to blacklist: %v", err)
 		}
@@ -42,7 +42,7 @@ func TestBlacklist(t *testing.T) {
 		if !exists {
 			t.Error("Blacklisted token details not found")
 		}
-		if blToken.Reason != "test revocation" {
+		if blToken.Reason != ReasonCompromise {
 			t.Errorf("Wrong revocation reason: got %v, want test revocation",
 				blToken.Reason)
 		}
@@ -65,7 +65,7 @@ func TestBlacklist(t *testing.T) {
 			ID:        NewID(),
 			ExpiresAt: time.Now().Add(-time.Hour),
 		}
-		if err := bl.Add(ctx, expiredToken, "expired"); err != nil {
+		if err := bl.Add(ctx, expiredToken, ReasonMandateExpired); err != nil {
 			t.Fatalf("Failed to add expired token: %v", err)
 		}
 
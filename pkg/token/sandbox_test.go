@@ -0,0 +1,88 @@
+package token
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestSandboxMode(t *testing.T) {
+	ctx := context.Background()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	// The worst case: both environments share a key and a store
+	store := NewMemoryStore()
+	denyAll := NewIssuancePipeline(IssuanceCheckFunc{CheckName: "registry", Fn: func(context.Context, *Token) error {
+		return errors.New("registry unreachable")
+	}})
+	fakeChecked := false
+	fake := NewIssuancePipeline(IssuanceCheckFunc{CheckName: "fake_registry", Fn: func(context.Context, *Token) error {
+		fakeChecked = true
+		return nil
+	}})
+	prod := NewService(Config{SigningKey: key, ValidityPeriod: time.Hour}, store)
+	sandbox := NewService(Config{
+		SigningKey:     key,
+		ValidityPeriod: time.Hour,
+		IssuanceChecks: denyAll,
+		Mode:           ModeSandbox,
+		Sandbox:        &SandboxConfig{IssuanceChecks: fake},
+	}, store)
+
+	test, err := sandbox.Issue(ctx, &Token{ID: NewID(), Type: Access, Subject: "alice", Scopes: []string{"read"}})
+	if err != nil {
+		t.Fatalf("sandbox Issue() error: %v", err)
+	}
+	if !fakeChecked {
+		t.Error("sandbox issuance did not run the fake issuance checks")
+	}
+	live, err := prod.Issue(ctx, &Token{ID: NewID(), Type: Access, Subject: "alice", Scopes: []string{"read"}})
+	if err != nil {
+		t.Fatalf("production Issue() error: %v", err)
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(test.Value, claims); err != nil {
+		t.Fatalf("ParseUnverified() error: %v", err)
+	}
+	if claims[ClaimSandbox] != true {
+		t.Errorf("sandbox token lacks the %s claim: %v", ClaimSandbox, claims)
+	}
+
+	parsedTest, err := prod.Parse(ctx, test.Value)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if !IsSandbox(parsedTest) {
+		t.Fatal("parsed sandbox token is not marked as sandbox")
+	}
+	var verr *ValidationError
+	if err := prod.Validate(ctx, parsedTest); !errors.As(err, &verr) || verr.Code != ValidationCodeEnvironmentMismatch {
+		t.Errorf("production Validate(sandbox token) = %v, want %s", err, ValidationCodeEnvironmentMismatch)
+	}
+	if err := sandbox.Validate(ctx, parsedTest); err != nil {
+		t.Errorf("sandbox Validate(sandbox token) error: %v", err)
+	}
+
+	parsedLive, err := sandbox.Parse(ctx, live.Value)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if IsSandbox(parsedLive) {
+		t.Fatal("production token is marked as sandbox")
+	}
+	if err := sandbox.Validate(ctx, parsedLive); !errors.As(err, &verr) || verr.Code != ValidationCodeEnvironmentMismatch {
+		t.Errorf("sandbox Validate(production token) = %v, want %s", err, ValidationCodeEnvironmentMismatch)
+	}
+	if err := prod.Validate(ctx, parsedLive); err != nil {
+		t.Errorf("production Validate(production token) error: %v", err)
+	}
+}
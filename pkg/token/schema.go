@@ -0,0 +1,201 @@
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnsupportedSchema indicates a stored token was written by a newer
+// schema than this build understands
+var ErrUnsupportedSchema = errors.New("unsupported token schema version")
+
+// SchemaVersion is the version written by this build. Version 1 is the
+// legacy format: a bare Token JSON object without an envelope.
+const SchemaVersion = 2
+
+// storedToken is the versioned envelope persisted by stores
+type storedToken struct {
+	Version int             `json:"v"`
+	Token   json.RawMessage `json:"token"`
+}
+
+// MigrationFunc upgrades a decoded token document by one version in place
+type MigrationFunc func(doc map[string]interface{}) error
+
+var (
+	migrationsMu sync.RWMutex
+	migrations   = map[int]MigrationFunc{1: migrateV1}
+)
+
+// RegisterMigration registers the upgrade from version from to from+1.
+// Releases that change Token or Metadata bump SchemaVersion and register a
+// migration for the previous version.
+func RegisterMigration(from int, fn MigrationFunc) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	migrations[from] = fn
+}
+
+// MarshalStored encodes a token in the current schema
+func MarshalStored(t *Token) ([]byte, error) {
+	body, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(storedToken{Version: SchemaVersion, Token: body})
+}
+
+// UnmarshalStored decodes a token written by any known schema version,
+// migrating it to the current one. It reports whether a migration ran so
+// callers can write the upgraded form back.
+func UnmarshalStored(data []byte) (*Token, bool, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, false, err
+	}
+
+	version, body := 1, data
+	if raw, ok := probe["v"]; ok && probe["token"] != nil && probe["id"] == nil {
+		var env storedToken
+		if err := json.Unmarshal(data, &env); err != nil {
+			return nil, false, err
+		}
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return nil, false, fmt.Errorf("invalid schema version: %w", err)
+		}
+		body = env.Token
+	}
+	if version > SchemaVersion {
+		return nil, false, fmt.Errorf("%w: %d (this build supports up to %d)", ErrUnsupportedSchema, version, SchemaVersion)
+	}
+
+	if version < SchemaVersion {
+		var doc map[string]interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, false, err
+		}
+		migrationsMu.RLock()
+		for v := version; v < SchemaVersion; v++ {
+			fn, ok := migrations[v]
+			if !ok {
+				migrationsMu.RUnlock()
+				return nil, false, fmt.Errorf("%w: no migration from version %d", ErrUnsupportedSchema, v)
+			}
+			if err := fn(doc); err != nil {
+				migrationsMu.RUnlock()
+				return nil, false, fmt.Errorf("failed to migrate token from version %d: %w", v, err)
+			}
+		}
+		migrationsMu.RUnlock()
+		var err error
+		if body, err = json.Marshal(doc); err != nil {
+			return nil, false, err
+		}
+	}
+
+	var t Token
+	if err := json.Unmarshal(body, &t); err != nil {
+		return nil, false, err
+	}
+	return &t, version < SchemaVersion, nil
+}
+
+// migrateV1 normalises legacy documents written with JWT-style claim
+// encodings: space-delimited scope strings, a single audience string,
+// NumericDate timestamps and single-valued metadata attributes
+func migrateV1(doc map[string]interface{}) error {
+	if s, ok := doc["scope"].(string); ok {
+		doc["scope"] = strings.Fields(s)
+	}
+	if s, ok := doc["aud"].(string); ok {
+		doc["aud"] = []string{s}
+	}
+	for _, field := range []string{"iat", "exp", "nbf", "last_used_at"} {
+		if n, ok := doc[field].(float64); ok {
+			doc[field] = time.Unix(int64(n), 0).UTC().Format(time.RFC3339)
+		}
+	}
+	if md, ok := doc["metadata"].(map[string]interface{}); ok {
+		if attrs, ok := md["attributes"].(map[string]interface{}); ok {
+			for k, v := range attrs {
+				if s, ok := v.(string); ok {
+					attrs[k] = []string{s}
+				}
+			}
+		}
+	}
+	if rs, ok := doc["revocation_status"].(map[string]interface{}); ok {
+		if n, ok := rs["revoked_at"].(float64); ok {
+			rs["revoked_at"] = time.Unix(int64(n), 0).UTC().Format(time.RFC3339)
+		}
+	}
+	return nil
+}
+
+// RawTokenStore exposes a store's serialized records for offline migration
+type RawTokenStore interface {
+	// ForEachRaw calls fn with every stored token record
+	ForEachRaw(ctx context.Context, fn func(key string, data []byte) error) error
+
+	// PutRaw replaces a stored record without changing its expiry
+	PutRaw(ctx context.Context, key string, data []byte) error
+}
+
+// MigrationReport summarises a bulk migration
+type MigrationReport struct {
+	Scanned  int               `json:"scanned"`
+	Migrated int               `json:"migrated"`
+	Current  int               `json:"current"`
+	Failed   map[string]string `json:"failed,omitempty"`
+	DryRun   bool              `json:"dry_run"`
+}
+
+// MigrateStore rewrites every record in the current schema. Records that
+// cannot be decoded are listed in the report and left untouched.
+func MigrateStore(ctx context.Context, store RawTokenStore, dryRun bool) (*MigrationReport, error) {
+	report := &MigrationReport{Failed: make(map[string]string), DryRun: dryRun}
+	err := store.ForEachRaw(ctx, func(key string, data []byte) error {
+		report.Scanned++
+		t, migrated, err := UnmarshalStored(data)
+		if err != nil {
+			report.Failed[key] = err.Error()
+			return nil
+		}
+		if !migrated {
+			report.Current++
+			return nil
+		}
+		report.Migrated++
+		if dryRun {
+			return nil
+		}
+		out, err := MarshalStored(t)
+		if err != nil {
+			return fmt.Errorf("failed to encode token %s: %w", key, err)
+		}
+		if err := store.PutRaw(ctx, key, out); err != nil {
+			return fmt.Errorf("failed to write token %s: %w", key, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// FailedKeys returns the keys that could not be migrated, sorted
+func (r *MigrationReport) FailedKeys() []string {
+	keys := make([]string, 0, len(r.Failed))
+	for k := range r.Failed {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,92 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+const legacyToken = `{"id":"legacy-1","token":"v","type":"access_token","iat":1700000000,"exp":4102444800,` +
+	`"nbf":1700000000,"iss":"gauth","sub":"alice","aud":"api","scope":"read write",` +
+	`"alg":"RS256","metadata":{"attributes":{"dept":"finance"}}}`
+
+func TestTokenSchema(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Migrate On Read", func(t *testing.T) {
+		tok, migrated, err := UnmarshalStored([]byte(legacyToken))
+		if err != nil {
+			t.Fatalf("UnmarshalStored() error: %v", err)
+		}
+		if !migrated {
+			t.Error("Expected legacy token to be migrated")
+		}
+		if len(tok.Scopes) != 2 || tok.Scopes[1] != "write" || len(tok.Audience) != 1 || tok.Audience[0] != "api" {
+			t.Errorf("Unexpected scopes %v or audience %v", tok.Scopes, tok.Audience)
+		}
+		if !tok.IssuedAt.Equal(time.Unix(1700000000, 0)) {
+			t.Errorf("Unexpected issued at %v", tok.IssuedAt)
+		}
+		if got := tok.Metadata.Attributes["dept"]; len(got) != 1 || got[0] != "finance" {
+			t.Errorf("Unexpected attributes %v", tok.Metadata.Attributes)
+		}
+
+		data, err := MarshalStored(tok)
+		if err != nil {
+			t.Fatalf("MarshalStored() error: %v", err)
+		}
+		again, migrated, err := UnmarshalStored(data)
+		if err != nil || migrated || again.ID != tok.ID {
+			t.Errorf("Expected current record to round-trip, got %v, %v, %v", again, migrated, err)
+		}
+
+		if _, _, err := UnmarshalStored([]byte(`{"v":99,"token":{}}`)); !errors.Is(err, ErrUnsupportedSchema) {
+			t.Errorf("Expected ErrUnsupportedSchema, got %v", err)
+		}
+	})
+
+	t.Run("Bulk Redis Migration", func(t *testing.T) {
+		mr, err := miniredis.Run()
+		if err != nil {
+			t.Fatalf("miniredis.Run() error: %v", err)
+		}
+		defer mr.Close()
+		store, err := NewRedisStore(RedisConfig{Addresses: []string{mr.Addr()}, KeyPrefix: "test:"})
+		if err != nil {
+			t.Fatalf("NewRedisStore() error: %v", err)
+		}
+		defer store.Close()
+
+		_ = mr.Set("test:token:legacy-1", legacyToken)
+		_ = mr.Set("test:token:broken", "{not json")
+		if err := store.Save(ctx, &Token{ID: "current", Subject: "bob", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+
+		report, err := MigrateStore(ctx, store, true)
+		if err != nil {
+			t.Fatalf("MigrateStore() error: %v", err)
+		}
+		if report.Scanned != 3 || report.Migrated != 1 || report.Current != 1 || len(report.Failed) != 1 {
+			t.Errorf("Unexpected dry-run report %+v", report)
+		}
+		if raw, _ := mr.Get("test:token:legacy-1"); raw != legacyToken {
+			t.Error("Expected dry run to leave records untouched")
+		}
+
+		if _, err := MigrateStore(ctx, store, false); err != nil {
+			t.Fatalf("MigrateStore() error: %v", err)
+		}
+		report, _ = MigrateStore(ctx, store, true)
+		if report.Migrated != 0 || report.Current != 2 {
+			t.Errorf("Expected all readable records current, got %+v", report)
+		}
+		tok, err := store.Get(ctx, "legacy-1")
+		if err != nil || tok.Subject != "alice" {
+			t.Errorf("Expected migrated token readable, got %v, %v", tok, err)
+		}
+	})
+}
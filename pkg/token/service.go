@@ -3,12 +3,16 @@ package token
 import (
 	"context"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/base64"
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
 )
 
 // splitScopes splits a comma-separated string into a slice of scopes
@@ -20,10 +24,11 @@ func splitScopes(s string) []string {
 type ServiceAPI interface {
 	GetToken(ctx context.Context, id string) (*Token, error)
 	Validate(ctx context.Context, token *Token) error
-	Revoke(ctx context.Context, token *Token) error
+	Revoke(ctx context.Context, token *Token, reason RevocationReason) error
 	Issue(ctx context.Context, token *Token) (*Token, error)
 	Refresh(ctx context.Context, refreshToken *Token) (*Token, error)
 	List(ctx context.Context, filter Filter) ([]*Token, error)
+	Parse(ctx context.Context, value string) (*Token, error)
 }
 
 // Service provides token management functionality
@@ -70,20 +75,56 @@ func (s *Service) Issue(ctx context.Context, token *Token) (*Token, error) {
 	}
 
 	if token.Algorithm == "" {
-		token.Algorithm = s.config.SigningMethod
+		token.Algorithm = s.algorithm()
 	}
 
 	if len(token.Scopes) == 0 {
 		token.Scopes = s.config.DefaultScopes
 	}
 
+	stampCorrelationID(ctx, token)
+	stampCertThumbprint(ctx, token)
+	if s.config.Mode == ModeSandbox {
+		stampSandbox(token)
+	}
+
 	// Basic validation
 	if err := s.validateConfig(token); err != nil {
 		return nil, NewValidationErrorWithCause(ValidationCodeInvalidConfig, "token fails config validation", err)
 	}
 
+	if err := ValidateDelegation(token); err != nil {
+		return nil, NewValidationErrorWithCause(ValidationCodeInvalidClaims, "token carries an invalid delegation chain", err)
+	}
+	if chain := DelegationChainOf(token); chain != nil && !chain.ActiveAt(time.Now()) {
+		return nil, NewValidationError(ValidationCodeExpired, "delegation chain has expired")
+	}
+
+	// Pre-issuance checks
+	if checks := s.config.issuanceChecks(); checks != nil {
+		if err := checks.Run(ctx, token); err != nil {
+			return nil, err
+		}
+	}
+
+	// Claims enrichment
+	if enrichers := s.config.claimsEnrichers(); enrichers != nil {
+		if err := enrichers.Enrich(ctx, token); err != nil {
+			return nil, err
+		}
+	}
+
+	// Privacy by design: only the claims the audiences need are signed. The
+	// stored token keeps every claim, so delegation limits and attestations
+	// are still enforced.
+	claims := token
+	if s.config.ClaimMinimization != nil {
+		claims = copyToken(token)
+		s.config.ClaimMinimization.Minimize(claims)
+	}
+
 	// Generate signed token value
-	signedValue, err := s.signToken(token)
+	signedValue, err := s.signToken(claims)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -97,44 +138,70 @@ func (s *Service) Issue(ctx context.Context, token *Token) (*Token, error) {
 	return token, nil
 }
 
-// Validate checks if a token is valid
+// Validate checks if a token is valid. Times, scopes, issuer and audience
+// are read from the signed claims of token.Value, so changing the fields of
+// the struct does not change the outcome.
 func (s *Service) Validate(ctx context.Context, token *Token) error {
-	if err := s.validateSignature(token); err != nil {
-		return err
+	_, err := s.validate(ctx, token)
+	return err
+}
+
+// validate checks a token and returns its stored copy, which is
+// authoritative for the claims minimization left out of the signed value
+func (s *Service) validate(ctx context.Context, token *Token) (*Token, error) {
+	signed, err := s.validateSignature(token)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.validateTimeClaims(token); err != nil {
-		return err
+	if err := validateMode(s.config.Mode, signed); err != nil {
+		return nil, err
 	}
 
-	if err := s.validateIssuerAndAudience(token); err != nil {
-		return err
+	if err := s.validateTimeClaims(signed); err != nil {
+		return nil, err
 	}
 
-	return s.validateTokenStorage(ctx, token)
+	if err := s.validateIssuerAndAudience(signed); err != nil {
+		return nil, err
+	}
+
+	return s.validateTokenStorage(ctx, signed)
 }
 
-func (s *Service) validateSignature(token *Token) error {
-	if err := s.verifySignature(token); err != nil {
-		return NewValidationErrorWithCause(ValidationCodeInvalidSignature, "invalid token signature", err)
+func (s *Service) validateSignature(token *Token) (*Token, error) {
+	signed, err := s.verifySignature(token)
+	if err != nil {
+		return nil, NewValidationErrorWithCause(ValidationCodeInvalidSignature, "invalid token signature", err)
 	}
-	return nil
+	return signed, nil
 }
 
 func (s *Service) validateTimeClaims(token *Token) error {
 	now := time.Now()
+	skew := clockSkewFor(token.Type, s.config.ClockSkew, s.config.ClockSkewByType)
 
-	if now.After(token.ExpiresAt) {
+	if now.After(token.ExpiresAt.Add(skew)) && !s.config.Grace.accepts(token, now, skew) {
 		return NewValidationError(ValidationCodeExpired, "token has expired")
 	}
 
-	if now.Before(token.NotBefore) {
+	if now.Before(token.NotBefore.Add(-skew)) {
 		return NewValidationError(ValidationCodeNotYetValid, "token not yet valid")
 	}
 
+	if chain := DelegationChainOf(token); chain != nil && !chain.ActiveAt(now.Add(-skew)) {
+		return NewValidationError(ValidationCodeExpired, "delegation chain has expired")
+	}
+
 	return nil
 }
 
+// IsStale reports whether a token that passed Validate was only accepted
+// under the grace policy, applying the same clock skew as Validate
+func (s *Service) IsStale(token *Token) bool {
+	return token != nil && IsStale(token, clockSkewFor(token.Type, s.config.ClockSkew, s.config.ClockSkewByType))
+}
+
 func (s *Service) validateIssuerAndAudience(token *Token) error {
 	if err := s.validateTokenIssuer(token); err != nil {
 		return err
@@ -171,31 +238,81 @@ func (s *Service) validateTokenAudience(token *Token) error {
 	return NewValidationError(ValidationCodeInvalidAudience, "token audience not allowed")
 }
 
-func (s *Service) validateTokenStorage(ctx context.Context, token *Token) error {
+func (s *Service) validateTokenStorage(ctx context.Context, token *Token) (*Token, error) {
 	stored, err := s.store.Get(ctx, token.ID)
 	if err != nil {
 		if err == ErrTokenNotFound {
-			return NewValidationError(ValidationCodeRevoked, "token has been revoked")
+			return nil, NewValidationError(ValidationCodeRevoked, "token has been revoked")
 		}
-		return NewValidationErrorWithCause(ValidationCodeStorageFailure, "failed to verify token status", err)
+		return nil, NewValidationErrorWithCause(ValidationCodeStorageFailure, "failed to verify token status", err)
 	}
 
 	if stored.Value != token.Value {
-		return NewValidationError(ValidationCodeInvalid, "token does not match stored value")
+		return nil, NewValidationError(ValidationCodeInvalid, "token does not match stored value")
 	}
 
-	return nil
+	if stored.RevocationStatus != nil {
+		return nil, NewValidationError(ValidationCodeRevoked, "token has been revoked")
+	}
+
+	// The stored token is authoritative for the certificate binding and for
+	// a delegation chain minimized out of the signed claims
+	if DelegationChainOf(token) == nil {
+		skew := clockSkewFor(stored.Type, s.config.ClockSkew, s.config.ClockSkewByType)
+		if chain := DelegationChainOf(stored); chain != nil && !chain.ActiveAt(time.Now().Add(-skew)) {
+			return nil, NewValidationError(ValidationCodeExpired, "delegation chain has expired")
+		}
+	}
+	if err := validateCertBinding(ctx, stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
 }
 
-// Revoke invalidates a token before its natural expiration
-func (s *Service) Revoke(ctx context.Context, token *Token) error {
-	return s.store.Delete(ctx, token.ID)
+// Revoke invalidates a token before its natural expiration. The reason is
+// required. RevokedBy and Detail are kept if the caller set
+// token.RevocationStatus beforehand.
+func (s *Service) Revoke(ctx context.Context, token *Token, reason RevocationReason) error {
+	if err := checkReason(reason); err != nil {
+		return err
+	}
+	status := RevocationStatus{}
+	if token.RevocationStatus != nil {
+		status = *token.RevocationStatus
+	}
+	status.RevokedAt, status.Reason = time.Now(), reason
+	token.RevocationStatus = &status
+
+	err := s.store.Revoke(ctx, token)
+	s.emitRevocation(ctx, token, err)
+	return err
+}
+
+func (s *Service) emitRevocation(ctx context.Context, t *Token, err error) {
+	if s.config.Events == nil {
+		return
+	}
+	status, message := events.StatusSuccess, "token revoked"
+	if err != nil {
+		status, message = events.StatusFailure, fmt.Sprintf("revocation failed: %v", err)
+	}
+	event := events.NewTokenEvent(events.ActionTokenRevoked, status).
+		WithContext(ctx).
+		WithSubject(t.RevocationStatus.RevokedBy).
+		WithResource(t.ID).
+		WithMessage(message).
+		WithStringMetadata("reason", string(t.RevocationStatus.Reason))
+	if t.RevocationStatus.Detail != "" {
+		event = event.WithStringMetadata("detail", t.RevocationStatus.Detail)
+	}
+	s.config.Events.Handle(event)
 }
 
 // Refresh exchanges a refresh token for a new access token
-func (s *Service) Refresh(ctx context.Context, refreshToken *Token) (*Token, error) {
-	// Validate refresh token
-	if err := s.Validate(ctx, refreshToken); err != nil {
+func (s *Service) Refresh(ctx context.Context, presented *Token) (*Token, error) {
+	// Validate refresh token; the stored copy is used from here on
+	refreshToken, err := s.validate(ctx, presented)
+	if err != nil {
 		return nil, fmt.Errorf("invalid refresh token: %w", err)
 	}
 
@@ -228,7 +345,8 @@ func (s *Service) Refresh(ctx context.Context, refreshToken *Token) (*Token, err
 		Subject:   refreshToken.Subject,
 		Audience:  refreshToken.Audience,
 		Scopes:    scopes,
-		Algorithm: s.config.SigningMethod,
+		Algorithm: s.algorithm(),
+		Metadata:  derivedMetadata(refreshToken, DerivationRefresh, ""),
 	}
 
 	// Helper to split comma-separated scopes
@@ -244,9 +362,66 @@ func (s *Service) List(ctx context.Context, filter Filter) ([]*Token, error) {
 	return s.store.List(ctx, filter)
 }
 
-func (s *Service) validateConfig(_ *Token) error {
+// Parse verifies a signed token value and decodes its claims, including the
+// RFC111 metadata. It does not check expiry or revocation; pass the result
+// to Validate for that.
+func (s *Service) Parse(_ context.Context, value string) (*Token, error) {
+	token, err := s.parse(value)
+	if err != nil {
+		return nil, NewValidationErrorWithCause(ValidationCodeInvalidSignature, "invalid token signature", err)
+	}
+	return token, nil
+}
+
+// parse verifies a token value against the key set, if configured, or the
+// signing key
+func (s *Service) parse(value string) (*Token, error) {
+	if s.config.KeySet != nil {
+		return s.config.KeySet.Verify(value)
+	}
 	if s.config.SigningKey == nil {
+		return nil, fmt.Errorf("%w: signing key not configured", ErrInvalidConfig)
+	}
+	return NewJWTSigner(s.config.SigningKey, s.algorithm()).ParseToken(value)
+}
+
+// algorithm returns the active key set algorithm, the configured signing
+// method, or the one implied by the signing key's type
+func (s *Service) algorithm() Algorithm {
+	if s.config.KeySet != nil {
+		_, _, alg, _ := s.config.KeySet.Active()
+		return alg
+	}
+	if s.config.SigningMethod != "" {
+		return s.config.SigningMethod
+	}
+	return algorithmForKey(s.config.SigningKey)
+}
+
+func algorithmForKey(key crypto.Signer) Algorithm {
+	switch key.(type) {
+	case *ecdsa.PrivateKey:
+		return ES256
+	case ed25519.PrivateKey, *ed25519.PrivateKey:
+		return EdDSA
+	default:
+		return RS256
+	}
+}
+
+func (s *Service) validateConfig(token *Token) error {
+	if s.config.KeySet != nil {
+		if _, _, _, err := s.config.KeySet.Active(); err != nil {
+			return err
+		}
+	} else if s.config.SigningKey == nil {
 		return fmt.Errorf("signing key not configured")
+	} else if !keyMatches(s.algorithm(), s.config.SigningKey) {
+		return fmt.Errorf("signing key type does not support %s", s.algorithm())
+	}
+
+	if token.Algorithm != s.algorithm() {
+		return fmt.Errorf("algorithm %s does not match the signing method %s", token.Algorithm, s.algorithm())
 	}
 
 	if s.config.ValidateIssuer && len(s.config.AllowedIssuers) == 0 {
@@ -261,30 +436,40 @@ func (s *Service) validateConfig(_ *Token) error {
 }
 
 func (s *Service) signToken(token *Token) (string, error) {
-	// This is a placeholder - actual signing would use JWT, PASETO, etc.
-	tokenBytes := []byte(fmt.Sprintf("%s.%s.%s", token.ID, token.Subject, token.Type))
-
-	// Hash the tokenBytes using SHA256
-	hash := crypto.SHA256.New()
-	hash.Write(tokenBytes)
-	hashed := hash.Sum(nil)
-
-	// Use rsa.SignPKCS1v15 for signing
-	rsaPriv, ok := s.config.SigningKey.(*rsa.PrivateKey)
-	if !ok {
-		return "", fmt.Errorf("SigningKey is not an *rsa.PrivateKey")
+	if s.config.KeySet != nil {
+		return s.config.KeySet.Sign(token)
 	}
-	signature, err := rsa.SignPKCS1v15(rand.Reader, rsaPriv, crypto.SHA256, hashed)
+	return NewJWTSigner(s.config.SigningKey, token.Algorithm).SignToken(token)
+}
+
+// verifySignature checks that the token value is a JWT signed by the
+// configured key for this token and returns its signed claims
+func (s *Service) verifySignature(token *Token) (*Token, error) {
+	parsed, err := s.parse(token.Value)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-
-	return base64.URLEncoding.EncodeToString(signature), nil
+	if parsed.ID != token.ID {
+		return nil, fmt.Errorf("token ID does not match signed claims")
+	}
+	return parsed, nil
 }
 
-func (s *Service) verifySignature(_ *Token) error {
-	// This is a placeholder - actual verification would use JWT, PASETO, etc.
-	return nil
+// keyMatches reports whether the key can sign with the algorithm
+func keyMatches(alg Algorithm, key crypto.Signer) bool {
+	switch alg {
+	case RS256, PS256:
+		_, ok := key.(*rsa.PrivateKey)
+		return ok
+	case ES256:
+		_, ok := key.(*ecdsa.PrivateKey)
+		return ok
+	case EdDSA:
+		_, ok := key.Public().(ed25519.PublicKey)
+		return ok
+	default:
+		return false
+	}
 }
 
 func (s *Service) periodicCleanup() {
@@ -303,6 +488,9 @@ func (s *Service) periodicCleanup() {
 		}
 
 		for _, token := range tokens {
+			if isHeld(s.config.LegalHolds, token) {
+				continue
+			}
 			_ = s.store.Delete(ctx, token.ID)
 		}
 	}
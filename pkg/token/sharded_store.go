@@ -0,0 +1,371 @@
+package token
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ShardedMemoryConfig configures a ShardedMemoryStore
+type ShardedMemoryConfig struct {
+	// Shards is the number of independently locked shards, rounded up to a
+	// power of two (default: 4 × GOMAXPROCS)
+	Shards int
+
+	// MaxTokens caps the store, split evenly across shards (0 = unlimited)
+	MaxTokens int
+
+	// CleanupInterval is how often every shard is swept for expired tokens.
+	// Shards are swept one at a time, spread over the interval, so a sweep
+	// never blocks more than one shard (0 = no background cleanup).
+	CleanupInterval time.Duration
+}
+
+// memoryShard is one lock domain of a ShardedMemoryStore
+type memoryShard struct {
+	mu        sync.RWMutex
+	tokens    map[string]*Token
+	maxTokens int
+}
+
+// holdsRef boxes a LegalHoldChecker for atomic replacement
+type holdsRef struct {
+	checker LegalHoldChecker
+}
+
+// ShardedMemoryStore is an in-memory Store that spreads tokens over shards
+// with their own locks, so concurrent Save and Get calls on different keys
+// do not contend on one mutex. It behaves like MemoryStore: Revoke deletes
+// the token and returned tokens are copies.
+type ShardedMemoryStore struct {
+	shards    []*memoryShard
+	mask      uint64
+	holds     atomic.Pointer[holdsRef]
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewShardedMemoryStore creates a sharded in-memory token store
+func NewShardedMemoryStore(config ShardedMemoryConfig) *ShardedMemoryStore {
+	n := config.Shards
+	if n <= 0 {
+		n = 4 * runtime.GOMAXPROCS(0)
+	}
+	size := 1
+	for size < n {
+		size <<= 1
+	}
+
+	s := &ShardedMemoryStore{
+		shards: make([]*memoryShard, size),
+		mask:   uint64(size - 1),
+		stop:   make(chan struct{}),
+	}
+	for i := range s.shards {
+		s.shards[i] = &memoryShard{tokens: make(map[string]*Token)}
+	}
+	s.SetCapacity(config.MaxTokens)
+
+	if config.CleanupInterval > 0 {
+		go s.sweep(config.CleanupInterval)
+	}
+	return s
+}
+
+// shardIndex returns the index of the shard owning key, by FNV-1a hash
+func (s *ShardedMemoryStore) shardIndex(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h & s.mask
+}
+
+func (s *ShardedMemoryStore) shard(key string) *memoryShard {
+	return s.shards[s.shardIndex(key)]
+}
+
+// ShardCount returns the number of shards
+func (s *ShardedMemoryStore) ShardCount() int {
+	return len(s.shards)
+}
+
+// SetCapacity caps the store at n tokens, split evenly across shards; a
+// shard that is full rejects Save with ErrStorageFailure (0 = unlimited)
+func (s *ShardedMemoryStore) SetCapacity(n int) {
+	per := 0
+	if n > 0 {
+		per = (n + len(s.shards) - 1) / len(s.shards)
+	}
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		sh.maxTokens = per
+		sh.mu.Unlock()
+	}
+}
+
+// Save stores a token with the given key
+func (s *ShardedMemoryStore) Save(ctx context.Context, key string, token *Token) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if _, exists := sh.tokens[key]; !exists && sh.maxTokens > 0 && len(sh.tokens) >= sh.maxTokens {
+		return ErrStorageFailure
+	}
+	sh.tokens[key] = token
+	return nil
+}
+
+// Get retrieves a token by key. Expired tokens are reported as
+// ErrTokenExpired and left for Cleanup to remove.
+func (s *ShardedMemoryStore) Get(ctx context.Context, key string) (*Token, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sh := s.shard(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	token, exists := sh.tokens[key]
+	if !exists {
+		return nil, ErrTokenNotFound
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+	return copyToken(token), nil
+}
+
+// Delete removes a token
+func (s *ShardedMemoryStore) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if _, exists := sh.tokens[key]; !exists {
+		return ErrTokenNotFound
+	}
+	delete(sh.tokens, key)
+	return nil
+}
+
+// List returns all tokens matching the filter. Shards are read one after
+// another, so the result is not a point-in-time snapshot of the whole store.
+func (s *ShardedMemoryStore) List(ctx context.Context, filter Filter) ([]*Token, error) {
+	var matches []*Token
+	for _, sh := range s.shards {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		sh.mu.RLock()
+		for _, token := range sh.tokens {
+			if matchesFilter(token, filter) {
+				matches = append(matches, copyToken(token))
+			}
+		}
+		sh.mu.RUnlock()
+	}
+	return applyPage(matches, filter)
+}
+
+// Count returns the number of tokens matching the filter
+func (s *ShardedMemoryStore) Count(ctx context.Context, filter Filter) (int64, error) {
+	var count int64
+	for _, sh := range s.shards {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		sh.mu.RLock()
+		for _, token := range sh.tokens {
+			if matchesFilter(token, filter) {
+				count++
+			}
+		}
+		sh.mu.RUnlock()
+	}
+	return count, nil
+}
+
+// Rotate replaces an existing token with a new one. When the two keys live
+// in different shards both are locked, in shard order, so the swap is atomic.
+func (s *ShardedMemoryStore) Rotate(ctx context.Context, old, newToken *Token) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	oldIdx, newIdx := s.shardIndex(old.ID), s.shardIndex(newToken.ID)
+	lo, hi := min(oldIdx, newIdx), max(oldIdx, newIdx)
+	s.shards[lo].mu.Lock()
+	defer s.shards[lo].mu.Unlock()
+	if hi != lo {
+		s.shards[hi].mu.Lock()
+		defer s.shards[hi].mu.Unlock()
+	}
+
+	oldShard, newShard := s.shards[oldIdx], s.shards[newIdx]
+	if _, exists := oldShard.tokens[old.ID]; !exists {
+		return ErrTokenNotFound
+	}
+	newShard.tokens[newToken.ID] = newToken
+	delete(oldShard.tokens, old.ID)
+	return nil
+}
+
+// Revoke invalidates a token
+func (s *ShardedMemoryStore) Revoke(ctx context.Context, token *Token) error {
+	return s.Delete(ctx, token.ID)
+}
+
+// groupByShard returns the indexes of keys grouped by owning shard
+func (s *ShardedMemoryStore) groupByShard(n int, key func(i int) string) map[uint64][]int {
+	groups := make(map[uint64][]int)
+	for i := 0; i < n; i++ {
+		idx := s.shardIndex(key(i))
+		groups[idx] = append(groups[idx], i)
+	}
+	return groups
+}
+
+// SaveBatch stores tokens under their IDs, locking each affected shard once.
+// Tokens for a full shard are reported in a BatchError.
+func (s *ShardedMemoryStore) SaveBatch(ctx context.Context, tokens []*Token) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	failed := batchErrors{}
+	for idx, group := range s.groupByShard(len(tokens), func(i int) string { return tokens[i].ID }) {
+		sh := s.shards[idx]
+		sh.mu.Lock()
+		for _, i := range group {
+			token := tokens[i]
+			if _, exists := sh.tokens[token.ID]; !exists && sh.maxTokens > 0 && len(sh.tokens) >= sh.maxTokens {
+				failed[token.ID] = ErrStorageFailure
+				continue
+			}
+			sh.tokens[token.ID] = token
+		}
+		sh.mu.Unlock()
+	}
+	return failed.err()
+}
+
+// RevokeBatch revokes tokens, locking each affected shard once
+func (s *ShardedMemoryStore) RevokeBatch(ctx context.Context, tokens []*Token) error {
+	keys := make([]string, len(tokens))
+	for i, token := range tokens {
+		keys[i] = token.ID
+	}
+	return s.DeleteBatch(ctx, keys)
+}
+
+// DeleteBatch removes tokens, locking each affected shard once. Missing keys
+// are reported in a BatchError.
+func (s *ShardedMemoryStore) DeleteBatch(ctx context.Context, keys []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	failed := batchErrors{}
+	for idx, group := range s.groupByShard(len(keys), func(i int) string { return keys[i] }) {
+		sh := s.shards[idx]
+		sh.mu.Lock()
+		for _, i := range group {
+			if _, exists := sh.tokens[keys[i]]; !exists {
+				failed[keys[i]] = ErrTokenNotFound
+				continue
+			}
+			delete(sh.tokens, keys[i])
+		}
+		sh.mu.Unlock()
+	}
+	return failed.err()
+}
+
+// Validate checks if a token is valid
+func (s *ShardedMemoryStore) Validate(ctx context.Context, token *Token) error {
+	stored, err := s.Get(ctx, token.ID)
+	if err != nil {
+		return err
+	}
+	if stored.Value != token.Value {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// Refresh generates a new access token from a refresh token
+func (s *ShardedMemoryStore) Refresh(ctx context.Context, refreshToken *Token) (*Token, error) {
+	if err := s.Validate(ctx, refreshToken); err != nil {
+		return nil, err
+	}
+	if refreshToken.Type != Refresh {
+		return nil, ErrInvalidType
+	}
+	return nil, ErrInvalidConfig // Actual refresh should be handled by Service
+}
+
+// Cleanup removes expired tokens, locking one shard at a time
+func (s *ShardedMemoryStore) Cleanup(ctx context.Context) error {
+	for i := range s.shards {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.cleanupShard(i, time.Now())
+	}
+	return nil
+}
+
+func (s *ShardedMemoryStore) cleanupShard(i int, now time.Time) {
+	var holds LegalHoldChecker
+	if ref := s.holds.Load(); ref != nil {
+		holds = ref.checker
+	}
+	sh := s.shards[i]
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	for key, token := range sh.tokens {
+		if token.ExpiresAt.Before(now) && !isHeld(holds, token) {
+			delete(sh.tokens, key)
+		}
+	}
+}
+
+// sweep cleans one shard per tick so each shard is visited once per interval
+func (s *ShardedMemoryStore) sweep(interval time.Duration) {
+	tick := interval / time.Duration(len(s.shards))
+	if tick < time.Millisecond {
+		tick = time.Millisecond
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	next := 0
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.cleanupShard(next, now)
+			next = (next + 1) % len(s.shards)
+		}
+	}
+}
+
+// SetLegalHolds makes Cleanup keep expired tokens of held subjects
+func (s *ShardedMemoryStore) SetLegalHolds(holds LegalHoldChecker) {
+	s.holds.Store(&holdsRef{checker: holds})
+}
+
+// Close stops background cleanup
+func (s *ShardedMemoryStore) Close() error {
+	s.closeOnce.Do(func() { close(s.stop) })
+	return nil
+}
@@ -0,0 +1,135 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardedMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	newToken := func(id string, ttl time.Duration) *Token {
+		return &Token{ID: id, Value: "value-" + id, Type: Access, Subject: "user-" + id,
+			IssuedAt: time.Now(), ExpiresAt: time.Now().Add(ttl)}
+	}
+
+	t.Run("Shard Count Rounded To Power Of Two", func(t *testing.T) {
+		if got := NewShardedMemoryStore(ShardedMemoryConfig{Shards: 5}).ShardCount(); got != 8 {
+			t.Errorf("Expected 8 shards, got %d", got)
+		}
+	})
+
+	t.Run("Concurrent Save And Get", func(t *testing.T) {
+		store := NewShardedMemoryStore(ShardedMemoryConfig{Shards: 16})
+		var wg sync.WaitGroup
+		for w := 0; w < 8; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < 200; i++ {
+					id := strconv.Itoa(w*1000 + i)
+					if err := store.Save(ctx, id, newToken(id, time.Hour)); err != nil {
+						t.Errorf("Save() error: %v", err)
+						return
+					}
+					if _, err := store.Get(ctx, id); err != nil {
+						t.Errorf("Get() error: %v", err)
+						return
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+		if n, _ := store.Count(ctx, Filter{}); n != 1600 {
+			t.Errorf("Expected 1600 tokens, got %d", n)
+		}
+	})
+
+	t.Run("Rotate Across Shards", func(t *testing.T) {
+		store := NewShardedMemoryStore(ShardedMemoryConfig{Shards: 64})
+		old := newToken("old", time.Hour)
+		_ = store.Save(ctx, old.ID, old)
+		// Find a replacement ID owned by a different shard
+		replacement := newToken("new-0", time.Hour)
+		for i := 1; store.shardIndex(replacement.ID) == store.shardIndex(old.ID); i++ {
+			replacement = newToken("new-"+strconv.Itoa(i), time.Hour)
+		}
+		if err := store.Rotate(ctx, old, replacement); err != nil {
+			t.Fatalf("Rotate() error: %v", err)
+		}
+		if _, err := store.Get(ctx, old.ID); !errors.Is(err, ErrTokenNotFound) {
+			t.Errorf("Expected old token gone, got %v", err)
+		}
+		if _, err := store.Get(ctx, replacement.ID); err != nil {
+			t.Errorf("Expected new token, got %v", err)
+		}
+	})
+
+	t.Run("Capacity", func(t *testing.T) {
+		store := NewShardedMemoryStore(ShardedMemoryConfig{Shards: 1, MaxTokens: 2})
+		_ = store.Save(ctx, "a", newToken("a", time.Hour))
+		_ = store.Save(ctx, "b", newToken("b", time.Hour))
+		if err := store.Save(ctx, "c", newToken("c", time.Hour)); !errors.Is(err, ErrStorageFailure) {
+			t.Errorf("Expected ErrStorageFailure, got %v", err)
+		}
+		if err := store.Save(ctx, "a", newToken("a", time.Hour)); err != nil {
+			t.Errorf("Overwriting an existing key should not count against capacity: %v", err)
+		}
+	})
+
+	t.Run("Background Cleanup Honors Legal Holds", func(t *testing.T) {
+		store := NewShardedMemoryStore(ShardedMemoryConfig{Shards: 4, CleanupInterval: 20 * time.Millisecond})
+		defer store.Close()
+		store.SetLegalHolds(subjectHolds{"user-held": true})
+		_ = store.Save(ctx, "gone", newToken("gone", -time.Minute))
+		_ = store.Save(ctx, "held", newToken("held", -time.Minute))
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			n, _ := store.Count(ctx, Filter{})
+			if n == 1 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected cleanup to leave only the held token, have %d", n)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		if _, err := store.Get(ctx, "held"); !errors.Is(err, ErrTokenExpired) {
+			t.Errorf("Expected held token kept as expired, got %v", err)
+		}
+	})
+}
+
+func benchmarkStoreParallel(b *testing.B, store Store) {
+	ctx := context.Background()
+	const keys = 100000
+	for i := 0; i < keys; i++ {
+		id := strconv.Itoa(i)
+		_ = store.Save(ctx, id, &Token{ID: id, ExpiresAt: time.Now().Add(time.Hour)})
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			id := strconv.Itoa(i % keys)
+			if i%4 == 0 {
+				_ = store.Save(ctx, id, &Token{ID: id, ExpiresAt: time.Now().Add(time.Hour)})
+			} else {
+				_, _ = store.Get(ctx, id)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkMemoryStoreParallel(b *testing.B) {
+	benchmarkStoreParallel(b, NewMemoryStore())
+}
+
+func BenchmarkShardedMemoryStoreParallel(b *testing.B) {
+	benchmarkStoreParallel(b, NewShardedMemoryStore(ShardedMemoryConfig{}))
+}
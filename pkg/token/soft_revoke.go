@@ -0,0 +1,250 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// Soft revocation errors
+var (
+	// ErrNotSoftRevoked indicates the token has no pending revocation to undo
+	ErrNotSoftRevoked = errors.New("token is not soft-revoked")
+
+	// ErrUndoWindowExpired indicates the revocation has become permanent
+	ErrUndoWindowExpired = errors.New("undo window has expired")
+
+	// ErrDuplicateApproval indicates the admin already approved this restore
+	ErrDuplicateApproval = errors.New("restore already approved by this admin")
+
+	// ErrRevokerApproval indicates the admin who revoked the token tried to
+	// approve its restore
+	ErrRevokerApproval = errors.New("revoker cannot approve the restore")
+)
+
+// SoftRevocationConfig configures a SoftRevoker
+type SoftRevocationConfig struct {
+	// Store holds the tokens being revoked
+	Store Store
+
+	// UndoWindow is how long a revocation can be undone (default: 15m)
+	UndoWindow time.Duration
+
+	// RequiredApprovals is the number of distinct admins that must approve a
+	// restore (default: 2)
+	RequiredApprovals int
+
+	// Events receives every transition for the audit trail
+	Events events.EventHandler
+
+	// LegalHolds keeps revoked tokens of held subjects instead of deleting
+	// them when the undo window closes; they stay revoked
+	LegalHolds LegalHoldChecker
+}
+
+// PendingRevocation is a soft revocation that can still be undone
+type PendingRevocation struct {
+	TokenID   string           `json:"token_id"`
+	Subject   string           `json:"subject"`
+	RevokedAt time.Time        `json:"revoked_at"`
+	RevokedBy string           `json:"revoked_by"`
+	Reason    RevocationReason `json:"reason"`
+	Deadline  time.Time        `json:"deadline"`
+	Approvals []string         `json:"approvals,omitempty"`
+}
+
+// SoftRevoker revokes tokens (including delegation tokens) in two phases.
+// A revoked token is immediately rejected by validation but stays in the
+// store until the undo window closes; within the window, RequiredApprovals
+// distinct admins other than the revoker can restore it. Afterwards Finalize
+// deletes it for good.
+//
+// Pending revocations are held in memory; after a restart they can no
+// longer be restored and their tokens stay in the store, revoked. Tokens
+// revoked by other means are never touched.
+type SoftRevoker struct {
+	config  SoftRevocationConfig
+	mu      sync.Mutex
+	pending map[string]*PendingRevocation
+}
+
+// NewSoftRevoker creates a soft revoker
+func NewSoftRevoker(config SoftRevocationConfig) (*SoftRevoker, error) {
+	if config.Store == nil {
+		return nil, fmt.Errorf("%w: store is required", ErrInvalidConfig)
+	}
+	if config.UndoWindow <= 0 {
+		config.UndoWindow = 15 * time.Minute
+	}
+	if config.RequiredApprovals <= 0 {
+		config.RequiredApprovals = 2
+	}
+	return &SoftRevoker{config: config, pending: make(map[string]*PendingRevocation)}, nil
+}
+
+// Revoke soft-revokes a token. It is rejected by validation at once and can
+// be restored until the undo window closes.
+func (r *SoftRevoker) Revoke(ctx context.Context, tokenID, actor string, reason RevocationReason) (*PendingRevocation, error) {
+	if err := checkReason(reason); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, err := r.config.Store.Get(ctx, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	if t.RevocationStatus != nil {
+		return nil, ErrTokenRevoked
+	}
+
+	now := time.Now()
+	t.RevocationStatus = &RevocationStatus{RevokedAt: now, Reason: reason, RevokedBy: actor}
+	if err := r.config.Store.Save(ctx, t.ID, t); err != nil {
+		return nil, fmt.Errorf("failed to mark token revoked: %w", err)
+	}
+
+	p := &PendingRevocation{
+		TokenID:   t.ID,
+		Subject:   t.Subject,
+		RevokedAt: now,
+		RevokedBy: actor,
+		Reason:    reason,
+		Deadline:  now.Add(r.config.UndoWindow),
+	}
+	r.pending[t.ID] = p
+	r.emit(ctx, events.ActionTokenRevoked, t.ID, actor,
+		fmt.Sprintf("token soft-revoked, restorable until %s", p.Deadline.Format(time.RFC3339)),
+		"state", "soft", "reason", string(reason))
+	return p, nil
+}
+
+// ApproveRestore records an admin's approval to undo a revocation. The token
+// is restored once RequiredApprovals distinct admins have approved.
+func (r *SoftRevoker) ApproveRestore(ctx context.Context, tokenID, admin string) (restored bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.pending[tokenID]
+	if !ok {
+		return false, ErrNotSoftRevoked
+	}
+	if time.Now().After(p.Deadline) {
+		return false, ErrUndoWindowExpired
+	}
+	if admin == p.RevokedBy {
+		return false, ErrRevokerApproval
+	}
+	for _, a := range p.Approvals {
+		if a == admin {
+			return false, ErrDuplicateApproval
+		}
+	}
+	p.Approvals = append(p.Approvals, admin)
+	r.emit(ctx, events.ActionTokenRestoreApproved, tokenID, admin,
+		fmt.Sprintf("restore approval %d of %d", len(p.Approvals), r.config.RequiredApprovals))
+
+	if len(p.Approvals) < r.config.RequiredApprovals {
+		return false, nil
+	}
+
+	t, err := r.config.Store.Get(ctx, tokenID)
+	if err != nil {
+		return false, err
+	}
+	t.RevocationStatus = nil
+	if err := r.config.Store.Save(ctx, t.ID, t); err != nil {
+		return false, fmt.Errorf("failed to restore token: %w", err)
+	}
+	delete(r.pending, tokenID)
+	r.emit(ctx, events.ActionTokenRestored, tokenID, admin, "token restored after dual approval")
+	return true, nil
+}
+
+// Finalize permanently deletes the soft-revoked tokens whose undo window
+// closed before now and returns the number of tokens deleted. Only tokens
+// revoked through this SoftRevoker are finalized.
+func (r *SoftRevoker) Finalize(ctx context.Context, now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	finalized := 0
+	for id, p := range r.pending {
+		if !now.After(p.Deadline) {
+			continue
+		}
+		t, err := r.config.Store.Get(ctx, id)
+		if errors.Is(err, ErrTokenNotFound) {
+			delete(r.pending, id)
+			continue
+		}
+		if err != nil {
+			return finalized, fmt.Errorf("failed to get token %s: %w", id, err)
+		}
+		if t.RevocationStatus == nil || isHeld(r.config.LegalHolds, t) {
+			delete(r.pending, id)
+			continue
+		}
+		if err := r.config.Store.Delete(ctx, id); err != nil && err != ErrTokenNotFound {
+			return finalized, fmt.Errorf("failed to delete token %s: %w", id, err)
+		}
+		delete(r.pending, id)
+		finalized++
+		r.emit(ctx, events.ActionTokenRevocationFinal, id, p.RevokedBy,
+			"revocation is permanent", "reason", string(p.Reason))
+	}
+	return finalized, nil
+}
+
+// Run calls Finalize every interval until ctx is cancelled
+func (r *SoftRevoker) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := r.Finalize(ctx, now); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Pending returns the revocations that can still be undone, oldest first
+func (r *SoftRevoker) Pending() []PendingRevocation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]PendingRevocation, 0, len(r.pending))
+	for _, p := range r.pending {
+		cp := *p
+		cp.Approvals = append([]string(nil), p.Approvals...)
+		out = append(out, cp)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].RevokedAt.Before(out[j].RevokedAt) })
+	return out
+}
+
+func (r *SoftRevoker) emit(ctx context.Context, action events.EventAction, tokenID, actor, message string, kv ...string) {
+	if r.config.Events == nil {
+		return
+	}
+	event := events.NewTokenEvent(action, events.StatusSuccess).
+		WithContext(ctx).
+		WithSubject(actor).
+		WithResource(tokenID).
+		WithMessage(message)
+	for i := 0; i+1 < len(kv); i += 2 {
+		event = event.WithStringMetadata(kv[i], kv[i+1])
+	}
+	r.config.Events.Handle(event)
+}
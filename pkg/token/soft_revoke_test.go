@@ -0,0 +1,103 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSoftRevoker(t *testing.T) {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	store := NewMemoryStore(time.Hour)
+	svc := NewService(Config{SigningKey: key, ValidityPeriod: time.Hour}, store)
+	handler := &captureHandler{}
+	revoker, err := NewSoftRevoker(SoftRevocationConfig{Store: store, UndoWindow: time.Minute, Events: handler})
+	if err != nil {
+		t.Fatalf("NewSoftRevoker() error: %v", err)
+	}
+
+	issued, err := svc.Issue(ctx, &Token{ID: NewID(), Type: Access, Subject: "poa-holder", Scopes: []string{"sign"}})
+	if err != nil {
+		t.Fatalf("Issue() error: %v", err)
+	}
+
+	t.Run("Restore With Dual Approval", func(t *testing.T) {
+		if _, err := revoker.Revoke(ctx, issued.ID, "ops-1", ReasonCompromise); err != nil {
+			t.Fatalf("Revoke() error: %v", err)
+		}
+		if err := svc.Validate(ctx, issued); err == nil {
+			t.Fatal("Expected soft-revoked token to fail validation")
+		}
+
+		if _, err := revoker.ApproveRestore(ctx, issued.ID, "ops-1"); !errors.Is(err, ErrRevokerApproval) {
+			t.Errorf("Expected ErrRevokerApproval, got %v", err)
+		}
+		restored, err := revoker.ApproveRestore(ctx, issued.ID, "admin-1")
+		if err != nil || restored {
+			t.Fatalf("Expected first approval to be recorded only, got %v, %v", restored, err)
+		}
+		if _, err := revoker.ApproveRestore(ctx, issued.ID, "admin-1"); !errors.Is(err, ErrDuplicateApproval) {
+			t.Errorf("Expected ErrDuplicateApproval, got %v", err)
+		}
+		restored, err = revoker.ApproveRestore(ctx, issued.ID, "admin-2")
+		if err != nil || !restored {
+			t.Fatalf("Expected restore after second approval, got %v, %v", restored, err)
+		}
+		if err := svc.Validate(ctx, issued); err != nil {
+			t.Errorf("Expected restored token to validate, got %v", err)
+		}
+		if len(revoker.Pending()) != 0 {
+			t.Error("Expected no pending revocations after restore")
+		}
+	})
+
+	t.Run("Finalize After Window", func(t *testing.T) {
+		if _, err := revoker.Revoke(ctx, issued.ID, "ops-1", ReasonSecurityIncident); err != nil {
+			t.Fatalf("Revoke() error: %v", err)
+		}
+		if n, _ := revoker.Finalize(ctx, time.Now()); n != 0 {
+			t.Errorf("Expected nothing finalized inside the window, got %d", n)
+		}
+		n, err := revoker.Finalize(ctx, time.Now().Add(2*time.Minute))
+		if err != nil || n != 1 {
+			t.Fatalf("Expected 1 finalized, got %d, %v", n, err)
+		}
+		if _, err := store.Get(ctx, issued.ID); !errors.Is(err, ErrTokenNotFound) {
+			t.Errorf("Expected token deleted, got %v", err)
+		}
+		if _, err := revoker.ApproveRestore(ctx, issued.ID, "admin-1"); !errors.Is(err, ErrNotSoftRevoked) {
+			t.Errorf("Expected ErrNotSoftRevoked, got %v", err)
+		}
+	})
+
+	t.Run("Hard Revocations Kept", func(t *testing.T) {
+		hard, err := svc.Issue(ctx, &Token{ID: NewID(), Type: Access, Subject: "other"})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		// Recorded the way RevokeChain and the revocation handler keep it
+		hard.RevocationStatus = &RevocationStatus{RevokedAt: time.Now(), Reason: ReasonCompromise, RevokedBy: "ops-2"}
+		if err := store.Save(ctx, hard.ID, hard); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+		if n, err := revoker.Finalize(ctx, time.Now().Add(time.Hour)); err != nil || n != 0 {
+			t.Errorf("Expected nothing finalized, got %d, %v", n, err)
+		}
+		stored, err := store.Get(ctx, hard.ID)
+		if err != nil || stored.RevocationStatus == nil || stored.RevocationStatus.Reason != ReasonCompromise {
+			t.Errorf("Expected the hard revocation record kept, got %+v, %v", stored, err)
+		}
+	})
+
+	// revoke, approve x2, restore, revoke, finalize
+	if len(handler.events) != 6 {
+		t.Errorf("Expected 6 audited transitions, got %d", len(handler.events))
+	}
+}
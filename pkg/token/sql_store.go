@@ -0,0 +1,665 @@
+package token
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+//go:embed migrations/*.sql
+var sqlMigrations embed.FS
+
+// sqlMigrationLock is the advisory lock key serialising schema migrations
+// across instances starting at the same time
+const sqlMigrationLock = 0x6761757468
+
+// SQLStore implements the Store interface using PostgreSQL. Each token is
+// kept as a versioned document (see MarshalStored) plus indexed columns for
+// lookups by subject, issuer, type and expiry.
+type SQLStore struct {
+	db    *sql.DB
+	holds LegalHoldChecker
+}
+
+// SQLStoreConfig holds configuration for the SQL token store
+type SQLStoreConfig struct {
+	// Driver name (default: "postgres")
+	Driver string
+
+	// DSN for database connection
+	DSN string
+
+	// MaxOpenConns sets the maximum number of open connections
+	MaxOpenConns int
+
+	// MaxIdleConns sets the maximum number of idle connections
+	MaxIdleConns int
+
+	// ConnMaxLifetime sets the maximum amount of time a connection may be reused
+	ConnMaxLifetime time.Duration
+}
+
+// NewSQLStore opens the database and applies pending schema migrations
+func NewSQLStore(config SQLStoreConfig) (*SQLStore, error) {
+	if config.Driver == "" {
+		config.Driver = "postgres"
+	}
+	db, err := sql.Open(config.Driver, config.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+
+	store, err := NewSQLStoreFromDB(context.Background(), db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// NewSQLStoreFromDB wraps an open database and applies pending migrations
+func NewSQLStoreFromDB(ctx context.Context, db *sql.DB) (*SQLStore, error) {
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	s := &SQLStore{db: db}
+	if err := s.Migrate(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Migrate applies embedded schema migrations that have not run yet. Each
+// migration runs in its own transaction.
+func (s *SQLStore) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS gauth_token_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)`); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	files, err := fs.Glob(sqlMigrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		name := strings.TrimPrefix(file, "migrations/")
+		version, err := strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("invalid migration name %s: %w", name, err)
+		}
+		if err := s.applyMigration(ctx, version, name, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLStore) applyMigration(ctx context.Context, version int, name, file string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", sqlMigrationLock); err != nil {
+		return fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	var applied bool
+	if err := tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM gauth_token_migrations WHERE version = $1)", version).Scan(&applied); err != nil {
+		return fmt.Errorf("failed to read migration state: %w", err)
+	}
+	if applied {
+		return nil
+	}
+
+	script, err := sqlMigrations.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+		return fmt.Errorf("failed to apply migration %s: %w", name, err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO gauth_token_migrations (version, name) VALUES ($1, $2)", version, name); err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", name, err)
+	}
+	return tx.Commit()
+}
+
+// sqlExecer is satisfied by *sql.DB and *sql.Tx
+type sqlExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+const upsertTokenSQL = `
+	INSERT INTO gauth_tokens (
+		id, value, subject, issuer, type, algorithm, scopes, audience,
+		issued_at, expires_at, not_before, metadata, body, revoked_at
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	ON CONFLICT (id) DO UPDATE SET
+		value = EXCLUDED.value, subject = EXCLUDED.subject, issuer = EXCLUDED.issuer,
+		type = EXCLUDED.type, algorithm = EXCLUDED.algorithm, scopes = EXCLUDED.scopes,
+		audience = EXCLUDED.audience, issued_at = EXCLUDED.issued_at,
+		expires_at = EXCLUDED.expires_at, not_before = EXCLUDED.not_before,
+		metadata = EXCLUDED.metadata, body = EXCLUDED.body, revoked_at = EXCLUDED.revoked_at`
+
+func saveToken(ctx context.Context, db sqlExecer, key string, token *Token) error {
+	body, err := MarshalStored(token)
+	if err != nil {
+		return fmt.Errorf("%w: failed to marshal token: %v", ErrStorageFailure, err)
+	}
+	var metadata []byte
+	if token.Metadata != nil {
+		if metadata, err = json.Marshal(token.Metadata); err != nil {
+			return fmt.Errorf("%w: failed to marshal metadata: %v", ErrStorageFailure, err)
+		}
+	}
+	var revokedAt *time.Time
+	if token.RevocationStatus != nil {
+		revokedAt = &token.RevocationStatus.RevokedAt
+	}
+
+	if _, err := db.ExecContext(ctx, upsertTokenSQL,
+		key, nullString(token.Value), token.Subject, token.Issuer, string(token.Type), string(token.Algorithm),
+		pq.Array(nonNil(token.Scopes)), pq.Array(nonNil(token.Audience)),
+		nullTime(token.IssuedAt), nullTime(token.ExpiresAt), nullTime(token.NotBefore),
+		metadata, body, revokedAt,
+	); err != nil {
+		return fmt.Errorf("%w: failed to save token: %v", ErrStorageFailure, err)
+	}
+	return nil
+}
+
+// Save implements the Store interface
+func (s *SQLStore) Save(ctx context.Context, key string, token *Token) error {
+	return saveToken(ctx, s.db, key, token)
+}
+
+// Get implements the Store interface
+func (s *SQLStore) Get(ctx context.Context, key string) (*Token, error) {
+	var body []byte
+	err := s.db.QueryRowContext(ctx, "SELECT body FROM gauth_tokens WHERE id = $1", key).Scan(&body)
+	if err == sql.ErrNoRows {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to get token: %v", ErrStorageFailure, err)
+	}
+	token, migrated, err := UnmarshalStored(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to unmarshal token: %v", ErrStorageFailure, err)
+	}
+	if migrated {
+		_ = saveToken(ctx, s.db, key, token)
+	}
+	return token, nil
+}
+
+// Delete implements the Store interface
+func (s *SQLStore) Delete(ctx context.Context, key string) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM gauth_tokens WHERE id = $1", key)
+	if err != nil {
+		return fmt.Errorf("%w: failed to delete token: %v", ErrStorageFailure, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// List implements the Store interface
+func (s *SQLStore) List(ctx context.Context, filter Filter) ([]*Token, error) {
+	where, args, err := filterSQL(filter, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, "SELECT body FROM gauth_tokens WHERE "+where+" ORDER BY issued_at", args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to list tokens: %v", ErrStorageFailure, err)
+	}
+	defer rows.Close()
+
+	var tokens []*Token
+	for rows.Next() {
+		var body []byte
+		if err := rows.Scan(&body); err != nil {
+			return nil, fmt.Errorf("%w: failed to scan token: %v", ErrStorageFailure, err)
+		}
+		token, _, err := UnmarshalStored(body)
+		if err != nil {
+			continue // Skip unreadable tokens
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return applyPage(tokens, filter)
+}
+
+// Count implements the Store interface
+func (s *SQLStore) Count(ctx context.Context, filter Filter) (int64, error) {
+	where, args, err := filterSQL(filter, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM gauth_tokens WHERE "+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("%w: failed to count tokens: %v", ErrStorageFailure, err)
+	}
+	return count, nil
+}
+
+// Rotate implements the Store interface. The old token is locked, the new
+// token inserted and the old one deleted in a single transaction.
+func (s *SQLStore) Rotate(ctx context.Context, old, newToken *Token) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: failed to begin rotation: %v", ErrStorageFailure, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var revokedAt sql.NullTime
+	err = tx.QueryRowContext(ctx, "SELECT revoked_at FROM gauth_tokens WHERE id = $1 FOR UPDATE", old.ID).Scan(&revokedAt)
+	if err == sql.ErrNoRows {
+		return ErrTokenNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("%w: failed to lock token: %v", ErrStorageFailure, err)
+	}
+	if revokedAt.Valid {
+		return ErrTokenRevoked
+	}
+	if err := saveToken(ctx, tx, newToken.ID, newToken); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM gauth_tokens WHERE id = $1", old.ID); err != nil {
+		return fmt.Errorf("%w: failed to delete rotated token: %v", ErrStorageFailure, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: failed to commit rotation: %v", ErrStorageFailure, err)
+	}
+	return nil
+}
+
+// Revoke implements the Store interface. The token is kept with its
+// revocation status so validation reports it as revoked, not unknown.
+func (s *SQLStore) Revoke(ctx context.Context, token *Token) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: failed to begin revocation: %v", ErrStorageFailure, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var body []byte
+	err = tx.QueryRowContext(ctx, "SELECT body FROM gauth_tokens WHERE id = $1 FOR UPDATE", token.ID).Scan(&body)
+	if err == sql.ErrNoRows {
+		return ErrTokenNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("%w: failed to lock token: %v", ErrStorageFailure, err)
+	}
+	stored, _, err := UnmarshalStored(body)
+	if err != nil {
+		return fmt.Errorf("%w: failed to unmarshal token: %v", ErrStorageFailure, err)
+	}
+	if stored.RevocationStatus != nil {
+		return nil
+	}
+	stored.RevocationStatus = &RevocationStatus{RevokedAt: time.Now()}
+	if token.RevocationStatus != nil {
+		stored.RevocationStatus = token.RevocationStatus
+	}
+	if err := saveToken(ctx, tx, token.ID, stored); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: failed to commit revocation: %v", ErrStorageFailure, err)
+	}
+	return nil
+}
+
+// SaveBatch implements BatchStore. All tokens are saved in one transaction.
+func (s *SQLStore) SaveBatch(ctx context.Context, tokens []*Token) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: failed to begin batch: %v", ErrStorageFailure, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, token := range tokens {
+		if err := saveToken(ctx, tx, token.ID, token); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: failed to commit batch: %v", ErrStorageFailure, err)
+	}
+	return nil
+}
+
+// RevokeBatch implements BatchStore. The tokens are locked and marked revoked
+// in one transaction; unknown tokens are reported in a BatchError.
+func (s *SQLStore) RevokeBatch(ctx context.Context, tokens []*Token) error {
+	ids := make([]string, len(tokens))
+	byID := make(map[string]*Token, len(tokens))
+	for i, token := range tokens {
+		ids[i] = token.ID
+		byID[token.ID] = token
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: failed to begin revocation: %v", ErrStorageFailure, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, body FROM gauth_tokens WHERE id = ANY($1) FOR UPDATE", pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("%w: failed to lock tokens: %v", ErrStorageFailure, err)
+	}
+	stored := make(map[string][]byte, len(ids))
+	for rows.Next() {
+		var id string
+		var body []byte
+		if err := rows.Scan(&id, &body); err != nil {
+			rows.Close()
+			return fmt.Errorf("%w: failed to scan token: %v", ErrStorageFailure, err)
+		}
+		stored[id] = body
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%w: failed to lock tokens: %v", ErrStorageFailure, err)
+	}
+
+	failed := batchErrors{}
+	now := time.Now()
+	for id, token := range byID {
+		body, ok := stored[id]
+		if !ok {
+			failed[id] = ErrTokenNotFound
+			continue
+		}
+		current, _, err := UnmarshalStored(body)
+		if err != nil {
+			failed[id] = fmt.Errorf("%w: failed to unmarshal token: %v", ErrStorageFailure, err)
+			continue
+		}
+		if current.RevocationStatus != nil {
+			continue
+		}
+		current.RevocationStatus = &RevocationStatus{RevokedAt: now}
+		if token.RevocationStatus != nil {
+			current.RevocationStatus = token.RevocationStatus
+		}
+		if err := saveToken(ctx, tx, id, current); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: failed to commit revocation: %v", ErrStorageFailure, err)
+	}
+	return failed.err()
+}
+
+// DeleteBatch implements BatchStore with a single statement; unknown keys
+// are reported in a BatchError
+func (s *SQLStore) DeleteBatch(ctx context.Context, keys []string) error {
+	rows, err := s.db.QueryContext(ctx, "DELETE FROM gauth_tokens WHERE id = ANY($1) RETURNING id", pq.Array(keys))
+	if err != nil {
+		return fmt.Errorf("%w: failed to delete tokens: %v", ErrStorageFailure, err)
+	}
+	defer rows.Close()
+
+	deleted := make(map[string]bool, len(keys))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("%w: failed to scan token: %v", ErrStorageFailure, err)
+		}
+		deleted[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%w: failed to delete tokens: %v", ErrStorageFailure, err)
+	}
+	failed := batchErrors{}
+	for _, key := range keys {
+		if !deleted[key] {
+			failed[key] = ErrTokenNotFound
+		}
+	}
+	return failed.err()
+}
+
+// Validate implements the Store interface
+func (s *SQLStore) Validate(ctx context.Context, token *Token) error {
+	stored, err := s.Get(ctx, token.ID)
+	if err != nil {
+		return err
+	}
+	if stored.Value != token.Value {
+		return ErrInvalidToken
+	}
+	if stored.RevocationStatus != nil {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// Refresh implements the Store interface
+func (s *SQLStore) Refresh(ctx context.Context, refreshToken *Token) (*Token, error) {
+	if err := s.Validate(ctx, refreshToken); err != nil {
+		return nil, err
+	}
+	if refreshToken.Type != Refresh {
+		return nil, ErrInvalidType
+	}
+	return nil, ErrInvalidConfig // Actual refresh should be handled by Service
+}
+
+// Cleanup implements the Store interface
+func (s *SQLStore) Cleanup(ctx context.Context) error {
+	if s.holds == nil {
+		if _, err := s.db.ExecContext(ctx, "DELETE FROM gauth_tokens WHERE expires_at < now()"); err != nil {
+			return fmt.Errorf("%w: failed to clean up tokens: %v", ErrStorageFailure, err)
+		}
+		return nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, subject FROM gauth_tokens WHERE expires_at < now()")
+	if err != nil {
+		return fmt.Errorf("%w: failed to find expired tokens: %v", ErrStorageFailure, err)
+	}
+	var expired []string
+	for rows.Next() {
+		var id, subject string
+		if err := rows.Scan(&id, &subject); err != nil {
+			rows.Close()
+			return fmt.Errorf("%w: failed to scan token: %v", ErrStorageFailure, err)
+		}
+		if !s.holds.HoldsSubject(subject) {
+			expired = append(expired, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%w: failed to find expired tokens: %v", ErrStorageFailure, err)
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM gauth_tokens WHERE id = ANY($1)", pq.Array(expired)); err != nil {
+		return fmt.Errorf("%w: failed to clean up tokens: %v", ErrStorageFailure, err)
+	}
+	return nil
+}
+
+// SetLegalHolds makes Cleanup keep expired tokens of held subjects
+func (s *SQLStore) SetLegalHolds(holds LegalHoldChecker) {
+	s.holds = holds
+}
+
+// ForEachRaw implements RawTokenStore
+func (s *SQLStore) ForEachRaw(ctx context.Context, fn func(key string, data []byte) error) error {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, body FROM gauth_tokens ORDER BY id")
+	if err != nil {
+		return fmt.Errorf("%w: failed to scan tokens: %v", ErrStorageFailure, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var body []byte
+		if err := rows.Scan(&key, &body); err != nil {
+			return fmt.Errorf("%w: failed to scan token: %v", ErrStorageFailure, err)
+		}
+		if err := fn(key, body); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// PutRaw implements RawTokenStore; indexed columns are refreshed from the
+// migrated document
+func (s *SQLStore) PutRaw(ctx context.Context, key string, data []byte) error {
+	token, _, err := UnmarshalStored(data)
+	if err != nil {
+		return fmt.Errorf("%w: failed to unmarshal token: %v", ErrStorageFailure, err)
+	}
+	return saveToken(ctx, s.db, key, token)
+}
+
+// Close releases resources used by the store
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// filterSQL compiles a Filter to a WHERE clause
+func filterSQL(filter Filter, now time.Time) (string, []interface{}, error) {
+	var clauses []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if len(filter.Types) > 0 {
+		types := make([]string, len(filter.Types))
+		for i, t := range filter.Types {
+			types[i] = string(t)
+		}
+		clauses = append(clauses, "type = ANY("+arg(pq.Array(types))+")")
+	}
+	if filter.Subject != "" {
+		clauses = append(clauses, "subject = "+arg(filter.Subject))
+	}
+	if filter.Issuer != "" {
+		clauses = append(clauses, "issuer = "+arg(filter.Issuer))
+	}
+	if !filter.ExpiresAfter.IsZero() {
+		clauses = append(clauses, "expires_at >= "+arg(filter.ExpiresAfter))
+	}
+	if !filter.ExpiresBefore.IsZero() {
+		clauses = append(clauses, "expires_at <= "+arg(filter.ExpiresBefore))
+	}
+	if !filter.IssuedAfter.IsZero() {
+		clauses = append(clauses, "issued_at >= "+arg(filter.IssuedAfter))
+	}
+	if !filter.IssuedBefore.IsZero() {
+		clauses = append(clauses, "issued_at <= "+arg(filter.IssuedBefore))
+	}
+	if len(filter.Scopes) > 0 {
+		op := "&&"
+		if filter.RequireAllScopes {
+			op = "@>"
+		}
+		clauses = append(clauses, "scopes "+op+" "+arg(pq.Array(filter.Scopes)))
+	}
+	if filter.Active {
+		t := arg(now)
+		clauses = append(clauses, "expires_at > "+t+" AND (not_before IS NULL OR not_before < "+t+")")
+	}
+	keys := make([]string, 0, len(filter.Metadata))
+	for k := range filter.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		key, value := arg(k), arg(filter.Metadata[k])
+		clauses = append(clauses, fmt.Sprintf(
+			"COALESCE(metadata->'app_data'->>%[1]s, metadata->'labels'->>%[1]s) = %[2]s", key, value))
+	}
+	for _, p := range filter.MetadataPredicates {
+		field := fmt.Sprintf("COALESCE(metadata->'app_data'->>%[1]s, metadata->'labels'->>%[1]s)", arg(p.Key))
+		switch p.Op {
+		case MetadataEquals, "":
+			clauses = append(clauses, field+" = "+arg(p.Value))
+		case MetadataNotEquals:
+			clauses = append(clauses, field+" IS DISTINCT FROM "+arg(p.Value))
+		case MetadataPrefix:
+			value := arg(p.Value)
+			clauses = append(clauses, fmt.Sprintf("left(%s, char_length(%s)) = %s", field, value, value))
+		case MetadataExists:
+			clauses = append(clauses, field+" IS NOT NULL")
+		case MetadataMissing:
+			clauses = append(clauses, field+" IS NULL")
+		default:
+			return "", nil, fmt.Errorf("%w: unknown metadata operator %q", ErrInvalidFilter, p.Op)
+		}
+	}
+	if filter.Query != nil {
+		clause, queryArgs, err := filter.Query.SQL(DefaultSQLColumns, len(args)+1)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, queryArgs...)
+		clauses = append(clauses, clause)
+	}
+
+	if len(clauses) == 0 {
+		return "TRUE", nil, nil
+	}
+	return strings.Join(clauses, " AND "), args, nil
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
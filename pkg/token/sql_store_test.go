@@ -0,0 +1,60 @@
+package token
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilterSQL(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Empty Filter", func(t *testing.T) {
+		where, args, err := filterSQL(Filter{}, now)
+		if err != nil {
+			t.Fatalf("filterSQL() error: %v", err)
+		}
+		if where != "TRUE" || len(args) != 0 {
+			t.Errorf("Expected TRUE without args, got %q %v", where, args)
+		}
+	})
+
+	t.Run("Indexed Columns", func(t *testing.T) {
+		where, args, err := filterSQL(Filter{
+			Types:            []Type{Access, Refresh},
+			Subject:          "alice",
+			Issuer:           "gauth",
+			Scopes:           []string{"read", "write"},
+			RequireAllScopes: true,
+			Active:           true,
+		}, now)
+		if err != nil {
+			t.Fatalf("filterSQL() error: %v", err)
+		}
+		for _, want := range []string{
+			"type = ANY($1)", "subject = $2", "issuer = $3", "scopes @> $4",
+			"expires_at > $5 AND (not_before IS NULL OR not_before < $5)",
+		} {
+			if !strings.Contains(where, want) {
+				t.Errorf("Expected %q in %q", want, where)
+			}
+		}
+		if len(args) != 5 {
+			t.Errorf("Expected 5 args, got %d", len(args))
+		}
+	})
+
+	t.Run("Query Arguments Continue Numbering", func(t *testing.T) {
+		query, err := ParseQuery(`issuer = "gauth"`)
+		if err != nil {
+			t.Fatalf("ParseQuery() error: %v", err)
+		}
+		where, args, err := filterSQL(Filter{Subject: "alice", Query: query}, now)
+		if err != nil {
+			t.Fatalf("filterSQL() error: %v", err)
+		}
+		if !strings.Contains(where, "$2") || len(args) != 2 {
+			t.Errorf("Expected query arg as $2, got %q %v", where, args)
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrPrivilegeEscalation indicates a step-down request asked for more than
+// the parent token grants
+var ErrPrivilegeEscalation = errors.New("derived token exceeds parent privileges")
+
+// Metadata keys recording how a token was derived
+const (
+	// AppDataParentTokenID holds the ID of the token a derived token came from
+	AppDataParentTokenID = "parent_token_id"
+
+	// AppDataDerivation names the derivation that produced the token
+	AppDataDerivation = "derivation"
+
+	// AttributeDerivationChain lists ancestor token IDs, root first
+	AttributeDerivationChain = "derivation_chain"
+
+	// DerivationStepDown marks tokens produced by StepDown
+	DerivationStepDown = "step_down"
+
+	// DerivationRefresh marks access tokens produced by Service.Refresh
+	DerivationRefresh = "refresh"
+)
+
+// StepDownRequest describes the reduced token a holder wants to derive
+type StepDownRequest struct {
+	// Scopes must be a non-empty subset of the parent's scopes
+	Scopes []string
+
+	// TTL shortens the lifetime; the derived token never outlives the parent
+	// (0 = same expiry as the parent)
+	TTL time.Duration
+
+	// Audience narrows the audience; must be a subset of the parent's
+	// audience when the parent has one
+	Audience []string
+
+	// Subprocessor is recorded on the derived token for audit
+	Subprocessor string
+}
+
+// StepDown lets a token holder voluntarily derive a reduced-privilege token
+// from its current one, e.g. to hand to a subprocessor. The parent must be
+// valid; the derived access token carries a subset of its scopes, an equal
+// or earlier expiry, and records the derivation in its metadata. Only
+// presented.Value is trusted: scopes, expiry and metadata come from the stored
+// token it was issued as.
+func StepDown(ctx context.Context, svc ServiceAPI, presented *Token, req StepDownRequest) (*Token, error) {
+	if presented == nil {
+		return nil, NewValidationError(ValidationCodeInvalid, "parent token is required")
+	}
+	parent, err := verifiedParent(ctx, svc, presented)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parent token: %w", err)
+	}
+	if len(req.Scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrPrivilegeEscalation)
+	}
+	for _, scope := range req.Scopes {
+		if !parent.HasScope(scope) {
+			return nil, fmt.Errorf("%w: scope %q not held by parent", ErrPrivilegeEscalation, scope)
+		}
+	}
+	audience := parent.Audience
+	if len(req.Audience) > 0 {
+		if len(parent.Audience) > 0 {
+			for _, aud := range req.Audience {
+				if !containsString(parent.Audience, aud) {
+					return nil, fmt.Errorf("%w: audience %q not held by parent", ErrPrivilegeEscalation, aud)
+				}
+			}
+		}
+		audience = req.Audience
+	}
+
+	now := time.Now()
+	expiresAt := parent.ExpiresAt
+	if req.TTL > 0 && now.Add(req.TTL).Before(expiresAt) {
+		expiresAt = now.Add(req.TTL)
+	}
+
+	derived := &Token{
+		ID:        NewID(),
+		Type:      Access,
+		IssuedAt:  now,
+		NotBefore: now,
+		ExpiresAt: expiresAt,
+		Issuer:    parent.Issuer,
+		Subject:   parent.Subject,
+		Audience:  audience,
+		Scopes:    append([]string(nil), req.Scopes...),
+		Algorithm: parent.Algorithm,
+		Metadata:  derivedMetadata(parent, DerivationStepDown, req.Subprocessor),
+	}
+
+	issued, err := svc.Issue(ctx, derived)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue step-down token: %w", err)
+	}
+	return issued, nil
+}
+
+// verifiedParent validates a presented token and returns its stored copy,
+// so that fields edited on the presented struct are ignored
+func verifiedParent(ctx context.Context, svc ServiceAPI, presented *Token) (*Token, error) {
+	if err := svc.Validate(ctx, presented); err != nil {
+		return nil, err
+	}
+	signed, err := svc.Parse(ctx, presented.Value)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := svc.GetToken(ctx, signed.ID)
+	if err != nil {
+		return nil, NewValidationErrorWithCause(ValidationCodeNotFound, "parent token not stored", err)
+	}
+	if stored.Value != presented.Value {
+		return nil, NewValidationError(ValidationCodeInvalid, "token does not match stored value")
+	}
+	return stored, nil
+}
+
+// derivedMetadata records the parent and extends its derivation chain
+func derivedMetadata(parent *Token, derivation, subprocessor string) *Metadata {
+	meta := &Metadata{}
+	if parent.Metadata != nil {
+		meta.AppID = parent.Metadata.AppID
+	}
+	setLineage(meta, parent, derivation)
+	if subprocessor != "" {
+		meta.AppData["subprocessor"] = subprocessor
+	}
+	return meta
+}
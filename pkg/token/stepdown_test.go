@@ -0,0 +1,100 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStepDown(t *testing.T) {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	svc := NewService(Config{SigningKey: key, ValidityPeriod: time.Hour}, NewMemoryStore(time.Hour))
+
+	parent, err := svc.Issue(ctx, &Token{
+		ID:       NewID(),
+		Type:     Access,
+		Subject:  "client-1",
+		Audience: []string{"api", "billing"},
+		Scopes:   []string{"read", "write", "delete"},
+	})
+	if err != nil {
+		t.Fatalf("Issue() error: %v", err)
+	}
+
+	t.Run("Reduced Token", func(t *testing.T) {
+		child, err := StepDown(ctx, svc, parent, StepDownRequest{
+			Scopes:       []string{"read"},
+			TTL:          5 * time.Minute,
+			Audience:     []string{"billing"},
+			Subprocessor: "invoicer",
+		})
+		if err != nil {
+			t.Fatalf("StepDown() error: %v", err)
+		}
+		if len(child.Scopes) != 1 || child.Scopes[0] != "read" {
+			t.Errorf("Expected [read], got %v", child.Scopes)
+		}
+		if !child.ExpiresAt.Before(parent.ExpiresAt) {
+			t.Error("Expected derived token to expire before parent")
+		}
+		if child.Metadata.AppData[AppDataParentTokenID] != parent.ID {
+			t.Errorf("Expected parent ID recorded, got %v", child.Metadata.AppData)
+		}
+
+		grandchild, err := StepDown(ctx, svc, child, StepDownRequest{Scopes: []string{"read"}, TTL: 24 * time.Hour})
+		if err != nil {
+			t.Fatalf("StepDown() error: %v", err)
+		}
+		chain := grandchild.Metadata.Attributes[AttributeDerivationChain]
+		if len(chain) != 2 || chain[0] != parent.ID || chain[1] != child.ID {
+			t.Errorf("Unexpected derivation chain %v", chain)
+		}
+		if grandchild.ExpiresAt.After(child.ExpiresAt) {
+			t.Error("Expected derived token never to outlive its parent")
+		}
+	})
+
+	t.Run("Escalation Rejected", func(t *testing.T) {
+		if _, err := StepDown(ctx, svc, parent, StepDownRequest{Scopes: []string{"admin"}}); !errors.Is(err, ErrPrivilegeEscalation) {
+			t.Errorf("Expected ErrPrivilegeEscalation for scope, got %v", err)
+		}
+		if _, err := StepDown(ctx, svc, parent, StepDownRequest{Scopes: []string{"read"}, Audience: []string{"admin-api"}}); !errors.Is(err, ErrPrivilegeEscalation) {
+			t.Errorf("Expected ErrPrivilegeEscalation for audience, got %v", err)
+		}
+		if _, err := StepDown(ctx, svc, parent, StepDownRequest{}); !errors.Is(err, ErrPrivilegeEscalation) {
+			t.Errorf("Expected ErrPrivilegeEscalation for empty scopes, got %v", err)
+		}
+	})
+
+	t.Run("Tampered Parent", func(t *testing.T) {
+		low, err := svc.Issue(ctx, &Token{ID: NewID(), Type: Access, Subject: "client-2", Scopes: []string{"read"}})
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		tampered := *low
+		tampered.Scopes = []string{"read", "admin"}
+		tampered.ExpiresAt = time.Now().Add(1000 * time.Hour)
+		tampered.Metadata = &Metadata{AppID: "other-app"}
+
+		if _, err := StepDown(ctx, svc, &tampered, StepDownRequest{Scopes: []string{"admin"}}); !errors.Is(err, ErrPrivilegeEscalation) {
+			t.Errorf("Expected ErrPrivilegeEscalation for a scope edited into the struct, got %v", err)
+		}
+		child, err := StepDown(ctx, svc, &tampered, StepDownRequest{Scopes: []string{"read"}})
+		if err != nil {
+			t.Fatalf("StepDown() error: %v", err)
+		}
+		if child.ExpiresAt.After(low.ExpiresAt) {
+			t.Errorf("Expected the signed expiry %v, got %v", low.ExpiresAt, child.ExpiresAt)
+		}
+		if child.Metadata.AppID == "other-app" {
+			t.Error("Expected metadata from the stored parent")
+		}
+	})
+}
@@ -1,7 +1,11 @@
 package token
 
 import (
+	"context"
+	"fmt"
 	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/metrics"
 )
 
 // StoreOption is a function that configures a Store
@@ -27,6 +31,20 @@ func WithCleanup(interval time.Duration) StoreOption {
 	}
 }
 
+// ReportExpiryBacklog counts the expired tokens the store still holds and
+// records them as gauth_token_expiry_backlog under name. Run it on the
+// cleanup schedule: a backlog that keeps growing means cleanup has stalled.
+func ReportExpiryBacklog(ctx context.Context, name string, store Store, m *metrics.Collector) (int64, error) {
+	backlog, err := store.Count(ctx, Filter{ExpiresBefore: time.Now()})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count expired tokens: %w", err)
+	}
+	if m != nil {
+		m.SetTokenExpiryBacklog(name, backlog)
+	}
+	return backlog, nil
+}
+
 // WithCapacity sets the maximum number of tokens the store can hold
 func WithCapacity(n int) StoreOption {
 	return func(s Store) error {
@@ -37,6 +55,22 @@ func WithCapacity(n int) StoreOption {
 	}
 }
 
+// LegalHoldChecker reports whether a subject's records are under legal hold.
+// audit.HoldRegistry implements it.
+type LegalHoldChecker interface {
+	HoldsSubject(subject string) bool
+}
+
+// WithLegalHolds keeps tokens of held subjects through expiry cleanup
+func WithLegalHolds(holds LegalHoldChecker) StoreOption {
+	return func(s Store) error {
+		if configurable, ok := s.(interface{ SetLegalHolds(LegalHoldChecker) }); ok {
+			configurable.SetLegalHolds(holds)
+		}
+		return nil
+	}
+}
+
 // StoreConfig holds common store configuration
 type StoreConfig struct {
 	// Default TTL for tokens
@@ -14,6 +14,8 @@ import (
 	"context"
 	"crypto"
 	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
 )
 
 // Type represents the type of a token.
@@ -42,6 +44,8 @@ const (
 	HS256 Algorithm = "HS256"
 	// PS256 is RSA-PSS with SHA-256
 	PS256 Algorithm = "PS256"
+	// EdDSA is Ed25519
+	EdDSA Algorithm = "EdDSA"
 )
 
 // DeviceInfo contains information about the device using the token.
@@ -57,9 +61,10 @@ type DeviceInfo struct {
 // RevocationStatus contains information about token revocation.
 // RFC111: Required for transparent and auditable revocation.
 type RevocationStatus struct {
-	RevokedAt time.Time `json:"revoked_at"`           // When the token was revoked
-	Reason    string    `json:"reason"`               // Reason for revocation
-	RevokedBy string    `json:"revoked_by,omitempty"` // Who revoked the token
+	RevokedAt time.Time        `json:"revoked_at"`           // When the token was revoked
+	Reason    RevocationReason `json:"reason"`               // Why the token was revoked
+	Detail    string           `json:"detail,omitempty"`     // Free-text explanation
+	RevokedBy string           `json:"revoked_by,omitempty"` // Who revoked the token
 }
 
 // Metadata contains strongly-typed token metadata.
@@ -72,6 +77,7 @@ type Metadata struct {
 	Labels     map[string]string   `json:"labels,omitempty"`
 	Tags       []string            `json:"tags,omitempty"`
 	Attributes map[string][]string `json:"attributes,omitempty"`
+	Delegation *DelegationChain    `json:"delegation,omitempty"` // RFC111 multi-level power of attorney
 }
 
 // Token represents a security token with metadata.
@@ -193,6 +199,25 @@ type Filter struct {
 
 	// Metadata filters by token metadata matching all key-value pairs
 	Metadata map[string]string `json:"metadata"`
+
+	// MetadataPredicates are further metadata tests, all of which must hold
+	MetadataPredicates []MetadataPredicate `json:"metadata_predicates,omitempty"`
+
+	// Query is an additional expression the token must satisfy (see ParseQuery)
+	Query *QueryExpr `json:"-"`
+
+	// SortBy orders the results (default: unordered, or by ID when paging)
+	SortBy SortField `json:"sort_by,omitempty"`
+
+	// Descending reverses the sort order
+	Descending bool `json:"descending,omitempty"`
+
+	// Limit caps the number of results (0 = no limit)
+	Limit int `json:"limit,omitempty"`
+
+	// Cursor resumes a listing after the last token of a previous page (see
+	// ListPage)
+	Cursor string `json:"cursor,omitempty"`
 }
 
 // Config contains token configuration options
@@ -206,6 +231,11 @@ type Config struct {
 	// SigningKey is the key used to sign tokens
 	SigningKey crypto.Signer
 
+	// KeySet, when set, replaces SigningKey: tokens are signed with its
+	// active key and carry a kid header, so they verify against the
+	// published JWKS after the key is rotated
+	KeySet *KeySet
+
 	// ValidityPeriod is how long tokens are valid for
 	ValidityPeriod time.Duration
 
@@ -232,4 +262,36 @@ type Config struct {
 
 	// AllowedAudiences are the allowed token audiences
 	AllowedAudiences []string
+
+	// ClockSkew is the tolerance applied to exp and nbf checks
+	ClockSkew time.Duration
+
+	// ClockSkewByType overrides ClockSkew for specific token types
+	ClockSkewByType map[Type]time.Duration
+
+	// Grace optionally accepts just-expired access tokens for low-risk scopes
+	Grace *GracePolicy
+
+	// IssuanceChecks run in order before any token is signed and stored
+	IssuanceChecks *IssuancePipeline
+
+	// ClaimsEnrichers add computed claims after the issuance checks pass
+	ClaimsEnrichers *ClaimsEnrichment
+
+	// ClaimMinimization strips metadata claims the token's audiences do not
+	// need, after enrichment and before signing
+	ClaimMinimization *ClaimMinimization
+
+	// LegalHolds keeps held subjects' tokens through periodic cleanup
+	LegalHolds LegalHoldChecker
+
+	// Events receives a token_revoked event, with its reason, for every
+	// Revoke
+	Events events.EventHandler
+
+	// Mode selects production (default) or sandbox issuance and validation
+	Mode Mode
+
+	// Sandbox supplies the fake providers used in sandbox mode
+	Sandbox *SandboxConfig
 }
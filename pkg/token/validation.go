@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/scope"
 )
 
 // # Licensing
@@ -38,6 +40,12 @@ type ValidationConfig struct {
 	// ClockSkew allows for small time differences
 	ClockSkew time.Duration
 
+	// ClockSkewByType overrides ClockSkew for specific token types
+	ClockSkewByType map[Type]time.Duration
+
+	// Grace optionally accepts just-expired access tokens for low-risk scopes
+	Grace *GracePolicy
+
 	// ValidateSignature indicates if signature validation is required
 	ValidateSignature bool
 }
@@ -108,17 +116,25 @@ func (vc *ValidationChain) validateBlacklist(ctx context.Context, token *Token)
 }
 
 func (vc *ValidationChain) validateTimeClaims(token *Token, now time.Time) error {
-	if token.ExpiresAt.Add(vc.config.ClockSkew).Before(now) {
+	skew := clockSkewFor(token.Type, vc.config.ClockSkew, vc.config.ClockSkewByType)
+
+	if token.ExpiresAt.Add(skew).Before(now) && !vc.config.Grace.accepts(token, now, skew) {
 		return ErrTokenExpired
 	}
 
-	if token.NotBefore.Add(-vc.config.ClockSkew).After(now) {
+	if token.NotBefore.Add(-skew).After(now) {
 		return ErrTokenNotYetValid
 	}
 
 	return nil
 }
 
+// IsStale reports whether a token that passed this chain was only accepted
+// under its grace policy, applying the same clock skew as validation
+func (vc *ValidationChain) IsStale(token *Token) bool {
+	return token != nil && IsStale(token, clockSkewFor(token.Type, vc.config.ClockSkew, vc.config.ClockSkewByType))
+}
+
 func (vc *ValidationChain) validateIssuer(token *Token) error {
 	if len(vc.config.AllowedIssuers) == 0 {
 		return nil
@@ -180,14 +196,10 @@ func ValidateClaims(_ *Token, _ *ClaimRequirements) error {
 	return nil
 }
 
-// HasScope checks if the token has the given scope
-func (t *Token) HasScope(scope string) bool {
-	for _, s := range t.Scopes {
-		if s == scope {
-			return true
-		}
-	}
-	return false
+// HasScope checks if the token's scopes grant the given scope, directly or
+// through a wildcard such as payments:*
+func (t *Token) HasScope(want string) bool {
+	return scope.Allows(t.Scopes, want)
 }
 
 // Query represents a token search query
@@ -0,0 +1,340 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+)
+
+//go:generate go run ../../cmd/gauthctl vectors -out ../../test/vectors/extended_tokens.json
+
+// VectorSetVersion is the version of the test vector format
+const VectorSetVersion = 1
+
+// TestVector is a signed extended token together with the validation inputs
+// and the outcome every conforming validator must reach. Validators check,
+// in order: the kid header (invalid), the key lookup (unknown_key), the
+// algorithm and signature (invalid_signature), expiry and not-before with
+// the clock skew (expired, not_yet_valid), the issuer (invalid_issuer), the
+// audience (invalid_audience) and the required scopes (insufficient_scope).
+type TestVector struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	JWT         string `json:"jwt"`
+
+	// Now is the time the token is validated at
+	Now time.Time `json:"now"`
+	// ClockSkewSeconds is the tolerance applied to exp and nbf
+	ClockSkewSeconds int64    `json:"clock_skew_seconds,omitempty"`
+	AllowedIssuers   []string `json:"allowed_issuers,omitempty"`
+	AllowedAudiences []string `json:"allowed_audiences,omitempty"`
+	RequiredScopes   []string `json:"required_scopes,omitempty"`
+
+	Expect VectorExpectation `json:"expect"`
+}
+
+// VectorExpectation is the expected validation outcome of a vector
+type VectorExpectation struct {
+	Valid bool `json:"valid"`
+	// Code is the validation error code when Valid is false
+	Code ValidationErrorCode `json:"code,omitempty"`
+	// Claims are the decoded claims when Valid is true
+	Claims *VectorClaims `json:"claims,omitempty"`
+}
+
+// VectorClaims are the claims a validator must decode from a valid vector
+type VectorClaims struct {
+	ID       string    `json:"jti"`
+	Type     Type      `json:"typ"`
+	Subject  string    `json:"sub"`
+	Issuer   string    `json:"iss"`
+	Audience []string  `json:"aud"`
+	Scopes   []string  `json:"scp"`
+	Metadata *Metadata `json:"gauth,omitempty"`
+}
+
+// VectorSet is a published set of test vectors and the keys that verify them
+type VectorSet struct {
+	Version int          `json:"version"`
+	Keys    *JWKS        `json:"keys"`
+	Vectors []TestVector `json:"vectors"`
+}
+
+// VectorResult is the outcome of checking one vector
+type VectorResult struct {
+	Name     string              `json:"name"`
+	Expected ValidationErrorCode `json:"expected,omitempty"`
+	Got      ValidationErrorCode `json:"got,omitempty"`
+	Passed   bool                `json:"passed"`
+	Detail   string              `json:"detail,omitempty"`
+}
+
+// ValidationCodeUnknownKey indicates the kid header names no published key
+const ValidationCodeUnknownKey ValidationErrorCode = "unknown_key"
+
+// vectorEpoch is the reference time of the generated vectors
+var vectorEpoch = time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC)
+
+// vectorKey derives a fixed test key from a label so that regenerated
+// vectors keep their keys
+func vectorKey(alg Algorithm, label string) (crypto.Signer, error) {
+	seed := sha256.Sum256([]byte("gauth test vector key: " + label))
+	switch alg {
+	case EdDSA:
+		return ed25519.NewKeyFromSeed(seed[:]), nil
+	case ES256:
+		priv, err := ecdh.P256().NewPrivateKey(seed[:])
+		if err != nil {
+			return nil, err
+		}
+		pub := priv.PublicKey().Bytes()
+		return &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(pub[1:33]),
+				Y:     new(big.Int).SetBytes(pub[33:]),
+			},
+			D: new(big.Int).SetBytes(seed[:]),
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedKey, alg)
+	}
+}
+
+// GenerateVectors builds the extended token test vectors. EdDSA signatures
+// are deterministic; ES256 signatures differ between runs but verify the
+// same way.
+func GenerateVectors() (*VectorSet, error) {
+	keys := NewKeySet()
+	signers := make(map[Algorithm]*JWTSigner)
+	for _, alg := range []Algorithm{EdDSA, ES256} {
+		signer, err := vectorKey(alg, string(alg))
+		if err != nil {
+			return nil, err
+		}
+		kid, err := keys.Add(signer, alg, "")
+		if err != nil {
+			return nil, err
+		}
+		signers[alg] = NewJWTSigner(signer, alg).WithKeyID(kid)
+	}
+	edKid := signers[EdDSA].keyID
+	rogue, err := vectorKey(EdDSA, "not published")
+	if err != nil {
+		return nil, err
+	}
+
+	base := func() *Token {
+		return &Token{
+			ID:        "vector-token-1",
+			Type:      Access,
+			Issuer:    "https://gauth.example",
+			Subject:   "agent-42",
+			Audience:  []string{"https://api.example"},
+			Scopes:    []string{"payments:read", "payments:initiate"},
+			IssuedAt:  vectorEpoch,
+			NotBefore: vectorEpoch,
+			ExpiresAt: vectorEpoch.Add(time.Hour),
+			Metadata: &Metadata{
+				AppID:  "treasury-agent",
+				Labels: map[string]string{"principal": "acme-corp", "delegation_level": "1"},
+				Attributes: map[string][]string{
+					"power_of_attorney": {"payments<=10000EUR"},
+				},
+			},
+		}
+	}
+	claimsOf := func(t *Token) *VectorClaims {
+		return &VectorClaims{ID: t.ID, Type: t.Type, Subject: t.Subject, Issuer: t.Issuer,
+			Audience: t.Audience, Scopes: t.Scopes, Metadata: t.Metadata}
+	}
+	sign := func(s *JWTSigner, t *Token) string {
+		if err == nil {
+			var jwt string
+			jwt, err = s.SignToken(t)
+			return jwt
+		}
+		return ""
+	}
+	withHeader := func(jwt string, header map[string]string) string {
+		raw, _ := json.Marshal(header)
+		_, rest, _ := strings.Cut(jwt, ".")
+		return base64.RawURLEncoding.EncodeToString(raw) + "." + rest
+	}
+	valid := func(t *Token) VectorExpectation {
+		return VectorExpectation{Valid: true, Claims: claimsOf(t)}
+	}
+	invalid := func(code ValidationErrorCode) VectorExpectation {
+		return VectorExpectation{Code: code}
+	}
+
+	good := sign(signers[EdDSA], base())
+	es := sign(signers[ES256], base())
+	unsigned := sign(signers[EdDSA], base())
+	escalated := base()
+	escalated.Scopes = append(escalated.Scopes, "payments:approve")
+	tampered := sign(signers[EdDSA], escalated)
+	forged := sign(NewJWTSigner(rogue, EdDSA).WithKeyID(edKid), base())
+	unknown := sign(NewJWTSigner(rogue, EdDSA).WithKeyID("unpublished"), base())
+	noKid := sign(NewJWTSigner(rogue, EdDSA), base())
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign vector: %w", err)
+	}
+
+	goodParts := strings.Split(good, ".")
+	tamperedParts := strings.Split(tampered, ".")
+	unsignedParts := strings.Split(withHeader(unsigned, map[string]string{"alg": "none", "kid": edKid, "typ": "JWT"}), ".")
+
+	within := vectorEpoch.Add(30 * time.Minute)
+	vectors := []TestVector{
+		{Name: "valid-eddsa", Description: "EdDSA-signed extended token carrying RFC111 metadata",
+			JWT: good, Now: within, Expect: valid(base())},
+		{Name: "valid-es256", Description: "ES256-signed extended token carrying RFC111 metadata",
+			JWT: es, Now: within, Expect: valid(base())},
+		{Name: "valid-with-constraints", Description: "Issuer, audience and scope requirements are met",
+			JWT: good, Now: within, AllowedIssuers: []string{"https://gauth.example"},
+			AllowedAudiences: []string{"https://api.example"}, RequiredScopes: []string{"payments:initiate"},
+			Expect: valid(base())},
+		{Name: "expired", Description: "Validated one minute after exp",
+			JWT: good, Now: vectorEpoch.Add(61 * time.Minute), Expect: invalid(ValidationCodeExpired)},
+		{Name: "expired-within-skew", Description: "Validated 30s after exp with 60s clock skew",
+			JWT: good, Now: vectorEpoch.Add(time.Hour + 30*time.Second), ClockSkewSeconds: 60, Expect: valid(base())},
+		{Name: "not-yet-valid", Description: "Validated two minutes before nbf",
+			JWT: good, Now: vectorEpoch.Add(-2 * time.Minute), Expect: invalid(ValidationCodeNotYetValid)},
+		{Name: "tampered-payload", Description: "Payload of an escalated token spliced onto a valid signature",
+			JWT: goodParts[0] + "." + tamperedParts[1] + "." + goodParts[2], Now: within,
+			Expect: invalid(ValidationCodeInvalidSignature)},
+		{Name: "forged-signature", Description: "Signed by an unpublished key under a published kid",
+			JWT: forged, Now: within, Expect: invalid(ValidationCodeInvalidSignature)},
+		{Name: "alg-none", Description: "Signature stripped and alg set to none",
+			JWT: unsignedParts[0] + "." + unsignedParts[1] + ".", Now: within,
+			Expect: invalid(ValidationCodeInvalidSignature)},
+		{Name: "alg-mismatch", Description: "ES256 signature presented under the EdDSA key's kid",
+			JWT: withHeader(es, map[string]string{"alg": "ES256", "kid": edKid, "typ": "JWT"}), Now: within,
+			Expect: invalid(ValidationCodeInvalidSignature)},
+		{Name: "unknown-kid", Description: "kid header names no published key",
+			JWT: unknown, Now: within, Expect: invalid(ValidationCodeUnknownKey)},
+		{Name: "missing-kid", Description: "Header carries no kid",
+			JWT: noKid, Now: within, Expect: invalid(ValidationCodeInvalid)},
+		{Name: "wrong-issuer", Description: "Issuer is not in the allowed list",
+			JWT: good, Now: within, AllowedIssuers: []string{"https://other.example"},
+			Expect: invalid(ValidationCodeInvalidIssuer)},
+		{Name: "wrong-audience", Description: "Audience is not in the allowed list",
+			JWT: good, Now: within, AllowedAudiences: []string{"https://other.example"},
+			Expect: invalid(ValidationCodeInvalidAudience)},
+		{Name: "insufficient-scope", Description: "A required scope was not granted",
+			JWT: good, Now: within, RequiredScopes: []string{"payments:approve"},
+			Expect: invalid(ValidationCodeInsufficientScope)},
+	}
+	return &VectorSet{Version: VectorSetVersion, Keys: keys.JWKS(), Vectors: vectors}, nil
+}
+
+// VerifyVector validates the vector's token with the given keys and returns
+// the resulting code, empty when the token is valid, and the decoded token
+func VerifyVector(keys *KeySet, v *TestVector) (ValidationErrorCode, *Token) {
+	if _, err := headerKeyID(v.JWT); err != nil {
+		return ValidationCodeInvalid, nil
+	}
+	t, err := keys.Verify(v.JWT)
+	if errors.Is(err, ErrKeyNotFound) {
+		return ValidationCodeUnknownKey, nil
+	}
+	if err != nil {
+		return ValidationCodeInvalidSignature, nil
+	}
+
+	skew := time.Duration(v.ClockSkewSeconds) * time.Second
+	if v.Now.After(t.ExpiresAt.Add(skew)) {
+		return ValidationCodeExpired, t
+	}
+	if v.Now.Before(t.NotBefore.Add(-skew)) {
+		return ValidationCodeNotYetValid, t
+	}
+	if len(v.AllowedIssuers) > 0 && !containsString(v.AllowedIssuers, t.Issuer) {
+		return ValidationCodeInvalidIssuer, t
+	}
+	if len(v.AllowedAudiences) > 0 && !intersects(v.AllowedAudiences, t.Audience) {
+		return ValidationCodeInvalidAudience, t
+	}
+	for _, scope := range v.RequiredScopes {
+		if !t.HasScope(scope) {
+			return ValidationCodeInsufficientScope, t
+		}
+	}
+	return "", t
+}
+
+// Verify checks every vector against its expectation
+func (vs *VectorSet) Verify() ([]VectorResult, error) {
+	if vs.Version != VectorSetVersion {
+		return nil, fmt.Errorf("%w: vector set version %d, want %d", ErrInvalidConfig, vs.Version, VectorSetVersion)
+	}
+	if vs.Keys == nil {
+		return nil, fmt.Errorf("%w: vector set has no keys", ErrInvalidConfig)
+	}
+	keys, err := vs.Keys.KeySet()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load vector keys: %w", err)
+	}
+
+	results := make([]VectorResult, 0, len(vs.Vectors))
+	for i := range vs.Vectors {
+		v := &vs.Vectors[i]
+		got, t := VerifyVector(keys, v)
+		r := VectorResult{Name: v.Name, Expected: v.Expect.Code, Got: got}
+		switch {
+		case v.Expect.Valid != (got == ""):
+			r.Detail = "validity differs"
+		case !v.Expect.Valid && got != v.Expect.Code:
+			r.Detail = "error code differs"
+		case v.Expect.Valid && v.Expect.Claims != nil && !sameVectorClaims(v.Expect.Claims, t):
+			r.Detail = "decoded claims differ"
+		default:
+			r.Passed = true
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// WriteJSON writes the vector set as indented JSON
+func (vs *VectorSet) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(vs)
+}
+
+func sameVectorClaims(want *VectorClaims, t *Token) bool {
+	got := &VectorClaims{ID: t.ID, Type: t.Type, Subject: t.Subject, Issuer: t.Issuer,
+		Audience: t.Audience, Scopes: t.Scopes, Metadata: t.Metadata}
+	if want.Metadata == nil {
+		got.Metadata = nil
+	}
+	a, _ := json.Marshal(want)
+	b, _ := json.Marshal(got)
+	var x, y interface{}
+	_ = json.Unmarshal(a, &x)
+	_ = json.Unmarshal(b, &y)
+	return reflect.DeepEqual(x, y)
+}
+
+func intersects(a, b []string) bool {
+	for _, v := range b {
+		if containsString(a, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package token
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestVectors(t *testing.T) {
+	check := func(t *testing.T, set *VectorSet) {
+		t.Helper()
+		results, err := set.Verify()
+		if err != nil {
+			t.Fatalf("Verify() error: %v", err)
+		}
+		if len(results) == 0 {
+			t.Fatal("Expected vectors")
+		}
+		for _, r := range results {
+			if !r.Passed {
+				t.Errorf("%s: expected %q, got %q (%s)", r.Name, r.Expected, r.Got, r.Detail)
+			}
+		}
+	}
+
+	t.Run("Generated", func(t *testing.T) {
+		set, err := GenerateVectors()
+		if err != nil {
+			t.Fatalf("GenerateVectors() error: %v", err)
+		}
+		check(t, set)
+	})
+
+	t.Run("Published", func(t *testing.T) {
+		data, err := os.ReadFile("../../test/vectors/extended_tokens.json")
+		if err != nil {
+			t.Fatalf("ReadFile() error: %v", err)
+		}
+		var set VectorSet
+		if err := json.Unmarshal(data, &set); err != nil {
+			t.Fatalf("Unmarshal() error: %v", err)
+		}
+		check(t, &set)
+	})
+
+	t.Run("Mismatch Reported", func(t *testing.T) {
+		set, _ := GenerateVectors()
+		set.Vectors[0].Expect = VectorExpectation{Code: ValidationCodeExpired}
+		results, _ := set.Verify()
+		if results[0].Passed {
+			t.Error("Expected altered expectation to fail")
+		}
+	})
+}
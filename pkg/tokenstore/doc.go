@@ -1,5 +1,8 @@
 // Package tokenstore provides pluggable token storage backends for GAuth.
 //
-// Implementations in this package can be used to persist and manage tokens
-// in-memory, on disk, or in external systems.
+// The backends live in pkg/token; this package re-exports them under their
+// storage-oriented names so existing importers keep compiling. In the v2
+// module storage is part of github.com/Gimel-Foundation/gauth/v2/token.
+//
+// Deprecated: use the stores in github.com/Gimel-Foundation/gauth/pkg/token.
 package tokenstore
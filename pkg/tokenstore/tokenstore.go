@@ -0,0 +1,93 @@
+package tokenstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/deprecation"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// Store is the token storage interface
+type Store = token.Store
+
+// Filter selects tokens in List and Count
+type Filter = token.Filter
+
+// BatchStore applies many writes in one round trip
+type BatchStore = token.BatchStore
+
+// MemoryStore keeps tokens in process memory
+type MemoryStore = token.MemoryStore
+
+// ShardedMemoryStore spreads in-memory tokens over independently locked shards
+type ShardedMemoryStore = token.ShardedMemoryStore
+
+// ShardedMemoryConfig configures a ShardedMemoryStore
+type ShardedMemoryConfig = token.ShardedMemoryConfig
+
+// SQLStore keeps tokens in PostgreSQL
+type SQLStore = token.SQLStore
+
+// SQLStoreConfig configures an SQLStore
+type SQLStoreConfig = token.SQLStoreConfig
+
+// RedisStore keeps tokens in Redis
+type RedisStore = token.RedisStore
+
+// RedisConfig configures a RedisStore
+type RedisConfig = token.RedisConfig
+
+// FailoverStore replicates tokens across stores with failover
+type FailoverStore = token.FailoverStore
+
+// FailoverConfig configures a FailoverStore
+type FailoverConfig = token.FailoverConfig
+
+// DeprecationID identifies this package in the deprecation registry
+const DeprecationID = "pkg/tokenstore"
+
+func init() {
+	deprecation.Register(deprecation.Notice{
+		ID:          DeprecationID,
+		Kind:        deprecation.KindPackage,
+		Replacement: "github.com/Gimel-Foundation/gauth/pkg/token or github.com/Gimel-Foundation/gauth/v2/token",
+	})
+}
+
+// NewMemoryStore creates an in-memory store
+func NewMemoryStore(ttl ...time.Duration) *MemoryStore {
+	deprecation.Use(DeprecationID)
+	return token.NewMemoryStore(ttl...)
+}
+
+// NewShardedMemoryStore creates a sharded in-memory store
+func NewShardedMemoryStore(config ShardedMemoryConfig) *ShardedMemoryStore {
+	deprecation.Use(DeprecationID)
+	return token.NewShardedMemoryStore(config)
+}
+
+// NewSQLStore creates a PostgreSQL store
+func NewSQLStore(config SQLStoreConfig) (*SQLStore, error) {
+	deprecation.Use(DeprecationID)
+	return token.NewSQLStore(config)
+}
+
+// NewSQLStoreFromDB creates a PostgreSQL store on an open database
+func NewSQLStoreFromDB(ctx context.Context, db *sql.DB) (*SQLStore, error) {
+	deprecation.Use(DeprecationID)
+	return token.NewSQLStoreFromDB(ctx, db)
+}
+
+// NewRedisStore creates a Redis store
+func NewRedisStore(config RedisConfig) (*RedisStore, error) {
+	deprecation.Use(DeprecationID)
+	return token.NewRedisStore(config)
+}
+
+// NewFailoverStore creates a replicating store with failover
+func NewFailoverStore(config FailoverConfig) (*FailoverStore, error) {
+	deprecation.Use(DeprecationID)
+	return token.NewFailoverStore(config)
+}
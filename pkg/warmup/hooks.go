@@ -0,0 +1,68 @@
+package warmup
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// PolicyHook loads all policies. Authorizers with a Prime method (such as
+// authz.BudgetedAuthorizer) keep the loaded set for later use.
+func PolicyHook(a authz.Authorizer) Hook {
+	return Hook{
+		Name:     "policies",
+		Critical: true,
+		Run: func(ctx context.Context) error {
+			if primer, ok := a.(interface{ Prime(context.Context) error }); ok {
+				return primer.Prime(ctx)
+			}
+			if _, err := a.ListPolicies(ctx); err != nil {
+				return fmt.Errorf("failed to load policies: %w", err)
+			}
+			return nil
+		},
+	}
+}
+
+// RolesHook looks up the role assignments of frequently seen subjects
+func RolesHook(a interface {
+	GetRoles(ctx context.Context, subject authz.Subject) ([]authz.Role, error)
+}, subjects []authz.Subject) Hook {
+	return Hook{
+		Name: "roles",
+		Run: func(ctx context.Context) error {
+			for _, s := range subjects {
+				if _, err := a.GetRoles(ctx, s); err != nil {
+					return fmt.Errorf("failed to load roles for %s: %w", s.ID, err)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// RevocationHook fills a revocation set from a durable source so revoked
+// tokens are rejected from the first request
+func RevocationHook(set *token.RevocationSet, load func(ctx context.Context) ([]token.RevocationEntry, error)) Hook {
+	return Hook{
+		Name:     "revocations",
+		Critical: true,
+		Run: func(ctx context.Context) error {
+			entries, err := load(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to load revocations: %w", err)
+			}
+			if _, err := set.Merge(ctx, entries); err != nil {
+				return fmt.Errorf("failed to load revocations: %w", err)
+			}
+			return nil
+		},
+	}
+}
+
+// KeysHook fetches signing or verification keys, e.g. a remote JWKS
+func KeysHook(fetch func(ctx context.Context) error) Hook {
+	return Hook{Name: "keys", Critical: true, Run: fetch}
+}
@@ -0,0 +1,211 @@
+// Package warmup primes caches before a server reports ready. Hooks preload
+// hot policies, signing keys, role assignments and revocation state so the
+// first requests after a deploy do not pay for cold caches.
+package warmup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// Warm-up errors
+var (
+	// ErrDuplicateHook indicates a hook with the same name is registered
+	ErrDuplicateHook = errors.New("warm-up hook already registered")
+
+	// ErrPrimingFailed indicates a critical hook failed
+	ErrPrimingFailed = errors.New("warm-up priming failed")
+)
+
+// Hook preloads one cache
+type Hook struct {
+	// Name identifies the hook in reports
+	Name string
+
+	// Critical hooks must succeed before the server reports ready
+	Critical bool
+
+	// Timeout bounds the hook (default: Config.HookTimeout)
+	Timeout time.Duration
+
+	// Run performs the preload
+	Run func(ctx context.Context) error
+}
+
+// Config configures a Primer
+type Config struct {
+	// HookTimeout bounds each hook without its own timeout (default: 30s)
+	HookTimeout time.Duration
+
+	// Events receives a system_startup event when priming completes
+	Events events.EventHandler
+
+	// ConfigFingerprint identifies the effective configuration (see
+	// doctor.Fingerprint); it is included in reports and the startup event
+	ConfigFingerprint string
+}
+
+// HookResult is the outcome of one hook
+type HookResult struct {
+	Name     string        `json:"name"`
+	Critical bool          `json:"critical"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Report summarises a priming run
+type Report struct {
+	StartedAt         time.Time     `json:"started_at"`
+	Duration          time.Duration `json:"duration"`
+	Ready             bool          `json:"ready"`
+	ConfigFingerprint string        `json:"config_fingerprint,omitempty"`
+	Hooks             []HookResult  `json:"hooks"`
+}
+
+// Primer runs warm-up hooks and tracks readiness
+type Primer struct {
+	config Config
+	mu     sync.RWMutex
+	hooks  []Hook
+	ready  bool
+	last   *Report
+}
+
+// NewPrimer creates a primer
+func NewPrimer(config Config) *Primer {
+	if config.HookTimeout <= 0 {
+		config.HookTimeout = 30 * time.Second
+	}
+	return &Primer{config: config}
+}
+
+// Register adds a hook
+func (p *Primer) Register(hook Hook) error {
+	if hook.Name == "" || hook.Run == nil {
+		return errors.New("hook needs a name and a run function")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, h := range p.hooks {
+		if h.Name == hook.Name {
+			return fmt.Errorf("%w: %s", ErrDuplicateHook, hook.Name)
+		}
+	}
+	p.hooks = append(p.hooks, hook)
+	return nil
+}
+
+// Prime runs all hooks concurrently. The primer becomes ready when every
+// critical hook succeeds; failures of other hooks are only reported.
+func (p *Primer) Prime(ctx context.Context) (*Report, error) {
+	p.mu.RLock()
+	hooks := append([]Hook(nil), p.hooks...)
+	p.mu.RUnlock()
+
+	report := &Report{StartedAt: time.Now(), ConfigFingerprint: p.config.ConfigFingerprint, Hooks: make([]HookResult, len(hooks))}
+	var wg sync.WaitGroup
+	for i, hook := range hooks {
+		wg.Add(1)
+		go func(i int, hook Hook) {
+			defer wg.Done()
+			report.Hooks[i] = p.run(ctx, hook)
+		}(i, hook)
+	}
+	wg.Wait()
+	report.Duration = time.Since(report.StartedAt)
+	sort.SliceStable(report.Hooks, func(i, j int) bool { return report.Hooks[i].Name < report.Hooks[j].Name })
+
+	var failed []string
+	for _, r := range report.Hooks {
+		if r.Critical && r.Error != "" {
+			failed = append(failed, r.Name)
+		}
+	}
+	report.Ready = len(failed) == 0
+
+	p.mu.Lock()
+	p.last = report
+	p.ready = p.ready || report.Ready
+	p.mu.Unlock()
+
+	p.emit(ctx, report, failed)
+	if !report.Ready {
+		return report, fmt.Errorf("%w: %v", ErrPrimingFailed, failed)
+	}
+	return report, nil
+}
+
+func (p *Primer) run(ctx context.Context, hook Hook) HookResult {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = p.config.HookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	result := HookResult{Name: hook.Name, Critical: hook.Critical}
+	if err := hook.Run(ctx); err != nil {
+		result.Error = err.Error()
+	}
+	result.Duration = time.Since(start)
+	return result
+}
+
+func (p *Primer) emit(ctx context.Context, report *Report, failed []string) {
+	if p.config.Events == nil {
+		return
+	}
+	status := events.StatusSuccess
+	message := fmt.Sprintf("warm-up completed in %s", report.Duration)
+	if !report.Ready {
+		status = events.StatusFailure
+		message = fmt.Sprintf("warm-up failed: %v", failed)
+	}
+	event := events.NewSystemEvent(events.ActionSystemStartup, status).
+		WithContext(ctx).
+		WithMessage(message).
+		WithStringMetadata("hooks", fmt.Sprint(len(report.Hooks)))
+	if report.ConfigFingerprint != "" {
+		event = event.WithStringMetadata("config_fingerprint", report.ConfigFingerprint)
+	}
+	p.config.Events.Handle(event)
+}
+
+// Ready reports whether a priming run has succeeded
+func (p *Primer) Ready() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.ready
+}
+
+// LastReport returns the most recent priming report
+func (p *Primer) LastReport() *Report {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.last
+}
+
+// ReadyHandler serves readiness: 200 once primed, 503 before, with the last
+// report as JSON
+func (p *Primer) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !p.Ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		report := p.LastReport()
+		if report == nil {
+			report = &Report{ConfigFingerprint: p.config.ConfigFingerprint}
+		}
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
@@ -0,0 +1,101 @@
+package warmup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/authz"
+	"github.com/Gimel-Foundation/gauth/pkg/events"
+	"github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+type eventRecorder []events.Event
+
+func (r *eventRecorder) Handle(e events.Event) { *r = append(*r, e) }
+
+func TestPrimer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Ready After Priming", func(t *testing.T) {
+		p := NewPrimer(Config{})
+		a := authz.NewMemoryAuthorizer()
+		set := token.NewRevocationSet("node-1")
+		if err := p.Register(PolicyHook(a)); err != nil {
+			t.Fatalf("Register() error: %v", err)
+		}
+		if err := p.Register(RevocationHook(set, func(context.Context) ([]token.RevocationEntry, error) {
+			return []token.RevocationEntry{{TokenID: "tok-1", Origin: "node-2", Seq: 1}}, nil
+		})); err != nil {
+			t.Fatalf("Register() error: %v", err)
+		}
+		if err := p.Register(PolicyHook(a)); !errors.Is(err, ErrDuplicateHook) {
+			t.Errorf("Expected ErrDuplicateHook, got %v", err)
+		}
+
+		rec := httptest.NewRecorder()
+		p.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected 503 before priming, got %d", rec.Code)
+		}
+
+		if _, err := p.Prime(ctx); err != nil {
+			t.Fatalf("Prime() error: %v", err)
+		}
+		if !set.IsRevoked("tok-1") {
+			t.Error("Expected revocation set to be primed")
+		}
+		rec = httptest.NewRecorder()
+		p.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected 200 after priming, got %d", rec.Code)
+		}
+	})
+
+	t.Run("Critical Failure", func(t *testing.T) {
+		p := NewPrimer(Config{})
+		_ = p.Register(Hook{Name: "optional", Run: func(context.Context) error { return errors.New("cold") }})
+		_ = p.Register(Hook{Name: "jwks", Critical: true, Timeout: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}})
+
+		report, err := p.Prime(ctx)
+		if !errors.Is(err, ErrPrimingFailed) {
+			t.Fatalf("Expected ErrPrimingFailed, got %v", err)
+		}
+		if p.Ready() || report.Ready {
+			t.Error("Expected primer not to be ready")
+		}
+		if len(report.Hooks) != 2 || report.Hooks[0].Name != "jwks" || report.Hooks[0].Error == "" {
+			t.Errorf("Unexpected report %+v", report.Hooks)
+		}
+	})
+
+	t.Run("Config Fingerprint", func(t *testing.T) {
+		var recorded eventRecorder
+		p := NewPrimer(Config{Events: &recorded, ConfigFingerprint: "sha256:abc"})
+		rec := httptest.NewRecorder()
+		p.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+		if !strings.Contains(rec.Body.String(), `"config_fingerprint":"sha256:abc"`) {
+			t.Errorf("Expected the fingerprint before priming, got %s", rec.Body)
+		}
+		report, err := p.Prime(ctx)
+		if err != nil {
+			t.Fatalf("Prime() error: %v", err)
+		}
+		if report.ConfigFingerprint != "sha256:abc" {
+			t.Errorf("Expected the fingerprint in the report, got %q", report.ConfigFingerprint)
+		}
+		if len(recorded) != 1 {
+			t.Fatalf("Expected one startup event, got %d", len(recorded))
+		}
+		if fp, _ := recorded[0].Metadata.GetString("config_fingerprint"); fp != "sha256:abc" {
+			t.Errorf("Expected the fingerprint in the startup event, got %q", fp)
+		}
+	})
+}
@@ -87,11 +87,18 @@ func BenchmarkRateLimiting(b *testing.B) {
 // BenchmarkDistributedRateLimiting benchmarks distributed rate limiting
 func BenchmarkDistributedRateLimiting(b *testing.B) {
 	ctx := context.Background()
-	limiter, _ := rate.NewDistributedLimiter(rate.Config{
+	limiter, err := rate.NewDistributedLimiter(rate.Config{
 		Rate:      100,
 		Window:    time.Second,
 		BurstSize: 10,
+		DistributedConfig: &rate.RedisConfig{
+			Addresses: []string{"localhost:6379"},
+		},
 	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer limiter.Close()
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
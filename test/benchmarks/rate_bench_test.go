@@ -0,0 +1,120 @@
+package benchmarks
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/Gimel-Foundation/gauth/pkg/rate"
+)
+
+// rateAlgorithms are the in-memory limiters compared by the benchmarks
+var rateAlgorithms = []struct {
+	name string
+	new  func(cfg rate.Config) rate.Limiter
+}{
+	{"TokenBucket", func(cfg rate.Config) rate.Limiter { return rate.NewTokenBucket(cfg) }},
+	{"SlidingWindow", func(cfg rate.Config) rate.Limiter { return rate.NewSlidingWindow(cfg) }},
+	{"FixedWindow", func(cfg rate.Config) rate.Limiter { return rate.NewFixedWindow(cfg) }},
+	{"LeakyBucket", func(cfg rate.Config) rate.Limiter { return rate.NewLeakyBucket(cfg) }},
+}
+
+// BenchmarkRateAlgorithms benchmarks Allow across many keys
+func BenchmarkRateAlgorithms(b *testing.B) {
+	ctx := context.Background()
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("client-%d", i)
+	}
+
+	for _, alg := range rateAlgorithms {
+		b.Run(alg.name, func(b *testing.B) {
+			limiter := alg.new(rate.Config{Rate: 100, Window: time.Second, BurstSize: 100})
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = limiter.Allow(ctx, keys[i%len(keys)])
+			}
+		})
+	}
+}
+
+// BenchmarkRateMemory reports the heap each algorithm retains per key after
+// requestsPerKey requests. Sliding window grows with the requests in the
+// window; the others keep constant state.
+func BenchmarkRateMemory(b *testing.B) {
+	ctx := context.Background()
+	const requestsPerKey = 50
+
+	for _, alg := range rateAlgorithms {
+		b.Run(alg.name, func(b *testing.B) {
+			keys := make([]string, b.N)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("client-%d", i)
+			}
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+
+			b.ResetTimer()
+			limiter := alg.new(rate.Config{Rate: 100, Window: time.Minute, BurstSize: 100})
+			for _, key := range keys {
+				for j := 0; j < requestsPerKey; j++ {
+					_ = limiter.Allow(ctx, key)
+				}
+			}
+			b.StopTimer()
+
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+			b.ReportMetric(float64(after.HeapAlloc-min(before.HeapAlloc, after.HeapAlloc))/float64(b.N), "B/key")
+			runtime.KeepAlive(limiter)
+		})
+	}
+}
+
+// BenchmarkRateAccuracy reports how far each algorithm lets a client exceed
+// its limit. A client starts at a random point in time and sends as fast as
+// it can for three windows; peak/limit is the most requests admitted within
+// any one window divided by the limit, 1.0 being exact. Fixed window admits
+// up to twice the limit around a boundary, and leaky bucket its burst on
+// top of the rate.
+func BenchmarkRateAccuracy(b *testing.B) {
+	ctx := context.Background()
+	const limit = 20
+	window := 20 * time.Millisecond
+
+	for _, alg := range rateAlgorithms {
+		b.Run(alg.name, func(b *testing.B) {
+			var peaks float64
+			for i := 0; i < b.N; i++ {
+				limiter := alg.new(rate.Config{Rate: limit, Window: window, BurstSize: limit})
+				time.Sleep(time.Duration(rand.Int63n(int64(window))))
+
+				var admitted []time.Time
+				for end := time.Now().Add(3 * window); time.Now().Before(end); {
+					if limiter.Allow(ctx, "client") == nil {
+						admitted = append(admitted, time.Now())
+					}
+				}
+				peaks += float64(peakInWindow(admitted, window)) / limit
+			}
+			b.ReportMetric(peaks/float64(b.N), "peak/limit")
+		})
+	}
+}
+
+// peakInWindow returns the most timestamps within any span of one window
+func peakInWindow(times []time.Time, window time.Duration) int {
+	peak := 0
+	for lo, hi := 0, 0; hi < len(times); hi++ {
+		for times[hi].Sub(times[lo]) >= window {
+			lo++
+		}
+		peak = max(peak, hi-lo+1)
+	}
+	return peak
+}
@@ -2,10 +2,16 @@ package integration
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/Gimel-Foundation/gauth/pkg/rate"
 )
@@ -37,6 +43,27 @@ func TestRateLimiterIntegration(t *testing.T) {
 				Window: time.Second,
 			},
 		},
+		{
+			name: "FixedWindow",
+			setup: func(cfg rate.Config) rate.Limiter {
+				return rate.NewFixedWindow(cfg)
+			},
+			cfg: rate.Config{
+				Rate:   10,
+				Window: time.Minute,
+			},
+		},
+		{
+			name: "LeakyBucket",
+			setup: func(cfg rate.Config) rate.Limiter {
+				return rate.NewLeakyBucket(cfg)
+			},
+			cfg: rate.Config{
+				Rate:      10,
+				Window:    time.Second,
+				BurstSize: 3,
+			},
+		},
 	}
 
 	for _, tc := range configs {
@@ -65,3 +92,356 @@ func TestRateLimiterIntegration(t *testing.T) {
 		})
 	}
 }
+
+func TestDistributedRateLimiter(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+
+	newLimiter := func(t *testing.T, algorithm rate.DistributedAlgorithm, onFallback func(error)) *rate.DistributedLimiter {
+		limiter, err := rate.NewDistributedLimiter(rate.Config{
+			Rate:      3,
+			Window:    200 * time.Millisecond,
+			BurstSize: 3,
+			DistributedConfig: &rate.RedisConfig{
+				Addresses:     []string{mr.Addr()},
+				KeyPrefix:     "test:" + string(algorithm) + ":",
+				Algorithm:     algorithm,
+				RetryInterval: 50 * time.Millisecond,
+				OnFallback:    onFallback,
+			},
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = limiter.Close() })
+		return limiter
+	}
+
+	for _, algorithm := range []rate.DistributedAlgorithm{rate.DistributedSlidingWindow, rate.DistributedTokenBucket} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			// two instances share one limit
+			a, b := newLimiter(t, algorithm, nil), newLimiter(t, algorithm, nil)
+			assert.NoError(t, a.Allow(ctx, "client"))
+			assert.NoError(t, b.Allow(ctx, "client"))
+			assert.NoError(t, a.Allow(ctx, "client"))
+			assert.ErrorIs(t, b.Allow(ctx, "client"), rate.ErrRateLimitExceeded)
+			assert.Equal(t, int64(0), a.GetRemainingRequests("client"))
+			assert.NoError(t, a.Allow(ctx, "other"))
+
+			time.Sleep(250 * time.Millisecond)
+			assert.NoError(t, b.Allow(ctx, "client"))
+
+			a.Reset("client")
+			assert.Equal(t, int64(3), b.GetRemainingRequests("client"))
+		})
+	}
+
+	t.Run("Local Fallback", func(t *testing.T) {
+		var failures int
+		limiter := newLimiter(t, rate.DistributedSlidingWindow, func(error) { failures++ })
+		require.NoError(t, limiter.Allow(ctx, "fallback"))
+
+		mr.Close()
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, limiter.Allow(ctx, "fallback"))
+		}
+		assert.ErrorIs(t, limiter.Allow(ctx, "fallback"), rate.ErrRateLimitExceeded)
+		assert.False(t, limiter.Available())
+		assert.Equal(t, 1, failures)
+
+		require.NoError(t, mr.Restart())
+		time.Sleep(60 * time.Millisecond)
+		assert.NoError(t, limiter.Allow(ctx, "fallback"))
+		assert.True(t, limiter.Available())
+	})
+
+	t.Run("Fail Closed", func(t *testing.T) {
+		_, err := rate.NewDistributedLimiter(rate.Config{Rate: 1})
+		assert.ErrorIs(t, err, rate.ErrInvalidConfig)
+
+		limiter, err := rate.NewDistributedLimiter(rate.Config{
+			Rate: 1,
+			DistributedConfig: &rate.RedisConfig{
+				Addresses:       []string{"127.0.0.1:1"},
+				DisableFallback: true,
+			},
+		})
+		require.NoError(t, err)
+		err = limiter.Allow(ctx, "x")
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, rate.ErrRateLimitExceeded)
+	})
+}
+
+func TestFixedWindowRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	window := 100 * time.Millisecond
+	limiter := rate.NewFixedWindow(rate.Config{Rate: 2, Window: window})
+
+	// start just after a boundary so the window cannot end mid-test
+	time.Sleep(time.Until(time.Now().Truncate(window).Add(window)))
+	quota := limiter.Quota("client")
+	require.NoError(t, limiter.Allow(ctx, "client"))
+	require.NoError(t, limiter.Allow(ctx, "client"))
+	assert.ErrorIs(t, limiter.Allow(ctx, "client"), rate.ErrRateLimitExceeded)
+	assert.Equal(t, int64(0), limiter.GetRemainingRequests("client"))
+
+	// windows are aligned, so the counter resets at the quoted time
+	assert.Equal(t, quota.ResetAt, quota.ResetAt.Truncate(window))
+	time.Sleep(time.Until(quota.ResetAt) + 5*time.Millisecond)
+	assert.Equal(t, int64(2), limiter.GetRemainingRequests("client"))
+	assert.NoError(t, limiter.Allow(ctx, "client"))
+
+	limiter.Reset("client")
+	assert.Equal(t, int64(2), limiter.Quota("client").Remaining)
+}
+
+func TestLeakyBucketRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	limiter := rate.NewLeakyBucket(rate.Config{Rate: 20, Window: time.Second, BurstSize: 2})
+
+	require.NoError(t, limiter.Allow(ctx, "client"))
+	require.NoError(t, limiter.Allow(ctx, "client"))
+	assert.ErrorIs(t, limiter.Allow(ctx, "client"), rate.ErrRateLimitExceeded)
+
+	// one request leaks out every 50ms
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, int64(1), limiter.GetRemainingRequests("client"))
+	assert.NoError(t, limiter.Allow(ctx, "client"))
+	assert.ErrorIs(t, limiter.Allow(ctx, "client"), rate.ErrRateLimitExceeded)
+
+	t.Run("Wait Shapes Bursts", func(t *testing.T) {
+		start := time.Now()
+		for i := 0; i < 2; i++ {
+			require.NoError(t, limiter.Wait(ctx, "shaped"))
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 45*time.Millisecond)
+
+		// a cancelled wait keeps its place in the bucket
+		limiter.Reset("shaped")
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		require.NoError(t, limiter.Wait(cancelled, "shaped"))
+		assert.ErrorIs(t, limiter.Wait(cancelled, "shaped"), context.Canceled)
+		assert.ErrorIs(t, limiter.Wait(ctx, "shaped"), rate.ErrRateLimitExceeded)
+	})
+}
+
+func TestHierarchicalRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	newLimiter := func(t *testing.T) *rate.Hierarchical {
+		limiter, err := rate.NewHierarchical(rate.HierarchicalConfig{
+			Global: rate.NewFixedWindow(rate.Config{Rate: 5, Window: time.Minute}),
+			Client: rate.NewFixedWindow(rate.Config{Rate: 3, Window: time.Minute}),
+			Token:  rate.NewFixedWindow(rate.Config{Rate: 2, Window: time.Minute}),
+		})
+		require.NoError(t, err)
+		return limiter
+	}
+	levelOf := func(err error) rate.Level {
+		var le *rate.LimitError
+		if errors.As(err, &le) {
+			return le.Level
+		}
+		return ""
+	}
+
+	t.Run("Levels", func(t *testing.T) {
+		limiter := newLimiter(t)
+		tokenA := rate.HierarchicalKey("acme", "token-a")
+		require.NoError(t, limiter.Allow(ctx, tokenA))
+		require.NoError(t, limiter.Allow(ctx, tokenA))
+		err := limiter.Allow(ctx, tokenA)
+		assert.ErrorIs(t, err, rate.ErrRateLimitExceeded)
+		assert.Equal(t, rate.LevelToken, levelOf(err))
+
+		// the rejected token did not use up the client quota
+		tokenB := rate.HierarchicalKey("acme", "token-b")
+		require.NoError(t, limiter.Allow(ctx, tokenB))
+		assert.Equal(t, rate.LevelClient, levelOf(limiter.Allow(ctx, tokenB)))
+
+		require.NoError(t, limiter.Allow(ctx, "globex"))
+		require.NoError(t, limiter.Allow(ctx, rate.HierarchicalKey("globex", "token-a")))
+		err = limiter.Allow(ctx, "initech")
+		assert.Equal(t, rate.LevelGlobal, levelOf(err))
+
+		var le *rate.LimitError
+		require.ErrorAs(t, err, &le)
+		assert.Equal(t, int64(5), le.Quota.Limit)
+		assert.Greater(t, le.RetryAfter, time.Duration(0))
+		assert.LessOrEqual(t, le.RetryAfter, time.Minute)
+	})
+
+	t.Run("Remaining And Reset", func(t *testing.T) {
+		limiter := newLimiter(t)
+		key := rate.HierarchicalKey("acme", "token-a")
+		require.NoError(t, limiter.Allow(ctx, key))
+		assert.Equal(t, int64(1), limiter.GetRemainingRequests(key))
+		assert.Equal(t, int64(2), limiter.Quota("acme").Remaining)
+
+		limiter.Reset(key)
+		assert.Equal(t, int64(2), limiter.GetRemainingRequests(key))
+		// the global level is shared and keeps its count
+		assert.Equal(t, int64(4), limiter.Quota("").Remaining)
+	})
+
+	t.Run("Middleware", func(t *testing.T) {
+		handler := rate.Middleware(rate.HTTPLimiterConfig{
+			Limiter: newLimiter(t),
+			KeyFunc: rate.HierarchicalKeyFunc(func(r *http.Request) string { return r.Header.Get("X-Client-ID") }, rate.BearerTokenID),
+			Headers: true,
+		})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+
+		var rec *httptest.ResponseRecorder
+		for i := 0; i < 3; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-Client-ID", "acme")
+			req.Header.Set("Authorization", "Bearer secret")
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+		}
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "token", rec.Header().Get("X-RateLimit-Level"))
+		assert.Equal(t, "2", rec.Header().Get("X-RateLimit-Limit"))
+		assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+	})
+
+	_, err := rate.NewHierarchical(rate.HierarchicalConfig{})
+	assert.ErrorIs(t, err, rate.ErrInvalidConfig)
+}
+
+func TestAIMDRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	var adjustments [][2]int64
+	limiter, err := rate.NewAIMD(rate.AIMDConfig{
+		InitialLimit:     10,
+		MinLimit:         2,
+		MaxLimit:         12,
+		Window:           time.Minute,
+		Interval:         10 * time.Millisecond,
+		MinSamples:       4,
+		LatencyThreshold: 50 * time.Millisecond,
+		OnAdjust: func(_ string, oldLimit, newLimit int64) {
+			adjustments = append(adjustments, [2]int64{oldLimit, newLimit})
+		},
+	})
+	require.NoError(t, err)
+
+	interval := func(key string, err error, latency time.Duration) {
+		time.Sleep(15 * time.Millisecond)
+		for i := 0; i < 4; i++ {
+			limiter.Observe(key, err, latency)
+		}
+	}
+	failure := errors.New("upstream unavailable")
+
+	// intervals of a key start with its first request
+	require.NoError(t, limiter.Allow(ctx, "client"))
+	limiter.Reset("client")
+
+	interval("client", failure, time.Millisecond) // errors: 10 -> 5
+	interval("client", nil, time.Millisecond)     // healthy: 5 -> 6
+	interval("client", nil, 100*time.Millisecond) // slow: 6 -> 3
+	interval("client", failure, time.Millisecond) // floor: 3 -> 2
+	assert.Equal(t, [][2]int64{{10, 5}, {5, 6}, {6, 3}, {3, 2}}, adjustments)
+
+	require.NoError(t, limiter.Allow(ctx, "client"))
+	require.NoError(t, limiter.Allow(ctx, "client"))
+	assert.ErrorIs(t, limiter.Allow(ctx, "client"), rate.ErrRateLimitExceeded)
+	assert.Equal(t, int64(2), limiter.Quota("client").Limit)
+
+	// a key's limit grows up to the ceiling only
+	limiter.Quota("other")
+	for i := 0; i < 3; i++ {
+		interval("other", nil, time.Millisecond)
+	}
+	assert.Equal(t, int64(12), limiter.CurrentLimit("other"))
+
+	t.Run("Middleware", func(t *testing.T) {
+		handler := rate.Middleware(rate.HTTPLimiterConfig{
+			Limiter:  limiter,
+			Observer: limiter,
+		})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+
+		limiter.Quota("ip:192.0.2.1")
+		time.Sleep(15 * time.Millisecond)
+		for i := 0; i < 4; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		}
+		assert.Equal(t, int64(5), limiter.CurrentLimit("ip:192.0.2.1"))
+	})
+
+	_, err = rate.NewAIMD(rate.AIMDConfig{InitialLimit: 10, DecreaseFactor: 1.5})
+	assert.ErrorIs(t, err, rate.ErrInvalidConfig)
+}
+
+func TestRateLimiterStatePersistence(t *testing.T) {
+	ctx := context.Background()
+	cfg := rate.Config{Rate: 3, Window: time.Minute, BurstSize: 3}
+	newAIMD := func() rate.StatefulLimiter {
+		limiter, err := rate.NewAIMD(rate.AIMDConfig{InitialLimit: 3, MaxLimit: 10, Window: time.Minute})
+		require.NoError(t, err)
+		return limiter
+	}
+	newHierarchical := func() rate.StatefulLimiter {
+		limiter, err := rate.NewHierarchical(rate.HierarchicalConfig{
+			Global: rate.NewSlidingWindow(rate.Config{Rate: 10, Window: time.Minute}),
+			Client: rate.NewFixedWindow(cfg),
+		})
+		require.NoError(t, err)
+		return limiter
+	}
+
+	limiters := []struct {
+		name string
+		new  func() rate.StatefulLimiter
+	}{
+		{"TokenBucket", func() rate.StatefulLimiter { return rate.NewTokenBucket(cfg) }},
+		{"SlidingWindow", func() rate.StatefulLimiter { return rate.NewSlidingWindow(cfg) }},
+		{"FixedWindow", func() rate.StatefulLimiter { return rate.NewFixedWindow(cfg) }},
+		{"LeakyBucket", func() rate.StatefulLimiter { return rate.NewLeakyBucket(cfg) }},
+		{"AIMD", newAIMD},
+		{"Hierarchical", newHierarchical},
+	}
+
+	store, err := rate.NewFileStateStore(t.TempDir())
+	require.NoError(t, err)
+
+	for _, tc := range limiters {
+		t.Run(tc.name, func(t *testing.T) {
+			// the old process uses up the quota and saves on shutdown
+			old := tc.new()
+			for i := 0; i < 3; i++ {
+				require.NoError(t, old.Allow(ctx, "client"))
+			}
+			p, err := rate.NewPersister(rate.PersisterConfig{Name: tc.name, Limiter: old, Store: store})
+			require.NoError(t, err)
+			require.NoError(t, p.Save(ctx))
+
+			// the new process resumes without a fresh burst
+			resumed := tc.new()
+			p, err = rate.NewPersister(rate.PersisterConfig{Name: tc.name, Limiter: resumed, Store: store})
+			require.NoError(t, err)
+			require.NoError(t, p.Restore(ctx))
+			assert.ErrorIs(t, resumed.Allow(ctx, "client"), rate.ErrRateLimitExceeded)
+			assert.NoError(t, resumed.Allow(ctx, "other"))
+		})
+	}
+
+	t.Run("AIMD Keeps Learned Limits", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		store := rate.NewRedisStateStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "", time.Hour)
+
+		old := newAIMD()
+		old.ImportState(map[string]rate.KeyState{"client": {Limit: 7}, "capped": {Limit: 50}})
+		require.NoError(t, store.SaveState(ctx, "aimd", old.ExportState()))
+
+		state, err := store.LoadState(ctx, "aimd")
+		require.NoError(t, err)
+		resumed := newAIMD().(*rate.AIMD)
+		resumed.ImportState(state)
+		assert.Equal(t, int64(7), resumed.CurrentLimit("client"))
+		assert.Equal(t, int64(10), resumed.CurrentLimit("capped"))
+	})
+}
@@ -3,12 +3,21 @@ package integration
 import (
 	"context"
 	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	gauthErrors "github.com/Gimel-Foundation/gauth/pkg/errors"
+	"github.com/Gimel-Foundation/gauth/pkg/metrics"
 	"github.com/Gimel-Foundation/gauth/pkg/resilience"
 )
 
@@ -72,3 +81,535 @@ func TestResilienceIntegration(t *testing.T) {
 		})
 	})
 }
+
+func TestCircuitBreakerFailureRate(t *testing.T) {
+	ctx := context.Background()
+	failure := stderrors.New("test failure")
+	call := func(cb *resilience.CircuitBreaker, err error) error {
+		return cb.Execute(ctx, func(_ context.Context) error { return err })
+	}
+
+	t.Run("OpensOnErrorRate", func(t *testing.T) {
+		cb := resilience.NewCircuitBreaker(resilience.CircuitConfig{
+			FailureRateThreshold: 0.5,
+			FailureRateWindow:    time.Second,
+			MinRequests:          6,
+			Timeout:              time.Minute,
+		})
+
+		// interleaved failures never run consecutively, but the rate trips
+		for i := 0; i < 5; i++ {
+			err := failure
+			if i%2 == 1 {
+				err = nil
+			}
+			_ = call(cb, err)
+		}
+		rate, calls := cb.FailureRate()
+		assert.Equal(t, 5, calls)
+		assert.InDelta(t, 0.6, rate, 0.001)
+		assert.Equal(t, resilience.StateClosed, cb.State(), "below MinRequests")
+
+		_ = call(cb, nil)
+		assert.Equal(t, resilience.StateOpen, cb.State())
+		assert.ErrorIs(t, call(cb, nil), resilience.ErrCircuitOpen)
+	})
+
+	t.Run("WindowSlides", func(t *testing.T) {
+		cb := resilience.NewCircuitBreaker(resilience.CircuitConfig{
+			FailureRateThreshold: 0.5,
+			FailureRateWindow:    100 * time.Millisecond,
+			MinRequests:          4,
+		})
+		for i := 0; i < 3; i++ {
+			_ = call(cb, failure)
+		}
+		time.Sleep(120 * time.Millisecond)
+
+		// the old failures have left the window
+		_ = call(cb, failure)
+		for i := 0; i < 3; i++ {
+			_ = call(cb, nil)
+		}
+		assert.Equal(t, resilience.StateClosed, cb.State())
+		_, calls := cb.FailureRate()
+		assert.Equal(t, 4, calls)
+	})
+
+	t.Run("HalfOpenProbeBudget", func(t *testing.T) {
+		var transitions []resilience.CircuitState
+		cb := resilience.NewCircuitBreaker(resilience.CircuitConfig{
+			MaxFailures:    1,
+			Timeout:        20 * time.Millisecond,
+			HalfOpenProbes: 2,
+			OnStateChange: func(_, to resilience.CircuitState) {
+				transitions = append(transitions, to)
+			},
+		})
+		_ = call(cb, failure)
+		time.Sleep(30 * time.Millisecond)
+
+		// two probes in flight use up the budget
+		release := make(chan struct{})
+		started := make(chan struct{}, 2)
+		done := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				done <- cb.Execute(ctx, func(_ context.Context) error {
+					started <- struct{}{}
+					<-release
+					return nil
+				})
+			}()
+		}
+		<-started
+		<-started
+		assert.ErrorIs(t, call(cb, nil), resilience.ErrCircuitOpen)
+		close(release)
+		assert.NoError(t, <-done)
+		assert.NoError(t, <-done)
+		assert.Equal(t, resilience.StateClosed, cb.State())
+
+		// a failed probe reopens the circuit for a full Timeout
+		_ = call(cb, failure)
+		time.Sleep(30 * time.Millisecond)
+		assert.ErrorIs(t, call(cb, failure), failure)
+		assert.ErrorIs(t, call(cb, nil), resilience.ErrCircuitOpen)
+		assert.Equal(t, []resilience.CircuitState{
+			resilience.StateOpen, resilience.StateHalfOpen, resilience.StateClosed,
+			resilience.StateOpen, resilience.StateHalfOpen, resilience.StateOpen,
+		}, transitions)
+	})
+
+	t.Run("Classification", func(t *testing.T) {
+		cb := resilience.NewCircuitBreaker(resilience.CircuitConfig{MaxFailures: 2, Timeout: 20 * time.Millisecond})
+		for i := 0; i < 5; i++ {
+			_ = call(cb, context.Canceled)
+		}
+		assert.Equal(t, resilience.StateClosed, cb.State(), "cancellations are not failures")
+		_, calls := cb.FailureRate()
+		assert.Zero(t, calls)
+
+		// an ignored probe frees its slot instead of deciding
+		_ = call(cb, failure)
+		_ = call(cb, failure)
+		time.Sleep(30 * time.Millisecond)
+		_ = call(cb, context.Canceled)
+		assert.Equal(t, resilience.StateHalfOpen, cb.State())
+		assert.NoError(t, call(cb, nil))
+		assert.Equal(t, resilience.StateClosed, cb.State())
+
+		notFound := stderrors.New("not found")
+		custom := resilience.NewCircuitBreaker(resilience.CircuitConfig{
+			MaxFailures: 1,
+			Classify: func(err error) resilience.Outcome {
+				if stderrors.Is(err, notFound) {
+					return resilience.OutcomeSuccess
+				}
+				return resilience.DefaultClassify(err)
+			},
+		})
+		_ = call(custom, notFound)
+		assert.Equal(t, resilience.StateClosed, custom.State())
+	})
+
+	t.Run("Zero Thresholds", func(t *testing.T) {
+		cb := resilience.NewCircuitBreaker(resilience.CircuitConfig{Timeout: time.Minute})
+		for i := 0; i < 4; i++ {
+			_ = call(cb, failure)
+		}
+		assert.Equal(t, resilience.StateClosed, cb.State())
+		_ = call(cb, failure)
+		assert.Equal(t, resilience.StateOpen, cb.State(), "a breaker without thresholds falls back to 5 failures")
+	})
+}
+
+func TestExecuteT(t *testing.T) {
+	ctx := context.Background()
+	failure := stderrors.New("test failure")
+
+	t.Run("CircuitBreaker", func(t *testing.T) {
+		cb := resilience.NewCircuitBreaker(resilience.CircuitConfig{MaxFailures: 1, Timeout: time.Minute})
+		n, err := resilience.ExecuteT(ctx, cb, func(_ context.Context) (int, error) { return 42, nil })
+		assert.NoError(t, err)
+		assert.Equal(t, 42, n)
+
+		n, err = resilience.ExecuteT(ctx, cb, func(_ context.Context) (int, error) { return 7, failure })
+		assert.ErrorIs(t, err, failure)
+		assert.Zero(t, n)
+		_, err = resilience.ExecuteT(ctx, cb, func(_ context.Context) (int, error) { return 1, nil })
+		assert.ErrorIs(t, err, resilience.ErrCircuitOpen)
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		retry := resilience.NewRetry(resilience.RetryConfig{MaxAttempts: 3, InitialDelay: time.Millisecond})
+		attempts := 0
+		s, err := resilience.ExecuteT(ctx, retry, func(_ context.Context) (string, error) {
+			attempts++
+			if attempts < 3 {
+				return "partial", failure
+			}
+			return "attempt 3", nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "attempt 3", s)
+
+		permanent := stderrors.New("permanent")
+		retry = resilience.NewRetry(resilience.RetryConfig{
+			MaxAttempts:  5,
+			InitialDelay: time.Millisecond,
+			Retryable:    func(err error) bool { return !stderrors.Is(err, permanent) },
+		})
+		attempts = 0
+		_, err = resilience.ExecuteT(ctx, retry, func(_ context.Context) (string, error) {
+			attempts++
+			return "", permanent
+		})
+		assert.ErrorIs(t, err, permanent)
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		timeout := resilience.NewTimeout(resilience.TimeoutConfig{Timeout: 20 * time.Millisecond})
+		v, err := resilience.ExecuteT(ctx, timeout, func(_ context.Context) ([]byte, error) {
+			time.Sleep(50 * time.Millisecond) // ignores its context
+			return []byte("late"), nil
+		})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Nil(t, v)
+	})
+
+	t.Run("Bulkhead", func(t *testing.T) {
+		bulkhead := resilience.NewBulkhead(resilience.BulkheadConfig{MaxConcurrent: 1})
+		release := make(chan struct{})
+		started := make(chan struct{})
+		go func() {
+			_, _ = resilience.ExecuteT(ctx, bulkhead, func(_ context.Context) (int, error) {
+				close(started)
+				<-release
+				return 1, nil
+			})
+		}()
+		<-started
+		_, err := resilience.ExecuteT(ctx, bulkhead, func(_ context.Context) (int, error) { return 2, nil })
+		assert.ErrorIs(t, err, resilience.ErrBulkheadFull)
+		close(release)
+	})
+
+	t.Run("Combined", func(t *testing.T) {
+		cb := resilience.NewCircuitBreaker(resilience.CircuitConfig{MaxFailures: 5, Timeout: time.Minute})
+		pipeline := resilience.Combine(
+			cb,
+			resilience.NewRetry(resilience.RetryConfig{MaxAttempts: 3, InitialDelay: time.Millisecond}),
+			resilience.NewTimeout(resilience.TimeoutConfig{Timeout: 20 * time.Millisecond}),
+			resilience.NewBulkhead(resilience.BulkheadConfig{MaxConcurrent: 2}),
+		)
+
+		// the first attempt times out, the retry succeeds
+		attempts := 0
+		v, err := resilience.ExecuteT(ctx, pipeline, func(ctx context.Context) (string, error) {
+			attempts++
+			if attempts == 1 {
+				<-ctx.Done()
+				return "", ctx.Err()
+			}
+			return "ok", nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", v)
+		assert.Equal(t, 2, attempts)
+		assert.Equal(t, resilience.StateClosed, cb.State())
+
+		patterns := resilience.NewPatterns("typed", resilience.WithBulkhead(1))
+		n, err := resilience.ExecuteT(ctx, patterns.Executor(), func(_ context.Context) (int, error) { return 5, nil })
+		assert.NoError(t, err)
+		assert.Equal(t, 5, n)
+	})
+}
+
+func TestFallback(t *testing.T) {
+	ctx := context.Background()
+	metrics.RegisterMetrics()
+
+	type claims struct {
+		Subject string
+		Cached  bool
+	}
+	introspectionDown := stderrors.New("introspection endpoint unavailable")
+	remoteUp := true
+	var fallbacks []string
+
+	breaker := resilience.NewCircuitBreaker(resilience.CircuitConfig{MaxFailures: 2, Timeout: time.Minute})
+	chain, err := resilience.NewFallback(resilience.FallbackConfig{
+		Name:       "token-validation",
+		Metrics:    metrics.NewCollector(),
+		OnFallback: func(stage string, _ error) { fallbacks = append(fallbacks, stage) },
+	},
+		resilience.Stage[claims]{
+			Name:     "remote-introspection",
+			Executor: breaker,
+			Run: func(_ context.Context) (claims, error) {
+				if !remoteUp {
+					return claims{}, introspectionDown
+				}
+				return claims{Subject: "alice"}, nil
+			},
+		},
+		resilience.Stage[claims]{
+			Name: "local-cache",
+			Run: func(_ context.Context) (claims, error) {
+				return claims{Subject: "alice", Cached: true}, nil
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	c, stage, err := chain.Execute(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "remote-introspection", stage)
+	assert.False(t, c.Cached)
+
+	// the remote fails, then its circuit opens; the cache serves both
+	remoteUp = false
+	for i := 0; i < 3; i++ {
+		c, stage, err = chain.Execute(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "local-cache", stage)
+		assert.True(t, c.Cached)
+	}
+	assert.Equal(t, resilience.StateOpen, breaker.State())
+	assert.Equal(t, []string{"remote-introspection", "remote-introspection", "remote-introspection"}, fallbacks)
+	assert.Equal(t, map[string]uint64{"remote-introspection": 1, "local-cache": 3}, chain.Served())
+	assert.Equal(t, 3.0, fallbackCount(t, "token-validation", "local-cache", "served"))
+	assert.Equal(t, 3.0, fallbackCount(t, "token-validation", "remote-introspection", "failed"))
+
+	t.Run("Exhausted", func(t *testing.T) {
+		cacheMiss := stderrors.New("token not cached")
+		chain, err := resilience.NewFallback(resilience.FallbackConfig{},
+			resilience.Stage[string]{Name: "remote", Run: func(_ context.Context) (string, error) { return "", introspectionDown }},
+			resilience.Stage[string]{Name: "cache", Run: func(_ context.Context) (string, error) { return "", cacheMiss }},
+		)
+		require.NoError(t, err)
+		_, _, err = chain.Execute(ctx)
+		assert.ErrorIs(t, err, resilience.ErrFallbackExhausted)
+		assert.ErrorIs(t, err, introspectionDown)
+		assert.ErrorIs(t, err, cacheMiss)
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		calls := 0
+		chain, err := resilience.NewFallback(resilience.FallbackConfig{},
+			resilience.Stage[string]{Name: "remote", Run: func(_ context.Context) (string, error) { return "", context.Canceled }},
+			resilience.Stage[string]{Name: "cache", Run: func(_ context.Context) (string, error) { calls++; return "", nil }},
+		)
+		require.NoError(t, err)
+		_, stage, err := chain.Execute(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, "remote", stage)
+		assert.Zero(t, calls)
+	})
+
+	_, err = resilience.NewFallback[string](resilience.FallbackConfig{})
+	assert.Error(t, err)
+}
+
+// fallbackCount reads gauth_resilience_fallback_total for one stage result
+func fallbackCount(t *testing.T, chain, stage, result string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, mf := range families {
+		if mf.GetName() != "gauth_resilience_fallback_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["chain"] == chain && labels["stage"] == stage && labels["result"] == result {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func TestAdaptiveBulkhead(t *testing.T) {
+	ctx := context.Background()
+
+	// backend simulates a service that holds capacity calls at a time:
+	// beyond that, latency grows with concurrency
+	backend := func(capacity int64) func(context.Context) error {
+		var active atomic.Int64
+		return func(_ context.Context) error {
+			n := active.Add(1)
+			defer active.Add(-1)
+			time.Sleep(2 * time.Millisecond * time.Duration(max(1, n/capacity)))
+			return nil
+		}
+	}
+	load := func(b *resilience.AdaptiveBulkhead, call func(context.Context) error, workers int, d time.Duration) {
+		var wg sync.WaitGroup
+		stop := time.Now().Add(d)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for time.Now().Before(stop) {
+					if err := b.Execute(ctx, call); stderrors.Is(err, resilience.ErrBulkheadFull) {
+						time.Sleep(time.Millisecond)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	config := resilience.AdaptiveBulkheadConfig{
+		InitialLimit: 4,
+		MaxLimit:     64,
+		Window:       10 * time.Millisecond,
+		MinSamples:   5,
+		Smoothing:    0.5,
+	}
+
+	t.Run("GrowsWhileLatencyHolds", func(t *testing.T) {
+		b, err := resilience.NewAdaptiveBulkhead(config)
+		require.NoError(t, err)
+		load(b, backend(1000), 48, 600*time.Millisecond)
+		assert.Greater(t, b.Limit(), 24)
+	})
+
+	t.Run("ShrinksUnderQueueing", func(t *testing.T) {
+		b, err := resilience.NewAdaptiveBulkhead(config)
+		require.NoError(t, err)
+		load(b, backend(4), 48, 600*time.Millisecond)
+		assert.Less(t, b.Limit(), 24)
+		assert.GreaterOrEqual(t, b.Limit(), 1)
+	})
+
+	t.Run("WaitsForSlots", func(t *testing.T) {
+		b, err := resilience.NewAdaptiveBulkhead(resilience.AdaptiveBulkheadConfig{
+			InitialLimit: 1,
+			MaxLimit:     1,
+			MaxWaitTime:  time.Second,
+		})
+		require.NoError(t, err)
+		release := make(chan struct{})
+		started := make(chan struct{})
+		go func() {
+			_ = b.Execute(ctx, func(_ context.Context) error {
+				close(started)
+				<-release
+				return nil
+			})
+		}()
+		<-started
+
+		cancelled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, b.Execute(cancelled, func(_ context.Context) error { return nil }), context.DeadlineExceeded)
+
+		done := make(chan error)
+		go func() { done <- b.Execute(ctx, func(_ context.Context) error { return nil }) }()
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		assert.NoError(t, <-done)
+		assert.Equal(t, 0, b.InFlight())
+	})
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	ctx := context.Background()
+
+	// tokenEndpoint throttles the first requests with the given headers
+	tokenEndpoint := func(throttled int, headers http.Header) (*httptest.Server, *atomic.Int32) {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if int(calls.Add(1)) <= throttled {
+				for k, v := range headers {
+					w.Header()[k] = v
+				}
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(srv.Close)
+		return srv, &calls
+	}
+	call := func(url string) func(context.Context) error {
+		return func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+			if err != nil {
+				return err
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("token request failed: %w", gauthErrors.NewHTTPError(resp, nil))
+			}
+			return nil
+		}
+	}
+
+	t.Run("WaitsForRetryAfter", func(t *testing.T) {
+		srv, calls := tokenEndpoint(1, http.Header{"Retry-After": {"1"}})
+		retry := resilience.NewRetry(resilience.RetryConfig{InitialDelay: time.Millisecond})
+
+		start := time.Now()
+		require.NoError(t, retry.Execute(ctx, call(srv.URL)))
+		assert.GreaterOrEqual(t, time.Since(start), time.Second)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("WaitsForRateLimitReset", func(t *testing.T) {
+		srv, calls := tokenEndpoint(1, http.Header{"Ratelimit-Reset": {"1"}})
+		retry := resilience.NewRetry(resilience.RetryConfig{InitialDelay: time.Millisecond})
+
+		start := time.Now()
+		require.NoError(t, retry.Execute(ctx, call(srv.URL)))
+		assert.GreaterOrEqual(t, time.Since(start), time.Second)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("GivesUpOverCap", func(t *testing.T) {
+		srv, calls := tokenEndpoint(5, http.Header{"Retry-After": {"120"}})
+		retry := resilience.NewRetry(resilience.RetryConfig{
+			InitialDelay:  time.Millisecond,
+			MaxRetryAfter: 30 * time.Second,
+		})
+
+		start := time.Now()
+		err := retry.Execute(ctx, call(srv.URL))
+		var authErr *gauthErrors.Error
+		require.ErrorAs(t, err, &authErr)
+		assert.Equal(t, 2*time.Minute, authErr.RetryAfter)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("CustomHint", func(t *testing.T) {
+		errThrottled := stderrors.New("throttled")
+		attempts := 0
+		retry := resilience.NewRetry(resilience.RetryConfig{
+			InitialDelay: time.Millisecond,
+			RetryAfter: func(err error) (time.Duration, bool) {
+				return 50 * time.Millisecond, stderrors.Is(err, errThrottled)
+			},
+		})
+
+		start := time.Now()
+		err := retry.Execute(ctx, func(_ context.Context) error {
+			if attempts++; attempts < 3 {
+				return errThrottled
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+}
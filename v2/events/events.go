@@ -0,0 +1,74 @@
+// Package events is the v2 event system. Handlers take a context and return
+// an error; the v1 context-less EventHandler is gone. Event types, actions,
+// statuses and metadata are shared with v1, so events flow unchanged between
+// v1 and v2 code during the migration.
+package events
+
+import (
+	"context"
+	"time"
+
+	v1 "github.com/Gimel-Foundation/gauth/pkg/events"
+)
+
+// Event is a system event with strongly typed fields
+type Event = v1.Event
+
+// EventType categorizes events
+type EventType = v1.EventType
+
+// Metadata holds typed event metadata
+type Metadata = v1.Metadata
+
+// Handler handles an event under the publisher's context
+type Handler = v1.ContextHandler
+
+// HandlerFunc adapts a function to Handler
+type HandlerFunc = v1.ContextHandlerFunc
+
+// Event constructors
+var (
+	NewEvent    = v1.NewEvent
+	NewMetadata = v1.NewMetadata
+)
+
+// FromV1 wraps a v1 EventHandler as a Handler
+func FromV1(h v1.EventHandler) Handler {
+	return v1.AdaptHandler(h)
+}
+
+// ToV1 wraps a Handler for v1 APIs that still take an EventHandler
+func ToV1(h Handler) v1.EventHandler {
+	return v1.LegacyHandler(h)
+}
+
+// WithTimeout bounds every call of h to d
+func WithTimeout(h Handler, d time.Duration) Handler {
+	return v1.TimeoutHandler(h, d)
+}
+
+// Bus delivers events to subscribed handlers
+type Bus struct {
+	bus *v1.EventBus
+}
+
+// NewBus creates an event bus
+func NewBus() *Bus {
+	return &Bus{bus: v1.NewEventBus()}
+}
+
+// Subscribe adds a handler
+func (b *Bus) Subscribe(h Handler) {
+	b.bus.SubscribeContext(h)
+}
+
+// Publish sends an event to all handlers under ctx. Remaining handlers are
+// skipped once ctx is done; handler errors are joined.
+func (b *Bus) Publish(ctx context.Context, event Event) error {
+	return b.bus.PublishContext(ctx, event)
+}
+
+// V1 returns the underlying v1 bus, for code that has not migrated yet
+func (b *Bus) V1() *v1.EventBus {
+	return b.bus
+}
@@ -0,0 +1,51 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v1 "github.com/Gimel-Foundation/gauth/pkg/events"
+	"github.com/Gimel-Foundation/gauth/v2/events"
+)
+
+type v1Handler struct {
+	seen int
+}
+
+func (h *v1Handler) Handle(v1.Event) { h.seen++ }
+
+func TestBus(t *testing.T) {
+	t.Run("Mixed Handlers", func(t *testing.T) {
+		bus := events.NewBus()
+		legacy := &v1Handler{}
+		bus.Subscribe(events.FromV1(legacy))
+		var ids []string
+		bus.Subscribe(events.HandlerFunc(func(ctx context.Context, e events.Event) error {
+			ids = append(ids, e.CorrelationID)
+			return nil
+		}))
+
+		ctx := v1.ContextWithCorrelationID(context.Background(), "corr-1")
+		if err := bus.Publish(ctx, events.NewEvent()); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+		bus.V1().Publish(v1.NewEvent())
+		if len(ids) != 2 || ids[0] != "corr-1" {
+			t.Errorf("expected correlation ID from ctx on the first event, got %q", ids)
+		}
+		if legacy.seen != 2 {
+			t.Errorf("expected v1 handler to see both events, got %d", legacy.seen)
+		}
+	})
+
+	t.Run("Cancelled Context", func(t *testing.T) {
+		bus := events.NewBus()
+		bus.Subscribe(events.HandlerFunc(func(context.Context, events.Event) error { return nil }))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := bus.Publish(ctx, events.NewEvent()); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+}
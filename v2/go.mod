@@ -0,0 +1,32 @@
+module github.com/Gimel-Foundation/gauth/v2
+
+go 1.23.3
+
+require (
+	github.com/Gimel-Foundation/gauth v1.2.0
+	github.com/alicebob/miniredis/v2 v2.35.0
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/digitorus/pkcs7 v0.0.0-20250730155240-ffadbf3f398c // indirect
+	github.com/go-redis/redis/v8 v8.11.5 // indirect
+	github.com/golang-jwt/jwt/v5 v5.3.0 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/lib/pq v1.10.9 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_golang v1.23.0 // indirect
+	github.com/prometheus/client_model v0.6.2 // indirect
+	github.com/prometheus/common v0.65.0 // indirect
+	github.com/prometheus/procfs v0.16.1 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+	google.golang.org/protobuf v1.36.9 // indirect
+)
+
+// v2 builds on the v1 implementation and requires v1.2.0, the first
+// release with the APIs it wraps. Inside this repository it builds against
+// the working tree; drop the replace when tagging a v2 release.
+replace github.com/Gimel-Foundation/gauth => ../
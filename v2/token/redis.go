@@ -0,0 +1,101 @@
+package token
+
+import (
+	"context"
+	"fmt"
+
+	v1 "github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// redisStore adapts the v1 RedisStore, which keys tokens by ID and takes
+// revocation reasons directly, to Store and BatchStore
+type redisStore struct {
+	*v1.RedisStore
+}
+
+var (
+	_ Store         = redisStore{}
+	_ v1.BatchStore = redisStore{}
+)
+
+// Save implements Store; Redis keys every token by its ID
+func (s redisStore) Save(ctx context.Context, key string, t *Token) error {
+	if key != t.ID {
+		return fmt.Errorf("redis store keys tokens by ID: key %q, ID %q", key, t.ID)
+	}
+	return s.RedisStore.Save(ctx, t)
+}
+
+// Rotate implements Store
+func (s redisStore) Rotate(ctx context.Context, old, newToken *Token) error {
+	if _, err := s.Get(ctx, old.ID); err != nil {
+		return err
+	}
+	if err := s.RedisStore.Save(ctx, newToken); err != nil {
+		return err
+	}
+	return s.Delete(ctx, old.ID)
+}
+
+// Revoke implements Store. The token keeps the revocation status the
+// service set, so the reason and revoker are stored with it.
+func (s redisStore) Revoke(ctx context.Context, t *Token) error {
+	if t.RevocationStatus == nil {
+		return s.RedisStore.Revoke(ctx, t.ID, ReasonPrincipalRequest)
+	}
+	stored, err := s.Get(ctx, t.ID)
+	if err != nil {
+		return err
+	}
+	status := *t.RevocationStatus
+	stored.RevocationStatus = &status
+	return s.RedisStore.Save(ctx, stored)
+}
+
+// Validate implements Store
+func (s redisStore) Validate(ctx context.Context, t *Token) error {
+	stored, err := s.Get(ctx, t.ID)
+	if err != nil {
+		return err
+	}
+	if stored.Value != t.Value {
+		return ErrInvalidToken
+	}
+	if stored.RevocationStatus != nil {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// Refresh implements Store; refreshing is the Service's job
+func (s redisStore) Refresh(context.Context, *Token) (*Token, error) {
+	return nil, v1.ErrInvalidConfig
+}
+
+// Count implements Store
+func (s redisStore) Count(ctx context.Context, filter Filter) (int64, error) {
+	tokens, err := s.List(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(tokens)), nil
+}
+
+// Cleanup implements Store; Redis expires tokens itself
+func (s redisStore) Cleanup(context.Context) error {
+	return nil
+}
+
+// RevokeBatch implements BatchStore. RevokeBatch gives every token of a
+// batch the same reason, so the first one's applies to all.
+func (s redisStore) RevokeBatch(ctx context.Context, tokens []*Token) error {
+	ids := make([]string, len(tokens))
+	for i, t := range tokens {
+		ids[i] = t.ID
+	}
+	reason := ReasonPrincipalRequest
+	if len(tokens) > 0 && tokens[0].RevocationStatus != nil {
+		reason = tokens[0].RevocationStatus.Reason
+	}
+	return s.RedisStore.RevokeBatch(ctx, ids, reason)
+}
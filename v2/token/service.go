@@ -0,0 +1,71 @@
+package token
+
+import (
+	"context"
+
+	v1 "github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// Config configures a Service
+type Config = v1.Config
+
+// Service issues, validates, refreshes and revokes tokens. It replaces the
+// v1 ServiceAPI interface and the *Service it was implemented by.
+type Service struct {
+	svc v1.ServiceAPI
+}
+
+// NewService creates a token service storing tokens in store
+func NewService(config Config, store Store) *Service {
+	return &Service{svc: v1.NewService(config, store)}
+}
+
+// Issue signs a token and stores it. Unset times, type defaults and the
+// signing algorithm are filled in from the configuration.
+func (s *Service) Issue(ctx context.Context, t *Token) (*Token, error) {
+	return s.svc.Issue(ctx, t)
+}
+
+// Parse verifies a signed token value and returns its claims
+func (s *Service) Parse(ctx context.Context, value string) (*Token, error) {
+	return s.svc.Parse(ctx, value)
+}
+
+// Validate checks a token against its signed claims and the store
+func (s *Service) Validate(ctx context.Context, t *Token) error {
+	return s.svc.Validate(ctx, t)
+}
+
+// IsStale reports whether a validated token was only accepted under the
+// grace policy, so the client should refresh it
+func (s *Service) IsStale(t *Token) bool {
+	if stale, ok := s.svc.(interface{ IsStale(*Token) bool }); ok {
+		return stale.IsStale(t)
+	}
+	return v1.IsStale(t, 0)
+}
+
+// Refresh exchanges a refresh token for a new access token
+func (s *Service) Refresh(ctx context.Context, refreshToken *Token) (*Token, error) {
+	return s.svc.Refresh(ctx, refreshToken)
+}
+
+// Revoke revokes a token for the given reason
+func (s *Service) Revoke(ctx context.Context, t *Token, reason RevocationReason) error {
+	return s.svc.Revoke(ctx, t, reason)
+}
+
+// Get returns a stored token by ID
+func (s *Service) Get(ctx context.Context, id string) (*Token, error) {
+	return s.svc.GetToken(ctx, id)
+}
+
+// List returns the stored tokens matching filter
+func (s *Service) List(ctx context.Context, filter Filter) ([]*Token, error) {
+	return s.svc.List(ctx, filter)
+}
+
+// V1 returns the underlying v1 service, for code that has not migrated yet
+func (s *Service) V1() v1.ServiceAPI {
+	return s.svc
+}
@@ -0,0 +1,137 @@
+package token
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	v1 "github.com/Gimel-Foundation/gauth/pkg/token"
+)
+
+// Store persists tokens. v1 stores implement it unchanged.
+type Store = v1.Store
+
+// ErrStoreConfig indicates a StoreConfig that selects more than one backend
+var ErrStoreConfig = errors.New("at most one store backend may be configured")
+
+// StoreConfig selects and configures the storage backend opened by
+// NewStore. At most one backend may be set; with none, tokens are kept in
+// memory.
+type StoreConfig struct {
+	// Memory keeps tokens in a single map (optional)
+	Memory *MemoryConfig
+
+	// Sharded keeps tokens in independently locked shards, for high write
+	// rates (optional)
+	Sharded *ShardedMemoryConfig
+
+	// SQL stores tokens in a database (optional)
+	SQL *SQLConfig
+
+	// Redis stores tokens in Redis (optional)
+	Redis *RedisConfig
+}
+
+// MemoryConfig configures the in-memory store
+type MemoryConfig struct {
+	// TTL removes tokens this long after they expire (optional)
+	TTL time.Duration
+}
+
+// ShardedMemoryConfig configures the sharded in-memory store
+type ShardedMemoryConfig = v1.ShardedMemoryConfig
+
+// SQLConfig configures the SQL store
+type SQLConfig struct {
+	// DB is an open database to use instead of connecting with DSN
+	// (optional)
+	DB *sql.DB
+
+	v1.SQLStoreConfig
+}
+
+// RedisConfig configures the Redis store
+type RedisConfig = v1.RedisConfig
+
+// FailoverConfig configures a replicated pair of stores
+type FailoverConfig = v1.FailoverConfig
+
+// NewStore opens the backend selected by config
+func NewStore(ctx context.Context, config StoreConfig) (Store, error) {
+	set := 0
+	for _, backend := range []bool{config.Memory != nil, config.Sharded != nil, config.SQL != nil, config.Redis != nil} {
+		if backend {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, ErrStoreConfig
+	}
+
+	switch {
+	case config.Sharded != nil:
+		return v1.NewShardedMemoryStore(*config.Sharded), nil
+	case config.SQL != nil && config.SQL.DB != nil:
+		store, err := v1.NewSQLStoreFromDB(ctx, config.SQL.DB)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open SQL store: %w", err)
+		}
+		return store, nil
+	case config.SQL != nil:
+		store, err := v1.NewSQLStore(config.SQL.SQLStoreConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open SQL store: %w", err)
+		}
+		return store, nil
+	case config.Redis != nil:
+		store, err := v1.NewRedisStore(*config.Redis)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open Redis store: %w", err)
+		}
+		return redisStore{store}, nil
+	case config.Memory != nil && config.Memory.TTL > 0:
+		return v1.NewMemoryStore(config.Memory.TTL), nil
+	default:
+		return v1.NewMemoryStore(), nil
+	}
+}
+
+// NewFailoverStore replicates writes from config.Primary to
+// config.Secondary, which serves reads while the primary fails
+func NewFailoverStore(config FailoverConfig) (Store, error) {
+	store, err := v1.NewFailoverStore(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create failover store: %w", err)
+	}
+	return store, nil
+}
+
+// ListPage lists one page of tokens. Pass NextCursor back in Filter.Cursor
+// to fetch the following page.
+func ListPage(ctx context.Context, store Store, filter Filter) (*Page, error) {
+	return v1.ListPage(ctx, store, filter)
+}
+
+// SaveBatch stores tokens under their IDs, in one round trip where the
+// backend supports it
+func SaveBatch(ctx context.Context, store Store, tokens []*Token) error {
+	return v1.SaveBatch(ctx, store, tokens)
+}
+
+// RevokeBatch revokes tokens for the given reason
+func RevokeBatch(ctx context.Context, store Store, tokens []*Token, reason RevocationReason) error {
+	return v1.RevokeBatch(ctx, store, tokens, reason)
+}
+
+// DeleteBatch removes the tokens stored under keys
+func DeleteBatch(ctx context.Context, store Store, keys []string) error {
+	return v1.DeleteBatch(ctx, store, keys)
+}
+
+// RevokeMatching revokes every token matching filter and returns how many
+// were revoked
+func RevokeMatching(ctx context.Context, store Store, filter Filter, reason RevocationReason) (int, error) {
+	return v1.RevokeMatching(ctx, store, filter, reason)
+}
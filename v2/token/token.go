@@ -0,0 +1,78 @@
+// Package token is the v2 token package. It consolidates token types, the
+// token service and every storage backend, which v1 split across pkg/token,
+// pkg/tokenstore and internal/tokenstore.
+//
+// The token model and the errors are shared with v1, so tokens issued by v1
+// code validate in v2 code and errors.Is matches across both. The service
+// and stores have their own v2 API: Service is the one service type and
+// NewStore the one way to open any backend.
+package token
+
+import v1 "github.com/Gimel-Foundation/gauth/pkg/token"
+
+// Token model, shared with v1
+type (
+	Token            = v1.Token
+	Type             = v1.Type
+	Metadata         = v1.Metadata
+	DelegationChain  = v1.DelegationChain
+	DelegationLink   = v1.DelegationLink
+	Restrictions     = v1.Restrictions
+	Attestation      = v1.Attestation
+	RevocationReason = v1.RevocationReason
+	Filter           = v1.Filter
+	Page             = v1.Page
+)
+
+// Token types
+const (
+	Access  = v1.Access
+	Refresh = v1.Refresh
+)
+
+// Revocation reasons
+const (
+	ReasonCompromise       = v1.ReasonCompromise
+	ReasonPolicyChange     = v1.ReasonPolicyChange
+	ReasonMandateExpired   = v1.ReasonMandateExpired
+	ReasonPrincipalRequest = v1.ReasonPrincipalRequest
+	ReasonSecurityIncident = v1.ReasonSecurityIncident
+	ReasonSuperseded       = v1.ReasonSuperseded
+)
+
+// Errors, identical to their v1 counterparts
+var (
+	ErrTokenNotFound          = v1.ErrTokenNotFound
+	ErrTokenExpired           = v1.ErrTokenExpired
+	ErrTokenRevoked           = v1.ErrTokenRevoked
+	ErrInvalidToken           = v1.ErrInvalidToken
+	ErrInvalidDelegationChain = v1.ErrInvalidDelegationChain
+	ErrInvalidCursor          = v1.ErrInvalidCursor
+)
+
+// ValidationError is returned by Service.Validate; Code says why the token
+// was rejected
+type (
+	ValidationError     = v1.ValidationError
+	ValidationErrorCode = v1.ValidationErrorCode
+)
+
+// Validation error codes
+const (
+	ValidationCodeExpired           = v1.ValidationCodeExpired
+	ValidationCodeNotFound          = v1.ValidationCodeNotFound
+	ValidationCodeInvalid           = v1.ValidationCodeInvalid
+	ValidationCodeRevoked           = v1.ValidationCodeRevoked
+	ValidationCodeNotYetValid       = v1.ValidationCodeNotYetValid
+	ValidationCodeInvalidAudience   = v1.ValidationCodeInvalidAudience
+	ValidationCodeInvalidIssuer     = v1.ValidationCodeInvalidIssuer
+	ValidationCodeInvalidType       = v1.ValidationCodeInvalidType
+	ValidationCodeInvalidSignature  = v1.ValidationCodeInvalidSignature
+	ValidationCodeInvalidClaims     = v1.ValidationCodeInvalidClaims
+	ValidationCodeInsufficientScope = v1.ValidationCodeInsufficientScope
+)
+
+// NewID returns a random token ID
+func NewID() string {
+	return v1.NewID()
+}
@@ -0,0 +1,215 @@
+package token_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	v1 "github.com/Gimel-Foundation/gauth/pkg/token"
+	"github.com/Gimel-Foundation/gauth/v2/token"
+)
+
+func newToken(subject string) *token.Token {
+	return &token.Token{
+		ID:      token.NewID(),
+		Type:    token.Access,
+		Subject: subject,
+		Scopes:  []string{"read"},
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Default Memory", func(t *testing.T) {
+		store, err := token.NewStore(ctx, token.StoreConfig{})
+		if err != nil {
+			t.Fatalf("NewStore() error: %v", err)
+		}
+		if _, ok := store.(*v1.MemoryStore); !ok {
+			t.Errorf("Expected a memory store by default, got %T", store)
+		}
+	})
+
+	t.Run("Sharded", func(t *testing.T) {
+		store, err := token.NewStore(ctx, token.StoreConfig{Sharded: &token.ShardedMemoryConfig{Shards: 4}})
+		if err != nil {
+			t.Fatalf("NewStore() error: %v", err)
+		}
+		tokens := []*token.Token{newToken("a"), newToken("b"), newToken("c")}
+		if err := token.SaveBatch(ctx, store, tokens); err != nil {
+			t.Fatalf("SaveBatch() error: %v", err)
+		}
+		page, err := token.ListPage(ctx, store, token.Filter{Limit: 2})
+		if err != nil {
+			t.Fatalf("ListPage() error: %v", err)
+		}
+		if len(page.Tokens) != 2 || page.NextCursor == "" {
+			t.Errorf("Expected a first page of 2 with a cursor, got %d, %q", len(page.Tokens), page.NextCursor)
+		}
+	})
+
+	t.Run("Two Backends", func(t *testing.T) {
+		_, err := token.NewStore(ctx, token.StoreConfig{
+			Memory:  &token.MemoryConfig{},
+			Sharded: &token.ShardedMemoryConfig{},
+		})
+		if !errors.Is(err, token.ErrStoreConfig) {
+			t.Errorf("Expected ErrStoreConfig, got %v", err)
+		}
+	})
+
+	t.Run("Redis", func(t *testing.T) {
+		mr, err := miniredis.Run()
+		if err != nil {
+			t.Fatalf("miniredis.Run() error: %v", err)
+		}
+		defer mr.Close()
+
+		store, err := token.NewStore(ctx, token.StoreConfig{Redis: &token.RedisConfig{
+			Addresses:  []string{mr.Addr()},
+			KeyPrefix:  "v2:",
+			DefaultTTL: time.Hour,
+		}})
+		if err != nil {
+			t.Fatalf("NewStore() error: %v", err)
+		}
+
+		tok := newToken("redis-user")
+		tok.Value = "value-1"
+		if err := store.Save(ctx, "other-key", tok); err == nil {
+			t.Error("Expected a key other than the ID to be rejected")
+		}
+		if err := store.Save(ctx, tok.ID, tok); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+		if err := store.Validate(ctx, tok); err != nil {
+			t.Errorf("Validate() error: %v", err)
+		}
+		if err := token.RevokeBatch(ctx, store, []*token.Token{tok}, token.ReasonCompromise); err != nil {
+			t.Fatalf("RevokeBatch() error: %v", err)
+		}
+		if err := store.Validate(ctx, tok); !errors.Is(err, token.ErrTokenRevoked) {
+			t.Errorf("Expected ErrTokenRevoked, got %v", err)
+		}
+		stored, err := store.Get(ctx, tok.ID)
+		if err != nil {
+			t.Fatalf("Get() error: %v", err)
+		}
+		if stored.RevocationStatus == nil || stored.RevocationStatus.Reason != token.ReasonCompromise {
+			t.Errorf("Expected the compromise reason to be stored, got %+v", stored.RevocationStatus)
+		}
+	})
+}
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	store, err := token.NewStore(ctx, token.StoreConfig{})
+	if err != nil {
+		t.Fatalf("NewStore() error: %v", err)
+	}
+	svc := token.NewService(token.Config{SigningKey: key, ValidityPeriod: time.Hour}, store)
+
+	issued, err := svc.Issue(ctx, newToken("agent-1"))
+	if err != nil {
+		t.Fatalf("Issue() error: %v", err)
+	}
+
+	t.Run("Parse And Validate", func(t *testing.T) {
+		parsed, err := svc.Parse(ctx, issued.Value)
+		if err != nil {
+			t.Fatalf("Parse() error: %v", err)
+		}
+		if parsed.ID != issued.ID || parsed.Subject != "agent-1" {
+			t.Errorf("Unexpected parsed token %+v", parsed)
+		}
+		if err := svc.Validate(ctx, parsed); err != nil {
+			t.Errorf("Validate() error: %v", err)
+		}
+		if svc.IsStale(parsed) {
+			t.Error("Expected a fresh token not to be stale")
+		}
+	})
+
+	t.Run("Get And List", func(t *testing.T) {
+		got, err := svc.Get(ctx, issued.ID)
+		if err != nil {
+			t.Fatalf("Get() error: %v", err)
+		}
+		if got.Value != issued.Value {
+			t.Errorf("Expected the stored value, got %q", got.Value)
+		}
+		listed, err := svc.List(ctx, token.Filter{Subject: "agent-1"})
+		if err != nil {
+			t.Fatalf("List() error: %v", err)
+		}
+		if len(listed) != 1 {
+			t.Errorf("Expected 1 token for agent-1, got %d", len(listed))
+		}
+	})
+
+	t.Run("V1 Interop", func(t *testing.T) {
+		// Tokens issued through the v1 service validate through v2
+		legacy, err := svc.V1().Issue(ctx, &v1.Token{ID: v1.NewID(), Type: v1.Access, Subject: "legacy"})
+		if err != nil {
+			t.Fatalf("V1().Issue() error: %v", err)
+		}
+		if err := svc.Validate(ctx, legacy); err != nil {
+			t.Errorf("Validate() error: %v", err)
+		}
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		if err := svc.Revoke(ctx, issued, token.ReasonPrincipalRequest); err != nil {
+			t.Fatalf("Revoke() error: %v", err)
+		}
+		var verr *token.ValidationError
+		if err := svc.Validate(ctx, issued); !errors.As(err, &verr) || verr.Code != token.ValidationCodeRevoked {
+			t.Errorf("Expected a revoked validation error, got %v", err)
+		}
+		if _, err := svc.Get(ctx, fmt.Sprintf("missing-%s", issued.ID)); !errors.Is(err, token.ErrTokenNotFound) {
+			t.Errorf("Expected ErrTokenNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Redis Backed", func(t *testing.T) {
+		mr, err := miniredis.Run()
+		if err != nil {
+			t.Fatalf("miniredis.Run() error: %v", err)
+		}
+		defer mr.Close()
+		store, err := token.NewStore(ctx, token.StoreConfig{Redis: &token.RedisConfig{Addresses: []string{mr.Addr()}}})
+		if err != nil {
+			t.Fatalf("NewStore() error: %v", err)
+		}
+		svc := token.NewService(token.Config{SigningKey: key, ValidityPeriod: time.Hour}, store)
+
+		tok, err := svc.Issue(ctx, newToken("redis-agent"))
+		if err != nil {
+			t.Fatalf("Issue() error: %v", err)
+		}
+		if err := svc.Validate(ctx, tok); err != nil {
+			t.Errorf("Validate() error: %v", err)
+		}
+		if err := svc.Revoke(ctx, tok, token.ReasonSuperseded); err != nil {
+			t.Fatalf("Revoke() error: %v", err)
+		}
+		stored, err := svc.Get(ctx, tok.ID)
+		if err != nil {
+			t.Fatalf("Get() error: %v", err)
+		}
+		if stored.RevocationStatus == nil || stored.RevocationStatus.Reason != token.ReasonSuperseded {
+			t.Errorf("Expected the superseded reason to be stored, got %+v", stored.RevocationStatus)
+		}
+	})
+}